@@ -0,0 +1,152 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/log"
+)
+
+// Optional adaptive attestation interval, stretching the time between
+// attestations in proportion to the mempool fee rate when it is above a
+// ceiling and shortening it when below a floor, bounded by min and max
+// intervals, so that fewer attestations are made while fees are high
+
+// adaptive interval consts
+const (
+	DefaultAdaptiveMaxFactor = 4 // max interval as a multiple of the new attestation time
+	AdaptiveFeeTarget        = 6 // confirmation target in blocks of the fee rate estimate
+
+	ErrorAdaptiveUnsupported = "Chain backend does not support fee rate estimation"
+	ErrorAdaptiveEstimate    = "Fee rate estimate not available"
+
+	WarningInvalidAdaptiveFeeCeilingArg = "Invalid adaptive fee ceiling config value"
+	WarningInvalidAdaptiveFeeFloorArg   = "Invalid adaptive fee floor config value"
+	WarningInvalidAdaptiveMinArg        = "Invalid adaptive min interval config value"
+	WarningInvalidAdaptiveMaxArg        = "Invalid adaptive max interval config value"
+	WarningAdaptiveFeeRate              = "Could not estimate fee rate - using new attestation time"
+)
+
+// chain backend estimating the fee rate in satoshis per vbyte
+// for confirmation within the target number of blocks
+type feeRateEstimator interface {
+	EstimateFeeRate(int) (float64, error)
+}
+
+// estimatesmartfee rpc result
+type smartFeeResult struct {
+	FeeRate float64  `json:"feerate"`
+	Errors  []string `json:"errors"`
+}
+
+// Return fee rate in satoshis per vbyte for confirmation within
+// the target number of blocks through the estimatesmartfee rpc
+func rpcEstimateFeeRate(client rawRequester, target int) (float64, error) {
+	targetJson, _ := json.Marshal(target)
+	resultJson, resultErr := client.RawRequest("estimatesmartfee", []json.RawMessage{targetJson})
+	if resultErr != nil {
+		return 0, resultErr
+	}
+	var result smartFeeResult
+	if unmarshalErr := json.Unmarshal(resultJson, &result); unmarshalErr != nil {
+		return 0, unmarshalErr
+	}
+	if result.FeeRate <= 0 {
+		return 0, errors.New(fmt.Sprintf("%s %v", ErrorAdaptiveEstimate, result.Errors))
+	}
+	return result.FeeRate * 1e8 / 1000, nil // btc per kvbyte
+}
+
+// Return fee rate estimate from the chain backend
+func chainFeeRate(chain ChainBackend, target int) (float64, error) {
+	if estimator, ok := chain.(feeRateEstimator); ok {
+		return estimator.EstimateFeeRate(target)
+	} else if client, ok := chain.(rawRequester); ok {
+		return rpcEstimateFeeRate(client, target)
+	}
+	return 0, errors.New(ErrorAdaptiveUnsupported)
+}
+
+// Set adaptive interval fee rates and bounds from adaptive config
+// Must be set after the timing config as bounds default to the new attestation time
+func (s *AttestService) setAdaptiveConfig(adaptiveConfig confpkg.AdaptiveConfig) {
+	s.adaptiveFeeCeiling = 0
+	if adaptiveConfig.FeeCeiling > 0 {
+		s.adaptiveFeeCeiling = float64(adaptiveConfig.FeeCeiling)
+		log.Infof("Adaptive fee ceiling set to: %d\n", adaptiveConfig.FeeCeiling)
+	} else if adaptiveConfig.FeeCeiling != -1 {
+		log.Warnf("%s (%v)\n", WarningInvalidAdaptiveFeeCeilingArg, adaptiveConfig.FeeCeiling)
+	}
+	s.adaptiveFeeFloor = 0
+	if adaptiveConfig.FeeFloor > 0 && (s.adaptiveFeeCeiling == 0 || float64(adaptiveConfig.FeeFloor) <= s.adaptiveFeeCeiling) {
+		s.adaptiveFeeFloor = float64(adaptiveConfig.FeeFloor)
+		log.Infof("Adaptive fee floor set to: %d\n", adaptiveConfig.FeeFloor)
+	} else if adaptiveConfig.FeeFloor != -1 {
+		log.Warnf("%s (%v)\n", WarningInvalidAdaptiveFeeFloorArg, adaptiveConfig.FeeFloor)
+	}
+	if !s.isAdaptive() {
+		return
+	}
+
+	s.atimeAdaptiveMin = s.atimeNewAttestation
+	if adaptiveConfig.MinMinutes > 0 && time.Duration(adaptiveConfig.MinMinutes)*time.Minute <= s.atimeNewAttestation {
+		s.atimeAdaptiveMin = time.Duration(adaptiveConfig.MinMinutes) * time.Minute
+	} else if adaptiveConfig.MinMinutes != -1 {
+		log.Warnf("%s (%v)\n", WarningInvalidAdaptiveMinArg, adaptiveConfig.MinMinutes)
+	}
+	s.atimeAdaptiveMax = DefaultAdaptiveMaxFactor * s.atimeNewAttestation
+	if adaptiveConfig.MaxMinutes > 0 && time.Duration(adaptiveConfig.MaxMinutes)*time.Minute >= s.atimeNewAttestation {
+		s.atimeAdaptiveMax = time.Duration(adaptiveConfig.MaxMinutes) * time.Minute
+	} else if adaptiveConfig.MaxMinutes != -1 {
+		log.Warnf("%s (%v)\n", WarningInvalidAdaptiveMaxArg, adaptiveConfig.MaxMinutes)
+	}
+	log.Infof("Time adaptive interval set to: %v - %v\n", s.atimeAdaptiveMin, s.atimeAdaptiveMax)
+}
+
+// Return true if the attestation interval adapts to the fee rate
+func (s *AttestService) isAdaptive() bool {
+	return s.adaptiveFeeCeiling > 0 || s.adaptiveFeeFloor > 0
+}
+
+// Return new attestation time for the fee rate, in proportion to the fee rate
+// above the ceiling or below the floor and bounded by the min and max interval
+func (s *AttestService) adaptiveInterval(feeRate float64) time.Duration {
+	interval := s.atimeNewAttestation
+	if s.adaptiveFeeCeiling > 0 && feeRate > s.adaptiveFeeCeiling {
+		interval = time.Duration(float64(s.atimeNewAttestation) * feeRate / s.adaptiveFeeCeiling)
+	} else if s.adaptiveFeeFloor > 0 && feeRate < s.adaptiveFeeFloor {
+		interval = time.Duration(float64(s.atimeNewAttestation) * feeRate / s.adaptiveFeeFloor)
+	}
+	if interval < s.atimeAdaptiveMin {
+		interval = s.atimeAdaptiveMin
+	} else if interval > s.atimeAdaptiveMax {
+		interval = s.atimeAdaptiveMax
+	}
+	return interval
+}
+
+// Return time until the next attestation, adapted to the current fee rate
+// if configured. The configured new attestation time is used if the fee
+// rate cannot be estimated
+func (s *AttestService) newAttestationTime() time.Duration {
+	if !s.isAdaptive() {
+		return s.atimeNewAttestation
+	}
+	feeRate, feeRateErr := chainFeeRate(s.attester.Chain, AdaptiveFeeTarget)
+	if feeRateErr != nil {
+		s.logger().Warnf("%s: %v\n", WarningAdaptiveFeeRate, feeRateErr)
+		return s.atimeNewAttestation
+	}
+	interval := s.adaptiveInterval(feeRate)
+	if interval != s.atimeNewAttestation {
+		s.logger().Infof("new attestation time adapted to %v at fee rate %.1f sat/vbyte\n", interval, feeRate)
+	}
+	return interval
+}
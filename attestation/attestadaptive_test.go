@@ -0,0 +1,77 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	confpkg "mainstay/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test adaptive interval stretches above the fee ceiling and
+// shortens below the fee floor within the min and max bounds
+func TestAdaptiveInterval(t *testing.T) {
+	s := &AttestService{}
+	s.setTimingConfig(confpkg.TimingConfig{60, -1, -1, -1, -1, -1, -1})
+
+	s.setAdaptiveConfig(confpkg.AdaptiveConfig{-1, -1, -1, -1})
+	assert.Equal(t, false, s.isAdaptive())
+
+	// default bounds do not shorten the interval
+	s.setAdaptiveConfig(confpkg.AdaptiveConfig{20, 5, -1, -1})
+	assert.Equal(t, true, s.isAdaptive())
+	assert.Equal(t, 60*time.Minute, s.atimeAdaptiveMin)
+	assert.Equal(t, 240*time.Minute, s.atimeAdaptiveMax)
+	assert.Equal(t, 60*time.Minute, s.adaptiveInterval(1))
+	assert.Equal(t, 60*time.Minute, s.adaptiveInterval(20))
+	assert.Equal(t, 90*time.Minute, s.adaptiveInterval(30))
+	assert.Equal(t, 240*time.Minute, s.adaptiveInterval(500))
+
+	s.setAdaptiveConfig(confpkg.AdaptiveConfig{20, 5, 15, 180})
+	assert.Equal(t, 60*time.Minute, s.adaptiveInterval(5))
+	assert.Equal(t, 60*time.Minute, s.adaptiveInterval(12.5))
+	assert.Equal(t, 36*time.Minute, s.adaptiveInterval(3))
+	assert.Equal(t, 15*time.Minute, s.adaptiveInterval(1))
+	assert.Equal(t, 120*time.Minute, s.adaptiveInterval(40))
+	assert.Equal(t, 180*time.Minute, s.adaptiveInterval(100))
+
+	// floor only
+	s.setAdaptiveConfig(confpkg.AdaptiveConfig{-1, 10, 30, -1})
+	assert.Equal(t, 60*time.Minute, s.adaptiveInterval(500))
+	assert.Equal(t, 30*time.Minute, s.adaptiveInterval(5))
+
+	// invalid values ignored
+	s.setAdaptiveConfig(confpkg.AdaptiveConfig{5, 20, 90, 30})
+	assert.Equal(t, float64(5), s.adaptiveFeeCeiling)
+	assert.Equal(t, float64(0), s.adaptiveFeeFloor)
+	assert.Equal(t, 60*time.Minute, s.atimeAdaptiveMin)
+	assert.Equal(t, 240*time.Minute, s.atimeAdaptiveMax)
+}
+
+// Test fee rate estimates from the rpc and esplora backends
+func TestChainFeeRate(t *testing.T) {
+	feeRate, feeRateErr := chainFeeRate(&syncTestChain{info: `{"feerate":0.00012,"blocks":6}`}, 6)
+	assert.Equal(t, nil, feeRateErr)
+	assert.InDelta(t, 12, feeRate, 1e-9)
+
+	_, feeRateErr = chainFeeRate(&syncTestChain{info: `{"errors":["Insufficient data or no feerate found"],"blocks":0}`}, 6)
+	assert.Equal(t, ErrorAdaptiveEstimate+" [Insufficient data or no feerate found]", feeRateErr.Error())
+
+	server := httptest.NewServer(&esploraFake{fees: map[string]float64{"1": 40.5, "6": 22.1}})
+	defer server.Close()
+	client := NewEsploraClient(confpkg.EsploraConfig{Url: server.URL}, "", "")
+	feeRate, feeRateErr = chainFeeRate(client, 6)
+	assert.Equal(t, nil, feeRateErr)
+	assert.Equal(t, 22.1, feeRate)
+	_, feeRateErr = chainFeeRate(client, 3)
+	assert.Equal(t, ErrorAdaptiveEstimate+": target 3", feeRateErr.Error())
+
+	_, feeRateErr = chainFeeRate(struct{ ChainBackend }{}, 6)
+	assert.Equal(t, ErrorAdaptiveUnsupported, feeRateErr.Error())
+}
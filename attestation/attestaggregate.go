@@ -0,0 +1,90 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/log"
+	"mainstay/models"
+)
+
+// Worker pre-aggregating client commitments into cached merkle subtrees as
+// commitments arrive between attestation rounds, so that at round close the
+// commitment tree is built from the cached roots of unchanged subtrees
+
+// aggregation consts
+const (
+	DefaultAggregationInterval = 10 * time.Second
+
+	WarningInvalidAggregationIntervalArg = "Invalid aggregation interval config value"
+	WarningAggregationFailed             = "Commitment pre-aggregation failed"
+)
+
+// AttestAggregator struct
+// Periodically updates the merkle subtrees cached by the attest server
+// with the latest client commitments
+type AttestAggregator struct {
+	ctx        context.Context
+	wg         *sync.WaitGroup
+	server     *AttestServer
+	aggregator *models.CommitmentAggregator
+	interval   time.Duration
+}
+
+// Return new AttestAggregator from aggregation config, setting
+// the subtree cache used by the server to build commitments
+func NewAttestAggregator(ctx context.Context, wg *sync.WaitGroup, server *AttestServer,
+	config confpkg.AggregationConfig) (*AttestAggregator, error) {
+
+	aggregator, aggregatorErr := models.NewCommitmentAggregator(config.SubtreeSize)
+	if aggregatorErr != nil {
+		return nil, aggregatorErr
+	}
+	interval := DefaultAggregationInterval
+	if config.IntervalSeconds > 0 {
+		interval = time.Duration(config.IntervalSeconds) * time.Second
+	} else if config.IntervalSeconds != -1 {
+		log.Warnf("%s (%d)\n", WarningInvalidAggregationIntervalArg, config.IntervalSeconds)
+	}
+	server.SetAggregator(aggregator)
+	return &AttestAggregator{ctx, wg, server, aggregator, interval}, nil
+}
+
+// Run aggregation until the context is cancelled
+func (a *AttestAggregator) Run() {
+	defer a.wg.Done()
+	log.Infof("*Aggregator* Pre-aggregating commitments every %s\n", a.interval.String())
+
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		if rebuilt, err := a.aggregate(); err != nil {
+			log.WithFields(log.Fields{log.FieldError: err}).Warnln(WarningAggregationFailed)
+		} else if rebuilt > 0 {
+			log.Debugf("*Aggregator* Rebuilt %d merkle subtrees\n", rebuilt)
+		}
+		select {
+		case <-a.ctx.Done():
+			log.Infoln("Shutting down commitment aggregator...")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Update cached subtrees with the latest client commitments
+// returning the number of subtrees rebuilt
+func (a *AttestAggregator) aggregate() (int, error) {
+	latestCommitments, latestErr := a.server.dbInterface.GetClientCommitments()
+	if latestErr != nil {
+		return 0, latestErr
+	}
+	commitmentHashes, _, _ := a.server.clientCommitmentHashes(latestCommitments)
+	return a.aggregator.Update(commitmentHashes), nil
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	confpkg "mainstay/config"
+	"mainstay/db"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test pre-aggregated commitments match commitments built at round close
+func TestAttestAggregator(t *testing.T) {
+	dbFake := db.NewDbFake()
+	server := NewAttestServer(dbFake)
+
+	_, aggregatorErr := NewAttestAggregator(context.Background(), &sync.WaitGroup{}, server,
+		confpkg.AggregationConfig{SubtreeSize: 3, IntervalSeconds: -1})
+	assert.Equal(t, models.ErrorSubtreeSize+": 3", aggregatorErr.Error())
+	assert.Nil(t, server.aggregator)
+
+	aggregator, aggregatorErr := NewAttestAggregator(context.Background(), &sync.WaitGroup{}, server,
+		confpkg.AggregationConfig{SubtreeSize: 4, IntervalSeconds: -1})
+	assert.Equal(t, nil, aggregatorErr)
+	assert.Equal(t, DefaultAggregationInterval, aggregator.interval)
+	assert.Equal(t, aggregator.aggregator, server.aggregator)
+
+	var hashes []chainhash.Hash
+	for i := 0; i < 11; i++ {
+		hash := chainhash.DoubleHashH([]byte{byte(i)})
+		hashes = append(hashes, hash)
+		if i != 6 { // unused position
+			dbFake.SaveClientCommitment(models.ClientCommitment{Commitment: hash, ClientPosition: int32(i)})
+		}
+	}
+	hashes[6] = chainhash.Hash{}
+
+	rebuilt, aggregateErr := aggregator.aggregate()
+	assert.Equal(t, nil, aggregateErr)
+	assert.Equal(t, 3, rebuilt)
+
+	// commitment arriving after aggregation
+	hashes[9] = chainhash.DoubleHashH([]byte{9, 9})
+	dbFake.SaveClientCommitment(models.ClientCommitment{Commitment: hashes[9], ClientPosition: 9, RequestId: "req9"})
+
+	commitment, commitmentErr := server.GetClientCommitment()
+	assert.Equal(t, nil, commitmentErr)
+	expected, _ := models.NewCommitment(hashes)
+	assert.Equal(t, expected.GetCommitmentHash(), commitment.GetCommitmentHash())
+	assert.Equal(t, expected.GetMerkleProofs(), commitment.GetMerkleProofs())
+	assert.Equal(t, map[int32]string{9: "req9"}, commitment.RequestIds())
+
+	// only the changed subtree is outstanding
+	rebuilt, _ = aggregator.aggregate()
+	assert.Equal(t, 0, rebuilt)
+}
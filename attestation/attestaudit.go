@@ -0,0 +1,238 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	confpkg "mainstay/config"
+	"mainstay/db"
+	"mainstay/log"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Staychain integrity audit cross checking every confirmed attestation stored
+// against the main chain. The staychain is indexed from the base transaction,
+// so that there is exactly one chain of spends to compare with, and each
+// attestation must be a transaction of that chain paying the whole amount
+// spent, less the fee, to the address tweaked with its merkle root, of the
+// keys of the key rotation active at the height of the attestation. The
+// attestation info stored must match the block and amounts found on-chain.
+//
+// Stored info not matching the main chain is repaired by storing the info
+// found on-chain, while attestations off the staychain are repaired by
+// marking them unconfirmed. Staychain transactions without attestation are
+// reported only, as their merkle roots are recovered by the backfill tool
+
+// audit inconsistency reasons
+const (
+	AuditReasonRootInvalid     = "Invalid attestation merkle root"
+	AuditReasonNotOnStaychain  = "Attestation not on the staychain spent from the base transaction"
+	AuditReasonAddressMismatch = "Attestation output not derived from attestation merkle root"
+	AuditReasonAmountMismatch  = "Attestation amount does not decrease only by the fee"
+	AuditReasonInfoMismatch    = "Stored attestation info does not match the main chain"
+	AuditReasonMissing         = "Staychain transaction without confirmed attestation"
+)
+
+// AuditInconsistency struct
+// Inconsistency of an attestation or staychain transaction found by the
+// audit and whether it is repaired by storing what is found on-chain
+type AuditInconsistency struct {
+	Txid       string
+	MerkleRoot string
+	Reason     string
+	Repairable bool
+}
+
+// AuditResult struct
+// Number of attestations audited and staychain transactions
+// indexed with the inconsistencies found and number repaired
+type AuditResult struct {
+	Attestations    int
+	Staychain       int
+	Inconsistencies []AuditInconsistency
+	Repaired        int
+}
+
+// AttestAudit struct
+// Audits the attestations stored against the staychain of the attest client
+type AttestAudit struct {
+	attester    *AttestClient
+	dbInterface db.Db
+	indexer     *AttestIndexer
+
+	// keys of the key rotation active at each staychain height
+	history attestKeyHistory
+}
+
+// Return new AttestAudit instance for the staychain of the attest
+// client, indexing every confirmed staychain transaction
+func NewAttestAudit(ctx context.Context, attester *AttestClient, dbInterface db.Db) *AttestAudit {
+	indexer := NewAttestIndexer(ctx, &sync.WaitGroup{}, dbInterface, attester.Chain, attester.txid0,
+		confpkg.IndexerConfig{IntervalSeconds: -1, Confirmations: 1})
+	return &AttestAudit{attester: attester, dbInterface: dbInterface, indexer: indexer}
+}
+
+// Audit confirmed attestations, oldest first, and the staychain transactions
+// indexed, repairing any repairable inconsistencies if repair is set
+func (a *AttestAudit) Audit(repair bool) (AuditResult, error) {
+	indexed, indexErr := a.indexer.index()
+	if indexErr != nil {
+		return AuditResult{}, indexErr
+	}
+	log.Infof("*Audit* Indexed %d staychain transactions\n", indexed)
+	rotations, rotationsErr := a.dbInterface.GetKeyRotations()
+	if rotationsErr != nil {
+		return AuditResult{}, rotationsErr
+	}
+	history, historyErr := a.attester.keyHistory(rotations, staychainTxHeight(a.dbInterface))
+	if historyErr != nil {
+		return AuditResult{}, historyErr
+	}
+	a.history = history
+
+	attestations, attestationsErr := a.dbInterface.GetAttestations()
+	if attestationsErr != nil {
+		return AuditResult{}, attestationsErr
+	}
+	result := AuditResult{Attestations: len(attestations)}
+	audited := make(map[string]bool)
+	for _, attestation := range attestations {
+		inconsistency, repaired, auditErr := a.auditAttestation(attestation, repair)
+		if auditErr != nil {
+			return result, auditErr
+		} else if inconsistency != nil {
+			result.Inconsistencies = append(result.Inconsistencies, *inconsistency)
+			if repaired {
+				result.Repaired++
+			}
+			if inconsistency.Reason == AuditReasonNotOnStaychain {
+				continue
+			}
+		}
+		audited[attestation.Txid] = true
+	}
+
+	// every staychain transaction after the base transaction is an attestation
+	tx, txErr := a.dbInterface.GetStaychainTx(a.attester.txid0)
+	for txErr == nil && tx.SpentBy != "" {
+		if tx, txErr = a.dbInterface.GetStaychainTx(tx.SpentBy); txErr != nil {
+			return result, txErr
+		}
+		result.Staychain++
+		if !audited[tx.Txid] {
+			result.Inconsistencies = append(result.Inconsistencies,
+				AuditInconsistency{Txid: tx.Txid, Reason: AuditReasonMissing})
+		}
+	}
+	return result, txErr
+}
+
+// Audit single attestation and return the inconsistency found, if any, and
+// whether this was repaired
+func (a *AttestAudit) auditAttestation(attestation models.AttestationBSON, repair bool) (
+	*AuditInconsistency, bool, error) {
+
+	inconsistency := &AuditInconsistency{Txid: attestation.Txid, MerkleRoot: attestation.MerkleRoot}
+	root, rootErr := chainhash.NewHashFromStr(attestation.MerkleRoot)
+	if rootErr != nil {
+		inconsistency.Reason = AuditReasonRootInvalid
+		return inconsistency, false, nil
+	}
+	txid, txidErr := chainhash.NewHashFromStr(attestation.Txid)
+	staychainTx, staychainErr := a.dbInterface.GetStaychainTx(attestation.Txid)
+	if staychainErr != nil {
+		return nil, false, staychainErr
+	}
+	if txidErr != nil || staychainTx.Txid == "" || staychainTx.Index == 0 {
+		inconsistency.Reason = AuditReasonNotOnStaychain
+		inconsistency.Repairable = txidErr == nil
+		if !repair || !inconsistency.Repairable {
+			return inconsistency, false, nil
+		}
+		unconfirmed := models.NewAttestation(*txid, models.NewCommitmentRoot(*root))
+		unconfirmed.Info.Time = attestation.InsertedAt.Unix()
+		return inconsistency, true, a.dbInterface.SaveAttestation(*unconfirmed)
+	}
+
+	tx, txErr := a.attester.Chain.GetRawTransaction(txid)
+	if txErr != nil {
+		return nil, false, txErr
+	}
+	msgTx := tx.MsgTx()
+	keys := a.history.keysAt(staychainTx.Txid, staychainTx.Height)
+	match, matchErr := a.matchesRoot(keys, msgTx, *root)
+	if matchErr != nil {
+		return nil, false, matchErr
+	} else if !match {
+		inconsistency.Reason = AuditReasonAddressMismatch
+		return inconsistency, false, nil
+	}
+	if len(msgTx.TxOut) != 1 {
+		inconsistency.Reason = AuditReasonAmountMismatch
+		return inconsistency, false, nil
+	}
+
+	// stored info must match the info found on-chain
+	fee, feeErr := txFee(a.attester.Chain, msgTx)
+	if feeErr != nil {
+		return nil, false, feeErr
+	}
+	storedInfo, storedInfoErr := a.dbInterface.GetAttestationInfoByMerkleRoot(*root)
+	if storedInfoErr != nil {
+		return nil, false, storedInfoErr
+	}
+	if attestation.BlockHash == staychainTx.Blockhash && attestation.BlockHeight == staychainTx.Height &&
+		attestation.Fee == fee && storedInfo.Txid == attestation.Txid && storedInfo.Amount == msgTx.TxOut[0].Value {
+		return nil, false, nil
+	}
+	inconsistency.Reason = AuditReasonInfoMismatch
+	inconsistency.Repairable = true
+	if !repair {
+		return inconsistency, false, nil
+	}
+	repaired := models.NewAttestation(*txid, models.NewCommitmentRoot(*root))
+	repaired.Tx = *msgTx
+	repaired.Confirmed = true
+	repaired.Info = models.AttestationInfo{
+		Txid:      attestation.Txid,
+		Blockhash: staychainTx.Blockhash,
+		Amount:    msgTx.TxOut[0].Value,
+		Time:      attestation.InsertedAt.Unix(),
+		Height:    staychainTx.Height,
+		BlockTime: staychainTx.Time,
+		Fee:       fee,
+	}
+	if saveErr := a.dbInterface.SaveAttestation(*repaired); saveErr != nil {
+		return nil, false, saveErr
+	}
+	return inconsistency, true, a.dbInterface.SaveAttestationInfo(repaired.Info)
+}
+
+// Return whether the transaction pays to the address of the keys tweaked with the root
+func (a *AttestAudit) matchesRoot(keys attestClientKeys, msgTx *wire.MsgTx, root chainhash.Hash) (bool, error) {
+	if len(msgTx.TxOut) == 0 {
+		return false, nil
+	}
+	key, keyErr := a.attester.GetNextAttestationKey(root)
+	if keyErr != nil {
+		return false, keyErr
+	}
+	addr, _, addrErr := keys.attestationAddr(key, root, a.attester.MainChainCfg)
+	if addrErr != nil {
+		return false, addrErr
+	}
+	pkScript, pkScriptErr := txscript.PayToAddrScript(addr)
+	if pkScriptErr != nil {
+		return false, pkScriptErr
+	}
+	return bytes.Equal(msgTx.TxOut[0].PkScript, pkScript), nil
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"mainstay/db"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test stored attestations are audited against the staychain
+// and inconsistencies with the main chain are repaired
+func TestAttestAudit(t *testing.T) {
+	c := newSignerSetTestClient()
+	client := c.client
+	var chaincodes [][]byte
+	for i := range client.pubkeys {
+		chaincodes = append(chaincodes, bytes.Repeat([]byte{byte(i + 1)}, 32))
+	}
+	client.pubkeysExtended = newExtendedPubkeys(client.pubkeys, chaincodes)
+
+	chain := &backfillTestChain{newIndexerTestChain(1), make(map[chainhash.Hash]*wire.MsgTx)}
+	client.Chain = chain
+	roots := []chainhash.Hash{{1}, {2}, {3}}
+	tx0 := chain.addTx(t, client, nil, chainhash.Hash{})
+	tx1 := chain.addTx(t, client, tx0, roots[0])
+	tx2 := chain.addTx(t, client, tx1, roots[1])
+	tx3 := chain.addTx(t, client, tx2, roots[2])
+	txid0, txid1, txid2, txid3 := tx0.TxHash(), tx1.TxHash(), tx2.TxHash(), tx3.TxHash()
+	client.txid0 = txid0.String()
+
+	chain.base = btcjson.GetTransactionResult{TxID: txid0.String(),
+		BlockHash: chain.blocks[1].Hash, BlockTime: chain.blocks[1].Time}
+	chain.addBlock([2]string{txid0.String(), txid1.String()})
+	chain.addBlock([2]string{txid1.String(), txid2.String()}, [2]string{txid2.String(), txid3.String()})
+	chain.base.Confirmations = int64(len(chain.blocks) - 1)
+
+	// attestations of tx1 and tx2 backfilled, with tx3 not stored
+	dbFake := db.NewDbFake()
+	_, backfillErr := NewAttestBackfill(context.Background(), client, dbFake, []BackfillExport{
+		{MerkleRoot: roots[0].String()}, {MerkleRoot: roots[1].String()}}).Backfill()
+	assert.Equal(t, nil, backfillErr)
+	assert.Equal(t, 2, len(dbFake.Attestations))
+
+	result, auditErr := NewAttestAudit(context.Background(), client, dbFake).Audit(false)
+	assert.Equal(t, nil, auditErr)
+	assert.Equal(t, AuditResult{Attestations: 2, Staychain: 3, Inconsistencies: []AuditInconsistency{
+		{Txid: txid3.String(), Reason: AuditReasonMissing}}}, result)
+
+	// stored fee of tx2 wrong and attestation off the staychain stored
+	dbFake.Attestations[1].Info.Fee = 5
+	dbFake.AttestationsInfo[1].Fee = 5
+	offChain := models.NewAttestation(chainhash.Hash{9}, models.NewCommitmentRoot(chainhash.Hash{9}))
+	offChain.Confirmed = true
+	offChain.Info = models.AttestationInfo{Txid: offChain.Txid.String(), Time: 10}
+	assert.Equal(t, nil, dbFake.SaveAttestation(*offChain))
+
+	result, auditErr = NewAttestAudit(context.Background(), client, dbFake).Audit(false)
+	assert.Equal(t, nil, auditErr)
+	assert.Equal(t, AuditResult{Attestations: 3, Staychain: 3, Inconsistencies: []AuditInconsistency{
+		{txid2.String(), roots[1].String(), AuditReasonInfoMismatch, true},
+		{offChain.Txid.String(), chainhash.Hash{9}.String(), AuditReasonNotOnStaychain, true},
+		{Txid: txid3.String(), Reason: AuditReasonMissing}}}, result)
+	assert.Equal(t, int64(5), dbFake.AttestationsInfo[1].Fee)
+
+	// repair inconsistencies
+	result, auditErr = NewAttestAudit(context.Background(), client, dbFake).Audit(true)
+	assert.Equal(t, nil, auditErr)
+	assert.Equal(t, 3, len(result.Inconsistencies))
+	assert.Equal(t, 2, result.Repaired)
+	assert.Equal(t, int64(1000), dbFake.Attestations[1].Info.Fee)
+	assert.Equal(t, int64(1000), dbFake.AttestationsInfo[1].Fee)
+	assert.Equal(t, false, dbFake.Attestations[2].Confirmed)
+
+	result, auditErr = NewAttestAudit(context.Background(), client, dbFake).Audit(false)
+	assert.Equal(t, nil, auditErr)
+	assert.Equal(t, AuditResult{Attestations: 2, Staychain: 3, Inconsistencies: []AuditInconsistency{
+		{Txid: txid3.String(), Reason: AuditReasonMissing}}}, result)
+
+	// stored merkle root not matching the attestation output
+	dbFake.Attestations[0].SetCommitment(models.NewCommitmentRoot(chainhash.Hash{7}))
+	result, auditErr = NewAttestAudit(context.Background(), client, dbFake).Audit(true)
+	assert.Equal(t, nil, auditErr)
+	assert.Equal(t, AuditResult{Attestations: 2, Staychain: 3, Inconsistencies: []AuditInconsistency{
+		{txid1.String(), chainhash.Hash{7}.String(), AuditReasonAddressMismatch, false},
+		{Txid: txid3.String(), Reason: AuditReasonMissing}}}, result)
+}
+
+// Test attestations before and after a key rotation are audited with
+// the keys of the rotation active at their height
+func TestAttestAuditKeyRotation(t *testing.T) {
+	c := newSignerSetTestClient()
+	client := c.client
+	var chaincodes [][]byte
+	for i := range client.pubkeys {
+		chaincodes = append(chaincodes, bytes.Repeat([]byte{byte(i + 1)}, 32))
+	}
+	client.pubkeysExtended = newExtendedPubkeys(client.pubkeys, chaincodes)
+	rotation := newTestKeyRotation()
+	keys, keysErr := newRotationKeys(rotation, client.MainChainCfg)
+	assert.Equal(t, nil, keysErr)
+	rotated := *client
+	rotated.setKeys(keys)
+
+	// transition attestation tx2 and tx3 pay to the rotation keys
+	chain := &backfillTestChain{newIndexerTestChain(1), make(map[chainhash.Hash]*wire.MsgTx)}
+	client.Chain = chain
+	roots := []chainhash.Hash{{1}, {2}, {3}}
+	tx0 := chain.addTx(t, client, nil, chainhash.Hash{})
+	tx1 := chain.addTx(t, client, tx0, roots[0])
+	tx2 := chain.addTx(t, &rotated, tx1, roots[1])
+	tx3 := chain.addTx(t, &rotated, tx2, roots[2])
+	txid0, txid1, txid2, txid3 := tx0.TxHash(), tx1.TxHash(), tx2.TxHash(), tx3.TxHash()
+	client.txid0 = txid0.String()
+
+	chain.base = btcjson.GetTransactionResult{TxID: txid0.String(),
+		BlockHash: chain.blocks[1].Hash, BlockTime: chain.blocks[1].Time}
+	chain.addBlock([2]string{txid0.String(), txid1.String()})
+	chain.addBlock([2]string{txid1.String(), txid2.String()})
+	chain.addBlock([2]string{txid2.String(), txid3.String()})
+	chain.base.Confirmations = int64(len(chain.blocks) - 1)
+
+	dbFake := db.NewDbFake()
+	rotation.Id = 1
+	rotation.Status = models.KeyRotationActive
+	rotation.Txid = txid2.String()
+	rotation.Height = 3
+	assert.Equal(t, nil, dbFake.SaveKeyRotation(rotation))
+	backfill, backfillErr := NewAttestBackfill(context.Background(), client, dbFake, []BackfillExport{
+		{MerkleRoot: roots[0].String()}, {MerkleRoot: roots[1].String()}, {MerkleRoot: roots[2].String()}}).Backfill()
+	assert.Equal(t, nil, backfillErr)
+	assert.Equal(t, 3, backfill.Attestations)
+
+	// audited by the service client with the rotation keys in use
+	client.setKeys(keys)
+	result, auditErr := NewAttestAudit(context.Background(), client, dbFake).Audit(true)
+	assert.Equal(t, nil, auditErr)
+	assert.Equal(t, AuditResult{Attestations: 3, Staychain: 3}, result)
+
+	// rotations without height placed at their transition attestation
+	rotation.Height = 0
+	assert.Equal(t, nil, dbFake.SaveKeyRotation(rotation))
+	result, auditErr = NewAttestAudit(context.Background(), client, dbFake).Audit(true)
+	assert.Equal(t, nil, auditErr)
+	assert.Equal(t, AuditResult{Attestations: 3, Staychain: 3}, result)
+
+	// attestations of the rotation keys not audited with the config keys
+	dbFake.KeyRotations = nil
+	result, auditErr = NewAttestAudit(context.Background(), client, dbFake).Audit(true)
+	assert.Equal(t, nil, auditErr)
+	assert.Equal(t, AuditResult{Attestations: 3, Staychain: 3, Inconsistencies: []AuditInconsistency{
+		{txid2.String(), roots[1].String(), AuditReasonAddressMismatch, false},
+		{txid3.String(), roots[2].String(), AuditReasonAddressMismatch, false}}}, result)
+}
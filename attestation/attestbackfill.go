@@ -0,0 +1,274 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	confpkg "mainstay/config"
+	"mainstay/db"
+	"mainstay/log"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Historical attestations are backfilled into a fresh database from the main
+// chain alone. The staychain is followed from the base transaction by the
+// staychain indexer and an attestation is stored for each transaction after
+// the base transaction, confirmed in the block it was indexed at.
+//
+// The merkle root attested by each transaction is recovered by tweaking the
+// base script with candidate roots until the tweaked address matches the
+// address paid to. Candidates are the root exported for the txid, if any,
+// followed by every other exported root from the root matched last, so that
+// exports of roots in staychain order match on the first attempt while
+// exports without txids or out of order are still matched. The empty root of
+// the untweaked address is always a candidate. Transactions whose root is not
+// recovered are reported and not stored
+
+// backfill consts
+const (
+	ErrorBackfillMultisig = "Backfill requires a multisig init script"
+	ErrorBackfillExport   = "Invalid backfill export"
+	ErrorBackfillTx       = "Invalid staychain transaction"
+
+	WarningBackfillRootMissing = "Merkle root of staychain transaction not recovered"
+)
+
+// BackfillExport struct
+// Attestation of a backfill export, as exported from the Attestation
+// collection or returned by the attestation api routes
+type BackfillExport struct {
+	Txid       string `json:"txid"`
+	MerkleRoot string `json:"merkle_root"`
+}
+
+// Parse backfill export of a json array or json lines of attestations
+func ParseBackfillExport(r io.Reader) ([]BackfillExport, error) {
+	exportBytes, readErr := ioutil.ReadAll(r)
+	if readErr != nil {
+		return nil, readErr
+	}
+	var exports []BackfillExport
+	if trimmed := bytes.TrimSpace(exportBytes); len(trimmed) > 0 && trimmed[0] == '[' {
+		if jsonErr := json.Unmarshal(trimmed, &exports); jsonErr != nil {
+			return nil, errors.New(fmt.Sprintf("%s: %v", ErrorBackfillExport, jsonErr))
+		}
+	} else {
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+		for decoder.More() {
+			var export BackfillExport
+			if jsonErr := decoder.Decode(&export); jsonErr != nil {
+				return nil, errors.New(fmt.Sprintf("%s: %v", ErrorBackfillExport, jsonErr))
+			}
+			exports = append(exports, export)
+		}
+	}
+	for _, export := range exports {
+		if _, rootErr := chainhash.NewHashFromStr(export.MerkleRoot); rootErr != nil || export.MerkleRoot == "" {
+			return nil, errors.New(fmt.Sprintf("%s: merkle root %s", ErrorBackfillExport, export.MerkleRoot))
+		}
+	}
+	return exports, nil
+}
+
+// BackfillResult struct
+// Number of attestations stored and the txids of the
+// staychain transactions whose merkle root was not recovered
+type BackfillResult struct {
+	Attestations int
+	Unrecovered  []string
+}
+
+// AttestBackfill struct
+// Repopulates the attestations of the staychain of the
+// attest client, recovering merkle roots from exported roots
+type AttestBackfill struct {
+	attester    *AttestClient
+	dbInterface db.Db
+	indexer     *AttestIndexer
+
+	// candidate roots in export order, the roots exported by txid
+	// and the position of the candidate matched last
+	roots       []chainhash.Hash
+	rootsByTxid map[string]chainhash.Hash
+	rootsNext   int
+
+	// keys of the key rotation active at each staychain height
+	history attestKeyHistory
+}
+
+// Return new AttestBackfill instance for the staychain of the attest client
+// and the exported attestations, indexing every confirmed staychain transaction
+func NewAttestBackfill(ctx context.Context, attester *AttestClient, dbInterface db.Db,
+	exports []BackfillExport) *AttestBackfill {
+
+	indexer := NewAttestIndexer(ctx, &sync.WaitGroup{}, dbInterface, attester.Chain, attester.txid0,
+		confpkg.IndexerConfig{IntervalSeconds: -1, Confirmations: 1})
+	b := &AttestBackfill{attester: attester, dbInterface: dbInterface, indexer: indexer,
+		roots: []chainhash.Hash{{}}, rootsByTxid: make(map[string]chainhash.Hash)}
+	for _, export := range exports {
+		root, _ := chainhash.NewHashFromStr(export.MerkleRoot)
+		if export.Txid != "" {
+			b.rootsByTxid[export.Txid] = *root
+		}
+		if *root != (chainhash.Hash{}) {
+			b.roots = append(b.roots, *root)
+		}
+	}
+	return b
+}
+
+// Backfill attestations of the staychain transactions confirmed on the main
+// chain, storing the attestation and attestation info of each transaction
+// Attestations are upserted, so the backfill can be run again after failures
+func (b *AttestBackfill) Backfill() (BackfillResult, error) {
+	if len(b.attester.getKeys().pubkeysExtended) == 0 {
+		return BackfillResult{}, errors.New(ErrorBackfillMultisig)
+	}
+	indexed, indexErr := b.indexer.index()
+	if indexErr != nil {
+		return BackfillResult{}, indexErr
+	}
+	log.Infof("*Backfill* Indexed %d staychain transactions\n", indexed)
+	rotations, rotationsErr := b.dbInterface.GetKeyRotations()
+	if rotationsErr != nil {
+		return BackfillResult{}, rotationsErr
+	}
+	history, historyErr := b.attester.keyHistory(rotations, staychainTxHeight(b.dbInterface))
+	if historyErr != nil {
+		return BackfillResult{}, historyErr
+	}
+	b.history = history
+
+	var result BackfillResult
+	tx, txErr := b.dbInterface.GetStaychainTx(b.attester.txid0)
+	for txErr == nil && tx.SpentBy != "" {
+		if tx, txErr = b.dbInterface.GetStaychainTx(tx.SpentBy); txErr != nil {
+			return result, txErr
+		}
+		attestation, found, attestationErr := b.recoverAttestation(tx)
+		if attestationErr != nil {
+			return result, attestationErr
+		} else if !found {
+			log.Warnf("%s (%s)\n", WarningBackfillRootMissing, tx.Txid)
+			result.Unrecovered = append(result.Unrecovered, tx.Txid)
+			continue
+		}
+		if saveErr := b.dbInterface.SaveAttestation(*attestation); saveErr != nil {
+			return result, saveErr
+		}
+		if saveErr := b.dbInterface.SaveAttestationInfo(attestation.Info); saveErr != nil {
+			return result, saveErr
+		}
+		result.Attestations++
+	}
+	return result, txErr
+}
+
+// Return confirmed attestation of the staychain transaction with the merkle
+// root recovered and whether the root was recovered
+func (b *AttestBackfill) recoverAttestation(staychainTx models.StaychainTx) (*models.Attestation, bool, error) {
+	txid, hashErr := chainhash.NewHashFromStr(staychainTx.Txid)
+	if hashErr != nil {
+		return nil, false, hashErr
+	}
+	tx, txErr := b.attester.Chain.GetRawTransaction(txid)
+	if txErr != nil {
+		return nil, false, txErr
+	}
+	msgTx := tx.MsgTx()
+	if len(msgTx.TxOut) == 0 {
+		return nil, false, errors.New(fmt.Sprintf("%s: %s", ErrorBackfillTx, staychainTx.Txid))
+	}
+	_, addrs, _, addrsErr := txscript.ExtractPkScriptAddrs(msgTx.TxOut[0].PkScript, b.attester.MainChainCfg)
+	if addrsErr != nil || len(addrs) != 1 {
+		return nil, false, errors.New(fmt.Sprintf("%s: %s", ErrorBackfillTx, staychainTx.Txid))
+	}
+
+	keys := b.history.keysAt(staychainTx.Txid, staychainTx.Height)
+	root, found, rootErr := b.recoverRoot(keys, staychainTx.Txid, addrs[0].EncodeAddress())
+	if rootErr != nil || !found {
+		return nil, false, rootErr
+	}
+	fee, feeErr := txFee(b.attester.Chain, msgTx)
+	if feeErr != nil {
+		return nil, false, feeErr
+	}
+
+	attestation := models.NewAttestation(*txid, models.NewCommitmentRoot(root))
+	attestation.Tx = *msgTx
+	attestation.Confirmed = true
+	attestation.Info = models.AttestationInfo{
+		Txid:      staychainTx.Txid,
+		Blockhash: staychainTx.Blockhash,
+		Amount:    msgTx.TxOut[0].Value,
+		Time:      staychainTx.Time,
+		Height:    staychainTx.Height,
+		BlockTime: staychainTx.Time,
+		Fee:       fee,
+	}
+	return attestation, true, nil
+}
+
+// Return the candidate root tweaking the base script of the keys to the address
+func (b *AttestBackfill) recoverRoot(keys attestClientKeys, txid string, addr string) (chainhash.Hash, bool, error) {
+	if root, ok := b.rootsByTxid[txid]; ok {
+		match, matchErr := b.matchesAddr(keys, root, addr)
+		if matchErr != nil || match {
+			return root, match, matchErr
+		}
+	}
+	for i := range b.roots {
+		i_r := (b.rootsNext + i) % len(b.roots)
+		match, matchErr := b.matchesAddr(keys, b.roots[i_r], addr)
+		if matchErr != nil {
+			return chainhash.Hash{}, false, matchErr
+		} else if match {
+			b.rootsNext = i_r + 1
+			return b.roots[i_r], true, nil
+		}
+	}
+	return chainhash.Hash{}, false, nil
+}
+
+// Return whether tweaking the base script of the keys with the root gives the address
+func (b *AttestBackfill) matchesAddr(keys attestClientKeys, root chainhash.Hash, addr string) (bool, error) {
+	tweakedAddr, _, tweakErr := keys.attestationAddr(nil, root, b.attester.MainChainCfg)
+	if tweakErr != nil {
+		return false, tweakErr
+	}
+	return tweakedAddr.EncodeAddress() == addr, nil
+}
+
+// Return fee of the transaction from the outputs spent by its inputs
+func txFee(chain ChainBackend, msgTx *wire.MsgTx) (int64, error) {
+	var fee int64
+	for _, txIn := range msgTx.TxIn {
+		prevTx, prevTxErr := chain.GetRawTransaction(&txIn.PreviousOutPoint.Hash)
+		if prevTxErr != nil {
+			return 0, prevTxErr
+		}
+		prevOuts := prevTx.MsgTx().TxOut
+		if int(txIn.PreviousOutPoint.Index) >= len(prevOuts) {
+			return 0, errors.New(fmt.Sprintf("%s: %s", ErrorBackfillTx, msgTx.TxHash().String()))
+		}
+		fee += prevOuts[txIn.PreviousOutPoint.Index].Value
+	}
+	for _, txOut := range msgTx.TxOut {
+		fee -= txOut.Value
+	}
+	return fee, nil
+}
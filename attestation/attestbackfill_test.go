@@ -0,0 +1,146 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"mainstay/db"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// chain backend serving the blocks and transactions of a staychain
+type backfillTestChain struct {
+	*indexerTestChain
+	txs map[chainhash.Hash]*wire.MsgTx
+}
+
+func (c *backfillTestChain) GetRawTransaction(txid *chainhash.Hash) (*btcutil.Tx, error) {
+	if tx, ok := c.txs[*txid]; ok {
+		return btcutil.NewTx(tx), nil
+	}
+	return nil, errors.New("No such mempool or blockchain transaction")
+}
+
+// Return transaction spending the previous transaction output to the
+// attestation address of the root, paying a fee of 1000
+func (c *backfillTestChain) addTx(t *testing.T, client *AttestClient, prevTx *wire.MsgTx, root chainhash.Hash) *wire.MsgTx {
+	addr, _, addrErr := client.GetNextAttestationAddr(nil, root)
+	assert.Equal(t, nil, addrErr)
+	pkScript, _ := txscript.PayToAddrScript(addr)
+	tx := wire.NewMsgTx(wire.TxVersion)
+	value := int64(100000000)
+	if prevTx != nil {
+		prevHash := prevTx.TxHash()
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&prevHash, 0), nil, nil))
+		value = prevTx.TxOut[0].Value - 1000
+	}
+	tx.AddTxOut(wire.NewTxOut(value, pkScript))
+	c.txs[tx.TxHash()] = tx
+	return tx
+}
+
+// Test exported attestations are parsed from json arrays and json lines
+func TestParseBackfillExport(t *testing.T) {
+	root := strings.Repeat("ab", 32)
+	exports, exportsErr := ParseBackfillExport(strings.NewReader(
+		`[{"txid": "aa", "merkle_root": "` + root + `", "confirmed": true}]`))
+	assert.Equal(t, nil, exportsErr)
+	assert.Equal(t, []BackfillExport{{"aa", root}}, exports)
+
+	exports, exportsErr = ParseBackfillExport(strings.NewReader(
+		`{"txid": "aa", "merkle_root": "` + root + `"}` + "\n" + `{"merkle_root": "` + root + `"}` + "\n"))
+	assert.Equal(t, nil, exportsErr)
+	assert.Equal(t, []BackfillExport{{"aa", root}, {"", root}}, exports)
+
+	exports, exportsErr = ParseBackfillExport(strings.NewReader(""))
+	assert.Equal(t, nil, exportsErr)
+	assert.Equal(t, 0, len(exports))
+
+	_, exportsErr = ParseBackfillExport(strings.NewReader(`[{"txid": "aa"}]`))
+	assert.Equal(t, ErrorBackfillExport+": merkle root ", exportsErr.Error())
+	_, exportsErr = ParseBackfillExport(strings.NewReader(`{"txid": "aa"`))
+	assert.True(t, strings.HasPrefix(exportsErr.Error(), ErrorBackfillExport))
+}
+
+// Test attestations are backfilled from the staychain with
+// the merkle roots recovered from the exported roots
+func TestAttestBackfill(t *testing.T) {
+	c := newSignerSetTestClient()
+	client := c.client
+	var chaincodes [][]byte
+	for i := range client.pubkeys {
+		chaincodes = append(chaincodes, bytes.Repeat([]byte{byte(i + 1)}, 32))
+	}
+	client.pubkeysExtended = newExtendedPubkeys(client.pubkeys, chaincodes)
+
+	chain := &backfillTestChain{newIndexerTestChain(1), make(map[chainhash.Hash]*wire.MsgTx)}
+	client.Chain = chain
+	roots := []chainhash.Hash{{1}, {2}, {3}, {4}}
+	tx0 := chain.addTx(t, client, nil, chainhash.Hash{})
+	tx1 := chain.addTx(t, client, tx0, roots[0])
+	tx2 := chain.addTx(t, client, tx1, roots[1])
+	tx3 := chain.addTx(t, client, tx2, roots[2])
+	tx4 := chain.addTx(t, client, tx3, roots[3])
+	txid0, txid1, txid2, txid3, txid4 := tx0.TxHash(), tx1.TxHash(), tx2.TxHash(), tx3.TxHash(), tx4.TxHash()
+	client.txid0 = txid0.String()
+
+	chain.base = btcjson.GetTransactionResult{TxID: txid0.String(),
+		BlockHash: chain.blocks[1].Hash, BlockTime: chain.blocks[1].Time}
+	chain.addBlock([2]string{txid0.String(), txid1.String()})
+	chain.addBlock([2]string{txid1.String(), txid2.String()}, [2]string{txid2.String(), txid3.String()})
+	chain.addBlock([2]string{txid3.String(), txid4.String()})
+	chain.base.Confirmations = int64(len(chain.blocks) - 1)
+
+	// root of tx3 missing from the export, roots of tx2 and tx4 without txid and out of order
+	dbFake := db.NewDbFake()
+	backfill := NewAttestBackfill(context.Background(), client, dbFake, []BackfillExport{
+		{Txid: txid1.String(), MerkleRoot: roots[0].String()},
+		{MerkleRoot: roots[3].String()},
+		{MerkleRoot: roots[1].String()},
+	})
+	result, backfillErr := backfill.Backfill()
+	assert.Equal(t, nil, backfillErr)
+	assert.Equal(t, BackfillResult{Attestations: 3, Unrecovered: []string{txid3.String()}}, result)
+	assert.Equal(t, 5, len(dbFake.StaychainTxs))
+
+	assert.Equal(t, 3, len(dbFake.Attestations))
+	for i, expected := range []struct {
+		txid   chainhash.Hash
+		root   chainhash.Hash
+		height int64
+	}{{txid1, roots[0], 2}, {txid2, roots[1], 3}, {txid4, roots[3], 4}} {
+		attestation := dbFake.Attestations[i]
+		assert.Equal(t, expected.txid, attestation.Txid)
+		assert.Equal(t, expected.root, attestation.CommitmentHash())
+		assert.Equal(t, true, attestation.Confirmed)
+		assert.Equal(t, expected.height, attestation.Info.Height)
+		assert.Equal(t, chain.blocks[expected.height].Hash, attestation.Info.Blockhash)
+		assert.Equal(t, int64(1000), attestation.Info.Fee)
+		assert.Equal(t, attestation.Info, dbFake.AttestationsInfo[i])
+	}
+
+	// backfill again without changes
+	result, backfillErr = NewAttestBackfill(context.Background(), client, dbFake, nil).Backfill()
+	assert.Equal(t, nil, backfillErr)
+	assert.Equal(t, BackfillResult{Attestations: 0, Unrecovered: []string{txid1.String(), txid2.String(),
+		txid3.String(), txid4.String()}}, result)
+	assert.Equal(t, 3, len(dbFake.Attestations))
+
+	// single key client not supported
+	client.pubkeysExtended = nil
+	_, backfillErr = NewAttestBackfill(context.Background(), client, dbFake, nil).Backfill()
+	assert.Equal(t, errors.New(ErrorBackfillMultisig), backfillErr)
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"sync"
+	"time"
+
+	"mainstay/config"
+	"mainstay/log"
+	"mainstay/models"
+)
+
+// Utility to monitor the staychain balance and the remaining attestation
+// runway, alerting when the number of remaining attestations is low
+
+// balance monitor consts
+const (
+	DefaultBalanceAlertThreshold = 100 // remaining attestations below which to alert
+
+	WarningInvalidBalanceAlertThresholdArg = "Invalid balance alert threshold config value"
+	WarningLowBalance                      = "Warning - Low staychain balance"
+)
+
+// BalanceMonitor struct
+// Keeps the latest staychain balance updated by the attestation service
+// and is safe for concurrent use by the request api
+type BalanceMonitor struct {
+	mu sync.RWMutex
+
+	threshold int64
+	balance   models.Balance
+	isSet     bool
+	isAlerted bool
+}
+
+// Return new BalanceMonitor instance from balance config
+func NewBalanceMonitor(balanceConfig config.BalanceConfig) *BalanceMonitor {
+	threshold := int64(DefaultBalanceAlertThreshold)
+	if balanceConfig.AlertThreshold >= 0 {
+		threshold = int64(balanceConfig.AlertThreshold)
+	} else {
+		log.Warnf("%s (%d)\n", WarningInvalidBalanceAlertThresholdArg, balanceConfig.AlertThreshold)
+	}
+	log.Infof("*Balance* Alert threshold set to: %d\n", threshold)
+
+	return &BalanceMonitor{threshold: threshold}
+}
+
+// Get balance alert threshold
+func (b *BalanceMonitor) Threshold() int64 {
+	return b.threshold
+}
+
+// Update balance from the latest staychain output value and the fee
+// paid by each attestation, alerting once when the runway drops below
+// the threshold until the balance is topped up again
+func (b *BalanceMonitor) Update(value int64, attestationFee int64, feePerByte int) models.Balance {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var runway int64
+	if attestationFee > 0 {
+		runway = value / attestationFee
+	}
+	b.balance = models.Balance{
+		Value:          value,
+		FeePerByte:     feePerByte,
+		AttestationFee: attestationFee,
+		Runway:         runway,
+		Threshold:      b.threshold,
+		Low:            runway < b.threshold,
+		Time:           time.Now().Unix(),
+	}
+	b.isSet = true
+
+	if b.balance.Low && !b.isAlerted {
+		log.Warnf("%s: %d attestations remaining (value %d, fee %d)\n",
+			WarningLowBalance, runway, value, attestationFee)
+	}
+	b.isAlerted = b.balance.Low
+	return b.balance
+}
+
+// Get latest balance and whether a balance has been set
+func (b *BalanceMonitor) Balance() (models.Balance, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.balance, b.isSet
+}
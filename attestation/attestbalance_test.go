@@ -0,0 +1,53 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"testing"
+
+	"mainstay/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Balance monitor test
+func TestBalanceMonitor(t *testing.T) {
+	balanceMonitor := NewBalanceMonitor(config.BalanceConfig{-1})
+	assert.Equal(t, int64(DefaultBalanceAlertThreshold), balanceMonitor.Threshold())
+
+	balanceMonitor = NewBalanceMonitor(config.BalanceConfig{10})
+	assert.Equal(t, int64(10), balanceMonitor.Threshold())
+	_, isSet := balanceMonitor.Balance()
+	assert.Equal(t, false, isSet)
+
+	// runway above threshold
+	balance := balanceMonitor.Update(50000, 2000, 10)
+	assert.Equal(t, int64(25), balance.Runway)
+	assert.Equal(t, false, balance.Low)
+	assert.Equal(t, false, balanceMonitor.isAlerted)
+	latest, isSet := balanceMonitor.Balance()
+	assert.Equal(t, true, isSet)
+	assert.Equal(t, balance, latest)
+
+	// runway below threshold alerts once
+	balance = balanceMonitor.Update(18000, 2000, 10)
+	assert.Equal(t, int64(9), balance.Runway)
+	assert.Equal(t, true, balance.Low)
+	assert.Equal(t, true, balanceMonitor.isAlerted)
+	balance = balanceMonitor.Update(16000, 2000, 10)
+	assert.Equal(t, int64(8), balance.Runway)
+	assert.Equal(t, true, balanceMonitor.isAlerted)
+
+	// topup resets alert
+	balance = balanceMonitor.Update(1000000, 2000, 10)
+	assert.Equal(t, int64(500), balance.Runway)
+	assert.Equal(t, false, balance.Low)
+	assert.Equal(t, false, balanceMonitor.isAlerted)
+
+	// no runway without fee
+	balance = balanceMonitor.Update(1000000, 0, 0)
+	assert.Equal(t, int64(0), balance.Runway)
+	assert.Equal(t, true, balance.Low)
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"sync"
+	"time"
+
+	"mainstay/config"
+	"mainstay/log"
+	"mainstay/models"
+)
+
+// Utility to track the fees spent on confirmed attestations and enforce
+// daily and monthly fee budgets, pausing new attestations while either
+// budget is exceeded until the next calendar day or month in UTC
+
+// fee budget consts
+const (
+	WarningInvalidDailyBudgetArg   = "Invalid daily fee budget config value"
+	WarningInvalidMonthlyBudgetArg = "Invalid monthly fee budget config value"
+	WarningFeeBudgetExceeded       = "Fee budget exceeded - attestations paused"
+)
+
+// FeeBudget struct
+// Sums the fees of confirmed attestations stored in the server and
+// is safe for concurrent use by the request api
+type FeeBudget struct {
+	server *AttestServer
+
+	daily   int64 // 0 for no daily budget
+	monthly int64 // 0 for no monthly budget
+
+	mu        sync.Mutex
+	isAlerted bool
+}
+
+// Return new FeeBudget instance from budget config
+func NewFeeBudget(budgetConfig config.BudgetConfig, server *AttestServer) *FeeBudget {
+	budget := &FeeBudget{server: server}
+	if budgetConfig.Daily > 0 {
+		budget.daily = int64(budgetConfig.Daily)
+		log.Infof("*Budget* Daily fee budget set to: %d\n", budget.daily)
+	} else if budgetConfig.Daily != -1 {
+		log.Warnf("%s (%d)\n", WarningInvalidDailyBudgetArg, budgetConfig.Daily)
+	}
+	if budgetConfig.Monthly > 0 {
+		budget.monthly = int64(budgetConfig.Monthly)
+		log.Infof("*Budget* Monthly fee budget set to: %d\n", budget.monthly)
+	} else if budgetConfig.Monthly != -1 {
+		log.Warnf("%s (%d)\n", WarningInvalidMonthlyBudgetArg, budgetConfig.Monthly)
+	}
+	return budget
+}
+
+// Return fees spent in total and in the calendar day and month of now
+// A budget is exceeded once the fees spent reach it, as the next
+// attestation would take the spend over the budget
+func (b *FeeBudget) Spend(now time.Time) (models.FeeSpend, error) {
+	now = now.UTC()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	total, totalErr := b.server.GetAttestationFees(0)
+	if totalErr != nil {
+		return models.FeeSpend{}, totalErr
+	}
+	day, dayErr := b.server.GetAttestationFees(dayStart.Unix())
+	if dayErr != nil {
+		return models.FeeSpend{}, dayErr
+	}
+	month, monthErr := b.server.GetAttestationFees(monthStart.Unix())
+	if monthErr != nil {
+		return models.FeeSpend{}, monthErr
+	}
+
+	return models.FeeSpend{
+		Total:         total,
+		Day:           day,
+		Month:         month,
+		DailyBudget:   b.daily,
+		MonthlyBudget: b.monthly,
+		Exceeded:      (b.daily > 0 && day >= b.daily) || (b.monthly > 0 && month >= b.monthly),
+		Time:          now.Unix(),
+	}, nil
+}
+
+// Return fees spent at the current time of the attestation service clock
+func (b *FeeBudget) FeeSpend() (models.FeeSpend, error) {
+	return b.Spend(clock.Now())
+}
+
+// Return fees spent and whether a budget has newly been exceeded,
+// to alert once until the spend is back within the budgets
+func (b *FeeBudget) Check() (models.FeeSpend, bool, error) {
+	spend, spendErr := b.FeeSpend()
+	if spendErr != nil {
+		return models.FeeSpend{}, false, spendErr
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	isNew := spend.Exceeded && !b.isAlerted
+	b.isAlerted = spend.Exceeded
+	return spend, isNew, nil
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"testing"
+	"time"
+
+	"mainstay/config"
+	"mainstay/db"
+	"mainstay/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Fee budget test
+func TestFeeBudget(t *testing.T) {
+	dbFake := db.NewDbFake()
+	now := time.Date(2020, time.March, 15, 12, 0, 0, 0, time.UTC)
+	dbFake.SaveAttestationInfo(models.AttestationInfo{Txid: "a", Blockhash: "h", Fee: 1000,
+		Time: time.Date(2020, time.February, 20, 0, 0, 0, 0, time.UTC).Unix()})
+	dbFake.SaveAttestationInfo(models.AttestationInfo{Txid: "b", Blockhash: "h", Fee: 2000,
+		Time: time.Date(2020, time.March, 14, 23, 0, 0, 0, time.UTC).Unix()})
+	dbFake.SaveAttestationInfo(models.AttestationInfo{Txid: "c", Blockhash: "h", Fee: 3000,
+		Time: time.Date(2020, time.March, 15, 1, 0, 0, 0, time.UTC).Unix()})
+
+	// reorged attestation fee not spent
+	dbFake.SaveAttestationInfo(models.AttestationInfo{Txid: "d"})
+
+	// no budgets
+	budget := NewFeeBudget(config.BudgetConfig{-1, -1}, NewAttestServer(dbFake))
+	spend, spendErr := budget.Spend(now)
+	assert.Equal(t, nil, spendErr)
+	assert.Equal(t, models.FeeSpend{Total: 6000, Day: 3000, Month: 5000, Time: now.Unix()}, spend)
+
+	// invalid budgets ignored
+	budget = NewFeeBudget(config.BudgetConfig{0, -5}, NewAttestServer(dbFake))
+	spend, _ = budget.Spend(now)
+	assert.Equal(t, false, spend.Exceeded)
+
+	// daily budget reached and not reached the next day
+	budget = NewFeeBudget(config.BudgetConfig{3000, -1}, NewAttestServer(dbFake))
+	spend, _ = budget.Spend(now)
+	assert.Equal(t, int64(3000), spend.DailyBudget)
+	assert.Equal(t, true, spend.Exceeded)
+	spend, _ = budget.Spend(now.Add(24 * time.Hour))
+	assert.Equal(t, int64(0), spend.Day)
+	assert.Equal(t, false, spend.Exceeded)
+
+	// monthly budget exceeded until the next month
+	budget = NewFeeBudget(config.BudgetConfig{-1, 4000}, NewAttestServer(dbFake))
+	spend, _ = budget.Spend(now.Add(24 * time.Hour))
+	assert.Equal(t, true, spend.Exceeded)
+	spend, _ = budget.Spend(time.Date(2020, time.April, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, int64(0), spend.Month)
+	assert.Equal(t, false, spend.Exceeded)
+}
+
+// Fee budget check alerts once until back within budget
+func TestFeeBudgetCheck(t *testing.T) {
+	simClock := newSimClock(time.Date(2020, time.March, 15, 12, 0, 0, 0, time.UTC))
+	defer useSimClock(simClock)()
+
+	dbFake := db.NewDbFake()
+	budget := NewFeeBudget(config.BudgetConfig{1000, -1}, NewAttestServer(dbFake))
+	spend, isNew, checkErr := budget.Check()
+	assert.Equal(t, nil, checkErr)
+	assert.Equal(t, false, spend.Exceeded)
+	assert.Equal(t, false, isNew)
+
+	dbFake.SaveAttestationInfo(models.AttestationInfo{Txid: "a", Blockhash: "h", Fee: 1000, Time: simClock.Now().Unix()})
+	spend, isNew, _ = budget.Check()
+	assert.Equal(t, true, spend.Exceeded)
+	assert.Equal(t, true, isNew)
+	spend, isNew, _ = budget.Check()
+	assert.Equal(t, true, spend.Exceeded)
+	assert.Equal(t, false, isNew)
+
+	simClock.Advance(24 * time.Hour)
+	spend, isNew, _ = budget.Check()
+	assert.Equal(t, false, spend.Exceeded)
+	assert.Equal(t, false, isNew)
+	assert.Equal(t, false, budget.isAlerted)
+}
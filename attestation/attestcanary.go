@@ -0,0 +1,179 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/log"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Canary attestations mirror each main staychain attestation on a testnet
+// or signet staychain, with the same commitment, before the main attestation
+// is broadcast. Derivation and signing regressions are caught on the canary
+// staychain before costing main staychain fees. The canary staychain is
+// signed locally so the canary init private key must be able to sign alone
+
+// canary consts
+const (
+	DefaultCanaryTimeout = 30 * time.Minute // wait for canary confirmation before proceeding
+	ATimeCanary          = 1 * time.Minute  // waiting time between canary confirmation checks
+
+	ErrorCanaryUnspentNotFound = "No valid canary unspent found"
+	ErrorCanaryOutOfSync       = "Canary staychain unspent not derived from known commitments"
+
+	WarningInvalidCanaryTimeoutArg = "Invalid canary timeout config value"
+	WarningCanaryFailed            = "Canary attestation failed"
+	WarningCanaryTimeout           = "Canary attestation not confirmed before timeout - proceeding"
+)
+
+// canary round status
+type CanaryStatus int
+
+// canary round status values
+const (
+	CanaryPending   CanaryStatus = 0
+	CanaryConfirmed CanaryStatus = 1
+	CanaryTimeout   CanaryStatus = 2
+)
+
+// AttestCanary struct
+// Creates, signs and sends canary attestations with a local signer
+// client and tracks the confirmation of the current canary round
+type AttestCanary struct {
+	attester *AttestClient
+	timeout  time.Duration
+
+	// current canary round
+	commitment chainhash.Hash
+	txid       chainhash.Hash
+	startTime  time.Time
+
+	// commitment of the last confirmed canary attestation
+	lastCommitment *chainhash.Hash
+}
+
+// Return new AttestCanary instance from canary config
+func NewAttestCanary(canaryConfig confpkg.CanaryConfig) *AttestCanary {
+	timeout := DefaultCanaryTimeout
+	if canaryConfig.TimeoutMinutes > 0 {
+		timeout = time.Duration(canaryConfig.TimeoutMinutes) * time.Minute
+	} else {
+		log.Warnf("%s (%v)\n", WarningInvalidCanaryTimeoutArg, canaryConfig.TimeoutMinutes)
+	}
+	log.Infof("*Canary* Canary timeout set to: %v\n", timeout)
+
+	return &AttestCanary{
+		attester: NewAttestClient(canaryConfig.Config, true),
+		timeout:  timeout,
+	}
+}
+
+// Check canary round for the commitment provided, starting a new round
+// and sending the canary attestation if required, and return round status
+// The last commitment of the main staychain is used to find the key tweak
+// of the canary unspent if this is not known, e.g. after a restart
+func (c *AttestCanary) Check(commitment chainhash.Hash, lastCommitment chainhash.Hash) (CanaryStatus, error) {
+	if commitment != c.commitment {
+		c.commitment = commitment
+		c.txid = chainhash.Hash{}
+		c.startTime = clock.Now()
+	}
+	if since(c.startTime) > c.timeout {
+		return CanaryTimeout, nil
+	}
+
+	if c.txid.IsEqual(&chainhash.Hash{}) {
+		txid, attestErr := c.attest(commitment, lastCommitment)
+		if attestErr != nil {
+			return CanaryPending, attestErr
+		}
+		c.txid = txid
+		log.WithFields(log.Fields{log.FieldTxid: txid.String(), log.FieldCommitment: commitment.String()}).Infoln("canary attestation committed")
+	}
+
+	tx, txErr := c.attester.Chain.GetTransaction(&c.txid)
+	if txErr != nil {
+		return CanaryPending, txErr
+	}
+	if tx.BlockHash != "" {
+		c.lastCommitment = &commitment
+		return CanaryConfirmed, nil
+	}
+	return CanaryPending, nil
+}
+
+// Create, sign and send canary attestation for commitment
+func (c *AttestCanary) attest(commitment chainhash.Hash, lastCommitment chainhash.Hash) (chainhash.Hash, error) {
+	found, unspent, unspentErr := c.attester.findLastUnspent()
+	if unspentErr != nil {
+		return chainhash.Hash{}, unspentErr
+	} else if !found {
+		return chainhash.Hash{}, errors.New(ErrorCanaryUnspentNotFound)
+	}
+
+	prevCommitment, prevErr := c.unspentCommitment(unspent, lastCommitment)
+	if prevErr != nil {
+		return chainhash.Hash{}, prevErr
+	}
+
+	key, keyErr := c.attester.GetNextAttestationKey(commitment)
+	if keyErr != nil {
+		return chainhash.Hash{}, keyErr
+	}
+	paytoaddr, _, addrErr := c.attester.GetNextAttestationAddr(key, commitment)
+	if addrErr != nil {
+		return chainhash.Hash{}, addrErr
+	}
+	if importErr := c.attester.ImportAttestationAddr(paytoaddr, false); importErr != nil {
+		return chainhash.Hash{}, importErr
+	}
+
+	tx, createErr := c.attester.createAttestation(paytoaddr, []btcjson.ListUnspentResult{unspent})
+	if createErr != nil {
+		return chainhash.Hash{}, createErr
+	}
+	signedTx, signErr := c.attester.signAttestation(tx, nil, prevCommitment)
+	if signErr != nil {
+		return chainhash.Hash{}, signErr
+	}
+	return c.attester.sendAttestation(signedTx)
+}
+
+// Return the commitment used to derive the address of the canary unspent
+// Candidates are the last confirmed canary commitment and the last commitment
+// of the main staychain, while the genesis transaction has no commitment
+func (c *AttestCanary) unspentCommitment(unspent btcjson.ListUnspentResult, lastCommitment chainhash.Hash) (
+	chainhash.Hash, error) {
+
+	if unspent.TxID == c.attester.txid0 {
+		return chainhash.Hash{}, nil
+	}
+
+	candidates := []chainhash.Hash{lastCommitment}
+	if c.lastCommitment != nil {
+		candidates = append([]chainhash.Hash{*c.lastCommitment}, candidates...)
+	}
+	for _, candidate := range candidates {
+		key, keyErr := c.attester.GetNextAttestationKey(candidate)
+		if keyErr != nil {
+			return chainhash.Hash{}, keyErr
+		}
+		addr, _, addrErr := c.attester.GetNextAttestationAddr(key, candidate)
+		if addrErr != nil {
+			return chainhash.Hash{}, addrErr
+		}
+		if addr.String() == unspent.Address {
+			return candidate, nil
+		}
+	}
+	return chainhash.Hash{}, errors.New(fmt.Sprintf("%s: %s", ErrorCanaryOutOfSync, unspent.TxID))
+}
@@ -0,0 +1,28 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test canary round timing out without canary staychain access
+func TestAttestCanaryTimeout(t *testing.T) {
+	commitment, _ := chainhash.NewHashFromStr("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	canary := &AttestCanary{
+		timeout:    time.Minute,
+		commitment: *commitment,
+		startTime:  time.Now().Add(-2 * time.Minute),
+	}
+
+	status, err := canary.Check(*commitment, chainhash.Hash{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, CanaryTimeout, status)
+	assert.Nil(t, canary.lastCommitment)
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	confpkg "mainstay/config"
+	"mainstay/log"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// ChainBackend interface
+//
+// Main chain access required by the attestation client to find the
+// staychain tip and topups, create, broadcast and track attestations
+// Method signatures follow the bitcoind rpc client, which implements the
+// interface through the node wallet, while alternative backends such as
+// Esplora only watch the staychain and topup address of the client
+type ChainBackend interface {
+	GetBlockCount() (int64, error)
+	ListUnspent() ([]btcjson.ListUnspentResult, error)
+	GetRawMempool() ([]*chainhash.Hash, error)
+	GetRawTransaction(*chainhash.Hash) (*btcutil.Tx, error)
+	GetRawTransactionVerbose(*chainhash.Hash) (*btcjson.TxRawResult, error)
+	GetTransaction(*chainhash.Hash) (*btcjson.GetTransactionResult, error)
+	GetMempoolEntry(string) (*btcjson.GetMempoolEntryResult, error)
+	CreateRawTransaction([]btcjson.TransactionInput, map[btcutil.Address]btcutil.Amount, *int64) (*wire.MsgTx, error)
+	SendRawTransaction(*wire.MsgTx, bool) (*chainhash.Hash, error)
+	ImportAddressRescan(string, string, bool) error
+}
+
+// Return chain backend from config, using Esplora if an
+// Esplora url is configured or the main client rpc otherwise
+// Main client rpc calls are run with the timeout deadline, fail over
+// between main clients if several rpc urls are configured, are
+// rate limited if a throttle is configured and include watch-only
+// wallet transactions if the wallet is watch-only
+func NewChainBackend(config *confpkg.Config, timeout *RpcTimeout) ChainBackend {
+	if esploraUrl := config.EsploraConfig().Url; esploraUrl != "" {
+		log.Infof("*Client* Accessing main chain through esplora %s\n", esploraUrl)
+		return NewEsploraClient(config.EsploraConfig(), config.InitTx(), config.TopupAddress())
+	}
+	mainClients := config.MainClients()
+	if len(mainClients) == 0 {
+		mainClients = []*rpcclient.Client{config.MainClient()}
+	}
+	nodes := make([]ChainBackend, len(mainClients))
+	for i, client := range mainClients {
+		nodes[i] = NewChainTimeout(client, timeout)
+	}
+	chain := nodes[0]
+	if len(nodes) > 1 {
+		log.Infof("*Client* Failing over between %d main client rpc nodes\n", len(nodes))
+		chain = NewChainFailover(nodes)
+	}
+	if throttle := NewRpcThrottle(config.ThrottleConfig()); throttle != nil {
+		log.Infof("*Client* Throttling main client rpc calls (%d/s, %d/min)\n",
+			config.ThrottleConfig().PerSecond, config.ThrottleConfig().PerMinute)
+		chain = NewChainThrottled(chain, throttle)
+	}
+	if config.WalletConfig().WatchOnly {
+		chain = NewChainWatchOnly(chain)
+	}
+	return chain
+}
@@ -0,0 +1,467 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// esplora consts
+const (
+	EsploraTimeout = 30 * time.Second // timeout of esplora requests
+
+	ErrorEsploraResponse      = "Invalid esplora response"
+	ErrorEsploraNotInMempool  = "Transaction not in mempool"
+	ErrorEsploraOutputMissing = "Transaction output not found"
+)
+
+// esplora transaction status
+type esploraStatus struct {
+	Confirmed   bool   `json:"confirmed"`
+	BlockHeight int64  `json:"block_height"`
+	BlockHash   string `json:"block_hash"`
+	BlockTime   int64  `json:"block_time"`
+}
+
+// esplora transaction output
+type esploraVout struct {
+	ScriptPubKey        string `json:"scriptpubkey"`
+	ScriptPubKeyAddress string `json:"scriptpubkey_address"`
+	Value               int64  `json:"value"`
+}
+
+// esplora transaction
+type esploraTx struct {
+	Txid   string        `json:"txid"`
+	Vout   []esploraVout `json:"vout"`
+	Size   int32         `json:"size"`
+	Fee    int64         `json:"fee"`
+	Status esploraStatus `json:"status"`
+}
+
+// esplora spending status of transaction output
+type esploraOutspend struct {
+	Spent  bool          `json:"spent"`
+	Txid   string        `json:"txid"`
+	Status esploraStatus `json:"status"`
+}
+
+// esplora unspent output of address
+type esploraUtxo struct {
+	Txid   string        `json:"txid"`
+	Vout   uint32        `json:"vout"`
+	Value  int64         `json:"value"`
+	Status esploraStatus `json:"status"`
+}
+
+// EsploraClient struct
+//
+// Implements ChainBackend interface through the http api of an Esplora
+// indexer, e.g. a hosted block explorer, for running without a full node
+// wallet. Instead of wallet unspents and mempool, the staychain is followed
+// from the init tx through the spends of the first output of each attestation
+// and the unspents of the topup address are looked up. Transactions are
+// created and signed locally and imported addresses are not required
+type EsploraClient struct {
+	client    *http.Client
+	url       string
+	txid0     string
+	addrTopup string
+
+	// latest confirmed staychain transaction found, from
+	// which the staychain is followed on the next lookup
+	mu  sync.Mutex
+	tip string
+
+	// time unconfirmed transactions were first broadcast or seen, as
+	// esplora does not report when transactions entered the mempool
+	seenMu sync.Mutex
+	seen   map[string]int64
+}
+
+// Return new EsploraClient instance following the staychain from txid0
+func NewEsploraClient(esploraConfig confpkg.EsploraConfig, txid0 string, addrTopup string) *EsploraClient {
+	return &EsploraClient{
+		client:    &http.Client{Timeout: EsploraTimeout},
+		url:       strings.TrimSuffix(esploraConfig.Url, "/"),
+		txid0:     txid0,
+		addrTopup: addrTopup,
+		tip:       txid0,
+		seen:      make(map[string]int64),
+	}
+}
+
+// Return time the unconfirmed transaction was first seen, recording the
+// current time if not seen before
+func (e *EsploraClient) firstSeen(txid string) int64 {
+	e.seenMu.Lock()
+	defer e.seenMu.Unlock()
+	if seen, ok := e.seen[txid]; ok {
+		return seen
+	}
+	seen := time.Now().Unix()
+	e.seen[txid] = seen
+	return seen
+}
+
+// Stop tracking the first seen time of the confirmed transaction
+func (e *EsploraClient) forgetSeen(txid string) {
+	e.seenMu.Lock()
+	defer e.seenMu.Unlock()
+	delete(e.seen, txid)
+}
+
+// Send esplora request and return response body
+func (e *EsploraClient) do(method string, path string, body []byte) ([]byte, error) {
+	req, reqErr := http.NewRequest(method, e.url+path, bytes.NewReader(body))
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	resp, respErr := e.client.Do(req)
+	if respErr != nil {
+		return nil, respErr
+	}
+	defer resp.Body.Close()
+	respBody, readErr := ioutil.ReadAll(resp.Body)
+	if readErr != nil {
+		return nil, readErr
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(fmt.Sprintf("%s: %s %d %s", ErrorEsploraResponse, path,
+			resp.StatusCode, strings.TrimSpace(string(respBody))))
+	}
+	return respBody, nil
+}
+
+// Send esplora get request and decode json response into result
+func (e *EsploraClient) get(path string, result interface{}) error {
+	body, getErr := e.do(http.MethodGet, path, nil)
+	if getErr != nil {
+		return getErr
+	}
+	if decodeErr := json.Unmarshal(body, result); decodeErr != nil {
+		return errors.New(fmt.Sprintf("%s: %s %v", ErrorEsploraResponse, path, decodeErr))
+	}
+	return nil
+}
+
+// Return number of confirmations at tip height of transaction status
+func confirmations(status esploraStatus, tipHeight int64) int64 {
+	if !status.Confirmed {
+		return 0
+	}
+	return tipHeight - status.BlockHeight + 1
+}
+
+// Follow the staychain from the latest confirmed tip found and return the
+// confirmed staychain tip and any unconfirmed staychain transactions after it
+func (e *EsploraClient) followStaychain() (string, []string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var unconfirmed []string
+	txid := e.tip
+	for {
+		var outspend esploraOutspend
+		if outspendErr := e.get("/tx/"+txid+"/outspend/0", &outspend); outspendErr != nil {
+			return "", nil, outspendErr
+		}
+		if !outspend.Spent {
+			return e.tip, unconfirmed, nil
+		}
+		if outspend.Status.Confirmed && len(unconfirmed) == 0 {
+			e.tip = outspend.Txid
+		} else {
+			unconfirmed = append(unconfirmed, outspend.Txid)
+		}
+		txid = outspend.Txid
+	}
+}
+
+// Return current block height
+func (e *EsploraClient) GetBlockCount() (int64, error) {
+	body, heightErr := e.do(http.MethodGet, "/blocks/tip/height", nil)
+	if heightErr != nil {
+		return 0, heightErr
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(body)), 10, 64)
+}
+
+// Return confirmed unspent output of the staychain tip, if not spent by
+// an unconfirmed attestation, and confirmed unspents of the topup address
+func (e *EsploraClient) ListUnspent() ([]btcjson.ListUnspentResult, error) {
+	tip, unconfirmed, followErr := e.followStaychain()
+	if followErr != nil {
+		return nil, followErr
+	}
+	tipHeight, heightErr := e.GetBlockCount()
+	if heightErr != nil {
+		return nil, heightErr
+	}
+
+	var unspent []btcjson.ListUnspentResult
+	if len(unconfirmed) == 0 {
+		var tx esploraTx
+		if txErr := e.get("/tx/"+tip, &tx); txErr != nil {
+			return nil, txErr
+		}
+		if len(tx.Vout) == 0 {
+			return nil, errors.New(fmt.Sprintf("%s: %s", ErrorEsploraOutputMissing, tip))
+		}
+		if tx.Status.Confirmed {
+			unspent = append(unspent, btcjson.ListUnspentResult{
+				TxID:          tip,
+				Vout:          0,
+				Address:       tx.Vout[0].ScriptPubKeyAddress,
+				ScriptPubKey:  tx.Vout[0].ScriptPubKey,
+				Amount:        btcutil.Amount(tx.Vout[0].Value).ToBTC(),
+				Confirmations: confirmations(tx.Status, tipHeight),
+				Spendable:     true,
+			})
+		}
+	}
+
+	if e.addrTopup != "" {
+		var utxos []esploraUtxo
+		if utxosErr := e.get("/address/"+e.addrTopup+"/utxo", &utxos); utxosErr != nil {
+			return nil, utxosErr
+		}
+		for _, utxo := range utxos {
+			if !utxo.Status.Confirmed || utxo.Txid == tip {
+				continue
+			}
+			unspent = append(unspent, btcjson.ListUnspentResult{
+				TxID:          utxo.Txid,
+				Vout:          utxo.Vout,
+				Address:       e.addrTopup,
+				Amount:        btcutil.Amount(utxo.Value).ToBTC(),
+				Confirmations: confirmations(utxo.Status, tipHeight),
+				Spendable:     true,
+			})
+		}
+	}
+	return unspent, nil
+}
+
+// Return unconfirmed staychain transactions
+func (e *EsploraClient) GetRawMempool() ([]*chainhash.Hash, error) {
+	_, unconfirmed, followErr := e.followStaychain()
+	if followErr != nil {
+		return nil, followErr
+	}
+	var mempool []*chainhash.Hash
+	for _, txid := range unconfirmed {
+		hash, hashErr := chainhash.NewHashFromStr(txid)
+		if hashErr != nil {
+			return nil, hashErr
+		}
+		mempool = append(mempool, hash)
+	}
+	return mempool, nil
+}
+
+// Return transaction
+func (e *EsploraClient) GetRawTransaction(txid *chainhash.Hash) (*btcutil.Tx, error) {
+	body, hexErr := e.do(http.MethodGet, "/tx/"+txid.String()+"/hex", nil)
+	if hexErr != nil {
+		return nil, hexErr
+	}
+	txBytes, decodeErr := hex.DecodeString(strings.TrimSpace(string(body)))
+	if decodeErr != nil {
+		return nil, errors.New(fmt.Sprintf("%s: %v", ErrorEsploraResponse, decodeErr))
+	}
+	return btcutil.NewTxFromBytes(txBytes)
+}
+
+// Return merkle block proving the transaction in the block
+// Esplora proves the transaction in the block confirming it
+func (e *EsploraClient) GetTxOutProof(txid string, blockhash string) ([]byte, error) {
+	body, proofErr := e.do(http.MethodGet, "/tx/"+txid+"/merkleblock-proof", nil)
+	if proofErr != nil {
+		return nil, proofErr
+	}
+	merkleBlock, decodeErr := hex.DecodeString(strings.TrimSpace(string(body)))
+	if decodeErr != nil {
+		return nil, errors.New(fmt.Sprintf("%s: %v", ErrorEsploraResponse, decodeErr))
+	}
+	return merkleBlock, nil
+}
+
+// Return confirmed transaction spending the staychain output of the transaction
+func (e *EsploraClient) GetStaychainSpend(txid string) (models.StaychainTx, bool, error) {
+	var outspend esploraOutspend
+	if outspendErr := e.get("/tx/"+txid+"/outspend/0", &outspend); outspendErr != nil {
+		return models.StaychainTx{}, false, outspendErr
+	}
+	if !outspend.Spent || !outspend.Status.Confirmed {
+		return models.StaychainTx{}, false, nil
+	}
+	return models.StaychainTx{Txid: outspend.Txid, Height: outspend.Status.BlockHeight,
+		Blockhash: outspend.Status.BlockHash, Time: outspend.Status.BlockTime}, true, nil
+}
+
+// Return fee rate in satoshis per vbyte for confirmation
+// within the target number of blocks from the esplora estimates
+func (e *EsploraClient) EstimateFeeRate(target int) (float64, error) {
+	var estimates map[string]float64
+	if estimatesErr := e.get("/fee-estimates", &estimates); estimatesErr != nil {
+		return 0, estimatesErr
+	}
+	feeRate, ok := estimates[strconv.Itoa(target)]
+	if !ok || feeRate <= 0 {
+		return 0, errors.New(fmt.Sprintf("%s: target %d", ErrorAdaptiveEstimate, target))
+	}
+	return feeRate, nil
+}
+
+// Return transaction with inputs decoded as by the main client rpc
+func (e *EsploraClient) GetRawTransactionVerbose(txid *chainhash.Hash) (*btcjson.TxRawResult, error) {
+	tx, txErr := e.GetRawTransaction(txid)
+	if txErr != nil {
+		return nil, txErr
+	}
+	msgTx := tx.MsgTx()
+	var txBuf bytes.Buffer
+	msgTx.Serialize(&txBuf)
+
+	result := &btcjson.TxRawResult{
+		Hex:      hex.EncodeToString(txBuf.Bytes()),
+		Txid:     msgTx.TxHash().String(),
+		Hash:     msgTx.WitnessHash().String(),
+		Size:     int32(msgTx.SerializeSize()),
+		Version:  msgTx.Version,
+		LockTime: msgTx.LockTime,
+	}
+	for _, txIn := range msgTx.TxIn {
+		asm, _ := txscript.DisasmString(txIn.SignatureScript)
+		result.Vin = append(result.Vin, btcjson.Vin{
+			Txid:      txIn.PreviousOutPoint.Hash.String(),
+			Vout:      txIn.PreviousOutPoint.Index,
+			ScriptSig: &btcjson.ScriptSig{Asm: asm, Hex: hex.EncodeToString(txIn.SignatureScript)},
+			Sequence:  txIn.Sequence,
+		})
+	}
+	return result, nil
+}
+
+// Return transaction status as returned by the main client wallet
+// The time of unconfirmed transactions is the time they were first
+// broadcast or seen by the client
+func (e *EsploraClient) GetTransaction(txid *chainhash.Hash) (*btcjson.GetTransactionResult, error) {
+	var tx esploraTx
+	if txErr := e.get("/tx/"+txid.String(), &tx); txErr != nil {
+		return nil, txErr
+	}
+	result := &btcjson.GetTransactionResult{
+		TxID:            txid.String(),
+		Fee:             -btcutil.Amount(tx.Fee).ToBTC(),
+		WalletConflicts: []string{},
+		Details:         []btcjson.GetTransactionDetailsResult{},
+	}
+	if !tx.Status.Confirmed {
+		result.Time = e.firstSeen(txid.String())
+		result.TimeReceived = result.Time
+	} else {
+		tipHeight, heightErr := e.GetBlockCount()
+		if heightErr != nil {
+			return nil, heightErr
+		}
+		e.forgetSeen(txid.String())
+		result.TimeReceived = tx.Status.BlockTime
+		result.BlockHash = tx.Status.BlockHash
+		result.BlockTime = tx.Status.BlockTime
+		result.Time = tx.Status.BlockTime
+		result.Confirmations = confirmations(tx.Status, tipHeight)
+	}
+	return result, nil
+}
+
+// Return fee and size of unconfirmed transaction
+// The time entering the mempool is the time it was first broadcast or seen
+// by the client
+func (e *EsploraClient) GetMempoolEntry(txid string) (*btcjson.GetMempoolEntryResult, error) {
+	var tx esploraTx
+	if txErr := e.get("/tx/"+txid, &tx); txErr != nil {
+		return nil, txErr
+	}
+	if tx.Status.Confirmed {
+		e.forgetSeen(txid)
+		return nil, errors.New(fmt.Sprintf("%s: %s", ErrorEsploraNotInMempool, txid))
+	}
+	return &btcjson.GetMempoolEntryResult{
+		Size: tx.Size,
+		Fee:  btcutil.Amount(tx.Fee).ToBTC(),
+		Time: e.firstSeen(txid),
+	}, nil
+}
+
+// Create unsigned transaction spending inputs to the amounts of each address
+// as the createrawtransaction rpc, with version 2 and final sequence numbers
+func (e *EsploraClient) CreateRawTransaction(inputs []btcjson.TransactionInput,
+	amounts map[btcutil.Address]btcutil.Amount, lockTime *int64) (*wire.MsgTx, error) {
+
+	msgTx := wire.NewMsgTx(2)
+	for _, input := range inputs {
+		hash, hashErr := chainhash.NewHashFromStr(input.Txid)
+		if hashErr != nil {
+			return nil, hashErr
+		}
+		msgTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, input.Vout), nil, nil))
+	}
+	for addr, amount := range amounts {
+		pkScript, pkScriptErr := txscript.PayToAddrScript(addr)
+		if pkScriptErr != nil {
+			return nil, pkScriptErr
+		}
+		msgTx.AddTxOut(wire.NewTxOut(int64(amount), pkScript))
+	}
+	if lockTime != nil {
+		msgTx.LockTime = uint32(*lockTime)
+	}
+	return msgTx, nil
+}
+
+// Broadcast transaction and return its txid
+func (e *EsploraClient) SendRawTransaction(msgTx *wire.MsgTx, allowHighFees bool) (*chainhash.Hash, error) {
+	var txBuf bytes.Buffer
+	if serializeErr := msgTx.Serialize(&txBuf); serializeErr != nil {
+		return nil, serializeErr
+	}
+	body, sendErr := e.do(http.MethodPost, "/tx", []byte(hex.EncodeToString(txBuf.Bytes())))
+	if sendErr != nil {
+		return nil, sendErr
+	}
+	txid, txidErr := chainhash.NewHashFromStr(strings.TrimSpace(string(body)))
+	if txidErr != nil {
+		return nil, txidErr
+	}
+	e.firstSeen(txid.String())
+	return txid, nil
+}
+
+// Addresses are not imported as the staychain and
+// topup address are looked up directly
+func (e *EsploraClient) ImportAddressRescan(address string, account string, rescan bool) error {
+	return nil
+}
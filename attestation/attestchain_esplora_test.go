@@ -0,0 +1,230 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	confpkg "mainstay/config"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// fake esplora api serving a staychain and topup address
+type esploraFake struct {
+	height    int64
+	txs       map[string]esploraTx
+	hexes     map[string]string
+	outspends map[string]esploraOutspend
+	utxos     map[string][]esploraUtxo
+	proofs    map[string]string
+	fees      map[string]float64
+	sent      []string
+}
+
+func (f *esploraFake) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	var result interface{}
+	switch {
+	case req.Method == http.MethodPost && req.URL.Path == "/tx":
+		body, _ := ioutil.ReadAll(req.Body)
+		txBytes, _ := hex.DecodeString(string(body))
+		tx, txErr := btcutil.NewTxFromBytes(txBytes)
+		if txErr != nil {
+			http.Error(rw, "sendrawtransaction RPC error", http.StatusBadRequest)
+			return
+		}
+		f.sent = append(f.sent, string(body))
+		rw.Write([]byte(tx.Hash().String()))
+		return
+	case req.URL.Path == "/blocks/tip/height":
+		json.NewEncoder(rw).Encode(f.height)
+		return
+	case req.URL.Path == "/fee-estimates":
+		json.NewEncoder(rw).Encode(f.fees)
+		return
+	case len(parts) == 2 && parts[0] == "tx":
+		result, _ = f.txs[parts[1]]
+	case len(parts) == 3 && parts[0] == "tx" && parts[2] == "hex":
+		if txHex, ok := f.hexes[parts[1]]; ok {
+			rw.Write([]byte(txHex))
+			return
+		}
+	case len(parts) == 3 && parts[0] == "tx" && parts[2] == "merkleblock-proof":
+		if proofHex, ok := f.proofs[parts[1]]; ok {
+			rw.Write([]byte(proofHex))
+			return
+		}
+	case len(parts) == 4 && parts[0] == "tx" && parts[2] == "outspend" && parts[3] == "0":
+		result = f.outspends[parts[1]]
+	case len(parts) == 3 && parts[0] == "address" && parts[2] == "utxo":
+		result = f.utxos[parts[1]]
+	}
+	if tx, isTx := result.(esploraTx); result == nil || (isTx && tx.Txid == "") {
+		http.Error(rw, "Transaction not found", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(rw).Encode(result)
+}
+
+// Test staychain unspents and mempool are found by following the staychain
+func TestEsploraClientStaychain(t *testing.T) {
+	txid0 := strings.Repeat("00", 31) + "01"
+	txid1 := strings.Repeat("00", 31) + "02"
+	txid2 := strings.Repeat("00", 31) + "03"
+	topupTxid := strings.Repeat("00", 31) + "04"
+	confirmed := func(height int64) esploraStatus {
+		return esploraStatus{Confirmed: true, BlockHeight: height, BlockHash: "blockhash", BlockTime: 1000 + height}
+	}
+	fake := &esploraFake{
+		height: 110,
+		txs: map[string]esploraTx{
+			txid1: esploraTx{Txid: txid1, Fee: 500, Size: 250, Status: confirmed(101),
+				Vout: []esploraVout{{ScriptPubKey: "a914", ScriptPubKeyAddress: "2N1", Value: 100000000}}},
+			txid2: esploraTx{Txid: txid2, Fee: 1000, Size: 250,
+				Vout: []esploraVout{{ScriptPubKey: "a914", ScriptPubKeyAddress: "2N2", Value: 99999000}}},
+		},
+		outspends: map[string]esploraOutspend{
+			txid0: esploraOutspend{Spent: true, Txid: txid1, Status: confirmed(101)},
+			txid1: esploraOutspend{},
+		},
+		utxos: map[string][]esploraUtxo{
+			"2Ntopup": []esploraUtxo{
+				{Txid: topupTxid, Vout: 1, Value: 50000000, Status: confirmed(105)},
+				{Txid: txid2, Vout: 1, Value: 50000000},
+			},
+		},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := NewEsploraClient(confpkg.EsploraConfig{Url: server.URL + "/"}, txid0, "2Ntopup")
+
+	height, heightErr := client.GetBlockCount()
+	assert.Equal(t, nil, heightErr)
+	assert.Equal(t, int64(110), height)
+
+	// confirmed tip unspent with confirmed topups
+	unspent, unspentErr := client.ListUnspent()
+	assert.Equal(t, nil, unspentErr)
+	assert.Equal(t, []btcjson.ListUnspentResult{
+		{TxID: txid1, Vout: 0, Address: "2N1", ScriptPubKey: "a914", Amount: 1, Confirmations: 10, Spendable: true},
+		{TxID: topupTxid, Vout: 1, Address: "2Ntopup", Amount: 0.5, Confirmations: 6, Spendable: true},
+	}, unspent)
+	mempool, mempoolErr := client.GetRawMempool()
+	assert.Equal(t, nil, mempoolErr)
+	assert.Equal(t, 0, len(mempool))
+	assert.Equal(t, txid1, client.tip)
+
+	// tip spent by unconfirmed attestation
+	fake.outspends[txid1] = esploraOutspend{Spent: true, Txid: txid2}
+	fake.outspends[txid2] = esploraOutspend{}
+	unspent, unspentErr = client.ListUnspent()
+	assert.Equal(t, nil, unspentErr)
+	assert.Equal(t, 1, len(unspent))
+	assert.Equal(t, topupTxid, unspent[0].TxID)
+	mempool, mempoolErr = client.GetRawMempool()
+	assert.Equal(t, nil, mempoolErr)
+	assert.Equal(t, 1, len(mempool))
+	assert.Equal(t, txid2, mempool[0].String())
+
+	// unconfirmed attestation fee and status
+	hash2, _ := chainhash.NewHashFromStr(txid2)
+	entry, entryErr := client.GetMempoolEntry(txid2)
+	assert.Equal(t, nil, entryErr)
+	assert.Equal(t, 0.00001, entry.Fee)
+	assert.Equal(t, int32(250), entry.Size)
+	tx, txErr := client.GetTransaction(hash2)
+	assert.Equal(t, nil, txErr)
+	assert.Equal(t, "", tx.BlockHash)
+	assert.Equal(t, int64(0), tx.Confirmations)
+	assert.Equal(t, entry.Time, tx.Time)
+
+	// unconfirmed time is kept from when the transaction was first seen
+	client.seen[txid2] = 1000
+	entry, _ = client.GetMempoolEntry(txid2)
+	assert.Equal(t, int64(1000), entry.Time)
+	tx, _ = client.GetTransaction(hash2)
+	assert.Equal(t, int64(1000), tx.Time)
+	assert.Equal(t, int64(1000), tx.TimeReceived)
+
+	// attestation confirmed
+	fake.txs[txid2] = esploraTx{Txid: txid2, Fee: 1000, Status: confirmed(110),
+		Vout: []esploraVout{{ScriptPubKey: "a914", ScriptPubKeyAddress: "2N2", Value: 99999000}}}
+	fake.outspends[txid1] = esploraOutspend{Spent: true, Txid: txid2, Status: confirmed(110)}
+	tx, txErr = client.GetTransaction(hash2)
+	assert.Equal(t, nil, txErr)
+	assert.Equal(t, "blockhash", tx.BlockHash)
+	assert.Equal(t, int64(1110), tx.Time)
+	assert.Equal(t, int64(1), tx.Confirmations)
+	assert.Equal(t, 0, len(client.seen))
+	_, entryErr = client.GetMempoolEntry(txid2)
+	assert.Equal(t, ErrorEsploraNotInMempool+": "+txid2, entryErr.Error())
+	unspent, unspentErr = client.ListUnspent()
+	assert.Equal(t, nil, unspentErr)
+	assert.Equal(t, txid2, unspent[0].TxID)
+	assert.Equal(t, txid2, client.tip)
+
+	// unknown transaction
+	_, txErr = client.GetTransaction(&chainhash.Hash{})
+	assert.Equal(t, true, strings.HasPrefix(txErr.Error(), ErrorEsploraResponse))
+}
+
+// Test transactions are created locally, decoded and broadcast
+func TestEsploraClientTransactions(t *testing.T) {
+	fake := &esploraFake{hexes: make(map[string]string)}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := NewEsploraClient(confpkg.EsploraConfig{Url: server.URL}, "", "")
+
+	addr, _ := btcutil.DecodeAddress("2N53Hkuyz8gSM2swdAvt7yqzxH8vVCKxgvK", &chaincfg.RegressionNetParams)
+	prevTxid := strings.Repeat("00", 31) + "01"
+	msgTx, createErr := client.CreateRawTransaction([]btcjson.TransactionInput{{Txid: prevTxid, Vout: 0}},
+		map[btcutil.Address]btcutil.Amount{addr: 1000}, nil)
+	assert.Equal(t, nil, createErr)
+	assert.Equal(t, int32(2), msgTx.Version)
+	assert.Equal(t, prevTxid, msgTx.TxIn[0].PreviousOutPoint.Hash.String())
+	assert.Equal(t, uint32(wire.MaxTxInSequenceNum), msgTx.TxIn[0].Sequence)
+	pkScript, _ := txscript.PayToAddrScript(addr)
+	assert.Equal(t, pkScript, msgTx.TxOut[0].PkScript)
+	assert.Equal(t, int64(1000), msgTx.TxOut[0].Value)
+
+	// redeem script is the last element of the decoded input script
+	msgTx.TxIn[0].SignatureScript, _ = txscript.NewScriptBuilder().AddOp(txscript.OP_0).
+		AddData([]byte{1, 2, 3}).AddData([]byte{0x51, 0x21}).Script()
+	var txBuf bytes.Buffer
+	msgTx.Serialize(&txBuf)
+	txid := msgTx.TxHash()
+	fake.hexes[txid.String()] = hex.EncodeToString(txBuf.Bytes())
+
+	rawTx, rawTxErr := client.GetRawTransaction(&txid)
+	assert.Equal(t, nil, rawTxErr)
+	assert.Equal(t, txid, rawTx.MsgTx().TxHash())
+	verboseTx, verboseErr := client.GetRawTransactionVerbose(&txid)
+	assert.Equal(t, nil, verboseErr)
+	assert.Equal(t, txid.String(), verboseTx.Hash)
+	assert.Equal(t, "0 010203 5121", verboseTx.Vin[0].ScriptSig.Asm)
+
+	sentTxid, sendErr := client.SendRawTransaction(msgTx, false)
+	assert.Equal(t, nil, sendErr)
+	assert.Equal(t, txid, *sentTxid)
+	assert.Equal(t, []string{hex.EncodeToString(txBuf.Bytes())}, fake.sent)
+	_, broadcastSeen := client.seen[txid.String()]
+	assert.True(t, broadcastSeen)
+
+	assert.Equal(t, nil, client.ImportAddressRescan(addr.String(), "", false))
+}
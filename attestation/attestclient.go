@@ -9,16 +9,20 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sort"
+	"sync"
 
 	confpkg "mainstay/config"
 	"mainstay/crypto"
 	"mainstay/log"
 
+	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 	"github.com/btcsuite/btcutil/hdkeychain"
@@ -33,6 +37,7 @@ const (
 	WarningFailedDecodingTopupMultisig  = `Could not decode multisig topup script`
 
 	ErrorInsufficientFunds          = `Insufficient unspent vout value (less than the 5*maxFee target)`
+	ErrorInsufficientFundsChild     = `Insufficient attestation vout value to pay for child transaction fee`
 	ErrorMissingMultisig            = `No multisig used - Client must be signer and include private key`
 	ErrorFailedDecodingInitMultisig = `Could not decode multisig init script`
 	ErrorMissingAddress             = `Client address missing from multisig script`
@@ -44,11 +49,28 @@ const (
 	ErrorInvalidChaincode           = `Invalid chaincode provided`
 	ErrorMissingChaincodes          = `Missing chaincodes for pubkeys`
 	ErrorTopUpScriptNumSigs         = `Different number of signatures in Init script to top-up script`
+	ErrorInvalidXpub                = `Invalid xpub provided`
+	ErrorXpubsScriptMismatch        = `Xpubs do not derive the pubkeys of the init script`
 )
 
 // coin in satoshis
 const Coin = 100000000
 
+// topup input selection consts
+const (
+	// minimum confirmations for a topup unspent to be swept
+	TopupMinConfirmations = 1
+
+	// maximum signed size of an attestation transaction when adding topups
+	// kept well below the 100kB standard transaction size limit
+	TopupMaxTxSize = 50000
+
+	// estimated serialized size of an unsigned attestation transaction
+	// with a single input and a single p2sh output, and of each extra input
+	unsignedAttestationTxSize = 83
+	unsignedTxInSize          = 41
+)
+
 // AttestClient structure
 //
 // This struct maintains rpc connection to the main bitcoin client
@@ -70,6 +92,9 @@ type AttestClient struct {
 	// rpc client connection to main bitcoin client
 	MainClient *rpcclient.Client
 
+	// main chain access, through the main client or an alternative backend
+	Chain ChainBackend
+
 	// chain config for main bitcoin client
 	MainChainCfg *chaincfg.Params
 
@@ -84,10 +109,17 @@ type AttestClient struct {
 	pubkeysExtended []*hdkeychain.ExtendedKey
 	pubkeys         []*btcec.PublicKey
 	chaincodes      [][]byte
+	signerSet       *AttestSignerSet
 	numOfSigs       int
 	addrTopup       string
 	scriptTopup     string
 
+	// keys of the config, before any key rotation, and lock of the keys
+	// above, replaced by the attestation service on key rotations while
+	// read by the request api through getKeys
+	baseKeys *attestClientKeys
+	keysMu   *sync.RWMutex
+
 	// states whether Attest Client struct is used for transaction
 	// signing or simply for address tweaking and transaction creation
 	// in signer case the wallet priv key of the signer is imported
@@ -95,6 +127,17 @@ type AttestClient struct {
 	WalletPriv      *btcutil.WIF
 	WalletPrivTopup *btcutil.WIF
 	WalletChainCode []byte
+
+	// passphrase and unlock seconds used to unlock an encrypted
+	// wallet around wallet signing operations
+	walletPassphrase string
+	walletUnlock     int64
+
+	// watch-only wallet holding no private keys, never unlocked
+	watchOnly bool
+
+	// deadline of main client rpc calls, cancelled on shutdown
+	rpcTimeout *RpcTimeout
 }
 
 // Parse topup configuration and return private keys related to topup addresses
@@ -114,9 +157,59 @@ func parseTopupKeys(config *confpkg.Config, isSigner bool) *btcutil.WIF {
 	return nil
 }
 
+// Derive initial private key from the init xprv along the derivation path
+func parseMainXprv(config *confpkg.Config) *btcutil.WIF {
+	path, pathErr := crypto.ParseDerivationPath(config.DerivationPath())
+	if pathErr != nil {
+		log.Error(pathErr)
+	}
+	xprv, xprvErr := crypto.ParseExtendedKey(config.InitXprv(), config.MainChainCfg())
+	if xprvErr != nil || !xprv.IsPrivate() {
+		log.Errorf("%s %s\n", ErrorInvalidPk, confpkg.StaychainInitXprvName)
+	}
+	baseKey, baseKeyErr := crypto.DeriveExtendedKey(xprv, path)
+	if baseKeyErr != nil {
+		log.Errorf("%s %s\n%v\n", ErrorInvalidPk, confpkg.StaychainInitXprvName, baseKeyErr)
+	}
+	pkWif, errPkWif := crypto.GetExtendedKeyWalletPrivKey(baseKey, config.MainChainCfg())
+	if errPkWif != nil {
+		log.Errorf("%s %s\n%v\n", ErrorInvalidPk, confpkg.StaychainInitXprvName, errPkWif)
+	}
+	return pkWif
+}
+
+// Derive base pubkeys and chaincodes of the init xpubs along the derivation path
+func parseMainXpubs(config *confpkg.Config) ([]*btcec.PublicKey, [][]byte) {
+	path, pathErr := crypto.ParseDerivationPath(config.DerivationPath())
+	if pathErr != nil {
+		log.Error(pathErr)
+	}
+	var pubkeys []*btcec.PublicKey
+	var chaincodes [][]byte
+	for _, xpubStr := range config.InitXpubs() {
+		xpub, xpubErr := crypto.ParseExtendedKey(xpubStr, config.MainChainCfg())
+		if xpubErr != nil {
+			log.Errorf("%s %s", ErrorInvalidXpub, xpubStr)
+		}
+		baseKey, baseKeyErr := crypto.DeriveExtendedKey(xpub, path)
+		if baseKeyErr != nil {
+			log.Errorf("%s %s\n%v\n", ErrorInvalidXpub, xpubStr, baseKeyErr)
+		}
+		pub, pubErr := baseKey.ECPubKey()
+		if pubErr != nil {
+			log.Errorf("%s %s\n%v\n", ErrorInvalidXpub, xpubStr, pubErr)
+		}
+		pubkeys = append(pubkeys, pub)
+		chaincodes = append(chaincodes, crypto.GetExtendedKeyChaincode(baseKey))
+	}
+	return pubkeys, chaincodes
+}
+
 // Parse main configuration and return private keys related to main addresses
 func parseMainKeys(config *confpkg.Config, isSigner bool) *btcutil.WIF {
-	if isSigner { // signer case import private key
+	if isSigner && config.InitXprv() != "" { // signer case derive private key from xprv
+		return parseMainXprv(config)
+	} else if isSigner { // signer case import private key
 		// Get initial private key
 		pk := config.InitPK()
 		pkWif, errPkWif := crypto.GetWalletPrivKey(pk)
@@ -134,20 +227,24 @@ func parseMainKeys(config *confpkg.Config, isSigner bool) *btcutil.WIF {
 // Any multisig related parameters are irrelevant and set to nil
 func newNonMultisigAttestClient(config *confpkg.Config, isSigner bool, wif *btcutil.WIF, wifTopup *btcutil.WIF) *AttestClient {
 	return &AttestClient{
-		MainClient:      config.MainClient(),
-		MainChainCfg:    config.MainChainCfg(),
-		Fees:            NewAttestFees(config.FeesConfig()),
-		txid0:           config.InitTx(),
-		script0:         "",
-		pubkeysExtended: nil,
-		pubkeys:         nil,
-		chaincodes:      nil,
-		numOfSigs:       1,
-		addrTopup:       config.TopupAddress(),
-		scriptTopup:     config.TopupScript(),
-		WalletPriv:      wif,
-		WalletPrivTopup: wifTopup,
-		WalletChainCode: []byte{}}
+		MainClient:       config.MainClient(),
+		MainChainCfg:     config.MainChainCfg(),
+		Fees:             NewAttestFees(config.FeesConfig(), config.RbfConfig()),
+		txid0:            config.InitTx(),
+		script0:          "",
+		pubkeysExtended:  nil,
+		pubkeys:          nil,
+		chaincodes:       nil,
+		numOfSigs:        1,
+		addrTopup:        config.TopupAddress(),
+		scriptTopup:      config.TopupScript(),
+		keysMu:           &sync.RWMutex{},
+		WalletPriv:       wif,
+		WalletPrivTopup:  wifTopup,
+		WalletChainCode:  []byte{},
+		walletPassphrase: config.WalletConfig().Passphrase,
+		walletUnlock:     walletUnlockSeconds(config.WalletConfig().UnlockSeconds),
+		watchOnly:        config.WalletConfig().WatchOnly}
 }
 
 // Return new AttestClient instance for the multisig case
@@ -161,18 +258,33 @@ func newMultisigAttestClient(config *confpkg.Config, isSigner bool, wif *btcutil
 		log.Errorf("%s. %d != %d", ErrorTopUpScriptNumSigs, numOfSigs, topUpnumOfSigs)
 	}
 
-	// get chaincodes of pubkeys from config
-	chaincodesStr := config.InitChaincodes()
-	if len(chaincodesStr) != len(pubkeys) {
-		log.Errorf("%s %d != %d", ErrorMissingChaincodes, len(chaincodesStr), len(pubkeys))
-	}
-	chaincodes := make([][]byte, len(pubkeys))
-	for i_c := range chaincodesStr {
-		ccBytes, ccBytesErr := hex.DecodeString(chaincodesStr[i_c])
-		if ccBytesErr != nil || len(ccBytes) != 32 {
-			log.Errorf("%s %s", ErrorInvalidChaincode, chaincodesStr[i_c])
+	// get chaincodes of pubkeys from config, or derive the pubkeys
+	// and their chaincodes from xpubs if these are set instead
+	var chaincodes [][]byte
+	if len(config.InitXpubs()) > 0 {
+		var xpubKeys []*btcec.PublicKey
+		xpubKeys, chaincodes = parseMainXpubs(config)
+		if len(xpubKeys) != len(pubkeys) {
+			log.Errorf("%s %d != %d", ErrorXpubsScriptMismatch, len(xpubKeys), len(pubkeys))
+		}
+		for i_p := range pubkeys {
+			if !xpubKeys[i_p].IsEqual(pubkeys[i_p]) {
+				log.Errorf("%s %s", ErrorXpubsScriptMismatch, config.InitXpubs()[i_p])
+			}
+		}
+	} else {
+		chaincodesStr := config.InitChaincodes()
+		if len(chaincodesStr) != len(pubkeys) {
+			log.Errorf("%s %d != %d", ErrorMissingChaincodes, len(chaincodesStr), len(pubkeys))
+		}
+		chaincodes = make([][]byte, len(pubkeys))
+		for i_c := range chaincodesStr {
+			ccBytes, ccBytesErr := hex.DecodeString(chaincodesStr[i_c])
+			if ccBytesErr != nil || len(ccBytes) != 32 {
+				log.Errorf("%s %s", ErrorInvalidChaincode, chaincodesStr[i_c])
+			}
+			chaincodes[i_c] = append(chaincodes[i_c], ccBytes...)
 		}
-		chaincodes[i_c] = append(chaincodes[i_c], ccBytes...)
 	}
 
 	// verify our key is one of the multisig keys in signer case
@@ -191,8 +303,33 @@ func newMultisigAttestClient(config *confpkg.Config, isSigner bool, wif *btcutil
 	}
 
 	// create extended keys from multisig pubs, to be used for tweaking and address generation
-	// using extended keys instead of normal pubkeys in order to perform key tweaking
-	// via bip-32 child derivation as opposed to regular cryptograpic tweaking
+	pubkeysExtended := newExtendedPubkeys(pubkeys, chaincodes)
+
+	return &AttestClient{
+		MainClient:       config.MainClient(),
+		MainChainCfg:     config.MainChainCfg(),
+		Fees:             NewAttestFees(config.FeesConfig(), config.RbfConfig()),
+		txid0:            config.InitTx(),
+		script0:          multisig,
+		pubkeysExtended:  pubkeysExtended,
+		pubkeys:          pubkeys,
+		chaincodes:       chaincodes,
+		numOfSigs:        numOfSigs,
+		addrTopup:        config.TopupAddress(),
+		scriptTopup:      config.TopupScript(),
+		keysMu:           &sync.RWMutex{},
+		WalletPriv:       wif,
+		WalletPrivTopup:  wifTopup,
+		WalletChainCode:  myChaincode,
+		walletPassphrase: config.WalletConfig().Passphrase,
+		walletUnlock:     walletUnlockSeconds(config.WalletConfig().UnlockSeconds),
+		watchOnly:        config.WalletConfig().WatchOnly}
+}
+
+// Return extended keys of multisig pubkeys and their chaincodes
+// Using extended keys instead of normal pubkeys in order to perform key tweaking
+// via bip-32 child derivation as opposed to regular cryptograpic tweaking
+func newExtendedPubkeys(pubkeys []*btcec.PublicKey, chaincodes [][]byte) []*hdkeychain.ExtendedKey {
 	var pubkeysExtended []*hdkeychain.ExtendedKey
 	for i_p, pub := range pubkeys {
 		// Ignoring any fields except key and chaincode, as these are only used for
@@ -202,22 +339,7 @@ func newMultisigAttestClient(config *confpkg.Config, isSigner bool, wif *btcutil
 		pubkeysExtended = append(pubkeysExtended,
 			hdkeychain.NewExtendedKey([]byte{}, pub.SerializeCompressed(), chaincodes[i_p], []byte{}, 0, 0, false))
 	}
-
-	return &AttestClient{
-		MainClient:      config.MainClient(),
-		MainChainCfg:    config.MainChainCfg(),
-		Fees:            NewAttestFees(config.FeesConfig()),
-		txid0:           config.InitTx(),
-		script0:         multisig,
-		pubkeysExtended: pubkeysExtended,
-		pubkeys:         pubkeys,
-		chaincodes:      chaincodes,
-		numOfSigs:       numOfSigs,
-		addrTopup:       config.TopupAddress(),
-		scriptTopup:     config.TopupScript(),
-		WalletPriv:      wif,
-		WalletPrivTopup: wifTopup,
-		WalletChainCode: myChaincode}
+	return pubkeysExtended
 }
 
 // NewAttestClient returns a pointer to a new AttestClient instance
@@ -231,12 +353,14 @@ func NewAttestClient(config *confpkg.Config, signerFlag ...bool) *AttestClient {
 	}
 
 	// top up config
+	rpcTimeout := NewRpcTimeout(config.RpcConfig())
+	chain := NewChainBackend(config, rpcTimeout)
 	topupAddrStr := config.TopupAddress()
 	topupScriptStr := config.TopupScript()
 	var pkWifTopup *btcutil.WIF
 	if topupAddrStr != "" && topupScriptStr != "" {
 		log.Infof("*Client* importing top-up addr: %s ...\n", topupAddrStr)
-		importErr := config.MainClient().ImportAddressRescan(topupAddrStr, "", false)
+		importErr := chain.ImportAddressRescan(topupAddrStr, "", false)
 		if importErr != nil {
 			log.Warnf("%s (%s)\n%v\n", WarningFailureImportingTopupAddress, topupAddrStr, importErr)
 		}
@@ -249,10 +373,22 @@ func NewAttestClient(config *confpkg.Config, signerFlag ...bool) *AttestClient {
 	multisig := config.InitScript()
 	var pkWif = parseMainKeys(config, isSigner)
 
+	if config.WalletConfig().WatchOnly {
+		log.Infoln("*Client* Watch-only wallet - all inputs signed by signers")
+		if multisig == "" {
+			log.Error(ErrorWatchOnlyMultisig)
+		}
+	}
+
+	var attestClient *AttestClient
 	if multisig != "" { // if multisig is set, parse pubkeys
-		return newMultisigAttestClient(config, isSigner, pkWif, pkWifTopup)
+		attestClient = newMultisigAttestClient(config, isSigner, pkWif, pkWifTopup)
+	} else {
+		attestClient = newNonMultisigAttestClient(config, isSigner, pkWif, pkWifTopup)
 	}
-	return newNonMultisigAttestClient(config, isSigner, pkWif, pkWifTopup)
+	attestClient.Chain = chain
+	attestClient.rpcTimeout = rpcTimeout
+	return attestClient
 }
 
 // Get next attestation key by tweaking with latest commitment hash
@@ -301,19 +437,26 @@ func (w *AttestClient) GetNextAttestationKey(hash chainhash.Hash) (*btcutil.WIF,
 func (w *AttestClient) GetNextAttestationAddr(key *btcutil.WIF, hash chainhash.Hash) (
 	btcutil.Address, string, error) {
 
+	return w.getKeys().attestationAddr(key, hash, w.MainChainCfg)
+}
+
+// Get attestation address of the keys using the commitment hash provided
+func (keys attestClientKeys) attestationAddr(key *btcutil.WIF, hash chainhash.Hash, chainCfg *chaincfg.Params) (
+	btcutil.Address, string, error) {
+
 	// In multisig case tweak all initial pubkeys and import
 	// a multisig address to the main client wallet
-	if len(w.pubkeysExtended) > 0 {
+	if len(keys.pubkeysExtended) > 0 {
 		// empty hash - no tweaking
 		if hash.IsEqual(&chainhash.Hash{}) {
-			multisigAddr, multisigScript := crypto.CreateMultisig(w.pubkeys, w.numOfSigs, w.MainChainCfg)
+			multisigAddr, multisigScript := crypto.CreateMultisig(keys.pubkeys, keys.numOfSigs, chainCfg)
 			return multisigAddr, multisigScript, nil
 		}
 
 		// hash non empty - tweak each pubkey
 		var tweakedPubs []*btcec.PublicKey
 		hashBytes := hash.CloneBytes()
-		for _, pub := range w.pubkeysExtended {
+		for _, pub := range keys.pubkeysExtended {
 			// tweak extended pubkeys
 			// pseudo bip-32 child derivation to do pub key tweaking
 			tweakedKey, tweakErr := crypto.TweakExtendedKey(pub, hashBytes)
@@ -329,13 +472,13 @@ func (w *AttestClient) GetNextAttestationAddr(key *btcutil.WIF, hash chainhash.H
 
 		// construct multisig and address from pubkey of extended key
 		multisigAddr, redeemScript := crypto.CreateMultisig(
-			tweakedPubs, w.numOfSigs, w.MainChainCfg)
+			tweakedPubs, keys.numOfSigs, chainCfg)
 
 		return multisigAddr, redeemScript, nil
 	}
 
 	// no multisig - signer case - use client key
-	myAddr, myAddrErr := crypto.GetAddressFromPrivKey(key, w.MainChainCfg)
+	myAddr, myAddrErr := crypto.GetAddressFromPrivKey(key, chainCfg)
 	if myAddrErr != nil {
 		return nil, "", myAddrErr
 	}
@@ -355,7 +498,7 @@ func (w *AttestClient) ImportAttestationAddr(addr btcutil.Address, rescan ...boo
 	}
 
 	// import address for unspent watching
-	importErr := w.MainClient.ImportAddressRescan(addr.String(), "", isRescan)
+	importErr := w.Chain.ImportAddressRescan(addr.String(), "", isRescan)
 	if importErr != nil {
 		return importErr
 	}
@@ -385,7 +528,7 @@ func (w *AttestClient) createAttestation(paytoaddr btcutil.Address, unspent []bt
 	}
 
 	// attempt to create raw transaction
-	msgTx, errCreate := w.MainClient.CreateRawTransaction(inputs, amounts, nil)
+	msgTx, errCreate := w.Chain.CreateRawTransaction(inputs, amounts, nil)
 	if errCreate != nil {
 		return nil, errCreate
 	}
@@ -395,8 +538,8 @@ func (w *AttestClient) createAttestation(paytoaddr btcutil.Address, unspent []bt
 	msgTx.TxIn[0].Sequence = uint32(math.Pow(2, float64(32))) - 3
 
 	// return error if txout value is less than maxFee target
-	maxFee := calcSignedTxFee(w.Fees.maxFee, msgTx.SerializeSize(),
-		len(w.script0)/2, w.numOfSigs, len(inputs))
+	signedVsize := w.calcSignedTxVsize(msgTx)
+	maxFee := int64(w.Fees.maxFee * signedVsize)
 	if msgTx.TxOut[0].Value < 5*maxFee {
 		return nil, errors.New(ErrorInsufficientFunds)
 	}
@@ -408,8 +551,7 @@ func (w *AttestClient) createAttestation(paytoaddr btcutil.Address, unspent []bt
 
 	// add fees using best fee-per-byte estimate
 	feePerByte := w.Fees.GetFee()
-	fee := calcSignedTxFee(feePerByte, msgTx.SerializeSize(),
-		len(w.script0)/2, w.numOfSigs, len(inputs))
+	fee := int64(feePerByte * signedVsize)
 	msgTx.TxOut[0].Value -= fee
 
 	return msgTx, nil
@@ -436,25 +578,115 @@ func (w *AttestClient) bumpAttestationFees(msgTx *wire.MsgTx, isFeeBumped bool)
 	feePerByteIncrement := w.Fees.GetFee() - prevFeePerByte
 
 	// increase tx fees by fee difference
-	feeIncrement := calcSignedTxFee(feePerByteIncrement, msgTx.SerializeSize(),
-		len(w.script0)/2, w.numOfSigs, len(msgTx.TxIn))
+	feeIncrement := int64(feePerByteIncrement * w.calcSignedTxVsize(msgTx))
 	msgTx.TxOut[0].Value -= feeIncrement
 
 	return nil
 }
 
+// Create a child transaction spending the unconfirmed attestation output back to
+// the same tweaked address, in order to speed up confirmation of the parent (CPFP)
+// The child fee is set so that the fee per byte of the parent and child package
+// matches the current fee, taking into account the fee already paid by the parent
+func (w *AttestClient) createAttestationChild(paytoaddr btcutil.Address, parentTx *wire.MsgTx, parentFee int64) (
+	*wire.MsgTx, error) {
+
+	// check parent has an attestation output to spend
+	if len(parentTx.TxOut) <= 0 {
+		return nil, errors.New(ErrorInputMissingForTx)
+	}
+
+	// spend attestation vout of parent paying all funds to the same address
+	parentTxid := parentTx.TxHash()
+	inputs := []btcjson.TransactionInput{{Txid: parentTxid.String(), Vout: 0}}
+	amounts := map[btcutil.Address]btcutil.Amount{
+		paytoaddr: btcutil.Amount(parentTx.TxOut[0].Value)}
+
+	// attempt to create raw transaction
+	msgTx, errCreate := w.Chain.CreateRawTransaction(inputs, amounts, nil)
+	if errCreate != nil {
+		return nil, errCreate
+	}
+
+	// set replace-by-fee flag so that the child can be bumped as usual
+	msgTx.TxIn[0].Sequence = uint32(math.Pow(2, float64(32))) - 3
+
+	// calculate fee required for the package and subtract parent fee
+	// child fee can never be less than the fee required for the child alone
+	feePerByte := w.Fees.GetFee()
+	childSize := w.calcSignedTxVsize(msgTx)
+	childFee := int64(feePerByte*(calcTxVsize(parentTx)+childSize)) - parentFee
+	if minChildFee := int64(feePerByte * childSize); childFee < minChildFee {
+		childFee = minChildFee
+	}
+	if msgTx.TxOut[0].Value <= childFee {
+		return nil, errors.New(ErrorInsufficientFundsChild)
+	}
+	msgTx.TxOut[0].Value -= childFee
+
+	return msgTx, nil
+}
+
+// Check if a transaction signals replace-by-fee (BIP 125)
+// A single input with a low enough sequence number is sufficient
+func signalsReplaceByFee(msgTx *wire.MsgTx) bool {
+	for _, txIn := range msgTx.TxIn {
+		if txIn.Sequence < wire.MaxTxInSequenceNum-1 {
+			return true
+		}
+	}
+	return false
+}
+
 // Calculate the size of a signed transaction by summing the unsigned tx size
 // and the redeem script size and estimated signature size of the scriptsig
 func calcSignedTxSize(unsignedTxSize int, scriptSize int, numOfSigs int, numOfInputs int) int {
-	var pushDataSize int
-	if pushDataSize = 0; scriptSize > 75 {
-		pushDataSize = 1
-	}
-	scriptSigSize := /*size byte*/ 1 + pushDataSize + scriptSize + /*00 byte*/ 1 + numOfSigs*( /*size byte*/ 1+72)
+	scriptSigSize := calcScriptSigSize(scriptSize, numOfSigs)
 	scriptSigSizeSize := wire.VarIntSerializeSize(uint64(scriptSigSize)) - 1 // unsignedTxSize includes 1 byte already
 	return unsignedTxSize + (scriptSigSize+scriptSigSizeSize)*numOfInputs
 }
 
+// Calculate the size of a multisig scriptsig spending a p2sh output, i.e. the
+// 00 byte, the pushes of the signatures with the max DER signature size
+// and the push of the redeem script
+func calcScriptSigSize(scriptSize int, numOfSigs int) int {
+	var pushDataSize int
+	switch {
+	case scriptSize > math.MaxUint8:
+		pushDataSize = 2 // OP_PUSHDATA2
+	case scriptSize > txscript.OP_DATA_75:
+		pushDataSize = 1 // OP_PUSHDATA1
+	}
+	return /*size byte*/ 1 + pushDataSize + scriptSize + /*00 byte*/ 1 + numOfSigs*( /*size byte*/ 1+72)
+}
+
+// Calculate the virtual size of the transaction once signed, by setting the
+// scriptsig of each input to the estimated scriptsig of its redeem script,
+// i.e. the attestation script for vin 0 and the topup script for any topup vin
+func (w *AttestClient) calcSignedTxVsize(msgTx *wire.MsgTx) int {
+	signedTx := msgTx.Copy()
+	for i, txIn := range signedTx.TxIn {
+		script := w.script0
+		if i > 0 && w.scriptTopup != "" {
+			script = w.scriptTopup
+		}
+		txIn.SignatureScript = make([]byte, calcScriptSigSize(len(script)/2, w.numOfSigs))
+	}
+	return calcTxVsize(signedTx)
+}
+
+// Calculate the virtual size of a transaction from its weight,
+// with witness data discounted by the witness scale factor
+func calcTxVsize(msgTx *wire.MsgTx) int {
+	weight := blockchain.GetTransactionWeight(btcutil.NewTx(msgTx))
+	return int((weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor)
+}
+
+// Estimate the fee of a single input attestation transaction at the fee per byte provided
+func (w *AttestClient) estimateAttestationFee(feePerByte int) int64 {
+	return calcSignedTxFee(feePerByte, unsignedAttestationTxSize, len(w.script0)/2, w.numOfSigs, 1)
+}
+
 // Calculate the actual fee of an unsigned transaction by taking into consideration
 // the size of the script and the number of signatures required and calculating the
 // aggregated transaction size with the fee per byte provided
@@ -562,7 +794,7 @@ func (w *AttestClient) SignTransaction(hash chainhash.Hash, msgTx wire.MsgTx) (
 
 	// get prev outpoint hash in order to generate tx inputs for signing
 	prevTxId := msgTx.TxIn[0].PreviousOutPoint.Hash
-	prevTx, prevTxErr := w.MainClient.GetRawTransaction(&prevTxId)
+	prevTx, prevTxErr := w.Chain.GetRawTransaction(&prevTxId)
 	if prevTxErr != nil {
 		return nil, "", prevTxErr
 	}
@@ -577,10 +809,11 @@ func (w *AttestClient) SignTransaction(hash chainhash.Hash, msgTx wire.MsgTx) (
 
 	// for any remaining vins - sign with topup privkey
 	// this should be a very rare occasion
-	for i := 1; i < len(msgTx.TxIn); i++ {
+	// without a topup privkey topup vins are left to the signers
+	for i := 1; i < len(msgTx.TxIn) && w.WalletPrivTopup != nil; i++ {
 		// fetch previous attestation transaction
 		prevTxId = msgTx.TxIn[i].PreviousOutPoint.Hash
-		prevTx, prevTxErr = w.MainClient.GetRawTransaction(&prevTxId)
+		prevTx, prevTxErr = w.Chain.GetRawTransaction(&prevTxId)
 		if prevTxErr != nil {
 			return nil, "", prevTxErr
 		}
@@ -590,8 +823,12 @@ func (w *AttestClient) SignTransaction(hash chainhash.Hash, msgTx wire.MsgTx) (
 	}
 
 	// attempt to sign transcation with provided inputs - keys
-	signedMsgTx, _, errSign := w.MainClient.SignRawTransaction3(
-		&msgTx, inputs, keys)
+	var signedMsgTx *wire.MsgTx
+	errSign := w.rpcTimeout.Do("signrawtransactionwithkey", false, func() (signErr error) {
+		signedMsgTx, _, signErr = w.MainClient.SignRawTransaction3(
+			&msgTx, inputs, keys)
+		return signErr
+	})
 	if errSign != nil {
 		return nil, "", errSign
 	}
@@ -628,33 +865,33 @@ func (w *AttestClient) signAttestation(msgtx *wire.MsgTx, sigs [][]crypto.Sig, h
 				if len(sigs) > i {
 					mySigs = append(mySigs, sigs[i]...)
 				}
-				// check we have the required number of sigs for vin
-				if len(mySigs) < w.numOfSigs {
-					return nil, errors.New(ErrorSigsMissingForVin)
-				}
-				// take up to numOfSigs sigs
-				combinedScriptSig := crypto.CreateScriptSig(mySigs[:w.numOfSigs], script)
-				signedMsgTx.TxIn[i].SignatureScript = combinedScriptSig
 			} else {
 				// check we have all the sigs required
 				if len(sigs) < len(signedMsgTx.TxIn) {
 					return nil, errors.New(ErrorSigsMissingForTx)
 				}
-				if len(sigs[i]) < w.numOfSigs {
-					return nil, errors.New(ErrorSigsMissingForVin)
-				}
 				// no mySigs - just use received client sigs and script
-				var redeemScriptBytes []byte
 				if i == 0 {
 					// for vin 0, use last attestation script
-					redeemScriptBytes, _ = hex.DecodeString(redeemScript)
+					script, _ = hex.DecodeString(redeemScript)
 				} else {
 					// for any other vin, use topup script as we assume topup use only
-					redeemScriptBytes, _ = hex.DecodeString(w.scriptTopup)
+					script, _ = hex.DecodeString(w.scriptTopup)
 				}
-				combinedScriptSig := crypto.CreateScriptSig(sigs[i][:w.numOfSigs], redeemScriptBytes)
-				signedMsgTx.TxIn[i].SignatureScript = combinedScriptSig
+				mySigs = sigs[i]
 			}
+			// select valid sigs of accepted signers for vin 0 and any
+			// signer of the topup script for topup vins
+			accepted, threshold := []bool(nil), w.numOfSigs
+			if i == 0 {
+				accepted, threshold = w.signerSet.accepted(w.pubkeys), w.signerSet.thresholdFor(w.numOfSigs)
+			}
+			selectedSigs, selectErr := w.selectSigs(mySigs, script, signedMsgTx, i, accepted, threshold)
+			if selectErr != nil {
+				return nil, selectErr
+			}
+			combinedScriptSig := crypto.CreateScriptSig(selectedSigs, script)
+			signedMsgTx.TxIn[i].SignatureScript = combinedScriptSig
 		}
 	}
 
@@ -665,7 +902,7 @@ func (w *AttestClient) signAttestation(msgtx *wire.MsgTx, sigs [][]crypto.Sig, h
 func (w *AttestClient) sendAttestation(msgtx *wire.MsgTx) (chainhash.Hash, error) {
 
 	// send signed attestation
-	txhash, errSend := w.MainClient.SendRawTransaction(msgtx, false)
+	txhash, errSend := w.Chain.SendRawTransaction(msgtx, false)
 	if errSend != nil {
 		return chainhash.Hash{}, errSend
 	}
@@ -673,6 +910,19 @@ func (w *AttestClient) sendAttestation(msgtx *wire.MsgTx) (chainhash.Hash, error
 	return *txhash, nil
 }
 
+// Return height of the block confirming the wallet transaction
+// from the current block count and the transaction confirmations
+func (w *AttestClient) getConfirmationHeight(tx *btcjson.GetTransactionResult) (int64, error) {
+	if tx.Confirmations <= 0 {
+		return 0, nil
+	}
+	blockCount, countErr := w.Chain.GetBlockCount()
+	if countErr != nil {
+		return 0, countErr
+	}
+	return blockCount - tx.Confirmations + 1, nil
+}
+
 // Verify that an unspent vout is on the tip of the subchain attestations
 func (w *AttestClient) verifyTxOnSubchain(txid chainhash.Hash) bool {
 	if txid.String() == w.txid0 { // genesis transaction
@@ -680,7 +930,7 @@ func (w *AttestClient) verifyTxOnSubchain(txid chainhash.Hash) bool {
 	} else {
 		// might be better to store subchain on init
 		// and no need to parse all transactions every time
-		txraw, err := w.MainClient.GetRawTransaction(&txid)
+		txraw, err := w.Chain.GetRawTransaction(&txid)
 		if err != nil {
 			return false
 		}
@@ -693,7 +943,7 @@ func (w *AttestClient) verifyTxOnSubchain(txid chainhash.Hash) bool {
 
 // Find the latest unspent vout that is on the tip of subchain attestations
 func (w *AttestClient) findLastUnspent() (bool, btcjson.ListUnspentResult, error) {
-	unspent, err := w.MainClient.ListUnspent()
+	unspent, err := w.Chain.ListUnspent()
 	if err != nil {
 		return false, btcjson.ListUnspentResult{}, err
 	}
@@ -709,29 +959,81 @@ func (w *AttestClient) findLastUnspent() (bool, btcjson.ListUnspentResult, error
 
 // Find unspent vout for topup address specified in attestation client init
 func (w *AttestClient) findTopupUnspent() (bool, btcjson.ListUnspentResult, error) {
-	unspent, err := w.MainClient.ListUnspent()
+	topupUnspent, err := w.findTopupUnspents()
 	if err != nil {
 		return false, btcjson.ListUnspentResult{}, err
 	}
+	if len(topupUnspent) > 0 {
+		return true, topupUnspent[0], nil
+	}
+	return false, btcjson.ListUnspentResult{}, nil
+}
+
+// Find all mature unspent vouts for the topup address specified in attestation
+// client init, limited so that the signed attestation transaction size does not
+// exceed the TopupMaxTxSize budget when all topups are added as inputs
+func (w *AttestClient) findTopupUnspents() ([]btcjson.ListUnspentResult, error) {
+	unspent, err := w.Chain.ListUnspent()
+	if err != nil {
+		return nil, err
+	}
+	return selectTopupUnspents(unspent, w.addrTopup, w.txid0,
+		len(w.script0)/2, w.numOfSigs, TopupMaxTxSize), nil
+}
+
+// Select topup unspents from the list of wallet unspents, largest first, until
+// the estimated signed transaction size reaches the max size budget provided
+func selectTopupUnspents(unspent []btcjson.ListUnspentResult, addrTopup string, txid0 string,
+	scriptSize int, numOfSigs int, maxTxSize int) []btcjson.ListUnspentResult {
+
+	var candidates []btcjson.ListUnspentResult
 	for _, u := range unspent {
 		// search for an address matching the topup address provided in config
 		// exclude txid0, as this signals the first staychain transaction
-		if u.Address == w.addrTopup && u.TxID != w.txid0 {
-			return true, u, nil
+		if u.Address == addrTopup && u.TxID != txid0 && u.Confirmations >= TopupMinConfirmations {
+			candidates = append(candidates, u)
 		}
 	}
-	return false, btcjson.ListUnspentResult{}, nil
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Amount > candidates[j].Amount
+	})
+
+	var selected []btcjson.ListUnspentResult
+	for _, u := range candidates {
+		// staychain input plus all selected topup inputs
+		numOfInputs := len(selected) + 2
+		unsignedSize := unsignedAttestationTxSize + (numOfInputs-1)*unsignedTxInSize
+		if calcSignedTxSize(unsignedSize, scriptSize, numOfSigs, numOfInputs) > maxTxSize {
+			break
+		}
+		selected = append(selected, u)
+	}
+	return selected
 }
 
 // Find any previously unconfirmed transactions in the client
+// If a child transaction (CPFP) is also unconfirmed, the unconfirmed
+// attestation at the tip of the subchain is returned instead of its parent
 func (w *AttestClient) getUnconfirmedTx() (bool, chainhash.Hash, error) {
-	mempool, err := w.MainClient.GetRawMempool()
+	mempool, err := w.Chain.GetRawMempool()
 	if err != nil {
 		return false, chainhash.Hash{}, err
 	}
+	var unconfirmed []chainhash.Hash
+	spent := make(map[chainhash.Hash]bool)
 	for _, hash := range mempool {
 		if w.verifyTxOnSubchain(*hash) {
-			return true, *hash, nil
+			unconfirmed = append(unconfirmed, *hash)
+			txraw, txErr := w.Chain.GetRawTransaction(hash)
+			if txErr != nil {
+				return false, chainhash.Hash{}, txErr
+			}
+			spent[txraw.MsgTx().TxIn[0].PreviousOutPoint.Hash] = true
+		}
+	}
+	for _, hash := range unconfirmed {
+		if !spent[hash] {
+			return true, hash, nil
 		}
 	}
 	return false, chainhash.Hash{}, nil
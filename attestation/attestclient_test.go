@@ -7,7 +7,9 @@ package attestation
 import (
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"math"
+	"strings"
 	"testing"
 
 	"mainstay/clients"
@@ -16,10 +18,14 @@ import (
 	"mainstay/models"
 	testpkg "mainstay/test"
 
+	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -536,10 +542,8 @@ func TestAttestClient_FeeBumping(t *testing.T) {
 
 		newFee := client.Fees.GetFee()
 		newValue := tx2.TxOut[0].Value
-		newTxFee := calcSignedTxFee(newFee, tx2.SerializeSize(),
-			len(client.script0)/2, client.numOfSigs, len(tx2.TxIn))
-		currentTxFee := calcSignedTxFee(currentFee, tx.SerializeSize(),
-			len(client.script0)/2, client.numOfSigs, len(tx.TxIn))
+		newTxFee := int64(newFee * client.calcSignedTxVsize(tx2))
+		currentTxFee := int64(currentFee * client.calcSignedTxVsize(tx))
 		assert.Equal(t, newTxFee-currentTxFee, currentValue+topupValue-newValue)
 		assert.Equal(t, client.Fees.minFee+client.Fees.feeIncrement, newFee)
 
@@ -602,3 +606,165 @@ func TestAttestClient_feeCalculation(t *testing.T) {
 	assert.Equal(t, int64(8510), calcSignedTxFee(feePerByte, unsignedTxSize, scriptSize2, numOfSigs2, 3))
 	assert.Equal(t, 10, int(calcSignedTxFee(feePerByte, unsignedTxSize, scriptSize2, numOfSigs2, 3))/calcSignedTxSize(unsignedTxSize, scriptSize2, numOfSigs2, 3))
 }
+
+// Test signed transaction vsize is estimated from the redeem script of each input
+func TestAttestClient_calcSignedTxVsize(t *testing.T) {
+	c := newSignerSetTestClient()
+	tx := newSignerSetTestTx()
+	vsize := c.client.calcSignedTxVsize(tx)
+	assert.Equal(t, 0, len(tx.TxIn[0].SignatureScript))
+
+	// estimate covers the signed transaction by at most the signature size margin
+	signedTx := tx.Copy()
+	builder := txscript.NewScriptBuilder().AddOp(txscript.OP_0)
+	for _, sig := range c.sign(t, tx, 0, 1) {
+		builder.AddData(sig)
+	}
+	signedTx.TxIn[0].SignatureScript, _ = builder.AddData(c.script).Script()
+	c.verify(t, signedTx)
+	assert.Equal(t, signedTx.SerializeSize(), calcTxVsize(signedTx))
+	assert.Equal(t, true, vsize >= signedTx.SerializeSize())
+	assert.Equal(t, true, vsize-signedTx.SerializeSize() <= c.client.numOfSigs)
+
+	// topup inputs are estimated with the topup script
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{2}, 0), nil, nil))
+	vsizeTopup := c.client.calcSignedTxVsize(tx)
+	c.client.scriptTopup = testpkg.Script
+	scriptSigSize := calcScriptSigSize(len(c.client.script0)/2, c.client.numOfSigs)
+	scriptSigSizeTopup := calcScriptSigSize(len(testpkg.Script)/2, c.client.numOfSigs)
+	assert.Equal(t, 254, scriptSigSize)
+	assert.Equal(t, 219, scriptSigSizeTopup)
+	assert.Equal(t, vsizeTopup-(3+scriptSigSize)+(1+scriptSigSizeTopup), c.client.calcSignedTxVsize(tx))
+
+	// witness data is discounted
+	tx.TxIn[1].Witness = wire.TxWitness{make([]byte, 99)}
+	assert.Equal(t, tx.SerializeSizeStripped()+(tx.SerializeSize()-tx.SerializeSizeStripped()+3)/4, calcTxVsize(tx))
+}
+
+// Test replace-by-fee signaling check for attestation transactions
+func TestAttestClient_signalsReplaceByFee(t *testing.T) {
+	msgTx := wire.NewMsgTx(wire.TxVersion)
+	assert.Equal(t, false, signalsReplaceByFee(msgTx))
+
+	msgTx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil, nil))
+	assert.Equal(t, false, signalsReplaceByFee(msgTx))
+
+	msgTx.TxIn[0].Sequence = wire.MaxTxInSequenceNum - 2
+	assert.Equal(t, true, signalsReplaceByFee(msgTx))
+
+	// topup input not signaling does not affect replaceability
+	msgTx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil, nil))
+	assert.Equal(t, true, signalsReplaceByFee(msgTx))
+
+	msgTx.TxIn[0].Sequence = wire.MaxTxInSequenceNum - 1
+	assert.Equal(t, false, signalsReplaceByFee(msgTx))
+
+	msgTx.TxIn[1].Sequence = uint32(math.Pow(2, float64(32))) - 3
+	assert.Equal(t, true, signalsReplaceByFee(msgTx))
+}
+
+// Test topup unspent selection for attestation transactions
+func TestAttestClient_selectTopupUnspents(t *testing.T) {
+	scriptSize := len(testpkg.Script) / 2
+	_, numOfSigs := crypto.ParseRedeemScript(testpkg.Script)
+
+	addrTopup := "2N74sgEvpJRwBZqjYUEXwPfvuoLZnRaF1xJ"
+	txid0 := "6be7f4d5c3aa4e8b5a1c2c4b6f2d9d0c3e2d8f0a1b2c3d4e5f60718293a4b5c6"
+	unspent := []btcjson.ListUnspentResult{
+		{TxID: "aa", Address: addrTopup, Amount: 1, Confirmations: 3},
+		{TxID: "bb", Address: "2MxBi6eodnuoVCw8McGrf1nuoVhastqoBXB", Amount: 10, Confirmations: 3},
+		{TxID: txid0, Address: addrTopup, Amount: 50, Confirmations: 3},
+		{TxID: "cc", Address: addrTopup, Amount: 5, Confirmations: 1},
+		{TxID: "dd", Address: addrTopup, Amount: 20, Confirmations: 0},
+		{TxID: "ee", Address: addrTopup, Amount: 2, Confirmations: 10},
+	}
+
+	// no topups when no unspents
+	assert.Equal(t, 0, len(selectTopupUnspents(nil, addrTopup, txid0, scriptSize, numOfSigs, TopupMaxTxSize)))
+
+	// all mature topups selected, largest first
+	selected := selectTopupUnspents(unspent, addrTopup, txid0, scriptSize, numOfSigs, TopupMaxTxSize)
+	assert.Equal(t, 3, len(selected))
+	assert.Equal(t, "cc", selected[0].TxID)
+	assert.Equal(t, "ee", selected[1].TxID)
+	assert.Equal(t, "aa", selected[2].TxID)
+
+	// size budget limits the number of topups selected
+	assert.Equal(t, 603, calcSignedTxSize(165, scriptSize, numOfSigs, 3))
+	selected = selectTopupUnspents(unspent, addrTopup, txid0, scriptSize, numOfSigs, 700)
+	assert.Equal(t, 2, len(selected))
+	assert.Equal(t, "cc", selected[0].TxID)
+	assert.Equal(t, "ee", selected[1].TxID)
+
+	// no topups when budget smaller than a single topup
+	assert.Equal(t, 0, len(selectTopupUnspents(unspent, addrTopup, txid0, scriptSize, numOfSigs, 300)))
+}
+
+// Test attest client federation keys configured as xpubs and xprv
+func TestAttestClientXpubs(t *testing.T) {
+	var xprvs []*hdkeychain.ExtendedKey
+	var xpubStrs []string
+	var basePubs []*btcec.PublicKey
+	var baseChaincodes [][]byte
+	path := []uint32{0, 3}
+	for _, seed := range []string{"000102030405060708090a0b0c0d0e0f", "0f0e0d0c0b0a09080706050403020100"} {
+		seedBytes, _ := hex.DecodeString(seed)
+		xprv, _ := hdkeychain.NewMaster(seedBytes, &chaincfg.RegressionNetParams)
+		xpub, _ := xprv.Neuter()
+		baseKey, _ := crypto.DeriveExtendedKey(xpub, path)
+		basePub, _ := baseKey.ECPubKey()
+		xprvs = append(xprvs, xprv)
+		xpubStrs = append(xpubStrs, xpub.String())
+		basePubs = append(basePubs, basePub)
+		baseChaincodes = append(baseChaincodes, crypto.GetExtendedKeyChaincode(baseKey))
+	}
+	_, script := crypto.CreateMultisig(basePubs, 1, &chaincfg.RegressionNetParams)
+
+	config, configErr := confpkg.NewConfig([]byte(fmt.Sprintf(`
+    {
+        "main": {
+            "rpcurl": "localhost:18443",
+            "rpcuser": "user",
+            "rpcpass": "pass",
+            "chain": "regtest"
+        },
+        "staychain": {
+            "initScript": "%s",
+            "initXpubs": "%s",
+            "initXprv": "%s",
+            "derivationPath": "m/0/3",
+            "topupScript": "%s"
+        }
+    }
+    `, script, strings.Join(xpubStrs, ", "), xprvs[1].String(), script)))
+	assert.Equal(t, nil, configErr)
+
+	// base keys derived from xpubs and signer key from xprv
+	wif := parseMainKeys(config, true)
+	assert.Equal(t, true, wif.PrivKey.PubKey().IsEqual(basePubs[1]))
+	client := newMultisigAttestClient(config, true, wif, nil)
+	assert.Equal(t, basePubs, client.pubkeys)
+	assert.Equal(t, baseChaincodes, client.chaincodes)
+	assert.Equal(t, baseChaincodes[1], client.WalletChainCode)
+
+	// attestation keys and address derived along path and tweak
+	hash, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	key, keyErr := client.GetNextAttestationKey(*hash)
+	assert.Equal(t, nil, keyErr)
+	attestationPriv, _ := crypto.DeriveAttestationKey(xprvs[1], path, hash.CloneBytes())
+	attestationPrivKey, _ := attestationPriv.ECPrivKey()
+	assert.Equal(t, attestationPrivKey.Serialize(), key.PrivKey.Serialize())
+
+	var attestationPubs []*btcec.PublicKey
+	for _, xpubStr := range xpubStrs {
+		xpub, _ := crypto.ParseExtendedKey(xpubStr, &chaincfg.RegressionNetParams)
+		attestationKey, _ := crypto.DeriveAttestationKey(xpub, path, hash.CloneBytes())
+		attestationPub, _ := attestationKey.ECPubKey()
+		attestationPubs = append(attestationPubs, attestationPub)
+	}
+	expectedAddr, expectedScript := crypto.CreateMultisig(attestationPubs, 1, &chaincfg.RegressionNetParams)
+	addr, addrScript, addrErr := client.GetNextAttestationAddr(key, *hash)
+	assert.Equal(t, nil, addrErr)
+	assert.Equal(t, expectedAddr.String(), addr.String())
+	assert.Equal(t, expectedScript, addrScript)
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"time"
+)
+
+// Clock interface
+// Source of the current time and of the timers scheduling attestation states
+// The system clock is used outside of tests, which replace it with a simulated
+// clock to run complete attestation cycles without waiting for the schedules
+type Clock interface {
+	Now() time.Time
+	NewTimer(time.Duration) ClockTimer
+}
+
+// ClockTimer interface
+// Timer sending the time on its channel once the duration has elapsed
+type ClockTimer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// clock of the attestation service timing
+var clock Clock = systemClock{}
+
+// Return time elapsed since t on the attestation service clock
+func since(t time.Time) time.Duration {
+	return clock.Now().Sub(t)
+}
+
+// Return duration until t on the attestation service clock
+func until(t time.Time) time.Duration {
+	return t.Sub(clock.Now())
+}
+
+// system clock using the time package
+type systemClock struct{}
+
+// Return current system time
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Return new system timer
+func (systemClock) NewTimer(d time.Duration) ClockTimer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+// system timer wrapping a time package timer
+type systemTimer struct {
+	timer *time.Timer
+}
+
+// Return timer channel
+func (t systemTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+// Stop timer
+func (t systemTimer) Stop() bool {
+	return t.timer.Stop()
+}
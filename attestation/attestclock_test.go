@@ -0,0 +1,163 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// simulated clock for timing dependent tests
+// Waiting on a new timer advances the clock straight to the timer deadline,
+// running the callbacks scheduled on the way in order of their deadlines, so
+// that the attestation service runs its schedules without waiting
+type simClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	seq    int
+	timers []*simTimer
+}
+
+// timer of the simulated clock firing on its channel or running its callback
+type simTimer struct {
+	clock *simClock
+	at    time.Time
+	seq   int
+	c     chan time.Time
+	f     func()
+}
+
+// Return simulated clock starting at the time provided
+func newSimClock(start time.Time) *simClock {
+	return &simClock{now: start}
+}
+
+// Use simulated clock for the attestation service timing
+// returning a function restoring the previous clock
+func useSimClock(c *simClock) func() {
+	prev := clock
+	clock = c
+	return func() { clock = prev }
+}
+
+// Return current simulated time
+func (c *simClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Return new timer and advance the clock to its deadline
+func (c *simClock) NewTimer(d time.Duration) ClockTimer {
+	t := c.schedule(d, nil)
+	c.Advance(t.at.Sub(c.Now()))
+	return t
+}
+
+// Run callback once the clock has been advanced by the duration
+func (c *simClock) AfterFunc(d time.Duration, f func()) ClockTimer {
+	return c.schedule(d, f)
+}
+
+// Add timer with deadline after the duration
+func (c *simClock) schedule(d time.Duration, f func()) *simTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d < 0 {
+		d = 0
+	}
+	c.seq++
+	t := &simTimer{clock: c, at: c.now.Add(d), seq: c.seq, c: make(chan time.Time, 1), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance clock by the duration, firing timers due in order of deadline
+// Callbacks run without the clock locked and may schedule new timers
+func (c *simClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	for {
+		sort.SliceStable(c.timers, func(i, j int) bool {
+			if c.timers[i].at.Equal(c.timers[j].at) {
+				return c.timers[i].seq < c.timers[j].seq
+			}
+			return c.timers[i].at.Before(c.timers[j].at)
+		})
+		if len(c.timers) == 0 || c.timers[0].at.After(end) {
+			break
+		}
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		if t.at.After(c.now) {
+			c.now = t.at
+		}
+		if t.f != nil {
+			c.mu.Unlock()
+			t.f()
+			c.mu.Lock()
+			continue
+		}
+		t.c <- c.now
+	}
+	c.now = end
+	c.mu.Unlock()
+}
+
+// Return timer channel
+func (t *simTimer) C() <-chan time.Time {
+	return t.c
+}
+
+// Stop timer if it has not fired
+func (t *simTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, timer := range t.clock.timers {
+		if timer == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Test simulated clock fires timers and callbacks in order of deadline
+func TestSimClock(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	c := newSimClock(start)
+
+	var fired []time.Duration
+	var tick func()
+	tick = func() {
+		fired = append(fired, c.Now().Sub(start))
+		if len(fired) < 3 {
+			c.AfterFunc(10*time.Minute, tick)
+		}
+	}
+	c.AfterFunc(10*time.Minute, tick)
+	stopped := c.AfterFunc(5*time.Minute, func() { fired = append(fired, -1) })
+	assert.Equal(t, true, stopped.Stop())
+	assert.Equal(t, false, stopped.Stop())
+
+	// waiting on a timer runs the callbacks due before its deadline
+	timer := c.NewTimer(25 * time.Minute)
+	assert.Equal(t, start.Add(25*time.Minute), <-timer.C())
+	assert.Equal(t, []time.Duration{10 * time.Minute, 20 * time.Minute}, fired)
+	assert.Equal(t, false, timer.Stop())
+
+	c.Advance(time.Hour)
+	assert.Equal(t, []time.Duration{10 * time.Minute, 20 * time.Minute, 30 * time.Minute}, fired)
+	assert.Equal(t, start.Add(85*time.Minute), c.Now())
+
+	// service timing follows the clock in use
+	defer useSimClock(c)()
+	assert.Equal(t, 5*time.Minute, since(start.Add(80*time.Minute)))
+	assert.Equal(t, 5*time.Minute, until(start.Add(90*time.Minute)))
+}
@@ -0,0 +1,683 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/db"
+	"mainstay/models"
+	"mainstay/test"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// Full cycle tests run the attestation service against an in memory regtest
+// chain on a simulated clock, with blocks mined automatically at an interval
+// of simulated time, so that multi-round attestation lifecycles including
+// handling of unconfirmed attestations complete in well under a second
+
+// in memory regtest chain backend with a mempool, rbf replacement and a miner
+// including mempool transactions, with any unconfirmed ancestors, that pay
+// at least the min fee rate of the miner
+type regtestChainFake struct {
+	clock      *simClock
+	txs        map[chainhash.Hash]*wire.MsgTx
+	heights    map[chainhash.Hash]int64
+	blockTimes map[int64]time.Time
+	spent      map[wire.OutPoint]chainhash.Hash
+	confirmed  []chainhash.Hash
+	mempool    []chainhash.Hash
+	height     int64
+	minFeeRate int64           // min fee rate in satoshi per byte of transactions mined
+	forks      map[int64]int64 // reorgs before blocks were mined, changing their hashes
+	reorgs     int64
+	feeRate    float64 // mempool fee rate estimate in satoshi per vbyte
+}
+
+// Return regtest chain with the base transaction confirmed
+func newRegtestChainFake(clock *simClock, base *wire.MsgTx) *regtestChainFake {
+	c := &regtestChainFake{
+		clock:      clock,
+		txs:        make(map[chainhash.Hash]*wire.MsgTx),
+		heights:    make(map[chainhash.Hash]int64),
+		blockTimes: make(map[int64]time.Time),
+		spent:      make(map[wire.OutPoint]chainhash.Hash),
+		forks:      make(map[int64]int64),
+	}
+	txid := base.TxHash()
+	c.txs[txid] = base
+	c.height = 1
+	c.blockTimes[c.height] = clock.Now()
+	c.heights[txid] = c.height
+	c.confirmed = []chainhash.Hash{txid}
+	return c
+}
+
+// Mine blocks every interval of simulated time
+func (c *regtestChainFake) autoMine(interval time.Duration) {
+	c.clock.AfterFunc(interval, func() {
+		c.mine()
+		c.autoMine(interval)
+	})
+}
+
+// Mine block including mempool transactions paying the min fee rate
+// Transactions below the rate are included if a child pays for them
+func (c *regtestChainFake) mine() {
+	c.height++
+	c.blockTimes[c.height] = c.clock.Now()
+	c.forks[c.height] = c.reorgs
+	included := make(map[chainhash.Hash]bool)
+	for _, txid := range c.mempool {
+		if included[txid] {
+			continue
+		}
+		pkg := c.ancestors(txid, included)
+		var fee, size int64
+		for _, ancestor := range pkg {
+			fee += c.fee(c.txs[ancestor])
+			size += int64(c.txs[ancestor].SerializeSize())
+		}
+		if fee < c.minFeeRate*size {
+			continue
+		}
+		for _, ancestor := range pkg {
+			included[ancestor] = true
+			c.heights[ancestor] = c.height
+			c.confirmed = append(c.confirmed, ancestor)
+		}
+	}
+	var mempool []chainhash.Hash
+	for _, txid := range c.mempool {
+		if !included[txid] {
+			mempool = append(mempool, txid)
+		}
+	}
+	c.mempool = mempool
+}
+
+// Disconnect the blocks above the height, returning their transactions
+// to the mempool, so that blocks mined after have other hashes
+func (c *regtestChainFake) reorg(height int64) {
+	var confirmed, reorged []chainhash.Hash
+	for _, txid := range c.confirmed {
+		if c.heights[txid] > height {
+			delete(c.heights, txid)
+			reorged = append(reorged, txid)
+		} else {
+			confirmed = append(confirmed, txid)
+		}
+	}
+	c.confirmed = confirmed
+	c.mempool = append(reorged, c.mempool...)
+	c.height = height
+	c.reorgs++
+}
+
+// Return hash of the block at the height
+func (c *regtestChainFake) blockHash(height int64) string {
+	if fork := c.forks[height]; fork > 0 {
+		return chainhash.DoubleHashH([]byte(fmt.Sprintf("block %d fork %d", height, fork))).String()
+	}
+	return chainhash.DoubleHashH([]byte(fmt.Sprintf("block %d", height))).String()
+}
+
+// Return unconfirmed ancestors not yet included, parents first, and the transaction
+func (c *regtestChainFake) ancestors(txid chainhash.Hash, included map[chainhash.Hash]bool) []chainhash.Hash {
+	var pkg []chainhash.Hash
+	for _, txIn := range c.txs[txid].TxIn {
+		parent := txIn.PreviousOutPoint.Hash
+		if _, known := c.txs[parent]; !known || included[parent] || c.isConfirmed(parent) {
+			continue
+		}
+		pkg = append(pkg, c.ancestors(parent, included)...)
+	}
+	return append(pkg, txid)
+}
+
+// Return whether transaction is confirmed
+func (c *regtestChainFake) isConfirmed(txid chainhash.Hash) bool {
+	_, confirmed := c.heights[txid]
+	return confirmed
+}
+
+// Return fee of transaction in satoshis
+func (c *regtestChainFake) fee(tx *wire.MsgTx) int64 {
+	var fee int64
+	for _, txIn := range tx.TxIn {
+		if prev, ok := c.txs[txIn.PreviousOutPoint.Hash]; ok {
+			fee += prev.TxOut[txIn.PreviousOutPoint.Index].Value
+		}
+	}
+	for _, txOut := range tx.TxOut {
+		fee -= txOut.Value
+	}
+	return fee
+}
+
+// Remove mempool transaction and its descendants
+func (c *regtestChainFake) evict(txid chainhash.Hash) {
+	for _, other := range c.mempool {
+		for _, txIn := range c.txs[other].TxIn {
+			if txIn.PreviousOutPoint.Hash == txid {
+				c.evict(other)
+			}
+		}
+	}
+	for _, txIn := range c.txs[txid].TxIn {
+		delete(c.spent, txIn.PreviousOutPoint)
+	}
+	for i, other := range c.mempool {
+		if other == txid {
+			c.mempool = append(c.mempool[:i], c.mempool[i+1:]...)
+			break
+		}
+	}
+}
+
+func (c *regtestChainFake) GetBlockCount() (int64, error) {
+	return c.height, nil
+}
+
+func (c *regtestChainFake) ListUnspent() ([]btcjson.ListUnspentResult, error) {
+	var unspent []btcjson.ListUnspentResult
+	for _, txid := range c.confirmed {
+		for vout, txOut := range c.txs[txid].TxOut {
+			if _, spent := c.spent[*wire.NewOutPoint(&txid, uint32(vout))]; spent {
+				continue
+			}
+			_, addrs, _, _ := txscript.ExtractPkScriptAddrs(txOut.PkScript, &chaincfg.RegressionNetParams)
+			if len(addrs) == 0 {
+				continue
+			}
+			unspent = append(unspent, btcjson.ListUnspentResult{
+				TxID:          txid.String(),
+				Vout:          uint32(vout),
+				Address:       addrs[0].String(),
+				ScriptPubKey:  hex.EncodeToString(txOut.PkScript),
+				Amount:        btcutil.Amount(txOut.Value).ToBTC(),
+				Confirmations: c.height - c.heights[txid] + 1,
+				Spendable:     true,
+			})
+		}
+	}
+	return unspent, nil
+}
+
+func (c *regtestChainFake) GetRawMempool() ([]*chainhash.Hash, error) {
+	var mempool []*chainhash.Hash
+	for i := range c.mempool {
+		mempool = append(mempool, &c.mempool[i])
+	}
+	return mempool, nil
+}
+
+func (c *regtestChainFake) GetRawTransaction(txid *chainhash.Hash) (*btcutil.Tx, error) {
+	if tx, ok := c.txs[*txid]; ok {
+		return btcutil.NewTx(tx), nil
+	}
+	return nil, errors.New("No such mempool or blockchain transaction")
+}
+
+func (c *regtestChainFake) GetRawTransactionVerbose(txid *chainhash.Hash) (*btcjson.TxRawResult, error) {
+	tx, ok := c.txs[*txid]
+	if !ok {
+		return nil, errors.New("No such mempool or blockchain transaction")
+	}
+	result := &btcjson.TxRawResult{Txid: txid.String(), Hash: txid.String()}
+	for _, txIn := range tx.TxIn {
+		asm, _ := txscript.DisasmString(txIn.SignatureScript)
+		result.Vin = append(result.Vin, btcjson.Vin{Txid: txIn.PreviousOutPoint.Hash.String(),
+			Vout: txIn.PreviousOutPoint.Index, ScriptSig: &btcjson.ScriptSig{Asm: asm}})
+	}
+	return result, nil
+}
+
+func (c *regtestChainFake) GetTransaction(txid *chainhash.Hash) (*btcjson.GetTransactionResult, error) {
+	if _, ok := c.txs[*txid]; !ok {
+		return nil, errors.New("Invalid or non-wallet transaction id")
+	}
+	// fee of sent transactions is negative as reported by the wallet
+	result := &btcjson.GetTransactionResult{TxID: txid.String(), Time: c.clock.Now().Unix(),
+		Fee: -btcutil.Amount(c.fee(c.txs[*txid])).ToBTC()}
+	if height, confirmed := c.heights[*txid]; confirmed {
+		result.BlockHash = c.blockHash(height)
+		result.BlockTime = c.blockTimes[height].Unix()
+		result.Confirmations = c.height - height + 1
+		result.Time = c.blockTimes[height].Unix()
+	}
+	return result, nil
+}
+
+func (c *regtestChainFake) EstimateFeeRate(target int) (float64, error) {
+	return c.feeRate, nil
+}
+
+func (c *regtestChainFake) GetMempoolEntry(txid string) (*btcjson.GetMempoolEntryResult, error) {
+	hash, _ := chainhash.NewHashFromStr(txid)
+	for _, other := range c.mempool {
+		if other == *hash {
+			return &btcjson.GetMempoolEntryResult{Fee: btcutil.Amount(c.fee(c.txs[other])).ToBTC(),
+				Size: int32(c.txs[other].SerializeSize()), Time: c.clock.Now().Unix()}, nil
+		}
+	}
+	return nil, errors.New("Transaction not in mempool")
+}
+
+func (c *regtestChainFake) CreateRawTransaction(inputs []btcjson.TransactionInput,
+	amounts map[btcutil.Address]btcutil.Amount, lockTime *int64) (*wire.MsgTx, error) {
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	for _, input := range inputs {
+		prevTxid, hashErr := chainhash.NewHashFromStr(input.Txid)
+		if hashErr != nil {
+			return nil, hashErr
+		}
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(prevTxid, input.Vout), nil, nil))
+	}
+	var addrs []btcutil.Address
+	for addr := range amounts {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i].String() < addrs[j].String() })
+	for _, addr := range addrs {
+		pkScript, scriptErr := txscript.PayToAddrScript(addr)
+		if scriptErr != nil {
+			return nil, scriptErr
+		}
+		tx.AddTxOut(wire.NewTxOut(int64(amounts[addr]), pkScript))
+	}
+	if lockTime != nil {
+		tx.LockTime = uint32(*lockTime)
+	}
+	return tx, nil
+}
+
+// Accept transaction to the mempool, replacing conflicting mempool
+// transactions signaling rbf if the transaction pays a higher fee
+func (c *regtestChainFake) SendRawTransaction(tx *wire.MsgTx, allowHighFees bool) (*chainhash.Hash, error) {
+	txid := tx.TxHash()
+	if _, known := c.txs[txid]; known {
+		return nil, errors.New("txn-already-known")
+	}
+	conflicts := make(map[chainhash.Hash]bool)
+	for _, txIn := range tx.TxIn {
+		prev, ok := c.txs[txIn.PreviousOutPoint.Hash]
+		if !ok || int(txIn.PreviousOutPoint.Index) >= len(prev.TxOut) {
+			return nil, errors.New("bad-txns-inputs-missingorspent")
+		}
+		if spender, spent := c.spent[txIn.PreviousOutPoint]; spent {
+			if c.isConfirmed(spender) {
+				return nil, errors.New("bad-txns-inputs-missingorspent")
+			}
+			conflicts[spender] = true
+		}
+	}
+	for conflict := range conflicts {
+		if !signalsReplaceByFee(c.txs[conflict]) {
+			return nil, errors.New("txn-mempool-conflict")
+		} else if c.fee(tx) <= c.fee(c.txs[conflict]) {
+			return nil, errors.New("insufficient fee")
+		}
+	}
+	for conflict := range conflicts {
+		c.evict(conflict)
+	}
+	c.txs[txid] = tx.Copy()
+	for _, txIn := range tx.TxIn {
+		c.spent[txIn.PreviousOutPoint] = txid
+	}
+	c.mempool = append(c.mempool, txid)
+	return &txid, nil
+}
+
+func (c *regtestChainFake) ImportAddressRescan(addr string, label string, rescan bool) error {
+	return nil
+}
+
+// full cycle test harness running the attestation service with a single
+// signer on the regtest chain, with client commitments updated every block
+// unless clients are quiet
+type cycleHarness struct {
+	service *AttestService
+	chain   *regtestChainFake
+	clock   *simClock
+	dbFake  *db.DbFake
+	start   time.Time
+	quiet   bool
+}
+
+// Return full cycle test harness with the extra config provided
+// and the timing of the attestation service set to the simulated clock
+func newCycleHarness(t *testing.T, extraConf string) (*cycleHarness, func()) {
+	start := time.Unix(1500000000, 0)
+	simClock := newSimClock(start)
+	restoreClock := useSimClock(simClock)
+	// use same as init for topup for ease
+	scriptBytes, _ := hex.DecodeString(test.Script)
+	baseAddr, _ := btcutil.NewAddressScriptHash(scriptBytes, &chaincfg.RegressionNetParams)
+	base := newInitTestTx(chainhash.Hash{1}, baseAddr)
+	base.TxOut[0].Value = 100000000
+	chain := newRegtestChainFake(simClock, base)
+
+	config, configErr := confpkg.NewConfig([]byte(fmt.Sprintf(`
+    {
+        "main": {
+            "rpcurl": "localhost:18443",
+            "rpcuser": "user",
+            "rpcpass": "pass",
+            "chain": "regtest"
+        },
+        "staychain": {
+            "regtest": "1",
+            "initTx": "%s",
+            "initScript": "%s",
+            "initPK": "%s",
+            "initChaincodes": "%s",
+            "topupAddress": "%s",
+            "topupScript": "%s",
+            "topupPK": "%s"
+        }%s
+    }
+    `, base.TxHash().String(), test.Script, test.PrivMain, test.InitChaincodes,
+		baseAddr.String(), test.Script, test.PrivMain, extraConf)))
+	assert.Equal(t, nil, configErr)
+
+	dbFake := db.NewDbFake()
+	server := NewAttestServer(dbFake)
+	service := NewAttestService(context.Background(), &sync.WaitGroup{}, server,
+		NewAttestSignerFake([]*confpkg.Config{config}), config)
+	service.attester.Chain = chain
+	service.attester.Fees.ResetFee(true)
+	service.atimeRegtest = 0
+
+	h := &cycleHarness{service, chain, simClock, dbFake, start, false}
+	h.commit()
+	h.commitEvery(10 * time.Minute)
+	chain.autoMine(10 * time.Minute)
+	return h, func() {
+		restoreClock()
+	}
+}
+
+// Set a new client commitment
+func (h *cycleHarness) commit() {
+	hash := chainhash.DoubleHashH([]byte(h.clock.Now().String()))
+	h.dbFake.SetClientCommitments([]models.ClientCommitment{{Commitment: hash, ClientPosition: 0}})
+}
+
+// Set a new client commitment every interval of simulated time
+func (h *cycleHarness) commitEvery(interval time.Duration) {
+	h.clock.AfterFunc(interval, func() {
+		if !h.quiet {
+			h.commit()
+		}
+		h.commitEvery(interval)
+	})
+}
+
+// Run the attestation service until the duration of simulated time has elapsed
+func (h *cycleHarness) run(d time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	h.service.ctx = ctx
+	h.service.wg.Add(1)
+	h.clock.AfterFunc(d, cancel)
+	h.service.Run()
+}
+
+// Return confirmed attestations stored
+func (h *cycleHarness) confirmed() []models.Attestation {
+	var confirmed []models.Attestation
+	for _, attestation := range h.dbFake.Attestations {
+		if attestation.Confirmed {
+			confirmed = append(confirmed, attestation)
+		}
+	}
+	return confirmed
+}
+
+// Test several attestation rounds are confirmed on schedule
+// and form a staychain of the client commitments
+func TestAttestServiceCycle(t *testing.T) {
+	h, restore := newCycleHarness(t, `,
+        "timing": {
+            "newAttestationMinutes": "60",
+            "startupDelaySeconds": "0"
+        }`)
+	defer restore()
+	wallStart := time.Now()
+	h.run(6 * time.Hour)
+	assert.True(t, time.Since(wallStart) < 30*time.Second)
+
+	confirmed := h.confirmed()
+	assert.True(t, len(confirmed) >= 5, fmt.Sprintf("%d confirmed attestations", len(confirmed)))
+	prevTxid := h.chain.txs[h.chain.confirmed[0]].TxHash()
+	for i, attestation := range confirmed {
+		// each attestation spends the previous one and is confirmed on chain
+		assert.Equal(t, prevTxid, attestation.Tx.TxIn[0].PreviousOutPoint.Hash)
+		assert.True(t, h.chain.isConfirmed(attestation.Txid))
+		assert.Equal(t, h.chain.heights[attestation.Txid], attestation.Info.Height)
+		assert.Equal(t, h.chain.blockHash(attestation.Info.Height), attestation.Info.Blockhash)
+		assert.Equal(t, h.chain.blockTimes[attestation.Info.Height].Unix(), attestation.Info.BlockTime)
+		assert.Equal(t, h.chain.fee(&attestation.Tx), attestation.Info.Fee)
+		prevTxid = attestation.Txid
+
+		// rounds are at least the new attestation time apart
+		if i > 0 {
+			gap := time.Duration(attestation.Info.Time-confirmed[i-1].Info.Time) * time.Second
+			assert.True(t, gap >= 50*time.Minute, fmt.Sprintf("round %d after %s", i, gap))
+		}
+	}
+	assert.Equal(t, 0, len(h.dbFake.FeeBumps))
+	assert.Equal(t, 0, len(h.chain.mempool))
+}
+
+// Test unconfirmed attestations are fee bumped on the bump schedule
+// until confirmed once the mined fee rate rises above the initial fee
+func TestAttestServiceCycleFeeBumps(t *testing.T) {
+	h, restore := newCycleHarness(t, `,
+        "timing": {
+            "newAttestationMinutes": "60",
+            "handleUnconfirmedMinutes": "30",
+            "startupDelaySeconds": "0"
+        }`)
+	defer restore()
+
+	// blocks only include transactions paying more than the min fee
+	h.chain.minFeeRate = DefaultMinFee + 1
+	h.run(4 * time.Hour)
+
+	confirmed := h.confirmed()
+	assert.True(t, len(confirmed) >= 2, fmt.Sprintf("%d confirmed attestations", len(confirmed)))
+	assert.True(t, len(h.dbFake.FeeBumps) >= len(confirmed)-1)
+	for _, feeBump := range h.dbFake.FeeBumps {
+		assert.Equal(t, models.FeeBumpMethodRbf, feeBump.Method)
+		assert.Equal(t, "", feeBump.Error)
+		assert.Equal(t, int32(DefaultMinFee), feeBump.PrevFee)
+		assert.Equal(t, int32(DefaultMinFee+DefaultFeeIncrement), feeBump.Fee)
+
+		// bumped after the handle unconfirmed time of simulated time
+		assert.True(t, time.Unix(feeBump.Time, 0).Sub(h.start) >= 30*time.Minute)
+	}
+	for _, attestation := range confirmed {
+		assert.True(t, h.chain.fee(&attestation.Tx) >= h.chain.minFeeRate*int64(attestation.Tx.SerializeSize()))
+	}
+
+	// replaced attestations are not confirmed
+	for _, feeBump := range h.dbFake.FeeBumps {
+		txid, _ := chainhash.NewHashFromStr(feeBump.Txid)
+		assert.Equal(t, false, h.chain.isConfirmed(*txid))
+	}
+}
+
+// notifier recording the chain height when attestations are confirmed
+type confirmedHeightNotifier struct {
+	chain   *regtestChainFake
+	heights map[string]int64
+}
+
+func (n *confirmedHeightNotifier) Notify(event models.AttestationEvent) {
+	if event.Event == models.AttestationEventConfirmed {
+		n.heights[event.Txid] = n.chain.height
+	}
+}
+
+// Test attestations are only confirmed at the confirmation depth and
+// are not fee bumped while awaiting the depth once included in a block
+func TestAttestServiceCycleConfirmationDepth(t *testing.T) {
+	h, restore := newCycleHarness(t, `,
+        "timing": {
+            "newAttestationMinutes": "60",
+            "handleUnconfirmedMinutes": "20",
+            "startupDelaySeconds": "0"
+        },
+        "confirmation": {
+            "depth": "3"
+        }`)
+	defer restore()
+	notifier := &confirmedHeightNotifier{h.chain, make(map[string]int64)}
+	h.service.AddNotifier(notifier)
+	h.run(6 * time.Hour)
+
+	confirmed := h.confirmed()
+	assert.True(t, len(confirmed) >= 3, fmt.Sprintf("%d confirmed attestations", len(confirmed)))
+	for _, attestation := range confirmed {
+		height, notified := notifier.heights[attestation.Txid.String()]
+		assert.True(t, notified)
+		assert.True(t, height-attestation.Info.Height+1 >= 3,
+			fmt.Sprintf("confirmed at %d in block %d", height, attestation.Info.Height))
+	}
+	assert.Equal(t, 0, len(h.dbFake.FeeBumps))
+}
+
+// Test new attestations are paused with an alert while the daily fee
+// budget is exceeded and resume on the next day
+func TestAttestServiceCycleFeeBudget(t *testing.T) {
+	h, restore := newCycleHarness(t, `,
+        "timing": {
+            "newAttestationMinutes": "60",
+            "startupDelaySeconds": "0"
+        },
+        "budget": {
+            "daily": "1"
+        }`)
+	defer restore()
+	alerter := &alerterFake{}
+	h.service.alerter = alerter
+
+	// budget exceeded by the first attestation of the day
+	h.run(6 * time.Hour)
+	assert.Equal(t, 1, len(h.confirmed()))
+	assert.Equal(t, 1, len(alerter.alerts))
+	assert.Equal(t, WarningFeeBudgetExceeded, alerter.alerts[0].Error)
+	spend, spendErr := h.service.FeeBudget().FeeSpend()
+	assert.Equal(t, nil, spendErr)
+	assert.Equal(t, true, spend.Exceeded)
+	assert.Equal(t, h.confirmed()[0].Info.Fee, spend.Day)
+
+	// one more attestation after midnight
+	h.run(24 * time.Hour)
+	assert.Equal(t, 2, len(h.confirmed()))
+	assert.Equal(t, 2, len(alerter.alerts))
+	spend, _ = h.service.FeeBudget().FeeSpend()
+	assert.Equal(t, h.confirmed()[0].Info.Fee+h.confirmed()[1].Info.Fee, spend.Total)
+}
+
+// Test the time between attestations stretches while the mempool fee
+// rate is above the adaptive ceiling and shortens while below the floor
+func TestAttestServiceCycleAdaptive(t *testing.T) {
+	h, restore := newCycleHarness(t, `,
+        "timing": {
+            "newAttestationMinutes": "60",
+            "startupDelaySeconds": "0"
+        },
+        "adaptive": {
+            "feeCeiling": "20",
+            "feeFloor": "5",
+            "minMinutes": "30",
+            "maxMinutes": "180"
+        }`)
+	defer restore()
+
+	// rounds gaps between confirmed attestations from the first given
+	gaps := func(from int) []time.Duration {
+		var gaps []time.Duration
+		confirmed := h.confirmed()
+		for i := from + 1; i < len(confirmed); i++ {
+			gaps = append(gaps, time.Duration(confirmed[i].Info.Time-confirmed[i-1].Info.Time)*time.Second)
+		}
+		return gaps
+	}
+
+	// fee rate at twice the ceiling doubles the interval
+	h.chain.feeRate = 40
+	h.run(12 * time.Hour)
+	high := len(h.confirmed())
+	assert.True(t, high >= 4 && high <= 7, fmt.Sprintf("%d confirmed attestations", high))
+	for _, gap := range gaps(0) {
+		assert.True(t, gap >= 110*time.Minute, fmt.Sprintf("round after %s", gap))
+	}
+
+	// fee rate below the floor shortens the interval to the min
+	h.chain.feeRate = 1
+	h.run(6 * time.Hour)
+	low := gaps(high)
+	assert.True(t, len(low) >= 8, fmt.Sprintf("%d rounds", len(low)))
+	for _, gap := range low {
+		assert.True(t, gap < 50*time.Minute, fmt.Sprintf("round after %s", gap))
+	}
+}
+
+// Test the unchanged commitment is attested again after the max idle
+// time while clients are quiet, and not attested again without heartbeat
+func TestAttestServiceCycleHeartbeat(t *testing.T) {
+	h, restore := newCycleHarness(t, `,
+        "timing": {
+            "newAttestationMinutes": "60",
+            "startupDelaySeconds": "0"
+        }`)
+	h.quiet = true
+	h.run(12 * time.Hour)
+	assert.Equal(t, 1, len(h.confirmed()))
+	restore()
+
+	h, restore = newCycleHarness(t, `,
+        "timing": {
+            "newAttestationMinutes": "60",
+            "startupDelaySeconds": "0"
+        },
+        "heartbeat": {
+            "idleMinutes": "180"
+        }`)
+	defer restore()
+	h.quiet = true
+	h.run(12 * time.Hour)
+
+	confirmed := h.confirmed()
+	assert.True(t, len(confirmed) >= 4, fmt.Sprintf("%d confirmed attestations", len(confirmed)))
+	for i, attestation := range confirmed {
+		assert.Equal(t, confirmed[0].CommitmentHash(), attestation.CommitmentHash())
+		if i > 0 {
+			assert.Equal(t, confirmed[i-1].Txid, attestation.Tx.TxIn[0].PreviousOutPoint.Hash)
+			gap := time.Duration(attestation.Info.Time-confirmed[i-1].Info.Time) * time.Second
+			assert.True(t, gap >= 180*time.Minute, fmt.Sprintf("heartbeat %d after %s", i, gap))
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"mainstay/crypto"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// derivation error consts
+const (
+	ErrorDerivationTxidInvalid  = "Invalid attestation txid"
+	ErrorDerivationNotFound     = "Attestation not found"
+	ErrorDerivationRootMismatch = "Stored commitments do not match attestation merkle root"
+	ErrorDerivationNoKeys       = "No base keys to derive attestation address from"
+)
+
+// Return bip-32 derivation path of tweak, e.g. m/47011/.../51883
+// The empty tweak of the initial attestation derives no children
+func derivationPathString(tweak chainhash.Hash) string {
+	path := []string{"m"}
+	if tweak.IsEqual(&chainhash.Hash{}) {
+		return "m"
+	}
+	for _, index := range crypto.GetDerivationIndexes(tweak.CloneBytes()) {
+		path = append(path, fmt.Sprint(index))
+	}
+	return strings.Join(path, "/")
+}
+
+// Re-derive the address of a confirmed attestation from stored data
+// The merkle root of the attestation is recomputed from the stored
+// commitments and used to tweak the base keys of the key rotation active at
+// the height of the attestation, without replaying the attestations preceding it
+func (w *AttestClient) deriveAttestation(server *AttestServer, txidStr string) (models.AttestationDerivation, error) {
+	txid, txidErr := chainhash.NewHashFromStr(txidStr)
+	if txidErr != nil {
+		return models.AttestationDerivation{}, errors.New(ErrorDerivationTxidInvalid)
+	}
+	attestation, attestationErr := server.GetAttestation(*txid)
+	if attestationErr != nil {
+		return models.AttestationDerivation{}, attestationErr
+	}
+	merkleRoot := attestation.MerkleRoot
+	if !attestation.Confirmed || merkleRoot == "" {
+		return models.AttestationDerivation{}, errors.New(ErrorDerivationNotFound)
+	}
+
+	// recompute commitment from stored client commitments
+	commitment, commitmentErr := server.GetAttestationCommitment(*txid)
+	if commitmentErr != nil {
+		return models.AttestationDerivation{}, commitmentErr
+	}
+	hash := commitment.GetCommitmentHash()
+	if hash.String() != merkleRoot {
+		return models.AttestationDerivation{}, errors.New(fmt.Sprintf("%s: %s != %s",
+			ErrorDerivationRootMismatch, hash.String(), merkleRoot))
+	}
+
+	// keys of the key rotation active at the height of the attestation
+	rotations, rotationsErr := server.GetKeyRotations()
+	if rotationsErr != nil {
+		return models.AttestationDerivation{}, rotationsErr
+	}
+	history, historyErr := w.keyHistory(rotations, server.attestationHeight)
+	if historyErr != nil {
+		return models.AttestationDerivation{}, historyErr
+	}
+	keys := history.keysAt(attestation.Txid, attestation.BlockHeight)
+
+	// base keys are the multisig keys or the key of single key clients
+	var baseKeys []models.DerivationKey
+	if len(keys.pubkeysExtended) > 0 {
+		for i_p, pub := range keys.pubkeysExtended {
+			tweakedPub := keys.pubkeys[i_p]
+			if !hash.IsEqual(&chainhash.Hash{}) { // initial attestation not tweaked
+				tweakedKey, tweakErr := crypto.TweakExtendedKey(pub, hash.CloneBytes())
+				if tweakErr != nil {
+					return models.AttestationDerivation{}, tweakErr
+				}
+				var tweakPubErr error
+				if tweakedPub, tweakPubErr = tweakedKey.ECPubKey(); tweakPubErr != nil {
+					return models.AttestationDerivation{}, tweakPubErr
+				}
+			}
+			baseKeys = append(baseKeys, models.DerivationKey{
+				Pubkey:    hex.EncodeToString(keys.pubkeys[i_p].SerializeCompressed()),
+				Chaincode: hex.EncodeToString(keys.chaincodes[i_p]),
+				Derived:   hex.EncodeToString(tweakedPub.SerializeCompressed()),
+			})
+		}
+	} else if w.WalletPriv != nil {
+		tweakedKey, tweakErr := w.GetNextAttestationKey(hash)
+		if tweakErr != nil {
+			return models.AttestationDerivation{}, tweakErr
+		}
+		baseKeys = append(baseKeys, models.DerivationKey{
+			Pubkey:    hex.EncodeToString(w.WalletPriv.PrivKey.PubKey().SerializeCompressed()),
+			Chaincode: hex.EncodeToString(w.WalletChainCode),
+			Derived:   hex.EncodeToString(tweakedKey.PrivKey.PubKey().SerializeCompressed()),
+		})
+	} else {
+		return models.AttestationDerivation{}, errors.New(ErrorDerivationNoKeys)
+	}
+
+	key, keyErr := w.GetNextAttestationKey(hash)
+	if keyErr != nil {
+		return models.AttestationDerivation{}, keyErr
+	}
+	addr, script, addrErr := keys.attestationAddr(key, hash, w.MainChainCfg)
+	if addrErr != nil {
+		return models.AttestationDerivation{}, addrErr
+	}
+
+	return models.AttestationDerivation{
+		Txid:       txid.String(),
+		Commitment: hash.String(),
+		Tweak:      hex.EncodeToString(hash.CloneBytes()),
+		Path:       derivationPathString(hash),
+		BaseKeys:   baseKeys,
+		BaseScript: keys.script0,
+		Script:     script,
+		Address:    addr.String(),
+	}, nil
+}
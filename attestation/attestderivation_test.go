@@ -0,0 +1,140 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"encoding/hex"
+	"testing"
+
+	confpkg "mainstay/config"
+	"mainstay/crypto"
+	"mainstay/db"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+var derivationTestConf = []byte(`
+{
+    "main": {
+        "rpcurl": "localhost:18443",
+        "rpcuser": "user",
+        "rpcpass": "pass",
+        "chain": "regtest"
+    },
+    "staychain": {
+        "initTx": "87e56bda501ba6a022f12e178e9f1ac03fb2c07f04e1dfa62ac9e1d83cd840e1",
+        "initScript": "51210381324c14a482646e9ad7cf82372021e5ecb9a7e1b67ee168dddf1e97dafe40af210376c091faaeb6bb3b74e0568db5dd499746d99437758a5cb1e60ab38f02e279c352ae",
+        "initChaincodes": "0a090f710e47968aee906804f211cf10cde9a11e14908ca0f78cc55dd190ceaa,0a090f710e47968aee906804f211cf10cde9a11e14908ca0f78cc55dd190ceaa",
+        "topupScript": "51210381324c14a482646e9ad7cf82372021e5ecb9a7e1b67ee168dddf1e97dafe40af210376c091faaeb6bb3b74e0568db5dd499746d99437758a5cb1e60ab38f02e279c352ae"
+    }
+}
+`)
+
+// Test historical attestation addresses are re-derived from stored commitments
+func TestAttestClientDeriveAttestation(t *testing.T) {
+	config, configErr := confpkg.NewConfig(derivationTestConf)
+	assert.Equal(t, nil, configErr)
+	attester := newMultisigAttestClient(config, false, nil, nil)
+
+	dbFake := db.NewDbFake()
+	server := NewAttestServer(dbFake)
+
+	hash0, _ := chainhash.NewHashFromStr("abcadae1214d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("bbcadae1214d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*hash0, *hash1})
+	txid, _ := chainhash.NewHashFromStr("2222222222222222222222222222222222222222222222222222222222222222")
+	attestation := models.NewAttestation(*txid, commitment)
+	attestation.Confirmed = true
+	dbFake.SetClientCommitments([]models.ClientCommitment{{*hash0, 0, "", 0}, {*hash1, 1, "", 0}})
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*attestation))
+
+	// invalid and unknown txids
+	_, deriveErr := attester.deriveAttestation(server, "x")
+	assert.Equal(t, ErrorDerivationTxidInvalid, deriveErr.Error())
+	_, deriveErr = attester.deriveAttestation(server, hash0.String())
+	assert.Equal(t, ErrorDerivationNotFound, deriveErr.Error())
+
+	derivation, deriveErr := attester.deriveAttestation(server, txid.String())
+	assert.Equal(t, nil, deriveErr)
+	root := commitment.GetCommitmentHash()
+	assert.Equal(t, txid.String(), derivation.Txid)
+	assert.Equal(t, root.String(), derivation.Commitment)
+	assert.Equal(t, hex.EncodeToString(root.CloneBytes()), derivation.Tweak)
+	assert.Equal(t, derivationPathString(root), derivation.Path)
+	assert.Equal(t, config.InitScript(), derivation.BaseScript)
+	assert.Equal(t, 2, len(derivation.BaseKeys))
+	assert.Equal(t, "0381324c14a482646e9ad7cf82372021e5ecb9a7e1b67ee168dddf1e97dafe40af", derivation.BaseKeys[0].Pubkey)
+	assert.Equal(t, "0a090f710e47968aee906804f211cf10cde9a11e14908ca0f78cc55dd190ceaa", derivation.BaseKeys[0].Chaincode)
+
+	// script of the derived keys matches the address attested to
+	var derivedPubs []*btcec.PublicKey
+	for _, key := range derivation.BaseKeys {
+		pubBytes, _ := hex.DecodeString(key.Derived)
+		pub, pubErr := btcec.ParsePubKey(pubBytes, btcec.S256())
+		assert.Equal(t, nil, pubErr)
+		derivedPubs = append(derivedPubs, pub)
+	}
+	derivedAddr, derivedScript := crypto.CreateMultisig(derivedPubs, 1, config.MainChainCfg())
+	addr, script, _ := attester.GetNextAttestationAddr(nil, root)
+	assert.Equal(t, script, derivation.Script)
+	assert.Equal(t, derivedScript, derivation.Script)
+	assert.Equal(t, addr.String(), derivation.Address)
+	assert.Equal(t, derivedAddr.String(), derivation.Address)
+	assert.NotEqual(t, derivation.BaseScript, derivation.Script)
+
+	// stored commitments no longer matching merkle root
+	dbFake.MerkleCommitments = dbFake.MerkleCommitments[:1]
+	_, deriveErr = attester.deriveAttestation(server, txid.String())
+	assert.Contains(t, deriveErr.Error(), ErrorDerivationRootMismatch)
+}
+
+// Test attestations before and after a key rotation are derived
+// from the keys of the rotation active at their height
+func TestAttestClientDeriveAttestationKeyRotation(t *testing.T) {
+	testChain := newInitTestChain(t)
+	base := testChain.base
+	dbFake := db.NewDbFake()
+	server := NewAttestServer(dbFake)
+	chain := &initChainFake{txs: map[chainhash.Hash]*wire.MsgTx{base.TxHash(): base}}
+	client := *testChain.client
+	client.Chain = chain
+	baseScript := client.script0
+
+	rotation := newTestKeyRotation()
+	keys, keysErr := newRotationKeys(rotation, client.MainChainCfg)
+	assert.Equal(t, nil, keysErr)
+	txs := newRotationTestChain(t, &client, keys, server, chain, base.TxHash())
+	rotation.Id = 1
+	rotation.Status = models.KeyRotationActive
+	rotation.Txid = txs[2].TxHash().String()
+	rotation.Height = 3
+	assert.Equal(t, nil, server.RecordKeyRotation(rotation))
+	client.setKeys(keys)
+
+	for i_t, tx := range txs {
+		derivation, deriveErr := client.deriveAttestation(server, tx.TxHash().String())
+		assert.Equal(t, nil, deriveErr)
+		_, addrs, _, _ := txscript.ExtractPkScriptAddrs(tx.TxOut[0].PkScript, client.MainChainCfg)
+		assert.Equal(t, addrs[0].String(), derivation.Address)
+		if i_t < 2 {
+			assert.Equal(t, baseScript, derivation.BaseScript)
+		} else {
+			assert.Equal(t, rotation.InitScript, derivation.BaseScript)
+		}
+	}
+}
+
+// Test derivation path of tweak
+func TestDerivationPathString(t *testing.T) {
+	assert.Equal(t, "m", derivationPathString(chainhash.Hash{}))
+	hash, _ := chainhash.NewHashFromStr("abcadae1214d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	assert.Equal(t, "m/47011/44440/32754/24934/17028/40542/51051/4167/30629/19125/6196/56428/7834/19745/57818/51883",
+		derivationPathString(*hash))
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"mainstay/db"
+
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+// Attestation errors are classified so that transient rpc and db failures
+// are retried in place with backoff, remaining at the failing state, while
+// signer timeouts, fatal config errors and unknown errors reset the service
+// to AStateInit as before
+
+// error class type
+type ErrorClass int
+
+// error class values
+const (
+	ErrorClassUnknown       ErrorClass = 0
+	ErrorClassRpcTransient  ErrorClass = 1
+	ErrorClassDbTransient   ErrorClass = 2
+	ErrorClassSignerTimeout ErrorClass = 3
+	ErrorClassFatalConfig   ErrorClass = 4
+)
+
+// error class names used in logs and alerts
+var errorClassNames = map[ErrorClass]string{
+	ErrorClassUnknown:       "unknown",
+	ErrorClassRpcTransient:  "rpc_transient",
+	ErrorClassDbTransient:   "db_transient",
+	ErrorClassSignerTimeout: "signer_timeout",
+	ErrorClassFatalConfig:   "fatal_config",
+}
+
+// Return error class name
+func (c ErrorClass) String() string {
+	if name, ok := errorClassNames[c]; ok {
+		return name
+	}
+	return errorClassNames[ErrorClassUnknown]
+}
+
+// Return true if errors of this class may succeed if retried in place
+func (c ErrorClass) IsTransient() bool {
+	return c == ErrorClassRpcTransient || c == ErrorClassDbTransient
+}
+
+// bitcoin rpc error codes not defined in btcjson
+const (
+	rpcErrorInWarmup       btcjson.RPCErrorCode = -28 // node is starting up
+	rpcErrorWalletNotFound btcjson.RPCErrorCode = -18 // wallet not loaded
+)
+
+// rpc http status errors returned by rpcclient on non json responses
+const (
+	rpcStatusUnauthorized = "status code: 401"
+	rpcStatusForbidden    = "status code: 403"
+	rpcStatusServerError  = "status code: 5"
+)
+
+// AttestError struct
+// Error of an explicit class for failures that cannot be classified from
+// the underlying error alone, e.g. signatures missing after all retries
+type AttestError struct {
+	Class ErrorClass
+	Err   error
+}
+
+// Return new AttestError of class wrapping err
+func NewAttestError(class ErrorClass, err error) *AttestError {
+	return &AttestError{Class: class, Err: err}
+}
+
+// Implement error interface
+func (e *AttestError) Error() string {
+	return e.Err.Error()
+}
+
+// Return wrapped error
+func (e *AttestError) Unwrap() error {
+	return e.Err
+}
+
+// Return class of an attestation error
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ErrorClassUnknown
+	}
+
+	var attestErr *AttestError
+	if errors.As(err, &attestErr) {
+		return attestErr.Class
+	}
+
+	if db.IsTransientError(err) {
+		return ErrorClassDbTransient
+	}
+
+	var rpcErr *btcjson.RPCError
+	if errors.As(err, &rpcErr) {
+		switch rpcErr.Code {
+		case rpcErrorInWarmup:
+			return ErrorClassRpcTransient
+		case rpcErrorWalletNotFound, rpcErrorWalletUnlockNeeded, rpcErrorWalletPassphrase:
+			return ErrorClassFatalConfig
+		}
+		return ErrorClassUnknown
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrorClassRpcTransient
+	}
+
+	msg := err.Error()
+	switch {
+	case strings.HasPrefix(msg, rpcStatusUnauthorized), strings.HasPrefix(msg, rpcStatusForbidden):
+		return ErrorClassFatalConfig
+	case strings.HasPrefix(msg, rpcStatusServerError):
+		return ErrorClassRpcTransient
+	}
+	return ErrorClassUnknown
+}
+
+// retry policy defaults
+const (
+	DefaultStateRetries = 3                // in place retries of a transient failure
+	ATimeRetryBase      = 10 * time.Second // waiting time before the first retry - doubled for each retry
+	ATimeRetryMax       = 5 * time.Minute  // max waiting time between retries
+)
+
+// RetryPolicy struct
+// Number of in place retries of transient failures
+// and the exponential backoff between retries
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+// Return default retry policy
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: DefaultStateRetries,
+		BaseDelay:  ATimeRetryBase,
+		MaxDelay:   ATimeRetryMax,
+	}
+}
+
+// Return true if the error class should be retried
+// in place having already been retried n times
+func (p RetryPolicy) ShouldRetry(class ErrorClass, n int) bool {
+	return class.IsTransient() && n < p.MaxRetries
+}
+
+// Return backoff delay before retry n, starting from 1
+func (p RetryPolicy) Delay(n int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < n && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"mainstay/db"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test classification of attestation errors
+func TestClassifyError(t *testing.T) {
+	checksumErr := &db.ChecksumError{Collection: "Attestation", Expected: "a", Actual: "b"}
+	opErr := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+
+	cases := []struct {
+		err   error
+		class ErrorClass
+	}{
+		{nil, ErrorClassUnknown},
+		{errors.New("unknown failure"), ErrorClassUnknown},
+		{errors.New(fmt.Sprintf("%s %v", db.ErrorAttestationSave, "timeout")), ErrorClassDbTransient},
+		{errors.New(db.BadDataAttestationModel), ErrorClassUnknown},
+		{checksumErr, ErrorClassUnknown},
+		{opErr, ErrorClassRpcTransient},
+		{fmt.Errorf("post: %w", syscall.ECONNRESET), ErrorClassRpcTransient},
+		{io.EOF, ErrorClassRpcTransient},
+		{&btcjson.RPCError{Code: -28, Message: "Loading block index..."}, ErrorClassRpcTransient},
+		{&btcjson.RPCError{Code: -18, Message: "Requested wallet does not exist"}, ErrorClassFatalConfig},
+		{&btcjson.RPCError{Code: btcjson.ErrRPCInvalidParameter, Message: "invalid"}, ErrorClassUnknown},
+		{errors.New("status code: 401, response: \"\""), ErrorClassFatalConfig},
+		{errors.New("status code: 503, response: \"\""), ErrorClassRpcTransient},
+		{NewAttestError(ErrorClassSignerTimeout, errors.New("missing sigs")), ErrorClassSignerTimeout},
+		{NewAttestError(ErrorClassFatalConfig, io.EOF), ErrorClassFatalConfig},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.class, ClassifyError(c.err), fmt.Sprintf("%v", c.err))
+	}
+
+	attestErr := NewAttestError(ErrorClassSignerTimeout, io.EOF)
+	assert.Equal(t, io.EOF.Error(), attestErr.Error())
+	assert.Equal(t, true, errors.Is(attestErr, io.EOF))
+
+	assert.Equal(t, "rpc_transient", ErrorClassRpcTransient.String())
+	assert.Equal(t, "unknown", ErrorClass(10).String())
+}
+
+// Test retry policy retries and backoff delays
+func TestRetryPolicy(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 2, BaseDelay: 10 * time.Second, MaxDelay: 30 * time.Second}
+
+	assert.Equal(t, true, policy.ShouldRetry(ErrorClassRpcTransient, 0))
+	assert.Equal(t, true, policy.ShouldRetry(ErrorClassDbTransient, 1))
+	assert.Equal(t, false, policy.ShouldRetry(ErrorClassDbTransient, 2))
+	assert.Equal(t, false, policy.ShouldRetry(ErrorClassSignerTimeout, 0))
+	assert.Equal(t, false, policy.ShouldRetry(ErrorClassFatalConfig, 0))
+	assert.Equal(t, false, policy.ShouldRetry(ErrorClassUnknown, 0))
+
+	assert.Equal(t, 10*time.Second, policy.Delay(1))
+	assert.Equal(t, 20*time.Second, policy.Delay(2))
+	assert.Equal(t, 30*time.Second, policy.Delay(3))
+	assert.Equal(t, 30*time.Second, policy.Delay(10))
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"mainstay/log"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Commitment inclusion rule
+// A round closes when the attestation service reads the client commitments
+// for the next attestation. Each round includes, for every client position,
+// the latest commitment submitted at or before the round close. Commitments
+// submitted after the round close are left to the next round and a newer
+// commitment for the same position replaces an older one not yet read.
+//
+// Each round close is checked against the previous round: a commitment still
+// stored for a position that was submitted before the previous round closed,
+// but was not read by that round, was excluded from it, e.g. due to a db race.
+// Such exclusions are recorded for the client position so that clients can
+// tell exclusions apart from commitments that missed the round close. Only
+// rounds closed since the service started are checked
+
+// warning consts
+const (
+	WarningCommitmentExcluded     = "Commitment submitted before round close excluded from round"
+	WarningCommitmentExclusionLog = "Could not record commitment exclusion"
+)
+
+// attestation round
+// Close time, in unix milliseconds, and commitments read for each position
+type attestRound struct {
+	close       int64
+	commitments map[int32]chainhash.Hash
+	merkleRoot  chainhash.Hash
+}
+
+// Return client commitments excluded from the previous round that are read
+// by the round closing at roundClose and set the new round as the previous
+func (s *AttestServer) auditRound(roundClose int64, commitments []models.ClientCommitment,
+	merkleRoot chainhash.Hash) []models.CommitmentExclusion {
+
+	var exclusions []models.CommitmentExclusion
+	if prev := s.prevRound; prev != nil {
+		for _, c := range commitments {
+			if c.SubmittedAt == 0 || c.SubmittedAt > prev.close {
+				continue // submitted after the previous round closed
+			}
+			included, isIncluded := prev.commitments[c.ClientPosition]
+			if isIncluded && included == c.Commitment {
+				continue
+			}
+			exclusion := models.CommitmentExclusion{
+				ClientPosition: c.ClientPosition,
+				Commitment:     c.Commitment.String(),
+				SubmittedAt:    c.SubmittedAt,
+				RoundClose:     prev.close,
+				MerkleRoot:     prev.merkleRoot.String(),
+				Reason:         models.ExclusionReasonMissed,
+			}
+			if isIncluded {
+				exclusion.Included = included.String()
+			}
+			exclusions = append(exclusions, exclusion)
+		}
+	}
+
+	round := &attestRound{close: roundClose, commitments: make(map[int32]chainhash.Hash), merkleRoot: merkleRoot}
+	for _, c := range commitments {
+		round.commitments[c.ClientPosition] = c.Commitment
+	}
+	s.prevRound = round
+	return exclusions
+}
+
+// Record commitment exclusions in the server
+// Failing to record an exclusion does not fail the round
+func (s *AttestServer) recordExclusions(exclusions []models.CommitmentExclusion) {
+	for _, exclusion := range exclusions {
+		fields := log.Fields{
+			log.FieldClientPosition: exclusion.ClientPosition,
+			log.FieldCommitment:     exclusion.Commitment,
+		}
+		log.WithFields(fields).Warnln(WarningCommitmentExcluded)
+		if saveErr := s.dbInterface.SaveCommitmentExclusion(exclusion); saveErr != nil {
+			fields[log.FieldError] = saveErr
+			log.WithFields(fields).Warnln(WarningCommitmentExclusionLog)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"mainstay/log"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// Main client rpc calls are sent to the active node of several main chain
+// nodes, switching to the next node on transient rpc failures. The nodes are
+// health checked periodically so that the service returns to the preferred
+// node, the first one configured, once it is available again. Attestation
+// transactions are broadcast to all nodes for propagation robustness
+
+// failover health check interval
+const FailoverHealthInterval = time.Minute
+
+// failover error/warning consts
+const (
+	ErrorFailoverRawRequest   = "raw rpc requests not supported by failover node"
+	ErrorFailoverNoneHealthy  = "no healthy main chain node"
+	WarningFailoverNodeFailed = "main chain node failed"
+	WarningFailoverBroadcast  = "failed broadcasting to main chain node"
+)
+
+// ChainFailover struct
+// Chain backend failing over between several main chain nodes
+type ChainFailover struct {
+	nodes []ChainBackend
+
+	mu        sync.Mutex
+	active    int
+	lastCheck time.Time
+	now       func() time.Time
+}
+
+// Return new ChainFailover for the nodes, in order of preference
+func NewChainFailover(nodes []ChainBackend) *ChainFailover {
+	return &ChainFailover{nodes: nodes, now: time.Now}
+}
+
+// Return index of the active node
+func (c *ChainFailover) Active() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.active
+}
+
+// Set the active node, logging any switch
+func (c *ChainFailover) setActive(index int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active != index {
+		log.Infof("*Client* Switching main chain node %d to %d\n", c.active, index)
+		c.active = index
+	}
+}
+
+// Health check nodes in order of preference and set the
+// first node responding as the active node
+func (c *ChainFailover) HealthCheck() error {
+	c.mu.Lock()
+	c.lastCheck = c.now()
+	c.mu.Unlock()
+
+	var countErr error
+	for i, node := range c.nodes {
+		if _, countErr = node.GetBlockCount(); countErr == nil {
+			c.setActive(i)
+			return nil
+		}
+		log.Warnf("%s %d: %v\n", WarningFailoverNodeFailed, i, countErr)
+	}
+	return errors.New(fmt.Sprintf("%s: %v", ErrorFailoverNoneHealthy, countErr))
+}
+
+// Return index of the active node, health checking nodes first
+// if the active node is not the preferred node and a check is due
+func (c *ChainFailover) activeNode() int {
+	c.mu.Lock()
+	checkDue := c.active != 0 && c.now().Sub(c.lastCheck) >= FailoverHealthInterval
+	c.mu.Unlock()
+	if checkDue {
+		_ = c.HealthCheck()
+	}
+	return c.Active()
+}
+
+// Call fn on the active node and on the following nodes in turn
+// for as long as the call fails with a transient rpc error
+func (c *ChainFailover) call(fn func(ChainBackend) error) error {
+	active := c.activeNode()
+	var callErr error
+	for i := range c.nodes {
+		index := (active + i) % len(c.nodes)
+		callErr = fn(c.nodes[index])
+		if callErr == nil || ClassifyError(callErr) != ErrorClassRpcTransient {
+			c.setActive(index)
+			return callErr
+		}
+		log.Warnf("%s %d: %v\n", WarningFailoverNodeFailed, index, callErr)
+	}
+	return callErr
+}
+
+// Get block count
+func (c *ChainFailover) GetBlockCount() (int64, error) {
+	var count int64
+	callErr := c.call(func(node ChainBackend) (err error) {
+		count, err = node.GetBlockCount()
+		return err
+	})
+	return count, callErr
+}
+
+// List unspent outputs
+func (c *ChainFailover) ListUnspent() ([]btcjson.ListUnspentResult, error) {
+	var unspent []btcjson.ListUnspentResult
+	callErr := c.call(func(node ChainBackend) (err error) {
+		unspent, err = node.ListUnspent()
+		return err
+	})
+	return unspent, callErr
+}
+
+// Get raw mempool
+func (c *ChainFailover) GetRawMempool() ([]*chainhash.Hash, error) {
+	var mempool []*chainhash.Hash
+	callErr := c.call(func(node ChainBackend) (err error) {
+		mempool, err = node.GetRawMempool()
+		return err
+	})
+	return mempool, callErr
+}
+
+// Get raw transaction
+func (c *ChainFailover) GetRawTransaction(txid *chainhash.Hash) (*btcutil.Tx, error) {
+	var tx *btcutil.Tx
+	callErr := c.call(func(node ChainBackend) (err error) {
+		tx, err = node.GetRawTransaction(txid)
+		return err
+	})
+	return tx, callErr
+}
+
+// Get raw transaction verbose
+func (c *ChainFailover) GetRawTransactionVerbose(txid *chainhash.Hash) (*btcjson.TxRawResult, error) {
+	var tx *btcjson.TxRawResult
+	callErr := c.call(func(node ChainBackend) (err error) {
+		tx, err = node.GetRawTransactionVerbose(txid)
+		return err
+	})
+	return tx, callErr
+}
+
+// Get wallet transaction, used for confirmation checks
+func (c *ChainFailover) GetTransaction(txid *chainhash.Hash) (*btcjson.GetTransactionResult, error) {
+	var tx *btcjson.GetTransactionResult
+	callErr := c.call(func(node ChainBackend) (err error) {
+		tx, err = node.GetTransaction(txid)
+		return err
+	})
+	return tx, callErr
+}
+
+// Get mempool entry
+func (c *ChainFailover) GetMempoolEntry(txid string) (*btcjson.GetMempoolEntryResult, error) {
+	var entry *btcjson.GetMempoolEntryResult
+	callErr := c.call(func(node ChainBackend) (err error) {
+		entry, err = node.GetMempoolEntry(txid)
+		return err
+	})
+	return entry, callErr
+}
+
+// Create raw transaction
+func (c *ChainFailover) CreateRawTransaction(inputs []btcjson.TransactionInput,
+	amounts map[btcutil.Address]btcutil.Amount, lockTime *int64) (*wire.MsgTx, error) {
+	var tx *wire.MsgTx
+	callErr := c.call(func(node ChainBackend) (err error) {
+		tx, err = node.CreateRawTransaction(inputs, amounts, lockTime)
+		return err
+	})
+	return tx, callErr
+}
+
+// Call fn on all nodes, starting from the active node
+// Succeeds if the call succeeds on any node, otherwise the
+// error of the active node is returned
+func (c *ChainFailover) broadcast(fn func(ChainBackend) error) error {
+	active := c.activeNode()
+	var activeErr error
+	succeeded := false
+	for i := range c.nodes {
+		index := (active + i) % len(c.nodes)
+		if callErr := fn(c.nodes[index]); callErr != nil {
+			log.Warnf("%s %d: %v\n", WarningFailoverBroadcast, index, callErr)
+			if i == 0 {
+				activeErr = callErr
+			}
+			continue
+		}
+		succeeded = true
+	}
+	if succeeded {
+		return nil
+	}
+	return activeErr
+}
+
+// Send raw transaction to all nodes for propagation robustness
+func (c *ChainFailover) SendRawTransaction(tx *wire.MsgTx, allowHighFees bool) (*chainhash.Hash, error) {
+	var txid *chainhash.Hash
+	sendErr := c.broadcast(func(node ChainBackend) error {
+		hash, err := node.SendRawTransaction(tx, allowHighFees)
+		if err == nil && txid == nil {
+			txid = hash
+		}
+		return err
+	})
+	if sendErr != nil {
+		return nil, sendErr
+	}
+	return txid, nil
+}
+
+// Import address with rescan to all nodes so that
+// the staychain is watched by any active node
+func (c *ChainFailover) ImportAddressRescan(address string, account string, rescan bool) error {
+	return c.broadcast(func(node ChainBackend) error {
+		return node.ImportAddressRescan(address, account, rescan)
+	})
+}
+
+// Send raw json-rpc request, e.g. for the node sync status
+func (c *ChainFailover) RawRequest(method string, params []json.RawMessage) (json.RawMessage, error) {
+	var result json.RawMessage
+	callErr := c.call(func(node ChainBackend) error {
+		client, ok := node.(rawRequester)
+		if !ok {
+			return errors.New(ErrorFailoverRawRequest)
+		}
+		var err error
+		result, err = client.RawRequest(method, params)
+		return err
+	})
+	return result, callErr
+}
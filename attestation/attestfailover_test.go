@@ -0,0 +1,172 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+// main chain node failing all calls with err if set
+type failoverNodeFake struct {
+	ChainBackend
+	height   int64
+	err      error
+	calls    int
+	sent     []chainhash.Hash
+	imported []string
+}
+
+func (n *failoverNodeFake) GetBlockCount() (int64, error) {
+	n.calls++
+	return n.height, n.err
+}
+
+func (n *failoverNodeFake) SendRawTransaction(tx *wire.MsgTx, allowHighFees bool) (*chainhash.Hash, error) {
+	n.calls++
+	if n.err != nil {
+		return nil, n.err
+	}
+	txid := tx.TxHash()
+	n.sent = append(n.sent, txid)
+	return &txid, nil
+}
+
+func (n *failoverNodeFake) ImportAddressRescan(address string, account string, rescan bool) error {
+	n.calls++
+	if n.err != nil {
+		return n.err
+	}
+	n.imported = append(n.imported, address)
+	return nil
+}
+
+func (n *failoverNodeFake) RawRequest(method string, params []json.RawMessage) (json.RawMessage, error) {
+	n.calls++
+	if n.err != nil {
+		return nil, n.err
+	}
+	return json.Marshal(n.height)
+}
+
+// Return failover chain for the nodes with a fake clock
+func newTestChainFailover(nodes ...*failoverNodeFake) (*ChainFailover, *time.Time) {
+	now := time.Unix(1500000000, 0)
+	backends := make([]ChainBackend, len(nodes))
+	for i := range nodes {
+		backends[i] = nodes[i]
+	}
+	failover := NewChainFailover(backends)
+	failover.now = func() time.Time { return now }
+	return failover, &now
+}
+
+// Test calls switch to the next node on transient rpc errors only
+func TestChainFailoverCall(t *testing.T) {
+	node0 := &failoverNodeFake{height: 100}
+	node1 := &failoverNodeFake{height: 101}
+	failover, _ := newTestChainFailover(node0, node1)
+
+	count, countErr := failover.GetBlockCount()
+	assert.Equal(t, nil, countErr)
+	assert.Equal(t, int64(100), count)
+	assert.Equal(t, 0, failover.Active())
+
+	// transient failure of the active node
+	node0.err = io.EOF
+	count, countErr = failover.GetBlockCount()
+	assert.Equal(t, nil, countErr)
+	assert.Equal(t, int64(101), count)
+	assert.Equal(t, 1, failover.Active())
+
+	// raw requests are also failed over
+	result, rawErr := failover.RawRequest("getblockcount", nil)
+	assert.Equal(t, nil, rawErr)
+	assert.Equal(t, json.RawMessage("101"), result)
+
+	// non transient errors are returned without switching
+	rpcErr := &btcjson.RPCError{Code: btcjson.ErrRPCInvalidParameter, Message: "invalid"}
+	node1.err = rpcErr
+	_, countErr = failover.GetBlockCount()
+	assert.Equal(t, rpcErr, countErr)
+	assert.Equal(t, 1, failover.Active())
+
+	// all nodes failing
+	node1.err = io.EOF
+	_, countErr = failover.GetBlockCount()
+	assert.Equal(t, io.EOF, countErr)
+	assert.Equal(t, 1, failover.Active())
+}
+
+// Test health checks return to the preferred node once available
+func TestChainFailoverHealthCheck(t *testing.T) {
+	node0 := &failoverNodeFake{height: 100, err: io.EOF}
+	node1 := &failoverNodeFake{height: 101}
+	failover, now := newTestChainFailover(node0, node1)
+
+	assert.Equal(t, nil, failover.HealthCheck())
+	assert.Equal(t, 1, failover.Active())
+
+	// preferred node not checked again until the interval has passed
+	node0.err = nil
+	node0.calls = 0
+	count, _ := failover.GetBlockCount()
+	assert.Equal(t, int64(101), count)
+	assert.Equal(t, 0, node0.calls)
+
+	*now = now.Add(FailoverHealthInterval)
+	count, _ = failover.GetBlockCount()
+	assert.Equal(t, int64(100), count)
+	assert.Equal(t, 0, failover.Active())
+
+	// no healthy nodes
+	node0.err = io.EOF
+	node1.err = io.EOF
+	healthErr := failover.HealthCheck()
+	assert.Equal(t, errors.New(ErrorFailoverNoneHealthy+": "+io.EOF.Error()), healthErr)
+	assert.Equal(t, 0, failover.Active())
+}
+
+// Test transactions and address imports are broadcast to all nodes
+func TestChainFailoverBroadcast(t *testing.T) {
+	node0 := &failoverNodeFake{}
+	node1 := &failoverNodeFake{}
+	node2 := &failoverNodeFake{}
+	failover, _ := newTestChainFailover(node0, node1, node2)
+
+	tx := newInitTestTx(chainhash.Hash{1}, nil)
+	txid, sendErr := failover.SendRawTransaction(tx, false)
+	assert.Equal(t, nil, sendErr)
+	assert.Equal(t, tx.TxHash(), *txid)
+	for _, node := range []*failoverNodeFake{node0, node1, node2} {
+		assert.Equal(t, []chainhash.Hash{tx.TxHash()}, node.sent)
+	}
+
+	// succeeds if any node accepts the transaction
+	node0.err = io.EOF
+	node2.err = io.EOF
+	txid, sendErr = failover.SendRawTransaction(tx, false)
+	assert.Equal(t, nil, sendErr)
+	assert.Equal(t, tx.TxHash(), *txid)
+	assert.Equal(t, 2, len(node1.sent))
+	assert.Equal(t, nil, failover.ImportAddressRescan("addr", "", false))
+	assert.Equal(t, []string{"addr"}, node1.imported)
+
+	// error of the active node returned if all nodes fail
+	rpcErr := &btcjson.RPCError{Code: btcjson.ErrRPCVerify, Message: "rejected"}
+	node0.err = rpcErr
+	node1.err = io.EOF
+	txid, sendErr = failover.SendRawTransaction(tx, false)
+	assert.Equal(t, rpcErr, sendErr)
+	assert.Equal(t, true, txid == nil)
+}
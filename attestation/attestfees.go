@@ -18,9 +18,16 @@ import (
 
 // default fee per byte values in satoshis
 const (
-	DefaultMinFee       = 10
-	DefaultMaxFee       = 100
-	DefaultFeeIncrement = 5
+	DefaultMinFee              = 10
+	DefaultMaxFee              = 100
+	DefaultFeeIncrement        = 5
+	DefaultFeeIncrementPercent = 20
+)
+
+// fee bump strategies
+const (
+	BumpStrategyAbsolute   = "absolute"   // increment fee by constant fee increment
+	BumpStrategyPercentage = "percentage" // increment fee by percentage of current fee
 )
 
 // warnings for arguments
@@ -28,6 +35,9 @@ const (
 	WarningInvalidMinFeeArg       = "Invalid min fee config value"
 	WarningInvalidMaxFeeArg       = "Invalid max fee config value"
 	WarningInvalidFeeIncrementArg = "Invalid fee increment config value"
+
+	WarningInvalidBumpStrategyArg        = "Invalid bump strategy config value"
+	WarningInvalidFeeIncrementPercentArg = "Invalid fee increment percent config value"
 )
 
 // fee api config
@@ -52,6 +62,10 @@ type AttestFees struct {
 	// constant fee increment on fee bumping case
 	feeIncrement int
 
+	// strategy used when bumping fee and percentage increment for percentage strategy
+	bumpStrategy        string
+	feeIncrementPercent int
+
 	// current fee used for attestation transactions
 	currentFee int
 
@@ -61,8 +75,9 @@ type AttestFees struct {
 
 // New AttestFees instance
 // Limit values taken from configuration
+// Bump strategy taken from optional rbf configuration
 // Current fee value reset from api
-func NewAttestFees(feesConfig config.FeesConfig) AttestFees {
+func NewAttestFees(feesConfig config.FeesConfig, rbfConfig ...config.RbfConfig) AttestFees {
 
 	// min fee with upper limit max_fee default
 	minFee := DefaultMinFee
@@ -91,11 +106,35 @@ func NewAttestFees(feesConfig config.FeesConfig) AttestFees {
 	}
 	log.Infof("*Fees* Fee increment set to: %d\n", feeIncrement)
 
+	// bump strategy and percentage increment from rbf config
+	bumpStrategy := BumpStrategyAbsolute
+	feeIncrementPercent := DefaultFeeIncrementPercent
+	if len(rbfConfig) > 0 {
+		switch rbfConfig[0].BumpStrategy {
+		case BumpStrategyAbsolute, BumpStrategyPercentage:
+			bumpStrategy = rbfConfig[0].BumpStrategy
+		case "":
+		default:
+			log.Warnf("%s (%s)\n", WarningInvalidBumpStrategyArg, rbfConfig[0].BumpStrategy)
+		}
+		if rbfConfig[0].FeeIncrementPercent > 0 {
+			feeIncrementPercent = rbfConfig[0].FeeIncrementPercent
+		} else if bumpStrategy == BumpStrategyPercentage {
+			log.Warnf("%s (%d)\n", WarningInvalidFeeIncrementPercentArg, rbfConfig[0].FeeIncrementPercent)
+		}
+	}
+	log.Infof("*Fees* Bump strategy set to: %s\n", bumpStrategy)
+	if bumpStrategy == BumpStrategyPercentage {
+		log.Infof("*Fees* Fee increment percent set to: %d\n", feeIncrementPercent)
+	}
+
 	attestFees := AttestFees{
-		minFee:       minFee,
-		maxFee:       maxFee,
-		feeIncrement: feeIncrement,
-		prevFee:      0}
+		minFee:              minFee,
+		maxFee:              maxFee,
+		feeIncrement:        feeIncrement,
+		bumpStrategy:        bumpStrategy,
+		feeIncrementPercent: feeIncrementPercent,
+		prevFee:             0}
 
 	attestFees.ResetFee()
 	return attestFees
@@ -132,10 +171,24 @@ func (a *AttestFees) ResetFee(useMinimum ...bool) {
 	log.Infof("*Fees* Current fee set to value: %d\n", a.currentFee)
 }
 
+// Get bump strategy
+func (a AttestFees) GetBumpStrategy() string {
+	return a.bumpStrategy
+}
+
 // Bump fee upon request using increment value and not allowing values higher than max configured fee
+// The increment is either constant or a percentage of the current fee depending on bump strategy
 func (a *AttestFees) BumpFee() {
 	a.prevFee = a.currentFee
-	a.currentFee += a.feeIncrement
+	if a.bumpStrategy == BumpStrategyPercentage {
+		increment := a.currentFee * a.feeIncrementPercent / 100
+		if increment < 1 {
+			increment = 1
+		}
+		a.currentFee += increment
+	} else {
+		a.currentFee += a.feeIncrement
+	}
 	log.Infof("*Fees* Bumping fee value to: %d\n", a.currentFee)
 	if a.currentFee > a.maxFee {
 		log.Infof("*Fees* Max allowed fee value reached: %d\n", a.currentFee)
@@ -100,3 +100,36 @@ func TestAttestFeesWithConfig(t *testing.T) {
 	attestFees.ResetFee(true)
 	assert.Equal(t, DefaultMinFee, attestFees.GetFee())
 }
+
+// Attest Fees test with rbf bump strategy config
+func TestAttestFeesBumpStrategy(t *testing.T) {
+
+	// test default absolute strategy without rbf config and with invalid strategy
+	attestFees := NewAttestFees(config.FeesConfig{10, 50, 5})
+	assert.Equal(t, BumpStrategyAbsolute, attestFees.GetBumpStrategy())
+	attestFees = NewAttestFees(config.FeesConfig{10, 50, 5}, config.RbfConfig{"invalid", -1, -1, nil})
+	assert.Equal(t, BumpStrategyAbsolute, attestFees.GetBumpStrategy())
+	assert.Equal(t, DefaultFeeIncrementPercent, attestFees.feeIncrementPercent)
+
+	// test percentage strategy increments by percentage of current fee
+	attestFees = NewAttestFees(config.FeesConfig{10, 50, 5}, config.RbfConfig{BumpStrategyPercentage, 50, -1, nil})
+	assert.Equal(t, BumpStrategyPercentage, attestFees.GetBumpStrategy())
+	attestFees.ResetFee(true)
+	attestFees.BumpFee()
+	assert.Equal(t, 10, attestFees.GetPrevFee())
+	assert.Equal(t, 15, attestFees.GetFee())
+	attestFees.BumpFee()
+	assert.Equal(t, 22, attestFees.GetFee())
+	attestFees.BumpFee()
+	assert.Equal(t, 33, attestFees.GetFee())
+	attestFees.BumpFee()
+	assert.Equal(t, 49, attestFees.GetFee())
+	attestFees.BumpFee()
+	assert.Equal(t, 50, attestFees.GetFee())
+
+	// test percentage increment is at least one
+	attestFees = NewAttestFees(config.FeesConfig{1, 50, 5}, config.RbfConfig{BumpStrategyPercentage, 1, -1, nil})
+	attestFees.ResetFee(true)
+	attestFees.BumpFee()
+	assert.Equal(t, 2, attestFees.GetFee())
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"mainstay/models"
+)
+
+// Liveness and readiness of the attestation service for orchestrator probes
+// The service is live while the attestation loop keeps running states on
+// schedule and ready while bitcoind, the database and signers are reachable,
+// bitcoind has synced and the last attestation state completed without failure
+
+// health check names and results
+const (
+	HealthCheckAttestation = "attestation"
+	HealthCheckBitcoind    = "bitcoind"
+	HealthCheckDb          = "mongodb"
+	HealthCheckSigner      = "signer"
+
+	HealthStatusOk = "ok"
+
+	ErrorAttestationOverdue = "Attestation state overdue since"
+	ErrorAttestationFailing = "Attestation state failing since"
+	ErrorChainSyncing       = "Main chain node syncing"
+)
+
+// grace period after the scheduled time of the next attestation state
+// before the attestation loop is reported as stalled
+const DefaultHealthGrace = 5 * time.Minute
+
+// Record next attestation state and its scheduled time and either the time
+// of the last successful state transition or that the state just run failed
+func (s *AttestService) recordTransition(success bool) {
+	now := clock.Now()
+	atomic.StoreInt64(&s.nextState, now.Add(s.attestDelay).Unix())
+	atomic.StoreInt32(&s.scheduled, int32(s.state))
+	if success {
+		atomic.StoreInt64(&s.lastTransition, now.Unix())
+		atomic.StoreInt32(&s.failing, 0)
+	} else {
+		atomic.StoreInt32(&s.failing, 1)
+	}
+}
+
+// Return error if the attestation loop is overdue, unless paused
+func (s *AttestService) checkAttestationLive() error {
+	if s.IsPaused() {
+		return nil
+	}
+	nextState := time.Unix(atomic.LoadInt64(&s.nextState), 0)
+	if since(nextState) > DefaultHealthGrace {
+		return errors.New(fmt.Sprintf("%s %s", ErrorAttestationOverdue, nextState.UTC().Format(time.RFC3339)))
+	}
+	return nil
+}
+
+// Return error if the attestation loop is overdue or the last state failed
+func (s *AttestService) checkAttestationReady() error {
+	if err := s.checkAttestationLive(); err != nil {
+		return err
+	}
+	if atomic.LoadInt32(&s.failing) == 1 {
+		lastTransition := time.Unix(atomic.LoadInt64(&s.lastTransition), 0)
+		return errors.New(fmt.Sprintf("%s %s", ErrorAttestationFailing, lastTransition.UTC().Format(time.RFC3339)))
+	}
+	return nil
+}
+
+// Return error if bitcoind is not reachable or still syncing
+func (s *AttestService) checkBitcoind() error {
+	syncing, progress, syncErr := s.chainSyncing()
+	if syncErr != nil {
+		return syncErr
+	}
+	if syncing {
+		return errors.New(fmt.Sprintf("%s (%s)", ErrorChainSyncing, progress))
+	}
+	_, countErr := s.attester.Chain.GetBlockCount()
+	return countErr
+}
+
+// Return error if signers are not reachable, for signers reporting status
+func (s *AttestService) checkSigner() error {
+	if status, ok := s.signer.(AttestSignerStatus); ok {
+		return status.Status()
+	}
+	return nil
+}
+
+// Return health report from named checks
+func (s *AttestService) healthReport(checks map[string]error) models.HealthReport {
+	report := models.HealthReport{
+		Healthy:        true,
+		Checks:         make(map[string]string),
+		LastTransition: atomic.LoadInt64(&s.lastTransition),
+		Paused:         s.IsPaused(),
+		Syncing:        s.IsSyncing(),
+		Time:           clock.Now().Unix(),
+	}
+	for name, err := range checks {
+		if err != nil {
+			report.Healthy = false
+			report.Checks[name] = err.Error()
+		} else {
+			report.Checks[name] = HealthStatusOk
+		}
+	}
+	return report
+}
+
+// Return liveness of the attestation service
+// Only fails if the attestation loop has stalled and requires a restart
+func (s *AttestService) Liveness() models.HealthReport {
+	return s.healthReport(map[string]error{
+		HealthCheckAttestation: s.checkAttestationLive(),
+	})
+}
+
+// Return readiness of the attestation service
+// Fails while any dependency is unreachable or attestation states are failing
+func (s *AttestService) Readiness() models.HealthReport {
+	return s.healthReport(map[string]error{
+		HealthCheckAttestation: s.checkAttestationReady(),
+		HealthCheckBitcoind:    s.checkBitcoind(),
+		HealthCheckDb:          s.server.Ping(),
+		HealthCheckSigner:      s.checkSigner(),
+	})
+}
+
+// Return status of the attestation state machine
+// The state is the next state to run at the scheduled time
+func (s *AttestService) Status() models.AttestationStatus {
+	return models.AttestationStatus{
+		State:          AttestationState(atomic.LoadInt32(&s.scheduled)).String(),
+		NextState:      atomic.LoadInt64(&s.nextState),
+		LastTransition: atomic.LoadInt64(&s.lastTransition),
+		Failing:        atomic.LoadInt32(&s.failing) == 1,
+		Paused:         s.IsPaused(),
+		Syncing:        s.IsSyncing(),
+	}
+}
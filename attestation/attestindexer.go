@@ -0,0 +1,254 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/db"
+	"mainstay/log"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Worker following the staychain on the main chain from the base transaction,
+// storing each staychain transaction with the block confirming it and the
+// staychain transactions it spends and is spent by. The index is built from
+// the main chain alone, so that the staychain history is backfilled after
+// loss of the database, and serves the staychain routes of the request api
+//
+// Transactions are only indexed once they have the configured number of
+// confirmations, so that the index is not changed by reorgs of recent blocks.
+// The staychain is followed through the outspends of Esplora or by scanning
+// the blocks after the latest transaction indexed with the main client rpc
+
+// indexer consts
+const (
+	DefaultIndexerInterval      = 60 * time.Second
+	DefaultIndexerConfirmations = 6
+
+	ErrorIndexerUnsupported = "Chain backend does not support staychain indexing"
+
+	WarningInvalidIndexerIntervalArg      = "Invalid indexer interval config value"
+	WarningInvalidIndexerConfirmationsArg = "Invalid indexer confirmations config value"
+	WarningIndexerFailed                  = "Staychain indexing failed"
+)
+
+// chain backend returning the confirmed spend of staychain transactions
+type staychainSpendFinder interface {
+	GetStaychainSpend(string) (models.StaychainTx, bool, error)
+}
+
+// block with the inputs of its transactions from the getblock rpc
+type indexerBlock struct {
+	Hash   string      `json:"hash"`
+	Height int64       `json:"height"`
+	Time   int64       `json:"time"`
+	Tx     []indexerTx `json:"tx"`
+}
+
+// transaction of getblock rpc block
+type indexerTx struct {
+	Txid string       `json:"txid"`
+	Vin  []indexerVin `json:"vin"`
+}
+
+// transaction input of getblock rpc block
+type indexerVin struct {
+	Txid string `json:"txid"`
+	Vout uint32 `json:"vout"`
+}
+
+// AttestIndexer struct
+// Periodically indexes the staychain transactions confirmed
+// since the latest transaction indexed
+type AttestIndexer struct {
+	ctx           context.Context
+	wg            *sync.WaitGroup
+	dbInterface   db.Db
+	chain         ChainBackend
+	txid0         string
+	interval      time.Duration
+	confirmations int64
+
+	// height up to which blocks have been scanned for the
+	// transaction spending the latest transaction indexed
+	scanHeight int64
+}
+
+// Return new AttestIndexer of the staychain from the base transaction
+func NewAttestIndexer(ctx context.Context, wg *sync.WaitGroup, dbInterface db.Db, chain ChainBackend,
+	txid0 string, config confpkg.IndexerConfig) *AttestIndexer {
+
+	interval := DefaultIndexerInterval
+	if config.IntervalSeconds > 0 {
+		interval = time.Duration(config.IntervalSeconds) * time.Second
+	} else if config.IntervalSeconds != -1 {
+		log.Warnf("%s (%d)\n", WarningInvalidIndexerIntervalArg, config.IntervalSeconds)
+	}
+	confirmations := int64(DefaultIndexerConfirmations)
+	if config.Confirmations > 0 {
+		confirmations = int64(config.Confirmations)
+	} else if config.Confirmations != -1 {
+		log.Warnf("%s (%d)\n", WarningInvalidIndexerConfirmationsArg, config.Confirmations)
+	}
+	return &AttestIndexer{ctx: ctx, wg: wg, dbInterface: dbInterface, chain: chain, txid0: txid0,
+		interval: interval, confirmations: confirmations}
+}
+
+// Run indexing until the context is cancelled
+func (i *AttestIndexer) Run() {
+	defer i.wg.Done()
+	log.Infof("*Indexer* Indexing staychain every %s\n", i.interval.String())
+
+	ticker := time.NewTicker(i.interval)
+	defer ticker.Stop()
+	for {
+		if indexed, err := i.index(); err != nil {
+			log.WithFields(log.Fields{log.FieldError: err}).Warnln(WarningIndexerFailed)
+		} else if indexed > 0 {
+			log.Infof("*Indexer* Indexed %d staychain transactions\n", indexed)
+		}
+		select {
+		case <-i.ctx.Done():
+			log.Infoln("Shutting down staychain indexer...")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Index the staychain transactions with the required confirmations
+// from the latest transaction indexed, returning the number indexed
+func (i *AttestIndexer) index() (int, error) {
+	tipHeight, tipErr := i.chain.GetBlockCount()
+	if tipErr != nil {
+		return 0, tipErr
+	}
+	maxHeight := tipHeight - i.confirmations + 1
+
+	indexed := 0
+	latest, latestErr := i.dbInterface.GetLatestStaychainTx()
+	if latestErr != nil {
+		return 0, latestErr
+	} else if latest.Txid == "" {
+		base, found, baseErr := i.baseTx(tipHeight, maxHeight)
+		if baseErr != nil || !found {
+			return 0, baseErr
+		}
+		if saveErr := i.dbInterface.SaveStaychainTx(base); saveErr != nil {
+			return 0, saveErr
+		}
+		latest = base
+		indexed++
+	}
+
+	for {
+		spend, found, spendErr := i.spendingTx(latest, maxHeight)
+		if spendErr != nil || !found {
+			return indexed, spendErr
+		}
+		spend.Index = latest.Index + 1
+		spend.Spends = latest.Txid
+
+		// the spending tx is found again from the latest tx if not stored
+		latest.SpentBy = spend.Txid
+		if saveErr := i.dbInterface.SaveStaychainTx(latest); saveErr != nil {
+			return indexed, saveErr
+		}
+		if saveErr := i.dbInterface.SaveStaychainTx(spend); saveErr != nil {
+			return indexed, saveErr
+		}
+		latest = spend
+		indexed++
+	}
+}
+
+// Return the base transaction of the staychain if confirmed up to the height
+func (i *AttestIndexer) baseTx(tipHeight int64, maxHeight int64) (models.StaychainTx, bool, error) {
+	txid0, hashErr := chainhash.NewHashFromStr(i.txid0)
+	if hashErr != nil {
+		return models.StaychainTx{}, false, hashErr
+	}
+	tx, txErr := i.chain.GetTransaction(txid0)
+	if txErr != nil {
+		return models.StaychainTx{}, false, txErr
+	}
+	height := tipHeight - tx.Confirmations + 1
+	if tx.Confirmations <= 0 || height > maxHeight {
+		return models.StaychainTx{}, false, nil
+	}
+	return models.StaychainTx{Txid: i.txid0, Height: height, Blockhash: tx.BlockHash, Time: tx.BlockTime}, true, nil
+}
+
+// Return the transaction spending the staychain output of the transaction
+// if confirmed up to the height
+func (i *AttestIndexer) spendingTx(tx models.StaychainTx, maxHeight int64) (models.StaychainTx, bool, error) {
+	if finder, ok := i.chain.(staychainSpendFinder); ok {
+		spend, found, spendErr := finder.GetStaychainSpend(tx.Txid)
+		if spendErr != nil || !found || spend.Height > maxHeight {
+			return models.StaychainTx{}, false, spendErr
+		}
+		return spend, true, nil
+	}
+
+	client, ok := i.chain.(rawRequester)
+	if !ok {
+		return models.StaychainTx{}, false, errors.New(ErrorIndexerUnsupported)
+	}
+	// the spending tx may be in the same block as the tx
+	height := tx.Height
+	if i.scanHeight >= height {
+		height = i.scanHeight + 1
+	}
+	for ; height <= maxHeight; height++ {
+		block, blockErr := rpcIndexerBlock(client, height)
+		if blockErr != nil {
+			return models.StaychainTx{}, false, blockErr
+		}
+		for _, blockTx := range block.Tx {
+			for _, vin := range blockTx.Vin {
+				if vin.Txid == tx.Txid && vin.Vout == 0 {
+					i.scanHeight = height - 1
+					return models.StaychainTx{Txid: blockTx.Txid, Height: height,
+						Blockhash: block.Hash, Time: block.Time}, true, nil
+				}
+			}
+		}
+		i.scanHeight = height
+	}
+	return models.StaychainTx{}, false, nil
+}
+
+// Return block at the height with the inputs of its transactions
+// through the getblockhash and getblock rpcs of the main client node
+func rpcIndexerBlock(client rawRequester, height int64) (indexerBlock, error) {
+	heightJson, _ := json.Marshal(height)
+	hashJson, hashErr := client.RawRequest("getblockhash", []json.RawMessage{heightJson})
+	if hashErr != nil {
+		return indexerBlock{}, hashErr
+	}
+	verbosityJson, _ := json.Marshal(2)
+	blockJson, blockErr := client.RawRequest("getblock", []json.RawMessage{hashJson, verbosityJson})
+	if blockErr != nil {
+		return indexerBlock{}, blockErr
+	}
+	var block indexerBlock
+	if unmarshalErr := json.Unmarshal(blockJson, &block); unmarshalErr != nil {
+		return indexerBlock{}, unmarshalErr
+	}
+	return block, nil
+}
+
+// Return staychain indexer following the staychain of the attestation service
+func (s *AttestService) NewIndexer(config confpkg.IndexerConfig) *AttestIndexer {
+	return NewAttestIndexer(s.ctx, s.wg, s.server.dbInterface, s.attester.Chain, s.attester.txid0, config)
+}
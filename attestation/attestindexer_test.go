@@ -0,0 +1,170 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	confpkg "mainstay/config"
+	"mainstay/db"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// chain backend serving blocks of staychain transactions
+// through the getblockhash and getblock rpcs
+type indexerTestChain struct {
+	ChainBackend
+	blocks   []indexerBlock
+	base     btcjson.GetTransactionResult
+	requests int
+}
+
+func (c *indexerTestChain) GetBlockCount() (int64, error) {
+	return int64(len(c.blocks) - 1), nil
+}
+
+func (c *indexerTestChain) GetTransaction(txid *chainhash.Hash) (*btcjson.GetTransactionResult, error) {
+	return &c.base, nil
+}
+
+func (c *indexerTestChain) RawRequest(method string, params []json.RawMessage) (json.RawMessage, error) {
+	c.requests++
+	var height int64
+	switch method {
+	case "getblockhash":
+		json.Unmarshal(params[0], &height)
+		return json.Marshal(c.blocks[height].Hash)
+	case "getblock":
+		var hash string
+		json.Unmarshal(params[0], &hash)
+		for _, block := range c.blocks {
+			if block.Hash == hash {
+				return json.Marshal(block)
+			}
+		}
+	}
+	return nil, errors.New("unexpected request")
+}
+
+// Return chain of empty blocks up to the height
+func newIndexerTestChain(height int) *indexerTestChain {
+	chain := &indexerTestChain{}
+	for i := 0; i <= height; i++ {
+		chain.addBlock()
+	}
+	return chain
+}
+
+// Mine block with transactions spending the outputs
+func (c *indexerTestChain) addBlock(spends ...[2]string) {
+	block := indexerBlock{Hash: strings.Repeat("0", 63) + string(rune('a'+len(c.blocks))),
+		Height: int64(len(c.blocks)), Time: 1000 + int64(len(c.blocks))}
+	for _, spend := range spends {
+		block.Tx = append(block.Tx, indexerTx{Txid: spend[1], Vin: []indexerVin{{Txid: spend[0]}}})
+	}
+	c.blocks = append(c.blocks, block)
+}
+
+// Test staychain is indexed from the blocks of the main client node
+// once confirmed and followed from the latest transaction indexed
+func TestAttestIndexerRpc(t *testing.T) {
+	txid0 := strings.Repeat("00", 31) + "01"
+	txid1 := strings.Repeat("00", 31) + "02"
+	txid2 := strings.Repeat("00", 31) + "03"
+
+	chain := newIndexerTestChain(2)
+	chain.base = btcjson.GetTransactionResult{TxID: txid0, Confirmations: 2,
+		BlockHash: chain.blocks[1].Hash, BlockTime: chain.blocks[1].Time}
+	chain.addBlock([2]string{txid0, txid1})
+	chain.base.Confirmations++
+
+	dbFake := db.NewDbFake()
+	indexer := NewAttestIndexer(context.Background(), &sync.WaitGroup{}, dbFake, chain, txid0,
+		confpkg.IndexerConfig{IntervalSeconds: -1, Confirmations: 2})
+
+	// base transaction indexed and spend awaiting confirmations
+	indexed, err := indexer.index()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, indexed)
+	assert.Equal(t, []models.StaychainTx{{Txid: txid0, Height: 1,
+		Blockhash: chain.blocks[1].Hash, Time: chain.blocks[1].Time}}, dbFake.StaychainTxs)
+	assert.Equal(t, int64(2), indexer.scanHeight)
+
+	// spend and next spend in the same block indexed
+	chain.addBlock()
+	chain.addBlock([2]string{txid1, txid2})
+	chain.addBlock()
+	indexed, err = indexer.index()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, indexed)
+	latest, _ := dbFake.GetLatestStaychainTx()
+	assert.Equal(t, models.StaychainTx{Txid: txid2, Index: 2, Height: 5, Blockhash: chain.blocks[5].Hash,
+		Time: chain.blocks[5].Time, Spends: txid1}, latest)
+	first, _ := dbFake.GetStaychainTx(txid1)
+	assert.Equal(t, models.StaychainTx{Txid: txid1, Index: 1, Height: 3, Blockhash: chain.blocks[3].Hash,
+		Time: chain.blocks[3].Time, Spends: txid0, SpentBy: txid2}, first)
+	base, _ := dbFake.GetStaychainTx(txid0)
+	assert.Equal(t, txid1, base.SpentBy)
+
+	// blocks scanned once while awaiting next spend
+	requests := chain.requests
+	indexed, err = indexer.index()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, indexed)
+	assert.Equal(t, requests, chain.requests)
+}
+
+// Test staychain is indexed from the outspends of the Esplora api
+func TestAttestIndexerEsplora(t *testing.T) {
+	txid0 := strings.Repeat("00", 31) + "01"
+	txid1 := strings.Repeat("00", 31) + "02"
+	txid2 := strings.Repeat("00", 31) + "03"
+	confirmed := func(height int64) esploraStatus {
+		return esploraStatus{Confirmed: true, BlockHeight: height, BlockHash: "blockhash", BlockTime: 1000 + height}
+	}
+	fake := &esploraFake{
+		height: 110,
+		txs:    map[string]esploraTx{txid0: esploraTx{Txid: txid0, Status: confirmed(100)}},
+		outspends: map[string]esploraOutspend{
+			txid0: esploraOutspend{Spent: true, Txid: txid1, Status: confirmed(101)},
+			txid1: esploraOutspend{Spent: true, Txid: txid2, Status: confirmed(108)},
+		},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := NewEsploraClient(confpkg.EsploraConfig{Url: server.URL}, txid0, "")
+
+	dbFake := db.NewDbFake()
+	indexer := NewAttestIndexer(context.Background(), &sync.WaitGroup{}, dbFake, client, txid0,
+		confpkg.IndexerConfig{IntervalSeconds: -1, Confirmations: -1})
+	indexed, err := indexer.index()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, indexed)
+
+	txs, _ := dbFake.GetStaychainTxs(0, 10)
+	assert.Equal(t, int64(2), txs.Total)
+	assert.Equal(t, models.StaychainTx{Txid: txid1, Index: 1, Height: 101, Blockhash: "blockhash",
+		Time: 1101, Spends: txid0}, txs.Txs[0])
+	assert.Equal(t, models.StaychainTx{Txid: txid0, Height: 100, Blockhash: "blockhash",
+		Time: 1100, SpentBy: txid1}, txs.Txs[1])
+
+	// latest spend indexed after the default confirmations
+	fake.height = 113
+	indexed, err = indexer.index()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, indexed)
+	latest, _ := dbFake.GetLatestStaychainTx()
+	assert.Equal(t, txid2, latest.Txid)
+}
@@ -0,0 +1,239 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"mainstay/crypto"
+	"mainstay/log"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// In flight attestations are new attestations that have been created but
+// not sent when the service stops. These are stored on shutdown, along with
+// the signer state, and resumed on restart if still spending the last unspent
+
+// in flight warning consts
+const (
+	WarningInFlightFlushFailed   = "Could not store in flight attestation"
+	WarningInFlightRestoreFailed = "Could not restore in flight attestation"
+)
+
+// Return whether the state has a new attestation in progress that can be resumed
+func isInFlightState(state AttestationState) bool {
+	switch state {
+	case AStateSignAttestation, AStateCanaryAttestation, AStateReviewAttestation, AStatePreSendStore,
+		AStateSendAttestation:
+		return true
+	}
+	return false
+}
+
+// Return in flight attestation model for attestation in progress and signer state
+func newInFlightAttestation(state AttestationState, attestation *models.Attestation,
+	sigs [][]crypto.Sig, sigsRequest []string, sigsRetries int) (models.InFlightAttestation, error) {
+
+	commitment, commitmentErr := attestation.Commitment()
+	if commitmentErr != nil {
+		return models.InFlightAttestation{}, commitmentErr
+	}
+	var txBytes bytes.Buffer
+	if err := attestation.Tx.Serialize(&txBytes); err != nil {
+		return models.InFlightAttestation{}, err
+	}
+
+	inFlight := models.InFlightAttestation{
+		State:       int(state),
+		Tx:          hex.EncodeToString(txBytes.Bytes()),
+		SigsRequest: sigsRequest,
+		SigsRetries: sigsRetries,
+		Time:        time.Now().Unix(),
+		Hash:        commitment.MerkleHash(),
+	}
+	positions := commitment.Positions()
+	for _, merkleCommitment := range commitment.GetMerkleCommitments() {
+		inFlight.Commitments = append(inFlight.Commitments, merkleCommitment.Commitment.String())
+		inFlight.RequestIds = append(inFlight.RequestIds, merkleCommitment.RequestId)
+		if positions != nil {
+			inFlight.Positions = append(inFlight.Positions, merkleCommitment.ClientPosition)
+		}
+	}
+	if positions != nil {
+		inFlight.LeafCount = len(positions)
+		for leaf, position := range positions {
+			if position >= 0 {
+				inFlight.Leaves = append(inFlight.Leaves, int32(leaf))
+			}
+		}
+	}
+	for _, inputSigs := range sigs {
+		var inputSigsHex []string
+		for _, sig := range inputSigs {
+			inputSigsHex = append(inputSigsHex, hex.EncodeToString(sig))
+		}
+		inFlight.Sigs = append(inFlight.Sigs, inputSigsHex)
+	}
+	return inFlight, nil
+}
+
+// Return attestation and signatures from in flight attestation model
+func restoreInFlightAttestation(inFlight models.InFlightAttestation) (*models.Attestation, [][]crypto.Sig, error) {
+	txBytes, txBytesErr := hex.DecodeString(inFlight.Tx)
+	if txBytesErr != nil {
+		return nil, nil, txBytesErr
+	}
+	var tx wire.MsgTx
+	if err := tx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+		return nil, nil, err
+	}
+
+	// commitments not ordered by client position are placed at their leaves
+	ordered := inFlight.LeafCount > 0 && len(inFlight.Positions) == len(inFlight.Commitments) &&
+		len(inFlight.Leaves) == len(inFlight.Commitments)
+	var commitments []chainhash.Hash
+	var positions []int32
+	if ordered {
+		commitments = make([]chainhash.Hash, inFlight.LeafCount)
+		positions = make([]int32, inFlight.LeafCount)
+		for leaf := range positions {
+			positions[leaf] = -1
+		}
+	}
+	requestIds := make(map[int32]string)
+	for i, commitmentStr := range inFlight.Commitments {
+		commitmentHash, hashErr := chainhash.NewHashFromStr(commitmentStr)
+		if hashErr != nil {
+			return nil, nil, hashErr
+		}
+		pos := int32(i)
+		if ordered {
+			leaf := inFlight.Leaves[i]
+			if leaf < 0 || int(leaf) >= inFlight.LeafCount {
+				return nil, nil, errors.New(fmt.Sprintf("%s: %d", models.ErrorOrderingLeaf, leaf))
+			}
+			pos = inFlight.Positions[i]
+			commitments[leaf] = *commitmentHash
+			positions[leaf] = pos
+		} else {
+			commitments = append(commitments, *commitmentHash)
+		}
+		if i < len(inFlight.RequestIds) && inFlight.RequestIds[i] != "" {
+			requestIds[pos] = inFlight.RequestIds[i]
+		}
+	}
+	commitment, commitmentErr := models.NewCommitmentHash(commitments, inFlight.Hash)
+	if commitmentErr != nil {
+		return nil, nil, commitmentErr
+	}
+	commitment.SetPositions(positions)
+	commitment.SetRequestIds(requestIds)
+
+	var inFlightSigs [][]crypto.Sig
+	for _, inputSigsHex := range inFlight.Sigs {
+		inputSigs := []crypto.Sig{}
+		for _, sigHex := range inputSigsHex {
+			sig, sigErr := hex.DecodeString(sigHex)
+			if sigErr != nil {
+				return nil, nil, sigErr
+			}
+			inputSigs = append(inputSigs, sig)
+		}
+		inFlightSigs = append(inFlightSigs, inputSigs)
+	}
+
+	attestation := models.NewAttestation(tx.TxHash(), commitment)
+	attestation.Tx = tx
+	return attestation, inFlightSigs, nil
+}
+
+// Store new attestation in progress and signer state on shutdown
+// Fee bumped and cpfp attestations are re-initiated from handle unconfirmed instead
+func (s *AttestService) flushInFlight() {
+	if !isInFlightState(s.state) || s.isFeeBumped || s.cpfpParent != nil {
+		return
+	}
+	inFlight, inFlightErr := newInFlightAttestation(s.state, s.attestation, s.sigs, s.sigsRequest, s.sigsRetries)
+	if inFlightErr != nil {
+		log.Warnf("%s %v\n", WarningInFlightFlushFailed, inFlightErr)
+		return
+	}
+	if err := s.server.RecordInFlightAttestation(inFlight); err != nil {
+		log.Warnf("%s %v\n", WarningInFlightFlushFailed, err)
+		return
+	}
+	s.attestationLogger().Infoln("stored in flight attestation")
+}
+
+// part of AStateInit
+// resume in flight attestation stored on shutdown if this spends the unspent
+// found, otherwise discard it, and update signers for attestations being signed
+func (s *AttestService) stateInitInFlight(unspent btcjson.ListUnspentResult) {
+	inFlight, inFlightErr := s.server.GetInFlightAttestation()
+	if inFlightErr != nil {
+		log.Warnf("%s %v\n", WarningInFlightRestoreFailed, inFlightErr)
+		return
+	} else if inFlight.Tx == "" {
+		return // nothing in flight
+	}
+	if err := s.server.ClearInFlightAttestation(); err != nil {
+		log.Warnf("%s %v\n", WarningInFlightRestoreFailed, err)
+		return
+	}
+
+	attestation, inFlightSigs, restoreErr := restoreInFlightAttestation(inFlight)
+	if restoreErr != nil {
+		log.Warnf("%s %v\n", WarningInFlightRestoreFailed, restoreErr)
+		return
+	}
+	prevOut := attestation.Tx.TxIn[0].PreviousOutPoint
+	if prevOut.Hash.String() != unspent.TxID || prevOut.Index != unspent.Vout {
+		s.logger().WithFields(log.Fields{log.FieldTxid: attestation.Txid.String()}).Infoln("discarding stale in flight attestation")
+		return
+	}
+
+	state := AttestationState(inFlight.State)
+	if state == AStateSignAttestation {
+		// re-publish pre signed transaction to signers
+		lastCommitmentHash, latestErr := s.server.GetLatestAttestationCommitmentHash()
+		if latestErr != nil {
+			log.Warnf("%s %v\n", WarningInFlightRestoreFailed, latestErr)
+			return
+		}
+		if s.attester.txid0 == unspent.TxID {
+			lastCommitmentHash = chainhash.Hash{}
+		}
+		txPreImages, getPreImagesErr := s.attester.getTransactionPreImages(lastCommitmentHash, &attestation.Tx)
+		if getPreImagesErr != nil {
+			log.Warnf("%s %v\n", WarningInFlightRestoreFailed, getPreImagesErr)
+			return
+		}
+		var txPreImageBytes [][]byte
+		for _, txPreImage := range txPreImages {
+			var txBytesBuffer bytes.Buffer
+			txPreImage.Serialize(&txBytesBuffer)
+			txPreImageBytes = append(txPreImageBytes, txBytesBuffer.Bytes())
+		}
+		s.signer.ReSubscribe()
+		s.signer.SendTxPreImages(txPreImageBytes)
+		s.attestDelay = ATimeSigsPoll // add sigs polling time
+	}
+
+	s.logger().WithFields(log.Fields{log.FieldCommitment: attestation.CommitmentHash().String()}).Infoln("resuming in flight attestation")
+	s.attestation = attestation
+	s.sigs = inFlightSigs
+	s.sigsRequest = inFlight.SigsRequest
+	s.sigsRetries = inFlight.SigsRetries
+	s.sigsTime = clock.Now()
+	s.state = state // update attestation state
+}
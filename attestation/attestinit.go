@@ -0,0 +1,125 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"errors"
+	"fmt"
+
+	"mainstay/log"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Attestation service init reconciles the wallet, mempool and db into a
+// single picture of the staychain tip, so that init can be repeated after a
+// failure at any point of an attestation round, e.g. with the attestation
+// address imported or the transaction sent but the round not stored.
+// The tip found through the chain backend is authoritative. Its commitment
+// is read from the db or, if not stored, recovered from the in flight
+// attestation or the latest client commitment by matching the tip address
+// derived from these. Recovered attestations are stored before resuming
+
+// init error and warning consts
+const (
+	ErrorInitCommitmentUnknown = "Could not recover commitment of staychain tip"
+	ErrorInitTipAddress        = "Staychain tip output pays to no address"
+
+	WarningInitCommitmentRecovered = "Staychain tip not stored - recovered commitment"
+	WarningInitBaseUnconfirmed     = "Staychain base transaction unconfirmed - waiting"
+)
+
+// Return commitment of the staychain tip attestation and whether this is
+// stored in the server, recovering the commitment if not stored
+func (s *AttestService) reconcileTipCommitment(txid chainhash.Hash, tx *wire.MsgTx) (*models.Commitment, bool, error) {
+	commitment, commitmentErr := s.server.GetAttestationCommitment(txid)
+	if commitmentErr != nil {
+		return nil, false, commitmentErr
+	} else if (commitment.GetCommitmentHash() != chainhash.Hash{}) {
+		return &commitment, true, nil
+	}
+
+	tipAddr, tipAddrErr := s.attester.getTxOutAddr(tx)
+	if tipAddrErr != nil {
+		return nil, false, tipAddrErr
+	}
+	candidates, candidatesErr := s.recoveryCommitments()
+	if candidatesErr != nil {
+		return nil, false, candidatesErr
+	}
+	attesters := []*AttestClient{s.attester}
+	if next := s.nextAttester(); next != s.attester {
+		attesters = append(attesters, next) // transition attestation of key rotation
+	}
+	for _, candidate := range candidates {
+		for _, attester := range attesters {
+			addr, addrErr := attester.getAttestationAddr(candidate.GetCommitmentHash())
+			if addrErr != nil {
+				return nil, false, addrErr
+			}
+			if addr != tipAddr {
+				continue
+			}
+			s.logger().WithFields(log.Fields{log.FieldTxid: txid.String(),
+				log.FieldCommitment: candidate.GetCommitmentHash().String()}).Warnln(WarningInitCommitmentRecovered)
+			return candidate, false, nil
+		}
+	}
+	return nil, false, errors.New(fmt.Sprintf("%s %s", ErrorInitCommitmentUnknown, txid.String()))
+}
+
+// Return commitments that an attestation not stored in the server may have
+// committed to: the in flight attestation and the latest client commitment
+// In flight attestations that can not be restored are ignored
+func (s *AttestService) recoveryCommitments() ([]*models.Commitment, error) {
+	var candidates []*models.Commitment
+	inFlight, inFlightErr := s.server.GetInFlightAttestation()
+	if inFlightErr != nil {
+		return nil, inFlightErr
+	} else if inFlight.Tx != "" {
+		if attestation, _, restoreErr := restoreInFlightAttestation(inFlight); restoreErr == nil {
+			if commitment, commitmentErr := attestation.Commitment(); commitmentErr == nil {
+				candidates = append(candidates, commitment)
+			}
+		}
+	}
+	clientCommitment, clientErr := s.server.PeekClientCommitment()
+	if clientErr != nil && clientErr.Error() != models.ErrorCommitmentListEmpty {
+		return nil, clientErr
+	} else if clientErr == nil {
+		candidates = append(candidates, &clientCommitment)
+	}
+	return candidates, nil
+}
+
+// Return address of the attestation committing to the commitment hash
+func (w *AttestClient) getAttestationAddr(hash chainhash.Hash) (string, error) {
+	key, keyErr := w.GetNextAttestationKey(hash)
+	if keyErr != nil {
+		return "", keyErr
+	}
+	addr, _, addrErr := w.GetNextAttestationAddr(key, hash)
+	if addrErr != nil {
+		return "", addrErr
+	}
+	return addr.String(), nil
+}
+
+// Return address paid to by the attestation output of the transaction
+func (w *AttestClient) getTxOutAddr(tx *wire.MsgTx) (string, error) {
+	if len(tx.TxOut) == 0 {
+		return "", errors.New(ErrorInitTipAddress)
+	}
+	_, addrs, _, addrsErr := txscript.ExtractPkScriptAddrs(tx.TxOut[0].PkScript, w.MainChainCfg)
+	if addrsErr != nil {
+		return "", addrsErr
+	} else if len(addrs) == 0 {
+		return "", errors.New(ErrorInitTipAddress)
+	}
+	return addrs[0].String(), nil
+}
@@ -0,0 +1,344 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	confpkg "mainstay/config"
+	"mainstay/crypto"
+	"mainstay/db"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/stretchr/testify/assert"
+)
+
+// chain backend with the wallet unspent and mempool transactions set by tests
+type initChainFake struct {
+	ChainBackend
+	txs      map[chainhash.Hash]*wire.MsgTx
+	mempool  []*chainhash.Hash
+	unspent  []btcjson.ListUnspentResult
+	imported []string
+}
+
+func (c *initChainFake) GetRawMempool() ([]*chainhash.Hash, error) {
+	return c.mempool, nil
+}
+
+func (c *initChainFake) GetRawTransaction(txid *chainhash.Hash) (*btcutil.Tx, error) {
+	if tx, ok := c.txs[*txid]; ok {
+		return btcutil.NewTx(tx), nil
+	}
+	return nil, errors.New("No such mempool or blockchain transaction")
+}
+
+func (c *initChainFake) GetTransaction(txid *chainhash.Hash) (*btcjson.GetTransactionResult, error) {
+	return &btcjson.GetTransactionResult{TxID: txid.String(), BlockHash: "blockhash", Confirmations: 1, Time: 1}, nil
+}
+
+func (c *initChainFake) GetBlockCount() (int64, error) {
+	return 1, nil
+}
+
+func (c *initChainFake) GetMempoolEntry(txid string) (*btcjson.GetMempoolEntryResult, error) {
+	return &btcjson.GetMempoolEntryResult{Fee: 0.0001, Time: 1}, nil
+}
+
+func (c *initChainFake) ListUnspent() ([]btcjson.ListUnspentResult, error) {
+	return c.unspent, nil
+}
+
+func (c *initChainFake) ImportAddressRescan(addr string, label string, rescan bool) error {
+	c.imported = append(c.imported, addr)
+	return nil
+}
+
+// add confirmed transaction to the wallet unspent
+func (c *initChainFake) confirm(tx *wire.MsgTx) {
+	c.txs[tx.TxHash()] = tx
+	c.unspent = append(c.unspent, btcjson.ListUnspentResult{TxID: tx.TxHash().String()})
+}
+
+// add unconfirmed transaction to the mempool
+func (c *initChainFake) send(tx *wire.MsgTx) {
+	txid := tx.TxHash()
+	c.txs[txid] = tx
+	c.mempool = append(c.mempool, &txid)
+}
+
+// signer recording the confirmed hashes sent
+type initSignerFake struct {
+	confirmed []chainhash.Hash
+}
+
+func (f *initSignerFake) SendConfirmedHash(hash []byte) {
+	confirmedHash, _ := chainhash.NewHash(hash)
+	f.confirmed = append(f.confirmed, *confirmedHash)
+}
+func (f *initSignerFake) SendTxPreImages([][]byte)                           {}
+func (f *initSignerFake) ReSubscribe()                                       {}
+func (f *initSignerFake) GetSigs(string, string, string, int) [][]crypto.Sig { return nil }
+
+// init test staychain of base transaction and attestations
+type initTestChain struct {
+	client *AttestClient
+	base   *wire.MsgTx
+}
+
+// Return transaction spending the previous transaction and paying to address
+func newInitTestTx(prevTxid chainhash.Hash, addr btcutil.Address) *wire.MsgTx {
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&prevTxid, 0), nil, nil))
+	pkScript, _ := txscript.PayToAddrScript(addr)
+	tx.AddTxOut(wire.NewTxOut(1000000, pkScript))
+	return tx
+}
+
+// Return test staychain with a 1 of 2 multisig client derived from xpubs
+func newInitTestChain(t *testing.T) initTestChain {
+	var xpubStrs []string
+	var basePubs []*btcec.PublicKey
+	for _, seed := range []string{"000102030405060708090a0b0c0d0e0f", "0f0e0d0c0b0a09080706050403020100"} {
+		seedBytes, _ := hex.DecodeString(seed)
+		xprv, _ := hdkeychain.NewMaster(seedBytes, &chaincfg.RegressionNetParams)
+		xpub, _ := xprv.Neuter()
+		basePub, _ := xpub.ECPubKey()
+		xpubStrs = append(xpubStrs, xpub.String())
+		basePubs = append(basePubs, basePub)
+	}
+	baseAddr, script := crypto.CreateMultisig(basePubs, 1, &chaincfg.RegressionNetParams)
+	base := newInitTestTx(chainhash.Hash{1}, baseAddr)
+
+	config, configErr := confpkg.NewConfig([]byte(fmt.Sprintf(`
+    {
+        "main": {
+            "rpcurl": "localhost:18443",
+            "rpcuser": "user",
+            "rpcpass": "pass",
+            "chain": "regtest"
+        },
+        "staychain": {
+            "initTx": "%s",
+            "initScript": "%s",
+            "initXpubs": "%s",
+            "topupScript": "%s"
+        }
+    }
+    `, base.TxHash().String(), script, strings.Join(xpubStrs, ", "), script)))
+	assert.Equal(t, nil, configErr)
+	return initTestChain{newMultisigAttestClient(config, false, nil, nil), base}
+}
+
+// Return attestation transaction committing to the commitment
+func (c initTestChain) attest(t *testing.T, prevTxid chainhash.Hash, commitment *models.Commitment) *wire.MsgTx {
+	addr, _, addrErr := c.client.GetNextAttestationAddr(nil, commitment.GetCommitmentHash())
+	assert.Equal(t, nil, addrErr)
+	return newInitTestTx(prevTxid, addr)
+}
+
+// Return attestation of the transaction with the commitment
+func newInitTestAttestation(tx *wire.MsgTx, commitment *models.Commitment, confirmed bool) models.Attestation {
+	attestation := models.NewAttestation(tx.TxHash(), commitment)
+	attestation.Tx = *tx
+	attestation.Confirmed = confirmed
+	attestation.Info = models.AttestationInfo{Txid: tx.TxHash().String(), Blockhash: "blockhash", Amount: 1000000, Time: 1}
+	return *attestation
+}
+
+// Test init reconciles the staychain tip found in the wallet and mempool
+// with the db for every combination of partial progress of a round
+// Repeating init after completing must result in the same state
+func TestAttestServiceInitReconcile(t *testing.T) {
+	hash1, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash2, _ := chainhash.NewHashFromStr("2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment1, _ := models.NewCommitment([]chainhash.Hash{*hash1})
+	commitment2, _ := models.NewCommitment([]chainhash.Hash{*hash2})
+	testChain := newInitTestChain(t)
+	base := testChain.base
+	tx1 := testChain.attest(t, base.TxHash(), commitment1)
+	tx2 := testChain.attest(t, tx1.TxHash(), commitment2)
+
+	// db progress options
+	storeTx1 := func(server *AttestServer, dbFake *db.DbFake) {
+		server.UpdateLatestAttestation(newInitTestAttestation(tx1, commitment1, true))
+	}
+	storeTx1Unconfirmed := func(server *AttestServer, dbFake *db.DbFake) {
+		server.UpdateLatestAttestation(newInitTestAttestation(tx1, commitment1, false))
+	}
+	storeTx2Unconfirmed := func(server *AttestServer, dbFake *db.DbFake) {
+		server.UpdateLatestAttestation(newInitTestAttestation(tx2, commitment2, false))
+	}
+	inFlightTx1 := func(server *AttestServer, dbFake *db.DbFake) {
+		attestation := newInitTestAttestation(tx1, commitment1, false)
+		inFlight, _ := newInFlightAttestation(AStateSendAttestation, &attestation, nil, nil, 0)
+		server.RecordInFlightAttestation(inFlight)
+	}
+	clientCommitment := func(hash *chainhash.Hash) func(*AttestServer, *db.DbFake) {
+		return func(server *AttestServer, dbFake *db.DbFake) {
+			dbFake.SetClientCommitments([]models.ClientCommitment{models.ClientCommitment{*hash, 0, "", 0}})
+		}
+	}
+
+	cases := []struct {
+		name        string
+		confirmed   []*wire.MsgTx
+		unconfirmed []*wire.MsgTx
+		db          []func(*AttestServer, *db.DbFake)
+		state       AttestationState
+		txid        chainhash.Hash
+		commitment  chainhash.Hash
+		signerHash  chainhash.Hash
+		stored      []models.Attestation
+		err         string
+	}{
+		{name: "base unspent",
+			confirmed: []*wire.MsgTx{base},
+			state:     AStateNextCommitment},
+		{name: "base unconfirmed",
+			unconfirmed: []*wire.MsgTx{base},
+			state:       AStateInit},
+		{name: "confirmed stored",
+			confirmed: []*wire.MsgTx{tx1},
+			db:        []func(*AttestServer, *db.DbFake){storeTx1},
+			state:     AStateNextCommitment, txid: tx1.TxHash(), commitment: commitment1.GetCommitmentHash(), signerHash: commitment1.GetCommitmentHash(),
+			stored: []models.Attestation{newInitTestAttestation(tx1, commitment1, true)}},
+		{name: "confirmed stored unconfirmed",
+			confirmed: []*wire.MsgTx{tx1},
+			db:        []func(*AttestServer, *db.DbFake){storeTx1Unconfirmed},
+			state:     AStateNextCommitment, txid: tx1.TxHash(), commitment: commitment1.GetCommitmentHash(), signerHash: commitment1.GetCommitmentHash(),
+			stored: []models.Attestation{newInitTestAttestation(tx1, commitment1, true)}},
+		{name: "confirmed not stored in flight",
+			confirmed: []*wire.MsgTx{tx1},
+			db:        []func(*AttestServer, *db.DbFake){inFlightTx1, clientCommitment(hash2)},
+			state:     AStateNextCommitment, txid: tx1.TxHash(), commitment: commitment1.GetCommitmentHash(), signerHash: commitment1.GetCommitmentHash(),
+			stored: []models.Attestation{newInitTestAttestation(tx1, commitment1, true)}},
+		{name: "confirmed not stored client commitment",
+			confirmed: []*wire.MsgTx{tx1},
+			db:        []func(*AttestServer, *db.DbFake){clientCommitment(hash1)},
+			state:     AStateNextCommitment, txid: tx1.TxHash(), commitment: commitment1.GetCommitmentHash(), signerHash: commitment1.GetCommitmentHash(),
+			stored: []models.Attestation{newInitTestAttestation(tx1, commitment1, true)}},
+		{name: "confirmed not stored unknown",
+			confirmed: []*wire.MsgTx{tx1},
+			db:        []func(*AttestServer, *db.DbFake){clientCommitment(hash2)},
+			state:     AStateError,
+			err:       fmt.Sprintf("%s %s", ErrorInitCommitmentUnknown, tx1.TxHash().String())},
+		{name: "unconfirmed stored",
+			confirmed: []*wire.MsgTx{tx1}, unconfirmed: []*wire.MsgTx{tx2},
+			db:    []func(*AttestServer, *db.DbFake){storeTx1, storeTx2Unconfirmed},
+			state: AStateAwaitConfirmation, txid: tx2.TxHash(), commitment: commitment2.GetCommitmentHash(), signerHash: commitment1.GetCommitmentHash(),
+			stored: []models.Attestation{newInitTestAttestation(tx1, commitment1, true), newInitTestAttestation(tx2, commitment2, false)}},
+		{name: "unconfirmed not stored client commitment",
+			confirmed: []*wire.MsgTx{tx1}, unconfirmed: []*wire.MsgTx{tx2},
+			db:    []func(*AttestServer, *db.DbFake){storeTx1, clientCommitment(hash2)},
+			state: AStateAwaitConfirmation, txid: tx2.TxHash(), commitment: commitment2.GetCommitmentHash(), signerHash: commitment1.GetCommitmentHash(),
+			stored: []models.Attestation{newInitTestAttestation(tx1, commitment1, true), newInitTestAttestation(tx2, commitment2, false)}},
+		{name: "unconfirmed not stored unknown",
+			confirmed: []*wire.MsgTx{tx1}, unconfirmed: []*wire.MsgTx{tx2},
+			db:    []func(*AttestServer, *db.DbFake){storeTx1},
+			state: AStateError,
+			err:   fmt.Sprintf("%s %s", ErrorInitCommitmentUnknown, tx2.TxHash().String())},
+		{name: "unconfirmed and confirmed not stored",
+			confirmed: []*wire.MsgTx{tx1}, unconfirmed: []*wire.MsgTx{tx2},
+			db:    []func(*AttestServer, *db.DbFake){clientCommitment(hash2)},
+			state: AStateAwaitConfirmation, txid: tx2.TxHash(), commitment: commitment2.GetCommitmentHash(),
+			stored: []models.Attestation{newInitTestAttestation(tx2, commitment2, false)}},
+		{name: "wallet missing tip",
+			db:     []func(*AttestServer, *db.DbFake){storeTx1, clientCommitment(hash2)},
+			state:  AStateInit,
+			stored: []models.Attestation{newInitTestAttestation(tx1, commitment1, true)}},
+	}
+	for _, c := range cases {
+		chain := &initChainFake{txs: map[chainhash.Hash]*wire.MsgTx{base.TxHash(): base}}
+		for _, tx := range c.confirmed {
+			chain.confirm(tx)
+		}
+		for _, tx := range c.unconfirmed {
+			chain.send(tx)
+		}
+		dbFake := db.NewDbFake()
+		server := NewAttestServer(dbFake)
+		for _, progress := range c.db {
+			progress(server, dbFake)
+		}
+		client := *testChain.client
+		client.Chain = chain
+		signer := &initSignerFake{}
+		s := &AttestService{attester: &client, server: server, signer: signer, isRegtest: true}
+
+		// repeated init from the same chain and db state has the same result
+		for run := 0; run < 2; run++ {
+			s.state = AStateInit
+			s.errorState = nil
+			s.attestation = models.NewAttestationDefault()
+			signer.confirmed = nil
+			s.doStateInit()
+
+			assert.Equal(t, c.state, s.state, c.name)
+			if c.err != "" {
+				assert.Equal(t, c.err, s.errorState.Error(), c.name)
+				continue
+			}
+			assert.Equal(t, nil, s.errorState, c.name)
+			assert.Equal(t, c.txid, s.attestation.Txid, c.name)
+			assert.Equal(t, c.commitment, s.attestation.CommitmentHash(), c.name)
+			if c.state != AStateInit {
+				assert.Equal(t, []chainhash.Hash{c.signerHash}, signer.confirmed, c.name)
+			}
+			assert.Equal(t, len(c.stored), len(dbFake.Attestations), c.name)
+			for i, stored := range c.stored {
+				if i < len(dbFake.Attestations) {
+					assert.Equal(t, stored.Txid, dbFake.Attestations[i].Txid, c.name)
+					assert.Equal(t, stored.Confirmed, dbFake.Attestations[i].Confirmed, c.name)
+					assert.Equal(t, stored.CommitmentHash(), dbFake.Attestations[i].CommitmentHash(), c.name)
+				}
+				commitment, _ := server.GetAttestationCommitment(stored.Txid)
+				assert.Equal(t, stored.CommitmentHash(), commitment.GetCommitmentHash(), c.name)
+			}
+		}
+	}
+}
+
+// Test addresses imported when the wallet is missing the staychain tip
+// include the addresses of attestations that may not have been stored
+func TestAttestServiceInitWalletFailure(t *testing.T) {
+	hash1, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash2, _ := chainhash.NewHashFromStr("2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment1, _ := models.NewCommitment([]chainhash.Hash{*hash1})
+	commitment2, _ := models.NewCommitment([]chainhash.Hash{*hash2})
+	testChain := newInitTestChain(t)
+
+	dbFake := db.NewDbFake()
+	server := NewAttestServer(dbFake)
+	server.UpdateLatestAttestation(newInitTestAttestation(testChain.attest(t, testChain.base.TxHash(), commitment1), commitment1, true))
+	dbFake.SetClientCommitments([]models.ClientCommitment{models.ClientCommitment{*hash2, 0, "", 0}})
+
+	chain := &initChainFake{txs: map[chainhash.Hash]*wire.MsgTx{}}
+	client := *testChain.client
+	client.Chain = chain
+	s := &AttestService{attester: &client, server: server, signer: &initSignerFake{}, state: AStateInit}
+	s.doStateInit()
+	assert.Equal(t, AStateInit, s.state)
+
+	var expected []string
+	// latest confirmed, latest unconfirmed, base and client commitment addresses
+	for _, hash := range []chainhash.Hash{commitment1.GetCommitmentHash(), {}, {}, commitment2.GetCommitmentHash()} {
+		addr, _, _ := client.GetNextAttestationAddr(nil, hash)
+		expected = append(expected, addr.String())
+	}
+	assert.Equal(t, expected, chain.imported)
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"bytes"
+	"time"
+
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// integrity check failure reasons
+const (
+	IntegrityReasonTxidInvalid        = "Invalid attestation txid"
+	IntegrityReasonRootInvalid        = "Invalid attestation merkle root"
+	IntegrityReasonRootMismatch       = "Stored commitments do not match attestation merkle root"
+	IntegrityReasonTxNotFound         = "Attestation transaction not found on-chain"
+	IntegrityReasonPreviousNotSpent   = "Attestation does not spend the previous attestation"
+	IntegrityReasonAddressMismatch    = "Attestation output not derived from attestation merkle root"
+	IntegrityReasonOutputsUnsupported = "Attestation transaction has no outputs"
+)
+
+// AttestIntegrity struct
+// Walks all confirmed attestations stored in the server and verifies the
+// attestation chain against the transactions found with the main client
+type AttestIntegrity struct {
+	attester *AttestClient
+	server   *AttestServer
+}
+
+// Return new AttestIntegrity instance
+func NewAttestIntegrity(attester *AttestClient, server *AttestServer) *AttestIntegrity {
+	return &AttestIntegrity{attester, server}
+}
+
+// Check all confirmed attestations, oldest first, and return a report with
+// the first inconsistent round. Each attestation must spend the previous one
+// and pay to the address tweaked with its merkle root, using the keys of the
+// key rotation active at the height of the attestation, while the stored
+// commitments must hash to the same merkle root. The oldest attestation is
+// not checked against the previous one as this is not stored
+func (i *AttestIntegrity) Check() (models.IntegrityReport, error) {
+	attestations, attestationsErr := i.server.GetAttestations()
+	if attestationsErr != nil {
+		return models.IntegrityReport{}, attestationsErr
+	}
+	rotations, rotationsErr := i.server.GetKeyRotations()
+	if rotationsErr != nil {
+		return models.IntegrityReport{}, rotationsErr
+	}
+	history, historyErr := i.attester.keyHistory(rotations, i.server.attestationHeight)
+	if historyErr != nil {
+		return models.IntegrityReport{}, historyErr
+	}
+
+	report := models.IntegrityReport{Passed: true, Rounds: len(attestations), Time: time.Now().Unix()}
+	var prevTxid *chainhash.Hash
+	for round, attestation := range attestations {
+		txid, reason, checkErr := i.checkRound(attestation, prevTxid, history)
+		if checkErr != nil {
+			return models.IntegrityReport{}, checkErr
+		} else if reason != "" {
+			report.Passed = false
+			report.Inconsistent = &models.IntegrityRound{
+				Round:      round + 1,
+				Txid:       attestation.Txid,
+				MerkleRoot: attestation.MerkleRoot,
+				Reason:     reason,
+			}
+			break
+		}
+		prevTxid = txid
+	}
+	return report, nil
+}
+
+// Check single attestation round and return attestation txid and the reason
+// this is inconsistent, which is empty for consistent attestations
+func (i *AttestIntegrity) checkRound(attestation models.AttestationBSON, prevTxid *chainhash.Hash,
+	history attestKeyHistory) (
+	*chainhash.Hash, string, error) {
+
+	txid, txidErr := chainhash.NewHashFromStr(attestation.Txid)
+	if txidErr != nil {
+		return nil, IntegrityReasonTxidInvalid, nil
+	}
+	root, rootErr := chainhash.NewHashFromStr(attestation.MerkleRoot)
+	if rootErr != nil {
+		return nil, IntegrityReasonRootInvalid, nil
+	}
+
+	// stored commitments must hash to attestation merkle root
+	commitment, commitmentErr := i.server.GetAttestationCommitment(*txid)
+	if commitmentErr != nil {
+		return nil, "", commitmentErr
+	}
+	commitmentHash := commitment.GetCommitmentHash()
+	if !commitmentHash.IsEqual(root) {
+		return nil, IntegrityReasonRootMismatch, nil
+	}
+
+	tx, txErr := i.attester.Chain.GetRawTransaction(txid)
+	if txErr != nil {
+		if rpcErr, ok := txErr.(*btcjson.RPCError); ok && rpcErr.Code == btcjson.ErrRPCNoTxInfo {
+			return nil, IntegrityReasonTxNotFound, nil
+		}
+		return nil, "", txErr
+	}
+	msgTx := tx.MsgTx()
+
+	// attestation must spend previous attestation
+	if prevTxid != nil && !msgTx.TxIn[0].PreviousOutPoint.Hash.IsEqual(prevTxid) {
+		return nil, IntegrityReasonPreviousNotSpent, nil
+	}
+
+	// attestation must pay to address tweaked with merkle root
+	if len(msgTx.TxOut) == 0 {
+		return nil, IntegrityReasonOutputsUnsupported, nil
+	}
+	key, keyErr := i.attester.GetNextAttestationKey(*root)
+	if keyErr != nil {
+		return nil, "", keyErr
+	}
+	keys := history.keysAt(attestation.Txid, attestation.BlockHeight)
+	addr, _, addrErr := keys.attestationAddr(key, *root, i.attester.MainChainCfg)
+	if addrErr != nil {
+		return nil, "", addrErr
+	}
+	pkScript, pkScriptErr := txscript.PayToAddrScript(addr)
+	if pkScriptErr != nil {
+		return nil, "", pkScriptErr
+	}
+	if !bytes.Equal(msgTx.TxOut[0].PkScript, pkScript) {
+		return nil, IntegrityReasonAddressMismatch, nil
+	}
+	return txid, "", nil
+}
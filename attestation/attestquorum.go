@@ -0,0 +1,101 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"errors"
+	"fmt"
+
+	confpkg "mainstay/config"
+	"mainstay/log"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Attestation confirmations reported by the main client are checked on
+// additional independent nodes before being accepted, so that a single
+// eclipsed or misbehaving node cannot confirm an attestation on its own
+// The nodes do not need the staychain wallet or a transaction index as
+// the block reported by the main client is checked for the attestation
+
+// quorum consts
+const (
+	ErrorQuorumBlockNotInChain = "Block not in node main chain"
+	ErrorQuorumTxNotInBlock    = "Attestation not in block"
+
+	WarningInvalidQuorumThresholdArg = "Invalid quorum threshold config value"
+	WarningQuorumNotReached          = "Attestation confirmation quorum not reached"
+)
+
+// QuorumNode interface
+// Node rpc calls required to check attestation confirmations
+type QuorumNode interface {
+	GetBlockVerbose(blockHash *chainhash.Hash) (*btcjson.GetBlockVerboseResult, error)
+}
+
+// AttestQuorum struct
+// Checks attestation confirmations on the quorum nodes, requiring
+// a threshold of nodes, including the main client, to agree
+type AttestQuorum struct {
+	names     []string
+	nodes     []QuorumNode
+	threshold int
+}
+
+// Return new AttestQuorum instance from quorum config
+// Threshold defaults to a majority of the quorum nodes and main client
+func NewAttestQuorum(quorumConfig confpkg.QuorumConfig) *AttestQuorum {
+	var nodes []QuorumNode
+	for _, client := range quorumConfig.Clients {
+		nodes = append(nodes, client)
+	}
+	total := len(nodes) + 1
+	threshold := total/2 + 1
+	if quorumConfig.Threshold > 0 && quorumConfig.Threshold <= total {
+		threshold = quorumConfig.Threshold
+	} else {
+		log.Warnf("%s (%d)\n", WarningInvalidQuorumThresholdArg, quorumConfig.Threshold)
+	}
+	log.Infof("*Quorum* Confirmation threshold set to: %d of %d nodes\n", threshold, total)
+
+	return &AttestQuorum{names: quorumConfig.Nodes, nodes: nodes, threshold: threshold}
+}
+
+// Check whether the threshold of nodes agree that the attestation is confirmed
+// in the block reported by the main client, and return number of nodes agreeing
+func (q *AttestQuorum) Confirmed(txid chainhash.Hash, blockHash string) (bool, int) {
+	agreed := 1 // main client
+	for i, node := range q.nodes {
+		if confirmErr := checkNodeConfirmation(node, txid, blockHash); confirmErr != nil {
+			log.WithFields(log.Fields{log.FieldTxid: txid.String(), log.FieldNode: q.names[i], log.FieldError: confirmErr}).
+				Warnln("quorum node does not confirm attestation")
+			continue
+		}
+		agreed++
+	}
+	return agreed >= q.threshold, agreed
+}
+
+// Check that the block is in the node main chain and includes the attestation
+func checkNodeConfirmation(node QuorumNode, txid chainhash.Hash, blockHash string) error {
+	hash, hashErr := chainhash.NewHashFromStr(blockHash)
+	if hashErr != nil {
+		return hashErr
+	}
+	block, blockErr := node.GetBlockVerbose(hash)
+	if blockErr != nil {
+		return blockErr
+	}
+	if block.Confirmations < 1 {
+		return errors.New(fmt.Sprintf("%s: %s", ErrorQuorumBlockNotInChain, blockHash))
+	}
+	for _, blockTxid := range block.Tx {
+		if blockTxid == txid.String() {
+			return nil
+		}
+	}
+	return errors.New(fmt.Sprintf("%s: %s", ErrorQuorumTxNotInBlock, blockHash))
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"errors"
+	"testing"
+
+	confpkg "mainstay/config"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/stretchr/testify/assert"
+)
+
+// quorum node returning fixed blocks
+type quorumNodeFake struct {
+	blocks map[string]*btcjson.GetBlockVerboseResult
+}
+
+func (n quorumNodeFake) GetBlockVerbose(blockHash *chainhash.Hash) (*btcjson.GetBlockVerboseResult, error) {
+	block, ok := n.blocks[blockHash.String()]
+	if !ok {
+		return nil, errors.New("Block not found")
+	}
+	return block, nil
+}
+
+// Test quorum threshold config and agreement on attestation confirmations
+func TestAttestQuorum(t *testing.T) {
+	clients := []*rpcclient.Client{nil, nil, nil, nil}
+	assert.Equal(t, 2, NewAttestQuorum(confpkg.QuorumConfig{Clients: clients[:2], Threshold: -1}).threshold)
+	assert.Equal(t, 3, NewAttestQuorum(confpkg.QuorumConfig{Clients: clients, Threshold: 0}).threshold)
+	assert.Equal(t, 3, NewAttestQuorum(confpkg.QuorumConfig{Clients: clients, Threshold: 6}).threshold)
+	assert.Equal(t, 5, NewAttestQuorum(confpkg.QuorumConfig{Clients: clients, Threshold: 5}).threshold)
+	assert.Equal(t, 2, NewAttestQuorum(confpkg.QuorumConfig{Clients: clients[:1], Threshold: -1}).threshold)
+
+	txid := chainhash.Hash{1}
+	blockHash := chainhash.Hash{2}
+	otherHash := chainhash.Hash{3}
+	confirmed := quorumNodeFake{map[string]*btcjson.GetBlockVerboseResult{
+		blockHash.String(): {Confirmations: 1, Tx: []string{"aa", txid.String()}}}}
+	stale := quorumNodeFake{map[string]*btcjson.GetBlockVerboseResult{
+		blockHash.String(): {Confirmations: -1, Tx: []string{txid.String()}}}}
+	other := quorumNodeFake{map[string]*btcjson.GetBlockVerboseResult{
+		blockHash.String(): {Confirmations: 3, Tx: []string{"aa"}}}}
+	missing := quorumNodeFake{}
+
+	assert.Equal(t, nil, checkNodeConfirmation(confirmed, txid, blockHash.String()))
+	assert.Equal(t, ErrorQuorumBlockNotInChain+": "+blockHash.String(),
+		checkNodeConfirmation(stale, txid, blockHash.String()).Error())
+	assert.Equal(t, ErrorQuorumTxNotInBlock+": "+blockHash.String(),
+		checkNodeConfirmation(other, txid, blockHash.String()).Error())
+	assert.Equal(t, errors.New("Block not found"), checkNodeConfirmation(confirmed, txid, otherHash.String()))
+	assert.NotEqual(t, nil, checkNodeConfirmation(confirmed, txid, "xyz"))
+
+	quorum := &AttestQuorum{names: []string{"a", "b", "c", "d"},
+		nodes: []QuorumNode{confirmed, stale, other, missing}, threshold: 3}
+	isConfirmed, agreed := quorum.Confirmed(txid, blockHash.String())
+	assert.Equal(t, false, isConfirmed)
+	assert.Equal(t, 2, agreed)
+
+	quorum.nodes[3] = confirmed
+	isConfirmed, agreed = quorum.Confirmed(txid, blockHash.String())
+	assert.Equal(t, true, isConfirmed)
+	assert.Equal(t, 3, agreed)
+
+	// service without quorum accepts main client confirmation
+	attestService := &AttestService{attestation: newReviewTestAttestation(chainhash.Hash{1})}
+	assert.Equal(t, true, attestService.isQuorumConfirmed(blockHash.String()))
+	attestService.quorum = quorum
+	assert.Equal(t, false, attestService.isQuorumConfirmed(blockHash.String()))
+}
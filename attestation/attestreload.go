@@ -0,0 +1,140 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"fmt"
+	"reflect"
+
+	confpkg "mainstay/config"
+)
+
+// Timing, adaptive interval, heartbeat, fee and signer configuration can be
+// reloaded at runtime, e.g. on SIGHUP, without restarting the attestation
+// service. The reloaded config is held until the service next waits for a new
+// commitment, when no attestation is in progress, and is then applied with
+// each changed value logged
+
+// warning consts
+const (
+	WarningReloadSignerUrl = "Signer url cannot be reloaded - restart required"
+)
+
+// ReloadConfig struct
+// Subset of the service config that can be reloaded at runtime
+type ReloadConfig struct {
+	Timing    confpkg.TimingConfig
+	Adaptive  confpkg.AdaptiveConfig
+	Heartbeat confpkg.HeartbeatConfig
+	Fees      confpkg.FeesConfig
+	Rbf       confpkg.RbfConfig
+	Signer    confpkg.SignerConfig
+}
+
+// Return ReloadConfig from conf options
+func GetReloadConfig(conf []byte) (ReloadConfig, error) {
+	signerConfig, signerErr := confpkg.GetSignerConfig(conf)
+	if signerErr != nil {
+		return ReloadConfig{}, signerErr
+	}
+	return ReloadConfig{
+		Timing:    confpkg.GetTimingConfig(conf),
+		Adaptive:  confpkg.GetAdaptiveConfig(conf),
+		Heartbeat: confpkg.GetHeartbeatConfig(conf),
+		Fees:      confpkg.GetFeesConfig(conf),
+		Rbf:       confpkg.GetRbfConfig(conf),
+		Signer:    signerConfig,
+	}, nil
+}
+
+// Return ReloadConfig currently used by the service config
+func currentReloadConfig(config *confpkg.Config) ReloadConfig {
+	return ReloadConfig{
+		Timing:    config.TimingConfig(),
+		Adaptive:  config.AdaptiveConfig(),
+		Heartbeat: config.HeartbeatConfig(),
+		Fees:      config.FeesConfig(),
+		Rbf:       config.RbfConfig(),
+		Signer:    config.SignerConfig(),
+	}
+}
+
+// Return description of each value changed between two reload configs
+func reloadChanges(prev ReloadConfig, next ReloadConfig) []string {
+	var changes []string
+	prevValue := reflect.ValueOf(prev)
+	nextValue := reflect.ValueOf(next)
+	for i := 0; i < prevValue.NumField(); i++ {
+		category := prevValue.Type().Field(i).Name
+		prevCategory := prevValue.Field(i)
+		nextCategory := nextValue.Field(i)
+		for j := 0; j < prevCategory.NumField(); j++ {
+			prevField := prevCategory.Field(j).Interface()
+			nextField := nextCategory.Field(j).Interface()
+			if !reflect.DeepEqual(prevField, nextField) {
+				changes = append(changes, fmt.Sprintf("%s.%s: %v -> %v",
+					category, prevCategory.Type().Field(j).Name, prevField, nextField))
+			}
+		}
+	}
+	return changes
+}
+
+// Queue reloaded config to be applied at the next safe state boundary
+// Replaces any reloaded config still pending. Does not block
+func (s *AttestService) Reload(reloadConfig ReloadConfig) {
+	select {
+	case <-s.reload: // drop pending reload
+	default:
+	}
+	select {
+	case s.reload <- reloadConfig:
+	default:
+	}
+}
+
+// Apply pending reloaded config, if any, logging each changed value
+func (s *AttestService) applyReload() {
+	var reloadConfig ReloadConfig
+	select {
+	case reloadConfig = <-s.reload:
+	default:
+		return
+	}
+
+	prev := currentReloadConfig(s.config)
+	if reloadConfig.Signer.Url != prev.Signer.Url {
+		s.logger().Warnf("%s (%s)\n", WarningReloadSignerUrl, reloadConfig.Signer.Url)
+		reloadConfig.Signer.Url = prev.Signer.Url
+	}
+	changes := reloadChanges(prev, reloadConfig)
+	if len(changes) == 0 {
+		s.logger().Infoln("config reloaded - no changes")
+		return
+	}
+	for _, change := range changes {
+		s.logger().Infof("config reloaded - %s\n", change)
+	}
+
+	s.setTimingConfig(reloadConfig.Timing)
+	s.setAdaptiveConfig(reloadConfig.Adaptive)
+	s.setHeartbeatConfig(reloadConfig.Heartbeat)
+	s.setRbfConfig(reloadConfig.Rbf)
+	s.setSignerConfig(reloadConfig.Signer)
+	if !reflect.DeepEqual(prev.Fees, reloadConfig.Fees) || !reflect.DeepEqual(prev.Rbf, reloadConfig.Rbf) {
+		s.attester.Fees = NewAttestFees(reloadConfig.Fees, reloadConfig.Rbf)
+		s.attester.Fees.ResetFee(s.isRegtest)
+	}
+
+	s.config.SetTimingConfig(reloadConfig.Timing)
+	s.config.SetAdaptiveConfig(reloadConfig.Adaptive)
+	s.config.SetHeartbeatConfig(reloadConfig.Heartbeat)
+	s.config.SetFeesConfig(reloadConfig.Fees)
+	s.config.SetRbfConfig(reloadConfig.Rbf)
+	s.config.SetSignerConfig(reloadConfig.Signer)
+	if !reflect.DeepEqual(prev.Signer, reloadConfig.Signer) {
+		s.applySignerSet()
+	}
+}
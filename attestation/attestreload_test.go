@@ -0,0 +1,86 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"testing"
+	"time"
+
+	confpkg "mainstay/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var reloadTestConf = []byte(`
+{
+    "main": {
+        "rpcurl": "localhost:18443",
+        "rpcuser": "user",
+        "rpcpass": "pass",
+        "chain": "regtest"
+    },
+    "signer": {
+        "url": "localhost:5000",
+        "retries": "2"
+    },
+    "timing": {
+        "newAttestationMinutes": "60"
+    },
+    "fees": {
+        "minFee": "10"
+    }
+}
+`)
+
+// Test reload config changes are described per value
+func TestReloadChanges(t *testing.T) {
+	prev, prevErr := GetReloadConfig(reloadTestConf)
+	assert.Equal(t, nil, prevErr)
+	assert.Equal(t, 0, len(reloadChanges(prev, prev)))
+
+	next := prev
+	next.Timing.NewAttestationMinutes = 30
+	next.Fees.MinFee = 20
+	next.Rbf.BumpScheduleMinutes = []int{10}
+	assert.Equal(t, []string{
+		"Timing.NewAttestationMinutes: 60 -> 30",
+		"Fees.MinFee: 10 -> 20",
+		"Rbf.BumpScheduleMinutes: [] -> [10]",
+	}, reloadChanges(prev, next))
+}
+
+// Test reloaded config is applied once, keeping the signer url
+func TestAttestServiceReload(t *testing.T) {
+	config, configErr := confpkg.NewConfig(reloadTestConf)
+	assert.Equal(t, nil, configErr)
+	attester := &AttestClient{Fees: NewAttestFees(config.FeesConfig(), config.RbfConfig())}
+	attestService := &AttestService{config: config, attester: attester, isRegtest: true,
+		reload: make(chan ReloadConfig, 1)}
+	attestService.setTimingConfig(config.TimingConfig())
+	attestService.setSignerConfig(config.SignerConfig())
+
+	// nothing pending
+	attestService.applyReload()
+	assert.Equal(t, 60*time.Minute, attestService.atimeNewAttestation)
+
+	// latest pending reload is applied
+	reloadConfig := currentReloadConfig(config)
+	reloadConfig.Timing.NewAttestationMinutes = 10
+	attestService.Reload(reloadConfig)
+	reloadConfig.Timing.NewAttestationMinutes = 30
+	reloadConfig.Fees.MinFee = 25
+	reloadConfig.Signer.Retries = 5
+	reloadConfig.Signer.Url = "localhost:6000"
+	attestService.Reload(reloadConfig)
+
+	attestService.applyReload()
+	assert.Equal(t, 30*time.Minute, attestService.atimeNewAttestation)
+	assert.Equal(t, 5, attestService.maxSignerRetries)
+	assert.Equal(t, 25, attestService.attester.Fees.GetFee())
+	assert.Equal(t, 30, config.TimingConfig().NewAttestationMinutes)
+	assert.Equal(t, 25, config.FeesConfig().MinFee)
+	assert.Equal(t, "localhost:5000", config.SignerConfig().Url)
+	assert.Equal(t, 0, len(attestService.reload))
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"mainstay/log"
+	"mainstay/models"
+)
+
+// Reorgs of the main chain can drop the block confirming the latest
+// attestation. Before each new attestation the block of the latest confirmed
+// attestation is checked, and attestations dropped from the chain are marked
+// unconfirmed in the server, re-broadcast if not back in the mempool and
+// awaited again, handled as unconfirmed if not confirmed in time, instead of
+// building the staychain on a stale confirmation. Attestations confirmed in
+// another block of the new chain have their block info updated
+
+// reorg warning consts
+const (
+	WarningAttestationReorged     = "Attestation block reorged - awaiting confirmation"
+	WarningAttestationReconfirmed = "Attestation block reorged - confirmed in another block"
+	WarningReorgRebroadcastFailed = "Could not re-broadcast reorged attestation"
+)
+
+// part of AStateNextCommitment
+// check that the latest confirmed attestation is still in the block that
+// confirmed it, updating the block info if confirmed in another block
+// Returns true if the attestation was dropped from the chain and the state
+// is set to await its confirmation again, or on failure
+func (s *AttestService) checkReorg() bool {
+	if s.attestation == nil || !s.attestation.Confirmed || s.attestation.Info.Blockhash == "" ||
+		s.attestation.Txid.String() == s.attester.txid0 {
+		return false
+	}
+	tx, txErr := s.attester.Chain.GetTransaction(&s.attestation.Txid)
+	if s.setFailure(txErr) {
+		return true // will rebound to init
+	} else if tx.BlockHash == s.attestation.Info.Blockhash {
+		return false
+	}
+	reorgedBlock := s.attestation.Info.Blockhash
+
+	// confirmed in another block of the new chain
+	if tx.BlockHash != "" {
+		height, heightErr := s.attester.getConfirmationHeight(tx)
+		if s.setFailure(heightErr) {
+			return true // will rebound to init
+		}
+		s.attestation.UpdateInfo(tx, height)
+		if s.setFailure(s.server.UpdateLatestAttestation(*s.attestation)) {
+			return true // will rebound to init
+		}
+		s.attestationLogger().WithFields(log.Fields{log.FieldBlockhash: reorgedBlock}).Warnln(
+			WarningAttestationReconfirmed)
+		s.notify(models.AttestationEventReorged, reorgedBlock)
+		return false
+	}
+
+	// dropped from the chain
+	s.attestation.Confirmed = false
+	s.attestation.Info = models.AttestationInfo{}
+	if s.setFailure(s.server.UpdateReorgedAttestation(*s.attestation)) {
+		return true // will rebound to init
+	}
+	s.attestationLogger().WithFields(log.Fields{log.FieldBlockhash: reorgedBlock}).Warnln(
+		WarningAttestationReorged)
+	s.notify(models.AttestationEventReorged, reorgedBlock)
+
+	// reorged transactions are usually returned to the mempool by the node
+	if _, entryErr := s.attester.Chain.GetMempoolEntry(s.attestation.Txid.String()); entryErr != nil &&
+		len(s.attestation.Tx.TxIn) > 0 {
+		if _, sendErr := s.attester.Chain.SendRawTransaction(&s.attestation.Tx, false); sendErr != nil {
+			s.attestationLogger().WithFields(log.Fields{log.FieldError: sendErr}).Warnln(
+				WarningReorgRebroadcastFailed)
+		}
+	}
+
+	s.confirmTime = clock.Now()
+	s.isFeeBumped = false
+	s.feeBumps = 0
+	s.state = AStateAwaitConfirmation // update attestation state
+	s.attestDelay = s.atimeConfirmation
+	return true
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Return reorged events notified
+func reorgedEvents(notifier *notifierFake) []models.AttestationEvent {
+	var events []models.AttestationEvent
+	for _, event := range notifier.events {
+		if event.Event == models.AttestationEventReorged {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// Return stored attestation info of the transaction
+func storedInfo(h *cycleHarness, txid chainhash.Hash) models.AttestationInfo {
+	for _, info := range h.dbFake.AttestationsInfo {
+		if info.Txid == txid.String() {
+			return info
+		}
+	}
+	return models.AttestationInfo{}
+}
+
+// Test attestations confirmed again in another block after a reorg
+// have the block info updated before the next attestation
+func TestAttestServiceReorgReconfirmed(t *testing.T) {
+	h, restore := newCycleHarness(t, `,
+        "timing": {
+            "newAttestationMinutes": "60",
+            "startupDelaySeconds": "0"
+        }`)
+	defer restore()
+	notifier := &notifierFake{}
+	h.service.AddNotifier(notifier)
+
+	// reorg the block of the first attestation, mined again in the next block
+	var txid chainhash.Hash
+	var reorgedBlock string
+	h.clock.AfterFunc(35*time.Minute, func() {
+		confirmed := h.confirmed()
+		assert.Equal(t, 1, len(confirmed))
+		txid = confirmed[0].Txid
+		reorgedBlock = confirmed[0].Info.Blockhash
+		h.chain.reorg(h.chain.heights[txid] - 1)
+	})
+	h.run(3 * time.Hour)
+
+	events := reorgedEvents(notifier)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, txid.String(), events[0].Txid)
+	assert.Equal(t, reorgedBlock, events[0].Blockhash)
+
+	tx, _ := h.chain.GetTransaction(&txid)
+	assert.NotEqual(t, reorgedBlock, tx.BlockHash)
+	assert.Equal(t, tx.BlockHash, storedInfo(h, txid).Blockhash)
+	assert.Equal(t, h.chain.heights[txid], storedInfo(h, txid).Height)
+	assert.True(t, len(h.confirmed()) >= 3, fmt.Sprintf("%d confirmed attestations", len(h.confirmed())))
+}
+
+// Test attestations dropped from the chain by a reorg are marked
+// unconfirmed and awaited again before the next attestation
+func TestAttestServiceReorgDropped(t *testing.T) {
+	h, restore := newCycleHarness(t, `,
+        "timing": {
+            "newAttestationMinutes": "60",
+            "startupDelaySeconds": "0"
+        }`)
+	defer restore()
+	notifier := &notifierFake{}
+	h.service.AddNotifier(notifier)
+
+	// reorg the block of the first attestation, not mined again until later
+	var txid chainhash.Hash
+	h.clock.AfterFunc(35*time.Minute, func() {
+		txid = h.confirmed()[0].Txid
+		h.chain.reorg(h.chain.heights[txid] - 1)
+		h.chain.minFeeRate = 1 << 40
+	})
+	h.clock.AfterFunc(70*time.Minute, func() {
+		assert.Equal(t, AStateAwaitConfirmation, h.service.state)
+		assert.Equal(t, 0, len(h.confirmed()))
+		assert.Equal(t, "", storedInfo(h, txid).Blockhash)
+		assert.Equal(t, 1, len(reorgedEvents(notifier)))
+		h.chain.minFeeRate = 0
+	})
+	h.run(3 * time.Hour)
+
+	// confirmed again and the staychain continues from the attestation
+	confirmed := h.confirmed()
+	assert.True(t, len(confirmed) >= 2, fmt.Sprintf("%d confirmed attestations", len(confirmed)))
+	assert.Equal(t, txid, confirmed[0].Txid)
+	assert.Equal(t, txid, confirmed[1].Tx.TxIn[0].PreviousOutPoint.Hash)
+	tx, _ := h.chain.GetTransaction(&txid)
+	assert.Equal(t, tx.BlockHash, storedInfo(h, txid).Blockhash)
+	assert.Equal(t, 1, len(reorgedEvents(notifier)))
+}
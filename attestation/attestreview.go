@@ -0,0 +1,144 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/log"
+	"mainstay/models"
+)
+
+// Review windows hold each new signed attestation for a configured time
+// before broadcast. The attestation pending review can be inspected and
+// vetoed by an operator through the admin api, while attestations that
+// are not vetoed are broadcast once the review window has elapsed
+
+// review consts
+const (
+	ATimeReview = 30 * time.Second // waiting time between veto checks during the review window
+
+	ErrorReviewNotPending   = "No attestation pending review"
+	ErrorReviewTxidMismatch = "Attestation pending review has a different txid"
+
+	WarningReviewVetoed           = "Attestation vetoed by operator - broadcast aborted"
+	WarningInvalidReviewWindowArg = "Invalid review window config value"
+)
+
+// review round status
+type ReviewStatus int
+
+// review round status values
+const (
+	ReviewPending  ReviewStatus = 0
+	ReviewApproved ReviewStatus = 1
+	ReviewVetoed   ReviewStatus = 2
+)
+
+// AttestReview struct
+// Holds the attestation of the current review window, which is
+// accessed by the attestation service and the admin api
+type AttestReview struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	pending *models.AttestationReview
+}
+
+// Return new AttestReview instance from review config
+func NewAttestReview(reviewConfig confpkg.ReviewConfig) *AttestReview {
+	window := time.Duration(reviewConfig.WindowMinutes) * time.Minute
+	log.Infof("Review window set to: %v\n", window)
+	return &AttestReview{window: window}
+}
+
+// Check review window of the attestation provided, starting a new window
+// if the attestation is not pending review, and return review status
+// and the time remaining until the end of the window
+func (r *AttestReview) Check(attestation *models.Attestation) (ReviewStatus, time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.pending == nil || r.pending.Txid != attestation.Txid.String() {
+		var txBytes bytes.Buffer
+		if err := attestation.Tx.Serialize(&txBytes); err != nil {
+			return ReviewPending, 0, err
+		}
+		now := clock.Now()
+		r.pending = &models.AttestationReview{
+			Txid:       attestation.Txid.String(),
+			Commitment: attestation.CommitmentHash().String(),
+			Tx:         hex.EncodeToString(txBytes.Bytes()),
+			Start:      now.Unix(),
+			Deadline:   now.Add(r.window).Unix(),
+		}
+	}
+
+	if r.pending.Vetoed {
+		r.pending = nil
+		return ReviewVetoed, 0, nil
+	}
+	remaining := until(time.Unix(r.pending.Deadline, 0))
+	if remaining <= 0 {
+		r.pending = nil
+		return ReviewApproved, 0, nil
+	}
+	return ReviewPending, remaining, nil
+}
+
+// Return attestation pending review and whether there is one
+func (r *AttestReview) Pending() (models.AttestationReview, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.pending == nil {
+		return models.AttestationReview{}, false
+	}
+	return *r.pending, true
+}
+
+// Veto attestation pending review so that it is not broadcast
+// If a txid is provided this must match the attestation pending review
+func (r *AttestReview) Veto(txid string) (models.AttestationReview, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.pending == nil {
+		return models.AttestationReview{}, errors.New(ErrorReviewNotPending)
+	}
+	if txid != "" && txid != r.pending.Txid {
+		return models.AttestationReview{}, errors.New(fmt.Sprintf("%s: %s", ErrorReviewTxidMismatch, r.pending.Txid))
+	}
+	r.pending.Vetoed = true
+	return *r.pending, nil
+}
+
+// Return attestation pending operator review and whether there is one
+func (s *AttestService) PendingReview() (models.AttestationReview, bool) {
+	if s.review == nil {
+		return models.AttestationReview{}, false
+	}
+	return s.review.Pending()
+}
+
+// Veto attestation pending operator review
+// The attestation is aborted on the next review state check
+func (s *AttestService) VetoReview(txid string) (models.AttestationReview, error) {
+	if s.review == nil {
+		return models.AttestationReview{}, errors.New(ErrorReviewNotPending)
+	}
+	review, vetoErr := s.review.Veto(txid)
+	if vetoErr != nil {
+		return models.AttestationReview{}, vetoErr
+	}
+	log.WithFields(log.Fields{log.FieldTxid: review.Txid, log.FieldCommitment: review.Commitment}).Warnln("attestation veto requested")
+	return review, nil
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+// Return attestation with transaction spending outpoint hash for review tests
+func newReviewTestAttestation(prevHash chainhash.Hash) *models.Attestation {
+	commitmentHash, _ := chainhash.NewHashFromStr("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*commitmentHash})
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&prevHash, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{}))
+	attestation := models.NewAttestation(tx.TxHash(), commitment)
+	attestation.Tx = *tx
+	return attestation
+}
+
+// Test review window approval, veto and txid check
+func TestAttestReview(t *testing.T) {
+	review := NewAttestReview(confpkg.ReviewConfig{WindowMinutes: 10})
+	attestation := newReviewTestAttestation(chainhash.Hash{1})
+
+	_, pending := review.Pending()
+	assert.Equal(t, false, pending)
+	_, vetoErr := review.Veto("")
+	assert.Equal(t, errors.New(ErrorReviewNotPending), vetoErr)
+
+	// new review window started
+	status, remaining, checkErr := review.Check(attestation)
+	assert.Equal(t, nil, checkErr)
+	assert.Equal(t, ReviewPending, status)
+	assert.Equal(t, true, remaining > 9*time.Minute && remaining <= 10*time.Minute)
+	pendingReview, pending := review.Pending()
+	assert.Equal(t, true, pending)
+	assert.Equal(t, attestation.Txid.String(), pendingReview.Txid)
+	assert.Equal(t, attestation.CommitmentHash().String(), pendingReview.Commitment)
+	assert.Equal(t, int64(600), pendingReview.Deadline-pendingReview.Start)
+	assert.Equal(t, false, pendingReview.Vetoed)
+
+	// approved once window has elapsed
+	review.pending.Deadline = time.Now().Add(-time.Second).Unix()
+	status, _, checkErr = review.Check(attestation)
+	assert.Equal(t, nil, checkErr)
+	assert.Equal(t, ReviewApproved, status)
+	_, pending = review.Pending()
+	assert.Equal(t, false, pending)
+
+	// veto requires matching txid if provided
+	status, _, _ = review.Check(attestation)
+	assert.Equal(t, ReviewPending, status)
+	_, vetoErr = review.Veto("abc")
+	assert.Equal(t, ErrorReviewTxidMismatch+": "+attestation.Txid.String(), vetoErr.Error())
+	vetoed, vetoErr := review.Veto(attestation.Txid.String())
+	assert.Equal(t, nil, vetoErr)
+	assert.Equal(t, true, vetoed.Vetoed)
+
+	// veto takes precedence over elapsed window
+	review.pending.Deadline = time.Now().Add(-time.Second).Unix()
+	status, _, checkErr = review.Check(attestation)
+	assert.Equal(t, nil, checkErr)
+	assert.Equal(t, ReviewVetoed, status)
+	_, pending = review.Pending()
+	assert.Equal(t, false, pending)
+
+	// different attestation starts a new review window
+	review.Check(attestation)
+	otherAttestation := newReviewTestAttestation(chainhash.Hash{2})
+	status, _, _ = review.Check(otherAttestation)
+	assert.Equal(t, ReviewPending, status)
+	pendingReview, _ = review.Pending()
+	assert.Equal(t, otherAttestation.Txid.String(), pendingReview.Txid)
+}
+
+// Test attest service review state transitions
+func TestAttestServiceReview(t *testing.T) {
+	attestService := &AttestService{state: AStateReviewAttestation,
+		attestation: newReviewTestAttestation(chainhash.Hash{1}), atimeNewAttestation: DefaultATimeNewAttestation}
+	_, pending := attestService.PendingReview()
+	assert.Equal(t, false, pending)
+	_, vetoErr := attestService.VetoReview("")
+	assert.Equal(t, errors.New(ErrorReviewNotPending), vetoErr)
+	assert.Equal(t, AStatePreSendStore, attestService.stateAfterSigning())
+
+	// review disabled after restoring in flight attestation
+	attestService.doStateReviewAttestation()
+	assert.Equal(t, AStatePreSendStore, attestService.state)
+
+	// held while review window open
+	attestService.review = NewAttestReview(confpkg.ReviewConfig{WindowMinutes: 10})
+	assert.Equal(t, AStateReviewAttestation, attestService.stateAfterSigning())
+	attestService.isFeeBumped = true
+	assert.Equal(t, AStatePreSendStore, attestService.stateAfterSigning())
+	attestService.isFeeBumped = false
+
+	attestService.state = AStateReviewAttestation
+	attestService.doStateReviewAttestation()
+	assert.Equal(t, AStateReviewAttestation, attestService.state)
+	assert.Equal(t, ATimeReview, attestService.attestDelay)
+	_, pending = attestService.PendingReview()
+	assert.Equal(t, true, pending)
+
+	// proceeds to send once window has elapsed
+	attestService.review.pending.Deadline = time.Now().Add(-time.Second).Unix()
+	attestService.doStateReviewAttestation()
+	assert.Equal(t, AStatePreSendStore, attestService.state)
+
+	// vetoed attestation is aborted
+	attestService.state = AStateReviewAttestation
+	attestService.doStateReviewAttestation()
+	_, vetoErr = attestService.VetoReview(attestService.attestation.Txid.String())
+	assert.Equal(t, nil, vetoErr)
+	attestService.doStateReviewAttestation()
+	assert.Equal(t, AStateInit, attestService.state)
+	assert.Equal(t, DefaultATimeNewAttestation, attestService.attestDelay)
+	_, pending = attestService.PendingReview()
+	assert.Equal(t, false, pending)
+}
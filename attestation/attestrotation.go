@@ -0,0 +1,488 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"mainstay/crypto"
+	"mainstay/db"
+	"mainstay/log"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+// Key rotations replace the federation multisig redeem script of the
+// staychain. A rotation requested through the admin api is picked up by
+// the next new attestation, the transition attestation, which spends the
+// last attestation of the previous script to an address of the new script.
+// Once the transition attestation confirms the rotation is activated and
+// the new init and topup scripts are used for all following attestations.
+// Rotations are stored in the db, which overrides the keys of the config
+// on startup, and active rotations are published for verifiers
+
+// key rotation error consts
+const (
+	ErrorRotationInProgress    = "Key rotation already in progress"
+	ErrorRotationNotPending    = "No pending key rotation"
+	ErrorRotationScript        = "Invalid key rotation init script"
+	ErrorRotationChaincodes    = "Invalid key rotation chaincodes"
+	ErrorRotationTopupScript   = "Invalid key rotation topup script"
+	ErrorRotationTopupAddress  = "Key rotation topup address does not match topup script"
+	ErrorRotationUnchanged     = "Key rotation init script is already in use"
+	WarningRotationSaveFailed  = "Could not store key rotation"
+	WarningRotationTopupImport = "Could not import key rotation topup address"
+	WarningRotationCpfp        = "Transition attestation can not be bumped with cpfp - awaiting confirmation"
+)
+
+// multisig keys of the attestation client
+type attestClientKeys struct {
+	script0         string
+	pubkeysExtended []*hdkeychain.ExtendedKey
+	pubkeys         []*btcec.PublicKey
+	chaincodes      [][]byte
+	numOfSigs       int
+	addrTopup       string
+	scriptTopup     string
+}
+
+// Lock keys of the attestation client for reading and return the unlock
+func (w *AttestClient) rlockKeys() func() {
+	if w.keysMu == nil {
+		return func() {}
+	}
+	w.keysMu.RLock()
+	return w.keysMu.RUnlock
+}
+
+// Return multisig keys of the attestation client
+func (w *AttestClient) getKeys() attestClientKeys {
+	defer w.rlockKeys()()
+	return w.keys()
+}
+
+// Return multisig keys of the attestation client, with the keys locked
+func (w *AttestClient) keys() attestClientKeys {
+	return attestClientKeys{w.script0, w.pubkeysExtended, w.pubkeys, w.chaincodes,
+		w.numOfSigs, w.addrTopup, w.scriptTopup}
+}
+
+// Return multisig keys of the config, used by attestations before any key rotation
+func (w *AttestClient) getBaseKeys() attestClientKeys {
+	defer w.rlockKeys()()
+	if w.baseKeys != nil {
+		return *w.baseKeys
+	}
+	return w.keys()
+}
+
+// Set multisig keys of the attestation client, keeping the keys
+// replaced on the first change as the keys of the config
+func (w *AttestClient) setKeys(keys attestClientKeys) {
+	if w.keysMu != nil {
+		w.keysMu.Lock()
+		defer w.keysMu.Unlock()
+	}
+	if w.baseKeys == nil {
+		base := w.keys()
+		w.baseKeys = &base
+	}
+	w.script0 = keys.script0
+	w.pubkeysExtended = keys.pubkeysExtended
+	w.pubkeys = keys.pubkeys
+	w.chaincodes = keys.chaincodes
+	w.numOfSigs = keys.numOfSigs
+	w.addrTopup = keys.addrTopup
+	w.scriptTopup = keys.scriptTopup
+}
+
+// multisig keys in use from the transition attestation of a
+// key rotation, confirmed at the height of the rotation
+type rotationKeySet struct {
+	height int64
+	txid   string
+	keys   attestClientKeys
+}
+
+// keys of the config followed by the keys of each active key rotation
+type attestKeyHistory []rotationKeySet
+
+// Return key history of the attestation client for the key rotations stored
+// Rotations activated before their height was stored are placed at the
+// block height of their transition attestation returned by getHeight
+func (w *AttestClient) keyHistory(rotations []models.KeyRotation,
+	getHeight func(txid string) (int64, error)) (attestKeyHistory, error) {
+
+	history := attestKeyHistory{{keys: w.getBaseKeys()}}
+	for _, rotation := range rotations {
+		if rotation.Status != models.KeyRotationActive {
+			continue
+		}
+		keys, keysErr := newRotationKeys(rotation, w.MainChainCfg)
+		if keysErr != nil {
+			return nil, keysErr
+		}
+		height := rotation.Height
+		if height == 0 {
+			var heightErr error
+			if height, heightErr = getHeight(rotation.Txid); heightErr != nil {
+				return nil, heightErr
+			}
+		}
+		history = append(history, rotationKeySet{height, rotation.Txid, keys})
+	}
+	return history, nil
+}
+
+// Return block height of the stored attestation with the txid, zero if not found
+func (s *AttestServer) attestationHeight(txid string) (int64, error) {
+	hash, hashErr := chainhash.NewHashFromStr(txid)
+	if hashErr != nil {
+		return 0, nil
+	}
+	attestation, attestationErr := s.GetAttestation(*hash)
+	return attestation.BlockHeight, attestationErr
+}
+
+// Return block height of the staychain transaction with the txid, zero if not indexed
+func staychainTxHeight(dbInterface db.Db) func(string) (int64, error) {
+	return func(txid string) (int64, error) {
+		tx, txErr := dbInterface.GetStaychainTx(txid)
+		return tx.Height, txErr
+	}
+}
+
+// Return keys of the attestation with the txid confirmed at the height, i.e.
+// the keys of the rotation with this transition attestation or of the last
+// rotation confirmed at or below the height. Attestations of unknown
+// height, zero, use the keys of the config
+func (h attestKeyHistory) keysAt(txid string, height int64) attestClientKeys {
+	keys := h[0].keys
+	for _, set := range h[1:] {
+		if set.txid == txid {
+			return set.keys
+		} else if height > 0 && set.height > 0 && height >= set.height {
+			keys = set.keys
+		}
+	}
+	return keys
+}
+
+// Return multisig keys of the key rotation, verifying the scripts,
+// chaincodes and topup address of the rotation are valid
+func newRotationKeys(rotation models.KeyRotation, chainCfg *chaincfg.Params) (attestClientKeys, error) {
+	scriptBytes, scriptErr := hex.DecodeString(rotation.InitScript)
+	if scriptErr != nil || rotation.InitScript == "" {
+		return attestClientKeys{}, errors.New(ErrorRotationScript)
+	}
+	if !isMultisigScript(scriptBytes, chainCfg) {
+		return attestClientKeys{}, errors.New(ErrorRotationScript)
+	}
+	pubkeys, numOfSigs := crypto.ParseRedeemScript(rotation.InitScript)
+
+	if len(rotation.InitChaincodes) != len(pubkeys) {
+		return attestClientKeys{}, errors.New(fmt.Sprintf("%s %d != %d",
+			ErrorRotationChaincodes, len(rotation.InitChaincodes), len(pubkeys)))
+	}
+	chaincodes := make([][]byte, len(pubkeys))
+	for i_c, chaincodeStr := range rotation.InitChaincodes {
+		chaincode, chaincodeErr := hex.DecodeString(chaincodeStr)
+		if chaincodeErr != nil || len(chaincode) != 32 {
+			return attestClientKeys{}, errors.New(fmt.Sprintf("%s %s", ErrorRotationChaincodes, chaincodeStr))
+		}
+		chaincodes[i_c] = chaincode
+	}
+
+	if rotation.TopupScript != "" {
+		topupBytes, topupErr := hex.DecodeString(rotation.TopupScript)
+		if topupErr != nil || !isMultisigScript(topupBytes, chainCfg) {
+			return attestClientKeys{}, errors.New(ErrorRotationTopupScript)
+		}
+		if _, topupNumOfSigs := crypto.ParseRedeemScript(rotation.TopupScript); topupNumOfSigs != numOfSigs {
+			return attestClientKeys{}, errors.New(fmt.Sprintf("%s. %d != %d",
+				ErrorTopUpScriptNumSigs, numOfSigs, topupNumOfSigs))
+		}
+		topupAddr, topupAddrErr := btcutil.NewAddressScriptHash(topupBytes, chainCfg)
+		if topupAddrErr != nil || topupAddr.String() != rotation.TopupAddress {
+			return attestClientKeys{}, errors.New(ErrorRotationTopupAddress)
+		}
+	} else if rotation.TopupAddress != "" {
+		return attestClientKeys{}, errors.New(ErrorRotationTopupAddress)
+	}
+
+	return attestClientKeys{rotation.InitScript, newExtendedPubkeys(pubkeys, chaincodes), pubkeys, chaincodes,
+		numOfSigs, rotation.TopupAddress, rotation.TopupScript}, nil
+}
+
+// Return whether the script is a valid multisig script, checked before
+// parsing the script with crypto.ParseRedeemScript which exits on failure
+func isMultisigScript(script []byte, chainCfg *chaincfg.Params) bool {
+	class, addrs, numOfSigs, extractErr := txscript.ExtractPkScriptAddrs(script, chainCfg)
+	return extractErr == nil && class == txscript.MultiSigTy && len(addrs) > 0 && numOfSigs > 0
+}
+
+// AttestRotation struct
+// Holds the keys of the config and the key rotation in progress, which
+// is requested through the admin api and progressed by the attestation service
+type AttestRotation struct {
+	base     attestClientKeys
+	chainCfg *chaincfg.Params
+
+	mu      sync.Mutex
+	current *models.KeyRotation
+}
+
+// Return new AttestRotation instance for the keys of the attestation client
+func NewAttestRotation(attester *AttestClient) *AttestRotation {
+	return &AttestRotation{base: attester.getKeys(), chainCfg: attester.MainChainCfg}
+}
+
+// Return key rotation in progress and whether there is one
+func (r *AttestRotation) Current() (models.KeyRotation, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.current == nil {
+		return models.KeyRotation{}, false
+	}
+	return *r.current, true
+}
+
+// Return attestation client keys of the key rotation in progress, if any
+func (r *AttestRotation) currentKeys() (attestClientKeys, bool) {
+	rotation, ok := r.Current()
+	if !ok {
+		return attestClientKeys{}, false
+	}
+	keys, keysErr := newRotationKeys(rotation, r.chainCfg)
+	if keysErr != nil {
+		return attestClientKeys{}, false
+	}
+	return keys, true
+}
+
+// Update key rotation in progress, clearing it once activated or cancelled
+func (r *AttestRotation) setCurrent(rotation models.KeyRotation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if rotation.InProgress() {
+		r.current = &rotation
+	} else {
+		r.current = nil
+	}
+}
+
+// Load key rotations stored in the server and set the attestation client
+// keys to those of the last active rotation, or to the keys of the config
+func (s *AttestService) loadKeyRotations() error {
+	if s.rotation == nil {
+		return nil
+	}
+	rotations, rotationsErr := s.server.GetKeyRotations()
+	if rotationsErr != nil {
+		return rotationsErr
+	}
+	keys := s.rotation.base
+	var current models.KeyRotation
+	for _, rotation := range rotations {
+		if rotation.Status == models.KeyRotationActive {
+			activeKeys, keysErr := newRotationKeys(rotation, s.rotation.chainCfg)
+			if keysErr != nil {
+				return keysErr
+			}
+			keys = activeKeys
+		} else if rotation.InProgress() {
+			current = rotation
+		}
+	}
+	s.attester.setKeys(keys)
+	s.applySignerSet()
+	s.rotation.setCurrent(current)
+	return nil
+}
+
+// Return attestation client for the next attestation, using the keys
+// of the key rotation in progress if any, so that the next attestation
+// pays to an address of the new script
+func (s *AttestService) nextAttester() *AttestClient {
+	if s.rotation == nil {
+		return s.attester
+	}
+	keys, ok := s.rotation.currentKeys()
+	if !ok {
+		return s.attester
+	}
+	rotated := *s.attester
+	rotated.setKeys(keys)
+	return &rotated
+}
+
+// Return whether the transaction is the transition attestation of the key
+// rotation in progress, paying to the address of the new script
+func (s *AttestService) isRotationTx(tx *wire.MsgTx, hash chainhash.Hash) bool {
+	if s.rotation == nil {
+		return false
+	}
+	next := s.nextAttester()
+	if next == s.attester {
+		return false
+	}
+	txAddr, txAddrErr := next.getTxOutAddr(tx)
+	if txAddrErr != nil {
+		return false
+	}
+	addr, addrErr := next.getAttestationAddr(hash)
+	return addrErr == nil && addr == txAddr
+}
+
+// Start transition of the key rotation in progress, if the rotation has
+// not started yet, so that it can no longer be cancelled
+func (s *AttestService) startKeyRotation() error {
+	if s.rotation == nil {
+		return nil
+	}
+	rotation, ok := s.rotation.Current()
+	if !ok || rotation.Status != models.KeyRotationPending {
+		return nil
+	}
+	rotation.Status = models.KeyRotationTransition
+	rotation.PrevScript = s.attester.script0
+	if err := s.server.RecordKeyRotation(rotation); err != nil {
+		return err
+	}
+	s.rotation.setCurrent(rotation)
+	s.logger().WithFields(log.Fields{log.FieldRotation: rotation.Id}).Infoln("key rotation transition started")
+	return nil
+}
+
+// Record the transition attestation of the key rotation in progress if the
+// attestation pays to the new script, and activate the rotation once the
+// attestation is confirmed, switching the client to the new keys
+func (s *AttestService) updateKeyRotation(attestation *models.Attestation) error {
+	if !s.isRotationTx(&attestation.Tx, attestation.CommitmentHash()) {
+		return nil
+	}
+	rotation, _ := s.rotation.Current()
+	if rotation.Status == models.KeyRotationPending {
+		rotation.PrevScript = s.attester.script0
+	}
+	rotation.Status = models.KeyRotationTransition
+	rotation.Txid = attestation.Txid.String()
+	rotation.Commitment = attestation.CommitmentHash().String()
+	if attestation.Confirmed {
+		rotation.Status = models.KeyRotationActive
+		rotation.Activated = time.Now().Unix()
+		rotation.Height = attestation.Info.Height
+	}
+	if err := s.server.RecordKeyRotation(rotation); err != nil {
+		return err
+	}
+
+	if attestation.Confirmed {
+		s.attester.setKeys(s.nextAttester().getKeys())
+		s.applySignerSet()
+		s.logger().WithFields(log.Fields{log.FieldRotation: rotation.Id, log.FieldTxid: rotation.Txid}).Infoln("key rotation activated")
+		if s.attester.addrTopup != "" {
+			importErr := s.attester.Chain.ImportAddressRescan(s.attester.addrTopup, "", false)
+			if importErr != nil {
+				log.Warnf("%s (%s)\n%v\n", WarningRotationTopupImport, s.attester.addrTopup, importErr)
+			}
+		}
+	} else {
+		s.logger().WithFields(log.Fields{log.FieldRotation: rotation.Id, log.FieldTxid: rotation.Txid}).Infoln("key rotation transition attestation")
+	}
+	s.rotation.setCurrent(rotation)
+	return nil
+}
+
+// Request rotation of the federation keys to the init script and topup of
+// the rotation provided, picked up by the next new attestation
+func (s *AttestService) RequestKeyRotation(request models.KeyRotation) (models.KeyRotation, error) {
+	if s.rotation == nil {
+		return models.KeyRotation{}, errors.New(ErrorRotationNotPending)
+	}
+	if _, keysErr := newRotationKeys(request, s.rotation.chainCfg); keysErr != nil {
+		return models.KeyRotation{}, keysErr
+	}
+
+	s.rotation.mu.Lock()
+	defer s.rotation.mu.Unlock()
+	if s.rotation.current != nil {
+		return models.KeyRotation{}, errors.New(ErrorRotationInProgress)
+	}
+	rotations, rotationsErr := s.server.GetKeyRotations()
+	if rotationsErr != nil {
+		return models.KeyRotation{}, rotationsErr
+	}
+	var lastId int64
+	activeScript := s.rotation.base.script0
+	for _, rotation := range rotations {
+		if rotation.InProgress() {
+			return models.KeyRotation{}, errors.New(ErrorRotationInProgress)
+		} else if rotation.Status == models.KeyRotationActive {
+			activeScript = rotation.InitScript
+		}
+		if rotation.Id > lastId {
+			lastId = rotation.Id
+		}
+	}
+	if request.InitScript == activeScript {
+		return models.KeyRotation{}, errors.New(ErrorRotationUnchanged)
+	}
+
+	rotation := models.KeyRotation{
+		Id:             lastId + 1,
+		InitScript:     request.InitScript,
+		InitChaincodes: request.InitChaincodes,
+		TopupAddress:   request.TopupAddress,
+		TopupScript:    request.TopupScript,
+		Status:         models.KeyRotationPending,
+		Requested:      time.Now().Unix(),
+	}
+	if err := s.server.RecordKeyRotation(rotation); err != nil {
+		return models.KeyRotation{}, err
+	}
+	s.rotation.current = &rotation
+	log.WithFields(log.Fields{log.FieldRotation: rotation.Id}).Warnln("key rotation requested")
+	return rotation, nil
+}
+
+// Cancel key rotation that is pending and has not started its transition
+func (s *AttestService) CancelKeyRotation() (models.KeyRotation, error) {
+	if s.rotation == nil {
+		return models.KeyRotation{}, errors.New(ErrorRotationNotPending)
+	}
+
+	s.rotation.mu.Lock()
+	defer s.rotation.mu.Unlock()
+	if s.rotation.current == nil || s.rotation.current.Status != models.KeyRotationPending {
+		return models.KeyRotation{}, errors.New(ErrorRotationNotPending)
+	}
+	rotation := *s.rotation.current
+	rotation.Status = models.KeyRotationCancelled
+	if err := s.server.RecordKeyRotation(rotation); err != nil {
+		return models.KeyRotation{}, err
+	}
+	s.rotation.current = nil
+	log.WithFields(log.Fields{log.FieldRotation: rotation.Id}).Warnln("key rotation cancelled")
+	return rotation, nil
+}
+
+// Return key rotations stored in the server, oldest first
+func (s *AttestService) KeyRotations() ([]models.KeyRotation, error) {
+	return s.server.GetKeyRotations()
+}
@@ -0,0 +1,321 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"mainstay/crypto"
+	"mainstay/db"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/stretchr/testify/assert"
+)
+
+// Return key rotation to a 1 of 2 multisig of new keys with the same topup script
+func newTestKeyRotation() models.KeyRotation {
+	var pubs []*btcec.PublicKey
+	var chaincodes []string
+	for _, seed := range []string{"101112131415161718191a1b1c1d1e1f", "1f1e1d1c1b1a19181716151413121110"} {
+		seedBytes, _ := hex.DecodeString(seed)
+		xprv, _ := hdkeychain.NewMaster(seedBytes, &chaincfg.RegressionNetParams)
+		xpub, _ := xprv.Neuter()
+		pub, _ := xpub.ECPubKey()
+		pubs = append(pubs, pub)
+		chaincodes = append(chaincodes, hex.EncodeToString(crypto.GetExtendedKeyChaincode(xpub)))
+	}
+	addr, script := crypto.CreateMultisig(pubs, 1, &chaincfg.RegressionNetParams)
+	return models.KeyRotation{InitScript: script, InitChaincodes: chaincodes,
+		TopupAddress: addr.String(), TopupScript: script}
+}
+
+// Test key rotation scripts, chaincodes and topup are validated
+func TestNewRotationKeys(t *testing.T) {
+	rotation := newTestKeyRotation()
+	keys, keysErr := newRotationKeys(rotation, &chaincfg.RegressionNetParams)
+	assert.Equal(t, nil, keysErr)
+	assert.Equal(t, rotation.InitScript, keys.script0)
+	assert.Equal(t, 1, keys.numOfSigs)
+	assert.Equal(t, 2, len(keys.pubkeys))
+	assert.Equal(t, 2, len(keys.pubkeysExtended))
+	assert.Equal(t, rotation.TopupAddress, keys.addrTopup)
+
+	// topup is optional
+	noTopup := rotation
+	noTopup.TopupAddress = ""
+	noTopup.TopupScript = ""
+	_, keysErr = newRotationKeys(noTopup, &chaincfg.RegressionNetParams)
+	assert.Equal(t, nil, keysErr)
+
+	invalid := rotation
+	invalid.InitScript = "zz"
+	_, keysErr = newRotationKeys(invalid, &chaincfg.RegressionNetParams)
+	assert.Equal(t, ErrorRotationScript, keysErr.Error())
+
+	invalid = rotation
+	invalid.InitScript = "76a914"
+	_, keysErr = newRotationKeys(invalid, &chaincfg.RegressionNetParams)
+	assert.Equal(t, ErrorRotationScript, keysErr.Error())
+
+	invalid = rotation
+	invalid.InitChaincodes = rotation.InitChaincodes[:1]
+	_, keysErr = newRotationKeys(invalid, &chaincfg.RegressionNetParams)
+	assert.Equal(t, ErrorRotationChaincodes+" 1 != 2", keysErr.Error())
+
+	invalid = rotation
+	invalid.InitChaincodes = []string{rotation.InitChaincodes[0], "abcd"}
+	_, keysErr = newRotationKeys(invalid, &chaincfg.RegressionNetParams)
+	assert.Equal(t, ErrorRotationChaincodes+" abcd", keysErr.Error())
+
+	invalid = rotation
+	invalid.TopupScript = "zz"
+	_, keysErr = newRotationKeys(invalid, &chaincfg.RegressionNetParams)
+	assert.Equal(t, ErrorRotationTopupScript, keysErr.Error())
+
+	invalid = rotation
+	invalid.TopupAddress = ""
+	_, keysErr = newRotationKeys(invalid, &chaincfg.RegressionNetParams)
+	assert.Equal(t, ErrorRotationTopupAddress, keysErr.Error())
+
+	invalid = noTopup
+	invalid.TopupAddress = rotation.TopupAddress
+	_, keysErr = newRotationKeys(invalid, &chaincfg.RegressionNetParams)
+	assert.Equal(t, ErrorRotationTopupAddress, keysErr.Error())
+}
+
+// Test key rotation requests and cancellations
+func TestAttestServiceKeyRotationRequest(t *testing.T) {
+	testChain := newInitTestChain(t)
+	dbFake := db.NewDbFake()
+	client := *testChain.client
+	s := &AttestService{attester: &client, server: NewAttestServer(dbFake), rotation: NewAttestRotation(&client)}
+
+	// rotation to the keys in use is rejected
+	_, requestErr := s.RequestKeyRotation(models.KeyRotation{InitScript: client.script0,
+		InitChaincodes: []string{hex.EncodeToString(client.chaincodes[0]), hex.EncodeToString(client.chaincodes[1])}})
+	assert.Equal(t, ErrorRotationUnchanged, requestErr.Error())
+
+	_, cancelErr := s.CancelKeyRotation()
+	assert.Equal(t, ErrorRotationNotPending, cancelErr.Error())
+
+	rotation, requestErr := s.RequestKeyRotation(newTestKeyRotation())
+	assert.Equal(t, nil, requestErr)
+	assert.Equal(t, int64(1), rotation.Id)
+	assert.Equal(t, models.KeyRotationPending, rotation.Status)
+	assert.NotEqual(t, int64(0), rotation.Requested)
+
+	_, requestErr = s.RequestKeyRotation(newTestKeyRotation())
+	assert.Equal(t, ErrorRotationInProgress, requestErr.Error())
+
+	cancelled, cancelErr := s.CancelKeyRotation()
+	assert.Equal(t, nil, cancelErr)
+	assert.Equal(t, models.KeyRotationCancelled, cancelled.Status)
+	_, inProgress := s.rotation.Current()
+	assert.Equal(t, false, inProgress)
+
+	rotation, requestErr = s.RequestKeyRotation(newTestKeyRotation())
+	assert.Equal(t, nil, requestErr)
+	assert.Equal(t, int64(2), rotation.Id)
+
+	// pending rotations can not be cancelled once the transition started
+	assert.Equal(t, nil, s.startKeyRotation())
+	_, cancelErr = s.CancelKeyRotation()
+	assert.Equal(t, ErrorRotationNotPending, cancelErr.Error())
+
+	rotations, _ := dbFake.GetKeyRotations()
+	assert.Equal(t, 2, len(rotations))
+	assert.Equal(t, models.KeyRotationCancelled, rotations[0].Status)
+	assert.Equal(t, models.KeyRotationTransition, rotations[1].Status)
+	assert.Equal(t, client.script0, rotations[1].PrevScript)
+
+	// service without rotation support rejects requests
+	s = &AttestService{attester: &client, server: NewAttestServer(dbFake)}
+	_, requestErr = s.RequestKeyRotation(newTestKeyRotation())
+	assert.Equal(t, ErrorRotationNotPending, requestErr.Error())
+}
+
+// Test transition attestation paying to the new script is tracked
+// through init and activates the rotation once confirmed
+func TestAttestServiceKeyRotationTransition(t *testing.T) {
+	hash1, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment1, _ := models.NewCommitment([]chainhash.Hash{*hash1})
+	testChain := newInitTestChain(t)
+	base := testChain.base
+
+	dbFake := db.NewDbFake()
+	server := NewAttestServer(dbFake)
+	chain := &initChainFake{txs: map[chainhash.Hash]*wire.MsgTx{base.TxHash(): base}}
+	client := *testChain.client
+	client.Chain = chain
+	baseScript := client.script0
+	s := &AttestService{attester: &client, server: server, signer: &initSignerFake{},
+		isRegtest: true, rotation: NewAttestRotation(&client)}
+
+	request := newTestKeyRotation()
+	_, requestErr := s.RequestKeyRotation(request)
+	assert.Equal(t, nil, requestErr)
+
+	// transition attestation pays to an address of the new script
+	rotated := s.nextAttester()
+	assert.Equal(t, request.InitScript, rotated.script0)
+	assert.Equal(t, baseScript, s.attester.script0)
+	rotatedAddr, _, _ := rotated.GetNextAttestationAddr(nil, commitment1.GetCommitmentHash())
+	tx1 := newInitTestTx(base.TxHash(), rotatedAddr)
+	assert.Equal(t, true, s.isRotationTx(tx1, commitment1.GetCommitmentHash()))
+	assert.Equal(t, false, s.isRotationTx(testChain.attest(t, base.TxHash(), commitment1), commitment1.GetCommitmentHash()))
+
+	// unconfirmed transition attestation recovered from the client commitment
+	chain.confirm(base)
+	chain.send(tx1)
+	dbFake.SetClientCommitments([]models.ClientCommitment{models.ClientCommitment{*hash1, 0, "", 0}})
+	s.state = AStateInit
+	s.doStateInit()
+	assert.Equal(t, nil, s.errorState)
+	assert.Equal(t, AStateAwaitConfirmation, s.state)
+	rotations, _ := dbFake.GetKeyRotations()
+	assert.Equal(t, models.KeyRotationTransition, rotations[0].Status)
+	assert.Equal(t, tx1.TxHash().String(), rotations[0].Txid)
+	assert.Equal(t, commitment1.GetCommitmentHash().String(), rotations[0].Commitment)
+	assert.Equal(t, baseScript, rotations[0].PrevScript)
+	assert.Equal(t, baseScript, s.attester.script0)
+
+	// confirmed transition attestation activates the rotation
+	chain.mempool = nil
+	chain.unspent = nil
+	chain.confirm(tx1)
+	for run := 0; run < 2; run++ {
+		s.state = AStateInit
+		s.doStateInit()
+		assert.Equal(t, nil, s.errorState)
+		assert.Equal(t, AStateNextCommitment, s.state)
+		assert.Equal(t, tx1.TxHash(), s.attestation.Txid)
+		rotations, _ = dbFake.GetKeyRotations()
+		assert.Equal(t, models.KeyRotationActive, rotations[0].Status)
+		assert.NotEqual(t, int64(0), rotations[0].Activated)
+		assert.Equal(t, int64(1), rotations[0].Height)
+		assert.Equal(t, request.InitScript, s.attester.script0)
+		assert.Equal(t, request.TopupAddress, s.attester.addrTopup)
+		_, inProgress := s.rotation.Current()
+		assert.Equal(t, false, inProgress)
+	}
+	assert.Equal(t, []string{request.TopupAddress}, chain.imported)
+
+	// restarted service loads the keys of the active rotation
+	restartClient := *testChain.client
+	restartClient.Chain = chain
+	s = &AttestService{attester: &restartClient, server: server, signer: &initSignerFake{},
+		isRegtest: true, rotation: NewAttestRotation(&restartClient)}
+	s.state = AStateInit
+	s.doStateInit()
+	assert.Equal(t, nil, s.errorState)
+	assert.Equal(t, AStateNextCommitment, s.state)
+	assert.Equal(t, request.InitScript, s.attester.script0)
+	assert.Equal(t, s.attester, s.nextAttester())
+
+	// rotating back to the config keys is allowed once the rotation is active
+	back := models.KeyRotation{InitScript: baseScript,
+		InitChaincodes: []string{hex.EncodeToString(testChain.client.chaincodes[0]), hex.EncodeToString(testChain.client.chaincodes[1])}}
+	rotation, requestErr := s.RequestKeyRotation(back)
+	assert.Equal(t, nil, requestErr)
+	assert.Equal(t, int64(2), rotation.Id)
+	_, requestErr = s.RequestKeyRotation(newTestKeyRotation())
+	assert.Equal(t, ErrorRotationInProgress, requestErr.Error())
+}
+
+// Test topup address of the rotation must match the topup script
+func TestNewRotationKeysTopupAddress(t *testing.T) {
+	rotation := newTestKeyRotation()
+	scriptBytes, _ := hex.DecodeString(rotation.TopupScript)
+	addr, _ := btcutil.NewAddressScriptHash(scriptBytes, &chaincfg.MainNetParams)
+	rotation.TopupAddress = addr.String()
+	_, keysErr := newRotationKeys(rotation, &chaincfg.RegressionNetParams)
+	assert.Equal(t, ErrorRotationTopupAddress, keysErr.Error())
+}
+
+// Return staychain of the attestations of the config keys followed by the
+// transition attestation and an attestation of the rotation keys, confirmed
+// at heights 1 to 4 and stored in the server
+func newRotationTestChain(t *testing.T, client *AttestClient, keys attestClientKeys, server *AttestServer,
+	chain *initChainFake, prevTxid chainhash.Hash) []*wire.MsgTx {
+
+	var txs []*wire.MsgTx
+	for i := 0; i < 4; i++ {
+		hash := chainhash.Hash{byte(i + 1)}
+		commitment, _ := models.NewCommitment([]chainhash.Hash{hash})
+		attestKeys := client.getKeys()
+		if i >= 2 {
+			attestKeys = keys
+		}
+		addr, _, addrErr := attestKeys.attestationAddr(nil, commitment.GetCommitmentHash(), client.MainChainCfg)
+		assert.Equal(t, nil, addrErr)
+		tx := newInitTestTx(prevTxid, addr)
+		chain.confirm(tx)
+		attestation := newInitTestAttestation(tx, commitment, true)
+		attestation.Info.Height = int64(i + 1)
+		assert.Equal(t, nil, server.UpdateLatestAttestation(attestation))
+		txs = append(txs, tx)
+		prevTxid = tx.TxHash()
+	}
+	return txs
+}
+
+// Test attestations are checked with the keys of the key rotation
+// active at the height of each attestation
+func TestAttestIntegrityKeyRotation(t *testing.T) {
+	testChain := newInitTestChain(t)
+	base := testChain.base
+	dbFake := db.NewDbFake()
+	server := NewAttestServer(dbFake)
+	chain := &initChainFake{txs: map[chainhash.Hash]*wire.MsgTx{base.TxHash(): base}}
+	client := *testChain.client
+	client.Chain = chain
+	baseKeys := client.getKeys()
+
+	rotation := newTestKeyRotation()
+	keys, keysErr := newRotationKeys(rotation, client.MainChainCfg)
+	assert.Equal(t, nil, keysErr)
+	txs := newRotationTestChain(t, &client, keys, server, chain, base.TxHash())
+	client.setKeys(keys)
+	assert.Equal(t, baseKeys, client.getBaseKeys())
+
+	// attestations of the rotation keys not checked with the config keys
+	report, checkErr := NewAttestIntegrity(&client, server).Check()
+	assert.Equal(t, nil, checkErr)
+	assert.Equal(t, false, report.Passed)
+	assert.Equal(t, 3, report.Inconsistent.Round)
+	assert.Equal(t, IntegrityReasonAddressMismatch, report.Inconsistent.Reason)
+
+	// active rotation with the height of the transition attestation
+	rotation.Id = 1
+	rotation.Status = models.KeyRotationActive
+	rotation.Txid = txs[2].TxHash().String()
+	rotation.Height = 3
+	assert.Equal(t, nil, server.RecordKeyRotation(rotation))
+	report, checkErr = NewAttestIntegrity(&client, server).Check()
+	assert.Equal(t, nil, checkErr)
+	assert.Equal(t, true, report.Passed)
+	assert.Equal(t, 4, report.Rounds)
+
+	// rotations without height placed at their transition attestation
+	rotation.Height = 0
+	assert.Equal(t, nil, server.RecordKeyRotation(rotation))
+	report, checkErr = NewAttestIntegrity(&client, server).Check()
+	assert.Equal(t, nil, checkErr)
+	assert.Equal(t, true, report.Passed)
+
+	history, historyErr := client.keyHistory([]models.KeyRotation{rotation}, server.attestationHeight)
+	assert.Equal(t, nil, historyErr)
+	assert.Equal(t, baseKeys, history.keysAt(txs[1].TxHash().String(), 2))
+	assert.Equal(t, keys, history.keysAt(txs[2].TxHash().String(), 0))
+	assert.Equal(t, keys, history.keysAt(txs[3].TxHash().String(), 4))
+	assert.Equal(t, baseKeys, history.keysAt(txs[3].TxHash().String(), 0))
+}
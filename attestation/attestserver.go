@@ -5,23 +5,86 @@
 package attestation
 
 import (
+	"time"
+
 	"mainstay/db"
 	"mainstay/models"
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 )
 
+// name of the attestation service state stored in the server
+const ServiceStateNameAttestation = "attestation"
+
 // AttestServer structure
 // Stores information on the latest attestation and commitment
 // Methods to get latest state by attestation service
 type AttestServer struct {
 	// underlying database interface
 	dbInterface db.Db
+
+	// previous round of client commitments read
+	prevRound *attestRound
+
+	// optional cache of client commitment merkle subtrees
+	aggregator *models.CommitmentAggregator
+
+	// ordering of client commitments in the commitment merkle tree
+	ordering models.CommitmentOrdering
+
+	// hash function of new commitment merkle trees
+	hash string
+
+	// optional transition to the next hash function at a switchover round
+	transition *models.ProtocolTransition
 }
 
 // NewAttestServer returns a pointer to an AttestServer instance
 func NewAttestServer(dbInterface db.Db) *AttestServer {
-	return &AttestServer{dbInterface}
+	return &AttestServer{dbInterface, nil, nil, models.CommitmentOrdering{Strategy: models.OrderingPosition},
+		models.MerkleHashSha256d, nil}
+}
+
+// Set ordering of client commitments in the commitment merkle tree
+// The ordering must not change for a staychain, as past commitments are
+// rebuilt with it
+func (s *AttestServer) SetOrdering(ordering models.CommitmentOrdering) {
+	s.ordering = ordering
+}
+
+// Set hash function of new commitment merkle trees
+// The hash function is recorded with the merkle commitments and proofs, so
+// past commitments are rebuilt with the hash function they were built with
+func (s *AttestServer) SetMerkleHash(hash string) {
+	s.hash = hash
+	if s.aggregator != nil {
+		s.aggregator.SetHash(hash)
+	}
+}
+
+// Set transition to the next hash function of new commitment merkle trees,
+// used from the switchover round on
+func (s *AttestServer) SetTransition(transition models.ProtocolTransition) {
+	s.transition = &transition
+}
+
+// Return hash function of the commitment merkle tree of the next attestation
+// The next attestation round is the number of confirmed attestations plus one
+func (s *AttestServer) roundMerkleHash() (string, error) {
+	if s.transition == nil {
+		return s.hash, nil
+	}
+	count, countErr := s.dbInterface.GetConfirmedAttestationCount()
+	if countErr != nil {
+		return "", countErr
+	}
+	return s.transition.MerkleHash(count+1, s.hash), nil
+}
+
+// Set cache of client commitment merkle subtrees used to build commitments
+func (s *AttestServer) SetAggregator(aggregator *models.CommitmentAggregator) {
+	aggregator.SetHash(s.hash)
+	s.aggregator = aggregator
 }
 
 // Handle saving Commitment underlying components to the database
@@ -40,6 +103,19 @@ func (s *AttestServer) updateAttestationCommitment(commitment models.Commitment)
 		return errSave
 	}
 
+	// resolve pending commitment submissions with the attested commitments
+	pending, errPending := s.dbInterface.GetPendingCommitmentSubmissions()
+	if errPending != nil {
+		return errPending
+	}
+	resolved := models.ResolveCommitmentSubmissions(pending, merkleCommitments)
+	if len(resolved) > 0 {
+		errSave = s.dbInterface.SaveCommitmentSubmissions(resolved)
+		if errSave != nil {
+			return errSave
+		}
+	}
+
 	return nil
 }
 
@@ -68,6 +144,80 @@ func (s *AttestServer) UpdateLatestAttestation(attestation models.Attestation) e
 	return nil
 }
 
+// Update attestation dropped from the chain by a reorg in the server,
+// marking it unconfirmed and clearing the block of the attestation info
+func (s *AttestServer) UpdateReorgedAttestation(attestation models.Attestation) error {
+	attestation.Confirmed = false
+	errSave := s.dbInterface.SaveAttestation(attestation)
+	if errSave != nil {
+		return errSave
+	}
+	return s.dbInterface.SaveAttestationInfo(models.AttestationInfo{Txid: attestation.Txid.String()})
+}
+
+// Record fee bump attempt for an unconfirmed attestation in the server
+func (s *AttestServer) RecordFeeBump(feeBump models.FeeBump) error {
+	return s.dbInterface.SaveFeeBump(feeBump)
+}
+
+// Record attestation transaction that was not sent in dry run mode in the server
+func (s *AttestServer) RecordDryRunAttestation(attestation models.DryRunAttestation) error {
+	return s.dbInterface.SaveDryRunAttestation(attestation)
+}
+
+// Update paused flag of the attestation service in the server
+func (s *AttestServer) UpdatePaused(paused bool) error {
+	return s.dbInterface.SaveServiceState(models.ServiceState{
+		Name:   ServiceStateNameAttestation,
+		Paused: paused,
+		Time:   time.Now().Unix(),
+	})
+}
+
+// Return paused flag of the attestation service stored in the server
+func (s *AttestServer) GetPaused() (bool, error) {
+	state, stateErr := s.dbInterface.GetServiceState(ServiceStateNameAttestation)
+	if stateErr != nil {
+		return false, stateErr
+	}
+	return state.Paused, nil
+}
+
+// Record new attestation in progress on shutdown in the server
+func (s *AttestServer) RecordInFlightAttestation(inFlight models.InFlightAttestation) error {
+	return s.dbInterface.SaveInFlightAttestation(inFlight)
+}
+
+// Return new attestation in progress recorded on shutdown in the server
+func (s *AttestServer) GetInFlightAttestation() (models.InFlightAttestation, error) {
+	return s.dbInterface.GetInFlightAttestation()
+}
+
+// Clear new attestation in progress recorded on shutdown in the server
+func (s *AttestServer) ClearInFlightAttestation() error {
+	return s.dbInterface.DeleteInFlightAttestation()
+}
+
+// Record new or updated key rotation in the server
+func (s *AttestServer) RecordKeyRotation(rotation models.KeyRotation) error {
+	return s.dbInterface.SaveKeyRotation(rotation)
+}
+
+// Return key rotations stored in the server, oldest first
+func (s *AttestServer) GetKeyRotations() ([]models.KeyRotation, error) {
+	return s.dbInterface.GetKeyRotations()
+}
+
+// Record signer liveness in the server
+func (s *AttestServer) RecordSignerStatus(status models.SignerStatus) error {
+	return s.dbInterface.SaveSignerStatus(status)
+}
+
+// Return signer liveness stored in the server in redeem script order
+func (s *AttestServer) GetSignerStatuses() ([]models.SignerStatus, error) {
+	return s.dbInterface.GetSignerStatuses()
+}
+
 // Return Commitment hash of latest Attestation stored in the server
 func (s *AttestServer) GetLatestAttestationCommitmentHash(confirmed ...bool) (chainhash.Hash, error) {
 	// optional param to set confirmed flag - looks for confirmed only by default
@@ -91,34 +241,102 @@ func (s *AttestServer) GetLatestAttestationCommitmentHash(confirmed ...bool) (ch
 }
 
 // Return latest commitment stored in the server
+// Reading the commitments closes the attestation round, storing the
+// snapshot of the commitments read and the resulting merkle root
 func (s *AttestServer) GetClientCommitment() (models.Commitment, error) {
+	roundClose := time.Now().UnixMilli()
 
+	latestCommitments, commitment, errCommitment := s.getClientCommitment()
+	if errCommitment != nil {
+		return models.Commitment{}, errCommitment
+	}
+	snapshot := models.NewRoundSnapshot(roundClose, latestCommitments, commitment.GetCommitmentHash())
+	snapshot.Hash = commitment.MerkleHash()
+	if errSave := s.dbInterface.SaveRoundSnapshot(snapshot); errSave != nil {
+		return models.Commitment{}, errSave
+	}
+	s.recordExclusions(s.auditRound(roundClose, latestCommitments, commitment.GetCommitmentHash()))
+
+	// db interface
+	return *commitment, nil
+}
+
+// Return latest commitment stored in the server without closing the attestation round
+func (s *AttestServer) PeekClientCommitment() (models.Commitment, error) {
+	_, commitment, errCommitment := s.getClientCommitment()
+	if errCommitment != nil {
+		return models.Commitment{}, errCommitment
+	}
+	return *commitment, nil
+}
+
+// Return latest client commitments and the commitment constructed from these
+func (s *AttestServer) getClientCommitment() ([]models.ClientCommitment, *models.Commitment, error) {
 	// get latest commitments from db
 	latestCommitments, errLatest := s.dbInterface.GetClientCommitments()
 	if errLatest != nil {
-		return models.Commitment{}, errLatest
-	}
-
-	var commitmentHashes []chainhash.Hash
-	if len(latestCommitments) > 0 {
-		// initialise hash slice with the maximum position returned from the commitment results
-		// asume latestCommitments ordered (ASC) by client position
-		commitmentHashes = make([]chainhash.Hash, latestCommitments[len(latestCommitments)-1].ClientPosition+1)
-		// set commitments in ordered position for resulting slice
-		// missing positions have been initialized to zero hash
-		for _, c := range latestCommitments {
-			commitmentHashes[c.ClientPosition] = c.Commitment
-		}
+		return nil, nil, errLatest
+	}
+	commitmentHashes, positions, requestIds := s.clientCommitmentHashes(latestCommitments)
+	hash, errHash := s.roundMerkleHash()
+	if errHash != nil {
+		return nil, nil, errHash
 	}
 
 	// construct Commitment from MerkleCommitment commitments
-	commitment, errCommitment := models.NewCommitment(commitmentHashes)
+	// reusing the cached subtrees of unchanged commitments if aggregating
+	var commitment *models.Commitment
+	var errCommitment error
+	if s.aggregator != nil {
+		s.aggregator.SetHash(hash)
+		commitment, errCommitment = s.aggregator.Commitment(commitmentHashes)
+	} else {
+		commitment, errCommitment = models.NewCommitmentHash(commitmentHashes, hash)
+	}
 	if errCommitment != nil {
-		return models.Commitment{}, errCommitment
+		return nil, nil, errCommitment
 	}
+	commitment.SetPositions(positions)
+	if len(requestIds) > 0 {
+		commitment.SetRequestIds(requestIds)
+	}
+	return latestCommitments, commitment, nil
+}
 
-	// db interface
-	return *commitment, nil
+// Return client commitment hashes ordered by merkle tree leaf, the client
+// position of each leaf and the request ids of the commitments by position
+// from the latest client commitments, ordered (ASC) by client position
+func (s *AttestServer) clientCommitmentHashes(latestCommitments []models.ClientCommitment) (
+	[]chainhash.Hash, []int32, map[int32]string) {
+
+	commitmentHashes, positions := s.ordering.Leaves(latestCommitments)
+	requestIds := make(map[int32]string)
+	for _, c := range latestCommitments {
+		if c.RequestId != "" {
+			requestIds[c.ClientPosition] = c.RequestId
+		}
+	}
+	return commitmentHashes, positions, requestIds
+}
+
+// Return error if the server database can not be reached
+func (s *AttestServer) Ping() error {
+	return s.dbInterface.Ping()
+}
+
+// Return total fee of attestations confirmed since unix time stored in the server
+func (s *AttestServer) GetAttestationFees(since int64) (int64, error) {
+	return s.dbInterface.GetAttestationFees(since)
+}
+
+// Return confirmed Attestations stored in the server, oldest first
+func (s *AttestServer) GetAttestations() ([]models.AttestationBSON, error) {
+	return s.dbInterface.GetAttestations()
+}
+
+// Return Attestation stored in the server with given txid, empty if not found
+func (s *AttestServer) GetAttestation(txid chainhash.Hash) (models.AttestationBSON, error) {
+	return s.dbInterface.GetAttestation(txid)
 }
 
 // Return Commitment for a particular Attestation transaction id
@@ -139,13 +357,17 @@ func (s *AttestServer) GetAttestationCommitment(attestationTxid chainhash.Hash,
 		}
 	}
 
-	// construct Commitment from MerkleCommitment commitments
-	var commitmentHashes []chainhash.Hash
+	// construct Commitment from MerkleCommitment commitments placed at the
+	// leaves of the server ordering with the hash function they were built with
+	var clientCommitments []models.ClientCommitment
+	var hash string
 	for _, c := range merkleCommitments {
-		commitmentHashes = append(commitmentHashes, c.Commitment)
+		clientCommitments = append(clientCommitments,
+			models.ClientCommitment{Commitment: c.Commitment, ClientPosition: c.ClientPosition})
+		hash = c.Hash
 	}
 
-	commitment, errCommitment := models.NewCommitment(commitmentHashes)
+	commitment, errCommitment := s.ordering.Commitment(clientCommitments, hash)
 	if errCommitment != nil {
 		return models.Commitment{}, errCommitment
 	}
@@ -7,6 +7,7 @@ package attestation
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"mainstay/db"
 	"mainstay/models"
@@ -39,7 +40,7 @@ func TestAttestServerUpdateLatestAttestation_1ClientCommitments(t *testing.T) {
 
 	// set db latest commitment
 	hash0, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
-	latestCommitments := []models.ClientCommitment{models.ClientCommitment{*hash0, 0}}
+	latestCommitments := []models.ClientCommitment{models.ClientCommitment{*hash0, 0, "", 0}}
 	latestCommitment, _ := models.NewCommitment([]chainhash.Hash{*hash0})
 	dbFake.SetClientCommitments(latestCommitments)
 
@@ -127,7 +128,7 @@ func TestAttestServerUpdateLatestAttestation_1ClientCommitments(t *testing.T) {
 	// add an additional unconfirmed attestation
 	// set db latest commitment
 	hash2, _ := chainhash.NewHashFromStr("baaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
-	latestCommitments2 := []models.ClientCommitment{models.ClientCommitment{*hash2, 0}}
+	latestCommitments2 := []models.ClientCommitment{models.ClientCommitment{*hash2, 0, "", 0}}
 	latestCommitment2, _ := models.NewCommitment([]chainhash.Hash{*hash2})
 	dbFake.SetClientCommitments(latestCommitments2)
 
@@ -168,9 +169,9 @@ func TestAttestServerUpdateLatestAttestation_3ClientCommitments(t *testing.T) {
 	hash2, _ := chainhash.NewHashFromStr("caaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
 	hash22, _ := chainhash.NewHashFromStr("e0ae56a5a7eec5de827346ea45dd3d834c006d12e333d0d949aa974dda4928ed")
 	latestCommitments := []models.ClientCommitment{
-		models.ClientCommitment{*hash0, 0},
-		models.ClientCommitment{*hash1, 1},
-		models.ClientCommitment{*hash2, 2}}
+		models.ClientCommitment{*hash0, 0, "", 0},
+		models.ClientCommitment{*hash1, 1, "", 0},
+		models.ClientCommitment{*hash2, 2, "", 0}}
 	latestCommitment, _ := models.NewCommitment([]chainhash.Hash{*hash0, *hash1, *hash2})
 	dbFake.SetClientCommitments(latestCommitments)
 
@@ -289,9 +290,9 @@ func TestAttestServerUpdateLatestAttestation_3ClientCommitments(t *testing.T) {
 	hashY, _ := chainhash.NewHashFromStr("caaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
 	hashZ, _ := chainhash.NewHashFromStr("daaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
 	latestCommitments2 := []models.ClientCommitment{
-		models.ClientCommitment{*hashX, 0},
-		models.ClientCommitment{*hashY, 1},
-		models.ClientCommitment{*hashZ, 2}}
+		models.ClientCommitment{*hashX, 0, "", 0},
+		models.ClientCommitment{*hashY, 1, "", 0},
+		models.ClientCommitment{*hashZ, 2, "", 0}}
 	latestCommitment2, _ := models.NewCommitment([]chainhash.Hash{*hashX, *hashY, *hashZ})
 	dbFake.SetClientCommitments(latestCommitments2)
 
@@ -336,7 +337,7 @@ func TestAttestServerGetClientCommitment(t *testing.T) {
 
 	// update server with incorrect latest commitment and test server
 	latestCommitments := []models.ClientCommitment{
-		models.ClientCommitment{*hash0, 0}, models.ClientCommitment{*hash2, 2}}
+		models.ClientCommitment{*hash0, 0, "", 0}, models.ClientCommitment{*hash2, 2, "", 0}}
 	dbFake.SetClientCommitments(latestCommitments)
 
 	respClientCommitment, err = server.GetClientCommitment()
@@ -347,7 +348,7 @@ func TestAttestServerGetClientCommitment(t *testing.T) {
 
 	// update server with incorrect latest commitment and test server
 	latestCommitments = []models.ClientCommitment{
-		models.ClientCommitment{*hash1, 1}, models.ClientCommitment{*hash2, 2}}
+		models.ClientCommitment{*hash1, 1, "", 0}, models.ClientCommitment{*hash2, 2, "", 0}}
 	dbFake.SetClientCommitments(latestCommitments)
 
 	respClientCommitment, err = server.GetClientCommitment()
@@ -357,7 +358,7 @@ func TestAttestServerGetClientCommitment(t *testing.T) {
 	assert.Equal(t, latestCommitment.GetCommitmentHash(), respClientCommitment.GetCommitmentHash())
 
 	// update server with incorrect latest commitment and test server
-	latestCommitments = []models.ClientCommitment{models.ClientCommitment{*hash2, 2}}
+	latestCommitments = []models.ClientCommitment{models.ClientCommitment{*hash2, 2, "", 0}}
 	dbFake.SetClientCommitments(latestCommitments)
 
 	respClientCommitment, err = server.GetClientCommitment()
@@ -368,9 +369,9 @@ func TestAttestServerGetClientCommitment(t *testing.T) {
 
 	// update server with correct latest commitment and test server
 	latestCommitments = []models.ClientCommitment{
-		models.ClientCommitment{*hash0, 0},
-		models.ClientCommitment{*hash1, 1},
-		models.ClientCommitment{*hash2, 2}}
+		models.ClientCommitment{*hash0, 0, "", 0},
+		models.ClientCommitment{*hash1, 1, "", 0},
+		models.ClientCommitment{*hash2, 2, "", 0}}
 	latestCommitment, err2 = models.NewCommitment([]chainhash.Hash{*hash0, *hash1, *hash2})
 	assert.Equal(t, nil, err2)
 	dbFake.SetClientCommitments(latestCommitments)
@@ -378,6 +379,23 @@ func TestAttestServerGetClientCommitment(t *testing.T) {
 	respClientCommitment, err = server.GetClientCommitment()
 	assert.Equal(t, nil, err)
 	assert.Equal(t, latestCommitment.GetCommitmentHash(), respClientCommitment.GetCommitmentHash())
+	assert.Equal(t, map[int32]string(nil), respClientCommitment.RequestIds())
+
+	// update server with latest commitments set by api requests
+	latestCommitments = []models.ClientCommitment{
+		models.ClientCommitment{*hash0, 0, "request0", 0},
+		models.ClientCommitment{*hash1, 1, "", 0},
+		models.ClientCommitment{*hash2, 2, "request2", 0}}
+	dbFake.SetClientCommitments(latestCommitments)
+
+	respClientCommitment, err = server.GetClientCommitment()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, latestCommitment.GetCommitmentHash(), respClientCommitment.GetCommitmentHash())
+	assert.Equal(t, map[int32]string{0: "request0", 2: "request2"}, respClientCommitment.RequestIds())
+	merkleCommitments := respClientCommitment.GetMerkleCommitments()
+	assert.Equal(t, "request0", merkleCommitments[0].RequestId)
+	assert.Equal(t, "", merkleCommitments[1].RequestId)
+	assert.Equal(t, "request2", merkleCommitments[2].RequestId)
 }
 
 // Test AttestServer GetAttestationCommitment
@@ -405,9 +423,9 @@ func TestAttestServerGetAttestationCommitment(t *testing.T) {
 
 	// update attestation to server
 	latestCommitments0 := []models.ClientCommitment{
-		models.ClientCommitment{*hashX, 0},
-		models.ClientCommitment{*hashY, 1},
-		models.ClientCommitment{*hashZ, 2}}
+		models.ClientCommitment{*hashX, 0, "", 0},
+		models.ClientCommitment{*hashY, 1, "", 0},
+		models.ClientCommitment{*hashZ, 2, "", 0}}
 	dbFake.SetClientCommitments(latestCommitments0)
 	latestCommitment0, _ := models.NewCommitment([]chainhash.Hash{*hashX, *hashY, *hashZ})
 
@@ -434,8 +452,8 @@ func TestAttestServerGetAttestationCommitment(t *testing.T) {
 
 	// add another attestation to server
 	latestCommitments1 := []models.ClientCommitment{
-		models.ClientCommitment{*hashX, 0},
-		models.ClientCommitment{*hashY, 1}}
+		models.ClientCommitment{*hashX, 0, "", 0},
+		models.ClientCommitment{*hashY, 1, "", 0}}
 	dbFake.SetClientCommitments(latestCommitments1)
 	latestCommitment1, _ := models.NewCommitment([]chainhash.Hash{*hashX, *hashY})
 
@@ -483,3 +501,195 @@ func TestAttestServerGetAttestationCommitment(t *testing.T) {
 	commitment, err = server.GetAttestationCommitment(chainhash.Hash{}, false)
 	assert.Equal(t, errors.New(models.ErrorCommitmentListEmpty), err)
 }
+
+// Test commitments submitted before a round close but not read by the round are recorded
+func TestAttestServerCommitmentExclusions(t *testing.T) {
+	dbFake := db.NewDbFake()
+	server := NewAttestServer(dbFake)
+
+	hash0, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash2, _ := chainhash.NewHashFromStr("ccccccc1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	future := time.Now().Add(time.Hour).UnixMilli()
+
+	// first round read since startup is not checked
+	dbFake.SetClientCommitments([]models.ClientCommitment{
+		models.ClientCommitment{*hash0, 0, "", 1}, models.ClientCommitment{*hash1, 1, "", 1}})
+	round1, err := server.GetClientCommitment()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(dbFake.Exclusions))
+
+	// same commitments and commitments submitted after round close are not excluded
+	dbFake.SetClientCommitments([]models.ClientCommitment{
+		models.ClientCommitment{*hash0, 0, "", 1}, models.ClientCommitment{*hash2, 1, "", future}})
+	_, err = server.GetClientCommitment()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(dbFake.Exclusions))
+
+	// commitment submitted before the previous round close but not read by it
+	// and commitment of a new position submitted before the previous round close
+	roundClose := server.prevRound.close
+	dbFake.SetClientCommitments([]models.ClientCommitment{
+		models.ClientCommitment{*hash1, 0, "", 2}, models.ClientCommitment{*hash2, 1, "", future},
+		models.ClientCommitment{*hash0, 2, "", 2}})
+	_, err = server.GetClientCommitment()
+	assert.Equal(t, nil, err)
+	round2Root, _ := models.NewCommitment([]chainhash.Hash{*hash0, *hash2})
+	assert.Equal(t, []models.CommitmentExclusion{
+		models.CommitmentExclusion{0, hash1.String(), 2, roundClose, round2Root.GetCommitmentHash().String(),
+			hash0.String(), models.ExclusionReasonMissed},
+		models.CommitmentExclusion{2, hash0.String(), 2, roundClose, round2Root.GetCommitmentHash().String(),
+			"", models.ExclusionReasonMissed},
+	}, dbFake.Exclusions)
+	assert.NotEqual(t, round1.GetCommitmentHash(), round2Root.GetCommitmentHash())
+
+	// exclusions only recorded once
+	_, err = server.GetClientCommitment()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(dbFake.Exclusions))
+}
+
+// Test AttestServer stores a snapshot of the commitments read by each round
+func TestAttestServerRoundSnapshots(t *testing.T) {
+	dbFake := db.NewDbFake()
+	server := NewAttestServer(dbFake)
+
+	hash0, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash2, _ := chainhash.NewHashFromStr("ccccccc1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	// no snapshot of rounds failing to read commitments
+	_, err := server.GetClientCommitment()
+	assert.Equal(t, errors.New(models.ErrorCommitmentListEmpty), err)
+	assert.Equal(t, 0, len(dbFake.RoundSnapshots))
+
+	dbFake.SetClientCommitments([]models.ClientCommitment{
+		models.ClientCommitment{*hash0, 0, "", 1}, models.ClientCommitment{*hash2, 2, "", 1}})
+	commitment, err := server.GetClientCommitment()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []models.RoundSnapshot{models.RoundSnapshot{
+		Round:      server.prevRound.close,
+		MerkleRoot: commitment.GetCommitmentHash().String(),
+		Commitments: []models.RoundSnapshotCommitment{
+			models.RoundSnapshotCommitment{0, hash0.String()},
+			models.RoundSnapshotCommitment{2, hash2.String()}},
+	}}, dbFake.RoundSnapshots)
+
+	// snapshot commitments recompute the round merkle root
+	merkleRoot, rootErr := dbFake.RoundSnapshots[0].ComputeMerkleRoot(server.ordering)
+	assert.Equal(t, nil, rootErr)
+	assert.Equal(t, commitment.GetCommitmentHash(), merkleRoot)
+
+	// snapshots are immutable once stored
+	assert.Equal(t, nil, dbFake.SaveRoundSnapshot(models.RoundSnapshot{Round: server.prevRound.close}))
+	assert.Equal(t, commitment.GetCommitmentHash().String(), dbFake.RoundSnapshots[0].MerkleRoot)
+}
+
+// Test commitments built and rebuilt with the merkle tree hash function
+// they were attested with after the server hash function changes
+func TestAttestServerMerkleHash(t *testing.T) {
+	dbFake := db.NewDbFake()
+	server := NewAttestServer(dbFake)
+	server.SetMerkleHash(models.MerkleHashTaggedSha256)
+
+	hash0, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("baaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	dbFake.SetClientCommitments([]models.ClientCommitment{
+		models.ClientCommitment{*hash0, 0, "", 1}, models.ClientCommitment{*hash1, 1, "", 1}})
+	commitment, err := server.GetClientCommitment()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, models.MerkleHashTaggedSha256, commitment.MerkleHash())
+	assert.Equal(t, models.MerkleHashTaggedSha256, dbFake.RoundSnapshots[0].Hash)
+	expected, _ := models.NewCommitmentHash([]chainhash.Hash{*hash0, *hash1}, models.MerkleHashTaggedSha256)
+	assert.Equal(t, expected.GetCommitmentHash(), commitment.GetCommitmentHash())
+
+	txid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	attestation := models.NewAttestation(*txid, &commitment)
+	attestation.Confirmed = true
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*attestation))
+
+	// past commitment rebuilt with the recorded hash function
+	server.SetMerkleHash(models.MerkleHashSha256d)
+	rebuilt, err := server.GetAttestationCommitment(*txid)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, commitment.GetCommitmentHash(), rebuilt.GetCommitmentHash())
+	merkleRoot, rootErr := dbFake.RoundSnapshots[0].ComputeMerkleRoot(server.ordering)
+	assert.Equal(t, nil, rootErr)
+	assert.Equal(t, commitment.GetCommitmentHash(), merkleRoot)
+
+	next, _ := server.GetClientCommitment()
+	assert.Equal(t, "", next.MerkleHash())
+	assert.NotEqual(t, commitment.GetCommitmentHash(), next.GetCommitmentHash())
+}
+
+// Test merkle tree hash function switched at the transition switchover round
+func TestAttestServerTransition(t *testing.T) {
+	dbFake := db.NewDbFake()
+	server := NewAttestServer(dbFake)
+	transition, _ := models.NewProtocolTransition(models.MerkleHashSha256, 2, 1)
+	server.SetTransition(transition)
+
+	hash0, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("baaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	dbFake.SetClientCommitments([]models.ClientCommitment{
+		models.ClientCommitment{*hash0, 0, "", 1}, models.ClientCommitment{*hash1, 1, "", 1}})
+
+	// first round attested with the current hash function while dual running
+	commitment, err := server.GetClientCommitment()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "", commitment.MerkleHash())
+
+	txid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	attestation := models.NewAttestation(*txid, &commitment)
+	attestation.Confirmed = true
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*attestation))
+
+	// switchover round attested with the next hash function
+	next, err := server.GetClientCommitment()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, models.MerkleHashSha256, next.MerkleHash())
+	expected, _ := models.NewCommitmentHash([]chainhash.Hash{*hash0, *hash1}, models.MerkleHashSha256)
+	assert.Equal(t, expected.GetCommitmentHash(), next.GetCommitmentHash())
+
+	// past commitment rebuilt with the recorded hash function
+	rebuilt, err := server.GetAttestationCommitment(*txid)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, commitment.GetCommitmentHash(), rebuilt.GetCommitmentHash())
+}
+
+// Test attestations resolve the pending commitment submissions of each position
+func TestAttestServerCommitmentSubmissions(t *testing.T) {
+	dbFake := db.NewDbFake()
+	server := NewAttestServer(dbFake)
+
+	hash0, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash2, _ := chainhash.NewHashFromStr("ccccccc1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	txid, _ := chainhash.NewHashFromStr("ddddddd1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	// submissions faster than the attestation interval keep the latest value
+	submissions, err := db.SubmitClientCommitments(dbFake, []models.ClientCommitment{
+		models.ClientCommitment{*hash0, 0, "", 1}, models.ClientCommitment{*hash2, 1, "", 1}})
+	assert.Equal(t, nil, err)
+	replaced, err := db.SubmitClientCommitments(dbFake, []models.ClientCommitment{
+		models.ClientCommitment{*hash1, 0, "", 2}})
+	assert.Equal(t, nil, err)
+
+	commitment, err := server.GetClientCommitment()
+	assert.Equal(t, nil, err)
+	later, err := db.SubmitClientCommitments(dbFake, []models.ClientCommitment{
+		models.ClientCommitment{*hash0, 0, "", 3}})
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*models.NewAttestation(*txid, &commitment)))
+	merkleRoot := commitment.GetCommitmentHash().String()
+	for _, expected := range []models.CommitmentSubmission{
+		{Id: submissions[0].Id, Status: models.SubmissionStatusSuperseded, MerkleRoot: merkleRoot},
+		{Id: submissions[1].Id, Status: models.SubmissionStatusIncluded, MerkleRoot: merkleRoot},
+		{Id: replaced[0].Id, Status: models.SubmissionStatusIncluded, MerkleRoot: merkleRoot},
+		{Id: later[0].Id, Status: models.SubmissionStatusPending},
+	} {
+		submission, _ := dbFake.GetCommitmentSubmission(expected.Id)
+		assert.Equal(t, expected.Status, submission.Status)
+		assert.Equal(t, expected.MerkleRoot, submission.MerkleRoot)
+	}
+}
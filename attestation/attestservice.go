@@ -7,20 +7,27 @@ package attestation
 import (
 	"bytes"
 	"context"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	confpkg "mainstay/config"
 	"mainstay/crypto"
 	"mainstay/log"
 	"mainstay/models"
+	"mainstay/notify"
+	"mainstay/tracing"
 
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	_ "github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Attestation Service is the main processes that handles generating
@@ -40,14 +47,54 @@ const (
 	AStateSendAttestation   AttestationState = 5
 	AStateAwaitConfirmation AttestationState = 6
 	AStateHandleUnconfirmed AttestationState = 7
+	AStateCanaryAttestation AttestationState = 8
+	AStateReviewAttestation AttestationState = 9
 )
 
+// attestation state names used in logs
+var stateNames = map[AttestationState]string{
+	AStateError:             "error",
+	AStateInit:              "init",
+	AStateNextCommitment:    "next_commitment",
+	AStateNewAttestation:    "new_attestation",
+	AStateSignAttestation:   "sign_attestation",
+	AStatePreSendStore:      "pre_send_store",
+	AStateSendAttestation:   "send_attestation",
+	AStateAwaitConfirmation: "await_confirmation",
+	AStateHandleUnconfirmed: "handle_unconfirmed",
+	AStateCanaryAttestation: "canary_attestation",
+	AStateReviewAttestation: "review_attestation",
+}
+
+// Return attestation state name
+func (s AttestationState) String() string {
+	if name, ok := stateNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("%d", int(s))
+}
+
 // error / warning consts
 const (
 	ErroUnspentNotFound = "No valid unspent found"
+	ErrorSigsTimeout    = "Signature collection timed out - missing signers"
 
 	WarningInvalidATimeNewAttestationArg    = "Invalid new attestation time config value"
 	WarningInvalidATimeHandleUnconfirmedArg = "Invalid handle unconfirmed time config value"
+	WarningInvalidBumpScheduleArg           = "Invalid bump schedule config value"
+	WarningFeeBumpRecordFailed              = "Could not record fee bump"
+	WarningAttestNowIgnored                 = "Attest now trigger ignored while not waiting for next commitment"
+	WarningPausedRestoreFailed              = "Could not restore paused flag"
+	WarningInvalidSignerRetriesArg          = "Invalid signer retries config value"
+	WarningSigsMissing                      = "Missing signatures - retrying signers"
+	WarningTransientFailure                 = "Transient failure - retrying state"
+	WarningInvalidStartupDelayArg           = "Invalid startup delay config value"
+	WarningInvalidATimeFixedArg             = "Invalid fixed time config value"
+	WarningInvalidATimeSigsArg              = "Invalid sigs time config value"
+	WarningInvalidATimeConfirmationArg      = "Invalid confirmation time config value"
+	WarningInvalidStaggerOffsetArg          = "Invalid stagger offset config value"
+	WarningInvalidConfirmationDepthArg      = "Invalid confirmation depth config value"
+	WarningInvalidHeartbeatIdleArg          = "Invalid heartbeat idle time config value"
 )
 
 // waiting time schedules
@@ -58,12 +105,25 @@ const (
 	// waiting time for sigs to arrive from multisig nodes
 	ATimeSigs = 1 * time.Minute
 
+	// waiting time between polls of the signers while collecting sigs
+	ATimeSigsPoll = 5 * time.Second
+
 	// waiting time to next attestation attempt when skipping already attested commitment
 	ATimeSkip = 1 * time.Minute
 
 	// waiting time between attemps to check if an attestation has been confirmed
 	ATimeConfirmation = 15 * time.Minute
 
+	// waiting time between checks of the main chain node sync progress
+	ATimeSync = 1 * time.Minute
+
+	// waiting time between all states in regtest mode
+	ATimeRegtest = 5 * time.Second
+
+	// waiting time before the first state on startup
+	// allows subscribers to have time to set up
+	DefaultATimeStartup = 10 * time.Second
+
 	// waiting time between consecutive attestations after one was confirmed
 	DefaultATimeNewAttestation = 60 * time.Minute
 
@@ -72,6 +132,17 @@ const (
 	DefaultATimeHandleUnconfirmed = 60 * time.Minute
 )
 
+// maximum number of fee bumps for an unconfirmed attestation
+// negative value allows bumping until the max fee is reached
+const DefaultMaxFeeBumps = -1
+
+// number of signature request retries with escalating urgency
+// before missing signatures fail the attestation
+const DefaultSignerRetries = 2
+
+// number of confirmations of an attestation before it is treated as confirmed
+const DefaultConfirmationDepth = 1
+
 // AttestationService structure
 // Encapsulates Attest Client and connectivity
 // to a AttestServer for updates and requests
@@ -99,18 +170,220 @@ type AttestService struct {
 	attestation *models.Attestation
 	errorState  error
 	isRegtest   bool
-}
-
-var (
-	atimeNewAttestation    time.Duration // delay between attestations - DEFAULTS to DefaultATimeNewAttestation
-	atimeHandleUnconfirmed time.Duration // delay until handling unconfirmed - DEFAULTS to DefaultATimeHandleUnconfirmed
 
+	// monitor for the staychain balance and remaining attestations
+	balance *BalanceMonitor
+
+	// fees spent on attestations and the daily and monthly fee budgets
+	budget *FeeBudget
+
+	// optional canary staychain mirroring attestations before broadcast
+	canary *AttestCanary
+
+	// optional review window holding attestations for operator veto before broadcast
+	review *AttestReview
+
+	// optional quorum of nodes required to agree on attestation confirmations
+	quorum *AttestQuorum
+
+	// optional zmq notifications of the main client node for confirmation checks
+	zmq *AttestZmq
+
+	// optional notifier of attestation lifecycle events
+	notifier notify.Notifier
+
+	// optional alerter of attestation service failures
+	alerter notify.Alerter
+
+	// federation key rotation requested through the admin api
+	rotation *AttestRotation
+
+	// manual trigger interrupting the wait for the next commitment
+	attestNow chan struct{}
+
+	// operator pause flag checked before each state and resume signal
+	paused int32
+	resume chan struct{}
+
+	// reloaded config pending until the next safe state boundary
+	reload chan ReloadConfig
+
+	// unix times of the next scheduled state and the last successful state
+	// transition, flags set while states are failing or the main chain
+	// node is syncing and the scheduled state, for health checks and status
+	nextState      int64
+	lastTransition int64
+	failing        int32
+	syncing        int32
+	scheduled      int32
+
+	// scope of the current state span shared with traced db and signer
+	// calls and context and span of the current attestation cycle
+	traceScope *tracing.Scope
+	cycleCtx   context.Context
+	cycleSpan  trace.Span
+
+	// timing schedules, rbf policy, signer retries and confirmation depth
+	// set from the service config and on config reloads
+	atimeNewAttestation    time.Duration   // delay between attestations - DEFAULTS to DefaultATimeNewAttestation
+	atimeHandleUnconfirmed time.Duration   // delay until handling unconfirmed - DEFAULTS to DefaultATimeHandleUnconfirmed
+	atimeBumpSchedule      []time.Duration // optional delays before each successive fee bump - last delay is repeated
+	atimeStartup           time.Duration   // delay before the first state on startup - DEFAULTS to DefaultATimeStartup
+	atimeStaggerOffset     time.Duration   // offset of new attestation slots if staggered
+	isStaggered            bool            // flag set to align new attestations to staggered slots
+	maxFeeBumps            int             // max fee bumps for an unconfirmed attestation - DEFAULTS to DefaultMaxFeeBumps
+	maxSignerRetries       int             // max signature request retries - DEFAULTS to DefaultSignerRetries
+	confirmationDepth      int64           // confirmations before an attestation is confirmed - DEFAULTS to DefaultConfirmationDepth
+	atimeMaxIdle           time.Duration   // time without a new commitment before attesting the same commitment again - 0 if not set
+	atimeRegtest           time.Duration   // delay between states in regtest mode - zero keeps the schedules
+	atimeFixed             time.Duration   // delay between states - DEFAULTS to ATimeFixed
+	atimeSigs              time.Duration   // time collecting sigs of a signature request - DEFAULTS to ATimeSigs
+	atimeConfirmation      time.Duration   // delay between confirmation checks - DEFAULTS to ATimeConfirmation
+
+	// optional adaptive interval fee rates and bounds
+	adaptiveFeeCeiling float64       // fee rate above which the interval is stretched - 0 if not set
+	adaptiveFeeFloor   float64       // fee rate below which the interval is shortened - 0 if not set
+	atimeAdaptiveMin   time.Duration // min interval - DEFAULTS to atimeNewAttestation
+	atimeAdaptiveMax   time.Duration // max interval - DEFAULTS to DefaultAdaptiveMaxFactor * atimeNewAttestation
+
+	// state delay, fee bumps, signatures and retries of the current attestation
 	attestDelay time.Duration // handle state delay
 	confirmTime time.Time     // handle confirmation timing
 
-	isFeeBumped bool // flag to keep track if the fee has already been bumped
-	sigs        [][]crypto.Sig
-)
+	isFeeBumped   bool                // flag to keep track if the fee has already been bumped
+	feeBumps      int                 // number of fee bumps for the current unconfirmed attestation
+	isRbfRejected bool                // flag set when a fee bumped replacement has been rejected
+	cpfpParent    *models.Attestation // unconfirmed parent attestation while a cpfp child is in progress
+	sigs          [][]crypto.Sig
+	sigsRequest   []string  // tx hash, redeem script and merkle root of the last signature request
+	sigsRetries   int       // number of retries of the last signature request
+	sigsTime      time.Time // time of the last signature request or retry
+
+	retryPolicy  RetryPolicy // in place retries of transient failures
+	stateRetries int         // number of in place retries of the current state
+}
+
+// Return delay extended to the start of the next new attestation slot
+// if staggered, so that instances sharing a bitcoind node with different
+// stagger offsets do not access the rpc wallet at the same time
+func (s *AttestService) staggerDelay(delay time.Duration) time.Duration {
+	if !s.isStaggered {
+		return delay
+	}
+	target := clock.Now().Add(delay)
+	slot := target.Truncate(s.atimeNewAttestation).Add(s.atimeStaggerOffset)
+	if slot.Before(target) {
+		slot = slot.Add(s.atimeNewAttestation)
+	}
+	return until(slot)
+}
+
+// Set timing schedules from timing config
+// Invalid values are reported and replaced by defaults
+func (s *AttestService) setTimingConfig(timingConfig confpkg.TimingConfig) {
+	s.atimeNewAttestation = DefaultATimeNewAttestation
+	if timingConfig.NewAttestationMinutes > 0 {
+		s.atimeNewAttestation = time.Duration(timingConfig.NewAttestationMinutes) * time.Minute
+	} else {
+		log.Warnf("%s (%v)\n", WarningInvalidATimeNewAttestationArg, timingConfig.NewAttestationMinutes)
+	}
+	log.Infof("Time new attestation set to: %v\n", s.atimeNewAttestation)
+	s.atimeHandleUnconfirmed = DefaultATimeHandleUnconfirmed
+	if timingConfig.HandleUnconfirmedMinutes > 0 {
+		s.atimeHandleUnconfirmed = time.Duration(timingConfig.HandleUnconfirmedMinutes) * time.Minute
+	} else {
+		log.Warnf("%s (%v)\n", WarningInvalidATimeHandleUnconfirmedArg, timingConfig.HandleUnconfirmedMinutes)
+	}
+	log.Infof("Time handle unconfirmed set to: %v\n", s.atimeHandleUnconfirmed)
+	s.atimeStartup = DefaultATimeStartup
+	if timingConfig.StartupDelaySeconds >= 0 {
+		s.atimeStartup = time.Duration(timingConfig.StartupDelaySeconds) * time.Second
+	} else if timingConfig.StartupDelaySeconds != -1 {
+		log.Warnf("%s (%v)\n", WarningInvalidStartupDelayArg, timingConfig.StartupDelaySeconds)
+	}
+	log.Infof("Time startup set to: %v\n", s.atimeStartup)
+	s.isStaggered = false
+	if timingConfig.StaggerOffsetMinutes >= 0 {
+		s.isStaggered = true
+		s.atimeStaggerOffset = time.Duration(timingConfig.StaggerOffsetMinutes) * time.Minute % s.atimeNewAttestation
+		log.Infof("Time stagger offset set to: %v\n", s.atimeStaggerOffset)
+	} else if timingConfig.StaggerOffsetMinutes != -1 {
+		log.Warnf("%s (%v)\n", WarningInvalidStaggerOffsetArg, timingConfig.StaggerOffsetMinutes)
+	}
+	s.atimeFixed = ATimeFixed
+	if timingConfig.FixedSeconds > 0 {
+		s.atimeFixed = time.Duration(timingConfig.FixedSeconds) * time.Second
+	} else if timingConfig.FixedSeconds != -1 {
+		log.Warnf("%s (%v)\n", WarningInvalidATimeFixedArg, timingConfig.FixedSeconds)
+	}
+	s.atimeSigs = ATimeSigs
+	if timingConfig.SigsSeconds > 0 {
+		s.atimeSigs = time.Duration(timingConfig.SigsSeconds) * time.Second
+	} else if timingConfig.SigsSeconds != -1 {
+		log.Warnf("%s (%v)\n", WarningInvalidATimeSigsArg, timingConfig.SigsSeconds)
+	}
+	s.atimeConfirmation = ATimeConfirmation
+	if timingConfig.ConfirmationSeconds > 0 {
+		s.atimeConfirmation = time.Duration(timingConfig.ConfirmationSeconds) * time.Second
+	} else if timingConfig.ConfirmationSeconds != -1 {
+		log.Warnf("%s (%v)\n", WarningInvalidATimeConfirmationArg, timingConfig.ConfirmationSeconds)
+	}
+	log.Infof("Time fixed set to: %v s.sigs set to: %v confirmation set to: %v\n", s.atimeFixed, s.atimeSigs, s.atimeConfirmation)
+}
+
+// Set fee bump schedule and max fee bumps from rbf config
+func (s *AttestService) setRbfConfig(rbfConfig confpkg.RbfConfig) {
+	s.atimeBumpSchedule = nil
+	for _, minutes := range rbfConfig.BumpScheduleMinutes {
+		if minutes <= 0 {
+			log.Warnf("%s (%v)\n", WarningInvalidBumpScheduleArg, rbfConfig.BumpScheduleMinutes)
+			s.atimeBumpSchedule = nil
+			break
+		}
+		s.atimeBumpSchedule = append(s.atimeBumpSchedule, time.Duration(minutes)*time.Minute)
+	}
+	if len(s.atimeBumpSchedule) > 0 {
+		log.Infof("Time bump schedule set to: %v\n", s.atimeBumpSchedule)
+	}
+	s.maxFeeBumps = DefaultMaxFeeBumps
+	if rbfConfig.MaxBumps >= 0 {
+		s.maxFeeBumps = rbfConfig.MaxBumps
+		log.Infof("Max fee bumps set to: %d\n", s.maxFeeBumps)
+	}
+}
+
+// Set max signature request retries from signer config
+func (s *AttestService) setSignerConfig(signerConfig confpkg.SignerConfig) {
+	s.maxSignerRetries = DefaultSignerRetries
+	if signerConfig.Retries >= 0 {
+		s.maxSignerRetries = signerConfig.Retries
+	} else {
+		log.Warnf("%s (%v)\n", WarningInvalidSignerRetriesArg, signerConfig.Retries)
+	}
+	log.Infof("Signer retries set to: %d\n", s.maxSignerRetries)
+}
+
+// Set confirmations required before an attestation is treated as confirmed
+func (s *AttestService) setConfirmationConfig(confirmConfig confpkg.ConfirmationConfig) {
+	s.confirmationDepth = DefaultConfirmationDepth
+	if confirmConfig.Depth > 0 {
+		s.confirmationDepth = int64(confirmConfig.Depth)
+		log.Infof("Confirmation depth set to: %d\n", s.confirmationDepth)
+	} else if confirmConfig.Depth != -1 {
+		log.Warnf("%s (%v)\n", WarningInvalidConfirmationDepthArg, confirmConfig.Depth)
+	}
+}
+
+// Set max idle time before a heartbeat attestation from heartbeat config
+func (s *AttestService) setHeartbeatConfig(heartbeatConfig confpkg.HeartbeatConfig) {
+	s.atimeMaxIdle = 0
+	if heartbeatConfig.IdleMinutes > 0 {
+		s.atimeMaxIdle = time.Duration(heartbeatConfig.IdleMinutes) * time.Minute
+		log.Infof("Time heartbeat idle set to: %v\n", s.atimeMaxIdle)
+	} else if heartbeatConfig.IdleMinutes != -1 {
+		log.Warnf("%s (%v)\n", WarningInvalidHeartbeatIdleArg, heartbeatConfig.IdleMinutes)
+	}
+}
 
 // NewAttestService returns a pointer to an AttestService instance
 // Initiates Attest Client and Attest AttestServer
@@ -123,73 +396,286 @@ func NewAttestService(ctx context.Context, wg *sync.WaitGroup, server *AttestSer
 
 	// initiate attestation client
 	attester := NewAttestClient(config)
-	isFeeBumped = false
+	attester.SetRpcContext(ctx)
+	if signerSetErr := attester.SetSignerSet(config.SignerConfig()); signerSetErr != nil {
+		log.Error(signerSetErr)
+	}
+	if config.DryRun() {
+		log.Warnln("Dry run mode - attestation transactions will not be sent")
+	}
 
-	// initiate timing schedules
-	atimeNewAttestation = DefaultATimeNewAttestation
-	if config.TimingConfig().NewAttestationMinutes > 0 {
-		atimeNewAttestation = time.Duration(config.TimingConfig().NewAttestationMinutes) * time.Minute
-	} else {
-		log.Warnf("%s (%v)\n", WarningInvalidATimeNewAttestationArg, config.TimingConfig().NewAttestationMinutes)
+	// initiate canary staychain if configured
+	var canary *AttestCanary
+	if config.CanaryConfig().Config != nil {
+		log.Infoln("Canary mode - attestations will be mirrored on the canary staychain before broadcast")
+		canary = NewAttestCanary(config.CanaryConfig())
+		canary.attester.SetRpcContext(ctx)
 	}
-	log.Infof("Time new attestation set to: %v\n", atimeNewAttestation)
-	atimeHandleUnconfirmed = DefaultATimeHandleUnconfirmed
-	if config.TimingConfig().HandleUnconfirmedMinutes > 0 {
-		atimeHandleUnconfirmed = time.Duration(config.TimingConfig().HandleUnconfirmedMinutes) * time.Minute
-	} else {
-		log.Warnf("%s (%v)\n", WarningInvalidATimeHandleUnconfirmedArg, config.TimingConfig().HandleUnconfirmedMinutes)
+
+	// initiate review window if configured
+	var review *AttestReview
+	if config.ReviewConfig().WindowMinutes > 0 {
+		log.Infoln("Review mode - attestations will be held for operator veto before broadcast")
+		review = NewAttestReview(config.ReviewConfig())
 	}
-	log.Infof("Time handle unconfirmed set to: %v\n", atimeHandleUnconfirmed)
 
-	return &AttestService{ctx, wg, config, attester, server, signer, AStateInit, models.NewAttestationDefault(), nil, config.Regtest()}
+	// initiate confirmation quorum if configured
+	var quorum *AttestQuorum
+	if len(config.QuorumConfig().Nodes) > 0 {
+		log.Infoln("Quorum mode - attestation confirmations will be checked on the quorum nodes")
+		quorum = NewAttestQuorum(config.QuorumConfig())
+	}
+
+	// initiate zmq notifications if configured, with polling as a fallback
+	zmq := NewAttestZmq(ctx, config.ZmqConfig())
+
+	// initiate webhook notifications if configured
+	var notifier notify.Notifier
+	if len(config.WebhookConfig().Urls) > 0 {
+		notifier = notify.NewWebhook(config.WebhookConfig())
+	}
+
+	// initiate failure alerting if any alert channels are configured
+	var alerter notify.Alerter
+	if dispatcher := notify.NewAlertDispatcher(config.AlertConfig()); dispatcher != nil {
+		alerter = dispatcher
+	}
+
+	s := &AttestService{
+		ctx:          ctx,
+		wg:           wg,
+		config:       config,
+		attester:     attester,
+		server:       server,
+		signer:       signer,
+		state:        AStateInit,
+		attestation:  models.NewAttestationDefault(),
+		isRegtest:    config.Regtest(),
+		balance:      NewBalanceMonitor(config.BalanceConfig()),
+		budget:       NewFeeBudget(config.BudgetConfig(), server),
+		canary:       canary,
+		review:       review,
+		quorum:       quorum,
+		zmq:          zmq,
+		notifier:     notifier,
+		alerter:      alerter,
+		rotation:     NewAttestRotation(attester),
+		attestNow:    make(chan struct{}, 1),
+		resume:       make(chan struct{}, 1),
+		reload:       make(chan ReloadConfig, 1),
+		scheduled:    int32(AStateInit),
+		traceScope:   tracing.NewScope(),
+		atimeRegtest: ATimeRegtest,
+		retryPolicy:  DefaultRetryPolicy(),
+	}
+
+	// initiate timing schedules, rbf policy, signer retries and confirmation depth
+	s.setTimingConfig(config.TimingConfig())
+	s.setAdaptiveConfig(config.AdaptiveConfig())
+	s.setRbfConfig(config.RbfConfig())
+	s.setSignerConfig(config.SignerConfig())
+	s.setConfirmationConfig(config.ConfirmationConfig())
+	s.setHeartbeatConfig(config.HeartbeatConfig())
+	return s
+}
+
+// Check integrity of the chain of confirmed attestations
+func (s *AttestService) CheckIntegrity() (models.IntegrityReport, error) {
+	return NewAttestIntegrity(s.attester, s.server).Check()
+}
+
+// Re-derive the address of a historical attestation
+func (s *AttestService) DeriveAttestation(txid string) (models.AttestationDerivation, error) {
+	return s.attester.deriveAttestation(s.server, txid)
+}
+
+// Add notifier of attestation lifecycle events
+// to any notifier already configured
+func (s *AttestService) AddNotifier(notifier notify.Notifier) {
+	if s.notifier == nil {
+		s.notifier = notifier
+		return
+	}
+	s.notifier = notify.Notifiers{s.notifier, notifier}
+}
+
+// Trigger an out of schedule attestation
+// Interrupts the new attestation wait if the service is waiting for the
+// next commitment and is ignored in any other state. Does not block
+func (s *AttestService) AttestNow() {
+	select {
+	case s.attestNow <- struct{}{}:
+	default: // trigger already pending
+	}
+}
+
+// Get balance monitor of the attestation service
+func (s *AttestService) BalanceMonitor() *BalanceMonitor {
+	return s.balance
+}
+
+// Get fee budget of the attestation service
+func (s *AttestService) FeeBudget() *FeeBudget {
+	return s.budget
+}
+
+// Pause attestation service after the current state completes
+// The paused flag is stored in the server and restored on restart
+func (s *AttestService) Pause() error {
+	if err := s.server.UpdatePaused(true); err != nil {
+		return err
+	}
+	atomic.StoreInt32(&s.paused, 1)
+	s.logger().Infoln("pause requested")
+	return nil
+}
+
+// Resume paused attestation service
+func (s *AttestService) Resume() error {
+	if err := s.server.UpdatePaused(false); err != nil {
+		return err
+	}
+	atomic.StoreInt32(&s.paused, 0)
+	select {
+	case s.resume <- struct{}{}:
+	default: // resume already pending
+	}
+	return nil
+}
+
+// Return whether the attestation service is paused
+func (s *AttestService) IsPaused() bool {
+	return atomic.LoadInt32(&s.paused) == 1
+}
+
+// Block while the attestation service is paused
+// Return false if the service was stopped while paused
+func (s *AttestService) waitWhilePaused() bool {
+	if !s.IsPaused() {
+		return true
+	}
+	s.logger().Infoln("paused")
+	for s.IsPaused() {
+		select {
+		case <-s.ctx.Done():
+			return false
+		case <-s.resume:
+		}
+	}
+	s.logger().Infoln("resumed")
+	return true
 }
 
 // Run Attest Service
 func (s *AttestService) Run() {
 	defer s.wg.Done()
 
-	attestDelay = 10 * time.Second // add some delay for subscribers to have time to set up
+	s.attestDelay = s.atimeStartup // add some delay for subscribers to have time to set up
+	s.recordTransition(true)
+
+	// restore paused flag from previous run
+	paused, pausedErr := s.server.GetPaused()
+	if pausedErr != nil {
+		log.Warnf("%s %v\n", WarningPausedRestoreFailed, pausedErr)
+	} else if paused {
+		atomic.StoreInt32(&s.paused, 1)
+	}
 
 	for { //Doing attestations using attestation client and waiting for transaction confirmation
-		timer := time.NewTimer(attestDelay)
+		timer := clock.NewTimer(s.attestDelay)
+		deadline := clock.Now().Add(s.attestDelay)
 		select {
 		case <-s.ctx.Done():
 			log.Infoln("Shutting down Attestation Service...")
+			s.flushInFlight()
+			s.endCycleSpan(nil)
 			return
-		case <-timer.C:
-			// do next attestation state
-			s.doAttestation()
-
-			// for testing - overwrite delay
-			if s.isRegtest {
-				attestDelay = 5 * time.Second
+		case <-s.attestNow:
+			timer.Stop()
+			if s.state != AStateNextCommitment {
+				// keep waiting for the remaining delay of the current state
+				s.logger().Warnln(WarningAttestNowIgnored)
+				s.attestDelay = until(deadline)
+				continue
+			}
+			s.logger().Infoln("attest now triggered - skipping new attestation wait")
+		case notification := <-s.zmq.Notifications():
+			timer.Stop()
+			if !s.isConfirmationNotification(notification) {
+				s.attestDelay = until(deadline)
+				continue
 			}
+			s.logger().Infof("%s notification - checking confirmation\n", notification.Topic)
+		case <-timer.C():
+		}
+
+		if !s.waitWhilePaused() {
+			log.Infoln("Shutting down Attestation Service...")
+			s.flushInFlight()
+			s.endCycleSpan(nil)
+			return
+		}
 
-			log.Infof("********** sleeping for: %s ...\n", attestDelay.String())
+		// wait for the main chain node to sync instead of running
+		// states against an incomplete chain
+		if s.waitChainSync() {
+			continue
 		}
+
+		// apply reloaded config while no attestation is in progress
+		if s.state == AStateNextCommitment {
+			s.applyReload()
+		}
+
+		// do next attestation state
+		prevState := s.state
+		span := s.startStateSpan()
+		s.doAttestation()
+		s.endStateSpan(span)
+
+		// for testing - overwrite delay
+		if s.isRegtest && s.atimeRegtest > 0 {
+			s.attestDelay = s.atimeRegtest
+		}
+		s.recordTransition(prevState != AStateError && s.state != AStateError)
+
+		s.logger().Debugf("sleeping for: %s\n", s.attestDelay.String())
 	}
 }
 
 // AStateError
 // - Print error state and re-initiate attestation
 func (s *AttestService) doStateError() {
-	log.Warnln("*AttestService* ATTESTATION SERVICE FAILURE")
-	log.Warnln(s.errorState)
+	s.logger().WithFields(log.Fields{log.FieldError: s.errorState}).Warnln("attestation service failure")
 	s.state = AStateInit // update attestation state
 }
 
 // part of AStateInit
 // handle case when an unconfirmed transactions is found in the mempool
 // fetch attestation information and set service state to AStateAwaitConfirmation
+// attestations not stored are stored with the commitment recovered
 func (s *AttestService) stateInitUnconfirmed(unconfirmedTxid chainhash.Hash) {
-	commitment, commitmentErr := s.server.GetAttestationCommitment(unconfirmedTxid, false)
+	rawTx, rawTxErr := s.attester.Chain.GetRawTransaction(&unconfirmedTxid)
+	if s.setFailure(rawTxErr) {
+		return // will rebound to init
+	}
+	commitment, stored, commitmentErr := s.reconcileTipCommitment(unconfirmedTxid, rawTx.MsgTx())
 	if s.setFailure(commitmentErr) {
 		return // will rebound to init
 	}
-	log.Warnf("********** found unconfirmed attestation: %s\n", unconfirmedTxid.String())
-	s.attestation = models.NewAttestation(unconfirmedTxid, &commitment) // initialise attestation
-	rawTx, _ := s.config.MainClient().GetRawTransaction(&unconfirmedTxid)
-	s.attestation.Tx = *rawTx.MsgTx() // set msgTx
+	s.logger().WithFields(log.Fields{log.FieldTxid: unconfirmedTxid.String()}).Warnln("found unconfirmed attestation")
+	s.attestation = models.NewAttestation(unconfirmedTxid, commitment) // initialise attestation
+	s.attestation.Tx = *rawTx.MsgTx()                                  // set msgTx
+	if !stored {
+		// store recovered attestation, in case the service fails again
+		errUpdate := s.server.UpdateLatestAttestation(*s.attestation)
+		if s.setFailure(errUpdate) {
+			return // will rebound to init
+		}
+	}
+	if s.setFailure(s.updateKeyRotation(s.attestation)) {
+		return // will rebound to init
+	}
 
 	// get last confirmed commitment from server
 	lastCommitmentHash, latestErr := s.server.GetLatestAttestationCommitmentHash()
@@ -199,17 +685,17 @@ func (s *AttestService) stateInitUnconfirmed(unconfirmedTxid chainhash.Hash) {
 	s.signer.SendConfirmedHash((&lastCommitmentHash).CloneBytes()) // update clients
 
 	s.state = AStateAwaitConfirmation // update attestation state
-	walletTx, getTxError := s.config.MainClient().GetMempoolEntry(unconfirmedTxid.String())
+	walletTx, getTxError := s.attester.Chain.GetMempoolEntry(unconfirmedTxid.String())
 	if s.setFailure(getTxError) {
-		log.Info("********** failed to find unconfirmed transaction in mempool, re-initialising attestation")
+		s.logger().WithFields(log.Fields{log.FieldTxid: unconfirmedTxid.String()}).Infoln("failed to find unconfirmed transaction in mempool, re-initialising attestation")
 		return // will rebound to init
 	}
-	confirmTime = time.Unix(walletTx.Time, 0)
+	s.confirmTime = time.Unix(walletTx.Time, 0)
 
 	//set fee to unconfirmed tx's fee
 	feePerByte := int(walletTx.Fee*float64(Coin)) / s.attestation.Tx.SerializeSize() // fee in satoshis / tx size
 	s.attester.Fees.setCurrentFee(feePerByte)
-	isFeeBumped = false // in case we bumped fees but then attestation creation/signing/sending failed
+	s.isFeeBumped = false // in case we bumped fees but then attestation creation/signing/sending failed
 }
 
 // part of AStateInit
@@ -218,40 +704,69 @@ func (s *AttestService) stateInitUnconfirmed(unconfirmedTxid chainhash.Hash) {
 // initiate a new attestation and inform signers of commitment
 func (s *AttestService) stateInitUnspent(unspent btcjson.ListUnspentResult) {
 	unspentTxid, _ := chainhash.NewHashFromStr(unspent.TxID)
-	commitment, commitmentErr := s.server.GetAttestationCommitment(*unspentTxid)
-	if s.setFailure(commitmentErr) {
-		return // will rebound to init
-	} else if (commitment.GetCommitmentHash() != chainhash.Hash{}) {
-		log.Infof("********** found confirmed attestation: %s\n", unspentTxid.String())
-		s.attestation = models.NewAttestation(*unspentTxid, &commitment)
+	if s.attester.txid0 == unspentTxid.String() {
+		s.logger().WithFields(log.Fields{log.FieldTxid: unspentTxid.String()}).Infoln("found base transaction, blank attestation")
+		s.attestation = models.NewAttestationDefault()
+	} else {
+		rawTx, rawTxErr := s.attester.Chain.GetRawTransaction(unspentTxid)
+		if s.setFailure(rawTxErr) {
+			return // will rebound to init
+		}
+		walletTx, walletTxErr := s.attester.Chain.GetTransaction(unspentTxid)
+		if s.setFailure(walletTxErr) {
+			return // will rebound to init
+		}
+		height, heightErr := s.attester.getConfirmationHeight(walletTx)
+		if s.setFailure(heightErr) {
+			return // will rebound to init
+		}
+		commitment, _, commitmentErr := s.reconcileTipCommitment(*unspentTxid, rawTx.MsgTx())
+		if s.setFailure(commitmentErr) {
+			return // will rebound to init
+		}
+		s.attestation = models.NewAttestation(*unspentTxid, commitment)
+
+		// attestation not yet at the confirmation depth is awaited
+		// before being treated as confirmed
+		if walletTx.Confirmations < s.confirmationDepth {
+			s.logger().WithFields(log.Fields{log.FieldTxid: unspentTxid.String()}).Infoln("found attestation awaiting confirmation depth")
+			s.attestation.Tx = *rawTx.MsgTx()
+			errUpdate := s.server.UpdateLatestAttestation(*s.attestation)
+			if s.setFailure(errUpdate) {
+				return // will rebound to init
+			}
+			s.state = AStateAwaitConfirmation
+			s.attestDelay = s.atimeConfirmation
+			s.confirmTime = clock.Now()
+			s.isFeeBumped = false
+			s.feeBumps = 0
+			return
+		}
+
+		s.logger().WithFields(log.Fields{log.FieldTxid: unspentTxid.String()}).Infoln("found confirmed attestation")
 		// update server with latest confirmed attestation
 		s.attestation.Confirmed = true
-		rawTx, _ := s.config.MainClient().GetRawTransaction(unspentTxid)
-		walletTx, _ := s.config.MainClient().GetTransaction(unspentTxid)
-		s.attestation.Tx = *rawTx.MsgTx()  // set msgTx
-		s.attestation.UpdateInfo(walletTx) // set tx info
+		s.attestation.Tx = *rawTx.MsgTx()          // set msgTx
+		s.attestation.UpdateInfo(walletTx, height) // set tx info
 
 		errUpdate := s.server.UpdateLatestAttestation(*s.attestation)
 		if s.setFailure(errUpdate) {
 			return // will rebound to init
 		}
+		if s.setFailure(s.updateKeyRotation(s.attestation)) {
+			return // will rebound to init
+		}
 
 		s.attester.Fees.ResetFee(s.isRegtest) // reset client fees
+		s.feeBumps = 0                        // reset fee bumps
 		// set delay to the difference between atimeNewAttestation and time since last attestation
-		lastDelay := time.Since(time.Unix(s.attestation.Info.Time, 0))
-		if atimeNewAttestation > lastDelay {
-			attestDelay = atimeNewAttestation - lastDelay
+		lastDelay := since(time.Unix(s.attestation.Info.Time, 0))
+		if newAttestationTime := s.newAttestationTime(); newAttestationTime > lastDelay {
+			s.attestDelay = s.staggerDelay(newAttestationTime - lastDelay)
 		}
-	} else {
-		log.Infoln("********** found unspent transaction, initiating staychain")
-		s.attestation = models.NewAttestationDefault()
 	}
 
 	confirmedHash := s.attestation.CommitmentHash()
-	if s.attester.txid0 == unspentTxid.String() {
-		log.Infoln("********** found base transaction, blank attestation")
-		confirmedHash = chainhash.Hash{}
-	}
 	s.signer.SendConfirmedHash((&confirmedHash).CloneBytes()) // update clients
 
 	s.state = AStateNextCommitment // update attestation state
@@ -263,7 +778,7 @@ func (s *AttestService) stateInitUnspent(unspent btcjson.ListUnspentResult) {
 // both latest unconfirmed and confirmed attestation addresses to wallet
 func (s *AttestService) stateInitWalletFailure() {
 
-	log.Warnln("********** wallet failure")
+	s.logger().Warnln("wallet failure")
 
 	// get last confirmed commitment from server
 	lastCommitmentHash, latestErr := s.server.GetLatestAttestationCommitmentHash()
@@ -277,7 +792,7 @@ func (s *AttestService) stateInitWalletFailure() {
 		return // will rebound to init
 	}
 
-	log.Infof("********** importing latest confirmed addr: %s ...\n", paytoaddr.String())
+	s.logger().Infof("importing latest confirmed addr: %s\n", paytoaddr.String())
 	importErr := s.attester.ImportAttestationAddr(paytoaddr)
 	if s.setFailure(importErr) {
 		return // will rebound to init
@@ -294,7 +809,7 @@ func (s *AttestService) stateInitWalletFailure() {
 	if s.setFailure(addrErr) {
 		return // will rebound to init
 	}
-	log.Infof("********** importing latest unconfirmed addr: %s ...\n", paytoaddr.String())
+	s.logger().Infof("importing latest unconfirmed addr: %s\n", paytoaddr.String())
 	importErr = s.attester.ImportAttestationAddr(paytoaddr)
 	if s.setFailure(importErr) {
 		return // will rebound to init
@@ -306,27 +821,61 @@ func (s *AttestService) stateInitWalletFailure() {
 		return // will rebound to init
 	}
 
-	log.Infof("********** importing base init addr: %s ...\n", paytoaddr.String())
+	s.logger().Infof("importing base init addr: %s\n", paytoaddr.String())
 	importErr = s.attester.ImportAttestationAddr(paytoaddr)
 	if s.setFailure(importErr) {
 		return // will rebound to init
 	}
 
+	// import addresses of attestations that may have been sent but not stored
+	candidates, candidatesErr := s.recoveryCommitments()
+	if s.setFailure(candidatesErr) {
+		return // will rebound to init
+	}
+	attesters := []*AttestClient{s.attester}
+	if next := s.nextAttester(); next != s.attester {
+		attesters = append(attesters, next) // transition attestation of key rotation
+	}
+	for _, candidate := range candidates {
+		for _, attester := range attesters {
+			paytoaddr, _, addrErr = attester.GetNextAttestationAddr((*btcutil.WIF)(nil), candidate.GetCommitmentHash())
+			if s.setFailure(addrErr) {
+				return // will rebound to init
+			}
+			s.logger().Infof("importing recovery addr: %s\n", paytoaddr.String())
+			importErr = s.attester.ImportAttestationAddr(paytoaddr)
+			if s.setFailure(importErr) {
+				return // will rebound to init
+			}
+		}
+	}
+
 	s.state = AStateInit // update attestation state
 }
 
 // AStateInit
 // - Check if there are unconfirmed or unspent transactions in the client
+// - Reconcile the staychain tip with the server, recovering attestations not stored
 // - Update server with latest attestation information
 // - If no transaction found wait, else initiate new attestation
 // - If no attestation found, check last unconfirmed from db
 func (s *AttestService) doStateInit() {
-	log.Infoln("*AttestService* INITIATING ATTESTATION PROCESS")
+	s.logger().Infoln("initiating attestation process")
+	s.cpfpParent = nil // any in progress cpfp child is re-initiated from handle unconfirmed
+
+	// set client keys to the last active key rotation
+	if s.setFailure(s.loadKeyRotations()) {
+		return // will rebound to init
+	}
 
 	// find the state of the attestation
 	unconfirmed, unconfirmedTxid, unconfirmedErr := s.attester.getUnconfirmedTx()
 	if s.setFailure(unconfirmedErr) {
 		return // will rebound to init
+	} else if unconfirmed && unconfirmedTxid.String() == s.attester.txid0 {
+		// staychain not started until base transaction confirms
+		s.logger().WithFields(log.Fields{log.FieldTxid: unconfirmedTxid.String()}).Warnln(WarningInitBaseUnconfirmed)
+		s.attestDelay = s.atimeConfirmation
 	} else if unconfirmed { // check mempool for unconfirmed - added check in case something gets rejected
 		// handle init unconfirmed case
 		s.stateInitUnconfirmed(unconfirmedTxid)
@@ -337,6 +886,11 @@ func (s *AttestService) doStateInit() {
 		} else if success {
 			// handle init unspent case
 			s.stateInitUnspent(unspent)
+
+			// resume attestation in progress before shutdown
+			if s.state == AStateNextCommitment {
+				s.stateInitInFlight(unspent)
+			}
 		} else {
 			// handle wallet failure case
 			s.stateInitWalletFailure()
@@ -345,12 +899,18 @@ func (s *AttestService) doStateInit() {
 }
 
 // AStateNextCommitment
+// - Check the latest attestation has not been dropped from the chain by a reorg
 // - Get latest commitment from server
 // - Check if commitment has already been attested
 // - Send commitment to client signers
 // - Initialise new attestation
 func (s *AttestService) doStateNextCommitment() {
-	log.Infoln("*AttestService* NEW ATTESTATION COMMITMENT")
+	s.logger().Infoln("new attestation commitment")
+
+	// await confirmation again if the latest attestation was reorged
+	if s.checkReorg() {
+		return // will await confirmation or rebound to init
+	}
 
 	// get latest commitment hash from server
 	latestCommitment, latestErr := s.server.GetClientCommitment()
@@ -360,39 +920,92 @@ func (s *AttestService) doStateNextCommitment() {
 	latestCommitmentHash := latestCommitment.GetCommitmentHash()
 
 	// check if commitment has already been attested
-	log.Infof("********** received commitment hash: %s\n", latestCommitmentHash.String())
+	s.logger().WithFields(log.Fields{log.FieldCommitment: latestCommitmentHash.String()}).Infoln("received commitment")
 	if latestCommitmentHash == s.attestation.CommitmentHash() {
-		log.Infof("********** Skipping attestation - Client commitment already attested")
-		attestDelay = ATimeSkip // sleep
-		return                  // will remain at the same state
+		if !s.isHeartbeatDue() {
+			s.logger().WithFields(log.Fields{log.FieldCommitment: latestCommitmentHash.String()}).Infoln("skipping attestation - client commitment already attested")
+			s.attestDelay = ATimeSkip // sleep
+			return                    // will remain at the same state
+		}
+		s.logger().WithFields(log.Fields{log.FieldCommitment: latestCommitmentHash.String()}).Infoln("heartbeat attestation - client commitment unchanged")
+	}
+
+	// pause new attestations while the fee budget is exceeded
+	if s.isOverBudget() {
+		s.attestDelay = ATimeSkip // sleep
+		return                    // will remain at the same state
 	}
 
 	// initialise new attestation with commitment
 	s.attestation = models.NewAttestationDefault()
 	s.attestation.SetCommitment(&latestCommitment)
+	if requestIds := s.attestationRequestIds(); requestIds != "" {
+		s.attestationLogger().WithFields(log.Fields{log.FieldRequestIds: requestIds}).Infoln("commitment request ids")
+	}
 
 	s.state = AStateNewAttestation // update attestation state
 }
 
+// Return true if the latest attestation was confirmed longer than the
+// max idle time ago, to attest the unchanged commitment again
+func (s *AttestService) isHeartbeatDue() bool {
+	if s.atimeMaxIdle == 0 || !s.attestation.Confirmed || s.attestation.Info.Time == 0 {
+		return false
+	}
+	return since(time.Unix(s.attestation.Info.Time, 0)) >= s.atimeMaxIdle
+}
+
+// Return true if the daily or monthly fee budget is exceeded, alerting
+// once when a budget is first exceeded, or the spend could not be checked
+func (s *AttestService) isOverBudget() bool {
+	if s.budget == nil {
+		return false
+	}
+	spend, isNew, spendErr := s.budget.Check()
+	if s.setFailure(spendErr) {
+		return true // will retry or rebound to init
+	}
+	if !spend.Exceeded {
+		return false
+	}
+	s.logger().Warnf("%s (day %d of %d, month %d of %d)\n", WarningFeeBudgetExceeded,
+		spend.Day, spend.DailyBudget, spend.Month, spend.MonthlyBudget)
+	if isNew && s.alerter != nil {
+		s.alerter.Alert(models.Alert{
+			Error:     WarningFeeBudgetExceeded,
+			PrevState: s.state.String(),
+			Time:      clock.Now().Unix(),
+		})
+	}
+	return true
+}
+
 // AStateNewAttestation
 // - Generate new pay to address for attestation transaction using client commitment
 // - Create new unsigned transaction using the last unspent
 // - If a topup unspent exists, add this to the new attestation
 // - Publish unsigned transaction to signer clients
-// - add ATimeSigs waiting time
+// - add ATimeSigsPoll polling time
 func (s *AttestService) doStateNewAttestation() {
-	log.Infoln("*AttestService* NEW ATTESTATION")
+	s.logger().Infoln("new attestation")
+	s.feeBumps = 0 // reset fee bumps for new attestation
+
+	// pay to the new script if a key rotation is in progress
+	if s.setFailure(s.startKeyRotation()) {
+		return // will rebound to init
+	}
+	next := s.nextAttester()
 
 	// Get key and address for next attestation using client commitment
-	key, keyErr := s.attester.GetNextAttestationKey(s.attestation.CommitmentHash())
+	key, keyErr := next.GetNextAttestationKey(s.attestation.CommitmentHash())
 	if s.setFailure(keyErr) {
 		return // will rebound to init
 	}
-	paytoaddr, _, addrErr := s.attester.GetNextAttestationAddr(key, s.attestation.CommitmentHash())
+	paytoaddr, _, addrErr := next.GetNextAttestationAddr(key, s.attestation.CommitmentHash())
 	if s.setFailure(addrErr) {
 		return // will rebound to init
 	}
-	log.Infof("********** importing pay-to addr: %s ...\n", paytoaddr.String())
+	s.logger().Infof("importing pay-to addr: %s\n", paytoaddr.String())
 	importErr := s.attester.ImportAttestationAddr(paytoaddr, false) // no rescan needed here
 	if s.setFailure(importErr) {
 		return // will rebound to init
@@ -406,12 +1019,13 @@ func (s *AttestService) doStateNewAttestation() {
 		var unspentList []btcjson.ListUnspentResult
 		unspentList = append(unspentList, unspent)
 
-		// search for topup unspent and add if it exists
-		topupFound, topupUnspent, topupUnspentErr := s.attester.findTopupUnspent()
+		// search for topup unspents and add all that fit in the tx size budget
+		topupUnspents, topupUnspentErr := s.attester.findTopupUnspents()
 		if s.setFailure(topupUnspentErr) {
 			return // will rebound to init
-		} else if topupFound {
-			log.Infof("********** found topup unspent: %s\n", topupUnspent.TxID)
+		}
+		for _, topupUnspent := range topupUnspents {
+			s.logger().WithFields(log.Fields{log.FieldTxid: topupUnspent.TxID}).Infoln("found topup unspent")
 			unspentList = append(unspentList, topupUnspent)
 		}
 
@@ -422,7 +1036,13 @@ func (s *AttestService) doStateNewAttestation() {
 		}
 
 		s.attestation.Tx = *newTx
-		log.Infof("********** pre-sign txid: %s\n", s.attestation.Tx.TxHash().String())
+		s.logger().WithFields(log.Fields{log.FieldTxid: s.attestation.Tx.TxHash().String()}).Infoln("pre-sign attestation")
+
+		// update staychain balance and remaining attestations
+		feePerByte := s.attester.Fees.GetFee()
+		balance := s.balance.Update(s.attestation.Tx.TxOut[0].Value,
+			s.attester.estimateAttestationFee(feePerByte), feePerByte)
+		s.logger().Infof("staychain balance: %d runway: %d attestations\n", balance.Value, balance.Runway)
 
 		// get last confirmed commitment from server
 		lastCommitmentHash, latestErr := s.server.GetLatestAttestationCommitmentHash()
@@ -432,21 +1052,20 @@ func (s *AttestService) doStateNewAttestation() {
 
 		//if spending from base transaction, zero last commitment
 		if s.attester.txid0 == s.attestation.Tx.TxIn[0].PreviousOutPoint.Hash.String() {
-			log.Infoln("********** base transaction, zero tweaking for signature")
+			s.logger().Infoln("base transaction, zero tweaking for signature")
 			lastCommitmentHash = chainhash.Hash{}
 		}
 
 		txId := newTx.TxIn[0].PreviousOutPoint.Hash
-		rawTx, _ := s.config.MainClient().GetRawTransactionVerbose(&txId)
+		rawTx, rawTxErr := s.attester.Chain.GetRawTransactionVerbose(&txId)
+		if s.setFailure(rawTxErr) {
+			return // will rebound to init
+		}
 		txHash := rawTx.Hash
 		asmList := strings.Split(rawTx.Vin[0].ScriptSig.Asm, " ")
 		redeemScript := asmList[len(asmList)-1]
 		merkle_root := lastCommitmentHash.String()
-		sigs = s.signer.GetSigs(txHash, redeemScript, merkle_root)
-		for sigForInput, _ := range sigs {
-			log.Infof("********** received %d signatures for input %d \n",
-				len(sigs[sigForInput]), sigForInput)
-		}
+		s.requestSigs(txHash, redeemScript, merkle_root)
 
 		// publish pre signed transaction
 		txPreImages, getPreImagesErr := s.attester.getTransactionPreImages(lastCommitmentHash, newTx)
@@ -464,7 +1083,7 @@ func (s *AttestService) doStateNewAttestation() {
 		s.signer.SendTxPreImages(txPreImageBytes)
 
 		s.state = AStateSignAttestation // update attestation state
-		attestDelay = ATimeSigs         // add sigs waiting time
+		s.attestDelay = ATimeSigsPoll   // add sigs polling time
 	} else {
 		s.setFailure(errors.New(ErroUnspentNotFound))
 		return // will rebound to init
@@ -472,10 +1091,13 @@ func (s *AttestService) doStateNewAttestation() {
 }
 
 // AStateSignAttestation
-// - Collect signatures from client signers
+// - Collect signatures from client signers, discarding invalid signatures
+// - Poll signers until the threshold of signatures is collected, retrying
+//   the request once ATimeSigs passes and failing with the missing signers
 // - Combine signatures them and sign the attestation transaction
+// - Verify the scripts of the signed attestation transaction
 func (s *AttestService) doStateSignAttestation() {
-	log.Infoln("*AttestService* SIGN ATTESTATION")
+	s.logger().Infoln("sign attestation")
 
 	// get last confirmed commitment from server
 	lastCommitmentHash, latestErr := s.server.GetLatestAttestationCommitmentHash()
@@ -484,27 +1106,245 @@ func (s *AttestService) doStateSignAttestation() {
 	}
 
 	if s.attester.txid0 == s.attestation.Tx.TxIn[0].PreviousOutPoint.Hash.String() {
-		log.Infoln("********** base transaction, zero tweaking for signature")
+		s.logger().Infoln("base transaction, zero tweaking for signature")
 		lastCommitmentHash = chainhash.Hash{}
+	} else if s.cpfpParent != nil {
+		s.logger().Infoln("child transaction, parent commitment tweaking for signature")
+		lastCommitmentHash = s.cpfpParent.CommitmentHash()
 	}
 
+	// discard invalid sigs so that these are re-requested if missing
+	validSigs, numInvalid, validErr := s.attester.rejectInvalidSigs(&s.attestation.Tx, s.sigs, lastCommitmentHash)
+	if s.setFailure(validErr) {
+		return // will rebound to init
+	}
+	if numInvalid > 0 {
+		s.logger().Warnf("%s (%d)\n", WarningSigsInvalid, numInvalid)
+		s.sigs = validSigs
+	}
+	s.recordSignerStatus(lastCommitmentHash)
+
 	// sign attestation with combined sigs and last commitment
-	signedTx, signErr := s.attester.signAttestation(&s.attestation.Tx, sigs, lastCommitmentHash)
+	// the unsigned transaction is kept in case signatures are missing
+	// proceeding as soon as the threshold of valid sigs is collected
+	signedTx, signErr := s.attester.signAttestation(s.attestation.Tx.Copy(), s.sigs, lastCommitmentHash)
+	if isSigsMissing(signErr) && since(s.sigsTime) < s.atimeSigs {
+		s.pollSigs()
+		s.attestDelay = s.sigsPollDelay() // add sigs polling time
+		return                            // will remain at the same state
+	}
+	if isSigsMissing(signErr) && s.sigsRetries < s.maxSignerRetries {
+		s.retrySigs()
+		s.attestDelay = ATimeSigsPoll // add sigs polling time
+		return                        // will remain at the same state
+	}
+	if isSigsMissing(signErr) {
+		signErr = NewAttestError(ErrorClassSignerTimeout, s.sigsTimeoutError(signErr, lastCommitmentHash))
+	}
 	if s.setFailure(signErr) {
-		log.Infof("********** signer failure. resubscribing to signers...")
+		s.logger().Warnln("signer failure - resubscribing to signers")
 		s.signer.ReSubscribe()
 		return // will rebound to init
 	}
+
+	// verify signed attestation scripts before storing and sending
+	if s.setFailure(s.attester.verifyAttestation(signedTx)) {
+		return // will rebound to init
+	}
 	s.attestation.Tx = *signedTx
 	s.attestation.Txid = s.attestation.Tx.TxHash()
 
-	s.state = AStatePreSendStore // update attestation state
+	// mirror new attestations on canary staychain first if configured
+	if s.canary != nil && !s.isFeeBumped && s.cpfpParent == nil {
+		s.state = AStateCanaryAttestation // update attestation state
+		return
+	}
+	s.state = s.stateAfterSigning() // update attestation state
+}
+
+// part of AStateSignAttestation and AStateCanaryAttestation
+// return state following signing of the attestation, holding
+// new attestations for operator review first if configured
+func (s *AttestService) stateAfterSigning() AttestationState {
+	if s.review != nil && !s.isFeeBumped && s.cpfpParent == nil {
+		return AStateReviewAttestation
+	}
+	return AStatePreSendStore
+}
+
+// part of AStateNewAttestation and AStateHandleUnconfirmed
+// request signatures from signers and keep request for retries
+func (s *AttestService) requestSigs(txHash string, redeemScript string, merkleRoot string) {
+	s.sigsRequest = []string{txHash, redeemScript, merkleRoot}
+	s.sigsRetries = 0
+	s.sigsTime = clock.Now()
+	s.sigs = s.signer.GetSigs(txHash, redeemScript, merkleRoot, SigsUrgencyNormal)
+	for sigForInput := range s.sigs {
+		s.logger().Infof("received %d signatures for input %d\n", len(s.sigs[sigForInput]), sigForInput)
+	}
+}
+
+// part of AStateSignAttestation
+// re-send the last signature request with escalated urgency
+// and merge any new signatures with those already received
+func (s *AttestService) retrySigs() {
+	s.sigsRetries++
+	s.sigsTime = clock.Now()
+	s.logger().Warnf("%s (retry %d of %d)\n", WarningSigsMissing, s.sigsRetries, s.maxSignerRetries)
+	s.pollSigs()
+}
+
+// part of AStateSignAttestation
+// poll signers for the last signature request at the current urgency
+// and merge any new signatures with those already received
+func (s *AttestService) pollSigs() {
+	if len(s.sigsRequest) != 3 {
+		return
+	}
+	newSigs := s.signer.GetSigs(s.sigsRequest[0], s.sigsRequest[1], s.sigsRequest[2], s.sigsRetries)
+	s.sigs = MergeSigs(s.sigs, newSigs)
+	for sigForInput := range s.sigs {
+		s.logger().Infof("received %d signatures for input %d\n", len(s.sigs[sigForInput]), sigForInput)
+	}
+}
+
+// Return delay until the next poll of the signers,
+// bounded by the end of the signature collection time
+func (s *AttestService) sigsPollDelay() time.Duration {
+	if remaining := s.atimeSigs - since(s.sigsTime); remaining < ATimeSigsPoll {
+		return remaining
+	}
+	return ATimeSigsPoll
+}
+
+// part of AStateSignAttestation
+// return error listing the positions of the signers without a valid
+// signature once the signature collection time and retries are exhausted
+func (s *AttestService) sigsTimeoutError(signErr error, hash chainhash.Hash) error {
+	signed, signedErr := s.attester.signersOfSigs(&s.attestation.Tx, s.sigs, hash)
+	if signedErr != nil {
+		return signErr
+	}
+	var missing []string
+	for i_k := range signed {
+		if !signed[i_k] {
+			missing = append(missing, fmt.Sprintf("%d", i_k))
+		}
+	}
+	if len(missing) == 0 {
+		return signErr
+	}
+	return errors.New(fmt.Sprintf("%s: %s", ErrorSigsTimeout, strings.Join(missing, ",")))
+}
+
+// Check if signing error is due to missing signatures
+func isSigsMissing(err error) bool {
+	return err != nil && (err.Error() == ErrorSigsMissingForVin || err.Error() == ErrorSigsMissingForTx)
+}
+
+// AStateCanaryAttestation
+// - Send canary attestation with the same commitment on the canary staychain
+// - Wait for the canary attestation to confirm or for the canary timeout
+// - add ATimeCanary waiting time while the canary is pending
+func (s *AttestService) doStateCanaryAttestation() {
+	s.logger().Infoln("canary attestation")
+
+	// get last confirmed commitment from server
+	lastCommitmentHash, latestErr := s.server.GetLatestAttestationCommitmentHash()
+	if s.setFailure(latestErr) {
+		return // will rebound to init
+	}
+
+	status, canaryErr := s.canary.Check(s.attestation.CommitmentHash(), lastCommitmentHash)
+	if canaryErr != nil {
+		s.attestationLogger().WithFields(log.Fields{log.FieldError: canaryErr}).Warnln(WarningCanaryFailed)
+	}
+	switch status {
+	case CanaryConfirmed:
+		s.attestationLogger().Infoln("canary attestation confirmed")
+	case CanaryTimeout:
+		s.attestationLogger().Warnln(WarningCanaryTimeout)
+	default:
+		s.attestDelay = ATimeCanary // add canary waiting time
+		return                      // will remain at the same state
+	}
+
+	s.state = s.stateAfterSigning() // update attestation state
+}
+
+// AStateReviewAttestation
+// - Hold signed attestation until the review window has elapsed
+// - If vetoed by an operator, abort attestation and re-initiate
+// - add ATimeReview waiting time while the review window is open
+func (s *AttestService) doStateReviewAttestation() {
+	if s.review == nil { // review disabled since attestation was stored in flight
+		s.state = AStatePreSendStore // update attestation state
+		return
+	}
+
+	if review, pending := s.review.Pending(); !pending || review.Txid != s.attestation.Txid.String() {
+		s.attestationLogger().Infoln("attestation pending review")
+	}
+	status, remaining, reviewErr := s.review.Check(s.attestation)
+	if s.setFailure(reviewErr) {
+		return // will rebound to init
+	}
+	switch status {
+	case ReviewVetoed:
+		s.attestationLogger().Warnln(WarningReviewVetoed)
+		s.state = AStateInit                  // update attestation state
+		s.attestDelay = s.atimeNewAttestation // add new attestation waiting time
+	case ReviewApproved:
+		s.attestationLogger().Infoln("review window elapsed without veto")
+		s.state = AStatePreSendStore // update attestation state
+	default:
+		s.attestationLogger().Debugf("review window open for: %s\n", remaining.String())
+		s.attestDelay = ATimeReview // add review waiting time
+		if remaining < s.attestDelay {
+			s.attestDelay = remaining
+		}
+	}
+}
+
+// part of AStatePreSendStore
+// - Store would-be attestation transaction to server in dry run mode
+// - Skip sending and confirmation and move on to the next commitment
+// - add ATIME_NEW_ATTESTATION waiting time
+func (s *AttestService) stateDryRunStore() {
+	var txBytes bytes.Buffer
+	if s.setFailure(s.attestation.Tx.Serialize(&txBytes)) {
+		return // will rebound to init
+	}
+	txHex := hex.EncodeToString(txBytes.Bytes())
+	s.attestationLogger().Infof("dry run - attestation transaction not sent\ntx: %s\n", txHex)
+	if requestIds := s.attestationRequestIds(); requestIds != "" {
+		s.attestationLogger().WithFields(log.Fields{log.FieldRequestIds: requestIds}).Infoln("attestation request ids")
+	}
+
+	errStore := s.server.RecordDryRunAttestation(models.DryRunAttestation{
+		Txid:       s.attestation.Txid.String(),
+		MerkleRoot: s.attestation.CommitmentHash().String(),
+		Tx:         txHex,
+		Time:       clock.Now().Unix(),
+	})
+	if s.setFailure(errStore) {
+		return // will rebound to init
+	}
+
+	s.state = AStateNextCommitment                         // update attestation state
+	s.attestDelay = s.staggerDelay(s.newAttestationTime()) // add new attestation waiting time
 }
 
 // AStatePreSendStore
 // - Store unconfirmed attestation to server prior to sending
+// - In dry run mode store the would-be attestation transaction instead
 func (s *AttestService) doStatePreSendStore() {
-	log.Infoln("*AttestService* PRE SEND STORE")
+	s.logger().Infoln("pre send store")
+
+	if s.config.DryRun() {
+		s.stateDryRunStore()
+		return
+	}
 
 	// update server with latest unconfirmed attestation, in case the service fails
 	errUpdate := s.server.UpdateLatestAttestation(*s.attestation)
@@ -520,54 +1360,124 @@ func (s *AttestService) doStatePreSendStore() {
 // - add ATimeConfirmation waiting time
 // - start time for confirmation time
 func (s *AttestService) doStateSendAttestation() {
-	log.Infoln("*AttestService* SEND ATTESTATION")
+	s.logger().Infoln("send attestation")
 
 	// sign attestation with combined signatures and send through client to network
 	txid, attestationErr := s.attester.sendAttestation(&s.attestation.Tx)
+	if attestationErr != nil && s.isFeeBumped && s.cpfpParent == nil && !ClassifyError(attestationErr).IsTransient() {
+		// fee bumped replacement rejected - fall back to cpfp on next handle unconfirmed
+		s.attestationLogger().WithFields(log.Fields{log.FieldError: attestationErr}).Warnln("fee bumped replacement rejected")
+		s.isRbfRejected = true
+	}
 	if s.setFailure(attestationErr) {
 		return // will rebound to init
 	}
 	s.attestation.Txid = txid
-	log.Infof("********** attestation transaction committed with txid: (%s)\n", txid)
+	s.attestationLogger().Infoln("attestation transaction committed")
+	if rotationErr := s.updateKeyRotation(s.attestation); rotationErr != nil {
+		s.attestationLogger().WithFields(log.Fields{log.FieldError: rotationErr}).Warnln(WarningRotationSaveFailed)
+	}
+	if s.isFeeBumped || s.cpfpParent != nil {
+		s.notify(models.AttestationEventFeeBumped, "")
+	} else {
+		s.notify(models.AttestationEventBroadcast, "")
+	}
+	if requestIds := s.attestationRequestIds(); requestIds != "" {
+		s.attestationLogger().WithFields(log.Fields{log.FieldRequestIds: requestIds}).Infoln("attestation request ids")
+	}
 
-	s.state = AStateAwaitConfirmation // update attestation state
-	attestDelay = ATimeConfirmation   // add confirmation waiting time
-	confirmTime = time.Now()          // set time for awaiting confirmation
-	isFeeBumped = false               // reset fee bumped flag
+	s.state = AStateAwaitConfirmation   // update attestation state
+	s.attestDelay = s.atimeConfirmation // add confirmation waiting time
+	s.confirmTime = clock.Now()         // set time for awaiting confirmation
+	s.isFeeBumped = false               // reset fee bumped flag
+	if s.cpfpParent != nil {
+		s.isRbfRejected = false // child transaction can be replaced as usual
+	}
 }
 
 // AStateAwaitConfirmation
 // - Check if the attestation transaction has been confirmed in the main network
+//   with the configured confirmation depth
 // - If confirmed, initiate new attestation, update server and signer clients
 // - Check if ATIME_HANDLE_UNCONFIRMED has elapsed since attestation was sent
+//   without the attestation being included in a block
 // - add ATIME_NEW_ATTESTATION if confirmed or ATimeConfirmation if not to waiting time
 func (s *AttestService) doStateAwaitConfirmation() {
-	log.Infof("*AttestService* AWAITING CONFIRMATION \ntxid: (%s)\ncommitment: (%s)\n", s.attestation.Txid.String(), s.attestation.CommitmentHash().String())
+	s.attestationLogger().Infoln("awaiting confirmation")
+
+	newTx, err := s.attester.Chain.GetTransaction(&s.attestation.Txid)
+	if s.setFailure(err) {
+		return // will rebound to init
+	}
 
 	// if attestation has been unconfirmed for too long
 	// set to handle unconfirmed state
-	if time.Since(confirmTime) > atimeHandleUnconfirmed {
+	if newTx.BlockHash == "" && since(s.confirmTime) > s.handleUnconfirmedDelay(s.feeBumps) {
 		s.state = AStateHandleUnconfirmed
 		return
 	}
 
-	newTx, err := s.config.MainClient().GetTransaction(&s.attestation.Txid)
-	if s.setFailure(err) {
-		return // will rebound to init
+	// attestation included in a block but not yet at the confirmation
+	// depth - keep waiting, as the block may still be reorged out
+	if newTx.BlockHash != "" && newTx.Confirmations < s.confirmationDepth {
+		s.attestationLogger().Infof("attestation awaiting confirmation depth (%d of %d)\n",
+			newTx.Confirmations, s.confirmationDepth)
+		s.attestDelay = s.atimeConfirmation
+		return
+	}
+
+	// confirmation not agreed by the quorum nodes - keep waiting without
+	// handling as unconfirmed, as the attestation cannot be replaced if
+	// the main client is correct
+	if newTx.BlockHash != "" && !s.isQuorumConfirmed(newTx.BlockHash) {
+		s.confirmTime = clock.Now()
+		s.attestDelay = s.atimeConfirmation
+		return
 	}
 
 	if newTx.BlockHash != "" {
-		log.Infof("********** attestation confirmed with txid: (%s)\n", s.attestation.Txid.String())
+		s.attestationLogger().Infoln("attestation confirmed")
+		if requestIds := s.attestationRequestIds(); requestIds != "" {
+			s.attestationLogger().WithFields(log.Fields{log.FieldRequestIds: requestIds}).Infoln("attestation request ids")
+		}
+
+		// parent of cpfp child is confirmed in the same or an earlier block
+		if s.cpfpParent != nil {
+			parentTx, parentErr := s.attester.Chain.GetTransaction(&s.cpfpParent.Txid)
+			if s.setFailure(parentErr) {
+				return // will rebound to init
+			}
+			parentHeight, parentHeightErr := s.attester.getConfirmationHeight(parentTx)
+			if s.setFailure(parentHeightErr) {
+				return // will rebound to init
+			}
+			s.cpfpParent.Confirmed = true
+			s.cpfpParent.UpdateInfo(parentTx, parentHeight)
+			errUpdate := s.server.UpdateLatestAttestation(*s.cpfpParent)
+			if s.setFailure(errUpdate) {
+				return // will rebound to init
+			}
+			s.cpfpParent = nil
+		}
 
 		// update server with latest confirmed attestation
+		height, heightErr := s.attester.getConfirmationHeight(newTx)
+		if s.setFailure(heightErr) {
+			return // will rebound to init
+		}
 		s.attestation.Confirmed = true
-		s.attestation.UpdateInfo(newTx)
+		s.attestation.UpdateInfo(newTx, height)
 		errUpdate := s.server.UpdateLatestAttestation(*s.attestation)
 		if s.setFailure(errUpdate) {
 			return // will rebound to init
 		}
+		if s.setFailure(s.updateKeyRotation(s.attestation)) {
+			return // will rebound to init
+		}
+		s.notify(models.AttestationEventConfirmed, newTx.BlockHash)
 
 		s.attester.Fees.ResetFee(s.isRegtest) // reset client fees
+		s.feeBumps = 0                        // reset fee bumps
 
 		confirmedHash := s.attestation.CommitmentHash()
 		if s.attester.txid0 == s.attestation.Txid.String() {
@@ -578,28 +1488,155 @@ func (s *AttestService) doStateAwaitConfirmation() {
 		s.state = AStateNextCommitment // update attestation state
 		// add new attestation waiting time with confimation time and signature
 		// waiting time subtracted so that attestations are ~1 hour apart
-		attestDelay = atimeNewAttestation - time.Since(confirmTime) - ATimeSigs
+		s.attestDelay = s.staggerDelay(s.newAttestationTime() - since(s.confirmTime) - ATimeSigsPoll)
 	} else {
-		attestDelay = ATimeConfirmation // add confirmation waiting time
+		s.attestDelay = s.atimeConfirmation // add confirmation waiting time
 	}
 }
 
+// part of AStateAwaitConfirmation
+// check that the quorum nodes agree on the confirmation reported by the main client
+func (s *AttestService) isQuorumConfirmed(blockHash string) bool {
+	if s.quorum == nil {
+		return true
+	}
+	confirmed, agreed := s.quorum.Confirmed(s.attestation.Txid, blockHash)
+	if !confirmed {
+		s.attestationLogger().Warnf("%s (%d of %d)\n", WarningQuorumNotReached, agreed, s.quorum.threshold)
+	}
+	return confirmed
+}
+
+// part of AStateHandleUnconfirmed
+// handle case when the unconfirmed attestation cannot be replaced by fee
+// create a child transaction spending the unconfirmed attestation output
+// to the same address, paying a higher fee for both parent and child (CPFP)
+func (s *AttestService) stateHandleUnconfirmedCpfp() {
+	s.attestationLogger().Infoln("creating cpfp child for attestation")
+
+	// the transition attestation of a key rotation pays to the new script,
+	// which the service cannot spend until the rotation is activated
+	if s.isRotationTx(&s.attestation.Tx, s.attestation.CommitmentHash()) {
+		s.attestationLogger().Warnln(WarningRotationCpfp)
+		s.state = AStateAwaitConfirmation
+		s.attestDelay = s.atimeConfirmation
+		s.confirmTime = clock.Now()
+		return
+	}
+
+	// get fee already paid by the parent from the mempool
+	parentEntry, parentErr := s.attester.Chain.GetMempoolEntry(s.attestation.Txid.String())
+	if s.setFailure(parentErr) {
+		return // will rebound to init
+	}
+	parentFee := int64(parentEntry.Fee * float64(Coin))
+
+	// child pays to the same address as the parent, attesting the same commitment
+	parentHash := s.attestation.CommitmentHash()
+	key, keyErr := s.attester.GetNextAttestationKey(parentHash)
+	if s.setFailure(keyErr) {
+		return // will rebound to init
+	}
+	paytoaddr, _, addrErr := s.attester.GetNextAttestationAddr(key, parentHash)
+	if s.setFailure(addrErr) {
+		return // will rebound to init
+	}
+
+	prevFee := s.attester.Fees.GetPrevFee()
+	if !s.isFeeBumped {
+		prevFee = s.attester.Fees.GetFee()
+		s.attester.Fees.BumpFee()
+	}
+	s.isFeeBumped = true
+	childTx, createErr := s.attester.createAttestationChild(paytoaddr, &s.attestation.Tx, parentFee)
+	s.recordFeeBump(models.FeeBumpMethodCpfp, prevFee, createErr)
+	if s.setFailure(createErr) {
+		return // will rebound to init
+	}
+
+	// initialise child attestation with the parent commitment
+	commitment, commitmentErr := s.attestation.Commitment()
+	if s.setFailure(commitmentErr) {
+		return // will rebound to init
+	}
+	s.cpfpParent = s.attestation
+	s.attestation = models.NewAttestationDefault()
+	s.attestation.SetCommitment(commitment)
+	s.attestation.Tx = *childTx
+	s.logger().WithFields(log.Fields{log.FieldTxid: s.attestation.Tx.TxHash().String()}).Infoln("pre-sign child attestation")
+
+	// request signatures for spending the parent attestation output
+	parentTxid := s.cpfpParent.Txid
+	rawTx, rawTxErr := s.attester.Chain.GetRawTransactionVerbose(&parentTxid)
+	if s.setFailure(rawTxErr) {
+		return // will rebound to init
+	}
+	asmList := strings.Split(rawTx.Vin[0].ScriptSig.Asm, " ")
+	redeemScript := asmList[len(asmList)-1]
+	s.requestSigs(rawTx.Hash, redeemScript, parentHash.String())
+
+	// publish pre signed child transaction
+	txPreImages, getPreImagesErr := s.attester.getTransactionPreImages(parentHash, childTx)
+	if s.setFailure(getPreImagesErr) {
+		return // will rebound to init
+	}
+	// get pre image bytes
+	var txPreImageBytes [][]byte
+	for _, txPreImage := range txPreImages {
+		var txBytesBuffer bytes.Buffer
+		txPreImage.Serialize(&txBytesBuffer)
+		txPreImageBytes = append(txPreImageBytes, txBytesBuffer.Bytes())
+	}
+	s.signer.ReSubscribe()
+	s.signer.SendTxPreImages(txPreImageBytes)
+
+	s.state = AStateSignAttestation // update attestation state
+	s.attestDelay = ATimeSigsPoll   // add sigs polling time
+}
+
 // AStateHandleUnconfirmed
 // - Handle attestations that have been unconfirmed for too long
 // - Bump attestation fees and re-initiate sign and send process
+// - If the attestation cannot be replaced, fall back to a cpfp child transaction
 func (s *AttestService) doStateHandleUnconfirmed() {
-	log.Infoln("*AttestService* HANDLE UNCONFIRMED")
+	s.attestationLogger().Infoln("handle unconfirmed")
+
+	// stop bumping once the max number of fee bumps has been reached
+	// and keep waiting for the attestation to be confirmed
+	if !s.isFeeBumped {
+		if s.maxFeeBumps >= 0 && s.feeBumps >= s.maxFeeBumps {
+			s.attestationLogger().Infof("max fee bumps reached (%d)\n", s.maxFeeBumps)
+			s.state = AStateAwaitConfirmation
+			s.attestDelay = s.atimeConfirmation
+			s.confirmTime = clock.Now()
+			return
+		}
+		s.feeBumps++
+	}
+
+	// replacement previously rejected or inputs not signaling rbf
+	if s.isRbfRejected || !signalsReplaceByFee(&s.attestation.Tx) {
+		s.stateHandleUnconfirmedCpfp()
+		return
+	}
 
-	log.Infof("********** bumping fees for attestation txid: %s\n", s.attestation.Tx.TxHash().String())
+	s.attestationLogger().Infoln("bumping fees for attestation")
 	currentTx := &s.attestation.Tx
-	bumpErr := s.attester.bumpAttestationFees(currentTx, isFeeBumped)
-	if s.setFailure(bumpErr) {
-		return // will rebound to init
+	prevFee := s.attester.Fees.GetPrevFee()
+	if !s.isFeeBumped {
+		prevFee = s.attester.Fees.GetFee()
+	}
+	bumpErr := s.attester.bumpAttestationFees(currentTx, s.isFeeBumped)
+	s.recordFeeBump(models.FeeBumpMethodRbf, prevFee, bumpErr)
+	if bumpErr != nil {
+		s.attestationLogger().WithFields(log.Fields{log.FieldError: bumpErr}).Warnln("fee bumping failed")
+		s.stateHandleUnconfirmedCpfp()
+		return
 	}
-	isFeeBumped = true
+	s.isFeeBumped = true
 
 	s.attestation.Tx = *currentTx
-	log.Infof("********** new pre-sign txid: %s\n", s.attestation.Tx.TxHash().String())
+	s.logger().WithFields(log.Fields{log.FieldTxid: s.attestation.Tx.TxHash().String()}).Infoln("new pre-sign attestation")
 
 	// get last confirmed commitment from server
 	lastCommitmentHash, latestErr := s.server.GetLatestAttestationCommitmentHash()
@@ -608,7 +1645,7 @@ func (s *AttestService) doStateHandleUnconfirmed() {
 	}
 
 	if s.attester.txid0 == s.attestation.Tx.TxIn[0].PreviousOutPoint.Hash.String() {
-		log.Infoln("********** base transaction, zero tweaking for signature")
+		s.logger().Infoln("base transaction, zero tweaking for signature")
 		lastCommitmentHash = chainhash.Hash{}
 	}
 
@@ -626,9 +1663,79 @@ func (s *AttestService) doStateHandleUnconfirmed() {
 	}
 	s.signer.ReSubscribe()
 	s.signer.SendTxPreImages(txPreImageBytes)
+	s.sigsTime = clock.Now() // collect sigs of the replacement
 
 	s.state = AStateSignAttestation // update attestation state
-	attestDelay = ATimeSigs         // add sigs waiting time
+	s.attestDelay = ATimeSigsPoll   // add sigs polling time
+}
+
+// Return waiting time until handling an unconfirmed attestation
+// Uses the bump schedule if configured, repeating the last entry,
+// or the handle unconfirmed time otherwise
+func (s *AttestService) handleUnconfirmedDelay(bumps int) time.Duration {
+	if len(s.atimeBumpSchedule) == 0 {
+		return s.atimeHandleUnconfirmed
+	}
+	if bumps >= len(s.atimeBumpSchedule) {
+		return s.atimeBumpSchedule[len(s.atimeBumpSchedule)-1]
+	}
+	return s.atimeBumpSchedule[bumps]
+}
+
+// Return ids of the api requests that set the client commitments of the
+// current attestation, ordered by client position, for tracing in logs
+func (s *AttestService) attestationRequestIds() string {
+	commitment, commitmentErr := s.attestation.Commitment()
+	if commitmentErr != nil || len(commitment.RequestIds()) == 0 {
+		return ""
+	}
+	var positions []int
+	for position := range commitment.RequestIds() {
+		positions = append(positions, int(position))
+	}
+	sort.Ints(positions)
+
+	var requestIds []string
+	for _, position := range positions {
+		requestIds = append(requestIds, fmt.Sprintf("%d:%s", position, commitment.RequestIds()[int32(position)]))
+	}
+	return strings.Join(requestIds, ",")
+}
+
+// Record fee bump attempt for the current unconfirmed attestation
+// Failure to record is only logged as it should not affect attesting
+func (s *AttestService) recordFeeBump(method string, prevFee int, bumpErr error) {
+	feeBump := models.FeeBump{
+		Txid:       s.attestation.Txid.String(),
+		MerkleRoot: s.attestation.CommitmentHash().String(),
+		Attempt:    int32(s.feeBumps),
+		Method:     method,
+		Strategy:   s.attester.Fees.GetBumpStrategy(),
+		PrevFee:    int32(prevFee),
+		Fee:        int32(s.attester.Fees.GetFee()),
+		Time:       clock.Now().Unix(),
+	}
+	if bumpErr != nil {
+		feeBump.Error = bumpErr.Error()
+	}
+	if err := s.server.RecordFeeBump(feeBump); err != nil {
+		s.attestationLogger().WithFields(log.Fields{log.FieldError: err}).Warnln(WarningFeeBumpRecordFailed)
+	}
+}
+
+// Notify attestation event for the current attestation if a notifier is set
+func (s *AttestService) notify(event string, blockhash string) {
+	if s.notifier == nil {
+		return
+	}
+	s.notifier.Notify(models.AttestationEvent{
+		Event:      event,
+		Txid:       s.attestation.Txid.String(),
+		Commitment: s.attestation.CommitmentHash().String(),
+		Blockhash:  blockhash,
+		Fee:        s.attester.Fees.GetFee(),
+		Time:       clock.Now().Unix(),
+	})
 }
 
 //Main attestation service method - cycles through AttestationStates
@@ -636,7 +1743,15 @@ func (s *AttestService) doAttestation() {
 
 	// fixed waiting time between states specific states might
 	// re-write this to set specific waiting times
-	attestDelay = ATimeFixed
+	s.attestDelay = s.atimeFixed
+
+	// reset in place retries unless the state failed again
+	retries := s.stateRetries
+	defer func() {
+		if s.stateRetries == retries {
+			s.stateRetries = 0
+		}
+	}()
 
 	switch s.state {
 
@@ -664,17 +1779,71 @@ func (s *AttestService) doAttestation() {
 	case AStateAwaitConfirmation:
 		s.doStateAwaitConfirmation()
 
+	case AStateCanaryAttestation:
+		s.doStateCanaryAttestation()
+
+	case AStateReviewAttestation:
+		s.doStateReviewAttestation()
+
 	case AStateHandleUnconfirmed:
 		s.doStateHandleUnconfirmed()
 	}
 }
 
+// Return log entry with the current attestation state
+func (s *AttestService) logger() *log.Entry {
+	return log.WithFields(log.Fields{log.FieldState: s.state})
+}
+
+// Return log entry with the current attestation state, txid and commitment
+func (s *AttestService) attestationLogger() *log.Entry {
+	return s.logger().WithFields(log.Fields{
+		log.FieldTxid:       s.attestation.Txid.String(),
+		log.FieldCommitment: s.attestation.CommitmentHash().String()})
+}
+
 // Check if there is an error and set error state
+// Transient failures are retried in place with backoff, remaining
+// at the same state, until the retry policy is exhausted
 func (s *AttestService) setFailure(err error) bool {
 	if err != nil {
+		class := ClassifyError(err)
+		if s.isRetryableState() && s.retryPolicy.ShouldRetry(class, s.stateRetries) {
+			s.stateRetries++
+			s.attestDelay = s.retryPolicy.Delay(s.stateRetries)
+			s.logger().WithFields(log.Fields{log.FieldError: err.Error(), log.FieldErrorClass: class.String()}).Warnf(
+				"%s (%d/%d) in %s\n", WarningTransientFailure, s.stateRetries, s.retryPolicy.MaxRetries, s.attestDelay.String())
+			return true // will remain at the same state
+		}
+		s.alert(err, class)
 		s.errorState = err
 		s.state = AStateError
+		s.stateRetries = 0
 		return true
 	}
 	return false
 }
+
+// Return true if the current state can be repeated after a failure
+// Handling unconfirmed attestations is excluded as fee bumps are not
+// idempotent and errors are never retried in place
+func (s *AttestService) isRetryableState() bool {
+	return s.state != AStateError && s.state != AStateHandleUnconfirmed
+}
+
+// Alert failure of the current state if an alerter is set
+func (s *AttestService) alert(err error, class ErrorClass) {
+	if s.alerter == nil {
+		return
+	}
+	alert := models.Alert{
+		Error:     err.Error(),
+		Class:     class.String(),
+		PrevState: s.state.String(),
+		Time:      clock.Now().Unix(),
+	}
+	if s.attestation != nil && !s.attestation.Txid.IsEqual(&chainhash.Hash{}) {
+		alert.Txid = s.attestation.Txid.String()
+	}
+	s.alerter.Alert(alert)
+}
@@ -5,19 +5,29 @@
 package attestation
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"testing"
 	"time"
 
 	confpkg "mainstay/config"
+	"mainstay/crypto"
 	"mainstay/db"
 	"mainstay/models"
 	"mainstay/test"
+	"mainstay/tracing"
 
+	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 // verify AStateInit
@@ -44,7 +54,7 @@ func verifyStateInitToNextCommitment(t *testing.T, attestService *AttestService)
 	assert.Equal(t, chainhash.Hash{}, attestService.attestation.Txid)
 	assert.Equal(t, false, attestService.attestation.Confirmed)
 	assert.Equal(t, models.AttestationInfo{}, attestService.attestation.Info)
-	assert.Equal(t, ATimeFixed, attestDelay)
+	assert.Equal(t, ATimeFixed, attestService.attestDelay)
 }
 
 // verify AStateInit to AStateAwaitConfirmation
@@ -55,19 +65,19 @@ func verifyStateInitToAwaitConfirmation(t *testing.T, attestService *AttestServi
 	assert.Equal(t, latestCommitment.GetCommitmentHash(), attestService.attestation.CommitmentHash())
 	assert.Equal(t, txid, attestService.attestation.Txid)
 	assert.Equal(t, false, attestService.attestation.Confirmed)
-	assert.Equal(t, walletTx.Time, confirmTime.Unix())
+	assert.Equal(t, walletTx.Time, attestService.confirmTime.Unix())
 	assert.Equal(t, models.AttestationInfo{}, attestService.attestation.Info)
 }
 
 // verify AStateNextCommitment to AStateNewAttestation
 func verifyStateNextCommitmentToNewAttestation(t *testing.T, attestService *AttestService, dbFake *db.DbFake, hash *chainhash.Hash) *models.Commitment {
 	latestCommitment, _ := models.NewCommitment([]chainhash.Hash{*hash})
-	latestCommitments := []models.ClientCommitment{models.ClientCommitment{*hash, 0}}
+	latestCommitments := []models.ClientCommitment{models.ClientCommitment{*hash, 0, "", 0}}
 	dbFake.SetClientCommitments(latestCommitments)
 	attestService.doAttestation()
 	assert.Equal(t, AStateNewAttestation, attestService.state)
 	assert.Equal(t, latestCommitment.GetCommitmentHash(), attestService.attestation.CommitmentHash())
-	assert.Equal(t, ATimeFixed, attestDelay)
+	assert.Equal(t, ATimeFixed, attestService.attestDelay)
 
 	return latestCommitment
 }
@@ -80,7 +90,7 @@ func verifyStateNewAttestationToSignAttestation(t *testing.T, attestService *Att
 	assert.Equal(t, 1, len(attestService.attestation.Tx.TxIn))
 	assert.Equal(t, 1, len(attestService.attestation.Tx.TxOut))
 	assert.Equal(t, 0, len(attestService.attestation.Tx.TxIn[0].SignatureScript))
-	assert.Equal(t, ATimeSigs, attestDelay)
+	assert.Equal(t, ATimeSigsPoll, attestService.attestDelay)
 }
 
 // verify AStateSignAttestation to AStatePreSendStore
@@ -89,21 +99,21 @@ func verifyStateSignAttestationToPreSendStore(t *testing.T, attestService *Attes
 	attestService.doAttestation()
 	assert.Equal(t, AStatePreSendStore, attestService.state)
 	assert.Equal(t, true, len(attestService.attestation.Tx.TxIn[0].SignatureScript) > 0)
-	assert.Equal(t, ATimeFixed, attestDelay)
+	assert.Equal(t, ATimeFixed, attestService.attestDelay)
 }
 
 // verify AStatePreSendStore to AStateSendAttestation
 func verifyStatePreSendStoreToSendAttestation(t *testing.T, attestService *AttestService) {
 	attestService.doAttestation()
 	assert.Equal(t, AStateSendAttestation, attestService.state)
-	assert.Equal(t, ATimeFixed, attestDelay)
+	assert.Equal(t, ATimeFixed, attestService.attestDelay)
 }
 
 // verify AStateSendAttestation to AStateAwaitConfirmation
 func verifyStateSendAttestationToAwaitConfirmation(t *testing.T, attestService *AttestService) chainhash.Hash {
 	attestService.doAttestation()
 	assert.Equal(t, AStateAwaitConfirmation, attestService.state)
-	assert.Equal(t, ATimeConfirmation, attestDelay)
+	assert.Equal(t, ATimeConfirmation, attestService.attestDelay)
 	return attestService.attestation.Txid
 }
 
@@ -111,7 +121,14 @@ func verifyStateSendAttestationToAwaitConfirmation(t *testing.T, attestService *
 func verifyStateAwaitConfirmationToAwaitConfirmation(t *testing.T, attestService *AttestService) {
 	attestService.doAttestation()
 	assert.Equal(t, AStateAwaitConfirmation, attestService.state)
-	assert.Equal(t, ATimeConfirmation, attestDelay)
+	assert.Equal(t, ATimeConfirmation, attestService.attestDelay)
+}
+
+// return height of block from main client
+func blockHeight(config *confpkg.Config, blockHash string) int64 {
+	hash, _ := chainhash.NewHashFromStr(blockHash)
+	header, _ := config.MainClient().GetBlockHeaderVerbose(hash)
+	return int64(header.Height)
 }
 
 // verify AStateAwaitConfirmation to AStateNextCommitment
@@ -124,14 +141,15 @@ func verifyStateAwaitConfirmationToNextCommitment(t *testing.T, attestService *A
 	assert.Equal(t, AStateNextCommitment, attestService.state)
 	assert.Equal(t, true, attestService.attestation.Confirmed)
 	assert.Equal(t, txid, attestService.attestation.Txid)
-	assert.Equal(t, true, attestDelay < timeNew)
-	assert.Equal(t, true, attestDelay+ATimeSigs > (timeNew-time.Since(confirmTime)))
+	assert.Equal(t, true, attestService.attestDelay < timeNew)
+	assert.Equal(t, true, attestService.attestDelay+ATimeSigsPoll > (timeNew-time.Since(attestService.confirmTime)))
 	assert.Equal(t,
 		models.AttestationInfo{
 			Txid:      txid.String(),
 			Blockhash: walletTx.BlockHash,
 			Amount:    rawTx.MsgTx().TxOut[0].Value,
-			Time:      walletTx.Time},
+			Time:      walletTx.Time,
+			Height:    blockHeight(config, walletTx.BlockHash)},
 		attestService.attestation.Info,
 	)
 }
@@ -150,7 +168,7 @@ func verifyStateHandleUnconfirmedToSignAttestation(t *testing.T, attestService *
 	assert.Equal(t, 1, len(attestService.attestation.Tx.TxIn))
 	assert.Equal(t, 1, len(attestService.attestation.Tx.TxOut))
 	assert.Equal(t, 0, len(attestService.attestation.Tx.TxIn[0].SignatureScript))
-	assert.Equal(t, ATimeSigs, attestDelay)
+	assert.Equal(t, ATimeSigsPoll, attestService.attestDelay)
 	assert.Equal(t, attestService.attester.Fees.minFee+attestService.attester.Fees.feeIncrement,
 		attestService.attester.Fees.GetFee())
 }
@@ -166,7 +184,7 @@ func TestAttestService_Regular(t *testing.T) {
 
 	// randomly test with invalid config here
 	// timing config no effect on server
-	timingConfig := confpkg.TimingConfig{-1, -1}
+	timingConfig := confpkg.TimingConfig{-1, -1, -1, -1, -1, -1, -1}
 	config.SetTimingConfig(timingConfig)
 
 	dbFake := db.NewDbFake()
@@ -184,7 +202,7 @@ func TestAttestService_Regular(t *testing.T) {
 	attestService.doAttestation()
 	assert.Equal(t, AStateError, attestService.state)
 	assert.Equal(t, errors.New(models.ErrorCommitmentListEmpty), attestService.errorState)
-	assert.Equal(t, ATimeFixed, attestDelay)
+	assert.Equal(t, ATimeFixed, attestService.attestDelay)
 
 	// Test AStateError -> AStateInit -> AStateNextCommitment again
 	attestService.doAttestation()
@@ -216,7 +234,7 @@ func TestAttestService_Regular(t *testing.T) {
 	attestService.doAttestation()
 	assert.Equal(t, AStateNextCommitment, attestService.state)
 	assert.Equal(t, latestCommitment.GetCommitmentHash(), attestService.attestation.CommitmentHash())
-	assert.Equal(t, ATimeSkip, attestDelay)
+	assert.Equal(t, ATimeSkip, attestService.attestDelay)
 
 	// Test AStateNextCommitment -> AStateNewAttestation
 	// stuck in next commitment
@@ -247,7 +265,7 @@ func TestAttestService_Unconfirmed(t *testing.T) {
 	// randomly test custom config here
 	customAtimeNewAttestation := 5
 	customAtimeHandleUnconfirmed := 10
-	timingConfig := confpkg.TimingConfig{customAtimeNewAttestation, customAtimeHandleUnconfirmed}
+	timingConfig := confpkg.TimingConfig{customAtimeNewAttestation, customAtimeHandleUnconfirmed, -1, -1, -1, -1, -1}
 	config.SetTimingConfig(timingConfig)
 
 	dbFake := db.NewDbFake()
@@ -277,7 +295,7 @@ func TestAttestService_Unconfirmed(t *testing.T) {
 	txid := verifyStateSendAttestationToAwaitConfirmation(t, attestService)
 
 	// set confirm time back to test what happens in handle unconfirmed case
-	confirmTime = confirmTime.Add(-time.Duration(customAtimeHandleUnconfirmed) * time.Minute)
+	attestService.confirmTime = attestService.confirmTime.Add(-time.Duration(customAtimeHandleUnconfirmed) * time.Minute)
 
 	// Test AStateAwaitConfirmation -> AStateHandleUnconfirmed
 	verifyStateAwaitConfirmationToHandleUnconfirmed(t, attestService)
@@ -315,7 +333,7 @@ func TestAttestService_Unconfirmed(t *testing.T) {
 	assert.Equal(t, 1, len(attestService.attestation.Tx.TxOut))
 	assert.Equal(t, 0, len(attestService.attestation.Tx.TxIn[0].SignatureScript))
 	assert.Equal(t, 0, len(attestService.attestation.Tx.TxIn[1].SignatureScript))
-	assert.Equal(t, ATimeSigs, attestDelay)
+	assert.Equal(t, ATimeSigsPoll, attestService.attestDelay)
 	assert.Equal(t, attestService.attester.Fees.minFee, attestService.attester.Fees.GetFee())
 	// Test AStateSignAttestation -> AStatePreSendStore
 	verifyStateSignAttestationToPreSendStore(t, attestService)
@@ -325,7 +343,7 @@ func TestAttestService_Unconfirmed(t *testing.T) {
 	txid = verifyStateSendAttestationToAwaitConfirmation(t, attestService)
 
 	// set confirm time back to test what happens in handle unconfirmed case
-	confirmTime = confirmTime.Add(-time.Duration(customAtimeHandleUnconfirmed) * time.Minute)
+	attestService.confirmTime = attestService.confirmTime.Add(-time.Duration(customAtimeHandleUnconfirmed) * time.Minute)
 
 	// Test AStateAwaitConfirmation -> AStateHandleUnconfirmed
 	verifyStateAwaitConfirmationToHandleUnconfirmed(t, attestService)
@@ -337,7 +355,7 @@ func TestAttestService_Unconfirmed(t *testing.T) {
 	assert.Equal(t, 1, len(attestService.attestation.Tx.TxOut))
 	assert.Equal(t, 0, len(attestService.attestation.Tx.TxIn[0].SignatureScript))
 	assert.Equal(t, 0, len(attestService.attestation.Tx.TxIn[1].SignatureScript))
-	assert.Equal(t, ATimeSigs, attestDelay)
+	assert.Equal(t, ATimeSigsPoll, attestService.attestDelay)
 	assert.Equal(t, attestService.attester.Fees.minFee+attestService.attester.Fees.feeIncrement,
 		attestService.attester.Fees.GetFee())
 
@@ -363,7 +381,7 @@ func TestAttestService_WithTopup(t *testing.T) {
 
 	// randomly test with invalid config here
 	// timing config no effect on server
-	timingConfig := confpkg.TimingConfig{-1, -1}
+	timingConfig := confpkg.TimingConfig{-1, -1, -1, -1, -1, -1, -1}
 	config.SetTimingConfig(timingConfig)
 
 	dbFake := db.NewDbFake()
@@ -414,7 +432,7 @@ func TestAttestService_WithTopup(t *testing.T) {
 	assert.Equal(t, 1, len(attestService.attestation.Tx.TxOut))
 	assert.Equal(t, 0, len(attestService.attestation.Tx.TxIn[0].SignatureScript))
 	assert.Equal(t, 0, len(attestService.attestation.Tx.TxIn[1].SignatureScript))
-	assert.Equal(t, ATimeSigs, attestDelay)
+	assert.Equal(t, ATimeSigsPoll, attestService.attestDelay)
 
 	// Test AStateSignAttestation -> AStatePreSendStore
 	verifyStateSignAttestationToPreSendStore(t, attestService)
@@ -703,10 +721,10 @@ func TestAttestService_FailureSendAttestation(t *testing.T) {
 		assert.Equal(t, prevAttestation.Confirmed, attestService.attestation.Confirmed)
 		assert.Equal(t, prevAttestation.Info, attestService.attestation.Info)
 		if attestService.attestation.Info.Time == 0 {
-			assert.Equal(t, ATimeFixed, attestDelay)
+			assert.Equal(t, ATimeFixed, attestService.attestDelay)
 		} else {
-			assert.Empty(t, attestDelay < ATimeFixed)
-			assert.Empty(t, atimeNewAttestation < attestDelay)
+			assert.Empty(t, attestService.attestDelay < ATimeFixed)
+			assert.Empty(t, attestService.atimeNewAttestation < attestService.attestDelay)
 		}
 
 		// Test AStateNextCommitment -> AStateNewAttestation
@@ -753,7 +771,8 @@ func TestAttestService_FailureSendAttestation(t *testing.T) {
 				Txid:      txid.String(),
 				Blockhash: walletTx.BlockHash,
 				Amount:    rawTx.MsgTx().TxOut[0].Value,
-				Time:      walletTx.Time},
+				Time:      walletTx.Time,
+				Height:    blockHeight(config, walletTx.BlockHash)},
 			attestService.attestation.Info,
 		)
 
@@ -771,7 +790,8 @@ func TestAttestService_FailureSendAttestation(t *testing.T) {
 				Txid:      txid.String(),
 				Blockhash: walletTx.BlockHash,
 				Amount:    rawTx.MsgTx().TxOut[0].Value,
-				Time:      walletTx.Time},
+				Time:      walletTx.Time,
+				Height:    blockHeight(config, walletTx.BlockHash)},
 			attestService.attestation.Info,
 		)
 
@@ -826,7 +846,8 @@ func TestAttestService_FailureAwaitConfirmation(t *testing.T) {
 			Txid:      txid.String(),
 			Blockhash: walletTx.BlockHash,
 			Amount:    rawTx.MsgTx().TxOut[0].Value,
-			Time:      walletTx.Time},
+			Time:      walletTx.Time,
+			Height:    blockHeight(config, walletTx.BlockHash)},
 		attestService.attestation.Info,
 	)
 
@@ -843,7 +864,8 @@ func TestAttestService_FailureAwaitConfirmation(t *testing.T) {
 			Txid:      txid.String(),
 			Blockhash: walletTx.BlockHash,
 			Amount:    rawTx.MsgTx().TxOut[0].Value,
-			Time:      walletTx.Time},
+			Time:      walletTx.Time,
+			Height:    blockHeight(config, walletTx.BlockHash)},
 		attestService.attestation.Info,
 	)
 
@@ -860,7 +882,8 @@ func TestAttestService_FailureAwaitConfirmation(t *testing.T) {
 			Txid:      txid.String(),
 			Blockhash: walletTx.BlockHash,
 			Amount:    rawTx.MsgTx().TxOut[0].Value,
-			Time:      walletTx.Time},
+			Time:      walletTx.Time,
+			Height:    blockHeight(config, walletTx.BlockHash)},
 		attestService.attestation.Info,
 	)
 }
@@ -893,10 +916,10 @@ func TestAttestService_FailureHandleUnconfirmed(t *testing.T) {
 		assert.Equal(t, prevAttestation.Confirmed, attestService.attestation.Confirmed)
 		assert.Equal(t, prevAttestation.Info, attestService.attestation.Info)
 		if attestService.attestation.Info.Time == 0 {
-			assert.Equal(t, ATimeFixed, attestDelay)
+			assert.Equal(t, ATimeFixed, attestService.attestDelay)
 		} else {
-			assert.Empty(t, attestDelay < ATimeFixed)
-			assert.Empty(t, atimeNewAttestation < attestDelay)
+			assert.Empty(t, attestService.attestDelay < ATimeFixed)
+			assert.Empty(t, attestService.atimeNewAttestation < attestService.attestDelay)
 		}
 
 		// Test AStateNextCommitment -> AStateNewAttestation
@@ -915,7 +938,7 @@ func TestAttestService_FailureHandleUnconfirmed(t *testing.T) {
 		txid := verifyStateSendAttestationToAwaitConfirmation(t, attestService)
 
 		// set confirm time back to test what happens in handle unconfirmed case
-		confirmTime = confirmTime.Add(-DefaultATimeHandleUnconfirmed)
+		attestService.confirmTime = attestService.confirmTime.Add(-DefaultATimeHandleUnconfirmed)
 
 		// Test AStateAwaitConfirmation -> AStateHandleUnconfirmed
 		verifyStateAwaitConfirmationToHandleUnconfirmed(t, attestService)
@@ -937,7 +960,7 @@ func TestAttestService_FailureHandleUnconfirmed(t *testing.T) {
 		}
 
 		// set confirm time back to test what happens in handle unconfirmed case
-		confirmTime = confirmTime.Add(-DefaultATimeHandleUnconfirmed)
+		attestService.confirmTime = attestService.confirmTime.Add(-DefaultATimeHandleUnconfirmed)
 
 		// Test AStateAwaitConfirmation -> AStateHandleUnconfirmed
 		verifyStateAwaitConfirmationToHandleUnconfirmed(t, attestService)
@@ -964,10 +987,10 @@ func TestAttestService_FailureHandleUnconfirmed(t *testing.T) {
 
 		// second time bump fee manually and set is fee bumped flag
 		attestService.attester.Fees.BumpFee()
-		isFeeBumped = true
+		attestService.isFeeBumped = true
 
 		// set confirm time back to test what happens in handle unconfirmed case
-		confirmTime = confirmTime.Add(-DefaultATimeHandleUnconfirmed)
+		attestService.confirmTime = attestService.confirmTime.Add(-DefaultATimeHandleUnconfirmed)
 
 		// Test AStateAwaitConfirmation -> AStateHandleUnconfirmed
 		verifyStateAwaitConfirmationToHandleUnconfirmed(t, attestService)
@@ -1007,10 +1030,556 @@ func TestAttestService_FailureHandleUnconfirmed(t *testing.T) {
 				Txid:      txid.String(),
 				Blockhash: walletTx.BlockHash,
 				Amount:    rawTx.MsgTx().TxOut[0].Value,
-				Time:      walletTx.Time},
+				Time:      walletTx.Time,
+				Height:    blockHeight(config, walletTx.BlockHash)},
 			attestService.attestation.Info,
 		)
 
 		prevAttestation = attestService.attestation
 	}
 }
+
+// Test handle unconfirmed waiting time with and without rbf bump schedule
+func TestAttestServiceHandleUnconfirmedDelay(t *testing.T) {
+	attestService := &AttestService{atimeHandleUnconfirmed: 60 * time.Minute}
+	assert.Equal(t, 60*time.Minute, attestService.handleUnconfirmedDelay(0))
+	assert.Equal(t, 60*time.Minute, attestService.handleUnconfirmedDelay(5))
+
+	attestService.atimeBumpSchedule = []time.Duration{30 * time.Minute, 20 * time.Minute, 10 * time.Minute}
+	assert.Equal(t, 30*time.Minute, attestService.handleUnconfirmedDelay(0))
+	assert.Equal(t, 20*time.Minute, attestService.handleUnconfirmedDelay(1))
+	assert.Equal(t, 10*time.Minute, attestService.handleUnconfirmedDelay(2))
+	assert.Equal(t, 10*time.Minute, attestService.handleUnconfirmedDelay(3))
+}
+
+// Test attestation service dry run store
+func TestAttestServiceDryRunStore(t *testing.T) {
+	dbFake := db.NewDbFake()
+	config := &confpkg.Config{}
+	config.SetDryRun(true)
+
+	hash, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*hash})
+	attestation := models.NewAttestationDefault()
+	attestation.SetCommitment(commitment)
+	attestation.Tx = *wire.NewMsgTx(wire.TxVersion)
+	attestation.Tx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+	attestation.Txid = attestation.Tx.TxHash()
+
+	attestService := &AttestService{config: config, server: NewAttestServer(dbFake),
+		state: AStatePreSendStore, attestation: attestation, atimeNewAttestation: 60 * time.Minute}
+	attestService.doStatePreSendStore()
+
+	// no attestation stored and would-be transaction recorded instead
+	assert.Equal(t, AStateNextCommitment, attestService.state)
+	assert.Equal(t, attestService.atimeNewAttestation, attestService.attestDelay)
+	assert.Equal(t, 0, len(dbFake.Attestations))
+	assert.Equal(t, 1, len(dbFake.DryRunAttestations))
+	assert.Equal(t, attestation.Txid.String(), dbFake.DryRunAttestations[0].Txid)
+	assert.Equal(t, commitment.GetCommitmentHash().String(), dbFake.DryRunAttestations[0].MerkleRoot)
+	assert.Equal(t, "010000000001e803000000000000015100000000", dbFake.DryRunAttestations[0].Tx)
+}
+
+// Test pausing and resuming attestation service with persisted paused flag
+func TestAttestServicePause(t *testing.T) {
+	dbFake := db.NewDbFake()
+	server := NewAttestServer(dbFake)
+	ctx, cancel := context.WithCancel(context.Background())
+	attestService := &AttestService{ctx: ctx, server: server, resume: make(chan struct{}, 1)}
+
+	assert.Equal(t, false, attestService.IsPaused())
+	assert.Equal(t, true, attestService.waitWhilePaused())
+
+	assert.Equal(t, nil, attestService.Pause())
+	assert.Equal(t, true, attestService.IsPaused())
+	paused, pausedErr := server.GetPaused()
+	assert.Equal(t, nil, pausedErr)
+	assert.Equal(t, true, paused)
+
+	// resume unblocks paused service
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		attestService.Resume()
+	}()
+	assert.Equal(t, true, attestService.waitWhilePaused())
+	assert.Equal(t, false, attestService.IsPaused())
+	paused, pausedErr = server.GetPaused()
+	assert.Equal(t, nil, pausedErr)
+	assert.Equal(t, false, paused)
+
+	// stopping service while paused
+	assert.Equal(t, nil, attestService.Pause())
+	cancel()
+	assert.Equal(t, false, attestService.waitWhilePaused())
+}
+
+// signer stub returning a different signature on each request
+type attestSignerRetryStub struct {
+	urgencies *[]int
+}
+
+func (f attestSignerRetryStub) SendConfirmedHash([]byte) {}
+func (f attestSignerRetryStub) SendTxPreImages([][]byte) {}
+func (f attestSignerRetryStub) ReSubscribe()             {}
+func (f attestSignerRetryStub) GetSigs(txHash string, redeemScript string, merkleRoot string, urgency int) [][]crypto.Sig {
+	*f.urgencies = append(*f.urgencies, urgency)
+	return [][]crypto.Sig{{crypto.Sig{byte(len(*f.urgencies))}, crypto.Sig{0}}, {}}
+}
+
+// Test signature request retries with escalating urgency
+func TestAttestServiceSigsRetry(t *testing.T) {
+	var urgencies []int
+	attestService := &AttestService{signer: attestSignerRetryStub{&urgencies}}
+
+	attestService.requestSigs("txhash", "script", "root")
+	assert.Equal(t, []int{SigsUrgencyNormal}, urgencies)
+	assert.Equal(t, [][]crypto.Sig{{crypto.Sig{1}, crypto.Sig{0}}, {}}, attestService.sigs)
+	assert.Equal(t, 0, attestService.sigsRetries)
+
+	attestService.retrySigs()
+	attestService.retrySigs()
+	assert.Equal(t, []int{SigsUrgencyNormal, 1, 2}, urgencies)
+	assert.Equal(t, 2, attestService.sigsRetries)
+	assert.Equal(t, [][]crypto.Sig{{crypto.Sig{1}, crypto.Sig{0}, crypto.Sig{2}, crypto.Sig{3}}, {}}, attestService.sigs)
+
+	// new request resets retries
+	attestService.requestSigs("txhash", "script", "root")
+	assert.Equal(t, 0, attestService.sigsRetries)
+
+	assert.Equal(t, true, isSigsMissing(errors.New(ErrorSigsMissingForVin)))
+	assert.Equal(t, true, isSigsMissing(errors.New(ErrorSigsMissingForTx)))
+	assert.Equal(t, false, isSigsMissing(errors.New(ErroUnspentNotFound)))
+	assert.Equal(t, false, isSigsMissing(nil))
+}
+
+// Test fixed, sigs and confirmation times set from the timing config
+func TestSetTimingConfigStateTimes(t *testing.T) {
+	attestService := &AttestService{}
+	attestService.setTimingConfig(confpkg.TimingConfig{-1, -1, -1, -1, -1, -1, -1})
+	assert.Equal(t, ATimeFixed, attestService.atimeFixed)
+	assert.Equal(t, ATimeSigs, attestService.atimeSigs)
+	assert.Equal(t, ATimeConfirmation, attestService.atimeConfirmation)
+
+	attestService.setTimingConfig(confpkg.TimingConfig{-1, -1, -1, -1, 2, 3, 300})
+	assert.Equal(t, 2*time.Second, attestService.atimeFixed)
+	assert.Equal(t, 3*time.Second, attestService.atimeSigs)
+	assert.Equal(t, 5*time.Minute, attestService.atimeConfirmation)
+
+	// polling bounded by the sigs time
+	sim := newSimClock(time.Unix(1500000000, 0))
+	defer useSimClock(sim)()
+	attestService.sigsTime = clock.Now()
+	assert.Equal(t, 3*time.Second, attestService.sigsPollDelay())
+
+	// invalid values replaced by defaults
+	attestService.setTimingConfig(confpkg.TimingConfig{-1, -1, -1, -1, 0, -5, 0})
+	assert.Equal(t, ATimeFixed, attestService.atimeFixed)
+	assert.Equal(t, ATimeSigs, attestService.atimeSigs)
+	assert.Equal(t, ATimeConfirmation, attestService.atimeConfirmation)
+
+	// schedules are not shared between services
+	otherService := &AttestService{}
+	otherService.setTimingConfig(confpkg.TimingConfig{-1, -1, -1, -1, 2, -1, -1})
+	assert.Equal(t, 2*time.Second, otherService.atimeFixed)
+	assert.Equal(t, ATimeFixed, attestService.atimeFixed)
+}
+
+// Test signers are polled until the collection time passes
+// and the missing signers are listed once it has passed
+func TestAttestServiceSigsPoll(t *testing.T) {
+	var urgencies []int
+	attestService := &AttestService{signer: attestSignerRetryStub{&urgencies}, atimeSigs: ATimeSigs}
+	sim := newSimClock(time.Unix(1500000000, 0))
+	defer useSimClock(sim)()
+
+	attestService.requestSigs("txhash", "script", "root")
+	assert.Equal(t, ATimeSigsPoll, attestService.sigsPollDelay())
+	attestService.pollSigs()
+	assert.Equal(t, []int{SigsUrgencyNormal, SigsUrgencyNormal}, urgencies)
+	assert.Equal(t, [][]crypto.Sig{{crypto.Sig{1}, crypto.Sig{0}, crypto.Sig{2}}, {}}, attestService.sigs)
+
+	// poll delay bounded by the end of the collection time
+	sim.Advance(ATimeSigs - 2*time.Second)
+	assert.Equal(t, 2*time.Second, attestService.sigsPollDelay())
+
+	// retries start a new collection time
+	attestService.retrySigs()
+	assert.Equal(t, ATimeSigsPoll, attestService.sigsPollDelay())
+
+	c := newSignerSetTestClient()
+	tx := newSignerSetTestTx()
+	attestService.attester = c.client
+	attestService.attestation = models.NewAttestationDefault()
+	attestService.attestation.Tx = *tx
+	attestService.sigs = [][]crypto.Sig{c.sign(t, tx, 1)}
+	signErr := errors.New(ErrorSigsMissingForVin)
+	assert.Equal(t, errors.New(ErrorSigsTimeout+": 0,2"), attestService.sigsTimeoutError(signErr, chainhash.Hash{}))
+	attestService.sigs = [][]crypto.Sig{c.sign(t, tx, 0, 1, 2)}
+	assert.Equal(t, signErr, attestService.sigsTimeoutError(signErr, chainhash.Hash{}))
+}
+
+// Test storing in flight attestation on shutdown and resuming on restart
+func TestAttestServiceInFlight(t *testing.T) {
+	dbFake := db.NewDbFake()
+	server := NewAttestServer(dbFake)
+	attestService := &AttestService{server: server}
+
+	prevHash, _ := chainhash.NewHashFromStr("aa2b2c0f2d41e1e6f1ceb5abfd6f3c73ac2d2d3c3b9e3d1e2b47e6c9f1ad5f21")
+	hashX, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hashY, _ := chainhash.NewHashFromStr("2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*hashX, *hashY})
+	commitment.SetRequestIds(map[int32]string{1: "request"})
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(prevHash, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+	attestService.attestation = models.NewAttestation(tx.TxHash(), commitment)
+	attestService.attestation.Tx = *tx
+
+	// nothing stored for states without attestation in progress
+	attestService.state = AStateAwaitConfirmation
+	attestService.flushInFlight()
+	assert.Equal(t, (*models.InFlightAttestation)(nil), dbFake.InFlight)
+
+	attestService.state = AStatePreSendStore
+	attestService.sigs = [][]crypto.Sig{{crypto.Sig{0x01, 0x02}}}
+	attestService.sigsRequest = []string{"txHash", "redeemScript", "merkleRoot"}
+	attestService.sigsRetries = 1
+	attestService.flushInFlight()
+	assert.Equal(t, int(AStatePreSendStore), dbFake.InFlight.State)
+	assert.Equal(t, []string{hashX.String(), hashY.String()}, dbFake.InFlight.Commitments)
+	assert.Equal(t, []string{"", "request"}, dbFake.InFlight.RequestIds)
+	assert.Equal(t, [][]string{{"0102"}}, dbFake.InFlight.Sigs)
+
+	// stale in flight attestation discarded on restart
+	restarted := &AttestService{server: server, state: AStateNextCommitment, attestation: models.NewAttestationDefault()}
+	restarted.stateInitInFlight(btcjson.ListUnspentResult{TxID: prevHash.String(), Vout: 1})
+	assert.Equal(t, AStateNextCommitment, restarted.state)
+	assert.Equal(t, (*models.InFlightAttestation)(nil), dbFake.InFlight)
+
+	// in flight attestation spending last unspent resumed on restart
+	attestService.flushInFlight()
+	restarted.stateInitInFlight(btcjson.ListUnspentResult{TxID: prevHash.String(), Vout: 0})
+	assert.Equal(t, AStatePreSendStore, restarted.state)
+	assert.Equal(t, tx.TxHash(), restarted.attestation.Txid)
+	assert.Equal(t, commitment.GetCommitmentHash(), restarted.attestation.CommitmentHash())
+	restoredCommitment, _ := restarted.attestation.Commitment()
+	assert.Equal(t, commitment.RequestIds(), restoredCommitment.RequestIds())
+	assert.Equal(t, [][]crypto.Sig{{crypto.Sig{0x01, 0x02}}}, restarted.sigs)
+	assert.Equal(t, []string{"txHash", "redeemScript", "merkleRoot"}, restarted.sigsRequest)
+	assert.Equal(t, 1, restarted.sigsRetries)
+	assert.Equal(t, (*models.InFlightAttestation)(nil), dbFake.InFlight)
+}
+
+// Test in flight attestation of commitments not ordered by client position
+// restored with the same leaves and client positions
+func TestAttestServiceInFlightOrdering(t *testing.T) {
+	prevHash, _ := chainhash.NewHashFromStr("aa2b2c0f2d41e1e6f1ceb5abfd6f3c73ac2d2d3c3b9e3d1e2b47e6c9f1ad5f21")
+	hashX, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hashY, _ := chainhash.NewHashFromStr("2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	ordering, _ := models.NewCommitmentOrdering(models.OrderingMap, map[int32]int32{0: 3, 2: 0}, 0, nil)
+	commitment, _ := ordering.Commitment([]models.ClientCommitment{
+		{Commitment: *hashX, ClientPosition: 0}, {Commitment: *hashY, ClientPosition: 2, RequestId: "request"}}, "")
+	commitment.SetRequestIds(map[int32]string{2: "request"})
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(prevHash, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+	attestation := models.NewAttestation(tx.TxHash(), commitment)
+	attestation.Tx = *tx
+
+	inFlight, inFlightErr := newInFlightAttestation(AStatePreSendStore, attestation, nil, nil, 0)
+	assert.Equal(t, nil, inFlightErr)
+	assert.Equal(t, []string{hashY.String(), hashX.String()}, inFlight.Commitments)
+	assert.Equal(t, []int32{2, 0}, inFlight.Positions)
+	assert.Equal(t, []int32{0, 3}, inFlight.Leaves)
+	assert.Equal(t, 4, inFlight.LeafCount)
+
+	restored, _, restoreErr := restoreInFlightAttestation(inFlight)
+	assert.Equal(t, nil, restoreErr)
+	assert.Equal(t, commitment.GetCommitmentHash(), restored.CommitmentHash())
+	restoredCommitment, _ := restored.Commitment()
+	assert.Equal(t, commitment.GetMerkleCommitments(), restoredCommitment.GetMerkleCommitments())
+	assert.Equal(t, commitment.Positions(), restoredCommitment.Positions())
+	assert.Equal(t, commitment.RequestIds(), restoredCommitment.RequestIds())
+
+	inFlight.Leaves[1] = 4
+	_, _, restoreErr = restoreInFlightAttestation(inFlight)
+	assert.Equal(t, models.ErrorOrderingLeaf+": 4", restoreErr.Error())
+}
+
+// Test liveness of the attestation loop and failing state tracking
+func TestAttestServiceHealth(t *testing.T) {
+	attestService := &AttestService{}
+
+	attestService.attestDelay = time.Minute
+	attestService.recordTransition(true)
+	assert.Equal(t, nil, attestService.checkAttestationLive())
+	assert.Equal(t, nil, attestService.checkAttestationReady())
+	report := attestService.Liveness()
+	assert.Equal(t, true, report.Healthy)
+	assert.Equal(t, map[string]string{HealthCheckAttestation: HealthStatusOk}, report.Checks)
+
+	// failing state is not ready but still live
+	attestService.recordTransition(false)
+	assert.Equal(t, nil, attestService.checkAttestationLive())
+	assert.NotEqual(t, nil, attestService.checkAttestationReady())
+	attestService.recordTransition(true)
+	assert.Equal(t, nil, attestService.checkAttestationReady())
+
+	// overdue state is not live unless paused
+	attestService.attestDelay = -DefaultHealthGrace - time.Minute
+	attestService.recordTransition(true)
+	assert.NotEqual(t, nil, attestService.checkAttestationLive())
+	report = attestService.Liveness()
+	assert.Equal(t, false, report.Healthy)
+	attestService.paused = 1
+	assert.Equal(t, nil, attestService.checkAttestationLive())
+	assert.Equal(t, true, attestService.Liveness().Paused)
+}
+
+// Test status of the attestation state machine
+func TestAttestServiceStatus(t *testing.T) {
+	attestService := &AttestService{}
+
+	attestService.attestDelay = time.Minute
+	attestService.state = AStateNextCommitment
+	attestService.recordTransition(true)
+	status := attestService.Status()
+	assert.Equal(t, "next_commitment", status.State)
+	assert.Equal(t, status.LastTransition+60, status.NextState)
+	assert.Equal(t, false, status.Failing)
+	assert.Equal(t, false, status.Paused)
+
+	attestService.state = AStateError
+	attestService.recordTransition(false)
+	attestService.paused = 1
+	status = attestService.Status()
+	assert.Equal(t, "error", status.State)
+	assert.Equal(t, true, status.Failing)
+	assert.Equal(t, true, status.Paused)
+}
+
+// notifier collecting attestation events
+type notifierFake struct {
+	events []models.AttestationEvent
+}
+
+func (n *notifierFake) Notify(event models.AttestationEvent) {
+	n.events = append(n.events, event)
+}
+
+// Test Attest Service notifies attestation events for the current attestation
+func TestAttestServiceNotify(t *testing.T) {
+	attestation := newReviewTestAttestation(chainhash.Hash{1})
+	attester := &AttestClient{Fees: NewAttestFees(confpkg.FeesConfig{MinFee: 20, MaxFee: 100, FeeIncrement: 5})}
+	attestService := &AttestService{attester: attester, attestation: attestation}
+
+	// no notifier set
+	attestService.notify(models.AttestationEventBroadcast, "")
+
+	notifier := &notifierFake{}
+	attestService.AddNotifier(notifier)
+	attestService.notify(models.AttestationEventBroadcast, "")
+	attestService.notify(models.AttestationEventConfirmed, "abcd")
+	assert.Equal(t, 2, len(notifier.events))
+	assert.Equal(t, models.AttestationEventBroadcast, notifier.events[0].Event)
+	assert.Equal(t, attestation.Txid.String(), notifier.events[0].Txid)
+	assert.Equal(t, attestation.CommitmentHash().String(), notifier.events[0].Commitment)
+	assert.Equal(t, 20, notifier.events[0].Fee)
+	assert.Equal(t, "", notifier.events[0].Blockhash)
+	assert.Equal(t, models.AttestationEventConfirmed, notifier.events[1].Event)
+	assert.Equal(t, "abcd", notifier.events[1].Blockhash)
+
+	// additional notifiers notified after existing ones
+	busNotifier := &notifierFake{}
+	attestService.AddNotifier(busNotifier)
+	attestService.notify(models.AttestationEventFeeBumped, "")
+	assert.Equal(t, 3, len(notifier.events))
+	assert.Equal(t, 1, len(busNotifier.events))
+	assert.Equal(t, models.AttestationEventFeeBumped, busNotifier.events[0].Event)
+}
+
+// alerter collecting alerts
+type alerterFake struct {
+	alerts []models.Alert
+}
+
+func (a *alerterFake) Alert(alert models.Alert) {
+	a.alerts = append(a.alerts, alert)
+}
+
+// Test Attest Service alerts failures with the failed state and current txid
+func TestAttestServiceAlert(t *testing.T) {
+	attestService := &AttestService{state: AStateInit, attestation: models.NewAttestationDefault()}
+
+	// no alerter set
+	assert.Equal(t, true, attestService.setFailure(errors.New("init failure")))
+	assert.Equal(t, AStateError, attestService.state)
+
+	alerter := &alerterFake{}
+	attestService.alerter = alerter
+	attestService.state = AStateInit
+	assert.Equal(t, false, attestService.setFailure(nil))
+	assert.Equal(t, true, attestService.setFailure(errors.New("init failure")))
+	assert.Equal(t, 1, len(alerter.alerts))
+	assert.Equal(t, "init failure", alerter.alerts[0].Error)
+	assert.Equal(t, "unknown", alerter.alerts[0].Class)
+	assert.Equal(t, "init", alerter.alerts[0].PrevState)
+	assert.Equal(t, "", alerter.alerts[0].Txid)
+
+	attestService.state = AStateSendAttestation
+	attestService.attestation = newReviewTestAttestation(chainhash.Hash{1})
+	assert.Equal(t, true, attestService.setFailure(errors.New("send failure")))
+	assert.Equal(t, 2, len(alerter.alerts))
+	assert.Equal(t, "send_attestation", alerter.alerts[1].PrevState)
+	assert.Equal(t, attestService.attestation.Txid.String(), alerter.alerts[1].Txid)
+}
+
+// Test Attest Service retries transient failures in place
+// and resets to init for other failures or when retries run out
+func TestAttestServiceRetry(t *testing.T) {
+	alerter := &alerterFake{}
+	attestService := &AttestService{state: AStateAwaitConfirmation, attestation: models.NewAttestationDefault(),
+		alerter: alerter, retryPolicy: DefaultRetryPolicy()}
+
+	dbErr := errors.New(db.ErrorAttestationGet + " timeout")
+	for i := 1; i <= attestService.retryPolicy.MaxRetries; i++ {
+		assert.Equal(t, true, attestService.setFailure(dbErr))
+		assert.Equal(t, AStateAwaitConfirmation, attestService.state)
+		assert.Equal(t, i, attestService.stateRetries)
+		assert.Equal(t, attestService.retryPolicy.Delay(i), attestService.attestDelay)
+	}
+	assert.Equal(t, 0, len(alerter.alerts))
+
+	// retries exhausted
+	assert.Equal(t, true, attestService.setFailure(dbErr))
+	assert.Equal(t, AStateError, attestService.state)
+	assert.Equal(t, dbErr, attestService.errorState)
+	assert.Equal(t, 0, attestService.stateRetries)
+	assert.Equal(t, 1, len(alerter.alerts))
+	assert.Equal(t, "db_transient", alerter.alerts[0].Class)
+
+	// fatal and unknown errors reset immediately
+	attestService.state = AStateSendAttestation
+	assert.Equal(t, true, attestService.setFailure(errors.New("status code: 401, response: \"\"")))
+	assert.Equal(t, AStateError, attestService.state)
+	assert.Equal(t, "fatal_config", alerter.alerts[1].Class)
+
+	// signer timeouts reset immediately
+	attestService.state = AStateSignAttestation
+	assert.Equal(t, true, attestService.setFailure(NewAttestError(ErrorClassSignerTimeout, errors.New("sigs"))))
+	assert.Equal(t, AStateError, attestService.state)
+	assert.Equal(t, "signer_timeout", alerter.alerts[2].Class)
+
+	// transient failures handling unconfirmed reset immediately
+	attestService.state = AStateHandleUnconfirmed
+	assert.Equal(t, true, attestService.setFailure(dbErr))
+	assert.Equal(t, AStateError, attestService.state)
+	assert.Equal(t, 4, len(alerter.alerts))
+}
+
+// Test new attestation delays are extended to staggered slots
+func TestAttestServiceStaggerDelay(t *testing.T) {
+	attestService := &AttestService{atimeNewAttestation: 60 * time.Minute}
+	assert.Equal(t, 10*time.Minute, attestService.staggerDelay(10*time.Minute))
+
+	attestService.isStaggered = true
+	for _, offset := range []time.Duration{0, 15 * time.Minute, 45 * time.Minute} {
+		attestService.atimeStaggerOffset = offset
+		for _, delay := range []time.Duration{0, 10 * time.Minute, 59 * time.Minute, 90 * time.Minute} {
+			slot := time.Now().Add(attestService.staggerDelay(delay)).Add(time.Second)
+			assert.Equal(t, false, slot.Before(time.Now().Add(delay)))
+			assert.Equal(t, true, slot.Before(time.Now().Add(delay).Add(attestService.atimeNewAttestation)))
+			assert.Equal(t, offset, slot.Sub(slot.Truncate(attestService.atimeNewAttestation)).Truncate(time.Minute))
+		}
+	}
+}
+
+// Test Attest Service traces attestation cycle with state, db and signer spans
+func TestAttestServiceTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	scope := tracing.NewScope()
+	dbTraced := db.NewDbTraced(db.NewDbFake(), scope)
+	signer := NewAttestSignerTraced(&AttestSignerFake{}, scope)
+	attestService := &AttestService{state: AStateInit, attestation: models.NewAttestationDefault()}
+	attestService.SetTraceScope(scope)
+
+	// step through states making a db or signer call in each state
+	step := func(nextState AttestationState, call func()) {
+		span := attestService.startStateSpan()
+		call()
+		attestService.state = nextState
+		attestService.endStateSpan(span)
+	}
+
+	// init is not part of an attestation cycle
+	step(AStateNextCommitment, func() { dbTraced.GetClientCommitments() })
+	assert.Equal(t, nil, attestService.cycleSpan)
+
+	txid, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	step(AStateNewAttestation, func() { dbTraced.GetClientCommitments() })
+	step(AStateSignAttestation, func() { signer.SendTxPreImages([][]byte{}) })
+	step(AStatePreSendStore, func() { attestService.attestation.Txid = *txid })
+	step(AStateAwaitConfirmation, func() { dbTraced.SaveAttestation(*attestService.attestation) })
+	assert.NotEqual(t, nil, attestService.cycleSpan)
+	step(AStateNextCommitment, func() {})
+	assert.Equal(t, nil, attestService.cycleSpan)
+
+	// failed state ends cycle with error status
+	step(AStateNewAttestation, func() {})
+	attestService.errorState = errors.New("failure")
+	step(AStateError, func() {})
+	assert.Equal(t, nil, attestService.cycleSpan)
+
+	ended := recorder.Ended()
+	var names []string
+	for _, span := range ended {
+		names = append(names, span.Name())
+	}
+	assert.Equal(t, []string{
+		"db.GetClientCommitments", "attestation.init",
+		"db.GetClientCommitments", "attestation.next_commitment",
+		"signer.SendTxPreImages", "attestation.new_attestation",
+		"attestation.sign_attestation",
+		"db.SaveAttestation", "attestation.pre_send_store",
+		"attestation.await_confirmation", SpanCycle,
+		"attestation.next_commitment", "attestation.new_attestation", SpanCycle,
+	}, names)
+	assert.Equal(t, false, ended[1].Parent().IsValid())
+	assert.Equal(t, ended[1].SpanContext().SpanID(), ended[0].Parent().SpanID())
+
+	// state spans are children of the cycle span and db and signer spans children of state spans
+	cycle := ended[10]
+	assert.Equal(t, false, cycle.Parent().IsValid())
+	assert.Equal(t, attribute.StringValue(txid.String()), spanAttribute(cycle, tracing.AttrTxid))
+	assert.Equal(t, codes.Unset, cycle.Status().Code)
+	for _, state := range []int{3, 5, 6, 8, 9} {
+		assert.Equal(t, cycle.SpanContext().SpanID(), ended[state].Parent().SpanID())
+	}
+	assert.Equal(t, ended[3].SpanContext().SpanID(), ended[2].Parent().SpanID())
+	assert.Equal(t, ended[5].SpanContext().SpanID(), ended[4].Parent().SpanID())
+	assert.Equal(t, ended[8].SpanContext().SpanID(), ended[7].Parent().SpanID())
+	assert.Equal(t, attribute.StringValue(AStateNextCommitment.String()),
+		spanAttribute(ended[9], tracing.AttrNextState))
+
+	failedCycle := ended[13]
+	assert.NotEqual(t, cycle.SpanContext().TraceID(), failedCycle.SpanContext().TraceID())
+	assert.Equal(t, failedCycle.SpanContext().SpanID(), ended[12].Parent().SpanID())
+	assert.Equal(t, codes.Error, ended[12].Status().Code)
+	assert.Equal(t, codes.Error, failedCycle.Status().Code)
+	assert.Equal(t, "failure", failedCycle.Status().Description)
+}
+
+// Return attribute value of span
+func spanAttribute(span sdktrace.ReadOnlySpan, key string) attribute.Value {
+	for _, attr := range span.Attributes() {
+		if string(attr.Key) == key {
+			return attr.Value
+		}
+	}
+	return attribute.Value{}
+}
@@ -5,9 +5,15 @@
 package attestation
 
 import (
+	"bytes"
+
 	"mainstay/crypto"
 )
 
+// signature request urgency of the initial request
+// retries use the retry number as urgency
+const SigsUrgencyNormal = 0
+
 // AttestSigner interface
 //
 // Provides the interface for communication with
@@ -15,7 +21,8 @@ import (
 // - sending the last confirmed commitment hash
 // - sending the new commitment (for tweaking)
 // - sending the new generated transaction for signing
-// - getting the signatures from signers
+// - getting the signatures from signers, with an urgency
+//   flag escalated on each retry after missing signatures
 //
 // This interface allows building communication with
 // various ways - currently supporting zmq only
@@ -23,6 +30,35 @@ import (
 type AttestSigner interface {
 	SendConfirmedHash([]byte)
 	SendTxPreImages([][]byte)
-	GetSigs(string, string, string) [][]crypto.Sig
+	GetSigs(string, string, string, int) [][]crypto.Sig
 	ReSubscribe()
 }
+
+// AttestSignerStatus interface
+// Optionally implemented by signers that can report whether these are reachable
+type AttestSignerStatus interface {
+	Status() error
+}
+
+// Merge signatures received on a retry into the existing signatures
+// Signatures already received for an input are not added again
+func MergeSigs(sigs [][]crypto.Sig, newSigs [][]crypto.Sig) [][]crypto.Sig {
+	for len(sigs) < len(newSigs) {
+		sigs = append(sigs, []crypto.Sig{})
+	}
+	for i := range newSigs {
+		for _, newSig := range newSigs[i] {
+			found := false
+			for _, sig := range sigs[i] {
+				if bytes.Equal(sig, newSig) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				sigs[i] = append(sigs[i], newSig)
+			}
+		}
+	}
+	return sigs
+}
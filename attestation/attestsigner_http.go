@@ -6,6 +6,7 @@ package attestation
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -13,6 +14,7 @@ import (
 	confpkg "mainstay/config"
 	"mainstay/crypto"
 	"net/http"
+	"time"
 )
 
 // AttestSignerFake struct
@@ -29,8 +31,12 @@ type RequestBody struct {
 	Value           int    `json:"value"`
 	MerkleRoot      string `json:"merkle_root"`
 	RedeemScriptHex string `json:"redeem_script_hex"`
+	Urgency         int    `json:"urgency,omitempty"`
 }
 
+// timeout of signer status requests
+const SignerStatusTimeout = 5 * time.Second
+
 // store latest hash and transaction
 var signerTxPreImageBytes []byte
 var signerConfirmedHashBytes []byte
@@ -48,6 +54,24 @@ func (f AttestSignerHttp) ReSubscribe() {
 	return
 }
 
+// Return error if the signer url can not be reached
+// Any http response, including errors for the unsupported method, is
+// taken as the signer being reachable
+func (f AttestSignerHttp) Status() error {
+	ctx, cancel := context.WithTimeout(context.Background(), SignerStatusTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, f.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
 // Store received confirmed hash
 func (f AttestSignerHttp) SendConfirmedHash(hash []byte) {
 	signerConfirmedHashBytes = hash
@@ -59,7 +83,8 @@ func (f AttestSignerHttp) SendTxPreImages(txs [][]byte) {
 }
 
 // Return signatures for received tx and hashes
-func (f AttestSignerHttp) GetSigs(txHash string, redeem_script string, merkle_root string) [][]crypto.Sig {
+// Signatures missing due to request failures are left empty to be retried
+func (f AttestSignerHttp) GetSigs(txHash string, redeem_script string, merkle_root string, urgency int) [][]crypto.Sig {
 	// get unserialized tx pre images
 	txPreImages := UnserializeBytes(signerTxPreImageBytes)
 
@@ -71,18 +96,21 @@ func (f AttestSignerHttp) GetSigs(txHash string, redeem_script string, merkle_ro
 		Value:           10000,
 		MerkleRoot:      merkle_root,
 		RedeemScriptHex: redeem_script,
+		Urgency:         urgency,
 	}
 
 	// Encode the request body to JSON
 	requestBodyJSON, err := json.Marshal(requestBody)
 	if err != nil {
 		fmt.Println("Error marshalling request body:", err)
+		return sigs
 	}
 
 	// Create the HTTP request
 	req, err := http.NewRequest(http.MethodPost, f.url, bytes.NewReader(requestBodyJSON))
 	if err != nil {
 		fmt.Println("Error creating request:", err)
+		return sigs
 	}
 
 	// Set the request headers
@@ -91,6 +119,7 @@ func (f AttestSignerHttp) GetSigs(txHash string, redeem_script string, merkle_ro
 	resp, err := f.client.Do(req)
 	if err != nil {
 		fmt.Println("Error sending request:", err)
+		return sigs
 	}
 
 	// Close the response body
@@ -100,12 +129,15 @@ func (f AttestSignerHttp) GetSigs(txHash string, redeem_script string, merkle_ro
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		fmt.Println("Error reading response body:", err)
+		return sigs
 	}
 
 	// Print the response
 	fmt.Println(string(body))
 	sig, _ := hex.DecodeString(string(body))
-	sigs[0][0] = sig
+	if len(sigs) > 0 && len(sig) > 0 {
+		sigs[0] = append(sigs[0], sig)
+	}
 	return sigs
 }
 
@@ -0,0 +1,69 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"mainstay/crypto"
+	"mainstay/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// span attribute keys of signer calls
+const (
+	AttrSigsUrgency = "signer.urgency"
+	AttrSigsInputs  = "signer.inputs"
+)
+
+// AttestSignerTraced struct
+// Wraps an AttestSigner interface and records a span for each signer
+// round-trip as a child of the current span of the tracing scope
+type AttestSignerTraced struct {
+	signer AttestSigner
+	scope  *tracing.Scope
+}
+
+// Return new AttestSignerTraced instance wrapping signer
+func NewAttestSignerTraced(signer AttestSigner, scope *tracing.Scope) *AttestSignerTraced {
+	return &AttestSignerTraced{signer, scope}
+}
+
+// Send confirmed hash to signers
+func (t *AttestSignerTraced) SendConfirmedHash(hash []byte) {
+	_, span := t.scope.Start("signer.SendConfirmedHash")
+	t.signer.SendConfirmedHash(hash)
+	span.End()
+}
+
+// Send transaction pre images to signers
+func (t *AttestSignerTraced) SendTxPreImages(txs [][]byte) {
+	_, span := t.scope.Start("signer.SendTxPreImages", attribute.Int(AttrSigsInputs, len(txs)))
+	t.signer.SendTxPreImages(txs)
+	span.End()
+}
+
+// Get signatures from signers
+func (t *AttestSignerTraced) GetSigs(txHash string, redeemScript string, merkleRoot string, urgency int) [][]crypto.Sig {
+	_, span := t.scope.Start("signer.GetSigs", attribute.Int(AttrSigsUrgency, urgency))
+	sigs := t.signer.GetSigs(txHash, redeemScript, merkleRoot, urgency)
+	span.SetAttributes(attribute.Int(AttrSigsInputs, len(sigs)))
+	span.End()
+	return sigs
+}
+
+// Resubscribe to signers
+func (t *AttestSignerTraced) ReSubscribe() {
+	_, span := t.scope.Start("signer.ReSubscribe")
+	t.signer.ReSubscribe()
+	span.End()
+}
+
+// Return status of the wrapped signer if it reports one
+func (t *AttestSignerTraced) Status() error {
+	if status, ok := t.signer.(AttestSignerStatus); ok {
+		return status.Status()
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	confpkg "mainstay/config"
+	"mainstay/crypto"
+	"mainstay/log"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// The signer set configures the m-of-n signer quorum of attestations
+// independently of the redeem script: the threshold of valid signatures
+// that must be collected and the signers these are accepted from. When
+// more signatures than required are collected, signatures are verified
+// against the tweaked pubkeys of the redeem script and the first valid
+// ones in redeem script key order are used, as required by checkmultisig
+
+// signer set error consts
+const (
+	ErrorSignerThresholdLow  = "Signer threshold below signatures required by redeem script"
+	ErrorSignerThresholdHigh = "Signer threshold above number of signers"
+	ErrorSignerPubkeyInvalid = "Invalid signer pubkey"
+	ErrorSignerPubkeyUnknown = "Signer pubkey not in redeem script"
+
+	WarningSignerSetInvalid = "Invalid signer set - using signatures required by redeem script"
+)
+
+// AttestSignerSet struct
+// Threshold of signatures and pubkeys of the signers accepted
+// An empty set of pubkeys accepts all signers of the redeem script
+type AttestSignerSet struct {
+	threshold int
+	pubkeys   [][]byte
+}
+
+// Return new AttestSignerSet from the signer config, verifying the threshold
+// and signer pubkeys against the pubkeys and signatures of the redeem script
+func NewAttestSignerSet(config confpkg.SignerConfig, scriptPubkeys []*btcec.PublicKey,
+	numOfSigs int) (*AttestSignerSet, error) {
+
+	var pubkeys [][]byte
+	for _, pubkeyStr := range config.Pubkeys {
+		pubkeyBytes, decodeErr := hex.DecodeString(pubkeyStr)
+		if decodeErr != nil {
+			return nil, errors.New(fmt.Sprintf("%s %s", ErrorSignerPubkeyInvalid, pubkeyStr))
+		}
+		pubkey, pubkeyErr := btcec.ParsePubKey(pubkeyBytes, btcec.S256())
+		if pubkeyErr != nil {
+			return nil, errors.New(fmt.Sprintf("%s %s", ErrorSignerPubkeyInvalid, pubkeyStr))
+		}
+		if pubkeyIndex(scriptPubkeys, pubkey) < 0 {
+			return nil, errors.New(fmt.Sprintf("%s %s", ErrorSignerPubkeyUnknown, pubkeyStr))
+		}
+		pubkeys = append(pubkeys, pubkey.SerializeCompressed())
+	}
+
+	numOfSigners := len(scriptPubkeys)
+	if len(pubkeys) > 0 {
+		numOfSigners = len(pubkeys)
+	}
+	threshold := numOfSigs
+	if config.Threshold > 0 {
+		threshold = config.Threshold
+	}
+	if threshold < numOfSigs {
+		return nil, errors.New(fmt.Sprintf("%s. %d < %d", ErrorSignerThresholdLow, threshold, numOfSigs))
+	} else if threshold > numOfSigners {
+		return nil, errors.New(fmt.Sprintf("%s. %d > %d", ErrorSignerThresholdHigh, threshold, numOfSigners))
+	}
+	return &AttestSignerSet{threshold, pubkeys}, nil
+}
+
+// Return index of pubkey in the list of pubkeys or -1 if not found
+func pubkeyIndex(pubkeys []*btcec.PublicKey, pubkey *btcec.PublicKey) int {
+	for i, key := range pubkeys {
+		if key.IsEqual(pubkey) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Return whether signatures are accepted from each of the base pubkeys
+// of the redeem script
+func (s *AttestSignerSet) accepted(basePubkeys []*btcec.PublicKey) []bool {
+	accepted := make([]bool, len(basePubkeys))
+	for i, basePubkey := range basePubkeys {
+		if s == nil || len(s.pubkeys) == 0 {
+			accepted[i] = true
+			continue
+		}
+		for _, pubkey := range s.pubkeys {
+			if string(pubkey) == string(basePubkey.SerializeCompressed()) {
+				accepted[i] = true
+				break
+			}
+		}
+	}
+	return accepted
+}
+
+// Return threshold of signatures for the signatures required by the script
+func (s *AttestSignerSet) thresholdFor(numOfSigs int) int {
+	if s == nil || s.threshold < numOfSigs {
+		return numOfSigs
+	}
+	return s.threshold
+}
+
+// Set threshold and signers of the attestation client from the signer config
+func (w *AttestClient) SetSignerSet(config confpkg.SignerConfig) error {
+	signerSet, signerSetErr := NewAttestSignerSet(config, w.pubkeys, w.numOfSigs)
+	if signerSetErr != nil {
+		return signerSetErr
+	}
+	w.signerSet = signerSet
+	return nil
+}
+
+// Set signer set of the attestation client from the service config, e.g.
+// after reloading the config or rotating the keys of the client, falling
+// back to the signatures required by the redeem script if the signer set
+// does not match the keys of the client
+func (s *AttestService) applySignerSet() {
+	if s.config == nil {
+		return
+	}
+	if err := s.attester.SetSignerSet(s.config.SignerConfig()); err != nil {
+		s.logger().WithFields(log.Fields{log.FieldError: err}).Warnln(WarningSignerSetInvalid)
+		s.attester.signerSet = nil
+	}
+}
+
+// Select signatures of the transaction input to combine into the script sig
+// Signatures are verified against the pubkeys of the redeem script and
+// only accepted from the signers accepted, or any signer if nil, with one
+// signature per pubkey. At least threshold valid signatures are required,
+// of which the first numOfSigs in pubkey order are returned
+func (w *AttestClient) selectSigs(sigs []crypto.Sig, redeemScript []byte, tx *wire.MsgTx, idx int,
+	accepted []bool, threshold int) ([]crypto.Sig, error) {
+
+	_, addrs, _, extractErr := txscript.ExtractPkScriptAddrs(redeemScript, w.MainChainCfg)
+	if extractErr != nil {
+		return nil, extractErr
+	}
+	sigHash, sigHashErr := txscript.CalcSignatureHash(redeemScript, txscript.SigHashAll, tx, idx)
+	if sigHashErr != nil {
+		return nil, sigHashErr
+	}
+
+	var selected []crypto.Sig
+	for i_k, addr := range addrs {
+		pubkeyAddr, ok := addr.(*btcutil.AddressPubKey)
+		if !ok || (accepted != nil && (i_k >= len(accepted) || !accepted[i_k])) {
+			continue
+		}
+		for _, sig := range sigs {
+			if len(sig) < 2 {
+				continue
+			}
+			signature, parseErr := btcec.ParseDERSignature(sig[:len(sig)-1], btcec.S256())
+			if parseErr == nil && signature.Verify(sigHash, pubkeyAddr.PubKey()) {
+				selected = append(selected, sig)
+				break
+			}
+		}
+	}
+	if len(selected) < threshold || len(selected) < w.numOfSigs {
+		log.Warnf("%s (%d valid of %d, threshold %d)\n", ErrorSigsMissingForVin, len(selected), len(sigs), threshold)
+		return nil, errors.New(ErrorSigsMissingForVin)
+	}
+	return selected[:w.numOfSigs], nil
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	confpkg "mainstay/config"
+	"mainstay/crypto"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// signer set test client of a 2 of 3 multisig and its signer private keys
+type signerSetTestClient struct {
+	client     *AttestClient
+	privs      []*btcec.PrivateKey
+	script     []byte
+	pubkeyStrs []string
+}
+
+// Return test client of a 2 of 3 multisig
+func newSignerSetTestClient() signerSetTestClient {
+	var privs []*btcec.PrivateKey
+	var pubs []*btcec.PublicKey
+	var pubStrs []string
+	for i := byte(1); i <= 3; i++ {
+		priv, pub := btcec.PrivKeyFromBytes(btcec.S256(), append(make([]byte, 31), i))
+		privs = append(privs, priv)
+		pubs = append(pubs, pub)
+		pubStrs = append(pubStrs, hex.EncodeToString(pub.SerializeCompressed()))
+	}
+	_, script := crypto.CreateMultisig(pubs, 2, &chaincfg.RegressionNetParams)
+	scriptBytes, _ := hex.DecodeString(script)
+	client := &AttestClient{script0: script, pubkeys: pubs, numOfSigs: 2, MainChainCfg: &chaincfg.RegressionNetParams}
+	return signerSetTestClient{client, privs, scriptBytes, pubStrs}
+}
+
+// Return unsigned transaction spending a single multisig output
+func newSignerSetTestTx() *wire.MsgTx {
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{txscript.OP_TRUE}))
+	return tx
+}
+
+// Return signatures of the signers with the indices provided
+func (c signerSetTestClient) sign(t *testing.T, tx *wire.MsgTx, indices ...int) []crypto.Sig {
+	var sigs []crypto.Sig
+	for _, i := range indices {
+		sig, sigErr := txscript.RawTxInSignature(tx, 0, c.script, txscript.SigHashAll, c.privs[i])
+		assert.Equal(t, nil, sigErr)
+		sigs = append(sigs, sig)
+	}
+	return sigs
+}
+
+// Verify signed transaction spends the multisig output
+func (c signerSetTestClient) verify(t *testing.T, tx *wire.MsgTx) {
+	pkScript, _ := txscript.NewScriptBuilder().AddOp(txscript.OP_HASH160).
+		AddData(btcutil.Hash160(c.script)).AddOp(txscript.OP_EQUAL).Script()
+	engine, engineErr := txscript.NewEngine(pkScript, tx, 0, txscript.StandardVerifyFlags, nil, nil, 1000)
+	assert.Equal(t, nil, engineErr)
+	assert.Equal(t, nil, engine.Execute())
+}
+
+// Test signer set threshold and pubkeys are verified against the redeem script
+func TestNewAttestSignerSet(t *testing.T) {
+	c := newSignerSetTestClient()
+
+	signerSet, signerSetErr := NewAttestSignerSet(confpkg.SignerConfig{}, c.client.pubkeys, 2)
+	assert.Equal(t, nil, signerSetErr)
+	assert.Equal(t, 2, signerSet.thresholdFor(2))
+	assert.Equal(t, []bool{true, true, true}, signerSet.accepted(c.client.pubkeys))
+
+	signerSet, signerSetErr = NewAttestSignerSet(confpkg.SignerConfig{Threshold: 3}, c.client.pubkeys, 2)
+	assert.Equal(t, nil, signerSetErr)
+	assert.Equal(t, 3, signerSet.thresholdFor(2))
+
+	signerSet, signerSetErr = NewAttestSignerSet(confpkg.SignerConfig{Pubkeys: []string{c.pubkeyStrs[2], c.pubkeyStrs[0]}},
+		c.client.pubkeys, 2)
+	assert.Equal(t, nil, signerSetErr)
+	assert.Equal(t, []bool{true, false, true}, signerSet.accepted(c.client.pubkeys))
+
+	_, signerSetErr = NewAttestSignerSet(confpkg.SignerConfig{Threshold: 1}, c.client.pubkeys, 2)
+	assert.Equal(t, ErrorSignerThresholdLow+". 1 < 2", signerSetErr.Error())
+	_, signerSetErr = NewAttestSignerSet(confpkg.SignerConfig{Threshold: 4}, c.client.pubkeys, 2)
+	assert.Equal(t, ErrorSignerThresholdHigh+". 4 > 3", signerSetErr.Error())
+	_, signerSetErr = NewAttestSignerSet(confpkg.SignerConfig{Pubkeys: []string{c.pubkeyStrs[1]}}, c.client.pubkeys, 2)
+	assert.Equal(t, ErrorSignerThresholdHigh+". 2 > 1", signerSetErr.Error())
+	_, signerSetErr = NewAttestSignerSet(confpkg.SignerConfig{Pubkeys: []string{"zz"}}, c.client.pubkeys, 2)
+	assert.Equal(t, ErrorSignerPubkeyInvalid+" zz", signerSetErr.Error())
+	_, unknownPub := btcec.PrivKeyFromBytes(btcec.S256(), append(make([]byte, 31), 4))
+	unknown := hex.EncodeToString(unknownPub.SerializeCompressed())
+	_, signerSetErr = NewAttestSignerSet(confpkg.SignerConfig{Pubkeys: []string{unknown}}, c.client.pubkeys, 2)
+	assert.Equal(t, ErrorSignerPubkeyUnknown+" "+unknown, signerSetErr.Error())
+}
+
+// Test signatures used when signing attestations with a signer set
+func TestSignAttestationSignerSet(t *testing.T) {
+	c := newSignerSetTestClient()
+	tx := newSignerSetTestTx()
+	invalidSig := append(c.sign(t, newSignerSetTestTx(), 0)[0][:10], 1)
+
+	cases := []struct {
+		name   string
+		config confpkg.SignerConfig
+		sigs   []int
+		extra  []crypto.Sig
+		err    error
+	}{
+		{name: "required sigs", sigs: []int{0, 1}},
+		{name: "sigs out of key order", sigs: []int{2, 0}},
+		{name: "more sigs than required", sigs: []int{2, 1, 0}},
+		{name: "duplicate and invalid sigs", sigs: []int{1, 1}, extra: []crypto.Sig{invalidSig, {}},
+			err: errors.New(ErrorSigsMissingForVin)},
+		{name: "invalid sigs discarded", sigs: []int{1, 2}, extra: []crypto.Sig{invalidSig}},
+		{name: "below threshold", config: confpkg.SignerConfig{Threshold: 3}, sigs: []int{0, 2},
+			err: errors.New(ErrorSigsMissingForVin)},
+		{name: "threshold", config: confpkg.SignerConfig{Threshold: 3}, sigs: []int{0, 2, 1}},
+		{name: "signer not accepted", config: confpkg.SignerConfig{Pubkeys: []string{c.pubkeyStrs[0], c.pubkeyStrs[1]}},
+			sigs: []int{0, 2}, err: errors.New(ErrorSigsMissingForVin)},
+		{name: "signers accepted", config: confpkg.SignerConfig{Pubkeys: []string{c.pubkeyStrs[0], c.pubkeyStrs[1]}},
+			sigs: []int{2, 1, 0}},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, nil, c.client.SetSignerSet(tc.config), tc.name)
+		sigs := append(tc.extra, c.sign(t, tx, tc.sigs...)...)
+		signedTx, signErr := c.client.signAttestation(tx.Copy(), [][]crypto.Sig{sigs}, chainhash.Hash{})
+		assert.Equal(t, tc.err, signErr, tc.name)
+		if tc.err != nil {
+			continue
+		}
+		scriptSigs, script := crypto.ParseScriptSig(signedTx.TxIn[0].SignatureScript)
+		assert.Equal(t, c.script, script, tc.name)
+		assert.Equal(t, 2, len(scriptSigs), tc.name)
+		c.verify(t, signedTx)
+	}
+}
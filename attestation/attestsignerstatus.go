@@ -0,0 +1,122 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"mainstay/crypto"
+	"mainstay/log"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Signers do not acknowledge the confirmed hash and transaction pre-images
+// sent to them, so signer liveness is tracked from the signatures received.
+// On each signature request the signatures of the attestation input are
+// verified against the pubkeys of the redeem script and every signer is
+// recorded as seen or as having missed the request, so that operators can
+// see which signers are offline before an attestation gets stuck
+
+// signer status warning consts
+const (
+	WarningSignerStatusGet  = "Could not get signer statuses"
+	WarningSignerStatusSave = "Could not save signer status"
+	WarningSignersMissed    = "Signers missed signature request"
+)
+
+// Return whether each signer of the redeem script, in redeem script order,
+// provided a valid signature for vin 0 of the attestation transaction,
+// with vin 0 signed with the script tweaked by hash as when signing
+// The signer of the client is taken to have signed if a key is set
+func (w *AttestClient) signersOfSigs(msgtx *wire.MsgTx, sigs [][]crypto.Sig, hash chainhash.Hash) ([]bool, error) {
+	signed := make([]bool, len(w.pubkeys))
+	if w.WalletPriv != nil {
+		for i_k, pubkey := range w.pubkeys {
+			if pubkey.IsEqual(w.WalletPriv.PrivKey.PubKey()) {
+				signed[i_k] = true
+			}
+		}
+	}
+	if len(msgtx.TxIn) == 0 || len(sigs) == 0 {
+		return signed, nil
+	}
+
+	redeemScript, redeemScriptErr := w.GetScriptFromHash(hash)
+	if redeemScriptErr != nil || redeemScript == "" {
+		return signed, redeemScriptErr
+	}
+	script, _ := hex.DecodeString(redeemScript)
+	pubkeys, pubkeysErr := w.scriptPubkeys(script)
+	if pubkeysErr != nil {
+		return signed, pubkeysErr
+	}
+	sigHash, sigHashErr := txscript.CalcSignatureHash(script, txscript.SigHashAll, msgtx, 0)
+	if sigHashErr != nil {
+		return signed, sigHashErr
+	}
+	for i_k := range signed {
+		if i_k >= len(pubkeys) {
+			break
+		}
+		for _, sig := range sigs[0] {
+			if isValidSig(sig, []*btcec.PublicKey{pubkeys[i_k]}, sigHash) {
+				signed[i_k] = true
+				break
+			}
+		}
+	}
+	return signed, nil
+}
+
+// part of AStateSignAttestation
+// record signers that signed or missed the latest signature request
+// Failures are only logged as liveness does not affect attestation
+func (s *AttestService) recordSignerStatus(lastCommitmentHash chainhash.Hash) {
+	signed, signedErr := s.attester.signersOfSigs(&s.attestation.Tx, s.sigs, lastCommitmentHash)
+	if signedErr != nil {
+		s.logger().WithFields(log.Fields{log.FieldError: signedErr}).Warnln(WarningSignerStatusGet)
+		return
+	}
+	statuses, statusesErr := s.server.GetSignerStatuses()
+	if statusesErr != nil {
+		s.logger().WithFields(log.Fields{log.FieldError: statusesErr}).Warnln(WarningSignerStatusGet)
+		return
+	}
+	previous := make(map[string]models.SignerStatus)
+	for _, status := range statuses {
+		previous[status.Pubkey] = status
+	}
+
+	now := clock.Now().Unix()
+	var missed []string
+	for i_k, pubkey := range s.attester.pubkeys {
+		pubkeyStr := hex.EncodeToString(pubkey.SerializeCompressed())
+		status := previous[pubkeyStr]
+		status.Pubkey = pubkeyStr
+		status.Position = i_k
+		status.LastRequest = now
+		if signed[i_k] {
+			status.LastSeen = now
+			status.Missed = 0
+		} else {
+			status.Missed++
+			missed = append(missed, fmt.Sprintf("%d", i_k))
+		}
+		if saveErr := s.server.RecordSignerStatus(status); saveErr != nil {
+			s.logger().WithFields(log.Fields{log.FieldError: saveErr}).Warnln(WarningSignerStatusSave)
+			return
+		}
+	}
+	if len(missed) > 0 {
+		s.logger().Warnf("%s (positions %s)\n", WarningSignersMissed, strings.Join(missed, ","))
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"testing"
+	"time"
+
+	"mainstay/crypto"
+	"mainstay/db"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test signers are identified from the valid signatures received
+func TestSignersOfSigs(t *testing.T) {
+	c := newSignerSetTestClient()
+	tx := newSignerSetTestTx()
+
+	signed, signedErr := c.client.signersOfSigs(tx, nil, chainhash.Hash{})
+	assert.Equal(t, nil, signedErr)
+	assert.Equal(t, []bool{false, false, false}, signed)
+
+	invalidSig := c.sign(t, newSignerSetTestTx(), 1)[0]
+	invalidSig[len(invalidSig)-2] ^= 1
+	sigs := [][]crypto.Sig{append(c.sign(t, tx, 2), invalidSig)}
+	signed, signedErr = c.client.signersOfSigs(tx, sigs, chainhash.Hash{})
+	assert.Equal(t, nil, signedErr)
+	assert.Equal(t, []bool{false, false, true}, signed)
+
+	// key of the client signs the attestation itself
+	c.client.WalletPriv, _ = btcutil.NewWIF(c.privs[0], c.client.MainChainCfg, true)
+	signed, signedErr = c.client.signersOfSigs(tx, sigs, chainhash.Hash{})
+	assert.Equal(t, nil, signedErr)
+	assert.Equal(t, []bool{true, false, true}, signed)
+}
+
+// Test signer liveness is recorded on each signature request
+func TestRecordSignerStatus(t *testing.T) {
+	c := newSignerSetTestClient()
+	tx := newSignerSetTestTx()
+	dbFake := db.NewDbFake()
+	attestation := models.NewAttestation(chainhash.Hash{}, nil)
+	attestation.Tx = *tx
+	s := &AttestService{attester: c.client, server: NewAttestServer(dbFake), attestation: attestation}
+
+	sim := newSimClock(time.Unix(1500000000, 0))
+	defer useSimClock(sim)()
+
+	s.sigs = [][]crypto.Sig{c.sign(t, tx, 0, 1)}
+	s.recordSignerStatus(chainhash.Hash{})
+	statuses, _ := s.server.GetSignerStatuses()
+	assert.Equal(t, 3, len(statuses))
+	assert.Equal(t, models.SignerStatus{Pubkey: c.pubkeyStrs[0], Position: 0,
+		LastRequest: 1500000000, LastSeen: 1500000000}, statuses[0])
+	assert.Equal(t, models.SignerHealthOnline, statuses[1].Health())
+	assert.Equal(t, models.SignerStatus{Pubkey: c.pubkeyStrs[2], Position: 2,
+		LastRequest: 1500000000, Missed: 1}, statuses[2])
+	assert.Equal(t, models.SignerHealthLagging, statuses[2].Health())
+
+	// missed requests accumulate until the signer signs again
+	sim.Advance(time.Minute)
+	s.sigs = [][]crypto.Sig{c.sign(t, tx, 0)}
+	s.recordSignerStatus(chainhash.Hash{})
+	s.recordSignerStatus(chainhash.Hash{})
+	statuses, _ = s.server.GetSignerStatuses()
+	assert.Equal(t, int64(1500000060), statuses[0].LastSeen)
+	assert.Equal(t, int64(1500000000), statuses[1].LastSeen)
+	assert.Equal(t, 2, statuses[1].Missed)
+	assert.Equal(t, models.SignerHealthOffline, statuses[2].Health())
+
+	s.sigs = [][]crypto.Sig{c.sign(t, tx, 2)}
+	s.recordSignerStatus(chainhash.Hash{})
+	statuses, _ = s.server.GetSignerStatuses()
+	assert.Equal(t, 0, statuses[2].Missed)
+	assert.Equal(t, int64(1500000060), statuses[2].LastSeen)
+}
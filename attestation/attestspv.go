@@ -0,0 +1,76 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"mainstay/models"
+)
+
+// SPV proofs of attestation transactions are fetched from the main chain
+// backend, through the gettxoutproof rpc of the main client node, which
+// requires the node to have the block, or the merkleblock-proof endpoint of
+// Esplora, and served with the proof bundles of the request api
+
+// error consts
+const (
+	ErrorSpvUnsupported = "Chain backend does not support spv proofs"
+	ErrorSpvProofGet    = "Could not get spv proof"
+)
+
+// chain backend returning serialized merkle blocks proving transactions
+type txOutProver interface {
+	GetTxOutProof(string, string) ([]byte, error)
+}
+
+// Return merkle block proving the transaction in the block
+// through the gettxoutproof rpc of the main client node
+func rpcTxOutProof(client rawRequester, txid string, blockhash string) ([]byte, error) {
+	txidsJson, _ := json.Marshal([]string{txid})
+	blockhashJson, _ := json.Marshal(blockhash)
+	proofJson, proofErr := client.RawRequest("gettxoutproof",
+		[]json.RawMessage{txidsJson, blockhashJson})
+	if proofErr != nil {
+		return nil, proofErr
+	}
+	var proofHex string
+	if unmarshalErr := json.Unmarshal(proofJson, &proofHex); unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+	return hex.DecodeString(proofHex)
+}
+
+// Return spv proof of the transaction in the block from the chain backend
+func chainSpvProof(chain ChainBackend, txid string, blockhash string) (models.SpvProof, error) {
+	var merkleBlock []byte
+	var proofErr error
+	if prover, ok := chain.(txOutProver); ok {
+		merkleBlock, proofErr = prover.GetTxOutProof(txid, blockhash)
+	} else if client, ok := chain.(rawRequester); ok {
+		merkleBlock, proofErr = rpcTxOutProof(client, txid, blockhash)
+	} else {
+		return models.SpvProof{}, errors.New(ErrorSpvUnsupported)
+	}
+	if proofErr != nil {
+		return models.SpvProof{}, errors.New(fmt.Sprintf("%s: %v", ErrorSpvProofGet, proofErr))
+	}
+	proof, newErr := models.NewSpvProof(merkleBlock)
+	if newErr != nil {
+		return models.SpvProof{}, newErr
+	}
+	if verifyErr := models.VerifySpvProof(proof, txid, blockhash); verifyErr != nil {
+		return models.SpvProof{}, verifyErr
+	}
+	return proof, nil
+}
+
+// Return spv proof of the attestation transaction in the block confirming it
+func (s *AttestService) SpvProof(txid string, blockhash string) (models.SpvProof, error) {
+	return chainSpvProof(s.attester.Chain, txid, blockhash)
+}
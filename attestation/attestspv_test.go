@@ -0,0 +1,97 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/bloom"
+	"github.com/stretchr/testify/assert"
+)
+
+// chain backend replying to gettxoutproof with a fixed merkle block
+type spvTestChain struct {
+	ChainBackend
+	proof  string
+	params []json.RawMessage
+}
+
+func (c *spvTestChain) RawRequest(method string, params []json.RawMessage) (json.RawMessage, error) {
+	if method != "gettxoutproof" {
+		return nil, errors.New("unexpected method")
+	}
+	c.params = params
+	proofJson, _ := json.Marshal(c.proof)
+	return proofJson, nil
+}
+
+// Return hex encoded merkle block of a test block of three
+// transactions proving the second, with its txid and block hash
+func spvTestMerkleBlock() (string, string, string) {
+	msgBlock := wire.NewMsgBlock(&wire.BlockHeader{Version: 1, Timestamp: time.Unix(1542121293, 0)})
+	for i := 0; i < 3; i++ {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, uint32(i)), nil, nil))
+		msgBlock.AddTransaction(tx)
+	}
+	merkles := blockchain.BuildMerkleTreeStore(btcutil.NewBlock(msgBlock).Transactions(), false)
+	msgBlock.Header.MerkleRoot = *merkles[len(merkles)-1]
+
+	txid := msgBlock.Transactions[1].TxHash()
+	filter := bloom.NewFilter(1, 0, 0, wire.BloomUpdateNone)
+	filter.AddHash(&txid)
+	merkleBlock, _ := bloom.NewMerkleBlock(btcutil.NewBlock(msgBlock), filter)
+	var buf bytes.Buffer
+	merkleBlock.BtcEncode(&buf, wire.ProtocolVersion, wire.BaseEncoding)
+	return hex.EncodeToString(buf.Bytes()), txid.String(), msgBlock.BlockHash().String()
+}
+
+// Test spv proofs of attestations from the main client rpc and esplora
+func TestAttestServiceSpvProof(t *testing.T) {
+	proofHex, txid, blockhash := spvTestMerkleBlock()
+
+	// main client rpc
+	chain := &spvTestChain{proof: proofHex}
+	s := &AttestService{attester: &AttestClient{Chain: chain}}
+	proof, proofErr := s.SpvProof(txid, blockhash)
+	assert.Equal(t, nil, proofErr)
+	assert.Equal(t, proofHex, proof.MerkleBlock)
+	assert.Equal(t, proofHex[:160], proof.Header)
+	assert.Equal(t, []json.RawMessage{json.RawMessage(`["` + txid + `"]`), json.RawMessage(`"` + blockhash + `"`)},
+		chain.params)
+
+	// proof not matching the attestation block
+	_, proofErr = s.SpvProof(txid, txid)
+	assert.Equal(t, errors.New(models.ErrorSpvProofBlock), proofErr)
+
+	// esplora
+	fake := &esploraFake{proofs: map[string]string{txid: proofHex}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	s = &AttestService{attester: &AttestClient{Chain: NewEsploraClient(confpkg.EsploraConfig{Url: server.URL}, txid, "")}}
+	proof, proofErr = s.SpvProof(txid, blockhash)
+	assert.Equal(t, nil, proofErr)
+	assert.Equal(t, proofHex, proof.MerkleBlock)
+	_, proofErr = s.SpvProof(blockhash, blockhash)
+	assert.Contains(t, proofErr.Error(), ErrorSpvProofGet)
+
+	// unsupported chain backend
+	s = &AttestService{attester: &AttestClient{Chain: struct{ ChainBackend }{}}}
+	_, proofErr = s.SpvProof(txid, blockhash)
+	assert.Equal(t, errors.New(ErrorSpvUnsupported), proofErr)
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+// While the main chain node is in initial block download, reindexing or
+// warming up after startup, its wallet and chain state are incomplete and
+// attestation states would fail with misleading errors, e.g. no unspent found.
+// The attestation service instead waits, without running any states, until
+// the node has synced, reporting the sync progress in logs and readiness
+
+// warning consts
+const (
+	WarningChainSyncing = "Main chain node syncing - waiting"
+)
+
+// chain sync info from the getblockchaininfo rpc
+// Requested raw as the rpc client result lacks the initial block download flag
+type chainSyncInfo struct {
+	Blocks               int64   `json:"blocks"`
+	Headers              int64   `json:"headers"`
+	VerificationProgress float64 `json:"verificationprogress"`
+	InitialBlockDownload bool    `json:"initialblockdownload"`
+}
+
+// rpc client sending raw json-rpc requests
+type rawRequester interface {
+	RawRequest(string, []json.RawMessage) (json.RawMessage, error)
+}
+
+// Return whether the node of the rpc client is syncing, with a description of
+// the sync progress, or any error requesting the chain info
+func rpcChainSyncing(client rawRequester) (bool, string, error) {
+	infoJson, infoErr := client.RawRequest("getblockchaininfo", nil)
+	if infoErr != nil {
+		if rpcErr, ok := infoErr.(*btcjson.RPCError); ok && rpcErr.Code == rpcErrorInWarmup {
+			return true, rpcErr.Message, nil
+		}
+		return false, "", infoErr
+	}
+	var info chainSyncInfo
+	if unmarshalErr := json.Unmarshal(infoJson, &info); unmarshalErr != nil {
+		return false, "", unmarshalErr
+	}
+	if !info.InitialBlockDownload {
+		return false, "", nil
+	}
+	return true, fmt.Sprintf("blocks %d/%d, progress %.2f%%",
+		info.Blocks, info.Headers, 100*info.VerificationProgress), nil
+}
+
+// Return whether the main chain node is syncing, with a description of the
+// sync progress. Backends other than the rpc client are never syncing
+func (s *AttestService) chainSyncing() (bool, string, error) {
+	if client, ok := s.attester.Chain.(rawRequester); ok {
+		return rpcChainSyncing(client)
+	}
+	return false, "", nil
+}
+
+// Check whether the main chain node is syncing and if so set the waiting time
+// to the next check. Returns true while syncing, when no state should be run
+// Errors requesting the chain info are left to the attestation states
+func (s *AttestService) waitChainSync() bool {
+	syncing, progress, _ := s.chainSyncing()
+	if !syncing {
+		if atomic.CompareAndSwapInt32(&s.syncing, 1, 0) {
+			s.logger().Infoln("main chain node synced - resuming")
+		}
+		return false
+	}
+	atomic.StoreInt32(&s.syncing, 1)
+	s.logger().Warnf("%s (%s)\n", WarningChainSyncing, progress)
+	s.attestDelay = ATimeSync
+	atomic.StoreInt64(&s.nextState, clock.Now().Add(s.attestDelay).Unix())
+	return true
+}
+
+// Return whether the attestation service is waiting for the main chain node to sync
+func (s *AttestService) IsSyncing() bool {
+	return atomic.LoadInt32(&s.syncing) == 1
+}
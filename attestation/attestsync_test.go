@@ -0,0 +1,86 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/stretchr/testify/assert"
+)
+
+// chain backend replying to getblockchaininfo with a fixed result
+type syncTestChain struct {
+	ChainBackend
+	info string
+	err  error
+}
+
+func (c *syncTestChain) RawRequest(method string, params []json.RawMessage) (json.RawMessage, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return json.RawMessage(c.info), nil
+}
+
+// Test main chain node sync status from getblockchaininfo
+func TestChainSyncing(t *testing.T) {
+	chain := &syncTestChain{info: `{"blocks":1200,"headers":54000,"verificationprogress":0.0221,"initialblockdownload":true}`}
+	syncing, progress, err := rpcChainSyncing(chain)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, syncing)
+	assert.Equal(t, "blocks 1200/54000, progress 2.21%", progress)
+
+	chain.info = `{"blocks":54000,"headers":54000,"verificationprogress":0.9999,"initialblockdownload":false}`
+	syncing, progress, err = rpcChainSyncing(chain)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, false, syncing)
+	assert.Equal(t, "", progress)
+
+	chain.err = &btcjson.RPCError{Code: -28, Message: "Loading block index..."}
+	syncing, progress, err = rpcChainSyncing(chain)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, syncing)
+	assert.Equal(t, "Loading block index...", progress)
+
+	chain.err = io.EOF
+	syncing, _, err = rpcChainSyncing(chain)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, false, syncing)
+
+	chain.err = nil
+	chain.info = `{"blocks":`
+	_, _, err = rpcChainSyncing(chain)
+	assert.NotEqual(t, nil, err)
+}
+
+// Test attestation service waits while the main chain node syncs
+func TestWaitChainSync(t *testing.T) {
+	chain := &syncTestChain{info: `{"blocks":10,"headers":100,"verificationprogress":0.1,"initialblockdownload":true}`}
+	s := &AttestService{attester: &AttestClient{Chain: chain}}
+
+	assert.Equal(t, true, s.waitChainSync())
+	assert.Equal(t, true, s.IsSyncing())
+	assert.Equal(t, ATimeSync, s.attestDelay)
+	assert.NotEqual(t, int64(0), s.nextState)
+	assert.Equal(t, ErrorChainSyncing+" (blocks 10/100, progress 10.00%)", s.checkBitcoind().Error())
+
+	// rpc errors are left to the attestation states
+	chain.err = errors.New("connection refused")
+	assert.Equal(t, false, s.waitChainSync())
+	assert.Equal(t, false, s.IsSyncing())
+
+	chain.err = nil
+	chain.info = `{"blocks":100,"headers":100,"verificationprogress":1,"initialblockdownload":false}`
+	assert.Equal(t, false, s.waitChainSync())
+	assert.Equal(t, false, s.IsSyncing())
+
+	// backends without raw rpc requests are never syncing
+	s = &AttestService{attester: &AttestClient{Chain: &EsploraClient{}}}
+	assert.Equal(t, false, s.waitChainSync())
+}
@@ -0,0 +1,240 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/log"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// When the main client node is shared with other services, rpc calls of the
+// attestation client can be rate limited per second and/or per minute with
+// token buckets. Calls over the limit are queued by priority, so that calls
+// creating and broadcasting attestations go ahead of staychain lookups and
+// confirmation checks, which can safely be deferred to the next state run.
+// Wallet calls for signing and unlocking go through the main client directly
+// and are not throttled
+
+// throttle consts
+const (
+	ThrottlePollInterval = 10 * time.Millisecond // wait of calls deferred to higher priority calls
+
+	ErrorThrottleRawRequest = "Raw requests not supported by chain backend"
+
+	WarningInvalidThrottleRateArg = "Invalid rpc throttle rate config value"
+)
+
+// rpc call priorities, highest first
+const (
+	RpcPriorityHigh = iota
+	RpcPriorityNormal
+	RpcPriorityLow
+	numRpcPriorities
+)
+
+// token bucket refilled at a constant rate up to its capacity
+type tokenBucket struct {
+	capacity float64
+	tokens   float64
+	rate     float64 // tokens per second
+	last     time.Time
+}
+
+// Return full token bucket of capacity refilled over the period
+func newTokenBucket(capacity int, period time.Duration, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		capacity: float64(capacity),
+		tokens:   float64(capacity),
+		rate:     float64(capacity) / period.Seconds(),
+		last:     now,
+	}
+}
+
+// Refill tokens for the time elapsed since the last refill
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.rate
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+	}
+	b.last = now
+}
+
+// Return time until a token is available
+func (b *tokenBucket) delay() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// RpcThrottle struct
+// Token buckets of the rate limits and number of calls waiting per priority
+type RpcThrottle struct {
+	mu      sync.Mutex
+	buckets []*tokenBucket
+	waiting [numRpcPriorities]int
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// Return new RpcThrottle from config or nil if no rate limits are set
+func NewRpcThrottle(config confpkg.ThrottleConfig) *RpcThrottle {
+	t := &RpcThrottle{now: time.Now, sleep: time.Sleep}
+	if config.PerSecond > 0 {
+		t.buckets = append(t.buckets, newTokenBucket(config.PerSecond, time.Second, t.now()))
+	} else if config.PerSecond == 0 {
+		log.Warnf("%s (%d)\n", WarningInvalidThrottleRateArg, config.PerSecond)
+	}
+	if config.PerMinute > 0 {
+		t.buckets = append(t.buckets, newTokenBucket(config.PerMinute, time.Minute, t.now()))
+	} else if config.PerMinute == 0 {
+		log.Warnf("%s (%d)\n", WarningInvalidThrottleRateArg, config.PerMinute)
+	}
+	if len(t.buckets) == 0 {
+		return nil
+	}
+	return t
+}
+
+// Return whether calls of higher priority than priority are waiting
+func (t *RpcThrottle) higherWaiting(priority int) bool {
+	for p := 0; p < priority; p++ {
+		if t.waiting[p] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Wait until an rpc call of the priority is within the rate limits,
+// deferring to any waiting calls of higher priority
+func (t *RpcThrottle) Wait(priority int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.waiting[priority]++
+	for {
+		now := t.now()
+		var delay time.Duration
+		for _, bucket := range t.buckets {
+			bucket.refill(now)
+			if bucketDelay := bucket.delay(); bucketDelay > delay {
+				delay = bucketDelay
+			}
+		}
+		if t.higherWaiting(priority) {
+			if delay < ThrottlePollInterval {
+				delay = ThrottlePollInterval
+			}
+		} else if delay == 0 {
+			for _, bucket := range t.buckets {
+				bucket.tokens--
+			}
+			t.waiting[priority]--
+			t.mu.Unlock()
+			return
+		}
+		t.mu.Unlock()
+		t.sleep(delay)
+		t.mu.Lock()
+	}
+}
+
+// ChainThrottled struct
+// Chain backend with rpc calls rate limited by the throttle
+type ChainThrottled struct {
+	chain    ChainBackend
+	throttle *RpcThrottle
+}
+
+// Return new ChainThrottled for chain backend and throttle
+func NewChainThrottled(chain ChainBackend, throttle *RpcThrottle) *ChainThrottled {
+	return &ChainThrottled{chain, throttle}
+}
+
+// Get block count
+func (c *ChainThrottled) GetBlockCount() (int64, error) {
+	c.throttle.Wait(RpcPriorityNormal)
+	return c.chain.GetBlockCount()
+}
+
+// List unspent outputs
+func (c *ChainThrottled) ListUnspent() ([]btcjson.ListUnspentResult, error) {
+	c.throttle.Wait(RpcPriorityHigh)
+	return c.chain.ListUnspent()
+}
+
+// Get raw mempool
+func (c *ChainThrottled) GetRawMempool() ([]*chainhash.Hash, error) {
+	c.throttle.Wait(RpcPriorityNormal)
+	return c.chain.GetRawMempool()
+}
+
+// Get raw transaction
+func (c *ChainThrottled) GetRawTransaction(txid *chainhash.Hash) (*btcutil.Tx, error) {
+	c.throttle.Wait(RpcPriorityNormal)
+	return c.chain.GetRawTransaction(txid)
+}
+
+// Get raw transaction verbose
+func (c *ChainThrottled) GetRawTransactionVerbose(txid *chainhash.Hash) (*btcjson.TxRawResult, error) {
+	c.throttle.Wait(RpcPriorityHigh)
+	return c.chain.GetRawTransactionVerbose(txid)
+}
+
+// Get wallet transaction, used for confirmation checks
+func (c *ChainThrottled) GetTransaction(txid *chainhash.Hash) (*btcjson.GetTransactionResult, error) {
+	c.throttle.Wait(RpcPriorityLow)
+	return c.chain.GetTransaction(txid)
+}
+
+// Get mempool entry
+func (c *ChainThrottled) GetMempoolEntry(txid string) (*btcjson.GetMempoolEntryResult, error) {
+	c.throttle.Wait(RpcPriorityNormal)
+	return c.chain.GetMempoolEntry(txid)
+}
+
+// Create raw transaction
+func (c *ChainThrottled) CreateRawTransaction(inputs []btcjson.TransactionInput,
+	amounts map[btcutil.Address]btcutil.Amount, lockTime *int64) (*wire.MsgTx, error) {
+	c.throttle.Wait(RpcPriorityHigh)
+	return c.chain.CreateRawTransaction(inputs, amounts, lockTime)
+}
+
+// Send raw transaction
+func (c *ChainThrottled) SendRawTransaction(tx *wire.MsgTx, allowHighFees bool) (*chainhash.Hash, error) {
+	c.throttle.Wait(RpcPriorityHigh)
+	return c.chain.SendRawTransaction(tx, allowHighFees)
+}
+
+// Import address with rescan
+func (c *ChainThrottled) ImportAddressRescan(address string, account string, rescan bool) error {
+	c.throttle.Wait(RpcPriorityHigh)
+	return c.chain.ImportAddressRescan(address, account, rescan)
+}
+
+// Send raw json-rpc request, e.g. for the node sync status
+func (c *ChainThrottled) RawRequest(method string, params []json.RawMessage) (json.RawMessage, error) {
+	client, ok := c.chain.(rawRequester)
+	if !ok {
+		return nil, errors.New(ErrorThrottleRawRequest)
+	}
+	c.throttle.Wait(RpcPriorityNormal)
+	return client.RawRequest(method, params)
+}
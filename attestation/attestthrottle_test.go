@@ -0,0 +1,121 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"testing"
+	"time"
+
+	confpkg "mainstay/config"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+// Return throttle with a fake clock advanced by sleeping
+// and the list of sleeps of the throttle
+func newTestRpcThrottle(config confpkg.ThrottleConfig) (*RpcThrottle, *[]time.Duration) {
+	var sleeps []time.Duration
+	now := time.Unix(1500000000, 0)
+	throttle := NewRpcThrottle(config)
+	if throttle == nil {
+		return nil, &sleeps
+	}
+	throttle.now = func() time.Time { return now }
+	for _, bucket := range throttle.buckets {
+		bucket.last = now
+	}
+	throttle.sleep = func(d time.Duration) {
+		sleeps = append(sleeps, d)
+		now = now.Add(d)
+	}
+	return throttle, &sleeps
+}
+
+// Test throttle is only enabled with rate limits
+func TestNewRpcThrottle(t *testing.T) {
+	assert.Nil(t, NewRpcThrottle(confpkg.ThrottleConfig{PerSecond: -1, PerMinute: -1}))
+	assert.Nil(t, NewRpcThrottle(confpkg.ThrottleConfig{PerSecond: 0, PerMinute: 0}))
+	assert.Equal(t, 1, len(NewRpcThrottle(confpkg.ThrottleConfig{PerSecond: 5, PerMinute: -1}).buckets))
+	assert.Equal(t, 2, len(NewRpcThrottle(confpkg.ThrottleConfig{PerSecond: 5, PerMinute: 60}).buckets))
+
+	// waiting on a disabled throttle returns immediately
+	var throttle *RpcThrottle
+	throttle.Wait(RpcPriorityLow)
+}
+
+// Test calls are delayed once the rate limits are exceeded
+func TestRpcThrottleWait(t *testing.T) {
+	throttle, sleeps := newTestRpcThrottle(confpkg.ThrottleConfig{PerSecond: 2, PerMinute: -1})
+	throttle.Wait(RpcPriorityNormal)
+	throttle.Wait(RpcPriorityNormal)
+	assert.Equal(t, 0, len(*sleeps))
+	throttle.Wait(RpcPriorityNormal)
+	assert.Equal(t, []time.Duration{500 * time.Millisecond}, *sleeps)
+
+	// per minute limit applies over the per second limit
+	throttle, sleeps = newTestRpcThrottle(confpkg.ThrottleConfig{PerSecond: 10, PerMinute: 3})
+	for i := 0; i < 3; i++ {
+		throttle.Wait(RpcPriorityHigh)
+	}
+	assert.Equal(t, 0, len(*sleeps))
+	throttle.Wait(RpcPriorityHigh)
+	assert.Equal(t, []time.Duration{20 * time.Second}, *sleeps)
+
+	// tokens refill up to the bucket capacity only
+	throttle, sleeps = newTestRpcThrottle(confpkg.ThrottleConfig{PerSecond: 2, PerMinute: -1})
+	throttle.sleep(time.Hour)
+	for i := 0; i < 3; i++ {
+		throttle.Wait(RpcPriorityNormal)
+	}
+	assert.Equal(t, []time.Duration{time.Hour, 500 * time.Millisecond}, *sleeps)
+}
+
+// Test lower priority calls defer to waiting higher priority calls
+func TestRpcThrottleWaitPriority(t *testing.T) {
+	throttle, sleeps := newTestRpcThrottle(confpkg.ThrottleConfig{PerSecond: 1, PerMinute: -1})
+	sleep := throttle.sleep
+
+	// a broadcast is waiting for a token while a confirmation check is made
+	throttle.waiting[RpcPriorityHigh] = 1
+	throttle.sleep = func(d time.Duration) {
+		sleep(d)
+		throttle.mu.Lock()
+		if len(*sleeps) == 2 {
+			throttle.waiting[RpcPriorityHigh] = 0
+			throttle.buckets[0].tokens--
+		}
+		throttle.mu.Unlock()
+	}
+	throttle.Wait(RpcPriorityLow)
+	assert.Equal(t, []time.Duration{ThrottlePollInterval, ThrottlePollInterval, 990 * time.Millisecond}, *sleeps)
+	assert.Equal(t, [numRpcPriorities]int{0, 0, 0}, throttle.waiting)
+
+	// calls of the same or lower priority waiting do not delay calls
+	throttle, sleeps = newTestRpcThrottle(confpkg.ThrottleConfig{PerSecond: 1, PerMinute: -1})
+	throttle.waiting[RpcPriorityLow] = 1
+	throttle.Wait(RpcPriorityNormal)
+	assert.Equal(t, 0, len(*sleeps))
+}
+
+// Test throttled chain backend forwards calls to the chain
+func TestChainThrottled(t *testing.T) {
+	tx := newInitTestTx(chainhash.Hash{1}, nil)
+	chain := &initChainFake{txs: map[chainhash.Hash]*wire.MsgTx{tx.TxHash(): tx}}
+	throttle, sleeps := newTestRpcThrottle(confpkg.ThrottleConfig{PerSecond: 1, PerMinute: -1})
+	throttled := NewChainThrottled(chain, throttle)
+
+	txid := tx.TxHash()
+	rawTx, rawTxErr := throttled.GetRawTransaction(&txid)
+	assert.Equal(t, nil, rawTxErr)
+	assert.Equal(t, txid, *rawTx.Hash())
+	assert.Equal(t, nil, throttled.ImportAddressRescan("addr", "", false))
+	assert.Equal(t, []string{"addr"}, chain.imported)
+	assert.Equal(t, []time.Duration{time.Second}, *sleeps)
+
+	_, rawErr := throttled.RawRequest("getblockchaininfo", nil)
+	assert.Equal(t, ErrorThrottleRawRequest, rawErr.Error())
+}
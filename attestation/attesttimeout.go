@@ -0,0 +1,264 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/log"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// Main client rpc calls block until the node replies, so each call is run
+// with a deadline and abandoned once the deadline passes or the service is
+// shut down. Timed out calls fail with a transient rpc error, so that the
+// state is retried in place or the call fails over to the next node
+
+// default rpc call timeout
+const DefaultRpcTimeout = time.Minute
+
+// rpc timeout error/warning consts
+const (
+	ErrorRpcTimeout          = "main client rpc call timed out"
+	WarningInvalidRpcTimeout = "Invalid rpc timeout config value"
+	ErrorTimeoutRawRequest   = "raw rpc requests not supported by timed out chain"
+)
+
+// RpcTimeout struct
+// Deadline of main client rpc calls and the context cancelling calls on shutdown
+type RpcTimeout struct {
+	timeout time.Duration
+
+	mu  sync.Mutex
+	ctx context.Context
+}
+
+// Return new RpcTimeout from rpc config
+func NewRpcTimeout(config confpkg.RpcConfig) *RpcTimeout {
+	timeout := DefaultRpcTimeout
+	if config.TimeoutSeconds > 0 {
+		timeout = time.Duration(config.TimeoutSeconds) * time.Second
+	} else if config.TimeoutSeconds != -1 {
+		log.Warnf("%s (%v)\n", WarningInvalidRpcTimeout, config.TimeoutSeconds)
+	}
+	return &RpcTimeout{timeout: timeout, ctx: context.Background()}
+}
+
+// Set context cancelling rpc calls, e.g. the service context
+func (t *RpcTimeout) SetContext(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ctx = ctx
+}
+
+// Return context cancelling rpc calls
+func (t *RpcTimeout) context() context.Context {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.ctx
+}
+
+// Run rpc call, returning once the call returns, the deadline passes or
+// the context is cancelled. Calls are run without a deadline if long is set,
+// e.g. imports rescanning the chain. Results must only be read by the caller
+// if no error is returned, as an abandoned call may still set these
+func (t *RpcTimeout) Do(method string, long bool, call func() error) error {
+	if t == nil {
+		return call()
+	}
+	ctx := t.context()
+	done := make(chan error, 1)
+	go func() {
+		done <- call()
+	}()
+
+	var deadline <-chan time.Time
+	if !long {
+		timer := time.NewTimer(t.timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	select {
+	case callErr := <-done:
+		return callErr
+	case <-deadline:
+		return NewAttestError(ErrorClassRpcTransient,
+			errors.New(fmt.Sprintf("%s: %s (%v)", ErrorRpcTimeout, method, t.timeout)))
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Set context cancelling main client rpc calls of the client
+func (w *AttestClient) SetRpcContext(ctx context.Context) {
+	w.rpcTimeout.SetContext(ctx)
+}
+
+// ChainTimeout struct
+// Chain backend with rpc calls run with a deadline
+type ChainTimeout struct {
+	chain   ChainBackend
+	timeout *RpcTimeout
+}
+
+// Return new ChainTimeout for chain backend and timeout
+func NewChainTimeout(chain ChainBackend, timeout *RpcTimeout) *ChainTimeout {
+	return &ChainTimeout{chain, timeout}
+}
+
+// Get block count
+func (c *ChainTimeout) GetBlockCount() (int64, error) {
+	var count int64
+	callErr := c.timeout.Do("getblockcount", false, func() (err error) {
+		count, err = c.chain.GetBlockCount()
+		return err
+	})
+	if callErr != nil {
+		return 0, callErr
+	}
+	return count, nil
+}
+
+// List unspent outputs
+func (c *ChainTimeout) ListUnspent() ([]btcjson.ListUnspentResult, error) {
+	var unspent []btcjson.ListUnspentResult
+	callErr := c.timeout.Do("listunspent", false, func() (err error) {
+		unspent, err = c.chain.ListUnspent()
+		return err
+	})
+	if callErr != nil {
+		return nil, callErr
+	}
+	return unspent, nil
+}
+
+// Get raw mempool
+func (c *ChainTimeout) GetRawMempool() ([]*chainhash.Hash, error) {
+	var mempool []*chainhash.Hash
+	callErr := c.timeout.Do("getrawmempool", false, func() (err error) {
+		mempool, err = c.chain.GetRawMempool()
+		return err
+	})
+	if callErr != nil {
+		return nil, callErr
+	}
+	return mempool, nil
+}
+
+// Get raw transaction
+func (c *ChainTimeout) GetRawTransaction(txid *chainhash.Hash) (*btcutil.Tx, error) {
+	var tx *btcutil.Tx
+	callErr := c.timeout.Do("getrawtransaction", false, func() (err error) {
+		tx, err = c.chain.GetRawTransaction(txid)
+		return err
+	})
+	if callErr != nil {
+		return nil, callErr
+	}
+	return tx, nil
+}
+
+// Get raw transaction verbose
+func (c *ChainTimeout) GetRawTransactionVerbose(txid *chainhash.Hash) (*btcjson.TxRawResult, error) {
+	var tx *btcjson.TxRawResult
+	callErr := c.timeout.Do("getrawtransaction", false, func() (err error) {
+		tx, err = c.chain.GetRawTransactionVerbose(txid)
+		return err
+	})
+	if callErr != nil {
+		return nil, callErr
+	}
+	return tx, nil
+}
+
+// Get wallet transaction, used for confirmation checks
+func (c *ChainTimeout) GetTransaction(txid *chainhash.Hash) (*btcjson.GetTransactionResult, error) {
+	var tx *btcjson.GetTransactionResult
+	callErr := c.timeout.Do("gettransaction", false, func() (err error) {
+		tx, err = c.chain.GetTransaction(txid)
+		return err
+	})
+	if callErr != nil {
+		return nil, callErr
+	}
+	return tx, nil
+}
+
+// Get mempool entry
+func (c *ChainTimeout) GetMempoolEntry(txid string) (*btcjson.GetMempoolEntryResult, error) {
+	var entry *btcjson.GetMempoolEntryResult
+	callErr := c.timeout.Do("getmempoolentry", false, func() (err error) {
+		entry, err = c.chain.GetMempoolEntry(txid)
+		return err
+	})
+	if callErr != nil {
+		return nil, callErr
+	}
+	return entry, nil
+}
+
+// Create raw transaction
+func (c *ChainTimeout) CreateRawTransaction(inputs []btcjson.TransactionInput,
+	amounts map[btcutil.Address]btcutil.Amount, lockTime *int64) (*wire.MsgTx, error) {
+	var tx *wire.MsgTx
+	callErr := c.timeout.Do("createrawtransaction", false, func() (err error) {
+		tx, err = c.chain.CreateRawTransaction(inputs, amounts, lockTime)
+		return err
+	})
+	if callErr != nil {
+		return nil, callErr
+	}
+	return tx, nil
+}
+
+// Send raw transaction
+func (c *ChainTimeout) SendRawTransaction(tx *wire.MsgTx, allowHighFees bool) (*chainhash.Hash, error) {
+	var txid *chainhash.Hash
+	callErr := c.timeout.Do("sendrawtransaction", false, func() (err error) {
+		txid, err = c.chain.SendRawTransaction(tx, allowHighFees)
+		return err
+	})
+	if callErr != nil {
+		return nil, callErr
+	}
+	return txid, nil
+}
+
+// Import address with rescan, without a deadline if rescanning
+func (c *ChainTimeout) ImportAddressRescan(address string, account string, rescan bool) error {
+	return c.timeout.Do("importaddress", rescan, func() error {
+		return c.chain.ImportAddressRescan(address, account, rescan)
+	})
+}
+
+// Send raw json-rpc request, e.g. for the node sync status
+func (c *ChainTimeout) RawRequest(method string, params []json.RawMessage) (json.RawMessage, error) {
+	client, ok := c.chain.(rawRequester)
+	if !ok {
+		return nil, errors.New(ErrorTimeoutRawRequest)
+	}
+	var result json.RawMessage
+	callErr := c.timeout.Do(method, false, func() (err error) {
+		result, err = client.RawRequest(method, params)
+		return err
+	})
+	if callErr != nil {
+		return nil, callErr
+	}
+	return result, nil
+}
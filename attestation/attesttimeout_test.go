@@ -0,0 +1,110 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	confpkg "mainstay/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// main chain node blocking calls until released
+type timeoutNodeFake struct {
+	ChainBackend
+	release chan struct{}
+	height  int64
+}
+
+func (n *timeoutNodeFake) GetBlockCount() (int64, error) {
+	<-n.release
+	return n.height, nil
+}
+
+func (n *timeoutNodeFake) ImportAddressRescan(address string, account string, rescan bool) error {
+	<-n.release
+	return nil
+}
+
+func (n *timeoutNodeFake) RawRequest(method string, params []json.RawMessage) (json.RawMessage, error) {
+	<-n.release
+	return nil, io.EOF
+}
+
+// Test rpc timeout config
+func TestNewRpcTimeout(t *testing.T) {
+	assert.Equal(t, DefaultRpcTimeout, NewRpcTimeout(confpkg.RpcConfig{TimeoutSeconds: -1}).timeout)
+	assert.Equal(t, DefaultRpcTimeout, NewRpcTimeout(confpkg.RpcConfig{TimeoutSeconds: 0}).timeout)
+	assert.Equal(t, 30*time.Second, NewRpcTimeout(confpkg.RpcConfig{TimeoutSeconds: 30}).timeout)
+
+	// calls without timeout are run directly
+	var timeout *RpcTimeout
+	timeout.SetContext(context.Background())
+	assert.Equal(t, io.EOF, timeout.Do("getblockcount", false, func() error { return io.EOF }))
+}
+
+// Test hung calls fail with a transient error once the deadline passes
+func TestChainTimeout(t *testing.T) {
+	node := &timeoutNodeFake{release: make(chan struct{}), height: 100}
+	timeout := &RpcTimeout{timeout: 10 * time.Millisecond, ctx: context.Background()}
+	chain := NewChainTimeout(node, timeout)
+
+	count, countErr := chain.GetBlockCount()
+	assert.Equal(t, int64(0), count)
+	assert.Equal(t, ErrorClassRpcTransient, ClassifyError(countErr))
+	assert.Equal(t, ErrorRpcTimeout+": getblockcount (10ms)", countErr.Error())
+
+	_, rawErr := chain.RawRequest("getblockchaininfo", nil)
+	assert.Equal(t, ErrorRpcTimeout+": getblockchaininfo (10ms)", rawErr.Error())
+
+	// calls returning in time are unaffected
+	node = &timeoutNodeFake{release: make(chan struct{}), height: 101}
+	chain = NewChainTimeout(node, timeout)
+	go func() { node.release <- struct{}{} }()
+	timeout.timeout = time.Minute
+	count, countErr = chain.GetBlockCount()
+	assert.Equal(t, nil, countErr)
+	assert.Equal(t, int64(101), count)
+
+	// rescanning imports have no deadline
+	timeout.timeout = 10 * time.Millisecond
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		node.release <- struct{}{}
+	}()
+	assert.Equal(t, nil, chain.ImportAddressRescan("addr", "", true))
+
+	_, rawErr = NewChainTimeout(&initChainFake{}, timeout).RawRequest("getblockchaininfo", nil)
+	assert.Equal(t, ErrorTimeoutRawRequest, rawErr.Error())
+}
+
+// Test hung calls are cancelled on shutdown
+func TestChainTimeoutCancel(t *testing.T) {
+	node := &timeoutNodeFake{release: make(chan struct{})}
+	timeout := NewRpcTimeout(confpkg.RpcConfig{TimeoutSeconds: -1})
+	ctx, cancel := context.WithCancel(context.Background())
+	timeout.SetContext(ctx)
+	chain := NewChainTimeout(node, timeout)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	_, countErr := chain.GetBlockCount()
+	assert.Equal(t, context.Canceled, countErr)
+}
+
+// Test main client rpc calls are run with the timeout without configured rpc urls
+func TestNewChainBackendTimeout(t *testing.T) {
+	timeout := NewRpcTimeout(confpkg.RpcConfig{})
+	chain, isTimeout := NewChainBackend(&confpkg.Config{}, timeout).(*ChainTimeout)
+	assert.True(t, isTimeout)
+	assert.Equal(t, timeout, chain.timeout)
+}
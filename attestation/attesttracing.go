@@ -0,0 +1,81 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"context"
+
+	"mainstay/tracing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Each attestation cycle, from fetching the next commitment to the attestation
+// confirming, is traced with a cycle span and a child span for each state
+// Db and signer calls are traced as children of the current state span
+// through the trace scope shared with the traced db and signer wrappers
+
+// span names
+const (
+	SpanCycle       = "attestation.cycle"
+	SpanStatePrefix = "attestation."
+)
+
+// Set scope through which traced db and signer calls find the current state span
+func (s *AttestService) SetTraceScope(scope *tracing.Scope) {
+	s.traceScope = scope
+}
+
+// Start span for the current state, and start a cycle span if a new
+// attestation cycle is starting or the service restarted mid cycle
+func (s *AttestService) startStateSpan() trace.Span {
+	if s.cycleSpan == nil && s.state != AStateInit && s.state != AStateError {
+		s.cycleCtx, s.cycleSpan = tracing.Start(context.Background(), SpanCycle)
+	}
+	parent := context.Background()
+	if s.cycleSpan != nil {
+		parent = s.cycleCtx
+	}
+	ctx, span := tracing.Start(parent, SpanStatePrefix+s.state.String(),
+		attribute.String(tracing.AttrState, s.state.String()))
+	s.traceScope.Set(ctx)
+	return span
+}
+
+// End span of the previous state with the new state, and end the cycle span
+// if the attestation confirmed, no new commitment was found or states failed
+func (s *AttestService) endStateSpan(span trace.Span) {
+	var err error
+	if s.state == AStateError {
+		err = s.errorState
+	}
+	span.SetAttributes(attribute.String(tracing.AttrNextState, s.state.String()))
+	tracing.End(span, err)
+	s.traceScope.Set(context.Background())
+
+	if s.cycleSpan == nil {
+		return
+	}
+	switch s.state {
+	case AStateNextCommitment, AStateInit, AStateError:
+		s.endCycleSpan(err)
+	default:
+		s.cycleSpan.SetAttributes(attribute.String(tracing.AttrCommitment, s.attestation.CommitmentHash().String()))
+		if !s.attestation.Txid.IsEqual(&chainhash.Hash{}) {
+			s.cycleSpan.SetAttributes(attribute.String(tracing.AttrTxid, s.attestation.Txid.String()))
+		}
+	}
+}
+
+// End span of the current attestation cycle
+func (s *AttestService) endCycleSpan(err error) {
+	if s.cycleSpan == nil {
+		return
+	}
+	tracing.End(s.cycleSpan, err)
+	s.cycleCtx, s.cycleSpan = nil, nil
+}
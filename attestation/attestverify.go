@@ -0,0 +1,139 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"mainstay/crypto"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// Signatures collected from signers are verified against the redeem script
+// and the attestation input they sign before combining them, so that invalid
+// signatures are discarded and re-requested rather than failing the signing
+// round. The signed attestation is then run through the script engine against
+// the outputs it spends before it is stored and broadcast, surfacing script
+// failures with the failing input instead of an opaque node rejection
+
+// verify error consts
+const (
+	ErrorAttestationScriptInvalid = "Attestation script verification failed"
+	ErrorAttestationPrevOut       = "Attestation previous output not found"
+
+	WarningSigsInvalid = "Invalid signatures discarded"
+)
+
+// Return whether the signature is a valid signature of the transaction input
+// by any of the pubkeys of the redeem script
+func isValidSig(sig crypto.Sig, pubkeys []*btcec.PublicKey, sigHash []byte) bool {
+	if len(sig) < 2 {
+		return false
+	}
+	signature, parseErr := btcec.ParseDERSignature(sig[:len(sig)-1], btcec.S256())
+	if parseErr != nil {
+		return false
+	}
+	for _, pubkey := range pubkeys {
+		if signature.Verify(sigHash, pubkey) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return pubkeys of a multisig redeem script
+func (w *AttestClient) scriptPubkeys(redeemScript []byte) ([]*btcec.PublicKey, error) {
+	_, addrs, _, extractErr := txscript.ExtractPkScriptAddrs(redeemScript, w.MainChainCfg)
+	if extractErr != nil {
+		return nil, extractErr
+	}
+	var pubkeys []*btcec.PublicKey
+	for _, addr := range addrs {
+		if pubkeyAddr, ok := addr.(*btcutil.AddressPubKey); ok {
+			pubkeys = append(pubkeys, pubkeyAddr.PubKey())
+		}
+	}
+	return pubkeys, nil
+}
+
+// Return the signatures received for each input of the attestation transaction
+// that are valid for the redeem script of the input and the number discarded
+// Vin 0 is signed with the script tweaked by hash and any other vin with the
+// topup script, as when signing the attestation
+func (w *AttestClient) rejectInvalidSigs(msgtx *wire.MsgTx, sigs [][]crypto.Sig, hash chainhash.Hash) (
+	[][]crypto.Sig, int, error) {
+
+	redeemScript, redeemScriptErr := w.GetScriptFromHash(hash)
+	if redeemScriptErr != nil || redeemScript == "" {
+		return sigs, 0, redeemScriptErr
+	}
+
+	validSigs := make([][]crypto.Sig, len(sigs))
+	invalid := 0
+	for i := range sigs {
+		if i >= len(msgtx.TxIn) {
+			invalid += len(sigs[i])
+			continue
+		}
+		scriptStr := w.scriptTopup
+		if i == 0 {
+			scriptStr = redeemScript
+		} else if scriptStr == "" { // left to fail signing
+			validSigs[i] = sigs[i]
+			continue
+		}
+		script, _ := hex.DecodeString(scriptStr)
+		pubkeys, pubkeysErr := w.scriptPubkeys(script)
+		if pubkeysErr != nil {
+			return nil, 0, pubkeysErr
+		}
+		sigHash, sigHashErr := txscript.CalcSignatureHash(script, txscript.SigHashAll, msgtx, i)
+		if sigHashErr != nil {
+			return nil, 0, sigHashErr
+		}
+		validSigs[i] = []crypto.Sig{}
+		for _, sig := range sigs[i] {
+			if isValidSig(sig, pubkeys, sigHash) {
+				validSigs[i] = append(validSigs[i], sig)
+			} else {
+				invalid++
+			}
+		}
+	}
+	return validSigs, invalid, nil
+}
+
+// Verify the signed attestation transaction with the script engine against
+// the outputs spent by each of its inputs
+func (w *AttestClient) verifyAttestation(msgtx *wire.MsgTx) error {
+	for i, txin := range msgtx.TxIn {
+		prevTx, prevTxErr := w.Chain.GetRawTransaction(&txin.PreviousOutPoint.Hash)
+		if prevTxErr != nil {
+			return prevTxErr
+		}
+		prevOuts := prevTx.MsgTx().TxOut
+		if int(txin.PreviousOutPoint.Index) >= len(prevOuts) {
+			return errors.New(fmt.Sprintf("%s: %s", ErrorAttestationPrevOut, txin.PreviousOutPoint.String()))
+		}
+		prevOut := prevOuts[txin.PreviousOutPoint.Index]
+		engine, engineErr := txscript.NewEngine(prevOut.PkScript, msgtx, i,
+			txscript.StandardVerifyFlags, nil, nil, prevOut.Value)
+		if engineErr == nil {
+			engineErr = engine.Execute()
+		}
+		if engineErr != nil {
+			return errors.New(fmt.Sprintf("%s for vin %d: %v", ErrorAttestationScriptInvalid, i, engineErr))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"strings"
+	"testing"
+
+	"mainstay/crypto"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test invalid signatures are discarded before signing
+func TestRejectInvalidSigs(t *testing.T) {
+	c := newSignerSetTestClient()
+	tx := newSignerSetTestTx()
+	otherSig := c.sign(t, newSignerSetTestTx(), 0)[0]
+	otherSig[len(otherSig)-2] ^= 1
+	validSigs := c.sign(t, tx, 1, 2)
+
+	sigs := [][]crypto.Sig{append([]crypto.Sig{otherSig, {}}, validSigs...), {{1, 2}}}
+	rejected, numInvalid, rejectErr := c.client.rejectInvalidSigs(tx, sigs, chainhash.Hash{})
+	assert.Equal(t, nil, rejectErr)
+	assert.Equal(t, 3, numInvalid)
+	assert.Equal(t, [][]crypto.Sig{validSigs, nil}, rejected)
+
+	// valid sigs remain sufficient for signing
+	signedTx, signErr := c.client.signAttestation(tx.Copy(), rejected[:1], chainhash.Hash{})
+	assert.Equal(t, nil, signErr)
+	c.verify(t, signedTx)
+}
+
+// Test signed attestations are verified against the outputs spent
+func TestVerifyAttestation(t *testing.T) {
+	c := newSignerSetTestClient()
+	p2sh, _ := btcutil.NewAddressScriptHash(c.script, c.client.MainChainCfg)
+	prevTx := newInitTestTx(chainhash.Hash{1}, p2sh)
+	chain := &initChainFake{txs: map[chainhash.Hash]*wire.MsgTx{prevTx.TxHash(): prevTx}}
+	c.client.Chain = chain
+
+	tx := newInitTestTx(prevTx.TxHash(), p2sh)
+	signedTx, signErr := c.client.signAttestation(tx.Copy(), [][]crypto.Sig{c.sign(t, tx, 0, 1)}, chainhash.Hash{})
+	assert.Equal(t, nil, signErr)
+	assert.Equal(t, nil, c.client.verifyAttestation(signedTx))
+
+	// signature script not matching the tx
+	invalidTx := signedTx.Copy()
+	invalidTx.TxOut[0].Value--
+	verifyErr := c.client.verifyAttestation(invalidTx)
+	assert.Equal(t, true, strings.HasPrefix(verifyErr.Error(), ErrorAttestationScriptInvalid+" for vin 0"))
+
+	// output spent not paying to the redeem script
+	otherTx := newInitTestTx(chainhash.Hash{2}, p2sh)
+	otherTx.TxOut[0].PkScript = []byte{txscript.OP_TRUE, txscript.OP_VERIFY}
+	chain.txs[otherTx.TxHash()] = otherTx
+	invalidTx = signedTx.Copy()
+	invalidTx.TxIn[0].PreviousOutPoint.Hash = otherTx.TxHash()
+	verifyErr = c.client.verifyAttestation(invalidTx)
+	assert.Equal(t, true, strings.HasPrefix(verifyErr.Error(), ErrorAttestationScriptInvalid+" for vin 0"))
+
+	invalidTx.TxIn[0].PreviousOutPoint.Index = 1
+	verifyErr = c.client.verifyAttestation(invalidTx)
+	assert.Equal(t, ErrorAttestationPrevOut+": "+invalidTx.TxIn[0].PreviousOutPoint.String(), verifyErr.Error())
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"errors"
+	"fmt"
+
+	"mainstay/log"
+
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+// If the bitcoind wallet is encrypted, operations using the wallet keys are
+// run with the wallet unlocked just-in-time using the configured passphrase
+// and the wallet is re-locked as soon as the operation completes. The
+// unlock duration only bounds how long the wallet stays unlocked should
+// re-locking fail. Operations not using the wallet keys, such as importing
+// watch-only addresses or signing with the provided keys, are not wrapped
+
+// wallet consts
+const (
+	DefaultWalletUnlock = 60 // seconds
+
+	ErrorWalletLocked = "Wallet locked - configure wallet passphrase"
+	ErrorWalletUnlock = "Could not unlock wallet"
+
+	WarningWalletLock             = "Could not re-lock wallet"
+	WarningInvalidWalletUnlockArg = "Invalid wallet unlock config value"
+)
+
+// bitcoin wallet rpc error codes not defined in btcjson
+const (
+	rpcErrorWalletUnlockNeeded    btcjson.RPCErrorCode = -13 // passphrase required
+	rpcErrorWalletPassphrase      btcjson.RPCErrorCode = -14 // incorrect passphrase
+	rpcErrorWalletWrongEncryption btcjson.RPCErrorCode = -15 // wallet not encrypted
+)
+
+// Return wallet unlock seconds from config value, using default if unset
+func walletUnlockSeconds(unlockSeconds int) int64 {
+	if unlockSeconds > 0 {
+		return int64(unlockSeconds)
+	} else if unlockSeconds == 0 {
+		log.Warnf("%s (%d)\n", WarningInvalidWalletUnlockArg, unlockSeconds)
+	}
+	return DefaultWalletUnlock
+}
+
+// Return rpc error code of err or 0 if err is not an rpc error
+func rpcErrorCode(err error) btcjson.RPCErrorCode {
+	var rpcErr *btcjson.RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Code
+	}
+	return 0
+}
+
+// Run wallet operation using the wallet keys with the wallet unlocked,
+// re-locking afterwards
+// The wallet is not unlocked if no passphrase is configured, if the
+// wallet is not encrypted or if the wallet is watch-only. Wallet lock failures are returned as fatal
+// config errors instead of opaque rpc errors
+func (w *AttestClient) withWalletUnlocked(fn func() error) error {
+	if w.walletPassphrase != "" && !w.watchOnly {
+		unlockErr := w.rpcTimeout.Do("walletpassphrase", false, func() error {
+			return w.MainClient.WalletPassphrase(w.walletPassphrase, w.walletUnlock)
+		})
+		if unlockErr == nil {
+			defer func() {
+				lockErr := w.rpcTimeout.Do("walletlock", false, w.MainClient.WalletLock)
+				if lockErr != nil {
+					log.WithFields(log.Fields{log.FieldError: lockErr}).Warnln(WarningWalletLock)
+				}
+			}()
+		} else if ClassifyError(unlockErr) == ErrorClassRpcTransient {
+			return unlockErr
+		} else if rpcErrorCode(unlockErr) != rpcErrorWalletWrongEncryption {
+			return NewAttestError(ErrorClassFatalConfig,
+				errors.New(fmt.Sprintf("%s: %v", ErrorWalletUnlock, unlockErr)))
+		}
+	}
+
+	fnErr := fn()
+	if rpcErrorCode(fnErr) == rpcErrorWalletUnlockNeeded {
+		return NewAttestError(ErrorClassFatalConfig,
+			errors.New(fmt.Sprintf("%s: %v", ErrorWalletLocked, fnErr)))
+	}
+	return fnErr
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/stretchr/testify/assert"
+)
+
+// fake bitcoind wallet rpc recording the wallet methods called
+type walletRpcFake struct {
+	encrypted  bool
+	passphrase string
+	unlocked   bool
+	calls      []string
+}
+
+func (f *walletRpcFake) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	var request struct {
+		Id     interface{}       `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	json.NewDecoder(req.Body).Decode(&request)
+	f.calls = append(f.calls, request.Method)
+
+	var rpcErr *btcjson.RPCError
+	switch request.Method {
+	case "walletpassphrase":
+		var passphrase string
+		json.Unmarshal(request.Params[0], &passphrase)
+		if !f.encrypted {
+			rpcErr = &btcjson.RPCError{Code: rpcErrorWalletWrongEncryption, Message: "wallet not encrypted"}
+		} else if passphrase != f.passphrase {
+			rpcErr = &btcjson.RPCError{Code: rpcErrorWalletPassphrase, Message: "incorrect passphrase"}
+		} else {
+			f.unlocked = true
+		}
+	case "walletlock":
+		f.unlocked = false
+	case "keypoolrefill":
+		if f.encrypted && !f.unlocked {
+			rpcErr = &btcjson.RPCError{Code: rpcErrorWalletUnlockNeeded, Message: "unlock needed"}
+		}
+	}
+	json.NewEncoder(rw).Encode(map[string]interface{}{"id": request.Id, "result": nil, "error": rpcErr})
+}
+
+// Return attest client connected to the fake wallet rpc
+func newWalletTestClient(t *testing.T, fake *walletRpcFake, passphrase string) (*AttestClient, func()) {
+	server := httptest.NewServer(fake)
+	client, clientErr := rpcclient.New(&rpcclient.ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		HTTPPostMode: true,
+		DisableTLS:   true,
+	}, nil)
+	assert.Equal(t, nil, clientErr)
+	return &AttestClient{MainClient: client, walletPassphrase: passphrase, walletUnlock: 30}, func() {
+		client.Shutdown()
+		server.Close()
+	}
+}
+
+// Test wallet is unlocked around wallet key operations and re-locked afterwards
+func TestWithWalletUnlocked(t *testing.T) {
+	refillKeys := func(client *AttestClient) func() error {
+		return func() error { return client.MainClient.KeyPoolRefill() }
+	}
+
+	// encrypted wallet with correct passphrase
+	fake := &walletRpcFake{encrypted: true, passphrase: "secret"}
+	client, closeClient := newWalletTestClient(t, fake, "secret")
+	assert.Equal(t, nil, client.withWalletUnlocked(refillKeys(client)))
+	assert.Equal(t, []string{"walletpassphrase", "keypoolrefill", "walletlock"}, fake.calls)
+	assert.Equal(t, false, fake.unlocked)
+	closeClient()
+
+	// unencrypted wallet runs operation without locking
+	fake = &walletRpcFake{}
+	client, closeClient = newWalletTestClient(t, fake, "secret")
+	assert.Equal(t, nil, client.withWalletUnlocked(refillKeys(client)))
+	assert.Equal(t, []string{"walletpassphrase", "keypoolrefill"}, fake.calls)
+	closeClient()
+
+	// incorrect passphrase is a fatal config error
+	fake = &walletRpcFake{encrypted: true, passphrase: "secret"}
+	client, closeClient = newWalletTestClient(t, fake, "wrong")
+	unlockErr := client.withWalletUnlocked(refillKeys(client))
+	assert.Equal(t, ErrorClassFatalConfig, ClassifyError(unlockErr))
+	assert.True(t, strings.HasPrefix(unlockErr.Error(), ErrorWalletUnlock))
+	assert.Equal(t, []string{"walletpassphrase"}, fake.calls)
+	closeClient()
+
+	// missing passphrase for encrypted wallet is a fatal config error
+	fake = &walletRpcFake{encrypted: true, passphrase: "secret"}
+	client, closeClient = newWalletTestClient(t, fake, "")
+	lockedErr := client.withWalletUnlocked(refillKeys(client))
+	assert.Equal(t, ErrorClassFatalConfig, ClassifyError(lockedErr))
+	assert.True(t, strings.HasPrefix(lockedErr.Error(), ErrorWalletLocked))
+	assert.Equal(t, []string{"keypoolrefill"}, fake.calls)
+	closeClient()
+
+	// watch-only wallet is never unlocked
+	fake = &walletRpcFake{}
+	client, closeClient = newWalletTestClient(t, fake, "secret")
+	client.watchOnly = true
+	assert.Equal(t, nil, client.withWalletUnlocked(refillKeys(client)))
+	assert.Equal(t, []string{"keypoolrefill"}, fake.calls)
+	closeClient()
+
+	// other operation errors returned unchanged
+	opErr := errors.New("operation failed")
+	assert.Equal(t, opErr, client.withWalletUnlocked(func() error { return opErr }))
+}
+
+// Test wallet unlock seconds default
+func TestWalletUnlockSeconds(t *testing.T) {
+	assert.Equal(t, int64(30), walletUnlockSeconds(30))
+	assert.Equal(t, int64(DefaultWalletUnlock), walletUnlockSeconds(-1))
+	assert.Equal(t, int64(DefaultWalletUnlock), walletUnlockSeconds(0))
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// In watch-only mode the main bitcoind wallet holds no private keys at all,
+// e.g. a wallet created with disable_private_keys. The attestation and topup
+// addresses are imported watch-only, wallet transactions are looked up
+// including watch-only transactions and every input of the attestation,
+// including any topup input, is signed by the external signers. The wallet
+// is never unlocked as there are no keys to protect
+
+// watch-only consts
+const (
+	ErrorWatchOnlyMultisig     = "Watch-only mode requires a multisig init script"
+	ErrorWatchOnlyRawRequest   = "Raw requests not supported by watch-only chain"
+	WarningWatchOnlyPassphrase = "Wallet passphrase ignored in watch-only mode"
+)
+
+// ChainWatchOnly struct
+// Chain backend looking up wallet transactions including watch-only ones
+type ChainWatchOnly struct {
+	ChainBackend
+}
+
+// Return new ChainWatchOnly instance wrapping the chain backend
+func NewChainWatchOnly(chain ChainBackend) *ChainWatchOnly {
+	return &ChainWatchOnly{chain}
+}
+
+// Get wallet transaction with include_watchonly set, as bitcoind omits
+// watch-only transactions by default from wallets with private keys enabled
+// Backends without raw requests, e.g. Esplora, have no wallet to filter
+func (c *ChainWatchOnly) GetTransaction(txid *chainhash.Hash) (*btcjson.GetTransactionResult, error) {
+	client, ok := c.ChainBackend.(rawRequester)
+	if !ok {
+		return c.ChainBackend.GetTransaction(txid)
+	}
+	txidJson, _ := json.Marshal(txid.String())
+	watchOnlyJson, _ := json.Marshal(true)
+	resultJson, resultErr := client.RawRequest("gettransaction", []json.RawMessage{txidJson, watchOnlyJson})
+	if resultErr != nil {
+		return nil, resultErr
+	}
+	var result btcjson.GetTransactionResult
+	if unmarshalErr := json.Unmarshal(resultJson, &result); unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+	return &result, nil
+}
+
+// Send raw json-rpc request, e.g. for the node sync status
+func (c *ChainWatchOnly) RawRequest(method string, params []json.RawMessage) (json.RawMessage, error) {
+	client, ok := c.ChainBackend.(rawRequester)
+	if !ok {
+		return nil, errors.New(ErrorWatchOnlyRawRequest)
+	}
+	return client.RawRequest(method, params)
+}
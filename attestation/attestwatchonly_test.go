@@ -0,0 +1,51 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// main chain node recording raw requests
+type watchOnlyNodeFake struct {
+	ChainBackend
+	method string
+	params []json.RawMessage
+}
+
+func (n *watchOnlyNodeFake) RawRequest(method string, params []json.RawMessage) (json.RawMessage, error) {
+	n.method, n.params = method, params
+	return json.RawMessage(`{"txid":"abcd","confirmations":2,"blockhash":"blockhash","time":1}`), nil
+}
+
+// Test wallet transactions are looked up including watch-only transactions
+func TestChainWatchOnly(t *testing.T) {
+	node := &watchOnlyNodeFake{}
+	chain := NewChainWatchOnly(node)
+	txid := chainhash.Hash{1}
+
+	tx, txErr := chain.GetTransaction(&txid)
+	assert.Equal(t, nil, txErr)
+	assert.Equal(t, "abcd", tx.TxID)
+	assert.Equal(t, int64(2), tx.Confirmations)
+	assert.Equal(t, "gettransaction", node.method)
+	assert.Equal(t, []json.RawMessage{json.RawMessage(`"` + txid.String() + `"`), json.RawMessage(`true`)}, node.params)
+
+	_, rawErr := chain.RawRequest("getblockchaininfo", nil)
+	assert.Equal(t, nil, rawErr)
+	assert.Equal(t, "getblockchaininfo", node.method)
+
+	// backends without raw requests look up transactions directly
+	chain = NewChainWatchOnly(&initChainFake{})
+	tx, txErr = chain.GetTransaction(&txid)
+	assert.Equal(t, nil, txErr)
+	assert.Equal(t, txid.String(), tx.TxID)
+	_, rawErr = chain.RawRequest("getblockchaininfo", nil)
+	assert.Equal(t, ErrorWatchOnlyRawRequest, rawErr.Error())
+}
@@ -0,0 +1,360 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/log"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Block and transaction notifications are received from the zmq publishers
+// of the main client node, so that attestation confirmations are checked as
+// soon as a block is connected instead of on the next confirmation poll.
+// Polling continues as a fallback for missed notifications. A minimal ZMTP
+// 3.0 subscriber with the NULL mechanism is implemented, as used by bitcoind
+
+// zmq topics published by bitcoind
+const (
+	ZmqTopicRawBlock = "rawblock"
+	ZmqTopicHashTx   = "hashtx"
+)
+
+// zmq consts
+const (
+	ZmqTimeout        = 5 * time.Second // timeout of connecting and the handshake
+	ZmqReconnectDelay = 5 * time.Second // delay before reconnecting a failed subscription
+	ZmqQueueSize      = 16              // notifications queued before dropping new notifications
+	ZmqMaxFrameSize   = 1 << 25         // max size of a frame, above the max block size
+
+	ErrorZmqUrlInvalid     = "Invalid zmq url"
+	ErrorZmqGreeting       = "Invalid zmq greeting"
+	ErrorZmqHandshake      = "Invalid zmq handshake"
+	ErrorZmqFrameSize      = "zmq frame exceeds max size"
+	ErrorZmqNotification   = "Invalid zmq notification"
+	WarningZmqSubscription = "Zmq subscription failed - reconnecting"
+)
+
+// ZMTP frame flags
+const (
+	zmtpFlagMore    = 0x01
+	zmtpFlagLong    = 0x02
+	zmtpFlagCommand = 0x04
+)
+
+// ChainNotification struct
+// Block connected or transaction seen by the main client node
+type ChainNotification struct {
+	Topic string
+	Hash  chainhash.Hash
+}
+
+// Check that the zmq url is a valid tcp://host:port url
+func ValidateZmqUrl(zmqUrl string) error {
+	_, addrErr := parseZmqUrl(zmqUrl)
+	return addrErr
+}
+
+// Parse zmq url and return the host:port address
+func parseZmqUrl(zmqUrl string) (string, error) {
+	addr := strings.TrimPrefix(zmqUrl, "tcp://")
+	if addr == zmqUrl {
+		return "", errors.New(fmt.Sprintf("%s: %s", ErrorZmqUrlInvalid, zmqUrl))
+	}
+	if _, _, splitErr := confpkg.SplitHostPort(addr); splitErr != nil {
+		return "", errors.New(fmt.Sprintf("%s: %s", ErrorZmqUrlInvalid, zmqUrl))
+	}
+	return addr, nil
+}
+
+// zmq connection speaking ZMTP 3.0 as a SUB socket
+type zmqConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// Dial zmq publisher, perform the handshake and subscribe to the topics
+func dialZmq(addr string, topics []string, timeout time.Duration) (*zmqConn, error) {
+	conn, dialErr := net.DialTimeout("tcp", addr, timeout)
+	if dialErr != nil {
+		return nil, dialErr
+	}
+	c := &zmqConn{conn: conn, reader: bufio.NewReader(conn)}
+	conn.SetDeadline(time.Now().Add(timeout))
+	if handshakeErr := c.handshake(); handshakeErr != nil {
+		conn.Close()
+		return nil, handshakeErr
+	}
+	for _, topic := range topics {
+		if subscribeErr := c.writeFrame(0, append([]byte{1}, topic...)); subscribeErr != nil {
+			conn.Close()
+			return nil, subscribeErr
+		}
+	}
+	conn.SetDeadline(time.Time{})
+	return c, nil
+}
+
+// Close connection
+func (c *zmqConn) Close() error {
+	return c.conn.Close()
+}
+
+// Exchange greetings and READY commands with the publisher
+func (c *zmqConn) handshake() error {
+	greeting := make([]byte, 64)
+	greeting[0] = 0xff
+	greeting[9] = 0x7f
+	greeting[10] = 3 // version 3.0
+	copy(greeting[12:32], "NULL")
+	if _, writeErr := c.conn.Write(greeting); writeErr != nil {
+		return writeErr
+	}
+	peerGreeting := make([]byte, 64)
+	if _, readErr := io.ReadFull(c.reader, peerGreeting); readErr != nil {
+		return readErr
+	}
+	if peerGreeting[0] != 0xff || peerGreeting[9]&0x01 == 0 || peerGreeting[10] < 3 ||
+		string(bytes.TrimRight(peerGreeting[12:32], "\x00")) != "NULL" {
+		return errors.New(ErrorZmqGreeting)
+	}
+
+	if writeErr := c.writeFrame(zmtpFlagCommand, zmtpReady("SUB")); writeErr != nil {
+		return writeErr
+	}
+	flags, body, readErr := c.readFrame()
+	if readErr != nil {
+		return readErr
+	}
+	if flags&zmtpFlagCommand == 0 || len(body) < 6 || string(body[1:6]) != "READY" {
+		return errors.New(ErrorZmqHandshake)
+	}
+	return nil
+}
+
+// Return READY command body with the socket type property
+func zmtpReady(socketType string) []byte {
+	var body bytes.Buffer
+	body.WriteByte(5)
+	body.WriteString("READY")
+	body.WriteByte(11)
+	body.WriteString("Socket-Type")
+	binary.Write(&body, binary.BigEndian, uint32(len(socketType)))
+	body.WriteString(socketType)
+	return body.Bytes()
+}
+
+// Write frame with flags
+func (c *zmqConn) writeFrame(flags byte, body []byte) error {
+	var frame bytes.Buffer
+	if len(body) > 255 {
+		frame.WriteByte(flags | zmtpFlagLong)
+		binary.Write(&frame, binary.BigEndian, uint64(len(body)))
+	} else {
+		frame.WriteByte(flags)
+		frame.WriteByte(byte(len(body)))
+	}
+	frame.Write(body)
+	_, writeErr := c.conn.Write(frame.Bytes())
+	return writeErr
+}
+
+// Read frame and return its flags and body
+func (c *zmqConn) readFrame() (byte, []byte, error) {
+	flags, flagsErr := c.reader.ReadByte()
+	if flagsErr != nil {
+		return 0, nil, flagsErr
+	}
+	var size uint64
+	if flags&zmtpFlagLong != 0 {
+		if sizeErr := binary.Read(c.reader, binary.BigEndian, &size); sizeErr != nil {
+			return 0, nil, sizeErr
+		}
+	} else {
+		sizeByte, sizeErr := c.reader.ReadByte()
+		if sizeErr != nil {
+			return 0, nil, sizeErr
+		}
+		size = uint64(sizeByte)
+	}
+	if size > ZmqMaxFrameSize {
+		return 0, nil, errors.New(fmt.Sprintf("%s (%d)", ErrorZmqFrameSize, size))
+	}
+	body := make([]byte, size)
+	if _, readErr := io.ReadFull(c.reader, body); readErr != nil {
+		return 0, nil, readErr
+	}
+	return flags, body, nil
+}
+
+// Receive the frames of the next message, skipping any commands
+func (c *zmqConn) receive() ([][]byte, error) {
+	var parts [][]byte
+	for {
+		flags, body, readErr := c.readFrame()
+		if readErr != nil {
+			return nil, readErr
+		}
+		if flags&zmtpFlagCommand != 0 {
+			continue
+		}
+		parts = append(parts, body)
+		if flags&zmtpFlagMore == 0 {
+			return parts, nil
+		}
+	}
+}
+
+// Return chain notification of a bitcoind zmq message
+// of topic, body and sequence number frames
+func parseChainNotification(parts [][]byte) (ChainNotification, error) {
+	if len(parts) < 2 {
+		return ChainNotification{}, errors.New(ErrorZmqNotification)
+	}
+	topic := string(parts[0])
+	switch topic {
+	case ZmqTopicRawBlock:
+		var header wire.BlockHeader
+		if headerErr := header.Deserialize(bytes.NewReader(parts[1])); headerErr != nil {
+			return ChainNotification{}, errors.New(fmt.Sprintf("%s: %v", ErrorZmqNotification, headerErr))
+		}
+		return ChainNotification{Topic: topic, Hash: header.BlockHash()}, nil
+	case ZmqTopicHashTx:
+		if len(parts[1]) != chainhash.HashSize {
+			return ChainNotification{}, errors.New(ErrorZmqNotification)
+		}
+		var hash chainhash.Hash
+		for i, b := range parts[1] { // published in display byte order
+			hash[chainhash.HashSize-1-i] = b
+		}
+		return ChainNotification{Topic: topic, Hash: hash}, nil
+	}
+	return ChainNotification{}, errors.New(fmt.Sprintf("%s: %s", ErrorZmqNotification, topic))
+}
+
+// AttestZmq struct
+// Subscriber to the zmq block and transaction notifications of the main
+// client node, each publisher address subscribed on its own connection
+type AttestZmq struct {
+	ctx            context.Context
+	topics         map[string][]string
+	notifications  chan ChainNotification
+	reconnectDelay time.Duration
+}
+
+// Return new AttestZmq from zmq config or nil if no notifications are configured
+// Subscriptions run until the context is cancelled
+func NewAttestZmq(ctx context.Context, zmqConfig confpkg.ZmqConfig) *AttestZmq {
+	topics := make(map[string][]string)
+	for topic, zmqUrl := range map[string]string{ZmqTopicRawBlock: zmqConfig.RawBlock, ZmqTopicHashTx: zmqConfig.HashTx} {
+		if zmqUrl == "" {
+			continue
+		}
+		addr, addrErr := parseZmqUrl(zmqUrl)
+		if addrErr != nil {
+			log.Warnln(addrErr)
+			continue
+		}
+		topics[addr] = append(topics[addr], topic)
+	}
+	if len(topics) == 0 {
+		return nil
+	}
+
+	z := &AttestZmq{
+		ctx:            ctx,
+		topics:         topics,
+		notifications:  make(chan ChainNotification, ZmqQueueSize),
+		reconnectDelay: ZmqReconnectDelay,
+	}
+	for addr := range topics {
+		log.Infof("*Zmq* Subscribing to %s notifications from %s\n", strings.Join(topics[addr], ","), addr)
+		go z.subscribe(addr)
+	}
+	return z
+}
+
+// Return channel of chain notifications, nil if no notifications are configured
+func (z *AttestZmq) Notifications() <-chan ChainNotification {
+	if z == nil {
+		return nil
+	}
+	return z.notifications
+}
+
+// Deliver notifications received from the publisher,
+// reconnecting after a delay whenever the subscription fails
+func (z *AttestZmq) subscribe(addr string) {
+	for {
+		subscribeErr := z.receive(addr)
+		select {
+		case <-z.ctx.Done():
+			return
+		default:
+		}
+		log.WithFields(log.Fields{log.FieldError: subscribeErr}).Warnln(WarningZmqSubscription)
+		select {
+		case <-z.ctx.Done():
+			return
+		case <-time.After(z.reconnectDelay):
+		}
+	}
+}
+
+// Subscribe to the publisher and deliver notifications received
+// until the connection fails or the context is cancelled
+func (z *AttestZmq) receive(addr string) error {
+	conn, dialErr := dialZmq(addr, z.topics[addr], ZmqTimeout)
+	if dialErr != nil {
+		return dialErr
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() { // unblock receiving on cancel
+		select {
+		case <-z.ctx.Done():
+		case <-done:
+		}
+		conn.Close()
+	}()
+
+	for {
+		parts, receiveErr := conn.receive()
+		if receiveErr != nil {
+			return receiveErr
+		}
+		notification, parseErr := parseChainNotification(parts)
+		if parseErr != nil {
+			log.Debugf("%v\n", parseErr)
+			continue
+		}
+		select {
+		case z.notifications <- notification:
+		default: // queue full - pending notifications trigger the check
+		}
+	}
+}
+
+// Return true if the notification may confirm the awaited attestation,
+// i.e. a new block or the attestation transaction seen by the node
+func (s *AttestService) isConfirmationNotification(notification ChainNotification) bool {
+	if s.state != AStateAwaitConfirmation {
+		return false
+	}
+	return notification.Topic == ZmqTopicRawBlock || notification.Hash == s.attestation.Txid
+}
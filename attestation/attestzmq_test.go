@@ -0,0 +1,140 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+// zmq publisher serving a single subscriber speaking ZMTP 3.0
+// and publishing the messages provided once subscribed
+type zmqPublisherFake struct {
+	listener net.Listener
+	topics   chan string
+}
+
+// Return fake zmq publisher listening on a local port
+func newZmqPublisherFake(t *testing.T, messages [][][]byte) *zmqPublisherFake {
+	listener, listenErr := net.Listen("tcp", "127.0.0.1:0")
+	assert.Equal(t, nil, listenErr)
+	p := &zmqPublisherFake{listener: listener, topics: make(chan string, 2)}
+	go p.serve(messages)
+	return p
+}
+
+// Return zmq url of the publisher
+func (p *zmqPublisherFake) url() string {
+	return "tcp://" + p.listener.Addr().String()
+}
+
+// Serve the subscriber, publishing messages after the subscriptions
+// and keeping the connection open until the listener is closed
+func (p *zmqPublisherFake) serve(messages [][][]byte) {
+	conn, acceptErr := p.listener.Accept()
+	if acceptErr != nil {
+		return
+	}
+	defer conn.Close()
+	c := &zmqConn{conn: conn, reader: bufio.NewReader(conn)}
+
+	greeting := make([]byte, 64)
+	if _, readErr := io.ReadFull(c.reader, greeting); readErr != nil {
+		return
+	}
+	greeting[32] = 1 // as server
+	conn.Write(greeting)
+	if _, _, readErr := c.readFrame(); readErr != nil { // subscriber READY
+		return
+	}
+	c.writeFrame(zmtpFlagCommand, zmtpReady("PUB"))
+	for range []int{0, 1} {
+		_, body, readErr := c.readFrame()
+		if readErr != nil {
+			return
+		}
+		p.topics <- string(body[1:])
+	}
+	for _, message := range messages {
+		for i, part := range message {
+			var flags byte
+			if i < len(message)-1 {
+				flags = zmtpFlagMore
+			}
+			c.writeFrame(flags, part)
+		}
+	}
+	io.Copy(io.Discard, conn)
+}
+
+// Test zmq urls
+func TestZmqUrl(t *testing.T) {
+	addr, addrErr := parseZmqUrl("tcp://127.0.0.1:28332")
+	assert.Equal(t, nil, addrErr)
+	assert.Equal(t, "127.0.0.1:28332", addr)
+	for _, zmqUrl := range []string{"127.0.0.1:28332", "tcp://127.0.0.1", "ipc:///tmp/zmq"} {
+		assert.Equal(t, errors.New(ErrorZmqUrlInvalid+": "+zmqUrl), ValidateZmqUrl(zmqUrl))
+	}
+}
+
+// Test block and transaction notifications are received from the publisher
+func TestAttestZmq(t *testing.T) {
+	assert.Equal(t, true, NewAttestZmq(context.Background(), confpkg.ZmqConfig{}) == nil)
+	var noZmq *AttestZmq
+	assert.Equal(t, true, noZmq.Notifications() == nil)
+
+	var block bytes.Buffer
+	header := wire.BlockHeader{Version: 1, Timestamp: time.Unix(1500000000, 0), Nonce: 1}
+	assert.Equal(t, nil, header.Serialize(&block))
+	txid := chainhash.Hash{1, 2, 3}
+	txidDisplay := make([]byte, chainhash.HashSize)
+	for i := range txidDisplay {
+		txidDisplay[i] = txid[chainhash.HashSize-1-i]
+	}
+	sequence := []byte{0, 0, 0, 0}
+	publisher := newZmqPublisherFake(t, [][][]byte{
+		{[]byte(ZmqTopicRawBlock), block.Bytes(), sequence},
+		{[]byte("sequence"), []byte("invalid"), sequence},
+		{[]byte(ZmqTopicHashTx), txidDisplay, sequence},
+	})
+	defer publisher.listener.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	zmq := NewAttestZmq(ctx, confpkg.ZmqConfig{RawBlock: publisher.url(), HashTx: publisher.url()})
+	assert.Equal(t, 1, len(zmq.topics))
+	topics := []string{<-publisher.topics, <-publisher.topics}
+	assert.ElementsMatch(t, []string{ZmqTopicRawBlock, ZmqTopicHashTx}, topics)
+
+	assert.Equal(t, ChainNotification{ZmqTopicRawBlock, header.BlockHash()}, <-zmq.Notifications())
+	assert.Equal(t, ChainNotification{ZmqTopicHashTx, txid}, <-zmq.Notifications())
+}
+
+// Test notifications only trigger confirmation checks while
+// awaiting confirmation of the attestation
+func TestAttestServiceConfirmationNotification(t *testing.T) {
+	txid := chainhash.Hash{1}
+	s := &AttestService{state: AStateNextCommitment, attestation: models.NewAttestation(txid, nil)}
+	block := ChainNotification{ZmqTopicRawBlock, chainhash.Hash{9}}
+	assert.Equal(t, false, s.isConfirmationNotification(block))
+
+	s.state = AStateAwaitConfirmation
+	assert.Equal(t, true, s.isConfirmationNotification(block))
+	assert.Equal(t, true, s.isConfirmationNotification(ChainNotification{ZmqTopicHashTx, txid}))
+	assert.Equal(t, false, s.isConfirmationNotification(ChainNotification{ZmqTopicHashTx, chainhash.Hash{2}}))
+}
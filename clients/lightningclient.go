@@ -0,0 +1,72 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package clients
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// lightning client consts
+const (
+	LightningImplementationLnd = "lnd"
+	LightningImplementationCln = "cln"
+
+	LightningRequestTimeout = 30 * time.Second
+
+	ErrorLightningImplementation = "Unknown lightning implementation"
+	ErrorLightningTlsCert        = "Could not load lightning node tls certificate"
+	ErrorLightningResponse       = "Lightning node request failed"
+	ErrorLightningBackupEmpty    = "Lightning node returned empty channel backup"
+)
+
+// LightningClient interface
+// Implements the interface for lightning node clients
+// Current logic includes getting the static channel backup (SCB)
+// of the node, which is committed to the staychain each round
+type LightningClient interface {
+	GetChannelBackup() ([]byte, error)
+}
+
+// Return http client for the lightning node rest api
+// Nodes use self signed certificates so the node tls certificate
+// is trusted if provided instead of the system certificate pool
+func newLightningHttpClient(tlsCertPath string) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if tlsCertPath != "" {
+		certPem, readErr := os.ReadFile(tlsCertPath)
+		if readErr != nil {
+			return nil, errors.New(fmt.Sprintf("%s: %v", ErrorLightningTlsCert, readErr))
+		}
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(certPem) {
+			return nil, errors.New(fmt.Sprintf("%s: %s", ErrorLightningTlsCert, tlsCertPath))
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: certPool}
+	}
+	return &http.Client{Transport: transport, Timeout: LightningRequestTimeout}, nil
+}
+
+// Return error for lightning node response with non ok status
+func lightningResponseError(resp *http.Response) error {
+	return errors.New(fmt.Sprintf("%s: %s", ErrorLightningResponse, resp.Status))
+}
+
+// Return digest of the channel backup of a lightning node
+// The sha256 digest is committed instead of the backup itself
+func GetChannelBackupDigest(client LightningClient) ([]byte, error) {
+	backup, backupErr := client.GetChannelBackup()
+	if backupErr != nil {
+		return nil, backupErr
+	}
+	digest := sha256.Sum256(backup)
+	return digest[:], nil
+}
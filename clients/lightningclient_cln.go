@@ -0,0 +1,80 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package clients
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// cln rest api consts
+const (
+	ClnStaticBackupPath = "/v1/staticbackup"
+	ClnRuneHeader       = "Rune"
+)
+
+// LightningClientCln structure
+// Core Lightning implementation for the lightning client interface
+// using the clnrest plugin rest api
+type LightningClientCln struct {
+	url    string
+	rune   string
+	client *http.Client
+}
+
+// cln static backup response
+// Each entry is the hex encoded backup of a single channel
+type clnStaticBackup struct {
+	Scb []string `json:"scb"`
+}
+
+// NewLightningClientCln returns new instance of LightningClient for Core Lightning
+// The rune is required to authenticate to the rest api
+func NewLightningClientCln(url string, rune string, tlsCertPath string) (*LightningClientCln, error) {
+	client, clientErr := newLightningHttpClient(tlsCertPath)
+	if clientErr != nil {
+		return nil, clientErr
+	}
+	return &LightningClientCln{strings.TrimSuffix(url, "/"), rune, client}, nil
+}
+
+// GetChannelBackup Core Lightning implementation returning
+// the concatenated static backups of all channels
+func (c *LightningClientCln) GetChannelBackup() ([]byte, error) {
+	req, reqErr := http.NewRequest(http.MethodPost, c.url+ClnStaticBackupPath, nil)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	req.Header.Set(ClnRuneHeader, c.rune)
+
+	resp, respErr := c.client.Do(req)
+	if respErr != nil {
+		return nil, respErr
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, lightningResponseError(resp)
+	}
+
+	var backup clnStaticBackup
+	if decodeErr := json.NewDecoder(resp.Body).Decode(&backup); decodeErr != nil {
+		return nil, decodeErr
+	}
+	var scb []byte
+	for _, channelScb := range backup.Scb {
+		channelScbBytes, hexErr := hex.DecodeString(channelScb)
+		if hexErr != nil {
+			return nil, hexErr
+		}
+		scb = append(scb, channelScbBytes...)
+	}
+	if len(scb) == 0 {
+		return nil, errors.New(ErrorLightningBackupEmpty)
+	}
+	return scb, nil
+}
@@ -0,0 +1,34 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package clients
+
+import (
+	"errors"
+)
+
+// LightningClientFake structure
+// Implements fake implementation of LightningClient for unit-testing
+// Returns the fake backups in order, repeating the last one
+type LightningClientFake struct {
+	backups [][]byte
+	round   int
+}
+
+// NewLightningClientFake returns new instance of fake LightningClient
+func NewLightningClientFake(backups ...[]byte) *LightningClientFake {
+	return &LightningClientFake{backups, 0}
+}
+
+// GetChannelBackup fake implementation returning next fake backup
+func (f *LightningClientFake) GetChannelBackup() ([]byte, error) {
+	if len(f.backups) == 0 {
+		return nil, errors.New(ErrorLightningBackupEmpty)
+	}
+	backup := f.backups[f.round]
+	if f.round < len(f.backups)-1 {
+		f.round++
+	}
+	return backup, nil
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package clients
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// lnd rest api consts
+const (
+	LndChannelBackupPath = "/v1/channels/backup"
+	LndMacaroonHeader    = "Grpc-Metadata-macaroon"
+)
+
+// LightningClientLnd structure
+// LND implementation for the lightning client interface using the LND rest api
+type LightningClientLnd struct {
+	url      string
+	macaroon string
+	client   *http.Client
+}
+
+// lnd channel backup response
+// Only the multi channel backup, covering all channels, is used
+type lndChannelBackup struct {
+	MultiChanBackup struct {
+		MultiChanBackup []byte `json:"multi_chan_backup"`
+	} `json:"multi_chan_backup"`
+}
+
+// NewLightningClientLnd returns new instance of LightningClient for LND
+// The macaroon file is required to authenticate to the rest api
+func NewLightningClientLnd(url string, macaroonPath string, tlsCertPath string) (*LightningClientLnd, error) {
+	macaroon, readErr := os.ReadFile(macaroonPath)
+	if readErr != nil {
+		return nil, readErr
+	}
+	client, clientErr := newLightningHttpClient(tlsCertPath)
+	if clientErr != nil {
+		return nil, clientErr
+	}
+	return &LightningClientLnd{strings.TrimSuffix(url, "/"), hex.EncodeToString(macaroon), client}, nil
+}
+
+// GetChannelBackup LND implementation returning the multi channel backup
+func (l *LightningClientLnd) GetChannelBackup() ([]byte, error) {
+	req, reqErr := http.NewRequest(http.MethodGet, l.url+LndChannelBackupPath, nil)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	req.Header.Set(LndMacaroonHeader, l.macaroon)
+
+	resp, respErr := l.client.Do(req)
+	if respErr != nil {
+		return nil, respErr
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, lightningResponseError(resp)
+	}
+
+	var backup lndChannelBackup
+	if decodeErr := json.NewDecoder(resp.Body).Decode(&backup); decodeErr != nil {
+		return nil, decodeErr
+	}
+	if len(backup.MultiChanBackup.MultiChanBackup) == 0 {
+		return nil, errors.New(ErrorLightningBackupEmpty)
+	}
+	return backup.MultiChanBackup.MultiChanBackup, nil
+}
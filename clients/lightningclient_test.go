@@ -0,0 +1,107 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package clients
+
+import (
+	"crypto/sha256"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Return path of file with data in test temp dir
+func writeTestFile(t *testing.T, name string, data []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.Equal(t, nil, os.WriteFile(path, data, 0600))
+	return path
+}
+
+// Test LND client fetching multi channel backup over tls with macaroon
+func TestLightningClientLnd(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != LndChannelBackupPath ||
+			r.Header.Get(LndMacaroonHeader) != "0102" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"single_chan_backups":{},"multi_chan_backup":{"chan_points":[],"multi_chan_backup":"AQIDBA=="}}`))
+	}))
+	defer server.Close()
+
+	macaroonPath := writeTestFile(t, "readonly.macaroon", []byte{1, 2})
+	certPath := writeTestFile(t, "tls.cert",
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	client, clientErr := NewLightningClientLnd(server.URL+"/", macaroonPath, certPath)
+	assert.Equal(t, nil, clientErr)
+	backup, backupErr := client.GetChannelBackup()
+	assert.Equal(t, nil, backupErr)
+	assert.Equal(t, []byte{1, 2, 3, 4}, backup)
+
+	// node certificate not trusted without tls cert
+	client, clientErr = NewLightningClientLnd(server.URL, macaroonPath, "")
+	assert.Equal(t, nil, clientErr)
+	_, backupErr = client.GetChannelBackup()
+	assert.NotEqual(t, nil, backupErr)
+
+	// invalid macaroon
+	client, _ = NewLightningClientLnd(server.URL, writeTestFile(t, "admin.macaroon", []byte{3}), certPath)
+	_, backupErr = client.GetChannelBackup()
+	assert.Equal(t, ErrorLightningResponse+": 401 Unauthorized", backupErr.Error())
+
+	_, clientErr = NewLightningClientLnd(server.URL, macaroonPath, macaroonPath)
+	assert.Equal(t, ErrorLightningTlsCert+": "+macaroonPath, clientErr.Error())
+}
+
+// Test CLN client fetching static channel backups with rune
+func TestLightningClientCln(t *testing.T) {
+	response := `{"scb":["0102","0304"]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != ClnStaticBackupPath || r.Header.Get(ClnRuneHeader) != "rune" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	client, clientErr := NewLightningClientCln(server.URL, "rune", "")
+	assert.Equal(t, nil, clientErr)
+	backup, backupErr := client.GetChannelBackup()
+	assert.Equal(t, nil, backupErr)
+	assert.Equal(t, []byte{1, 2, 3, 4}, backup)
+
+	response = `{"scb":[]}`
+	_, backupErr = client.GetChannelBackup()
+	assert.Equal(t, errors.New(ErrorLightningBackupEmpty), backupErr)
+
+	client, _ = NewLightningClientCln(server.URL, "other", "")
+	_, backupErr = client.GetChannelBackup()
+	assert.Equal(t, ErrorLightningResponse+": 401 Unauthorized", backupErr.Error())
+}
+
+// Test channel backup digest
+func TestGetChannelBackupDigest(t *testing.T) {
+	client := NewLightningClientFake([]byte{1}, []byte{2})
+	digest1 := sha256.Sum256([]byte{1})
+	digest2 := sha256.Sum256([]byte{2})
+
+	digest, digestErr := GetChannelBackupDigest(client)
+	assert.Equal(t, nil, digestErr)
+	assert.Equal(t, digest1[:], digest)
+	digest, _ = GetChannelBackupDigest(client)
+	assert.Equal(t, digest2[:], digest)
+	digest, _ = GetChannelBackupDigest(client)
+	assert.Equal(t, digest2[:], digest)
+
+	_, digestErr = GetChannelBackupDigest(NewLightningClientFake())
+	assert.Equal(t, errors.New(ErrorLightningBackupEmpty), digestErr)
+}
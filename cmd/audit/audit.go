@@ -0,0 +1,108 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+// Staychain integrity audit tool
+
+import (
+	"context"
+	"flag"
+	"os"
+	"strings"
+
+	"mainstay/attestation"
+	"mainstay/config"
+	"mainstay/db"
+	"mainstay/log"
+)
+
+// Use the staychain indexer to follow the staychain from the base transaction
+// and cross check every confirmed attestation stored against it, optionally
+// repairing the inconsistencies found
+
+const ConfPath = "/src/mainstay/cmd/audit/conf.json"
+
+var (
+	tx         string
+	script     string
+	chaincodes string
+	repair     bool
+	mainConfig *config.Config
+)
+
+// init
+func init() {
+	flag.StringVar(&tx, "tx", "", "Base transaction id of the staychain")
+	flag.StringVar(&script, "script", "", "Base redeem script of the attestation service multisig")
+	flag.StringVar(&chaincodes, "chaincodes", "", "Chaincodes for multisig pubkeys")
+	flag.BoolVar(&repair, "repair", false, "Repair inconsistencies with what is found on-chain")
+	flag.Parse()
+
+	confFile, confErr := config.GetConfFile(os.Getenv("GOPATH") + ConfPath)
+	if confErr != nil {
+		log.Error(confErr)
+	}
+	var mainConfigErr error
+	mainConfig, mainConfigErr = config.NewConfig(confFile)
+	if mainConfigErr != nil {
+		log.Error(mainConfigErr)
+	}
+
+	// staychain from the flags overrides the conf
+	if tx != "" {
+		mainConfig.SetInitTx(tx)
+	}
+	if script != "" {
+		mainConfig.SetInitScript(script)
+	}
+	if chaincodes != "" {
+		mainConfig.SetInitChaincodes(strings.Split(chaincodes, ","))
+	}
+	if mainConfig.InitTx() == "" || mainConfig.InitScript() == "" {
+		flag.PrintDefaults()
+		log.Error("Need to provide both -tx and -script arguments.")
+	}
+}
+
+// main
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dbMongo, dbErr := db.NewDbMongo(ctx, mainConfig.DbConfig())
+	if dbErr != nil {
+		log.Error(dbErr)
+	}
+	if migrateErr := dbMongo.Migrate(); migrateErr != nil {
+		log.Error(migrateErr)
+	}
+
+	log.Infoln()
+	log.Infoln("*********************************************")
+	log.Infoln("**************** Audit Tool *****************")
+	log.Infoln("*********************************************")
+	log.Infoln()
+
+	attester := attestation.NewAttestClient(mainConfig)
+	defer mainConfig.MainClient().Shutdown()
+
+	result, auditErr := attestation.NewAttestAudit(ctx, attester, dbMongo).Audit(repair)
+	if auditErr != nil {
+		log.Error(auditErr)
+	}
+	log.Infof("audited %d attestations against %d staychain transactions\n", result.Attestations, result.Staychain)
+	if len(result.Inconsistencies) == 0 {
+		log.Infoln("no inconsistencies found")
+		return
+	}
+	log.Warnf("%d inconsistencies found:\n", len(result.Inconsistencies))
+	for _, inconsistency := range result.Inconsistencies {
+		log.Warnf("%s %s: %s (repairable: %t)\n", inconsistency.Txid, inconsistency.MerkleRoot,
+			inconsistency.Reason, inconsistency.Repairable)
+	}
+	if repair {
+		log.Infof("repaired %d inconsistencies\n", result.Repaired)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+// Historical attestation backfill tool
+
+import (
+	"context"
+	"flag"
+	"os"
+	"strings"
+
+	"mainstay/attestation"
+	"mainstay/config"
+	"mainstay/db"
+	"mainstay/log"
+)
+
+// Use the staychain indexer to follow the staychain from the base transaction
+// and store the attestations found, with merkle roots recovered by tweaking
+
+const ConfPath = "/src/mainstay/cmd/backfill/conf.json"
+
+var (
+	tx         string
+	script     string
+	chaincodes string
+	exportPath string
+	mainConfig *config.Config
+)
+
+// init
+func init() {
+	flag.StringVar(&tx, "tx", "", "Base transaction id of the staychain")
+	flag.StringVar(&script, "script", "", "Base redeem script of the attestation service multisig")
+	flag.StringVar(&chaincodes, "chaincodes", "", "Chaincodes for multisig pubkeys")
+	flag.StringVar(&exportPath, "export", "", "Export file of attestation txids and merkle roots (optional)")
+	flag.Parse()
+
+	confFile, confErr := config.GetConfFile(os.Getenv("GOPATH") + ConfPath)
+	if confErr != nil {
+		log.Error(confErr)
+	}
+	var mainConfigErr error
+	mainConfig, mainConfigErr = config.NewConfig(confFile)
+	if mainConfigErr != nil {
+		log.Error(mainConfigErr)
+	}
+
+	// staychain from the flags overrides the conf
+	if tx != "" {
+		mainConfig.SetInitTx(tx)
+	}
+	if script != "" {
+		mainConfig.SetInitScript(script)
+	}
+	if chaincodes != "" {
+		mainConfig.SetInitChaincodes(strings.Split(chaincodes, ","))
+	}
+	if mainConfig.InitTx() == "" || mainConfig.InitScript() == "" || strings.Join(mainConfig.InitChaincodes(), "") == "" {
+		flag.PrintDefaults()
+		log.Error("Need to provide all -tx, -script and -chaincodes arguments.")
+	}
+}
+
+// Read exported attestations from the export file, if any
+func readExports() []attestation.BackfillExport {
+	if exportPath == "" {
+		return nil
+	}
+	exportFile, openErr := os.Open(exportPath)
+	if openErr != nil {
+		log.Error(openErr)
+	}
+	defer exportFile.Close()
+	exports, exportsErr := attestation.ParseBackfillExport(exportFile)
+	if exportsErr != nil {
+		log.Error(exportsErr)
+	}
+	log.Infof("read %d exported attestations\n", len(exports))
+	return exports
+}
+
+// main
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dbMongo, dbErr := db.NewDbMongo(ctx, mainConfig.DbConfig())
+	if dbErr != nil {
+		log.Error(dbErr)
+	}
+	if migrateErr := dbMongo.Migrate(); migrateErr != nil {
+		log.Error(migrateErr)
+	}
+
+	log.Infoln()
+	log.Infoln("*********************************************")
+	log.Infoln("************** Backfill Tool ****************")
+	log.Infoln("*********************************************")
+	log.Infoln()
+
+	exports := readExports()
+	attester := attestation.NewAttestClient(mainConfig)
+	defer mainConfig.MainClient().Shutdown()
+
+	backfill := attestation.NewAttestBackfill(ctx, attester, dbMongo, exports)
+	result, backfillErr := backfill.Backfill()
+	if backfillErr != nil {
+		log.Error(backfillErr)
+	}
+	log.Infof("backfilled %d attestations\n", result.Attestations)
+	if len(result.Unrecovered) > 0 {
+		log.Warnf("%d staychain transactions without merkle root recovered:\n", len(result.Unrecovered))
+		for _, txid := range result.Unrecovered {
+			log.Warnln(txid)
+		}
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"strings"
 	"time"
 
+	"mainstay/clients"
 	"mainstay/config"
 	"mainstay/log"
 
@@ -31,7 +32,7 @@ const (
 	DefaultApiHost       = "https://mainstay.xyz"    // testnet mainstay url
 	ApiCommitmentSendUrl = "/api/v1/commitment/send" // url to send commitments to
 
-	// config for sidechain connectivity (optional)
+	// config for sidechain or lightning node connectivity (optional)
 	ClientChainName = "ocean"
 	ConfPath        = "/src/mainstay/cmd/commitmenttool/conf.json"
 )
@@ -41,6 +42,7 @@ var (
 	apiHost string // mainstay host
 	isInit  bool   // init flag
 	isOcean bool   // ocean flag
+	isLn    bool   // lightning flag
 	delay   int    // commitment delay
 
 	position  int    // client position
@@ -56,6 +58,7 @@ func init() {
 	// mode options
 	flag.BoolVar(&isInit, "init", false, "Init mode")
 	flag.BoolVar(&isOcean, "ocean", false, "Ocean mode")
+	flag.BoolVar(&isLn, "lightning", false, "Lightning mode")
 	flag.IntVar(&delay, "delay", 60, "Delay in minutes between commitments")
 
 	// commitment variables
@@ -119,22 +122,19 @@ func send(sig []byte, msg string) error {
 
 	log.Infof("Response status: %s", resp.Status)
 
-	// check status response
-	if resp.StatusCode == 200 {
-		dec := json.NewDecoder(resp.Body)
-		var respJson map[string]interface{}
-		decErr := dec.Decode(&respJson)
-		if decErr != nil {
-			return decErr
-		}
-		if val, ok := respJson["error"]; ok {
-			return errors.New(val.(string))
-		}
-
-		return nil
+	// check response error, returned with the status of the error
+	dec := json.NewDecoder(resp.Body)
+	var respJson map[string]interface{}
+	if decErr := dec.Decode(&respJson); decErr != nil {
+		return errors.New(fmt.Sprintf("Response status %s", resp.Status))
 	}
-
-	return errors.New(fmt.Sprintf("Response status %s", resp.Status))
+	if val, ok := respJson["error"]; ok {
+		return errors.New(fmt.Sprintf("%v (%v)", val, respJson["code"]))
+	}
+	if resp.StatusCode != 200 {
+		return errors.New(fmt.Sprintf("Response status %s", resp.Status))
+	}
+	return nil
 }
 
 // Decode private key and get btcec ECDSA key
@@ -155,53 +155,36 @@ func sign(msg []byte) []byte {
 	return sig.Serialize()
 }
 
-// Ocean mode
-// Recurrent commitments of Ocean blockhash to Mainstay API
+// Recurrent commitments to Mainstay API
 // At regular intervals, fetch commitment, sign and send
-func doOceanMode() {
-	log.Infoln("****************************")
-	log.Infoln("****** Ocean mode **********")
-	log.Infoln("****************************")
-
+func doRecurrentMode(fetch func() ([]byte, error)) {
 	// check priv key is set
 	if privkey == "" {
 		log.Infoln("no private key provided")
 	}
 
-	// get conf file
-	confFile, confErr := config.GetConfFile(os.Getenv("GOPATH") + ConfPath)
-	if confErr != nil {
-		log.Error(confErr)
-	}
-
-	// get ocean sidechain client from config
-	client := config.NewClientFromConfig(ClientChainName, false, confFile)
-
 	sleepTime := 0 * time.Second // start immediately
 	for {
 		timer := time.NewTimer(sleepTime)
 		select {
 		case <-timer.C:
-			log.Infoln("Fetching next blockhash commitment...")
+			log.Infoln("Fetching next commitment...")
 
-			// get next blockhash
-			blockhash, blockhashErr := client.GetBestBlockHash()
-			if blockhashErr != nil {
-				log.Errorf("Client fetching error: %v\n", blockhashErr)
+			// get next commitment
+			commitmentBytes, fetchErr := fetch()
+			if fetchErr != nil {
+				log.Errorf("Client fetching error: %v\n", fetchErr)
 			}
-			log.Infoln("Commitment: ", blockhash.String())
-
-			// get reverse blockhash bytes as this is how blockhashes are displayed
-			revBlockHashBytes, _ := hex.DecodeString(blockhash.String())
+			log.Infoln("Commitment: ", hex.EncodeToString(commitmentBytes))
 
 			// sign commitment
 			var sigBytes []byte
 			if privkey != "" {
-				sigBytes = sign(revBlockHashBytes)
+				sigBytes = sign(commitmentBytes)
 			}
 
 			// send signed commitment
-			sendErr := send(sigBytes, hex.EncodeToString(revBlockHashBytes))
+			sendErr := send(sigBytes, hex.EncodeToString(commitmentBytes))
 			if sendErr != nil {
 				log.Errorf("Commitment send error: %v\n", sendErr)
 			} else {
@@ -209,9 +192,63 @@ func doOceanMode() {
 			}
 
 			sleepTime = time.Duration(delay) * time.Minute
-			log.Infof("********** sleeping for: %s ...\n", sleepTime.String())
+			log.Infof("sleeping for: %s ...\n", sleepTime.String())
+		}
+	}
+}
+
+// Ocean mode
+// Recurrent commitments of Ocean blockhash to Mainstay API
+func doOceanMode() {
+	log.Infoln("****************************")
+	log.Infoln("****** Ocean mode **********")
+	log.Infoln("****************************")
+
+	// get conf file
+	confFile, confErr := config.GetConfFile(os.Getenv("GOPATH") + ConfPath)
+	if confErr != nil {
+		log.Error(confErr)
+	}
+
+	// get ocean sidechain client from config
+	client := config.NewClientFromConfig(ClientChainName, false, confFile)
+
+	doRecurrentMode(func() ([]byte, error) {
+		// get next blockhash
+		blockhash, blockhashErr := client.GetBestBlockHash()
+		if blockhashErr != nil {
+			return nil, blockhashErr
 		}
+
+		// get reverse blockhash bytes as this is how blockhashes are displayed
+		return hex.DecodeString(blockhash.String())
+	})
+}
+
+// Lightning mode
+// Recurrent commitments of the digest of the lightning node
+// static channel backup (SCB) to Mainstay API, providing
+// on-chain evidence of the latest backup of the node
+func doLightningMode() {
+	log.Infoln("****************************")
+	log.Infoln("****** Lightning mode ******")
+	log.Infoln("****************************")
+
+	// get conf file
+	confFile, confErr := config.GetConfFile(os.Getenv("GOPATH") + ConfPath)
+	if confErr != nil {
+		log.Error(confErr)
 	}
+
+	// get lightning node client from config
+	client, clientErr := config.NewLightningClientFromConfig(confFile)
+	if clientErr != nil {
+		log.Error(clientErr)
+	}
+
+	doRecurrentMode(func() ([]byte, error) {
+		return clients.GetChannelBackupDigest(client)
+	})
 }
 
 // Standard mode
@@ -298,6 +335,8 @@ func main() {
 		doInitMode()
 	} else if isOcean {
 		doOceanMode()
+	} else if isLn {
+		doLightningMode()
 	} else {
 		doStandardMode()
 	}
@@ -0,0 +1,181 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"mainstay/log"
+	"mainstay/models"
+)
+
+// Proof bundle verification tool
+// Verifies a proof bundle read from a file or fetched from the mainstay api
+// or a base64 encoded compact binary proof
+
+const ApiTimeout = 30 * time.Second
+
+var (
+	file       string
+	apiHost    string
+	position   int
+	commitment string
+	proofOps   string
+	binaryStr  string
+	root       string
+)
+
+// init - flag parse
+func init() {
+	flag.StringVar(&file, "file", "", "Proof bundle json file")
+	flag.StringVar(&apiHost, "apiHost", "", "Mainstay api host to fetch proof bundle from")
+	flag.IntVar(&position, "position", 0, "Client position of commitment")
+	flag.StringVar(&commitment, "commitment", "", "Commitment to fetch proof bundle for")
+	flag.StringVar(&proofOps, "ops", "", "Proof ops encoding of bundles not declaring it, append or position")
+	flag.StringVar(&binaryStr, "binary", "", "Base64 encoded compact binary proof")
+	flag.StringVar(&root, "root", "", "Merkle root committed to by the attestation, checked against binary proofs")
+	flag.Parse()
+
+	if file == "" && binaryStr == "" && (apiHost == "" || commitment == "") {
+		flag.PrintDefaults()
+		log.Errorf("Need to provide either -file, -binary or -apiHost and -commitment")
+	}
+}
+
+// main
+func main() {
+	if binaryStr != "" {
+		verifyBinary()
+		return
+	}
+
+	var bundleJson []byte
+	var readErr error
+	if file != "" {
+		bundleJson, readErr = os.ReadFile(file)
+	} else {
+		bundleJson, readErr = fetchBundle()
+	}
+	if readErr != nil {
+		log.Errorf("failed reading proof bundle %v", readErr)
+	}
+
+	bundle, parseErr := parseBundle(bundleJson)
+	if parseErr != nil {
+		log.Errorf("failed parsing proof bundle %v", parseErr)
+	}
+	if bundle.Params.Ops == "" && proofOps != "" {
+		bundle.Params.Ops = proofOps
+	}
+	if verifyErr := models.VerifyProofBundle(bundle); verifyErr != nil {
+		log.Errorf("proof bundle invalid: %v", verifyErr)
+	}
+
+	// leaf checked against the commitment ordering of the staychain
+	if apiHost != "" {
+		ordering, orderingErr := fetchOrdering()
+		if orderingErr != nil {
+			log.Errorf("failed fetching commitment ordering %v", orderingErr)
+		}
+		if leafErr := ordering.VerifyLeaf(bundle.Slot, bundle.SlotLeaf()); leafErr != nil {
+			log.Errorf("proof bundle invalid: %v", leafErr)
+		}
+	}
+
+	log.Infof("commitment %s in slot %d proven to root %s\n", bundle.Commitment, bundle.Slot, bundle.Root)
+	if bundle.Group != nil {
+		log.Infof("through slot group root %s at member position %d\n", bundle.Group.Root, bundle.Group.MemberPosition)
+	}
+	if bundle.Block == nil {
+		log.Warnf("attestation %s not confirmed yet\n", bundle.Txid)
+		return
+	}
+	log.Infof("attestation %s confirmed in block %s at %s\n", bundle.Txid, bundle.Block.Hash,
+		time.Unix(bundle.Block.Time, 0).UTC().Format(time.RFC3339))
+	if bundle.Block.Spv != nil {
+		log.Infof("attestation %s proven in block with header %s\n", bundle.Txid, bundle.Block.Spv.Header)
+		log.Infoln("check that the block header is in the bitcoin chain of headers")
+	}
+	log.Infoln("check that the attestation transaction pays to the staychain and commits to the root on a bitcoin node")
+}
+
+// Verify base64 encoded compact binary proof, computing the proof merkle
+// root and checking it against the root given, if any
+func verifyBinary() {
+	proof, proofErr := models.DecodeProofBase64(binaryStr)
+	if proofErr != nil {
+		log.Errorf("failed parsing binary proof %v", proofErr)
+	}
+	if root != "" && proof.MerkleRoot.String() != root {
+		log.Errorf("binary proof invalid: proven root %s does not match root %s", proof.MerkleRoot.String(), root)
+	}
+	log.Infof("commitment %s in slot %d proven to root %s\n", proof.Commitment.String(), proof.ClientPosition,
+		proof.MerkleRoot.String())
+	if root == "" {
+		log.Infoln("check that an attestation transaction of the staychain commits to the root on a bitcoin node")
+	}
+}
+
+// Fetch proof bundle of commitment from the mainstay api
+func fetchBundle() ([]byte, error) {
+	client := &http.Client{Timeout: ApiTimeout}
+	resp, respErr := client.Get(fmt.Sprintf("%s/api/commitment/proof/%d/%s/", apiHost, position, commitment))
+	if respErr != nil {
+		return nil, respErr
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// Fetch commitment ordering of the staychain from the mainstay api protocol
+func fetchOrdering() (models.CommitmentOrdering, error) {
+	client := &http.Client{Timeout: ApiTimeout}
+	resp, respErr := client.Get(fmt.Sprintf("%s/api/protocol/", apiHost))
+	if respErr != nil {
+		return models.CommitmentOrdering{}, respErr
+	}
+	defer resp.Body.Close()
+	var envelope struct {
+		Response models.ProtocolResponse `json:"response"`
+		Error    string                  `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return models.CommitmentOrdering{}, err
+	}
+	if envelope.Error != "" {
+		return models.CommitmentOrdering{}, errors.New(envelope.Error)
+	}
+	return envelope.Response.Ordering, nil
+}
+
+// Parse proof bundle from either a bare bundle or an api response
+func parseBundle(bundleJson []byte) (models.ProofBundle, error) {
+	var envelope struct {
+		Response *models.ProofBundle `json:"response"`
+		Error    string              `json:"error"`
+	}
+	if err := json.Unmarshal(bundleJson, &envelope); err != nil {
+		return models.ProofBundle{}, err
+	}
+	if envelope.Error != "" {
+		return models.ProofBundle{}, errors.New(envelope.Error)
+	}
+	if envelope.Response != nil {
+		return *envelope.Response, nil
+	}
+
+	var bundle models.ProofBundle
+	if err := json.Unmarshal(bundleJson, &bundle); err != nil {
+		return models.ProofBundle{}, err
+	}
+	return bundle, nil
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Conf files can be written in JSON, YAML or TOML, detected by the file
+// extension. YAML and TOML conf files are converted to the equivalent JSON
+// conf on reading, so that config options are parsed and validated, with the
+// same errors, regardless of the format. Categories are top level YAML maps
+// or TOML tables and option values are converted to strings, with lists of
+// values joined by commas
+
+// conf format consts
+const (
+	ConfFormatJson = "json"
+	ConfFormatYaml = "yaml"
+	ConfFormatToml = "toml"
+
+	ErrorConfigFormatUnknown = "unknown config file format"
+	ErrorConfigFormatInvalid = "invalid config file"
+	ErrorConfigCategory      = "config category must be a map of options"
+	ErrorConfigValue         = "config value must be a string, number, boolean or list"
+)
+
+// conf file extensions for each conf format
+var confFormatExtensions = map[string]string{
+	".json": ConfFormatJson,
+	".yaml": ConfFormatYaml,
+	".yml":  ConfFormatYaml,
+	".toml": ConfFormatToml,
+}
+
+// Return conf format of conf file from its extension
+func GetConfFormat(path string) (string, error) {
+	format, ok := confFormatExtensions[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return "", errors.New(fmt.Sprintf("%s: %s", ErrorConfigFormatUnknown, path))
+	}
+	return format, nil
+}
+
+// Return path of the default conf file, checking for a YAML or TOML
+// conf file with the same name if the default JSON conf does not exist
+func GetDefaultConfPath() string {
+	confPath := os.Getenv("GOPATH") + ConfPath
+	if _, statErr := os.Stat(confPath); statErr == nil {
+		return confPath
+	}
+	basePath := strings.TrimSuffix(confPath, filepath.Ext(confPath))
+	for _, ext := range []string{".yaml", ".yml", ".toml"} {
+		if _, statErr := os.Stat(basePath + ext); statErr == nil {
+			return basePath + ext
+		}
+	}
+	return confPath
+}
+
+// Convert conf of the format provided to JSON conf
+func ParseConf(conf []byte, format string) ([]byte, error) {
+	var categories map[string]interface{}
+	switch format {
+	case ConfFormatJson:
+		return conf, nil
+	case ConfFormatYaml:
+		if yamlErr := yaml.Unmarshal(conf, &categories); yamlErr != nil {
+			return nil, errors.New(fmt.Sprintf("%s: %v", ErrorConfigFormatInvalid, yamlErr))
+		}
+	case ConfFormatToml:
+		var tomlErr error
+		if categories, tomlErr = parseToml(conf); tomlErr != nil {
+			return nil, errors.New(fmt.Sprintf("%s: %v", ErrorConfigFormatInvalid, tomlErr))
+		}
+	default:
+		return nil, errors.New(fmt.Sprintf("%s: %s", ErrorConfigFormatUnknown, format))
+	}
+
+	jsonConf := make(map[string]map[string]string)
+	for _, name := range sortedKeys(categories) {
+		options, ok := categories[name].(map[string]interface{})
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("%s: %s", ErrorConfigCategory, name))
+		}
+		jsonConf[name] = make(map[string]string)
+		for _, key := range sortedKeys(options) {
+			value := options[key]
+			if value == nil { // unset options are treated as missing
+				continue
+			}
+			str, strErr := confValueString(value)
+			if strErr != nil {
+				return nil, errors.New(fmt.Sprintf("%s: %s.%s", strErr, name, key))
+			}
+			jsonConf[name][key] = str
+		}
+	}
+	return json.Marshal(jsonConf)
+}
+
+// Return string value of a YAML or TOML option value
+func confValueString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case int, int64, float64, bool:
+		return fmt.Sprint(v), nil
+	case []interface{}:
+		values := make([]string, len(v))
+		for i := range v {
+			str, strErr := confValueString(v[i])
+			if strErr != nil {
+				return "", strErr
+			}
+			if _, isList := v[i].([]interface{}); isList {
+				return "", errors.New(ErrorConfigValue)
+			}
+			values[i] = str
+		}
+		return strings.Join(values, ","), nil
+	}
+	return "", errors.New(ErrorConfigValue)
+}
+
+// Return sorted map keys so that conf errors are deterministic
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -5,7 +5,9 @@
 package config
 
 import (
-	"os"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"strconv"
 	"strings"
 
@@ -22,10 +24,14 @@ const (
 	MainChainName                = "main"
 	StaychainName                = "staychain"
 	StaychainRegtestName         = "regtest"
+	StaychainDryRunName          = "dryRun"
 	StaychainInitTxName          = "initTx"
 	StaychainInitScriptName      = "initScript"
 	StaychainInitPkName          = "initPK"
 	StaychainInitChaincodesName  = "initChaincodes"
+	StaychainInitXpubsName       = "initXpubs"
+	StaychainInitXprvName        = "initXprv"
+	StaychainDerivationPathName  = "derivationPath"
 	StaychainTopupAddressName    = "topupAddress"
 	StaychainTopupScriptName     = "topupScript"
 	StaychainTopupPkName         = "topupPK"
@@ -38,24 +44,63 @@ const (
 type Config struct {
 	// main bitcoin rpc connectivity
 	mainClient   *rpcclient.Client
+	mainClients  []*rpcclient.Client
 	mainChainCfg *chaincfg.Params
 
 	// core staychain config parameters
 	regtest         bool
+	dryRun          bool
 	initTX          string
 	initPK          string
 	initScript      string
 	initChaincodes  []string
+	initXpubs       []string
+	initXprv        string
+	derivationPath  string
 	topupAddress    string
 	topupScript     string
 	topupPK         string
 	topupChaincodes []string
 
 	// additional parameter categories
-	signerConfig SignerConfig
-	dbConfig     DbConfig
-	feesConfig   FeesConfig
-	timingConfig TimingConfig
+	signerConfig      SignerConfig
+	dbConfig          DbConfig
+	feesConfig        FeesConfig
+	timingConfig      TimingConfig
+	adaptiveConfig    AdaptiveConfig
+	heartbeatConfig   HeartbeatConfig
+	rbfConfig         RbfConfig
+	confirmConfig     ConfirmationConfig
+	budgetConfig      BudgetConfig
+	apiConfig         ApiConfig
+	balanceConfig     BalanceConfig
+	canaryConfig      CanaryConfig
+	logConfig         LogConfig
+	tracingConfig     TracingConfig
+	reviewConfig      ReviewConfig
+	webhookConfig     WebhookConfig
+	alertConfig       AlertConfig
+	quorumConfig      QuorumConfig
+	eventsConfig      EventsConfig
+	walletConfig      WalletConfig
+	esploraConfig     EsploraConfig
+	throttleConfig    ThrottleConfig
+	rpcConfig         RpcConfig
+	zmqConfig         ZmqConfig
+	kafkaConfig       KafkaConfig
+	tsaConfig         TsaConfig
+	aggregationConfig AggregationConfig
+	indexerConfig     IndexerConfig
+	sidechainConfig   SidechainConfig
+	pullConfig        PullConfig
+	orderingConfig    OrderingConfig
+	merkleConfig      MerkleConfig
+	transitionConfig  TransitionConfig
+	deliveryConfig    DeliveryConfig
+	daemonConfig      DaemonConfig
+
+	// rpc clients of the sidechains committed by name
+	sidechainClients map[string]clients.SidechainClient
 }
 
 // Get Main Client
@@ -63,6 +108,15 @@ func (c Config) MainClient() *rpcclient.Client {
 	return c.mainClient
 }
 
+// Get Main Clients for all main rpc endpoints
+// The first client is the Main Client
+func (c Config) MainClients() []*rpcclient.Client {
+	if len(c.mainClients) == 0 && c.mainClient != nil {
+		return []*rpcclient.Client{c.mainClient}
+	}
+	return c.mainClients
+}
+
 // Get Main Client Cfg
 func (c Config) MainChainCfg() *chaincfg.Params {
 	return c.mainChainCfg
@@ -73,6 +127,11 @@ func (c Config) SignerConfig() SignerConfig {
 	return c.signerConfig
 }
 
+// Set signer configuration
+func (c *Config) SetSignerConfig(signerConfig SignerConfig) {
+	c.signerConfig = signerConfig
+}
+
 // Get Database configuration
 func (c Config) DbConfig() DbConfig {
 	return c.dbConfig
@@ -83,6 +142,11 @@ func (c Config) FeesConfig() FeesConfig {
 	return c.feesConfig
 }
 
+// Set fees configuration
+func (c *Config) SetFeesConfig(feesConfig FeesConfig) {
+	c.feesConfig = feesConfig
+}
+
 // Get Timing configuration
 func (c Config) TimingConfig() TimingConfig {
 	return c.timingConfig
@@ -93,6 +157,191 @@ func (c *Config) SetTimingConfig(timingConfig TimingConfig) {
 	c.timingConfig = timingConfig
 }
 
+// Get Adaptive configuration
+func (c Config) AdaptiveConfig() AdaptiveConfig {
+	return c.adaptiveConfig
+}
+
+// Set adaptive configuration
+func (c *Config) SetAdaptiveConfig(adaptiveConfig AdaptiveConfig) {
+	c.adaptiveConfig = adaptiveConfig
+}
+
+// Get Heartbeat configuration
+func (c Config) HeartbeatConfig() HeartbeatConfig {
+	return c.heartbeatConfig
+}
+
+// Set heartbeat configuration
+func (c *Config) SetHeartbeatConfig(heartbeatConfig HeartbeatConfig) {
+	c.heartbeatConfig = heartbeatConfig
+}
+
+// Get RBF configuration
+func (c Config) RbfConfig() RbfConfig {
+	return c.rbfConfig
+}
+
+// Set RBF configuration
+func (c *Config) SetRbfConfig(rbfConfig RbfConfig) {
+	c.rbfConfig = rbfConfig
+}
+
+// Get Confirmation configuration
+func (c Config) ConfirmationConfig() ConfirmationConfig {
+	return c.confirmConfig
+}
+
+// Set Confirmation configuration
+func (c *Config) SetConfirmationConfig(confirmConfig ConfirmationConfig) {
+	c.confirmConfig = confirmConfig
+}
+
+// Get Budget configuration
+func (c Config) BudgetConfig() BudgetConfig {
+	return c.budgetConfig
+}
+
+// Set Budget configuration
+func (c *Config) SetBudgetConfig(budgetConfig BudgetConfig) {
+	c.budgetConfig = budgetConfig
+}
+
+// Get Api configuration
+func (c Config) ApiConfig() ApiConfig {
+	return c.apiConfig
+}
+
+// Get Balance configuration
+func (c Config) BalanceConfig() BalanceConfig {
+	return c.balanceConfig
+}
+
+// Get Canary configuration
+func (c Config) CanaryConfig() CanaryConfig {
+	return c.canaryConfig
+}
+
+// Get Log configuration
+func (c Config) LogConfig() LogConfig {
+	return c.logConfig
+}
+
+// Get Tracing configuration
+func (c Config) TracingConfig() TracingConfig {
+	return c.tracingConfig
+}
+
+// Get Review configuration
+func (c Config) ReviewConfig() ReviewConfig {
+	return c.reviewConfig
+}
+
+// Get Webhook configuration
+func (c Config) WebhookConfig() WebhookConfig {
+	return c.webhookConfig
+}
+
+// Get Alert configuration
+func (c Config) AlertConfig() AlertConfig {
+	return c.alertConfig
+}
+
+// Get Quorum configuration
+func (c Config) QuorumConfig() QuorumConfig {
+	return c.quorumConfig
+}
+
+// Get Events configuration
+func (c Config) EventsConfig() EventsConfig {
+	return c.eventsConfig
+}
+
+// Get Wallet configuration
+func (c Config) WalletConfig() WalletConfig {
+	return c.walletConfig
+}
+
+// Get Esplora configuration
+func (c Config) EsploraConfig() EsploraConfig {
+	return c.esploraConfig
+}
+
+// Get Throttle configuration
+func (c Config) ThrottleConfig() ThrottleConfig {
+	return c.throttleConfig
+}
+
+// Get Rpc configuration
+func (c Config) RpcConfig() RpcConfig {
+	return c.rpcConfig
+}
+
+// Get Zmq configuration
+func (c Config) ZmqConfig() ZmqConfig {
+	return c.zmqConfig
+}
+
+// Get Kafka configuration
+func (c Config) KafkaConfig() KafkaConfig {
+	return c.kafkaConfig
+}
+
+// Get Tsa configuration
+func (c Config) TsaConfig() TsaConfig {
+	return c.tsaConfig
+}
+
+// Get Aggregation configuration
+func (c Config) AggregationConfig() AggregationConfig {
+	return c.aggregationConfig
+}
+
+// Get Indexer configuration
+func (c Config) IndexerConfig() IndexerConfig {
+	return c.indexerConfig
+}
+
+// Get Sidechain configuration
+func (c Config) SidechainConfig() SidechainConfig {
+	return c.sidechainConfig
+}
+
+// Get rpc clients of the sidechains by name
+func (c Config) SidechainClients() map[string]clients.SidechainClient {
+	return c.sidechainClients
+}
+
+// Get Pull configuration
+func (c Config) PullConfig() PullConfig {
+	return c.pullConfig
+}
+
+// Get Ordering configuration
+func (c Config) OrderingConfig() OrderingConfig {
+	return c.orderingConfig
+}
+
+// Get Merkle configuration
+func (c Config) MerkleConfig() MerkleConfig {
+	return c.merkleConfig
+}
+
+// Get Transition configuration
+func (c Config) TransitionConfig() TransitionConfig {
+	return c.transitionConfig
+}
+
+// Get proof Delivery configuration
+func (c Config) DeliveryConfig() DeliveryConfig {
+	return c.deliveryConfig
+}
+
+// Get Daemon configuration
+func (c Config) DaemonConfig() DaemonConfig {
+	return c.daemonConfig
+}
+
 // Get regtest flag
 func (c Config) Regtest() bool {
 	return c.regtest
@@ -103,6 +352,16 @@ func (c *Config) SetRegtest(regtest bool) {
 	c.regtest = regtest
 }
 
+// Get dry run flag
+func (c Config) DryRun() bool {
+	return c.dryRun
+}
+
+// Set dry run flag
+func (c *Config) SetDryRun(dryRun bool) {
+	c.dryRun = dryRun
+}
+
 // Get init TX
 func (c Config) InitTx() string {
 	return c.initTX
@@ -153,6 +412,21 @@ func (c *Config) SetInitChaincodes(chaincodes []string) {
 	c.initChaincodes = chaincodes
 }
 
+// Get Init Xpubs
+func (c *Config) InitXpubs() []string {
+	return c.initXpubs
+}
+
+// Get Init Xprv
+func (c *Config) InitXprv() string {
+	return c.initXprv
+}
+
+// Get Derivation Path
+func (c *Config) DerivationPath() string {
+	return c.derivationPath
+}
+
 // Get topup Script
 func (c *Config) TopupScript() string {
 	return c.topupScript
@@ -187,17 +461,21 @@ func (c *Config) SetTopupPK(pk string) {
 func NewConfig(customConf ...[]byte) (*Config, error) {
 	var conf []byte
 	if len(customConf) > 0 { //custom config provided
-		conf = customConf[0]
+		var secretsErr error
+		conf, secretsErr = ResolveSecrets(customConf[0])
+		if secretsErr != nil {
+			return nil, secretsErr
+		}
 	} else {
 		var confErr error
-		conf, confErr = GetConfFile(os.Getenv("GOPATH") + ConfPath)
+		conf, confErr = GetConfFile(GetDefaultConfPath())
 		if confErr != nil {
 			return nil, confErr
 		}
 	}
 
-	// get main rpc client
-	mainClient, rpcErr := GetRPC(MainChainName, conf)
+	// get main rpc clients - the first is used for signing
+	mainClients, rpcErr := GetRPCs(MainChainName, conf)
 	if rpcErr != nil {
 		return nil, rpcErr
 	}
@@ -216,15 +494,70 @@ func NewConfig(customConf ...[]byte) (*Config, error) {
 
 	feesConfig := GetFeesConfig(conf)
 	timingConfig := GetTimingConfig(conf)
+	adaptiveConfig := GetAdaptiveConfig(conf)
+	heartbeatConfig := GetHeartbeatConfig(conf)
+	rbfConfig := GetRbfConfig(conf)
+	confirmConfig := GetConfirmationConfig(conf)
+	budgetConfig := GetBudgetConfig(conf)
+	apiConfig := GetApiConfig(conf)
+	balanceConfig := GetBalanceConfig(conf)
+	logConfig := GetLogConfig(conf)
+	tracingConfig := GetTracingConfig(conf)
+	reviewConfig := GetReviewConfig(conf)
+	webhookConfig := GetWebhookConfig(conf)
+	alertConfig := GetAlertConfig(conf)
+	eventsConfig := GetEventsConfig(conf)
+	esploraConfig := GetEsploraConfig(conf)
+	throttleConfig := GetThrottleConfig(conf)
+	rpcConfig := GetRpcConfig(conf)
+	zmqConfig := GetZmqConfig(conf)
+	kafkaConfig := GetKafkaConfig(conf)
+	tsaConfig := GetTsaConfig(conf)
+	aggregationConfig := GetAggregationConfig(conf)
+	indexerConfig := GetIndexerConfig(conf)
+	sidechainConfig := GetSidechainConfig(conf)
+	pullConfig := GetPullConfig(conf)
+	deliveryConfig := GetDeliveryConfig(conf)
+	daemonConfig := GetDaemonConfig(conf)
+
+	canaryConfig, canaryConfigErr := GetCanaryConfig(conf)
+	if canaryConfigErr != nil {
+		return nil, canaryConfigErr
+	}
+
+	quorumConfig, quorumConfigErr := GetQuorumConfig(conf)
+	if quorumConfigErr != nil {
+		return nil, quorumConfigErr
+	}
 
 	signerConfig, signerConfigErr := GetSignerConfig(conf)
 	if signerConfigErr != nil {
 		return nil, signerConfigErr
 	}
 
+	walletConfig, walletConfigErr := GetWalletConfig(conf)
+	if walletConfigErr != nil {
+		return nil, walletConfigErr
+	}
+
+	orderingConfig, orderingConfigErr := GetOrderingConfig(conf)
+	if orderingConfigErr != nil {
+		return nil, orderingConfigErr
+	}
+
+	merkleConfig := GetMerkleConfig(conf)
+	transitionConfig := GetTransitionConfig(conf)
+
+	// get rpc clients of the sidechains committed
+	sidechainClients, sidechainErr := GetSidechainClients(sidechainConfig, conf)
+	if sidechainErr != nil {
+		return nil, sidechainErr
+	}
+
 	// get staychain config parameters
 	// most of these can be overriden from command line
 	regtestStr := TryGetParamFromConf(StaychainName, StaychainRegtestName, conf)
+	dryRunStr := TryGetParamFromConf(StaychainName, StaychainDryRunName, conf)
 	initTxStr := TryGetParamFromConf(StaychainName, StaychainInitTxName, conf)
 	initScriptStr := TryGetParamFromConf(StaychainName, StaychainInitScriptName, conf)
 	initPKStr := TryGetParamFromConf(StaychainName, StaychainInitPkName, conf)
@@ -237,6 +570,15 @@ func NewConfig(customConf ...[]byte) (*Config, error) {
 	for i := range initChaincodes {                         // trim whitespace
 		initChaincodes[i] = strings.TrimSpace(initChaincodes[i])
 	}
+	var initXpubs []string
+	if initXpubsStr := TryGetParamFromConf(StaychainName, StaychainInitXpubsName, conf); initXpubsStr != "" {
+		initXpubs = strings.Split(initXpubsStr, ",") // string to string slice
+		for i := range initXpubs {                   // trim whitespace
+			initXpubs[i] = strings.TrimSpace(initXpubs[i])
+		}
+	}
+	initXprvStr := TryGetParamFromConf(StaychainName, StaychainInitXprvName, conf)
+	derivationPathStr := TryGetParamFromConf(StaychainName, StaychainDerivationPathName, conf)
 	topupChaincodesStr := TryGetParamFromConf(StaychainName, StayChainTopupChaincodesName, conf)
 	topupChaincodes := strings.Split(topupChaincodesStr, ",") // string to string slice
 	for i := range topupChaincodes {                          // trim whitespace
@@ -244,21 +586,58 @@ func NewConfig(customConf ...[]byte) (*Config, error) {
 	}
 
 	return &Config{
-		mainClient:      mainClient,
-		mainChainCfg:    mainClientCfg,
-		regtest:         (regtestStr == "1"),
-		initTX:          initTxStr,
-		initPK:          initPKStr,
-		initScript:      initScriptStr,
-		initChaincodes:  initChaincodes,
-		topupAddress:    topupAddrStr,
-		topupScript:     topupScriptStr,
-		topupPK:         topupPKStr,
-		topupChaincodes: topupChaincodes,
-		signerConfig:    signerConfig,
-		dbConfig:        dbConnectivity,
-		feesConfig:      feesConfig,
-		timingConfig:    timingConfig,
+		mainClient:        mainClients[0],
+		mainClients:       mainClients,
+		mainChainCfg:      mainClientCfg,
+		regtest:           (regtestStr == "1"),
+		dryRun:            (dryRunStr == "1"),
+		initTX:            initTxStr,
+		initPK:            initPKStr,
+		initScript:        initScriptStr,
+		initChaincodes:    initChaincodes,
+		initXpubs:         initXpubs,
+		initXprv:          initXprvStr,
+		derivationPath:    derivationPathStr,
+		topupAddress:      topupAddrStr,
+		topupScript:       topupScriptStr,
+		topupPK:           topupPKStr,
+		topupChaincodes:   topupChaincodes,
+		signerConfig:      signerConfig,
+		dbConfig:          dbConnectivity,
+		feesConfig:        feesConfig,
+		timingConfig:      timingConfig,
+		adaptiveConfig:    adaptiveConfig,
+		heartbeatConfig:   heartbeatConfig,
+		rbfConfig:         rbfConfig,
+		confirmConfig:     confirmConfig,
+		budgetConfig:      budgetConfig,
+		apiConfig:         apiConfig,
+		balanceConfig:     balanceConfig,
+		canaryConfig:      canaryConfig,
+		logConfig:         logConfig,
+		tracingConfig:     tracingConfig,
+		reviewConfig:      reviewConfig,
+		webhookConfig:     webhookConfig,
+		alertConfig:       alertConfig,
+		quorumConfig:      quorumConfig,
+		eventsConfig:      eventsConfig,
+		walletConfig:      walletConfig,
+		esploraConfig:     esploraConfig,
+		throttleConfig:    throttleConfig,
+		rpcConfig:         rpcConfig,
+		zmqConfig:         zmqConfig,
+		kafkaConfig:       kafkaConfig,
+		tsaConfig:         tsaConfig,
+		aggregationConfig: aggregationConfig,
+		indexerConfig:     indexerConfig,
+		sidechainConfig:   sidechainConfig,
+		pullConfig:        pullConfig,
+		orderingConfig:    orderingConfig,
+		merkleConfig:      merkleConfig,
+		transitionConfig:  transitionConfig,
+		deliveryConfig:    deliveryConfig,
+		daemonConfig:      daemonConfig,
+		sidechainClients:  sidechainClients,
 	}, nil
 }
 
@@ -274,7 +653,7 @@ func NewClientFromConfig(chainName string, isTest bool, customConf ...[]byte) cl
 		conf = customConf[0]
 	} else {
 		var confErr error
-		conf, confErr = GetConfFile(os.Getenv("GOPATH") + ConfPath)
+		conf, confErr = GetConfFile(GetDefaultConfPath())
 		if confErr != nil {
 			log.Error(confErr)
 		}
@@ -288,6 +667,47 @@ func NewClientFromConfig(chainName string, isTest bool, customConf ...[]byte) cl
 	return clients.NewSidechainClientOcean(sideClient)
 }
 
+// lightning config parameter names
+const (
+	LightningName               = "lightning"
+	LightningImplementationName = "implementation"
+	LightningUrlName            = "url"
+	LightningMacaroonName       = "macaroon"
+	LightningRuneName           = "rune"
+	LightningTlsCertName        = "tlsCert"
+)
+
+// Return LightningClient for the lightning node implementation in config
+// Implementation and url are compulsory, as is the macaroon file path
+// for lnd nodes and the rune for cln nodes, while tls cert is optional
+func NewLightningClientFromConfig(conf []byte) (clients.LightningClient, error) {
+	implementation, implementationErr := GetParamFromConf(LightningName, LightningImplementationName, conf)
+	if implementationErr != nil {
+		return nil, implementationErr
+	}
+	url, urlErr := GetParamFromConf(LightningName, LightningUrlName, conf)
+	if urlErr != nil {
+		return nil, urlErr
+	}
+	tlsCert := TryGetParamFromConf(LightningName, LightningTlsCertName, conf)
+
+	switch implementation {
+	case clients.LightningImplementationLnd:
+		macaroon, macaroonErr := GetParamFromConf(LightningName, LightningMacaroonName, conf)
+		if macaroonErr != nil {
+			return nil, macaroonErr
+		}
+		return clients.NewLightningClientLnd(url, macaroon, tlsCert)
+	case clients.LightningImplementationCln:
+		rune, runeErr := GetParamFromConf(LightningName, LightningRuneName, conf)
+		if runeErr != nil {
+			return nil, runeErr
+		}
+		return clients.NewLightningClientCln(url, rune, tlsCert)
+	}
+	return nil, errors.New(fmt.Sprintf("%s: %s", clients.ErrorLightningImplementation, implementation))
+}
+
 // db config parameter names
 const (
 	DbUserName     = "user"
@@ -296,6 +716,19 @@ const (
 	DbPortName     = "port"
 	DbNameName     = "name"
 	DbName         = "db"
+
+	DbUriName            = "uri"
+	DbOptionsName        = "options"
+	DbSrvName            = "srv"
+	DbTlsName            = "tls"
+	DbTlsCaFileName      = "tlsCaFile"
+	DbTlsCertFileName    = "tlsCertFile"
+	DbTlsKeyFileName     = "tlsKeyFile"
+	DbX509Name           = "x509"
+	DbMaxPoolSizeName    = "maxPoolSize"
+	DbMinPoolSizeName    = "minPoolSize"
+	DbTimeoutSecondsName = "timeoutSeconds"
+	DbRetriesName        = "retries"
 )
 
 // DbConfig struct
@@ -306,49 +739,110 @@ type DbConfig struct {
 	Host     string
 	Port     string
 	Name     string
+
+	// optional connection string, replacing the connectivity details above,
+	// and connection string options, e.g. replicaSet=rs0&authSource=admin
+	Uri     string
+	Options string
+
+	// optional flag connecting through the SRV records of the host and tls
+	// options, enabled by the tls flag or any of the tls files being set,
+	// with the x509 flag authenticating by the tls client certificate
+	Srv         bool
+	Tls         bool
+	TlsCaFile   string
+	TlsCertFile string
+	TlsKeyFile  string
+	X509        bool
+
+	// optional connection pool, timeout and retry options, -1 if not set
+	MaxPoolSize    int
+	MinPoolSize    int
+	TimeoutSeconds int
+	Retries        int
 }
 
 // Return DbConfig from conf options
-// If DbName exists in the config, then all fields are compulsory
+// If DbName exists in the config, then all connectivity fields are
+// compulsory unless a connection string uri is set, in which case they are
+// optional and the database name defaults to the one of the uri. The port
+// is optional for srv hosts and credentials for x509 authentication
 // IF DbName does not exist, then all config fields are empty
 func GetDbConfig(conf []byte) (DbConfig, error) {
 
+	// optional connection string overriding the host connectivity parameters
+	uri := TryGetParamFromConf(DbName, DbUriName, conf)
+	srv := TryGetParamFromConf(DbName, DbSrvName, conf) == "1"
+	x509 := TryGetParamFromConf(DbName, DbX509Name, conf) == "1"
+
 	// db connectivity parameters
+	getParam := func(name string, optional bool) (string, error) {
+		if uri != "" || optional {
+			return TryGetParamFromConf(DbName, name, conf), nil
+		}
+		return GetParamFromConf(DbName, name, conf)
+	}
 
-	user, userErr := GetParamFromConf(DbName, DbUserName, conf)
+	user, userErr := getParam(DbUserName, x509)
 	if userErr != nil {
 		return DbConfig{}, userErr
 	}
 
-	password, passwordErr := GetParamFromConf(DbName, DbPasswordName, conf)
+	password, passwordErr := getParam(DbPasswordName, x509)
 	if passwordErr != nil {
 		return DbConfig{}, passwordErr
 	}
 
-	host, hostErr := GetParamFromConf(DbName, DbHostName, conf)
+	host, hostErr := getParam(DbHostName, false)
 	if hostErr != nil {
 		return DbConfig{}, hostErr
 	}
 
-	port, portErr := GetParamFromConf(DbName, DbPortName, conf)
+	port, portErr := getParam(DbPortName, srv)
 	if portErr != nil {
 		return DbConfig{}, portErr
 	}
 
-	name, nameErr := GetParamFromConf(DbName, DbNameName, conf)
+	name, nameErr := getParam(DbNameName, false)
 	if nameErr != nil {
 		return DbConfig{}, nameErr
 	}
 
+	tlsCaFile := TryGetParamFromConf(DbName, DbTlsCaFileName, conf)
+	tlsCertFile := TryGetParamFromConf(DbName, DbTlsCertFileName, conf)
+	tlsKeyFile := TryGetParamFromConf(DbName, DbTlsKeyFileName, conf)
+	tlsStr := TryGetParamFromConf(DbName, DbTlsName, conf)
+
 	return DbConfig{
-		User:     user,
-		Password: password,
-		Host:     host,
-		Port:     port,
-		Name:     name,
+		User:           user,
+		Password:       password,
+		Host:           NormalizeHost(host),
+		Port:           port,
+		Name:           name,
+		Uri:            uri,
+		Options:        TryGetParamFromConf(DbName, DbOptionsName, conf),
+		Srv:            srv,
+		Tls:            tlsStr == "1" || tlsCaFile != "" || tlsCertFile != "" || tlsKeyFile != "",
+		TlsCaFile:      tlsCaFile,
+		TlsCertFile:    tlsCertFile,
+		TlsKeyFile:     tlsKeyFile,
+		X509:           x509,
+		MaxPoolSize:    tryGetDbIntParam(DbMaxPoolSizeName, conf),
+		MinPoolSize:    tryGetDbIntParam(DbMinPoolSizeName, conf),
+		TimeoutSeconds: tryGetDbIntParam(DbTimeoutSecondsName, conf),
+		Retries:        tryGetDbIntParam(DbRetriesName, conf),
 	}, nil
 }
 
+// Return optional integer db parameter or -1 if not set
+func tryGetDbIntParam(name string, conf []byte) int {
+	value, valueErr := strconv.Atoi(TryGetParamFromConf(DbName, name, conf))
+	if valueErr != nil {
+		return -1
+	}
+	return value
+}
+
 // fee config parameter names
 const (
 	FeesName             = "fees"
@@ -411,6 +905,11 @@ const (
 	TimingName                         = "timing"
 	TimingNewAttestationMinutesName    = "newAttestationMinutes"
 	TimingHandleUnconfirmedMinutesName = "handleUnconfirmedMinutes"
+	TimingStartupDelaySecondsName      = "startupDelaySeconds"
+	TimingStaggerOffsetMinutesName     = "staggerOffsetMinutes"
+	TimingFixedSecondsName             = "fixedSeconds"
+	TimingSigsSecondsName              = "sigsSeconds"
+	TimingConfirmationSecondsName      = "confirmationSeconds"
 )
 
 // Timing config struct
@@ -418,6 +917,11 @@ const (
 type TimingConfig struct {
 	NewAttestationMinutes    int
 	HandleUnconfirmedMinutes int
+	StartupDelaySeconds      int
+	StaggerOffsetMinutes     int
+	FixedSeconds             int
+	SigsSeconds              int
+	ConfirmationSeconds      int
 }
 
 // Return TimingConfig from conf options
@@ -441,23 +945,284 @@ func GetTimingConfig(conf []byte) TimingConfig {
 		uncMin = uncMinInt
 	}
 
+	startupStr := TryGetParamFromConf(TimingName, TimingStartupDelaySecondsName, conf)
+	startup, startupErr := strconv.Atoi(startupStr)
+	if startupErr != nil {
+		startup = -1
+	}
+
+	staggerStr := TryGetParamFromConf(TimingName, TimingStaggerOffsetMinutesName, conf)
+	stagger, staggerErr := strconv.Atoi(staggerStr)
+	if staggerErr != nil {
+		stagger = -1
+	}
+
+	fixedStr := TryGetParamFromConf(TimingName, TimingFixedSecondsName, conf)
+	fixed, fixedErr := strconv.Atoi(fixedStr)
+	if fixedErr != nil {
+		fixed = -1
+	}
+
+	sigsStr := TryGetParamFromConf(TimingName, TimingSigsSecondsName, conf)
+	sigs, sigsErr := strconv.Atoi(sigsStr)
+	if sigsErr != nil {
+		sigs = -1
+	}
+
+	confirmationStr := TryGetParamFromConf(TimingName, TimingConfirmationSecondsName, conf)
+	confirmation, confirmationErr := strconv.Atoi(confirmationStr)
+	if confirmationErr != nil {
+		confirmation = -1
+	}
+
 	return TimingConfig{
 		NewAttestationMinutes:    attMin,
 		HandleUnconfirmedMinutes: uncMin,
+		StartupDelaySeconds:      startup,
+		StaggerOffsetMinutes:     stagger,
+		FixedSeconds:             fixed,
+		SigsSeconds:              sigs,
+		ConfirmationSeconds:      confirmation,
+	}
+}
+
+// adaptive config parameter names
+const (
+	AdaptiveName           = "adaptive"
+	AdaptiveFeeCeilingName = "feeCeiling"
+	AdaptiveFeeFloorName   = "feeFloor"
+	AdaptiveMinMinutesName = "minMinutes"
+	AdaptiveMaxMinutesName = "maxMinutes"
+)
+
+// Adaptive config struct
+// Configuration for stretching the time between attestations when mempool
+// fee rates are above a ceiling and shortening it when below a floor
+type AdaptiveConfig struct {
+	FeeCeiling int
+	FeeFloor   int
+	MinMinutes int
+	MaxMinutes int
+}
+
+// Return AdaptiveConfig from conf options
+// All Adaptive Config fields are optional
+func GetAdaptiveConfig(conf []byte) AdaptiveConfig {
+	ceilingStr := TryGetParamFromConf(AdaptiveName, AdaptiveFeeCeilingName, conf)
+	ceiling, ceilingErr := strconv.Atoi(ceilingStr)
+	if ceilingErr != nil {
+		ceiling = -1
+	}
+
+	floorStr := TryGetParamFromConf(AdaptiveName, AdaptiveFeeFloorName, conf)
+	floor, floorErr := strconv.Atoi(floorStr)
+	if floorErr != nil {
+		floor = -1
+	}
+
+	minStr := TryGetParamFromConf(AdaptiveName, AdaptiveMinMinutesName, conf)
+	min, minErr := strconv.Atoi(minStr)
+	if minErr != nil {
+		min = -1
+	}
+
+	maxStr := TryGetParamFromConf(AdaptiveName, AdaptiveMaxMinutesName, conf)
+	max, maxErr := strconv.Atoi(maxStr)
+	if maxErr != nil {
+		max = -1
+	}
+
+	return AdaptiveConfig{
+		FeeCeiling: ceiling,
+		FeeFloor:   floor,
+		MinMinutes: min,
+		MaxMinutes: max,
+	}
+}
+
+// heartbeat config parameter names
+const (
+	HeartbeatName            = "heartbeat"
+	HeartbeatIdleMinutesName = "idleMinutes"
+)
+
+// Heartbeat config struct
+// Configuration for the maximum time without a new commitment after
+// which the latest commitment is attested again
+type HeartbeatConfig struct {
+	IdleMinutes int
+}
+
+// Return HeartbeatConfig from conf options
+// All Heartbeat Config fields are optional
+func GetHeartbeatConfig(conf []byte) HeartbeatConfig {
+	idleStr := TryGetParamFromConf(HeartbeatName, HeartbeatIdleMinutesName, conf)
+	idle, idleErr := strconv.Atoi(idleStr)
+	if idleErr != nil {
+		idle = -1
+	}
+
+	return HeartbeatConfig{
+		IdleMinutes: idle,
+	}
+}
+
+// rbf config parameter names
+const (
+	RbfName                    = "rbf"
+	RbfBumpStrategyName        = "bumpStrategy"
+	RbfFeeIncrementPercentName = "feeIncrementPercent"
+	RbfMaxBumpsName            = "maxBumps"
+	RbfBumpScheduleMinutesName = "bumpScheduleMinutes"
+)
+
+// Rbf config struct
+// Configuration on the replace-by-fee policy used when bumping
+// the fee of attestations that have not been confirmed
+type RbfConfig struct {
+	BumpStrategy        string
+	FeeIncrementPercent int
+	MaxBumps            int
+	BumpScheduleMinutes []int
+}
+
+// Return RbfConfig from conf options
+// All Rbf Config fields are optional
+func GetRbfConfig(conf []byte) RbfConfig {
+	bumpStrategy := TryGetParamFromConf(RbfName, RbfBumpStrategyName, conf)
+
+	percentStr := TryGetParamFromConf(RbfName, RbfFeeIncrementPercentName, conf)
+	var percent int
+	percentInt, percentIntErr := strconv.Atoi(percentStr)
+	if percentIntErr != nil {
+		percent = -1
+	} else {
+		percent = percentInt
+	}
+
+	maxBumpsStr := TryGetParamFromConf(RbfName, RbfMaxBumpsName, conf)
+	var maxBumps int
+	maxBumpsInt, maxBumpsIntErr := strconv.Atoi(maxBumpsStr)
+	if maxBumpsIntErr != nil {
+		maxBumps = -1
+	} else {
+		maxBumps = maxBumpsInt
+	}
+
+	// comma separated minutes to wait before each successive bump
+	// invalid entries are set to -1 and rejected by the attestation service
+	var schedule []int
+	scheduleStr := TryGetParamFromConf(RbfName, RbfBumpScheduleMinutesName, conf)
+	if scheduleStr != "" {
+		for _, minutesStr := range strings.Split(scheduleStr, ",") {
+			minutes, minutesErr := strconv.Atoi(strings.TrimSpace(minutesStr))
+			if minutesErr != nil {
+				minutes = -1
+			}
+			schedule = append(schedule, minutes)
+		}
+	}
+
+	return RbfConfig{
+		BumpStrategy:        bumpStrategy,
+		FeeIncrementPercent: percent,
+		MaxBumps:            maxBumps,
+		BumpScheduleMinutes: schedule,
+	}
+}
+
+// confirmation config parameter names
+const (
+	ConfirmationName      = "confirmation"
+	ConfirmationDepthName = "depth"
+)
+
+// Confirmation config struct
+// Configuration for the number of confirmations of an attestation
+// before it is treated as confirmed
+type ConfirmationConfig struct {
+	Depth int
+}
+
+// Return ConfirmationConfig from conf options
+// All Confirmation Config fields are optional
+func GetConfirmationConfig(conf []byte) ConfirmationConfig {
+	depthStr := TryGetParamFromConf(ConfirmationName, ConfirmationDepthName, conf)
+	var depth int
+	depthInt, depthIntErr := strconv.Atoi(depthStr)
+	if depthIntErr != nil {
+		depth = -1
+	} else {
+		depth = depthInt
+	}
+
+	return ConfirmationConfig{
+		Depth: depth,
+	}
+}
+
+// budget config parameter names
+const (
+	BudgetName        = "budget"
+	BudgetDailyName   = "daily"
+	BudgetMonthlyName = "monthly"
+)
+
+// Budget config struct
+// Configuration for the maximum fees in satoshis spent on attestations
+// in a calendar day and month
+type BudgetConfig struct {
+	Daily   int
+	Monthly int
+}
+
+// Return BudgetConfig from conf options
+// All Budget Config fields are optional
+func GetBudgetConfig(conf []byte) BudgetConfig {
+	dailyStr := TryGetParamFromConf(BudgetName, BudgetDailyName, conf)
+	var daily int
+	dailyInt, dailyIntErr := strconv.Atoi(dailyStr)
+	if dailyIntErr != nil {
+		daily = -1
+	} else {
+		daily = dailyInt
+	}
+
+	monthlyStr := TryGetParamFromConf(BudgetName, BudgetMonthlyName, conf)
+	var monthly int
+	monthlyInt, monthlyIntErr := strconv.Atoi(monthlyStr)
+	if monthlyIntErr != nil {
+		monthly = -1
+	} else {
+		monthly = monthlyInt
+	}
+
+	return BudgetConfig{
+		Daily:   daily,
+		Monthly: monthly,
 	}
 }
 
 // signer config parameter names
 const (
-	Signer = "signer"
-	Url    = "url"
+	Signer          = "signer"
+	Url             = "url"
+	SignerRetries   = "retries"
+	SignerThreshold = "threshold"
+	SignerPubkeys   = "pubkeys"
 )
 
 // Signer config struct
 // Configuration on communication between service and signers
 // Configure host addresses and zmq TOPIC config
+// Threshold and Pubkeys configure the number of signatures required and
+// the signers these are accepted from, defaulting to the signatures required
+// by the redeem script from any of its signers
 type SignerConfig struct {
-	Url string
+	Url       string
+	Retries   int
+	Threshold int
+	Pubkeys   []string
 }
 
 // Return SignerConfig from conf options
@@ -469,7 +1234,1306 @@ func GetSignerConfig(conf []byte) (SignerConfig, error) {
 
 	url := TryGetParamFromConf(Signer, Url, conf)
 
+	retriesStr := TryGetParamFromConf(Signer, SignerRetries, conf)
+	var retries int
+	retriesInt, retriesIntErr := strconv.Atoi(retriesStr)
+	if retriesIntErr != nil {
+		retries = -1
+	} else {
+		retries = retriesInt
+	}
+
+	threshold, thresholdErr := strconv.Atoi(TryGetParamFromConf(Signer, SignerThreshold, conf))
+	if thresholdErr != nil {
+		threshold = 0
+	}
+
+	var pubkeys []string
+	if pubkeysStr := TryGetParamFromConf(Signer, SignerPubkeys, conf); pubkeysStr != "" {
+		pubkeys = strings.Split(pubkeysStr, ",") // string to string slice
+		for i := range pubkeys {
+			pubkeys[i] = strings.TrimSpace(pubkeys[i])
+		}
+	}
+
 	return SignerConfig{
-		Url: url,
+		Url:       url,
+		Retries:   retries,
+		Threshold: threshold,
+		Pubkeys:   pubkeys,
 	}, nil
 }
+
+// api config parameter names
+const (
+	ApiName                        = "api"
+	ApiAuthSchemesName             = "authSchemes"
+	ApiHmacReplayWindowSecondsName = "hmacReplayWindowSeconds"
+	ApiAdminTokenName              = "adminToken"
+	ApiProofOpsName                = "proofOps"
+	ApiAdminAllowedIpsName         = "adminAllowedIps"
+	ApiAdminClientCaFileName       = "adminClientCaFile"
+	ApiTlsCertFileName             = "tlsCertFile"
+	ApiTlsKeyFileName              = "tlsKeyFile"
+	ApiCorsAllowedOriginsName      = "corsAllowedOrigins"
+	ApiCorsAllowedMethodsName      = "corsAllowedMethods"
+	ApiAcmeDomainsName             = "acmeDomains"
+	ApiAcmeEmailName               = "acmeEmail"
+	ApiAcmeCacheDirName            = "acmeCacheDir"
+	ApiHttpRedirectAddrName        = "httpRedirectAddr"
+	ApiReadTimeoutSecondsName      = "readTimeoutSeconds"
+	ApiWriteTimeoutSecondsName     = "writeTimeoutSeconds"
+	ApiIdleTimeoutSecondsName      = "idleTimeoutSeconds"
+	ApiShutdownTimeoutSecondsName  = "shutdownTimeoutSeconds"
+	ApiMaxBodyBytesName            = "maxBodyBytes"
+	ApiEnabledName                 = "enabled"
+	ApiHostName                    = "host"
+	ApiPortName                    = "port"
+	ApiAdminHostName               = "adminHost"
+	ApiAdminPortName               = "adminPort"
+)
+
+// Api config struct
+// Configuration for the request api service and client authentication
+type ApiConfig struct {
+	AuthSchemes             []string
+	HmacReplayWindowSeconds int
+	AdminToken              string
+	ProofOps                string
+
+	// optional restriction of the admin routes to allowlisted source IPs or
+	// CIDR ranges and to client certificates issued by the client ca, which
+	// requires the api to be served over tls with the cert and key files
+	AdminAllowedIps   []string
+	AdminClientCaFile string
+	TlsCertFile       string
+	TlsKeyFile        string
+
+	// optional origins, or *, and methods allowed for cross-origin
+	// requests to the public routes
+	CorsAllowedOrigins []string
+	CorsAllowedMethods []string
+
+	// optional domains to obtain tls certificates for by acme, instead of
+	// certificate files, and address redirecting plain http to https
+	AcmeDomains      []string
+	AcmeEmail        string
+	AcmeCacheDir     string
+	HttpRedirectAddr string
+
+	// optional server timeouts, request body size limit and time allowed to
+	// drain requests on shutdown, -1 if not set
+	ReadTimeoutSeconds     int
+	WriteTimeoutSeconds    int
+	IdleTimeoutSeconds     int
+	ShutdownTimeoutSeconds int
+	MaxBodyBytes           int
+
+	// whether the request api is served, unless disabled with "0", and the
+	// optional host and port it is bound to, e.g. 127.0.0.1 for localhost only
+	Enabled bool
+	Host    string
+	Port    string
+
+	// optional host and port of a separate admin api serving the admin and
+	// management routes, which are then not served by the public api
+	AdminHost string
+	AdminPort string
+}
+
+// Return ApiConfig from conf options
+// All Api Config fields are optional
+func GetApiConfig(conf []byte) ApiConfig {
+	var authSchemes []string
+	authSchemesStr := TryGetParamFromConf(ApiName, ApiAuthSchemesName, conf)
+	if authSchemesStr != "" {
+		authSchemes = strings.Split(authSchemesStr, ",") // string to string slice
+		for i := range authSchemes {                     // trim whitespace
+			authSchemes[i] = strings.TrimSpace(authSchemes[i])
+		}
+	}
+
+	adminToken := TryGetParamFromConf(ApiName, ApiAdminTokenName, conf)
+	proofOps := TryGetParamFromConf(ApiName, ApiProofOpsName, conf)
+
+	return ApiConfig{
+		AuthSchemes:             authSchemes,
+		HmacReplayWindowSeconds: getApiInt(ApiHmacReplayWindowSecondsName, conf),
+		AdminToken:              adminToken,
+		ProofOps:                proofOps,
+		AdminAllowedIps:         getApiList(ApiAdminAllowedIpsName, conf),
+		AdminClientCaFile:       TryGetParamFromConf(ApiName, ApiAdminClientCaFileName, conf),
+		TlsCertFile:             TryGetParamFromConf(ApiName, ApiTlsCertFileName, conf),
+		TlsKeyFile:              TryGetParamFromConf(ApiName, ApiTlsKeyFileName, conf),
+		CorsAllowedOrigins:      getApiList(ApiCorsAllowedOriginsName, conf),
+		CorsAllowedMethods:      getApiList(ApiCorsAllowedMethodsName, conf),
+		AcmeDomains:             getApiList(ApiAcmeDomainsName, conf),
+		AcmeEmail:               TryGetParamFromConf(ApiName, ApiAcmeEmailName, conf),
+		AcmeCacheDir:            TryGetParamFromConf(ApiName, ApiAcmeCacheDirName, conf),
+		HttpRedirectAddr:        TryGetParamFromConf(ApiName, ApiHttpRedirectAddrName, conf),
+		ReadTimeoutSeconds:      getApiInt(ApiReadTimeoutSecondsName, conf),
+		WriteTimeoutSeconds:     getApiInt(ApiWriteTimeoutSecondsName, conf),
+		IdleTimeoutSeconds:      getApiInt(ApiIdleTimeoutSecondsName, conf),
+		ShutdownTimeoutSeconds:  getApiInt(ApiShutdownTimeoutSecondsName, conf),
+		MaxBodyBytes:            getApiInt(ApiMaxBodyBytesName, conf),
+		Enabled:                 TryGetParamFromConf(ApiName, ApiEnabledName, conf) != "0",
+		Host:                    TryGetParamFromConf(ApiName, ApiHostName, conf),
+		Port:                    TryGetParamFromConf(ApiName, ApiPortName, conf),
+		AdminHost:               TryGetParamFromConf(ApiName, ApiAdminHostName, conf),
+		AdminPort:               TryGetParamFromConf(ApiName, ApiAdminPortName, conf),
+	}
+}
+
+// Return int api config parameter, or -1 if not set or invalid
+func getApiInt(name string, conf []byte) int {
+	value, valueErr := strconv.Atoi(TryGetParamFromConf(ApiName, name, conf))
+	if valueErr != nil {
+		return -1
+	}
+	return value
+}
+
+// Return comma separated list of api config parameter, trimmed of whitespace
+func getApiList(name string, conf []byte) []string {
+	var list []string
+	listStr := TryGetParamFromConf(ApiName, name, conf)
+	if listStr != "" {
+		list = strings.Split(listStr, ",")
+		for i := range list {
+			list[i] = strings.TrimSpace(list[i])
+		}
+	}
+	return list
+}
+
+// balance config parameter names
+const (
+	BalanceName               = "balance"
+	BalanceAlertThresholdName = "alertThreshold"
+)
+
+// Balance config struct
+// Configuration for monitoring the staychain balance
+type BalanceConfig struct {
+	AlertThreshold int
+}
+
+// Return BalanceConfig from conf options
+// All Balance Config fields are optional
+func GetBalanceConfig(conf []byte) BalanceConfig {
+	thresholdStr := TryGetParamFromConf(BalanceName, BalanceAlertThresholdName, conf)
+	var threshold int
+	thresholdInt, thresholdIntErr := strconv.Atoi(thresholdStr)
+	if thresholdIntErr != nil {
+		threshold = -1
+	} else {
+		threshold = thresholdInt
+	}
+
+	return BalanceConfig{
+		AlertThreshold: threshold,
+	}
+}
+
+// canary config parameter names
+// canary rpc connectivity and staychain parameter names
+// are the same as the main and staychain parameter names
+const (
+	CanaryName               = "canary"
+	CanaryTimeoutMinutesName = "timeoutMinutes"
+)
+
+// Canary config struct
+// Configuration for mirroring attestations on a testnet or signet
+// staychain before broadcasting the attestation on the main staychain
+// Config is nil if the canary category is not set
+type CanaryConfig struct {
+	TimeoutMinutes int
+	Config         *Config
+}
+
+// Return CanaryConfig from conf options
+// If CanaryName exists in the config, rpc connectivity fields are compulsory
+// Fees config of the main staychain is also used for the canary staychain
+func GetCanaryConfig(conf []byte) (CanaryConfig, error) {
+	if _, cfgErr := getCfg(CanaryName, conf); cfgErr != nil {
+		return CanaryConfig{TimeoutMinutes: -1}, nil
+	}
+
+	canaryClient, rpcErr := GetRPC(CanaryName, conf)
+	if rpcErr != nil {
+		return CanaryConfig{}, rpcErr
+	}
+	canaryClientCfg, paramsErr := GetChainCfgParams(CanaryName, conf)
+	if paramsErr != nil {
+		return CanaryConfig{}, paramsErr
+	}
+
+	timeoutStr := TryGetParamFromConf(CanaryName, CanaryTimeoutMinutesName, conf)
+	var timeout int
+	timeoutInt, timeoutIntErr := strconv.Atoi(timeoutStr)
+	if timeoutIntErr != nil {
+		timeout = -1
+	} else {
+		timeout = timeoutInt
+	}
+
+	var initChaincodes []string
+	initChaincodesStr := TryGetParamFromConf(CanaryName, StaychainInitChaincodesName, conf)
+	if initChaincodesStr != "" {
+		initChaincodes = strings.Split(initChaincodesStr, ",") // string to string slice
+		for i := range initChaincodes {                        // trim whitespace
+			initChaincodes[i] = strings.TrimSpace(initChaincodes[i])
+		}
+	}
+
+	return CanaryConfig{
+		TimeoutMinutes: timeout,
+		Config: &Config{
+			mainClient:     canaryClient,
+			mainChainCfg:   canaryClientCfg,
+			initTX:         TryGetParamFromConf(CanaryName, StaychainInitTxName, conf),
+			initPK:         TryGetParamFromConf(CanaryName, StaychainInitPkName, conf),
+			initScript:     TryGetParamFromConf(CanaryName, StaychainInitScriptName, conf),
+			initChaincodes: initChaincodes,
+			topupAddress:   TryGetParamFromConf(CanaryName, StaychainTopupAddressName, conf),
+			topupScript:    TryGetParamFromConf(CanaryName, StaychainTopupScriptName, conf),
+			topupPK:        TryGetParamFromConf(CanaryName, StaychainTopupPkName, conf),
+			feesConfig:     GetFeesConfig(conf),
+		},
+	}, nil
+}
+
+// log config parameter names
+const (
+	LogName       = "log"
+	LogLevelName  = "level"
+	LogFormatName = "format"
+	LogOutputName = "output"
+)
+
+// Log config struct
+// Configuration for the level, format and output of service logs
+type LogConfig struct {
+	Level  string
+	Format string
+	Output string
+}
+
+// Return LogConfig from conf options
+// All Log Config fields are optional
+func GetLogConfig(conf []byte) LogConfig {
+	return LogConfig{
+		Level:  TryGetParamFromConf(LogName, LogLevelName, conf),
+		Format: TryGetParamFromConf(LogName, LogFormatName, conf),
+		Output: TryGetParamFromConf(LogName, LogOutputName, conf),
+	}
+}
+
+// tracing config parameter names
+const (
+	TracingName            = "tracing"
+	TracingEndpointName    = "endpoint"
+	TracingServiceNameName = "serviceName"
+)
+
+// Tracing config struct
+// Configuration for exporting attestation cycle traces to an OTLP http endpoint
+type TracingConfig struct {
+	Endpoint    string
+	ServiceName string
+}
+
+// Return TracingConfig from conf options
+// All Tracing Config fields are optional
+func GetTracingConfig(conf []byte) TracingConfig {
+	return TracingConfig{
+		Endpoint:    TryGetParamFromConf(TracingName, TracingEndpointName, conf),
+		ServiceName: TryGetParamFromConf(TracingName, TracingServiceNameName, conf),
+	}
+}
+
+// review config parameter names
+const (
+	ReviewName              = "review"
+	ReviewWindowMinutesName = "windowMinutes"
+)
+
+// Review config struct
+// Configuration for holding signed attestations for operator review before broadcast
+type ReviewConfig struct {
+	WindowMinutes int
+}
+
+// Return ReviewConfig from conf options
+// All Review Config fields are optional
+func GetReviewConfig(conf []byte) ReviewConfig {
+	windowStr := TryGetParamFromConf(ReviewName, ReviewWindowMinutesName, conf)
+	var window int
+	windowInt, windowIntErr := strconv.Atoi(windowStr)
+	if windowIntErr != nil {
+		window = -1
+	} else {
+		window = windowInt
+	}
+
+	return ReviewConfig{
+		WindowMinutes: window,
+	}
+}
+
+// webhook config parameter names
+const (
+	WebhookName        = "webhook"
+	WebhookUrlsName    = "urls"
+	WebhookSecretName  = "secret"
+	WebhookRetriesName = "retries"
+)
+
+// Webhook config struct
+// Configuration for notifying webhook urls of attestation events
+type WebhookConfig struct {
+	Urls    []string
+	Secret  string
+	Retries int
+}
+
+// Return WebhookConfig from conf options
+// All Webhook Config fields are optional
+func GetWebhookConfig(conf []byte) WebhookConfig {
+	var urls []string
+	urlsStr := TryGetParamFromConf(WebhookName, WebhookUrlsName, conf)
+	if urlsStr != "" {
+		urls = strings.Split(urlsStr, ",") // string to string slice
+		for i := range urls {              // trim whitespace
+			urls[i] = strings.TrimSpace(urls[i])
+		}
+	}
+
+	retriesStr := TryGetParamFromConf(WebhookName, WebhookRetriesName, conf)
+	var retries int
+	retriesInt, retriesIntErr := strconv.Atoi(retriesStr)
+	if retriesIntErr != nil {
+		retries = -1
+	} else {
+		retries = retriesInt
+	}
+
+	secret := TryGetParamFromConf(WebhookName, WebhookSecretName, conf)
+
+	return WebhookConfig{
+		Urls:    urls,
+		Secret:  secret,
+		Retries: retries,
+	}
+}
+
+// alert config parameter names
+const (
+	AlertName             = "alert"
+	AlertSlackUrlName     = "slackUrl"
+	AlertPagerDutyKeyName = "pagerDutyKey"
+	AlertSmtpHostName     = "smtpHost"
+	AlertSmtpUserName     = "smtpUser"
+	AlertSmtpPassName     = "smtpPass"
+	AlertSmtpFromName     = "smtpFrom"
+	AlertSmtpToName       = "smtpTo"
+	AlertDedupMinutesName = "dedupMinutes"
+)
+
+// Alert config struct
+// Configuration for alerting on attestation service failures
+// through slack, pagerduty and email channels
+type AlertConfig struct {
+	SlackUrl     string
+	PagerDutyKey string
+	SmtpHost     string
+	SmtpUser     string
+	SmtpPass     string
+	SmtpFrom     string
+	SmtpTo       []string
+	DedupMinutes int
+}
+
+// Return AlertConfig from conf options
+// All Alert Config fields are optional
+func GetAlertConfig(conf []byte) AlertConfig {
+	var smtpTo []string
+	smtpToStr := TryGetParamFromConf(AlertName, AlertSmtpToName, conf)
+	if smtpToStr != "" {
+		smtpTo = strings.Split(smtpToStr, ",") // string to string slice
+		for i := range smtpTo {                // trim whitespace
+			smtpTo[i] = strings.TrimSpace(smtpTo[i])
+		}
+	}
+
+	dedupStr := TryGetParamFromConf(AlertName, AlertDedupMinutesName, conf)
+	var dedup int
+	dedupInt, dedupIntErr := strconv.Atoi(dedupStr)
+	if dedupIntErr != nil {
+		dedup = -1
+	} else {
+		dedup = dedupInt
+	}
+
+	return AlertConfig{
+		SlackUrl:     TryGetParamFromConf(AlertName, AlertSlackUrlName, conf),
+		PagerDutyKey: TryGetParamFromConf(AlertName, AlertPagerDutyKeyName, conf),
+		SmtpHost:     TryGetParamFromConf(AlertName, AlertSmtpHostName, conf),
+		SmtpUser:     TryGetParamFromConf(AlertName, AlertSmtpUserName, conf),
+		SmtpPass:     TryGetParamFromConf(AlertName, AlertSmtpPassName, conf),
+		SmtpFrom:     TryGetParamFromConf(AlertName, AlertSmtpFromName, conf),
+		SmtpTo:       smtpTo,
+		DedupMinutes: dedup,
+	}
+}
+
+// quorum config parameter names
+// each quorum node is a separate category with the
+// same rpc connectivity parameter names as the main chain
+const (
+	QuorumName          = "quorum"
+	QuorumNodesName     = "nodes"
+	QuorumThresholdName = "threshold"
+)
+
+// Quorum config struct
+// Configuration for confirming attestations on additional independent nodes
+// Clients are ordered as the node category names
+type QuorumConfig struct {
+	Nodes     []string
+	Clients   []*rpcclient.Client
+	Threshold int
+}
+
+// Return QuorumConfig from conf options
+// If QuorumName exists in the config, rpc connectivity fields
+// of each of the quorum node categories are compulsory
+func GetQuorumConfig(conf []byte) (QuorumConfig, error) {
+	if _, cfgErr := getCfg(QuorumName, conf); cfgErr != nil {
+		return QuorumConfig{Threshold: -1}, nil
+	}
+
+	var nodes []string
+	var clients []*rpcclient.Client
+	nodesStr := TryGetParamFromConf(QuorumName, QuorumNodesName, conf)
+	if nodesStr != "" {
+		nodes = strings.Split(nodesStr, ",") // string to string slice
+		for i := range nodes {               // trim whitespace
+			nodes[i] = strings.TrimSpace(nodes[i])
+			client, rpcErr := GetRPC(nodes[i], conf)
+			if rpcErr != nil {
+				return QuorumConfig{}, rpcErr
+			}
+			clients = append(clients, client)
+		}
+	}
+
+	thresholdStr := TryGetParamFromConf(QuorumName, QuorumThresholdName, conf)
+	var threshold int
+	thresholdInt, thresholdIntErr := strconv.Atoi(thresholdStr)
+	if thresholdIntErr != nil {
+		threshold = -1
+	} else {
+		threshold = thresholdInt
+	}
+
+	return QuorumConfig{
+		Nodes:     nodes,
+		Clients:   clients,
+		Threshold: threshold,
+	}, nil
+}
+
+// events config parameter names
+const (
+	EventsName         = "events"
+	EventsRedisUrlName = "redisUrl"
+	EventsChannelName  = "channel"
+)
+
+// Events config struct
+// Configuration for distributing attestation events between
+// mainstay processes through a redis pub/sub channel
+type EventsConfig struct {
+	RedisUrl string
+	Channel  string
+}
+
+// Return EventsConfig from conf options
+// All Events Config fields are optional
+func GetEventsConfig(conf []byte) EventsConfig {
+	return EventsConfig{
+		RedisUrl: TryGetParamFromConf(EventsName, EventsRedisUrlName, conf),
+		Channel:  TryGetParamFromConf(EventsName, EventsChannelName, conf),
+	}
+}
+
+// esplora config parameter names
+const (
+	EsploraName    = "esplora"
+	EsploraUrlName = "url"
+)
+
+// Esplora config struct
+// Configuration for accessing the main chain through an Esplora http api,
+// e.g. a hosted indexer, instead of the main client rpc and wallet
+type EsploraConfig struct {
+	Url string
+}
+
+// Return EsploraConfig from conf options
+// All Esplora Config fields are optional
+func GetEsploraConfig(conf []byte) EsploraConfig {
+	return EsploraConfig{
+		Url: TryGetParamFromConf(EsploraName, EsploraUrlName, conf),
+	}
+}
+
+// throttle config parameter names
+const (
+	ThrottleName          = "throttle"
+	ThrottlePerSecondName = "perSecond"
+	ThrottlePerMinuteName = "perMinute"
+)
+
+// Throttle config struct
+// Configuration for rate limiting rpc calls to the main client node
+type ThrottleConfig struct {
+	PerSecond int
+	PerMinute int
+}
+
+// Return ThrottleConfig from conf options
+// All Throttle Config fields are optional
+func GetThrottleConfig(conf []byte) ThrottleConfig {
+	perSecondStr := TryGetParamFromConf(ThrottleName, ThrottlePerSecondName, conf)
+	var perSecond int
+	perSecondInt, perSecondIntErr := strconv.Atoi(perSecondStr)
+	if perSecondIntErr != nil {
+		perSecond = -1
+	} else {
+		perSecond = perSecondInt
+	}
+
+	perMinuteStr := TryGetParamFromConf(ThrottleName, ThrottlePerMinuteName, conf)
+	var perMinute int
+	perMinuteInt, perMinuteIntErr := strconv.Atoi(perMinuteStr)
+	if perMinuteIntErr != nil {
+		perMinute = -1
+	} else {
+		perMinute = perMinuteInt
+	}
+
+	return ThrottleConfig{
+		PerSecond: perSecond,
+		PerMinute: perMinute,
+	}
+}
+
+// rpc config parameter names
+const (
+	RpcName               = "rpc"
+	RpcTimeoutSecondsName = "timeoutSeconds"
+)
+
+// Rpc config struct
+// Configuration for the deadline of rpc calls to the main client node
+type RpcConfig struct {
+	TimeoutSeconds int
+}
+
+// Return RpcConfig from conf options
+// All Rpc Config fields are optional
+func GetRpcConfig(conf []byte) RpcConfig {
+	timeoutStr := TryGetParamFromConf(RpcName, RpcTimeoutSecondsName, conf)
+	var timeout int
+	timeoutInt, timeoutIntErr := strconv.Atoi(timeoutStr)
+	if timeoutIntErr != nil {
+		timeout = -1
+	} else {
+		timeout = timeoutInt
+	}
+
+	return RpcConfig{
+		TimeoutSeconds: timeout,
+	}
+}
+
+// zmq config parameter names
+const (
+	ZmqName         = "zmq"
+	ZmqRawBlockName = "rawblock"
+	ZmqHashTxName   = "hashtx"
+)
+
+// Zmq config struct
+// Addresses of the zmqpubrawblock and zmqpubhashtx notifications
+// of the main client node, e.g. tcp://127.0.0.1:28332
+type ZmqConfig struct {
+	RawBlock string
+	HashTx   string
+}
+
+// Return ZmqConfig from conf options
+// All Zmq Config fields are optional
+func GetZmqConfig(conf []byte) ZmqConfig {
+	return ZmqConfig{
+		RawBlock: TryGetParamFromConf(ZmqName, ZmqRawBlockName, conf),
+		HashTx:   TryGetParamFromConf(ZmqName, ZmqHashTxName, conf),
+	}
+}
+
+// aggregation config parameter names
+const (
+	AggregationName                = "aggregation"
+	AggregationSubtreeSizeName     = "subtreeSize"
+	AggregationIntervalSecondsName = "intervalSeconds"
+)
+
+// Aggregation config struct
+// Configuration for pre-aggregating client commitments into cached
+// merkle subtrees between attestation rounds
+type AggregationConfig struct {
+	SubtreeSize     int
+	IntervalSeconds int
+}
+
+// Return AggregationConfig from conf options
+// All Aggregation Config fields are optional
+// Aggregation is enabled by setting the subtree size
+func GetAggregationConfig(conf []byte) AggregationConfig {
+	subtreeSizeStr := TryGetParamFromConf(AggregationName, AggregationSubtreeSizeName, conf)
+	var subtreeSize int
+	subtreeSizeInt, subtreeSizeIntErr := strconv.Atoi(subtreeSizeStr)
+	if subtreeSizeIntErr != nil {
+		subtreeSize = -1
+	} else {
+		subtreeSize = subtreeSizeInt
+	}
+
+	intervalStr := TryGetParamFromConf(AggregationName, AggregationIntervalSecondsName, conf)
+	var interval int
+	intervalInt, intervalIntErr := strconv.Atoi(intervalStr)
+	if intervalIntErr != nil {
+		interval = -1
+	} else {
+		interval = intervalInt
+	}
+
+	return AggregationConfig{
+		SubtreeSize:     subtreeSize,
+		IntervalSeconds: interval,
+	}
+}
+
+// indexer config parameter names
+const (
+	IndexerName                = "indexer"
+	IndexerIntervalSecondsName = "intervalSeconds"
+	IndexerConfirmationsName   = "confirmations"
+)
+
+// Indexer config struct
+// Configuration for indexing the staychain transactions from the main chain
+type IndexerConfig struct {
+	IntervalSeconds int
+	Confirmations   int
+}
+
+// Return IndexerConfig from conf options
+// All Indexer Config fields are optional
+// Indexing is enabled by setting the interval
+func GetIndexerConfig(conf []byte) IndexerConfig {
+	intervalStr := TryGetParamFromConf(IndexerName, IndexerIntervalSecondsName, conf)
+	var interval int
+	intervalInt, intervalIntErr := strconv.Atoi(intervalStr)
+	if intervalIntErr != nil {
+		interval = -1
+	} else {
+		interval = intervalInt
+	}
+
+	confirmationsStr := TryGetParamFromConf(IndexerName, IndexerConfirmationsName, conf)
+	var confirmations int
+	confirmationsInt, confirmationsIntErr := strconv.Atoi(confirmationsStr)
+	if confirmationsIntErr != nil {
+		confirmations = -1
+	} else {
+		confirmations = confirmationsInt
+	}
+
+	return IndexerConfig{
+		IntervalSeconds: interval,
+		Confirmations:   confirmations,
+	}
+}
+
+// sidechain config parameter names
+const (
+	SidechainName                = "sidechain"
+	SidechainChainsName          = "chains"
+	SidechainIntervalSecondsName = "intervalSeconds"
+)
+
+// Sidechain config struct
+// Configuration for committing the latest block hash of sidechains
+// to the client slots of the chains
+type SidechainConfig struct {
+	Chains          []SidechainChain
+	IntervalSeconds int
+}
+
+// Sidechain committed to a client slot
+// The rpc connection of the chain is the config category of its name
+type SidechainChain struct {
+	Name     string
+	Position int32
+}
+
+// Return SidechainConfig from conf options
+// All Sidechain Config fields are optional
+// Chains are comma separated name:position entries, with the position
+// of invalid entries set to -1 and rejected by the sidechain fetcher
+func GetSidechainConfig(conf []byte) SidechainConfig {
+	var chains []SidechainChain
+	chainsStr := TryGetParamFromConf(SidechainName, SidechainChainsName, conf)
+	if chainsStr != "" {
+		for _, chainStr := range strings.Split(chainsStr, ",") {
+			chain := SidechainChain{Position: -1}
+			if name, positionStr, found := strings.Cut(strings.TrimSpace(chainStr), ":"); found {
+				chain.Name = strings.TrimSpace(name)
+				if position, positionErr := strconv.ParseInt(strings.TrimSpace(positionStr), 10, 32); positionErr == nil && position >= 0 {
+					chain.Position = int32(position)
+				}
+			} else {
+				chain.Name = strings.TrimSpace(chainStr)
+			}
+			chains = append(chains, chain)
+		}
+	}
+
+	intervalStr := TryGetParamFromConf(SidechainName, SidechainIntervalSecondsName, conf)
+	var interval int
+	intervalInt, intervalIntErr := strconv.Atoi(intervalStr)
+	if intervalIntErr != nil {
+		interval = -1
+	} else {
+		interval = intervalInt
+	}
+
+	return SidechainConfig{
+		Chains:          chains,
+		IntervalSeconds: interval,
+	}
+}
+
+// Return rpc clients of the sidechains by name from the rpc
+// connection of the config category of each chain name
+// Invalid chain entries are skipped and rejected by the sidechain fetcher
+func GetSidechainClients(sidechainConfig SidechainConfig, conf []byte) (map[string]clients.SidechainClient, error) {
+	sidechainClients := make(map[string]clients.SidechainClient)
+	for _, chain := range sidechainConfig.Chains {
+		if _, ok := sidechainClients[chain.Name]; ok || chain.Name == "" || chain.Position < 0 {
+			continue
+		}
+		rpc, rpcErr := GetRPC(chain.Name, conf)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		sidechainClients[chain.Name] = clients.NewSidechainClientOcean(rpc)
+	}
+	return sidechainClients, nil
+}
+
+// pull config parameter names
+const (
+	PullName                = "pull"
+	PullSourcesName         = "sources"
+	PullIntervalSecondsName = "intervalSeconds"
+
+	PullSourceTypeName    = "type"
+	PullSourceUrlName     = "url"
+	PullSourceTokenName   = "token"
+	PullSourcePathName    = "path"
+	PullSourcePayloadName = "payload"
+	PullSourceMethodName  = "method"
+)
+
+// Pull config struct
+// Configuration for pulling the latest commitments of client slots
+// from commitment sources instead of clients submitting them
+type PullConfig struct {
+	Sources         []PullSource
+	IntervalSeconds int
+}
+
+// Commitment source of a client slot
+// The source is configured by the config category of its name, with the
+// type of the source and the url, file path or rpc connection pulled from
+type PullSource struct {
+	Name     string
+	Position int32
+	Type     string
+	Url      string
+	Token    string
+	Path     string
+	Payload  string
+	Method   string
+	RpcUrl   string
+	RpcUser  string
+	RpcPass  string
+}
+
+// Return PullConfig from conf options
+// All Pull Config fields are optional
+// Sources are comma separated name:position entries, with the position
+// of invalid entries set to -1 and rejected by the source puller
+func GetPullConfig(conf []byte) PullConfig {
+	var sources []PullSource
+	sourcesStr := TryGetParamFromConf(PullName, PullSourcesName, conf)
+	if sourcesStr != "" {
+		for _, sourceStr := range strings.Split(sourcesStr, ",") {
+			source := PullSource{Position: -1}
+			if name, positionStr, found := strings.Cut(strings.TrimSpace(sourceStr), ":"); found {
+				source.Name = strings.TrimSpace(name)
+				if position, positionErr := strconv.ParseInt(strings.TrimSpace(positionStr), 10, 32); positionErr == nil && position >= 0 {
+					source.Position = int32(position)
+				}
+			} else {
+				source.Name = strings.TrimSpace(sourceStr)
+			}
+			if source.Name != "" {
+				source.Type = TryGetParamFromConf(source.Name, PullSourceTypeName, conf)
+				source.Url = TryGetParamFromConf(source.Name, PullSourceUrlName, conf)
+				source.Token = TryGetParamFromConf(source.Name, PullSourceTokenName, conf)
+				source.Path = TryGetParamFromConf(source.Name, PullSourcePathName, conf)
+				source.Payload = TryGetParamFromConf(source.Name, PullSourcePayloadName, conf)
+				source.Method = TryGetParamFromConf(source.Name, PullSourceMethodName, conf)
+				source.RpcUrl = TryGetParamFromConf(source.Name, RpcClientUrlName, conf)
+				source.RpcUser = TryGetParamFromConf(source.Name, RpcClientUserName, conf)
+				source.RpcPass = TryGetParamFromConf(source.Name, RpcClientPassName, conf)
+			}
+			sources = append(sources, source)
+		}
+	}
+
+	intervalStr := TryGetParamFromConf(PullName, PullIntervalSecondsName, conf)
+	var interval int
+	intervalInt, intervalIntErr := strconv.Atoi(intervalStr)
+	if intervalIntErr != nil {
+		interval = -1
+	} else {
+		interval = intervalInt
+	}
+
+	return PullConfig{
+		Sources:         sources,
+		IntervalSeconds: interval,
+	}
+}
+
+// ordering config parameter names
+const (
+	OrderingName          = "ordering"
+	OrderingStrategyName  = "strategy"
+	OrderingPositionsName = "positions"
+	OrderingDepthName     = "depth"
+	OrderingSlotIdsName   = "slotIds"
+	OrderingSizeName      = "size"
+
+	ErrorOrderingEntry = "invalid ordering entry - expected position:value"
+)
+
+// Ordering config struct
+// Configuration of the ordering of the client commitments in the commitment
+// merkle tree of the staychain, with the leaf of each client position for
+// the map strategy, the tree depth and slot id of each client position
+// for the sparse strategy and the number of leaves of padded merkle trees
+type OrderingConfig struct {
+	Strategy  string
+	Positions map[int32]int32
+	Depth     int
+	SlotIds   map[int32]string
+	Size      int
+}
+
+// Return OrderingConfig from conf options
+// All Ordering Config fields are optional
+// Positions and slot ids are comma separated position:value entries
+func GetOrderingConfig(conf []byte) (OrderingConfig, error) {
+	positionsStr := TryGetParamFromConf(OrderingName, OrderingPositionsName, conf)
+	positionEntries, positionsErr := parseOrderingEntries(positionsStr)
+	if positionsErr != nil {
+		return OrderingConfig{}, positionsErr
+	}
+	var positions map[int32]int32
+	for position, leafStr := range positionEntries {
+		leaf, leafErr := strconv.ParseInt(leafStr, 10, 32)
+		if leafErr != nil {
+			return OrderingConfig{}, errors.New(fmt.Sprintf("%s: %d:%s", ErrorOrderingEntry, position, leafStr))
+		}
+		if positions == nil {
+			positions = make(map[int32]int32)
+		}
+		positions[position] = int32(leaf)
+	}
+
+	slotIdsStr := TryGetParamFromConf(OrderingName, OrderingSlotIdsName, conf)
+	slotIds, slotIdsErr := parseOrderingEntries(slotIdsStr)
+	if slotIdsErr != nil {
+		return OrderingConfig{}, slotIdsErr
+	}
+
+	depthStr := TryGetParamFromConf(OrderingName, OrderingDepthName, conf)
+	var depth int
+	depthInt, depthIntErr := strconv.Atoi(depthStr)
+	if depthIntErr != nil {
+		depth = -1
+	} else {
+		depth = depthInt
+	}
+
+	sizeStr := TryGetParamFromConf(OrderingName, OrderingSizeName, conf)
+	var size int
+	sizeInt, sizeIntErr := strconv.Atoi(sizeStr)
+	if sizeIntErr != nil {
+		size = -1
+	} else {
+		size = sizeInt
+	}
+
+	return OrderingConfig{
+		Strategy:  TryGetParamFromConf(OrderingName, OrderingStrategyName, conf),
+		Positions: positions,
+		Depth:     depth,
+		SlotIds:   slotIds,
+		Size:      size,
+	}, nil
+}
+
+// Parse comma separated position:value ordering entries
+func parseOrderingEntries(entriesStr string) (map[int32]string, error) {
+	if entriesStr == "" {
+		return nil, nil
+	}
+	entries := make(map[int32]string)
+	for _, entry := range strings.Split(entriesStr, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 2)
+		if len(parts) != 2 {
+			return nil, errors.New(fmt.Sprintf("%s: %s", ErrorOrderingEntry, entry))
+		}
+		position, positionErr := strconv.ParseInt(strings.TrimSpace(parts[0]), 10, 32)
+		if positionErr != nil || position < 0 {
+			return nil, errors.New(fmt.Sprintf("%s: %s", ErrorOrderingEntry, entry))
+		}
+		entries[int32(position)] = strings.TrimSpace(parts[1])
+	}
+	return entries, nil
+}
+
+// merkle config parameter names
+const (
+	MerkleName     = "merkle"
+	MerkleHashName = "hash"
+)
+
+// Merkle config struct
+// Configuration of the hash function of new commitment merkle trees
+type MerkleConfig struct {
+	Hash string
+}
+
+// Return MerkleConfig from conf options
+// All Merkle Config fields are optional
+func GetMerkleConfig(conf []byte) MerkleConfig {
+	return MerkleConfig{
+		Hash: TryGetParamFromConf(MerkleName, MerkleHashName, conf),
+	}
+}
+
+// transition config parameter names
+const (
+	TransitionName           = "transition"
+	TransitionHashName       = "hash"
+	TransitionSwitchoverName = "switchoverRound"
+	TransitionRoundsName     = "rounds"
+)
+
+// Transition config struct
+// Configuration of the transition to the next hash function of commitment
+// merkle trees at the switchover round, with dual running of proofs in both
+// formats for the rounds before it
+type TransitionConfig struct {
+	Hash            string
+	SwitchoverRound int64
+	Rounds          int64
+}
+
+// Return TransitionConfig from conf options
+// All Transition Config fields are optional
+func GetTransitionConfig(conf []byte) TransitionConfig {
+	switchoverStr := TryGetParamFromConf(TransitionName, TransitionSwitchoverName, conf)
+	var switchover int64
+	switchoverInt, switchoverIntErr := strconv.ParseInt(switchoverStr, 10, 64)
+	if switchoverIntErr != nil {
+		switchover = -1
+	} else {
+		switchover = switchoverInt
+	}
+
+	roundsStr := TryGetParamFromConf(TransitionName, TransitionRoundsName, conf)
+	var rounds int64
+	roundsInt, roundsIntErr := strconv.ParseInt(roundsStr, 10, 64)
+	if roundsIntErr != nil {
+		rounds = -1
+	} else {
+		rounds = roundsInt
+	}
+
+	return TransitionConfig{
+		Hash:            TryGetParamFromConf(TransitionName, TransitionHashName, conf),
+		SwitchoverRound: switchover,
+		Rounds:          rounds,
+	}
+}
+
+// kafka config parameter names
+const (
+	KafkaName              = "kafka"
+	KafkaBrokersName       = "brokers"
+	KafkaTopicName         = "topic"
+	KafkaGroupName         = "group"
+	KafkaTlsName           = "tls"
+	KafkaTlsCaFileName     = "tlsCaFile"
+	KafkaTlsCertFileName   = "tlsCertFile"
+	KafkaTlsKeyFileName    = "tlsKeyFile"
+	KafkaSaslMechanismName = "saslMechanism"
+	KafkaSaslUserName      = "saslUser"
+	KafkaSaslPasswordName  = "saslPassword"
+	KafkaPayloadName       = "payload"
+)
+
+// Kafka config struct
+// Configuration for ingesting client commitments from a kafka topic
+type KafkaConfig struct {
+	Brokers       []string
+	Topic         string
+	Group         string
+	Tls           bool
+	TlsCaFile     string
+	TlsCertFile   string
+	TlsKeyFile    string
+	SaslMechanism string
+	SaslUser      string
+	SaslPassword  string
+	Payload       string
+}
+
+// Return KafkaConfig from conf options
+// All Kafka Config fields are optional
+// TLS is enabled by the tls flag or any of the tls files being set
+func GetKafkaConfig(conf []byte) KafkaConfig {
+	var brokers []string
+	if brokersStr := TryGetParamFromConf(KafkaName, KafkaBrokersName, conf); brokersStr != "" {
+		brokers = strings.Split(brokersStr, ",") // string to string slice
+		for i := range brokers {                 // trim whitespace
+			brokers[i] = strings.TrimSpace(brokers[i])
+		}
+	}
+	tlsCaFile := TryGetParamFromConf(KafkaName, KafkaTlsCaFileName, conf)
+	tlsCertFile := TryGetParamFromConf(KafkaName, KafkaTlsCertFileName, conf)
+	tlsKeyFile := TryGetParamFromConf(KafkaName, KafkaTlsKeyFileName, conf)
+	tlsStr := TryGetParamFromConf(KafkaName, KafkaTlsName, conf)
+
+	return KafkaConfig{
+		Brokers:       brokers,
+		Topic:         TryGetParamFromConf(KafkaName, KafkaTopicName, conf),
+		Group:         TryGetParamFromConf(KafkaName, KafkaGroupName, conf),
+		Tls:           tlsStr == "1" || tlsCaFile != "" || tlsCertFile != "" || tlsKeyFile != "",
+		TlsCaFile:     tlsCaFile,
+		TlsCertFile:   tlsCertFile,
+		TlsKeyFile:    tlsKeyFile,
+		SaslMechanism: TryGetParamFromConf(KafkaName, KafkaSaslMechanismName, conf),
+		SaslUser:      TryGetParamFromConf(KafkaName, KafkaSaslUserName, conf),
+		SaslPassword:  TryGetParamFromConf(KafkaName, KafkaSaslPasswordName, conf),
+		Payload:       TryGetParamFromConf(KafkaName, KafkaPayloadName, conf),
+	}
+}
+
+// tsa config parameter names
+const (
+	TsaName         = "tsa"
+	TsaKeyFileName  = "keyFile"
+	TsaCertFileName = "certFile"
+	TsaPolicyName   = "policy"
+)
+
+// Tsa config struct
+// Configuration of the timestamp authority signing RFC 3161
+// timestamp tokens of confirmed proofs
+type TsaConfig struct {
+	KeyFile  string
+	CertFile string
+	Policy   string
+}
+
+// Return TsaConfig from conf options
+// All Tsa Config fields are optional
+// Tokens are self-signed if no key and certificate files are set
+func GetTsaConfig(conf []byte) TsaConfig {
+	return TsaConfig{
+		KeyFile:  TryGetParamFromConf(TsaName, TsaKeyFileName, conf),
+		CertFile: TryGetParamFromConf(TsaName, TsaCertFileName, conf),
+		Policy:   TryGetParamFromConf(TsaName, TsaPolicyName, conf),
+	}
+}
+
+// delivery config parameter names
+const (
+	DeliveryName                   = "delivery"
+	DeliveryS3EndpointName         = "s3Endpoint"
+	DeliveryS3RegionName           = "s3Region"
+	DeliveryS3AccessKeyName        = "s3AccessKey"
+	DeliveryS3SecretKeyName        = "s3SecretKey"
+	DeliverySftpUserName           = "sftpUser"
+	DeliverySftpKeyFileName        = "sftpKeyFile"
+	DeliverySftpKnownHostsFileName = "sftpKnownHostsFile"
+	DeliveryRetriesName            = "retries"
+)
+
+// Delivery config struct
+// Configuration of the object storage and sftp targets that confirmed
+// proofs are pushed to for clients that registered a delivery target
+type DeliveryConfig struct {
+	S3Endpoint         string
+	S3Region           string
+	S3AccessKey        string
+	S3SecretKey        string
+	SftpUser           string
+	SftpKeyFile        string
+	SftpKnownHostsFile string
+	Retries            int
+}
+
+// Return DeliveryConfig from conf options
+// All Delivery Config fields are optional
+func GetDeliveryConfig(conf []byte) DeliveryConfig {
+	retriesStr := TryGetParamFromConf(DeliveryName, DeliveryRetriesName, conf)
+	var retries int
+	retriesInt, retriesIntErr := strconv.Atoi(retriesStr)
+	if retriesIntErr != nil {
+		retries = -1
+	} else {
+		retries = retriesInt
+	}
+
+	return DeliveryConfig{
+		S3Endpoint:         TryGetParamFromConf(DeliveryName, DeliveryS3EndpointName, conf),
+		S3Region:           TryGetParamFromConf(DeliveryName, DeliveryS3RegionName, conf),
+		S3AccessKey:        TryGetParamFromConf(DeliveryName, DeliveryS3AccessKeyName, conf),
+		S3SecretKey:        TryGetParamFromConf(DeliveryName, DeliveryS3SecretKeyName, conf),
+		SftpUser:           TryGetParamFromConf(DeliveryName, DeliverySftpUserName, conf),
+		SftpKeyFile:        TryGetParamFromConf(DeliveryName, DeliverySftpKeyFileName, conf),
+		SftpKnownHostsFile: TryGetParamFromConf(DeliveryName, DeliverySftpKnownHostsFileName, conf),
+		Retries:            retries,
+	}
+}
+
+// wallet config parameter names
+const (
+	WalletName               = "wallet"
+	WalletPassphraseName     = "passphrase"
+	WalletPassphraseFileName = "passphraseFile"
+	WalletUnlockSecondsName  = "unlockSeconds"
+	WalletWatchOnlyName      = "watchOnly"
+)
+
+// Wallet config struct
+// Configuration for unlocking an encrypted bitcoind wallet
+// or for a watch-only wallet holding no private keys
+type WalletConfig struct {
+	Passphrase    string
+	UnlockSeconds int
+	WatchOnly     bool
+}
+
+// Return WalletConfig from conf options
+// All Wallet Config fields are optional. The passphrase is read from
+// the passphrase file if set, e.g. a secret mounted from a KMS
+func GetWalletConfig(conf []byte) (WalletConfig, error) {
+	passphrase := TryGetParamFromConf(WalletName, WalletPassphraseName, conf)
+	passphraseFile := TryGetParamFromConf(WalletName, WalletPassphraseFileName, conf)
+	if passphraseFile != "" {
+		passphraseBytes, readErr := ioutil.ReadFile(passphraseFile)
+		if readErr != nil {
+			return WalletConfig{}, readErr
+		}
+		passphrase = strings.TrimSpace(string(passphraseBytes))
+	}
+
+	unlockStr := TryGetParamFromConf(WalletName, WalletUnlockSecondsName, conf)
+	var unlock int
+	unlockInt, unlockIntErr := strconv.Atoi(unlockStr)
+	if unlockIntErr != nil {
+		unlock = -1
+	} else {
+		unlock = unlockInt
+	}
+
+	watchOnlyStr := TryGetParamFromConf(WalletName, WalletWatchOnlyName, conf)
+
+	return WalletConfig{
+		Passphrase:    passphrase,
+		UnlockSeconds: unlock,
+		WatchOnly:     watchOnlyStr == "1",
+	}, nil
+}
+
+// secrets config parameter names
+const (
+	SecretsName           = "secrets"
+	SecretsVaultAddrName  = "vaultAddr"
+	SecretsVaultTokenName = "vaultToken"
+	SecretsAwsRegionName  = "awsRegion"
+)
+
+// Secrets config struct
+// Configuration of the secrets backends that secret references in the
+// conf are fetched from. Unset values fall back to the standard env
+// variables of each backend, e.g. VAULT_ADDR and AWS_REGION
+type SecretsConfig struct {
+	VaultAddr  string
+	VaultToken string
+	AwsRegion  string
+}
+
+// Return SecretsConfig from conf options
+// All Secrets Config fields are optional
+func GetSecretsConfig(conf []byte) SecretsConfig {
+	return SecretsConfig{
+		VaultAddr:  TryGetParamFromConf(SecretsName, SecretsVaultAddrName, conf),
+		VaultToken: TryGetParamFromConf(SecretsName, SecretsVaultTokenName, conf),
+		AwsRegion:  TryGetParamFromConf(SecretsName, SecretsAwsRegionName, conf),
+	}
+}
+
+// daemon config parameter names
+const (
+	DaemonName        = "daemon"
+	DaemonPidFileName = "pidFile"
+)
+
+// Daemon config struct
+// Process management options of the mainstay daemon
+type DaemonConfig struct {
+	PidFile string
+}
+
+// Return DaemonConfig from conf options
+// All Daemon Config fields are optional
+func GetDaemonConfig(conf []byte) DaemonConfig {
+	return DaemonConfig{
+		PidFile: TryGetParamFromConf(DaemonName, DaemonPidFileName, conf),
+	}
+}
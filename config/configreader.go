@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/rpcclient"
@@ -27,17 +28,38 @@ const (
 	ErrorBadDataClientChain = "invalid value for client chain. 'main', 'testnet' and 'regtest' allowed only"
 )
 
-// Get default conf from local file
+// Get conf from local file
+// YAML and TOML conf files are converted to JSON conf, detected
+// by the file extension. Files of any other extension are JSON
 func GetConfFile(filepath string) ([]byte, error) {
 	conf, err := ioutil.ReadFile(filepath)
 	if err != nil {
 		return []byte{}, err
 	}
-	return conf, nil
+	format, formatErr := GetConfFormat(filepath)
+	if formatErr == nil {
+		var parseErr error
+		if conf, parseErr = ParseConf(conf, format); parseErr != nil {
+			return nil, parseErr
+		}
+	}
+	return ResolveSecrets(conf)
 }
 
 // Get RPC connection for a client name from a conf file
+// If several urls are listed the connection to the first url is returned
 func GetRPC(name string, conf []byte) (*rpcclient.Client, error) {
+	clients, clientsErr := GetRPCs(name, conf)
+	if clientsErr != nil {
+		return nil, clientsErr
+	}
+	return clients[0], nil
+}
+
+// Get RPC connections for a client name from a conf file
+// The client url value may be a comma separated list of urls
+// sharing the same rpc user and password
+func GetRPCs(name string, conf []byte) ([]*rpcclient.Client, error) {
 	// get client from config
 	cfg, cfgErr := getCfg(name, conf)
 	if cfgErr != nil {
@@ -74,18 +96,32 @@ func GetRPC(name string, conf []byte) (*rpcclient.Client, error) {
 		pass = passValue
 	}
 
-	connCfg := &rpcclient.ConnConfig{
-		Host:         host,
-		User:         user,
-		Pass:         pass,
-		HTTPPostMode: true,
-		DisableTLS:   true,
+	var hosts []string
+	for _, hostUrl := range strings.Split(host, ",") {
+		if hostUrl = strings.TrimSpace(hostUrl); hostUrl != "" {
+			hosts = append(hosts, hostUrl)
+		}
+	}
+	if len(hosts) == 0 {
+		hosts = []string{host}
 	}
-	client, rpcErr := rpcclient.New(connCfg, nil)
-	if rpcErr != nil {
-		return nil, errors.New(fmt.Sprintf("%s: %s", rpcErr, ErrorRpcConnectionFailure))
+
+	var clients []*rpcclient.Client
+	for _, hostUrl := range hosts {
+		connCfg := &rpcclient.ConnConfig{
+			Host:         hostUrl,
+			User:         user,
+			Pass:         pass,
+			HTTPPostMode: true,
+			DisableTLS:   true,
+		}
+		client, rpcErr := rpcclient.New(connCfg, nil)
+		if rpcErr != nil {
+			return nil, errors.New(fmt.Sprintf("%s: %s", rpcErr, ErrorRpcConnectionFailure))
+		}
+		clients = append(clients, client)
 	}
-	return client, nil
+	return clients, nil
 }
 
 // Chain configuration parameters from btcsuite for main bitcoin client only
@@ -2,6 +2,5 @@
 Package config handles reading conf files and establishing client RPC connections.
 
 Also provides general configuration for various functions of the attestation service.
-
 */
 package config
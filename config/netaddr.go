@@ -0,0 +1,39 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Host addresses in config, e.g. rpcurl or the db host, can be hostnames,
+// resolving to A and/or AAAA records, IPv4 literals or IPv6 literals. In
+// host:port addresses IPv6 literals must be bracketed, e.g. [fd00::1]:18443,
+// while hosts without port accept IPv6 literals with or without brackets
+
+// error consts
+const (
+	ErrorHostPortInvalid = "Invalid host:port address - IPv6 literals must be bracketed"
+)
+
+// Split host:port address into host, without brackets, and port
+func SplitHostPort(addr string) (string, string, error) {
+	host, port, splitErr := net.SplitHostPort(addr)
+	if splitErr != nil || host == "" || port == "" {
+		return "", "", errors.New(fmt.Sprintf("%s: %s", ErrorHostPortInvalid, addr))
+	}
+	return host, port, nil
+}
+
+// Return host without the brackets of bracketed IPv6 literals
+func NormalizeHost(host string) string {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		return host[1 : len(host)-1]
+	}
+	return host
+}
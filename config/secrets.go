@@ -0,0 +1,190 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Credentials such as rpcpass, the db password and the staychain private keys
+// can be fetched from a secrets backend instead of being stored in plaintext.
+// A conf value, or the env variable it names, set to a secret reference
+//
+//	<scheme>:<path>[#<key>]
+//
+// is replaced on reading the conf by the secret fetched from the backend of
+// the scheme, selecting key from secrets stored as json objects:
+//
+//	vault:secret/data/mainstay#rpcpass            Vault kv secret at path
+//	aws-sm:mainstay/prod#dbpassword               AWS Secrets Manager secret id
+//	gcp-sm:projects/p/secrets/topup-pk            GCP Secret Manager secret version
+
+// secret reference schemes
+const (
+	SecretSchemeVault = "vault"
+	SecretSchemeAws   = "aws-sm"
+	SecretSchemeGcp   = "gcp-sm"
+)
+
+// secrets consts
+const (
+	SecretsTimeout = 10 * time.Second // timeout of secret backend requests
+
+	ErrorSecretFetch          = "Could not fetch secret"
+	ErrorSecretRefInvalid     = "Invalid secret reference"
+	ErrorSecretKeyNotFound    = "Secret key not found"
+	ErrorSecretProviderConfig = "Secret provider not configured"
+	ErrorSecretResponse       = "Invalid secret backend response"
+)
+
+// SecretProvider interface
+// Secrets backend fetching the secret stored at path
+type SecretProvider interface {
+	GetSecret(path string) (string, error)
+}
+
+// Return secret provider for scheme from secrets config
+func newSecretProvider(scheme string, secretsConfig SecretsConfig) (SecretProvider, error) {
+	switch scheme {
+	case SecretSchemeVault:
+		return newVaultProvider(secretsConfig)
+	case SecretSchemeAws:
+		return newAwsProvider(secretsConfig)
+	case SecretSchemeGcp:
+		return newGcpProvider(secretsConfig)
+	}
+	return nil, errors.New(fmt.Sprintf("%s: unknown scheme %s", ErrorSecretRefInvalid, scheme))
+}
+
+// Return scheme, path and key of secret reference and whether value is one
+func parseSecretRef(value string) (string, string, string, bool) {
+	schemeEnd := strings.Index(value, ":")
+	if schemeEnd < 0 {
+		return "", "", "", false
+	}
+	scheme := value[:schemeEnd]
+	if scheme != SecretSchemeVault && scheme != SecretSchemeAws && scheme != SecretSchemeGcp {
+		return "", "", "", false
+	}
+	path, key := value[schemeEnd+1:], ""
+	if keyStart := strings.LastIndex(path, "#"); keyStart >= 0 {
+		path, key = path[:keyStart], path[keyStart+1:]
+	}
+	return scheme, path, key, true
+}
+
+// Return key of secret stored as json object or the secret itself if no key
+func selectSecretKey(secret string, key string) (string, error) {
+	if key == "" {
+		return secret, nil
+	}
+	var values map[string]interface{}
+	if jsonErr := json.Unmarshal([]byte(secret), &values); jsonErr != nil {
+		return "", errors.New(fmt.Sprintf("%s: %s", ErrorSecretKeyNotFound, key))
+	}
+	switch value := values[key].(type) {
+	case string:
+		return value, nil
+	case float64, bool:
+		return fmt.Sprint(value), nil
+	}
+	return "", errors.New(fmt.Sprintf("%s: %s", ErrorSecretKeyNotFound, key))
+}
+
+// Replace secret references in conf values with the secrets fetched
+// Conf that contains no secret references is returned unchanged
+func ResolveSecrets(conf []byte) ([]byte, error) {
+	var categories map[string]map[string]interface{}
+	if jsonErr := json.Unmarshal(conf, &categories); jsonErr != nil {
+		return conf, nil // conf errors are reported when reading options
+	}
+
+	secretsConfig := GetSecretsConfig(conf)
+	providers := make(map[string]SecretProvider)
+	resolved := false
+	for _, name := range sortedCategoryNames(categories) {
+		if name == SecretsName {
+			continue
+		}
+		for _, key := range sortedKeys(categories[name]) {
+			value, isStr := categories[name][key].(string)
+			if !isStr || value == "" {
+				continue
+			}
+			if envValue := os.Getenv(value); envValue != "" {
+				value = envValue
+			}
+			scheme, path, secretKey, isRef := parseSecretRef(value)
+			if !isRef {
+				continue
+			}
+
+			provider, ok := providers[scheme]
+			if !ok {
+				var providerErr error
+				if provider, providerErr = newSecretProvider(scheme, secretsConfig); providerErr != nil {
+					return nil, errors.New(fmt.Sprintf("%s %s.%s: %v", ErrorSecretFetch, name, key, providerErr))
+				}
+				providers[scheme] = provider
+			}
+			secret, secretErr := provider.GetSecret(path)
+			if secretErr == nil {
+				secret, secretErr = selectSecretKey(secret, secretKey)
+			}
+			if secretErr != nil {
+				return nil, errors.New(fmt.Sprintf("%s %s.%s: %v", ErrorSecretFetch, name, key, secretErr))
+			}
+			categories[name][key] = secret
+			resolved = true
+		}
+	}
+	if !resolved {
+		return conf, nil
+	}
+	return json.Marshal(categories)
+}
+
+// Return sorted names of conf categories
+func sortedCategoryNames(categories map[string]map[string]interface{}) []string {
+	names := make([]string, 0, len(categories))
+	for name := range categories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Return env variable value of the first env variable set
+func getenvFirst(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// Send secret backend request and decode json response into result
+func doSecretRequest(client *http.Client, req *http.Request, result interface{}) error {
+	resp, respErr := client.Do(req)
+	if respErr != nil {
+		return respErr
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(fmt.Sprintf("%s: status %d", ErrorSecretResponse, resp.StatusCode))
+	}
+	if decodeErr := json.NewDecoder(resp.Body).Decode(result); decodeErr != nil {
+		return errors.New(fmt.Sprintf("%s: %v", ErrorSecretResponse, decodeErr))
+	}
+	return nil
+}
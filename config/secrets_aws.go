@@ -0,0 +1,160 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	b64 "encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// aws provider consts
+const (
+	AwsSecretsManagerService = "secretsmanager"
+	AwsSigningAlgorithm      = "AWS4-HMAC-SHA256"
+	AwsDateFormat            = "20060102T150405Z"
+)
+
+// aws credentials used for request signing
+type awsCredentials struct {
+	accessKeyId     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// awsProvider struct
+// Fetches secrets from AWS Secrets Manager with requests signed using
+// the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and optional
+// AWS_SESSION_TOKEN credentials of the env
+type awsProvider struct {
+	region      string
+	url         string
+	credentials awsCredentials
+	client      *http.Client
+	now         func() time.Time
+}
+
+// Return new aws provider for the region of secrets config
+// or the AWS_REGION or AWS_DEFAULT_REGION env variables
+func newAwsProvider(secretsConfig SecretsConfig) (*awsProvider, error) {
+	region := secretsConfig.AwsRegion
+	if region == "" {
+		region = getenvFirst("AWS_REGION", "AWS_DEFAULT_REGION")
+	}
+	credentials := awsCredentials{
+		accessKeyId:     getenvFirst("AWS_ACCESS_KEY_ID"),
+		secretAccessKey: getenvFirst("AWS_SECRET_ACCESS_KEY"),
+		sessionToken:    getenvFirst("AWS_SESSION_TOKEN"),
+	}
+	if region == "" || credentials.accessKeyId == "" || credentials.secretAccessKey == "" {
+		return nil, errors.New(fmt.Sprintf("%s: %s", ErrorSecretProviderConfig, SecretSchemeAws))
+	}
+	return &awsProvider{
+		region:      region,
+		url:         fmt.Sprintf("https://%s.%s.amazonaws.com/", AwsSecretsManagerService, region),
+		credentials: credentials,
+		client:      &http.Client{Timeout: SecretsTimeout},
+		now:         time.Now,
+	}, nil
+}
+
+// Return secret string, or decoded secret binary, of secret id
+func (p *awsProvider) GetSecret(secretId string) (string, error) {
+	body, _ := json.Marshal(map[string]string{"SecretId": secretId})
+	req, reqErr := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(body))
+	if reqErr != nil {
+		return "", reqErr
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signAwsRequest(req, body, p.credentials, p.region, AwsSecretsManagerService, p.now())
+
+	var result struct {
+		SecretString string `json:"SecretString"`
+		SecretBinary string `json:"SecretBinary"`
+	}
+	if respErr := doSecretRequest(p.client, req, &result); respErr != nil {
+		return "", respErr
+	}
+	if result.SecretString == "" && result.SecretBinary != "" {
+		secret, decodeErr := b64.StdEncoding.DecodeString(result.SecretBinary)
+		if decodeErr != nil {
+			return "", errors.New(fmt.Sprintf("%s: %v", ErrorSecretResponse, decodeErr))
+		}
+		return string(secret), nil
+	}
+	return result.SecretString, nil
+}
+
+// Return hmac sha256 of data with key
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// Return hex encoded sha256 of data
+func sha256Hex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// Sign request with AWS signature version 4, setting the date, session
+// token and authorization headers. All headers set are signed
+func signAwsRequest(req *http.Request, body []byte, credentials awsCredentials,
+	region string, service string, now time.Time) {
+
+	amzDate := now.UTC().Format(AwsDateFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if credentials.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.sessionToken)
+	}
+
+	// canonical headers including host sorted by lower case name
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{req.Method, path, req.URL.RawQuery,
+		canonicalHeaders.String(), signedHeaders, sha256Hex(body)}, "\n")
+
+	date := amzDate[:8]
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{AwsSigningAlgorithm, amzDate, scope,
+		sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSha256([]byte("AWS4"+credentials.secretAccessKey), date)
+	key = hmacSha256(key, region)
+	key = hmacSha256(key, service)
+	key = hmacSha256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		AwsSigningAlgorithm, credentials.accessKeyId, scope, signedHeaders, signature))
+}
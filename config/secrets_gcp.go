@@ -0,0 +1,86 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	b64 "encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// gcp provider consts
+const (
+	GcpSecretManagerUrl = "https://secretmanager.googleapis.com/v1/"
+	GcpMetadataTokenUrl = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// gcpProvider struct
+// Fetches secret versions from GCP Secret Manager, authenticating with the
+// access token in GOOGLE_OAUTH_ACCESS_TOKEN or, if not set, the access token
+// of the instance service account from the metadata server
+type gcpProvider struct {
+	url      string
+	tokenUrl string
+	client   *http.Client
+}
+
+// Return new gcp provider
+func newGcpProvider(secretsConfig SecretsConfig) (*gcpProvider, error) {
+	return &gcpProvider{GcpSecretManagerUrl, GcpMetadataTokenUrl, &http.Client{Timeout: SecretsTimeout}}, nil
+}
+
+// Return access token for secret manager requests
+func (p *gcpProvider) accessToken() (string, error) {
+	if token := getenvFirst("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+	req, reqErr := http.NewRequest(http.MethodGet, p.tokenUrl, nil)
+	if reqErr != nil {
+		return "", reqErr
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+	}
+	if respErr := doSecretRequest(p.client, req, &result); respErr != nil {
+		return "", errors.New(fmt.Sprintf("%s: %s (%v)", ErrorSecretProviderConfig, SecretSchemeGcp, respErr))
+	}
+	return result.AccessToken, nil
+}
+
+// Return secret version at path, e.g. projects/p/secrets/s/versions/1
+// The latest version is returned if the path does not include a version
+func (p *gcpProvider) GetSecret(path string) (string, error) {
+	path = strings.TrimPrefix(path, "/")
+	if !strings.Contains(path, "/versions/") {
+		path += "/versions/latest"
+	}
+	token, tokenErr := p.accessToken()
+	if tokenErr != nil {
+		return "", tokenErr
+	}
+	req, reqErr := http.NewRequest(http.MethodGet, p.url+path+":access", nil)
+	if reqErr != nil {
+		return "", reqErr
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	var result struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if respErr := doSecretRequest(p.client, req, &result); respErr != nil {
+		return "", respErr
+	}
+	secret, decodeErr := b64.StdEncoding.DecodeString(result.Payload.Data)
+	if decodeErr != nil {
+		return "", errors.New(fmt.Sprintf("%s: %v", ErrorSecretResponse, decodeErr))
+	}
+	return string(secret), nil
+}
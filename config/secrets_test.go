@@ -0,0 +1,198 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test parsing of secret references
+func TestSecretRefs(t *testing.T) {
+	scheme, path, key, isRef := parseSecretRef("vault:secret/data/mainstay#rpcpass")
+	assert.Equal(t, true, isRef)
+	assert.Equal(t, []string{SecretSchemeVault, "secret/data/mainstay", "rpcpass"}, []string{scheme, path, key})
+
+	scheme, path, key, isRef = parseSecretRef("gcp-sm:projects/p/secrets/s")
+	assert.Equal(t, true, isRef)
+	assert.Equal(t, []string{SecretSchemeGcp, "projects/p/secrets/s", ""}, []string{scheme, path, key})
+
+	for _, value := range []string{"", "pass", "localhost:18443", "http://localhost#x"} {
+		_, _, _, isRef = parseSecretRef(value)
+		assert.Equal(t, false, isRef)
+	}
+
+	secret, secretErr := selectSecretKey(`{"rpcpass":"pass","port":5}`, "port")
+	assert.Equal(t, nil, secretErr)
+	assert.Equal(t, "5", secret)
+	_, secretErr = selectSecretKey(`{"rpcpass":"pass"}`, "dbpassword")
+	assert.Equal(t, ErrorSecretKeyNotFound+": dbpassword", secretErr.Error())
+	_, secretErr = selectSecretKey("pass", "rpcpass")
+	assert.Equal(t, ErrorSecretKeyNotFound+": rpcpass", secretErr.Error())
+}
+
+// Test conf secret references are resolved from vault
+func TestResolveSecretsVault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(VaultHeaderToken) != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/mainstay": // kv version 2
+			fmt.Fprint(w, `{"data":{"data":{"rpcpass":"rpcsecret","dbpassword":"dbsecret","walletpassphrase":"walletsecret"},"metadata":{"version":1}}}`)
+		case "/v1/kv/topup": // kv version 1
+			fmt.Fprint(w, `{"data":{"topupPK":"pksecret"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("MAINSTAY_TEST_DB_PASSWORD", "vault:secret/data/mainstay#dbpassword")
+	conf := []byte(fmt.Sprintf(`
+    {
+        "main": {
+            "rpcurl": "localhost:18443",
+            "rpcuser": "user",
+            "rpcpass": "vault:secret/data/mainstay#rpcpass",
+            "chain": "regtest"
+        },
+        "wallet": {
+            "passphrase": "vault:secret/data/mainstay#walletpassphrase"
+        },
+        "db": {
+            "password": "MAINSTAY_TEST_DB_PASSWORD"
+        },
+        "staychain": {
+            "topupPK": "vault:kv/topup#topupPK"
+        },
+        "secrets": {
+            "vaultAddr": "%s",
+            "vaultToken": "token"
+        }
+    }
+    `, server.URL))
+	resolved, resolveErr := ResolveSecrets(conf)
+	assert.Equal(t, nil, resolveErr)
+	assert.Equal(t, "rpcsecret", TryGetParamFromConf(MainChainName, "rpcpass", resolved))
+	assert.Equal(t, "localhost:18443", TryGetParamFromConf(MainChainName, "rpcurl", resolved))
+	assert.Equal(t, "dbsecret", TryGetParamFromConf("db", "password", resolved))
+	assert.Equal(t, "walletsecret", TryGetParamFromConf(WalletName, WalletPassphraseName, resolved))
+	assert.Equal(t, "pksecret", TryGetParamFromConf(StaychainName, StaychainTopupPkName, resolved))
+
+	// conf without secret references unchanged
+	plainConf := []byte(`{"main": {"rpcpass": "pass"}}`)
+	resolved, resolveErr = ResolveSecrets(plainConf)
+	assert.Equal(t, nil, resolveErr)
+	assert.Equal(t, plainConf, resolved)
+
+	// missing secret key
+	_, resolveErr = ResolveSecrets([]byte(fmt.Sprintf(`{"main": {"rpcpass": "vault:kv/topup#rpcpass"},
+        "secrets": {"vaultAddr": "%s", "vaultToken": "token"}}`, server.URL)))
+	assert.Equal(t, ErrorSecretFetch+" main.rpcpass: "+ErrorSecretKeyNotFound+": rpcpass", resolveErr.Error())
+
+	// invalid token
+	_, resolveErr = ResolveSecrets([]byte(fmt.Sprintf(`{"main": {"rpcpass": "vault:kv/topup#topupPK"},
+        "secrets": {"vaultAddr": "%s", "vaultToken": "wrong"}}`, server.URL)))
+	assert.Equal(t, ErrorSecretFetch+" main.rpcpass: "+ErrorSecretResponse+": status 403", resolveErr.Error())
+
+	// vault not configured
+	t.Setenv("VAULT_ADDR", "")
+	t.Setenv("VAULT_TOKEN", "")
+	_, resolveErr = ResolveSecrets([]byte(`{"main": {"rpcpass": "vault:kv/topup#topupPK"}}`))
+	assert.Equal(t, ErrorSecretFetch+" main.rpcpass: "+ErrorSecretProviderConfig+": vault", resolveErr.Error())
+}
+
+// Test aws secrets manager requests are signed and secrets returned
+func TestSecretsAws(t *testing.T) {
+	// aws signature version 4 test suite get-vanilla vector
+	req, _ := http.NewRequest(http.MethodGet, "http://example.amazonaws.com/", nil)
+	credentials := awsCredentials{accessKeyId: "AKIDEXAMPLE", secretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signAwsRequest(req, nil, credentials, "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		req.Header.Get("Authorization"))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" || r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch string(body) {
+		case `{"SecretId":"mainstay/prod"}`:
+			fmt.Fprint(w, `{"SecretString":"{\"dbpassword\":\"dbsecret\"}"}`)
+		case `{"SecretId":"mainstay/binary"}`:
+			fmt.Fprint(w, `{"SecretBinary":"YmluYXJ5"}`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	provider, providerErr := newAwsProvider(SecretsConfig{AwsRegion: "eu-west-1"})
+	assert.Equal(t, nil, providerErr)
+	assert.Equal(t, "https://secretsmanager.eu-west-1.amazonaws.com/", provider.url)
+	provider.url = server.URL
+
+	secret, secretErr := provider.GetSecret("mainstay/prod")
+	assert.Equal(t, nil, secretErr)
+	assert.Equal(t, `{"dbpassword":"dbsecret"}`, secret)
+	secret, secretErr = provider.GetSecret("mainstay/binary")
+	assert.Equal(t, nil, secretErr)
+	assert.Equal(t, "binary", secret)
+	_, secretErr = provider.GetSecret("mainstay/missing")
+	assert.Equal(t, ErrorSecretResponse+": status 400", secretErr.Error())
+
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	_, providerErr = newAwsProvider(SecretsConfig{})
+	assert.Equal(t, ErrorSecretProviderConfig+": aws-sm", providerErr.Error())
+}
+
+// Test gcp secret manager secret versions are accessed
+func TestSecretsGcp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token" && r.Header.Get("Metadata-Flavor") == "Google":
+			fmt.Fprint(w, `{"access_token":"metadatatoken"}`)
+		case r.Header.Get("Authorization") != "Bearer metadatatoken":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/projects/p/secrets/topup/versions/latest:access":
+			fmt.Fprint(w, `{"payload":{"data":"cGtzZWNyZXQ="}}`)
+		case r.URL.Path == "/projects/p/secrets/topup/versions/2:access":
+			fmt.Fprint(w, `{"payload":{"data":"b2xkc2VjcmV0"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "")
+	provider, _ := newGcpProvider(SecretsConfig{})
+	provider.url = server.URL + "/"
+	provider.tokenUrl = server.URL + "/token"
+
+	secret, secretErr := provider.GetSecret("projects/p/secrets/topup")
+	assert.Equal(t, nil, secretErr)
+	assert.Equal(t, "pksecret", secret)
+	secret, secretErr = provider.GetSecret("projects/p/secrets/topup/versions/2")
+	assert.Equal(t, nil, secretErr)
+	assert.Equal(t, "oldsecret", secret)
+
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "envtoken")
+	_, secretErr = provider.GetSecret("projects/p/secrets/topup")
+	assert.Equal(t, ErrorSecretResponse+": status 401", secretErr.Error())
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// vault provider consts
+const (
+	VaultHeaderToken = "X-Vault-Token"
+)
+
+// vaultProvider struct
+// Fetches secrets from the Vault kv secrets engine, version 1 or 2,
+// authenticating with a Vault token
+type vaultProvider struct {
+	addr   string
+	token  string
+	client *http.Client
+}
+
+// Return new vault provider using vault address and token from
+// secrets config or VAULT_ADDR and VAULT_TOKEN env variables
+func newVaultProvider(secretsConfig SecretsConfig) (*vaultProvider, error) {
+	addr := secretsConfig.VaultAddr
+	if addr == "" {
+		addr = getenvFirst("VAULT_ADDR")
+	}
+	token := secretsConfig.VaultToken
+	if token == "" {
+		token = getenvFirst("VAULT_TOKEN")
+	}
+	if addr == "" || token == "" {
+		return nil, errors.New(fmt.Sprintf("%s: %s", ErrorSecretProviderConfig, SecretSchemeVault))
+	}
+	return &vaultProvider{strings.TrimSuffix(addr, "/"), token, &http.Client{Timeout: SecretsTimeout}}, nil
+}
+
+// Return secret at path, e.g. secret/data/mainstay, as json object
+// of the secret data
+func (p *vaultProvider) GetSecret(path string) (string, error) {
+	req, reqErr := http.NewRequest(http.MethodGet, p.addr+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if reqErr != nil {
+		return "", reqErr
+	}
+	req.Header.Set(VaultHeaderToken, p.token)
+
+	var result struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if respErr := doSecretRequest(p.client, req, &result); respErr != nil {
+		return "", respErr
+	}
+
+	// kv version 2 nests the secret data with its metadata
+	data := result.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, isV2 := data["metadata"]; isV2 {
+			data = nested
+		}
+	}
+	secret, marshalErr := json.Marshal(data)
+	if marshalErr != nil {
+		return "", marshalErr
+	}
+	return string(secret), nil
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Minimal TOML parser for conf files
+// Supports the subset of TOML needed for conf categories: comments, [category]
+// tables and key = value pairs with string, integer, float, boolean and
+// single line array values. Nested tables and multi-line values are not
+// supported as conf options are never nested
+
+// toml parser errors
+const (
+	ErrorTomlTableInvalid       = "invalid table"
+	ErrorTomlTableDuplicate     = "duplicate table"
+	ErrorTomlKeyInvalid         = "invalid key"
+	ErrorTomlKeyDuplicate       = "duplicate key"
+	ErrorTomlKeyOutsideTable    = "key outside table"
+	ErrorTomlValueInvalid       = "invalid value"
+	ErrorTomlStringUnterminated = "unterminated string"
+)
+
+// Parse TOML conf into a map of tables to key values
+func parseToml(conf []byte) (map[string]interface{}, error) {
+	tables := make(map[string]interface{})
+	var table map[string]interface{}
+	for i, line := range strings.Split(string(conf), "\n") {
+		lineErr := func(msg string) error {
+			return errors.New(fmt.Sprintf("line %d: %s", i+1, msg))
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' {
+			end := strings.IndexByte(line, ']')
+			if end < 0 || strings.TrimSpace(stripTomlComment(line[end+1:])) != "" {
+				return nil, lineErr(ErrorTomlTableInvalid)
+			}
+			name := strings.TrimSpace(line[1:end])
+			if !isTomlBareKey(name) {
+				return nil, lineErr(fmt.Sprintf("%s %s", ErrorTomlTableInvalid, name))
+			}
+			if _, exists := tables[name]; exists {
+				return nil, lineErr(fmt.Sprintf("%s %s", ErrorTomlTableDuplicate, name))
+			}
+			table = make(map[string]interface{})
+			tables[name] = table
+			continue
+		}
+
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			return nil, lineErr(ErrorTomlKeyInvalid)
+		}
+		key := strings.TrimSpace(line[:eq])
+		if unquoted, quoteErr := strconv.Unquote(key); quoteErr == nil && strings.HasPrefix(key, `"`) {
+			key = unquoted
+		} else if !isTomlBareKey(key) {
+			return nil, lineErr(fmt.Sprintf("%s %s", ErrorTomlKeyInvalid, key))
+		}
+		if table == nil {
+			return nil, lineErr(fmt.Sprintf("%s %s", ErrorTomlKeyOutsideTable, key))
+		}
+		if _, exists := table[key]; exists {
+			return nil, lineErr(fmt.Sprintf("%s %s", ErrorTomlKeyDuplicate, key))
+		}
+
+		value, rest, valueErr := parseTomlValue(strings.TrimSpace(line[eq+1:]))
+		if valueErr != nil {
+			return nil, lineErr(fmt.Sprintf("%v %s", valueErr, key))
+		}
+		if strings.TrimSpace(stripTomlComment(rest)) != "" {
+			return nil, lineErr(fmt.Sprintf("%s %s", ErrorTomlValueInvalid, key))
+		}
+		table[key] = value
+	}
+	return tables, nil
+}
+
+// Parse TOML value at the start of str and return the value and remaining str
+func parseTomlValue(str string) (interface{}, string, error) {
+	if str == "" {
+		return nil, "", errors.New(ErrorTomlValueInvalid)
+	}
+	switch str[0] {
+	case '"': // basic string with escapes
+		for end := 1; end < len(str); end++ {
+			if str[end] == '\\' {
+				end++
+			} else if str[end] == '"' {
+				value, unquoteErr := strconv.Unquote(str[:end+1])
+				if unquoteErr != nil {
+					return nil, "", errors.New(ErrorTomlValueInvalid)
+				}
+				return value, str[end+1:], nil
+			}
+		}
+		return nil, "", errors.New(ErrorTomlStringUnterminated)
+	case '\'': // literal string without escapes
+		end := strings.IndexByte(str[1:], '\'')
+		if end < 0 {
+			return nil, "", errors.New(ErrorTomlStringUnterminated)
+		}
+		return str[1 : end+1], str[end+2:], nil
+	case '[': // array
+		var values []interface{}
+		rest := strings.TrimSpace(str[1:])
+		for {
+			if strings.HasPrefix(rest, "]") {
+				return values, rest[1:], nil
+			}
+			value, next, valueErr := parseTomlValue(rest)
+			if valueErr != nil {
+				return nil, "", valueErr
+			}
+			values = append(values, value)
+			rest = strings.TrimSpace(next)
+			if strings.HasPrefix(rest, ",") {
+				rest = strings.TrimSpace(rest[1:])
+			} else if !strings.HasPrefix(rest, "]") {
+				return nil, "", errors.New(ErrorTomlValueInvalid)
+			}
+		}
+	}
+
+	// bare value ends at whitespace, comma, array end or comment
+	end := strings.IndexAny(str, " \t,]#")
+	if end < 0 {
+		end = len(str)
+	}
+	bare, rest := str[:end], str[end:]
+	switch bare {
+	case "true":
+		return true, rest, nil
+	case "false":
+		return false, rest, nil
+	}
+	if intValue, intErr := strconv.ParseInt(strings.ReplaceAll(bare, "_", ""), 0, 64); intErr == nil {
+		return intValue, rest, nil
+	}
+	if floatValue, floatErr := strconv.ParseFloat(strings.ReplaceAll(bare, "_", ""), 64); floatErr == nil {
+		return floatValue, rest, nil
+	}
+	return nil, "", errors.New(ErrorTomlValueInvalid)
+}
+
+// Return str without a trailing comment
+func stripTomlComment(str string) string {
+	if comment := strings.IndexByte(str, '#'); comment >= 0 {
+		return str[:comment]
+	}
+	return str
+}
+
+// Return true if key is a non empty TOML bare key
+func isTomlBareKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, c := range key {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
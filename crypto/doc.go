@@ -1,5 +1,6 @@
 /*
 Package crypto contains utilities for key tweaking under BIP-175,
+deriving attestation keys from BIP-32 extended federation keys,
 generating and validation attestation addresses, as well as parsing
 and generating multisig and redeem scripts
 */
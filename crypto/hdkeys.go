@@ -0,0 +1,99 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+// BIP-32 HD derivation of the federation keys
+// Federation keys can be configured as xpubs, and the key of a signer as an
+// xprv, from which the base keys of the staychain are derived along a path
+// of non-hardened child indexes. The keys of each attestation are derived
+// from the base keys with the commitment tweak, as in TweakExtendedKey
+
+// error consts
+const (
+	ErrorDerivationPath = "Invalid derivation path"
+	ErrorExtendedKey    = "Invalid extended key"
+)
+
+// serialized extended key chaincode offsets
+const (
+	extendedKeyChaincodeStart = 13
+	extendedKeyChaincodeEnd   = 45
+)
+
+// Parse derivation path of non-hardened child indexes, e.g. m/0/1
+// Empty path or m return no child indexes
+func ParseDerivationPath(path string) ([]uint32, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(path), "m"), "/")
+	if path == "" {
+		return nil, nil
+	}
+	var indexes []uint32
+	for _, child := range strings.Split(path, "/") {
+		index, indexErr := strconv.ParseUint(child, 10, 32)
+		if indexErr != nil || index >= hdkeychain.HardenedKeyStart {
+			return nil, errors.New(fmt.Sprintf("%s: %s", ErrorDerivationPath, child))
+		}
+		indexes = append(indexes, uint32(index))
+	}
+	return indexes, nil
+}
+
+// Parse base58 encoded xpub or xprv of the chain
+func ParseExtendedKey(key string, chainCfg *chaincfg.Params) (*hdkeychain.ExtendedKey, error) {
+	extndKey, keyErr := hdkeychain.NewKeyFromString(strings.TrimSpace(key))
+	if keyErr != nil || !extndKey.IsForNet(chainCfg) {
+		return nil, errors.New(ErrorExtendedKey)
+	}
+	return extndKey, nil
+}
+
+// Derive extended key along the path child indexes
+func DeriveExtendedKey(extndKey *hdkeychain.ExtendedKey, path []uint32) (*hdkeychain.ExtendedKey, error) {
+	var childErr error
+	for _, index := range path {
+		extndKey, childErr = extndKey.Child(index)
+		if childErr != nil {
+			return nil, childErr
+		}
+	}
+	return extndKey, nil
+}
+
+// Derive the attestation key of a commitment tweak from an extended
+// federation key, first along the path and then with the tweak
+func DeriveAttestationKey(extndKey *hdkeychain.ExtendedKey, path []uint32, tweak []byte) (*hdkeychain.ExtendedKey, error) {
+	baseKey, baseErr := DeriveExtendedKey(extndKey, path)
+	if baseErr != nil {
+		return nil, baseErr
+	}
+	return TweakExtendedKey(baseKey, tweak)
+}
+
+// Get chaincode of extended key
+func GetExtendedKeyChaincode(extndKey *hdkeychain.ExtendedKey) []byte {
+	serialized := base58.Decode(extndKey.String())
+	return serialized[extendedKeyChaincodeStart:extendedKeyChaincodeEnd]
+}
+
+// Get private key wallet readable format from an extended private key
+func GetExtendedKeyWalletPrivKey(extndKey *hdkeychain.ExtendedKey, chainCfg *chaincfg.Params) (*btcutil.WIF, error) {
+	privKey, privErr := extndKey.ECPrivKey()
+	if privErr != nil {
+		return nil, privErr
+	}
+	return btcutil.NewWIF(privKey, chainCfg, true)
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/stretchr/testify/assert"
+)
+
+// BIP-32 test vector 2 keys
+const (
+	testMasterXpub = "xpub661MyMwAqRbcFW31YEwpkMuc5THy2PSt5bDMsktWQcFF8syAmRUapSCGu8ED9W6oDMSgv6Zz8idoc4a6mr8BDzTJY47LJhkJ8UB7WEGuduB"
+	testMasterXprv = "xprv9s21ZrQH143K31xYSDQpPDxsXRTUcvj2iNHm5NUtrGiGG5e2DtALGdso3pGz6ssrdK4PFmM8NSpSBHNqPqm55Qn3LqFtT2emdEXVYsCzC2U"
+	testChildXpub  = "xpub69H7F5d8KSRgmmdJg2KhpAK8SR3DjMwAdkxj3ZuxV27CprR9LgpeyGmXUbC6wb7ERfvrnKZjXoUmmDznezpbZb7ap6r1D3tgFxHmwMkQTPH"
+)
+
+// Test parsing of derivation paths
+func TestHdDerivationPath(t *testing.T) {
+	for path, expected := range map[string][]uint32{
+		"":       nil,
+		"m":      nil,
+		"m/0":    {0},
+		"m/0/12": {0, 12},
+		"3/4":    {3, 4},
+	} {
+		indexes, pathErr := ParseDerivationPath(path)
+		assert.Equal(t, nil, pathErr)
+		assert.Equal(t, expected, indexes)
+	}
+
+	_, pathErr := ParseDerivationPath("m/0'/1")
+	assert.Equal(t, errors.New(ErrorDerivationPath+": 0'"), pathErr)
+	_, pathErr = ParseDerivationPath("m/2147483648")
+	assert.Equal(t, errors.New(ErrorDerivationPath+": 2147483648"), pathErr)
+	_, pathErr = ParseDerivationPath("m//1")
+	assert.Equal(t, errors.New(ErrorDerivationPath+": "), pathErr)
+}
+
+// Test derivation of federation keys from extended keys
+func TestHdKeys(t *testing.T) {
+	_, keyErr := ParseExtendedKey("xpub", &chaincfg.MainNetParams)
+	assert.Equal(t, errors.New(ErrorExtendedKey), keyErr)
+	_, keyErr = ParseExtendedKey(testMasterXpub, &chaincfg.RegressionNetParams)
+	assert.Equal(t, errors.New(ErrorExtendedKey), keyErr)
+
+	xpub, keyErr := ParseExtendedKey(testMasterXpub, &chaincfg.MainNetParams)
+	assert.Equal(t, nil, keyErr)
+	assert.Equal(t, "60499f801b896d83179a4374aeb7822aaeaceaa0db1f85ee3e904c4defbd9689",
+		hex.EncodeToString(GetExtendedKeyChaincode(xpub)))
+
+	// base key derived along path
+	childXpub, deriveErr := DeriveExtendedKey(xpub, []uint32{0})
+	assert.Equal(t, nil, deriveErr)
+	assert.Equal(t, testChildXpub, childXpub.String())
+	sameXpub, _ := DeriveExtendedKey(xpub, nil)
+	assert.Equal(t, testMasterXpub, sameXpub.String())
+
+	// attestation key derived from base key with tweak
+	tweak, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	attestationKey, attestationErr := DeriveAttestationKey(xpub, []uint32{0}, tweak.CloneBytes())
+	assert.Equal(t, nil, attestationErr)
+	tweakedKey, _ := TweakExtendedKey(childXpub, tweak.CloneBytes())
+	assert.Equal(t, tweakedKey.String(), attestationKey.String())
+
+	// signer key derived from xprv matches the xpub derivation
+	xprv, keyErr := ParseExtendedKey(testMasterXprv, &chaincfg.MainNetParams)
+	assert.Equal(t, nil, keyErr)
+	attestationPriv, attestationErr := DeriveAttestationKey(xprv, []uint32{0}, tweak.CloneBytes())
+	assert.Equal(t, nil, attestationErr)
+	wif, wifErr := GetExtendedKeyWalletPrivKey(attestationPriv, &chaincfg.MainNetParams)
+	assert.Equal(t, nil, wifErr)
+	attestationPub, _ := attestationKey.ECPubKey()
+	assert.Equal(t, true, wif.PrivKey.PubKey().IsEqual(attestationPub))
+	assert.Equal(t, true, wif.CompressPubKey)
+
+	_, wifErr = GetExtendedKeyWalletPrivKey(xpub, &chaincfg.MainNetParams)
+	assert.Equal(t, hdkeychain.ErrNotPrivExtKey, wifErr)
+}
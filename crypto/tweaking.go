@@ -59,6 +59,18 @@ func getDerivationPathFromTweak(tweak []byte) derivationPath {
 	return path
 }
 
+// Get bip-32 child indexes of the key derivation path from tweak hash
+func GetDerivationIndexes(tweak []byte) []uint32 {
+	path := getDerivationPathFromTweak(tweak)
+
+	indexes := make([]uint32, derivationPathSize)
+	for it, pathChild := range path {
+		childBytes := []byte{0, 0, pathChild[0], pathChild[1]}
+		indexes[it] = binary.BigEndian.Uint32(childBytes)
+	}
+	return indexes
+}
+
 // Tweak big int value with path child
 func tweakValWithPathChild(child derivationPathChild, val *big.Int) *big.Int {
 	// get bytes from child path
@@ -128,16 +140,10 @@ func tweakPubWithPathChild(child derivationPathChild, x *big.Int, y *big.Int) (*
 // but we are including the error check for any 100% completeness
 func TweakExtendedKey(extndPubKey *hdkeychain.ExtendedKey, tweak []byte) (*hdkeychain.ExtendedKey, error) {
 
-	path := getDerivationPathFromTweak(tweak) // get derivation path for tweak
-
 	var childErr error
 
-	// tweak pubkey for each path child
-	for _, pathChild := range path {
-
-		// get tweak index from path child
-		childBytes := []byte{0, 0, pathChild[0], pathChild[1]}
-		childInt := binary.BigEndian.Uint32(childBytes)
+	// tweak pubkey for each path child index
+	for _, childInt := range GetDerivationIndexes(tweak) {
 
 		// get tweaked pubkey
 		extndPubKey, childErr = extndPubKey.Child(childInt)
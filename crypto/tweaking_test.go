@@ -83,6 +83,12 @@ func TestTweaking_getDerivationPathFromTweak(t *testing.T) {
 		assert.Equal(t, testPathChild, path[i])
 	}
 
+	// test child indexes of path
+	indexes := GetDerivationIndexes(hashX.CloneBytes())
+	assert.Equal(t, derivationPathSize, len(indexes))
+	assert.Equal(t, uint32(183<<8+163), indexes[0])
+	assert.Equal(t, uint32(202<<8+171), indexes[derivationPathSize-1])
+
 	// test function with invalid hash sizes
 	path = getDerivationPathFromTweak([]byte{1, 2, 3})
 	assert.Equal(t, derivationPath{}, path)
@@ -0,0 +1,127 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test writing and removing pidfiles
+func TestPidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mainstay.pid")
+	assert.Equal(t, nil, WritePidFile(path))
+	pid, pidErr := readPidFile(path)
+	assert.Equal(t, nil, pidErr)
+	assert.Equal(t, os.Getpid(), pid)
+
+	// pidfile of own process rewritten
+	assert.Equal(t, nil, WritePidFile(path))
+
+	// pidfile of another running process refused and left in place
+	ioutil.WriteFile(path, []byte(fmt.Sprintf("%d\n", os.Getppid())), 0644)
+	assert.Equal(t, fmt.Sprintf("%s %s (%d)", ErrorPidFileRunning, path, os.Getppid()), WritePidFile(path).Error())
+	assert.Equal(t, nil, RemovePidFile(path))
+	_, statErr := os.Stat(path)
+	assert.Equal(t, nil, statErr)
+
+	// stale or invalid pidfiles replaced
+	ioutil.WriteFile(path, []byte("0\n"), 0644)
+	assert.Equal(t, nil, WritePidFile(path))
+	ioutil.WriteFile(path, []byte("x"), 0644)
+	assert.Equal(t, nil, WritePidFile(path))
+
+	assert.Equal(t, nil, RemovePidFile(path))
+	_, statErr = os.Stat(path)
+	assert.Equal(t, true, os.IsNotExist(statErr))
+
+	assert.Contains(t, WritePidFile(filepath.Join(path, "missing", "mainstay.pid")).Error(), ErrorPidFileWrite)
+}
+
+// Return notify socket listener set as the notify socket of the process
+func listenNotify(t *testing.T) *net.UnixConn {
+	socket := filepath.Join(t.TempDir(), "notify.sock")
+	conn, listenErr := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	assert.Equal(t, nil, listenErr)
+	t.Setenv(EnvNotifySocket, socket)
+	return conn
+}
+
+// Return next state received on the notify socket
+func readNotify(t *testing.T, conn *net.UnixConn) string {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 256)
+	n, readErr := conn.Read(buf)
+	assert.Equal(t, nil, readErr)
+	return string(buf[:n])
+}
+
+// Test systemd notify messages
+func TestNotify(t *testing.T) {
+	t.Setenv(EnvNotifySocket, "")
+	assert.Equal(t, nil, Notify(NotifyReady))
+
+	conn := listenNotify(t)
+	defer conn.Close()
+	assert.Equal(t, nil, Notify(NotifyReady))
+	assert.Equal(t, NotifyReady, readNotify(t, conn))
+	assert.Equal(t, nil, Notify(NotifyStopping))
+	assert.Equal(t, NotifyStopping, readNotify(t, conn))
+
+	t.Setenv(EnvNotifySocket, filepath.Join(t.TempDir(), "missing.sock"))
+	assert.NotEqual(t, nil, Notify(NotifyReady))
+}
+
+// Test systemd watchdog keep-alives
+func TestWatchdog(t *testing.T) {
+	t.Setenv(EnvWatchdogUsec, "")
+	assert.Equal(t, time.Duration(0), WatchdogInterval())
+	assert.Equal(t, (*Watchdog)(nil), NewWatchdog(context.Background(), &sync.WaitGroup{}, nil))
+
+	t.Setenv(EnvWatchdogUsec, "x")
+	assert.Equal(t, time.Duration(0), WatchdogInterval())
+	t.Setenv(EnvWatchdogUsec, "20000")
+	t.Setenv(EnvWatchdogPid, "1")
+	assert.Equal(t, time.Duration(0), WatchdogInterval())
+	t.Setenv(EnvWatchdogPid, strconv.Itoa(os.Getpid()))
+	assert.Equal(t, 20*time.Millisecond, WatchdogInterval())
+
+	conn := listenNotify(t)
+	defer conn.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	var mu sync.Mutex
+	live := false
+	checks := 0
+	watchdog := NewWatchdog(ctx, wg, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		checks++
+		return live
+	})
+	assert.Equal(t, 20*time.Millisecond, watchdog.interval)
+
+	// keep-alives only sent while live
+	wg.Add(1)
+	go watchdog.Run()
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	assert.Equal(t, true, checks > 1)
+	live = true
+	mu.Unlock()
+	assert.Equal(t, NotifyWatchdog, readNotify(t, conn))
+	cancel()
+	wg.Wait()
+}
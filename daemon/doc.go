@@ -0,0 +1,12 @@
+/*
+Package daemon provides process management of the mainstay daemon by
+supervisors.
+
+The pid of the daemon is written to the configured pidfile on startup, and
+startup refused while the pid of a pidfile points to a running process. When
+run as a systemd notify service, readiness, reloading and stopping are
+reported through the notify socket and, if a watchdog is configured for the
+service, watchdog keep-alives are sent while the attestation service is
+live, so that systemd restarts a stalled daemon.
+*/
+package daemon
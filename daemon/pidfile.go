@@ -0,0 +1,78 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// pidfile error consts
+const (
+	ErrorPidFileRunning = "Pidfile points to running process"
+	ErrorPidFileWrite   = "Could not write pidfile"
+
+	WarningPidFileRelative = "Pidfile path not absolute - resolved against the working directory"
+)
+
+// Write pid of the current process to the pidfile, replacing the file
+// atomically. Fails if the file holds the pid of another running process
+func WritePidFile(path string) error {
+	if pid, pidErr := readPidFile(path); pidErr == nil && pid != os.Getpid() && processRunning(pid) {
+		return errors.New(fmt.Sprintf("%s %s (%d)", ErrorPidFileRunning, path, pid))
+	}
+
+	tmpFile, tmpErr := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".")
+	if tmpErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorPidFileWrite, tmpErr))
+	}
+	_, writeErr := tmpFile.WriteString(fmt.Sprintf("%d\n", os.Getpid()))
+	if closeErr := tmpFile.Close(); writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr == nil {
+		writeErr = os.Chmod(tmpFile.Name(), 0644)
+	}
+	if writeErr == nil {
+		writeErr = os.Rename(tmpFile.Name(), path)
+	}
+	if writeErr != nil {
+		os.Remove(tmpFile.Name())
+		return errors.New(fmt.Sprintf("%s %v", ErrorPidFileWrite, writeErr))
+	}
+	return nil
+}
+
+// Remove pidfile if it holds the pid of the current process
+func RemovePidFile(path string) error {
+	if pid, pidErr := readPidFile(path); pidErr != nil || pid != os.Getpid() {
+		return pidErr
+	}
+	return os.Remove(path)
+}
+
+// Return pid read from pidfile
+func readPidFile(path string) (int, error) {
+	pidBytes, readErr := ioutil.ReadFile(path)
+	if readErr != nil {
+		return 0, readErr
+	}
+	return strconv.Atoi(strings.TrimSpace(string(pidBytes)))
+}
+
+// Return whether a process with pid exists
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	killErr := syscall.Kill(pid, 0)
+	return killErr == nil || killErr == syscall.EPERM
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package daemon
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"mainstay/log"
+)
+
+// systemd notify consts
+const (
+	EnvNotifySocket = "NOTIFY_SOCKET"
+	EnvWatchdogUsec = "WATCHDOG_USEC"
+	EnvWatchdogPid  = "WATCHDOG_PID"
+
+	NotifyReady     = "READY=1"
+	NotifyReloading = "RELOADING=1"
+	NotifyStopping  = "STOPPING=1"
+	NotifyWatchdog  = "WATCHDOG=1"
+
+	WarningNotifyFailed       = "Systemd notify failed"
+	WarningWatchdogNotLive    = "Attestation service not live - skipping watchdog keep-alive"
+	WarningInvalidWatchdogArg = "Invalid systemd watchdog interval"
+)
+
+// Send state to the systemd notify socket
+// No-op if the process is not run as a systemd notify service
+func Notify(state string) error {
+	socket := os.Getenv(EnvNotifySocket)
+	if socket == "" {
+		return nil
+	}
+	// abstract namespace sockets are prefixed with @
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+	conn, dialErr := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if dialErr != nil {
+		return dialErr
+	}
+	defer conn.Close()
+	_, writeErr := conn.Write([]byte(state))
+	return writeErr
+}
+
+// Send state to the systemd notify socket, logging any failure
+func NotifyOrWarn(state string) {
+	if notifyErr := Notify(state); notifyErr != nil {
+		log.WithFields(log.Fields{log.FieldError: notifyErr}).Warnln(WarningNotifyFailed)
+	}
+}
+
+// Return watchdog interval of the systemd service
+// Zero if no watchdog is configured for this process
+func WatchdogInterval() time.Duration {
+	usecStr := os.Getenv(EnvWatchdogUsec)
+	if usecStr == "" {
+		return 0
+	}
+	if pidStr := os.Getenv(EnvWatchdogPid); pidStr != "" && pidStr != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, usecErr := strconv.ParseInt(usecStr, 10, 64)
+	if usecErr != nil || usec <= 0 {
+		log.Warnf("%s (%s)\n", WarningInvalidWatchdogArg, usecStr)
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// Watchdog struct
+// Sends systemd watchdog keep-alives at half the watchdog interval
+// while the live check passes
+type Watchdog struct {
+	ctx      context.Context
+	wg       *sync.WaitGroup
+	interval time.Duration
+	live     func() bool
+}
+
+// Return new Watchdog instance for the systemd watchdog interval
+// or nil if no watchdog is configured
+func NewWatchdog(ctx context.Context, wg *sync.WaitGroup, live func() bool) *Watchdog {
+	interval := WatchdogInterval()
+	if interval == 0 {
+		return nil
+	}
+	return &Watchdog{ctx, wg, interval, live}
+}
+
+// Run watchdog keep-alives until the context is cancelled
+func (w *Watchdog) Run() {
+	defer w.wg.Done()
+	log.Infof("*Watchdog* Sending systemd watchdog keep-alives every %s\n", (w.interval / 2).String())
+
+	ticker := time.NewTicker(w.interval / 2)
+	defer ticker.Stop()
+	for {
+		if w.live() {
+			NotifyOrWarn(NotifyWatchdog)
+		} else {
+			log.Warnln(WarningWatchdogNotLive)
+		}
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
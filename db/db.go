@@ -20,8 +20,20 @@ type Db interface {
 	SaveAttestationInfo(models.AttestationInfo) error
 	SaveMerkleCommitments(commitments []models.CommitmentMerkleCommitment) error
 	SaveMerkleProofs(proofs []models.CommitmentMerkleProof) error
+	SaveFeeBump(models.FeeBump) error
+	SaveDryRunAttestation(models.DryRunAttestation) error
+	SaveServiceState(models.ServiceState) error
+	SaveInFlightAttestation(models.InFlightAttestation) error
+	DeleteInFlightAttestation() error
+	SaveCommitmentExclusion(models.CommitmentExclusion) error
+	SaveKeyRotation(models.KeyRotation) error
+	SaveRoundSnapshot(models.RoundSnapshot) error
+	SaveCommitmentSubmissions([]models.CommitmentSubmission) error
+	SaveStaychainTx(models.StaychainTx) error
+	SaveSignerStatus(models.SignerStatus) error
 
 	// util methods
+	Ping() error
 	getAttestationCount(...bool) (int64, error)
 	getAttestationMerkleRoot(chainhash.Hash) (string, error)
 
@@ -29,4 +41,36 @@ type Db interface {
 	GetLatestAttestationMerkleRoot(bool) (string, error)
 	GetClientCommitments() ([]models.ClientCommitment, error)
 	GetAttestationMerkleCommitments(chainhash.Hash) ([]models.CommitmentMerkleCommitment, error)
+	GetServiceState(string) (models.ServiceState, error)
+	GetInFlightAttestation() (models.InFlightAttestation, error)
+	GetAttestations() ([]models.AttestationBSON, error)
+	GetKeyRotations() ([]models.KeyRotation, error)
+	GetSignerStatuses() ([]models.SignerStatus, error)
+	GetPendingCommitmentSubmissions() ([]models.CommitmentSubmission, error)
+	GetConfirmedAttestationCount() (int64, error)
+	GetAttestationFees(int64) (int64, error)
+
+	// methods required by request api
+	GetClientDetails() ([]models.ClientDetails, error)
+	GetAttestationMerkleProofs(chainhash.Hash) ([]models.CommitmentMerkleProof, error)
+	SaveClientDetails(models.ClientDetails) error
+	SaveClientCommitment(models.ClientCommitment) error
+	SaveClientCommitments([]models.ClientCommitment) error
+	SaveSlotGroup(models.SlotGroup) error
+	GetSlotGroup(int32, chainhash.Hash) (models.SlotGroup, error)
+	GetCommitmentMerkleProof(int32, chainhash.Hash) (models.CommitmentMerkleProof, error)
+	GetMerkleProofByCommitment(chainhash.Hash) (models.CommitmentMerkleProof, error)
+	GetLatestCommitmentMerkleProof(int32) (models.CommitmentMerkleProof, error)
+	GetAttestationInfoByMerkleRoot(chainhash.Hash) (models.AttestationInfo, error)
+	GetAttestation(chainhash.Hash) (models.AttestationBSON, error)
+	GetCommitmentExclusions(int32) ([]models.CommitmentExclusion, error)
+	GetCommitmentHistory(int32, int64, int64) (models.CommitmentHistory, error)
+	GetRoundSnapshot(int64) (models.RoundSnapshot, error)
+	GetRoundSnapshots(string, int64, int64) (models.RoundSnapshots, error)
+	GetCommitmentSubmission(string) (models.CommitmentSubmission, error)
+	GetLatestCommitmentSubmissions([]int32) ([]models.CommitmentSubmission, error)
+	GetStaychainTx(string) (models.StaychainTx, error)
+	GetLatestStaychainTx() (models.StaychainTx, error)
+	GetStaychainTxs(int64, int64) (models.StaychainTxs, error)
+	GetAttestationsPage(int64, int64, int64, int64) ([]models.AttestationBSON, error)
 }
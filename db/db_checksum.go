@@ -0,0 +1,66 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"mainstay/log"
+	"mainstay/models"
+
+	"go.mongodb.org/mongo-driver/x/bsonx"
+)
+
+// checksum error messages
+const (
+	ErrorChecksumMismatch = "checksum mismatch for document"
+)
+
+// ChecksumError struct
+// Returned when a proof or commitment document read from the database
+// does not match the checksum stored with it on write, indicating
+// that the stored data has been altered
+type ChecksumError struct {
+	Collection string
+	Expected   string
+	Actual     string
+}
+
+// Implement error interface
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s in %s collection: expected %s got %s",
+		ErrorChecksumMismatch, e.Collection, e.Expected, e.Actual)
+}
+
+// count of checksum verification failures
+var checksumFailures uint64
+
+// Return number of checksum verification failures since startup
+func ChecksumFailures() uint64 {
+	return atomic.LoadUint64(&checksumFailures)
+}
+
+// Verify checksum stored in document against the checksum of the decoded model
+// Documents stored before checksums were introduced are not verified
+func verifyDocumentChecksum(collection string, doc *bsonx.Doc, model interface{}) error {
+	storedVal, lookupErr := doc.LookupErr(models.ChecksumName)
+	if lookupErr != nil {
+		return nil
+	}
+	stored := storedVal.StringValue()
+
+	checksum, checksumErr := models.GetModelChecksum(model)
+	if checksumErr != nil {
+		return checksumErr
+	}
+	if checksum != stored {
+		atomic.AddUint64(&checksumFailures, 1)
+		err := &ChecksumError{Collection: collection, Expected: stored, Actual: checksum}
+		log.WithFields(log.Fields{log.FieldCollection: collection}).Warnln(err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"errors"
+	"strings"
+)
+
+// db query error prefixes for failed reads and writes that may succeed
+// if retried, unlike bad data and checksum errors of the stored documents
+var transientErrors = []string{
+	ErrorMongoPing,
+	ErrorAttestationSave,
+	ErrorAttestationInfoSave,
+	ErrorMerkleCommitmentSave,
+	ErrorMerkleProofSave,
+	ErrorClientDetailsSave,
+	ErrorClientCommitmentSave,
+	ErrorFeeBumpSave,
+	ErrorDryRunAttestationSave,
+	ErrorServiceStateSave,
+	ErrorSlotGroupSave,
+	ErrorInFlightSave,
+	ErrorInFlightDelete,
+	ErrorCommitmentExclusionSave,
+	ErrorRoundSnapshotSave,
+	ErrorSubmissionSave,
+	ErrorStaychainTxSave,
+	ErrorAttestationGet,
+	ErrorMerkleCommitmentGet,
+	ErrorMerkleProofGet,
+	ErrorClientCommitmentGet,
+	ErrorClientDetailsGet,
+	ErrorServiceStateGet,
+	ErrorSlotGroupGet,
+	ErrorInFlightGet,
+	ErrorCommitmentExclusionGet,
+	ErrorRoundSnapshotGet,
+	ErrorSubmissionGet,
+	ErrorStaychainTxGet,
+}
+
+// Return true if the error is a failed db query that may succeed if retried
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var checksumErr *ChecksumError
+	if errors.As(err, &checksumErr) {
+		return false
+	}
+	for _, prefix := range transientErrors {
+		if strings.HasPrefix(err.Error(), prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -7,6 +7,8 @@ package db
 import (
 	"errors"
 	"mainstay/models"
+	"sort"
+	"time"
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 )
@@ -16,11 +18,23 @@ import (
 // Minimizing as much as possible reliance to mongo and testing as much
 // as possible without the need for a proper mongo mock for testing
 type DbFake struct {
-	Attestations      []models.Attestation
-	AttestationsInfo  []models.AttestationInfo
-	MerkleCommitments []models.CommitmentMerkleCommitment
-	MerkleProofs      []models.CommitmentMerkleProof
-	latestCommitments []models.ClientCommitment
+	Attestations       []models.Attestation
+	AttestationsInfo   []models.AttestationInfo
+	MerkleCommitments  []models.CommitmentMerkleCommitment
+	MerkleProofs       []models.CommitmentMerkleProof
+	FeeBumps           []models.FeeBump
+	DryRunAttestations []models.DryRunAttestation
+	ServiceStates      []models.ServiceState
+	SlotGroups         []models.SlotGroup
+	Exclusions         []models.CommitmentExclusion
+	KeyRotations       []models.KeyRotation
+	RoundSnapshots     []models.RoundSnapshot
+	Submissions        []models.CommitmentSubmission
+	StaychainTxs       []models.StaychainTx
+	SignerStatuses     []models.SignerStatus
+	InFlight           *models.InFlightAttestation
+	latestCommitments  []models.ClientCommitment
+	clientDetails      []models.ClientDetails
 }
 
 // Return new DbFake instance
@@ -30,7 +44,24 @@ func NewDbFake() *DbFake {
 		[]models.AttestationInfo{},
 		[]models.CommitmentMerkleCommitment{},
 		[]models.CommitmentMerkleProof{},
-		[]models.ClientCommitment{}}
+		[]models.FeeBump{},
+		[]models.DryRunAttestation{},
+		[]models.ServiceState{},
+		[]models.SlotGroup{},
+		[]models.CommitmentExclusion{},
+		[]models.KeyRotation{},
+		[]models.RoundSnapshot{},
+		[]models.CommitmentSubmission{},
+		[]models.StaychainTx{},
+		[]models.SignerStatus{},
+		nil,
+		[]models.ClientCommitment{},
+		[]models.ClientDetails{}}
+}
+
+// Ping database - always reachable
+func (d *DbFake) Ping() error {
+	return nil
 }
 
 // Save latest attestation to Attestations
@@ -99,6 +130,108 @@ func (d *DbFake) SaveMerkleProofs(proofs []models.CommitmentMerkleProof) error {
 	return nil
 }
 
+// Save fee bump attempt to FeeBumps
+func (d *DbFake) SaveFeeBump(feeBump models.FeeBump) error {
+	d.FeeBumps = append(d.FeeBumps, feeBump)
+	return nil
+}
+
+// Save dry run attestation to DryRunAttestations
+func (d *DbFake) SaveDryRunAttestation(attestation models.DryRunAttestation) error {
+	d.DryRunAttestations = append(d.DryRunAttestations, attestation)
+	return nil
+}
+
+// Save service state to ServiceStates
+func (d *DbFake) SaveServiceState(state models.ServiceState) error {
+	for i, s := range d.ServiceStates {
+		if s.Name == state.Name {
+			d.ServiceStates[i] = state
+			return nil
+		}
+	}
+	d.ServiceStates = append(d.ServiceStates, state)
+	return nil
+}
+
+// Get service state from ServiceStates
+// Return default state if no state has been saved for the service
+func (d *DbFake) GetServiceState(name string) (models.ServiceState, error) {
+	for _, s := range d.ServiceStates {
+		if s.Name == name {
+			return s, nil
+		}
+	}
+	return models.ServiceState{Name: name}, nil
+}
+
+// Save key rotation to KeyRotations replacing any with the same id
+func (d *DbFake) SaveKeyRotation(rotation models.KeyRotation) error {
+	for i, r := range d.KeyRotations {
+		if r.Id == rotation.Id {
+			d.KeyRotations[i] = rotation
+			return nil
+		}
+	}
+	d.KeyRotations = append(d.KeyRotations, rotation)
+	return nil
+}
+
+// Return key rotations from KeyRotations
+func (d *DbFake) GetKeyRotations() ([]models.KeyRotation, error) {
+	return append([]models.KeyRotation{}, d.KeyRotations...), nil
+}
+
+// Save round snapshot to RoundSnapshots unless the round has a snapshot
+func (d *DbFake) SaveRoundSnapshot(snapshot models.RoundSnapshot) error {
+	for _, s := range d.RoundSnapshots {
+		if s.Round == snapshot.Round {
+			return nil
+		}
+	}
+	d.RoundSnapshots = append(d.RoundSnapshots, snapshot)
+	return nil
+}
+
+// Save commitment submissions to Submissions replacing any with the same id
+func (d *DbFake) SaveCommitmentSubmissions(submissions []models.CommitmentSubmission) error {
+	for _, submission := range submissions {
+		replaced := false
+		for i, s := range d.Submissions {
+			if s.Id == submission.Id {
+				d.Submissions[i] = submission
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			d.Submissions = append(d.Submissions, submission)
+		}
+	}
+	return nil
+}
+
+// Save in flight attestation replacing any existing one
+func (d *DbFake) SaveInFlightAttestation(inFlight models.InFlightAttestation) error {
+	d.InFlight = &inFlight
+	return nil
+}
+
+// Delete in flight attestation
+func (d *DbFake) DeleteInFlightAttestation() error {
+	d.InFlight = nil
+	return nil
+}
+
+// Get in flight attestation
+// Return empty attestation if none has been saved
+func (d *DbFake) GetInFlightAttestation() (models.InFlightAttestation, error) {
+	if d.InFlight == nil {
+		return models.InFlightAttestation{}, nil
+	}
+	return *d.InFlight, nil
+}
+
 // Return attestation count with optional confirmed flag
 func (d *DbFake) getAttestationCount(confirmed ...bool) (int64, error) {
 	if len(confirmed) > 0 {
@@ -113,6 +246,22 @@ func (d *DbFake) getAttestationCount(confirmed ...bool) (int64, error) {
 	return int64(len(d.Attestations)), nil
 }
 
+// Return number of confirmed attestations
+func (d *DbFake) GetConfirmedAttestationCount() (int64, error) {
+	return d.getAttestationCount(true)
+}
+
+// Return total fee of attestation infos confirmed since time
+func (d *DbFake) GetAttestationFees(since int64) (int64, error) {
+	var fees int64
+	for _, info := range d.AttestationsInfo {
+		if info.Blockhash != "" && info.Time >= since {
+			fees += info.Fee
+		}
+	}
+	return fees, nil
+}
+
 // Return latest attestation commitment hash
 func (d *DbFake) GetLatestAttestationMerkleRoot(confirmed bool) (string, error) {
 	count, _ := d.getAttestationCount(confirmed)
@@ -145,6 +294,31 @@ func (d *DbFake) getAttestationMerkleRoot(txid chainhash.Hash) (string, error) {
 	return "", nil
 }
 
+// Return confirmed attestations in the order these were stored
+func (d *DbFake) GetAttestations() ([]models.AttestationBSON, error) {
+	var attestations []models.AttestationBSON
+	for _, attestation := range d.Attestations {
+		if attestation.Confirmed {
+			attestationBSON := models.NewAttestationBSON(attestation)
+			attestationBSON.InsertedAt = time.Unix(attestation.Info.Time, 0)
+			attestations = append(attestations, attestationBSON)
+		}
+	}
+	return attestations, nil
+}
+
+// Return attestation document with given txid, empty if not found
+func (d *DbFake) GetAttestation(txid chainhash.Hash) (models.AttestationBSON, error) {
+	for _, attestation := range d.Attestations {
+		if attestation.Txid == txid {
+			attestationBSON := models.NewAttestationBSON(attestation)
+			attestationBSON.InsertedAt = time.Unix(attestation.Info.Time, 0)
+			return attestationBSON, nil
+		}
+	}
+	return models.AttestationBSON{}, nil
+}
+
 // Return commitment for attestation with given txid
 func (d *DbFake) GetAttestationMerkleCommitments(txid chainhash.Hash) ([]models.CommitmentMerkleCommitment, error) {
 	// get merkle root of attestation
@@ -165,6 +339,25 @@ func (d *DbFake) GetAttestationMerkleCommitments(txid chainhash.Hash) ([]models.
 	return MerkleCommitments, nil
 }
 
+// Return merkle proofs for attestation with given txid
+func (d *DbFake) GetAttestationMerkleProofs(txid chainhash.Hash) ([]models.CommitmentMerkleProof, error) {
+	// get merkle root of attestation
+	merkleRoot, rootErr := d.getAttestationMerkleRoot(txid)
+	if rootErr != nil {
+		return []models.CommitmentMerkleProof{}, rootErr
+	} else if merkleRoot == "" {
+		return []models.CommitmentMerkleProof{}, nil
+	}
+
+	var merkleProofs []models.CommitmentMerkleProof
+	for _, proof := range d.MerkleProofs {
+		if proof.MerkleRoot.String() == merkleRoot {
+			merkleProofs = append(merkleProofs, proof)
+		}
+	}
+	return merkleProofs, nil
+}
+
 // Set latest commitments for testing
 func (d *DbFake) SetClientCommitments(latestCommitments []models.ClientCommitment) {
 	d.latestCommitments = latestCommitments
@@ -174,3 +367,318 @@ func (d *DbFake) SetClientCommitments(latestCommitments []models.ClientCommitmen
 func (d *DbFake) GetClientCommitments() ([]models.ClientCommitment, error) {
 	return d.latestCommitments, nil
 }
+
+// Save client commitment replacing any existing commitment for the same position
+func (d *DbFake) SaveClientCommitment(commitment models.ClientCommitment) error {
+	for i, c := range d.latestCommitments {
+		if c.ClientPosition == commitment.ClientPosition {
+			d.latestCommitments[i] = commitment
+			return nil
+		}
+	}
+	d.latestCommitments = append(d.latestCommitments, commitment)
+	return nil
+}
+
+// Save client commitments of several client positions
+func (d *DbFake) SaveClientCommitments(commitments []models.ClientCommitment) error {
+	for _, commitment := range commitments {
+		d.SaveClientCommitment(commitment)
+	}
+	return nil
+}
+
+// Save commitment exclusion to Exclusions
+func (d *DbFake) SaveCommitmentExclusion(exclusion models.CommitmentExclusion) error {
+	d.Exclusions = append(d.Exclusions, exclusion)
+	return nil
+}
+
+// Return commitment exclusions of a client position from Exclusions
+func (d *DbFake) GetCommitmentExclusions(position int32) ([]models.CommitmentExclusion, error) {
+	exclusions := []models.CommitmentExclusion{}
+	for _, exclusion := range d.Exclusions {
+		if exclusion.ClientPosition == position {
+			exclusions = append(exclusions, exclusion)
+		}
+	}
+	return exclusions, nil
+}
+
+// Return commitment history page of a client position from MerkleCommitments
+// newest first, with the attestation committing to each merkle root
+func (d *DbFake) GetCommitmentHistory(position int32, offset int64, limit int64) (models.CommitmentHistory, error) {
+	history := models.CommitmentHistory{ClientPosition: position, Offset: offset, Limit: limit,
+		Commitments: []models.CommitmentHistoryEntry{}}
+	for i := len(d.MerkleCommitments) - 1; i >= 0; i-- {
+		commitment := d.MerkleCommitments[i]
+		if commitment.ClientPosition != position {
+			continue
+		}
+		history.Total++
+		if history.Total <= offset || int64(len(history.Commitments)) >= limit {
+			continue
+		}
+		entry := models.CommitmentHistoryEntry{
+			Commitment: commitment.Commitment.String(),
+			MerkleRoot: commitment.MerkleRoot.String(),
+		}
+		for _, attestation := range d.Attestations {
+			if attestation.CommitmentHash() == commitment.MerkleRoot && !entry.Confirmed {
+				entry.Txid = attestation.Txid.String()
+				entry.Confirmed = attestation.Confirmed
+			}
+		}
+		history.Commitments = append(history.Commitments, entry)
+	}
+	return history, nil
+}
+
+// Return round snapshot of round from RoundSnapshots
+func (d *DbFake) GetRoundSnapshot(round int64) (models.RoundSnapshot, error) {
+	for _, snapshot := range d.RoundSnapshots {
+		if snapshot.Round == round {
+			return snapshot, nil
+		}
+	}
+	return models.RoundSnapshot{}, nil
+}
+
+// Return page of round snapshots from RoundSnapshots, newest first
+func (d *DbFake) GetRoundSnapshots(merkleRoot string, offset int64, limit int64) (models.RoundSnapshots, error) {
+	snapshots := models.RoundSnapshots{Offset: offset, Limit: limit, Rounds: []models.RoundSnapshot{}}
+	for i := len(d.RoundSnapshots) - 1; i >= 0; i-- {
+		snapshot := d.RoundSnapshots[i]
+		if merkleRoot != "" && snapshot.MerkleRoot != merkleRoot {
+			continue
+		}
+		snapshots.Total++
+		if snapshots.Total <= offset || int64(len(snapshots.Rounds)) >= limit {
+			continue
+		}
+		snapshots.Rounds = append(snapshots.Rounds, snapshot)
+	}
+	return snapshots, nil
+}
+
+// Return commitment submission with id from Submissions
+func (d *DbFake) GetCommitmentSubmission(id string) (models.CommitmentSubmission, error) {
+	for _, submission := range d.Submissions {
+		if submission.Id == id {
+			return submission, nil
+		}
+	}
+	return models.CommitmentSubmission{}, nil
+}
+
+// Return latest commitment submission of each of the client positions from Submissions
+func (d *DbFake) GetLatestCommitmentSubmissions(positions []int32) ([]models.CommitmentSubmission, error) {
+	submissions := []models.CommitmentSubmission{}
+	for _, position := range positions {
+		for i := len(d.Submissions) - 1; i >= 0; i-- {
+			if d.Submissions[i].ClientPosition == position {
+				submissions = append(submissions, d.Submissions[i])
+				break
+			}
+		}
+	}
+	return submissions, nil
+}
+
+// Return pending commitment submissions from Submissions, oldest first
+func (d *DbFake) GetPendingCommitmentSubmissions() ([]models.CommitmentSubmission, error) {
+	submissions := []models.CommitmentSubmission{}
+	for _, submission := range d.Submissions {
+		if submission.Status == models.SubmissionStatusPending {
+			submissions = append(submissions, submission)
+		}
+	}
+	return submissions, nil
+}
+
+// Save client details replacing any existing details for the same position
+func (d *DbFake) SaveClientDetails(details models.ClientDetails) error {
+	for i, c := range d.clientDetails {
+		if c.ClientPosition == details.ClientPosition {
+			d.clientDetails[i] = details
+			return nil
+		}
+	}
+	d.clientDetails = append(d.clientDetails, details)
+	return nil
+}
+
+// Return client details from fake client details
+func (d *DbFake) GetClientDetails() ([]models.ClientDetails, error) {
+	return d.clientDetails, nil
+}
+
+// Save slot group replacing any existing group with the same merkle root
+func (d *DbFake) SaveSlotGroup(group models.SlotGroup) error {
+	for i, g := range d.SlotGroups {
+		if g.ClientPosition == group.ClientPosition && g.MerkleRoot == group.MerkleRoot {
+			d.SlotGroups[i] = group
+			return nil
+		}
+	}
+	d.SlotGroups = append(d.SlotGroups, group)
+	return nil
+}
+
+// Return latest slot group for client position containing member commitment
+func (d *DbFake) GetSlotGroup(position int32, commitment chainhash.Hash) (models.SlotGroup, error) {
+	for i := len(d.SlotGroups) - 1; i >= 0; i-- {
+		if d.SlotGroups[i].ClientPosition != position {
+			continue
+		}
+		for _, c := range d.SlotGroups[i].Commitments {
+			if c == commitment {
+				return d.SlotGroups[i], nil
+			}
+		}
+	}
+	return models.SlotGroup{}, nil
+}
+
+// Return first merkle proof for commitment in client position
+func (d *DbFake) GetCommitmentMerkleProof(position int32, commitment chainhash.Hash) (models.CommitmentMerkleProof, error) {
+	for _, proof := range d.MerkleProofs {
+		if proof.ClientPosition == position && proof.Commitment == commitment {
+			return proof, nil
+		}
+	}
+	return models.CommitmentMerkleProof{}, nil
+}
+
+// Return earliest merkle proof of the commitment in any client position
+func (d *DbFake) GetMerkleProofByCommitment(commitment chainhash.Hash) (models.CommitmentMerkleProof, error) {
+	for _, proof := range d.MerkleProofs {
+		if proof.Commitment == commitment {
+			return proof, nil
+		}
+	}
+	return models.CommitmentMerkleProof{}, nil
+}
+
+// Return merkle proof for client position in the latest confirmed attestation
+func (d *DbFake) GetLatestCommitmentMerkleProof(position int32) (models.CommitmentMerkleProof, error) {
+	merkleRoot, rootErr := d.GetLatestAttestationMerkleRoot(true)
+	if rootErr != nil || merkleRoot == "" {
+		return models.CommitmentMerkleProof{}, rootErr
+	}
+	for _, proof := range d.MerkleProofs {
+		if proof.ClientPosition == position && proof.MerkleRoot.String() == merkleRoot {
+			return proof, nil
+		}
+	}
+	return models.CommitmentMerkleProof{}, nil
+}
+
+// Return attestation info of the attestation committing to the merkle root
+// preferring confirmed attestations
+func (d *DbFake) GetAttestationInfoByMerkleRoot(merkleRoot chainhash.Hash) (models.AttestationInfo, error) {
+	var txid string
+	for _, attestation := range d.Attestations {
+		if attestation.CommitmentHash() == merkleRoot && (txid == "" || attestation.Confirmed) {
+			txid = attestation.Txid.String()
+			if attestation.Confirmed {
+				break
+			}
+		}
+	}
+	if txid == "" {
+		return models.AttestationInfo{}, nil
+	}
+	for _, info := range d.AttestationsInfo {
+		if info.Txid == txid {
+			return info, nil
+		}
+	}
+	return models.AttestationInfo{Txid: txid}, nil
+}
+
+// Save staychain tx to StaychainTxs replacing any with the same txid
+func (d *DbFake) SaveStaychainTx(tx models.StaychainTx) error {
+	for i, t := range d.StaychainTxs {
+		if t.Txid == tx.Txid {
+			d.StaychainTxs[i] = tx
+			return nil
+		}
+	}
+	d.StaychainTxs = append(d.StaychainTxs, tx)
+	return nil
+}
+
+// Return staychain tx with txid from StaychainTxs
+func (d *DbFake) GetStaychainTx(txid string) (models.StaychainTx, error) {
+	for _, tx := range d.StaychainTxs {
+		if tx.Txid == txid {
+			return tx, nil
+		}
+	}
+	return models.StaychainTx{}, nil
+}
+
+// Return staychain tx with the highest index from StaychainTxs
+func (d *DbFake) GetLatestStaychainTx() (models.StaychainTx, error) {
+	latest := models.StaychainTx{}
+	for _, tx := range d.StaychainTxs {
+		if latest.Txid == "" || tx.Index > latest.Index {
+			latest = tx
+		}
+	}
+	return latest, nil
+}
+
+// Return page of staychain txs from StaychainTxs, latest first
+func (d *DbFake) GetStaychainTxs(offset int64, limit int64) (models.StaychainTxs, error) {
+	txs := models.StaychainTxs{Offset: offset, Limit: limit, Txs: []models.StaychainTx{}}
+	sorted := append([]models.StaychainTx{}, d.StaychainTxs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Index > sorted[j].Index })
+	for _, tx := range sorted {
+		txs.Total++
+		if txs.Total <= offset || int64(len(txs.Txs)) >= limit {
+			continue
+		}
+		txs.Txs = append(txs.Txs, tx)
+	}
+	return txs, nil
+}
+
+// Return page of confirmed attestations from Attestations, newest first,
+// with block height from minHeight to maxHeight unless negative
+func (d *DbFake) GetAttestationsPage(minHeight int64, maxHeight int64, offset int64, limit int64) (
+	[]models.AttestationBSON, error) {
+	attestations, _ := d.GetAttestations()
+	page := []models.AttestationBSON{}
+	for i := len(attestations) - 1; i >= 0 && int64(len(page)) < limit; i-- {
+		height := attestations[i].BlockHeight
+		if (minHeight >= 0 && height < minHeight) || (maxHeight >= 0 && height > maxHeight) {
+			continue
+		} else if offset > 0 {
+			offset--
+			continue
+		}
+		page = append(page, attestations[i])
+	}
+	return page, nil
+}
+
+// Save signer status to SignerStatuses replacing any with the same pubkey
+func (d *DbFake) SaveSignerStatus(status models.SignerStatus) error {
+	for i, s := range d.SignerStatuses {
+		if s.Pubkey == status.Pubkey {
+			d.SignerStatuses[i] = status
+			return nil
+		}
+	}
+	d.SignerStatuses = append(d.SignerStatuses, status)
+	return nil
+}
+
+// Return signer statuses from SignerStatuses ordered by position
+func (d *DbFake) GetSignerStatuses() ([]models.SignerStatus, error) {
+	statuses := append([]models.SignerStatus{}, d.SignerStatuses...)
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Position < statuses[j].Position })
+	return statuses, nil
+}
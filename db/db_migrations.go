@@ -0,0 +1,322 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"mainstay/log"
+	"mainstay/models"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx"
+)
+
+// migration consts
+const (
+	ColNameMigration = "Migration"
+
+	// mongo error codes
+	mongoCodeNamespaceExists = 48
+	mongoCodeDuplicateKey    = 11000
+
+	ErrorMigrationsGet    = "could not get applied migrations"
+	ErrorMigrationSave    = "could not save migration"
+	ErrorMigrationFailed  = "migration failed"
+	ErrorMigrationOrder   = "migrations not in increasing version order"
+	ErrorMigrationUnknown = "database migrated by newer version - unknown migration"
+	ErrorMigrationDb      = "database not connected - cannot migrate"
+
+	BadDataMigrationModel = "bad data in migration model"
+)
+
+// Migration struct
+// Versioned change to the mongo collections, applied once in order of version
+// Migrations must be idempotent, as a migration interrupted before being
+// recorded or run concurrently by another instance is applied again
+type Migration struct {
+	Version int32
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+}
+
+// Migrations of the mongo collections in order of version
+// New migrations are appended with the next version and never edited once released
+var Migrations = []Migration{
+	{1, "attestation_indexes", func(ctx context.Context, db *mongo.Database) error {
+		if err := CreateIndexes(ctx, db, ColNameAttestation,
+			bsonx.Doc{{models.AttestationTxidName, bsonx.Int32(1)}, {models.AttestationMerkleRootName, bsonx.Int32(1)}},
+			bsonx.Doc{{models.AttestationMerkleRootName, bsonx.Int32(1)}},
+			bsonx.Doc{{models.AttestationConfirmedName, bsonx.Int32(1)}, {models.AttestationInsertedAtName, bsonx.Int32(1)}},
+		); err != nil {
+			return err
+		}
+		return CreateIndexes(ctx, db, ColNameAttestationInfo,
+			bsonx.Doc{{models.AttestationInfoTxidName, bsonx.Int32(1)}})
+	}},
+	{2, "commitment_indexes", func(ctx context.Context, db *mongo.Database) error {
+		if err := CreateIndexes(ctx, db, ColNameMerkleCommitment,
+			bsonx.Doc{{models.CommitmentMerkleRootName, bsonx.Int32(1)}, {models.CommitmentClientPositionName, bsonx.Int32(1)}},
+		); err != nil {
+			return err
+		}
+		return CreateIndexes(ctx, db, ColNameMerkleProof,
+			bsonx.Doc{{models.ProofMerkleRootName, bsonx.Int32(1)}, {models.ProofClientPositionName, bsonx.Int32(1)}},
+			bsonx.Doc{{models.ProofClientPositionName, bsonx.Int32(1)}, {models.ProofCommitmentName, bsonx.Int32(1)}},
+		)
+	}},
+	{3, "client_indexes", func(ctx context.Context, db *mongo.Database) error {
+		if err := CreateIndexes(ctx, db, ColNameClientDetails,
+			bsonx.Doc{{models.ClientDetailsClientPositionName, bsonx.Int32(1)}}); err != nil {
+			return err
+		}
+		if err := CreateIndexes(ctx, db, ColNameClientCommitment,
+			bsonx.Doc{{models.ClientCommitmentClientPositionName, bsonx.Int32(1)}}); err != nil {
+			return err
+		}
+		if err := CreateIndexes(ctx, db, ColNameExclusion,
+			bsonx.Doc{{models.CommitmentExclusionClientPositionName, bsonx.Int32(1)},
+				{models.CommitmentExclusionRoundCloseName, bsonx.Int32(1)}}); err != nil {
+			return err
+		}
+		return CreateIndexes(ctx, db, ColNameSlotGroup,
+			bsonx.Doc{{models.SlotGroupClientPositionName, bsonx.Int32(1)}, {models.SlotGroupMerkleRootName, bsonx.Int32(1)}},
+			bsonx.Doc{{models.SlotGroupClientPositionName, bsonx.Int32(1)}, {models.SlotGroupCommitmentsName, bsonx.Int32(1)}},
+		)
+	}},
+	{4, "service_collections", func(ctx context.Context, db *mongo.Database) error {
+		for _, name := range []string{ColNameServiceState, ColNameKeyRotation, ColNameInFlight} {
+			if err := CreateCollection(ctx, db, name); err != nil {
+				return err
+			}
+		}
+		if err := CreateIndexes(ctx, db, ColNameServiceState,
+			bsonx.Doc{{models.ServiceStateNameName, bsonx.Int32(1)}}); err != nil {
+			return err
+		}
+		return CreateIndexes(ctx, db, ColNameKeyRotation,
+			bsonx.Doc{{models.KeyRotationIdName, bsonx.Int32(1)}})
+	}},
+	{5, "commitment_history_indexes", func(ctx context.Context, db *mongo.Database) error {
+		return CreateIndexes(ctx, db, ColNameMerkleCommitment,
+			bsonx.Doc{{models.CommitmentClientPositionName, bsonx.Int32(1)}, {"_id", bsonx.Int32(-1)}})
+	}},
+	{6, "round_snapshot_indexes", func(ctx context.Context, db *mongo.Database) error {
+		if _, err := db.Collection(ColNameRoundSnapshot).Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bsonx.Doc{{models.RoundSnapshotRoundName, bsonx.Int32(1)}},
+			Options: options.Index().SetUnique(true),
+		}); err != nil {
+			return err
+		}
+		return CreateIndexes(ctx, db, ColNameRoundSnapshot,
+			bsonx.Doc{{models.RoundSnapshotMerkleRootName, bsonx.Int32(1)}})
+	}},
+	{7, "commitment_submission_indexes", func(ctx context.Context, db *mongo.Database) error {
+		if _, err := db.Collection(ColNameSubmission).Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bsonx.Doc{{models.CommitmentSubmissionIdName, bsonx.Int32(1)}},
+			Options: options.Index().SetUnique(true),
+		}); err != nil {
+			return err
+		}
+		return CreateIndexes(ctx, db, ColNameSubmission,
+			bsonx.Doc{{models.CommitmentSubmissionClientPositionName, bsonx.Int32(1)}, {"_id", bsonx.Int32(-1)}},
+			bsonx.Doc{{models.CommitmentSubmissionStatusName, bsonx.Int32(1)}})
+	}},
+	{8, "merkle_proof_commitment_indexes", func(ctx context.Context, db *mongo.Database) error {
+		return CreateIndexes(ctx, db, ColNameMerkleProof,
+			bsonx.Doc{{models.ProofCommitmentName, bsonx.Int32(1)}})
+	}},
+	{9, "staychain_tx_indexes", func(ctx context.Context, db *mongo.Database) error {
+		if _, err := db.Collection(ColNameStaychainTx).Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bsonx.Doc{{models.StaychainTxTxidName, bsonx.Int32(1)}},
+			Options: options.Index().SetUnique(true),
+		}); err != nil {
+			return err
+		}
+		return CreateIndexes(ctx, db, ColNameStaychainTx,
+			bsonx.Doc{{models.StaychainTxIndexName, bsonx.Int32(-1)}})
+	}},
+	{10, "attestation_blocks", func(ctx context.Context, db *mongo.Database) error {
+		// block of confirmed attestations copied from the attestation info
+		// fee paid and confirmation time are not known for past attestations
+		filter := bsonx.Doc{{models.AttestationInfoBlockhashName, bsonx.Document(bsonx.Doc{{"$ne", bsonx.String("")}})}}
+		res, resErr := db.Collection(ColNameAttestationInfo).Find(ctx, filter)
+		if resErr != nil {
+			return resErr
+		}
+		for res.Next(ctx) {
+			var info models.AttestationInfo
+			if err := res.Decode(&info); err != nil {
+				return err
+			}
+			filterAttestation := bsonx.Doc{
+				{models.AttestationTxidName, bsonx.String(info.Txid)},
+				{models.AttestationConfirmedName, bsonx.Boolean(true)},
+			}
+			update := bsonx.Doc{{"$set", bsonx.Document(bsonx.Doc{
+				{models.AttestationBlockHeightName, bsonx.Int64(info.Height)},
+				{models.AttestationBlockHashName, bsonx.String(info.Blockhash)},
+			})}}
+			if _, err := db.Collection(ColNameAttestation).UpdateMany(ctx, filterAttestation, update); err != nil {
+				return err
+			}
+		}
+		if err := res.Err(); err != nil {
+			return err
+		}
+		return CreateIndexes(ctx, db, ColNameAttestation,
+			bsonx.Doc{{models.AttestationBlockHeightName, bsonx.Int32(1)}})
+	}},
+	{11, "attestation_info_time", func(ctx context.Context, db *mongo.Database) error {
+		return CreateIndexes(ctx, db, ColNameAttestationInfo,
+			bsonx.Doc{{models.AttestationInfoTimeName, bsonx.Int32(1)}})
+	}},
+	{12, "signer_status_indexes", func(ctx context.Context, db *mongo.Database) error {
+		if err := CreateCollection(ctx, db, ColNameSignerStatus); err != nil {
+			return err
+		}
+		return CreateIndexes(ctx, db, ColNameSignerStatus,
+			bsonx.Doc{{models.SignerStatusPubkeyName, bsonx.Int32(1)}})
+	}},
+}
+
+// Apply pending migrations to the mongo database
+func (d *DbMongo) Migrate() error {
+	if d.db == nil {
+		return errors.New(ErrorMigrationDb)
+	}
+	return RunMigrations(d.ctx, d.db, Migrations)
+}
+
+// Apply migrations not yet recorded in the Migration collection in order
+// of version, recording each migration once it has been applied
+func RunMigrations(ctx context.Context, db *mongo.Database, migrations []Migration) error {
+	col := db.Collection(ColNameMigration)
+	if _, indexErr := col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bsonx.Doc{{models.MigrationVersionName, bsonx.Int32(1)}},
+		Options: options.Index().SetUnique(true),
+	}); indexErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorMigrationSave, indexErr))
+	}
+
+	res, resErr := col.Find(ctx, bsonx.Doc{})
+	if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorMigrationsGet, resErr))
+	}
+	var applied []models.Migration
+	for res.Next(ctx) {
+		var migrationDoc bsonx.Doc
+		if err := res.Decode(&migrationDoc); err != nil {
+			return errors.New(fmt.Sprintf("%s %v", ErrorMigrationsGet, err))
+		}
+		migrationModel := &models.Migration{}
+		if modelErr := models.GetModelFromDocument(&migrationDoc, migrationModel); modelErr != nil {
+			return errors.New(fmt.Sprintf("%s %v", BadDataMigrationModel, modelErr))
+		}
+		applied = append(applied, *migrationModel)
+	}
+	if err := res.Err(); err != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorMigrationsGet, err))
+	}
+
+	pending, pendingErr := pendingMigrations(migrations, applied)
+	if pendingErr != nil {
+		return pendingErr
+	}
+	for _, migration := range pending {
+		log.Infof("*Db* Applying migration %d %s\n", migration.Version, migration.Name)
+		if upErr := migration.Up(ctx, db); upErr != nil {
+			return errors.New(fmt.Sprintf("%s %d %s: %v", ErrorMigrationFailed, migration.Version, migration.Name, upErr))
+		}
+
+		docMigration, docErr := models.GetDocumentFromModel(models.Migration{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			AppliedAt: time.Now().Unix(),
+		})
+		if docErr != nil {
+			return errors.New(fmt.Sprintf("%s %v", BadDataMigrationModel, docErr))
+		}
+		// applied concurrently by another instance if already recorded
+		if _, insertErr := col.InsertOne(ctx, docMigration); insertErr != nil && !isMongoErrorCode(insertErr, mongoCodeDuplicateKey) {
+			return errors.New(fmt.Sprintf("%s %v", ErrorMigrationSave, insertErr))
+		}
+	}
+	return nil
+}
+
+// Return migrations not yet applied in order of version
+// Applied migrations unknown to this version are rejected, as the
+// collections may have changed in ways this version does not support
+func pendingMigrations(migrations []Migration, applied []models.Migration) ([]Migration, error) {
+	known := make(map[int32]bool)
+	for i, migration := range migrations {
+		if i > 0 && migration.Version <= migrations[i-1].Version {
+			return nil, errors.New(fmt.Sprintf("%s: %d", ErrorMigrationOrder, migration.Version))
+		}
+		known[migration.Version] = true
+	}
+	isApplied := make(map[int32]bool)
+	for _, migration := range applied {
+		if !known[migration.Version] {
+			return nil, errors.New(fmt.Sprintf("%s %d %s", ErrorMigrationUnknown, migration.Version, migration.Name))
+		}
+		isApplied[migration.Version] = true
+	}
+	var pending []Migration
+	for _, migration := range migrations {
+		if !isApplied[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Create indexes with the given keys on a collection
+// Existing indexes with the same keys and options are left unchanged
+func CreateIndexes(ctx context.Context, db *mongo.Database, collection string, keys ...bsonx.Doc) error {
+	var indexes []mongo.IndexModel
+	for _, key := range keys {
+		indexes = append(indexes, mongo.IndexModel{Keys: key})
+	}
+	_, err := db.Collection(collection).Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// Create collection if it does not exist
+func CreateCollection(ctx context.Context, db *mongo.Database, collection string) error {
+	err := db.RunCommand(ctx, bsonx.Doc{{"create", bsonx.String(collection)}}).Err()
+	if err != nil && !isMongoErrorCode(err, mongoCodeNamespaceExists) {
+		return err
+	}
+	return nil
+}
+
+// Rename field in all documents of a collection
+func RenameField(ctx context.Context, db *mongo.Database, collection string, from string, to string) error {
+	filter := bsonx.Doc{{from, bsonx.Document(bsonx.Doc{{"$exists", bsonx.Boolean(true)}})}}
+	rename := bsonx.Doc{{"$rename", bsonx.Document(bsonx.Doc{{from, bsonx.String(to)}})}}
+	_, err := db.Collection(collection).UpdateMany(ctx, filter, rename)
+	return err
+}
+
+// Return whether the error is a mongo command or write error with code
+func isMongoErrorCode(err error, code int32) bool {
+	switch e := err.(type) {
+	case mongo.CommandError:
+		return e.Code == code
+	case mongo.WriteException:
+		for _, writeErr := range e.WriteErrors {
+			if int32(writeErr.Code) == code {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	"mainstay/models"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Test pending migrations are selected in order of version
+func TestPendingMigrations(t *testing.T) {
+	migrations := []Migration{{Version: 1, Name: "one"}, {Version: 2, Name: "two"}, {Version: 4, Name: "four"}}
+
+	pending, pendingErr := pendingMigrations(migrations, nil)
+	assert.Equal(t, nil, pendingErr)
+	assert.Equal(t, 3, len(pending))
+
+	pending, pendingErr = pendingMigrations(migrations, []models.Migration{{Version: 2, Name: "two"}})
+	assert.Equal(t, nil, pendingErr)
+	assert.Equal(t, []int32{1, 4}, []int32{pending[0].Version, pending[1].Version})
+
+	pending, pendingErr = pendingMigrations(migrations, []models.Migration{{Version: 1}, {Version: 2}, {Version: 4}})
+	assert.Equal(t, nil, pendingErr)
+	assert.Equal(t, 0, len(pending))
+
+	// database migrated by a newer version
+	_, pendingErr = pendingMigrations(migrations, []models.Migration{{Version: 5, Name: "five"}})
+	assert.Equal(t, ErrorMigrationUnknown+" 5 five", pendingErr.Error())
+
+	// migrations out of order
+	_, pendingErr = pendingMigrations([]Migration{{Version: 2}, {Version: 2}}, nil)
+	assert.Equal(t, ErrorMigrationOrder+": 2", pendingErr.Error())
+}
+
+// Test released migrations are in order
+func TestMigrations(t *testing.T) {
+	pending, pendingErr := pendingMigrations(Migrations, nil)
+	assert.Equal(t, nil, pendingErr)
+	assert.Equal(t, len(Migrations), len(pending))
+	for _, migration := range Migrations {
+		assert.NotEqual(t, "", migration.Name)
+		assert.NotNil(t, migration.Up)
+	}
+}
+
+// Test mongo error code matching
+func TestIsMongoErrorCode(t *testing.T) {
+	assert.Equal(t, true, isMongoErrorCode(mongo.CommandError{Code: mongoCodeNamespaceExists}, mongoCodeNamespaceExists))
+	assert.Equal(t, false, isMongoErrorCode(mongo.CommandError{Code: 1}, mongoCodeNamespaceExists))
+	assert.Equal(t, true, isMongoErrorCode(mongo.WriteException{
+		WriteErrors: mongo.WriteErrors{{Code: mongoCodeDuplicateKey}}}, mongoCodeDuplicateKey))
+	assert.Equal(t, false, isMongoErrorCode(mongo.ErrNoDocuments, mongoCodeDuplicateKey))
+}
@@ -6,8 +6,15 @@ package db
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strings"
+	"time"
 
 	"mainstay/config"
 	"mainstay/log"
@@ -17,59 +24,298 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/x/bsonx"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
 )
 
 const (
 	// collection names
-	ColNameAttestation      = "Attestation"
-	ColNameAttestationInfo  = "AttestationInfo"
-	ColNameMerkleCommitment = "MerkleCommitment"
-	ColNameMerkleProof      = "MerkleProof"
-	ColNameClientCommitment = "ClientCommitment"
-	ColNameClientDetails    = "ClientDetails"
+	ColNameAttestation       = "Attestation"
+	ColNameAttestationInfo   = "AttestationInfo"
+	ColNameMerkleCommitment  = "MerkleCommitment"
+	ColNameMerkleProof       = "MerkleProof"
+	ColNameClientCommitment  = "ClientCommitment"
+	ColNameClientDetails     = "ClientDetails"
+	ColNameFeeBump           = "FeeBump"
+	ColNameDryRunAttestation = "DryRunAttestation"
+	ColNameServiceState      = "ServiceState"
+	ColNameSlotGroup         = "SlotGroup"
+	ColNameInFlight          = "InFlightAttestation"
+	ColNameExclusion         = "CommitmentExclusion"
+	ColNameKeyRotation       = "KeyRotation"
+	ColNameRoundSnapshot     = "RoundSnapshot"
+	ColNameSubmission        = "CommitmentSubmission"
+	ColNameStaychainTx       = "StaychainTx"
+	ColNameSignerStatus      = "SignerStatus"
 
 	// error messages
 	ErrorMongoClient  = "could not create mongoDB client"
 	ErrorMongoConnect = "could not connect to mongoDB client"
 	ErrorMongoPing    = "could not ping mongoDB database"
 
-	ErrorAttestationSave      = "could not save attestation"
-	ErrorAttestationInfoSave  = "could not save attestation info"
-	ErrorMerkleCommitmentSave = "could not save merkle commitment"
-	ErrorMerkleProofSave      = "could not save merkle proof"
-	ErrorClientDetailsSave    = "could not save client details"
-	ErrorClientCommitmentSave = "could not save client commitment"
+	ErrorDbUri         = "invalid db connection string"
+	ErrorDbOptions     = "invalid db connection string options"
+	ErrorDbNameMissing = "db name missing from config and connection string"
 
-	ErrorAttestationGet      = "could not get attestation"
-	ErrorMerkleCommitmentGet = "could not get merkle commitment"
-	ErrorMerkleProofGet      = "could not get merkle proof"
-	ErrorClientCommitmentGet = "could not get client commitment"
-	ErrorClientDetailsGet    = "could not get client details"
+	ErrorDbTlsCa           = "could not read db tls ca file"
+	ErrorDbTlsCert         = "could not read db tls client certificate"
+	ErrorDbTlsCertMissing  = "db tls key file set without certificate file"
+	ErrorDbX509CertMissing = "db x509 authentication requires a tls certificate file"
+	ErrorDbSrvPort         = "db port not allowed with srv host"
+
+	// mechanism authenticating with the subject of the tls client certificate
+	DbAuthMechanismX509 = "MONGODB-X509"
+
+	ErrorAttestationSave         = "could not save attestation"
+	ErrorAttestationInfoSave     = "could not save attestation info"
+	ErrorMerkleCommitmentSave    = "could not save merkle commitment"
+	ErrorMerkleProofSave         = "could not save merkle proof"
+	ErrorClientDetailsSave       = "could not save client details"
+	ErrorClientCommitmentSave    = "could not save client commitment"
+	ErrorFeeBumpSave             = "could not save fee bump"
+	ErrorDryRunAttestationSave   = "could not save dry run attestation"
+	ErrorServiceStateSave        = "could not save service state"
+	ErrorSlotGroupSave           = "could not save slot group"
+	ErrorInFlightSave            = "could not save in flight attestation"
+	ErrorInFlightDelete          = "could not delete in flight attestation"
+	ErrorCommitmentExclusionSave = "could not save commitment exclusion"
+	ErrorKeyRotationSave         = "could not save key rotation"
+	ErrorRoundSnapshotSave       = "could not save round snapshot"
+	ErrorSubmissionSave          = "could not save commitment submission"
+	ErrorStaychainTxSave         = "could not save staychain tx"
+	ErrorSignerStatusSave        = "could not save signer status"
+
+	ErrorAttestationGet         = "could not get attestation"
+	ErrorMerkleCommitmentGet    = "could not get merkle commitment"
+	ErrorMerkleProofGet         = "could not get merkle proof"
+	ErrorClientCommitmentGet    = "could not get client commitment"
+	ErrorClientDetailsGet       = "could not get client details"
+	ErrorServiceStateGet        = "could not get service state"
+	ErrorSlotGroupGet           = "could not get slot group"
+	ErrorInFlightGet            = "could not get in flight attestation"
+	ErrorCommitmentExclusionGet = "could not get commitment exclusion"
+	ErrorKeyRotationGet         = "could not get key rotation"
+	ErrorRoundSnapshotGet       = "could not get round snapshot"
+	ErrorSubmissionGet          = "could not get commitment submission"
+	ErrorStaychainTxGet         = "could not get staychain tx"
+	ErrorSignerStatusGet        = "could not get signer status"
 
 	BadDataClientCommitmentCol = "bad data in client commitment collection"
 	BadDataMerkleCommitmentCol = "bad data in merkle commitment collection"
+	BadDataMerkleProofCol      = "bad data in merkle proof collection"
 	BadDataClientDetailsCol    = "bad data in client details collection"
+	BadDataExclusionCol        = "bad data in commitment exclusion collection"
+	BadDataKeyRotationCol      = "bad data in key rotation collection"
+	BadDataRoundSnapshotCol    = "bad data in round snapshot collection"
+	BadDataSubmissionCol       = "bad data in commitment submission collection"
+	BadDataStaychainTxCol      = "bad data in staychain tx collection"
+	BadDataSignerStatusCol     = "bad data in signer status collection"
+
+	BadDataAttestationModel       = "bad data in attestation model"
+	BadDataAttestationInfoModel   = "bad data in attestation info model"
+	BadDataMerkleCommitmentModel  = "bad data in merkle commitment model"
+	BadDataMerkleProofModel       = "bad data in merkle proof model"
+	BadDataClientDetailsModel     = "bad data in client details model"
+	BadDataClientCommitmentModel  = "bad data in client commitment model"
+	BadDataFeeBumpModel           = "bad data in fee bump model"
+	BadDataDryRunAttestationModel = "bad data in dry run attestation model"
+	BadDataServiceStateModel      = "bad data in service state model"
+	BadDataSlotGroupModel         = "bad data in slot group model"
+	BadDataInFlightModel          = "bad data in in flight attestation model"
+	BadDataExclusionModel         = "bad data in commitment exclusion model"
+	BadDataKeyRotationModel       = "bad data in key rotation model"
+	BadDataRoundSnapshotModel     = "bad data in round snapshot model"
+	BadDataSubmissionModel        = "bad data in commitment submission model"
+	BadDataStaychainTxModel       = "bad data in staychain tx model"
+	BadDataSignerStatusModel      = "bad data in signer status model"
+
+	// timeout for storing state on shutdown after the service context is cancelled
+	DbShutdownTimeout = 10 * time.Second
+
+	// timeout for database health check pings
+	DbPingTimeout = 5 * time.Second
+
+	// default timeout of each db operation, also bounding server selection
+	// so that operations fail rather than block while the database is down
+	DbDefaultTimeout = 30 * time.Second
+
+	// interval of the client checking the database server is reachable
+	DbHeartbeatInterval = 10 * time.Second
 
-	BadDataAttestationModel      = "bad data in attestation model"
-	BadDataAttestationInfoModel  = "bad data in attestation info model"
-	BadDataMerkleCommitmentModel = "bad data in merkle commitment model"
-	BadDataMerkleProofModel      = "bad data in merkle proof model"
-	BadDataClientDetailsModel    = "bad data in client details model"
-	BadDataClientCommitmentModel = "bad data in client commitment model"
+	WarningInvalidDbPoolSizeArg = "Invalid db connection pool size config value"
+	WarningInvalidDbTimeoutArg  = "Invalid db operation timeout config value"
+	WarningDbUnreachable        = "Mongo database not reachable on startup"
 )
 
-// Method to connect to mongo database through config
-func dbConnect(ctx context.Context, dbConnectivity config.DbConfig) (*mongo.Database, error) {
-	// get this from config
-	uri := fmt.Sprintf(`mongodb://%s:%s@%s:%s/%s?connect=direct`,
-		dbConnectivity.User,
-		dbConnectivity.Password,
-		dbConnectivity.Host,
-		dbConnectivity.Port,
-		dbConnectivity.Name,
-	)
-
-	client, err := mongo.NewClient(options.Client().ApplyURI(uri))
+// Return connection string uri of config, the configured uri or one built
+// from the connectivity details, with any connection string options added
+// A direct connection is made to a single host unless a replica set is named
+func DbUri(dbConnectivity config.DbConfig) (string, error) {
+	uri := dbConnectivity.Uri
+	if uri == "" {
+		// host joined with port bracketing IPv6 literals
+		dbUrl := url.URL{
+			Scheme: connstring.SchemeMongoDB,
+			Host:   net.JoinHostPort(dbConnectivity.Host, dbConnectivity.Port),
+			Path:   "/" + dbConnectivity.Name,
+		}
+		// srv hosts are resolved to the hosts of their SRV records
+		if dbConnectivity.Srv {
+			dbUrl.Scheme = connstring.SchemeMongoDBSRV
+			dbUrl.Host = dbConnectivity.Host
+		}
+		// x509 users are the subject of the client certificate with no password
+		if dbConnectivity.X509 && dbConnectivity.User != "" {
+			dbUrl.User = url.User(dbConnectivity.User)
+		} else if dbConnectivity.User != "" {
+			dbUrl.User = url.UserPassword(dbConnectivity.User, dbConnectivity.Password)
+		}
+		uri = dbUrl.String()
+	}
+
+	options, optionsErr := url.ParseQuery(dbConnectivity.Options)
+	if optionsErr != nil {
+		return "", errors.New(fmt.Sprintf("%s %v", ErrorDbOptions, optionsErr))
+	}
+	if dbConnectivity.Options != "" {
+		uri = dbAddQuery(uri, dbConnectivity.Options)
+	}
+	if dbConnectivity.X509 {
+		uri = dbAddQuery(uri, "authMechanism="+DbAuthMechanismX509)
+	}
+	if dbConnectivity.Uri == "" && !dbConnectivity.Srv {
+		direct := true
+		for name := range options {
+			if strings.EqualFold(name, "replicaSet") || strings.EqualFold(name, "connect") {
+				direct = false
+			}
+		}
+		if direct {
+			uri = dbAddQuery(uri, "connect=direct")
+		}
+	}
+	return uri, nil
+}
+
+// Return parsed connection string of config
+// The SRV records of srv connection strings are resolved
+func DbConnString(dbConnectivity config.DbConfig) (connstring.ConnString, error) {
+	uri, uriErr := DbUri(dbConnectivity)
+	if uriErr != nil {
+		return connstring.ConnString{}, uriErr
+	}
+	cs, csErr := connstring.Parse(uri)
+	if csErr != nil {
+		return connstring.ConnString{}, errors.New(fmt.Sprintf("%s %v", ErrorDbUri, csErr))
+	}
+	return cs, nil
+}
+
+// Check db connection string and tls config, without resolving the
+// SRV records of srv connection strings
+func ValidateDbConfig(dbConnectivity config.DbConfig) error {
+	if dbConnectivity.TlsKeyFile != "" && dbConnectivity.TlsCertFile == "" {
+		return errors.New(ErrorDbTlsCertMissing)
+	}
+	if dbConnectivity.X509 && dbConnectivity.TlsCertFile == "" {
+		return errors.New(ErrorDbX509CertMissing)
+	}
+	if dbConnectivity.Srv && dbConnectivity.Uri == "" && dbConnectivity.Port != "" {
+		return errors.New(ErrorDbSrvPort)
+	}
+	uri, uriErr := DbUri(dbConnectivity)
+	if uriErr != nil {
+		return uriErr
+	}
+	if strings.HasPrefix(uri, connstring.SchemeMongoDBSRV+"://") {
+		if dbConnectivity.Name == "" && dbConnectivity.Uri == "" {
+			return errors.New(ErrorDbNameMissing)
+		}
+		return nil
+	}
+	cs, csErr := connstring.Parse(uri)
+	if csErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorDbUri, csErr))
+	}
+	if dbConnectivity.Name == "" && cs.Database == "" {
+		return errors.New(ErrorDbNameMissing)
+	}
+	return nil
+}
+
+// Return tls config of db config or nil if tls is not enabled
+// The client key is read from the certificate file if no key file is set
+func dbTlsConfig(dbConnectivity config.DbConfig) (*tls.Config, error) {
+	if !dbConnectivity.Tls {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if dbConnectivity.TlsCaFile != "" {
+		ca, caErr := ioutil.ReadFile(dbConnectivity.TlsCaFile)
+		if caErr != nil {
+			return nil, errors.New(fmt.Sprintf("%s %v", ErrorDbTlsCa, caErr))
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, errors.New(fmt.Sprintf("%s %s", ErrorDbTlsCa, dbConnectivity.TlsCaFile))
+		}
+	}
+	if dbConnectivity.TlsCertFile != "" {
+		keyFile := dbConnectivity.TlsKeyFile
+		if keyFile == "" {
+			keyFile = dbConnectivity.TlsCertFile
+		}
+		cert, certErr := tls.LoadX509KeyPair(dbConnectivity.TlsCertFile, keyFile)
+		if certErr != nil {
+			return nil, errors.New(fmt.Sprintf("%s %v", ErrorDbTlsCert, certErr))
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// Return uri with the query appended to any existing query
+func dbAddQuery(uri string, query string) string {
+	if strings.Contains(uri, "?") {
+		return uri + "&" + query
+	}
+	return uri + "?" + query
+}
+
+// Return mongo database connected using connectivity details from config
+// The client keeps a pool of connections to the database that are
+// re-established in the background after a dropped connection
+func newDbClient(ctx context.Context, dbConnectivity config.DbConfig) (*mongo.Database, error) {
+	cs, csErr := DbConnString(dbConnectivity)
+	if csErr != nil {
+		return nil, errors.New(fmt.Sprintf("%s %v", ErrorMongoClient, csErr))
+	}
+	name := dbConnectivity.Name
+	if name == "" {
+		name = cs.Database
+	}
+	if name == "" {
+		return nil, errors.New(ErrorDbNameMissing)
+	}
+
+	timeout := dbTimeout(dbConnectivity)
+	opts := options.Client().ApplyURI(cs.Original).
+		SetConnectTimeout(timeout).
+		SetServerSelectionTimeout(timeout).
+		SetHeartbeatInterval(DbHeartbeatInterval)
+	tlsConfig, tlsErr := dbTlsConfig(dbConnectivity)
+	if tlsErr != nil {
+		return nil, tlsErr
+	} else if tlsConfig != nil {
+		opts.SetTLSConfig(tlsConfig)
+	}
+	if dbConnectivity.MaxPoolSize > 0 {
+		opts.SetMaxPoolSize(uint64(dbConnectivity.MaxPoolSize))
+	}
+	if dbConnectivity.MinPoolSize > 0 {
+		opts.SetMinPoolSize(uint64(dbConnectivity.MinPoolSize))
+	}
+
+	client, err := mongo.NewClient(opts)
 	if err != nil {
 		return nil, errors.New(fmt.Sprintf("%s %v", ErrorMongoClient, err))
 	}
@@ -78,13 +324,40 @@ func dbConnect(ctx context.Context, dbConnectivity config.DbConfig) (*mongo.Data
 	if err != nil {
 		return nil, errors.New(fmt.Sprintf("%s %v", ErrorMongoConnect, err))
 	}
+	return client.Database(name), nil
+}
 
-	err = client.Ping(ctx, nil) // use Ping to check if mongod is running
+// Method to connect to mongo database through config
+func dbConnect(ctx context.Context, dbConnectivity config.DbConfig) (*mongo.Database, error) {
+	db, err := newDbClient(ctx, dbConnectivity)
 	if err != nil {
+		return nil, err
+	}
+
+	err = db.Client().Ping(ctx, nil) // use Ping to check if mongod is running
+	if err != nil {
+		db.Client().Disconnect(ctx)
 		return nil, errors.New(fmt.Sprintf("%s %v", ErrorMongoPing, err))
 	}
 
-	return client.Database(dbConnectivity.Name), nil
+	return db, nil
+}
+
+// Return timeout of db operations from config
+func dbTimeout(dbConnectivity config.DbConfig) time.Duration {
+	if dbConnectivity.TimeoutSeconds > 0 {
+		return time.Duration(dbConnectivity.TimeoutSeconds) * time.Second
+	}
+	return DbDefaultTimeout
+}
+
+// Check mongo database is reachable using connectivity details from config
+func PingDbMongo(ctx context.Context, dbConnectivity config.DbConfig) error {
+	db, errConnect := dbConnect(ctx, dbConnectivity)
+	if errConnect != nil {
+		return errConnect
+	}
+	return db.Client().Disconnect(ctx)
 }
 
 // DbMongo struct
@@ -97,20 +370,48 @@ type DbMongo struct {
 
 	// mongo interface connection
 	db *mongo.Database
+
+	// timeout of each db operation
+	timeout time.Duration
 }
 
-// Return new DbMongo instance
-func NewDbMongo(ctx context.Context, dbConnectivity config.DbConfig) *DbMongo {
-	db, errConnect := dbConnect(ctx, dbConnectivity)
-	if errConnect != nil {
-		log.Error(errConnect)
+// Return new DbMongo instance or an error if the db config is invalid
+// A database that is not reachable on startup is only warned about, as the
+// client keeps trying to connect and operations fail until it is reachable
+func NewDbMongo(ctx context.Context, dbConnectivity config.DbConfig) (*DbMongo, error) {
+	db, errClient := newDbClient(ctx, dbConnectivity)
+	if errClient != nil {
+		return nil, errClient
 	}
+	d := &DbMongo{ctx: ctx, dbConnectivity: dbConnectivity, db: db, timeout: dbTimeout(dbConnectivity)}
+	if errPing := d.Ping(); errPing != nil {
+		log.WithFields(log.Fields{log.FieldError: errPing}).Warnln(WarningDbUnreachable)
+	}
+	return d, nil
+}
 
-	return &DbMongo{ctx, dbConnectivity, db}
+// Return context of a single db operation, cancelled on operation timeout
+func (d *DbMongo) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(d.ctx, d.timeout)
+}
+
+// Ping mongo database to check connectivity
+func (d *DbMongo) Ping() error {
+	if d.db == nil {
+		return errors.New(ErrorMongoClient)
+	}
+	ctx, cancel := context.WithTimeout(d.ctx, DbPingTimeout)
+	defer cancel()
+	if err := d.db.Client().Ping(ctx, nil); err != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorMongoPing, err))
+	}
+	return nil
 }
 
 // Save latest attestation to the Attestation collection
 func (d *DbMongo) SaveAttestation(attestation models.Attestation) error {
+	ctx, cancel := d.context()
+	defer cancel()
 
 	// get document representation of Attestation object
 	docAttestation, docErr := models.GetDocumentFromModel(attestation)
@@ -132,7 +433,7 @@ func (d *DbMongo) SaveAttestation(attestation models.Attestation) error {
 	var t bsonx.Doc
 	opts := &options.FindOneAndUpdateOptions{}
 	opts.SetUpsert(true)
-	res := d.db.Collection(ColNameAttestation).FindOneAndUpdate(d.ctx, filterAttestation, newAttestation, opts)
+	res := d.db.Collection(ColNameAttestation).FindOneAndUpdate(ctx, filterAttestation, newAttestation, opts)
 	resErr := res.Decode(&t)
 	if resErr != nil && resErr != mongo.ErrNoDocuments {
 		return errors.New(fmt.Sprintf("%s %v", ErrorAttestationSave, resErr))
@@ -143,6 +444,8 @@ func (d *DbMongo) SaveAttestation(attestation models.Attestation) error {
 
 // Save latest attestation info to the Attestation info collection
 func (d *DbMongo) SaveAttestationInfo(attestationInfo models.AttestationInfo) error {
+	ctx, cancel := d.context()
+	defer cancel()
 
 	// get document representation of AttestationInfo object
 	docAttestationInfo, docErr := models.GetDocumentFromModel(attestationInfo)
@@ -162,7 +465,7 @@ func (d *DbMongo) SaveAttestationInfo(attestationInfo models.AttestationInfo) er
 	var t bsonx.Doc
 	opts := &options.FindOneAndUpdateOptions{}
 	opts.SetUpsert(true)
-	res := d.db.Collection(ColNameAttestationInfo).FindOneAndUpdate(d.ctx, filterAttestationInfo, newAttestationInfo, opts)
+	res := d.db.Collection(ColNameAttestationInfo).FindOneAndUpdate(ctx, filterAttestationInfo, newAttestationInfo, opts)
 	resErr := res.Decode(&t)
 	if resErr != nil && resErr != mongo.ErrNoDocuments {
 		return errors.New(fmt.Sprintf("%s %v", ErrorAttestationInfoSave, resErr))
@@ -173,10 +476,13 @@ func (d *DbMongo) SaveAttestationInfo(attestationInfo models.AttestationInfo) er
 
 // Save merkle commitments to the MerkleCommitment collection
 func (d *DbMongo) SaveMerkleCommitments(commitments []models.CommitmentMerkleCommitment) error {
+	ctx, cancel := d.context()
+	defer cancel()
+
 	for pos := range commitments {
 		// get document representation of each commitment
 		// get document representation of Attestation object
-		docCommitment, docErr := models.GetDocumentFromModel(commitments[pos])
+		docCommitment, docErr := models.GetDocumentWithChecksumFromModel(commitments[pos])
 		if docErr != nil {
 			return errors.New(fmt.Sprintf("%s %v", BadDataMerkleCommitmentModel, docErr))
 		}
@@ -197,7 +503,7 @@ func (d *DbMongo) SaveMerkleCommitments(commitments []models.CommitmentMerkleCom
 		var t bsonx.Doc
 		opts := &options.FindOneAndUpdateOptions{}
 		opts.SetUpsert(true)
-		res := d.db.Collection(ColNameMerkleCommitment).FindOneAndUpdate(d.ctx, filterMerkleCommitment, newCommitment, opts)
+		res := d.db.Collection(ColNameMerkleCommitment).FindOneAndUpdate(ctx, filterMerkleCommitment, newCommitment, opts)
 		resErr := res.Decode(&t)
 		if resErr != nil && resErr != mongo.ErrNoDocuments {
 			return errors.New(fmt.Sprintf("%s %v", ErrorMerkleCommitmentSave, resErr))
@@ -208,9 +514,12 @@ func (d *DbMongo) SaveMerkleCommitments(commitments []models.CommitmentMerkleCom
 
 // Save merkle proofs to the MerkleProof collection
 func (d *DbMongo) SaveMerkleProofs(proofs []models.CommitmentMerkleProof) error {
+	ctx, cancel := d.context()
+	defer cancel()
+
 	for pos := range proofs {
 		// get document representation of merkle proof
-		docProof, docErr := models.GetDocumentFromModel(proofs[pos])
+		docProof, docErr := models.GetDocumentWithChecksumFromModel(proofs[pos])
 		if docErr != nil {
 			return errors.New(fmt.Sprintf("%s %v", BadDataMerkleProofModel, docErr))
 		}
@@ -231,7 +540,7 @@ func (d *DbMongo) SaveMerkleProofs(proofs []models.CommitmentMerkleProof) error
 		var t bsonx.Doc
 		opts := &options.FindOneAndUpdateOptions{}
 		opts.SetUpsert(true)
-		res := d.db.Collection(ColNameMerkleProof).FindOneAndUpdate(d.ctx, filterMerkleProof, newProof, opts)
+		res := d.db.Collection(ColNameMerkleProof).FindOneAndUpdate(ctx, filterMerkleProof, newProof, opts)
 		resErr := res.Decode(&t)
 		if resErr != nil && resErr != mongo.ErrNoDocuments {
 			return errors.New(fmt.Sprintf("%s %v", ErrorMerkleProofSave, resErr))
@@ -240,8 +549,180 @@ func (d *DbMongo) SaveMerkleProofs(proofs []models.CommitmentMerkleProof) error
 	return nil
 }
 
+// Save fee bump attempt to the FeeBump collection
+func (d *DbMongo) SaveFeeBump(feeBump models.FeeBump) error {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	// get document representation of fee bump
+	docFeeBump, docErr := models.GetDocumentFromModel(feeBump)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BadDataFeeBumpModel, docErr))
+	}
+
+	// every attempt is recorded so always insert
+	_, resErr := d.db.Collection(ColNameFeeBump).InsertOne(ctx, docFeeBump)
+	if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorFeeBumpSave, resErr))
+	}
+	return nil
+}
+
+// Save dry run attestation to the DryRunAttestation collection
+func (d *DbMongo) SaveDryRunAttestation(attestation models.DryRunAttestation) error {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	// get document representation of dry run attestation
+	docAttestation, docErr := models.GetDocumentFromModel(attestation)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BadDataDryRunAttestationModel, docErr))
+	}
+
+	_, resErr := d.db.Collection(ColNameDryRunAttestation).InsertOne(ctx, docAttestation)
+	if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorDryRunAttestationSave, resErr))
+	}
+	return nil
+}
+
+// Save commitment exclusion to the CommitmentExclusion collection
+func (d *DbMongo) SaveCommitmentExclusion(exclusion models.CommitmentExclusion) error {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	// get document representation of commitment exclusion
+	docExclusion, docErr := models.GetDocumentFromModel(exclusion)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BadDataExclusionModel, docErr))
+	}
+
+	// every exclusion is recorded so always insert
+	_, resErr := d.db.Collection(ColNameExclusion).InsertOne(ctx, docExclusion)
+	if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorCommitmentExclusionSave, resErr))
+	}
+	return nil
+}
+
+// Save key rotation to the KeyRotation collection
+// Rotations are updated in place by id as these progress
+func (d *DbMongo) SaveKeyRotation(rotation models.KeyRotation) error {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	// get document representation of key rotation
+	docRotation, docErr := models.GetDocumentFromModel(rotation)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BadDataKeyRotationModel, docErr))
+	}
+
+	filterRotation := bsonx.Doc{
+		{models.KeyRotationIdName, bsonx.Int64(rotation.Id)},
+	}
+	opts := &options.ReplaceOptions{}
+	opts.SetUpsert(true)
+	_, resErr := d.db.Collection(ColNameKeyRotation).ReplaceOne(ctx, filterRotation, docRotation, opts)
+	if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorKeyRotationSave, resErr))
+	}
+	return nil
+}
+
+// Save signer status to the SignerStatus collection
+// Statuses are updated in place by signer pubkey
+func (d *DbMongo) SaveSignerStatus(status models.SignerStatus) error {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	// get document representation of signer status
+	docStatus, docErr := models.GetDocumentFromModel(status)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BadDataSignerStatusModel, docErr))
+	}
+
+	filterStatus := bsonx.Doc{
+		{models.SignerStatusPubkeyName, bsonx.String(status.Pubkey)},
+	}
+	opts := &options.ReplaceOptions{}
+	opts.SetUpsert(true)
+	_, resErr := d.db.Collection(ColNameSignerStatus).ReplaceOne(ctx, filterStatus, docStatus, opts)
+	if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorSignerStatusSave, resErr))
+	}
+	return nil
+}
+
+// Save service state to the ServiceState collection
+func (d *DbMongo) SaveServiceState(state models.ServiceState) error {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	// get document representation of service state
+	docState, docErr := models.GetDocumentFromModel(state)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BadDataServiceStateModel, docErr))
+	}
+
+	newState := bsonx.Doc{
+		{"$set", bsonx.Document(*docState)},
+	}
+
+	// search if state for service already exists
+	filterState := bsonx.Doc{
+		{models.ServiceStateNameName, bsonx.String(state.Name)},
+	}
+
+	// insert or update service state
+	var t bsonx.Doc
+	opts := &options.FindOneAndUpdateOptions{}
+	opts.SetUpsert(true)
+	res := d.db.Collection(ColNameServiceState).FindOneAndUpdate(ctx, filterState, newState, opts)
+	resErr := res.Decode(&t)
+	if resErr != nil && resErr != mongo.ErrNoDocuments {
+		return errors.New(fmt.Sprintf("%s %v", ErrorServiceStateSave, resErr))
+	}
+	return nil
+}
+
+// Save in flight attestation to the InFlightAttestation collection
+// Only a single in flight attestation is kept. This is called on shutdown
+// after the service context is cancelled so a new context is used
+func (d *DbMongo) SaveInFlightAttestation(inFlight models.InFlightAttestation) error {
+	// get document representation of in flight attestation
+	docInFlight, docErr := models.GetDocumentFromModel(inFlight)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BadDataInFlightModel, docErr))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DbShutdownTimeout)
+	defer cancel()
+	opts := &options.ReplaceOptions{}
+	opts.SetUpsert(true)
+	_, resErr := d.db.Collection(ColNameInFlight).ReplaceOne(ctx, bsonx.Doc{}, docInFlight, opts)
+	if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorInFlightSave, resErr))
+	}
+	return nil
+}
+
+// Delete in flight attestation from the InFlightAttestation collection
+func (d *DbMongo) DeleteInFlightAttestation() error {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	_, resErr := d.db.Collection(ColNameInFlight).DeleteMany(ctx, bsonx.Doc{})
+	if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorInFlightDelete, resErr))
+	}
+	return nil
+}
+
 // Save client details to ClientDetails collection
 func (d *DbMongo) SaveClientDetails(details models.ClientDetails) error {
+	ctx, cancel := d.context()
+	defer cancel()
+
 	// get document representation of client details
 	docDetails, docErr := models.GetDocumentFromModel(details)
 	if docErr != nil {
@@ -251,6 +732,11 @@ func (d *DbMongo) SaveClientDetails(details models.ClientDetails) error {
 	newDetails := bsonx.Doc{
 		{"$set", bsonx.Document(*docDetails)},
 	}
+	// unset any delivery url removed, as empty urls are omitted from the document
+	if details.DeliveryUrl == "" {
+		newDetails = append(newDetails, bsonx.Elem{"$unset",
+			bsonx.Document(bsonx.Doc{{models.ClientDetailsDeliveryUrlName, bsonx.String("")}})})
+	}
 
 	// search if client details for position already exists
 	filterClientDetails := bsonx.Doc{
@@ -262,7 +748,7 @@ func (d *DbMongo) SaveClientDetails(details models.ClientDetails) error {
 	var t bsonx.Doc
 	opts := &options.FindOneAndUpdateOptions{}
 	opts.SetUpsert(true)
-	res := d.db.Collection(ColNameClientDetails).FindOneAndUpdate(d.ctx, filterClientDetails, newDetails, opts)
+	res := d.db.Collection(ColNameClientDetails).FindOneAndUpdate(ctx, filterClientDetails, newDetails, opts)
 	resErr := res.Decode(&t)
 	if resErr != nil && resErr != mongo.ErrNoDocuments {
 		return errors.New(fmt.Sprintf("%s %v", ErrorClientDetailsSave, resErr))
@@ -272,8 +758,11 @@ func (d *DbMongo) SaveClientDetails(details models.ClientDetails) error {
 
 // Save client commitment to ClientCommitment collection
 func (d *DbMongo) SaveClientCommitment(commitment models.ClientCommitment) error {
+	ctx, cancel := d.context()
+	defer cancel()
+
 	// get document representation of client details
-	docCommitment, docErr := models.GetDocumentFromModel(commitment)
+	docCommitment, docErr := models.GetDocumentWithChecksumFromModel(commitment)
 	if docErr != nil {
 		return errors.New(fmt.Sprintf("%s %v", BadDataClientCommitmentModel, docErr))
 	}
@@ -292,7 +781,7 @@ func (d *DbMongo) SaveClientCommitment(commitment models.ClientCommitment) error
 	var t bsonx.Doc
 	opts := &options.FindOneAndUpdateOptions{}
 	opts.SetUpsert(true)
-	res := d.db.Collection(ColNameClientCommitment).FindOneAndUpdate(d.ctx, filterClientCommitment, newCommitment, opts)
+	res := d.db.Collection(ColNameClientCommitment).FindOneAndUpdate(ctx, filterClientCommitment, newCommitment, opts)
 	resErr := res.Decode(&t)
 	if resErr != nil && resErr != mongo.ErrNoDocuments {
 		return errors.New(fmt.Sprintf("%s %v", ErrorClientCommitmentSave, resErr))
@@ -300,11 +789,46 @@ func (d *DbMongo) SaveClientCommitment(commitment models.ClientCommitment) error
 	return nil
 }
 
+// Save client commitments of several client positions in a single ordered
+// bulk write, so that a batch is stored in one round trip
+func (d *DbMongo) SaveClientCommitments(commitments []models.ClientCommitment) error {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	var writes []mongo.WriteModel
+	for _, commitment := range commitments {
+		docCommitment, docErr := models.GetDocumentWithChecksumFromModel(commitment)
+		if docErr != nil {
+			return errors.New(fmt.Sprintf("%s %v", BadDataClientCommitmentModel, docErr))
+		}
+		filterClientCommitment := bsonx.Doc{
+			{models.ClientCommitmentClientPositionName,
+				bsonx.Int32(docCommitment.Lookup(models.ClientCommitmentClientPositionName).Int32())},
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(filterClientCommitment).
+			SetUpdate(bsonx.Doc{{"$set", bsonx.Document(*docCommitment)}}).
+			SetUpsert(true))
+	}
+	if len(writes) == 0 {
+		return nil
+	}
+
+	_, writeErr := d.db.Collection(ColNameClientCommitment).BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(true))
+	if writeErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorClientCommitmentSave, writeErr))
+	}
+	return nil
+}
+
 // Get latest ClientDetails document
 func (d *DbMongo) GetClientDetails() ([]models.ClientDetails, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
 	// sort by client position
 	sortFilter := bsonx.Doc{{models.ClientDetailsClientPositionName, bsonx.Int32(1)}}
-	res, resErr := d.db.Collection(ColNameClientDetails).Find(d.ctx, bsonx.Doc{}, &options.FindOptions{Sort: sortFilter})
+	res, resErr := d.db.Collection(ColNameClientDetails).Find(ctx, bsonx.Doc{}, &options.FindOptions{Sort: sortFilter})
 	if resErr != nil {
 		return []models.ClientDetails{},
 			errors.New(fmt.Sprintf("%s %v", ErrorClientDetailsGet, resErr))
@@ -312,7 +836,7 @@ func (d *DbMongo) GetClientDetails() ([]models.ClientDetails, error) {
 
 	// iterate through details
 	var details []models.ClientDetails
-	for res.Next(d.ctx) {
+	for res.Next(ctx) {
 		var detailsDoc bsonx.Doc
 		if err := res.Decode(&detailsDoc); err != nil {
 			return []models.ClientDetails{},
@@ -333,6 +857,9 @@ func (d *DbMongo) GetClientDetails() ([]models.ClientDetails, error) {
 
 // Get Attestation collection document count
 func (d *DbMongo) getAttestationCount(confirmed ...bool) (int64, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
 	// set optional confirmed filter
 	confirmedFilter := bsonx.Doc{}
 	if len(confirmed) > 0 {
@@ -341,7 +868,7 @@ func (d *DbMongo) getAttestationCount(confirmed ...bool) (int64, error) {
 	// find latest attestation count
 	opts := options.CountOptions{}
 	opts.SetLimit(1)
-	count, countErr := d.db.Collection(ColNameAttestation).CountDocuments(d.ctx, confirmedFilter, &opts)
+	count, countErr := d.db.Collection(ColNameAttestation).CountDocuments(ctx, confirmedFilter, &opts)
 	if countErr != nil {
 		return 0, errors.New(fmt.Sprintf("%s %v", ErrorAttestationGet, countErr))
 	}
@@ -349,8 +876,53 @@ func (d *DbMongo) getAttestationCount(confirmed ...bool) (int64, error) {
 	return count, nil
 }
 
+// Get number of confirmed attestations, the attestation rounds completed
+func (d *DbMongo) GetConfirmedAttestationCount() (int64, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	confirmedFilter := bsonx.Doc{{models.AttestationConfirmedName, bsonx.Boolean(true)}}
+	count, countErr := d.db.Collection(ColNameAttestation).CountDocuments(ctx, confirmedFilter)
+	if countErr != nil {
+		return 0, errors.New(fmt.Sprintf("%s %v", ErrorAttestationGet, countErr))
+	}
+	return count, nil
+}
+
+// Get total fee of confirmed attestations from the attestation infos
+// with a transaction time at or after the time given
+func (d *DbMongo) GetAttestationFees(since int64) (int64, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	filter := bsonx.Doc{
+		{models.AttestationInfoBlockhashName, bsonx.Document(bsonx.Doc{{"$ne", bsonx.String("")}})},
+		{models.AttestationInfoTimeName, bsonx.Document(bsonx.Doc{{"$gte", bsonx.Int64(since)}})},
+	}
+	opts := options.Find().SetProjection(bsonx.Doc{{models.AttestationInfoFeeName, bsonx.Int32(1)}})
+	res, resErr := d.db.Collection(ColNameAttestationInfo).Find(ctx, filter, opts)
+	if resErr != nil {
+		return 0, errors.New(fmt.Sprintf("%s %v", ErrorAttestationGet, resErr))
+	}
+	var fees int64
+	for res.Next(ctx) {
+		var info models.AttestationInfo
+		if err := res.Decode(&info); err != nil {
+			return 0, errors.New(fmt.Sprintf("%s %v", BadDataAttestationInfoModel, err))
+		}
+		fees += info.Fee
+	}
+	if err := res.Err(); err != nil {
+		return 0, errors.New(fmt.Sprintf("%s %v", BadDataAttestationInfoModel, err))
+	}
+	return fees, nil
+}
+
 // Get Attestation entry from collection and return merkle_root field
 func (d *DbMongo) GetLatestAttestationMerkleRoot(confirmed bool) (string, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
 	// first check if attestation has any documents
 	count, countErr := d.getAttestationCount(confirmed)
 	if countErr != nil {
@@ -364,7 +936,7 @@ func (d *DbMongo) GetLatestAttestationMerkleRoot(confirmed bool) (string, error)
 	confirmedFilter := bsonx.Doc{{models.AttestationConfirmedName, bsonx.Boolean(confirmed)}}
 
 	var attestationDoc bsonx.Doc
-	resErr := d.db.Collection(ColNameAttestation).FindOne(d.ctx,
+	resErr := d.db.Collection(ColNameAttestation).FindOne(ctx,
 		confirmedFilter, &options.FindOneOptions{Sort: sortFilter}).Decode(&attestationDoc)
 	if resErr != nil {
 		return "", errors.New(fmt.Sprintf("%s %v", ErrorAttestationGet, resErr))
@@ -372,8 +944,100 @@ func (d *DbMongo) GetLatestAttestationMerkleRoot(confirmed bool) (string, error)
 	return attestationDoc.Lookup(models.AttestationMerkleRootName).StringValue(), nil
 }
 
+// Return confirmed attestations from the Attestation collection, oldest first
+func (d *DbMongo) GetAttestations() ([]models.AttestationBSON, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	sortFilter := bsonx.Doc{{models.AttestationInsertedAtName, bsonx.Int32(1)}}
+	confirmedFilter := bsonx.Doc{{models.AttestationConfirmedName, bsonx.Boolean(true)}}
+	res, resErr := d.db.Collection(ColNameAttestation).Find(ctx, confirmedFilter, &options.FindOptions{Sort: sortFilter})
+	if resErr != nil {
+		return []models.AttestationBSON{}, errors.New(fmt.Sprintf("%s %v", ErrorAttestationGet, resErr))
+	}
+
+	var attestations []models.AttestationBSON
+	for res.Next(ctx) {
+		var attestation models.AttestationBSON
+		if err := res.Decode(&attestation); err != nil {
+			log.WithFields(log.Fields{log.FieldCollection: ColNameAttestation, log.FieldError: err}).Warnln(BadDataAttestationModel)
+			return []models.AttestationBSON{}, err
+		}
+		attestations = append(attestations, attestation)
+	}
+	if err := res.Err(); err != nil {
+		return []models.AttestationBSON{}, errors.New(fmt.Sprintf("%s %v", BadDataAttestationModel, err))
+	}
+	return attestations, nil
+}
+
+// Return page of confirmed attestations, newest first, with block height
+// from minHeight to maxHeight, where negative heights are not filtered on
+func (d *DbMongo) GetAttestationsPage(minHeight int64, maxHeight int64, offset int64, limit int64) (
+	[]models.AttestationBSON, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	attestations := []models.AttestationBSON{}
+	if limit <= 0 {
+		return attestations, nil
+	}
+	filter := bsonx.Doc{{models.AttestationConfirmedName, bsonx.Boolean(true)}}
+	heightFilter := bsonx.Doc{}
+	if minHeight >= 0 {
+		heightFilter = append(heightFilter, bsonx.Elem{"$gte", bsonx.Int64(minHeight)})
+	}
+	if maxHeight >= 0 {
+		heightFilter = append(heightFilter, bsonx.Elem{"$lte", bsonx.Int64(maxHeight)})
+	}
+	if len(heightFilter) > 0 {
+		filter = append(filter, bsonx.Elem{models.AttestationBlockHeightName, bsonx.Document(heightFilter)})
+	}
+
+	opts := options.Find().SetSort(bsonx.Doc{{models.AttestationInsertedAtName, bsonx.Int32(-1)}}).
+		SetSkip(offset).SetLimit(limit)
+	res, resErr := d.db.Collection(ColNameAttestation).Find(ctx, filter, opts)
+	if resErr != nil {
+		return []models.AttestationBSON{}, errors.New(fmt.Sprintf("%s %v", ErrorAttestationGet, resErr))
+	}
+	for res.Next(ctx) {
+		var attestation models.AttestationBSON
+		if err := res.Decode(&attestation); err != nil {
+			log.WithFields(log.Fields{log.FieldCollection: ColNameAttestation, log.FieldError: err}).Warnln(BadDataAttestationModel)
+			return []models.AttestationBSON{}, err
+		}
+		attestations = append(attestations, attestation)
+	}
+	if err := res.Err(); err != nil {
+		return []models.AttestationBSON{}, errors.New(fmt.Sprintf("%s %v", BadDataAttestationModel, err))
+	}
+	return attestations, nil
+}
+
+// Return attestation document with given txid hash, empty if not found
+func (d *DbMongo) GetAttestation(txid chainhash.Hash) (models.AttestationBSON, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	filterAttestation := bsonx.Doc{
+		{models.AttestationTxidName, bsonx.String(txid.String())},
+	}
+	var attestation models.AttestationBSON
+	resErr := d.db.Collection(ColNameAttestation).FindOne(ctx, filterAttestation).Decode(&attestation)
+	if resErr != nil {
+		if resErr == mongo.ErrNoDocuments {
+			return models.AttestationBSON{}, nil
+		}
+		return models.AttestationBSON{}, errors.New(fmt.Sprintf("%s %v", ErrorAttestationGet, resErr))
+	}
+	return attestation, nil
+}
+
 // Return Commitment from MerkleCommitment commitments for attestation with given txid hash
 func (d *DbMongo) getAttestationMerkleRoot(txid chainhash.Hash) (string, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
 	// first check if attestation has any documents
 	count, countErr := d.getAttestationCount()
 	if countErr != nil {
@@ -388,7 +1052,7 @@ func (d *DbMongo) getAttestationMerkleRoot(txid chainhash.Hash) (string, error)
 	}
 
 	var attestationDoc bsonx.Doc
-	resErr := d.db.Collection(ColNameAttestation).FindOne(d.ctx, filterAttestation).Decode(&attestationDoc)
+	resErr := d.db.Collection(ColNameAttestation).FindOne(ctx, filterAttestation).Decode(&attestationDoc)
 	if resErr != nil {
 		if resErr == mongo.ErrNoDocuments {
 			return "", nil
@@ -400,6 +1064,9 @@ func (d *DbMongo) getAttestationMerkleRoot(txid chainhash.Hash) (string, error)
 
 // Return Commitment from MerkleCommitment commitments for attestation with given txid hash
 func (d *DbMongo) GetAttestationMerkleCommitments(txid chainhash.Hash) ([]models.CommitmentMerkleCommitment, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
 	// get merkle root of attestation
 	merkleRoot, rootErr := d.getAttestationMerkleRoot(txid)
 	if rootErr != nil {
@@ -411,7 +1078,7 @@ func (d *DbMongo) GetAttestationMerkleCommitments(txid chainhash.Hash) ([]models
 	// filter MerkleCommitment collection by merkle_root and sort for client position
 	sortFilter := bsonx.Doc{{models.CommitmentClientPositionName, bsonx.Int32(1)}}
 	filterMerkleRoot := bsonx.Doc{{models.CommitmentMerkleRootName, bsonx.String(merkleRoot)}}
-	res, resErr := d.db.Collection(ColNameMerkleCommitment).Find(d.ctx, filterMerkleRoot, &options.FindOptions{Sort: sortFilter})
+	res, resErr := d.db.Collection(ColNameMerkleCommitment).Find(ctx, filterMerkleRoot, &options.FindOptions{Sort: sortFilter})
 	if resErr != nil {
 		return []models.CommitmentMerkleCommitment{},
 			errors.New(fmt.Sprintf("%s %v", ErrorMerkleCommitmentGet, resErr))
@@ -419,19 +1086,22 @@ func (d *DbMongo) GetAttestationMerkleCommitments(txid chainhash.Hash) ([]models
 
 	// fetch commitments
 	var merkleCommitments []models.CommitmentMerkleCommitment
-	for res.Next(d.ctx) {
+	for res.Next(ctx) {
 		var commitmentDoc bsonx.Doc
 		if err := res.Decode(&commitmentDoc); err != nil {
-			log.Warnf("%s\n", BadDataMerkleCommitmentCol)
+			log.WithFields(log.Fields{log.FieldCollection: ColNameMerkleCommitment, log.FieldError: err}).Warnln(BadDataMerkleCommitmentCol)
 			return []models.CommitmentMerkleCommitment{}, err
 		}
 		// decode document result to Commitment model and get hash
 		commitmentModel := &models.CommitmentMerkleCommitment{}
 		modelErr := models.GetModelFromDocument(&commitmentDoc, commitmentModel)
 		if modelErr != nil {
-			log.Warnf("%s\n", BadDataMerkleCommitmentCol)
+			log.WithFields(log.Fields{log.FieldCollection: ColNameMerkleCommitment, log.FieldError: modelErr}).Warnln(BadDataMerkleCommitmentCol)
 			return []models.CommitmentMerkleCommitment{}, modelErr
 		}
+		if err := verifyDocumentChecksum(ColNameMerkleCommitment, &commitmentDoc, *commitmentModel); err != nil {
+			return []models.CommitmentMerkleCommitment{}, err
+		}
 		merkleCommitments = append(merkleCommitments, *commitmentModel)
 	}
 	if err := res.Err(); err != nil {
@@ -441,12 +1111,61 @@ func (d *DbMongo) GetAttestationMerkleCommitments(txid chainhash.Hash) ([]models
 	return merkleCommitments, nil
 }
 
+// Return merkle proofs from MerkleProof collection for attestation with given txid hash
+func (d *DbMongo) GetAttestationMerkleProofs(txid chainhash.Hash) ([]models.CommitmentMerkleProof, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	// get merkle root of attestation
+	merkleRoot, rootErr := d.getAttestationMerkleRoot(txid)
+	if rootErr != nil {
+		return []models.CommitmentMerkleProof{}, rootErr
+	} else if merkleRoot == "" {
+		return []models.CommitmentMerkleProof{}, nil
+	}
+
+	// filter MerkleProof collection by merkle_root and sort for client position
+	sortFilter := bsonx.Doc{{models.ProofClientPositionName, bsonx.Int32(1)}}
+	filterMerkleRoot := bsonx.Doc{{models.ProofMerkleRootName, bsonx.String(merkleRoot)}}
+	res, resErr := d.db.Collection(ColNameMerkleProof).Find(ctx, filterMerkleRoot, &options.FindOptions{Sort: sortFilter})
+	if resErr != nil {
+		return []models.CommitmentMerkleProof{},
+			errors.New(fmt.Sprintf("%s %v", ErrorMerkleProofGet, resErr))
+	}
+
+	// fetch proofs
+	var merkleProofs []models.CommitmentMerkleProof
+	for res.Next(ctx) {
+		var proofDoc bsonx.Doc
+		if err := res.Decode(&proofDoc); err != nil {
+			return []models.CommitmentMerkleProof{},
+				errors.New(fmt.Sprintf("%s %v", BadDataMerkleProofCol, err))
+		}
+		proofModel := &models.CommitmentMerkleProof{}
+		modelErr := models.GetModelFromDocument(&proofDoc, proofModel)
+		if modelErr != nil {
+			return []models.CommitmentMerkleProof{}, errors.New(fmt.Sprintf("%s %v", BadDataMerkleProofCol, modelErr))
+		}
+		if err := verifyDocumentChecksum(ColNameMerkleProof, &proofDoc, *proofModel); err != nil {
+			return []models.CommitmentMerkleProof{}, err
+		}
+		merkleProofs = append(merkleProofs, *proofModel)
+	}
+	if err := res.Err(); err != nil {
+		return []models.CommitmentMerkleProof{},
+			errors.New(fmt.Sprintf("%s %v", BadDataMerkleProofCol, err))
+	}
+	return merkleProofs, nil
+}
+
 // Return latest commitments from MerkleCommitment collection
 func (d *DbMongo) GetClientCommitments() ([]models.ClientCommitment, error) {
+	ctx, cancel := d.context()
+	defer cancel()
 
 	// sort by client position to get correct commitment order
 	sortFilter := bsonx.Doc{{models.ClientCommitmentClientPositionName, bsonx.Int32(1)}}
-	res, resErr := d.db.Collection(ColNameClientCommitment).Find(d.ctx, bsonx.Doc{}, &options.FindOptions{Sort: sortFilter})
+	res, resErr := d.db.Collection(ColNameClientCommitment).Find(ctx, bsonx.Doc{}, &options.FindOptions{Sort: sortFilter})
 	if resErr != nil {
 		return []models.ClientCommitment{},
 			errors.New(fmt.Sprintf("%s %v", ErrorClientCommitmentGet, resErr))
@@ -454,7 +1173,7 @@ func (d *DbMongo) GetClientCommitments() ([]models.ClientCommitment, error) {
 
 	// iterate through commitments
 	var latestCommitments []models.ClientCommitment
-	for res.Next(d.ctx) {
+	for res.Next(ctx) {
 		var commitmentDoc bsonx.Doc
 		if err := res.Decode(&commitmentDoc); err != nil {
 			return []models.ClientCommitment{},
@@ -465,6 +1184,9 @@ func (d *DbMongo) GetClientCommitments() ([]models.ClientCommitment, error) {
 		if modelErr != nil {
 			return []models.ClientCommitment{}, errors.New(fmt.Sprintf("%s %v", BadDataClientCommitmentCol, modelErr))
 		}
+		if err := verifyDocumentChecksum(ColNameClientCommitment, &commitmentDoc, *commitmentModel); err != nil {
+			return []models.ClientCommitment{}, err
+		}
 		latestCommitments = append(latestCommitments, *commitmentModel)
 	}
 	if err := res.Err(); err != nil {
@@ -472,3 +1194,711 @@ func (d *DbMongo) GetClientCommitments() ([]models.ClientCommitment, error) {
 	}
 	return latestCommitments, nil
 }
+
+// Return commitment exclusions of a client position, oldest round first
+func (d *DbMongo) GetCommitmentExclusions(position int32) ([]models.CommitmentExclusion, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	sortFilter := bsonx.Doc{{models.CommitmentExclusionRoundCloseName, bsonx.Int32(1)}}
+	filterPosition := bsonx.Doc{{models.CommitmentExclusionClientPositionName, bsonx.Int32(position)}}
+	res, resErr := d.db.Collection(ColNameExclusion).Find(ctx, filterPosition, &options.FindOptions{Sort: sortFilter})
+	if resErr != nil {
+		return []models.CommitmentExclusion{},
+			errors.New(fmt.Sprintf("%s %v", ErrorCommitmentExclusionGet, resErr))
+	}
+
+	exclusions := []models.CommitmentExclusion{}
+	for res.Next(ctx) {
+		var exclusionDoc bsonx.Doc
+		if err := res.Decode(&exclusionDoc); err != nil {
+			return []models.CommitmentExclusion{},
+				errors.New(fmt.Sprintf("%s %v", BadDataExclusionCol, err))
+		}
+		exclusionModel := &models.CommitmentExclusion{}
+		modelErr := models.GetModelFromDocument(&exclusionDoc, exclusionModel)
+		if modelErr != nil {
+			return []models.CommitmentExclusion{}, errors.New(fmt.Sprintf("%s %v", BadDataExclusionCol, modelErr))
+		}
+		exclusions = append(exclusions, *exclusionModel)
+	}
+	if err := res.Err(); err != nil {
+		return []models.CommitmentExclusion{}, errors.New(fmt.Sprintf("%s %v", BadDataExclusionCol, err))
+	}
+	return exclusions, nil
+}
+
+// Get commitment history page of client position from the MerkleCommitment
+// collection newest first, with the attestations committing to each merkle
+// root from the Attestation collection, preferring confirmed attestations
+func (d *DbMongo) GetCommitmentHistory(position int32, offset int64, limit int64) (models.CommitmentHistory, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	history := models.CommitmentHistory{ClientPosition: position, Offset: offset, Limit: limit,
+		Commitments: []models.CommitmentHistoryEntry{}}
+	filterPosition := bsonx.Doc{{models.CommitmentClientPositionName, bsonx.Int32(position)}}
+	total, countErr := d.db.Collection(ColNameMerkleCommitment).CountDocuments(ctx, filterPosition)
+	if countErr != nil {
+		return models.CommitmentHistory{}, errors.New(fmt.Sprintf("%s %v", ErrorMerkleCommitmentGet, countErr))
+	}
+	history.Total = total
+	if total <= offset || limit <= 0 {
+		return history, nil
+	}
+
+	// commitments are inserted in round order so the object id orders rounds
+	opts := options.Find().SetSort(bsonx.Doc{{"_id", bsonx.Int32(-1)}}).SetSkip(offset).SetLimit(limit)
+	res, resErr := d.db.Collection(ColNameMerkleCommitment).Find(ctx, filterPosition, opts)
+	if resErr != nil {
+		return models.CommitmentHistory{}, errors.New(fmt.Sprintf("%s %v", ErrorMerkleCommitmentGet, resErr))
+	}
+	var merkleRoots bsonx.Arr
+	for res.Next(ctx) {
+		var commitmentDoc bsonx.Doc
+		if err := res.Decode(&commitmentDoc); err != nil {
+			return models.CommitmentHistory{}, errors.New(fmt.Sprintf("%s %v", BadDataMerkleCommitmentCol, err))
+		}
+		commitmentModel := &models.CommitmentMerkleCommitment{}
+		if modelErr := models.GetModelFromDocument(&commitmentDoc, commitmentModel); modelErr != nil {
+			return models.CommitmentHistory{}, errors.New(fmt.Sprintf("%s %v", BadDataMerkleCommitmentCol, modelErr))
+		}
+		if err := verifyDocumentChecksum(ColNameMerkleCommitment, &commitmentDoc, *commitmentModel); err != nil {
+			return models.CommitmentHistory{}, err
+		}
+		history.Commitments = append(history.Commitments, models.CommitmentHistoryEntry{
+			Commitment: commitmentModel.Commitment.String(),
+			MerkleRoot: commitmentModel.MerkleRoot.String(),
+		})
+		merkleRoots = append(merkleRoots, bsonx.String(commitmentModel.MerkleRoot.String()))
+	}
+	if err := res.Err(); err != nil {
+		return models.CommitmentHistory{}, errors.New(fmt.Sprintf("%s %v", BadDataMerkleCommitmentCol, err))
+	}
+	if len(merkleRoots) == 0 {
+		return history, nil
+	}
+
+	// attestations of the merkle roots of the page
+	filterRoots := bsonx.Doc{{models.AttestationMerkleRootName, bsonx.Document(bsonx.Doc{{"$in", bsonx.Array(merkleRoots)}})}}
+	attestationRes, attestationErr := d.db.Collection(ColNameAttestation).Find(ctx, filterRoots)
+	if attestationErr != nil {
+		return models.CommitmentHistory{}, errors.New(fmt.Sprintf("%s %v", ErrorAttestationGet, attestationErr))
+	}
+	attestations := make(map[string]models.AttestationBSON)
+	for attestationRes.Next(ctx) {
+		var attestation models.AttestationBSON
+		if err := attestationRes.Decode(&attestation); err != nil {
+			return models.CommitmentHistory{}, errors.New(fmt.Sprintf("%s %v", BadDataAttestationModel, err))
+		}
+		if existing, ok := attestations[attestation.MerkleRoot]; !ok || !existing.Confirmed {
+			attestations[attestation.MerkleRoot] = attestation
+		}
+	}
+	if err := attestationRes.Err(); err != nil {
+		return models.CommitmentHistory{}, errors.New(fmt.Sprintf("%s %v", BadDataAttestationModel, err))
+	}
+	for i, entry := range history.Commitments {
+		if attestation, ok := attestations[entry.MerkleRoot]; ok {
+			history.Commitments[i].Txid = attestation.Txid
+			history.Commitments[i].Confirmed = attestation.Confirmed
+		}
+	}
+	return history, nil
+}
+
+// Save round snapshot to the RoundSnapshot collection
+// Snapshots are only inserted, so that a stored snapshot is never modified
+func (d *DbMongo) SaveRoundSnapshot(snapshot models.RoundSnapshot) error {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	// get document representation of round snapshot
+	docSnapshot, docErr := models.GetDocumentWithChecksumFromModel(snapshot)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BadDataRoundSnapshotModel, docErr))
+	}
+
+	filterRound := bsonx.Doc{{models.RoundSnapshotRoundName, bsonx.Int64(snapshot.Round)}}
+	newSnapshot := bsonx.Doc{{"$setOnInsert", bsonx.Document(*docSnapshot)}}
+	_, resErr := d.db.Collection(ColNameRoundSnapshot).UpdateOne(ctx, filterRound, newSnapshot,
+		options.Update().SetUpsert(true))
+	if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorRoundSnapshotSave, resErr))
+	}
+	return nil
+}
+
+// Get round snapshot of round from the RoundSnapshot collection
+// An empty snapshot is returned if the round has no snapshot
+func (d *DbMongo) GetRoundSnapshot(round int64) (models.RoundSnapshot, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	filterRound := bsonx.Doc{{models.RoundSnapshotRoundName, bsonx.Int64(round)}}
+	var snapshotDoc bsonx.Doc
+	resErr := d.db.Collection(ColNameRoundSnapshot).FindOne(ctx, filterRound).Decode(&snapshotDoc)
+	if resErr == mongo.ErrNoDocuments {
+		return models.RoundSnapshot{}, nil
+	} else if resErr != nil {
+		return models.RoundSnapshot{}, errors.New(fmt.Sprintf("%s %v", ErrorRoundSnapshotGet, resErr))
+	}
+	return decodeRoundSnapshot(&snapshotDoc)
+}
+
+// Get page of round snapshots from the RoundSnapshot collection, newest
+// first, with the total number of snapshots. Only snapshots of the merkle
+// root are returned if a merkle root is provided
+func (d *DbMongo) GetRoundSnapshots(merkleRoot string, offset int64, limit int64) (models.RoundSnapshots, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	snapshots := models.RoundSnapshots{Offset: offset, Limit: limit, Rounds: []models.RoundSnapshot{}}
+	filterRoot := bsonx.Doc{}
+	if merkleRoot != "" {
+		filterRoot = bsonx.Doc{{models.RoundSnapshotMerkleRootName, bsonx.String(merkleRoot)}}
+	}
+	total, countErr := d.db.Collection(ColNameRoundSnapshot).CountDocuments(ctx, filterRoot)
+	if countErr != nil {
+		return models.RoundSnapshots{}, errors.New(fmt.Sprintf("%s %v", ErrorRoundSnapshotGet, countErr))
+	}
+	snapshots.Total = total
+	if total <= offset || limit <= 0 {
+		return snapshots, nil
+	}
+
+	opts := options.Find().SetSort(bsonx.Doc{{models.RoundSnapshotRoundName, bsonx.Int32(-1)}}).
+		SetSkip(offset).SetLimit(limit)
+	res, resErr := d.db.Collection(ColNameRoundSnapshot).Find(ctx, filterRoot, opts)
+	if resErr != nil {
+		return models.RoundSnapshots{}, errors.New(fmt.Sprintf("%s %v", ErrorRoundSnapshotGet, resErr))
+	}
+	for res.Next(ctx) {
+		var snapshotDoc bsonx.Doc
+		if err := res.Decode(&snapshotDoc); err != nil {
+			return models.RoundSnapshots{}, errors.New(fmt.Sprintf("%s %v", BadDataRoundSnapshotCol, err))
+		}
+		snapshot, snapshotErr := decodeRoundSnapshot(&snapshotDoc)
+		if snapshotErr != nil {
+			return models.RoundSnapshots{}, snapshotErr
+		}
+		snapshots.Rounds = append(snapshots.Rounds, snapshot)
+	}
+	if err := res.Err(); err != nil {
+		return models.RoundSnapshots{}, errors.New(fmt.Sprintf("%s %v", BadDataRoundSnapshotCol, err))
+	}
+	return snapshots, nil
+}
+
+// Return round snapshot model of document verifying the document checksum
+func decodeRoundSnapshot(snapshotDoc *bsonx.Doc) (models.RoundSnapshot, error) {
+	snapshotModel := &models.RoundSnapshot{}
+	if modelErr := models.GetModelFromDocument(snapshotDoc, snapshotModel); modelErr != nil {
+		return models.RoundSnapshot{}, errors.New(fmt.Sprintf("%s %v", BadDataRoundSnapshotCol, modelErr))
+	}
+	if err := verifyDocumentChecksum(ColNameRoundSnapshot, snapshotDoc, *snapshotModel); err != nil {
+		return models.RoundSnapshot{}, err
+	}
+	return *snapshotModel, nil
+}
+
+// Save commitment submissions to the CommitmentSubmission collection in a
+// single ordered bulk write, updating the status of existing submissions
+func (d *DbMongo) SaveCommitmentSubmissions(submissions []models.CommitmentSubmission) error {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	var writes []mongo.WriteModel
+	for _, submission := range submissions {
+		docSubmission, docErr := models.GetDocumentFromModel(submission)
+		if docErr != nil {
+			return errors.New(fmt.Sprintf("%s %v", BadDataSubmissionModel, docErr))
+		}
+		filterSubmission := bsonx.Doc{{models.CommitmentSubmissionIdName, bsonx.String(submission.Id)}}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(filterSubmission).
+			SetUpdate(bsonx.Doc{{"$set", bsonx.Document(*docSubmission)}}).
+			SetUpsert(true))
+	}
+	if len(writes) == 0 {
+		return nil
+	}
+
+	_, writeErr := d.db.Collection(ColNameSubmission).BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(true))
+	if writeErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorSubmissionSave, writeErr))
+	}
+	return nil
+}
+
+// Get commitment submission with id from the CommitmentSubmission collection
+// Return empty submission if there is no submission with the id
+func (d *DbMongo) GetCommitmentSubmission(id string) (models.CommitmentSubmission, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	filterSubmission := bsonx.Doc{{models.CommitmentSubmissionIdName, bsonx.String(id)}}
+	var submissionDoc bsonx.Doc
+	resErr := d.db.Collection(ColNameSubmission).FindOne(ctx, filterSubmission).Decode(&submissionDoc)
+	if resErr == mongo.ErrNoDocuments {
+		return models.CommitmentSubmission{}, nil
+	} else if resErr != nil {
+		return models.CommitmentSubmission{}, errors.New(fmt.Sprintf("%s %v", ErrorSubmissionGet, resErr))
+	}
+
+	submissionModel := &models.CommitmentSubmission{}
+	if modelErr := models.GetModelFromDocument(&submissionDoc, submissionModel); modelErr != nil {
+		return models.CommitmentSubmission{}, errors.New(fmt.Sprintf("%s %v", BadDataSubmissionCol, modelErr))
+	}
+	return *submissionModel, nil
+}
+
+// Get latest commitment submission of each of the client positions from
+// the CommitmentSubmission collection, for positions with submissions
+func (d *DbMongo) GetLatestCommitmentSubmissions(positions []int32) ([]models.CommitmentSubmission, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	sortFilter := bsonx.Doc{{"_id", bsonx.Int32(-1)}}
+	submissions := []models.CommitmentSubmission{}
+	for _, position := range positions {
+		filterPosition := bsonx.Doc{{models.CommitmentSubmissionClientPositionName, bsonx.Int32(position)}}
+		var submissionDoc bsonx.Doc
+		resErr := d.db.Collection(ColNameSubmission).FindOne(ctx,
+			filterPosition, &options.FindOneOptions{Sort: sortFilter}).Decode(&submissionDoc)
+		if resErr == mongo.ErrNoDocuments {
+			continue
+		} else if resErr != nil {
+			return []models.CommitmentSubmission{}, errors.New(fmt.Sprintf("%s %v", ErrorSubmissionGet, resErr))
+		}
+		submissionModel := &models.CommitmentSubmission{}
+		if modelErr := models.GetModelFromDocument(&submissionDoc, submissionModel); modelErr != nil {
+			return []models.CommitmentSubmission{}, errors.New(fmt.Sprintf("%s %v", BadDataSubmissionCol, modelErr))
+		}
+		submissions = append(submissions, *submissionModel)
+	}
+	return submissions, nil
+}
+
+// Get pending commitment submissions from the CommitmentSubmission collection, oldest first
+func (d *DbMongo) GetPendingCommitmentSubmissions() ([]models.CommitmentSubmission, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	sortFilter := bsonx.Doc{{"_id", bsonx.Int32(1)}}
+	filterPending := bsonx.Doc{{models.CommitmentSubmissionStatusName, bsonx.String(models.SubmissionStatusPending)}}
+	res, resErr := d.db.Collection(ColNameSubmission).Find(ctx, filterPending, &options.FindOptions{Sort: sortFilter})
+	if resErr != nil {
+		return []models.CommitmentSubmission{}, errors.New(fmt.Sprintf("%s %v", ErrorSubmissionGet, resErr))
+	}
+
+	submissions := []models.CommitmentSubmission{}
+	for res.Next(ctx) {
+		var submissionDoc bsonx.Doc
+		if err := res.Decode(&submissionDoc); err != nil {
+			return []models.CommitmentSubmission{}, errors.New(fmt.Sprintf("%s %v", BadDataSubmissionCol, err))
+		}
+		submissionModel := &models.CommitmentSubmission{}
+		if modelErr := models.GetModelFromDocument(&submissionDoc, submissionModel); modelErr != nil {
+			return []models.CommitmentSubmission{}, errors.New(fmt.Sprintf("%s %v", BadDataSubmissionCol, modelErr))
+		}
+		submissions = append(submissions, *submissionModel)
+	}
+	if err := res.Err(); err != nil {
+		return []models.CommitmentSubmission{}, errors.New(fmt.Sprintf("%s %v", BadDataSubmissionCol, err))
+	}
+	return submissions, nil
+}
+
+// Get key rotations from the KeyRotation collection ordered by id
+func (d *DbMongo) GetKeyRotations() ([]models.KeyRotation, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	sortFilter := bsonx.Doc{{models.KeyRotationIdName, bsonx.Int32(1)}}
+	res, resErr := d.db.Collection(ColNameKeyRotation).Find(ctx, bsonx.Doc{}, &options.FindOptions{Sort: sortFilter})
+	if resErr != nil {
+		return []models.KeyRotation{}, errors.New(fmt.Sprintf("%s %v", ErrorKeyRotationGet, resErr))
+	}
+
+	rotations := []models.KeyRotation{}
+	for res.Next(ctx) {
+		var rotationDoc bsonx.Doc
+		if err := res.Decode(&rotationDoc); err != nil {
+			return []models.KeyRotation{}, errors.New(fmt.Sprintf("%s %v", BadDataKeyRotationCol, err))
+		}
+		rotationModel := &models.KeyRotation{}
+		modelErr := models.GetModelFromDocument(&rotationDoc, rotationModel)
+		if modelErr != nil {
+			return []models.KeyRotation{}, errors.New(fmt.Sprintf("%s %v", BadDataKeyRotationCol, modelErr))
+		}
+		rotations = append(rotations, *rotationModel)
+	}
+	if err := res.Err(); err != nil {
+		return []models.KeyRotation{}, errors.New(fmt.Sprintf("%s %v", BadDataKeyRotationCol, err))
+	}
+	return rotations, nil
+}
+
+// Get signer statuses from the SignerStatus collection ordered by position
+func (d *DbMongo) GetSignerStatuses() ([]models.SignerStatus, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	sortFilter := bsonx.Doc{{models.SignerStatusPositionName, bsonx.Int32(1)}}
+	res, resErr := d.db.Collection(ColNameSignerStatus).Find(ctx, bsonx.Doc{}, &options.FindOptions{Sort: sortFilter})
+	if resErr != nil {
+		return []models.SignerStatus{}, errors.New(fmt.Sprintf("%s %v", ErrorSignerStatusGet, resErr))
+	}
+
+	statuses := []models.SignerStatus{}
+	for res.Next(ctx) {
+		var statusDoc bsonx.Doc
+		if err := res.Decode(&statusDoc); err != nil {
+			return []models.SignerStatus{}, errors.New(fmt.Sprintf("%s %v", BadDataSignerStatusCol, err))
+		}
+		statusModel := &models.SignerStatus{}
+		modelErr := models.GetModelFromDocument(&statusDoc, statusModel)
+		if modelErr != nil {
+			return []models.SignerStatus{}, errors.New(fmt.Sprintf("%s %v", BadDataSignerStatusCol, modelErr))
+		}
+		statuses = append(statuses, *statusModel)
+	}
+	if err := res.Err(); err != nil {
+		return []models.SignerStatus{}, errors.New(fmt.Sprintf("%s %v", BadDataSignerStatusCol, err))
+	}
+	return statuses, nil
+}
+
+// Get service state from the ServiceState collection
+// Return default state if no state has been saved for the service
+func (d *DbMongo) GetServiceState(name string) (models.ServiceState, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	filterState := bsonx.Doc{
+		{models.ServiceStateNameName, bsonx.String(name)},
+	}
+
+	var stateDoc bsonx.Doc
+	resErr := d.db.Collection(ColNameServiceState).FindOne(ctx, filterState).Decode(&stateDoc)
+	if resErr == mongo.ErrNoDocuments {
+		return models.ServiceState{Name: name}, nil
+	} else if resErr != nil {
+		return models.ServiceState{}, errors.New(fmt.Sprintf("%s %v", ErrorServiceStateGet, resErr))
+	}
+
+	stateModel := &models.ServiceState{}
+	if modelErr := models.GetModelFromDocument(&stateDoc, stateModel); modelErr != nil {
+		return models.ServiceState{}, errors.New(fmt.Sprintf("%s %v", BadDataServiceStateModel, modelErr))
+	}
+	return *stateModel, nil
+}
+
+// Save slot group to the SlotGroup collection
+func (d *DbMongo) SaveSlotGroup(group models.SlotGroup) error {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	// get document representation of slot group
+	docGroup, docErr := models.GetDocumentFromModel(group)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BadDataSlotGroupModel, docErr))
+	}
+
+	newGroup := bsonx.Doc{
+		{"$set", bsonx.Document(*docGroup)},
+	}
+
+	// search if slot group already exists
+	filterGroup := bsonx.Doc{
+		{models.SlotGroupClientPositionName, bsonx.Int32(group.ClientPosition)},
+		{models.SlotGroupMerkleRootName, bsonx.String(group.MerkleRoot.String())},
+	}
+
+	// insert or update slot group
+	var t bsonx.Doc
+	opts := &options.FindOneAndUpdateOptions{}
+	opts.SetUpsert(true)
+	res := d.db.Collection(ColNameSlotGroup).FindOneAndUpdate(ctx, filterGroup, newGroup, opts)
+	resErr := res.Decode(&t)
+	if resErr != nil && resErr != mongo.ErrNoDocuments {
+		return errors.New(fmt.Sprintf("%s %v", ErrorSlotGroupSave, resErr))
+	}
+	return nil
+}
+
+// Get latest slot group for client position containing member commitment
+// Return empty slot group if no such group exists
+func (d *DbMongo) GetSlotGroup(position int32, commitment chainhash.Hash) (models.SlotGroup, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	sortFilter := bsonx.Doc{{"_id", bsonx.Int32(-1)}}
+	filterGroup := bsonx.Doc{
+		{models.SlotGroupClientPositionName, bsonx.Int32(position)},
+		{models.SlotGroupCommitmentsName, bsonx.String(commitment.String())},
+	}
+
+	var groupDoc bsonx.Doc
+	resErr := d.db.Collection(ColNameSlotGroup).FindOne(ctx, filterGroup,
+		&options.FindOneOptions{Sort: sortFilter}).Decode(&groupDoc)
+	if resErr == mongo.ErrNoDocuments {
+		return models.SlotGroup{}, nil
+	} else if resErr != nil {
+		return models.SlotGroup{}, errors.New(fmt.Sprintf("%s %v", ErrorSlotGroupGet, resErr))
+	}
+
+	groupModel := &models.SlotGroup{}
+	if modelErr := models.GetModelFromDocument(&groupDoc, groupModel); modelErr != nil {
+		return models.SlotGroup{}, errors.New(fmt.Sprintf("%s %v", BadDataSlotGroupModel, modelErr))
+	}
+	return *groupModel, nil
+}
+
+// Get first merkle proof for commitment in client position
+// Return empty proof if the commitment has not been attested yet
+func (d *DbMongo) GetCommitmentMerkleProof(position int32, commitment chainhash.Hash) (models.CommitmentMerkleProof, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	sortFilter := bsonx.Doc{{"_id", bsonx.Int32(1)}}
+	filterProof := bsonx.Doc{
+		{models.ProofClientPositionName, bsonx.Int32(position)},
+		{models.ProofCommitmentName, bsonx.String(commitment.String())},
+	}
+
+	var proofDoc bsonx.Doc
+	resErr := d.db.Collection(ColNameMerkleProof).FindOne(ctx, filterProof,
+		&options.FindOneOptions{Sort: sortFilter}).Decode(&proofDoc)
+	if resErr == mongo.ErrNoDocuments {
+		return models.CommitmentMerkleProof{}, nil
+	} else if resErr != nil {
+		return models.CommitmentMerkleProof{}, errors.New(fmt.Sprintf("%s %v", ErrorMerkleProofGet, resErr))
+	}
+
+	proofModel := &models.CommitmentMerkleProof{}
+	if modelErr := models.GetModelFromDocument(&proofDoc, proofModel); modelErr != nil {
+		return models.CommitmentMerkleProof{}, errors.New(fmt.Sprintf("%s %v", BadDataMerkleProofCol, modelErr))
+	}
+	if err := verifyDocumentChecksum(ColNameMerkleProof, &proofDoc, *proofModel); err != nil {
+		return models.CommitmentMerkleProof{}, err
+	}
+	return *proofModel, nil
+}
+
+// Get earliest merkle proof of the commitment in any client position
+// Return empty proof if the commitment has not been attested
+func (d *DbMongo) GetMerkleProofByCommitment(commitment chainhash.Hash) (models.CommitmentMerkleProof, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	sortFilter := bsonx.Doc{{"_id", bsonx.Int32(1)}}
+	filterProof := bsonx.Doc{{models.ProofCommitmentName, bsonx.String(commitment.String())}}
+
+	var proofDoc bsonx.Doc
+	resErr := d.db.Collection(ColNameMerkleProof).FindOne(ctx, filterProof,
+		&options.FindOneOptions{Sort: sortFilter}).Decode(&proofDoc)
+	if resErr == mongo.ErrNoDocuments {
+		return models.CommitmentMerkleProof{}, nil
+	} else if resErr != nil {
+		return models.CommitmentMerkleProof{}, errors.New(fmt.Sprintf("%s %v", ErrorMerkleProofGet, resErr))
+	}
+
+	proofModel := &models.CommitmentMerkleProof{}
+	if modelErr := models.GetModelFromDocument(&proofDoc, proofModel); modelErr != nil {
+		return models.CommitmentMerkleProof{}, errors.New(fmt.Sprintf("%s %v", BadDataMerkleProofCol, modelErr))
+	}
+	if err := verifyDocumentChecksum(ColNameMerkleProof, &proofDoc, *proofModel); err != nil {
+		return models.CommitmentMerkleProof{}, err
+	}
+	return *proofModel, nil
+}
+
+// Get merkle proof for client position in the merkle root of the latest
+// confirmed attestation. Return empty proof if there is no confirmed
+// attestation or the client position is not in its merkle tree
+func (d *DbMongo) GetLatestCommitmentMerkleProof(position int32) (models.CommitmentMerkleProof, error) {
+	merkleRoot, rootErr := d.GetLatestAttestationMerkleRoot(true)
+	if rootErr != nil {
+		return models.CommitmentMerkleProof{}, rootErr
+	} else if merkleRoot == "" {
+		return models.CommitmentMerkleProof{}, nil
+	}
+
+	ctx, cancel := d.context()
+	defer cancel()
+
+	filterProof := bsonx.Doc{
+		{models.ProofMerkleRootName, bsonx.String(merkleRoot)},
+		{models.ProofClientPositionName, bsonx.Int32(position)},
+	}
+
+	var proofDoc bsonx.Doc
+	resErr := d.db.Collection(ColNameMerkleProof).FindOne(ctx, filterProof).Decode(&proofDoc)
+	if resErr == mongo.ErrNoDocuments {
+		return models.CommitmentMerkleProof{}, nil
+	} else if resErr != nil {
+		return models.CommitmentMerkleProof{}, errors.New(fmt.Sprintf("%s %v", ErrorMerkleProofGet, resErr))
+	}
+
+	proofModel := &models.CommitmentMerkleProof{}
+	if modelErr := models.GetModelFromDocument(&proofDoc, proofModel); modelErr != nil {
+		return models.CommitmentMerkleProof{}, errors.New(fmt.Sprintf("%s %v", BadDataMerkleProofCol, modelErr))
+	}
+	if err := verifyDocumentChecksum(ColNameMerkleProof, &proofDoc, *proofModel); err != nil {
+		return models.CommitmentMerkleProof{}, err
+	}
+	return *proofModel, nil
+}
+
+// Get in flight attestation from the InFlightAttestation collection
+// Return empty attestation if none has been saved
+func (d *DbMongo) GetInFlightAttestation() (models.InFlightAttestation, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	var inFlightDoc bsonx.Doc
+	resErr := d.db.Collection(ColNameInFlight).FindOne(ctx, bsonx.Doc{}).Decode(&inFlightDoc)
+	if resErr == mongo.ErrNoDocuments {
+		return models.InFlightAttestation{}, nil
+	} else if resErr != nil {
+		return models.InFlightAttestation{}, errors.New(fmt.Sprintf("%s %v", ErrorInFlightGet, resErr))
+	}
+
+	inFlightModel := &models.InFlightAttestation{}
+	if modelErr := models.GetModelFromDocument(&inFlightDoc, inFlightModel); modelErr != nil {
+		return models.InFlightAttestation{}, errors.New(fmt.Sprintf("%s %v", BadDataInFlightModel, modelErr))
+	}
+	return *inFlightModel, nil
+}
+
+// Get attestation info of the attestation committing to the merkle root
+// preferring confirmed attestations, with only the txid set if no info is
+// stored yet. Return empty info if the merkle root has not been attested
+func (d *DbMongo) GetAttestationInfoByMerkleRoot(merkleRoot chainhash.Hash) (models.AttestationInfo, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	sortFilter := bsonx.Doc{
+		{models.AttestationConfirmedName, bsonx.Int32(-1)},
+		{models.AttestationInsertedAtName, bsonx.Int32(1)},
+	}
+	filterAttestation := bsonx.Doc{{models.AttestationMerkleRootName, bsonx.String(merkleRoot.String())}}
+
+	var attestationDoc bsonx.Doc
+	resErr := d.db.Collection(ColNameAttestation).FindOne(ctx, filterAttestation,
+		&options.FindOneOptions{Sort: sortFilter}).Decode(&attestationDoc)
+	if resErr == mongo.ErrNoDocuments {
+		return models.AttestationInfo{}, nil
+	} else if resErr != nil {
+		return models.AttestationInfo{}, errors.New(fmt.Sprintf("%s %v", ErrorAttestationGet, resErr))
+	}
+	txid := attestationDoc.Lookup(models.AttestationTxidName).StringValue()
+
+	filterInfo := bsonx.Doc{{models.AttestationInfoTxidName, bsonx.String(txid)}}
+	var infoDoc bsonx.Doc
+	resErr = d.db.Collection(ColNameAttestationInfo).FindOne(ctx, filterInfo).Decode(&infoDoc)
+	if resErr == mongo.ErrNoDocuments {
+		return models.AttestationInfo{Txid: txid}, nil
+	} else if resErr != nil {
+		return models.AttestationInfo{}, errors.New(fmt.Sprintf("%s %v", ErrorAttestationGet, resErr))
+	}
+
+	infoModel := &models.AttestationInfo{}
+	if modelErr := models.GetModelFromDocument(&infoDoc, infoModel); modelErr != nil {
+		return models.AttestationInfo{}, errors.New(fmt.Sprintf("%s %v", BadDataAttestationInfoModel, modelErr))
+	}
+	return *infoModel, nil
+}
+
+// Save staychain tx to the StaychainTx collection
+// updating the spending tx of a tx already indexed
+func (d *DbMongo) SaveStaychainTx(tx models.StaychainTx) error {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	docTx, docErr := models.GetDocumentFromModel(tx)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BadDataStaychainTxModel, docErr))
+	}
+	filterTx := bsonx.Doc{{models.StaychainTxTxidName, bsonx.String(tx.Txid)}}
+	newTx := bsonx.Doc{{"$set", bsonx.Document(*docTx)}}
+	_, resErr := d.db.Collection(ColNameStaychainTx).UpdateOne(ctx, filterTx, newTx,
+		options.Update().SetUpsert(true))
+	if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorStaychainTxSave, resErr))
+	}
+	return nil
+}
+
+// Get staychain tx with txid from the StaychainTx collection
+// An empty tx is returned if the txid is not indexed
+func (d *DbMongo) GetStaychainTx(txid string) (models.StaychainTx, error) {
+	return d.findStaychainTx(bsonx.Doc{{models.StaychainTxTxidName, bsonx.String(txid)}}, nil)
+}
+
+// Get latest staychain tx indexed from the StaychainTx collection
+// An empty tx is returned if no tx is indexed
+func (d *DbMongo) GetLatestStaychainTx() (models.StaychainTx, error) {
+	return d.findStaychainTx(bsonx.Doc{},
+		&options.FindOneOptions{Sort: bsonx.Doc{{models.StaychainTxIndexName, bsonx.Int32(-1)}}})
+}
+
+// Find staychain tx matching the filter from the StaychainTx collection
+func (d *DbMongo) findStaychainTx(filter bsonx.Doc, opts *options.FindOneOptions) (models.StaychainTx, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	var txDoc bsonx.Doc
+	resErr := d.db.Collection(ColNameStaychainTx).FindOne(ctx, filter, opts).Decode(&txDoc)
+	if resErr == mongo.ErrNoDocuments {
+		return models.StaychainTx{}, nil
+	} else if resErr != nil {
+		return models.StaychainTx{}, errors.New(fmt.Sprintf("%s %v", ErrorStaychainTxGet, resErr))
+	}
+	txModel := &models.StaychainTx{}
+	if modelErr := models.GetModelFromDocument(&txDoc, txModel); modelErr != nil {
+		return models.StaychainTx{}, errors.New(fmt.Sprintf("%s %v", BadDataStaychainTxCol, modelErr))
+	}
+	return *txModel, nil
+}
+
+// Get page of staychain txs from the StaychainTx collection, latest
+// first, with the total number of txs indexed
+func (d *DbMongo) GetStaychainTxs(offset int64, limit int64) (models.StaychainTxs, error) {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	txs := models.StaychainTxs{Offset: offset, Limit: limit, Txs: []models.StaychainTx{}}
+	total, countErr := d.db.Collection(ColNameStaychainTx).CountDocuments(ctx, bsonx.Doc{})
+	if countErr != nil {
+		return models.StaychainTxs{}, errors.New(fmt.Sprintf("%s %v", ErrorStaychainTxGet, countErr))
+	}
+	txs.Total = total
+	if total <= offset || limit <= 0 {
+		return txs, nil
+	}
+
+	opts := options.Find().SetSort(bsonx.Doc{{models.StaychainTxIndexName, bsonx.Int32(-1)}}).
+		SetSkip(offset).SetLimit(limit)
+	res, resErr := d.db.Collection(ColNameStaychainTx).Find(ctx, bsonx.Doc{}, opts)
+	if resErr != nil {
+		return models.StaychainTxs{}, errors.New(fmt.Sprintf("%s %v", ErrorStaychainTxGet, resErr))
+	}
+	for res.Next(ctx) {
+		var txDoc bsonx.Doc
+		if err := res.Decode(&txDoc); err != nil {
+			return models.StaychainTxs{}, errors.New(fmt.Sprintf("%s %v", BadDataStaychainTxCol, err))
+		}
+		txModel := &models.StaychainTx{}
+		if modelErr := models.GetModelFromDocument(&txDoc, txModel); modelErr != nil {
+			return models.StaychainTxs{}, errors.New(fmt.Sprintf("%s %v", BadDataStaychainTxCol, modelErr))
+		}
+		txs.Txs = append(txs.Txs, *txModel)
+	}
+	if err := res.Err(); err != nil {
+		return models.StaychainTxs{}, errors.New(fmt.Sprintf("%s %v", BadDataStaychainTxCol, err))
+	}
+	return txs, nil
+}
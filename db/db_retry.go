@@ -0,0 +1,512 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"mainstay/config"
+	"mainstay/log"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// db retry consts
+const (
+	DbDefaultRetries    = 3
+	DbRetryInitialDelay = 500 * time.Millisecond
+	DbRetryMaxDelay     = 10 * time.Second
+
+	WarningDbRetry             = "Db operation failed - retrying"
+	WarningInvalidDbRetriesArg = "Invalid db retries config value"
+)
+
+// DbRetry struct
+// Wraps a Db interface and retries db calls failing with transient errors,
+// e.g. while the database is unreachable, with exponential backoff. Calls
+// inserting audit records are not retried, as a call timing out after the
+// insert succeeded would record a duplicate
+type DbRetry struct {
+	ctx          context.Context
+	db           Db
+	retries      int
+	initialDelay time.Duration
+}
+
+// Return new DbRetry instance wrapping db with retries from db config
+func NewDbRetry(ctx context.Context, db Db, dbConnectivity config.DbConfig) *DbRetry {
+	retries := DbDefaultRetries
+	if dbConnectivity.Retries >= 0 {
+		retries = dbConnectivity.Retries
+	}
+	return &DbRetry{ctx, db, retries, DbRetryInitialDelay}
+}
+
+// Call db method retrying on transient errors until retries are exhausted
+// or the context is cancelled, doubling the delay between attempts
+func (d *DbRetry) retry(method string, call func() error) error {
+	delay := d.initialDelay
+	for attempt := 0; ; attempt++ {
+		err := call()
+		if err == nil || attempt >= d.retries || !IsTransientError(err) {
+			return err
+		}
+		log.WithFields(log.Fields{log.FieldError: err}).Warnf("%s: %s (attempt %d of %d)\n",
+			WarningDbRetry, method, attempt+1, d.retries)
+		select {
+		case <-d.ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > DbRetryMaxDelay {
+			delay = DbRetryMaxDelay
+		}
+	}
+}
+
+// Ping database
+// Not retried so that health checks report the current connectivity
+func (d *DbRetry) Ping() error {
+	return d.db.Ping()
+}
+
+// Save latest attestation
+func (d *DbRetry) SaveAttestation(attestation models.Attestation) error {
+	return d.retry("SaveAttestation", func() error {
+		return d.db.SaveAttestation(attestation)
+	})
+}
+
+// Save latest attestation info
+func (d *DbRetry) SaveAttestationInfo(attestationInfo models.AttestationInfo) error {
+	return d.retry("SaveAttestationInfo", func() error {
+		return d.db.SaveAttestationInfo(attestationInfo)
+	})
+}
+
+// Save merkle commitments
+func (d *DbRetry) SaveMerkleCommitments(commitments []models.CommitmentMerkleCommitment) error {
+	return d.retry("SaveMerkleCommitments", func() error {
+		return d.db.SaveMerkleCommitments(commitments)
+	})
+}
+
+// Save merkle proofs
+func (d *DbRetry) SaveMerkleProofs(proofs []models.CommitmentMerkleProof) error {
+	return d.retry("SaveMerkleProofs", func() error {
+		return d.db.SaveMerkleProofs(proofs)
+	})
+}
+
+// Save fee bump - not retried
+func (d *DbRetry) SaveFeeBump(feeBump models.FeeBump) error {
+	return d.db.SaveFeeBump(feeBump)
+}
+
+// Save dry run attestation - not retried
+func (d *DbRetry) SaveDryRunAttestation(attestation models.DryRunAttestation) error {
+	return d.db.SaveDryRunAttestation(attestation)
+}
+
+// Save service state
+func (d *DbRetry) SaveServiceState(state models.ServiceState) error {
+	return d.retry("SaveServiceState", func() error {
+		return d.db.SaveServiceState(state)
+	})
+}
+
+// Save key rotation
+func (d *DbRetry) SaveKeyRotation(rotation models.KeyRotation) error {
+	return d.retry("SaveKeyRotation", func() error {
+		return d.db.SaveKeyRotation(rotation)
+	})
+}
+
+// Save round snapshot
+func (d *DbRetry) SaveRoundSnapshot(snapshot models.RoundSnapshot) error {
+	return d.retry("SaveRoundSnapshot", func() error {
+		return d.db.SaveRoundSnapshot(snapshot)
+	})
+}
+
+// Save commitment submissions
+func (d *DbRetry) SaveCommitmentSubmissions(submissions []models.CommitmentSubmission) error {
+	return d.retry("SaveCommitmentSubmissions", func() error {
+		return d.db.SaveCommitmentSubmissions(submissions)
+	})
+}
+
+// Save in-flight attestation
+// Not retried as it is saved on shutdown after the context is cancelled
+func (d *DbRetry) SaveInFlightAttestation(inFlight models.InFlightAttestation) error {
+	return d.db.SaveInFlightAttestation(inFlight)
+}
+
+// Delete in-flight attestation
+func (d *DbRetry) DeleteInFlightAttestation() error {
+	return d.retry("DeleteInFlightAttestation", func() error {
+		return d.db.DeleteInFlightAttestation()
+	})
+}
+
+// Get attestation count
+func (d *DbRetry) getAttestationCount(confirmed ...bool) (int64, error) {
+	var count int64
+	err := d.retry("getAttestationCount", func() (err error) {
+		count, err = d.db.getAttestationCount(confirmed...)
+		return err
+	})
+	return count, err
+}
+
+// Get number of confirmed attestations
+func (d *DbRetry) GetConfirmedAttestationCount() (int64, error) {
+	var count int64
+	err := d.retry("GetConfirmedAttestationCount", func() (err error) {
+		count, err = d.db.GetConfirmedAttestationCount()
+		return err
+	})
+	return count, err
+}
+
+// Get total fee of attestations confirmed since time
+func (d *DbRetry) GetAttestationFees(since int64) (int64, error) {
+	var fees int64
+	err := d.retry("GetAttestationFees", func() (err error) {
+		fees, err = d.db.GetAttestationFees(since)
+		return err
+	})
+	return fees, err
+}
+
+// Get merkle root of attestation with txid
+func (d *DbRetry) getAttestationMerkleRoot(txid chainhash.Hash) (string, error) {
+	var root string
+	err := d.retry("getAttestationMerkleRoot", func() (err error) {
+		root, err = d.db.getAttestationMerkleRoot(txid)
+		return err
+	})
+	return root, err
+}
+
+// Get merkle root of latest attestation
+func (d *DbRetry) GetLatestAttestationMerkleRoot(confirmed bool) (string, error) {
+	var root string
+	err := d.retry("GetLatestAttestationMerkleRoot", func() (err error) {
+		root, err = d.db.GetLatestAttestationMerkleRoot(confirmed)
+		return err
+	})
+	return root, err
+}
+
+// Get latest client commitments
+func (d *DbRetry) GetClientCommitments() ([]models.ClientCommitment, error) {
+	var commitments []models.ClientCommitment
+	err := d.retry("GetClientCommitments", func() (err error) {
+		commitments, err = d.db.GetClientCommitments()
+		return err
+	})
+	return commitments, err
+}
+
+// Get merkle commitments of attestation with txid
+func (d *DbRetry) GetAttestationMerkleCommitments(txid chainhash.Hash) ([]models.CommitmentMerkleCommitment, error) {
+	var commitments []models.CommitmentMerkleCommitment
+	err := d.retry("GetAttestationMerkleCommitments", func() (err error) {
+		commitments, err = d.db.GetAttestationMerkleCommitments(txid)
+		return err
+	})
+	return commitments, err
+}
+
+// Get service state
+func (d *DbRetry) GetServiceState(name string) (models.ServiceState, error) {
+	var state models.ServiceState
+	err := d.retry("GetServiceState", func() (err error) {
+		state, err = d.db.GetServiceState(name)
+		return err
+	})
+	return state, err
+}
+
+// Get key rotations
+func (d *DbRetry) GetKeyRotations() ([]models.KeyRotation, error) {
+	var rotations []models.KeyRotation
+	err := d.retry("GetKeyRotations", func() (err error) {
+		rotations, err = d.db.GetKeyRotations()
+		return err
+	})
+	return rotations, err
+}
+
+// Get pending commitment submissions
+func (d *DbRetry) GetPendingCommitmentSubmissions() ([]models.CommitmentSubmission, error) {
+	var submissions []models.CommitmentSubmission
+	err := d.retry("GetPendingCommitmentSubmissions", func() (err error) {
+		submissions, err = d.db.GetPendingCommitmentSubmissions()
+		return err
+	})
+	return submissions, err
+}
+
+// Get in-flight attestation
+func (d *DbRetry) GetInFlightAttestation() (models.InFlightAttestation, error) {
+	var inFlight models.InFlightAttestation
+	err := d.retry("GetInFlightAttestation", func() (err error) {
+		inFlight, err = d.db.GetInFlightAttestation()
+		return err
+	})
+	return inFlight, err
+}
+
+// Get confirmed attestations
+func (d *DbRetry) GetAttestations() ([]models.AttestationBSON, error) {
+	var attestations []models.AttestationBSON
+	err := d.retry("GetAttestations", func() (err error) {
+		attestations, err = d.db.GetAttestations()
+		return err
+	})
+	return attestations, err
+}
+
+// Get attestation document by txid
+func (d *DbRetry) GetAttestation(txid chainhash.Hash) (models.AttestationBSON, error) {
+	var attestation models.AttestationBSON
+	err := d.retry("GetAttestation", func() (err error) {
+		attestation, err = d.db.GetAttestation(txid)
+		return err
+	})
+	return attestation, err
+}
+
+// Get client details
+func (d *DbRetry) GetClientDetails() ([]models.ClientDetails, error) {
+	var details []models.ClientDetails
+	err := d.retry("GetClientDetails", func() (err error) {
+		details, err = d.db.GetClientDetails()
+		return err
+	})
+	return details, err
+}
+
+// Get merkle proofs of attestation with txid
+func (d *DbRetry) GetAttestationMerkleProofs(txid chainhash.Hash) ([]models.CommitmentMerkleProof, error) {
+	var proofs []models.CommitmentMerkleProof
+	err := d.retry("GetAttestationMerkleProofs", func() (err error) {
+		proofs, err = d.db.GetAttestationMerkleProofs(txid)
+		return err
+	})
+	return proofs, err
+}
+
+// Save client details
+func (d *DbRetry) SaveClientDetails(details models.ClientDetails) error {
+	return d.retry("SaveClientDetails", func() error {
+		return d.db.SaveClientDetails(details)
+	})
+}
+
+// Save client commitment
+func (d *DbRetry) SaveClientCommitment(commitment models.ClientCommitment) error {
+	return d.retry("SaveClientCommitment", func() error {
+		return d.db.SaveClientCommitment(commitment)
+	})
+}
+
+// Save client commitments of several client positions
+func (d *DbRetry) SaveClientCommitments(commitments []models.ClientCommitment) error {
+	return d.retry("SaveClientCommitments", func() error {
+		return d.db.SaveClientCommitments(commitments)
+	})
+}
+
+// Save commitment exclusion - not retried
+func (d *DbRetry) SaveCommitmentExclusion(exclusion models.CommitmentExclusion) error {
+	return d.db.SaveCommitmentExclusion(exclusion)
+}
+
+// Return commitment exclusions of client position
+func (d *DbRetry) GetCommitmentExclusions(position int32) ([]models.CommitmentExclusion, error) {
+	var exclusions []models.CommitmentExclusion
+	err := d.retry("GetCommitmentExclusions", func() (err error) {
+		exclusions, err = d.db.GetCommitmentExclusions(position)
+		return err
+	})
+	return exclusions, err
+}
+
+// Get commitment history page of client position
+func (d *DbRetry) GetCommitmentHistory(position int32, offset int64, limit int64) (models.CommitmentHistory, error) {
+	var history models.CommitmentHistory
+	err := d.retry("GetCommitmentHistory", func() (err error) {
+		history, err = d.db.GetCommitmentHistory(position, offset, limit)
+		return err
+	})
+	return history, err
+}
+
+// Return round snapshot of round
+func (d *DbRetry) GetRoundSnapshot(round int64) (models.RoundSnapshot, error) {
+	var snapshot models.RoundSnapshot
+	err := d.retry("GetRoundSnapshot", func() (err error) {
+		snapshot, err = d.db.GetRoundSnapshot(round)
+		return err
+	})
+	return snapshot, err
+}
+
+// Return page of round snapshots
+func (d *DbRetry) GetRoundSnapshots(merkleRoot string, offset int64, limit int64) (models.RoundSnapshots, error) {
+	var snapshots models.RoundSnapshots
+	err := d.retry("GetRoundSnapshots", func() (err error) {
+		snapshots, err = d.db.GetRoundSnapshots(merkleRoot, offset, limit)
+		return err
+	})
+	return snapshots, err
+}
+
+// Return commitment submission with id
+func (d *DbRetry) GetCommitmentSubmission(id string) (models.CommitmentSubmission, error) {
+	var submission models.CommitmentSubmission
+	err := d.retry("GetCommitmentSubmission", func() (err error) {
+		submission, err = d.db.GetCommitmentSubmission(id)
+		return err
+	})
+	return submission, err
+}
+
+// Return latest commitment submission of each client position
+func (d *DbRetry) GetLatestCommitmentSubmissions(positions []int32) ([]models.CommitmentSubmission, error) {
+	var submissions []models.CommitmentSubmission
+	err := d.retry("GetLatestCommitmentSubmissions", func() (err error) {
+		submissions, err = d.db.GetLatestCommitmentSubmissions(positions)
+		return err
+	})
+	return submissions, err
+}
+
+// Save slot group
+func (d *DbRetry) SaveSlotGroup(group models.SlotGroup) error {
+	return d.retry("SaveSlotGroup", func() error {
+		return d.db.SaveSlotGroup(group)
+	})
+}
+
+// Get slot group of client position and commitment
+func (d *DbRetry) GetSlotGroup(position int32, commitment chainhash.Hash) (models.SlotGroup, error) {
+	var group models.SlotGroup
+	err := d.retry("GetSlotGroup", func() (err error) {
+		group, err = d.db.GetSlotGroup(position, commitment)
+		return err
+	})
+	return group, err
+}
+
+// Get commitment merkle proof of client position and commitment
+func (d *DbRetry) GetCommitmentMerkleProof(position int32, commitment chainhash.Hash) (models.CommitmentMerkleProof, error) {
+	var proof models.CommitmentMerkleProof
+	err := d.retry("GetCommitmentMerkleProof", func() (err error) {
+		proof, err = d.db.GetCommitmentMerkleProof(position, commitment)
+		return err
+	})
+	return proof, err
+}
+
+// Get earliest merkle proof of the commitment in any client position
+func (d *DbRetry) GetMerkleProofByCommitment(commitment chainhash.Hash) (models.CommitmentMerkleProof, error) {
+	var proof models.CommitmentMerkleProof
+	err := d.retry("GetMerkleProofByCommitment", func() (err error) {
+		proof, err = d.db.GetMerkleProofByCommitment(commitment)
+		return err
+	})
+	return proof, err
+}
+
+// Get merkle proof for client position in the latest confirmed attestation
+func (d *DbRetry) GetLatestCommitmentMerkleProof(position int32) (models.CommitmentMerkleProof, error) {
+	var proof models.CommitmentMerkleProof
+	err := d.retry("GetLatestCommitmentMerkleProof", func() (err error) {
+		proof, err = d.db.GetLatestCommitmentMerkleProof(position)
+		return err
+	})
+	return proof, err
+}
+
+// Get attestation info of the attestation committing to the merkle root
+func (d *DbRetry) GetAttestationInfoByMerkleRoot(merkleRoot chainhash.Hash) (models.AttestationInfo, error) {
+	var info models.AttestationInfo
+	err := d.retry("GetAttestationInfoByMerkleRoot", func() (err error) {
+		info, err = d.db.GetAttestationInfoByMerkleRoot(merkleRoot)
+		return err
+	})
+	return info, err
+}
+
+// Save staychain tx
+func (d *DbRetry) SaveStaychainTx(tx models.StaychainTx) error {
+	return d.retry("SaveStaychainTx", func() error {
+		return d.db.SaveStaychainTx(tx)
+	})
+}
+
+// Return staychain tx with txid
+func (d *DbRetry) GetStaychainTx(txid string) (models.StaychainTx, error) {
+	var tx models.StaychainTx
+	err := d.retry("GetStaychainTx", func() (err error) {
+		tx, err = d.db.GetStaychainTx(txid)
+		return err
+	})
+	return tx, err
+}
+
+// Return latest staychain tx indexed
+func (d *DbRetry) GetLatestStaychainTx() (models.StaychainTx, error) {
+	var tx models.StaychainTx
+	err := d.retry("GetLatestStaychainTx", func() (err error) {
+		tx, err = d.db.GetLatestStaychainTx()
+		return err
+	})
+	return tx, err
+}
+
+// Return page of staychain txs
+func (d *DbRetry) GetStaychainTxs(offset int64, limit int64) (models.StaychainTxs, error) {
+	var txs models.StaychainTxs
+	err := d.retry("GetStaychainTxs", func() (err error) {
+		txs, err = d.db.GetStaychainTxs(offset, limit)
+		return err
+	})
+	return txs, err
+}
+
+// Return page of confirmed attestations
+func (d *DbRetry) GetAttestationsPage(minHeight int64, maxHeight int64, offset int64, limit int64) (
+	[]models.AttestationBSON, error) {
+	var attestations []models.AttestationBSON
+	err := d.retry("GetAttestationsPage", func() (err error) {
+		attestations, err = d.db.GetAttestationsPage(minHeight, maxHeight, offset, limit)
+		return err
+	})
+	return attestations, err
+}
+
+// Save signer status
+func (d *DbRetry) SaveSignerStatus(status models.SignerStatus) error {
+	return d.retry("SaveSignerStatus", func() error {
+		return d.db.SaveSignerStatus(status)
+	})
+}
+
+// Return signer statuses
+func (d *DbRetry) GetSignerStatuses() ([]models.SignerStatus, error) {
+	var statuses []models.SignerStatus
+	err := d.retry("GetSignerStatuses", func() (err error) {
+		statuses, err = d.db.GetSignerStatuses()
+		return err
+	})
+	return statuses, err
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"mainstay/config"
+	"mainstay/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// DbFake failing calls with the error given until failures are exhausted
+type dbFailing struct {
+	*DbFake
+	failures int
+	err      error
+	calls    int
+}
+
+// Fail getting client commitments until failures are exhausted
+func (d *dbFailing) GetClientCommitments() ([]models.ClientCommitment, error) {
+	d.calls++
+	if d.calls <= d.failures {
+		return nil, d.err
+	}
+	return d.DbFake.GetClientCommitments()
+}
+
+// Fail saving fee bumps until failures are exhausted
+func (d *dbFailing) SaveFeeBump(feeBump models.FeeBump) error {
+	d.calls++
+	if d.calls <= d.failures {
+		return d.err
+	}
+	return d.DbFake.SaveFeeBump(feeBump)
+}
+
+// Test retrying db calls failing with transient errors
+func TestDbRetry(t *testing.T) {
+	transientErr := errors.New(ErrorClientCommitmentGet + " server selection error")
+
+	// default retries until success
+	dbFail := &dbFailing{DbFake: NewDbFake(), failures: 2, err: transientErr}
+	dbRetry := NewDbRetry(context.Background(), dbFail, config.DbConfig{Retries: -1})
+	assert.Equal(t, DbDefaultRetries, dbRetry.retries)
+	dbRetry.initialDelay = 0
+	_, err := dbRetry.GetClientCommitments()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 3, dbFail.calls)
+
+	// retries exhausted
+	dbFail = &dbFailing{DbFake: NewDbFake(), failures: 5, err: transientErr}
+	dbRetry = NewDbRetry(context.Background(), dbFail, config.DbConfig{Retries: 2})
+	dbRetry.initialDelay = 0
+	_, err = dbRetry.GetClientCommitments()
+	assert.Equal(t, transientErr, err)
+	assert.Equal(t, 3, dbFail.calls)
+
+	// retries disabled
+	dbFail = &dbFailing{DbFake: NewDbFake(), failures: 1, err: transientErr}
+	dbRetry = NewDbRetry(context.Background(), dbFail, config.DbConfig{Retries: 0})
+	_, err = dbRetry.GetClientCommitments()
+	assert.Equal(t, transientErr, err)
+	assert.Equal(t, 1, dbFail.calls)
+
+	// bad data not retried
+	badDataErr := errors.New(BadDataClientCommitmentCol + " invalid")
+	dbFail = &dbFailing{DbFake: NewDbFake(), failures: 1, err: badDataErr}
+	dbRetry = NewDbRetry(context.Background(), dbFail, config.DbConfig{Retries: 3})
+	_, err = dbRetry.GetClientCommitments()
+	assert.Equal(t, badDataErr, err)
+	assert.Equal(t, 1, dbFail.calls)
+
+	// audit record inserts not retried
+	feeBumpErr := errors.New(ErrorFeeBumpSave + " timeout")
+	dbFail = &dbFailing{DbFake: NewDbFake(), failures: 1, err: feeBumpErr}
+	dbRetry = NewDbRetry(context.Background(), dbFail, config.DbConfig{Retries: 3})
+	assert.Equal(t, feeBumpErr, dbRetry.SaveFeeBump(models.FeeBump{}))
+	assert.Equal(t, 1, dbFail.calls)
+
+	// retries stopped on context cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dbFail = &dbFailing{DbFake: NewDbFake(), failures: 5, err: transientErr}
+	dbRetry = NewDbRetry(ctx, dbFail, config.DbConfig{Retries: 3})
+	_, err = dbRetry.GetClientCommitments()
+	assert.Equal(t, transientErr, err)
+	assert.Equal(t, 1, dbFail.calls)
+}
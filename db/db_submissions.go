@@ -0,0 +1,54 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"mainstay/models"
+)
+
+// Save client commitments and record a submission for each of them
+// Commitments equal to the latest submission of their client position are
+// not saved again and the latest submission is returned marked as duplicate,
+// so that repeated submissions keep the original submission and status.
+// Submissions are returned in the order of the commitments
+func SubmitClientCommitments(d Db, commitments []models.ClientCommitment) ([]models.CommitmentSubmission, error) {
+	var positions []int32
+	for _, c := range commitments {
+		positions = append(positions, c.ClientPosition)
+	}
+	latestSubmissions, latestErr := d.GetLatestCommitmentSubmissions(positions)
+	if latestErr != nil {
+		return nil, latestErr
+	}
+	latest := make(map[int32]models.CommitmentSubmission)
+	for _, submission := range latestSubmissions {
+		latest[submission.ClientPosition] = submission
+	}
+
+	var newCommitments []models.ClientCommitment
+	var newSubmissions []models.CommitmentSubmission
+	submissions := make([]models.CommitmentSubmission, len(commitments))
+	for i, c := range commitments {
+		if submission, ok := latest[c.ClientPosition]; ok && submission.Commitment == c.Commitment.String() {
+			submission.Duplicate = true
+			submissions[i] = submission
+			continue
+		}
+		submissions[i] = models.NewCommitmentSubmission(c)
+		newCommitments = append(newCommitments, c)
+		newSubmissions = append(newSubmissions, submissions[i])
+	}
+	if len(newCommitments) == 0 {
+		return submissions, nil
+	}
+
+	if saveErr := d.SaveClientCommitments(newCommitments); saveErr != nil {
+		return nil, saveErr
+	}
+	if saveErr := d.SaveCommitmentSubmissions(newSubmissions); saveErr != nil {
+		return nil, saveErr
+	}
+	return submissions, nil
+}
@@ -0,0 +1,441 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"mainstay/models"
+	"mainstay/tracing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DbTraced struct
+// Wraps a Db interface and records a span for each db call
+// as a child of the current span of the tracing scope
+type DbTraced struct {
+	db    Db
+	scope *tracing.Scope
+}
+
+// Return new DbTraced instance wrapping db
+func NewDbTraced(db Db, scope *tracing.Scope) *DbTraced {
+	return &DbTraced{db, scope}
+}
+
+// Start span for db method
+func (d *DbTraced) start(method string) trace.Span {
+	_, span := d.scope.Start("db." + method)
+	return span
+}
+
+// Ping database
+func (d *DbTraced) Ping() error {
+	span := d.start("Ping")
+	err := d.db.Ping()
+	tracing.End(span, err)
+	return err
+}
+
+// Save latest attestation
+func (d *DbTraced) SaveAttestation(attestation models.Attestation) error {
+	span := d.start("SaveAttestation")
+	err := d.db.SaveAttestation(attestation)
+	tracing.End(span, err)
+	return err
+}
+
+// Save latest attestation info
+func (d *DbTraced) SaveAttestationInfo(attestationInfo models.AttestationInfo) error {
+	span := d.start("SaveAttestationInfo")
+	err := d.db.SaveAttestationInfo(attestationInfo)
+	tracing.End(span, err)
+	return err
+}
+
+// Save merkle commitments
+func (d *DbTraced) SaveMerkleCommitments(commitments []models.CommitmentMerkleCommitment) error {
+	span := d.start("SaveMerkleCommitments")
+	err := d.db.SaveMerkleCommitments(commitments)
+	tracing.End(span, err)
+	return err
+}
+
+// Save merkle proofs
+func (d *DbTraced) SaveMerkleProofs(proofs []models.CommitmentMerkleProof) error {
+	span := d.start("SaveMerkleProofs")
+	err := d.db.SaveMerkleProofs(proofs)
+	tracing.End(span, err)
+	return err
+}
+
+// Save fee bump
+func (d *DbTraced) SaveFeeBump(feeBump models.FeeBump) error {
+	span := d.start("SaveFeeBump")
+	err := d.db.SaveFeeBump(feeBump)
+	tracing.End(span, err)
+	return err
+}
+
+// Save dry run attestation
+func (d *DbTraced) SaveDryRunAttestation(attestation models.DryRunAttestation) error {
+	span := d.start("SaveDryRunAttestation")
+	err := d.db.SaveDryRunAttestation(attestation)
+	tracing.End(span, err)
+	return err
+}
+
+// Save service state
+func (d *DbTraced) SaveServiceState(state models.ServiceState) error {
+	span := d.start("SaveServiceState")
+	err := d.db.SaveServiceState(state)
+	tracing.End(span, err)
+	return err
+}
+
+// Save key rotation
+func (d *DbTraced) SaveKeyRotation(rotation models.KeyRotation) error {
+	span := d.start("SaveKeyRotation")
+	err := d.db.SaveKeyRotation(rotation)
+	tracing.End(span, err)
+	return err
+}
+
+// Save round snapshot
+func (d *DbTraced) SaveRoundSnapshot(snapshot models.RoundSnapshot) error {
+	span := d.start("SaveRoundSnapshot")
+	err := d.db.SaveRoundSnapshot(snapshot)
+	tracing.End(span, err)
+	return err
+}
+
+// Save commitment submissions
+func (d *DbTraced) SaveCommitmentSubmissions(submissions []models.CommitmentSubmission) error {
+	span := d.start("SaveCommitmentSubmissions")
+	err := d.db.SaveCommitmentSubmissions(submissions)
+	tracing.End(span, err)
+	return err
+}
+
+// Save in-flight attestation
+func (d *DbTraced) SaveInFlightAttestation(inFlight models.InFlightAttestation) error {
+	span := d.start("SaveInFlightAttestation")
+	err := d.db.SaveInFlightAttestation(inFlight)
+	tracing.End(span, err)
+	return err
+}
+
+// Delete in-flight attestation
+func (d *DbTraced) DeleteInFlightAttestation() error {
+	span := d.start("DeleteInFlightAttestation")
+	err := d.db.DeleteInFlightAttestation()
+	tracing.End(span, err)
+	return err
+}
+
+// Get attestation count
+func (d *DbTraced) getAttestationCount(confirmed ...bool) (int64, error) {
+	span := d.start("getAttestationCount")
+	count, err := d.db.getAttestationCount(confirmed...)
+	tracing.End(span, err)
+	return count, err
+}
+
+// Get number of confirmed attestations
+func (d *DbTraced) GetConfirmedAttestationCount() (int64, error) {
+	span := d.start("GetConfirmedAttestationCount")
+	count, err := d.db.GetConfirmedAttestationCount()
+	tracing.End(span, err)
+	return count, err
+}
+
+// Get total fee of attestations confirmed since time
+func (d *DbTraced) GetAttestationFees(since int64) (int64, error) {
+	span := d.start("GetAttestationFees")
+	fees, err := d.db.GetAttestationFees(since)
+	tracing.End(span, err)
+	return fees, err
+}
+
+// Get merkle root of attestation with txid
+func (d *DbTraced) getAttestationMerkleRoot(txid chainhash.Hash) (string, error) {
+	span := d.start("getAttestationMerkleRoot")
+	root, err := d.db.getAttestationMerkleRoot(txid)
+	tracing.End(span, err)
+	return root, err
+}
+
+// Get merkle root of latest attestation
+func (d *DbTraced) GetLatestAttestationMerkleRoot(confirmed bool) (string, error) {
+	span := d.start("GetLatestAttestationMerkleRoot")
+	root, err := d.db.GetLatestAttestationMerkleRoot(confirmed)
+	tracing.End(span, err)
+	return root, err
+}
+
+// Get latest client commitments
+func (d *DbTraced) GetClientCommitments() ([]models.ClientCommitment, error) {
+	span := d.start("GetClientCommitments")
+	commitments, err := d.db.GetClientCommitments()
+	tracing.End(span, err)
+	return commitments, err
+}
+
+// Get merkle commitments of attestation with txid
+func (d *DbTraced) GetAttestationMerkleCommitments(txid chainhash.Hash) ([]models.CommitmentMerkleCommitment, error) {
+	span := d.start("GetAttestationMerkleCommitments")
+	commitments, err := d.db.GetAttestationMerkleCommitments(txid)
+	tracing.End(span, err)
+	return commitments, err
+}
+
+// Get service state
+func (d *DbTraced) GetServiceState(name string) (models.ServiceState, error) {
+	span := d.start("GetServiceState")
+	state, err := d.db.GetServiceState(name)
+	tracing.End(span, err)
+	return state, err
+}
+
+// Get key rotations
+func (d *DbTraced) GetKeyRotations() ([]models.KeyRotation, error) {
+	span := d.start("GetKeyRotations")
+	rotations, err := d.db.GetKeyRotations()
+	tracing.End(span, err)
+	return rotations, err
+}
+
+// Get pending commitment submissions
+func (d *DbTraced) GetPendingCommitmentSubmissions() ([]models.CommitmentSubmission, error) {
+	span := d.start("GetPendingCommitmentSubmissions")
+	submissions, err := d.db.GetPendingCommitmentSubmissions()
+	tracing.End(span, err)
+	return submissions, err
+}
+
+// Get in-flight attestation
+func (d *DbTraced) GetInFlightAttestation() (models.InFlightAttestation, error) {
+	span := d.start("GetInFlightAttestation")
+	inFlight, err := d.db.GetInFlightAttestation()
+	tracing.End(span, err)
+	return inFlight, err
+}
+
+// Get confirmed attestations
+func (d *DbTraced) GetAttestations() ([]models.AttestationBSON, error) {
+	span := d.start("GetAttestations")
+	attestations, err := d.db.GetAttestations()
+	tracing.End(span, err)
+	return attestations, err
+}
+
+// Get attestation document by txid
+func (d *DbTraced) GetAttestation(txid chainhash.Hash) (models.AttestationBSON, error) {
+	span := d.start("GetAttestation")
+	attestation, err := d.db.GetAttestation(txid)
+	tracing.End(span, err)
+	return attestation, err
+}
+
+// Get client details
+func (d *DbTraced) GetClientDetails() ([]models.ClientDetails, error) {
+	span := d.start("GetClientDetails")
+	details, err := d.db.GetClientDetails()
+	tracing.End(span, err)
+	return details, err
+}
+
+// Get merkle proofs of attestation with txid
+func (d *DbTraced) GetAttestationMerkleProofs(txid chainhash.Hash) ([]models.CommitmentMerkleProof, error) {
+	span := d.start("GetAttestationMerkleProofs")
+	proofs, err := d.db.GetAttestationMerkleProofs(txid)
+	tracing.End(span, err)
+	return proofs, err
+}
+
+// Save client details
+func (d *DbTraced) SaveClientDetails(details models.ClientDetails) error {
+	span := d.start("SaveClientDetails")
+	err := d.db.SaveClientDetails(details)
+	tracing.End(span, err)
+	return err
+}
+
+// Save client commitment
+func (d *DbTraced) SaveClientCommitment(commitment models.ClientCommitment) error {
+	span := d.start("SaveClientCommitment")
+	err := d.db.SaveClientCommitment(commitment)
+	tracing.End(span, err)
+	return err
+}
+
+// Save client commitments of several client positions
+func (d *DbTraced) SaveClientCommitments(commitments []models.ClientCommitment) error {
+	span := d.start("SaveClientCommitments")
+	err := d.db.SaveClientCommitments(commitments)
+	tracing.End(span, err)
+	return err
+}
+
+// Save commitment exclusion
+func (d *DbTraced) SaveCommitmentExclusion(exclusion models.CommitmentExclusion) error {
+	span := d.start("SaveCommitmentExclusion")
+	err := d.db.SaveCommitmentExclusion(exclusion)
+	tracing.End(span, err)
+	return err
+}
+
+// Return commitment exclusions of client position
+func (d *DbTraced) GetCommitmentExclusions(position int32) ([]models.CommitmentExclusion, error) {
+	span := d.start("GetCommitmentExclusions")
+	exclusions, err := d.db.GetCommitmentExclusions(position)
+	tracing.End(span, err)
+	return exclusions, err
+}
+
+// Get commitment history page of client position
+func (d *DbTraced) GetCommitmentHistory(position int32, offset int64, limit int64) (models.CommitmentHistory, error) {
+	span := d.start("GetCommitmentHistory")
+	history, err := d.db.GetCommitmentHistory(position, offset, limit)
+	tracing.End(span, err)
+	return history, err
+}
+
+// Return round snapshot of round
+func (d *DbTraced) GetRoundSnapshot(round int64) (models.RoundSnapshot, error) {
+	span := d.start("GetRoundSnapshot")
+	snapshot, err := d.db.GetRoundSnapshot(round)
+	tracing.End(span, err)
+	return snapshot, err
+}
+
+// Return page of round snapshots
+func (d *DbTraced) GetRoundSnapshots(merkleRoot string, offset int64, limit int64) (models.RoundSnapshots, error) {
+	span := d.start("GetRoundSnapshots")
+	snapshots, err := d.db.GetRoundSnapshots(merkleRoot, offset, limit)
+	tracing.End(span, err)
+	return snapshots, err
+}
+
+// Return commitment submission with id
+func (d *DbTraced) GetCommitmentSubmission(id string) (models.CommitmentSubmission, error) {
+	span := d.start("GetCommitmentSubmission")
+	submission, err := d.db.GetCommitmentSubmission(id)
+	tracing.End(span, err)
+	return submission, err
+}
+
+// Return latest commitment submission of each client position
+func (d *DbTraced) GetLatestCommitmentSubmissions(positions []int32) ([]models.CommitmentSubmission, error) {
+	span := d.start("GetLatestCommitmentSubmissions")
+	submissions, err := d.db.GetLatestCommitmentSubmissions(positions)
+	tracing.End(span, err)
+	return submissions, err
+}
+
+// Save slot group
+func (d *DbTraced) SaveSlotGroup(group models.SlotGroup) error {
+	span := d.start("SaveSlotGroup")
+	err := d.db.SaveSlotGroup(group)
+	tracing.End(span, err)
+	return err
+}
+
+// Get slot group of client position and commitment
+func (d *DbTraced) GetSlotGroup(position int32, commitment chainhash.Hash) (models.SlotGroup, error) {
+	span := d.start("GetSlotGroup")
+	group, err := d.db.GetSlotGroup(position, commitment)
+	tracing.End(span, err)
+	return group, err
+}
+
+// Get commitment merkle proof of client position and commitment
+func (d *DbTraced) GetCommitmentMerkleProof(position int32, commitment chainhash.Hash) (models.CommitmentMerkleProof, error) {
+	span := d.start("GetCommitmentMerkleProof")
+	proof, err := d.db.GetCommitmentMerkleProof(position, commitment)
+	tracing.End(span, err)
+	return proof, err
+}
+
+// Get earliest merkle proof of the commitment in any client position
+func (d *DbTraced) GetMerkleProofByCommitment(commitment chainhash.Hash) (models.CommitmentMerkleProof, error) {
+	span := d.start("GetMerkleProofByCommitment")
+	proof, err := d.db.GetMerkleProofByCommitment(commitment)
+	tracing.End(span, err)
+	return proof, err
+}
+
+// Get merkle proof for client position in the latest confirmed attestation
+func (d *DbTraced) GetLatestCommitmentMerkleProof(position int32) (models.CommitmentMerkleProof, error) {
+	span := d.start("GetLatestCommitmentMerkleProof")
+	proof, err := d.db.GetLatestCommitmentMerkleProof(position)
+	tracing.End(span, err)
+	return proof, err
+}
+
+// Get attestation info of the attestation committing to the merkle root
+func (d *DbTraced) GetAttestationInfoByMerkleRoot(merkleRoot chainhash.Hash) (models.AttestationInfo, error) {
+	span := d.start("GetAttestationInfoByMerkleRoot")
+	info, err := d.db.GetAttestationInfoByMerkleRoot(merkleRoot)
+	tracing.End(span, err)
+	return info, err
+}
+
+// Save staychain tx
+func (d *DbTraced) SaveStaychainTx(tx models.StaychainTx) error {
+	span := d.start("SaveStaychainTx")
+	err := d.db.SaveStaychainTx(tx)
+	tracing.End(span, err)
+	return err
+}
+
+// Return staychain tx with txid
+func (d *DbTraced) GetStaychainTx(txid string) (models.StaychainTx, error) {
+	span := d.start("GetStaychainTx")
+	tx, err := d.db.GetStaychainTx(txid)
+	tracing.End(span, err)
+	return tx, err
+}
+
+// Return latest staychain tx indexed
+func (d *DbTraced) GetLatestStaychainTx() (models.StaychainTx, error) {
+	span := d.start("GetLatestStaychainTx")
+	tx, err := d.db.GetLatestStaychainTx()
+	tracing.End(span, err)
+	return tx, err
+}
+
+// Return page of staychain txs
+func (d *DbTraced) GetStaychainTxs(offset int64, limit int64) (models.StaychainTxs, error) {
+	span := d.start("GetStaychainTxs")
+	txs, err := d.db.GetStaychainTxs(offset, limit)
+	tracing.End(span, err)
+	return txs, err
+}
+
+// Return page of confirmed attestations
+func (d *DbTraced) GetAttestationsPage(minHeight int64, maxHeight int64, offset int64, limit int64) (
+	[]models.AttestationBSON, error) {
+	span := d.start("GetAttestationsPage")
+	attestations, err := d.db.GetAttestationsPage(minHeight, maxHeight, offset, limit)
+	tracing.End(span, err)
+	return attestations, err
+}
+
+// Save signer status
+func (d *DbTraced) SaveSignerStatus(status models.SignerStatus) error {
+	span := d.start("SaveSignerStatus")
+	err := d.db.SaveSignerStatus(status)
+	tracing.End(span, err)
+	return err
+}
+
+// Return signer statuses
+func (d *DbTraced) GetSignerStatuses() ([]models.SignerStatus, error) {
+	span := d.start("GetSignerStatuses")
+	statuses, err := d.db.GetSignerStatuses()
+	tracing.End(span, err)
+	return statuses, err
+}
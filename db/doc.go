@@ -3,5 +3,19 @@ Package attestation implements the MainStay Db
 
 Implemented using a generic Db interface and two implementations;
 DbFake for testing and DbMongo for connectivity to a MongoDb instance
+
+Changes to the MongoDb collections, such as new indexes, renamed fields or new
+collections, are made by versioned migrations. Pending migrations are applied
+in order on startup and recorded in the Migration collection.
+
+Each DbMongo operation is bounded by a timeout, so that operations fail rather
+than block while the database is unreachable, and the client reconnects in the
+background. DbRetry wraps the Db interface to retry operations failing with
+transient errors with exponential backoff.
+
+The connection string is the configured uri or is built from the connectivity
+details of the db config, with any configured connection string options.
+Connections are made over tls with the configured CA and client certificate,
+which can also authenticate the client by X.509.
 */
 package db
@@ -0,0 +1,340 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/db"
+	"mainstay/log"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// proof delivery consts
+const (
+	DefaultDeliveryRetries = 3                // attempts after the first failed delivery
+	DeliveryRetryDelay     = 10 * time.Second // delay multiplied by attempt between retries
+	DeliveryTimeout        = 30 * time.Second // timeout of connecting and each put request
+	DeliveryQueueSize      = 100              // confirmed attestations queued before dropping
+
+	SchemeS3   = "s3"
+	SchemeSftp = "sftp"
+
+	ErrorDeliveryUrlInvalid     = "Invalid proof delivery url"
+	ErrorDeliveryUrlScheme      = "Unsupported proof delivery url scheme - expected s3 or sftp"
+	ErrorDeliveryS3Disabled     = "S3 proof delivery not configured"
+	ErrorDeliverySftpDisabled   = "Sftp proof delivery not configured"
+	ErrorDeliveryS3Keys         = "S3 access and secret keys both required"
+	ErrorDeliverySftpFiles      = "Sftp key and known hosts files both required"
+	ErrorDeliveryS3Endpoint     = "Invalid s3 endpoint url"
+	ErrorDeliverySftpKey        = "Could not read sftp private key"
+	ErrorDeliverySftpKnownHosts = "Could not read sftp known hosts"
+
+	WarningInvalidDeliveryRetriesArg = "Invalid proof delivery retries config value"
+	WarningDeliveryQueueFull         = "Proof delivery queue full - dropping confirmed attestation"
+	WarningDeliveryFailed            = "Proof delivery failed"
+)
+
+// Target interface
+// Destination that proof bundles are written to by name
+type Target interface {
+	Put(name string, data []byte) error
+}
+
+// Check that the delivery config sets s3 keys and sftp files together
+func ValidateDeliveryConfig(config confpkg.DeliveryConfig) error {
+	if (config.S3AccessKey == "") != (config.S3SecretKey == "") {
+		return errors.New(ErrorDeliveryS3Keys)
+	}
+	if (config.SftpKeyFile == "") != (config.SftpKnownHostsFile == "") {
+		return errors.New(ErrorDeliverySftpFiles)
+	}
+	if config.S3Endpoint != "" {
+		endpointUrl, urlErr := url.Parse(config.S3Endpoint)
+		if urlErr != nil || endpointUrl.Host == "" ||
+			(endpointUrl.Scheme != "http" && endpointUrl.Scheme != "https") {
+			return errors.New(fmt.Sprintf("%s: %s", ErrorDeliveryS3Endpoint, config.S3Endpoint))
+		}
+	}
+	return nil
+}
+
+// Return whether proof delivery to any target type is configured
+func Enabled(config confpkg.DeliveryConfig) bool {
+	return config.S3AccessKey != "" || config.SftpKeyFile != ""
+}
+
+// ProofDelivery struct
+// Pushes the proof bundles of each confirmed attestation to the delivery
+// targets registered by clients, so that clients do not have to poll for
+// proofs. Targets are s3 bucket prefixes, s3://bucket/prefix, or sftp
+// server directories, sftp://[user@]host[:port]/path
+type ProofDelivery struct {
+	ctx         context.Context
+	wg          *sync.WaitGroup
+	dbInterface db.Db
+	config      confpkg.DeliveryConfig
+	proofOps    string
+	retries     int
+	retryDelay  time.Duration
+	sshSigner   ssh.Signer
+	hostKeys    ssh.HostKeyCallback
+	httpClient  *http.Client
+	queue       chan models.AttestationEvent
+
+	// target of a delivery url, replaceable for testing
+	newTarget func(deliveryUrl string) (Target, error)
+}
+
+// Return new ProofDelivery instance from delivery config, delivering
+// proof bundles with the ops encoding of the request api
+func NewProofDelivery(ctx context.Context, wg *sync.WaitGroup, dbInterface db.Db,
+	config confpkg.DeliveryConfig, proofOps string) (*ProofDelivery, error) {
+
+	if validateErr := ValidateDeliveryConfig(config); validateErr != nil {
+		return nil, validateErr
+	}
+	retries := DefaultDeliveryRetries
+	if config.Retries >= 0 {
+		retries = config.Retries
+	} else if config.Retries != -1 {
+		log.Warnf("%s (%d)\n", WarningInvalidDeliveryRetriesArg, config.Retries)
+	}
+	if config.S3Region == "" {
+		config.S3Region = DefaultS3Region
+	}
+	if config.S3Endpoint == "" {
+		config.S3Endpoint = s3Endpoint(config.S3Region)
+	}
+	if proofOps != models.ProofOpsPosition {
+		proofOps = models.ProofOpsAppend
+	}
+
+	d := &ProofDelivery{
+		ctx:         ctx,
+		wg:          wg,
+		dbInterface: dbInterface,
+		config:      config,
+		proofOps:    proofOps,
+		retries:     retries,
+		retryDelay:  DeliveryRetryDelay,
+		httpClient:  &http.Client{Timeout: DeliveryTimeout},
+		queue:       make(chan models.AttestationEvent, DeliveryQueueSize),
+	}
+	d.newTarget = d.target
+
+	if config.SftpKeyFile != "" {
+		keyBytes, keyErr := ioutil.ReadFile(config.SftpKeyFile)
+		if keyErr != nil {
+			return nil, errors.New(fmt.Sprintf("%s %v", ErrorDeliverySftpKey, keyErr))
+		}
+		signer, signerErr := ssh.ParsePrivateKey(keyBytes)
+		if signerErr != nil {
+			return nil, errors.New(fmt.Sprintf("%s %v", ErrorDeliverySftpKey, signerErr))
+		}
+		hostKeys, hostKeysErr := knownhosts.New(config.SftpKnownHostsFile)
+		if hostKeysErr != nil {
+			return nil, errors.New(fmt.Sprintf("%s %v", ErrorDeliverySftpKnownHosts, hostKeysErr))
+		}
+		d.sshSigner = signer
+		d.hostKeys = hostKeys
+	}
+	return d, nil
+}
+
+// Check that the delivery url is a valid target of a configured target type
+func (d *ProofDelivery) ValidateTarget(deliveryUrl string) error {
+	_, parseErr := d.parseTarget(deliveryUrl)
+	return parseErr
+}
+
+// Return parsed delivery url of a configured target type
+func (d *ProofDelivery) parseTarget(deliveryUrl string) (*url.URL, error) {
+	targetUrl, urlErr := url.Parse(deliveryUrl)
+	if urlErr != nil || targetUrl.Host == "" || targetUrl.RawQuery != "" || targetUrl.Fragment != "" {
+		return nil, errors.New(fmt.Sprintf("%s: %s", ErrorDeliveryUrlInvalid, deliveryUrl))
+	}
+	switch targetUrl.Scheme {
+	case SchemeS3:
+		if d.config.S3AccessKey == "" {
+			return nil, errors.New(ErrorDeliveryS3Disabled)
+		} else if targetUrl.User != nil || targetUrl.Port() != "" {
+			return nil, errors.New(fmt.Sprintf("%s: %s", ErrorDeliveryUrlInvalid, deliveryUrl))
+		}
+	case SchemeSftp:
+		if d.sshSigner == nil {
+			return nil, errors.New(ErrorDeliverySftpDisabled)
+		} else if _, hasPassword := targetUrl.User.Password(); hasPassword || targetUrl.Path == "" {
+			return nil, errors.New(fmt.Sprintf("%s: %s", ErrorDeliveryUrlInvalid, deliveryUrl))
+		}
+	default:
+		return nil, errors.New(fmt.Sprintf("%s: %s", ErrorDeliveryUrlScheme, targetUrl.Scheme))
+	}
+	return targetUrl, nil
+}
+
+// Return target of delivery url
+func (d *ProofDelivery) target(deliveryUrl string) (Target, error) {
+	targetUrl, parseErr := d.parseTarget(deliveryUrl)
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	if targetUrl.Scheme == SchemeS3 {
+		return &S3Target{
+			endpoint:  strings.TrimSuffix(d.config.S3Endpoint, "/"),
+			region:    d.config.S3Region,
+			accessKey: d.config.S3AccessKey,
+			secretKey: d.config.S3SecretKey,
+			bucket:    targetUrl.Host,
+			prefix:    strings.Trim(targetUrl.Path, "/"),
+			client:    d.httpClient,
+		}, nil
+	}
+	user := d.config.SftpUser
+	if targetUrl.User != nil {
+		user = targetUrl.User.Username()
+	}
+	return &SftpTarget{
+		addr: sftpAddr(targetUrl),
+		dir:  targetUrl.Path,
+		config: &ssh.ClientConfig{
+			User:            user,
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(d.sshSigner)},
+			HostKeyCallback: d.hostKeys,
+			Timeout:         DeliveryTimeout,
+		},
+	}, nil
+}
+
+// Queue confirmed attestation for delivering its proofs
+func (d *ProofDelivery) Notify(event models.AttestationEvent) {
+	if event.Event != models.AttestationEventConfirmed {
+		return
+	}
+	select {
+	case d.queue <- event:
+	default:
+		log.WithFields(log.Fields{log.FieldTxid: event.Txid}).Warnln(WarningDeliveryQueueFull)
+	}
+}
+
+// Run delivery of queued confirmed attestations until the context is cancelled
+func (d *ProofDelivery) Run() {
+	defer d.wg.Done()
+	log.Infof("*Delivery* Delivering confirmed proofs with %d retries\n", d.retries)
+	for {
+		select {
+		case <-d.ctx.Done():
+			log.Infoln("Shutting down proof delivery...")
+			return
+		case event := <-d.queue:
+			if deliverErr := d.deliver(event); deliverErr != nil {
+				log.WithFields(log.Fields{log.FieldTxid: event.Txid, log.FieldError: deliverErr}).Warnln(
+					WarningDeliveryFailed)
+			}
+		}
+	}
+}
+
+// Deliver the proof bundles of the confirmed attestation to the targets of
+// the clients with a commitment in the attestation. Slot groups are
+// delivered the proof of the group root
+func (d *ProofDelivery) deliver(event models.AttestationEvent) error {
+	details, detailsErr := d.dbInterface.GetClientDetails()
+	if detailsErr != nil {
+		return detailsErr
+	}
+	var clients []models.ClientDetails
+	for _, client := range details {
+		if client.DeliveryUrl != "" {
+			clients = append(clients, client)
+		}
+	}
+	if len(clients) == 0 {
+		return nil
+	}
+
+	txid, txidErr := chainhash.NewHashFromStr(event.Txid)
+	if txidErr != nil {
+		return txidErr
+	}
+	proofs, proofsErr := d.dbInterface.GetAttestationMerkleProofs(*txid)
+	if proofsErr != nil {
+		return proofsErr
+	}
+	proofsByPosition := make(map[int32]models.CommitmentMerkleProof)
+	for _, proof := range proofs {
+		proofsByPosition[proof.ClientPosition] = proof
+	}
+	root, rootErr := chainhash.NewHashFromStr(event.Commitment)
+	if rootErr != nil {
+		return rootErr
+	}
+	info, infoErr := d.dbInterface.GetAttestationInfoByMerkleRoot(*root)
+	if infoErr != nil {
+		return infoErr
+	}
+
+	for _, client := range clients {
+		proof, ok := proofsByPosition[client.ClientPosition]
+		if !ok {
+			continue
+		}
+		bundle := models.NewProofBundle(proof, info)
+		if encodeErr := bundle.EncodeOps(d.proofOps); encodeErr != nil {
+			return encodeErr
+		}
+		data, marshalErr := json.Marshal(bundle)
+		if marshalErr != nil {
+			return marshalErr
+		}
+		name := fmt.Sprintf("%d_%s.json", bundle.Slot, bundle.Commitment)
+		if putErr := d.putWithRetries(client.DeliveryUrl, name, data); putErr != nil {
+			log.WithFields(log.Fields{log.FieldTxid: event.Txid, log.FieldClientPosition: client.ClientPosition,
+				log.FieldError: putErr}).Warnln(WarningDeliveryFailed)
+			continue
+		}
+		log.WithFields(log.Fields{log.FieldTxid: event.Txid, log.FieldClientPosition: client.ClientPosition}).
+			Infof("*Delivery* Delivered proof %s\n", name)
+	}
+	return nil
+}
+
+// Put proof bundle to delivery url target, retrying with increasing delay
+// on failure until retries are exhausted or the context is cancelled
+func (d *ProofDelivery) putWithRetries(deliveryUrl string, name string, data []byte) error {
+	target, targetErr := d.newTarget(deliveryUrl)
+	if targetErr != nil {
+		return targetErr
+	}
+	var putErr error
+	for attempt := 0; attempt <= d.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-d.ctx.Done():
+				return putErr
+			case <-time.After(time.Duration(attempt) * d.retryDelay):
+			}
+		}
+		if putErr = target.Put(name, data); putErr == nil {
+			return nil
+		}
+	}
+	return putErr
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	confpkg "mainstay/config"
+	"mainstay/db"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testCommitment = "2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+	testTxid       = "4a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+)
+
+// targetFake struct
+// Records puts, failing the first number of puts set
+type targetFake struct {
+	fails int
+	puts  map[string][]byte
+}
+
+func (t *targetFake) Put(name string, data []byte) error {
+	if t.fails > 0 {
+		t.fails--
+		return errors.New("put failed")
+	}
+	t.puts[name] = data
+	return nil
+}
+
+// Test delivery config validation
+func TestValidateDeliveryConfig(t *testing.T) {
+	assert.Equal(t, nil, ValidateDeliveryConfig(confpkg.DeliveryConfig{}))
+	assert.Equal(t, false, Enabled(confpkg.DeliveryConfig{}))
+	assert.Equal(t, nil, ValidateDeliveryConfig(confpkg.DeliveryConfig{S3AccessKey: "a", S3SecretKey: "s",
+		S3Endpoint: "http://localhost:9000"}))
+	assert.Equal(t, true, Enabled(confpkg.DeliveryConfig{S3AccessKey: "a", S3SecretKey: "s"}))
+
+	assert.Equal(t, errors.New(ErrorDeliveryS3Keys),
+		ValidateDeliveryConfig(confpkg.DeliveryConfig{S3AccessKey: "a"}))
+	assert.Equal(t, errors.New(ErrorDeliverySftpFiles),
+		ValidateDeliveryConfig(confpkg.DeliveryConfig{SftpKnownHostsFile: "known_hosts"}))
+	assert.Equal(t, errors.New(ErrorDeliveryS3Endpoint+": localhost:9000"),
+		ValidateDeliveryConfig(confpkg.DeliveryConfig{S3AccessKey: "a", S3SecretKey: "s", S3Endpoint: "localhost:9000"}))
+
+	_, keyErr := NewProofDelivery(context.Background(), &sync.WaitGroup{}, db.NewDbFake(),
+		confpkg.DeliveryConfig{SftpKeyFile: "/nonexistent/key", SftpKnownHostsFile: "/nonexistent/known_hosts"}, "")
+	assert.Contains(t, keyErr.Error(), ErrorDeliverySftpKey)
+}
+
+// Test delivery url validation
+func TestProofDeliveryValidateTarget(t *testing.T) {
+	d, _ := NewProofDelivery(context.Background(), &sync.WaitGroup{}, db.NewDbFake(),
+		confpkg.DeliveryConfig{S3AccessKey: "a", S3SecretKey: "s", Retries: -1}, "")
+	assert.Equal(t, DefaultDeliveryRetries, d.retries)
+	assert.Equal(t, s3Endpoint(DefaultS3Region), d.config.S3Endpoint)
+
+	assert.Equal(t, nil, d.ValidateTarget("s3://bucket"))
+	assert.Equal(t, nil, d.ValidateTarget("s3://bucket/proofs/client"))
+	assert.Equal(t, errors.New(ErrorDeliveryUrlInvalid+": s3:///proofs"), d.ValidateTarget("s3:///proofs"))
+	assert.Equal(t, errors.New(ErrorDeliveryUrlInvalid+": s3://bucket/proofs?x=1"), d.ValidateTarget("s3://bucket/proofs?x=1"))
+	assert.Equal(t, errors.New(ErrorDeliveryUrlInvalid+": s3://user@bucket"), d.ValidateTarget("s3://user@bucket"))
+	assert.Equal(t, errors.New(ErrorDeliveryUrlScheme+": https"), d.ValidateTarget("https://bucket/proofs"))
+	assert.Equal(t, errors.New(ErrorDeliverySftpDisabled), d.ValidateTarget("sftp://host/proofs"))
+
+	target, targetErr := d.target("s3://bucket/proofs/client/")
+	assert.Equal(t, nil, targetErr)
+	assert.Equal(t, "bucket", target.(*S3Target).bucket)
+	assert.Equal(t, "proofs/client", target.(*S3Target).prefix)
+	assert.Equal(t, "https://s3.us-east-1.amazonaws.com", target.(*S3Target).endpoint)
+}
+
+// Test delivery of confirmed attestation proofs to client targets
+func TestProofDeliveryDeliver(t *testing.T) {
+	dbFake := db.NewDbFake()
+	dbFake.SaveClientDetails(models.ClientDetails{ClientPosition: 0, ClientName: "client0",
+		DeliveryUrl: "s3://bucket/client0"})
+	dbFake.SaveClientDetails(models.ClientDetails{ClientPosition: 1, ClientName: "client1"})
+	dbFake.SaveClientDetails(models.ClientDetails{ClientPosition: 2, ClientName: "client2",
+		DeliveryUrl: "s3://bucket/client2"})
+
+	d, _ := NewProofDelivery(context.Background(), &sync.WaitGroup{}, dbFake,
+		confpkg.DeliveryConfig{S3AccessKey: "a", S3SecretKey: "s", Retries: 2}, models.ProofOpsPosition)
+	d.retryDelay = 0
+	targets := make(map[string]*targetFake)
+	d.newTarget = func(deliveryUrl string) (Target, error) {
+		if validateErr := d.ValidateTarget(deliveryUrl); validateErr != nil {
+			return nil, validateErr
+		}
+		if _, ok := targets[deliveryUrl]; !ok {
+			targets[deliveryUrl] = &targetFake{fails: 2, puts: make(map[string][]byte)}
+		}
+		return targets[deliveryUrl], nil
+	}
+
+	commitment0, _ := chainhash.NewHashFromStr(testCommitment)
+	commitment1, _ := chainhash.NewHashFromStr("3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*commitment0, *commitment1})
+	txid, _ := chainhash.NewHashFromStr(testTxid)
+	attestation := models.NewAttestation(*txid, commitment)
+	attestation.Confirmed = true
+	dbFake.SaveAttestation(*attestation)
+	dbFake.SaveAttestationInfo(models.AttestationInfo{Txid: txid.String(), Blockhash: testCommitment, Time: 1542121293})
+	dbFake.SaveMerkleProofs(commitment.GetMerkleProofs())
+
+	// unconfirmed events not queued
+	event := models.AttestationEvent{Event: models.AttestationEventConfirmed, Txid: txid.String(),
+		Commitment: commitment.GetCommitmentHash().String()}
+	d.Notify(models.AttestationEvent{Event: models.AttestationEventBroadcast, Txid: txid.String()})
+	d.Notify(event)
+	assert.Equal(t, 1, len(d.queue))
+
+	// delivered after failed puts retried, with no delivery to clients
+	// without a target or a commitment in the attestation
+	assert.Equal(t, nil, d.deliver(<-d.queue))
+	assert.Equal(t, 1, len(targets))
+	name := "0_" + testCommitment + ".json"
+	data, ok := targets["s3://bucket/client0"].puts[name]
+	assert.Equal(t, true, ok)
+
+	var bundle models.ProofBundle
+	assert.Equal(t, nil, json.Unmarshal(data, &bundle))
+	assert.Equal(t, int32(0), bundle.Slot)
+	assert.Equal(t, testCommitment, bundle.Commitment)
+	assert.Equal(t, &models.ProofBundleBlock{Hash: testCommitment, Time: 1542121293}, bundle.Block)
+	assert.Equal(t, models.ProofOpsPosition, bundle.Params.Ops)
+	assert.Equal(t, nil, models.VerifyProofBundle(bundle))
+
+	// delivery failing once retries exhausted
+	d.retries = 0
+	targets["s3://bucket/client0"].fails = 1
+	assert.Equal(t, errors.New("put failed"), d.putWithRetries("s3://bucket/client0", name, data))
+}
@@ -0,0 +1,16 @@
+/*
+Package delivery provides pushing of confirmed attestation proofs to delivery
+targets registered by clients.
+
+Once an attestation is confirmed, the proof bundle of each client commitment
+in the attestation is written, as json named by slot and commitment, to the
+target registered for the client slot. Targets are prefixes of s3 buckets,
+s3://bucket/prefix, put to with AWS signature version 4 signed requests on
+the configured s3 compatible endpoint, or directories of sftp servers,
+sftp://[user@]host[:port]/path, written to with the configured private key
+and with host keys checked against the configured known hosts file.
+
+Failed deliveries are retried with increasing delay and logged once retries
+are exhausted, with no effect on attestation.
+*/
+package delivery
@@ -0,0 +1,160 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package delivery
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3 consts
+const (
+	DefaultS3Region = "us-east-1"
+
+	s3Algorithm  = "AWS4-HMAC-SHA256"
+	s3Service    = "s3"
+	s3DateFormat = "20060102T150405Z"
+
+	HeaderS3Date          = "X-Amz-Date"
+	HeaderS3ContentSha256 = "X-Amz-Content-Sha256"
+
+	ErrorS3Response = "S3 put object response error"
+)
+
+// S3Target struct
+// Puts objects to a prefix of an S3 bucket with AWS signature version 4
+// signed requests. Buckets are addressed by path on the endpoint, so that
+// S3 compatible stores such as MinIO can be used with a custom endpoint
+type S3Target struct {
+	endpoint  string
+	region    string
+	accessKey string
+	secretKey string
+	bucket    string
+	prefix    string
+	client    *http.Client
+}
+
+// Return S3 endpoint of region
+func s3Endpoint(region string) string {
+	return fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+}
+
+// Put object with the name under the target prefix
+func (t *S3Target) Put(name string, data []byte) error {
+	key := name
+	if t.prefix != "" {
+		key = t.prefix + "/" + name
+	}
+	req, reqErr := http.NewRequest(http.MethodPut,
+		fmt.Sprintf("%s/%s/%s", t.endpoint, t.bucket, s3UriEncode(key, false)), bytes.NewReader(data))
+	if reqErr != nil {
+		return reqErr
+	}
+	req.Header.Set("Content-Type", "application/json")
+	signS3Request(req, data, t.accessKey, t.secretKey, t.region, time.Now())
+
+	resp, respErr := t.client.Do(req)
+	if respErr != nil {
+		return respErr
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.New(fmt.Sprintf("%s: %s", ErrorS3Response, resp.Status))
+	}
+	return nil
+}
+
+// Sign request with AWS signature version 4, signing the host and all
+// headers set on the request, and set the authorization header
+func signS3Request(req *http.Request, payload []byte, accessKey string, secretKey string,
+	region string, now time.Time) {
+
+	payloadHash := sha256.Sum256(payload)
+	amzDate := now.UTC().Format(s3DateFormat)
+	req.Header.Set(HeaderS3Date, amzDate)
+	req.Header.Set(HeaderS3ContentSha256, hex.EncodeToString(payloadHash[:]))
+
+	// canonical headers in order of lower case name
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3UriEncode(req.URL.Path, false),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", amzDate[:8], region, s3Service)
+	stringToSign := strings.Join([]string{s3Algorithm, amzDate, scope, hex.EncodeToString(canonicalHash[:])}, "\n")
+
+	signingKey := hmacSha256([]byte("AWS4"+secretKey), []byte(amzDate[:8]))
+	signingKey = hmacSha256(signingKey, []byte(region))
+	signingKey = hmacSha256(signingKey, []byte(s3Service))
+	signingKey = hmacSha256(signingKey, []byte("aws4_request"))
+	signature := hex.EncodeToString(hmacSha256(signingKey, []byte(stringToSign)))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, accessKey, scope, signedHeaders, signature))
+}
+
+// Return hmac-sha256 of data with key
+func hmacSha256(key []byte, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// Return canonical query string of query values sorted by name
+func s3CanonicalQuery(query url.Values) string {
+	var params []string
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, s3UriEncode(name, true)+"="+s3UriEncode(value, true))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// Percent encode all characters except the unreserved characters,
+// and slashes unless encoding a query parameter
+func s3UriEncode(value string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for _, b := range []byte(value) {
+		if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') ||
+			b == '-' || b == '_' || b == '.' || b == '~' || (b == '/' && !encodeSlash) {
+			encoded.WriteByte(b)
+		} else {
+			encoded.WriteString(fmt.Sprintf("%%%02X", b))
+		}
+	}
+	return encoded.String()
+}
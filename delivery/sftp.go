@@ -0,0 +1,219 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package delivery
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"path"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sftp consts
+const (
+	DefaultSftpPort = "22"
+
+	// sftp protocol version 3 packet types and flags
+	sftpVersion    = 3
+	sftpFxpInit    = 1
+	sftpFxpVersion = 2
+	sftpFxpOpen    = 3
+	sftpFxpClose   = 4
+	sftpFxpWrite   = 6
+	sftpFxpStatus  = 101
+	sftpFxpHandle  = 102
+
+	sftpFlagWrite    = 0x02
+	sftpFlagCreate   = 0x08
+	sftpFlagTruncate = 0x10
+
+	sftpStatusOk      = 0
+	sftpWriteSize     = 32768   // max data written per write request
+	sftpMaxPacketSize = 1 << 18 // max size of response packets accepted
+
+	ErrorSftpConnect  = "Could not connect to sftp server"
+	ErrorSftpProtocol = "Sftp protocol error"
+	ErrorSftpStatus   = "Sftp request failed"
+)
+
+// SftpTarget struct
+// Writes files to a directory of an sftp server, connecting for each file
+// with public key authentication and host keys checked against known hosts
+type SftpTarget struct {
+	addr   string
+	dir    string
+	config *ssh.ClientConfig
+}
+
+// Write file with the name to the target directory, replacing any existing file
+func (t *SftpTarget) Put(name string, data []byte) error {
+	client, dialErr := ssh.Dial("tcp", t.addr, t.config)
+	if dialErr != nil {
+		return errors.New(fmt.Sprintf("%s %s: %v", ErrorSftpConnect, t.addr, dialErr))
+	}
+	defer client.Close()
+	session, sessionErr := client.NewSession()
+	if sessionErr != nil {
+		return errors.New(fmt.Sprintf("%s %s: %v", ErrorSftpConnect, t.addr, sessionErr))
+	}
+	defer session.Close()
+
+	w, stdinErr := session.StdinPipe()
+	if stdinErr != nil {
+		return stdinErr
+	}
+	r, stdoutErr := session.StdoutPipe()
+	if stdoutErr != nil {
+		return stdoutErr
+	}
+	if subsystemErr := session.RequestSubsystem("sftp"); subsystemErr != nil {
+		return errors.New(fmt.Sprintf("%s %s: %v", ErrorSftpConnect, t.addr, subsystemErr))
+	}
+	return newSftpClient(r, w).put(path.Join(t.dir, name), data)
+}
+
+// sftpClient struct
+// Minimal sftp client writing files over the sftp subsystem streams
+type sftpClient struct {
+	r  io.Reader
+	w  io.Writer
+	id uint32
+}
+
+// Return new sftp client for the subsystem streams
+func newSftpClient(r io.Reader, w io.Writer) *sftpClient {
+	return &sftpClient{r: r, w: w}
+}
+
+// Initialise session and write file, creating or truncating it
+func (c *sftpClient) put(filePath string, data []byte) error {
+	if sendErr := c.send(sftpFxpInit, sftpUint32(sftpVersion)); sendErr != nil {
+		return sendErr
+	}
+	packetType, _, recvErr := c.recv()
+	if recvErr != nil {
+		return recvErr
+	} else if packetType != sftpFxpVersion {
+		return errors.New(fmt.Sprintf("%s: unexpected packet %d", ErrorSftpProtocol, packetType))
+	}
+
+	// open file with empty attributes
+	open := append(sftpString([]byte(filePath)), sftpUint32(sftpFlagWrite|sftpFlagCreate|sftpFlagTruncate)...)
+	handle, openErr := c.request(sftpFxpOpen, append(open, sftpUint32(0)...))
+	if openErr != nil {
+		return openErr
+	}
+	for offset := 0; offset < len(data); offset += sftpWriteSize {
+		end := offset + sftpWriteSize
+		if end > len(data) {
+			end = len(data)
+		}
+		write := append(sftpString(handle), sftpUint64(uint64(offset))...)
+		if _, writeErr := c.request(sftpFxpWrite, append(write, sftpString(data[offset:end])...)); writeErr != nil {
+			c.request(sftpFxpClose, sftpString(handle))
+			return writeErr
+		}
+	}
+	_, closeErr := c.request(sftpFxpClose, sftpString(handle))
+	return closeErr
+}
+
+// Send request and wait for its response, returning the handle of handle
+// responses or an error for status responses other than ok
+func (c *sftpClient) request(packetType byte, payload []byte) ([]byte, error) {
+	c.id++
+	if sendErr := c.send(packetType, append(sftpUint32(c.id), payload...)); sendErr != nil {
+		return nil, sendErr
+	}
+	respType, resp, recvErr := c.recv()
+	if recvErr != nil {
+		return nil, recvErr
+	}
+	if len(resp) < 4 || binary.BigEndian.Uint32(resp) != c.id {
+		return nil, errors.New(fmt.Sprintf("%s: unexpected response id", ErrorSftpProtocol))
+	}
+	resp = resp[4:]
+	switch respType {
+	case sftpFxpHandle:
+		handle, _, handleErr := sftpReadString(resp)
+		return handle, handleErr
+	case sftpFxpStatus:
+		if len(resp) < 4 {
+			return nil, errors.New(fmt.Sprintf("%s: short status", ErrorSftpProtocol))
+		}
+		if code := binary.BigEndian.Uint32(resp); code != sftpStatusOk {
+			msg, _, _ := sftpReadString(resp[4:])
+			return nil, errors.New(fmt.Sprintf("%s: %d %s", ErrorSftpStatus, code, msg))
+		}
+		return nil, nil
+	}
+	return nil, errors.New(fmt.Sprintf("%s: unexpected packet %d", ErrorSftpProtocol, respType))
+}
+
+// Send packet of type with payload
+func (c *sftpClient) send(packetType byte, payload []byte) error {
+	packet := append(sftpUint32(uint32(len(payload)+1)), packetType)
+	_, err := c.w.Write(append(packet, payload...))
+	return err
+}
+
+// Receive packet returning its type and payload
+func (c *sftpClient) recv() (byte, []byte, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(c.r, length[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(length[:])
+	if size == 0 || size > sftpMaxPacketSize {
+		return 0, nil, errors.New(fmt.Sprintf("%s: invalid packet length %d", ErrorSftpProtocol, size))
+	}
+	packet := make([]byte, size)
+	if _, err := io.ReadFull(c.r, packet); err != nil {
+		return 0, nil, err
+	}
+	return packet[0], packet[1:], nil
+}
+
+// Encode uint32 in network byte order
+func sftpUint32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+// Encode uint64 in network byte order
+func sftpUint64(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+// Encode length prefixed string
+func sftpString(s []byte) []byte {
+	return append(sftpUint32(uint32(len(s))), s...)
+}
+
+// Decode length prefixed string returning the remaining bytes
+func sftpReadString(b []byte) ([]byte, []byte, error) {
+	if len(b) < 4 || uint32(len(b)-4) < binary.BigEndian.Uint32(b) {
+		return nil, nil, errors.New(fmt.Sprintf("%s: short string", ErrorSftpProtocol))
+	}
+	size := binary.BigEndian.Uint32(b)
+	return b[4 : 4+size], b[4+size:], nil
+}
+
+// Return host:port address of sftp url, with the default port if none is given
+func sftpAddr(sftpUrl *url.URL) string {
+	port := sftpUrl.Port()
+	if port == "" {
+		port = DefaultSftpPort
+	}
+	return net.JoinHostPort(sftpUrl.Hostname(), port)
+}
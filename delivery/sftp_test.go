@@ -0,0 +1,103 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package delivery
+
+import (
+	"encoding/binary"
+	"io"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sftpServerFake struct
+// Serves sftp requests over pipes, recording the written file and
+// failing writes with the status code set
+type sftpServerFake struct {
+	r         io.Reader
+	w         io.Writer
+	writeCode uint32
+	path      string
+	data      []byte
+	closed    bool
+}
+
+// Serve requests until the client streams are closed
+func (s *sftpServerFake) serve() {
+	client := newSftpClient(s.r, s.w)
+	for {
+		packetType, payload, recvErr := client.recv()
+		if recvErr != nil {
+			return
+		}
+		if packetType == sftpFxpInit {
+			client.send(sftpFxpVersion, sftpUint32(sftpVersion))
+			continue
+		}
+		id := payload[:4]
+		status := func(code uint32) {
+			client.send(sftpFxpStatus, append(append(id, sftpUint32(code)...), sftpString([]byte("failure"))...))
+		}
+		switch packetType {
+		case sftpFxpOpen:
+			filePath, _, _ := sftpReadString(payload[4:])
+			s.path = string(filePath)
+			client.send(sftpFxpHandle, append(id, sftpString([]byte("handle"))...))
+		case sftpFxpWrite:
+			_, rest, _ := sftpReadString(payload[4:])
+			offset := binary.BigEndian.Uint64(rest)
+			data, _, _ := sftpReadString(rest[8:])
+			if s.writeCode != sftpStatusOk {
+				status(s.writeCode)
+				continue
+			}
+			s.data = append(s.data[:offset], data...)
+			status(sftpStatusOk)
+		case sftpFxpClose:
+			s.closed = true
+			status(sftpStatusOk)
+		}
+	}
+}
+
+// Return sftp client connected to a new fake server
+func newSftpTestClient(writeCode uint32) (*sftpClient, *sftpServerFake, func()) {
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	server := &sftpServerFake{r: serverR, w: serverW, writeCode: writeCode}
+	go server.serve()
+	return newSftpClient(clientR, clientW), server, func() { clientW.Close(); serverW.Close() }
+}
+
+// Test writing files with the sftp client
+func TestSftpClientPut(t *testing.T) {
+	client, server, closeFn := newSftpTestClient(sftpStatusOk)
+	data := make([]byte, sftpWriteSize*2+10)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	assert.Equal(t, nil, client.put("/proofs/1_abc.json", data))
+	closeFn()
+	assert.Equal(t, "/proofs/1_abc.json", server.path)
+	assert.Equal(t, data, server.data)
+	assert.Equal(t, true, server.closed)
+
+	// failed write returned with the handle closed
+	client, server, closeFn = newSftpTestClient(4)
+	defer closeFn()
+	assert.Equal(t, ErrorSftpStatus+": 4 failure", client.put("/proofs/1_abc.json", data).Error())
+	assert.Equal(t, true, server.closed)
+}
+
+// Test sftp url addresses
+func TestSftpAddr(t *testing.T) {
+	sftpUrl, _ := url.Parse("sftp://user@host/proofs")
+	assert.Equal(t, "host:22", sftpAddr(sftpUrl))
+	sftpUrl, _ = url.Parse("sftp://host:2222/proofs")
+	assert.Equal(t, "host:2222", sftpAddr(sftpUrl))
+	sftpUrl, _ = url.Parse("sftp://[::1]/proofs")
+	assert.Equal(t, "[::1]:22", sftpAddr(sftpUrl))
+}
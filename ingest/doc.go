@@ -0,0 +1,23 @@
+/*
+Package ingest provides ingestion of client commitments from sources other
+than the request api
+
+Commitments are consumed from a kafka topic, with each message key mapped to
+the client slot of the same position or client name and each message payload
+mapped to the commitment of the slot, either as the sha256 digest of the
+payload or as a commitment hash hex string. Brokers are connected to over tls
+and authenticated with sasl PLAIN or SCRAM if configured. The consumer joins
+the consumer group, sharing the partitions of the topic with the other group
+members, and the offsets of the group are committed once the commitments
+have been saved.
+
+The latest block hashes of Ocean and Elements sidechains are also committed
+to the client slots of the chains, fetched from the rpc of each chain at a
+regular interval by a puller of sidechain commitment sources.
+
+Commitments are also pulled from commitment sources configured per client
+slot, polled from a client url, read from a watched file or returned by a
+json-rpc method of a client node, so that clients are attested without
+sending their commitments.
+*/
+package ingest
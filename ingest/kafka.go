@@ -0,0 +1,134 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package ingest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	confpkg "mainstay/config"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+// kafka consts
+const (
+	KafkaClientId = "mainstay"
+
+	SaslMechanismPlain       = "PLAIN"
+	SaslMechanismScramSha256 = "SCRAM-SHA-256"
+	SaslMechanismScramSha512 = "SCRAM-SHA-512"
+
+	ErrorKafkaSaslMechanism   = "Unsupported kafka sasl mechanism"
+	ErrorKafkaTlsCa           = "Invalid kafka tls ca file"
+	ErrorKafkaBrokerInvalid   = "Invalid kafka broker address"
+	ErrorKafkaTopicMissing    = "Kafka topic not set"
+	ErrorKafkaPayloadInvalid  = "Invalid kafka payload encoding"
+	ErrorKafkaSaslCredentials = "Kafka sasl user and password required"
+)
+
+// Check that the kafka config is complete and consistent
+func ValidateKafkaConfig(config confpkg.KafkaConfig) error {
+	for _, broker := range config.Brokers {
+		if _, _, hostErr := confpkg.SplitHostPort(broker); hostErr != nil || !strings.Contains(broker, ":") {
+			return errors.New(fmt.Sprintf("%s: %s", ErrorKafkaBrokerInvalid, broker))
+		}
+	}
+	if config.Topic == "" {
+		return errors.New(ErrorKafkaTopicMissing)
+	}
+	switch config.SaslMechanism {
+	case "":
+	case SaslMechanismPlain, SaslMechanismScramSha256, SaslMechanismScramSha512:
+		if config.SaslUser == "" || config.SaslPassword == "" {
+			return errors.New(ErrorKafkaSaslCredentials)
+		}
+	default:
+		return errors.New(fmt.Sprintf("%s: %s", ErrorKafkaSaslMechanism, config.SaslMechanism))
+	}
+	switch config.Payload {
+	case "", PayloadDigest, PayloadHash:
+	default:
+		return errors.New(fmt.Sprintf("%s: %s", ErrorKafkaPayloadInvalid, config.Payload))
+	}
+	return nil
+}
+
+// Return tls config from the kafka config or nil if tls is not enabled
+func kafkaTlsConfig(config confpkg.KafkaConfig) (*tls.Config, error) {
+	if !config.Tls {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.TlsCaFile != "" {
+		ca, caErr := ioutil.ReadFile(config.TlsCaFile)
+		if caErr != nil {
+			return nil, caErr
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, errors.New(fmt.Sprintf("%s: %s", ErrorKafkaTlsCa, config.TlsCaFile))
+		}
+	}
+	if config.TlsCertFile != "" || config.TlsKeyFile != "" {
+		cert, certErr := tls.LoadX509KeyPair(config.TlsCertFile, config.TlsKeyFile)
+		if certErr != nil {
+			return nil, certErr
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// Return sasl mechanism of the kafka config or nil if sasl is not enabled
+func kafkaSaslMechanism(config confpkg.KafkaConfig) (sasl.Mechanism, error) {
+	switch config.SaslMechanism {
+	case "":
+		return nil, nil
+	case SaslMechanismPlain:
+		return plain.Auth{User: config.SaslUser, Pass: config.SaslPassword}.AsMechanism(), nil
+	case SaslMechanismScramSha256:
+		return scram.Auth{User: config.SaslUser, Pass: config.SaslPassword}.AsSha256Mechanism(), nil
+	case SaslMechanismScramSha512:
+		return scram.Auth{User: config.SaslUser, Pass: config.SaslPassword}.AsSha512Mechanism(), nil
+	}
+	return nil, errors.New(fmt.Sprintf("%s: %s", ErrorKafkaSaslMechanism, config.SaslMechanism))
+}
+
+// Return kafka client options joining the consumer group of the kafka
+// config and consuming the topic, over tls and authenticated with sasl if
+// configured. Offsets are only committed once the commitments have been
+// saved and partitions are not reassigned while records are being saved
+func kafkaClientOpts(config confpkg.KafkaConfig, tlsConfig *tls.Config) ([]kgo.Opt, error) {
+	opts := []kgo.Opt{
+		kgo.ClientID(KafkaClientId),
+		kgo.SeedBrokers(config.Brokers...),
+		kgo.ConsumerGroup(config.Group),
+		kgo.ConsumeTopics(config.Topic),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+		kgo.DisableAutoCommit(),
+		kgo.BlockRebalanceOnPoll(),
+		kgo.DialTimeout(KafkaTimeout),
+		kgo.FetchMaxPartitionBytes(KafkaFetchMaxBytes),
+	}
+	if tlsConfig != nil {
+		opts = append(opts, kgo.DialTLSConfig(tlsConfig))
+	}
+	mechanism, mechanismErr := kafkaSaslMechanism(config)
+	if mechanismErr != nil {
+		return nil, mechanismErr
+	}
+	if mechanism != nil {
+		opts = append(opts, kgo.SASL(mechanism))
+	}
+	return opts, nil
+}
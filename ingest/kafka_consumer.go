@@ -0,0 +1,214 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package ingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/db"
+	"mainstay/log"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// kafka consumer consts
+const (
+	DefaultKafkaGroup   = "mainstay"
+	KafkaTimeout        = 10 * time.Second // timeout of connecting and requests
+	KafkaFetchWait      = 5 * time.Second  // max wait of fetching records
+	KafkaFetchMaxBytes  = 1 << 20          // max bytes fetched per partition
+	KafkaReconnectDelay = 10 * time.Second // delay before reconnecting the consumer
+
+	PayloadDigest = "digest" // commitment is the sha256 digest of the payload
+	PayloadHash   = "hash"   // payload is the commitment hash hex string
+
+	ErrorKafkaBrokersUnreachable = "Kafka brokers unreachable"
+	ErrorKafkaSlotInvalid        = "Invalid kafka message key - expected slot position or client name"
+	ErrorKafkaSlotGroup          = "Kafka messages for slot group clients not supported"
+	ErrorKafkaCommitmentInvalid  = "Invalid kafka message commitment"
+
+	WarningKafkaConsumeFailed  = "Kafka consumer failed - reconnecting"
+	WarningKafkaFetchFailed    = "Kafka partition fetch failed"
+	WarningKafkaMessageSkipped = "Kafka message skipped"
+)
+
+// KafkaConsumer struct
+// Consumes client commitments from a kafka topic, mapping message keys to
+// client slots and message payloads to commitments. Offsets are committed
+// for the consumer group once the commitments have been saved, so messages
+// are consumed at least once across restarts. The consumer joins the group
+// as a member, so partitions of the topic are balanced across the instances
+// consuming the topic as the same group
+type KafkaConsumer struct {
+	ctx            context.Context
+	wg             *sync.WaitGroup
+	dbInterface    db.Db
+	config         confpkg.KafkaConfig
+	opts           []kgo.Opt
+	reconnectDelay time.Duration
+	fetchWait      time.Duration
+}
+
+// Return new KafkaConsumer instance from kafka config
+func NewKafkaConsumer(ctx context.Context, wg *sync.WaitGroup, dbInterface db.Db,
+	config confpkg.KafkaConfig) (*KafkaConsumer, error) {
+
+	if validateErr := ValidateKafkaConfig(config); validateErr != nil {
+		return nil, validateErr
+	}
+	tlsConfig, tlsErr := kafkaTlsConfig(config)
+	if tlsErr != nil {
+		return nil, tlsErr
+	}
+	if config.Group == "" {
+		config.Group = DefaultKafkaGroup
+	}
+	if config.Payload == "" {
+		config.Payload = PayloadDigest
+	}
+	opts, optsErr := kafkaClientOpts(config, tlsConfig)
+	if optsErr != nil {
+		return nil, optsErr
+	}
+	return &KafkaConsumer{
+		ctx:            ctx,
+		wg:             wg,
+		dbInterface:    dbInterface,
+		config:         config,
+		opts:           opts,
+		reconnectDelay: KafkaReconnectDelay,
+		fetchWait:      KafkaFetchWait,
+	}, nil
+}
+
+// Run consumer until the context is cancelled, reconnecting
+// after a delay whenever consuming fails
+func (k *KafkaConsumer) Run() {
+	defer k.wg.Done()
+	log.Infof("*Kafka* Consuming commitments from kafka topic %s as group %s\n", k.config.Topic, k.config.Group)
+	for {
+		consumeErr := k.consume()
+		select {
+		case <-k.ctx.Done():
+			log.Infoln("Shutting down kafka consumer...")
+			return
+		default:
+		}
+		log.WithFields(log.Fields{log.FieldTopic: k.config.Topic, log.FieldError: consumeErr}).Warnln(
+			WarningKafkaConsumeFailed)
+		select {
+		case <-k.ctx.Done():
+			log.Infoln("Shutting down kafka consumer...")
+			return
+		case <-time.After(k.reconnectDelay):
+		}
+	}
+}
+
+// Consume the topic as a member of the consumer group until fetching
+// fails, saving a commitment fails or the context is cancelled. The group
+// is left on return, with the partitions of this consumer resumed from the
+// committed offsets by the next member assigned them, or on reconnecting
+func (k *KafkaConsumer) consume() error {
+	client, clientErr := kgo.NewClient(append(k.opts, kgo.FetchMaxWait(k.fetchWait))...)
+	if clientErr != nil {
+		return clientErr
+	}
+	defer client.Close()
+
+	for {
+		fetches := client.PollFetches(k.ctx)
+		if k.ctx.Err() != nil {
+			return k.ctx.Err()
+		}
+		var fetchErr error
+		fetches.EachError(func(topic string, partition int32, err error) {
+			log.WithFields(log.Fields{log.FieldTopic: topic, log.FieldError: err}).Warnf(
+				"%s (partition %d)\n", WarningKafkaFetchFailed, partition)
+			fetchErr = err
+		})
+
+		records := fetches.Records()
+		if len(records) > 0 {
+			clientDetails, detailsErr := k.dbInterface.GetClientDetails()
+			if detailsErr != nil {
+				return detailsErr
+			}
+			for _, record := range records {
+				if saveErr := k.ingest(record, clientDetails); saveErr != nil {
+					return saveErr
+				}
+			}
+			if commitErr := client.CommitRecords(k.ctx, records...); commitErr != nil {
+				return commitErr
+			}
+		} else if fetchErr != nil {
+			return fetchErr
+		}
+		client.AllowRebalance()
+	}
+}
+
+// Save the commitment of a kafka message for the slot of the message key
+// Messages that do not map to a client commitment are skipped with a
+// warning, while errors saving the commitment are returned. Commitments
+// equal to the latest submission of the slot are not saved again
+func (k *KafkaConsumer) ingest(record *kgo.Record, clientDetails []models.ClientDetails) error {
+	commitment, position, mapErr := k.mapRecord(record, clientDetails)
+	requestId := fmt.Sprintf("kafka-%s-%d-%d", k.config.Topic, record.Partition, record.Offset)
+	if mapErr != nil {
+		log.WithFields(log.Fields{log.FieldTopic: k.config.Topic, log.FieldRequestId: requestId,
+			log.FieldError: mapErr}).Warnln(WarningKafkaMessageSkipped)
+		return nil
+	}
+	_, saveErr := db.SubmitClientCommitments(k.dbInterface, []models.ClientCommitment{{
+		Commitment:     commitment,
+		ClientPosition: position,
+		RequestId:      requestId,
+		SubmittedAt:    time.Now().UnixMilli()}})
+	return saveErr
+}
+
+// Map kafka message to a commitment and the client position of its slot
+// The message key is the client position or the client name of the slot
+func (k *KafkaConsumer) mapRecord(record *kgo.Record, clientDetails []models.ClientDetails) (
+	chainhash.Hash, int32, error) {
+
+	key := strings.TrimSpace(string(record.Key))
+	var details *models.ClientDetails
+	for i := range clientDetails {
+		if strconv.Itoa(int(clientDetails[i].ClientPosition)) == key || clientDetails[i].ClientName == key {
+			details = &clientDetails[i]
+			break
+		}
+	}
+	if key == "" || details == nil {
+		return chainhash.Hash{}, 0, errors.New(fmt.Sprintf("%s: %q", ErrorKafkaSlotInvalid, key))
+	} else if details.SlotGroup {
+		return chainhash.Hash{}, 0, errors.New(fmt.Sprintf("%s: %q", ErrorKafkaSlotGroup, key))
+	}
+
+	if k.config.Payload == PayloadHash {
+		commitment, hashErr := chainhash.NewHashFromStr(strings.TrimSpace(string(record.Value)))
+		if hashErr != nil || len(strings.TrimSpace(string(record.Value))) != 2*chainhash.HashSize {
+			return chainhash.Hash{}, 0, errors.New(ErrorKafkaCommitmentInvalid)
+		}
+		return *commitment, details.ClientPosition, nil
+	}
+	if len(record.Value) == 0 {
+		return chainhash.Hash{}, 0, errors.New(ErrorKafkaCommitmentInvalid)
+	}
+	return chainhash.Hash(sha256.Sum256(record.Value)), details.ClientPosition, nil
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package ingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync"
+	"testing"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/db"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+// Test validation of kafka config
+func TestValidateKafkaConfig(t *testing.T) {
+	config := confpkg.KafkaConfig{Brokers: []string{"localhost:9092"}, Topic: "commitments"}
+	assert.Equal(t, nil, ValidateKafkaConfig(config))
+
+	invalid := config
+	invalid.Brokers = []string{"localhost"}
+	assert.Equal(t, ErrorKafkaBrokerInvalid+": localhost", ValidateKafkaConfig(invalid).Error())
+	invalid = config
+	invalid.Topic = ""
+	assert.Equal(t, ErrorKafkaTopicMissing, ValidateKafkaConfig(invalid).Error())
+	invalid = config
+	invalid.SaslMechanism = SaslMechanismScramSha256
+	assert.Equal(t, ErrorKafkaSaslCredentials, ValidateKafkaConfig(invalid).Error())
+	invalid.SaslUser, invalid.SaslPassword = "user", "pass"
+	assert.Equal(t, nil, ValidateKafkaConfig(invalid))
+	invalid = config
+	invalid.Payload = "json"
+	assert.Equal(t, ErrorKafkaPayloadInvalid+": json", ValidateKafkaConfig(invalid).Error())
+}
+
+// Test mapping of kafka messages to client commitments
+func TestKafkaConsumerMapRecord(t *testing.T) {
+	clientDetails := []models.ClientDetails{
+		{ClientPosition: 0, ClientName: "alpha"},
+		{ClientPosition: 1, ClientName: "beta"},
+		{ClientPosition: 2, ClientName: "group", SlotGroup: true},
+	}
+	commitment := "1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+	commitmentHash, _ := chainhash.NewHashFromStr(commitment)
+	digest := chainhash.Hash(sha256.Sum256([]byte("payload")))
+
+	k := &KafkaConsumer{config: confpkg.KafkaConfig{Topic: "commitments", Payload: PayloadDigest}}
+	cases := []struct {
+		payload    string
+		key        string
+		value      string
+		commitment chainhash.Hash
+		position   int32
+		err        string
+	}{
+		{PayloadDigest, "1", "payload", digest, 1, ""},
+		{PayloadDigest, "alpha", "payload", digest, 0, ""},
+		{PayloadDigest, "gamma", "payload", chainhash.Hash{}, 0, ErrorKafkaSlotInvalid + `: "gamma"`},
+		{PayloadDigest, "", "payload", chainhash.Hash{}, 0, ErrorKafkaSlotInvalid + `: ""`},
+		{PayloadDigest, "2", "payload", chainhash.Hash{}, 0, ErrorKafkaSlotGroup + `: "2"`},
+		{PayloadDigest, "1", "", chainhash.Hash{}, 0, ErrorKafkaCommitmentInvalid},
+		{PayloadHash, "beta", commitment + "\n", *commitmentHash, 1, ""},
+		{PayloadHash, "beta", "payload", chainhash.Hash{}, 0, ErrorKafkaCommitmentInvalid},
+		{PayloadHash, "beta", commitment[:10], chainhash.Hash{}, 0, ErrorKafkaCommitmentInvalid},
+	}
+	for _, tc := range cases {
+		k.config.Payload = tc.payload
+		hash, position, mapErr := k.mapRecord(&kgo.Record{Key: []byte(tc.key), Value: []byte(tc.value)}, clientDetails)
+		if tc.err != "" {
+			assert.Equal(t, tc.err, mapErr.Error(), tc.key)
+			continue
+		}
+		assert.Equal(t, nil, mapErr, tc.key)
+		assert.Equal(t, tc.commitment, hash, tc.key)
+		assert.Equal(t, tc.position, position, tc.key)
+	}
+}
+
+// Return offsets committed by the consumer group for the topic partitions
+func committedOffsets(t *testing.T, cluster *kfake.Cluster, group string, topic string) map[int32]int64 {
+	client, clientErr := kgo.NewClient(kgo.SeedBrokers(cluster.ListenAddrs()...),
+		kgo.SASL(scram.Auth{User: "admin", Pass: "admin"}.AsSha256Mechanism()))
+	assert.Equal(t, nil, clientErr)
+	defer client.Close()
+
+	req := kmsg.NewPtrOffsetFetchRequest()
+	req.Group = group
+	reqTopic := kmsg.NewOffsetFetchRequestTopic()
+	reqTopic.Topic = topic
+	reqTopic.Partitions = []int32{0}
+	req.Topics = append(req.Topics, reqTopic)
+	resp, respErr := req.RequestWith(context.Background(), client)
+	assert.Equal(t, nil, respErr)
+
+	offsets := make(map[int32]int64)
+	for _, respTopic := range resp.Topics {
+		for _, partition := range respTopic.Partitions {
+			if partition.Offset >= 0 {
+				offsets[partition.Partition] = partition.Offset
+			}
+		}
+	}
+	return offsets
+}
+
+// Produce records of keys and values to the topic in a single zstd batch
+func produceRecords(t *testing.T, cluster *kfake.Cluster, topic string, keyValues ...string) {
+	client, clientErr := kgo.NewClient(kgo.SeedBrokers(cluster.ListenAddrs()...),
+		kgo.SASL(scram.Auth{User: "admin", Pass: "admin"}.AsSha256Mechanism()),
+		kgo.ProducerBatchCompression(kgo.ZstdCompression()), kgo.ProducerLinger(10*time.Millisecond))
+	assert.Equal(t, nil, clientErr)
+	defer client.Close()
+
+	var records []*kgo.Record
+	for i := 0; i < len(keyValues); i += 2 {
+		records = append(records, &kgo.Record{Topic: topic, Key: []byte(keyValues[i]), Value: []byte(keyValues[i+1])})
+	}
+	assert.Equal(t, nil, client.ProduceSync(context.Background(), records...).FirstErr())
+}
+
+// Run consumer until the consumer group has committed the offset
+func runKafkaConsumer(t *testing.T, cluster *kfake.Cluster, dbFake db.Db, offset int64) {
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	consumer, consumerErr := NewKafkaConsumer(ctx, wg, dbFake, confpkg.KafkaConfig{
+		Brokers: cluster.ListenAddrs(), Topic: "commitments",
+		SaslMechanism: SaslMechanismScramSha256, SaslUser: "admin", SaslPassword: "admin"})
+	assert.Equal(t, nil, consumerErr)
+	assert.Equal(t, DefaultKafkaGroup, consumer.config.Group)
+	consumer.fetchWait = 10 * time.Millisecond
+
+	wg.Add(1)
+	go consumer.Run()
+	for i := 0; i < 500 && committedOffsets(t, cluster, DefaultKafkaGroup, "commitments")[0] != offset; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	wg.Wait()
+}
+
+// Test consumer saves commitments of the topic and commits offsets
+// for the consumer group, resumed from by the next consumer of the group
+func TestKafkaConsumer(t *testing.T) {
+	cluster, clusterErr := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(1, "commitments"),
+		kfake.EnableSASL())
+	assert.Equal(t, nil, clusterErr)
+	defer cluster.Close()
+	produceRecords(t, cluster, "commitments", "0", "first", "unknown", "second")
+
+	dbFake := db.NewDbFake()
+	dbFake.SaveClientDetails(models.ClientDetails{ClientPosition: 0, ClientName: "alpha"})
+	runKafkaConsumer(t, cluster, dbFake, 2)
+
+	assert.Equal(t, map[int32]int64{0: 2}, committedOffsets(t, cluster, DefaultKafkaGroup, "commitments"))
+	commitments, _ := dbFake.GetClientCommitments()
+	assert.Equal(t, 1, len(commitments))
+	assert.Equal(t, chainhash.Hash(sha256.Sum256([]byte("first"))), commitments[0].Commitment)
+	assert.Equal(t, int32(0), commitments[0].ClientPosition)
+	assert.Equal(t, "kafka-commitments-0-0", commitments[0].RequestId)
+
+	// only messages after the committed offset consumed on restart
+	produceRecords(t, cluster, "commitments", "alpha", "third")
+	restartDb := db.NewDbFake()
+	restartDb.SaveClientDetails(models.ClientDetails{ClientPosition: 0, ClientName: "alpha"})
+	runKafkaConsumer(t, cluster, restartDb, 3)
+
+	assert.Equal(t, map[int32]int64{0: 3}, committedOffsets(t, cluster, DefaultKafkaGroup, "commitments"))
+	commitments, _ = restartDb.GetClientCommitments()
+	assert.Equal(t, 1, len(commitments))
+	assert.Equal(t, chainhash.Hash(sha256.Sum256([]byte("third"))), commitments[0].Commitment)
+	assert.Equal(t, "kafka-commitments-0-2", commitments[0].RequestId)
+}
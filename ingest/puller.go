@@ -0,0 +1,178 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/db"
+	"mainstay/log"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// source puller consts
+const (
+	DefaultPullInterval = 60 * time.Second
+
+	ErrorSourceSlotInvalid = "Commitment source client slot not found"
+	ErrorSourceSlotGroup   = "Commitment sources for slot group clients not supported"
+
+	WarningInvalidPullIntervalArg = "Invalid pull interval config value"
+	WarningPullFailed             = "Commitment source pull failed"
+	WarningPullCommitSkipped      = "Commitment source commitment skipped"
+)
+
+// commitment source pulled for a client slot
+type sourceSlot struct {
+	name     string
+	position int32
+	source   CommitmentSource
+}
+
+// commitment source with its own request ids of commitments
+type requestIdSource interface {
+	requestId(name string, commitment chainhash.Hash) string
+}
+
+// Return request id of a commitment pulled from the slot source
+func (slot sourceSlot) requestId(commitment chainhash.Hash) string {
+	if source, ok := slot.source.(requestIdSource); ok {
+		return source.requestId(slot.name, commitment)
+	}
+	return fmt.Sprintf("pull-%s-%s", slot.name, commitment.String()[:16])
+}
+
+// SourcePuller struct
+// Periodically pulls the latest commitment of each configured commitment
+// source, e.g. a client url, a watched file, a client node rpc or a sidechain
+// block hash, and saves
+// it as the latest commitment of the client slot of the source, so that
+// clients are attested without submitting their commitments. Commitments
+// equal to the latest commitment of the slot are not saved again
+type SourcePuller struct {
+	ctx         context.Context
+	wg          *sync.WaitGroup
+	dbInterface db.Db
+	sources     []sourceSlot
+	interval    time.Duration
+}
+
+// Return new SourcePuller from pull config with the commitment
+// source of each configured source
+func NewSourcePuller(ctx context.Context, wg *sync.WaitGroup, dbInterface db.Db,
+	config confpkg.PullConfig) (*SourcePuller, error) {
+
+	if validateErr := ValidatePullConfig(config); validateErr != nil {
+		return nil, validateErr
+	}
+	var sources []sourceSlot
+	for _, sourceConfig := range config.Sources {
+		source, sourceErr := NewCommitmentSource(sourceConfig)
+		if sourceErr != nil {
+			return nil, sourceErr
+		}
+		sources = append(sources, sourceSlot{sourceConfig.Name, sourceConfig.Position, source})
+	}
+	interval := DefaultPullInterval
+	if config.IntervalSeconds > 0 {
+		interval = time.Duration(config.IntervalSeconds) * time.Second
+	} else if config.IntervalSeconds != -1 {
+		log.Warnf("%s (%d)\n", WarningInvalidPullIntervalArg, config.IntervalSeconds)
+	}
+	return newSourcePuller(ctx, wg, dbInterface, sources, interval), nil
+}
+
+// Return new SourcePuller of the source slots pulled every interval
+func newSourcePuller(ctx context.Context, wg *sync.WaitGroup, dbInterface db.Db,
+	sources []sourceSlot, interval time.Duration) *SourcePuller {
+	return &SourcePuller{ctx: ctx, wg: wg, dbInterface: dbInterface, sources: sources, interval: interval}
+}
+
+// Run puller until the context is cancelled
+func (p *SourcePuller) Run() {
+	defer p.wg.Done()
+	log.Infof("*Pull* Pulling %d commitment sources every %s\n", len(p.sources), p.interval.String())
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		if pullErr := p.pull(); pullErr != nil {
+			log.WithFields(log.Fields{log.FieldError: pullErr}).Warnln(WarningPullFailed)
+		}
+		select {
+		case <-p.ctx.Done():
+			log.Infoln("Shutting down source puller...")
+			for _, slot := range p.sources {
+				slot.source.Close()
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Save the latest commitment of each source as the commitment of its client
+// slot. Sources whose commitment could not be pulled or whose slot is not a
+// registered client slot are skipped with a warning, while errors getting
+// the client slots or saving the commitments are returned
+func (p *SourcePuller) pull() error {
+	clientDetails, detailsErr := p.dbInterface.GetClientDetails()
+	if detailsErr != nil {
+		return detailsErr
+	}
+	var commitments []models.ClientCommitment
+	for _, slot := range p.sources {
+		commitment, commitmentErr := p.commitment(slot, clientDetails)
+		if commitmentErr != nil {
+			log.WithFields(log.Fields{log.FieldClientPosition: slot.position, log.FieldSource: slot.name,
+				log.FieldError: commitmentErr}).Warnln(WarningPullCommitSkipped)
+			continue
+		}
+		commitments = append(commitments, commitment)
+	}
+	if len(commitments) == 0 {
+		return nil
+	}
+	_, saveErr := db.SubmitClientCommitments(p.dbInterface, commitments)
+	return saveErr
+}
+
+// Return commitment pulled from the source for its slot
+func (p *SourcePuller) commitment(slot sourceSlot, clientDetails []models.ClientDetails) (
+	models.ClientCommitment, error) {
+
+	var details *models.ClientDetails
+	for i := range clientDetails {
+		if clientDetails[i].ClientPosition == slot.position {
+			details = &clientDetails[i]
+			break
+		}
+	}
+	if details == nil {
+		return models.ClientCommitment{}, errors.New(fmt.Sprintf("%s: %d", ErrorSourceSlotInvalid, slot.position))
+	} else if details.SlotGroup {
+		return models.ClientCommitment{}, errors.New(fmt.Sprintf("%s: %d", ErrorSourceSlotGroup, slot.position))
+	}
+
+	ctx, cancel := context.WithTimeout(p.ctx, SourceTimeout)
+	defer cancel()
+	commitment, commitmentErr := slot.source.Commitment(ctx)
+	if commitmentErr != nil {
+		return models.ClientCommitment{}, commitmentErr
+	}
+	return models.ClientCommitment{
+		Commitment:     commitment,
+		ClientPosition: slot.position,
+		RequestId:      slot.requestId(commitment),
+		SubmittedAt:    time.Now().UnixMilli(),
+	}, nil
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package ingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	confpkg "mainstay/config"
+	"mainstay/db"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test source puller commits the latest commitment of each source
+func TestSourcePuller(t *testing.T) {
+	dbFake := db.NewDbFake()
+	dbFake.SaveClientDetails(models.ClientDetails{ClientPosition: 0, ClientName: "backup"})
+	dbFake.SaveClientDetails(models.ClientDetails{ClientPosition: 1, ClientName: "group", SlotGroup: true})
+	dir := t.TempDir()
+	backupPath := filepath.Join(dir, "backup")
+	assert.Equal(t, nil, os.WriteFile(backupPath, []byte("backup 1"), 0600))
+	hashPath := filepath.Join(dir, "hash")
+	assert.Equal(t, nil, os.WriteFile(hashPath, []byte(testSourceHash), 0600))
+
+	// sources validated
+	_, pullerErr := NewSourcePuller(nil, nil, dbFake, confpkg.PullConfig{
+		Sources: []confpkg.PullSource{{Name: "backup", Position: 0}}, IntervalSeconds: -1})
+	assert.Equal(t, ErrorSourceTypeInvalid+`: backup: ""`, pullerErr.Error())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	puller, pullerErr := NewSourcePuller(ctx, wg, dbFake, confpkg.PullConfig{
+		Sources: []confpkg.PullSource{
+			{Name: "backup", Position: 0, Type: SourceTypeFile, Path: backupPath},
+			{Name: "group", Position: 1, Type: SourceTypeFile, Path: backupPath},
+			{Name: "hash", Position: 2, Type: SourceTypeFile, Path: hashPath, Payload: PayloadHash}},
+		IntervalSeconds: -1})
+	assert.Equal(t, nil, pullerErr)
+	assert.Equal(t, DefaultPullInterval, puller.interval)
+
+	// slot group and unregistered slots skipped
+	assert.Equal(t, nil, puller.pull())
+	commitments, _ := dbFake.GetClientCommitments()
+	assert.Equal(t, 1, len(commitments))
+	backupCommitment := chainhash.Hash(sha256.Sum256([]byte("backup 1")))
+	assert.Equal(t, backupCommitment, commitments[0].Commitment)
+	assert.Equal(t, int32(0), commitments[0].ClientPosition)
+	assert.Equal(t, "pull-backup-"+backupCommitment.String()[:16], commitments[0].RequestId)
+
+	// unchanged commitments not saved again
+	assert.Equal(t, nil, puller.pull())
+	commitments, _ = dbFake.GetClientCommitments()
+	assert.Equal(t, 1, len(commitments))
+
+	// sources with failing pulls skipped
+	dbFake.SaveClientDetails(models.ClientDetails{ClientPosition: 2, ClientName: "hash"})
+	// run pulls once before stopping with the context already cancelled
+	assert.Equal(t, nil, os.Remove(backupPath))
+	cancel()
+	wg.Add(1)
+	go puller.Run()
+	wg.Wait()
+	commitments, _ = dbFake.GetClientCommitments()
+
+	hashCommitment, _ := chainhash.NewHashFromStr(testSourceHash)
+	assert.Equal(t, []models.ClientCommitment{
+		{Commitment: backupCommitment, ClientPosition: 0, RequestId: "pull-backup-" + backupCommitment.String()[:16],
+			SubmittedAt: commitments[0].SubmittedAt},
+		{Commitment: *hashCommitment, ClientPosition: 2, RequestId: "pull-hash-" + testSourceHash[:16],
+			SubmittedAt: commitments[1].SubmittedAt},
+	}, commitments)
+}
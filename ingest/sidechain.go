@@ -0,0 +1,74 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"mainstay/clients"
+	confpkg "mainstay/config"
+	"mainstay/db"
+	"mainstay/log"
+)
+
+// sidechain fetcher consts
+const (
+	DefaultSidechainInterval = 60 * time.Second
+
+	ErrorSidechainChainInvalid   = "Invalid sidechain chain - expected name:position"
+	ErrorSidechainChainDuplicate = "Duplicate sidechain chain"
+	ErrorSidechainClientMissing  = "Sidechain rpc client missing"
+
+	WarningInvalidSidechainIntervalArg = "Invalid sidechain interval config value"
+)
+
+// Check that the sidechain config is complete and consistent
+// Each chain must have a name and a client position, with no chain
+// name or position configured twice
+func ValidateSidechainConfig(config confpkg.SidechainConfig) error {
+	names := make(map[string]bool)
+	positions := make(map[int32]bool)
+	for _, chain := range config.Chains {
+		if chain.Name == "" || chain.Position < 0 {
+			return errors.New(fmt.Sprintf("%s: %q", ErrorSidechainChainInvalid, chain.Name))
+		} else if names[chain.Name] || positions[chain.Position] {
+			return errors.New(fmt.Sprintf("%s: %s:%d", ErrorSidechainChainDuplicate, chain.Name, chain.Position))
+		}
+		names[chain.Name] = true
+		positions[chain.Position] = true
+	}
+	return nil
+}
+
+// Return new SourcePuller of the latest block hash of each configured
+// sidechain, e.g. an Ocean or Elements chain, from the sidechain config and
+// the rpc clients of the sidechains by name, so that sidechains are attested
+// without an external submission script
+func NewSidechainFetcher(ctx context.Context, wg *sync.WaitGroup, dbInterface db.Db,
+	config confpkg.SidechainConfig, sidechainClients map[string]clients.SidechainClient) (*SourcePuller, error) {
+
+	if validateErr := ValidateSidechainConfig(config); validateErr != nil {
+		return nil, validateErr
+	}
+	var sources []sourceSlot
+	for _, chain := range config.Chains {
+		client, ok := sidechainClients[chain.Name]
+		if !ok || client == nil {
+			return nil, errors.New(fmt.Sprintf("%s: %s", ErrorSidechainClientMissing, chain.Name))
+		}
+		sources = append(sources, sourceSlot{chain.Name, chain.Position, NewCommitmentSourceSidechain(client)})
+	}
+	interval := DefaultSidechainInterval
+	if config.IntervalSeconds > 0 {
+		interval = time.Duration(config.IntervalSeconds) * time.Second
+	} else if config.IntervalSeconds != -1 {
+		log.Warnf("%s (%d)\n", WarningInvalidSidechainIntervalArg, config.IntervalSeconds)
+	}
+	return newSourcePuller(ctx, wg, dbInterface, sources, interval), nil
+}
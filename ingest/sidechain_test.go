@@ -0,0 +1,95 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package ingest
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"mainstay/clients"
+	confpkg "mainstay/config"
+	"mainstay/db"
+	"mainstay/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test validation of sidechain config
+func TestValidateSidechainConfig(t *testing.T) {
+	config := confpkg.SidechainConfig{Chains: []confpkg.SidechainChain{
+		{Name: "ocean", Position: 1}, {Name: "liquid", Position: 2}}}
+	assert.Equal(t, nil, ValidateSidechainConfig(config))
+	assert.Equal(t, nil, ValidateSidechainConfig(confpkg.SidechainConfig{}))
+
+	for _, tc := range []struct {
+		chains []confpkg.SidechainChain
+		err    string
+	}{
+		{[]confpkg.SidechainChain{{Name: "ocean", Position: -1}}, ErrorSidechainChainInvalid + `: "ocean"`},
+		{[]confpkg.SidechainChain{{Name: "", Position: 1}}, ErrorSidechainChainInvalid + `: ""`},
+		{[]confpkg.SidechainChain{{Name: "ocean", Position: 1}, {Name: "ocean", Position: 2}}, ErrorSidechainChainDuplicate + ": ocean:2"},
+		{[]confpkg.SidechainChain{{Name: "ocean", Position: 1}, {Name: "liquid", Position: 1}}, ErrorSidechainChainDuplicate + ": liquid:1"},
+	} {
+		validateErr := ValidateSidechainConfig(confpkg.SidechainConfig{Chains: tc.chains})
+		if assert.NotNil(t, validateErr, tc.err) {
+			assert.Equal(t, tc.err, validateErr.Error())
+		}
+	}
+}
+
+// Test sidechain fetcher commits the latest block hash of each chain
+func TestSidechainFetcher(t *testing.T) {
+	dbFake := db.NewDbFake()
+	dbFake.SaveClientDetails(models.ClientDetails{ClientPosition: 0, ClientName: "ocean"})
+	dbFake.SaveClientDetails(models.ClientDetails{ClientPosition: 1, ClientName: "group", SlotGroup: true})
+	ocean := clients.NewSidechainClientFake()
+	liquid := clients.NewSidechainClientFake()
+	liquid.Generate(2)
+	sidechainClients := map[string]clients.SidechainClient{"ocean": ocean, "liquid": liquid, "elements": ocean}
+
+	// rpc client of each chain required
+	_, fetcherErr := NewSidechainFetcher(nil, nil, dbFake, confpkg.SidechainConfig{
+		Chains: []confpkg.SidechainChain{{Name: "ocean", Position: 0}, {Name: "other", Position: 3}}, IntervalSeconds: -1}, sidechainClients)
+	assert.Equal(t, ErrorSidechainClientMissing+": other", fetcherErr.Error())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	fetcher, fetcherErr := NewSidechainFetcher(ctx, wg, dbFake, confpkg.SidechainConfig{
+		Chains: []confpkg.SidechainChain{
+			{Name: "ocean", Position: 0}, {Name: "liquid", Position: 1}, {Name: "elements", Position: 2}},
+		IntervalSeconds: -1}, sidechainClients)
+	assert.Equal(t, nil, fetcherErr)
+	assert.Equal(t, DefaultSidechainInterval, fetcher.interval)
+
+	// slot group and unregistered slots skipped
+	assert.Equal(t, nil, fetcher.pull())
+	commitments, _ := dbFake.GetClientCommitments()
+	assert.Equal(t, 1, len(commitments))
+	blockhash, _ := ocean.GetBestBlockHash()
+	assert.Equal(t, *blockhash, commitments[0].Commitment)
+	assert.Equal(t, int32(0), commitments[0].ClientPosition)
+	assert.Equal(t, "sidechain-ocean-0", commitments[0].RequestId)
+
+	// new block hash committed once the chain advances
+	dbFake.SaveClientDetails(models.ClientDetails{ClientPosition: 2, ClientName: "elements"})
+	ocean.Generate(1)
+	assert.Equal(t, nil, fetcher.pull())
+
+	// no block hashes fetched once the context is cancelled
+	cancel()
+	wg.Add(1)
+	go fetcher.Run()
+	wg.Wait()
+	commitments, _ = dbFake.GetClientCommitments()
+
+	blockhash, _ = ocean.GetBestBlockHash()
+	assert.Equal(t, []models.ClientCommitment{
+		{Commitment: *blockhash, ClientPosition: 0, RequestId: "sidechain-ocean-1",
+			SubmittedAt: commitments[0].SubmittedAt},
+		{Commitment: *blockhash, ClientPosition: 2, RequestId: "sidechain-elements-1",
+			SubmittedAt: commitments[1].SubmittedAt},
+	}, commitments)
+}
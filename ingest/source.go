@@ -0,0 +1,147 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package ingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	confpkg "mainstay/config"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// commitment source consts
+const (
+	SourceTypeHttp = "http" // commitment polled from a client url
+	SourceTypeFile = "file" // commitment read from a watched file
+	SourceTypeRpc  = "rpc"  // commitment returned by a json-rpc method
+
+	SourceTypeSidechain = "sidechain" // latest block hash of a sidechain
+
+	DefaultSourceRpcMethod = "getbestblockhash"
+	SourceTimeout          = 10 * time.Second // timeout of pulling a commitment
+	SourceMaxBytes         = 1 << 20          // max bytes read of a hash payload
+
+	ErrorSourceInvalid           = "Invalid commitment source - expected name:position"
+	ErrorSourceDuplicate         = "Duplicate commitment source"
+	ErrorSourceTypeInvalid       = "Invalid commitment source type"
+	ErrorSourceUrlInvalid        = "Invalid commitment source url"
+	ErrorSourcePathMissing       = "Commitment source file path missing"
+	ErrorSourceRpcMissing        = "Commitment source rpc url missing"
+	ErrorSourcePayloadInvalid    = "Invalid commitment source payload"
+	ErrorSourceCommitmentInvalid = "Invalid commitment source commitment - expected hash hex string"
+	ErrorSourceCommitmentEmpty   = "Commitment source returned no commitment"
+	ErrorSourceRequestFailed     = "Commitment source request failed"
+)
+
+// CommitmentSource interface
+// Source the server pulls the latest commitment of a client slot from,
+// as opposed to the client pushing its commitments to the request api
+type CommitmentSource interface {
+	// Return the latest commitment of the source
+	Commitment(ctx context.Context) (chainhash.Hash, error)
+	// Return the type of the source
+	Type() string
+	// Close any connection of the source
+	Close()
+}
+
+// Check that a commitment source config is complete for its type
+func ValidateSourceConfig(source confpkg.PullSource) error {
+	if source.Name == "" || source.Position < 0 {
+		return errors.New(fmt.Sprintf("%s: %q", ErrorSourceInvalid, source.Name))
+	}
+	switch source.Type {
+	case SourceTypeHttp:
+		sourceUrl, urlErr := url.Parse(source.Url)
+		if urlErr != nil || (sourceUrl.Scheme != "http" && sourceUrl.Scheme != "https") || sourceUrl.Host == "" {
+			return errors.New(fmt.Sprintf("%s: %s", ErrorSourceUrlInvalid, source.Name))
+		}
+	case SourceTypeFile:
+		if source.Path == "" {
+			return errors.New(fmt.Sprintf("%s: %s", ErrorSourcePathMissing, source.Name))
+		}
+	case SourceTypeRpc:
+		if source.RpcUrl == "" {
+			return errors.New(fmt.Sprintf("%s: %s", ErrorSourceRpcMissing, source.Name))
+		}
+		return nil
+	default:
+		return errors.New(fmt.Sprintf("%s: %s: %q", ErrorSourceTypeInvalid, source.Name, source.Type))
+	}
+	switch source.Payload {
+	case "", PayloadDigest, PayloadHash:
+	default:
+		return errors.New(fmt.Sprintf("%s: %s: %s", ErrorSourcePayloadInvalid, source.Name, source.Payload))
+	}
+	return nil
+}
+
+// Check that the pull config is complete and consistent
+// Each source must be complete for its type, with no source
+// name or position configured twice
+func ValidatePullConfig(config confpkg.PullConfig) error {
+	names := make(map[string]bool)
+	positions := make(map[int32]bool)
+	for _, source := range config.Sources {
+		if validateErr := ValidateSourceConfig(source); validateErr != nil {
+			return validateErr
+		} else if names[source.Name] || positions[source.Position] {
+			return errors.New(fmt.Sprintf("%s: %s:%d", ErrorSourceDuplicate, source.Name, source.Position))
+		}
+		names[source.Name] = true
+		positions[source.Position] = true
+	}
+	return nil
+}
+
+// Return CommitmentSource of the source config type
+func NewCommitmentSource(source confpkg.PullSource) (CommitmentSource, error) {
+	if validateErr := ValidateSourceConfig(source); validateErr != nil {
+		return nil, validateErr
+	}
+	switch source.Type {
+	case SourceTypeHttp:
+		return NewCommitmentSourceHttp(source), nil
+	case SourceTypeFile:
+		return NewCommitmentSourceFile(source), nil
+	}
+	return NewCommitmentSourceRpc(source)
+}
+
+// Return commitment of a hash payload from its commitment hash hex string
+func hashCommitment(value []byte) (chainhash.Hash, error) {
+	hashStr := strings.TrimSpace(string(value))
+	if hashStr == "" {
+		return chainhash.Hash{}, errors.New(ErrorSourceCommitmentEmpty)
+	}
+	commitment, hashErr := chainhash.NewHashFromStr(hashStr)
+	if hashErr != nil || len(hashStr) != 2*chainhash.HashSize {
+		return chainhash.Hash{}, errors.New(ErrorSourceCommitmentInvalid)
+	}
+	return *commitment, nil
+}
+
+// Return commitment of a digest payload from the sha256 digest of the
+// payload, read in full without keeping it in memory
+func digestCommitment(reader io.Reader) (chainhash.Hash, error) {
+	hasher := sha256.New()
+	n, copyErr := io.Copy(hasher, reader)
+	if copyErr != nil {
+		return chainhash.Hash{}, copyErr
+	} else if n == 0 {
+		return chainhash.Hash{}, errors.New(ErrorSourceCommitmentEmpty)
+	}
+	var commitment chainhash.Hash
+	copy(commitment[:], hasher.Sum(nil))
+	return commitment, nil
+}
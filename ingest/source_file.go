@@ -0,0 +1,84 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package ingest
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	confpkg "mainstay/config"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// CommitmentSourceFile struct
+// Watches a file for the commitment. With the digest payload, the default,
+// the commitment is the sha256 digest of the file contents, e.g. of a backup
+// or a document, while with the hash payload the file contains the commitment
+// hash hex string. The file is only read again once its modification time
+// or size has changed since it was last read
+type CommitmentSourceFile struct {
+	path    string
+	payload string
+
+	modTime    time.Time
+	size       int64
+	commitment *chainhash.Hash
+}
+
+// Return new CommitmentSourceFile instance
+func NewCommitmentSourceFile(source confpkg.PullSource) *CommitmentSourceFile {
+	payload := source.Payload
+	if payload == "" {
+		payload = PayloadDigest
+	}
+	return &CommitmentSourceFile{path: source.Path, payload: payload}
+}
+
+// Get source type
+func (s *CommitmentSourceFile) Type() string {
+	return SourceTypeFile
+}
+
+// Nothing to close for file sources
+func (s *CommitmentSourceFile) Close() {}
+
+// Get commitment of the file, read again if the file has changed
+func (s *CommitmentSourceFile) Commitment(ctx context.Context) (chainhash.Hash, error) {
+	info, statErr := os.Stat(s.path)
+	if statErr != nil {
+		return chainhash.Hash{}, statErr
+	}
+	if s.commitment != nil && info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return *s.commitment, nil
+	}
+
+	file, openErr := os.Open(s.path)
+	if openErr != nil {
+		return chainhash.Hash{}, openErr
+	}
+	defer file.Close()
+
+	var commitment chainhash.Hash
+	var commitmentErr error
+	if s.payload == PayloadDigest {
+		commitment, commitmentErr = digestCommitment(file)
+	} else {
+		value, readErr := io.ReadAll(io.LimitReader(file, SourceMaxBytes))
+		if readErr != nil {
+			return chainhash.Hash{}, readErr
+		}
+		commitment, commitmentErr = hashCommitment(value)
+	}
+	if commitmentErr != nil {
+		return chainhash.Hash{}, commitmentErr
+	}
+	s.modTime = info.ModTime()
+	s.size = info.Size()
+	s.commitment = &commitment
+	return commitment, nil
+}
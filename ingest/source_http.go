@@ -0,0 +1,90 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	confpkg "mainstay/config"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// CommitmentSourceHttp struct
+// Polls the commitment from a client url. With the hash payload, the default,
+// the response body is the commitment hash hex string or a json object with
+// the hash hex string as its commitment field. With the digest payload the
+// commitment is the sha256 digest of the body
+type CommitmentSourceHttp struct {
+	url     string
+	token   string
+	payload string
+	client  *http.Client
+}
+
+// Return new CommitmentSourceHttp instance
+func NewCommitmentSourceHttp(source confpkg.PullSource) *CommitmentSourceHttp {
+	payload := source.Payload
+	if payload == "" {
+		payload = PayloadHash
+	}
+	return &CommitmentSourceHttp{
+		url:     source.Url,
+		token:   source.Token,
+		payload: payload,
+		client:  &http.Client{Timeout: SourceTimeout},
+	}
+}
+
+// Get source type
+func (s *CommitmentSourceHttp) Type() string {
+	return SourceTypeHttp
+}
+
+// Nothing to close for http sources
+func (s *CommitmentSourceHttp) Close() {}
+
+// Get commitment from the response of the client url
+func (s *CommitmentSourceHttp) Commitment(ctx context.Context) (chainhash.Hash, error) {
+	req, reqErr := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if reqErr != nil {
+		return chainhash.Hash{}, reqErr
+	}
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	resp, respErr := s.client.Do(req)
+	if respErr != nil {
+		return chainhash.Hash{}, respErr
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return chainhash.Hash{}, errors.New(fmt.Sprintf("%s: %s", ErrorSourceRequestFailed, resp.Status))
+	}
+
+	if s.payload == PayloadDigest {
+		return digestCommitment(resp.Body)
+	}
+	body, bodyErr := io.ReadAll(io.LimitReader(resp.Body, SourceMaxBytes))
+	if bodyErr != nil {
+		return chainhash.Hash{}, bodyErr
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+		var response struct {
+			Commitment string `json:"commitment"`
+		}
+		if decodeErr := json.Unmarshal(body, &response); decodeErr != nil {
+			return chainhash.Hash{}, errors.New(ErrorSourceCommitmentInvalid)
+		}
+		body = []byte(response.Commitment)
+	}
+	return hashCommitment(body)
+}
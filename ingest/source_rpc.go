@@ -0,0 +1,94 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	confpkg "mainstay/config"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/rpcclient"
+)
+
+// CommitmentSourceRpc struct
+// Calls a json-rpc method of a client node returning the commitment hash hex
+// string, by default the getbestblockhash method of bitcoin based nodes
+type CommitmentSourceRpc struct {
+	method string
+	client *rpcclient.Client
+}
+
+// Return new CommitmentSourceRpc instance connecting to the rpc url
+// of the source with the rpc user and password of the source
+// TLS is enabled for https urls and urls without a scheme use plain http
+func NewCommitmentSourceRpc(source confpkg.PullSource) (*CommitmentSourceRpc, error) {
+	host := strings.TrimPrefix(source.RpcUrl, "http://")
+	disableTLS := true
+	if strings.HasPrefix(host, "https://") {
+		host = strings.TrimPrefix(host, "https://")
+		disableTLS = false
+	}
+	client, rpcErr := rpcclient.New(&rpcclient.ConnConfig{
+		Host:         host,
+		User:         source.RpcUser,
+		Pass:         source.RpcPass,
+		HTTPPostMode: true,
+		DisableTLS:   disableTLS,
+	}, nil)
+	if rpcErr != nil {
+		return nil, errors.New(fmt.Sprintf("%s: %v", ErrorSourceRequestFailed, rpcErr))
+	}
+	method := source.Method
+	if method == "" {
+		method = DefaultSourceRpcMethod
+	}
+	return &CommitmentSourceRpc{method: method, client: client}, nil
+}
+
+// Get source type
+func (s *CommitmentSourceRpc) Type() string {
+	return SourceTypeRpc
+}
+
+// Shutdown the rpc client of the source
+func (s *CommitmentSourceRpc) Close() {
+	s.client.Shutdown()
+}
+
+// Get commitment from the result of the rpc method
+// The rpc client does not take a context, so the request is awaited
+// until the context is done and its late result is then discarded
+func (s *CommitmentSourceRpc) Commitment(ctx context.Context) (chainhash.Hash, error) {
+	type rpcResult struct {
+		json json.RawMessage
+		err  error
+	}
+	resultChan := make(chan rpcResult, 1)
+	go func() {
+		resultJson, resultErr := s.client.RawRequest(s.method, nil)
+		resultChan <- rpcResult{resultJson, resultErr}
+	}()
+
+	var resultJson json.RawMessage
+	select {
+	case <-ctx.Done():
+		return chainhash.Hash{}, errors.New(fmt.Sprintf("%s: %v", ErrorSourceRequestFailed, ctx.Err()))
+	case result := <-resultChan:
+		if result.err != nil {
+			return chainhash.Hash{}, result.err
+		}
+		resultJson = result.json
+	}
+	var hashStr string
+	if unmarshalErr := json.Unmarshal(resultJson, &hashStr); unmarshalErr != nil {
+		return chainhash.Hash{}, errors.New(ErrorSourceCommitmentInvalid)
+	}
+	return hashCommitment([]byte(hashStr))
+}
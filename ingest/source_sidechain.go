@@ -0,0 +1,75 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"mainstay/clients"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// CommitmentSourceSidechain struct
+// Commitment of the latest block hash of a sidechain, e.g. an Ocean or
+// Elements chain, keeping the height of the last block hash returned
+type CommitmentSourceSidechain struct {
+	client clients.SidechainClient
+	height int32
+}
+
+// Return new CommitmentSourceSidechain instance for the sidechain rpc client
+func NewCommitmentSourceSidechain(client clients.SidechainClient) *CommitmentSourceSidechain {
+	return &CommitmentSourceSidechain{client: client}
+}
+
+// Get source type
+func (s *CommitmentSourceSidechain) Type() string {
+	return SourceTypeSidechain
+}
+
+// Shutdown the rpc client of the sidechain
+func (s *CommitmentSourceSidechain) Close() {
+	s.client.Close()
+}
+
+// Get commitment of the latest block hash of the sidechain
+// The sidechain client does not take a context, so the requests are awaited
+// until the context is done and their late result is then discarded
+func (s *CommitmentSourceSidechain) Commitment(ctx context.Context) (chainhash.Hash, error) {
+	type blockResult struct {
+		hash   *chainhash.Hash
+		height int32
+		err    error
+	}
+	resultChan := make(chan blockResult, 1)
+	go func() {
+		blockhash, blockhashErr := s.client.GetBestBlockHash()
+		if blockhashErr != nil {
+			resultChan <- blockResult{err: blockhashErr}
+			return
+		}
+		height, heightErr := s.client.GetBlockHeight(blockhash)
+		resultChan <- blockResult{blockhash, height, heightErr}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return chainhash.Hash{}, errors.New(fmt.Sprintf("%s: %v", ErrorSourceRequestFailed, ctx.Err()))
+	case result := <-resultChan:
+		if result.err != nil {
+			return chainhash.Hash{}, result.err
+		}
+		s.height = result.height
+		return *result.hash, nil
+	}
+}
+
+// Return request id of the commitment with the block height of the sidechain
+func (s *CommitmentSourceSidechain) requestId(name string, commitment chainhash.Hash) string {
+	return fmt.Sprintf("sidechain-%s-%d", name, s.height)
+}
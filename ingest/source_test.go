@@ -0,0 +1,236 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package ingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	confpkg "mainstay/config"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSourceHash = "1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+
+// Test validation of commitment source config
+func TestValidatePullConfig(t *testing.T) {
+	config := confpkg.PullConfig{Sources: []confpkg.PullSource{
+		{Name: "oracle", Position: 1, Type: SourceTypeHttp, Url: "https://oracle.example.com/commitment"},
+		{Name: "backup", Position: 2, Type: SourceTypeFile, Path: "/var/backups/latest", Payload: PayloadDigest},
+		{Name: "node", Position: 3, Type: SourceTypeRpc, RpcUrl: "localhost:18443"}}}
+	assert.Equal(t, nil, ValidatePullConfig(config))
+	assert.Equal(t, nil, ValidatePullConfig(confpkg.PullConfig{}))
+
+	for _, tc := range []struct {
+		sources []confpkg.PullSource
+		err     string
+	}{
+		{[]confpkg.PullSource{{Name: "oracle", Position: -1}}, ErrorSourceInvalid + `: "oracle"`},
+		{[]confpkg.PullSource{{Name: "", Position: 1}}, ErrorSourceInvalid + `: ""`},
+		{[]confpkg.PullSource{{Name: "oracle", Position: 1, Type: "ftp"}}, ErrorSourceTypeInvalid + `: oracle: "ftp"`},
+		{[]confpkg.PullSource{{Name: "oracle", Position: 1, Type: SourceTypeHttp, Url: "oracle.example.com"}},
+			ErrorSourceUrlInvalid + ": oracle"},
+		{[]confpkg.PullSource{{Name: "oracle", Position: 1, Type: SourceTypeHttp, Url: "http://oracle", Payload: "raw"}},
+			ErrorSourcePayloadInvalid + ": oracle: raw"},
+		{[]confpkg.PullSource{{Name: "backup", Position: 1, Type: SourceTypeFile}}, ErrorSourcePathMissing + ": backup"},
+		{[]confpkg.PullSource{{Name: "node", Position: 1, Type: SourceTypeRpc}}, ErrorSourceRpcMissing + ": node"},
+		{[]confpkg.PullSource{{Name: "node", Position: 1, Type: SourceTypeRpc, RpcUrl: "localhost:1"},
+			{Name: "node", Position: 2, Type: SourceTypeRpc, RpcUrl: "localhost:2"}}, ErrorSourceDuplicate + ": node:2"},
+		{[]confpkg.PullSource{{Name: "node", Position: 1, Type: SourceTypeRpc, RpcUrl: "localhost:1"},
+			{Name: "backup", Position: 1, Type: SourceTypeFile, Path: "latest"}}, ErrorSourceDuplicate + ": backup:1"},
+	} {
+		validateErr := ValidatePullConfig(confpkg.PullConfig{Sources: tc.sources})
+		if assert.NotNil(t, validateErr, tc.err) {
+			assert.Equal(t, tc.err, validateErr.Error())
+		}
+	}
+}
+
+// Test commitment polled from a client url
+func TestCommitmentSourceHttp(t *testing.T) {
+	var body string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	source, sourceErr := NewCommitmentSource(confpkg.PullSource{
+		Name: "oracle", Position: 1, Type: SourceTypeHttp, Url: server.URL, Token: "token"})
+	assert.Equal(t, nil, sourceErr)
+	assert.Equal(t, SourceTypeHttp, source.Type())
+	expected, _ := chainhash.NewHashFromStr(testSourceHash)
+
+	// hash hex string or json commitment field
+	body = testSourceHash + "\n"
+	commitment, commitmentErr := source.Commitment(context.Background())
+	assert.Equal(t, nil, commitmentErr)
+	assert.Equal(t, *expected, commitment)
+	body = `{"commitment": "` + testSourceHash + `", "position": 1}`
+	commitment, commitmentErr = source.Commitment(context.Background())
+	assert.Equal(t, nil, commitmentErr)
+	assert.Equal(t, *expected, commitment)
+
+	for _, invalid := range []string{"", "{}", "{", testSourceHash[:62], "z" + testSourceHash[1:]} {
+		body = invalid
+		_, commitmentErr = source.Commitment(context.Background())
+		assert.NotNil(t, commitmentErr, invalid)
+	}
+	body = testSourceHash
+	status = http.StatusServiceUnavailable
+	_, commitmentErr = source.Commitment(context.Background())
+	assert.Equal(t, ErrorSourceRequestFailed+": 503 Service Unavailable", commitmentErr.Error())
+
+	// token required by the client url
+	source = NewCommitmentSourceHttp(confpkg.PullSource{Url: server.URL})
+	_, commitmentErr = source.Commitment(context.Background())
+	assert.Equal(t, ErrorSourceRequestFailed+": 401 Unauthorized", commitmentErr.Error())
+
+	// digest of the response body
+	status = http.StatusOK
+	body = "document"
+	source = NewCommitmentSourceHttp(confpkg.PullSource{Url: server.URL, Token: "token", Payload: PayloadDigest})
+	commitment, commitmentErr = source.Commitment(context.Background())
+	assert.Equal(t, nil, commitmentErr)
+	assert.Equal(t, chainhash.Hash(sha256.Sum256([]byte("document"))), commitment)
+	body = ""
+	_, commitmentErr = source.Commitment(context.Background())
+	assert.Equal(t, ErrorSourceCommitmentEmpty, commitmentErr.Error())
+}
+
+// Test commitment of a watched file
+func TestCommitmentSourceFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backup")
+	source, sourceErr := NewCommitmentSource(confpkg.PullSource{
+		Name: "backup", Position: 1, Type: SourceTypeFile, Path: path})
+	assert.Equal(t, nil, sourceErr)
+	assert.Equal(t, SourceTypeFile, source.Type())
+
+	_, commitmentErr := source.Commitment(context.Background())
+	assert.True(t, os.IsNotExist(commitmentErr))
+
+	// digest of the file contents, read again once changed
+	assert.Equal(t, nil, os.WriteFile(path, []byte("backup 1"), 0600))
+	commitment, commitmentErr := source.Commitment(context.Background())
+	assert.Equal(t, nil, commitmentErr)
+	assert.Equal(t, chainhash.Hash(sha256.Sum256([]byte("backup 1"))), commitment)
+
+	assert.Equal(t, nil, os.WriteFile(path, []byte("backup 22"), 0600))
+	commitment, commitmentErr = source.Commitment(context.Background())
+	assert.Equal(t, nil, commitmentErr)
+	assert.Equal(t, chainhash.Hash(sha256.Sum256([]byte("backup 22"))), commitment)
+
+	// unchanged file not read again
+	fileSource := source.(*CommitmentSourceFile)
+	cached := chainhash.Hash{1}
+	fileSource.commitment = &cached
+	commitment, _ = source.Commitment(context.Background())
+	assert.Equal(t, cached, commitment)
+	modTime := fileSource.modTime.Add(time.Second)
+	assert.Equal(t, nil, os.Chtimes(path, modTime, modTime))
+	commitment, _ = source.Commitment(context.Background())
+	assert.Equal(t, chainhash.Hash(sha256.Sum256([]byte("backup 22"))), commitment)
+
+	// hash hex string in the file
+	source = NewCommitmentSourceFile(confpkg.PullSource{Path: path, Payload: PayloadHash})
+	_, commitmentErr = source.Commitment(context.Background())
+	assert.Equal(t, ErrorSourceCommitmentInvalid, commitmentErr.Error())
+	assert.Equal(t, nil, os.WriteFile(path, []byte(testSourceHash+"\n"), 0600))
+	commitment, commitmentErr = source.Commitment(context.Background())
+	assert.Equal(t, nil, commitmentErr)
+	expected, _ := chainhash.NewHashFromStr(testSourceHash)
+	assert.Equal(t, *expected, commitment)
+}
+
+// Test commitment returned by a json-rpc method
+func TestCommitmentSourceRpc(t *testing.T) {
+	var methods []string
+	result := `"` + testSourceHash + `"`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Id     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		methods = append(methods, request.Method)
+		fmt.Fprintf(w, `{"result": %s, "error": null, "id": %s}`, result, request.Id)
+	}))
+	defer server.Close()
+
+	source, sourceErr := NewCommitmentSource(confpkg.PullSource{Name: "node", Position: 1, Type: SourceTypeRpc,
+		RpcUrl: strings.TrimPrefix(server.URL, "http://"), RpcUser: "user", RpcPass: "pass"})
+	assert.Equal(t, nil, sourceErr)
+	defer source.Close()
+	assert.Equal(t, SourceTypeRpc, source.Type())
+
+	commitment, commitmentErr := source.Commitment(context.Background())
+	assert.Equal(t, nil, commitmentErr)
+	expected, _ := chainhash.NewHashFromStr(testSourceHash)
+	assert.Equal(t, *expected, commitment)
+
+	result = `{"hash": "` + testSourceHash + `"}`
+	_, commitmentErr = source.Commitment(context.Background())
+	assert.Equal(t, ErrorSourceCommitmentInvalid, commitmentErr.Error())
+
+	// configured rpc method called
+	result = `"` + testSourceHash + `"`
+	methodSource, _ := NewCommitmentSourceRpc(confpkg.PullSource{
+		RpcUrl: strings.TrimPrefix(server.URL, "http://"), RpcUser: "user", RpcPass: "pass", Method: "getcommitment"})
+	defer methodSource.Close()
+	_, commitmentErr = methodSource.Commitment(context.Background())
+	assert.Equal(t, nil, commitmentErr)
+	assert.Equal(t, []string{DefaultSourceRpcMethod, DefaultSourceRpcMethod, "getcommitment"}, methods)
+
+	// url scheme sets tls of the rpc client
+	httpSource, _ := NewCommitmentSourceRpc(confpkg.PullSource{RpcUrl: server.URL, RpcUser: "user", RpcPass: "pass"})
+	defer httpSource.Close()
+	_, commitmentErr = httpSource.Commitment(context.Background())
+	assert.Equal(t, nil, commitmentErr)
+
+	httpsSource, _ := NewCommitmentSourceRpc(confpkg.PullSource{
+		RpcUrl: strings.Replace(server.URL, "http://", "https://", 1), RpcUser: "user", RpcPass: "pass"})
+	defer httpsSource.Close()
+	_, commitmentErr = httpsSource.Commitment(context.Background())
+	assert.NotEqual(t, nil, commitmentErr)
+	assert.Equal(t, 4, len(methods))
+}
+
+// Test json-rpc commitment request stopped by the context deadline
+func TestCommitmentSourceRpcTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	source, _ := NewCommitmentSourceRpc(confpkg.PullSource{RpcUrl: server.URL})
+	defer source.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, commitmentErr := source.Commitment(ctx)
+	assert.Equal(t, ErrorSourceRequestFailed+": "+context.DeadlineExceeded.Error(), commitmentErr.Error())
+}
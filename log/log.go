@@ -5,66 +5,314 @@
 package log
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
 /*
-    Extended logging functionality providing Info, Warn and Error messages for
-	log entries.
-	Functions Infof(), Warnf(), Errorf() handles parameters in the same
-	way that fmt.Printf handles parameters.
+    Extended logging functionality providing Debug, Info, Warn and Error
+	messages for log entries.
+	Functions Debugf(), Infof(), Warnf(), Errorf() handles parameters in the
+	same way that fmt.Printf handles parameters.
 	Info(), Warn(), Error() take individual variables as parameters - useful
 	for	printing of simple error message constants
+	WithFields() returns an Entry that adds structured fields, e.g. the
+	attestation state or txid, to each message logged through it
+	Entries below the configured level are dropped and entries are written
+	either as text lines or as json objects
 */
 
-var infoLogger = log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
-var warnLogger = log.New(os.Stdout, "WARN: ", log.Ldate|log.Ltime|log.Lshortfile)
-var errorLogger = log.New(os.Stderr, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
+// log level type
+type Level int
+
+// log levels
+const (
+	LevelDebug Level = 0
+	LevelInfo  Level = 1
+	LevelWarn  Level = 2
+	LevelError Level = 3
+)
+
+// log level names
+var levelNames = map[Level]string{
+	LevelDebug: "DEBUG",
+	LevelInfo:  "INFO",
+	LevelWarn:  "WARN",
+	LevelError: "ERROR",
+}
+
+// log formats
+const (
+	FormatText = "text"
+	FormatJson = "json"
+)
+
+// common field names
+const (
+	FieldState          = "state"
+	FieldTxid           = "txid"
+	FieldCommitment     = "commitment"
+	FieldClientPosition = "client_position"
+	FieldRequestId      = "request_id"
+	FieldRequestIds     = "request_ids"
+	FieldError          = "error"
+	FieldErrorClass     = "error_class"
+	FieldCollection     = "collection"
+	FieldChannel        = "channel"
+	FieldNode           = "node"
+	FieldRotation       = "rotation"
+	FieldTopic          = "topic"
+	FieldBlockhash      = "blockhash"
+	FieldSource         = "source"
+)
+
+// error consts
+const (
+	ErrorLevelInvalid  = "Invalid log level"
+	ErrorFormatInvalid = "Invalid log format"
+)
+
+// Fields type
+// Structured fields added to log entries
+type Fields map[string]interface{}
+
+var (
+	mu        sync.Mutex
+	minLevel  = LevelInfo
+	logFormat = FormatText
+
+	infoLogger  = log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
+	warnLogger  = log.New(os.Stdout, "WARN: ", log.Ldate|log.Ltime|log.Lshortfile)
+	errorLogger = log.New(os.Stderr, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
+	debugLogger = log.New(os.Stdout, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+)
+
+// Return log level from level name, case insensitive
+func ParseLevel(name string) (Level, error) {
+	for level, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return level, nil
+		}
+	}
+	return LevelInfo, errors.New(fmt.Sprintf("%s: %s", ErrorLevelInvalid, name))
+}
+
+// Set minimum level of log entries written
+func SetLevel(level Level) {
+	mu.Lock()
+	defer mu.Unlock()
+	minLevel = level
+}
+
+// Set format of log entries, either FormatText or FormatJson
+func SetFormat(format string) error {
+	if format != FormatText && format != FormatJson {
+		return errors.New(fmt.Sprintf("%s: %s", ErrorFormatInvalid, format))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	logFormat = format
+	return nil
+}
+
+// Set output of log entries of all levels
+// By default errors are written to stderr and all other levels to stdout
+func SetOutput(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, logger := range []*log.Logger{debugLogger, infoLogger, warnLogger, errorLogger} {
+		logger.SetOutput(w)
+	}
+}
+
+// Entry struct
+// Log entry with structured fields added to each message
+type Entry struct {
+	fields Fields
+}
+
+// Return new Entry with fields
+func WithFields(fields Fields) *Entry {
+	return (&Entry{}).WithFields(fields)
+}
+
+// Return new Entry with fields added to the entry fields
+func (e *Entry) WithFields(fields Fields) *Entry {
+	merged := make(Fields, len(e.fields)+len(fields))
+	for key, value := range e.fields {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+	return &Entry{merged}
+}
+
+// Write log entry of level, with the caller of the exported log function
+func (e *Entry) output(level Level, msg string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if level < minLevel {
+		return
+	}
+	msg = strings.TrimSuffix(msg, "\n")
+
+	logger := loggerForLevel(level)
+	if logFormat == FormatJson {
+		_, file, line, _ := runtime.Caller(2)
+		jsonEntry := make(map[string]interface{}, len(e.fields)+4)
+		for key, value := range e.fields {
+			jsonEntry[key] = jsonValue(value)
+		}
+		jsonEntry["time"] = time.Now().UTC().Format(time.RFC3339)
+		jsonEntry["level"] = levelNames[level]
+		jsonEntry["caller"] = fmt.Sprintf("%s:%d", filepath.Base(file), line)
+		jsonEntry["msg"] = msg
+		jsonBytes, _ := json.Marshal(jsonEntry)
+		logger.Writer().Write(append(jsonBytes, '\n'))
+		return
+	}
+	logger.Output(3, msg+textFields(e.fields))
+}
+
+// Return logger writing entries of level
+func loggerForLevel(level Level) *log.Logger {
+	switch level {
+	case LevelDebug:
+		return debugLogger
+	case LevelWarn:
+		return warnLogger
+	case LevelError:
+		return errorLogger
+	}
+	return infoLogger
+}
+
+// Return fields as space separated key=value pairs sorted by key
+func textFields(fields Fields) string {
+	var keys []string
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var text strings.Builder
+	for _, key := range keys {
+		text.WriteString(fmt.Sprintf(" %s=%v", key, fields[key]))
+	}
+	return text.String()
+}
+
+// Return value encodable as json, using the string value of errors and
+// of types implementing fmt.Stringer
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return value
+}
+
+// root entry without fields used by package log functions
+var std = &Entry{}
+
+// Debug log entries are only written if the debug level is set
+// DEBUG print with formatting
+func Debugf(format string, v ...interface{}) {
+	std.output(LevelDebug, fmt.Sprintf(format, v...))
+}
+
+// DEBUG print with formatting and entry fields
+func (e *Entry) Debugf(format string, v ...interface{}) {
+	e.output(LevelDebug, fmt.Sprintf(format, v...))
+}
 
 // Info log entries print a message only, use for standard message output
 // standard INFO print
 func Info(v ...interface{}) {
-	infoLogger.Output(2, fmt.Sprint(v...))
+	std.output(LevelInfo, fmt.Sprint(v...))
 }
 
 // INFO print with formatting
 func Infof(format string, v ...interface{}) {
-	infoLogger.Output(2, fmt.Sprintf(format, v...))
+	std.output(LevelInfo, fmt.Sprintf(format, v...))
 }
 
 // standard INFO print with new line
 func Infoln(v ...interface{}) {
-	infoLogger.Output(2, fmt.Sprintln(v...))
+	std.output(LevelInfo, fmt.Sprintln(v...))
+}
+
+// INFO print with formatting and entry fields
+func (e *Entry) Infof(format string, v ...interface{}) {
+	e.output(LevelInfo, fmt.Sprintf(format, v...))
+}
+
+// INFO print with new line and entry fields
+func (e *Entry) Infoln(v ...interface{}) {
+	e.output(LevelInfo, fmt.Sprintln(v...))
 }
 
 // Warn log entries print a message only but wiht WARN marker, use for non-fatal
 // errors
 // standard WARN print
 func Warn(v ...interface{}) {
-	warnLogger.Output(2, fmt.Sprint(v...))
+	std.output(LevelWarn, fmt.Sprint(v...))
 }
 
 // WARN print with formatting
 func Warnf(format string, v ...interface{}) {
-	warnLogger.Output(2, fmt.Sprintf(format, v...))
+	std.output(LevelWarn, fmt.Sprintf(format, v...))
 }
 
 // standard WARN print with new line
 func Warnln(v ...interface{}) {
-	warnLogger.Output(2, fmt.Sprintln(v...))
+	std.output(LevelWarn, fmt.Sprintln(v...))
+}
+
+// WARN print with formatting and entry fields
+func (e *Entry) Warnf(format string, v ...interface{}) {
+	e.output(LevelWarn, fmt.Sprintf(format, v...))
+}
+
+// WARN print with new line and entry fields
+func (e *Entry) Warnln(v ...interface{}) {
+	e.output(LevelWarn, fmt.Sprintln(v...))
 }
 
 // Error log entries print a message then halt execution
 // standard ERROR print.
 func Error(v ...interface{}) {
-	errorLogger.Output(2, fmt.Sprint(v...))
+	std.output(LevelError, fmt.Sprint(v...))
 	os.Exit(1)
 }
 
 // ERROR print with formatting
 func Errorf(format string, v ...interface{}) {
-	errorLogger.Output(2, fmt.Sprintf(format, v...))
+	std.output(LevelError, fmt.Sprintf(format, v...))
 	os.Exit(1)
 }
+
+// ERROR print with formatting and entry fields
+func (e *Entry) Errorf(format string, v ...interface{}) {
+	e.output(LevelError, fmt.Sprintf(format, v...))
+	os.Exit(1)
+}
+
+// ERROR print then halt execution with exit code
+func ErrorExit(code int, v ...interface{}) {
+	std.output(LevelError, fmt.Sprint(v...))
+	os.Exit(code)
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test log levels, structured fields and formats
+func TestLogFields(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	defer func() {
+		SetOutput(os.Stdout)
+		SetLevel(LevelInfo)
+		SetFormat(FormatText)
+	}()
+
+	// text format with sorted fields
+	entry := WithFields(Fields{FieldTxid: "abc", FieldState: 3})
+	entry.Infoln("attestation committed")
+	line := buf.String()
+	assert.Equal(t, true, strings.HasPrefix(line, "INFO: "))
+	assert.Equal(t, true, strings.Contains(line, "log_test.go:"))
+	assert.Equal(t, true, strings.HasSuffix(line, " attestation committed state=3 txid=abc\n"))
+
+	// entries below level dropped
+	buf.Reset()
+	Debugf("hidden %d", 1)
+	assert.Equal(t, "", buf.String())
+	SetLevel(LevelDebug)
+	Debugf("shown %d", 1)
+	assert.Equal(t, true, strings.HasPrefix(buf.String(), "DEBUG: "))
+	SetLevel(LevelWarn)
+	buf.Reset()
+	entry.Infoln("hidden")
+	assert.Equal(t, "", buf.String())
+
+	// json format with merged fields
+	assert.NotEqual(t, nil, SetFormat("xml"))
+	assert.Equal(t, nil, SetFormat(FormatJson))
+	entry.WithFields(Fields{FieldTxid: "def", FieldClientPosition: 1}).Warnf("%s\n", "fee bump failed")
+	var jsonEntry map[string]interface{}
+	assert.Equal(t, nil, json.Unmarshal(buf.Bytes(), &jsonEntry))
+	assert.Equal(t, "WARN", jsonEntry["level"])
+	assert.Equal(t, "fee bump failed", jsonEntry["msg"])
+	assert.Equal(t, "def", jsonEntry[FieldTxid])
+	assert.Equal(t, float64(3), jsonEntry[FieldState])
+	assert.Equal(t, float64(1), jsonEntry[FieldClientPosition])
+	assert.Equal(t, true, strings.HasPrefix(jsonEntry["caller"].(string), "log_test.go:"))
+
+	// parent entry fields unchanged
+	assert.Equal(t, Fields{FieldTxid: "abc", FieldState: 3}, entry.fields)
+}
+
+// Test log level names
+func TestParseLevel(t *testing.T) {
+	level, levelErr := ParseLevel("debug")
+	assert.Equal(t, nil, levelErr)
+	assert.Equal(t, LevelDebug, level)
+	level, levelErr = ParseLevel("WARN")
+	assert.Equal(t, nil, levelErr)
+	assert.Equal(t, LevelWarn, level)
+	_, levelErr = ParseLevel("verbose")
+	assert.Equal(t, ErrorLevelInvalid+": verbose", levelErr.Error())
+}
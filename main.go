@@ -8,16 +8,26 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"os"
 	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
 
-	"mainstay/attestation"
 	"mainstay/config"
-	"mainstay/db"
+	"mainstay/daemon"
 	"mainstay/log"
+	"mainstay/service"
 	"mainstay/test"
+	"mainstay/tracing"
+)
+
+// process exit codes, distinguishing config errors that a restart
+// does not fix from runtime failures
+const (
+	exitCodeRuntime = 1
+	exitCodeConfig  = 78 // EX_CONFIG of sysexits.h
 )
 
 var (
@@ -27,11 +37,16 @@ var (
 	addrTopup   string
 	scriptTopup string
 	isRegtest   bool
+	isDryRun    bool
 	mainConfig  *config.Config
+
+	// path of config to validate with the config validate command
+	validateConfPath string
 )
 
 func parseFlags() {
 	flag.BoolVar(&isRegtest, "regtest", false, "Use regtest wallet configuration instead of user wallet")
+	flag.BoolVar(&isDryRun, "dryRun", false, "Run attestation service without sending attestation transactions")
 	flag.StringVar(&tx0, "tx", "", "Tx id for genesis attestation transaction")
 	flag.StringVar(&script0, "script", "", "Redeem script in case multisig is used")
 	flag.StringVar(&chaincodes, "chaincodes", "", "Chaincodes for multisig pubkeys")
@@ -43,6 +58,15 @@ func parseFlags() {
 func init() {
 	parseFlags()
 
+	// config validate command - mainstay config validate [path]
+	if flag.NArg() >= 2 && flag.Arg(0) == "config" && flag.Arg(1) == "validate" {
+		validateConfPath = config.GetDefaultConfPath()
+		if flag.NArg() > 2 {
+			validateConfPath = flag.Arg(2)
+		}
+		return
+	}
+
 	if isRegtest {
 		test := test.NewTest(true, true)
 		mainConfig = test.Config
@@ -51,14 +75,14 @@ func init() {
 		var mainConfigErr error
 		mainConfig, mainConfigErr = config.NewConfig()
 		if mainConfigErr != nil {
-			log.Error(mainConfigErr)
+			log.ErrorExit(exitCodeConfig, mainConfigErr)
 		}
 
 		// if either tx or script not set throw error
 		if tx0 == "" || script0 == "" || chaincodes == "" {
 			if mainConfig.InitTx() == "" || mainConfig.InitScript() == "" || len(mainConfig.InitChaincodes()) == 0 {
 				flag.PrintDefaults()
-				log.Error(`Need to provide all -tx, -script and -chaincode arguments.
+				log.ErrorExit(exitCodeConfig, `Need to provide all -tx, -script and -chaincode arguments.
                     To use test configuration set the -regtest flag.`)
 			}
 		} else {
@@ -77,21 +101,109 @@ func init() {
 		}
 		mainConfig.SetRegtest(isRegtest)
 	}
+	if isDryRun {
+		mainConfig.SetDryRun(true)
+	}
+	configureLog(mainConfig.LogConfig())
+}
+
+// Set log level, format and output from log config
+// Invalid values are reported and replaced by defaults
+func configureLog(logConfig config.LogConfig) {
+	if logConfig.Level != "" {
+		level, levelErr := log.ParseLevel(logConfig.Level)
+		if levelErr != nil {
+			log.Warnln(levelErr)
+		} else {
+			log.SetLevel(level)
+		}
+	}
+	if logConfig.Format != "" {
+		if formatErr := log.SetFormat(logConfig.Format); formatErr != nil {
+			log.Warnln(formatErr)
+		}
+	}
+	switch logConfig.Output {
+	case "":
+	case "stdout":
+		log.SetOutput(os.Stdout)
+	case "stderr":
+		log.SetOutput(os.Stderr)
+	default:
+		logFile, logFileErr := os.OpenFile(logConfig.Output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if logFileErr != nil {
+			log.Error(logFileErr)
+		}
+		log.SetOutput(logFile)
+	}
+}
+
+// Validate config file, print all issues found and return exit code
+func validateConfig(confPath string) int {
+	conf, confErr := config.GetConfFile(confPath)
+	if confErr != nil {
+		fmt.Println(confErr)
+		return exitCodeConfig
+	}
+
+	validation := service.ValidateConfig(context.Background(), conf)
+	for _, issue := range validation.Issues {
+		fmt.Println(issue)
+	}
+	if validation.HasErrors() {
+		fmt.Printf("config %s invalid - %d issues found\n", confPath, len(validation.Issues))
+		return exitCodeConfig
+	}
+	fmt.Printf("config %s valid - %d issues found\n", confPath, len(validation.Issues))
+	return 0
+}
+
+// Reload config file, reporting any config issues found, and
+// apply reloadable timing, fee and signer config to mainstay
+func reloadConfig(mainstay *service.Mainstay) error {
+	conf, confErr := config.GetConfFile(config.GetDefaultConfPath())
+	if confErr != nil {
+		return confErr
+	}
+	for _, issue := range service.ValidateConfigParams(conf).Issues {
+		log.Warnln(issue)
+	}
+	return mainstay.Reload(conf)
 }
 
 func main() {
+	if validateConfPath != "" {
+		os.Exit(validateConfig(validateConfPath))
+	}
+
 	defer mainConfig.MainClient().Shutdown()
 
+	shutdownTracing, tracingErr := tracing.Init(mainConfig.TracingConfig())
+	if tracingErr != nil {
+		log.Warnln(tracingErr)
+	} else {
+		defer shutdownTracing(context.Background())
+	}
+
+	// pidfile is left behind on failures, to be replaced on the next start
+	if pidFile := mainConfig.DaemonConfig().PidFile; pidFile != "" {
+		if pidErr := daemon.WritePidFile(pidFile); pidErr != nil {
+			log.ErrorExit(exitCodeRuntime, pidErr)
+		}
+		defer daemon.RemovePidFile(pidFile)
+	}
+
 	wg := &sync.WaitGroup{}
 	ctx, cancel := context.WithCancel(context.Background())
 
-	dbInterface := db.NewDbMongo(ctx, mainConfig.DbConfig())
-	server := attestation.NewAttestServer(dbInterface)
-	signer := attestation.NewAttestSignerHttp(mainConfig.SignerConfig())
-	attestService := attestation.NewAttestService(ctx, wg, server, signer, mainConfig)
+	mainstay, mainstayErr := service.NewMainstay(mainConfig,
+		service.WithContext(ctx), service.WithWaitGroup(wg))
+	if mainstayErr != nil {
+		log.ErrorExit(exitCodeRuntime, mainstayErr)
+	}
 
 	c := make(chan os.Signal)
-	signal.Notify(c, os.Interrupt)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
 	wg.Add(1)
 	go func() {
@@ -100,20 +212,58 @@ func main() {
 		select {
 		case sig := <-c:
 			log.Warnf("Got %s signal. Aborting...\n", sig)
+			daemon.NotifyOrWarn(daemon.NotifyStopping)
 		case <-ctx.Done():
 			signal.Stop(c)
 		}
 	}()
 
+	// trigger out of schedule attestations on SIGUSR1
+	// and reload timing, fee and signer config on SIGHUP
+	attestNow := make(chan os.Signal, 1)
+	signal.Notify(attestNow, syscall.SIGUSR1)
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+
 	wg.Add(1)
-	go attestService.Run()
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-attestNow:
+				log.Infoln("Got SIGUSR1 signal. Triggering attestation...")
+				mainstay.AttestNow()
+			case <-reload:
+				log.Infoln("Got SIGHUP signal. Reloading config...")
+				daemon.NotifyOrWarn(daemon.NotifyReloading)
+				if reloadErr := reloadConfig(mainstay); reloadErr != nil {
+					log.Warnln(reloadErr)
+				}
+				daemon.NotifyOrWarn(daemon.NotifyReady)
+			case <-ctx.Done():
+				signal.Stop(attestNow)
+				signal.Stop(reload)
+				return
+			}
+		}
+	}()
+
+	mainstay.Start()
+
+	// report readiness to systemd and keep its watchdog, if any, from
+	// restarting mainstay while the attestation service is live
+	daemon.NotifyOrWarn(daemon.NotifyReady)
+	if watchdog := daemon.NewWatchdog(ctx, wg, func() bool { return mainstay.Liveness().Healthy }); watchdog != nil {
+		wg.Add(1)
+		go watchdog.Run()
+	}
 
 	// In regtest demo mode do block generation work
 	// Also auto commitment to ClientCommitment to
 	// allow easier testing without db intervention
 	if isRegtest {
 		wg.Add(1)
-		go test.DoRegtestWork(dbInterface, mainConfig, wg, ctx)
+		go test.DoRegtestWork(mainstay.Db(), mainConfig, wg, ctx)
 	}
 	wg.Wait()
 }
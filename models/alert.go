@@ -0,0 +1,18 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+// struct for Alert
+// Attestation service failure alert with the error class, the state that failed, the txid
+// of the current attestation and the number of identical alerts suppressed
+// since the alert was last sent
+type Alert struct {
+	Error      string `json:"error"`
+	Class      string `json:"class"`
+	PrevState  string `json:"prev_state"`
+	Txid       string `json:"txid"`
+	Suppressed int    `json:"suppressed"`
+	Time       int64  `json:"time"`
+}
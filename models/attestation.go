@@ -6,11 +6,13 @@ package models
 
 import (
 	"errors"
+	"math"
 	"time"
 
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
@@ -41,17 +43,22 @@ func NewAttestationDefault() *Attestation {
 	return &Attestation{chainhash.Hash{}, wire.MsgTx{}, false, AttestationInfo{}, (*Commitment)(nil)}
 }
 
-// Update info with details from wallet transaction
-func (a *Attestation) UpdateInfo(tx *btcjson.GetTransactionResult) {
+// Update info with details from wallet transaction and height of the block confirming it
+// The wallet reports the fee paid by sent transactions as a negative amount
+func (a *Attestation) UpdateInfo(tx *btcjson.GetTransactionResult, height int64) {
 	amount := int64(0)
 	if len(a.Tx.TxOut) > 0 {
 		amount = a.Tx.TxOut[0].Value
 	}
+	fee, _ := btcutil.NewAmount(math.Abs(tx.Fee))
 	a.Info = AttestationInfo{
 		Txid:      a.Txid.String(),
 		Blockhash: tx.BlockHash,
 		Amount:    amount,
 		Time:      tx.Time,
+		Height:    height,
+		BlockTime: tx.BlockTime,
+		Fee:       int64(fee),
 	}
 }
 
@@ -78,12 +85,7 @@ func (a Attestation) CommitmentHash() chainhash.Hash {
 
 // Implement bson.Marshaler MarshalBSON() method for use with db_mongo interface
 func (a Attestation) MarshalBSON() ([]byte, error) {
-	attestationTime := time.Now()
-	if a.Info.Time != 0 { // check if tx time set
-		attestationTime = time.Unix(a.Info.Time, 0)
-	}
-	attestationBSON := AttestationBSON{a.Txid.String(), a.CommitmentHash().String(), a.Confirmed, attestationTime}
-	return bson.Marshal(attestationBSON)
+	return bson.Marshal(NewAttestationBSON(a))
 }
 
 // Implement bson.Unmarshaler UnmarshalJSON() method for use with db_mongo interface
@@ -98,6 +100,15 @@ func (a *Attestation) UnmarshalBSON(b []byte) error {
 	}
 	a.Txid = *txidHash
 	a.Confirmed = attestationBSON.Confirmed
+	a.Info = AttestationInfo{
+		Txid:      attestationBSON.Txid,
+		Blockhash: attestationBSON.BlockHash,
+		Height:    attestationBSON.BlockHeight,
+		Fee:       attestationBSON.Fee,
+	}
+	if attestationBSON.ConfirmedAt != nil {
+		a.Info.BlockTime = attestationBSON.ConfirmedAt.Unix()
+	}
 	// THIS IS INCOMPLETE
 	// in order to get a full Attestation model
 	// we still need to Umarshal the commitment
@@ -107,16 +118,50 @@ func (a *Attestation) UnmarshalBSON(b []byte) error {
 
 // Attestation field names
 const (
-	AttestationTxidName       = "txid"
-	AttestationMerkleRootName = "merkle_root"
-	AttestationConfirmedName  = "confirmed"
-	AttestationInsertedAtName = "inserted_at"
+	AttestationTxidName        = "txid"
+	AttestationMerkleRootName  = "merkle_root"
+	AttestationConfirmedName   = "confirmed"
+	AttestationInsertedAtName  = "inserted_at"
+	AttestationBlockHeightName = "block_height"
+	AttestationBlockHashName   = "block_hash"
+	AttestationConfirmedAtName = "confirmed_at"
+	AttestationFeeName         = "fee"
 )
 
 // AttestationBSON structure for mongoDb
+// The block confirming the attestation and the fee paid
+// are set for confirmed attestations only
 type AttestationBSON struct {
-	Txid       string    `bson:"txid"`
-	MerkleRoot string    `bson:"merkle_root"`
-	Confirmed  bool      `bson:"confirmed"`
-	InsertedAt time.Time `bson:"inserted_at"`
+	Txid        string     `bson:"txid"`
+	MerkleRoot  string     `bson:"merkle_root"`
+	Confirmed   bool       `bson:"confirmed"`
+	InsertedAt  time.Time  `bson:"inserted_at"`
+	BlockHeight int64      `bson:"block_height"`
+	BlockHash   string     `bson:"block_hash"`
+	ConfirmedAt *time.Time `bson:"confirmed_at"`
+	Fee         int64      `bson:"fee"`
+}
+
+// Return AttestationBSON document of attestation
+func NewAttestationBSON(a Attestation) AttestationBSON {
+	attestationTime := time.Now()
+	if a.Info.Time != 0 { // check if tx time set
+		attestationTime = time.Unix(a.Info.Time, 0)
+	}
+	attestationBSON := AttestationBSON{
+		Txid:       a.Txid.String(),
+		MerkleRoot: a.CommitmentHash().String(),
+		Confirmed:  a.Confirmed,
+		InsertedAt: attestationTime,
+	}
+	if a.Confirmed && a.Info.Blockhash != "" {
+		attestationBSON.BlockHeight = a.Info.Height
+		attestationBSON.BlockHash = a.Info.Blockhash
+		attestationBSON.Fee = a.Info.Fee
+		if a.Info.BlockTime != 0 {
+			confirmedAt := time.Unix(a.Info.BlockTime, 0)
+			attestationBSON.ConfirmedAt = &confirmedAt
+		}
+	}
+	return attestationBSON
 }
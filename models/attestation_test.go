@@ -11,6 +11,7 @@ import (
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 // Test Attestation high level interface
@@ -54,15 +55,20 @@ func TestAttestation(t *testing.T) {
 	// test attestation info
 	txRes := btcjson.GetTransactionResult{
 		BlockHash: "abcde34e881d9a1e6cdc3418b54bb57747106bc75e9e84426661f27f98ada3b7",
+		BlockTime: int64(1542121400),
+		Fee:       -0.00001234,
 		Time:      int64(1542121293),
 		TxID:      "4444e34e881d9a1e6cdc3418b54bb57747106bc75e9e84426661f27f98ada3b7"}
-	attestation.UpdateInfo(&txRes)
+	attestation.UpdateInfo(&txRes, 1000)
 	attestation.Info.Amount = int64(1)
 	assert.Equal(t, AttestationInfo{
 		Txid:      "4444e34e881d9a1e6cdc3418b54bb57747106bc75e9e84426661f27f98ada3b7",
 		Blockhash: "abcde34e881d9a1e6cdc3418b54bb57747106bc75e9e84426661f27f98ada3b7",
 		Amount:    int64(1),
-		Time:      int64(1542121293)}, attestation.Info)
+		Time:      int64(1542121293),
+		Height:    int64(1000),
+		BlockTime: int64(1542121400),
+		Fee:       int64(1234)}, attestation.Info)
 }
 
 // Test Attestation BSON interface
@@ -85,7 +91,7 @@ func TestAttestationBSON(t *testing.T) {
 	bytes, errBytes := attestation.MarshalBSON()
 	// can't test bytes exactly as there is a time component
 	// we do test the reverse though below
-	assert.Equal(t, 195, len(bytes))
+	assert.Equal(t, 261, len(bytes))
 	assert.Equal(t, nil, errBytes)
 
 	// test unmarshal attestaion model and verify reverse works
@@ -106,4 +112,26 @@ func TestAttestationBSON(t *testing.T) {
 	assert.Equal(t, nil, docErr)
 	assert.Equal(t, attestation.Txid, testtestCommitment.Txid)
 	assert.Equal(t, attestation.Confirmed, testtestCommitment.Confirmed)
+
+	// test block of confirmed attestation stored and restored
+	attestation.Confirmed = true
+	attestation.Info = AttestationInfo{
+		Txid:      txid.String(),
+		Blockhash: "abcde34e881d9a1e6cdc3418b54bb57747106bc75e9e84426661f27f98ada3b7",
+		Height:    int64(1000),
+		BlockTime: int64(1542121400),
+		Fee:       int64(1234)}
+	bytes, errBytes = attestation.MarshalBSON()
+	assert.Equal(t, nil, errBytes)
+	testAttestation = &Attestation{}
+	assert.Equal(t, nil, testAttestation.UnmarshalBSON(bytes))
+	assert.Equal(t, true, testAttestation.Confirmed)
+	assert.Equal(t, attestation.Info, testAttestation.Info)
+
+	var attestationBSON AttestationBSON
+	assert.Equal(t, nil, bson.Unmarshal(bytes, &attestationBSON))
+	assert.Equal(t, int64(1000), attestationBSON.BlockHeight)
+	assert.Equal(t, attestation.Info.Blockhash, attestationBSON.BlockHash)
+	assert.Equal(t, int64(1542121400), attestationBSON.ConfirmedAt.Unix())
+	assert.Equal(t, int64(1234), attestationBSON.Fee)
 }
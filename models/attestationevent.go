@@ -0,0 +1,24 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+// attestation event types
+const (
+	AttestationEventBroadcast = "attestation.broadcast"
+	AttestationEventConfirmed = "attestation.confirmed"
+	AttestationEventFeeBumped = "attestation.fee_bumped"
+	AttestationEventReorged   = "attestation.reorged"
+)
+
+// struct for AttestationEvent
+// Attestation lifecycle event sent to webhook urls
+type AttestationEvent struct {
+	Event      string `json:"event"`
+	Txid       string `json:"txid"`
+	Commitment string `json:"commitment"`
+	Blockhash  string `json:"blockhash,omitempty"`
+	Fee        int    `json:"fee,omitempty"`
+	Time       int64  `json:"time"`
+}
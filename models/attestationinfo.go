@@ -10,6 +10,9 @@ type AttestationInfo struct {
 	Blockhash string `bson:"blockhash"`
 	Amount    int64  `bson:"amount"`
 	Time      int64  `bson:"time"`
+	Height    int64  `bson:"height"`
+	BlockTime int64  `bson:"blocktime"`
+	Fee       int64  `bson:"fee"`
 }
 
 // AttestationInfo field names
@@ -18,4 +21,7 @@ const (
 	AttestationInfoBlockhashName = "blockhash"
 	AttestationInfoAmountName    = "amount"
 	AttestationInfoTimeName      = "time"
+	AttestationInfoHeightName    = "height"
+	AttestationInfoBlockTimeName = "blocktime"
+	AttestationInfoFeeName       = "fee"
 )
@@ -0,0 +1,18 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+// struct for staychain Balance
+// Value of the latest staychain output and the number of
+// attestations it can pay for at the current fee estimate
+type Balance struct {
+	Value          int64 `json:"value"`
+	FeePerByte     int   `json:"fee_per_byte"`
+	AttestationFee int64 `json:"attestation_fee"`
+	Runway         int64 `json:"runway"`
+	Threshold      int64 `json:"threshold"`
+	Low            bool  `json:"low"`
+	Time           int64 `json:"time"`
+}
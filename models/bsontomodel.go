@@ -5,10 +5,42 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/x/bsonx"
 )
 
+// Checksum field name added to model documents
+const ChecksumName = "checksum"
+
+// Function to get checksum from model interface that implements MarshalBSON
+// The checksum is the hex encoded sha256 of the model bson serialization
+func GetModelChecksum(model interface{}) (string, error) {
+	bytes, marshalErr := bson.Marshal(model)
+	if marshalErr != nil {
+		return "", marshalErr
+	}
+	checksum := sha256.Sum256(bytes)
+	return hex.EncodeToString(checksum[:]), nil
+}
+
+// Function to get bson Document from model interface that implements MarshalBSON
+// with the model checksum appended to the document fields
+func GetDocumentWithChecksumFromModel(model interface{}) (*bsonx.Doc, error) {
+	doc, docErr := GetDocumentFromModel(model)
+	if docErr != nil {
+		return nil, docErr
+	}
+	checksum, checksumErr := GetModelChecksum(model)
+	if checksumErr != nil {
+		return nil, checksumErr
+	}
+	*doc = doc.Append(ChecksumName, bsonx.String(checksum))
+	return doc, nil
+}
+
 // Function to get bson Document from model interface that implements MarshalBSON
 func GetDocumentFromModel(model interface{}) (*bsonx.Doc, error) {
 
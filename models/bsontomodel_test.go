@@ -32,3 +32,30 @@ func TestBsonToModel(t *testing.T) {
 	assert.Equal(t, dummyVal.Name, testDummy.Name)
 	assert.Equal(t, dummyVal.Verified, testDummy.Verified)
 }
+
+// Test BSON Model checksum utils
+func TestBsonToModelChecksum(t *testing.T) {
+	dummyVal := Dummy{"Nick", true}
+
+	checksum, checksumErr := GetModelChecksum(dummyVal)
+	assert.Equal(t, nil, checksumErr)
+	assert.Equal(t, 64, len(checksum))
+
+	// test model to document with checksum
+	doc, docErr := GetDocumentWithChecksumFromModel(dummyVal)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, dummyVal.Name, doc.Lookup("name").StringValue())
+	assert.Equal(t, checksum, doc.Lookup(ChecksumName).StringValue())
+
+	// test document to model ignores checksum and reproduces it
+	testDummy := &Dummy{}
+	docErr = GetModelFromDocument(doc, testDummy)
+	assert.Equal(t, nil, docErr)
+	testChecksum, _ := GetModelChecksum(*testDummy)
+	assert.Equal(t, checksum, testChecksum)
+
+	// test altered model produces different checksum
+	testDummy.Verified = false
+	testChecksum, _ = GetModelChecksum(*testDummy)
+	assert.NotEqual(t, checksum, testChecksum)
+}
@@ -10,14 +10,18 @@ import (
 )
 
 // struct for db ClientCommitment
+// RequestId is the optional id of the api request that set the commitment
+// and SubmittedAt the unix milliseconds time the commitment was received
 type ClientCommitment struct {
 	Commitment     chainhash.Hash
 	ClientPosition int32
+	RequestId      string
+	SubmittedAt    int64
 }
 
 // Implement bson.Marshaler MarshalBSON() method for use with db_mongo interface
 func (c ClientCommitment) MarshalBSON() ([]byte, error) {
-	commitmentBSON := ClientCommitmentBSON{c.Commitment.String(), c.ClientPosition}
+	commitmentBSON := ClientCommitmentBSON{c.Commitment.String(), c.ClientPosition, c.RequestId, c.SubmittedAt}
 	return bson.Marshal(commitmentBSON)
 
 }
@@ -34,6 +38,8 @@ func (c *ClientCommitment) UnmarshalBSON(b []byte) error {
 	}
 	c.ClientPosition = commitmentBSON.ClientPosition
 	c.Commitment = *commitmentHash
+	c.RequestId = commitmentBSON.RequestId
+	c.SubmittedAt = commitmentBSON.SubmittedAt
 	return nil
 }
 
@@ -41,10 +47,14 @@ func (c *ClientCommitment) UnmarshalBSON(b []byte) error {
 const (
 	ClientCommitmentClientPositionName = "client_position"
 	ClientCommitmentCommitmentName     = "commitment"
+	ClientCommitmentRequestIdName      = "request_id"
+	ClientCommitmentSubmittedAtName    = "submitted_at"
 )
 
 // ClientCommitmentBSON structure for mongoDB
 type ClientCommitmentBSON struct {
 	Commitment     string `bson:"commitment"`
 	ClientPosition int32  `bson:"client_position"`
+	RequestId      string `bson:"request_id,omitempty"`
+	SubmittedAt    int64  `bson:"submitted_at,omitempty"`
 }
@@ -14,7 +14,7 @@ import (
 // Test ClientCommitment high level interface
 func TestClientCommitment(t *testing.T) {
 	hash0, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
-	latestCommitment := ClientCommitment{*hash0, int32(5)}
+	latestCommitment := ClientCommitment{*hash0, int32(5), "", 0}
 	assert.Equal(t, *hash0, latestCommitment.Commitment)
 	assert.Equal(t, int32(5), latestCommitment.ClientPosition)
 }
@@ -22,7 +22,7 @@ func TestClientCommitment(t *testing.T) {
 // Test ClientCommitment BSON interface
 func TestClientCommitmentBSON(t *testing.T) {
 	hash0, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
-	latestCommitment := ClientCommitment{*hash0, int32(5)}
+	latestCommitment := ClientCommitment{*hash0, int32(5), "", 0}
 
 	// test marshal latestCommitment model
 	bytes, errBytes := latestCommitment.MarshalBSON()
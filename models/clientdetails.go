@@ -14,6 +14,9 @@ type ClientDetails struct {
 	AuthToken      string `bson:"auth_token"`
 	Pubkey         string `bson:"pubkey"`
 	ClientName     string `bson:"client_name"`
+	HmacSecret     string `bson:"hmac_secret,omitempty"`
+	SlotGroup      bool   `bson:"slot_group,omitempty"`
+	DeliveryUrl    string `bson:"delivery_url,omitempty"`
 }
 
 // ClientDetails field names
@@ -22,4 +25,7 @@ const (
 	ClientDetailsAuthTokenName      = "auth_token"
 	ClientDetailsPubkeyName         = "pubkey"
 	ClientDetailsClientNameName     = "client_name"
+	ClientDetailsHmacSecretName     = "hmac_secret"
+	ClientDetailsSlotGroupName      = "slot_group"
+	ClientDetailsDeliveryUrlName    = "delivery_url"
 )
@@ -13,7 +13,7 @@ import (
 
 // Test ClientDetails high level interface
 func TestClientDetails(t *testing.T) {
-	clientDetails := ClientDetails{0, "04ddb0d6-ed74-4cc6-b9dc-72f2a809525b", "03e52cf15e0a5cf6612314f077bb65cf9a6596b76c0fcb34b682f673a8314c7b33", "CommerceBlock"}
+	clientDetails := ClientDetails{0, "04ddb0d6-ed74-4cc6-b9dc-72f2a809525b", "03e52cf15e0a5cf6612314f077bb65cf9a6596b76c0fcb34b682f673a8314c7b33", "CommerceBlock", "", false, ""}
 	assert.Equal(t, int32(0), clientDetails.ClientPosition)
 	assert.Equal(t, "04ddb0d6-ed74-4cc6-b9dc-72f2a809525b", clientDetails.AuthToken)
 	assert.Equal(t, "03e52cf15e0a5cf6612314f077bb65cf9a6596b76c0fcb34b682f673a8314c7b33", clientDetails.Pubkey)
@@ -22,7 +22,7 @@ func TestClientDetails(t *testing.T) {
 
 // Test ClientDetails BSON interface
 func TestClientDetailsBSON(t *testing.T) {
-	clientDetails := ClientDetails{0, "04ddb0d6-ed74-4cc6-b9dc-72f2a809525b", "03e52cf15e0a5cf6612314f077bb65cf9a6596b76c0fcb34b682f673a8314c7b33", "CommerceBlock"}
+	clientDetails := ClientDetails{0, "04ddb0d6-ed74-4cc6-b9dc-72f2a809525b", "03e52cf15e0a5cf6612314f077bb65cf9a6596b76c0fcb34b682f673a8314c7b33", "CommerceBlock", "", false, ""}
 
 	// test marshal clientDetails model
 	bytes, errBytes := bson.Marshal(clientDetails)
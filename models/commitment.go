@@ -17,49 +17,125 @@ const (
 )
 
 // Commitment structure
+// Optionally keeps the ids of the api requests that set the client commitments
+// and the client position of each leaf, if leaves are not client positions
 type Commitment struct {
-	tree CommitmentMerkleTree
+	tree       CommitmentMerkleTree
+	requestIds map[int32]string
+	positions  []int32
 }
 
 // Return new Commitment instance
 func NewCommitment(commitments []chainhash.Hash) (*Commitment, error) {
+	return NewCommitmentHash(commitments, MerkleHashSha256d)
+}
+
+// Return new Commitment instance with the merkle tree hash function given
+func NewCommitmentHash(commitments []chainhash.Hash, hash string) (*Commitment, error) {
 	// check length
 	if len(commitments) == 0 {
 		return nil, errors.New(ErrorCommitmentListEmpty)
 	}
-	commitmentTree := NewCommitmentMerkleTree(commitments)
-	return &Commitment{commitmentTree}, nil
+	if hashErr := CheckMerkleHash(hash); hashErr != nil {
+		return nil, hashErr
+	}
+	commitmentTree := NewCommitmentMerkleTreeHash(commitments, hash)
+	return &Commitment{commitmentTree, nil, nil}, nil
+}
+
+// Return new Commitment instance of a known merkle root only, e.g. of an
+// attestation recovered from the main chain, without the tree commitments
+func NewCommitmentRoot(root chainhash.Hash) *Commitment {
+	return &Commitment{tree: CommitmentMerkleTree{root: root}}
 }
 
 // Get merkle proofs for Commitment
+// Proofs are of the leaves with a client position, by client position
 func (c Commitment) GetMerkleProofs() []CommitmentMerkleProof {
-	return c.tree.getMerkleProofs()
+	if c.positions == nil {
+		return c.tree.getMerkleProofs()
+	}
+	var proofs []CommitmentMerkleProof
+	for leaf, position := range c.positions {
+		if position < 0 {
+			continue
+		}
+		proof := buildMerkleProof(leaf, c.tree.getMerkleTree())
+		proof.ClientPosition = position
+		proof.Hash = c.tree.getMerkleHash()
+		proofs = append(proofs, proof)
+	}
+	return proofs
 }
 
 // Get merkle commitments for Commitment
+// Commitments are of the leaves with a client position, by client position
 func (c Commitment) GetMerkleCommitments() []CommitmentMerkleCommitment {
 	var commitments []CommitmentMerkleCommitment
-	for pos, commitment := range c.tree.getMerkleCommitments() {
-		commitments = append(commitments, CommitmentMerkleCommitment{c.GetCommitmentHash(), int32(pos), commitment})
+	for leaf, commitment := range c.tree.getMerkleCommitments() {
+		position := int32(leaf)
+		if c.positions != nil {
+			if position = c.positions[leaf]; position < 0 {
+				continue
+			}
+		}
+		commitments = append(commitments, CommitmentMerkleCommitment{c.GetCommitmentHash(), position, commitment,
+			c.requestIds[position], c.tree.getMerkleHash()})
 	}
 	return commitments
 }
 
+// Set client position of each leaf, -1 for leaves with no client position
+// Positions equal to the leaves are not kept
+func (c *Commitment) SetPositions(positions []int32) {
+	c.positions = nil
+	for leaf, position := range positions {
+		if position != int32(leaf) {
+			c.positions = positions
+			break
+		}
+	}
+}
+
+// Get client position of each leaf, or nil if leaves are client positions
+func (c Commitment) Positions() []int32 {
+	return c.positions
+}
+
+// Set request ids of client commitments by client position
+func (c *Commitment) SetRequestIds(requestIds map[int32]string) {
+	c.requestIds = requestIds
+}
+
+// Get request ids of client commitments by client position
+func (c Commitment) RequestIds() map[int32]string {
+	return c.requestIds
+}
+
 // Get merkle root hash for Commitment
 func (c Commitment) GetCommitmentHash() chainhash.Hash {
 	return c.tree.getMerkleRoot()
 }
 
+// Get merkle tree hash function of Commitment as recorded with the merkle
+// commitments and proofs, empty for the default double SHA256
+func (c Commitment) MerkleHash() string {
+	return c.tree.getMerkleHash()
+}
+
 // struct for db CommitmentMerkleCommitment
+// Hash is the merkle tree hash function, empty for the default double SHA256
 type CommitmentMerkleCommitment struct {
 	MerkleRoot     chainhash.Hash
 	ClientPosition int32
 	Commitment     chainhash.Hash
+	RequestId      string
+	Hash           string
 }
 
 // Implement bson.Marshaler MarshalBSON() method for use with db_mongo interface
 func (c CommitmentMerkleCommitment) MarshalBSON() ([]byte, error) {
-	commitmentBSON := CommitmentMerkleCommitmentBSON{c.MerkleRoot.String(), c.ClientPosition, c.Commitment.String()}
+	commitmentBSON := CommitmentMerkleCommitmentBSON{c.MerkleRoot.String(), c.ClientPosition, c.Commitment.String(), c.RequestId, c.Hash}
 	return bson.Marshal(commitmentBSON)
 
 }
@@ -82,6 +158,8 @@ func (c *CommitmentMerkleCommitment) UnmarshalBSON(b []byte) error {
 	c.MerkleRoot = *rootHash
 	c.ClientPosition = commitmentBSON.ClientPosition
 	c.Commitment = *commitHash
+	c.RequestId = commitmentBSON.RequestId
+	c.Hash = commitmentBSON.Hash
 	return nil
 }
 
@@ -90,11 +168,15 @@ const (
 	CommitmentMerkleRootName     = "merkle_root"
 	CommitmentClientPositionName = "client_position"
 	CommitmentCommitmentName     = "commitment"
+	CommitmentRequestIdName      = "request_id"
+	CommitmentHashName           = "hash"
 )
 
-//CommitmentMerkleCommitmentBSON structure for mongoDB
+// CommitmentMerkleCommitmentBSON structure for mongoDB
 type CommitmentMerkleCommitmentBSON struct {
 	MerkleRoot     string `bson:"merkle_root"`
 	ClientPosition int32  `bson:"client_position"`
 	Commitment     string `bson:"commitment"`
+	RequestId      string `bson:"request_id,omitempty"`
+	Hash           string `bson:"hash,omitempty"`
 }
@@ -44,6 +44,11 @@ func TestCommitment(t *testing.T) {
 
 	merkleProofs := commitment.GetMerkleProofs()
 	assert.Equal(t, proofs, merkleProofs)
+
+	// commitment of known root only
+	rootCommitment := NewCommitmentRoot(*root)
+	assert.Equal(t, *root, rootCommitment.GetCommitmentHash())
+	assert.Equal(t, 0, len(rootCommitment.GetMerkleCommitments()))
 }
 
 // Test Commitment BSON interface
@@ -0,0 +1,151 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// commitment aggregator consts
+const (
+	DefaultSubtreeSize = 1024 // client positions per cached merkle subtree
+
+	ErrorSubtreeSize = "Invalid merkle subtree size - expected power of two greater than one"
+)
+
+// cached merkle subtree of a fixed size range of client positions
+type commitmentSubtree struct {
+	leaves    []chainhash.Hash
+	treeStore []*chainhash.Hash
+}
+
+// CommitmentAggregator structure
+// Caches the merkle subtrees of fixed size ranges of client positions so that
+// the commitment merkle tree only has to be rebuilt for subtrees with changed
+// commitments, combining the cached subtree roots into the tree root. The
+// resulting tree is identical to the tree built from all commitments at once
+type CommitmentAggregator struct {
+	mu          sync.Mutex
+	subtreeSize int
+	subtrees    []commitmentSubtree
+	hash        string
+}
+
+// Return new CommitmentAggregator instance for subtrees of the size given
+func NewCommitmentAggregator(subtreeSize int) (*CommitmentAggregator, error) {
+	if subtreeSize < 2 || subtreeSize&(subtreeSize-1) != 0 {
+		return nil, errors.New(fmt.Sprintf("%s: %d", ErrorSubtreeSize, subtreeSize))
+	}
+	return &CommitmentAggregator{subtreeSize: subtreeSize}, nil
+}
+
+// Set merkle tree hash function of the subtrees, clearing cached subtrees
+// built with a different hash function
+func (a *CommitmentAggregator) SetHash(hash string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if merkleHashRecord(hash) != a.hash {
+		a.hash = merkleHashRecord(hash)
+		a.subtrees = nil
+	}
+}
+
+// Update cached subtrees with the latest client commitments ordered by
+// client position, returning the number of subtrees rebuilt
+func (a *CommitmentAggregator) Update(commitments []chainhash.Hash) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.update(commitments)
+}
+
+// Return Commitment of the latest client commitments ordered by client
+// position, rebuilding only the subtrees with changed commitments
+func (a *CommitmentAggregator) Commitment(commitments []chainhash.Hash) (*Commitment, error) {
+	if len(commitments) == 0 {
+		return nil, errors.New(ErrorCommitmentListEmpty)
+	}
+	// trees no larger than a subtree are built directly
+	if nextPow(len(commitments)) <= a.subtreeSize {
+		return NewCommitmentHash(commitments, a.hash)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.update(commitments)
+
+	// subtree roots are combined into the top levels of the tree
+	roots := make([]chainhash.Hash, len(a.subtrees))
+	for i, subtree := range a.subtrees {
+		roots[i] = *subtree.treeStore[len(subtree.treeStore)-1]
+	}
+	topTree := buildMerkleTreeHash(roots, a.hash)
+
+	// tree store levels below the subtree roots are the subtree levels
+	// in order, padded with nil for the subtrees beyond the last commitment
+	numTop := nextPow(len(roots))
+	treeStore := make([]*chainhash.Hash, 0, 2*numTop*a.subtreeSize-1)
+	subtreeOffset := 0
+	for width := a.subtreeSize; width > 1; width /= 2 {
+		for _, subtree := range a.subtrees {
+			treeStore = append(treeStore, subtree.treeStore[subtreeOffset:subtreeOffset+width]...)
+		}
+		treeStore = append(treeStore, make([]*chainhash.Hash, (numTop-len(a.subtrees))*width)...)
+		subtreeOffset += width
+	}
+	treeStore = append(treeStore, topTree...)
+
+	myCommitments := make([]chainhash.Hash, len(commitments))
+	copy(myCommitments, commitments)
+	tree := CommitmentMerkleTree{myCommitments, treeStore, *treeStore[len(treeStore)-1], a.hash}
+	return &Commitment{tree, nil, nil}, nil
+}
+
+// Rebuild subtrees with changed commitments
+// Subtrees are replaced rather than modified, as the tree stores of previous
+// commitments keep pointers to the subtree leaves and nodes
+func (a *CommitmentAggregator) update(commitments []chainhash.Hash) int {
+	numSubtrees := (len(commitments) + a.subtreeSize - 1) / a.subtreeSize
+	if numSubtrees < len(a.subtrees) {
+		a.subtrees = a.subtrees[:numSubtrees]
+	}
+	rebuilt := 0
+	for i := 0; i < numSubtrees; i++ {
+		start := i * a.subtreeSize
+		end := start + a.subtreeSize
+		if end > len(commitments) {
+			end = len(commitments)
+		}
+		if i < len(a.subtrees) && hashesEqual(a.subtrees[i].leaves, commitments[start:end]) {
+			continue
+		}
+		leaves := make([]chainhash.Hash, end-start)
+		copy(leaves, commitments[start:end])
+		subtree := commitmentSubtree{leaves, buildMerkleTreeSize(leaves, a.subtreeSize, a.hash)}
+		if i < len(a.subtrees) {
+			a.subtrees[i] = subtree
+		} else {
+			a.subtrees = append(a.subtrees, subtree)
+		}
+		rebuilt++
+	}
+	return rebuilt
+}
+
+// Return whether the hash lists are equal
+func hashesEqual(a []chainhash.Hash, b []chainhash.Hash) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Return test commitments for positions, with zero hashes for unused positions
+func testAggregatorCommitments(num int, seed byte) []chainhash.Hash {
+	commitments := make([]chainhash.Hash, num)
+	for i := range commitments {
+		if i%7 != 3 {
+			commitments[i] = chainhash.DoubleHashH([]byte{seed, byte(i), byte(i >> 8)})
+		}
+	}
+	return commitments
+}
+
+// Test aggregated commitment trees are identical to trees built at once
+func TestCommitmentAggregator(t *testing.T) {
+	_, aggregatorErr := NewCommitmentAggregator(6)
+	assert.Equal(t, ErrorSubtreeSize+": 6", aggregatorErr.Error())
+	_, aggregatorErr = NewCommitmentAggregator(1)
+	assert.Equal(t, ErrorSubtreeSize+": 1", aggregatorErr.Error())
+
+	for _, subtreeSize := range []int{2, 4, 16} {
+		aggregator, _ := NewCommitmentAggregator(subtreeSize)
+		_, commitmentErr := aggregator.Commitment(nil)
+		assert.Equal(t, ErrorCommitmentListEmpty, commitmentErr.Error())
+
+		for _, num := range []int{1, 2, 3, 5, 16, 17, 33, 64, 100} {
+			commitments := testAggregatorCommitments(num, byte(subtreeSize))
+			expected, _ := NewCommitment(commitments)
+			commitment, commitmentErr := aggregator.Commitment(commitments)
+			assert.Equal(t, nil, commitmentErr)
+			assert.Equal(t, expected.GetCommitmentHash(), commitment.GetCommitmentHash())
+			assert.Equal(t, expected.tree.getMerkleTree(), commitment.tree.getMerkleTree())
+			assert.Equal(t, expected.GetMerkleProofs(), commitment.GetMerkleProofs())
+			assert.Equal(t, expected.GetMerkleCommitments(), commitment.GetMerkleCommitments())
+		}
+	}
+}
+
+// Test only subtrees with changed commitments are rebuilt
+func TestCommitmentAggregatorUpdate(t *testing.T) {
+	aggregator, _ := NewCommitmentAggregator(4)
+	commitments := testAggregatorCommitments(10, 1)
+	assert.Equal(t, 3, aggregator.Update(commitments))
+	assert.Equal(t, 0, aggregator.Update(commitments))
+
+	// changed commitment rebuilds its subtree
+	prevCommitment, _ := aggregator.Commitment(commitments)
+	prevRoot := prevCommitment.GetCommitmentHash()
+	updated := append([]chainhash.Hash{}, commitments...)
+	updated[5] = chainhash.DoubleHashH([]byte{5})
+	assert.Equal(t, 1, aggregator.Update(updated))
+	commitment, _ := aggregator.Commitment(updated)
+	expected, _ := NewCommitment(updated)
+	assert.Equal(t, expected.GetCommitmentHash(), commitment.GetCommitmentHash())
+	assert.Equal(t, expected.GetMerkleProofs(), commitment.GetMerkleProofs())
+
+	// previous commitments unaffected by rebuilt subtrees
+	assert.Equal(t, prevRoot, prevCommitment.GetCommitmentHash())
+	assert.Equal(t, commitments[5], prevCommitment.GetMerkleProofs()[5].Commitment)
+
+	// new client positions rebuild the last subtree and add new ones
+	updated = append(updated, testAggregatorCommitments(5, 2)...)
+	assert.Equal(t, 2, aggregator.Update(updated))
+	commitment, _ = aggregator.Commitment(updated)
+	expected, _ = NewCommitment(updated)
+	assert.Equal(t, expected.GetCommitmentHash(), commitment.GetCommitmentHash())
+
+	// removed client positions drop subtrees
+	assert.Equal(t, 1, aggregator.Update(updated[:6]))
+	commitment, _ = aggregator.Commitment(updated[:9])
+	expected, _ = NewCommitment(updated[:9])
+	assert.Equal(t, expected.GetCommitmentHash(), commitment.GetCommitmentHash())
+}
@@ -0,0 +1,36 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+// commitment exclusion reasons
+const (
+	// commitment submitted before round close but not read by the round
+	ExclusionReasonMissed = "missed"
+)
+
+// struct for db CommitmentExclusion
+// Record of a client commitment submitted before the close of an attestation
+// round that was not included in the commitments of that round. Times are
+// unix milliseconds and MerkleRoot is the commitment hash of the round
+type CommitmentExclusion struct {
+	ClientPosition int32  `bson:"client_position" json:"position"`
+	Commitment     string `bson:"commitment" json:"commitment"`
+	SubmittedAt    int64  `bson:"submitted_at" json:"submitted_at"`
+	RoundClose     int64  `bson:"round_close" json:"round_close"`
+	MerkleRoot     string `bson:"merkle_root" json:"merkle_root"`
+	Included       string `bson:"included" json:"included"`
+	Reason         string `bson:"reason" json:"reason"`
+}
+
+// CommitmentExclusion field names
+const (
+	CommitmentExclusionClientPositionName = "client_position"
+	CommitmentExclusionCommitmentName     = "commitment"
+	CommitmentExclusionSubmittedAtName    = "submitted_at"
+	CommitmentExclusionRoundCloseName     = "round_close"
+	CommitmentExclusionMerkleRootName     = "merkle_root"
+	CommitmentExclusionIncludedName       = "included"
+	CommitmentExclusionReasonName         = "reason"
+)
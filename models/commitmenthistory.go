@@ -0,0 +1,28 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+// CommitmentHistory struct
+// Page of the commitments of a client position included in attestation
+// rounds, newest first. Total is the number of commitments of the position
+// across all pages
+type CommitmentHistory struct {
+	ClientPosition int32                    `json:"position"`
+	Total          int64                    `json:"total"`
+	Offset         int64                    `json:"offset"`
+	Limit          int64                    `json:"limit"`
+	Commitments    []CommitmentHistoryEntry `json:"commitments"`
+}
+
+// CommitmentHistoryEntry struct
+// Client commitment with the merkle root of the round it was included in and
+// the attestation transaction committing to the root. Txid is empty while the
+// round has not been attested, and Confirmed set once the attestation is confirmed
+type CommitmentHistoryEntry struct {
+	Commitment string `json:"commitment"`
+	MerkleRoot string `json:"merkle_root"`
+	Txid       string `json:"txid"`
+	Confirmed  bool   `json:"confirmed"`
+}
@@ -69,11 +69,11 @@ func ProveMerkleProof(proof CommitmentMerkleProof) bool {
 	for i := range proof.Ops {
 		if proof.Ops[i].Append {
 			log.Infof("append: %s\n", proof.Ops[i].Commitment.String())
-			hash = *hashLeaves(hash, proof.Ops[i].Commitment)
+			hash = *hashNodes(proof.Hash, hash, proof.Ops[i].Commitment)
 			log.Infof("result: %s\n", hash.String())
 		} else {
 			log.Infof("prepend: %s\n", proof.Ops[i].Commitment.String())
-			hash = *hashLeaves(proof.Ops[i].Commitment, hash)
+			hash = *hashNodes(proof.Hash, proof.Ops[i].Commitment, hash)
 			log.Infof("result: %s\n", hash.String())
 		}
 	}
@@ -100,16 +100,19 @@ const (
 )
 
 // CommitmentMerkleProof structure
+// Hash is the merkle tree hash function, empty for the default double SHA256
 type CommitmentMerkleProof struct {
 	MerkleRoot     chainhash.Hash
 	ClientPosition int32
 	Commitment     chainhash.Hash
 	Ops            []CommitmentMerkleProofOp
+	Hash           string
 }
 
 // Implement bson.Marshaler MarshalBSON() method for use with db_mongo interface
 func (c CommitmentMerkleProof) MarshalBSON() ([]byte, error) {
-	proofBson := CommitmentMerkleProofBSON{MerkleRoot: c.MerkleRoot.String(), ClientPosition: c.ClientPosition, Commitment: c.Commitment.String()}
+	proofBson := CommitmentMerkleProofBSON{MerkleRoot: c.MerkleRoot.String(), ClientPosition: c.ClientPosition, Commitment: c.Commitment.String(),
+		Hash: c.Hash}
 
 	var opsBson []CommitmentMerkleProofOpBSON
 	for _, op := range c.Ops {
@@ -125,7 +128,29 @@ func (c *CommitmentMerkleProof) UnmarshalBSON(b []byte) error {
 	if err := bson.Unmarshal(b, &proofBSON); err != nil {
 		return err
 	}
-	// TODO : not implemented as not required anywhere at the moment
+	rootHash, errHash := chainhash.NewHashFromStr(proofBSON.MerkleRoot)
+	if errHash != nil {
+		return errHash
+	}
+	commitHash, errHash := chainhash.NewHashFromStr(proofBSON.Commitment)
+	if errHash != nil {
+		return errHash
+	}
+
+	var ops []CommitmentMerkleProofOp
+	for _, opBSON := range proofBSON.Ops {
+		opHash, errHash := chainhash.NewHashFromStr(opBSON.Commitment)
+		if errHash != nil {
+			return errHash
+		}
+		ops = append(ops, CommitmentMerkleProofOp{opBSON.Append, *opHash})
+	}
+
+	c.MerkleRoot = *rootHash
+	c.ClientPosition = proofBSON.ClientPosition
+	c.Commitment = *commitHash
+	c.Ops = ops
+	c.Hash = proofBSON.Hash
 	return nil
 }
 
@@ -135,6 +160,7 @@ const (
 	ProofClientPositionName = "client_position"
 	ProofCommitmentName     = "commitment"
 	ProofOpsName            = "ops"
+	ProofHashName           = "hash"
 )
 
 // CommitmentMerkleProofBSON structure for mongoDB
@@ -143,4 +169,5 @@ type CommitmentMerkleProofBSON struct {
 	ClientPosition int32                         `bson:"client_position"`
 	Commitment     string                        `bson:"commitment"`
 	Ops            []CommitmentMerkleProofOpBSON `bson:"ops"`
+	Hash           string                        `bson:"hash,omitempty"`
 }
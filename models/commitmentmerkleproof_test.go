@@ -333,4 +333,59 @@ func TestMerkleProof_BSON(t *testing.T) {
 		assert.Equal(t, proof0.Ops[pos].Append, docOp.Lookup(ProofOpAppendName).Boolean())
 		assert.Equal(t, proof0.Ops[pos].Commitment.String(), docOp.Lookup(ProofOpCommitmentName).StringValue())
 	}
+
+	// test reverse document to proof model
+	testProof := &CommitmentMerkleProof{}
+	docErr = GetModelFromDocument(doc, testProof)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, proof0, *testProof)
+}
+
+// Test compact binary encoding of merkle proofs
+func TestMerkleProof_Binary(t *testing.T) {
+	hash0, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash2, _ := chainhash.NewHashFromStr("3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	for _, hash := range []string{MerkleHashSha256d, MerkleHashSha256, MerkleHashTaggedSha256} {
+		commitment, _ := NewCommitmentHash([]chainhash.Hash{*hash0, *hash1, *hash2}, hash)
+		for _, proof := range commitment.GetMerkleProofs() {
+			proofBytes, proofErr := proof.MarshalBinary()
+			assert.Equal(t, nil, proofErr)
+			assert.Equal(t, 2+1+32+1+1+2*32, len(proofBytes))
+
+			var decoded CommitmentMerkleProof
+			assert.Equal(t, nil, decoded.UnmarshalBinary(proofBytes))
+			assert.Equal(t, proof, decoded)
+
+			encoded, encodeErr := EncodeProofBase64(proof)
+			assert.Equal(t, nil, encodeErr)
+			decoded, decodeErr := DecodeProofBase64(encoded)
+			assert.Equal(t, nil, decodeErr)
+			assert.Equal(t, proof, decoded)
+		}
+	}
+
+	// client position varint and sides bitmap
+	proof := CommitmentMerkleProof{ClientPosition: 300, Commitment: *hash0, Ops: []CommitmentMerkleProofOp{
+		{true, *hash1}, {false, *hash2}, {true, *hash0}}}
+	proofBytes, _ := proof.MarshalBinary()
+	assert.Equal(t, []byte{ProofBinaryVersion, 0, 0xac, 0x02}, proofBytes[:4])
+	assert.Equal(t, []byte{3, 0x05}, proofBytes[36:38])
+	var decoded CommitmentMerkleProof
+	assert.Equal(t, nil, decoded.UnmarshalBinary(proofBytes))
+	assert.Equal(t, int32(300), decoded.ClientPosition)
+	assert.Equal(t, proof.Ops, decoded.Ops)
+
+	// invalid proofs
+	_, proofErr := CommitmentMerkleProof{Hash: "sha512"}.MarshalBinary()
+	assert.Equal(t, ErrorProofBinaryHash+": sha512", proofErr.Error())
+	_, proofErr = CommitmentMerkleProof{ClientPosition: -1}.MarshalBinary()
+	assert.Equal(t, ErrorProofBinaryPosition+": -1", proofErr.Error())
+	assert.Equal(t, ErrorProofBinaryVersion+": 2", decoded.UnmarshalBinary(append([]byte{2}, proofBytes[1:]...)).Error())
+	assert.Equal(t, ErrorProofBinaryHash+": 3", decoded.UnmarshalBinary(append([]byte{1, 3}, proofBytes[2:]...)).Error())
+	assert.Equal(t, ErrorProofBinaryLength+": 133", decoded.UnmarshalBinary(proofBytes[:len(proofBytes)-1]).Error())
+	assert.Equal(t, ErrorProofBinaryLength+": 1", decoded.UnmarshalBinary(proofBytes[:1]).Error())
+	_, decodeErr := DecodeProofBase64("!")
+	assert.NotEqual(t, nil, decodeErr)
 }
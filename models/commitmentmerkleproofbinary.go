@@ -0,0 +1,161 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Compact binary encoding of commitment merkle proofs, for sidechains to
+// embed proofs in their own blocks or OP_RETURN outputs
+//
+// The encoding is, in order:
+//   - version byte
+//   - merkle tree hash function byte, 0 double SHA256, 1 SHA256, 2 tagged SHA256
+//   - client position as unsigned varint
+//   - 32 byte commitment
+//   - op count byte
+//   - op sides bitmap of op count bits rounded up to bytes, bit i from the
+//     least significant bit of byte i/8 set if op i is appended
+//   - 32 bytes commitment of each op
+//
+// Hashes are in internal byte order. The merkle root is not encoded, as it
+// is computed from the ops when decoding and checked against the attestation
+// by verifiers
+
+// binary proof consts
+const (
+	ProofBinaryVersion = 1
+	ProofBinaryMaxOps  = 255
+
+	ErrorProofBinaryVersion  = "Unsupported binary proof version"
+	ErrorProofBinaryHash     = "Unsupported binary proof merkle tree hash function"
+	ErrorProofBinaryLength   = "Invalid binary proof length"
+	ErrorProofBinaryOps      = "Too many binary proof ops"
+	ErrorProofBinaryPosition = "Invalid binary proof client position"
+)
+
+// merkle tree hash functions by binary proof hash function byte
+var proofBinaryHashes = []string{MerkleHashSha256d, MerkleHashSha256, MerkleHashTaggedSha256}
+
+// Implement encoding.BinaryMarshaler MarshalBinary() method for the compact
+// binary encoding of the merkle proof
+func (c CommitmentMerkleProof) MarshalBinary() ([]byte, error) {
+	hashId := -1
+	for id, hash := range proofBinaryHashes {
+		if MerkleHashName(c.Hash) == hash {
+			hashId = id
+		}
+	}
+	if hashId < 0 {
+		return nil, errors.New(fmt.Sprintf("%s: %s", ErrorProofBinaryHash, c.Hash))
+	}
+	if c.ClientPosition < 0 {
+		return nil, errors.New(fmt.Sprintf("%s: %d", ErrorProofBinaryPosition, c.ClientPosition))
+	}
+	if len(c.Ops) > ProofBinaryMaxOps {
+		return nil, errors.New(fmt.Sprintf("%s: %d", ErrorProofBinaryOps, len(c.Ops)))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(ProofBinaryVersion)
+	buf.WriteByte(byte(hashId))
+	var positionBytes [binary.MaxVarintLen32]byte
+	buf.Write(positionBytes[:binary.PutUvarint(positionBytes[:], uint64(c.ClientPosition))])
+	buf.Write(c.Commitment[:])
+	buf.WriteByte(byte(len(c.Ops)))
+	sides := make([]byte, (len(c.Ops)+7)/8)
+	for i, op := range c.Ops {
+		if op.Append {
+			sides[i/8] |= 1 << uint(i%8)
+		}
+	}
+	buf.Write(sides)
+	for _, op := range c.Ops {
+		buf.Write(op.Commitment[:])
+	}
+	return buf.Bytes(), nil
+}
+
+// Implement encoding.BinaryUnmarshaler UnmarshalBinary() method for the
+// compact binary encoding of the merkle proof, computing the merkle root
+// of the proof from its ops
+func (c *CommitmentMerkleProof) UnmarshalBinary(data []byte) error {
+	size := len(data)
+	if size < 2 {
+		return errors.New(fmt.Sprintf("%s: %d", ErrorProofBinaryLength, size))
+	}
+	if data[0] != ProofBinaryVersion {
+		return errors.New(fmt.Sprintf("%s: %d", ErrorProofBinaryVersion, data[0]))
+	}
+	if int(data[1]) >= len(proofBinaryHashes) {
+		return errors.New(fmt.Sprintf("%s: %d", ErrorProofBinaryHash, data[1]))
+	}
+	hash := proofBinaryHashes[data[1]]
+
+	position, positionLen := binary.Uvarint(data[2:])
+	if positionLen <= 0 || position > uint64(1<<31-1) {
+		return errors.New(ErrorProofBinaryPosition)
+	}
+	data = data[2+positionLen:]
+	if len(data) < chainhash.HashSize+1 {
+		return errors.New(fmt.Sprintf("%s: %d", ErrorProofBinaryLength, size))
+	}
+	var commitment chainhash.Hash
+	copy(commitment[:], data[:chainhash.HashSize])
+	opCount := int(data[chainhash.HashSize])
+	data = data[chainhash.HashSize+1:]
+	sidesLen := (opCount + 7) / 8
+	if len(data) != sidesLen+opCount*chainhash.HashSize {
+		return errors.New(fmt.Sprintf("%s: %d", ErrorProofBinaryLength, size))
+	}
+
+	ops := make([]CommitmentMerkleProofOp, opCount)
+	root := commitment
+	for i := range ops {
+		ops[i].Append = data[i/8]&(1<<uint(i%8)) != 0
+		copy(ops[i].Commitment[:], data[sidesLen+i*chainhash.HashSize:])
+		if ops[i].Append {
+			root = *hashNodes(hash, root, ops[i].Commitment)
+		} else {
+			root = *hashNodes(hash, ops[i].Commitment, root)
+		}
+	}
+
+	c.MerkleRoot = root
+	c.ClientPosition = int32(position)
+	c.Commitment = commitment
+	c.Ops = ops
+	c.Hash = merkleHashRecord(hash)
+	return nil
+}
+
+// Return base64 encoded compact binary encoding of the merkle proof
+func EncodeProofBase64(proof CommitmentMerkleProof) (string, error) {
+	proofBytes, proofErr := proof.MarshalBinary()
+	if proofErr != nil {
+		return "", proofErr
+	}
+	return base64.StdEncoding.EncodeToString(proofBytes), nil
+}
+
+// Return merkle proof of the base64 encoded compact binary encoding
+func DecodeProofBase64(encoded string) (CommitmentMerkleProof, error) {
+	proofBytes, decodeErr := base64.StdEncoding.DecodeString(encoded)
+	if decodeErr != nil {
+		return CommitmentMerkleProof{}, decodeErr
+	}
+	var proof CommitmentMerkleProof
+	if proofErr := proof.UnmarshalBinary(proofBytes); proofErr != nil {
+		return CommitmentMerkleProof{}, proofErr
+	}
+	return proof, nil
+}
@@ -14,6 +14,10 @@ import (
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 )
 
+// Null hash of empty leaves of the commitment merkle tree, between client
+// commitments or padding the tree to a fixed number of leaves
+var MerkleNullHash = chainhash.Hash{}
+
 // Util function to print a merkle tree
 func printMerkleTree(tree []*chainhash.Hash) {
 	num := len(tree)/2 + 1
@@ -33,9 +37,20 @@ func printMerkleTree(tree []*chainhash.Hash) {
 // Build merkle tree store from a list of commitments
 // e.g. tree template: [hash0, hash1, hash2, nil, hash01, hash22, hashRoot]
 func buildMerkleTree(hashes []chainhash.Hash) []*chainhash.Hash {
+	return buildMerkleTreeHash(hashes, MerkleHashSha256d)
+}
+
+// Build merkle tree store from a list of commitments with the merkle
+// tree hash function given
+func buildMerkleTreeHash(hashes []chainhash.Hash, hash string) []*chainhash.Hash {
+	return buildMerkleTreeSize(hashes, nextPow(len(hashes)), hash)
+}
+
+// Build merkle tree store from a list of commitments padded
+// with nil leaves to the power of two number of leaves given
+func buildMerkleTreeSize(hashes []chainhash.Hash, nextPoT int, hash string) []*chainhash.Hash {
 	// Calculate how many entries are required to hold the binary merkle
 	// tree as a linear array and create an array of that size.
-	nextPoT := nextPow(len(hashes))
 	arraySize := nextPoT*2 - 1
 	merkles := make([]*chainhash.Hash, arraySize)
 
@@ -56,13 +71,13 @@ func buildMerkleTree(hashes []chainhash.Hash) []*chainhash.Hash {
 		// When there is no right child, the parent is generated by
 		// hashing the concatenation of the left child with itself.
 		case merkles[i+1] == nil:
-			newHash := hashLeaves(*merkles[i], *merkles[i])
+			newHash := hashNodes(hash, *merkles[i], *merkles[i])
 			merkles[offset] = newHash
 
 		// The normal case sets the parent node to the double sha256
 		// of the concatentation of the left and right children.
 		default:
-			newHash := hashLeaves(*merkles[i], *merkles[i+1])
+			newHash := hashNodes(hash, *merkles[i], *merkles[i+1])
 			merkles[offset] = newHash
 		}
 		offset++
@@ -73,13 +88,7 @@ func buildMerkleTree(hashes []chainhash.Hash) []*chainhash.Hash {
 
 // Hash the concatenation of two commitment leaves from merkle tree
 func hashLeaves(left chainhash.Hash, right chainhash.Hash) *chainhash.Hash {
-	// Concatenate the left and right nodes.
-	var hash [chainhash.HashSize * 2]byte
-	copy(hash[:chainhash.HashSize], left[:])
-	copy(hash[chainhash.HashSize:], right[:])
-
-	newHash := chainhash.DoubleHashH(hash[:])
-	return &newHash
+	return hashNodes(MerkleHashSha256d, left, right)
 }
 
 // Return next power of 2 for given integer number
@@ -100,32 +109,39 @@ func nextPow(n int) int {
 }
 
 // CommitmentMerkleTree structure
+// The merkle tree hash function is empty for the default double SHA256
 type CommitmentMerkleTree struct {
 	commitments []chainhash.Hash
 	treeStore   []*chainhash.Hash
 	root        chainhash.Hash
+	hash        string
 }
 
 // New CommitmentMerkleTree instance
 // Takes as input a list of commitments and stores these
 // along with the whole merkle tree in a list
 func NewCommitmentMerkleTree(commitments []chainhash.Hash) CommitmentMerkleTree {
+	return NewCommitmentMerkleTreeHash(commitments, MerkleHashSha256d)
+}
+
+// New CommitmentMerkleTree instance with the merkle tree hash function given
+func NewCommitmentMerkleTreeHash(commitments []chainhash.Hash, hash string) CommitmentMerkleTree {
 	leavesSize := len(commitments)
 	myCommitments := make([]chainhash.Hash, leavesSize)
 	copy(myCommitments, commitments)
 
 	treeSize := 2*nextPow(leavesSize) - 1
 	myTreeStore := make([]*chainhash.Hash, treeSize)
-	myTreeStore = buildMerkleTree(myCommitments)
+	myTreeStore = buildMerkleTreeHash(myCommitments, hash)
 
 	myRoot := *myTreeStore[treeSize-1]
 
-	return CommitmentMerkleTree{myCommitments, myTreeStore, myRoot}
+	return CommitmentMerkleTree{myCommitments, myTreeStore, myRoot, merkleHashRecord(hash)}
 }
 
 // Build commitment merkle tree store from commitment hashes
 func (m *CommitmentMerkleTree) updateTreeStore() {
-	m.treeStore = buildMerkleTree(m.commitments)
+	m.treeStore = buildMerkleTreeHash(m.commitments, m.hash)
 	m.root = *m.treeStore[len(m.treeStore)-1]
 }
 
@@ -154,7 +170,9 @@ func (m CommitmentMerkleTree) getMerkleCommitments() []chainhash.Hash {
 func (m CommitmentMerkleTree) getMerkleProofs() []CommitmentMerkleProof {
 	var proofs []CommitmentMerkleProof
 	for i := range m.commitments {
-		proofs = append(proofs, buildMerkleProof(i, m.treeStore))
+		proof := buildMerkleProof(i, m.treeStore)
+		proof.Hash = m.hash
+		proofs = append(proofs, proof)
 	}
 	return proofs
 }
@@ -168,3 +186,8 @@ func (m CommitmentMerkleTree) getMerkleTree() []*chainhash.Hash {
 func (m CommitmentMerkleTree) getMerkleRoot() chainhash.Hash {
 	return m.root
 }
+
+// Get tree merkle hash function, empty for the default double SHA256
+func (m CommitmentMerkleTree) getMerkleHash() string {
+	return m.hash
+}
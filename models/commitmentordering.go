@@ -0,0 +1,280 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// commitment ordering consts
+const (
+	OrderingPosition = "position"
+	OrderingMap      = "map"
+	OrderingSorted   = "sorted"
+	OrderingSparse   = "sparse"
+
+	DefaultSparseDepth = 16
+	MaxSparseDepth     = 20
+
+	ErrorOrderingUnknown   = "Unknown commitment ordering strategy"
+	ErrorOrderingMapEmpty  = "Map ordering requires client position leaves"
+	ErrorOrderingLeaf      = "Invalid commitment ordering leaf"
+	ErrorOrderingCollision = "Commitment ordering assigns client positions to the same leaf"
+	ErrorOrderingDepth     = "Invalid sparse ordering depth"
+	ErrorOrderingSlotIds   = "Sparse ordering requires client position slot ids"
+	ErrorOrderingMismatch  = "Proof leaf does not match commitment ordering"
+	ErrorOrderingSize      = "Invalid padded merkle tree size - expected power of two above the ordering leaves"
+)
+
+// CommitmentOrdering structure
+// Strategy placing the client commitments at the leaves of the commitment
+// merkle tree. The position strategy uses the client position as leaf, the
+// map strategy a fixed leaf for each client position, the sorted strategy
+// packs the client positions with commitments in ascending order and the
+// sparse strategy keys the leaf of each client position by the hash of its
+// slot id in a tree of fixed depth. Client positions with no leaf in the map
+// or sparse strategies are not committed to, so slots can be removed without
+// moving the leaves of the other slots. If a size is set, the merkle tree is
+// padded with null hashes to this fixed number of leaves, so the merkle path
+// of a slot does not change as other slots join or leave
+type CommitmentOrdering struct {
+	Strategy  string           `json:"strategy"`
+	Positions map[int32]int32  `json:"positions,omitempty"`
+	Depth     int              `json:"depth,omitempty"`
+	SlotIds   map[int32]string `json:"slot_ids,omitempty"`
+	Size      int              `json:"size,omitempty"`
+}
+
+// Return CommitmentOrdering for the strategy, the position strategy if not set
+// Positions are the leaves of the map strategy and depth and slot ids the
+// tree depth and slot id of each client position of the sparse strategy
+func NewCommitmentOrdering(strategy string, positions map[int32]int32, depth int,
+	slotIds map[int32]string) (CommitmentOrdering, error) {
+
+	switch strategy {
+	case "", OrderingPosition:
+		return CommitmentOrdering{Strategy: OrderingPosition}, nil
+	case OrderingSorted:
+		return CommitmentOrdering{Strategy: OrderingSorted}, nil
+	case OrderingMap:
+		if len(positions) == 0 {
+			return CommitmentOrdering{}, errors.New(ErrorOrderingMapEmpty)
+		}
+		ordering := CommitmentOrdering{Strategy: OrderingMap, Positions: positions}
+		return ordering, ordering.checkLeaves()
+	case OrderingSparse:
+		if depth <= 0 {
+			depth = DefaultSparseDepth
+		} else if depth > MaxSparseDepth {
+			return CommitmentOrdering{}, errors.New(fmt.Sprintf("%s: %d (max %d)", ErrorOrderingDepth, depth, MaxSparseDepth))
+		}
+		if len(slotIds) == 0 {
+			return CommitmentOrdering{}, errors.New(ErrorOrderingSlotIds)
+		}
+		for position, slotId := range slotIds {
+			if slotId == "" {
+				return CommitmentOrdering{}, errors.New(fmt.Sprintf("%s: %d", ErrorOrderingSlotIds, position))
+			}
+		}
+		ordering := CommitmentOrdering{Strategy: OrderingSparse, Depth: depth, SlotIds: slotIds}
+		return ordering, ordering.checkLeaves()
+	}
+	return CommitmentOrdering{}, errors.New(fmt.Sprintf("%s: %s", ErrorOrderingUnknown, strategy))
+}
+
+// Return CommitmentOrdering with the merkle tree padded to the fixed number
+// of leaves given, a power of two above any fixed leaf of the ordering, or
+// the ordering unpadded if the size is not positive. Leaves at or above the
+// size are not committed to
+func (o CommitmentOrdering) Padded(size int) (CommitmentOrdering, error) {
+	if size <= 0 {
+		o.Size = 0
+		return o, nil
+	}
+	if size < 2 || size > 1<<MaxSparseDepth || size&(size-1) != 0 {
+		return CommitmentOrdering{}, errors.New(fmt.Sprintf("%s: %d", ErrorOrderingSize, size))
+	}
+	if o.Strategy == OrderingSparse && size < 1<<o.Depth {
+		return CommitmentOrdering{}, errors.New(fmt.Sprintf("%s: %d (depth %d)", ErrorOrderingSize, size, o.Depth))
+	}
+	for _, leaf := range o.Positions {
+		if int(leaf) >= size {
+			return CommitmentOrdering{}, errors.New(fmt.Sprintf("%s: %d (leaf %d)", ErrorOrderingSize, size, leaf))
+		}
+	}
+	o.Size = size
+	return o, nil
+}
+
+// Check leaves of the map or sparse strategies are valid and distinct
+func (o CommitmentOrdering) checkLeaves() error {
+	var positions []int32
+	for position := range o.Positions {
+		positions = append(positions, position)
+	}
+	for position := range o.SlotIds {
+		positions = append(positions, position)
+	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i] < positions[j] })
+	leaves := make(map[int32]int32)
+	for _, position := range positions {
+		leaf, _ := o.leaf(position)
+		if leaf < 0 {
+			return errors.New(fmt.Sprintf("%s: %d", ErrorOrderingLeaf, leaf))
+		}
+		if other, isSet := leaves[leaf]; isSet {
+			return errors.New(fmt.Sprintf("%s: %d %d", ErrorOrderingCollision, other, position))
+		}
+		leaves[leaf] = position
+	}
+	return nil
+}
+
+// Return leaf of the sparse tree of the depth given keyed by the slot id,
+// the leading depth bits of the SHA256 hash of the slot id
+func SparseLeaf(slotId string, depth int) int32 {
+	hash := sha256.Sum256([]byte(slotId))
+	return int32(binary.BigEndian.Uint32(hash[:4]) >> (32 - depth))
+}
+
+// Return leaf of the client position for strategies with fixed leaves
+func (o CommitmentOrdering) leaf(position int32) (int32, bool) {
+	switch o.Strategy {
+	case OrderingMap:
+		leaf, isSet := o.Positions[position]
+		return leaf, isSet
+	case OrderingSparse:
+		slotId, isSet := o.SlotIds[position]
+		if !isSet {
+			return 0, false
+		}
+		return SparseLeaf(slotId, o.Depth), true
+	}
+	return position, true
+}
+
+// Return commitment hashes ordered by merkle tree leaf and the client
+// position of each leaf, -1 for leaves with no client position, from client
+// commitments ordered by client position. Empty leaves are null hashes
+func (o CommitmentOrdering) Leaves(commitments []ClientCommitment) ([]chainhash.Hash, []int32) {
+	if o.Size > 0 {
+		// client positions at leaves outside the padded tree are not committed
+		// to, leaves of the map and sparse strategies being inside the tree
+		switch o.Strategy {
+		case OrderingSorted:
+			if len(commitments) > o.Size {
+				commitments = commitments[:o.Size]
+			}
+		case "", OrderingPosition:
+			for len(commitments) > 0 && int(commitments[len(commitments)-1].ClientPosition) >= o.Size {
+				commitments = commitments[:len(commitments)-1]
+			}
+		}
+	}
+	if len(commitments) == 0 {
+		return nil, nil
+	}
+	var hashes []chainhash.Hash
+	var positions []int32
+	switch o.Strategy {
+	case OrderingSorted:
+		for _, c := range commitments {
+			hashes = append(hashes, c.Commitment)
+			positions = append(positions, c.ClientPosition)
+		}
+	case OrderingMap, OrderingSparse:
+		leafCount := 0
+		if o.Strategy == OrderingSparse {
+			leafCount = 1 << o.Depth
+		}
+		for _, c := range commitments {
+			if leaf, isSet := o.leaf(c.ClientPosition); isSet && int(leaf) >= leafCount {
+				leafCount = int(leaf) + 1
+			}
+		}
+		hashes = make([]chainhash.Hash, leafCount)
+		positions = make([]int32, leafCount)
+		for i := range positions {
+			positions[i] = -1
+		}
+		committed := false
+		for _, c := range commitments {
+			if leaf, isSet := o.leaf(c.ClientPosition); isSet {
+				hashes[leaf] = c.Commitment
+				positions[leaf] = c.ClientPosition
+				committed = true
+			}
+		}
+		if !committed {
+			return nil, nil
+		}
+	default:
+		// missing positions are null hashes at their own leaf
+		hashes = make([]chainhash.Hash, commitments[len(commitments)-1].ClientPosition+1)
+		positions = make([]int32, len(hashes))
+		for i := range positions {
+			positions[i] = int32(i)
+		}
+		for _, c := range commitments {
+			hashes[c.ClientPosition] = c.Commitment
+		}
+	}
+	return o.pad(hashes, positions)
+}
+
+// Pad commitment hashes with null hashes of no client position to the
+// padded tree size, if the ordering is padded
+func (o CommitmentOrdering) pad(hashes []chainhash.Hash, positions []int32) ([]chainhash.Hash, []int32) {
+	for len(hashes) < o.Size {
+		hashes = append(hashes, MerkleNullHash)
+		positions = append(positions, -1)
+	}
+	return hashes, positions
+}
+
+// Return Commitment of client commitments ordered by client position with
+// the leaves given by the ordering and the merkle tree hash function given
+func (o CommitmentOrdering) Commitment(commitments []ClientCommitment, hash string) (*Commitment, error) {
+	hashes, positions := o.Leaves(commitments)
+	commitment, commitmentErr := NewCommitmentHash(hashes, hash)
+	if commitmentErr != nil {
+		return nil, commitmentErr
+	}
+	commitment.SetPositions(positions)
+	return commitment, nil
+}
+
+// Verify leaf of a proof of the client position is consistent with the
+// ordering. Leaves of the sorted strategy depend on the other client
+// positions committed and can only be checked not to exceed the position
+func (o CommitmentOrdering) VerifyLeaf(position int32, leaf int32) error {
+	if o.Size > 0 && int(leaf) >= o.Size {
+		return errors.New(fmt.Sprintf("%s %s: position %d leaf %d size %d", ErrorOrderingMismatch, o.Strategy,
+			position, leaf, o.Size))
+	}
+	switch o.Strategy {
+	case "", OrderingPosition:
+		if leaf == position {
+			return nil
+		}
+	case OrderingSorted:
+		if leaf >= 0 && leaf <= position {
+			return nil
+		}
+	case OrderingMap, OrderingSparse:
+		if expected, isSet := o.leaf(position); isSet && expected == leaf {
+			return nil
+		}
+	default:
+		return errors.New(fmt.Sprintf("%s: %s", ErrorOrderingUnknown, o.Strategy))
+	}
+	return errors.New(fmt.Sprintf("%s %s: position %d leaf %d", ErrorOrderingMismatch, o.Strategy, position, leaf))
+}
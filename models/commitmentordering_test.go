@@ -0,0 +1,168 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Return test client commitments for positions
+func testOrderingCommitments(positions ...int32) []ClientCommitment {
+	var commitments []ClientCommitment
+	for _, position := range positions {
+		commitments = append(commitments, ClientCommitment{
+			Commitment:     chainhash.DoubleHashH([]byte{byte(position)}),
+			ClientPosition: position,
+		})
+	}
+	return commitments
+}
+
+// Test commitment ordering strategies validation
+func TestNewCommitmentOrdering(t *testing.T) {
+	ordering, orderingErr := NewCommitmentOrdering("", nil, 0, nil)
+	assert.Equal(t, nil, orderingErr)
+	assert.Equal(t, CommitmentOrdering{Strategy: OrderingPosition}, ordering)
+
+	ordering, orderingErr = NewCommitmentOrdering(OrderingSparse, nil, 0, map[int32]string{0: "a"})
+	assert.Equal(t, nil, orderingErr)
+	assert.Equal(t, DefaultSparseDepth, ordering.Depth)
+
+	_, orderingErr = NewCommitmentOrdering("random", nil, 0, nil)
+	assert.Equal(t, ErrorOrderingUnknown+": random", orderingErr.Error())
+	_, orderingErr = NewCommitmentOrdering(OrderingMap, nil, 0, nil)
+	assert.Equal(t, ErrorOrderingMapEmpty, orderingErr.Error())
+	_, orderingErr = NewCommitmentOrdering(OrderingMap, map[int32]int32{0: -1}, 0, nil)
+	assert.Equal(t, ErrorOrderingLeaf+": -1", orderingErr.Error())
+	_, orderingErr = NewCommitmentOrdering(OrderingMap, map[int32]int32{0: 1, 2: 5, 3: 1}, 0, nil)
+	assert.Equal(t, ErrorOrderingCollision+": 0 3", orderingErr.Error())
+	_, orderingErr = NewCommitmentOrdering(OrderingSparse, nil, MaxSparseDepth+1, map[int32]string{0: "a"})
+	assert.Equal(t, ErrorOrderingDepth+": 21 (max 20)", orderingErr.Error())
+	_, orderingErr = NewCommitmentOrdering(OrderingSparse, nil, 8, nil)
+	assert.Equal(t, ErrorOrderingSlotIds, orderingErr.Error())
+	_, orderingErr = NewCommitmentOrdering(OrderingSparse, nil, 8, map[int32]string{1: ""})
+	assert.Equal(t, ErrorOrderingSlotIds+": 1", orderingErr.Error())
+}
+
+// Test leaves and proofs of client commitments for each strategy
+func TestCommitmentOrderingLeaves(t *testing.T) {
+	commitments := testOrderingCommitments(0, 2, 3)
+
+	position, _ := NewCommitmentOrdering(OrderingPosition, nil, 0, nil)
+	hashes, positions := position.Leaves(commitments)
+	assert.Equal(t, []int32{0, 1, 2, 3}, positions)
+	assert.Equal(t, chainhash.Hash{}, hashes[1])
+
+	sorted, _ := NewCommitmentOrdering(OrderingSorted, nil, 0, nil)
+	hashes, positions = sorted.Leaves(commitments)
+	assert.Equal(t, []int32{0, 2, 3}, positions)
+	assert.Equal(t, commitments[1].Commitment, hashes[1])
+
+	// position 0 not committed to, position 2 at leaf 0 and position 3 at leaf 4
+	mapped, _ := NewCommitmentOrdering(OrderingMap, map[int32]int32{2: 0, 3: 4}, 0, nil)
+	hashes, positions = mapped.Leaves(commitments)
+	assert.Equal(t, []int32{2, -1, -1, -1, 3}, positions)
+	assert.Equal(t, commitments[1].Commitment, hashes[0])
+	assert.Equal(t, commitments[2].Commitment, hashes[4])
+	hashes, positions = mapped.Leaves(testOrderingCommitments(0))
+	assert.Equal(t, 0, len(hashes))
+	assert.Equal(t, 0, len(positions))
+
+	sparse, _ := NewCommitmentOrdering(OrderingSparse, nil, 4, map[int32]string{0: "slot-a", 3: "slot-b"})
+	hashes, positions = sparse.Leaves(commitments)
+	assert.Equal(t, 16, len(hashes))
+	assert.Equal(t, int32(0), positions[SparseLeaf("slot-a", 4)])
+	assert.Equal(t, int32(3), positions[SparseLeaf("slot-b", 4)])
+
+	for _, ordering := range []CommitmentOrdering{position, sorted, mapped, sparse} {
+		commitment, commitmentErr := ordering.Commitment(commitments, "")
+		assert.Equal(t, nil, commitmentErr)
+
+		// proofs and merkle commitments are keyed by client position
+		// and leaves with no client position are skipped
+		proofs := commitment.GetMerkleProofs()
+		merkleCommitments := commitment.GetMerkleCommitments()
+		assert.Equal(t, len(proofs), len(merkleCommitments))
+		for i, proof := range proofs {
+			assert.Equal(t, true, ProveMerkleProof(proof))
+			assert.Equal(t, proof.ClientPosition, merkleCommitments[i].ClientPosition)
+			assert.Equal(t, proof.Commitment, merkleCommitments[i].Commitment)
+			assert.Equal(t, nil, ordering.VerifyLeaf(proof.ClientPosition, proofLeaf(proof.Ops)))
+		}
+	}
+
+	// identity leaves are not stored
+	commitment, _ := position.Commitment(commitments, "")
+	assert.Equal(t, []int32(nil), commitment.Positions())
+	commitment, _ = mapped.Commitment(commitments, "")
+	assert.Equal(t, []int32{2, -1, -1, -1, 3}, commitment.Positions())
+}
+
+// Test verification of proof leaves against the ordering
+func TestCommitmentOrderingVerifyLeaf(t *testing.T) {
+	assert.Equal(t, nil, CommitmentOrdering{}.VerifyLeaf(3, 3))
+	assert.NotEqual(t, nil, CommitmentOrdering{}.VerifyLeaf(3, 2))
+
+	sorted := CommitmentOrdering{Strategy: OrderingSorted}
+	assert.Equal(t, nil, sorted.VerifyLeaf(3, 1))
+	assert.NotEqual(t, nil, sorted.VerifyLeaf(3, 4))
+
+	mapped := CommitmentOrdering{Strategy: OrderingMap, Positions: map[int32]int32{2: 0}}
+	assert.Equal(t, nil, mapped.VerifyLeaf(2, 0))
+	assert.Equal(t, ErrorOrderingMismatch+" map: position 2 leaf 2", mapped.VerifyLeaf(2, 2).Error())
+	assert.NotEqual(t, nil, mapped.VerifyLeaf(0, 0))
+
+	unknownErr := CommitmentOrdering{Strategy: "random"}.VerifyLeaf(0, 0)
+	assert.Equal(t, ErrorOrderingUnknown+": random", unknownErr.Error())
+}
+
+// Test padded merkle trees keep the merkle paths of slots as slots join
+func TestCommitmentOrderingPadded(t *testing.T) {
+	ordering, _ := NewCommitmentOrdering(OrderingPosition, nil, 0, nil)
+	for _, size := range []int{1, 6, 1 << 21} {
+		_, paddedErr := ordering.Padded(size)
+		assert.Equal(t, fmt.Sprintf("%s: %d", ErrorOrderingSize, size), paddedErr.Error())
+	}
+	mapOrdering, _ := NewCommitmentOrdering(OrderingMap, map[int32]int32{0: 2, 3: 8}, 0, nil)
+	_, paddedErr := mapOrdering.Padded(8)
+	assert.Equal(t, ErrorOrderingSize+": 8 (leaf 8)", paddedErr.Error())
+	sparseOrdering, _ := NewCommitmentOrdering(OrderingSparse, nil, 4, map[int32]string{0: "a"})
+	_, paddedErr = sparseOrdering.Padded(8)
+	assert.Equal(t, ErrorOrderingSize+": 8 (depth 4)", paddedErr.Error())
+	unpadded, paddedErr := ordering.Padded(-1)
+	assert.Equal(t, nil, paddedErr)
+	assert.Equal(t, ordering, unpadded)
+
+	padded, paddedErr := ordering.Padded(8)
+	assert.Equal(t, nil, paddedErr)
+	hashes, positions := padded.Leaves(testOrderingCommitments(0, 2, 9))
+	assert.Equal(t, 8, len(hashes))
+	assert.Equal(t, []int32{0, 1, 2, -1, -1, -1, -1, -1}, positions)
+	assert.Equal(t, MerkleNullHash, hashes[7])
+
+	// merkle path of the first slot unchanged as other slots join
+	first, _ := padded.Commitment(testOrderingCommitments(0), "")
+	joined, _ := padded.Commitment(testOrderingCommitments(0, 5), "")
+	firstProof, joinedProof := first.GetMerkleProofs()[0], joined.GetMerkleProofs()[0]
+	assert.Equal(t, 3, len(firstProof.Ops))
+	assert.Equal(t, firstProof.Ops[:2], joinedProof.Ops[:2])
+	assert.Equal(t, true, joinedProof.Ops[2].Append)
+	assert.Equal(t, true, ProveMerkleProof(joinedProof))
+	assert.Equal(t, 6, len(joined.GetMerkleCommitments()))
+
+	sorted, _ := NewCommitmentOrdering(OrderingSorted, nil, 0, nil)
+	sorted, _ = sorted.Padded(2)
+	hashes, positions = sorted.Leaves(testOrderingCommitments(1, 4, 7))
+	assert.Equal(t, 2, len(hashes))
+	assert.Equal(t, []int32{1, 4}, positions)
+
+	assert.Equal(t, nil, padded.VerifyLeaf(5, 5))
+	assert.Equal(t, fmt.Sprintf("%s %s: position 9 leaf 9 size 8", ErrorOrderingMismatch, OrderingPosition),
+		padded.VerifyLeaf(9, 9).Error())
+}
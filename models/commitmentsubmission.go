@@ -0,0 +1,109 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// commitment submission statuses
+const (
+	// submission not included in an attestation yet
+	SubmissionStatusPending = "pending"
+
+	// submission included in the attestation of the merkle root
+	SubmissionStatusIncluded = "included"
+
+	// submission replaced by a later submission of the client position
+	// before being attested, the later submission being included in the
+	// attestation of the merkle root
+	SubmissionStatusSuperseded = "superseded"
+)
+
+// struct for db CommitmentSubmission
+// Record of a client commitment accepted for a client position, tracking
+// the attestation merkle root that eventually included or superseded it.
+// SubmittedAt is unix milliseconds. Duplicate, Txid and Confirmed are not
+// stored and are set in responses only
+type CommitmentSubmission struct {
+	Id             string `bson:"id" json:"id"`
+	ClientPosition int32  `bson:"client_position" json:"position"`
+	Commitment     string `bson:"commitment" json:"commitment"`
+	SubmittedAt    int64  `bson:"submitted_at" json:"submitted_at"`
+	Status         string `bson:"status" json:"status"`
+	MerkleRoot     string `bson:"merkle_root" json:"merkle_root"`
+	Duplicate      bool   `bson:"-" json:"duplicate"`
+	Txid           string `bson:"-" json:"txid"`
+	Confirmed      bool   `bson:"-" json:"confirmed"`
+}
+
+// CommitmentSubmission field names
+const (
+	CommitmentSubmissionIdName             = "id"
+	CommitmentSubmissionClientPositionName = "client_position"
+	CommitmentSubmissionCommitmentName     = "commitment"
+	CommitmentSubmissionSubmittedAtName    = "submitted_at"
+	CommitmentSubmissionStatusName         = "status"
+	CommitmentSubmissionMerkleRootName     = "merkle_root"
+)
+
+// Return new pending submission of client commitment with a random id
+func NewCommitmentSubmission(commitment ClientCommitment) CommitmentSubmission {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return CommitmentSubmission{
+		Id:             hex.EncodeToString(id),
+		ClientPosition: commitment.ClientPosition,
+		Commitment:     commitment.Commitment.String(),
+		SubmittedAt:    commitment.SubmittedAt,
+		Status:         SubmissionStatusPending,
+	}
+}
+
+// Return pending submissions resolved by the merkle commitments of an
+// attestation. Pending submissions, oldest first, of the commitment attested
+// for their client position are included and pending submissions of other
+// commitments made before the latest included one are superseded. Submissions
+// made after the included one remain pending for a later attestation
+func ResolveCommitmentSubmissions(pending []CommitmentSubmission,
+	merkleCommitments []CommitmentMerkleCommitment) []CommitmentSubmission {
+
+	attested := make(map[int32]CommitmentMerkleCommitment)
+	for _, c := range merkleCommitments {
+		if c.Commitment != (chainhash.Hash{}) {
+			attested[c.ClientPosition] = c
+		}
+	}
+
+	// index of the latest included submission of each client position
+	includedAt := make(map[int32]int)
+	for i, submission := range pending {
+		c, ok := attested[submission.ClientPosition]
+		if ok && submission.Commitment == c.Commitment.String() {
+			includedAt[submission.ClientPosition] = i
+		}
+	}
+
+	var resolved []CommitmentSubmission
+	for i, submission := range pending {
+		c, ok := attested[submission.ClientPosition]
+		if !ok {
+			continue
+		}
+		if submission.Commitment == c.Commitment.String() {
+			submission.Status = SubmissionStatusIncluded
+		} else if at, included := includedAt[submission.ClientPosition]; included && i < at {
+			submission.Status = SubmissionStatusSuperseded
+		} else {
+			continue
+		}
+		submission.MerkleRoot = c.MerkleRoot.String()
+		resolved = append(resolved, submission)
+	}
+	return resolved
+}
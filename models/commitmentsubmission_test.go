@@ -0,0 +1,43 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test resolution of pending commitment submissions by attested merkle commitments
+func TestResolveCommitmentSubmissions(t *testing.T) {
+	hash0, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash2, _ := chainhash.NewHashFromStr("3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	submission0 := NewCommitmentSubmission(ClientCommitment{*hash0, 0, "", 1})
+	submission1 := NewCommitmentSubmission(ClientCommitment{*hash1, 0, "", 2})
+	submission2 := NewCommitmentSubmission(ClientCommitment{*hash2, 0, "", 3})
+	submission3 := NewCommitmentSubmission(ClientCommitment{*hash0, 1, "", 1})
+	submission4 := NewCommitmentSubmission(ClientCommitment{*hash0, 2, "", 1})
+	assert.Equal(t, 32, len(submission0.Id))
+	assert.NotEqual(t, submission0.Id, submission1.Id)
+	assert.Equal(t, SubmissionStatusPending, submission0.Status)
+	assert.Equal(t, hash0.String(), submission0.Commitment)
+
+	// position 0 attested with second commitment, position 1 with another
+	// commitment and position 2 not attested
+	commitment, _ := NewCommitment([]chainhash.Hash{*hash1, *hash1})
+	pending := []CommitmentSubmission{submission0, submission1, submission2, submission3, submission4}
+	resolved := ResolveCommitmentSubmissions(pending, commitment.GetMerkleCommitments())
+
+	merkleRoot := commitment.GetCommitmentHash().String()
+	submission0.Status, submission0.MerkleRoot = SubmissionStatusSuperseded, merkleRoot
+	submission1.Status, submission1.MerkleRoot = SubmissionStatusIncluded, merkleRoot
+	assert.Equal(t, []CommitmentSubmission{submission0, submission1}, resolved)
+
+	// nothing resolved without attested commitments
+	assert.Equal(t, 0, len(ResolveCommitmentSubmissions(pending, nil)))
+}
@@ -0,0 +1,30 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+// struct for AttestationDerivation
+// Derivation of the address of a historical attestation, recomputed from the
+// stored commitments of the attestation and the staychain base keys. The
+// commitment is the merkle root used as tweak, the tweak its byte order used
+// for the bip-32 derivation path of each base key and the script the multisig
+// redeem script of the tweaked base keys, which is empty for single key clients
+type AttestationDerivation struct {
+	Txid       string          `json:"txid"`
+	Commitment string          `json:"commitment"`
+	Tweak      string          `json:"tweak"`
+	Path       string          `json:"path"`
+	BaseKeys   []DerivationKey `json:"base_keys"`
+	BaseScript string          `json:"base_script"`
+	Script     string          `json:"script"`
+	Address    string          `json:"address"`
+}
+
+// struct for DerivationKey
+// Base public key and chaincode with the key derived using the tweak path
+type DerivationKey struct {
+	Pubkey    string `json:"pubkey"`
+	Chaincode string `json:"chaincode"`
+	Derived   string `json:"derived"`
+}
@@ -0,0 +1,23 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+// struct for db DryRunAttestation
+// Signed attestation transaction that would have been sent
+// to the network if the service was not running in dry run mode
+type DryRunAttestation struct {
+	Txid       string `bson:"txid"`
+	MerkleRoot string `bson:"merkle_root"`
+	Tx         string `bson:"tx"`
+	Time       int64  `bson:"time"`
+}
+
+// DryRunAttestation field names
+const (
+	DryRunAttestationTxidName       = "txid"
+	DryRunAttestationMerkleRootName = "merkle_root"
+	DryRunAttestationTxName         = "tx"
+	DryRunAttestationTimeName       = "time"
+)
@@ -0,0 +1,38 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+// fee bump methods
+const (
+	FeeBumpMethodRbf  = "rbf"
+	FeeBumpMethodCpfp = "cpfp"
+)
+
+// struct for db FeeBump
+// Record of an attempt to bump the fee of an unconfirmed attestation
+type FeeBump struct {
+	Txid       string `bson:"txid"`
+	MerkleRoot string `bson:"merkle_root"`
+	Attempt    int32  `bson:"attempt"`
+	Method     string `bson:"method"`
+	Strategy   string `bson:"strategy"`
+	PrevFee    int32  `bson:"prev_fee"`
+	Fee        int32  `bson:"fee"`
+	Error      string `bson:"error"`
+	Time       int64  `bson:"time"`
+}
+
+// FeeBump field names
+const (
+	FeeBumpTxidName       = "txid"
+	FeeBumpMerkleRootName = "merkle_root"
+	FeeBumpAttemptName    = "attempt"
+	FeeBumpMethodName     = "method"
+	FeeBumpStrategyName   = "strategy"
+	FeeBumpPrevFeeName    = "prev_fee"
+	FeeBumpFeeName        = "fee"
+	FeeBumpErrorName      = "error"
+	FeeBumpTimeName       = "time"
+)
@@ -0,0 +1,44 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test FeeBump BSON interface
+func TestFeeBumpBSON(t *testing.T) {
+	feeBump := FeeBump{
+		Txid:       "f123434e881d9a1e6cdc3418b54bb57747106bc75e9e84426661f27f98ada3b7",
+		MerkleRoot: "abcde34e881d9a1e6cdc3418b54bb57747106bc75e9e84426661f27f98ada3b7",
+		Attempt:    int32(2),
+		Method:     FeeBumpMethodRbf,
+		Strategy:   "percentage",
+		PrevFee:    int32(10),
+		Fee:        int32(12),
+		Error:      "",
+		Time:       int64(1542121293)}
+
+	// test FeeBump model to document
+	doc, docErr := GetDocumentFromModel(feeBump)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, feeBump.Txid, doc.Lookup(FeeBumpTxidName).StringValue())
+	assert.Equal(t, feeBump.MerkleRoot, doc.Lookup(FeeBumpMerkleRootName).StringValue())
+	assert.Equal(t, feeBump.Attempt, doc.Lookup(FeeBumpAttemptName).Int32())
+	assert.Equal(t, feeBump.Method, doc.Lookup(FeeBumpMethodName).StringValue())
+	assert.Equal(t, feeBump.Strategy, doc.Lookup(FeeBumpStrategyName).StringValue())
+	assert.Equal(t, feeBump.PrevFee, doc.Lookup(FeeBumpPrevFeeName).Int32())
+	assert.Equal(t, feeBump.Fee, doc.Lookup(FeeBumpFeeName).Int32())
+	assert.Equal(t, feeBump.Error, doc.Lookup(FeeBumpErrorName).StringValue())
+	assert.Equal(t, feeBump.Time, doc.Lookup(FeeBumpTimeName).Int64())
+
+	// test reverse document to FeeBump model
+	testFeeBump := &FeeBump{}
+	docErr = GetModelFromDocument(doc, testFeeBump)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, feeBump, *testFeeBump)
+}
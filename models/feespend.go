@@ -0,0 +1,19 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+// struct for attestation FeeSpend
+// Fees in satoshis paid by confirmed attestations in total and in the
+// current calendar day and month, with the budgets of the day and month
+// and whether either budget has been exceeded, pausing attestations
+type FeeSpend struct {
+	Total         int64 `json:"total"`
+	Day           int64 `json:"day"`
+	Month         int64 `json:"month"`
+	DailyBudget   int64 `json:"daily_budget,omitempty"`
+	MonthlyBudget int64 `json:"monthly_budget,omitempty"`
+	Exceeded      bool  `json:"exceeded"`
+	Time          int64 `json:"time"`
+}
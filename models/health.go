@@ -0,0 +1,18 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+// struct for service HealthReport
+// Result of each named health check, which is either ok or the
+// reason the check failed, and the time of the last successful
+// attestation state transition
+type HealthReport struct {
+	Healthy        bool              `json:"healthy"`
+	Checks         map[string]string `json:"checks"`
+	LastTransition int64             `json:"last_transition"`
+	Paused         bool              `json:"paused"`
+	Syncing        bool              `json:"syncing"`
+	Time           int64             `json:"time"`
+}
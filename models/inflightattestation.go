@@ -0,0 +1,42 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+// struct for db InFlightAttestation
+// Attestation in progress stored on shutdown, along with the signatures
+// received and the signature request, to be resumed on restart. For
+// commitments not ordered by client position, the client position and leaf
+// of each commitment and the number of merkle tree leaves are also stored,
+// and the merkle tree hash function if not the default double SHA256
+type InFlightAttestation struct {
+	State       int        `bson:"state"`
+	Tx          string     `bson:"tx"`
+	Commitments []string   `bson:"commitments"`
+	RequestIds  []string   `bson:"request_ids"`
+	Positions   []int32    `bson:"positions,omitempty"`
+	Leaves      []int32    `bson:"leaves,omitempty"`
+	LeafCount   int        `bson:"leaf_count,omitempty"`
+	Hash        string     `bson:"hash,omitempty"`
+	Sigs        [][]string `bson:"sigs"`
+	SigsRequest []string   `bson:"sigs_request"`
+	SigsRetries int        `bson:"sigs_retries"`
+	Time        int64      `bson:"time"`
+}
+
+// InFlightAttestation field names
+const (
+	InFlightAttestationStateName       = "state"
+	InFlightAttestationTxName          = "tx"
+	InFlightAttestationCommitmentsName = "commitments"
+	InFlightAttestationRequestIdsName  = "request_ids"
+	InFlightAttestationPositionsName   = "positions"
+	InFlightAttestationLeavesName      = "leaves"
+	InFlightAttestationLeafCountName   = "leaf_count"
+	InFlightAttestationHashName        = "hash"
+	InFlightAttestationSigsName        = "sigs"
+	InFlightAttestationSigsRequestName = "sigs_request"
+	InFlightAttestationSigsRetriesName = "sigs_retries"
+	InFlightAttestationTimeName        = "time"
+)
@@ -0,0 +1,25 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+// struct for attestation chain IntegrityReport
+// Result of walking all confirmed attestations and verifying each
+// spends the previous one on-chain and commits to the stored commitments
+type IntegrityReport struct {
+	Passed       bool            `json:"passed"`
+	Rounds       int             `json:"rounds"`
+	Inconsistent *IntegrityRound `json:"inconsistent,omitempty"`
+	Time         int64           `json:"time"`
+}
+
+// struct for IntegrityRound
+// First attestation round that failed verification, with
+// rounds numbered from one for the oldest stored attestation
+type IntegrityRound struct {
+	Round      int    `json:"round"`
+	Txid       string `json:"txid"`
+	MerkleRoot string `json:"merkle_root"`
+	Reason     string `json:"reason"`
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+// key rotation status values
+const (
+	// rotation requested and waiting for the next new attestation
+	KeyRotationPending = "pending"
+	// transition attestation paying to the new script sent
+	KeyRotationTransition = "transition"
+	// transition attestation confirmed and new script in use
+	KeyRotationActive = "active"
+	// rotation cancelled by the operator before the transition
+	KeyRotationCancelled = "cancelled"
+)
+
+// struct for db KeyRotation
+// Rotation of the federation multisig redeem script of the staychain. The
+// transition attestation spends the last attestation of the previous script
+// to an address of the new script tweaked with the attestation commitment,
+// and from its confirmation attestations and topups use the new script.
+// Height is the block height of the confirmed transition attestation
+type KeyRotation struct {
+	Id             int64    `bson:"id" json:"id"`
+	InitScript     string   `bson:"init_script" json:"init_script"`
+	InitChaincodes []string `bson:"init_chaincodes" json:"init_chaincodes"`
+	TopupAddress   string   `bson:"topup_address" json:"topup_address"`
+	TopupScript    string   `bson:"topup_script" json:"topup_script"`
+	PrevScript     string   `bson:"prev_script" json:"prev_script"`
+	Status         string   `bson:"status" json:"status"`
+	Txid           string   `bson:"txid" json:"txid"`
+	Commitment     string   `bson:"commitment" json:"commitment"`
+	Requested      int64    `bson:"requested" json:"requested"`
+	Activated      int64    `bson:"activated" json:"activated"`
+	Height         int64    `bson:"height" json:"height"`
+}
+
+// KeyRotation field names
+const (
+	KeyRotationIdName             = "id"
+	KeyRotationInitScriptName     = "init_script"
+	KeyRotationInitChaincodesName = "init_chaincodes"
+	KeyRotationTopupAddressName   = "topup_address"
+	KeyRotationTopupScriptName    = "topup_script"
+	KeyRotationPrevScriptName     = "prev_script"
+	KeyRotationStatusName         = "status"
+	KeyRotationTxidName           = "txid"
+	KeyRotationCommitmentName     = "commitment"
+	KeyRotationRequestedName      = "requested"
+	KeyRotationActivatedName      = "activated"
+	KeyRotationHeightName         = "height"
+)
+
+// Return whether the rotation has been requested and not yet activated or cancelled
+func (r KeyRotation) InProgress() bool {
+	return r.Status == KeyRotationPending || r.Status == KeyRotationTransition
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// merkle tree hash function consts
+const (
+	// double SHA256 of the concatenated child nodes, as bitcoin merkle trees
+	MerkleHashSha256d = "sha256d"
+
+	// single SHA256 of the concatenated child nodes
+	MerkleHashSha256 = "sha256"
+
+	// BIP340 style tagged SHA256 of the concatenated child nodes,
+	// SHA256(SHA256(tag) || SHA256(tag) || left || right)
+	MerkleHashTaggedSha256 = "sha256tagged"
+
+	// tag of the tagged SHA256 merkle tree hash function
+	MerkleHashTag = "mainstay/merkle"
+
+	ErrorMerkleHashUnknown = "Unknown merkle tree hash function"
+)
+
+// SHA256 of the tagged hash tag, prefixed twice to each tagged hash
+var merkleHashTagHash = sha256.Sum256([]byte(MerkleHashTag))
+
+// Check merkle tree hash function is known
+// Empty hash functions are the default double SHA256
+func CheckMerkleHash(hash string) error {
+	switch hash {
+	case "", MerkleHashSha256d, MerkleHashSha256, MerkleHashTaggedSha256:
+		return nil
+	}
+	return errors.New(fmt.Sprintf("%s: %s", ErrorMerkleHashUnknown, hash))
+}
+
+// Return merkle tree hash function name, the default double SHA256 if empty
+func MerkleHashName(hash string) string {
+	if hash == "" {
+		return MerkleHashSha256d
+	}
+	return hash
+}
+
+// Return merkle tree hash function to record with commitments and proofs
+// The default double SHA256 is not recorded, so that commitments and proofs
+// of trees built before hash functions were configurable are unchanged
+func merkleHashRecord(hash string) string {
+	if hash == MerkleHashSha256d {
+		return ""
+	}
+	return hash
+}
+
+// Hash the concatenation of two merkle tree nodes with the merkle tree
+// hash function given
+func hashNodes(hash string, left chainhash.Hash, right chainhash.Hash) *chainhash.Hash {
+	// Concatenate the left and right nodes.
+	var nodes [chainhash.HashSize * 2]byte
+	copy(nodes[:chainhash.HashSize], left[:])
+	copy(nodes[chainhash.HashSize:], right[:])
+
+	var newHash chainhash.Hash
+	switch hash {
+	case MerkleHashSha256:
+		newHash = sha256.Sum256(nodes[:])
+	case MerkleHashTaggedSha256:
+		h := sha256.New()
+		h.Write(merkleHashTagHash[:])
+		h.Write(merkleHashTagHash[:])
+		h.Write(nodes[:])
+		copy(newHash[:], h.Sum(nil))
+	default:
+		newHash = chainhash.DoubleHashH(nodes[:])
+	}
+	return &newHash
+}
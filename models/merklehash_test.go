@@ -0,0 +1,85 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test merkle tree node hashes of each hash function
+func TestMerkleHashNodes(t *testing.T) {
+	hash0, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	nodes := append(hash0.CloneBytes(), hash1.CloneBytes()...)
+
+	assert.Equal(t, hashLeaves(*hash0, *hash1), hashNodes(MerkleHashSha256d, *hash0, *hash1))
+	assert.Equal(t, hashLeaves(*hash0, *hash1), hashNodes("", *hash0, *hash1))
+
+	sha := chainhash.Hash(sha256.Sum256(nodes))
+	assert.Equal(t, &sha, hashNodes(MerkleHashSha256, *hash0, *hash1))
+
+	tag := sha256.Sum256([]byte("mainstay/merkle"))
+	tagged := chainhash.Hash(sha256.Sum256(append(append(tag[:], tag[:]...), nodes...)))
+	assert.Equal(t, &tagged, hashNodes(MerkleHashTaggedSha256, *hash0, *hash1))
+
+	assert.Equal(t, nil, CheckMerkleHash(""))
+	assert.Equal(t, nil, CheckMerkleHash(MerkleHashTaggedSha256))
+	assert.Equal(t, ErrorMerkleHashUnknown+": sha512", CheckMerkleHash("sha512").Error())
+	assert.Equal(t, MerkleHashSha256d, MerkleHashName(""))
+	assert.Equal(t, MerkleHashSha256, MerkleHashName(MerkleHashSha256))
+}
+
+// Test commitments, proofs and bundles record the merkle tree hash function
+func TestMerkleHashCommitment(t *testing.T) {
+	commitments := testAggregatorCommitments(5, 1)
+
+	_, commitmentErr := NewCommitmentHash(commitments, "sha512")
+	assert.Equal(t, ErrorMerkleHashUnknown+": sha512", commitmentErr.Error())
+
+	// default hash function not recorded
+	commitment, _ := NewCommitmentHash(commitments, MerkleHashSha256d)
+	defaultCommitment, _ := NewCommitment(commitments)
+	assert.Equal(t, defaultCommitment, commitment)
+	assert.Equal(t, "", commitment.MerkleHash())
+
+	for _, hash := range []string{MerkleHashSha256, MerkleHashTaggedSha256} {
+		commitment, commitmentErr = NewCommitmentHash(commitments, hash)
+		assert.Equal(t, nil, commitmentErr)
+		assert.Equal(t, hash, commitment.MerkleHash())
+		assert.NotEqual(t, defaultCommitment.GetCommitmentHash(), commitment.GetCommitmentHash())
+
+		for _, merkleCommitment := range commitment.GetMerkleCommitments() {
+			assert.Equal(t, hash, merkleCommitment.Hash)
+		}
+		for _, proof := range commitment.GetMerkleProofs() {
+			assert.Equal(t, hash, proof.Hash)
+			assert.Equal(t, true, ProveMerkleProof(proof))
+
+			bundle := NewProofBundle(proof, AttestationInfo{})
+			assert.Equal(t, hash, bundle.Params.Hash)
+			assert.Equal(t, nil, VerifyProofBundle(bundle))
+			bundle.Params.Hash = MerkleHashSha256d
+			assert.Equal(t, ErrorProofBundleRoot, VerifyProofBundle(bundle).Error())
+		}
+
+		// aggregated trees built with the same hash function
+		aggregator, _ := NewCommitmentAggregator(2)
+		aggregator.SetHash(hash)
+		aggregated, _ := aggregator.Commitment(commitments)
+		assert.Equal(t, commitment.GetCommitmentHash(), aggregated.GetCommitmentHash())
+		assert.Equal(t, commitment.GetMerkleProofs(), aggregated.GetMerkleProofs())
+
+		// slot group member proofs through the group root
+		group, _ := NewSlotGroupHash(1, commitments, hash)
+		assert.Equal(t, commitment.GetCommitmentHash(), group.MerkleRoot)
+		memberProof, _ := group.GetMemberProof(commitments[2])
+		assert.Equal(t, hash, memberProof.Hash)
+		assert.Equal(t, true, ProveMerkleProof(memberProof))
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+// struct for db Migration
+// Record of a database migration applied to the mongo collections
+type Migration struct {
+	Version   int32  `bson:"version"`
+	Name      string `bson:"name"`
+	AppliedAt int64  `bson:"applied_at"`
+}
+
+// Migration field names
+const (
+	MigrationVersionName   = "version"
+	MigrationNameName      = "name"
+	MigrationAppliedAtName = "applied_at"
+)
@@ -0,0 +1,301 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Canonical proof bundle emitted by the proof endpoints of the request api
+// and consumed by proof verification tools. The bundle format is versioned
+// and described by the json schema published by the request api
+//
+// Proof ops are encoded either with an append flag on each op or by the
+// positional convention, where ops carry no flag and the side of each op
+// follows from the bits of the slot leaf, or member position for slot group
+// member ops, from the least significant bit up: a 0 bit appends the op
+// commitment and a 1 bit prepends it. The encoding used is declared in the
+// bundle params and bundles not declaring it use append flags. The leaf of
+// the slot is set if the commitment ordering does not place the slot at its
+// client position
+//
+// Nodes are hashed with the merkle tree hash function of the commitment
+// merkle tree, declared in the bundle params: double SHA256 by default,
+// single SHA256 or BIP340 style tagged SHA256
+
+// proof bundle consts
+const (
+	ProofBundleVersion  = 1
+	ProofBundleProtocol = "mainstay"
+	ProofBundleHash     = MerkleHashSha256d
+	ProofBundleEncoding = "hex_reversed"
+
+	ProofOpsAppend   = "append"
+	ProofOpsPosition = "position"
+
+	ErrorProofBundleVersion   = "Unsupported proof bundle version"
+	ErrorProofBundleParams    = "Unsupported proof bundle params"
+	ErrorProofBundleHash      = "Invalid proof bundle hash"
+	ErrorProofBundleRoot      = "Proof bundle ops do not prove commitment to root"
+	ErrorProofBundleGroupRoot = "Proof bundle member ops do not prove commitment to group root"
+	ErrorProofBundleMemberOps = "Invalid proof bundle member ops"
+	ErrorProofBundleOpsEncode = "Unsupported proof bundle ops encoding"
+	ErrorProofBundleOpsFlags  = "Proof bundle ops do not match ops encoding"
+)
+
+// Json schema of the proof bundle format
+//
+//go:embed proofbundle.schema.json
+var ProofBundleSchema []byte
+
+// ProofBundleBlock structure
+// Block confirming the attestation of a proof bundle, with the spv proof
+// of the attestation transaction in the block, if available
+type ProofBundleBlock struct {
+	Hash   string    `json:"hash"`
+	Time   int64     `json:"time"`
+	Height int64     `json:"height,omitempty"`
+	Spv    *SpvProof `json:"spv,omitempty"`
+}
+
+// ProofBundleGroup structure
+// Slot group of a proof bundle commitment, with the number of leading
+// ops of the bundle proving the commitment to the group root
+type ProofBundleGroup struct {
+	Root           string `json:"root"`
+	MemberPosition int32  `json:"member_position"`
+	MemberOps      int    `json:"member_ops"`
+}
+
+// ProofBundleOp structure
+// Single operation of a proof bundle, with no append flag
+// for bundles using the positional ops encoding
+type ProofBundleOp struct {
+	Append     *bool  `json:"append,omitempty"`
+	Commitment string `json:"commitment"`
+}
+
+// Return append encoded ProofBundleOp list for merkle proof operations
+func newProofBundleOps(ops []CommitmentMerkleProofOp) []ProofBundleOp {
+	bundleOps := []ProofBundleOp{}
+	for _, op := range ops {
+		opAppend := op.Append
+		bundleOps = append(bundleOps, ProofBundleOp{&opAppend, op.Commitment.String()})
+	}
+	return bundleOps
+}
+
+// ProofBundleParams structure
+// Protocol parameters required to verify a proof bundle
+// Preview bundles are proofs in the format of a protocol transition before
+// its switchover, to a root that is not committed to by the transaction
+type ProofBundleParams struct {
+	Protocol string `json:"protocol"`
+	Hash     string `json:"hash"`
+	Encoding string `json:"encoding"`
+	Ops      string `json:"ops,omitempty"`
+	Preview  bool   `json:"preview,omitempty"`
+}
+
+// ProofBundle structure
+// Proof of a commitment in the slot of an attestation merkle root,
+// with the attestation transaction and block confirming it, if any
+type ProofBundle struct {
+	Version    int               `json:"version"`
+	Slot       int32             `json:"slot"`
+	Leaf       *int32            `json:"leaf,omitempty"`
+	Commitment string            `json:"commitment"`
+	Ops        []ProofBundleOp   `json:"ops"`
+	Root       string            `json:"root"`
+	Txid       string            `json:"txid"`
+	Block      *ProofBundleBlock `json:"block"`
+	Group      *ProofBundleGroup `json:"group,omitempty"`
+	Params     ProofBundleParams `json:"params"`
+}
+
+// Return ProofBundle for commitment merkle proof and attestation info of
+// the proof merkle root, with no block if the attestation is not confirmed
+func NewProofBundle(proof CommitmentMerkleProof, info AttestationInfo) ProofBundle {
+	bundle := ProofBundle{
+		Version:    ProofBundleVersion,
+		Slot:       proof.ClientPosition,
+		Commitment: proof.Commitment.String(),
+		Ops:        newProofBundleOps(proof.Ops),
+		Root:       proof.MerkleRoot.String(),
+		Txid:       info.Txid,
+		Params: ProofBundleParams{
+			Protocol: ProofBundleProtocol,
+			Hash:     MerkleHashName(proof.Hash),
+			Encoding: ProofBundleEncoding,
+			Ops:      ProofOpsAppend,
+		},
+	}
+	if leaf := proofLeaf(proof.Ops); leaf != proof.ClientPosition {
+		bundle.Leaf = &leaf
+	}
+	if info.Blockhash != "" {
+		bundle.Block = &ProofBundleBlock{Hash: info.Blockhash, Time: info.Time, Height: info.Height}
+	}
+	return bundle
+}
+
+// Return leaf of the commitment of merkle proof ops, from the side of each op
+func proofLeaf(ops []CommitmentMerkleProofOp) int32 {
+	var leaf int32
+	for i, op := range ops {
+		if !op.Append {
+			leaf |= 1 << i
+		}
+	}
+	return leaf
+}
+
+// Return leaf of the slot commitment of the proof bundle
+func (b ProofBundle) SlotLeaf() int32 {
+	if b.Leaf != nil {
+		return *b.Leaf
+	}
+	return b.Slot
+}
+
+// Return ProofBundle for slot group member proof and slot proof of the group
+// root, with the member ops followed by the slot ops of the group root
+func NewGroupProofBundle(memberProof CommitmentMerkleProof, slotProof CommitmentMerkleProof,
+	info AttestationInfo) ProofBundle {
+
+	bundle := NewProofBundle(slotProof, info)
+	bundle.Commitment = memberProof.Commitment.String()
+	bundle.Ops = append(newProofBundleOps(memberProof.Ops), bundle.Ops...)
+	bundle.Group = &ProofBundleGroup{
+		Root:           slotProof.Commitment.String(),
+		MemberPosition: memberProof.ClientPosition,
+		MemberOps:      len(memberProof.Ops),
+	}
+	return bundle
+}
+
+// Encode proof bundle ops with the append or positional ops encoding
+func (b *ProofBundle) EncodeOps(encoding string) error {
+	if encoding != ProofOpsAppend && encoding != ProofOpsPosition {
+		return errors.New(fmt.Sprintf("%s: %s", ErrorProofBundleOpsEncode, encoding))
+	}
+	sides, sidesErr := proofBundleOpSides(*b)
+	if sidesErr != nil {
+		return sidesErr
+	}
+	for i := range b.Ops {
+		b.Ops[i].Append = nil
+		if encoding == ProofOpsAppend {
+			opAppend := sides[i]
+			b.Ops[i].Append = &opAppend
+		}
+	}
+	b.Params.Ops = encoding
+	return nil
+}
+
+// Return whether each proof bundle op is appended, from either the append
+// flags or the op positions depending on the bundle ops encoding
+func proofBundleOpSides(bundle ProofBundle) ([]bool, error) {
+	memberOps := 0
+	if bundle.Group != nil {
+		if bundle.Group.MemberOps < 0 || bundle.Group.MemberOps > len(bundle.Ops) {
+			return nil, errors.New(ErrorProofBundleMemberOps)
+		}
+		memberOps = bundle.Group.MemberOps
+	}
+
+	sides := make([]bool, len(bundle.Ops))
+	switch bundle.Params.Ops {
+	case "", ProofOpsAppend:
+		for i, op := range bundle.Ops {
+			if op.Append == nil {
+				return nil, errors.New(ErrorProofBundleOpsFlags)
+			}
+			sides[i] = *op.Append
+		}
+	case ProofOpsPosition:
+		position := bundle.SlotLeaf()
+		if bundle.Group != nil {
+			position = bundle.Group.MemberPosition
+		}
+		for i, op := range bundle.Ops {
+			if op.Append != nil {
+				return nil, errors.New(ErrorProofBundleOpsFlags)
+			}
+			if i == memberOps {
+				position = bundle.SlotLeaf()
+			}
+			sides[i] = position&1 == 0
+			position >>= 1
+		}
+	default:
+		return nil, errors.New(fmt.Sprintf("%s: %s", ErrorProofBundleOpsEncode, bundle.Params.Ops))
+	}
+	return sides, nil
+}
+
+// Verify that the proof bundle ops prove the commitment to the root, and
+// to the group root for slot group members, and the spv proof, if any, proves
+// the attestation txid in the block
+// The attestation txid and block must be checked against the chain separately
+func VerifyProofBundle(bundle ProofBundle) error {
+	if bundle.Version != ProofBundleVersion {
+		return errors.New(fmt.Sprintf("%s: %d", ErrorProofBundleVersion, bundle.Version))
+	}
+	if bundle.Params.Protocol != ProofBundleProtocol || bundle.Params.Hash == "" ||
+		CheckMerkleHash(bundle.Params.Hash) != nil || bundle.Params.Encoding != ProofBundleEncoding {
+		return errors.New(ErrorProofBundleParams)
+	}
+
+	hash, hashErr := parseProofBundleHash(bundle.Commitment)
+	if hashErr != nil {
+		return hashErr
+	}
+	sides, sidesErr := proofBundleOpSides(bundle)
+	if sidesErr != nil {
+		return sidesErr
+	}
+	for i, op := range bundle.Ops {
+		if bundle.Group != nil && i == bundle.Group.MemberOps && hash.String() != bundle.Group.Root {
+			return errors.New(ErrorProofBundleGroupRoot)
+		}
+		opHash, opHashErr := parseProofBundleHash(op.Commitment)
+		if opHashErr != nil {
+			return opHashErr
+		}
+		if sides[i] {
+			hash = hashNodes(bundle.Params.Hash, *hash, *opHash)
+		} else {
+			hash = hashNodes(bundle.Params.Hash, *opHash, *hash)
+		}
+	}
+	if bundle.Group != nil && bundle.Group.MemberOps == len(bundle.Ops) && hash.String() != bundle.Group.Root {
+		return errors.New(ErrorProofBundleGroupRoot)
+	}
+	if hash.String() != bundle.Root {
+		return errors.New(ErrorProofBundleRoot)
+	}
+	if bundle.Block != nil && bundle.Block.Spv != nil {
+		return VerifySpvProof(*bundle.Block.Spv, bundle.Txid, bundle.Block.Hash)
+	}
+	return nil
+}
+
+// Parse full length hex encoded proof bundle hash
+func parseProofBundleHash(hashStr string) (*chainhash.Hash, error) {
+	if len(hashStr) != chainhash.MaxHashStringSize {
+		return nil, errors.New(fmt.Sprintf("%s: %s", ErrorProofBundleHash, hashStr))
+	}
+	hash, hashErr := chainhash.NewHashFromStr(hashStr)
+	if hashErr != nil {
+		return nil, errors.New(fmt.Sprintf("%s: %s", ErrorProofBundleHash, hashStr))
+	}
+	return hash, nil
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test proof bundle construction, encoding and verification
+func TestProofBundle(t *testing.T) {
+	hash0, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash2, _ := chainhash.NewHashFromStr("3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	txid, _ := chainhash.NewHashFromStr("4a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	blockhash, _ := chainhash.NewHashFromStr("5a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	// unconfirmed commitment proof
+	commitment, _ := NewCommitment([]chainhash.Hash{*hash0, *hash1, *hash2})
+	proof := commitment.GetMerkleProofs()[1]
+	bundle := NewProofBundle(proof, AttestationInfo{Txid: txid.String()})
+	assert.Equal(t, ProofBundle{
+		Version:    ProofBundleVersion,
+		Slot:       1,
+		Commitment: hash1.String(),
+		Ops:        newProofBundleOps(proof.Ops),
+		Root:       commitment.GetCommitmentHash().String(),
+		Txid:       txid.String(),
+		Params:     ProofBundleParams{Protocol: "mainstay", Hash: "sha256d", Encoding: "hex_reversed", Ops: "append"},
+	}, bundle)
+	assert.Equal(t, nil, VerifyProofBundle(bundle))
+	encoded, _ := json.Marshal(bundle)
+	assert.Contains(t, string(encoded), `"block":null`)
+	assert.NotContains(t, string(encoded), `"group"`)
+
+	// confirmed commitment proof
+	bundle = NewProofBundle(proof, AttestationInfo{Txid: txid.String(), Blockhash: blockhash.String(), Time: 1542121293})
+	assert.Equal(t, &ProofBundleBlock{Hash: blockhash.String(), Time: 1542121293}, bundle.Block)
+	encoded, _ = json.Marshal(bundle)
+	assert.NotContains(t, string(encoded), `"height"`)
+	bundle = NewProofBundle(proof, AttestationInfo{Txid: txid.String(), Blockhash: blockhash.String(), Time: 1542121293, Height: 1000})
+	assert.Equal(t, &ProofBundleBlock{Hash: blockhash.String(), Time: 1542121293, Height: 1000}, bundle.Block)
+
+	var decoded ProofBundle
+	encoded, _ = json.Marshal(bundle)
+	assert.Equal(t, nil, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, bundle, decoded)
+	assert.Equal(t, nil, VerifyProofBundle(decoded))
+
+	// invalid bundles
+	decoded.Version = 2
+	assert.Equal(t, errors.New(ErrorProofBundleVersion+": 2"), VerifyProofBundle(decoded))
+	decoded.Version = ProofBundleVersion
+	decoded.Params.Hash = "sha512"
+	assert.Equal(t, errors.New(ErrorProofBundleParams), VerifyProofBundle(decoded))
+	decoded.Params.Hash = MerkleHashSha256
+	assert.Equal(t, errors.New(ErrorProofBundleRoot), VerifyProofBundle(decoded))
+	decoded.Params.Hash = ProofBundleHash
+	decoded.Commitment = hash0.String()
+	assert.Equal(t, errors.New(ErrorProofBundleRoot), VerifyProofBundle(decoded))
+	decoded.Commitment = "1a39"
+	assert.Equal(t, errors.New(ErrorProofBundleHash+": 1a39"), VerifyProofBundle(decoded))
+	decoded.Commitment = hash1.String()
+	flipped := !*decoded.Ops[0].Append
+	decoded.Ops[0].Append = &flipped
+	assert.Equal(t, errors.New(ErrorProofBundleRoot), VerifyProofBundle(decoded))
+	decoded.Ops[0].Append = nil
+	assert.Equal(t, errors.New(ErrorProofBundleOpsFlags), VerifyProofBundle(decoded))
+	decoded.Params.Ops = "bits"
+	assert.Equal(t, errors.New(ErrorProofBundleOpsEncode+": bits"), VerifyProofBundle(decoded))
+
+	// slot group member proof
+	group, _ := NewSlotGroup(1, []chainhash.Hash{*hash1, *hash2})
+	memberProof, _ := group.GetMemberProof(*hash2)
+	slotCommitment, _ := NewCommitment([]chainhash.Hash{*hash0, group.MerkleRoot})
+	slotProof := slotCommitment.GetMerkleProofs()[1]
+	bundle = NewGroupProofBundle(memberProof, slotProof, AttestationInfo{Txid: txid.String()})
+	assert.Equal(t, int32(1), bundle.Slot)
+	assert.Equal(t, hash2.String(), bundle.Commitment)
+	assert.Equal(t, slotCommitment.GetCommitmentHash().String(), bundle.Root)
+	assert.Equal(t, &ProofBundleGroup{Root: group.MerkleRoot.String(), MemberPosition: 1, MemberOps: 1}, bundle.Group)
+	prepend := false
+	assert.Equal(t, []ProofBundleOp{
+		{Append: &prepend, Commitment: hash1.String()},
+		{Append: &prepend, Commitment: hash0.String()},
+	}, bundle.Ops)
+	assert.Equal(t, nil, VerifyProofBundle(bundle))
+
+	bundle.Group.Root = hash0.String()
+	assert.Equal(t, errors.New(ErrorProofBundleGroupRoot), VerifyProofBundle(bundle))
+	bundle.Group.MemberOps = 3
+	assert.Equal(t, errors.New(ErrorProofBundleMemberOps), VerifyProofBundle(bundle))
+
+	// positional ops encoding
+	for _, position := range []int{0, 1, 2} {
+		bundle = NewProofBundle(commitment.GetMerkleProofs()[position], AttestationInfo{Txid: txid.String()})
+		appendOps := append([]ProofBundleOp{}, bundle.Ops...)
+		assert.Equal(t, nil, bundle.EncodeOps(ProofOpsPosition))
+		assert.Equal(t, ProofOpsPosition, bundle.Params.Ops)
+		for _, op := range bundle.Ops {
+			assert.Equal(t, (*bool)(nil), op.Append)
+		}
+		encoded, _ = json.Marshal(bundle)
+		assert.NotContains(t, string(encoded), `"append"`)
+		assert.Contains(t, string(encoded), `"ops":"position"`)
+		assert.Equal(t, nil, VerifyProofBundle(bundle))
+
+		assert.Equal(t, nil, bundle.EncodeOps(ProofOpsAppend))
+		assert.Equal(t, appendOps, bundle.Ops)
+	}
+	bundle.Slot = 0
+	assert.Equal(t, nil, bundle.EncodeOps(ProofOpsPosition))
+	assert.Equal(t, errors.New(ErrorProofBundleRoot), VerifyProofBundle(bundle))
+	assert.Equal(t, errors.New(ErrorProofBundleOpsEncode+": bits"), bundle.EncodeOps("bits"))
+
+	bundle = NewGroupProofBundle(memberProof, slotProof, AttestationInfo{Txid: txid.String()})
+	assert.Equal(t, nil, bundle.EncodeOps(ProofOpsPosition))
+	assert.Equal(t, nil, VerifyProofBundle(bundle))
+	bundle.Group.MemberPosition = 0
+	assert.Equal(t, errors.New(ErrorProofBundleGroupRoot), VerifyProofBundle(bundle))
+
+	// bundles not declaring the ops encoding use append flags
+	bundle = NewProofBundle(proof, AttestationInfo{Txid: txid.String()})
+	bundle.Params.Ops = ""
+	assert.Equal(t, nil, VerifyProofBundle(bundle))
+
+	// published schema is valid json
+	var schema map[string]interface{}
+	assert.Equal(t, nil, json.Unmarshal(ProofBundleSchema, &schema))
+	assert.Equal(t, float64(ProofBundleVersion),
+		schema["properties"].(map[string]interface{})["version"].(map[string]interface{})["const"])
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// protocol transition consts
+const (
+	DefaultTransitionRounds = 10
+
+	ErrorTransitionHash       = "Invalid transition merkle tree hash function"
+	ErrorTransitionSwitchover = "Invalid transition switchover round"
+	ErrorTransitionRounds     = "Invalid transition dual running rounds"
+)
+
+// ProtocolTransition structure
+// Transition of the commitment merkle tree hash function at a switchover
+// round, the number of the attestation from which the next hash function is
+// attested, attestations being numbered from 1 in order of confirmation.
+// For the dual running rounds before the switchover, proofs are produced in
+// both the attested format and the next format, so that client verifiers can
+// migrate before the switchover
+type ProtocolTransition struct {
+	Hash       string `json:"hash"`
+	Switchover int64  `json:"switchover_round"`
+	Rounds     int64  `json:"rounds"`
+}
+
+// Return ProtocolTransition to the hash function at the switchover round
+// with dual running for the rounds given, the default rounds if negative
+func NewProtocolTransition(hash string, switchover int64, rounds int64) (ProtocolTransition, error) {
+	if hash == "" || CheckMerkleHash(hash) != nil {
+		return ProtocolTransition{}, errors.New(fmt.Sprintf("%s: %s", ErrorTransitionHash, hash))
+	}
+	if switchover <= 0 {
+		return ProtocolTransition{}, errors.New(fmt.Sprintf("%s: %d", ErrorTransitionSwitchover, switchover))
+	}
+	if rounds < 0 {
+		rounds = DefaultTransitionRounds
+	}
+	return ProtocolTransition{Hash: hash, Switchover: switchover, Rounds: rounds}, nil
+}
+
+// Return whether the next hash function is attested in the round
+func (t ProtocolTransition) Switched(round int64) bool {
+	return round >= t.Switchover
+}
+
+// Return whether proofs are produced in both formats in the round
+func (t ProtocolTransition) DualRunning(round int64) bool {
+	return !t.Switched(round) && round >= t.Switchover-t.Rounds
+}
+
+// Return merkle tree hash function attested in the round, the next hash
+// function from the switchover round and the current one before
+func (t ProtocolTransition) MerkleHash(round int64, current string) string {
+	if t.Switched(round) {
+		return t.Hash
+	}
+	return current
+}
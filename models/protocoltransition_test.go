@@ -0,0 +1,44 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test protocol transition rounds and hash functions
+func TestProtocolTransition(t *testing.T) {
+	_, err := NewProtocolTransition("", 10, 2)
+	assert.Equal(t, ErrorTransitionHash+": ", err.Error())
+	_, err = NewProtocolTransition("sha512", 10, 2)
+	assert.Equal(t, ErrorTransitionHash+": sha512", err.Error())
+	_, err = NewProtocolTransition(MerkleHashSha256, 0, 2)
+	assert.Equal(t, ErrorTransitionSwitchover+": 0", err.Error())
+
+	transition, err := NewProtocolTransition(MerkleHashSha256, 100, -1)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, ProtocolTransition{MerkleHashSha256, 100, DefaultTransitionRounds}, transition)
+
+	transition, err = NewProtocolTransition(MerkleHashTaggedSha256, 10, 2)
+	assert.Equal(t, nil, err)
+	for round, expected := range map[int64][3]bool{
+		1:  {false, false, false},
+		7:  {false, false, false},
+		8:  {false, true, false},
+		9:  {false, true, false},
+		10: {true, false, true},
+		11: {true, false, true},
+	} {
+		assert.Equal(t, expected[0], transition.Switched(round), round)
+		assert.Equal(t, expected[1], transition.DualRunning(round), round)
+		hash := transition.MerkleHash(round, "")
+		assert.Equal(t, expected[2], hash == MerkleHashTaggedSha256, round)
+		if !expected[2] {
+			assert.Equal(t, "", hash)
+		}
+	}
+}
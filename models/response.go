@@ -0,0 +1,277 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Typed responses of the request api
+// Every api response is one of the Response or ErrorResponse envelopes
+// with the typed result of the request in the response field
+
+// Response envelope for successful requests
+type Response struct {
+	Response interface{} `json:"response"`
+}
+
+// ErrorResponse envelope for failed requests
+// with the error message, the machine-readable code of the
+// error and the id of the request, as in the X-Request-ID header
+type ErrorResponse struct {
+	Error     string `json:"error"`
+	Code      string `json:"code"`
+	RequestId string `json:"request_id,omitempty"`
+}
+
+// AttestationResponse structure
+// Attestation transaction and the merkle root it commits to, with the
+// block confirming it and the fee paid in satoshis once confirmed
+type AttestationResponse struct {
+	Txid       string `json:"txid"`
+	MerkleRoot string `json:"merkle_root"`
+	Confirmed  bool   `json:"confirmed"`
+	Time       int64  `json:"time"`
+	Height     int64  `json:"height,omitempty"`
+	Blockhash  string `json:"blockhash,omitempty"`
+	BlockTime  int64  `json:"block_time,omitempty"`
+	Fee        int64  `json:"fee,omitempty"`
+}
+
+// Return AttestationResponse for attestation
+func NewAttestationResponse(attestation Attestation) AttestationResponse {
+	response := AttestationResponse{
+		Txid:       attestation.Txid.String(),
+		MerkleRoot: attestation.CommitmentHash().String(),
+		Confirmed:  attestation.Confirmed,
+		Time:       attestation.Info.Time,
+	}
+	if attestation.Confirmed {
+		response.Height = attestation.Info.Height
+		response.Blockhash = attestation.Info.Blockhash
+		response.BlockTime = attestation.Info.BlockTime
+		response.Fee = attestation.Info.Fee
+	}
+	return response
+}
+
+// Return AttestationResponse for stored attestation document
+func NewAttestationBSONResponse(attestation AttestationBSON) AttestationResponse {
+	response := AttestationResponse{
+		Txid:       attestation.Txid,
+		MerkleRoot: attestation.MerkleRoot,
+		Confirmed:  attestation.Confirmed,
+		Time:       attestation.InsertedAt.Unix(),
+		Height:     attestation.BlockHeight,
+		Blockhash:  attestation.BlockHash,
+		Fee:        attestation.Fee,
+	}
+	if attestation.ConfirmedAt != nil {
+		response.BlockTime = attestation.ConfirmedAt.Unix()
+	}
+	return response
+}
+
+// ProofOpResponse structure
+// Single operation of a merkle proof
+type ProofOpResponse struct {
+	Append     bool   `json:"append"`
+	Commitment string `json:"commitment"`
+}
+
+// Return ProofOpResponse list for merkle proof operations
+func NewProofOpsResponse(ops []CommitmentMerkleProofOp) []ProofOpResponse {
+	opsResponse := []ProofOpResponse{}
+	for _, op := range ops {
+		opsResponse = append(opsResponse, ProofOpResponse{op.Append, op.Commitment.String()})
+	}
+	return opsResponse
+}
+
+// CommitmentProofResponse structure
+// Merkle proof of a client commitment to the attestation merkle root
+type CommitmentProofResponse struct {
+	ClientPosition int32             `json:"client_position"`
+	Commitment     string            `json:"commitment"`
+	MerkleRoot     string            `json:"merkle_root"`
+	Ops            []ProofOpResponse `json:"ops"`
+}
+
+// Return CommitmentProofResponse for commitment merkle proof
+func NewCommitmentProofResponse(proof CommitmentMerkleProof) CommitmentProofResponse {
+	return CommitmentProofResponse{
+		ClientPosition: proof.ClientPosition,
+		Commitment:     proof.Commitment.String(),
+		MerkleRoot:     proof.MerkleRoot.String(),
+		Ops:            NewProofOpsResponse(proof.Ops),
+	}
+}
+
+// ProofBinaryResponse structure
+// Base64 encoded compact binary merkle proof of a client commitment with
+// the attestation transaction committing to its root
+type ProofBinaryResponse struct {
+	Proof     string `json:"proof"`
+	Txid      string `json:"txid"`
+	Confirmed bool   `json:"confirmed"`
+}
+
+// ProtocolResponse structure
+// Protocol parameters of the proof bundles returned by the request api
+// and the protocol transition, if any, with its switchover round
+type ProtocolResponse struct {
+	Version    int                 `json:"version"`
+	Protocol   string              `json:"protocol"`
+	Hash       string              `json:"hash"`
+	Encoding   string              `json:"encoding"`
+	Ops        string              `json:"ops"`
+	Ordering   CommitmentOrdering  `json:"ordering"`
+	Transition *TransitionResponse `json:"transition,omitempty"`
+}
+
+// TransitionResponse structure
+// Protocol transition with the current round, the number of the next
+// attestation, and whether proofs are produced in both formats
+type TransitionResponse struct {
+	ProtocolTransition
+	Round       int64 `json:"round"`
+	DualRunning bool  `json:"dual_running"`
+}
+
+// StateResponse structure
+// Operator controlled state of the attestation service
+type StateResponse struct {
+	Paused bool `json:"paused"`
+}
+
+// ClientHmacResponse structure
+// Hmac secret issued for a client position
+type ClientHmacResponse struct {
+	ClientPosition int32  `json:"client_position"`
+	HmacSecret     string `json:"hmac_secret"`
+}
+
+// ClientGroupResponse structure
+// Slot group flag of a client position
+type ClientGroupResponse struct {
+	ClientPosition int32 `json:"client_position"`
+	SlotGroup      bool  `json:"slot_group"`
+}
+
+// ClientDeliveryResponse structure
+// Proof delivery target of a client position
+type ClientDeliveryResponse struct {
+	ClientPosition int32  `json:"client_position"`
+	DeliveryUrl    string `json:"delivery_url"`
+}
+
+// KeyRotationsResponse structure
+// Rotations of the federation keys of the staychain, oldest first
+type KeyRotationsResponse struct {
+	Rotations []KeyRotation `json:"rotations"`
+}
+
+// SignerHealth structure
+// Liveness of a federation signer with its health
+type SignerHealth struct {
+	SignerStatus
+	Health string `json:"health"`
+}
+
+// SignersResponse structure
+// Liveness of the federation signers in redeem script order
+type SignersResponse struct {
+	Signers []SignerHealth `json:"signers"`
+}
+
+// ReviewResponse structure
+// Attestation held for operator review before broadcast, if any
+type ReviewResponse struct {
+	Pending bool               `json:"pending"`
+	Review  *AttestationReview `json:"review,omitempty"`
+}
+
+// Responses of the routes compatible with the hosted mainstay api
+// Hosted responses are wrapped in the HostedResponse envelope, with merkle
+// proof ops always carrying append flags, so that client libraries of the
+// hosted api can be pointed at a self-hosted instance
+
+// time layout of hosted api attestation insertion times
+const HostedTimeLayout = "15:04:05 02/01/2006"
+
+// HostedResponse envelope for successful hosted api requests
+// with the time of the response in unix milliseconds
+type HostedResponse struct {
+	Response  interface{} `json:"response"`
+	Timestamp int64       `json:"timestamp"`
+}
+
+// HostedAttestationResponse structure
+// Attestation of a commitment in the hosted api format
+type HostedAttestationResponse struct {
+	MerkleRoot string `json:"merkle_root"`
+	Txid       string `json:"txid"`
+	Confirmed  bool   `json:"confirmed"`
+	InsertedAt string `json:"inserted_at"`
+}
+
+// Return HostedAttestationResponse for the attestation info of a merkle root
+// The insertion time is the time of the block confirming the attestation,
+// empty if not confirmed
+func NewHostedAttestationResponse(merkleRoot chainhash.Hash, info AttestationInfo) HostedAttestationResponse {
+	var insertedAt string
+	if info.Blockhash != "" && info.Time > 0 {
+		insertedAt = time.Unix(info.Time, 0).UTC().Format(HostedTimeLayout)
+	}
+	return HostedAttestationResponse{
+		MerkleRoot: merkleRoot.String(),
+		Txid:       info.Txid,
+		Confirmed:  info.Blockhash != "",
+		InsertedAt: insertedAt,
+	}
+}
+
+// HostedMerkleProofResponse structure
+// Merkle proof of a client commitment in the hosted api format
+type HostedMerkleProofResponse struct {
+	Position   int32             `json:"position"`
+	MerkleRoot string            `json:"merkle_root"`
+	Commitment string            `json:"commitment"`
+	Ops        []ProofOpResponse `json:"ops"`
+}
+
+// Return HostedMerkleProofResponse for commitment merkle proof
+func NewHostedMerkleProofResponse(proof CommitmentMerkleProof) HostedMerkleProofResponse {
+	return HostedMerkleProofResponse{
+		Position:   proof.ClientPosition,
+		MerkleRoot: proof.MerkleRoot.String(),
+		Commitment: proof.Commitment.String(),
+		Ops:        NewProofOpsResponse(proof.Ops),
+	}
+}
+
+// HostedCommitmentResponse structure
+// Attestation and merkle proof of a client commitment
+type HostedCommitmentResponse struct {
+	Attestation HostedAttestationResponse `json:"attestation"`
+	MerkleProof HostedMerkleProofResponse `json:"merkleproof"`
+}
+
+// HostedLatestProofResponse structure
+// Merkle proof of a client position in the latest confirmed attestation
+type HostedLatestProofResponse struct {
+	Txid       string            `json:"txid"`
+	Commitment string            `json:"commitment"`
+	MerkleRoot string            `json:"merkle_root"`
+	Ops        []ProofOpResponse `json:"ops"`
+}
+
+// HostedVerifyResponse structure
+// Whether a client commitment is in a confirmed attestation
+type HostedVerifyResponse struct {
+	Confirmed bool `json:"confirmed"`
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Return json encoding of value as string
+func encodeJson(t *testing.T, value interface{}) string {
+	encoded, err := json.Marshal(value)
+	assert.Equal(t, nil, err)
+	return string(encoded)
+}
+
+// Test typed api responses JSON encoding
+func TestResponses(t *testing.T) {
+	hash0, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	txid, _ := chainhash.NewHashFromStr("f123434e881d9a1e6cdc3418b54bb57747106bc75e9e84426661f27f98ada3b7")
+
+	// envelopes
+	assert.Equal(t, `{"response":"ok"}`, encodeJson(t, Response{Response: "ok"}))
+	assert.Equal(t, `{"error":"failed","code":""}`, encodeJson(t, ErrorResponse{Error: "failed"}))
+	assert.Equal(t, `{"error":"failed","code":"internal_error","request_id":"abc"}`,
+		encodeJson(t, ErrorResponse{Error: "failed", Code: "internal_error", RequestId: "abc"}))
+
+	// attestation response
+	commitment, _ := NewCommitment([]chainhash.Hash{*hash0, *hash1})
+	attestation := NewAttestation(*txid, commitment)
+	attestation.Confirmed = true
+	attestation.Info.Time = 1542121293
+	assert.Equal(t, AttestationResponse{
+		Txid:       txid.String(),
+		MerkleRoot: commitment.GetCommitmentHash().String(),
+		Confirmed:  true,
+		Time:       1542121293,
+	}, NewAttestationResponse(*attestation))
+	assert.Equal(t, `{"txid":"`+txid.String()+`","merkle_root":"`+commitment.GetCommitmentHash().String()+
+		`","confirmed":true,"time":1542121293}`, encodeJson(t, NewAttestationResponse(*attestation)))
+
+	// attestation response with block and fee
+	attestation.Info = AttestationInfo{Txid: txid.String(), Blockhash: hash1.String(), Time: 1542121293,
+		Height: 1000, BlockTime: 1542121400, Fee: 1234}
+	attestationJson := `{"txid":"` + txid.String() + `","merkle_root":"` + commitment.GetCommitmentHash().String() +
+		`","confirmed":true,"time":1542121293,"height":1000,"blockhash":"` + hash1.String() +
+		`","block_time":1542121400,"fee":1234}`
+	assert.Equal(t, attestationJson, encodeJson(t, NewAttestationResponse(*attestation)))
+	assert.Equal(t, attestationJson, encodeJson(t, NewAttestationBSONResponse(NewAttestationBSON(*attestation))))
+
+	// commitment proof response
+	proof := commitment.GetMerkleProofs()[1]
+	proofResponse := NewCommitmentProofResponse(proof)
+	assert.Equal(t, int32(1), proofResponse.ClientPosition)
+	assert.Equal(t, hash1.String(), proofResponse.Commitment)
+	assert.Equal(t, commitment.GetCommitmentHash().String(), proofResponse.MerkleRoot)
+	assert.Equal(t, []ProofOpResponse{{Append: false, Commitment: hash0.String()}}, proofResponse.Ops)
+	assert.Equal(t, `{"client_position":1,"commitment":"`+hash1.String()+`","merkle_root":"`+
+		commitment.GetCommitmentHash().String()+`","ops":[{"append":false,"commitment":"`+hash0.String()+`"}]}`,
+		encodeJson(t, proofResponse))
+
+	// proof ops are never encoded as null
+	assert.Equal(t, `[]`, encodeJson(t, NewProofOpsResponse(nil)))
+
+	// operator responses
+	assert.Equal(t, `{"paused":true}`, encodeJson(t, StateResponse{Paused: true}))
+	assert.Equal(t, `{"client_position":2,"hmac_secret":"abcd"}`,
+		encodeJson(t, ClientHmacResponse{ClientPosition: 2, HmacSecret: "abcd"}))
+	assert.Equal(t, `{"client_position":2,"slot_group":false}`,
+		encodeJson(t, ClientGroupResponse{ClientPosition: 2, SlotGroup: false}))
+}
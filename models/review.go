@@ -0,0 +1,17 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+// struct for AttestationReview
+// Signed attestation held for operator review before broadcast
+// and the start and end times of the review window
+type AttestationReview struct {
+	Txid       string `json:"txid"`
+	Commitment string `json:"commitment"`
+	Tx         string `json:"tx"`
+	Start      int64  `json:"start"`
+	Deadline   int64  `json:"deadline"`
+	Vetoed     bool   `json:"vetoed"`
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// struct for db RoundSnapshot
+// Immutable record of the client commitments read by an attestation round,
+// ordered by client position, and the merkle root computed from these. The
+// round id is the round close time in unix milliseconds. Positions without
+// a commitment are zero hashes in the merkle tree and are not listed
+type RoundSnapshot struct {
+	Round       int64                     `bson:"round" json:"round"`
+	MerkleRoot  string                    `bson:"merkle_root" json:"merkle_root"`
+	Commitments []RoundSnapshotCommitment `bson:"commitments" json:"commitments"`
+	Hash        string                    `bson:"hash,omitempty" json:"hash,omitempty"`
+}
+
+// RoundSnapshotCommitment struct
+// Commitment of a client position read by an attestation round
+type RoundSnapshotCommitment struct {
+	ClientPosition int32  `bson:"client_position" json:"position"`
+	Commitment     string `bson:"commitment" json:"commitment"`
+}
+
+// RoundSnapshot field names
+const (
+	RoundSnapshotRoundName       = "round"
+	RoundSnapshotMerkleRootName  = "merkle_root"
+	RoundSnapshotCommitmentsName = "commitments"
+	RoundSnapshotHashName        = "hash"
+)
+
+// Return snapshot of round with the client commitments ordered by client position
+func NewRoundSnapshot(round int64, commitments []ClientCommitment, merkleRoot chainhash.Hash) RoundSnapshot {
+	snapshot := RoundSnapshot{Round: round, MerkleRoot: merkleRoot.String(),
+		Commitments: []RoundSnapshotCommitment{}}
+	for _, c := range commitments {
+		snapshot.Commitments = append(snapshot.Commitments,
+			RoundSnapshotCommitment{ClientPosition: c.ClientPosition, Commitment: c.Commitment.String()})
+	}
+	return snapshot
+}
+
+// Return merkle root recomputed from the snapshot commitments placed at
+// the leaves of the commitment ordering, with the snapshot hash function
+func (s RoundSnapshot) ComputeMerkleRoot(ordering CommitmentOrdering) (chainhash.Hash, error) {
+	var commitments []ClientCommitment
+	for _, c := range s.Commitments {
+		commitmentHash, hashErr := chainhash.NewHashFromStr(c.Commitment)
+		if hashErr != nil {
+			return chainhash.Hash{}, hashErr
+		}
+		commitments = append(commitments, ClientCommitment{Commitment: *commitmentHash, ClientPosition: c.ClientPosition})
+	}
+	commitment, commitmentErr := ordering.Commitment(commitments, s.Hash)
+	if commitmentErr != nil {
+		return chainhash.Hash{}, commitmentErr
+	}
+	return commitment.GetCommitmentHash(), nil
+}
+
+// RoundSnapshots struct
+// Page of round snapshots, newest first. Total is the number of snapshots
+// across all pages
+type RoundSnapshots struct {
+	Total  int64           `json:"total"`
+	Offset int64           `json:"offset"`
+	Limit  int64           `json:"limit"`
+	Rounds []RoundSnapshot `json:"rounds"`
+}
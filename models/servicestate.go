@@ -0,0 +1,20 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+// struct for db ServiceState
+// Operator controlled state of a service that persists across restarts
+type ServiceState struct {
+	Name   string `bson:"name"`
+	Paused bool   `bson:"paused"`
+	Time   int64  `bson:"time"`
+}
+
+// ServiceState field names
+const (
+	ServiceStateNameName   = "name"
+	ServiceStatePausedName = "paused"
+	ServiceStateTimeName   = "time"
+)
@@ -0,0 +1,53 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+// signer health values
+const (
+	// no signatures requested from the signer yet
+	SignerHealthUnknown = "unknown"
+	// valid signature received for the last signature request
+	SignerHealthOnline = "online"
+	// signature missing for the last signature request
+	SignerHealthLagging = "lagging"
+	// signatures missing for SignerOfflineMisses requests in a row
+	SignerHealthOffline = "offline"
+)
+
+// number of signature requests missed in a row before a signer is offline
+const SignerOfflineMisses = 3
+
+// struct for db SignerStatus
+// Liveness of a federation signer, identified by its base pubkey in the
+// redeem script. Signers are seen when a valid signature of theirs is
+// received for an attestation signature request
+type SignerStatus struct {
+	Pubkey      string `bson:"pubkey" json:"pubkey"`
+	Position    int    `bson:"position" json:"position"`
+	LastRequest int64  `bson:"last_request" json:"last_request"`
+	LastSeen    int64  `bson:"last_seen" json:"last_seen"`
+	Missed      int    `bson:"missed" json:"missed"`
+}
+
+// SignerStatus field names
+const (
+	SignerStatusPubkeyName      = "pubkey"
+	SignerStatusPositionName    = "position"
+	SignerStatusLastRequestName = "last_request"
+	SignerStatusLastSeenName    = "last_seen"
+	SignerStatusMissedName      = "missed"
+)
+
+// Return health of the signer from the signature requests missed in a row
+func (s SignerStatus) Health() string {
+	if s.LastRequest == 0 {
+		return SignerHealthUnknown
+	} else if s.Missed == 0 {
+		return SignerHealthOnline
+	} else if s.Missed < SignerOfflineMisses {
+		return SignerHealthLagging
+	}
+	return SignerHealthOffline
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// slot group limits
+const (
+	SlotGroupMaxMembers = 4096
+)
+
+// error consts
+const (
+	ErrorSlotGroupEmpty          = "Slot group has no members"
+	ErrorSlotGroupTooLarge       = "Slot group has too many members"
+	ErrorSlotGroupMemberNotFound = "Commitment not found in slot group"
+)
+
+// SlotGroup structure
+// Members of a slot group share the leaf of the group client position
+// This leaf is the merkle root of the member commitments, maintained by
+// an external aggregator, and member proofs are two-level proofs going
+// through the group merkle root to the attestation merkle root. Hash is the
+// merkle tree hash function, empty for the default double SHA256
+type SlotGroup struct {
+	ClientPosition int32
+	MerkleRoot     chainhash.Hash
+	Commitments    []chainhash.Hash
+	Hash           string
+}
+
+// Return new SlotGroup instance for client position from member commitments
+func NewSlotGroup(position int32, commitments []chainhash.Hash) (*SlotGroup, error) {
+	return NewSlotGroupHash(position, commitments, MerkleHashSha256d)
+}
+
+// Return new SlotGroup instance for client position from member commitments
+// with the merkle tree hash function given
+func NewSlotGroupHash(position int32, commitments []chainhash.Hash, hash string) (*SlotGroup, error) {
+	if hashErr := CheckMerkleHash(hash); hashErr != nil {
+		return nil, hashErr
+	}
+	if len(commitments) == 0 {
+		return nil, errors.New(ErrorSlotGroupEmpty)
+	} else if len(commitments) > SlotGroupMaxMembers {
+		return nil, errors.New(fmt.Sprintf("%s: %d", ErrorSlotGroupTooLarge, len(commitments)))
+	}
+	tree := NewCommitmentMerkleTreeHash(commitments, hash)
+	return &SlotGroup{position, tree.getMerkleRoot(), tree.getMerkleCommitments(), tree.getMerkleHash()}, nil
+}
+
+// Return proof of member commitment in the slot group merkle tree
+// The proof client position is the position of the member in the group
+func (g SlotGroup) GetMemberProof(commitment chainhash.Hash) (CommitmentMerkleProof, error) {
+	for i := range g.Commitments {
+		if g.Commitments[i] == commitment {
+			proof := buildMerkleProof(i, buildMerkleTreeHash(g.Commitments, g.Hash))
+			proof.Hash = g.Hash
+			return proof, nil
+		}
+	}
+	return CommitmentMerkleProof{}, errors.New(ErrorSlotGroupMemberNotFound)
+}
+
+// Implement bson.Marshaler MarshalBSON() method for use with db_mongo interface
+func (g SlotGroup) MarshalBSON() ([]byte, error) {
+	groupBSON := SlotGroupBSON{ClientPosition: g.ClientPosition, MerkleRoot: g.MerkleRoot.String(), Hash: g.Hash}
+	for _, commitment := range g.Commitments {
+		groupBSON.Commitments = append(groupBSON.Commitments, commitment.String())
+	}
+	return bson.Marshal(groupBSON)
+}
+
+// Implement bson.Unmarshaler UnmarshalBSON() method for use with db_mongo interface
+func (g *SlotGroup) UnmarshalBSON(b []byte) error {
+	var groupBSON SlotGroupBSON
+	if err := bson.Unmarshal(b, &groupBSON); err != nil {
+		return err
+	}
+	rootHash, errHash := chainhash.NewHashFromStr(groupBSON.MerkleRoot)
+	if errHash != nil {
+		return errHash
+	}
+
+	var commitments []chainhash.Hash
+	for _, commitmentStr := range groupBSON.Commitments {
+		commitmentHash, errHash := chainhash.NewHashFromStr(commitmentStr)
+		if errHash != nil {
+			return errHash
+		}
+		commitments = append(commitments, *commitmentHash)
+	}
+
+	g.ClientPosition = groupBSON.ClientPosition
+	g.MerkleRoot = *rootHash
+	g.Commitments = commitments
+	g.Hash = groupBSON.Hash
+	return nil
+}
+
+// SlotGroup field names
+const (
+	SlotGroupClientPositionName = "client_position"
+	SlotGroupMerkleRootName     = "merkle_root"
+	SlotGroupCommitmentsName    = "commitments"
+	SlotGroupHashName           = "hash"
+)
+
+// SlotGroupBSON structure for mongoDB
+type SlotGroupBSON struct {
+	ClientPosition int32    `bson:"client_position"`
+	MerkleRoot     string   `bson:"merkle_root"`
+	Commitments    []string `bson:"commitments"`
+	Hash           string   `bson:"hash,omitempty"`
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Test SlotGroup member proofs and BSON interface
+func TestSlotGroup(t *testing.T) {
+	hash0, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash2, _ := chainhash.NewHashFromStr("3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash3, _ := chainhash.NewHashFromStr("4a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	_, groupErr := NewSlotGroup(2, []chainhash.Hash{})
+	assert.Equal(t, ErrorSlotGroupEmpty, groupErr.Error())
+
+	group, groupErr := NewSlotGroup(2, []chainhash.Hash{*hash0, *hash1, *hash2})
+	assert.Equal(t, nil, groupErr)
+	hash01 := *hashLeaves(*hash0, *hash1)
+	hash22 := *hashLeaves(*hash2, *hash2)
+	assert.Equal(t, *hashLeaves(hash01, hash22), group.MerkleRoot)
+	assert.Equal(t, int32(2), group.ClientPosition)
+
+	// member proofs verify against group merkle root
+	proof, proofErr := group.GetMemberProof(*hash2)
+	assert.Equal(t, nil, proofErr)
+	assert.Equal(t, int32(2), proof.ClientPosition)
+	assert.Equal(t, group.MerkleRoot, proof.MerkleRoot)
+	assert.Equal(t, true, ProveMerkleProof(proof))
+
+	_, proofErr = group.GetMemberProof(*hash3)
+	assert.Equal(t, ErrorSlotGroupMemberNotFound, proofErr.Error())
+
+	// test reverse document to group model
+	doc, docErr := GetDocumentFromModel(group)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, group.MerkleRoot.String(), doc.Lookup(SlotGroupMerkleRootName).StringValue())
+	bytes, _ := bson.Marshal(group)
+	testGroup := &SlotGroup{}
+	assert.Equal(t, nil, bson.Unmarshal(bytes, testGroup))
+	assert.Equal(t, *group, *testGroup)
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// spv proof consts
+const (
+	ErrorSpvProofDecode  = "Invalid spv proof merkle block"
+	ErrorSpvProofHeader  = "Spv proof header does not match merkle block"
+	ErrorSpvProofBlock   = "Spv proof block does not match attestation block"
+	ErrorSpvProofTree    = "Invalid spv proof partial merkle tree"
+	ErrorSpvProofRoot    = "Spv proof partial merkle tree does not match block merkle root"
+	ErrorSpvProofMissing = "Spv proof does not prove attestation transaction"
+)
+
+// SpvProof structure
+// SPV inclusion proof of an attestation transaction in the block confirming
+// it, with the hex encoded block header and merkle block, as returned by the
+// bitcoind gettxoutproof rpc, so clients can verify the inclusion against
+// a headers only view of the chain without trusting the server
+type SpvProof struct {
+	Header      string `json:"header"`
+	MerkleBlock string `json:"merkle_block"`
+}
+
+// Return SpvProof of the serialized merkle block
+func NewSpvProof(merkleBlock []byte) (SpvProof, error) {
+	var msgBlock wire.MsgMerkleBlock
+	if decodeErr := msgBlock.BtcDecode(bytes.NewReader(merkleBlock), wire.ProtocolVersion,
+		wire.BaseEncoding); decodeErr != nil {
+		return SpvProof{}, errors.New(fmt.Sprintf("%s: %v", ErrorSpvProofDecode, decodeErr))
+	}
+	var header bytes.Buffer
+	if headerErr := msgBlock.Header.Serialize(&header); headerErr != nil {
+		return SpvProof{}, headerErr
+	}
+	return SpvProof{
+		Header:      hex.EncodeToString(header.Bytes()),
+		MerkleBlock: hex.EncodeToString(merkleBlock),
+	}, nil
+}
+
+// Verify spv proof proves the transaction in the block given, with the
+// partial merkle tree of the merkle block proving the transaction to the
+// merkle root of the block header. The header itself is to be checked
+// against the chain of headers of the verifier
+func VerifySpvProof(proof SpvProof, txid string, blockhash string) error {
+	merkleBlock, hexErr := hex.DecodeString(proof.MerkleBlock)
+	if hexErr != nil {
+		return errors.New(fmt.Sprintf("%s: %v", ErrorSpvProofDecode, hexErr))
+	}
+	var msgBlock wire.MsgMerkleBlock
+	if decodeErr := msgBlock.BtcDecode(bytes.NewReader(merkleBlock), wire.ProtocolVersion,
+		wire.BaseEncoding); decodeErr != nil {
+		return errors.New(fmt.Sprintf("%s: %v", ErrorSpvProofDecode, decodeErr))
+	}
+	var header bytes.Buffer
+	if headerErr := msgBlock.Header.Serialize(&header); headerErr != nil {
+		return headerErr
+	}
+	if hex.EncodeToString(header.Bytes()) != proof.Header {
+		return errors.New(ErrorSpvProofHeader)
+	}
+	if msgBlock.Header.BlockHash().String() != blockhash {
+		return errors.New(ErrorSpvProofBlock)
+	}
+
+	root, matched, treeErr := partialMerkleRoot(msgBlock)
+	if treeErr != nil {
+		return treeErr
+	} else if root != msgBlock.Header.MerkleRoot {
+		return errors.New(ErrorSpvProofRoot)
+	}
+	for _, hash := range matched {
+		if hash.String() == txid {
+			return nil
+		}
+	}
+	return errors.New(ErrorSpvProofMissing)
+}
+
+// Return merkle root and matched transaction hashes of the partial merkle
+// tree of the merkle block, traversed depth first as specified in BIP37
+func partialMerkleRoot(msgBlock wire.MsgMerkleBlock) (chainhash.Hash, []chainhash.Hash, error) {
+	txCount := int(msgBlock.Transactions)
+	if txCount == 0 || len(msgBlock.Hashes) > txCount {
+		return chainhash.Hash{}, nil, errors.New(ErrorSpvProofTree)
+	}
+	height := 0
+	for treeWidth(txCount, height) > 1 {
+		height++
+	}
+
+	var bitsUsed, hashesUsed int
+	var matched []chainhash.Hash
+	var traverse func(height int, pos int) (chainhash.Hash, error)
+	traverse = func(height int, pos int) (chainhash.Hash, error) {
+		if bitsUsed >= len(msgBlock.Flags)*8 {
+			return chainhash.Hash{}, errors.New(ErrorSpvProofTree)
+		}
+		parentOfMatch := msgBlock.Flags[bitsUsed/8]&(1<<uint(bitsUsed%8)) != 0
+		bitsUsed++
+		if height == 0 || !parentOfMatch {
+			if hashesUsed >= len(msgBlock.Hashes) {
+				return chainhash.Hash{}, errors.New(ErrorSpvProofTree)
+			}
+			hash := *msgBlock.Hashes[hashesUsed]
+			hashesUsed++
+			if height == 0 && parentOfMatch {
+				matched = append(matched, hash)
+			}
+			return hash, nil
+		}
+		left, leftErr := traverse(height-1, pos*2)
+		if leftErr != nil {
+			return chainhash.Hash{}, leftErr
+		}
+		right := left
+		if pos*2+1 < treeWidth(txCount, height-1) {
+			var rightErr error
+			if right, rightErr = traverse(height-1, pos*2+1); rightErr != nil {
+				return chainhash.Hash{}, rightErr
+			} else if right == left {
+				// identical subtrees allow duplicate transactions, CVE-2012-2459
+				return chainhash.Hash{}, errors.New(ErrorSpvProofTree)
+			}
+		}
+		return *blockchain.HashMerkleBranches(&left, &right), nil
+	}
+
+	root, rootErr := traverse(height, 0)
+	if rootErr != nil {
+		return chainhash.Hash{}, nil, rootErr
+	}
+	if hashesUsed != len(msgBlock.Hashes) || (bitsUsed+7)/8 != len(msgBlock.Flags) {
+		return chainhash.Hash{}, nil, errors.New(ErrorSpvProofTree)
+	}
+	return root, matched, nil
+}
+
+// Return number of nodes at the height of a merkle tree of the transactions
+func treeWidth(txCount int, height int) int {
+	return (txCount + (1 << uint(height)) - 1) >> uint(height)
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/bloom"
+	"github.com/stretchr/testify/assert"
+)
+
+// Return serialized merkle block of a test block with the number of
+// transactions given, matching the transaction at the index, with
+// the txid and block hash of the transaction
+func testMerkleBlock(t *testing.T, txCount int, index int) ([]byte, string, string) {
+	msgBlock := wire.NewMsgBlock(&wire.BlockHeader{Version: 1, Timestamp: time.Unix(1542121293, 0), Bits: 0x207fffff})
+	for i := 0; i < txCount; i++ {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, uint32(i)), nil, nil))
+		tx.AddTxOut(wire.NewTxOut(int64(i), []byte{0x51}))
+		msgBlock.AddTransaction(tx)
+	}
+	merkles := blockchain.BuildMerkleTreeStore(btcutil.NewBlock(msgBlock).Transactions(), false)
+	msgBlock.Header.MerkleRoot = *merkles[len(merkles)-1]
+	block := btcutil.NewBlock(msgBlock)
+
+	txid := msgBlock.Transactions[index].TxHash()
+	filter := bloom.NewFilter(1, 0, 0, wire.BloomUpdateNone)
+	filter.AddHash(&txid)
+	merkleBlock, _ := bloom.NewMerkleBlock(block, filter)
+
+	var buf bytes.Buffer
+	assert.Equal(t, nil, merkleBlock.BtcEncode(&buf, wire.ProtocolVersion, wire.BaseEncoding))
+	return buf.Bytes(), txid.String(), msgBlock.BlockHash().String()
+}
+
+// Test spv proof construction and verification
+func TestSpvProof(t *testing.T) {
+	for _, txCount := range []int{1, 2, 5, 8} {
+		for index := 0; index < txCount; index++ {
+			merkleBlock, txid, blockhash := testMerkleBlock(t, txCount, index)
+			proof, proofErr := NewSpvProof(merkleBlock)
+			assert.Equal(t, nil, proofErr)
+			assert.Equal(t, 160, len(proof.Header))
+			assert.Equal(t, hex.EncodeToString(merkleBlock), proof.MerkleBlock)
+			assert.Equal(t, nil, VerifySpvProof(proof, txid, blockhash))
+		}
+	}
+
+	merkleBlock, txid, blockhash := testMerkleBlock(t, 5, 3)
+	proof, _ := NewSpvProof(merkleBlock)
+	_, otherTxid, _ := testMerkleBlock(t, 5, 2)
+
+	// transaction or block not proven
+	assert.Equal(t, errors.New(ErrorSpvProofMissing), VerifySpvProof(proof, otherTxid, blockhash))
+	assert.Equal(t, errors.New(ErrorSpvProofBlock), VerifySpvProof(proof, txid, txid))
+
+	// header not matching merkle block
+	invalid := proof
+	invalid.Header = "00" + proof.Header[2:]
+	assert.Equal(t, errors.New(ErrorSpvProofHeader), VerifySpvProof(invalid, txid, blockhash))
+
+	// partial merkle tree not matching merkle root
+	tampered := append([]byte{}, merkleBlock...)
+	tampered[len(tampered)-40] ^= 0x01
+	invalid.Header = proof.Header
+	invalid.MerkleBlock = hex.EncodeToString(tampered)
+	assert.Equal(t, errors.New(ErrorSpvProofRoot), VerifySpvProof(invalid, txid, blockhash))
+
+	// malformed merkle blocks
+	invalid.MerkleBlock = "zz"
+	assert.Contains(t, VerifySpvProof(invalid, txid, blockhash).Error(), ErrorSpvProofDecode)
+	_, decodeErr := NewSpvProof(merkleBlock[:80])
+	assert.Contains(t, decodeErr.Error(), ErrorSpvProofDecode)
+	tampered = append([]byte{}, merkleBlock...)
+	tampered[len(tampered)-1] = 0x00
+	invalid.MerkleBlock = hex.EncodeToString(tampered)
+	assert.Equal(t, errors.New(ErrorSpvProofTree), VerifySpvProof(invalid, txid, blockhash))
+}
+
+// Test proof bundle verification of the spv proof of the attestation
+func TestProofBundleSpv(t *testing.T) {
+	merkleBlock, txid, blockhash := testMerkleBlock(t, 5, 3)
+	proof, _ := NewSpvProof(merkleBlock)
+
+	hash0, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := NewCommitment([]chainhash.Hash{*hash0, *hash1})
+	bundle := NewProofBundle(commitment.GetMerkleProofs()[0],
+		AttestationInfo{Txid: txid, Blockhash: blockhash, Time: 1542121293})
+	bundle.Block.Spv = &proof
+	assert.Equal(t, nil, VerifyProofBundle(bundle))
+
+	bundle.Txid = hash0.String()
+	assert.Equal(t, errors.New(ErrorSpvProofMissing), VerifyProofBundle(bundle))
+}
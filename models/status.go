@@ -0,0 +1,29 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+// struct for service AttestationStatus
+// Current state of the attestation state machine with the scheduled time
+// of the next state and the time of the last successful state transition
+type AttestationStatus struct {
+	State          string `json:"state"`
+	NextState      int64  `json:"next_state"`
+	LastTransition int64  `json:"last_transition"`
+	Failing        bool   `json:"failing"`
+	Paused         bool   `json:"paused"`
+	Syncing        bool   `json:"syncing"`
+}
+
+// StatusResponse structure
+// Snapshot of the staychain balance, the last confirmed attestation, the
+// attestation state machine and the federation signers. Parts that are not
+// available, e.g. the balance before the first attestation, are null
+type StatusResponse struct {
+	Balance         *Balance             `json:"balance"`
+	LastAttestation *AttestationResponse `json:"last_attestation"`
+	Attestation     *AttestationStatus   `json:"attestation"`
+	Signers         []SignerHealth       `json:"signers"`
+	Time            int64                `json:"time"`
+}
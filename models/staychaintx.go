@@ -0,0 +1,40 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+// struct for db StaychainTx
+// Transaction of the staychain indexed from the main chain, numbered from the
+// base transaction at index 0, with the block confirming it, the staychain
+// transaction it spends and the staychain transaction spending it, if any
+type StaychainTx struct {
+	Txid      string `bson:"txid" json:"txid"`
+	Index     int64  `bson:"index" json:"index"`
+	Height    int64  `bson:"height" json:"height"`
+	Blockhash string `bson:"blockhash" json:"blockhash"`
+	Time      int64  `bson:"time" json:"time"`
+	Spends    string `bson:"spends" json:"spends,omitempty"`
+	SpentBy   string `bson:"spent_by" json:"spent_by,omitempty"`
+}
+
+// StaychainTx field names
+const (
+	StaychainTxTxidName      = "txid"
+	StaychainTxIndexName     = "index"
+	StaychainTxHeightName    = "height"
+	StaychainTxBlockhashName = "blockhash"
+	StaychainTxTimeName      = "time"
+	StaychainTxSpendsName    = "spends"
+	StaychainTxSpentByName   = "spent_by"
+)
+
+// StaychainTxs struct
+// Page of indexed staychain transactions, latest first. Total is the number
+// of transactions indexed across all pages
+type StaychainTxs struct {
+	Total  int64         `json:"total"`
+	Offset int64         `json:"offset"`
+	Limit  int64         `json:"limit"`
+	Txs    []StaychainTx `json:"txs"`
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package notify
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/log"
+	"mainstay/models"
+)
+
+// alert consts
+const (
+	DefaultAlertDedupMinutes = 60  // window in which identical alerts are suppressed
+	AlertQueueSize           = 100 // alerts queued before dropping new alerts
+
+	WarningInvalidAlertDedupArg = "Invalid alert dedup config value"
+	WarningAlertQueueFull       = "Alert queue full - dropping alert"
+	WarningAlertFailed          = "Alert failed"
+)
+
+// Alerter interface
+// Alerted of attestation service failures by the attestation service
+type Alerter interface {
+	Alert(alert models.Alert)
+}
+
+// AlertChannel interface
+// Channel through which alerts are sent, e.g. slack or email
+type AlertChannel interface {
+	Name() string
+	Send(alert models.Alert) error
+}
+
+// Return one line summary of the alert
+func AlertSummary(alert models.Alert) string {
+	summary := fmt.Sprintf("Mainstay attestation failure in state %s: %s", alert.PrevState, alert.Error)
+	if alert.Class != "" {
+		summary += fmt.Sprintf(" (class %s)", alert.Class)
+	}
+	if alert.Txid != "" {
+		summary += fmt.Sprintf(" (txid %s)", alert.Txid)
+	}
+	if alert.Suppressed > 0 {
+		summary += fmt.Sprintf(" [repeated %d times]", alert.Suppressed)
+	}
+	return summary
+}
+
+// Return key identifying identical alerts
+func alertKey(alert models.Alert) string {
+	return alert.PrevState + ":" + alert.Error
+}
+
+// AlertDispatcher struct
+// Sends alerts to each alert channel from a queue, so that alerting never
+// blocks the attestation service, suppressing alerts identical to an alert
+// sent within the dedup window, e.g. on repeated init failures
+type AlertDispatcher struct {
+	channels []AlertChannel
+	dedup    time.Duration
+
+	mu         sync.Mutex
+	sent       map[string]time.Time
+	suppressed map[string]int
+
+	queue chan models.Alert
+}
+
+// Return new AlertDispatcher instance from alert config and start sending alerts
+// Returns nil if no alert channels are configured
+func NewAlertDispatcher(alertConfig confpkg.AlertConfig) *AlertDispatcher {
+	var channels []AlertChannel
+	if alertConfig.SlackUrl != "" {
+		channels = append(channels, NewAlertSlack(alertConfig.SlackUrl))
+	}
+	if alertConfig.PagerDutyKey != "" {
+		channels = append(channels, NewAlertPagerDuty(alertConfig.PagerDutyKey))
+	}
+	if alertConfig.SmtpHost != "" {
+		channels = append(channels, NewAlertSmtp(alertConfig))
+	}
+	if len(channels) == 0 {
+		return nil
+	}
+
+	dedupMinutes := DefaultAlertDedupMinutes
+	if alertConfig.DedupMinutes >= 0 {
+		dedupMinutes = alertConfig.DedupMinutes
+	} else {
+		log.Warnf("%s (%d)\n", WarningInvalidAlertDedupArg, alertConfig.DedupMinutes)
+	}
+	log.Infof("*Alert* Alerting through %d channels with dedup window %d minutes\n", len(channels), dedupMinutes)
+
+	return newAlertDispatcher(channels, time.Duration(dedupMinutes)*time.Minute)
+}
+
+// Return new AlertDispatcher instance for alert channels and start sending alerts
+func newAlertDispatcher(channels []AlertChannel, dedup time.Duration) *AlertDispatcher {
+	a := &AlertDispatcher{
+		channels:   channels,
+		dedup:      dedup,
+		sent:       make(map[string]time.Time),
+		suppressed: make(map[string]int),
+		queue:      make(chan models.Alert, AlertQueueSize),
+	}
+	go a.run()
+	return a
+}
+
+// Queue alert to be sent to the alert channels unless an identical
+// alert has been sent within the dedup window
+func (a *AlertDispatcher) Alert(alert models.Alert) {
+	a.mu.Lock()
+	key := alertKey(alert)
+	if sentTime, ok := a.sent[key]; ok && time.Since(sentTime) < a.dedup {
+		a.suppressed[key]++
+		a.mu.Unlock()
+		return
+	}
+	a.sent[key] = time.Now()
+	alert.Suppressed = a.suppressed[key]
+	delete(a.suppressed, key)
+	a.mu.Unlock()
+
+	select {
+	case a.queue <- alert:
+	default:
+		log.WithFields(log.Fields{log.FieldError: alert.Error}).Warnln(WarningAlertQueueFull)
+	}
+}
+
+// Send queued alerts to each alert channel
+func (a *AlertDispatcher) run() {
+	for alert := range a.queue {
+		for _, channel := range a.channels {
+			if sendErr := channel.Send(alert); sendErr != nil {
+				log.WithFields(log.Fields{log.FieldChannel: channel.Name(), log.FieldError: sendErr}).Warnln(WarningAlertFailed)
+			}
+		}
+	}
+}
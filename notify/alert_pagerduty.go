@@ -0,0 +1,73 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"mainstay/models"
+)
+
+// pagerduty consts
+const (
+	PagerDutyEventsUrl = "https://events.pagerduty.com/v2/enqueue"
+	PagerDutySource    = "mainstay"
+	PagerDutySeverity  = "error"
+)
+
+// pagerduty events api v2 trigger event
+type pagerDutyEvent struct {
+	RoutingKey  string           `json:"routing_key"`
+	EventAction string           `json:"event_action"`
+	DedupKey    string           `json:"dedup_key"`
+	Payload     pagerDutyPayload `json:"payload"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string       `json:"summary"`
+	Source        string       `json:"source"`
+	Severity      string       `json:"severity"`
+	CustomDetails models.Alert `json:"custom_details"`
+}
+
+// AlertPagerDuty struct
+// Triggers pagerduty incidents through the events api with an integration routing key
+type AlertPagerDuty struct {
+	url        string
+	routingKey string
+	client     *http.Client
+}
+
+// Return new AlertPagerDuty instance
+func NewAlertPagerDuty(routingKey string) *AlertPagerDuty {
+	return &AlertPagerDuty{url: PagerDutyEventsUrl, routingKey: routingKey,
+		client: &http.Client{Timeout: RequestTimeout}}
+}
+
+// Get alert channel name
+func (p *AlertPagerDuty) Name() string {
+	return "pagerduty"
+}
+
+// Trigger pagerduty incident for alert, grouping identical alerts
+// into the same incident with the alert key as dedup key
+func (p *AlertPagerDuty) Send(alert models.Alert) error {
+	payload, marshalErr := json.Marshal(pagerDutyEvent{
+		RoutingKey:  p.routingKey,
+		EventAction: "trigger",
+		DedupKey:    alertKey(alert),
+		Payload: pagerDutyPayload{
+			Summary:       AlertSummary(alert),
+			Source:        PagerDutySource,
+			Severity:      PagerDutySeverity,
+			CustomDetails: alert,
+		},
+	})
+	if marshalErr != nil {
+		return marshalErr
+	}
+	return postJson(p.client, p.url, payload, nil)
+}
@@ -0,0 +1,38 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"mainstay/models"
+)
+
+// AlertSlack struct
+// Sends alerts to a slack incoming webhook url
+type AlertSlack struct {
+	url    string
+	client *http.Client
+}
+
+// Return new AlertSlack instance
+func NewAlertSlack(url string) *AlertSlack {
+	return &AlertSlack{url: url, client: &http.Client{Timeout: RequestTimeout}}
+}
+
+// Get alert channel name
+func (s *AlertSlack) Name() string {
+	return "slack"
+}
+
+// Post alert summary as a slack message
+func (s *AlertSlack) Send(alert models.Alert) error {
+	payload, marshalErr := json.Marshal(map[string]string{"text": AlertSummary(alert)})
+	if marshalErr != nil {
+		return marshalErr
+	}
+	return postJson(s.client, s.url, payload, nil)
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/models"
+)
+
+// smtp consts
+const (
+	ErrorSmtpHostInvalid   = "Invalid smtp host - expected host:port"
+	ErrorSmtpAddressNotSet = "Smtp from and to addresses not set"
+)
+
+// Check that the smtp host includes a port and that addresses are set
+func ValidateSmtp(alertConfig confpkg.AlertConfig) error {
+	if _, _, splitErr := net.SplitHostPort(alertConfig.SmtpHost); splitErr != nil {
+		return errors.New(fmt.Sprintf("%s: %s", ErrorSmtpHostInvalid, alertConfig.SmtpHost))
+	}
+	if alertConfig.SmtpFrom == "" || len(alertConfig.SmtpTo) == 0 {
+		return errors.New(ErrorSmtpAddressNotSet)
+	}
+	return nil
+}
+
+// AlertSmtp struct
+// Emails alerts through an smtp server, authenticating with plain auth
+// if a user is set
+type AlertSmtp struct {
+	host string
+	auth smtp.Auth
+	from string
+	to   []string
+
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// Return new AlertSmtp instance from alert config
+func NewAlertSmtp(alertConfig confpkg.AlertConfig) *AlertSmtp {
+	var auth smtp.Auth
+	if alertConfig.SmtpUser != "" {
+		hostname, _, _ := net.SplitHostPort(alertConfig.SmtpHost)
+		auth = smtp.PlainAuth("", alertConfig.SmtpUser, alertConfig.SmtpPass, hostname)
+	}
+	return &AlertSmtp{
+		host:     alertConfig.SmtpHost,
+		auth:     auth,
+		from:     alertConfig.SmtpFrom,
+		to:       alertConfig.SmtpTo,
+		sendMail: smtp.SendMail,
+	}
+}
+
+// Get alert channel name
+func (m *AlertSmtp) Name() string {
+	return "smtp"
+}
+
+// Email alert to the configured recipients
+func (m *AlertSmtp) Send(alert models.Alert) error {
+	return m.sendMail(m.host, m.auth, m.from, m.to, m.message(alert))
+}
+
+// Return email message for alert with headers and plain text body
+func (m *AlertSmtp) message(alert models.Alert) []byte {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", m.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(m.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", strings.NewReplacer("\r", " ", "\n", " ").Replace(AlertSummary(alert)))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Unix(alert.Time, 0).UTC().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "Error: %s\r\n", alert.Error)
+	fmt.Fprintf(&msg, "Previous state: %s\r\n", alert.PrevState)
+	fmt.Fprintf(&msg, "Txid: %s\r\n", alert.Txid)
+	fmt.Fprintf(&msg, "Suppressed alerts: %d\r\n", alert.Suppressed)
+	return []byte(msg.String())
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// alert channel collecting alerts
+type alertChannelFake struct {
+	alerts chan models.Alert
+}
+
+func (c *alertChannelFake) Name() string { return "fake" }
+func (c *alertChannelFake) Send(alert models.Alert) error {
+	c.alerts <- alert
+	return nil
+}
+
+// Return next alert sent to channel
+func receiveAlert(t *testing.T, alerts chan models.Alert) models.Alert {
+	select {
+	case alert := <-alerts:
+		return alert
+	case <-time.After(5 * time.Second):
+		t.Fatal("alert not received")
+	}
+	return models.Alert{}
+}
+
+// Test alert dispatcher channels from config and alert de-duplication
+func TestAlertDispatcher(t *testing.T) {
+	assert.Nil(t, NewAlertDispatcher(confpkg.AlertConfig{DedupMinutes: -1}))
+
+	dispatcher := NewAlertDispatcher(confpkg.AlertConfig{SlackUrl: "http://localhost/slack",
+		PagerDutyKey: "key", SmtpHost: "localhost:25", DedupMinutes: -1})
+	assert.Equal(t, DefaultAlertDedupMinutes*time.Minute, dispatcher.dedup)
+	assert.Equal(t, 3, len(dispatcher.channels))
+	assert.Equal(t, "slack", dispatcher.channels[0].Name())
+	assert.Equal(t, "pagerduty", dispatcher.channels[1].Name())
+	assert.Equal(t, "smtp", dispatcher.channels[2].Name())
+
+	channel := &alertChannelFake{alerts: make(chan models.Alert, 10)}
+	dispatcher = newAlertDispatcher([]AlertChannel{channel}, time.Hour)
+
+	alert := models.Alert{Error: "rpc error", PrevState: "init", Time: 1}
+	dispatcher.Alert(alert)
+	assert.Equal(t, alert, receiveAlert(t, channel.alerts))
+
+	// identical alerts suppressed within dedup window
+	dispatcher.Alert(alert)
+	dispatcher.Alert(alert)
+	otherAlert := models.Alert{Error: "rpc error", PrevState: "send_attestation", Txid: "abc", Time: 2}
+	dispatcher.Alert(otherAlert)
+	assert.Equal(t, otherAlert, receiveAlert(t, channel.alerts))
+
+	// alert sent after dedup window with suppressed count
+	dispatcher.sent[alertKey(alert)] = time.Now().Add(-2 * time.Hour)
+	dispatcher.Alert(alert)
+	alert.Suppressed = 2
+	assert.Equal(t, alert, receiveAlert(t, channel.alerts))
+	assert.Equal(t, 0, dispatcher.suppressed[alertKey(alert)])
+}
+
+// Test alert channels message formats
+func TestAlertChannels(t *testing.T) {
+	alert := models.Alert{Error: "rpc error", PrevState: "send_attestation", Txid: "abc", Suppressed: 1, Time: 1}
+	summary := "Mainstay attestation failure in state send_attestation: rpc error (txid abc) [repeated 1 times]"
+	assert.Equal(t, summary, AlertSummary(alert))
+	assert.Equal(t, "Mainstay attestation failure in state init: rpc error",
+		AlertSummary(models.Alert{Error: "rpc error", PrevState: "init"}))
+	assert.Equal(t, "Mainstay attestation failure in state init: rpc error (class fatal_config)",
+		AlertSummary(models.Alert{Error: "rpc error", Class: "fatal_config", PrevState: "init"}))
+
+	var payloads []map[string]interface{}
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload map[string]interface{}
+		assert.Equal(t, nil, json.Unmarshal(body, &payload))
+		payloads = append(payloads, payload)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	// slack
+	slack := NewAlertSlack(server.URL)
+	assert.Equal(t, nil, slack.Send(alert))
+	assert.Equal(t, map[string]interface{}{"text": summary}, payloads[0])
+	status = http.StatusBadRequest
+	assert.Equal(t, ErrorResponse+": 400 Bad Request", slack.Send(alert).Error())
+	status = http.StatusOK
+
+	// pagerduty
+	pagerDuty := NewAlertPagerDuty("key")
+	pagerDuty.url = server.URL
+	assert.Equal(t, nil, pagerDuty.Send(alert))
+	assert.Equal(t, "key", payloads[2]["routing_key"])
+	assert.Equal(t, "trigger", payloads[2]["event_action"])
+	assert.Equal(t, "send_attestation:rpc error", payloads[2]["dedup_key"])
+	pagerDutyPayload := payloads[2]["payload"].(map[string]interface{})
+	assert.Equal(t, summary, pagerDutyPayload["summary"])
+	assert.Equal(t, PagerDutySeverity, pagerDutyPayload["severity"])
+	assert.Equal(t, "abc", pagerDutyPayload["custom_details"].(map[string]interface{})["txid"])
+
+	// smtp
+	alertConfig := confpkg.AlertConfig{SmtpHost: "smtp.example.com:587", SmtpUser: "user", SmtpPass: "pass",
+		SmtpFrom: "mainstay@example.com", SmtpTo: []string{"ops@example.com", "oncall@example.com"}}
+	assert.Equal(t, nil, ValidateSmtp(alertConfig))
+	mail := NewAlertSmtp(alertConfig)
+	var sentAddr string
+	var sentTo []string
+	var sentMsg []byte
+	mail.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		sentAddr, sentTo, sentMsg = addr, to, msg
+		assert.NotNil(t, a)
+		assert.Equal(t, "mainstay@example.com", from)
+		return nil
+	}
+	assert.Equal(t, nil, mail.Send(alert))
+	assert.Equal(t, "smtp.example.com:587", sentAddr)
+	assert.Equal(t, alertConfig.SmtpTo, sentTo)
+	assert.True(t, strings.Contains(string(sentMsg), "To: ops@example.com, oncall@example.com\r\n"))
+	assert.True(t, strings.Contains(string(sentMsg), "Subject: "+summary+"\r\n"))
+	assert.True(t, strings.Contains(string(sentMsg), "\r\n\r\nError: rpc error\r\n"))
+
+	mail.sendMail = func(string, smtp.Auth, string, []string, []byte) error {
+		return errors.New("connection refused")
+	}
+	assert.Equal(t, errors.New("connection refused"), mail.Send(alert))
+
+	alertConfig.SmtpHost = "smtp.example.com"
+	assert.Equal(t, ErrorSmtpHostInvalid+": smtp.example.com", ValidateSmtp(alertConfig).Error())
+	alertConfig.SmtpHost = "smtp.example.com:587"
+	alertConfig.SmtpTo = nil
+	assert.Equal(t, ErrorSmtpAddressNotSet, ValidateSmtp(alertConfig).Error())
+}
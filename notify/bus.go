@@ -0,0 +1,111 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package notify
+
+import (
+	"context"
+	"sync"
+
+	confpkg "mainstay/config"
+	"mainstay/log"
+	"mainstay/models"
+)
+
+// event bus consts
+const (
+	SubscriberQueueSize = 100 // events queued per subscriber before dropping new events
+
+	WarningSubscriberQueueFull = "Event subscriber queue full - dropping event"
+)
+
+// EventSource interface
+// Attestation lifecycle events can be subscribed to until the
+// returned cancel function is called, which closes the channel
+type EventSource interface {
+	Subscribe() (<-chan models.AttestationEvent, func())
+}
+
+// EventBus interface
+// Distributes events notified by the attestation service to subscribers
+type EventBus interface {
+	Notifier
+	EventSource
+}
+
+// Return event bus from events config, bridging processes through
+// redis if a redis url is configured or in-process otherwise
+func NewEventBus(ctx context.Context, eventsConfig confpkg.EventsConfig) (EventBus, error) {
+	if eventsConfig.RedisUrl == "" {
+		return NewLocalBus(), nil
+	}
+	redisBus, redisErr := NewRedisBus(ctx, eventsConfig)
+	if redisErr != nil {
+		return nil, redisErr
+	}
+	return redisBus, nil
+}
+
+// Notifiers type
+// Notifies each of the notifiers in order
+type Notifiers []Notifier
+
+// Notify each notifier of event
+func (n Notifiers) Notify(event models.AttestationEvent) {
+	for _, notifier := range n {
+		notifier.Notify(event)
+	}
+}
+
+// LocalBus struct
+// In-process event bus delivering events to subscribers of the same process
+// Slow subscribers never block notifying and miss events instead
+type LocalBus struct {
+	mu          sync.Mutex
+	subscribers map[chan models.AttestationEvent]struct{}
+}
+
+// Return new LocalBus instance
+func NewLocalBus() *LocalBus {
+	return &LocalBus{subscribers: make(map[chan models.AttestationEvent]struct{})}
+}
+
+// Deliver event to all subscribers
+func (b *LocalBus) Notify(event models.AttestationEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for subscriber := range b.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			log.WithFields(log.Fields{log.FieldTxid: event.Txid}).Warnln(WarningSubscriberQueueFull)
+		}
+	}
+}
+
+// Subscribe to events notified from now on
+func (b *LocalBus) Subscribe() (<-chan models.AttestationEvent, func()) {
+	subscriber := make(chan models.AttestationEvent, SubscriberQueueSize)
+	b.mu.Lock()
+	b.subscribers[subscriber] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, subscriber)
+			b.mu.Unlock()
+			close(subscriber)
+		})
+	}
+	return subscriber, cancel
+}
+
+// Return number of subscribers
+func (b *LocalBus) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/log"
+	"mainstay/models"
+)
+
+// redis bus consts
+const (
+	DefaultEventsChannel = "mainstay:events" // redis channel events are published to
+	RedisTimeout         = 5 * time.Second   // timeout of connecting and publishing
+	RedisReconnectDelay  = 5 * time.Second   // delay before reconnecting the subscription
+	RedisQueueSize       = 100               // events queued before dropping new events
+
+	WarningRedisQueueFull       = "Redis event queue full - dropping event"
+	WarningRedisPublishFailed   = "Redis event publish failed"
+	WarningRedisSubscribeFailed = "Redis event subscription failed - reconnecting"
+	WarningRedisEventInvalid    = "Invalid redis event"
+)
+
+// RedisBus struct
+// Event bus bridging mainstay processes through a redis pub/sub channel
+// Events notified are published to the channel and events received on
+// the channel, from this or any other process, are delivered to the local
+// subscribers, e.g. the request api of a separate api process
+type RedisBus struct {
+	ctx            context.Context
+	addr           redisAddr
+	channel        string
+	local          *LocalBus
+	queue          chan models.AttestationEvent
+	reconnectDelay time.Duration
+}
+
+// Return new RedisBus instance from events config
+// Publishing and subscribing run until the context is cancelled
+func NewRedisBus(ctx context.Context, eventsConfig confpkg.EventsConfig) (*RedisBus, error) {
+	addr, addrErr := parseRedisUrl(eventsConfig.RedisUrl)
+	if addrErr != nil {
+		return nil, addrErr
+	}
+	channel := DefaultEventsChannel
+	if eventsConfig.Channel != "" {
+		channel = eventsConfig.Channel
+	}
+	log.Infof("*Events* Distributing events through redis %s channel %s\n", addr.host, channel)
+
+	b := &RedisBus{
+		ctx:            ctx,
+		addr:           addr,
+		channel:        channel,
+		local:          NewLocalBus(),
+		queue:          make(chan models.AttestationEvent, RedisQueueSize),
+		reconnectDelay: RedisReconnectDelay,
+	}
+	go b.publish()
+	go b.subscribe()
+	return b, nil
+}
+
+// Queue event to be published to the redis channel
+func (b *RedisBus) Notify(event models.AttestationEvent) {
+	select {
+	case b.queue <- event:
+	default:
+		log.WithFields(log.Fields{log.FieldTxid: event.Txid}).Warnln(WarningRedisQueueFull)
+	}
+}
+
+// Subscribe to events received on the redis channel
+func (b *RedisBus) Subscribe() (<-chan models.AttestationEvent, func()) {
+	return b.local.Subscribe()
+}
+
+// Publish queued events, reconnecting once if publishing fails
+func (b *RedisBus) publish() {
+	var conn *redisConn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	for {
+		var event models.AttestationEvent
+		select {
+		case <-b.ctx.Done():
+			return
+		case event = <-b.queue:
+		}
+		payload, marshalErr := json.Marshal(event)
+		if marshalErr != nil {
+			log.WithFields(log.Fields{log.FieldError: marshalErr}).Warnln(WarningRedisPublishFailed)
+			continue
+		}
+
+		var publishErr error
+		for attempt := 0; attempt < 2; attempt++ {
+			if conn == nil {
+				if conn, publishErr = dialRedis(b.addr, RedisTimeout); publishErr != nil {
+					conn = nil
+					continue
+				}
+			}
+			if _, publishErr = conn.do(RedisTimeout, "PUBLISH", b.channel, string(payload)); publishErr == nil {
+				break
+			}
+			conn.Close()
+			conn = nil
+		}
+		if publishErr != nil {
+			log.WithFields(log.Fields{log.FieldTxid: event.Txid, log.FieldError: publishErr}).Warnln(WarningRedisPublishFailed)
+		}
+	}
+}
+
+// Deliver events received on the redis channel to local subscribers,
+// reconnecting after a delay whenever the subscription fails
+func (b *RedisBus) subscribe() {
+	for {
+		subscribeErr := b.receive()
+		select {
+		case <-b.ctx.Done():
+			return
+		default:
+		}
+		log.WithFields(log.Fields{log.FieldChannel: b.channel, log.FieldError: subscribeErr}).Warnln(WarningRedisSubscribeFailed)
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-time.After(b.reconnectDelay):
+		}
+	}
+}
+
+// Subscribe to the redis channel and deliver messages received
+// until the connection fails or the context is cancelled
+func (b *RedisBus) receive() error {
+	conn, dialErr := dialRedis(b.addr, RedisTimeout)
+	if dialErr != nil {
+		return dialErr
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() { // unblock receiving on cancel
+		select {
+		case <-b.ctx.Done():
+		case <-done:
+		}
+		conn.Close()
+	}()
+
+	if sendErr := conn.send("SUBSCRIBE", b.channel); sendErr != nil {
+		return sendErr
+	}
+	for {
+		reply, replyErr := conn.receive()
+		if replyErr != nil {
+			return replyErr
+		}
+		message, ok := reply.([]interface{})
+		if !ok || len(message) != 3 || message[0] != "message" {
+			continue // subscribe confirmation
+		}
+		payload, _ := message[2].(string)
+		var event models.AttestationEvent
+		if unmarshalErr := json.Unmarshal([]byte(payload), &event); unmarshalErr != nil {
+			log.WithFields(log.Fields{log.FieldChannel: b.channel, log.FieldError: unmarshalErr}).Warnln(WarningRedisEventInvalid)
+			continue
+		}
+		b.local.Notify(event)
+	}
+}
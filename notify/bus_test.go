@@ -0,0 +1,201 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package notify
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// notifier recording events notified
+type notifierRecorder struct {
+	events []models.AttestationEvent
+}
+
+func (n *notifierRecorder) Notify(event models.AttestationEvent) {
+	n.events = append(n.events, event)
+}
+
+// Receive event from subscription or fail after timeout
+func receiveEvent(t *testing.T, events <-chan models.AttestationEvent) models.AttestationEvent {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not received")
+	}
+	return models.AttestationEvent{}
+}
+
+// Test local bus delivers events to each subscriber until cancelled
+func TestLocalBus(t *testing.T) {
+	bus := NewLocalBus()
+	event := models.AttestationEvent{Event: models.AttestationEventBroadcast, Txid: "abc", Time: 1}
+
+	bus.Notify(event) // no subscribers
+	events1, cancel1 := bus.Subscribe()
+	events2, cancel2 := bus.Subscribe()
+	assert.Equal(t, 2, bus.Subscribers())
+
+	bus.Notify(event)
+	assert.Equal(t, event, receiveEvent(t, events1))
+	assert.Equal(t, event, receiveEvent(t, events2))
+
+	cancel1()
+	cancel1()
+	_, open := <-events1
+	assert.Equal(t, false, open)
+	assert.Equal(t, 1, bus.Subscribers())
+
+	// full subscriber queue drops events without blocking
+	for i := 0; i < SubscriberQueueSize+1; i++ {
+		bus.Notify(event)
+	}
+	assert.Equal(t, SubscriberQueueSize, len(events2))
+	cancel2()
+	assert.Equal(t, 0, bus.Subscribers())
+
+	// notifiers notified in order
+	recorder1, recorder2 := &notifierRecorder{}, &notifierRecorder{}
+	Notifiers{recorder1, recorder2}.Notify(event)
+	assert.Equal(t, []models.AttestationEvent{event}, recorder1.events)
+	assert.Equal(t, []models.AttestationEvent{event}, recorder2.events)
+
+	localBus, localErr := NewEventBus(context.Background(), confpkg.EventsConfig{})
+	assert.Equal(t, nil, localErr)
+	assert.IsType(t, &LocalBus{}, localBus)
+	_, redisErr := NewEventBus(context.Background(), confpkg.EventsConfig{RedisUrl: "localhost:6379"})
+	assert.Equal(t, ErrorRedisUrlInvalid+": localhost:6379", redisErr.Error())
+}
+
+// Test parsing of redis urls
+func TestParseRedisUrl(t *testing.T) {
+	addr, addrErr := parseRedisUrl("redis://localhost")
+	assert.Equal(t, nil, addrErr)
+	assert.Equal(t, redisAddr{host: "localhost:6379"}, addr)
+
+	addr, addrErr = parseRedisUrl("redis://:secret@10.0.0.1:6380/2")
+	assert.Equal(t, nil, addrErr)
+	assert.Equal(t, redisAddr{host: "10.0.0.1:6380", password: "secret", db: 2}, addr)
+
+	addr, addrErr = parseRedisUrl("redis://[::1]/1")
+	assert.Equal(t, nil, addrErr)
+	assert.Equal(t, redisAddr{host: "[::1]:6379", db: 1}, addr)
+
+	for _, redisUrl := range []string{"", "localhost:6379", "http://localhost", "redis://localhost/x"} {
+		_, addrErr = parseRedisUrl(redisUrl)
+		assert.Equal(t, ErrorRedisUrlInvalid+": "+redisUrl, addrErr.Error())
+	}
+}
+
+// minimal redis server supporting auth, publish and subscribe
+type redisServerFake struct {
+	listener    net.Listener
+	password    string
+	mu          sync.Mutex
+	subscribers map[string][]net.Conn
+}
+
+func newRedisServerFake(t *testing.T, password string) *redisServerFake {
+	listener, listenErr := net.Listen("tcp", "127.0.0.1:0")
+	assert.Equal(t, nil, listenErr)
+	s := &redisServerFake{listener: listener, password: password, subscribers: make(map[string][]net.Conn)}
+	go func() {
+		for {
+			conn, acceptErr := listener.Accept()
+			if acceptErr != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *redisServerFake) serve(conn net.Conn) {
+	defer conn.Close()
+	c := &redisConn{conn: conn, reader: bufio.NewReader(conn)}
+	authenticated := s.password == ""
+	for {
+		reply, replyErr := c.receive()
+		if replyErr != nil {
+			return
+		}
+		args, _ := reply.([]interface{})
+		if len(args) == 0 {
+			return
+		}
+		switch {
+		case args[0] == "AUTH" && args[1] == s.password:
+			authenticated = true
+			fmt.Fprint(conn, "+OK\r\n")
+		case !authenticated:
+			fmt.Fprint(conn, "-NOAUTH Authentication required.\r\n")
+		case args[0] == "PUBLISH":
+			channel, message := args[1].(string), args[2].(string)
+			s.mu.Lock()
+			for _, subscriber := range s.subscribers[channel] {
+				fmt.Fprintf(subscriber, "*3\r\n$7\r\nmessage\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n",
+					len(channel), channel, len(message), message)
+			}
+			fmt.Fprintf(conn, ":%d\r\n", len(s.subscribers[channel]))
+			s.mu.Unlock()
+		case args[0] == "SUBSCRIBE":
+			channel := args[1].(string)
+			s.mu.Lock()
+			s.subscribers[channel] = append(s.subscribers[channel], conn)
+			s.mu.Unlock()
+			fmt.Fprintf(conn, "*3\r\n$9\r\nsubscribe\r\n$%d\r\n%s\r\n:1\r\n", len(channel), channel)
+		default:
+			fmt.Fprint(conn, "-ERR unknown command\r\n")
+		}
+	}
+}
+
+func (s *redisServerFake) subscriberCount(channel string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subscribers[channel])
+}
+
+// Test redis bus bridges events between processes through the redis channel
+func TestRedisBus(t *testing.T) {
+	server := newRedisServerFake(t, "secret")
+	defer server.listener.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	redisUrl := fmt.Sprintf("redis://:secret@%s", server.listener.Addr().String())
+	daemonBus, daemonErr := NewRedisBus(ctx, confpkg.EventsConfig{RedisUrl: redisUrl})
+	assert.Equal(t, nil, daemonErr)
+	apiBus, apiErr := NewRedisBus(ctx, confpkg.EventsConfig{RedisUrl: redisUrl})
+	assert.Equal(t, nil, apiErr)
+	assert.Equal(t, DefaultEventsChannel, apiBus.channel)
+
+	for server.subscriberCount(DefaultEventsChannel) < 2 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	events, cancelEvents := apiBus.Subscribe()
+	defer cancelEvents()
+
+	event := models.AttestationEvent{Event: models.AttestationEventConfirmed, Txid: "abc",
+		Commitment: "def", Blockhash: "123", Time: 1}
+	daemonBus.Notify(event)
+	assert.Equal(t, event, receiveEvent(t, events))
+
+	// wrong password fails to connect
+	_, dialErr := dialRedis(redisAddr{host: server.listener.Addr().String(), password: "wrong"}, time.Second)
+	assert.Equal(t, "NOAUTH Authentication required.", dialErr.Error())
+}
@@ -0,0 +1,15 @@
+/*
+Package notify provides push notifications of attestation lifecycle events
+and alerts on attestation service failures
+
+Events are posted as json to the configured webhook urls when an attestation
+is broadcast, fee bumped or confirmed, signed with the webhook secret.
+
+Events are also distributed to subscribers, such as the request api event
+stream, through an event bus that is either in-process or bridges mainstay
+processes through a redis pub/sub channel.
+
+Failures are alerted through the configured slack, pagerduty and email
+channels, with repeated identical failures suppressed for a dedup window.
+*/
+package notify
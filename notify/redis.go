@@ -0,0 +1,169 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package notify
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redis consts
+const (
+	DefaultRedisPort = "6379"
+
+	ErrorRedisUrlInvalid = "Invalid redis url"
+	ErrorRedisReply      = "Invalid redis reply"
+)
+
+// redis connection details parsed from a redis://[:password@]host[:port][/db] url
+type redisAddr struct {
+	host     string
+	password string
+	db       int
+}
+
+// Check that the redis url is a valid redis:// url
+func ValidateRedisUrl(redisUrl string) error {
+	_, addrErr := parseRedisUrl(redisUrl)
+	return addrErr
+}
+
+// Parse redis url
+func parseRedisUrl(redisUrl string) (redisAddr, error) {
+	invalidErr := errors.New(fmt.Sprintf("%s: %s", ErrorRedisUrlInvalid, redisUrl))
+	parsedUrl, urlErr := url.Parse(redisUrl)
+	if urlErr != nil || parsedUrl.Scheme != "redis" || parsedUrl.Hostname() == "" {
+		return redisAddr{}, invalidErr
+	}
+
+	addr := redisAddr{host: parsedUrl.Host}
+	if parsedUrl.Port() == "" {
+		addr.host = net.JoinHostPort(parsedUrl.Hostname(), DefaultRedisPort)
+	}
+	if parsedUrl.User != nil {
+		addr.password, _ = parsedUrl.User.Password()
+	}
+	if db := strings.Trim(parsedUrl.Path, "/"); db != "" {
+		dbInt, dbErr := strconv.Atoi(db)
+		if dbErr != nil || dbInt < 0 {
+			return redisAddr{}, invalidErr
+		}
+		addr.db = dbInt
+	}
+	return addr, nil
+}
+
+// redis connection speaking the RESP protocol
+// Only the commands required for publishing and subscribing are used
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// Dial redis server, authenticating and selecting the db if required
+func dialRedis(addr redisAddr, timeout time.Duration) (*redisConn, error) {
+	conn, dialErr := net.DialTimeout("tcp", addr.host, timeout)
+	if dialErr != nil {
+		return nil, dialErr
+	}
+	c := &redisConn{conn: conn, reader: bufio.NewReader(conn)}
+	if addr.password != "" {
+		if _, authErr := c.do(timeout, "AUTH", addr.password); authErr != nil {
+			c.Close()
+			return nil, authErr
+		}
+	}
+	if addr.db != 0 {
+		if _, selectErr := c.do(timeout, "SELECT", strconv.Itoa(addr.db)); selectErr != nil {
+			c.Close()
+			return nil, selectErr
+		}
+	}
+	return c, nil
+}
+
+// Send command and return its reply within timeout
+func (c *redisConn) do(timeout time.Duration, args ...string) (interface{}, error) {
+	c.conn.SetDeadline(time.Now().Add(timeout))
+	defer c.conn.SetDeadline(time.Time{})
+	if sendErr := c.send(args...); sendErr != nil {
+		return nil, sendErr
+	}
+	return c.receive()
+}
+
+// Send command as an array of bulk strings
+func (c *redisConn) send(args ...string) error {
+	var cmd strings.Builder
+	cmd.WriteString(fmt.Sprintf("*%d\r\n", len(args)))
+	for _, arg := range args {
+		cmd.WriteString(fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg))
+	}
+	_, writeErr := io.WriteString(c.conn, cmd.String())
+	return writeErr
+}
+
+// Receive next reply, returning redis error replies as errors
+// Replies are strings, integers, nil or arrays of replies
+func (c *redisConn) receive() (interface{}, error) {
+	line, readErr := c.reader.ReadString('\n')
+	if readErr != nil {
+		return nil, readErr
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, errors.New(fmt.Sprintf("%s: %q", ErrorRedisReply, line))
+	}
+	prefix, value := line[0], line[1:len(line)-2]
+
+	switch prefix {
+	case '+':
+		return value, nil
+	case '-':
+		return nil, errors.New(value)
+	case ':':
+		return strconv.ParseInt(value, 10, 64)
+	case '$':
+		size, sizeErr := strconv.Atoi(value)
+		if sizeErr != nil {
+			return nil, sizeErr
+		} else if size < 0 {
+			return nil, nil
+		}
+		bulk := make([]byte, size+2)
+		if _, bulkErr := io.ReadFull(c.reader, bulk); bulkErr != nil {
+			return nil, bulkErr
+		}
+		return string(bulk[:size]), nil
+	case '*':
+		size, sizeErr := strconv.Atoi(value)
+		if sizeErr != nil {
+			return nil, sizeErr
+		} else if size < 0 {
+			return nil, nil
+		}
+		replies := make([]interface{}, size)
+		for i := range replies {
+			reply, replyErr := c.receive()
+			if replyErr != nil {
+				return nil, replyErr
+			}
+			replies[i] = reply
+		}
+		return replies, nil
+	}
+	return nil, errors.New(fmt.Sprintf("%s: %q", ErrorRedisReply, line))
+}
+
+// Close connection
+func (c *redisConn) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/log"
+	"mainstay/models"
+)
+
+// webhook consts
+const (
+	DefaultWebhookRetries = 3                // attempts after the first failed post
+	WebhookRetryDelay     = 5 * time.Second  // delay multiplied by attempt between retries
+	RequestTimeout        = 10 * time.Second // timeout of each post
+	WebhookQueueSize      = 100              // events queued before dropping new events
+
+	HeaderEvent     = "X-MAINSTAY-EVENT"
+	HeaderSignature = "X-MAINSTAY-SIGNATURE"
+
+	ErrorWebhookUrlInvalid = "Invalid webhook url"
+	ErrorResponse          = "Notification response error"
+
+	WarningInvalidWebhookRetriesArg = "Invalid webhook retries config value"
+	WarningWebhookSecretNotSet      = "Webhook secret not set - payloads will not be signed"
+	WarningWebhookQueueFull         = "Webhook queue full - dropping event"
+	WarningWebhookFailed            = "Webhook notification failed"
+)
+
+// Notifier interface
+// Notified of attestation lifecycle events by the attestation service
+type Notifier interface {
+	Notify(event models.AttestationEvent)
+}
+
+// Check that the webhook url is an http or https url
+func ValidateUrl(webhookUrl string) error {
+	parsedUrl, urlErr := url.Parse(webhookUrl)
+	if urlErr != nil || parsedUrl.Host == "" ||
+		(parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") {
+		return errors.New(fmt.Sprintf("%s: %s", ErrorWebhookUrlInvalid, webhookUrl))
+	}
+	return nil
+}
+
+// Return hex encoded hmac-sha256 signature of the payload with the secret
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Webhook struct
+// Posts events to the webhook urls from a queue, in order, so that
+// notifying never blocks the attestation service
+type Webhook struct {
+	urls       []string
+	secret     string
+	retries    int
+	retryDelay time.Duration
+	client     *http.Client
+	queue      chan models.AttestationEvent
+}
+
+// Return new Webhook instance from webhook config and start posting events
+func NewWebhook(webhookConfig confpkg.WebhookConfig) *Webhook {
+	retries := DefaultWebhookRetries
+	if webhookConfig.Retries >= 0 {
+		retries = webhookConfig.Retries
+	} else {
+		log.Warnf("%s (%d)\n", WarningInvalidWebhookRetriesArg, webhookConfig.Retries)
+	}
+	if webhookConfig.Secret == "" {
+		log.Warnln(WarningWebhookSecretNotSet)
+	}
+	log.Infof("*Webhook* Notifying %d webhook urls with %d retries\n", len(webhookConfig.Urls), retries)
+
+	w := &Webhook{
+		urls:       webhookConfig.Urls,
+		secret:     webhookConfig.Secret,
+		retries:    retries,
+		retryDelay: WebhookRetryDelay,
+		client:     &http.Client{Timeout: RequestTimeout},
+		queue:      make(chan models.AttestationEvent, WebhookQueueSize),
+	}
+	go w.run()
+	return w
+}
+
+// Queue event to be posted to the webhook urls
+func (w *Webhook) Notify(event models.AttestationEvent) {
+	select {
+	case w.queue <- event:
+	default:
+		log.WithFields(log.Fields{log.FieldTxid: event.Txid}).Warnln(WarningWebhookQueueFull)
+	}
+}
+
+// Post queued events to each webhook url
+func (w *Webhook) run() {
+	for event := range w.queue {
+		payload, marshalErr := json.Marshal(event)
+		if marshalErr != nil {
+			log.WithFields(log.Fields{log.FieldError: marshalErr}).Warnln(WarningWebhookFailed)
+			continue
+		}
+		for _, webhookUrl := range w.urls {
+			if postErr := w.postWithRetries(webhookUrl, event.Event, payload); postErr != nil {
+				log.WithFields(log.Fields{log.FieldTxid: event.Txid, log.FieldError: postErr}).Warnln(WarningWebhookFailed)
+			}
+		}
+	}
+}
+
+// Post payload to webhook url, retrying with increasing delay on failure
+func (w *Webhook) postWithRetries(webhookUrl string, event string, payload []byte) error {
+	var postErr error
+	for attempt := 0; attempt <= w.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * w.retryDelay)
+		}
+		if postErr = w.post(webhookUrl, event, payload); postErr == nil {
+			return nil
+		}
+	}
+	return postErr
+}
+
+// Post payload to webhook url with the event and signature headers
+func (w *Webhook) post(webhookUrl string, event string, payload []byte) error {
+	headers := map[string]string{HeaderEvent: event}
+	if w.secret != "" {
+		headers[HeaderSignature] = Sign(w.secret, payload)
+	}
+	return postJson(w.client, webhookUrl, payload, headers)
+}
+
+// Post json payload to url with additional headers
+// Returns error if the response status is not successful
+func postJson(client *http.Client, postUrl string, payload []byte, headers map[string]string) error {
+	req, reqErr := http.NewRequest(http.MethodPost, postUrl, bytes.NewReader(payload))
+	if reqErr != nil {
+		return reqErr
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, respErr := client.Do(req)
+	if respErr != nil {
+		return respErr
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.New(fmt.Sprintf("%s: %s", ErrorResponse, resp.Status))
+	}
+	return nil
+}
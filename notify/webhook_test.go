@@ -0,0 +1,94 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test webhook url validation and payload signing
+func TestWebhookValidate(t *testing.T) {
+	assert.Equal(t, nil, ValidateUrl("http://localhost:9000/hook"))
+	assert.Equal(t, nil, ValidateUrl("https://example.com/hook"))
+	assert.Equal(t, ErrorWebhookUrlInvalid+": example.com/hook", ValidateUrl("example.com/hook").Error())
+	assert.Equal(t, ErrorWebhookUrlInvalid+": ftp://example.com", ValidateUrl("ftp://example.com").Error())
+
+	// hmac-sha256 test vector from RFC 4231
+	assert.Equal(t, "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843",
+		Sign("Jefe", []byte("what do ya want for nothing?")))
+}
+
+// Test webhook notifications are posted signed and retried on failure
+func TestWebhookNotify(t *testing.T) {
+	type received struct {
+		event     models.AttestationEvent
+		header    string
+		signature string
+		payload   []byte
+	}
+	receivedCh := make(chan received, 10)
+	failures := 2
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		payload, _ := io.ReadAll(r.Body)
+		var event models.AttestationEvent
+		assert.Equal(t, nil, json.Unmarshal(payload, &event))
+		receivedCh <- received{event, r.Header.Get(HeaderEvent), r.Header.Get(HeaderSignature), payload}
+	}))
+	defer server.Close()
+
+	webhook := NewWebhook(confpkg.WebhookConfig{Urls: []string{server.URL}, Secret: "secret", Retries: 2})
+	webhook.retryDelay = time.Millisecond
+
+	event := models.AttestationEvent{Event: models.AttestationEventBroadcast, Txid: "abc", Commitment: "def", Time: 1}
+	webhook.Notify(event)
+	select {
+	case r := <-receivedCh:
+		assert.Equal(t, event, r.event)
+		assert.Equal(t, models.AttestationEventBroadcast, r.header)
+		assert.Equal(t, Sign("secret", r.payload), r.signature)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not received")
+	}
+
+	// event dropped after retries are exhausted and next event posted
+	failures = 3
+	webhook.Notify(event)
+	event2 := models.AttestationEvent{Event: models.AttestationEventConfirmed, Txid: "abc", Commitment: "def", Blockhash: "ghi", Time: 2}
+	webhook.Notify(event2)
+	select {
+	case r := <-receivedCh:
+		assert.Equal(t, event2, r.event)
+		assert.Equal(t, models.AttestationEventConfirmed, r.header)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not received")
+	}
+	assert.Equal(t, 0, failures)
+
+	// no signature without secret
+	webhook = NewWebhook(confpkg.WebhookConfig{Urls: []string{server.URL}, Retries: -1})
+	assert.Equal(t, DefaultWebhookRetries, webhook.retries)
+	webhook.Notify(event)
+	select {
+	case r := <-receivedCh:
+		assert.Equal(t, "", r.signature)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not received")
+	}
+}
@@ -0,0 +1,104 @@
+/*
+Package requestapi implements the request api service.
+
+The service accepts client commitments over http and stores them as the
+latest commitment for the client slot, to be picked up by the attestation
+service in the next attestation round.
+
+Commitment requests are authenticated using one of the configured schemes:
+the auth token (and optional ECDSA signature) issued at client signup, or
+HMAC request signing with a per-slot shared secret managed via the admin
+routes. Commitments of several slots can be submitted in one bulk request,
+authenticated per slot with the auth token, and are only stored if all of
+them are valid.
+
+Each accepted commitment is recorded as a submission, returned with its id.
+A commitment equal to the latest submission of the slot is not stored again
+and the existing submission is returned marked as duplicate. Submissions are
+resolved when attested, as included in the attestation or superseded by a
+later submission of the slot, and their status, with the attestation txid,
+is returned by the commitment submission route.
+
+Commitments submitted before an attestation round closed but not included
+in that round are recorded by the attestation service and returned to the
+client on request, as is the history of the commitments of the client slot
+included in attestation rounds, paginated with offset and limit parameters.
+
+The address of any past attestation can be re-derived from the stored
+commitments for audits through the derivation history route. The attestation
+route returns the height, hash and time of the block confirming an
+attestation and the fee paid, for proofs referencing an exact block.
+
+The staychain routes return the staychain transactions indexed from the main
+chain by the staychain indexer, with the blocks confirming them and the
+transactions they spend and are spent by, for explorer style browsing.
+
+Proof bundles encode the side of each proof op either with an append flag
+or positionally, as configured and declared by the protocol route along
+with the merkle tree hash function and the ordering of the client
+commitments at the merkle tree leaves. A configured transition of the hash
+function is declared by the protocol route too, with preview proofs in the
+next format returned by the commitment proof route for the dual running
+rounds before the switchover. The
+latest proof route returns the proof of a client slot in the latest confirmed
+attestation, with the height of the block confirming it. Confirmed proofs
+include an spv proof of the attestation transaction in the block, when the
+main chain backend can provide it.
+
+The commitment proof route also returns proofs in a compact binary encoding,
+base64 encoded, for sidechains embedding proofs in their blocks.
+
+Proofs are also returned in the response format of the hosted mainstay api
+by the hosted routes under /api/v1/commitment, taking query parameters as
+the hosted api, so that its client libraries work against a self-hosted
+instance.
+
+Proofs of confirmed attestations are also exported as RFC 3161 timestamp
+responses by the commitment timestamp route, for clients whose tooling only
+verifies standard timestamp tokens.
+
+Clients register an s3 or sftp target through the client delivery route,
+authenticated as for commitments, to have the proofs of their commitments
+pushed to it once confirmed.
+
+Public api routes are versioned, served under /api/v1/ as well as the
+unversioned /api/ prefix, with the version of unversioned requests negotiated
+from the Accept header and defaulting to v1.
+
+An OpenAPI v3 document of the routes, generated at runtime from the route
+table, is served by the api spec route, with an interactive ui under it.
+
+Attestations, their commitments and the commitment proofs can be queried
+with GraphQL at the graphql route, selecting nested fields of many
+attestations in one request instead of a request per commitment. Queries
+are validated and executed by the graphql-go library against the schema
+of the service, after a check of their max depth.
+
+Errors are returned in a common envelope with the error message, a
+machine-readable code and the request id, with the http status of the error.
+
+Admin and management routes can be restricted to allowlisted source addresses
+and to client certificates issued by an admin client ca, checked by the router
+on the matched route before the admin token, with public routes unaffected.
+With an admin port configured, admin and management routes are served only
+by a separate admin api, which requires admin client certificates in the tls
+handshake, leaving the public api with the proof and commitment routes.
+
+Browser based clients and explorers from the configured origins can call the
+public routes cross-origin, with preflight requests answered by the router.
+Standard security headers are set on every response.
+
+The api is served over https with certificate files or with certificates
+obtained by acme for the configured domains, with plain http optionally
+redirected to https. Requests are served with read, write and idle timeouts
+and a request body size limit, and in-flight requests are drained on shutdown.
+
+A snapshot of the staychain balance, the last confirmed attestation, the
+attestation state machine and the signer health is returned by the status
+route for operators and clients.
+
+Rotations of the federation keys are requested and cancelled through the
+admin rotation routes and, once active, published by the rotations route
+so that verifiers can follow the staychain across transition attestations.
+*/
+package requestapi
@@ -0,0 +1,147 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+
+	"mainstay/log"
+)
+
+// error / warning consts
+const (
+	ErrorAdminAccessDenied      = "Admin access denied"
+	ErrorAdminSourceNotAllowed  = "Source address not in admin allowlist"
+	ErrorAdminClientCertMissing = "Admin client certificate required"
+	ErrorAdminClientCertInvalid = "Invalid admin client certificate"
+	ErrorAdminClientCaFile      = "Could not load admin client ca file"
+	ErrorApiTlsFiles            = "Api tls certificate and key files both required"
+
+	WarningAdminAllowlistEntry = "Invalid admin allowlist entry - ignored"
+	WarningAdminClientCa       = "Admin client ca not loaded - admin routes unreachable"
+	WarningAdminClientCaNoTls  = "Admin client certificates require tls - admin routes unreachable"
+)
+
+// AdminAccess struct
+// Restricts admin and management routes to requests from allowlisted source
+// addresses and, optionally, presenting a client certificate issued by the
+// admin client ca. Checks are made before admin authorization and do not
+// apply to the public api routes
+type AdminAccess struct {
+	allowlist bool
+	allowed   []*net.IPNet
+	clientCas *x509.CertPool
+}
+
+// Return AdminAccess for the allowed source addresses, IPs or CIDR ranges,
+// and the admin client ca file, or nil if neither is set. Invalid entries
+// are ignored and an unreadable ca file rejects all admin requests
+func NewAdminAccess(allowedIps []string, clientCaFile string) *AdminAccess {
+	if len(allowedIps) == 0 && clientCaFile == "" {
+		return nil
+	}
+	access := &AdminAccess{allowlist: len(allowedIps) > 0}
+	for _, entry := range allowedIps {
+		ipNet, entryErr := ParseAdminAllowlistEntry(entry)
+		if entryErr != nil {
+			log.Warnf("%s: %s\n", WarningAdminAllowlistEntry, entry)
+			continue
+		}
+		access.allowed = append(access.allowed, ipNet)
+	}
+	if clientCaFile != "" {
+		clientCas, caErr := LoadAdminClientCas(clientCaFile)
+		if caErr != nil {
+			log.Warnf("%s: %v\n", WarningAdminClientCa, caErr)
+			clientCas = x509.NewCertPool()
+		}
+		access.clientCas = clientCas
+	}
+	return access
+}
+
+// Parse admin allowlist entry, a single IP or a CIDR range
+func ParseAdminAllowlistEntry(entry string) (*net.IPNet, error) {
+	if strings.Contains(entry, "/") {
+		_, ipNet, cidrErr := net.ParseCIDR(entry)
+		return ipNet, cidrErr
+	}
+	ip := net.ParseIP(strings.Trim(entry, "[]"))
+	if ip == nil {
+		return nil, errors.New(fmt.Sprintf("invalid IP address %s", entry))
+	}
+	bits := 8 * net.IPv4len
+	if ip.To4() == nil {
+		bits = 8 * net.IPv6len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// Load pool of the admin client ca certificates from a pem file
+func LoadAdminClientCas(clientCaFile string) (*x509.CertPool, error) {
+	pem, readErr := ioutil.ReadFile(clientCaFile)
+	if readErr != nil {
+		return nil, errors.New(fmt.Sprintf("%s %v", ErrorAdminClientCaFile, readErr))
+	}
+	clientCas := x509.NewCertPool()
+	if !clientCas.AppendCertsFromPEM(pem) {
+		return nil, errors.New(fmt.Sprintf("%s %s: no certificates", ErrorAdminClientCaFile, clientCaFile))
+	}
+	return clientCas, nil
+}
+
+// Check admin request source address and client certificate
+func (a *AdminAccess) Authorize(r *http.Request) error {
+	if a.allowlist && !a.isAllowedSource(r.RemoteAddr) {
+		return errors.New(ErrorAdminSourceNotAllowed)
+	}
+	if a.clientCas != nil {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			return errors.New(ErrorAdminClientCertMissing)
+		}
+		intermediates := x509.NewCertPool()
+		for _, cert := range r.TLS.PeerCertificates[1:] {
+			intermediates.AddCert(cert)
+		}
+		if _, verifyErr := r.TLS.PeerCertificates[0].Verify(x509.VerifyOptions{
+			Roots:         a.clientCas,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}); verifyErr != nil {
+			return errors.New(fmt.Sprintf("%s: %v", ErrorAdminClientCertInvalid, verifyErr))
+		}
+	}
+	return nil
+}
+
+// Check request remote address is in the allowlist
+// The connection address is used, as forwarding headers can be set by clients
+func (a *AdminAccess) isAllowedSource(remoteAddr string) bool {
+	host, _, splitErr := net.SplitHostPort(remoteAddr)
+	if splitErr != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range a.allowed {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return whether the route pattern is an admin route
+func isAdminRoute(pattern string) bool {
+	return strings.HasPrefix(pattern, RouteAdminPrefix)
+}
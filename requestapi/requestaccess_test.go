@@ -0,0 +1,175 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Return certificate signed by the parent, or self signed if no parent
+func newTestCert(t *testing.T, name string, isCa bool, parent *x509.Certificate,
+	parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+
+	key, keyErr := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if keyErr != nil {
+		t.Fatal(keyErr)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  isCa,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, certErr := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if certErr != nil {
+		t.Fatal(certErr)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+// Return admin request from the remote address with the client certificates
+func adminRequest(path string, remoteAddr string, certs ...*x509.Certificate) *http.Request {
+	r := httptest.NewRequest(GET, path, nil)
+	r.Header.Set(HeaderAuthorization, "Bearer admin")
+	r.RemoteAddr = remoteAddr
+	if len(certs) > 0 {
+		r.TLS = &tls.ConnectionState{PeerCertificates: certs}
+	}
+	return r
+}
+
+// Test parsing of admin allowlist entries
+func TestParseAdminAllowlistEntry(t *testing.T) {
+	for entry, expected := range map[string]string{
+		"127.0.0.1":   "127.0.0.1/32",
+		"10.0.0.0/8":  "10.0.0.0/8",
+		"10.1.2.3/16": "10.1.0.0/16",
+		"::1":         "::1/128",
+		"[fd00::1]":   "fd00::1/128",
+		"fd00::/8":    "fd00::/8",
+	} {
+		ipNet, err := ParseAdminAllowlistEntry(entry)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, expected, ipNet.String())
+	}
+	for _, entry := range []string{"", "localhost", "10.0.0.300", "10.0.0.0/33"} {
+		_, err := ParseAdminAllowlistEntry(entry)
+		assert.NotEqual(t, nil, err)
+	}
+}
+
+// Test admin routes restricted to allowlisted source addresses
+func TestAdminAccessAllowlist(t *testing.T) {
+	service := NewRequestService(nil, nil, db.NewDbFake(), confpkg.ApiConfig{AdminToken: "admin",
+		AdminAllowedIps: []string{"10.0.0.0/8", "::1", "invalid"}})
+
+	for _, remoteAddr := range []string{"10.1.2.3:5000", "[::1]:5000", "[::ffff:10.0.0.1]:5000"} {
+		code, response := serveRouter(service.router, adminRequest(RouteAdminRounds, remoteAddr))
+		assert.Equal(t, http.StatusOK, code)
+		assert.Nil(t, response["error"])
+	}
+	for _, remoteAddr := range []string{"192.168.0.1:5000", "[fd00::1]:5000", "", "invalid"} {
+		code, response := serveRouter(service.router, adminRequest(RouteAdminRounds, remoteAddr))
+		assert.Equal(t, http.StatusForbidden, code)
+		assert.Equal(t, ErrorAdminAccessDenied, response["error"])
+	}
+
+	// checked on the matched route whatever the request path
+	code, _ := serveRouter(service.router, adminRequest("/admin/rounds", "192.168.0.1:5000"))
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = serveRouter(service.router, adminRequest(RouteAdminIntegrity, "192.168.0.1:5000"))
+	assert.Equal(t, http.StatusForbidden, code)
+
+	// public routes are not restricted
+	code, response := serveRouter(service.router, adminRequest(RouteProtocol, "192.168.0.1:5000"))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Nil(t, response["error"])
+
+	// admin authorization still required from allowed addresses
+	r, _ := http.NewRequest(GET, RouteAdminRounds, nil)
+	r.RemoteAddr = "10.1.2.3:5000"
+	assert.Equal(t, ErrorAdminUnauthorized, serveRequest(t, service, r)["error"])
+
+	// all sources rejected if no valid entries
+	service = NewRequestService(nil, nil, db.NewDbFake(), confpkg.ApiConfig{AdminToken: "admin",
+		AdminAllowedIps: []string{"invalid"}})
+	code, _ = serveRouter(service.router, adminRequest(RouteAdminRounds, "10.1.2.3:5000"))
+	assert.Equal(t, http.StatusForbidden, code)
+
+	// no restriction if not configured
+	service = NewRequestService(nil, nil, db.NewDbFake(), confpkg.ApiConfig{AdminToken: "admin"})
+	assert.Equal(t, (*AdminAccess)(nil), service.adminAccess)
+	code, _ = serveRouter(service.router, adminRequest(RouteAdminRounds, "192.168.0.1:5000"))
+	assert.Equal(t, http.StatusOK, code)
+}
+
+// Test admin routes restricted to client certificates issued by the admin client ca
+func TestAdminAccessClientCert(t *testing.T) {
+	caCert, caKey := newTestCert(t, "admin ca", true, nil, nil)
+	interCert, interKey := newTestCert(t, "admin intermediate", true, caCert, caKey)
+	clientCert, _ := newTestCert(t, "admin", false, caCert, caKey)
+	interClientCert, _ := newTestCert(t, "admin", false, interCert, interKey)
+	otherCert, _ := newTestCert(t, "other", false, nil, nil)
+
+	caFile := filepath.Join(t.TempDir(), "admin-ca.pem")
+	caPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert.Raw})
+	if err := ioutil.WriteFile(caFile, caPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, caErr := LoadAdminClientCas(caFile + ".missing")
+	assert.NotEqual(t, nil, caErr)
+
+	service := NewRequestService(nil, nil, db.NewDbFake(), confpkg.ApiConfig{AdminToken: "admin",
+		AdminAllowedIps: []string{"10.0.0.0/8"}, AdminClientCaFile: caFile})
+
+	code, _ := serveRouter(service.router, adminRequest(RouteAdminRounds, "10.1.2.3:5000", clientCert))
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = serveRouter(service.router, adminRequest(RouteAdminRounds, "10.1.2.3:5000", interClientCert, interCert))
+	assert.Equal(t, http.StatusOK, code)
+
+	// certificate and allowlist both required
+	code, _ = serveRouter(service.router, adminRequest(RouteAdminRounds, "192.168.0.1:5000", clientCert))
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = serveRouter(service.router, adminRequest(RouteAdminRounds, "10.1.2.3:5000"))
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = serveRouter(service.router, adminRequest(RouteAdminRounds, "10.1.2.3:5000", otherCert))
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = serveRouter(service.router, adminRequest(RouteAdminRounds, "10.1.2.3:5000", interClientCert))
+	assert.Equal(t, http.StatusForbidden, code)
+
+	// all admin requests rejected if the client ca cannot be loaded
+	service = NewRequestService(nil, nil, db.NewDbFake(), confpkg.ApiConfig{AdminToken: "admin",
+		AdminClientCaFile: caFile + ".missing"})
+	code, _ = serveRouter(service.router, adminRequest(RouteAdminRounds, "10.1.2.3:5000", clientCert))
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = serveRouter(service.router, adminRequest(RouteProtocol, "10.1.2.3:5000"))
+	assert.Equal(t, http.StatusOK, code)
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"crypto/tls"
+	"net"
+
+	confpkg "mainstay/config"
+)
+
+// Separate admin api
+// Operator actions, e.g. pausing attestations, forcing an attestation,
+// rotating the federation keys and topup script or managing client slots,
+// are served by a separate admin api when an admin port is configured, so
+// that they can be bound to a private interface and firewalled independently
+// of the public proof api. The admin and management routes are then no longer
+// routed by the public api. Over tls, admin clients must present a
+// certificate issued by the admin client ca in the tls handshake, if one is
+// configured, in addition to the admin token required by every admin route
+
+// error consts
+const (
+	ErrorAdminPortInvalid  = "Invalid admin api port"
+	ErrorAdminPortConflict = "Admin api port must differ from the api port"
+)
+
+// Return whether the admin routes are served by a separate admin api
+func AdminApiEnabled(config confpkg.ApiConfig) bool {
+	return config.AdminPort != ""
+}
+
+// Return address the admin api listens on, from the admin host, all
+// interfaces if not set, and admin port of the api config, or "" if the
+// admin routes are served by the public api
+func AdminAddress(config confpkg.ApiConfig) string {
+	if !AdminApiEnabled(config) {
+		return ""
+	}
+	return net.JoinHostPort(confpkg.NormalizeHost(config.AdminHost), config.AdminPort)
+}
+
+// Return routes of the public api and of the admin api, with the admin
+// routes only split out if served by a separate admin api
+func splitAdminRoutes(routes []Route, separate bool) ([]Route, []Route) {
+	if !separate {
+		return routes, nil
+	}
+	var public, admin []Route
+	for _, route := range routes {
+		if isAdminRoute(route.pattern) {
+			admin = append(admin, route)
+		} else {
+			public = append(public, route)
+		}
+	}
+	return public, admin
+}
+
+// Return tls config of the admin api from the tls config of the api, or nil
+// if tls is not enabled. Client certificates issued by the admin client ca
+// are required in the handshake if the ca is configured
+func newAdminTlsConfig(apiTlsConfig *tls.Config, access *AdminAccess) *tls.Config {
+	if apiTlsConfig == nil {
+		return nil
+	}
+	tlsConfig := apiTlsConfig.Clone()
+	if access != nil && access.clientCas != nil {
+		tlsConfig.ClientCAs = access.clientCas
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	confpkg "mainstay/config"
+	"mainstay/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test address of the admin api
+func TestAdminAddress(t *testing.T) {
+	assert.Equal(t, false, AdminApiEnabled(confpkg.ApiConfig{}))
+	assert.Equal(t, "", AdminAddress(confpkg.ApiConfig{AdminHost: "127.0.0.1"}))
+	assert.Equal(t, ":9090", AdminAddress(confpkg.ApiConfig{AdminPort: "9090"}))
+	assert.Equal(t, "127.0.0.1:9090", AdminAddress(confpkg.ApiConfig{AdminHost: "127.0.0.1", AdminPort: "9090"}))
+	assert.Equal(t, "[::1]:9090", AdminAddress(confpkg.ApiConfig{AdminHost: "[::1]", AdminPort: "9090"}))
+}
+
+// Test admin routes served by the public api or by the admin api
+func TestAdminRouter(t *testing.T) {
+	// admin routes served by the public api
+	service := NewRequestService(nil, nil, db.NewDbFake(), confpkg.ApiConfig{AdminToken: "admin"})
+	assert.Nil(t, service.adminRouter)
+	_, response := serveRouter(service.router, httptest.NewRequest(POST, RouteAdminPause, nil))
+	assert.Equal(t, ErrorAdminUnauthorized, response["error"])
+
+	// admin routes only served by the admin api
+	service = NewRequestService(nil, nil, db.NewDbFake(),
+		confpkg.ApiConfig{AdminToken: "admin", AdminPort: "9090"})
+	for _, path := range []string{RouteAdminPause, RouteAdminRotation, "/admin/client/1/hmac/", RouteAdminIntegrity} {
+		status, response := serveRouter(service.router, httptest.NewRequest(GET, path, nil))
+		assert.Equal(t, http.StatusNotFound, status, path)
+		assert.Equal(t, ErrorRouteNotFound, response["error"], path)
+	}
+	_, response = serveRouter(service.adminRouter, httptest.NewRequest(POST, RouteAdminPause, nil))
+	assert.Equal(t, ErrorAdminUnauthorized, response["error"])
+	_, response = serveRouter(service.adminRouter, httptest.NewRequest(GET, RouteAdminIntegrity, nil))
+	assert.Equal(t, ErrorAdminUnauthorized, response["error"])
+
+	// public routes only served by the public api
+	status, _ := serveRouter(service.router, httptest.NewRequest(GET, RouteProtocol, nil))
+	assert.Equal(t, http.StatusOK, status)
+	_, response = serveRouter(service.adminRouter, httptest.NewRequest(GET, RouteProtocol, nil))
+	assert.Equal(t, ErrorRouteNotFound, response["error"])
+	_, response = serveRouter(service.adminRouter, httptest.NewRequest(GET, "/api/v1"+RouteAdminPause, nil))
+	assert.Equal(t, ErrorRouteNotFound, response["error"])
+
+	// admin routes left out of the public api spec
+	for _, route := range service.router.routes {
+		assert.Equal(t, false, isAdminRoute(route.pattern), route.pattern)
+	}
+}
+
+// Test tls config of the admin api
+func TestNewAdminTlsConfig(t *testing.T) {
+	assert.Nil(t, newAdminTlsConfig(nil, nil))
+
+	apiTlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	tlsConfig := newAdminTlsConfig(apiTlsConfig, nil)
+	assert.Equal(t, uint16(tls.VersionTLS12), tlsConfig.MinVersion)
+	assert.Equal(t, tls.NoClientCert, tlsConfig.ClientAuth)
+
+	// client certificates of the admin client ca required in the handshake
+	clientCas := x509.NewCertPool()
+	tlsConfig = newAdminTlsConfig(apiTlsConfig, &AdminAccess{clientCas: clientCas})
+	assert.Equal(t, tls.RequireAndVerifyClientCert, tlsConfig.ClientAuth)
+	assert.Equal(t, clientCas, tlsConfig.ClientCAs)
+	assert.Equal(t, tls.NoClientCert, apiTlsConfig.ClientAuth)
+	assert.Nil(t, apiTlsConfig.ClientCAs)
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	b64 "encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// authentication schemes for commitment requests
+const (
+	AuthSchemeToken = "token" // auth token and optional ECDSA signature
+	AuthSchemeHmac  = "hmac"  // HMAC request signing with per-slot secret
+)
+
+// hmac request signing consts
+const (
+	HeaderAuthorization = "Authorization"
+	HeaderDate          = "X-MAINSTAY-DATE"
+	HeaderDigest        = "X-MAINSTAY-DIGEST"
+
+	HmacAuthorizationPrefix = "MAINSTAY-HMAC-SHA256"
+	HmacDigestPrefix        = "SHA-256="
+	HmacSecretSize          = 32
+
+	DefaultHmacReplayWindow = 5 * time.Minute
+)
+
+// error / warning consts
+const (
+	ErrorAuthSchemeDisabled    = "Authentication scheme not enabled"
+	ErrorAuthClientNotFound    = "Client position not found"
+	ErrorAuthTokenInvalid      = "Invalid auth token"
+	ErrorAuthSignatureInvalid  = "Invalid commitment signature"
+	ErrorAuthPubkeyInvalid     = "Invalid client pubkey"
+	ErrorHmacAuthorization     = "Invalid hmac authorization header"
+	ErrorHmacSecretNotSet      = "Hmac secret not set for client position"
+	ErrorHmacDateInvalid       = "Invalid or missing request date"
+	ErrorHmacDateOutsideWindow = "Request date outside replay window"
+	ErrorHmacDigestInvalid     = "Request body digest mismatch"
+	ErrorHmacSignatureInvalid  = "Invalid hmac signature"
+	ErrorHmacRequestReplayed   = "Request signature already used"
+	ErrorHmacPositionMismatch  = "Hmac position does not match payload position"
+	WarningUnknownAuthScheme   = "Unknown api auth scheme"
+)
+
+// Verify commitment request using the auth token issued on client signup
+// If the client has registered a pubkey the commitment signature is also checked
+func verifyTokenAuth(details models.ClientDetails, payload CommitmentSendPayload, sig64 string) error {
+	if details.AuthToken == "" ||
+		subtle.ConstantTimeCompare([]byte(payload.Token), []byte(details.AuthToken)) != 1 {
+		return errors.New(ErrorAuthTokenInvalid)
+	}
+	if details.Pubkey == "" {
+		return nil
+	}
+
+	pubkeyBytes, pubkeyBytesErr := hex.DecodeString(details.Pubkey)
+	if pubkeyBytesErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorAuthPubkeyInvalid, pubkeyBytesErr))
+	}
+	pubkey, pubkeyErr := btcec.ParsePubKey(pubkeyBytes, btcec.S256())
+	if pubkeyErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorAuthPubkeyInvalid, pubkeyErr))
+	}
+
+	sigBytes, sigBytesErr := b64.StdEncoding.DecodeString(sig64)
+	if sigBytesErr != nil {
+		return errors.New(ErrorAuthSignatureInvalid)
+	}
+	sig, sigErr := btcec.ParseDERSignature(sigBytes, btcec.S256())
+	if sigErr != nil {
+		return errors.New(ErrorAuthSignatureInvalid)
+	}
+
+	// commitment is signed in the byte order it is displayed
+	msg, msgErr := hex.DecodeString(payload.Commitment)
+	if msgErr != nil || !sig.Verify(msg, pubkey) {
+		return errors.New(ErrorAuthSignatureInvalid)
+	}
+	return nil
+}
+
+// Return body digest header value for request body
+func HmacBodyDigest(body []byte) string {
+	digest := sha256.Sum256(body)
+	return HmacDigestPrefix + b64.StdEncoding.EncodeToString(digest[:])
+}
+
+// Return string signed by client for hmac request signing
+// The string consists of the request method, path, date and body digest
+func HmacSigningString(method string, path string, date string, digest string) string {
+	return strings.Join([]string{method, path, date, digest}, "\n")
+}
+
+// Return base64 hmac signature of signing string using client secret
+func HmacSignature(secret []byte, signingString string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signingString))
+	return b64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Return authorization header value for client position and hmac signature
+func HmacAuthorization(position int32, signature string) string {
+	return fmt.Sprintf("%s Position=%d, Signature=%s", HmacAuthorizationPrefix, position, signature)
+}
+
+// Parse hmac authorization header and return client position and signature
+func ParseHmacAuthorization(header string) (int32, string, error) {
+	if !strings.HasPrefix(header, HmacAuthorizationPrefix+" ") {
+		return -1, "", errors.New(ErrorHmacAuthorization)
+	}
+
+	position := int64(-1)
+	var signature string
+	for _, field := range strings.Split(strings.TrimPrefix(header, HmacAuthorizationPrefix+" "), ",") {
+		keyValue := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(keyValue) != 2 {
+			return -1, "", errors.New(ErrorHmacAuthorization)
+		}
+		switch keyValue[0] {
+		case "Position":
+			var positionErr error
+			position, positionErr = strconv.ParseInt(keyValue[1], 10, 32)
+			if positionErr != nil {
+				return -1, "", errors.New(ErrorHmacAuthorization)
+			}
+		case "Signature":
+			signature = keyValue[1]
+		}
+	}
+	if position < 0 || signature == "" {
+		return -1, "", errors.New(ErrorHmacAuthorization)
+	}
+	return int32(position), signature, nil
+}
+
+// HmacAuth struct
+// Verifies hmac signed requests and keeps track of signatures
+// seen within the replay window to reject replayed requests
+type HmacAuth struct {
+	window time.Duration
+	mu     sync.Mutex
+	seen   map[string]time.Time
+}
+
+// Return new HmacAuth instance
+func NewHmacAuth(window time.Duration) *HmacAuth {
+	return &HmacAuth{window: window, seen: make(map[string]time.Time)}
+}
+
+// Verify hmac signed request against client secret
+// The request date must be within the replay window of the current time,
+// the body digest must match the request body and the signature must
+// not have been used before within the replay window
+func (h *HmacAuth) Verify(r *http.Request, body []byte, secret []byte, signature string, now time.Time) error {
+	date := r.Header.Get(HeaderDate)
+	dateTime, dateErr := http.ParseTime(date)
+	if dateErr != nil {
+		return errors.New(ErrorHmacDateInvalid)
+	}
+	if dateTime.Before(now.Add(-h.window)) || dateTime.After(now.Add(h.window)) {
+		return errors.New(ErrorHmacDateOutsideWindow)
+	}
+
+	digest := r.Header.Get(HeaderDigest)
+	if !hmac.Equal([]byte(digest), []byte(HmacBodyDigest(body))) {
+		return errors.New(ErrorHmacDigestInvalid)
+	}
+
+	expected := HmacSignature(secret, HmacSigningString(r.Method, r.URL.Path, date, digest))
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return errors.New(ErrorHmacSignatureInvalid)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sig, expiry := range h.seen { // prune signatures outside the window
+		if now.After(expiry) {
+			delete(h.seen, sig)
+		}
+	}
+	if _, ok := h.seen[signature]; ok {
+		return errors.New(ErrorHmacRequestReplayed)
+	}
+	h.seen[signature] = dateTime.Add(h.window)
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"mainstay/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test auth token verification of commitment requests
+func TestTokenAuthVerify(t *testing.T) {
+	details := models.ClientDetails{ClientPosition: 0, AuthToken: "token"}
+	commitment := "1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+
+	assert.Equal(t, nil, verifyTokenAuth(details, CommitmentSendPayload{commitment, 0, "token", nil}, ""))
+	for _, token := range []string{"", "toke", "tokens", "other"} {
+		assert.Equal(t, errors.New(ErrorAuthTokenInvalid),
+			verifyTokenAuth(details, CommitmentSendPayload{commitment, 0, token, nil}, ""), token)
+	}
+
+	// empty token not accepted for slots without an auth token
+	details.AuthToken = ""
+	assert.Equal(t, errors.New(ErrorAuthTokenInvalid),
+		verifyTokenAuth(details, CommitmentSendPayload{commitment, 0, "", nil}, ""))
+	assert.Equal(t, errors.New(ErrorAuthTokenInvalid),
+		verifyTokenAuth(details, CommitmentSendPayload{commitment, 0, "token", nil}, ""))
+}
+
+// Test hmac authorization header parsing
+func TestHmacAuthorization(t *testing.T) {
+	header := HmacAuthorization(3, "c2lnbmF0dXJl")
+	assert.Equal(t, "MAINSTAY-HMAC-SHA256 Position=3, Signature=c2lnbmF0dXJl", header)
+
+	position, signature, err := ParseHmacAuthorization(header)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int32(3), position)
+	assert.Equal(t, "c2lnbmF0dXJl", signature)
+
+	for _, invalid := range []string{
+		"",
+		"Bearer token",
+		"MAINSTAY-HMAC-SHA256 Position=3",
+		"MAINSTAY-HMAC-SHA256 Signature=c2lnbmF0dXJl",
+		"MAINSTAY-HMAC-SHA256 Position=-1, Signature=c2lnbmF0dXJl",
+		"MAINSTAY-HMAC-SHA256 Position=a, Signature=c2lnbmF0dXJl",
+	} {
+		_, _, err = ParseHmacAuthorization(invalid)
+		assert.Equal(t, errors.New(ErrorHmacAuthorization), err)
+	}
+}
+
+// Test hmac request verification including replay window
+func TestHmacAuthVerify(t *testing.T) {
+	secret := []byte("secret")
+	body := []byte(`{"X-MAINSTAY-PAYLOAD": "e30="}`)
+	now := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+	hmacAuth := NewHmacAuth(5 * time.Minute)
+
+	newRequest := func(date time.Time, digest string) (*http.Request, string) {
+		r, _ := http.NewRequest(POST, RouteCommitmentSend, bytes.NewReader(body))
+		r.Header.Set(HeaderDate, date.Format(http.TimeFormat))
+		r.Header.Set(HeaderDigest, digest)
+		signingString := HmacSigningString(POST, RouteCommitmentSend, date.Format(http.TimeFormat), digest)
+		return r, HmacSignature(secret, signingString)
+	}
+
+	// valid request
+	r, sig := newRequest(now, HmacBodyDigest(body))
+	assert.Equal(t, nil, hmacAuth.Verify(r, body, secret, sig, now))
+
+	// same request replayed
+	assert.Equal(t, errors.New(ErrorHmacRequestReplayed), hmacAuth.Verify(r, body, secret, sig, now))
+
+	// wrong secret
+	r, sig = newRequest(now.Add(-time.Second), HmacBodyDigest(body))
+	assert.Equal(t, errors.New(ErrorHmacSignatureInvalid), hmacAuth.Verify(r, body, []byte("other"), sig, now))
+
+	// body digest not matching
+	r, sig = newRequest(now, HmacBodyDigest([]byte("other")))
+	assert.Equal(t, errors.New(ErrorHmacDigestInvalid), hmacAuth.Verify(r, body, secret, sig, now))
+
+	// date outside replay window
+	r, sig = newRequest(now.Add(-6*time.Minute), HmacBodyDigest(body))
+	assert.Equal(t, errors.New(ErrorHmacDateOutsideWindow), hmacAuth.Verify(r, body, secret, sig, now))
+	r, sig = newRequest(now.Add(6*time.Minute), HmacBodyDigest(body))
+	assert.Equal(t, errors.New(ErrorHmacDateOutsideWindow), hmacAuth.Verify(r, body, secret, sig, now))
+
+	// missing date
+	r, sig = newRequest(now, HmacBodyDigest(body))
+	r.Header.Del(HeaderDate)
+	assert.Equal(t, errors.New(ErrorHmacDateInvalid), hmacAuth.Verify(r, body, secret, sig, now))
+
+	// seen signatures are pruned after the replay window
+	_, oldSig := newRequest(now, HmacBodyDigest(body))
+	assert.Equal(t, 1, len(hmacAuth.seen))
+	r, sig = newRequest(now.Add(11*time.Minute), HmacBodyDigest(body))
+	assert.Equal(t, nil, hmacAuth.Verify(r, body, secret, sig, now.Add(11*time.Minute)))
+	_, oldSeen := hmacAuth.seen[oldSig]
+	assert.Equal(t, false, oldSeen)
+	assert.Equal(t, 1, len(hmacAuth.seen))
+}
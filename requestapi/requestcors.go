@@ -0,0 +1,180 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"mainstay/log"
+)
+
+// Cross-origin requests and security headers of the request api
+// Browser based clients and explorers from the allowed origins can request
+// the public api routes directly, with preflight requests answered for the
+// allowed methods. Admin routes never allow cross-origin requests. Standard
+// security headers are set on every response
+
+// error / warning consts
+const (
+	ErrorCorsOriginInvalid = "Invalid cors origin"
+
+	WarningCorsOrigin = "Invalid cors origin - ignored"
+	WarningCorsMethod = "Unknown cors method - ignored"
+)
+
+// cors consts
+const (
+	CorsAllOrigins = "*"
+	CorsMaxAge     = "600"
+
+	HeaderOrigin                     = "Origin"
+	HeaderAccessControlRequestMethod = "Access-Control-Request-Method"
+)
+
+// default methods allowed for cross-origin requests
+var DefaultCorsMethods = []string{GET}
+
+// request headers allowed for cross-origin requests
+var corsAllowedHeaders = []string{"Content-Type", HeaderAuthorization, HeaderRequestId, HeaderDate, HeaderDigest}
+
+// security headers set on every response
+var securityHeaders = map[string]string{
+	"X-Content-Type-Options":  "nosniff",
+	"X-Frame-Options":         "DENY",
+	"Referrer-Policy":         "no-referrer",
+	"Content-Security-Policy": "default-src 'none'; frame-ancestors 'none'",
+}
+
+// strict transport security of responses served over tls
+const StrictTransportSecurity = "max-age=31536000"
+
+// Cors struct
+// Origins and methods allowed for cross-origin requests to the public routes
+type Cors struct {
+	allOrigins bool
+	origins    map[string]bool
+	methods    []string
+}
+
+// Return Cors for the allowed origins and methods, or nil if no origins are
+// allowed. Invalid origins and unknown methods are ignored and methods
+// default to GET
+func NewCors(origins []string, methods []string) *Cors {
+	if len(origins) == 0 {
+		return nil
+	}
+	cors := &Cors{origins: make(map[string]bool)}
+	for _, origin := range origins {
+		if originErr := ParseCorsOrigin(origin); originErr != nil {
+			log.Warnf("%s: %s\n", WarningCorsOrigin, origin)
+			continue
+		}
+		if origin == CorsAllOrigins {
+			cors.allOrigins = true
+		}
+		cors.origins[origin] = true
+	}
+	for _, method := range methods {
+		if !IsCorsMethod(method) {
+			log.Warnf("%s: %s\n", WarningCorsMethod, method)
+			continue
+		}
+		cors.methods = append(cors.methods, strings.ToUpper(method))
+	}
+	if len(cors.methods) == 0 {
+		cors.methods = DefaultCorsMethods
+	}
+	return cors
+}
+
+// Check cors origin is either all origins or a scheme and host without path
+func ParseCorsOrigin(origin string) error {
+	if origin == CorsAllOrigins {
+		return nil
+	}
+	originUrl, urlErr := url.Parse(origin)
+	if urlErr != nil || (originUrl.Scheme != "http" && originUrl.Scheme != "https") || originUrl.Host == "" ||
+		(originUrl.Path != "" && originUrl.Path != "/") || originUrl.RawQuery != "" || originUrl.User != nil {
+		return errors.New(fmt.Sprintf("%s: %s", ErrorCorsOriginInvalid, origin))
+	}
+	return nil
+}
+
+// Return whether the method is a request api method
+func IsCorsMethod(method string) bool {
+	method = strings.ToUpper(method)
+	return method == GET || method == POST || method == DELETE
+}
+
+// Return whether requests from the origin are allowed
+func (c *Cors) allowOrigin(origin string) bool {
+	return origin != "" && (c.allOrigins || c.origins[strings.TrimSuffix(origin, "/")])
+}
+
+// Return whether the method is allowed
+func (c *Cors) allowMethod(method string) bool {
+	return containsMethod(c.methods, method)
+}
+
+// Return whether the methods contain the method
+func containsMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// Set cors headers of requests from allowed origins with allowed methods
+func (c *Cors) SetHeaders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", HeaderOrigin)
+	if c.allowOrigin(r.Header.Get(HeaderOrigin)) && c.allowMethod(r.Method) {
+		c.setAllowOrigin(w, r)
+		w.Header().Set("Access-Control-Expose-Headers", HeaderRequestId)
+	}
+}
+
+// Respond to preflight request for a route with the route methods given,
+// allowing the requested method if allowed for cross-origin requests
+func (c *Cors) Preflight(w http.ResponseWriter, r *http.Request, routeMethods []string) {
+	method := r.Header.Get(HeaderAccessControlRequestMethod)
+	w.Header().Add("Vary", HeaderOrigin)
+	if c.allowOrigin(r.Header.Get(HeaderOrigin)) && c.allowMethod(method) && containsMethod(routeMethods, method) {
+		c.setAllowOrigin(w, r)
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(c.methods, ", "))
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(corsAllowedHeaders, ", "))
+		w.Header().Set("Access-Control-Max-Age", CorsMaxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Set allowed origin header to all origins or the request origin
+func (c *Cors) setAllowOrigin(w http.ResponseWriter, r *http.Request) {
+	if c.allOrigins {
+		w.Header().Set("Access-Control-Allow-Origin", CorsAllOrigins)
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", r.Header.Get(HeaderOrigin))
+	}
+}
+
+// Return whether the request is a cors preflight request
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get(HeaderAccessControlRequestMethod) != ""
+}
+
+// Set security headers, with strict transport security over tls
+func setSecurityHeaders(w http.ResponseWriter, r *http.Request) {
+	for header, value := range securityHeaders {
+		w.Header().Set(header, value)
+	}
+	if r.TLS != nil {
+		w.Header().Set("Strict-Transport-Security", StrictTransportSecurity)
+	}
+}
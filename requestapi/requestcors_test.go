@@ -0,0 +1,114 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	confpkg "mainstay/config"
+	"mainstay/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Return response of request from the origin, as a preflight request
+// for the method if preflight is set
+func serveCorsRequest(service *RequestService, method string, path string, origin string,
+	preflight bool) *httptest.ResponseRecorder {
+
+	r, _ := http.NewRequest(method, path, nil)
+	if preflight {
+		r, _ = http.NewRequest(http.MethodOptions, path, nil)
+		r.Header.Set(HeaderAccessControlRequestMethod, method)
+	}
+	if origin != "" {
+		r.Header.Set(HeaderOrigin, origin)
+	}
+	writer := httptest.NewRecorder()
+	service.router.ServeHTTP(writer, r)
+	return writer
+}
+
+// Test parsing of cors origins and methods
+func TestParseCorsOrigin(t *testing.T) {
+	for _, origin := range []string{"*", "https://explorer.example.com", "http://localhost:8080",
+		"https://explorer.example.com/"} {
+		assert.Equal(t, nil, ParseCorsOrigin(origin), origin)
+	}
+	for _, origin := range []string{"", "explorer.example.com", "ftp://explorer.example.com",
+		"https://explorer.example.com/path", "https://explorer.example.com?q=1", "https://user@example.com"} {
+		assert.NotEqual(t, nil, ParseCorsOrigin(origin), origin)
+	}
+
+	assert.Equal(t, true, IsCorsMethod("get"))
+	assert.Equal(t, true, IsCorsMethod(POST))
+	assert.Equal(t, false, IsCorsMethod("PATCH"))
+
+	assert.Nil(t, NewCors(nil, []string{GET}))
+	cors := NewCors([]string{"https://explorer.example.com", "explorer.example.com"}, []string{"patch"})
+	assert.Equal(t, &Cors{origins: map[string]bool{"https://explorer.example.com": true},
+		methods: DefaultCorsMethods}, cors)
+	assert.Equal(t, true, cors.allowOrigin("https://explorer.example.com/"))
+	assert.Equal(t, false, cors.allowOrigin("https://other.example.com"))
+	assert.Equal(t, false, cors.allowOrigin(""))
+}
+
+// Test cors headers and preflight responses of public and admin routes
+func TestCorsRequests(t *testing.T) {
+	origin := "https://explorer.example.com"
+
+	// cors not configured
+	service := NewRequestService(nil, nil, db.NewDbFake(), confpkg.ApiConfig{AdminToken: "admin"})
+	writer := serveCorsRequest(service, GET, RouteKeyRotations, origin, false)
+	assert.Equal(t, "", writer.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "nosniff", writer.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "DENY", writer.Header().Get("X-Frame-Options"))
+	assert.Equal(t, "", writer.Header().Get("Strict-Transport-Security"))
+	writer = serveCorsRequest(service, GET, RouteKeyRotations, origin, true)
+	assert.Equal(t, http.StatusMethodNotAllowed, writer.Code)
+
+	// allowed origin and methods
+	service = NewRequestService(nil, nil, db.NewDbFake(), confpkg.ApiConfig{AdminToken: "admin",
+		CorsAllowedOrigins: []string{origin}, CorsAllowedMethods: []string{GET, POST}})
+	writer = serveCorsRequest(service, GET, RouteKeyRotations, origin, false)
+	assert.Equal(t, origin, writer.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, HeaderRequestId, writer.Header().Get("Access-Control-Expose-Headers"))
+	assert.Equal(t, HeaderOrigin, writer.Header().Get("Vary"))
+	writer = serveCorsRequest(service, GET, RouteKeyRotations, "https://other.example.com", false)
+	assert.Equal(t, "", writer.Header().Get("Access-Control-Allow-Origin"))
+	writer = serveCorsRequest(service, GET, RouteKeyRotations, "", false)
+	assert.Equal(t, "", writer.Header().Get("Access-Control-Allow-Origin"))
+
+	writer = serveCorsRequest(service, POST, RouteCommitmentSend, origin, true)
+	assert.Equal(t, http.StatusNoContent, writer.Code)
+	assert.Equal(t, origin, writer.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, POST", writer.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, CorsMaxAge, writer.Header().Get("Access-Control-Max-Age"))
+
+	// method of another route is not allowed for the route
+	writer = serveCorsRequest(service, GET, RouteCommitmentSend, origin, true)
+	assert.Equal(t, http.StatusNoContent, writer.Code)
+	assert.Equal(t, "", writer.Header().Get("Access-Control-Allow-Origin"))
+
+	// admin routes never allow cross-origin requests
+	writer = serveCorsRequest(service, POST, RouteAdminAttest, origin, true)
+	assert.Equal(t, http.StatusMethodNotAllowed, writer.Code)
+	assert.Equal(t, "", writer.Header().Get("Access-Control-Allow-Origin"))
+	writer = serveCorsRequest(service, POST, RouteAdminAttest, origin, false)
+	assert.Equal(t, "", writer.Header().Get("Access-Control-Allow-Origin"))
+
+	// all origins and strict transport security over tls
+	service = NewRequestService(nil, nil, db.NewDbFake(), confpkg.ApiConfig{CorsAllowedOrigins: []string{"*"}})
+	r, _ := http.NewRequest(GET, RouteKeyRotations, nil)
+	r.Header.Set(HeaderOrigin, origin)
+	r.TLS = &tls.ConnectionState{}
+	writer = httptest.NewRecorder()
+	service.router.ServeHTTP(writer, r)
+	assert.Equal(t, CorsAllOrigins, writer.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, StrictTransportSecurity, writer.Header().Get("Strict-Transport-Security"))
+}
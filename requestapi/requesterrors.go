@@ -0,0 +1,179 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"mainstay/log"
+	"mainstay/models"
+)
+
+// Error envelope of the request api
+// Every error response carries the error message, a machine-readable code
+// and the id of the request, with the http status of the error. Errors are
+// bad requests unless listed with another status below, and the code is the
+// code of the status unless the error has a more specific code, e.g. the
+// codes of invalid commitments
+
+// error consts
+const (
+	ErrorRouteNotFound    = "Route not found"
+	ErrorMethodNotAllowed = "Method not allowed"
+	ErrorResponseEncode   = "Could not encode response"
+)
+
+// error codes of http statuses
+const (
+	CodeBadRequest       = "bad_request"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeNotAcceptable    = "not_acceptable"
+	CodePayloadTooLarge  = "payload_too_large"
+	CodeRateLimited      = "rate_limited"
+	CodeInternal         = "internal_error"
+	CodeUnavailable      = "unavailable"
+)
+
+// error code of each http status of error responses
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            CodeBadRequest,
+	http.StatusUnauthorized:          CodeUnauthorized,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusMethodNotAllowed:      CodeMethodNotAllowed,
+	http.StatusNotAcceptable:         CodeNotAcceptable,
+	http.StatusRequestEntityTooLarge: CodePayloadTooLarge,
+	http.StatusTooManyRequests:       CodeRateLimited,
+	http.StatusInternalServerError:   CodeInternal,
+	http.StatusServiceUnavailable:    CodeUnavailable,
+}
+
+// http status of errors that are not bad requests
+var errorStatuses = map[string]int{
+	ErrorAuthSchemeDisabled:    http.StatusUnauthorized,
+	ErrorAuthTokenInvalid:      http.StatusUnauthorized,
+	ErrorAuthSignatureInvalid:  http.StatusUnauthorized,
+	ErrorHmacAuthorization:     http.StatusUnauthorized,
+	ErrorHmacSecretNotSet:      http.StatusUnauthorized,
+	ErrorHmacDateInvalid:       http.StatusUnauthorized,
+	ErrorHmacDateOutsideWindow: http.StatusUnauthorized,
+	ErrorHmacDigestInvalid:     http.StatusUnauthorized,
+	ErrorHmacSignatureInvalid:  http.StatusUnauthorized,
+	ErrorHmacRequestReplayed:   http.StatusUnauthorized,
+	ErrorHmacPositionMismatch:  http.StatusUnauthorized,
+	ErrorAdminUnauthorized:     http.StatusUnauthorized,
+
+	ErrorAdminAccessDenied: http.StatusForbidden,
+
+	ErrorRouteNotFound:        http.StatusNotFound,
+	ErrorAuthClientNotFound:   http.StatusNotFound,
+	ErrorSlotGroupNotFound:    http.StatusNotFound,
+	ErrorSlotGroupPending:     http.StatusNotFound,
+	ErrorProofPending:         http.StatusNotFound,
+	ErrorLatestProofPending:   http.StatusNotFound,
+	ErrorProofHashUnavailable: http.StatusNotFound,
+	ErrorTimestampPending:     http.StatusNotFound,
+	ErrorSubmissionNotFound:   http.StatusNotFound,
+	ErrorRoundNotFound:        http.StatusNotFound,
+	ErrorAttestationNotFound:  http.StatusNotFound,
+	ErrorStaychainTxNotFound:  http.StatusNotFound,
+
+	ErrorMethodNotAllowed: http.StatusMethodNotAllowed,
+
+	ErrorApiVersionUnsupported: http.StatusNotAcceptable,
+
+	ErrorRequestBodyTooLarge: http.StatusRequestEntityTooLarge,
+
+	ErrorRequestBody:          http.StatusInternalServerError,
+	ErrorCommitmentSave:       http.StatusInternalServerError,
+	ErrorClientDetailsGet:     http.StatusInternalServerError,
+	ErrorClientDetailsSave:    http.StatusInternalServerError,
+	ErrorHmacSecretGenerate:   http.StatusInternalServerError,
+	ErrorFeesGet:              http.StatusInternalServerError,
+	ErrorSlotGroupSave:        http.StatusInternalServerError,
+	ErrorSlotGroupGet:         http.StatusInternalServerError,
+	ErrorProofGet:             http.StatusInternalServerError,
+	ErrorIntegrityCheck:       http.StatusInternalServerError,
+	ErrorExclusionsGet:        http.StatusInternalServerError,
+	ErrorRotationsGet:         http.StatusInternalServerError,
+	ErrorSignersGet:           http.StatusInternalServerError,
+	ErrorTimestampGet:         http.StatusInternalServerError,
+	ErrorHistoryGet:           http.StatusInternalServerError,
+	ErrorSubmissionGet:        http.StatusInternalServerError,
+	ErrorRoundsGet:            http.StatusInternalServerError,
+	ErrorRoundSnapshotInvalid: http.StatusInternalServerError,
+	ErrorTransitionGet:        http.StatusInternalServerError,
+	ErrorAttestationGet:       http.StatusInternalServerError,
+	ErrorStaychainGet:         http.StatusInternalServerError,
+	ErrorResponseEncode:       http.StatusInternalServerError,
+
+	ErrorBalanceUnavailable:   http.StatusServiceUnavailable,
+	ErrorFeesUnavailable:      http.StatusServiceUnavailable,
+	ErrorAttestUnavailable:    http.StatusServiceUnavailable,
+	ErrorPauseUnavailable:     http.StatusServiceUnavailable,
+	ErrorReviewUnavailable:    http.StatusServiceUnavailable,
+	ErrorIntegrityUnavailable: http.StatusServiceUnavailable,
+	ErrorHealthUnavailable:    http.StatusServiceUnavailable,
+	ErrorEventsUnavailable:    http.StatusServiceUnavailable,
+	ErrorDeriveUnavailable:    http.StatusServiceUnavailable,
+	ErrorRotationUnavailable:  http.StatusServiceUnavailable,
+	ErrorTimestampUnavailable: http.StatusServiceUnavailable,
+	ErrorDeliveryUnavailable:  http.StatusServiceUnavailable,
+}
+
+// Return http status of the error message, matching errors with details
+// appended to the error const, e.g. "Invalid auth token: reason"
+func errorStatus(errStr string) int {
+	status, matched := http.StatusBadRequest, ""
+	for err, errStatus := range errorStatuses {
+		if len(err) > len(matched) && (errStr == err ||
+			strings.HasPrefix(errStr, err+":") || strings.HasPrefix(errStr, err+" (")) {
+			status, matched = errStatus, err
+		}
+	}
+	return status
+}
+
+// Return error response envelope of the error message and code, defaulting
+// to the code of the status, for the request id of the response
+func newErrorResponse(w http.ResponseWriter, errStr string, code string) (int, models.ErrorResponse) {
+	status := errorStatus(errStr)
+	if code == "" {
+		code = statusCodes[status]
+	}
+	return status, models.ErrorResponse{Error: errStr, Code: code, RequestId: w.Header().Get(HeaderRequestId)}
+}
+
+// Write json response with http status code
+// Responses failing to encode are replaced by an internal error response
+func writeResponseStatus(w http.ResponseWriter, status int, response interface{}) {
+	responseBytes, encodeErr := json.Marshal(response)
+	if encodeErr != nil {
+		log.WithFields(log.Fields{log.FieldRequestId: w.Header().Get(HeaderRequestId)}).Warnf(
+			"%s: %v\n", ErrorResponseEncode, encodeErr)
+		status, response = newErrorResponse(w, ErrorResponseEncode, "")
+		responseBytes, _ = json.Marshal(response)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(responseBytes, '\n'))
+}
+
+// Write json error response
+func writeError(w http.ResponseWriter, errStr string) {
+	status, response := newErrorResponse(w, errStr, "")
+	writeResponseStatus(w, status, response)
+}
+
+// Write json error response with the error code of the error, if any
+func writeErrorCode(w http.ResponseWriter, err error) {
+	status, response := newErrorResponse(w, err.Error(), errorCode(err))
+	writeResponseStatus(w, status, response)
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	confpkg "mainstay/config"
+	"mainstay/db"
+	"mainstay/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test http status of error messages with details appended
+func TestErrorStatus(t *testing.T) {
+	assert.Equal(t, http.StatusBadRequest, errorStatus(ErrorCommitmentInvalid))
+	assert.Equal(t, http.StatusUnauthorized, errorStatus(ErrorAuthSignatureInvalid))
+	assert.Equal(t, http.StatusUnauthorized, errorStatus(fmt.Sprintf("%s: %s", ErrorAuthSchemeDisabled, AuthSchemeToken)))
+	assert.Equal(t, http.StatusBadRequest, errorStatus(fmt.Sprintf("%s (max %d)", ErrorBulkSize, BulkMaxCommitments)))
+	assert.Equal(t, http.StatusNotFound, errorStatus(ErrorProofPending))
+	assert.Equal(t, http.StatusInternalServerError, errorStatus(ErrorProofGet))
+	assert.Equal(t, http.StatusServiceUnavailable, errorStatus(ErrorBalanceUnavailable))
+	assert.Equal(t, http.StatusBadRequest, errorStatus(ErrorProofGet+"s"))
+	assert.Equal(t, http.StatusBadRequest, errorStatus("unknown error"))
+	for _, status := range errorStatuses {
+		assert.NotEqual(t, "", statusCodes[status])
+	}
+}
+
+// Test error responses are written in the error envelope
+func TestWriteError(t *testing.T) {
+	writer := httptest.NewRecorder()
+	writer.Header().Set(HeaderRequestId, "abc")
+	writeError(writer, ErrorSubmissionNotFound)
+	assert.Equal(t, http.StatusNotFound, writer.Code)
+	assert.Equal(t, "application/json", writer.Header().Get("Content-Type"))
+	var response models.ErrorResponse
+	assert.Equal(t, nil, json.NewDecoder(writer.Body).Decode(&response))
+	assert.Equal(t, models.ErrorResponse{Error: ErrorSubmissionNotFound, Code: CodeNotFound, RequestId: "abc"}, response)
+
+	// specific error codes
+	writer = httptest.NewRecorder()
+	writeErrorCode(writer, &CommitmentError{CodeCommitmentZero})
+	assert.Equal(t, http.StatusBadRequest, writer.Code)
+	response = models.ErrorResponse{}
+	assert.Equal(t, nil, json.NewDecoder(writer.Body).Decode(&response))
+	assert.Equal(t, models.ErrorResponse{Error: ErrorCommitmentInvalid, Code: CodeCommitmentZero}, response)
+
+	// responses failing to encode
+	writer = httptest.NewRecorder()
+	writeResponse(writer, make(chan int))
+	assert.Equal(t, http.StatusInternalServerError, writer.Code)
+	response = models.ErrorResponse{}
+	assert.Equal(t, nil, json.NewDecoder(writer.Body).Decode(&response))
+	assert.Equal(t, models.ErrorResponse{Error: ErrorResponseEncode, Code: CodeInternal}, response)
+
+	// unknown routes and methods
+	service := NewRequestService(nil, nil, db.NewDbFake(), confpkg.ApiConfig{})
+	r, _ := http.NewRequest(GET, "/unknown", nil)
+	assert.Equal(t, ErrorRouteNotFound, serveRequest(t, service, r)["error"])
+	r, _ = http.NewRequest(GET, RouteCommitmentSend, nil)
+	assert.Equal(t, CodeMethodNotAllowed, serveRequest(t, service, r)["code"])
+}
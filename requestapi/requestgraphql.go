@@ -0,0 +1,519 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// GraphQL query api
+// Attestations, the commitments they attest and the proofs of the
+// commitments are queried with nested selections in a single request, e.g.
+// { attestations(limit: 5) { txid commitments { position proof { ops { commitment } } } } }
+// Queries are posted as json with the query, operation name and variables,
+// or sent as the query parameters of GET requests
+
+// graphql consts
+const (
+	GraphqlDefaultLimit = 20
+	GraphqlMaxLimit     = 100
+	GraphqlMaxDepth     = 10 // max depth of nested field selections
+
+	QueryGraphql              = "query"
+	QueryGraphqlOperationName = "operationName"
+	QueryGraphqlVariables     = "variables"
+
+	GraphqlTypeQuery       = "Query"
+	GraphqlTypeAttestation = "Attestation"
+	GraphqlTypeCommitment  = "Commitment"
+)
+
+// graphql error consts
+const (
+	ErrorGraphqlDepth  = "Query exceeds max depth"
+	ErrorGraphqlSchema = "Could not build graphql schema"
+)
+
+// GraphqlRequest struct
+// Query with optional operation name and variables
+type GraphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// GraphQL request handler
+// Executes the query of the request body, or of the query parameters of GET
+// requests. Queries that could not be executed are answered with a bad
+// request status, with any field errors of executed queries in the errors
+func HandleGraphql(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	var request GraphqlRequest
+	if r.Method == GET {
+		query := r.URL.Query()
+		request.Query = query.Get(QueryGraphql)
+		request.OperationName = query.Get(QueryGraphqlOperationName)
+		if variables := query.Get(QueryGraphqlVariables); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				writeError(w, ErrorRequestPayload)
+				return
+			}
+		}
+	} else {
+		body, bodyErr := io.ReadAll(r.Body)
+		if bodyErr != nil {
+			writeError(w, requestBodyError(bodyErr))
+			return
+		}
+		if err := json.Unmarshal(body, &request); err != nil {
+			writeError(w, ErrorRequestPayload)
+			return
+		}
+	}
+
+	result := s.executeGraphql(r, request)
+	status := http.StatusOK
+	if result.Data == nil {
+		status = http.StatusBadRequest
+	}
+	writeResponseStatus(w, status, result)
+}
+
+// Parse, validate and execute the query of the request
+// Queries are limited in depth before execution, as the number of db lookups
+// grows with the nesting of attestations and commitments
+func (s *RequestService) executeGraphql(r *http.Request, request GraphqlRequest) *graphql.Result {
+	document, parseErr := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(request.Query), Name: "GraphQL request"}),
+	})
+	if parseErr != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(parseErr)}
+	}
+	validation := graphql.ValidateDocument(&s.graphqlSchema, document, nil)
+	if !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}
+	}
+	if graphqlDepth(document) > GraphqlMaxDepth {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(errors.New(ErrorGraphqlDepth))}
+	}
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        s.graphqlSchema,
+		AST:           document,
+		OperationName: request.OperationName,
+		Args:          request.Variables,
+		Context:       r.Context(),
+	})
+}
+
+// Return max depth of the nested field selections of the document
+func graphqlDepth(document *ast.Document) int {
+	fragments := make(map[string]*ast.FragmentDefinition)
+	for _, definition := range document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			fragments[fragment.Name.Value] = fragment
+		}
+	}
+	var depth func(set *ast.SelectionSet) int
+	depth = func(set *ast.SelectionSet) int {
+		if set == nil {
+			return 0
+		}
+		max := 0
+		for _, selection := range set.Selections {
+			selectionDepth := 0
+			switch selection := selection.(type) {
+			case *ast.Field:
+				selectionDepth = 1 + depth(selection.SelectionSet)
+			case *ast.InlineFragment:
+				selectionDepth = depth(selection.SelectionSet)
+			case *ast.FragmentSpread:
+				// fragment cycles are rejected by validation
+				if fragment, ok := fragments[selection.Name.Value]; ok {
+					selectionDepth = depth(fragment.SelectionSet)
+				}
+			}
+			if selectionDepth > max {
+				max = selectionDepth
+			}
+		}
+		return max
+	}
+	max := 0
+	for _, definition := range document.Definitions {
+		if operation, ok := definition.(*ast.OperationDefinition); ok {
+			if operationDepth := depth(operation.SelectionSet); operationDepth > max {
+				max = operationDepth
+			}
+		}
+	}
+	return max
+}
+
+// graphql attestation object
+type graphqlAttestation struct {
+	attestation models.AttestationBSON
+}
+
+// graphql commitment object
+// The attestation is looked up by merkle root if not known
+type graphqlCommitment struct {
+	proof       models.CommitmentMerkleProof
+	attestation *models.AttestationBSON
+}
+
+// Return graphql schema of the query root, resolving fields with the db and
+// proof options of the service
+//
+// Query:
+// attestations(offset: Int, limit: Int, minHeight: Int, maxHeight: Int): [Attestation]
+// attestation(txid: String!): Attestation
+// commitment(position: Int!, commitment: String!): Commitment
+// latestCommitment(position: Int!): Commitment
+//
+// Attestation:
+// txid, merkleRoot, blockHash: String
+// confirmed: Boolean
+// insertedAt, confirmedAt, blockHeight, fee: Int
+// commitments(position: Int): [Commitment]
+//
+// Commitment:
+// position: Int
+// commitment, merkleRoot: String
+// attestation: Attestation
+// proof: ProofBundle, selected by its json fields
+func newGraphqlSchema(s *RequestService) (graphql.Schema, error) {
+	var commitmentType *graphql.Object
+	attestationField := func(t graphql.Output, value func(models.AttestationBSON) interface{}) *graphql.Field {
+		return &graphql.Field{Type: t, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return value(p.Source.(graphqlAttestation).attestation), nil
+		}}
+	}
+	attestationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: GraphqlTypeAttestation,
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"txid": attestationField(graphql.String, func(a models.AttestationBSON) interface{} {
+					return a.Txid
+				}),
+				"merkleRoot": attestationField(graphql.String, func(a models.AttestationBSON) interface{} {
+					return a.MerkleRoot
+				}),
+				"confirmed": attestationField(graphql.Boolean, func(a models.AttestationBSON) interface{} {
+					return a.Confirmed
+				}),
+				"insertedAt": attestationField(graphql.Int, func(a models.AttestationBSON) interface{} {
+					return a.InsertedAt.Unix()
+				}),
+				"confirmedAt": attestationField(graphql.Int, func(a models.AttestationBSON) interface{} {
+					if a.ConfirmedAt == nil {
+						return nil
+					}
+					return a.ConfirmedAt.Unix()
+				}),
+				"blockHeight": attestationField(graphql.Int, func(a models.AttestationBSON) interface{} {
+					return a.BlockHeight
+				}),
+				"blockHash": attestationField(graphql.String, func(a models.AttestationBSON) interface{} {
+					return a.BlockHash
+				}),
+				"fee": attestationField(graphql.Int, func(a models.AttestationBSON) interface{} {
+					return a.Fee
+				}),
+				"commitments": &graphql.Field{
+					Type: graphql.NewList(commitmentType),
+					Args: graphql.FieldConfigArgument{"position": {Type: graphql.Int}},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return s.graphqlCommitments(p.Source.(graphqlAttestation).attestation, p.Args)
+					},
+				},
+			}
+		}),
+	})
+
+	proofType, proofErr := graphqlObjectType(reflect.TypeOf(models.ProofBundle{}), map[reflect.Type]*graphql.Object{})
+	if proofErr != nil {
+		return graphql.Schema{}, proofErr
+	}
+	commitmentField := func(t graphql.Output, value func(graphqlCommitment) (interface{}, error)) *graphql.Field {
+		return &graphql.Field{Type: t, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return value(p.Source.(graphqlCommitment))
+		}}
+	}
+	commitmentType = graphql.NewObject(graphql.ObjectConfig{
+		Name: GraphqlTypeCommitment,
+		Fields: graphql.Fields{
+			"position": commitmentField(graphql.Int, func(c graphqlCommitment) (interface{}, error) {
+				return c.proof.ClientPosition, nil
+			}),
+			"commitment": commitmentField(graphql.String, func(c graphqlCommitment) (interface{}, error) {
+				return c.proof.Commitment.String(), nil
+			}),
+			"merkleRoot": commitmentField(graphql.String, func(c graphqlCommitment) (interface{}, error) {
+				return c.proof.MerkleRoot.String(), nil
+			}),
+			"attestation": commitmentField(attestationType, s.graphqlCommitmentAttestation),
+			"proof":       commitmentField(proofType, s.graphqlProof),
+		},
+	})
+
+	positionArg := &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)}
+	return graphql.NewSchema(graphql.SchemaConfig{Query: graphql.NewObject(graphql.ObjectConfig{
+		Name: GraphqlTypeQuery,
+		Fields: graphql.Fields{
+			"attestations": &graphql.Field{
+				Type: graphql.NewList(attestationType),
+				Args: graphql.FieldConfigArgument{
+					"offset":    {Type: graphql.Int},
+					"limit":     {Type: graphql.Int},
+					"minHeight": {Type: graphql.Int},
+					"maxHeight": {Type: graphql.Int},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return s.graphqlAttestations(p.Args)
+				},
+			},
+			"attestation": &graphql.Field{
+				Type: attestationType,
+				Args: graphql.FieldConfigArgument{"txid": {Type: graphql.NewNonNull(graphql.String)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					txid, txidErr := graphqlHashArg(p.Args, "txid", ErrorAttestationInvalid)
+					if txidErr != nil {
+						return nil, txidErr
+					}
+					return s.graphqlAttestation(txid)
+				},
+			},
+			"commitment": &graphql.Field{
+				Type: commitmentType,
+				Args: graphql.FieldConfigArgument{
+					"position":   positionArg,
+					"commitment": {Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					position, positionErr := graphqlPositionArg(p.Args)
+					if positionErr != nil {
+						return nil, positionErr
+					}
+					commitment, commitmentErr := graphqlHashArg(p.Args, "commitment", ErrorCommitmentInvalid)
+					if commitmentErr != nil {
+						return nil, commitmentErr
+					}
+					proof, proofErr := s.dbInterface.GetCommitmentMerkleProof(position, commitment)
+					return graphqlCommitmentOf(proof, proofErr, nil)
+				},
+			},
+			"latestCommitment": &graphql.Field{
+				Type: commitmentType,
+				Args: graphql.FieldConfigArgument{"position": positionArg},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					position, positionErr := graphqlPositionArg(p.Args)
+					if positionErr != nil {
+						return nil, positionErr
+					}
+					proof, proofErr := s.dbInterface.GetLatestCommitmentMerkleProof(position)
+					return graphqlCommitmentOf(proof, proofErr, nil)
+				},
+			},
+		},
+	})})
+}
+
+// Return graphql object type of the struct type, with fields of its json
+// names resolved by the default resolver of the library
+// Nested structs are objects named after their go type, slices lists
+func graphqlObjectType(t reflect.Type, objects map[reflect.Type]*graphql.Object) (*graphql.Object, error) {
+	if object, ok := objects[t]; ok {
+		return object, nil
+	}
+	fields := graphql.Fields{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" || field.PkgPath != "" {
+			continue
+		}
+		fieldType, fieldErr := graphqlOutputType(field.Type, objects)
+		if fieldErr != nil {
+			return nil, fieldErr
+		}
+		fields[name] = &graphql.Field{Type: fieldType}
+	}
+	object := graphql.NewObject(graphql.ObjectConfig{Name: t.Name(), Fields: fields})
+	objects[t] = object
+	return object, nil
+}
+
+// Return graphql output type of the go type
+func graphqlOutputType(t reflect.Type, objects map[reflect.Type]*graphql.Object) (graphql.Output, error) {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return graphqlOutputType(t.Elem(), objects)
+	case reflect.Slice:
+		elem, elemErr := graphqlOutputType(t.Elem(), objects)
+		if elemErr != nil {
+			return nil, elemErr
+		}
+		return graphql.NewList(elem), nil
+	case reflect.Struct:
+		return graphqlObjectType(t, objects)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return graphql.Int, nil
+	case reflect.String:
+		return graphql.String, nil
+	case reflect.Bool:
+		return graphql.Boolean, nil
+	}
+	return nil, errors.New(fmt.Sprintf("%s: %s", ErrorGraphqlSchema, t))
+}
+
+// Return page of confirmed attestations, newest first, filtered by block height
+func (s *RequestService) graphqlAttestations(args map[string]interface{}) (interface{}, error) {
+	offset, _ := graphqlIntArg(args, "offset")
+	limit, limitSet := graphqlIntArg(args, "limit")
+	minHeight, minSet := graphqlIntArg(args, "minHeight")
+	maxHeight, maxSet := graphqlIntArg(args, "maxHeight")
+	if !limitSet {
+		limit = GraphqlDefaultLimit
+	}
+	if !minSet {
+		minHeight = -1
+	}
+	if !maxSet {
+		maxHeight = -1
+	}
+	if offset < 0 || limit <= 0 || (minSet && minHeight < 0) || (maxSet && maxHeight < 0) {
+		return nil, errors.New(ErrorPaginationInvalid)
+	} else if limit > GraphqlMaxLimit {
+		limit = GraphqlMaxLimit
+	}
+
+	attestations, attestationsErr := s.dbInterface.GetAttestationsPage(minHeight, maxHeight, offset, limit)
+	if attestationsErr != nil {
+		return nil, errors.New(ErrorAttestationGet)
+	}
+	page := []graphqlAttestation{}
+	for _, attestation := range attestations {
+		page = append(page, graphqlAttestation{attestation})
+	}
+	return page, nil
+}
+
+// Return attestation of the txid, or nil if not found
+func (s *RequestService) graphqlAttestation(txid chainhash.Hash) (interface{}, error) {
+	attestation, attestationErr := s.dbInterface.GetAttestation(txid)
+	if attestationErr != nil {
+		return nil, errors.New(ErrorAttestationGet)
+	} else if attestation.Txid == "" {
+		return nil, nil
+	}
+	return graphqlAttestation{attestation}, nil
+}
+
+// Return commitments of the attestation, optionally of a client position
+func (s *RequestService) graphqlCommitments(attestation models.AttestationBSON,
+	args map[string]interface{}) (interface{}, error) {
+	position, positionSet := graphqlIntArg(args, "position")
+	txid, txidErr := chainhash.NewHashFromStr(attestation.Txid)
+	if txidErr != nil {
+		return nil, errors.New(ErrorAttestationGet)
+	}
+	proofs, proofsErr := s.dbInterface.GetAttestationMerkleProofs(*txid)
+	if proofsErr != nil {
+		return nil, errors.New(ErrorProofGet)
+	}
+	commitments := []graphqlCommitment{}
+	for _, proof := range proofs {
+		if !positionSet || int64(proof.ClientPosition) == position {
+			attestation := attestation
+			commitments = append(commitments, graphqlCommitment{proof, &attestation})
+		}
+	}
+	return commitments, nil
+}
+
+// Return commitment of the merkle proof, or nil if the commitment has not
+// been attested
+func graphqlCommitmentOf(proof models.CommitmentMerkleProof, proofErr error,
+	attestation *models.AttestationBSON) (interface{}, error) {
+	if proofErr != nil {
+		return nil, errors.New(ErrorProofGet)
+	} else if proof.MerkleRoot == (chainhash.Hash{}) {
+		return nil, nil
+	}
+	return graphqlCommitment{proof, attestation}, nil
+}
+
+// Return attestation of the commitment, looked up by merkle root if not known
+func (s *RequestService) graphqlCommitmentAttestation(c graphqlCommitment) (interface{}, error) {
+	if c.attestation != nil {
+		return graphqlAttestation{*c.attestation}, nil
+	}
+	info, infoErr := s.dbInterface.GetAttestationInfoByMerkleRoot(c.proof.MerkleRoot)
+	if infoErr != nil {
+		return nil, errors.New(ErrorAttestationGet)
+	}
+	txid, txidErr := chainhash.NewHashFromStr(info.Txid)
+	if txidErr != nil {
+		return nil, nil
+	}
+	return s.graphqlAttestation(*txid)
+}
+
+// Return proof bundle of the commitment
+func (s *RequestService) graphqlProof(c graphqlCommitment) (interface{}, error) {
+	info, infoErr := s.dbInterface.GetAttestationInfoByMerkleRoot(c.proof.MerkleRoot)
+	if infoErr != nil {
+		return nil, errors.New(ErrorProofGet)
+	}
+	bundle := models.NewProofBundle(c.proof, info)
+	s.addSpvProof(&bundle)
+	if encodeErr := bundle.EncodeOps(s.proofOps); encodeErr != nil {
+		return nil, errors.New(ErrorProofGet)
+	}
+	return bundle, nil
+}
+
+// Return integer argument and whether it is set
+func graphqlIntArg(args map[string]interface{}, name string) (int64, bool) {
+	value, ok := args[name].(int)
+	return int64(value), ok
+}
+
+// Return client position argument
+func graphqlPositionArg(args map[string]interface{}) (int32, error) {
+	position, positionSet := graphqlIntArg(args, "position")
+	if !positionSet || position < 0 || position > 1<<31-1 {
+		return 0, errors.New(ErrorAdminPositionInvalid)
+	}
+	return int32(position), nil
+}
+
+// Return hash argument, or the error string if missing or invalid
+func graphqlHashArg(args map[string]interface{}, name string, errStr string) (chainhash.Hash, error) {
+	value, _ := args[name].(string)
+	if len(value) != 2*chainhash.HashSize {
+		return chainhash.Hash{}, errors.New(errStr)
+	}
+	hash, hashErr := chainhash.NewHashFromStr(value)
+	if hashErr != nil {
+		return chainhash.Hash{}, errors.New(errStr)
+	}
+	return *hash, nil
+}
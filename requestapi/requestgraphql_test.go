@@ -0,0 +1,184 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	confpkg "mainstay/config"
+	"mainstay/db"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Return graphql POST request of the query
+func graphqlRequest(query string, variables map[string]interface{}) *http.Request {
+	body, _ := json.Marshal(GraphqlRequest{Query: query, Variables: variables})
+	r, _ := http.NewRequest(POST, RouteGraphql, bytes.NewReader(body))
+	return r
+}
+
+// Return message and path of the errors of the graphql response
+func graphqlErrors(response map[string]interface{}) []interface{} {
+	errs, _ := response["errors"].([]interface{})
+	messages := []interface{}{}
+	for _, err := range errs {
+		message := map[string]interface{}{"message": err.(map[string]interface{})["message"]}
+		if path, ok := err.(map[string]interface{})["path"]; ok {
+			message["path"] = path
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+// Test graphql queries of attestations, commitments and proofs
+func TestHandleGraphql(t *testing.T) {
+	dbFake := db.NewDbFake()
+	service := NewRequestService(nil, nil, dbFake, confpkg.ApiConfig{})
+
+	// two confirmed attestations, the first with two commitments
+	var commitments []*models.Commitment
+	var txids []string
+	for i := 0; i < 2; i++ {
+		var hashes []chainhash.Hash
+		for j := 0; j < 2-i; j++ {
+			hash, _ := chainhash.NewHashFromStr(fmt.Sprintf("%064x", 10*i+j+1))
+			hashes = append(hashes, *hash)
+		}
+		commitment, _ := models.NewCommitment(hashes)
+		txid, _ := chainhash.NewHashFromStr(fmt.Sprintf("%064x", 100+i))
+		attestation := models.NewAttestation(*txid, commitment)
+		attestation.Confirmed = true
+		attestation.Info = models.AttestationInfo{Txid: txid.String(), Blockhash: fmt.Sprintf("%064x", 200+i),
+			Time: int64(1542121293 + i), Height: int64(1000 + i)}
+		dbFake.SaveMerkleProofs(commitment.GetMerkleProofs())
+		dbFake.SaveAttestation(*attestation)
+		dbFake.SaveAttestationInfo(attestation.Info)
+		commitments = append(commitments, commitment)
+		txids = append(txids, txid.String())
+	}
+	root0 := commitments[0].GetCommitmentHash().String()
+
+	// nested attestations, commitments and proofs newest first
+	status, response := serveRouter(service.router, graphqlRequest(`{
+		attestations {
+			txid blockHeight confirmed
+			commitments { position commitment proof { root txid ops { append commitment } } }
+		}
+	}`, nil))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]interface{}{"data": map[string]interface{}{"attestations": []interface{}{
+		map[string]interface{}{"txid": txids[1], "blockHeight": float64(1001), "confirmed": true,
+			"commitments": []interface{}{
+				map[string]interface{}{"position": float64(0), "commitment": fmt.Sprintf("%064x", 11),
+					"proof": map[string]interface{}{"root": commitments[1].GetCommitmentHash().String(),
+						"txid": txids[1], "ops": []interface{}{
+							map[string]interface{}{"append": true, "commitment": fmt.Sprintf("%064x", 11)}}}},
+			}},
+		map[string]interface{}{"txid": txids[0], "blockHeight": float64(1000), "confirmed": true,
+			"commitments": []interface{}{
+				map[string]interface{}{"position": float64(0), "commitment": fmt.Sprintf("%064x", 1),
+					"proof": map[string]interface{}{"root": root0, "txid": txids[0], "ops": []interface{}{
+						map[string]interface{}{"append": true, "commitment": fmt.Sprintf("%064x", 2)}}}},
+				map[string]interface{}{"position": float64(1), "commitment": fmt.Sprintf("%064x", 2),
+					"proof": map[string]interface{}{"root": root0, "txid": txids[0], "ops": []interface{}{
+						map[string]interface{}{"append": false, "commitment": fmt.Sprintf("%064x", 1)}}}},
+			}},
+	}}}, response)
+
+	// filtering and paging of attestations and commitments
+	_, response = serveRouter(service.router, graphqlRequest(`{
+		high: attestations(minHeight: 1001) { txid }
+		low: attestations(maxHeight: 1000) { txid commitments(position: 1) { position } }
+		page: attestations(offset: 1, limit: 1) { txid }
+	}`, nil))
+	assert.Equal(t, map[string]interface{}{
+		"high": []interface{}{map[string]interface{}{"txid": txids[1]}},
+		"low": []interface{}{map[string]interface{}{"txid": txids[0],
+			"commitments": []interface{}{map[string]interface{}{"position": float64(1)}}}},
+		"page": []interface{}{map[string]interface{}{"txid": txids[0]}},
+	}, response["data"])
+
+	// commitment with its attestation as GET request with variables
+	query := url.Values{}
+	query.Set(QueryGraphql, `query Commitment($position: Int!, $commitment: String!) {
+		commitment(position: $position, commitment: $commitment) { merkleRoot attestation { txid blockHash } }
+		missing: commitment(position: 5, commitment: $commitment) { merkleRoot }
+		latestCommitment(position: 0) { commitment attestation { txid } }
+	}`)
+	query.Set(QueryGraphqlVariables, fmt.Sprintf(`{"position": 1, "commitment": "%064x"}`, 2))
+	r, _ := http.NewRequest(GET, RouteGraphql+"?"+query.Encode(), nil)
+	status, response = serveRouter(service.router, r)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]interface{}{
+		"commitment": map[string]interface{}{"merkleRoot": root0,
+			"attestation": map[string]interface{}{"txid": txids[0], "blockHash": fmt.Sprintf("%064x", 200)}},
+		"missing":          nil,
+		"latestCommitment": map[string]interface{}{"commitment": fmt.Sprintf("%064x", 11), "attestation": map[string]interface{}{"txid": txids[1]}},
+	}, response["data"])
+	assert.Nil(t, response["errors"])
+
+	// attestation by txid with field errors
+	_, response = serveRouter(service.router, graphqlRequest(`query ($txid: String!) {
+		attestation(txid: $txid) { txid insertedAt commitments { position } }
+		invalid: attestation(txid: "xyz") { txid }
+		attestations(limit: 0) { txid }
+		negative: attestations(minHeight: -1) { txid }
+	}`, map[string]interface{}{"txid": txids[0]}))
+	assert.Equal(t, map[string]interface{}{
+		"attestation": map[string]interface{}{"txid": txids[0], "insertedAt": float64(1542121293),
+			"commitments": []interface{}{map[string]interface{}{"position": float64(0)}, map[string]interface{}{"position": float64(1)}}},
+		"invalid":      nil,
+		"attestations": nil,
+		"negative":     nil,
+	}, response["data"])
+	// errors of fields resolved in any order
+	assert.ElementsMatch(t, []interface{}{
+		map[string]interface{}{"message": ErrorAttestationInvalid, "path": []interface{}{"invalid"}},
+		map[string]interface{}{"message": ErrorPaginationInvalid, "path": []interface{}{"attestations"}},
+		map[string]interface{}{"message": ErrorPaginationInvalid, "path": []interface{}{"negative"}},
+	}, graphqlErrors(response))
+
+	// queries that could not be executed
+	status, response = serveRouter(service.router, graphqlRequest("mutation { attest }", nil))
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, nil, response["data"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"message": "Schema is not configured for mutations"}},
+		graphqlErrors(response))
+
+	status, response = serveRouter(service.router, graphqlRequest("{ unknown }", nil))
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, []interface{}{map[string]interface{}{"message": `Cannot query field "unknown" on type "Query".`}},
+		graphqlErrors(response))
+
+	status, response = serveRouter(service.router, graphqlRequest("{ attestations { txid", nil))
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, 1, len(graphqlErrors(response)))
+
+	// max depth followed through fragments
+	nested := "txid"
+	for i := 0; i < 4; i++ {
+		nested = fmt.Sprintf("txid commitments { attestation { %s } }", nested)
+	}
+	_, response = serveRouter(service.router, graphqlRequest(fmt.Sprintf("{ attestations(limit: 1) { %s } }", nested), nil))
+	assert.NotNil(t, response["data"])
+	status, response = serveRouter(service.router, graphqlRequest(fmt.Sprintf(
+		"{ attestations { ...nested } } fragment nested on Attestation { commitments { attestation { %s } } }", nested), nil))
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, []interface{}{map[string]interface{}{"message": ErrorGraphqlDepth}}, graphqlErrors(response))
+
+	r, _ = http.NewRequest(POST, RouteGraphql, bytes.NewReader([]byte("{")))
+	assert.Equal(t, ErrorRequestPayload, serveRequest(t, service, r)["error"])
+	r, _ = http.NewRequest(GET, RouteGraphql+"?variables=x", nil)
+	assert.Equal(t, ErrorRequestPayload, serveRequest(t, service, r)["error"])
+}
@@ -0,0 +1,1532 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	b64 "encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"mainstay/db"
+	"mainstay/log"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Http handlers for service requests
+
+// error consts
+const (
+	ErrorRequestBody           = "Could not read request body"
+	ErrorRequestPayload        = "Invalid request payload"
+	ErrorCommitmentInvalid     = "Invalid commitment"
+	ErrorCommitmentSave        = "Could not save commitment"
+	ErrorClientDetailsGet      = "Could not get client details"
+	ErrorClientDetailsSave     = "Could not save client details"
+	ErrorAdminUnauthorized     = "Admin authorization failed"
+	ErrorAdminPositionInvalid  = "Invalid client position"
+	ErrorHmacSecretGenerate    = "Could not generate hmac secret"
+	ErrorBalanceUnavailable    = "Balance not available"
+	ErrorFeesUnavailable       = "Fee spend not available"
+	ErrorFeesGet               = "Could not get fee spend"
+	ErrorAttestUnavailable     = "Attestation trigger not available"
+	ErrorPauseUnavailable      = "Attestation pause not available"
+	ErrorReviewUnavailable     = "Attestation review not available"
+	ErrorSlotGroupMembers      = "Slot group commitments require group members"
+	ErrorSlotGroupNotGroup     = "Client position is not a slot group"
+	ErrorSlotGroupMismatch     = "Slot group members do not match commitment"
+	ErrorSlotGroupSave         = "Could not save slot group"
+	ErrorSlotGroupGet          = "Could not get slot group"
+	ErrorSlotGroupNotFound     = "Commitment not found in slot group"
+	ErrorSlotGroupPending      = "Slot group commitment not attested yet"
+	ErrorProofGet              = "Could not get commitment proof"
+	ErrorProofPending          = "Commitment not attested yet"
+	ErrorLatestProofPending    = "No confirmed commitment for client position"
+	ErrorIntegrityUnavailable  = "Integrity check not available"
+	ErrorIntegrityCheck        = "Could not check attestation integrity"
+	ErrorHealthUnavailable     = "Health check not available"
+	ErrorEventsUnavailable     = "Event stream not available"
+	ErrorExclusionsGet         = "Could not get commitment exclusions"
+	ErrorDeriveUnavailable     = "Attestation derivation not available"
+	ErrorRotationUnavailable   = "Key rotation not available"
+	ErrorRotationsGet          = "Could not get key rotations"
+	ErrorSignersGet            = "Could not get signer statuses"
+	ErrorTimestampUnavailable  = "Commitment timestamps not available"
+	ErrorTimestampPending      = "Commitment attestation not confirmed yet"
+	ErrorTimestampGet          = "Could not get commitment timestamp"
+	ErrorDeliveryUnavailable   = "Proof delivery not available"
+	ErrorHistoryGet            = "Could not get commitment history"
+	ErrorSubmissionGet         = "Could not get commitment submission"
+	ErrorSubmissionNotFound    = "Commitment submission not found"
+	ErrorPaginationInvalid     = "Invalid pagination parameters"
+	ErrorBulkSize              = "Invalid number of bulk commitments"
+	ErrorBulkPositionDuplicate = "Duplicate client position in bulk commitments"
+	ErrorBulkRejected          = "Bulk commitments rejected"
+	ErrorRoundInvalid          = "Invalid round"
+	ErrorRoundNotFound         = "Round snapshot not found"
+	ErrorRoundsGet             = "Could not get round snapshots"
+	ErrorRoundSnapshotInvalid  = "Round snapshot does not match its merkle root"
+	ErrorTransitionGet         = "Could not get protocol transition round"
+	ErrorProofHashUnavailable  = "Proof not available with hash function"
+	ErrorAttestationInvalid    = "Invalid attestation txid"
+	ErrorAttestationNotFound   = "Attestation not found"
+	ErrorAttestationGet        = "Could not get attestation"
+	ErrorStaychainGet          = "Could not get staychain transactions"
+	ErrorStaychainTxInvalid    = "Invalid staychain txid"
+	ErrorStaychainTxNotFound   = "Staychain transaction not found"
+)
+
+// max number of commitments of bulk commitment requests
+const BulkMaxCommitments = 1000
+
+// commitment history pagination
+// pages are selected with the offset and limit query parameters
+const (
+	QueryOffset = "offset"
+	QueryLimit  = "limit"
+	QueryRoot   = "root"
+	QueryHash   = "hash"
+
+	HistoryDefaultLimit = 100
+	HistoryMaxLimit     = 1000
+)
+
+// interval of keep alive comments sent on idle event streams
+const EventsKeepAlive = 30 * time.Second
+
+// admin authorization header prefix
+const AdminAuthorizationPrefix = "Bearer "
+
+// CommitmentSendRequest struct
+// Body of commitment send requests as sent by clients
+type CommitmentSendRequest struct {
+	Payload   string `json:"X-MAINSTAY-PAYLOAD"`
+	Signature string `json:"X-MAINSTAY-SIGNATURE"`
+}
+
+// CommitmentSendPayload struct
+// Base64 decoded payload of commitment send requests
+type CommitmentSendPayload struct {
+	Commitment string `json:"commitment"`
+	Position   int32  `json:"position"`
+	Token      string `json:"token"`
+
+	// member commitments of slot groups with the commitment as their merkle root
+	Members []string `json:"members,omitempty"`
+}
+
+// CommitmentBulkRequest struct
+// Body of bulk commitment send requests, with a commitment send request of
+// the same form as single commitment requests for each client position
+type CommitmentBulkRequest struct {
+	Commitments []CommitmentSendRequest `json:"commitments"`
+}
+
+// CommitmentBulkResult struct
+// Result of each commitment of bulk commitment send requests
+type CommitmentBulkResult struct {
+	Position   int32  `json:"position"`
+	Commitment string `json:"commitment"`
+	Submission string `json:"submission,omitempty"`
+	Duplicate  bool   `json:"duplicate,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Code       string `json:"code,omitempty"`
+}
+
+// CommitmentBulkErrorResponse struct
+// Error response of bulk commitment send requests with invalid commitments
+type CommitmentBulkErrorResponse struct {
+	models.ErrorResponse
+	Results []CommitmentBulkResult `json:"results"`
+}
+
+// ClientDeliveryRequest struct
+// Body of client proof delivery target registration requests
+type ClientDeliveryRequest struct {
+	Url string `json:"url"`
+}
+
+// Index request handler
+func HandleIndex(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	fmt.Fprintln(w, "Request Service for Mainstay Attestations!")
+}
+
+// Commitment Send request handler
+// Authenticates the request with either the auth token or the hmac
+// request signature and stores the commitment for the client position
+func HandleCommitmentSend(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	body, bodyErr := io.ReadAll(r.Body)
+	if bodyErr != nil {
+		writeError(w, requestBodyError(bodyErr))
+		return
+	}
+
+	var request CommitmentSendRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		writeError(w, ErrorRequestPayload)
+		return
+	}
+	payload, payloadErr := decodeCommitmentPayload(request)
+	if payloadErr != nil {
+		writeError(w, payloadErr.Error())
+		return
+	}
+
+	commitment, group, verifyErr := s.verifyCommitmentSend(r, body, r.Header.Get(HeaderAuthorization), payload, request.Signature)
+	if verifyErr != nil {
+		writeErrorCode(w, verifyErr)
+		return
+	}
+	if group != nil {
+		if saveErr := s.dbInterface.SaveSlotGroup(*group); saveErr != nil {
+			writeError(w, ErrorSlotGroupSave)
+			return
+		}
+	}
+
+	submissions, saveErr := db.SubmitClientCommitments(s.dbInterface,
+		[]models.ClientCommitment{newClientCommitment(r, payload.Position, commitment)})
+	if saveErr != nil {
+		writeError(w, ErrorCommitmentSave)
+		return
+	}
+	writeResponse(w, submissions[0])
+}
+
+// Bulk commitment send request handler
+// Commitments of several client positions are validated independently,
+// each authenticated with its own token and signature, and are only stored
+// if all of them are valid, with the result of each commitment returned
+func HandleCommitmentSendBulk(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	body, bodyErr := io.ReadAll(r.Body)
+	if bodyErr != nil {
+		writeError(w, requestBodyError(bodyErr))
+		return
+	}
+
+	var request CommitmentBulkRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		writeError(w, ErrorRequestPayload)
+		return
+	} else if len(request.Commitments) == 0 || len(request.Commitments) > BulkMaxCommitments {
+		writeError(w, fmt.Sprintf("%s (max %d)", ErrorBulkSize, BulkMaxCommitments))
+		return
+	}
+
+	var commitments []models.ClientCommitment
+	var groups []models.SlotGroup
+	results := make([]CommitmentBulkResult, len(request.Commitments))
+	positions := make(map[int32]bool)
+	for i, sendRequest := range request.Commitments {
+		payload, payloadErr := decodeCommitmentPayload(sendRequest)
+		results[i] = CommitmentBulkResult{Position: payload.Position, Commitment: payload.Commitment}
+		if payloadErr != nil {
+			results[i].Error = payloadErr.Error()
+			continue
+		} else if positions[payload.Position] {
+			results[i].Error = ErrorBulkPositionDuplicate
+			continue
+		}
+		positions[payload.Position] = true
+
+		commitment, group, verifyErr := s.verifyCommitmentSend(r, nil, "", payload, sendRequest.Signature)
+		if verifyErr != nil {
+			results[i].Error = verifyErr.Error()
+			results[i].Code = errorCode(verifyErr)
+			continue
+		}
+		results[i].Commitment = commitment.String()
+		if group != nil {
+			groups = append(groups, *group)
+		}
+		commitments = append(commitments, newClientCommitment(r, payload.Position, commitment))
+	}
+	if len(commitments) < len(request.Commitments) {
+		status, errResponse := newErrorResponse(w, ErrorBulkRejected, "")
+		writeResponseStatus(w, status, CommitmentBulkErrorResponse{errResponse, results})
+		return
+	}
+
+	for _, group := range groups {
+		if saveErr := s.dbInterface.SaveSlotGroup(group); saveErr != nil {
+			writeError(w, ErrorSlotGroupSave)
+			return
+		}
+	}
+	submissions, saveErr := db.SubmitClientCommitments(s.dbInterface, commitments)
+	if saveErr != nil {
+		writeError(w, ErrorCommitmentSave)
+		return
+	}
+	for i, submission := range submissions {
+		results[i].Submission = submission.Id
+		results[i].Duplicate = submission.Duplicate
+	}
+	writeResponse(w, results)
+}
+
+// Decode base64 payload of commitment send request
+func decodeCommitmentPayload(request CommitmentSendRequest) (CommitmentSendPayload, error) {
+	var payload CommitmentSendPayload
+	payloadBytes, payloadBytesErr := b64.StdEncoding.DecodeString(request.Payload)
+	if payloadBytesErr != nil {
+		return payload, errors.New(ErrorRequestPayload)
+	}
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		return payload, errors.New(ErrorRequestPayload)
+	}
+	return payload, nil
+}
+
+// Verify commitment send payload for the client position, authenticated
+// with the hmac authorization if provided or the token and signature, and
+// return the commitment with the slot group of slot group clients
+func (s *RequestService) verifyCommitmentSend(r *http.Request, body []byte, authorization string,
+	payload CommitmentSendPayload, signature string) (chainhash.Hash, *models.SlotGroup, error) {
+
+	commitment, commitmentErr := ParseCommitment(payload.Commitment)
+	if commitmentErr != nil {
+		return chainhash.Hash{}, nil, commitmentErr
+	}
+
+	details, detailsErr := s.clientDetails(payload.Position)
+	if detailsErr != nil {
+		return chainhash.Hash{}, nil, detailsErr
+	}
+
+	var authErr error
+	if authorization != "" {
+		authErr = s.verifyHmacRequest(r, body, authorization, details)
+	} else if s.authSchemes[AuthSchemeToken] {
+		authErr = verifyTokenAuth(details, payload, signature)
+	} else {
+		authErr = errors.New(fmt.Sprintf("%s: %s", ErrorAuthSchemeDisabled, AuthSchemeToken))
+	}
+	if authErr != nil {
+		return chainhash.Hash{}, nil, authErr
+	}
+
+	hash, _, hashErr := s.roundMerkleHash()
+	if hashErr != nil {
+		return chainhash.Hash{}, nil, errors.New(ErrorTransitionGet)
+	}
+	group, groupErr := slotGroup(details, commitment, payload.Members, hash)
+	if groupErr != nil {
+		return chainhash.Hash{}, nil, groupErr
+	}
+	return commitment, group, nil
+}
+
+// Return client commitment received by the request for the client position
+func newClientCommitment(r *http.Request, position int32, commitment chainhash.Hash) models.ClientCommitment {
+	return models.ClientCommitment{
+		Commitment:     commitment,
+		ClientPosition: position,
+		RequestId:      RequestId(r),
+		SubmittedAt:    time.Now().UnixMilli()}
+}
+
+// Slot group proof request handler
+// Returns the proof bundle of a member commitment, through the merkle
+// root of the slot group, to the merkle root of the attestation
+func HandleSlotGroupProof(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	position, positionErr := strconv.ParseInt(Vars(r)["position"], 10, 32)
+	if positionErr != nil {
+		writeError(w, ErrorAdminPositionInvalid)
+		return
+	}
+	commitment, commitmentErr := chainhash.NewHashFromStr(Vars(r)["commitment"])
+	if commitmentErr != nil {
+		writeError(w, ErrorCommitmentInvalid)
+		return
+	}
+
+	group, groupErr := s.dbInterface.GetSlotGroup(int32(position), *commitment)
+	if groupErr != nil {
+		writeError(w, ErrorSlotGroupGet)
+		return
+	}
+	memberProof, memberProofErr := group.GetMemberProof(*commitment)
+	if memberProofErr != nil {
+		writeError(w, ErrorSlotGroupNotFound)
+		return
+	}
+	slotProof, slotProofErr := s.dbInterface.GetCommitmentMerkleProof(int32(position), group.MerkleRoot)
+	if slotProofErr != nil {
+		writeError(w, ErrorSlotGroupGet)
+		return
+	} else if slotProof.MerkleRoot == (chainhash.Hash{}) {
+		writeError(w, ErrorSlotGroupPending)
+		return
+	}
+
+	info, infoErr := s.dbInterface.GetAttestationInfoByMerkleRoot(slotProof.MerkleRoot)
+	if infoErr != nil {
+		writeError(w, ErrorSlotGroupGet)
+		return
+	}
+
+	bundle := models.NewGroupProofBundle(memberProof, slotProof, info)
+	if encodeErr := bundle.EncodeOps(s.proofOps); encodeErr != nil {
+		writeError(w, ErrorSlotGroupGet)
+		return
+	}
+	writeResponse(w, bundle)
+}
+
+// Commitment proof request handler
+// Returns the proof bundle of a client commitment to the merkle root
+// of the attestation, with the attestation transaction and block
+func HandleCommitmentProof(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	bundle, bundleErr := s.commitmentProofBundle(r)
+	if bundleErr != nil {
+		writeError(w, bundleErr.Error())
+		return
+	}
+	if hash := r.URL.Query().Get(QueryHash); hash != "" && hash != bundle.Params.Hash {
+		bundle, bundleErr = s.transitionProofBundle(bundle, hash)
+		if bundleErr != nil {
+			writeError(w, bundleErr.Error())
+			return
+		}
+	}
+	if encodeErr := bundle.EncodeOps(s.proofOps); encodeErr != nil {
+		writeError(w, ErrorProofGet)
+		return
+	}
+	writeResponse(w, bundle)
+}
+
+// Commitment timestamp request handler
+// Returns the RFC 3161 timestamp response of a confirmed client commitment
+// proof, with the proof bundle carried in the timestamp token
+func HandleCommitmentTimestamp(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	if s.timestamper == nil {
+		writeError(w, ErrorTimestampUnavailable)
+		return
+	}
+	bundle, bundleErr := s.commitmentProofBundle(r)
+	if bundleErr != nil {
+		writeError(w, bundleErr.Error())
+		return
+	} else if bundle.Block == nil {
+		writeError(w, ErrorTimestampPending)
+		return
+	}
+	if encodeErr := bundle.EncodeOps(s.proofOps); encodeErr != nil {
+		writeError(w, ErrorTimestampGet)
+		return
+	}
+	response, responseErr := s.timestamper.TimestampResponse(bundle)
+	if responseErr != nil {
+		log.Warnf("%s: %v\n", ErrorTimestampGet, responseErr)
+		writeError(w, ErrorTimestampGet)
+		return
+	}
+	w.Header().Set("Content-Type", "application/timestamp-reply")
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+// Return proof bundle of the client commitment of the request route variables
+func (s *RequestService) commitmentProofBundle(r *http.Request) (models.ProofBundle, error) {
+	proof, info, proofErr := s.commitmentProof(r)
+	if proofErr != nil {
+		return models.ProofBundle{}, proofErr
+	}
+	bundle := models.NewProofBundle(proof, info)
+	s.addSpvProof(&bundle)
+	return bundle, nil
+}
+
+// Add spv proof of the attestation transaction to the block of the proof
+// bundle, if confirmed. Spv proofs that cannot be fetched are omitted
+func (s *RequestService) addSpvProof(bundle *models.ProofBundle) {
+	if s.spvSource == nil || bundle.Block == nil {
+		return
+	}
+	spv, spvErr := s.spvSource.SpvProof(bundle.Txid, bundle.Block.Hash)
+	if spvErr != nil {
+		log.Warnf("%s: %v\n", WarningSpvProofGet, spvErr)
+		return
+	}
+	bundle.Block.Spv = &spv
+}
+
+// Return merkle proof of the client commitment of the request route variables
+// and the attestation info of the proof merkle root
+func (s *RequestService) commitmentProof(r *http.Request) (models.CommitmentMerkleProof, models.AttestationInfo, error) {
+	position, positionErr := strconv.ParseInt(Vars(r)["position"], 10, 32)
+	if positionErr != nil {
+		return models.CommitmentMerkleProof{}, models.AttestationInfo{}, errors.New(ErrorAdminPositionInvalid)
+	}
+	commitment, commitmentErr := chainhash.NewHashFromStr(Vars(r)["commitment"])
+	if commitmentErr != nil {
+		return models.CommitmentMerkleProof{}, models.AttestationInfo{}, errors.New(ErrorCommitmentInvalid)
+	}
+
+	proof, proofErr := s.dbInterface.GetCommitmentMerkleProof(int32(position), *commitment)
+	if proofErr != nil {
+		return models.CommitmentMerkleProof{}, models.AttestationInfo{}, errors.New(ErrorProofGet)
+	} else if proof.MerkleRoot == (chainhash.Hash{}) {
+		return models.CommitmentMerkleProof{}, models.AttestationInfo{}, errors.New(ErrorProofPending)
+	}
+	info, infoErr := s.dbInterface.GetAttestationInfoByMerkleRoot(proof.MerkleRoot)
+	if infoErr != nil {
+		return models.CommitmentMerkleProof{}, models.AttestationInfo{}, errors.New(ErrorProofGet)
+	}
+	return proof, info, nil
+}
+
+// Commitment binary proof request handler
+// Returns the base64 encoded compact binary merkle proof of a client
+// commitment, with the attestation transaction committing to its root
+func HandleCommitmentProofBinary(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	proof, info, proofErr := s.commitmentProof(r)
+	if proofErr != nil {
+		writeError(w, proofErr.Error())
+		return
+	}
+	encoded, encodeErr := models.EncodeProofBase64(proof)
+	if encodeErr != nil {
+		writeError(w, ErrorProofGet)
+		return
+	}
+	writeResponse(w, models.ProofBinaryResponse{
+		Proof:     encoded,
+		Txid:      info.Txid,
+		Confirmed: info.Blockhash != "",
+	})
+}
+
+// Return preview proof bundle of the proof bundle with the next hash function
+// of the protocol transition, only available while dual running. The proof is
+// to the root of the attested commitments rebuilt with the next hash function
+func (s *RequestService) transitionProofBundle(bundle models.ProofBundle, hash string) (models.ProofBundle, error) {
+	if s.transition == nil || hash != s.transition.Hash || bundle.Group != nil {
+		return models.ProofBundle{}, errors.New(fmt.Sprintf("%s: %s", ErrorProofHashUnavailable, hash))
+	}
+	_, round, roundErr := s.roundMerkleHash()
+	if roundErr != nil {
+		return models.ProofBundle{}, errors.New(ErrorTransitionGet)
+	} else if !s.transition.DualRunning(round) {
+		return models.ProofBundle{}, errors.New(fmt.Sprintf("%s: %s", ErrorProofHashUnavailable, hash))
+	}
+
+	txid, txidErr := chainhash.NewHashFromStr(bundle.Txid)
+	if txidErr != nil {
+		return models.ProofBundle{}, errors.New(ErrorProofGet)
+	}
+	merkleCommitments, commitmentsErr := s.dbInterface.GetAttestationMerkleCommitments(*txid)
+	if commitmentsErr != nil {
+		return models.ProofBundle{}, errors.New(ErrorProofGet)
+	}
+	var clientCommitments []models.ClientCommitment
+	for _, c := range merkleCommitments {
+		clientCommitments = append(clientCommitments,
+			models.ClientCommitment{Commitment: c.Commitment, ClientPosition: c.ClientPosition})
+	}
+	commitment, commitmentErr := s.ordering.Commitment(clientCommitments, hash)
+	if commitmentErr != nil {
+		return models.ProofBundle{}, errors.New(ErrorProofGet)
+	}
+	for _, proof := range commitment.GetMerkleProofs() {
+		if proof.ClientPosition == bundle.Slot && proof.Commitment.String() == bundle.Commitment {
+			preview := models.NewProofBundle(proof, models.AttestationInfo{Txid: bundle.Txid})
+			preview.Block = bundle.Block
+			preview.Params.Preview = true
+			return preview, nil
+		}
+	}
+	return models.ProofBundle{}, errors.New(ErrorProofGet)
+}
+
+// Latest proof request handler
+// Returns the proof bundle of the client position commitment in the latest
+// confirmed attestation, with the attestation transaction and the hash and
+// height of the block confirming it
+func HandleLatestProof(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	position, positionErr := strconv.ParseInt(Vars(r)["position"], 10, 32)
+	if positionErr != nil {
+		writeError(w, ErrorAdminPositionInvalid)
+		return
+	}
+
+	proof, proofErr := s.dbInterface.GetLatestCommitmentMerkleProof(int32(position))
+	if proofErr != nil {
+		writeError(w, ErrorProofGet)
+		return
+	} else if proof.MerkleRoot == (chainhash.Hash{}) || proof.Commitment == (chainhash.Hash{}) {
+		writeError(w, ErrorLatestProofPending)
+		return
+	}
+	info, infoErr := s.dbInterface.GetAttestationInfoByMerkleRoot(proof.MerkleRoot)
+	if infoErr != nil {
+		writeError(w, ErrorProofGet)
+		return
+	}
+	bundle := models.NewProofBundle(proof, info)
+	if bundle.Block == nil {
+		writeError(w, ErrorLatestProofPending)
+		return
+	}
+	s.addSpvProof(&bundle)
+	if encodeErr := bundle.EncodeOps(s.proofOps); encodeErr != nil {
+		writeError(w, ErrorProofGet)
+		return
+	}
+	writeResponse(w, bundle)
+}
+
+// Commitment submission request handler
+// Returns the submission with the id returned on commitment send, with the
+// status of the submission and, once included in or superseded by an
+// attestation, the merkle root and txid of the attestation and whether it
+// is confirmed
+func HandleCommitmentSubmission(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	submission, submissionErr := s.dbInterface.GetCommitmentSubmission(Vars(r)["id"])
+	if submissionErr != nil {
+		writeError(w, ErrorSubmissionGet)
+		return
+	} else if submission.Id == "" {
+		writeError(w, ErrorSubmissionNotFound)
+		return
+	}
+
+	if submission.MerkleRoot != "" {
+		merkleRoot, rootErr := chainhash.NewHashFromStr(submission.MerkleRoot)
+		if rootErr != nil {
+			writeError(w, ErrorSubmissionGet)
+			return
+		}
+		info, infoErr := s.dbInterface.GetAttestationInfoByMerkleRoot(*merkleRoot)
+		if infoErr != nil {
+			writeError(w, ErrorSubmissionGet)
+			return
+		}
+		submission.Txid = info.Txid
+		submission.Confirmed = info.Blockhash != ""
+	}
+	writeResponse(w, submission)
+}
+
+// Commitment exclusions request handler
+// Returns the commitments of the client position submitted before the close of
+// an attestation round that were not included in the round. The request must be
+// authorized by the client, with either the auth token as bearer token or the
+// hmac request signature
+func HandleCommitmentExclusions(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	position, positionErr := strconv.ParseInt(Vars(r)["position"], 10, 32)
+	if positionErr != nil {
+		writeError(w, ErrorAdminPositionInvalid)
+		return
+	}
+	details, detailsErr := s.clientDetails(int32(position))
+	if detailsErr != nil {
+		writeError(w, detailsErr.Error())
+		return
+	}
+	if authErr := s.authorizeClient(r, details); authErr != nil {
+		writeError(w, authErr.Error())
+		return
+	}
+
+	exclusions, exclusionsErr := s.dbInterface.GetCommitmentExclusions(details.ClientPosition)
+	if exclusionsErr != nil {
+		writeError(w, ErrorExclusionsGet)
+		return
+	}
+	writeResponse(w, exclusions)
+}
+
+// Commitment history request handler
+// Returns a page of the commitments of the client position included in
+// attestation rounds newest first, with the merkle root of each round and the
+// attestation txid committing to it. Pages are selected with the offset and
+// limit query parameters, with limits above the max reduced to the max. The
+// request must be authorized by the client, with either the auth token as
+// bearer token or the hmac request signature
+func HandleCommitmentHistory(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	position, positionErr := strconv.ParseInt(Vars(r)["position"], 10, 32)
+	if positionErr != nil {
+		writeError(w, ErrorAdminPositionInvalid)
+		return
+	}
+	offset, limit, pageErr := pagination(r)
+	if pageErr != nil {
+		writeError(w, pageErr.Error())
+		return
+	}
+	details, detailsErr := s.clientDetails(int32(position))
+	if detailsErr != nil {
+		writeError(w, detailsErr.Error())
+		return
+	}
+	if authErr := s.authorizeClient(r, details); authErr != nil {
+		writeError(w, authErr.Error())
+		return
+	}
+
+	history, historyErr := s.dbInterface.GetCommitmentHistory(details.ClientPosition, offset, limit)
+	if historyErr != nil {
+		writeError(w, ErrorHistoryGet)
+		return
+	}
+	writeResponse(w, history)
+}
+
+// Return offset and limit query parameters of request
+func pagination(r *http.Request) (int64, int64, error) {
+	offset, limit := int64(0), int64(HistoryDefaultLimit)
+	query := r.URL.Query()
+	if value := query.Get(QueryOffset); value != "" {
+		parsed, parseErr := strconv.ParseInt(value, 10, 64)
+		if parseErr != nil || parsed < 0 {
+			return 0, 0, errors.New(ErrorPaginationInvalid)
+		}
+		offset = parsed
+	}
+	if value := query.Get(QueryLimit); value != "" {
+		parsed, parseErr := strconv.ParseInt(value, 10, 64)
+		if parseErr != nil || parsed <= 0 {
+			return 0, 0, errors.New(ErrorPaginationInvalid)
+		}
+		limit = parsed
+	}
+	if limit > HistoryMaxLimit {
+		limit = HistoryMaxLimit
+	}
+	return offset, limit, nil
+}
+
+// Client proof delivery registration request handler
+// Registers the target that the proofs of the client position are pushed to
+// once confirmed, replacing any existing target. The request must be
+// authorized by the client, with either the auth token as bearer token or
+// the hmac request signature
+func HandleClientDelivery(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	s.handleClientDelivery(w, r, true)
+}
+
+// Client proof delivery removal request handler
+func HandleClientDeliveryRemove(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	s.handleClientDelivery(w, r, false)
+}
+
+// Set or clear proof delivery target of client position and return client details
+func (s *RequestService) handleClientDelivery(w http.ResponseWriter, r *http.Request, register bool) {
+	if s.proofDeliverer == nil {
+		writeError(w, ErrorDeliveryUnavailable)
+		return
+	}
+	position, positionErr := strconv.ParseInt(Vars(r)["position"], 10, 32)
+	if positionErr != nil {
+		writeError(w, ErrorAdminPositionInvalid)
+		return
+	}
+	details, detailsErr := s.clientDetails(int32(position))
+	if detailsErr != nil {
+		writeError(w, detailsErr.Error())
+		return
+	}
+	body, bodyErr := io.ReadAll(r.Body)
+	if bodyErr != nil {
+		writeError(w, requestBodyError(bodyErr))
+		return
+	}
+	if authErr := s.authorizeClientBody(r, body, details); authErr != nil {
+		writeError(w, authErr.Error())
+		return
+	}
+
+	details.DeliveryUrl = ""
+	if register {
+		var request ClientDeliveryRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			writeError(w, ErrorRequestPayload)
+			return
+		}
+		if targetErr := s.proofDeliverer.ValidateTarget(request.Url); targetErr != nil {
+			writeError(w, targetErr.Error())
+			return
+		}
+		details.DeliveryUrl = request.Url
+	}
+	if err := s.dbInterface.SaveClientDetails(details); err != nil {
+		writeError(w, ErrorClientDetailsSave)
+		return
+	}
+	writeResponse(w, models.ClientDeliveryResponse{
+		ClientPosition: details.ClientPosition, DeliveryUrl: details.DeliveryUrl})
+}
+
+// Proof schema request handler
+// Returns the json schema of the proof bundles returned by proof requests
+func HandleProofSchema(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(models.ProofBundleSchema)
+}
+
+// Protocol request handler
+// Returns the protocol parameters of the proof bundles returned by proof
+// requests, including the merkle tree hash function and proof ops encoding
+// used and the ordering of the client commitments in the merkle tree, and
+// the protocol transition with its switchover round if configured
+func HandleProtocol(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	hash, round, hashErr := s.roundMerkleHash()
+	if hashErr != nil {
+		writeError(w, ErrorTransitionGet)
+		return
+	}
+	response := models.ProtocolResponse{
+		Version:  models.ProofBundleVersion,
+		Protocol: models.ProofBundleProtocol,
+		Hash:     hash,
+		Encoding: models.ProofBundleEncoding,
+		Ops:      s.proofOps,
+		Ordering: s.ordering,
+	}
+	if s.transition != nil {
+		response.Transition = &models.TransitionResponse{
+			ProtocolTransition: *s.transition,
+			Round:              round,
+			DualRunning:        s.transition.DualRunning(round),
+		}
+	}
+	writeResponse(w, response)
+}
+
+// Balance request handler
+// Returns the staychain balance and the number of remaining attestations
+func HandleBalance(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	if s.balanceSource == nil {
+		writeError(w, ErrorBalanceUnavailable)
+		return
+	}
+	balance, isSet := s.balanceSource.Balance()
+	if !isSet {
+		writeError(w, ErrorBalanceUnavailable)
+		return
+	}
+	writeResponse(w, balance)
+}
+
+// Fees request handler
+// Returns the fees spent on confirmed attestations in total and in the
+// current day and month, with the fee budgets and whether one is exceeded
+func HandleFees(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	if s.feeSpendSource == nil {
+		writeError(w, ErrorFeesUnavailable)
+		return
+	}
+	spend, spendErr := s.feeSpendSource.FeeSpend()
+	if spendErr != nil {
+		writeError(w, ErrorFeesGet)
+		return
+	}
+	writeResponse(w, spend)
+}
+
+// Events request handler
+// Streams attestation broadcast, fee bump and confirmation events to the
+// client as server-sent events until the client disconnects
+func HandleEvents(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	flusher, isFlusher := w.(http.Flusher)
+	if s.eventSource == nil || !isFlusher {
+		writeError(w, ErrorEventsUnavailable)
+		return
+	}
+	events, cancel := s.eventSource.Subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// streams outlive the server write timeout and end on service shutdown
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	var shutdown <-chan struct{}
+	if s.ctx != nil {
+		shutdown = s.ctx.Done()
+	}
+
+	keepAlive := time.NewTicker(EventsKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-shutdown:
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			payload, marshalErr := json.Marshal(event)
+			if marshalErr != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Event, payload)
+		}
+		flusher.Flush()
+	}
+}
+
+// Liveness probe request handler
+// Responds with service unavailable status if the attestation service has
+// stalled, so that orchestrators restart mainstay
+func HandleHealthz(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	if s.healthChecker == nil {
+		writeError(w, ErrorHealthUnavailable)
+		return
+	}
+	writeHealthReport(w, s.healthChecker.Liveness())
+}
+
+// Readiness probe request handler
+// Responds with service unavailable status while bitcoind, the database
+// or signers are unreachable or attestation states are failing
+func HandleReadyz(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	if s.healthChecker == nil {
+		writeError(w, ErrorHealthUnavailable)
+		return
+	}
+	writeHealthReport(w, s.healthChecker.Readiness())
+}
+
+// Write health report with status code for probes
+func writeHealthReport(w http.ResponseWriter, report models.HealthReport) {
+	status := http.StatusOK
+	if !report.Healthy {
+		status = http.StatusServiceUnavailable
+	}
+	writeResponseStatus(w, status, models.Response{Response: report})
+}
+
+// Admin integrity request handler
+// Walks all stored attestations and returns a pass or fail report with the
+// first inconsistent round. Requires admin authorization as every round is
+// looked up on-chain
+func HandleAdminIntegrity(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	if authErr := s.authorizeAdmin(r); authErr != nil {
+		writeError(w, authErr.Error())
+		return
+	}
+	if s.integrityChecker == nil {
+		writeError(w, ErrorIntegrityUnavailable)
+		return
+	}
+	report, reportErr := s.integrityChecker.CheckIntegrity()
+	if reportErr != nil {
+		log.WithFields(log.Fields{log.FieldRequestId: RequestId(r), log.FieldError: reportErr}).Warnln(ErrorIntegrityCheck)
+		writeError(w, ErrorIntegrityCheck)
+		return
+	}
+	writeResponse(w, report)
+}
+
+// Derivation history request handler
+// Returns the commitment, tweak, derivation path and resulting script and
+// address of a confirmed attestation, recomputed from the stored commitments
+// and the staychain base keys for spot-audits of past attestations
+func HandleDerivationHistory(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	if s.attestDeriver == nil {
+		writeError(w, ErrorDeriveUnavailable)
+		return
+	}
+	derivation, derivationErr := s.attestDeriver.DeriveAttestation(Vars(r)["txid"])
+	if derivationErr != nil {
+		writeError(w, derivationErr.Error())
+		return
+	}
+	writeResponse(w, derivation)
+}
+
+// Attestation request handler
+// Returns the attestation with the merkle root it commits to and, once
+// confirmed, the height, hash and time of the block confirming it and
+// the fee paid, so that proofs can reference an exact block
+func HandleAttestation(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	txid, txidErr := chainhash.NewHashFromStr(Vars(r)["txid"])
+	if txidErr != nil || len(Vars(r)["txid"]) != 2*chainhash.HashSize {
+		writeError(w, ErrorAttestationInvalid)
+		return
+	}
+
+	attestation, attestationErr := s.dbInterface.GetAttestation(*txid)
+	if attestationErr != nil {
+		writeError(w, ErrorAttestationGet)
+		return
+	} else if attestation.Txid == "" {
+		writeError(w, ErrorAttestationNotFound)
+		return
+	}
+	writeResponse(w, models.NewAttestationBSONResponse(attestation))
+}
+
+// Staychain request handler
+// Returns a page of the staychain transactions indexed from the main chain,
+// newest first, with the blocks confirming them and the transactions they
+// spend and are spent by. Pages are selected with the offset and limit
+// query parameters
+func HandleStaychain(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	offset, limit, pageErr := pagination(r)
+	if pageErr != nil {
+		writeError(w, pageErr.Error())
+		return
+	}
+
+	txs, txsErr := s.dbInterface.GetStaychainTxs(offset, limit)
+	if txsErr != nil {
+		writeError(w, ErrorStaychainGet)
+		return
+	}
+	writeResponse(w, txs)
+}
+
+// Staychain transaction request handler
+// Returns the staychain transaction indexed from the main chain with the
+// block confirming it and the transactions it spends and is spent by
+func HandleStaychainTx(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	txid := Vars(r)["txid"]
+	if _, txidErr := chainhash.NewHashFromStr(txid); txidErr != nil || len(txid) != 2*chainhash.HashSize {
+		writeError(w, ErrorStaychainTxInvalid)
+		return
+	}
+
+	tx, txErr := s.dbInterface.GetStaychainTx(txid)
+	if txErr != nil {
+		writeError(w, ErrorStaychainGet)
+		return
+	} else if tx.Txid == "" {
+		writeError(w, ErrorStaychainTxNotFound)
+		return
+	}
+	writeResponse(w, tx)
+}
+
+// Admin client hmac secret request handler
+// Generates a new hmac secret for the client position, replacing any
+// existing one, and returns it in the response
+func HandleAdminClientHmac(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	details, detailsErr := s.adminClientDetails(r)
+	if detailsErr != nil {
+		writeError(w, detailsErr.Error())
+		return
+	}
+
+	secret := make([]byte, HmacSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		writeError(w, ErrorHmacSecretGenerate)
+		return
+	}
+	details.HmacSecret = hex.EncodeToString(secret)
+	if err := s.dbInterface.SaveClientDetails(details); err != nil {
+		writeError(w, ErrorClientDetailsSave)
+		return
+	}
+	writeResponse(w, models.ClientHmacResponse{
+		ClientPosition: details.ClientPosition, HmacSecret: details.HmacSecret})
+}
+
+// Admin client hmac secret revoke request handler
+func HandleAdminClientHmacRevoke(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	details, detailsErr := s.adminClientDetails(r)
+	if detailsErr != nil {
+		writeError(w, detailsErr.Error())
+		return
+	}
+
+	details.HmacSecret = ""
+	if err := s.dbInterface.SaveClientDetails(details); err != nil {
+		writeError(w, ErrorClientDetailsSave)
+		return
+	}
+	writeResponse(w, "Hmac secret revoked")
+}
+
+// Admin attest now request handler
+// Triggers an out of schedule attestation if the attestation service
+// is waiting for the next commitment
+func HandleAdminAttest(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	if authErr := s.authorizeAdmin(r); authErr != nil {
+		writeError(w, authErr.Error())
+		return
+	}
+	if s.attestTrigger == nil {
+		writeError(w, ErrorAttestUnavailable)
+		return
+	}
+	s.attestTrigger.AttestNow()
+	writeResponse(w, "Attestation triggered")
+}
+
+// Admin pause request handler
+// Pauses the attestation service after the current state completes
+func HandleAdminPause(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	s.handleAdminPause(w, r, true)
+}
+
+// Admin resume request handler
+func HandleAdminResume(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	s.handleAdminPause(w, r, false)
+}
+
+// Pause or resume attestation service and return the paused flag
+func (s *RequestService) handleAdminPause(w http.ResponseWriter, r *http.Request, pause bool) {
+	if authErr := s.authorizeAdmin(r); authErr != nil {
+		writeError(w, authErr.Error())
+		return
+	}
+	if s.attestPauser == nil {
+		writeError(w, ErrorPauseUnavailable)
+		return
+	}
+
+	var pauseErr error
+	if pause {
+		pauseErr = s.attestPauser.Pause()
+	} else {
+		pauseErr = s.attestPauser.Resume()
+	}
+	if pauseErr != nil {
+		writeError(w, pauseErr.Error())
+		return
+	}
+	writeResponse(w, models.StateResponse{Paused: s.attestPauser.IsPaused()})
+}
+
+// Admin review request handler
+// Returns the signed attestation pending operator review, if any
+func HandleAdminReview(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	if authErr := s.authorizeAdmin(r); authErr != nil {
+		writeError(w, authErr.Error())
+		return
+	}
+	if s.attestReviewer == nil {
+		writeError(w, ErrorReviewUnavailable)
+		return
+	}
+	review, pending := s.attestReviewer.PendingReview()
+	if !pending {
+		writeResponse(w, models.ReviewResponse{Pending: false})
+		return
+	}
+	writeResponse(w, models.ReviewResponse{Pending: true, Review: &review})
+}
+
+// Admin review veto request handler
+// Vetoes the attestation pending review so that it is not broadcast
+// The optional txid query parameter must match the attestation pending review
+func HandleAdminReviewVeto(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	if authErr := s.authorizeAdmin(r); authErr != nil {
+		writeError(w, authErr.Error())
+		return
+	}
+	if s.attestReviewer == nil {
+		writeError(w, ErrorReviewUnavailable)
+		return
+	}
+	review, vetoErr := s.attestReviewer.VetoReview(r.URL.Query().Get("txid"))
+	if vetoErr != nil {
+		writeError(w, vetoErr.Error())
+		return
+	}
+	writeResponse(w, models.ReviewResponse{Pending: true, Review: &review})
+}
+
+// Key rotations request handler
+// Returns the active rotations of the federation keys, so that verifiers
+// can follow the staychain across the transition attestations
+func HandleKeyRotations(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	rotations, rotationsErr := s.dbInterface.GetKeyRotations()
+	if rotationsErr != nil {
+		writeError(w, ErrorRotationsGet)
+		return
+	}
+	active := []models.KeyRotation{}
+	for _, rotation := range rotations {
+		if rotation.Status == models.KeyRotationActive {
+			active = append(active, rotation)
+		}
+	}
+	writeResponse(w, models.KeyRotationsResponse{Rotations: active})
+}
+
+// Signers request handler
+// Returns the liveness of the federation signers from the signatures
+// received for the latest signature requests of the attestation service
+func HandleSigners(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	signers, signersErr := s.signerHealth()
+	if signersErr != nil {
+		writeError(w, ErrorSignersGet)
+		return
+	}
+	writeResponse(w, models.SignersResponse{Signers: signers})
+}
+
+// Return liveness of the federation signers with their health
+func (s *RequestService) signerHealth() ([]models.SignerHealth, error) {
+	statuses, statusesErr := s.dbInterface.GetSignerStatuses()
+	if statusesErr != nil {
+		return nil, statusesErr
+	}
+	signers := []models.SignerHealth{}
+	for _, status := range statuses {
+		signers = append(signers, models.SignerHealth{SignerStatus: status, Health: status.Health()})
+	}
+	return signers, nil
+}
+
+// Status request handler
+// Returns a snapshot of the staychain balance, the last confirmed
+// attestation, the attestation state machine and the signer health, with
+// the balance and state machine null if not available
+func HandleStatus(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	status := models.StatusResponse{Time: time.Now().Unix()}
+	if s.balanceSource != nil {
+		if balance, isSet := s.balanceSource.Balance(); isSet {
+			status.Balance = &balance
+		}
+	}
+	if s.statusSource != nil {
+		attestation := s.statusSource.Status()
+		status.Attestation = &attestation
+	}
+
+	lastAttestation, lastErr := s.lastAttestation()
+	if lastErr != nil {
+		writeError(w, ErrorAttestationGet)
+		return
+	}
+	status.LastAttestation = lastAttestation
+
+	signers, signersErr := s.signerHealth()
+	if signersErr != nil {
+		writeError(w, ErrorSignersGet)
+		return
+	}
+	status.Signers = signers
+	writeResponse(w, status)
+}
+
+// Return last confirmed attestation, or nil if none confirmed yet
+func (s *RequestService) lastAttestation() (*models.AttestationResponse, error) {
+	merkleRoot, rootErr := s.dbInterface.GetLatestAttestationMerkleRoot(true)
+	if rootErr != nil {
+		return nil, rootErr
+	} else if merkleRoot == "" {
+		return nil, nil
+	}
+	hash, hashErr := chainhash.NewHashFromStr(merkleRoot)
+	if hashErr != nil {
+		return nil, hashErr
+	}
+	info, infoErr := s.dbInterface.GetAttestationInfoByMerkleRoot(*hash)
+	if infoErr != nil {
+		return nil, infoErr
+	} else if info.Txid == "" {
+		return nil, nil
+	}
+	return &models.AttestationResponse{
+		Txid:       info.Txid,
+		MerkleRoot: merkleRoot,
+		Confirmed:  true,
+		Time:       info.Time,
+		Height:     info.Height,
+		Blockhash:  info.Blockhash,
+		BlockTime:  info.BlockTime,
+		Fee:        info.Fee,
+	}, nil
+}
+
+// Admin key rotations request handler
+// Returns all rotations of the federation keys, including those in progress
+func HandleAdminRotation(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	if authErr := s.authorizeAdmin(r); authErr != nil {
+		writeError(w, authErr.Error())
+		return
+	}
+	rotations, rotationsErr := s.dbInterface.GetKeyRotations()
+	if rotationsErr != nil {
+		writeError(w, ErrorRotationsGet)
+		return
+	}
+	if rotations == nil {
+		rotations = []models.KeyRotation{}
+	}
+	writeResponse(w, models.KeyRotationsResponse{Rotations: rotations})
+}
+
+// Admin key rotation request handler
+// Requests rotation of the federation keys to the init script, chaincodes
+// and topup of the request body, picked up by the next new attestation
+func HandleAdminRotationRequest(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	if authErr := s.authorizeAdmin(r); authErr != nil {
+		writeError(w, authErr.Error())
+		return
+	}
+	if s.keyRotator == nil {
+		writeError(w, ErrorRotationUnavailable)
+		return
+	}
+	body, bodyErr := io.ReadAll(r.Body)
+	if bodyErr != nil {
+		writeError(w, requestBodyError(bodyErr))
+		return
+	}
+	var request models.KeyRotation
+	if err := json.Unmarshal(body, &request); err != nil {
+		writeError(w, ErrorRequestPayload)
+		return
+	}
+	rotation, rotationErr := s.keyRotator.RequestKeyRotation(request)
+	if rotationErr != nil {
+		writeError(w, rotationErr.Error())
+		return
+	}
+	writeResponse(w, rotation)
+}
+
+// Admin key rotation cancel request handler
+// Cancels the pending key rotation before its transition attestation
+func HandleAdminRotationCancel(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	if authErr := s.authorizeAdmin(r); authErr != nil {
+		writeError(w, authErr.Error())
+		return
+	}
+	if s.keyRotator == nil {
+		writeError(w, ErrorRotationUnavailable)
+		return
+	}
+	rotation, rotationErr := s.keyRotator.CancelKeyRotation()
+	if rotationErr != nil {
+		writeError(w, rotationErr.Error())
+		return
+	}
+	writeResponse(w, rotation)
+}
+
+// Admin round snapshots request handler
+// Returns a page of the snapshots of the client commitments read by each
+// attestation round, newest first, optionally of the root query parameter
+func HandleAdminRounds(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	if authErr := s.authorizeAdmin(r); authErr != nil {
+		writeError(w, authErr.Error())
+		return
+	}
+	offset, limit, pageErr := pagination(r)
+	if pageErr != nil {
+		writeError(w, pageErr.Error())
+		return
+	}
+	merkleRoot := r.URL.Query().Get(QueryRoot)
+	if merkleRoot != "" {
+		if _, rootErr := chainhash.NewHashFromStr(merkleRoot); rootErr != nil {
+			writeError(w, ErrorCommitmentInvalid)
+			return
+		}
+	}
+
+	snapshots, snapshotsErr := s.dbInterface.GetRoundSnapshots(merkleRoot, offset, limit)
+	if snapshotsErr != nil {
+		writeError(w, ErrorRoundsGet)
+		return
+	}
+	writeResponse(w, snapshots)
+}
+
+// Admin round snapshot request handler
+// Returns the snapshot of the client commitments read by the round, after
+// checking that the commitments recompute the merkle root of the round
+func HandleAdminRound(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	if authErr := s.authorizeAdmin(r); authErr != nil {
+		writeError(w, authErr.Error())
+		return
+	}
+	round, roundErr := strconv.ParseInt(Vars(r)["round"], 10, 64)
+	if roundErr != nil || round <= 0 {
+		writeError(w, ErrorRoundInvalid)
+		return
+	}
+
+	snapshot, snapshotErr := s.dbInterface.GetRoundSnapshot(round)
+	if snapshotErr != nil {
+		writeError(w, ErrorRoundsGet)
+		return
+	} else if snapshot.Round == 0 {
+		writeError(w, ErrorRoundNotFound)
+		return
+	}
+	merkleRoot, rootErr := snapshot.ComputeMerkleRoot(s.ordering)
+	if rootErr != nil || merkleRoot.String() != snapshot.MerkleRoot {
+		writeError(w, ErrorRoundSnapshotInvalid)
+		return
+	}
+	writeResponse(w, snapshot)
+}
+
+// Admin slot group registration request handler
+// Registers the client position as a slot group
+func HandleAdminClientGroup(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	s.handleAdminClientGroup(w, r, true)
+}
+
+// Admin slot group removal request handler
+func HandleAdminClientGroupRemove(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	s.handleAdminClientGroup(w, r, false)
+}
+
+// Set slot group flag of client position and return client details
+func (s *RequestService) handleAdminClientGroup(w http.ResponseWriter, r *http.Request, slotGroup bool) {
+	details, detailsErr := s.adminClientDetails(r)
+	if detailsErr != nil {
+		writeError(w, detailsErr.Error())
+		return
+	}
+
+	details.SlotGroup = slotGroup
+	if err := s.dbInterface.SaveClientDetails(details); err != nil {
+		writeError(w, ErrorClientDetailsSave)
+		return
+	}
+	writeResponse(w, models.ClientGroupResponse{
+		ClientPosition: details.ClientPosition, SlotGroup: details.SlotGroup})
+}
+
+// Validate slot group members for commitment of slot group clients and
+// return the slot group to store. Members are only accepted for slot group
+// clients and are required for these. The group merkle tree is built with
+// the hash function of the commitment merkle tree
+func slotGroup(details models.ClientDetails, commitment chainhash.Hash, members []string,
+	hash string) (*models.SlotGroup, error) {
+
+	if !details.SlotGroup {
+		if len(members) > 0 {
+			return nil, errors.New(ErrorSlotGroupNotGroup)
+		}
+		return nil, nil
+	} else if len(members) == 0 {
+		return nil, errors.New(ErrorSlotGroupMembers)
+	}
+
+	var memberHashes []chainhash.Hash
+	for _, member := range members {
+		memberHash, memberErr := ParseCommitment(member)
+		if memberErr != nil {
+			return nil, memberErr
+		}
+		memberHashes = append(memberHashes, memberHash)
+	}
+	group, groupErr := models.NewSlotGroupHash(details.ClientPosition, memberHashes, hash)
+	if groupErr != nil {
+		return nil, groupErr
+	} else if group.MerkleRoot != commitment {
+		return nil, errors.New(ErrorSlotGroupMismatch)
+	}
+	return group, nil
+}
+
+// Verify hmac signed commitment request for client
+func (s *RequestService) verifyHmacRequest(r *http.Request, body []byte, authorization string, details models.ClientDetails) error {
+	if !s.authSchemes[AuthSchemeHmac] {
+		return errors.New(fmt.Sprintf("%s: %s", ErrorAuthSchemeDisabled, AuthSchemeHmac))
+	}
+	position, signature, parseErr := ParseHmacAuthorization(authorization)
+	if parseErr != nil {
+		return parseErr
+	}
+	if position != details.ClientPosition {
+		return errors.New(ErrorHmacPositionMismatch)
+	}
+	if details.HmacSecret == "" {
+		return errors.New(ErrorHmacSecretNotSet)
+	}
+	secret, secretErr := hex.DecodeString(details.HmacSecret)
+	if secretErr != nil {
+		return errors.New(ErrorHmacSecretNotSet)
+	}
+	return s.hmacAuth.Verify(r, body, secret, signature, time.Now())
+}
+
+// Authorize client read request with either the hmac request signature,
+// signed with an empty body, or the client auth token as bearer token
+func (s *RequestService) authorizeClient(r *http.Request, details models.ClientDetails) error {
+	return s.authorizeClientBody(r, []byte{}, details)
+}
+
+// Authorize client request with either the hmac request signature of
+// the request body or the client auth token as bearer token
+func (s *RequestService) authorizeClientBody(r *http.Request, body []byte, details models.ClientDetails) error {
+	authorization := r.Header.Get(HeaderAuthorization)
+	if strings.HasPrefix(authorization, HmacAuthorizationPrefix) {
+		return s.verifyHmacRequest(r, body, authorization, details)
+	}
+	if !s.authSchemes[AuthSchemeToken] {
+		return errors.New(fmt.Sprintf("%s: %s", ErrorAuthSchemeDisabled, AuthSchemeToken))
+	}
+	token := strings.TrimPrefix(authorization, AdminAuthorizationPrefix)
+	if details.AuthToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(details.AuthToken)) != 1 {
+		return errors.New(ErrorAuthTokenInvalid)
+	}
+	return nil
+}
+
+// Return client details for client position
+func (s *RequestService) clientDetails(position int32) (models.ClientDetails, error) {
+	details, detailsErr := s.dbInterface.GetClientDetails()
+	if detailsErr != nil {
+		return models.ClientDetails{}, errors.New(ErrorClientDetailsGet)
+	}
+	for _, client := range details {
+		if client.ClientPosition == position {
+			return client, nil
+		}
+	}
+	return models.ClientDetails{}, errors.New(ErrorAuthClientNotFound)
+}
+
+// Authorize admin request against the admin token
+// Admin routes are disabled if no admin token has been configured
+func (s *RequestService) authorizeAdmin(r *http.Request) error {
+	token := strings.TrimPrefix(r.Header.Get(HeaderAuthorization), AdminAuthorizationPrefix)
+	if s.config.AdminToken == "" ||
+		subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AdminToken)) != 1 {
+		return errors.New(ErrorAdminUnauthorized)
+	}
+	return nil
+}
+
+// Authorize admin request and return client details for the route position
+func (s *RequestService) adminClientDetails(r *http.Request) (models.ClientDetails, error) {
+	if authErr := s.authorizeAdmin(r); authErr != nil {
+		return models.ClientDetails{}, authErr
+	}
+
+	position, positionErr := strconv.ParseInt(Vars(r)["position"], 10, 32)
+	if positionErr != nil {
+		return models.ClientDetails{}, errors.New(ErrorAdminPositionInvalid)
+	}
+	return s.clientDetails(int32(position))
+}
+
+// Write json response with the typed result of the request
+func writeResponse(w http.ResponseWriter, response interface{}) {
+	writeResponseStatus(w, http.StatusOK, models.Response{Response: response})
+}
@@ -0,0 +1,1607 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"bufio"
+	"bytes"
+	b64 "encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/db"
+	"mainstay/models"
+	"mainstay/notify"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+const testCommitment = "1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+
+// Return commitment send request body for commitment/position/token
+func commitmentSendBody(commitment string, position int32, token string, sig []byte) []byte {
+	payload := fmt.Sprintf("{\"commitment\": \"%s\", \"position\": %d, \"token\": \"%s\"}",
+		commitment, position, token)
+	return []byte(fmt.Sprintf("{\"X-MAINSTAY-PAYLOAD\": \"%s\", \"X-MAINSTAY-SIGNATURE\": \"%s\"}",
+		b64.StdEncoding.EncodeToString([]byte(payload)), b64.StdEncoding.EncodeToString(sig)))
+}
+
+// Return commitment send request body for slot group commitment with members
+func groupCommitmentSendBody(commitment string, position int32, token string, members []string) []byte {
+	membersJson, _ := json.Marshal(members)
+	payload := fmt.Sprintf("{\"commitment\": \"%s\", \"position\": %d, \"token\": \"%s\", \"members\": %s}",
+		commitment, position, token, membersJson)
+	return []byte(fmt.Sprintf("{\"X-MAINSTAY-PAYLOAD\": \"%s\", \"X-MAINSTAY-SIGNATURE\": \"\"}",
+		b64.StdEncoding.EncodeToString([]byte(payload))))
+}
+
+// Serve request and return decoded json response, checking error responses
+// have the error envelope and the http status of the error
+func serveRequest(t *testing.T, service *RequestService, r *http.Request) map[string]interface{} {
+	writer := httptest.NewRecorder()
+	service.router.ServeHTTP(writer, r)
+
+	var response map[string]interface{}
+	if err := json.NewDecoder(writer.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if errStr, ok := response["error"].(string); ok {
+		assert.Equal(t, errorStatus(errStr), writer.Code)
+		assert.NotEqual(t, "", response["code"])
+		assert.Equal(t, writer.Header().Get(HeaderRequestId), response["request_id"])
+	} else {
+		assert.Equal(t, http.StatusOK, writer.Code)
+	}
+	return response
+}
+
+// Return response status and decoded response of request served by the router
+// Responses that are not json objects are returned as nil
+func serveRouter(router *Router, r *http.Request) (int, map[string]interface{}) {
+	writer := httptest.NewRecorder()
+	router.ServeHTTP(writer, r)
+	var response map[string]interface{}
+	json.NewDecoder(writer.Body).Decode(&response)
+	return writer.Code, response
+}
+
+// Test route pattern matching
+func TestRouteMatching(t *testing.T) {
+	vars, ok := matchRoutePattern(RouteAdminClientHmac, "/admin/client/5/hmac")
+	assert.Equal(t, true, ok)
+	assert.Equal(t, map[string]string{"position": "5"}, vars)
+
+	_, ok = matchRoutePattern(RouteAdminClientHmac, "/admin/client//hmac/")
+	assert.Equal(t, false, ok)
+	_, ok = matchRoutePattern(RouteCommitmentSend, "/api/commitment/")
+	assert.Equal(t, false, ok)
+
+	service := NewRequestService(nil, nil, db.NewDbFake(), confpkg.ApiConfig{})
+	r, _ := http.NewRequest(GET, RouteCommitmentSend, nil)
+	writer := httptest.NewRecorder()
+	service.router.ServeHTTP(writer, r)
+	assert.Equal(t, http.StatusMethodNotAllowed, writer.Code)
+
+	r, _ = http.NewRequest(GET, "/unknown", nil)
+	writer = httptest.NewRecorder()
+	service.router.ServeHTTP(writer, r)
+	assert.Equal(t, http.StatusNotFound, writer.Code)
+}
+
+// Test commitment send using auth token and signature
+func TestHandleCommitmentSendToken(t *testing.T) {
+	privKey, _ := btcec.NewPrivateKey(btcec.S256())
+	pubKey := hex.EncodeToString(privKey.PubKey().SerializeCompressed())
+
+	dbFake := db.NewDbFake()
+	dbFake.SaveClientDetails(models.ClientDetails{ClientPosition: 0, AuthToken: "token0", ClientName: "client0"})
+	dbFake.SaveClientDetails(models.ClientDetails{ClientPosition: 1, AuthToken: "token1", Pubkey: pubKey, ClientName: "client1"})
+	service := NewRequestService(nil, nil, dbFake, confpkg.ApiConfig{})
+
+	// unknown position
+	r, _ := http.NewRequest(POST, RouteCommitmentSend, bytes.NewReader(commitmentSendBody(testCommitment, 2, "token0", nil)))
+	assert.Equal(t, ErrorAuthClientNotFound, serveRequest(t, service, r)["error"])
+
+	// invalid commitment
+	r, _ = http.NewRequest(POST, RouteCommitmentSend, bytes.NewReader(commitmentSendBody("zz", 0, "token0", nil)))
+	response := serveRequest(t, service, r)
+	assert.Equal(t, ErrorCommitmentInvalid, response["error"])
+	assert.Equal(t, CodeCommitmentLength, response["code"])
+	r, _ = http.NewRequest(POST, RouteCommitmentSend, bytes.NewReader(commitmentSendBody(strings.Repeat("0", 64), 0, "token0", nil)))
+	response = serveRequest(t, service, r)
+	assert.Equal(t, ErrorCommitmentInvalid, response["error"])
+	assert.Equal(t, CodeCommitmentZero, response["code"])
+
+	// wrong token
+	r, _ = http.NewRequest(POST, RouteCommitmentSend, bytes.NewReader(commitmentSendBody(testCommitment, 0, "token1", nil)))
+	assert.Equal(t, ErrorAuthTokenInvalid, serveRequest(t, service, r)["error"])
+
+	// valid token without pubkey, with the commitment case normalized
+	r, _ = http.NewRequest(POST, RouteCommitmentSend, bytes.NewReader(commitmentSendBody(strings.ToUpper(testCommitment), 0, "token0", nil)))
+	assert.Equal(t, models.SubmissionStatusPending, serveRequest(t, service, r)["response"].(map[string]interface{})["status"])
+
+	// pubkey set requires valid signature
+	r, _ = http.NewRequest(POST, RouteCommitmentSend, bytes.NewReader(commitmentSendBody(testCommitment, 1, "token1", nil)))
+	assert.Equal(t, ErrorAuthSignatureInvalid, serveRequest(t, service, r)["error"])
+
+	msg, _ := hex.DecodeString(testCommitment)
+	sig, _ := privKey.Sign(msg)
+	r, _ = http.NewRequest(POST, RouteCommitmentSend, bytes.NewReader(commitmentSendBody(testCommitment, 1, "token1", sig.Serialize())))
+	assert.Equal(t, models.SubmissionStatusPending, serveRequest(t, service, r)["response"].(map[string]interface{})["status"])
+
+	commitments, _ := dbFake.GetClientCommitments()
+	assert.Equal(t, 2, len(commitments))
+	assert.Equal(t, testCommitment, commitments[0].Commitment.String())
+	assert.Equal(t, int32(1), commitments[1].ClientPosition)
+	assert.NotEqual(t, int64(0), commitments[0].SubmittedAt)
+}
+
+// Test commitment send using hmac request signing and admin secret management
+func TestHandleCommitmentSendHmac(t *testing.T) {
+	dbFake := db.NewDbFake()
+	dbFake.SaveClientDetails(models.ClientDetails{ClientPosition: 0, AuthToken: "token0", ClientName: "client0"})
+	service := NewRequestService(nil, nil, dbFake,
+		confpkg.ApiConfig{AuthSchemes: []string{AuthSchemeHmac}, AdminToken: "admin"})
+
+	newHmacRequest := func(secret []byte, body []byte) *http.Request {
+		r, _ := http.NewRequest(POST, RouteCommitmentSend, bytes.NewReader(body))
+		date := time.Now().UTC().Format(http.TimeFormat)
+		digest := HmacBodyDigest(body)
+		r.Header.Set(HeaderDate, date)
+		r.Header.Set(HeaderDigest, digest)
+		r.Header.Set(HeaderAuthorization, HmacAuthorization(0,
+			HmacSignature(secret, HmacSigningString(POST, RouteCommitmentSend, date, digest))))
+		return r
+	}
+
+	// token scheme disabled
+	r, _ := http.NewRequest(POST, RouteCommitmentSend, bytes.NewReader(commitmentSendBody(testCommitment, 0, "token0", nil)))
+	assert.Equal(t, fmt.Sprintf("%s: %s", ErrorAuthSchemeDisabled, AuthSchemeToken), serveRequest(t, service, r)["error"])
+
+	// no secret set yet
+	body := commitmentSendBody(testCommitment, 0, "", nil)
+	assert.Equal(t, ErrorHmacSecretNotSet, serveRequest(t, service, newHmacRequest([]byte("secret"), body))["error"])
+
+	// admin secret generation requires admin token
+	r, _ = http.NewRequest(POST, "/admin/client/0/hmac/", nil)
+	assert.Equal(t, ErrorAdminUnauthorized, serveRequest(t, service, r)["error"])
+	r.Header.Set(HeaderAuthorization, "Bearer admin")
+	response := serveRequest(t, service, r)["response"].(map[string]interface{})
+	secret, secretErr := hex.DecodeString(response[models.ClientDetailsHmacSecretName].(string))
+	assert.Equal(t, nil, secretErr)
+	assert.Equal(t, HmacSecretSize, len(secret))
+
+	// valid hmac request
+	response = serveRequest(t, service, newHmacRequest(secret, body))["response"].(map[string]interface{})
+	assert.Equal(t, models.SubmissionStatusPending, response["status"])
+	commitments, _ := dbFake.GetClientCommitments()
+	assert.Equal(t, 1, len(commitments))
+
+	// revoke secret
+	r, _ = http.NewRequest(DELETE, "/admin/client/0/hmac/", nil)
+	r.Header.Set(HeaderAuthorization, "Bearer admin")
+	assert.Equal(t, "Hmac secret revoked", serveRequest(t, service, r)["response"])
+	assert.Equal(t, ErrorHmacSecretNotSet, serveRequest(t, service, newHmacRequest(secret, body))["error"])
+}
+
+// Test bulk commitment send is only stored if all commitments are valid
+func TestHandleCommitmentSendBulk(t *testing.T) {
+	dbFake := db.NewDbFake()
+	dbFake.SaveClientDetails(models.ClientDetails{ClientPosition: 0, AuthToken: "token0", ClientName: "client0"})
+	dbFake.SaveClientDetails(models.ClientDetails{ClientPosition: 1, AuthToken: "token1", ClientName: "client1"})
+	service := NewRequestService(nil, nil, dbFake, confpkg.ApiConfig{})
+
+	bulkBody := func(requests ...[]byte) []byte {
+		return []byte(fmt.Sprintf("{\"commitments\": [%s]}", bytes.Join(requests, []byte(","))))
+	}
+	otherCommitment := "2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+
+	// empty bulk
+	r, _ := http.NewRequest(POST, RouteCommitmentSendBulk, bytes.NewReader(bulkBody()))
+	assert.Equal(t, fmt.Sprintf("%s (max %d)", ErrorBulkSize, BulkMaxCommitments), serveRequest(t, service, r)["error"])
+
+	// invalid commitments reject the whole bulk
+	r, _ = http.NewRequest(POST, RouteCommitmentSendBulk, bytes.NewReader(bulkBody(
+		commitmentSendBody(testCommitment, 0, "token0", nil),
+		commitmentSendBody(otherCommitment, 1, "token0", nil),
+		commitmentSendBody(otherCommitment, 0, "token0", nil),
+		commitmentSendBody(otherCommitment+"zz", 2, "token0", nil))))
+	response := serveRequest(t, service, r)
+	assert.Equal(t, ErrorBulkRejected, response["error"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"position": float64(0), "commitment": testCommitment},
+		map[string]interface{}{"position": float64(1), "commitment": otherCommitment, "error": ErrorAuthTokenInvalid},
+		map[string]interface{}{"position": float64(0), "commitment": otherCommitment, "error": ErrorBulkPositionDuplicate},
+		map[string]interface{}{"position": float64(2), "commitment": otherCommitment + "zz", "error": ErrorCommitmentInvalid,
+			"code": CodeCommitmentLength},
+	}, response["results"])
+	commitments, _ := dbFake.GetClientCommitments()
+	assert.Equal(t, 0, len(commitments))
+
+	// valid bulk
+	r, _ = http.NewRequest(POST, RouteCommitmentSendBulk, bytes.NewReader(bulkBody(
+		commitmentSendBody(testCommitment, 0, "token0", nil),
+		commitmentSendBody(otherCommitment, 1, "token1", nil))))
+	response = serveRequest(t, service, r)
+	assert.Equal(t, nil, response["error"])
+	assert.Equal(t, 2, len(response["response"].([]interface{})))
+	commitments, _ = dbFake.GetClientCommitments()
+	assert.Equal(t, 2, len(commitments))
+	assert.Equal(t, testCommitment, commitments[0].Commitment.String())
+	assert.Equal(t, otherCommitment, commitments[1].Commitment.String())
+	assert.Equal(t, commitments[0].RequestId, commitments[1].RequestId)
+}
+
+// Test repeated commitment sends are deduplicated and submission status
+// reports the attestation including each submission
+func TestHandleCommitmentSubmission(t *testing.T) {
+	dbFake := db.NewDbFake()
+	dbFake.SaveClientDetails(models.ClientDetails{ClientPosition: 0, AuthToken: "token0", ClientName: "client0"})
+	dbFake.SaveClientDetails(models.ClientDetails{ClientPosition: 1, AuthToken: "token1", ClientName: "client1"})
+	service := NewRequestService(nil, nil, dbFake, confpkg.ApiConfig{})
+	otherCommitment := "2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+
+	send := func(commitment string) map[string]interface{} {
+		r, _ := http.NewRequest(POST, RouteCommitmentSend, bytes.NewReader(commitmentSendBody(commitment, 0, "token0", nil)))
+		return serveRequest(t, service, r)["response"].(map[string]interface{})
+	}
+	status := func(id string) map[string]interface{} {
+		r, _ := http.NewRequest(GET, fmt.Sprintf("/api/commitment/submission/%s/", id), nil)
+		return serveRequest(t, service, r)
+	}
+
+	// repeated commitment keeps the first submission
+	first := send(testCommitment)
+	assert.Equal(t, false, first["duplicate"])
+	repeated := send(testCommitment)
+	assert.Equal(t, true, repeated["duplicate"])
+	assert.Equal(t, first["id"], repeated["id"])
+	assert.Equal(t, first["submitted_at"], repeated["submitted_at"])
+	assert.Equal(t, 1, len(dbFake.Submissions))
+
+	// later commitment queued as latest value of the position
+	second := send(otherCommitment)
+	assert.Equal(t, false, second["duplicate"])
+	assert.NotEqual(t, first["id"], second["id"])
+	assert.Equal(t, 2, len(dbFake.Submissions))
+	commitments, _ := dbFake.GetClientCommitments()
+	assert.Equal(t, otherCommitment, commitments[0].Commitment.String())
+
+	assert.Equal(t, ErrorSubmissionNotFound, status("unknown")["error"])
+	assert.Equal(t, models.SubmissionStatusPending, status(first["id"].(string))["response"].(map[string]interface{})["status"])
+
+	// attestation of the latest commitment includes it and supersedes the first
+	commitment0, _ := chainhash.NewHashFromStr(otherCommitment)
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*commitment0})
+	txid, _ := chainhash.NewHashFromStr("4a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	attestation := models.NewAttestation(*txid, commitment)
+	dbFake.SaveAttestation(*attestation)
+	pending, _ := dbFake.GetPendingCommitmentSubmissions()
+	dbFake.SaveCommitmentSubmissions(models.ResolveCommitmentSubmissions(pending, commitment.GetMerkleCommitments()))
+
+	response := status(first["id"].(string))["response"].(map[string]interface{})
+	assert.Equal(t, models.SubmissionStatusSuperseded, response["status"])
+	assert.Equal(t, commitment.GetCommitmentHash().String(), response["merkle_root"])
+	response = status(second["id"].(string))["response"].(map[string]interface{})
+	assert.Equal(t, models.SubmissionStatusIncluded, response["status"])
+	assert.Equal(t, txid.String(), response["txid"])
+	assert.Equal(t, false, response["confirmed"])
+
+	attestation.Confirmed = true
+	dbFake.SaveAttestation(*attestation)
+	dbFake.SaveAttestationInfo(models.AttestationInfo{Txid: txid.String(), Blockhash: testCommitment})
+	assert.Equal(t, true, status(second["id"].(string))["response"].(map[string]interface{})["confirmed"])
+
+	// repeated commitment after attestation reports the including submission
+	repeated = send(otherCommitment)
+	assert.Equal(t, true, repeated["duplicate"])
+	assert.Equal(t, second["id"], repeated["id"])
+	assert.Equal(t, models.SubmissionStatusIncluded, repeated["status"])
+
+	// bulk results with the submission of each commitment
+	r, _ := http.NewRequest(POST, RouteCommitmentSendBulk, bytes.NewReader([]byte(fmt.Sprintf(
+		"{\"commitments\": [%s,%s]}", commitmentSendBody(otherCommitment, 0, "token0", nil),
+		commitmentSendBody(testCommitment, 1, "token1", nil)))))
+	results := serveRequest(t, service, r)["response"].([]interface{})
+	assert.Equal(t, second["id"], results[0].(map[string]interface{})["submission"])
+	assert.Equal(t, true, results[0].(map[string]interface{})["duplicate"])
+	assert.NotEqual(t, nil, results[1].(map[string]interface{})["submission"])
+	assert.Equal(t, nil, results[1].(map[string]interface{})["duplicate"])
+	assert.Equal(t, 3, len(dbFake.Submissions))
+}
+
+// BalanceSource fake for testing the balance route
+type balanceSourceFake struct {
+	balance models.Balance
+	isSet   bool
+}
+
+func (b balanceSourceFake) Balance() (models.Balance, bool) {
+	return b.balance, b.isSet
+}
+
+// Test balance request
+func TestHandleBalance(t *testing.T) {
+	service := NewRequestService(nil, nil, db.NewDbFake(), confpkg.ApiConfig{})
+
+	r, _ := http.NewRequest(GET, RouteBalance, nil)
+	response := serveRequest(t, service, r)
+	assert.Equal(t, ErrorBalanceUnavailable, response["error"])
+
+	service.SetBalanceSource(balanceSourceFake{})
+	response = serveRequest(t, service, r)
+	assert.Equal(t, ErrorBalanceUnavailable, response["error"])
+
+	service.SetBalanceSource(balanceSourceFake{models.Balance{
+		Value: 18000, FeePerByte: 10, AttestationFee: 2000, Runway: 9, Threshold: 10, Low: true, Time: 1}, true})
+	response = serveRequest(t, service, r)
+	assert.Equal(t, map[string]interface{}{
+		"value":           float64(18000),
+		"fee_per_byte":    float64(10),
+		"attestation_fee": float64(2000),
+		"runway":          float64(9),
+		"threshold":       float64(10),
+		"low":             true,
+		"time":            float64(1),
+	}, response["response"])
+}
+
+type feeSpendSourceFake struct {
+	spend models.FeeSpend
+	err   error
+}
+
+func (f feeSpendSourceFake) FeeSpend() (models.FeeSpend, error) {
+	return f.spend, f.err
+}
+
+// Test fees request
+func TestHandleFees(t *testing.T) {
+	service := NewRequestService(nil, nil, db.NewDbFake(), confpkg.ApiConfig{})
+
+	r, _ := http.NewRequest(GET, RouteFees, nil)
+	response := serveRequest(t, service, r)
+	assert.Equal(t, ErrorFeesUnavailable, response["error"])
+
+	service.SetFeeSpendSource(feeSpendSourceFake{err: errors.New("db failure")})
+	response = serveRequest(t, service, r)
+	assert.Equal(t, ErrorFeesGet, response["error"])
+
+	service.SetFeeSpendSource(feeSpendSourceFake{spend: models.FeeSpend{
+		Total: 60000, Day: 3000, Month: 25000, DailyBudget: 3000, Exceeded: true, Time: 1}})
+	response = serveRequest(t, service, r)
+	assert.Equal(t, map[string]interface{}{
+		"total":        float64(60000),
+		"day":          float64(3000),
+		"month":        float64(25000),
+		"daily_budget": float64(3000),
+		"exceeded":     true,
+		"time":         float64(1),
+	}, response["response"])
+}
+
+func TestHandleEvents(t *testing.T) {
+	service := NewRequestService(nil, nil, db.NewDbFake(), confpkg.ApiConfig{})
+
+	r, _ := http.NewRequest(GET, RouteEvents, nil)
+	assert.Equal(t, ErrorEventsUnavailable, serveRequest(t, service, r)["error"])
+
+	bus := notify.NewLocalBus()
+	service.SetEventSource(bus)
+	server := httptest.NewServer(service.router)
+	defer server.Close()
+
+	resp, respErr := http.Get(server.URL + RouteEvents)
+	assert.Equal(t, nil, respErr)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	for bus.Subscribers() == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	event := models.AttestationEvent{Event: models.AttestationEventBroadcast, Txid: "abc", Commitment: "def", Time: 1}
+	bus.Notify(event)
+	reader := bufio.NewReader(resp.Body)
+	line, _ := reader.ReadString('\n')
+	assert.Equal(t, "event: attestation.broadcast\n", line)
+	line, _ = reader.ReadString('\n')
+	assert.Equal(t, `data: {"event":"attestation.broadcast","txid":"abc","commitment":"def","time":1}`+"\n", line)
+
+	// subscription cancelled on disconnect
+	resp.Body.Close()
+	for bus.Subscribers() != 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+type integrityCheckerFake struct {
+	report models.IntegrityReport
+	err    error
+}
+
+func (i integrityCheckerFake) CheckIntegrity() (models.IntegrityReport, error) {
+	return i.report, i.err
+}
+
+func TestHandleIntegrity(t *testing.T) {
+	service := NewRequestService(nil, nil, db.NewDbFake(), confpkg.ApiConfig{AdminToken: "admin"})
+
+	r, _ := http.NewRequest(GET, RouteAdminIntegrity, nil)
+	assert.Equal(t, ErrorAdminUnauthorized, serveRequest(t, service, r)["error"])
+	r.Header.Set(HeaderAuthorization, "Bearer admin")
+	assert.Equal(t, ErrorIntegrityUnavailable, serveRequest(t, service, r)["error"])
+
+	service.SetIntegrityChecker(integrityCheckerFake{err: errors.New("rpc down")})
+	assert.Equal(t, ErrorIntegrityCheck, serveRequest(t, service, r)["error"])
+
+	service.SetIntegrityChecker(integrityCheckerFake{report: models.IntegrityReport{Passed: true, Rounds: 2, Time: 1}})
+	assert.Equal(t, map[string]interface{}{
+		"passed": true,
+		"rounds": float64(2),
+		"time":   float64(1),
+	}, serveRequest(t, service, r)["response"])
+
+	service.SetIntegrityChecker(integrityCheckerFake{report: models.IntegrityReport{Passed: false, Rounds: 2,
+		Inconsistent: &models.IntegrityRound{Round: 2, Txid: "txid", MerkleRoot: "root", Reason: "reason"}, Time: 1}})
+	assert.Equal(t, map[string]interface{}{
+		"passed": false,
+		"rounds": float64(2),
+		"inconsistent": map[string]interface{}{
+			"round":       float64(2),
+			"txid":        "txid",
+			"merkle_root": "root",
+			"reason":      "reason",
+		},
+		"time": float64(1),
+	}, serveRequest(t, service, r)["response"])
+}
+
+type attestDeriverFake struct {
+	derivation models.AttestationDerivation
+	err        error
+}
+
+func (d attestDeriverFake) DeriveAttestation(txid string) (models.AttestationDerivation, error) {
+	if d.err != nil {
+		return models.AttestationDerivation{}, d.err
+	}
+	d.derivation.Txid = txid
+	return d.derivation, nil
+}
+
+func TestHandleDerivationHistory(t *testing.T) {
+	service := NewRequestService(nil, nil, db.NewDbFake(), confpkg.ApiConfig{})
+
+	r, _ := http.NewRequest(GET, "/derivation/history/abc/", nil)
+	assert.Equal(t, ErrorDeriveUnavailable, serveRequest(t, service, r)["error"])
+
+	service.SetAttestDeriver(attestDeriverFake{err: errors.New("Attestation not found")})
+	assert.Equal(t, "Attestation not found", serveRequest(t, service, r)["error"])
+
+	service.SetAttestDeriver(attestDeriverFake{derivation: models.AttestationDerivation{
+		Commitment: "root", Tweak: "toor", Path: "m/1/2",
+		BaseKeys:   []models.DerivationKey{{Pubkey: "pub", Chaincode: "cc", Derived: "derived"}},
+		BaseScript: "script0", Script: "script", Address: "addr"}})
+	assert.Equal(t, map[string]interface{}{
+		"txid":       "abc",
+		"commitment": "root",
+		"tweak":      "toor",
+		"path":       "m/1/2",
+		"base_keys": []interface{}{map[string]interface{}{
+			"pubkey":    "pub",
+			"chaincode": "cc",
+			"derived":   "derived",
+		}},
+		"base_script": "script0",
+		"script":      "script",
+		"address":     "addr",
+	}, serveRequest(t, service, r)["response"])
+}
+
+type healthCheckerFake struct {
+	live  models.HealthReport
+	ready models.HealthReport
+}
+
+func (h healthCheckerFake) Liveness() models.HealthReport {
+	return h.live
+}
+
+func (h healthCheckerFake) Readiness() models.HealthReport {
+	return h.ready
+}
+
+func TestHandleHealth(t *testing.T) {
+	service := NewRequestService(nil, nil, db.NewDbFake(), confpkg.ApiConfig{})
+
+	status, response := serveRouter(service.router, httptest.NewRequest(GET, "/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, ErrorHealthUnavailable, response["error"])
+
+	service.SetHealthChecker(healthCheckerFake{
+		live: models.HealthReport{Healthy: true, Checks: map[string]string{"attestation": "ok"},
+			LastTransition: 1, Time: 2},
+		ready: models.HealthReport{Healthy: false, Checks: map[string]string{"attestation": "ok", "mongodb": "down"},
+			LastTransition: 1, Time: 2}})
+
+	status, response = serveRouter(service.router, httptest.NewRequest(GET, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]interface{}{
+		"healthy":         true,
+		"checks":          map[string]interface{}{"attestation": "ok"},
+		"last_transition": float64(1),
+		"paused":          false,
+		"syncing":         false,
+		"time":            float64(2),
+	}, response["response"])
+
+	status, response = serveRouter(service.router, httptest.NewRequest(GET, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, map[string]interface{}{"attestation": "ok", "mongodb": "down"},
+		response["response"].(map[string]interface{})["checks"])
+}
+
+// Test slot group registration, commitments and two-level proofs
+func TestHandleSlotGroup(t *testing.T) {
+	dbFake := db.NewDbFake()
+	dbFake.SaveClientDetails(models.ClientDetails{ClientPosition: 0, AuthToken: "token0", ClientName: "client0"})
+	dbFake.SaveClientDetails(models.ClientDetails{ClientPosition: 1, AuthToken: "token1", ClientName: "reseller"})
+	service := NewRequestService(nil, nil, dbFake, confpkg.ApiConfig{AdminToken: "admin"})
+
+	member0, _ := chainhash.NewHashFromStr("2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	member1, _ := chainhash.NewHashFromStr("3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	group, _ := models.NewSlotGroup(1, []chainhash.Hash{*member0, *member1})
+	members := []string{member0.String(), member1.String()}
+
+	// members only accepted for slot groups
+	r, _ := http.NewRequest(POST, RouteCommitmentSend,
+		bytes.NewReader(groupCommitmentSendBody(group.MerkleRoot.String(), 1, "token1", members)))
+	assert.Equal(t, ErrorSlotGroupNotGroup, serveRequest(t, service, r)["error"])
+
+	r, _ = http.NewRequest(POST, "/admin/client/1/group/", nil)
+	r.Header.Set(HeaderAuthorization, "Bearer admin")
+	assert.Equal(t, map[string]interface{}{"client_position": float64(1), "slot_group": true},
+		serveRequest(t, service, r)["response"])
+
+	// slot group commitments require matching members
+	r, _ = http.NewRequest(POST, RouteCommitmentSend,
+		bytes.NewReader(commitmentSendBody(group.MerkleRoot.String(), 1, "token1", nil)))
+	assert.Equal(t, ErrorSlotGroupMembers, serveRequest(t, service, r)["error"])
+	r, _ = http.NewRequest(POST, RouteCommitmentSend,
+		bytes.NewReader(groupCommitmentSendBody(testCommitment, 1, "token1", members)))
+	assert.Equal(t, ErrorSlotGroupMismatch, serveRequest(t, service, r)["error"])
+	r, _ = http.NewRequest(POST, RouteCommitmentSend,
+		bytes.NewReader(groupCommitmentSendBody(group.MerkleRoot.String(), 1, "token1", members)))
+	assert.Equal(t, models.SubmissionStatusPending, serveRequest(t, service, r)["response"].(map[string]interface{})["status"])
+	assert.Equal(t, []models.SlotGroup{*group}, dbFake.SlotGroups)
+
+	// proof pending until group root attested
+	proofRoute := fmt.Sprintf("/api/group/proof/1/%s/", member1.String())
+	r, _ = http.NewRequest(GET, proofRoute, nil)
+	assert.Equal(t, ErrorSlotGroupPending, serveRequest(t, service, r)["error"])
+
+	commitment0, _ := chainhash.NewHashFromStr(testCommitment)
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*commitment0, group.MerkleRoot})
+	dbFake.SaveMerkleProofs(commitment.GetMerkleProofs())
+
+	response := serveRequest(t, service, r)["response"].(map[string]interface{})
+	assert.Equal(t, float64(models.ProofBundleVersion), response["version"])
+	assert.Equal(t, float64(1), response["slot"])
+	assert.Equal(t, member1.String(), response["commitment"])
+	assert.Equal(t, commitment.GetCommitmentHash().String(), response["root"])
+	assert.Equal(t, map[string]interface{}{"root": group.MerkleRoot.String(), "member_position": float64(1),
+		"member_ops": float64(1)}, response["group"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"append": false, "commitment": member0.String()},
+		map[string]interface{}{"append": false, "commitment": testCommitment}}, response["ops"])
+	assert.Equal(t, "", response["txid"])
+	assert.Equal(t, nil, response["block"])
+
+	r, _ = http.NewRequest(GET, fmt.Sprintf("/api/group/proof/1/%s/", testCommitment), nil)
+	assert.Equal(t, ErrorSlotGroupNotFound, serveRequest(t, service, r)["error"])
+}
+
+// Test commitment proof bundles and proof schema
+func TestHandleCommitmentProof(t *testing.T) {
+	dbFake := db.NewDbFake()
+	service := NewRequestService(nil, nil, dbFake, confpkg.ApiConfig{})
+
+	commitment0, _ := chainhash.NewHashFromStr(testCommitment)
+	commitment1, _ := chainhash.NewHashFromStr("3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*commitment0, *commitment1})
+
+	r, _ := http.NewRequest(GET, fmt.Sprintf("/api/commitment/proof/x/%s/", testCommitment), nil)
+	assert.Equal(t, ErrorAdminPositionInvalid, serveRequest(t, service, r)["error"])
+	r, _ = http.NewRequest(GET, "/api/commitment/proof/0/xyz/", nil)
+	assert.Equal(t, ErrorCommitmentInvalid, serveRequest(t, service, r)["error"])
+
+	// proof pending until commitment attested
+	r, _ = http.NewRequest(GET, fmt.Sprintf("/api/commitment/proof/1/%s/", commitment1.String()), nil)
+	assert.Equal(t, ErrorProofPending, serveRequest(t, service, r)["error"])
+
+	// unconfirmed attestation proof without block
+	dbFake.SaveMerkleProofs(commitment.GetMerkleProofs())
+	txid, _ := chainhash.NewHashFromStr("4a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	attestation := models.NewAttestation(*txid, commitment)
+	dbFake.SaveAttestation(*attestation)
+	response := serveRequest(t, service, r)["response"].(map[string]interface{})
+	assert.Equal(t, txid.String(), response["txid"])
+	assert.Equal(t, nil, response["block"])
+
+	// confirmed attestation proof with block
+	attestation.Confirmed = true
+	dbFake.SaveAttestation(*attestation)
+	dbFake.SaveAttestationInfo(models.AttestationInfo{Txid: txid.String(), Blockhash: testCommitment, Time: 1542121293})
+	response = serveRequest(t, service, r)["response"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"version":    float64(models.ProofBundleVersion),
+		"slot":       float64(1),
+		"commitment": commitment1.String(),
+		"ops":        []interface{}{map[string]interface{}{"append": false, "commitment": testCommitment}},
+		"root":       commitment.GetCommitmentHash().String(),
+		"txid":       txid.String(),
+		"block":      map[string]interface{}{"hash": testCommitment, "time": float64(1542121293)},
+		"params": map[string]interface{}{"protocol": "mainstay", "hash": "sha256d", "encoding": "hex_reversed",
+			"ops": "append"},
+	}, response)
+
+	// response decodes to a verifiable proof bundle
+	var bundle models.ProofBundle
+	encoded, _ := json.Marshal(response)
+	assert.Equal(t, nil, json.Unmarshal(encoded, &bundle))
+	assert.Equal(t, nil, models.VerifyProofBundle(bundle))
+
+	// schema published as is
+	r, _ = http.NewRequest(GET, RouteProofSchema, nil)
+	writer := httptest.NewRecorder()
+	service.router.ServeHTTP(writer, r)
+	assert.Equal(t, http.StatusOK, writer.Code)
+	assert.Equal(t, "application/schema+json", writer.Header().Get("Content-Type"))
+	assert.Equal(t, models.ProofBundleSchema, writer.Body.Bytes())
+
+	// protocol declares the proof ops encoding
+	r, _ = http.NewRequest(GET, RouteProtocol, nil)
+	assert.Equal(t, map[string]interface{}{"version": float64(models.ProofBundleVersion), "protocol": "mainstay",
+		"hash": "sha256d", "encoding": "hex_reversed", "ops": "append",
+		"ordering": map[string]interface{}{"strategy": "position"}}, serveRequest(t, service, r)["response"])
+
+	// positional ops encoding without append flags
+	service = NewRequestService(nil, nil, dbFake, confpkg.ApiConfig{ProofOps: models.ProofOpsPosition})
+	r, _ = http.NewRequest(GET, fmt.Sprintf("/api/commitment/proof/1/%s/", commitment1.String()), nil)
+	response = serveRequest(t, service, r)["response"].(map[string]interface{})
+	assert.Equal(t, []interface{}{map[string]interface{}{"commitment": testCommitment}}, response["ops"])
+	assert.Equal(t, "position", response["params"].(map[string]interface{})["ops"])
+	var positionBundle models.ProofBundle
+	encoded, _ = json.Marshal(response)
+	assert.Equal(t, nil, json.Unmarshal(encoded, &positionBundle))
+	assert.Equal(t, nil, models.VerifyProofBundle(positionBundle))
+
+	r, _ = http.NewRequest(GET, RouteProtocol, nil)
+	assert.Equal(t, "position", serveRequest(t, service, r)["response"].(map[string]interface{})["ops"])
+	service = NewRequestService(nil, nil, dbFake, confpkg.ApiConfig{ProofOps: "bits"})
+	assert.Equal(t, "append", serveRequest(t, service, r)["response"].(map[string]interface{})["ops"])
+}
+
+// Test proofs of commitments ordered by a fixed position map
+func TestHandleCommitmentProofOrdering(t *testing.T) {
+	dbFake := db.NewDbFake()
+	service := NewRequestService(nil, nil, dbFake, confpkg.ApiConfig{ProofOps: models.ProofOpsPosition})
+	ordering, _ := models.NewCommitmentOrdering(models.OrderingMap, map[int32]int32{0: 2, 3: 0}, 0, nil)
+	service.SetCommitmentOrdering(ordering)
+
+	commitment0, _ := chainhash.NewHashFromStr(testCommitment)
+	commitment3, _ := chainhash.NewHashFromStr("3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := ordering.Commitment([]models.ClientCommitment{
+		models.ClientCommitment{Commitment: *commitment0, ClientPosition: 0},
+		models.ClientCommitment{Commitment: *commitment3, ClientPosition: 3}}, "")
+	dbFake.SaveMerkleProofs(commitment.GetMerkleProofs())
+	txid, _ := chainhash.NewHashFromStr("4a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	dbFake.SaveAttestation(*models.NewAttestation(*txid, commitment))
+
+	// slots proven at their mapped leaves
+	for position, leaf := range map[int32]float64{0: 2, 3: 0} {
+		r, _ := http.NewRequest(GET, fmt.Sprintf("/api/commitment/proof/%d/%s/", position,
+			map[int32]string{0: commitment0.String(), 3: commitment3.String()}[position]), nil)
+		response := serveRequest(t, service, r)["response"].(map[string]interface{})
+		assert.Equal(t, float64(position), response["slot"])
+		assert.Equal(t, leaf, response["leaf"])
+
+		var bundle models.ProofBundle
+		encoded, _ := json.Marshal(response)
+		assert.Equal(t, nil, json.Unmarshal(encoded, &bundle))
+		assert.Equal(t, nil, models.VerifyProofBundle(bundle))
+		assert.Equal(t, nil, ordering.VerifyLeaf(bundle.Slot, bundle.SlotLeaf()))
+	}
+
+	// protocol declares the ordering
+	r, _ := http.NewRequest(GET, RouteProtocol, nil)
+	assert.Equal(t, map[string]interface{}{"strategy": "map",
+		"positions": map[string]interface{}{"0": float64(2), "3": float64(0)}},
+		serveRequest(t, service, r)["response"].(map[string]interface{})["ordering"])
+}
+
+// Test protocol transition and preview proofs of dual running rounds
+func TestHandleCommitmentProofTransition(t *testing.T) {
+	dbFake := db.NewDbFake()
+	service := NewRequestService(nil, nil, dbFake, confpkg.ApiConfig{})
+
+	commitment0, _ := chainhash.NewHashFromStr(testCommitment)
+	commitment1, _ := chainhash.NewHashFromStr("3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*commitment0, *commitment1})
+	dbFake.SaveMerkleCommitments(commitment.GetMerkleCommitments())
+	dbFake.SaveMerkleProofs(commitment.GetMerkleProofs())
+	txid, _ := chainhash.NewHashFromStr("4a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	attestation := models.NewAttestation(*txid, commitment)
+	attestation.Confirmed = true
+	dbFake.SaveAttestation(*attestation)
+
+	// next format proofs unavailable without transition
+	route := fmt.Sprintf("/api/commitment/proof/1/%s/", commitment1.String())
+	r, _ := http.NewRequest(GET, route+"?hash=sha256", nil)
+	assert.Equal(t, ErrorProofHashUnavailable+": sha256", serveRequest(t, service, r)["error"])
+	r, _ = http.NewRequest(GET, route+"?hash=sha256d", nil)
+	response := serveRequest(t, service, r)["response"].(map[string]interface{})
+	assert.Equal(t, "sha256d", response["params"].(map[string]interface{})["hash"])
+
+	// second round dual running before the switchover
+	transition, _ := models.NewProtocolTransition(models.MerkleHashSha256, 3, 1)
+	service.SetTransition(transition)
+	r, _ = http.NewRequest(GET, RouteProtocol, nil)
+	response = serveRequest(t, service, r)["response"].(map[string]interface{})
+	assert.Equal(t, "sha256d", response["hash"])
+	assert.Equal(t, map[string]interface{}{"hash": "sha256", "switchover_round": float64(3), "rounds": float64(1),
+		"round": float64(2), "dual_running": true}, response["transition"])
+
+	r, _ = http.NewRequest(GET, route+"?hash=sha256", nil)
+	response = serveRequest(t, service, r)["response"].(map[string]interface{})
+	var bundle models.ProofBundle
+	encoded, _ := json.Marshal(response)
+	assert.Equal(t, nil, json.Unmarshal(encoded, &bundle))
+	assert.Equal(t, nil, models.VerifyProofBundle(bundle))
+	assert.Equal(t, models.ProofBundleParams{Protocol: "mainstay", Hash: "sha256", Encoding: "hex_reversed",
+		Ops: "append", Preview: true}, bundle.Params)
+	expected, _ := models.NewCommitmentHash([]chainhash.Hash{*commitment0, *commitment1}, models.MerkleHashSha256)
+	assert.Equal(t, expected.GetCommitmentHash().String(), bundle.Root)
+	assert.Equal(t, txid.String(), bundle.Txid)
+
+	r, _ = http.NewRequest(GET, route+"?hash=sha256tagged", nil)
+	assert.Equal(t, ErrorProofHashUnavailable+": sha256tagged", serveRequest(t, service, r)["error"])
+
+	// no preview proofs from the switchover round
+	txid2, _ := chainhash.NewHashFromStr("5a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	attestation2 := models.NewAttestation(*txid2, expected)
+	attestation2.Confirmed = true
+	dbFake.SaveAttestation(*attestation2)
+	r, _ = http.NewRequest(GET, RouteProtocol, nil)
+	response = serveRequest(t, service, r)["response"].(map[string]interface{})
+	assert.Equal(t, "sha256", response["hash"])
+	assert.Equal(t, false, response["transition"].(map[string]interface{})["dual_running"])
+	r, _ = http.NewRequest(GET, route+"?hash=sha256", nil)
+	assert.Equal(t, ErrorProofHashUnavailable+": sha256", serveRequest(t, service, r)["error"])
+}
+
+// Test compact binary proofs of client commitments
+func TestHandleCommitmentProofBinary(t *testing.T) {
+	dbFake := db.NewDbFake()
+	service := NewRequestService(nil, nil, dbFake, confpkg.ApiConfig{})
+
+	commitment0, _ := chainhash.NewHashFromStr(testCommitment)
+	commitment1, _ := chainhash.NewHashFromStr("3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*commitment0, *commitment1})
+
+	r, _ := http.NewRequest(GET, "/api/commitment/proof/0/xyz/binary/", nil)
+	assert.Equal(t, ErrorCommitmentInvalid, serveRequest(t, service, r)["error"])
+	r, _ = http.NewRequest(GET, fmt.Sprintf("/api/commitment/proof/1/%s/binary/", commitment1.String()), nil)
+	assert.Equal(t, ErrorProofPending, serveRequest(t, service, r)["error"])
+
+	dbFake.SaveMerkleProofs(commitment.GetMerkleProofs())
+	txid, _ := chainhash.NewHashFromStr("4a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	attestation := models.NewAttestation(*txid, commitment)
+	attestation.Confirmed = true
+	dbFake.SaveAttestation(*attestation)
+	dbFake.SaveAttestationInfo(models.AttestationInfo{Txid: txid.String(), Blockhash: testCommitment, Time: 1542121293})
+	response := serveRequest(t, service, r)["response"].(map[string]interface{})
+	assert.Equal(t, txid.String(), response["txid"])
+	assert.Equal(t, true, response["confirmed"])
+
+	proof, proofErr := models.DecodeProofBase64(response["proof"].(string))
+	assert.Equal(t, nil, proofErr)
+	assert.Equal(t, commitment.GetMerkleProofs()[1], proof)
+}
+
+// Test latest confirmed proof of client positions
+func TestHandleLatestProof(t *testing.T) {
+	dbFake := db.NewDbFake()
+	service := NewRequestService(nil, nil, dbFake, confpkg.ApiConfig{})
+
+	commitment0, _ := chainhash.NewHashFromStr(testCommitment)
+	commitment1, _ := chainhash.NewHashFromStr("3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment2, _ := chainhash.NewHashFromStr("5a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	r, _ := http.NewRequest(GET, "/api/position/x/latestproof/", nil)
+	assert.Equal(t, ErrorAdminPositionInvalid, serveRequest(t, service, r)["error"])
+
+	// no proof until an attestation is confirmed
+	r, _ = http.NewRequest(GET, "/api/position/1/latestproof/", nil)
+	assert.Equal(t, ErrorLatestProofPending, serveRequest(t, service, r)["error"])
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*commitment0, *commitment1})
+	dbFake.SaveMerkleProofs(commitment.GetMerkleProofs())
+	txid, _ := chainhash.NewHashFromStr("4a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	attestation := models.NewAttestation(*txid, commitment)
+	dbFake.SaveAttestation(*attestation)
+	assert.Equal(t, ErrorLatestProofPending, serveRequest(t, service, r)["error"])
+
+	// confirmed attestation proof with block hash and height
+	attestation.Confirmed = true
+	dbFake.SaveAttestation(*attestation)
+	dbFake.SaveAttestationInfo(models.AttestationInfo{Txid: txid.String(), Blockhash: testCommitment,
+		Time: 1542121293, Height: 1000})
+	response := serveRequest(t, service, r)["response"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"version":    float64(models.ProofBundleVersion),
+		"slot":       float64(1),
+		"commitment": commitment1.String(),
+		"ops":        []interface{}{map[string]interface{}{"append": false, "commitment": testCommitment}},
+		"root":       commitment.GetCommitmentHash().String(),
+		"txid":       txid.String(),
+		"block": map[string]interface{}{"hash": testCommitment, "time": float64(1542121293),
+			"height": float64(1000)},
+		"params": map[string]interface{}{"protocol": "mainstay", "hash": "sha256d", "encoding": "hex_reversed",
+			"ops": "append"},
+	}, response)
+	var bundle models.ProofBundle
+	encoded, _ := json.Marshal(response)
+	assert.Equal(t, nil, json.Unmarshal(encoded, &bundle))
+	assert.Equal(t, nil, models.VerifyProofBundle(bundle))
+
+	// proof of the latest confirmed attestation only
+	nextCommitment, _ := models.NewCommitment([]chainhash.Hash{*commitment0, *commitment2})
+	dbFake.SaveMerkleProofs(nextCommitment.GetMerkleProofs())
+	nextTxid, _ := chainhash.NewHashFromStr("6a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	nextAttestation := models.NewAttestation(*nextTxid, nextCommitment)
+	dbFake.SaveAttestation(*nextAttestation)
+	response = serveRequest(t, service, r)["response"].(map[string]interface{})
+	assert.Equal(t, commitment1.String(), response["commitment"])
+	assert.Equal(t, txid.String(), response["txid"])
+
+	nextAttestation.Confirmed = true
+	dbFake.SaveAttestation(*nextAttestation)
+	dbFake.SaveAttestationInfo(models.AttestationInfo{Txid: nextTxid.String(), Blockhash: testCommitment,
+		Time: 1542122293, Height: 1006})
+	response = serveRequest(t, service, r)["response"].(map[string]interface{})
+	assert.Equal(t, commitment2.String(), response["commitment"])
+	assert.Equal(t, nextCommitment.GetCommitmentHash().String(), response["root"])
+	assert.Equal(t, nextTxid.String(), response["txid"])
+	assert.Equal(t, float64(1006), response["block"].(map[string]interface{})["height"])
+
+	// no proof for positions outside the merkle tree
+	r, _ = http.NewRequest(GET, "/api/position/5/latestproof/", nil)
+	assert.Equal(t, ErrorLatestProofPending, serveRequest(t, service, r)["error"])
+}
+
+type spvSourceFake struct {
+	err error
+}
+
+func (f *spvSourceFake) SpvProof(txid string, blockhash string) (models.SpvProof, error) {
+	if f.err != nil {
+		return models.SpvProof{}, f.err
+	}
+	return models.SpvProof{Header: blockhash, MerkleBlock: txid}, nil
+}
+
+// Test spv proofs of confirmed attestations included in proof bundles
+func TestHandleCommitmentProofSpv(t *testing.T) {
+	dbFake := db.NewDbFake()
+	service := NewRequestService(nil, nil, dbFake, confpkg.ApiConfig{})
+	spvSource := &spvSourceFake{}
+	service.SetSpvSource(spvSource)
+
+	commitment0, _ := chainhash.NewHashFromStr(testCommitment)
+	commitment1, _ := chainhash.NewHashFromStr("3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*commitment0, *commitment1})
+	dbFake.SaveMerkleProofs(commitment.GetMerkleProofs())
+	txid, _ := chainhash.NewHashFromStr("4a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	attestation := models.NewAttestation(*txid, commitment)
+	dbFake.SaveAttestation(*attestation)
+
+	// no spv proof until confirmed
+	r, _ := http.NewRequest(GET, fmt.Sprintf("/api/commitment/proof/1/%s/", commitment1.String()), nil)
+	response := serveRequest(t, service, r)["response"].(map[string]interface{})
+	assert.Equal(t, nil, response["block"])
+
+	attestation.Confirmed = true
+	dbFake.SaveAttestation(*attestation)
+	dbFake.SaveAttestationInfo(models.AttestationInfo{Txid: txid.String(), Blockhash: testCommitment, Time: 1542121293})
+	spv := map[string]interface{}{"header": testCommitment, "merkle_block": txid.String()}
+	response = serveRequest(t, service, r)["response"].(map[string]interface{})
+	assert.Equal(t, spv, response["block"].(map[string]interface{})["spv"])
+	r, _ = http.NewRequest(GET, "/api/position/1/latestproof/", nil)
+	response = serveRequest(t, service, r)["response"].(map[string]interface{})
+	assert.Equal(t, spv, response["block"].(map[string]interface{})["spv"])
+
+	// spv proof omitted if not available
+	spvSource.err = errors.New("block not found")
+	response = serveRequest(t, service, r)["response"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"hash": testCommitment, "time": float64(1542121293)}, response["block"])
+}
+
+type timestamperFake struct {
+	bundles []models.ProofBundle
+}
+
+func (f *timestamperFake) TimestampResponse(bundle models.ProofBundle) ([]byte, error) {
+	f.bundles = append(f.bundles, bundle)
+	return []byte{0x30, 0x03, 0x30, 0x01, 0x00}, nil
+}
+
+// Test commitment timestamp responses of confirmed proofs
+func TestHandleCommitmentTimestamp(t *testing.T) {
+	dbFake := db.NewDbFake()
+	service := NewRequestService(nil, nil, dbFake, confpkg.ApiConfig{})
+
+	commitment0, _ := chainhash.NewHashFromStr(testCommitment)
+	commitment1, _ := chainhash.NewHashFromStr("3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*commitment0, *commitment1})
+
+	r, _ := http.NewRequest(GET, fmt.Sprintf("/api/commitment/timestamp/1/%s/", commitment1.String()), nil)
+	assert.Equal(t, ErrorTimestampUnavailable, serveRequest(t, service, r)["error"])
+
+	timestamper := &timestamperFake{}
+	service.SetTimestamper(timestamper)
+	assert.Equal(t, ErrorProofPending, serveRequest(t, service, r)["error"])
+
+	// timestamps unavailable until attestation confirmed
+	dbFake.SaveMerkleProofs(commitment.GetMerkleProofs())
+	txid, _ := chainhash.NewHashFromStr("4a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	attestation := models.NewAttestation(*txid, commitment)
+	dbFake.SaveAttestation(*attestation)
+	assert.Equal(t, ErrorTimestampPending, serveRequest(t, service, r)["error"])
+	assert.Equal(t, 0, len(timestamper.bundles))
+
+	attestation.Confirmed = true
+	dbFake.SaveAttestation(*attestation)
+	dbFake.SaveAttestationInfo(models.AttestationInfo{Txid: txid.String(), Blockhash: testCommitment, Time: 1542121293})
+	writer := httptest.NewRecorder()
+	service.router.ServeHTTP(writer, r)
+	assert.Equal(t, http.StatusOK, writer.Code)
+	assert.Equal(t, "application/timestamp-reply", writer.Header().Get("Content-Type"))
+	assert.Equal(t, []byte{0x30, 0x03, 0x30, 0x01, 0x00}, writer.Body.Bytes())
+
+	// timestamped bundle is the commitment proof bundle
+	assert.Equal(t, 1, len(timestamper.bundles))
+	assert.Equal(t, commitment1.String(), timestamper.bundles[0].Commitment)
+	assert.Equal(t, &models.ProofBundleBlock{Hash: testCommitment, Time: 1542121293}, timestamper.bundles[0].Block)
+	assert.Equal(t, nil, models.VerifyProofBundle(timestamper.bundles[0]))
+}
+
+type attestTriggerFake struct {
+	triggers int
+}
+
+func (a *attestTriggerFake) AttestNow() {
+	a.triggers++
+}
+
+// Test admin attest now request
+func TestHandleAdminAttest(t *testing.T) {
+	service := NewRequestService(nil, nil, db.NewDbFake(), confpkg.ApiConfig{AdminToken: "admin"})
+
+	r, _ := http.NewRequest(POST, RouteAdminAttest, nil)
+	assert.Equal(t, ErrorAdminUnauthorized, serveRequest(t, service, r)["error"])
+	r.Header.Set(HeaderAuthorization, "Bearer admin")
+	assert.Equal(t, ErrorAttestUnavailable, serveRequest(t, service, r)["error"])
+
+	trigger := &attestTriggerFake{}
+	service.SetAttestTrigger(trigger)
+	assert.Equal(t, "Attestation triggered", serveRequest(t, service, r)["response"])
+	assert.Equal(t, 1, trigger.triggers)
+
+	r.Header.Set(HeaderAuthorization, "Bearer wrong")
+	assert.Equal(t, ErrorAdminUnauthorized, serveRequest(t, service, r)["error"])
+	assert.Equal(t, 1, trigger.triggers)
+}
+
+type attestPauserFake struct {
+	paused bool
+}
+
+func (a *attestPauserFake) Pause() error {
+	a.paused = true
+	return nil
+}
+
+func (a *attestPauserFake) Resume() error {
+	a.paused = false
+	return nil
+}
+
+func (a *attestPauserFake) IsPaused() bool {
+	return a.paused
+}
+
+// Test admin pause and resume requests
+func TestHandleAdminPause(t *testing.T) {
+	service := NewRequestService(nil, nil, db.NewDbFake(), confpkg.ApiConfig{AdminToken: "admin"})
+
+	r, _ := http.NewRequest(POST, RouteAdminPause, nil)
+	assert.Equal(t, ErrorAdminUnauthorized, serveRequest(t, service, r)["error"])
+	r.Header.Set(HeaderAuthorization, "Bearer admin")
+	assert.Equal(t, ErrorPauseUnavailable, serveRequest(t, service, r)["error"])
+
+	pauser := &attestPauserFake{}
+	service.SetAttestPauser(pauser)
+	assert.Equal(t, map[string]interface{}{"paused": true}, serveRequest(t, service, r)["response"])
+	assert.Equal(t, true, pauser.paused)
+
+	r, _ = http.NewRequest(POST, RouteAdminResume, nil)
+	r.Header.Set(HeaderAuthorization, "Bearer admin")
+	assert.Equal(t, map[string]interface{}{"paused": false}, serveRequest(t, service, r)["response"])
+	assert.Equal(t, false, pauser.paused)
+}
+
+type attestReviewerFake struct {
+	review *models.AttestationReview
+}
+
+func (a *attestReviewerFake) PendingReview() (models.AttestationReview, bool) {
+	if a.review == nil {
+		return models.AttestationReview{}, false
+	}
+	return *a.review, true
+}
+
+func (a *attestReviewerFake) VetoReview(txid string) (models.AttestationReview, error) {
+	if a.review == nil || (txid != "" && txid != a.review.Txid) {
+		return models.AttestationReview{}, errors.New("no review")
+	}
+	a.review.Vetoed = true
+	return *a.review, nil
+}
+
+// Test admin review and veto requests
+func TestHandleAdminReview(t *testing.T) {
+	service := NewRequestService(nil, nil, db.NewDbFake(), confpkg.ApiConfig{AdminToken: "admin"})
+
+	r, _ := http.NewRequest(GET, RouteAdminReview, nil)
+	assert.Equal(t, ErrorAdminUnauthorized, serveRequest(t, service, r)["error"])
+	r.Header.Set(HeaderAuthorization, "Bearer admin")
+	assert.Equal(t, ErrorReviewUnavailable, serveRequest(t, service, r)["error"])
+
+	reviewer := &attestReviewerFake{}
+	service.SetAttestReviewer(reviewer)
+	assert.Equal(t, map[string]interface{}{"pending": false}, serveRequest(t, service, r)["response"])
+
+	reviewer.review = &models.AttestationReview{Txid: "abc", Commitment: "def", Tx: "00", Start: 1, Deadline: 2}
+	review := map[string]interface{}{"txid": "abc", "commitment": "def", "tx": "00",
+		"start": float64(1), "deadline": float64(2), "vetoed": false}
+	assert.Equal(t, map[string]interface{}{"pending": true, "review": review}, serveRequest(t, service, r)["response"])
+
+	// veto with mismatching txid fails
+	r, _ = http.NewRequest(POST, RouteAdminReviewVeto+"?txid=xyz", nil)
+	assert.Equal(t, ErrorAdminUnauthorized, serveRequest(t, service, r)["error"])
+	r.Header.Set(HeaderAuthorization, "Bearer admin")
+	assert.Equal(t, "no review", serveRequest(t, service, r)["error"])
+	assert.Equal(t, false, reviewer.review.Vetoed)
+
+	r, _ = http.NewRequest(POST, RouteAdminReviewVeto+"?txid=abc", nil)
+	r.Header.Set(HeaderAuthorization, "Bearer admin")
+	review["vetoed"] = true
+	assert.Equal(t, map[string]interface{}{"pending": true, "review": review}, serveRequest(t, service, r)["response"])
+	assert.Equal(t, true, reviewer.review.Vetoed)
+}
+
+type keyRotatorFake struct {
+	dbFake *db.DbFake
+}
+
+func (k *keyRotatorFake) RequestKeyRotation(request models.KeyRotation) (models.KeyRotation, error) {
+	if request.InitScript == "" {
+		return models.KeyRotation{}, errors.New("invalid script")
+	}
+	request.Id = 1
+	request.Status = models.KeyRotationPending
+	return request, k.dbFake.SaveKeyRotation(request)
+}
+
+func (k *keyRotatorFake) CancelKeyRotation() (models.KeyRotation, error) {
+	rotations, _ := k.dbFake.GetKeyRotations()
+	if len(rotations) == 0 || rotations[0].Status != models.KeyRotationPending {
+		return models.KeyRotation{}, errors.New("no rotation")
+	}
+	rotations[0].Status = models.KeyRotationCancelled
+	return rotations[0], k.dbFake.SaveKeyRotation(rotations[0])
+}
+
+// Test admin key rotation requests and published rotations
+func TestHandleKeyRotation(t *testing.T) {
+	dbFake := db.NewDbFake()
+	service := NewRequestService(nil, nil, dbFake, confpkg.ApiConfig{AdminToken: "admin"})
+
+	body := []byte(`{"init_script": "51ae", "init_chaincodes": ["00"]}`)
+	r, _ := http.NewRequest(POST, RouteAdminRotation, bytes.NewReader(body))
+	assert.Equal(t, ErrorAdminUnauthorized, serveRequest(t, service, r)["error"])
+	r, _ = http.NewRequest(POST, RouteAdminRotation, bytes.NewReader(body))
+	r.Header.Set(HeaderAuthorization, "Bearer admin")
+	assert.Equal(t, ErrorRotationUnavailable, serveRequest(t, service, r)["error"])
+
+	service.SetKeyRotator(&keyRotatorFake{dbFake})
+	r, _ = http.NewRequest(POST, RouteAdminRotation, bytes.NewReader([]byte("{")))
+	r.Header.Set(HeaderAuthorization, "Bearer admin")
+	assert.Equal(t, ErrorRequestPayload, serveRequest(t, service, r)["error"])
+	r, _ = http.NewRequest(POST, RouteAdminRotation, bytes.NewReader([]byte("{}")))
+	r.Header.Set(HeaderAuthorization, "Bearer admin")
+	assert.Equal(t, "invalid script", serveRequest(t, service, r)["error"])
+
+	r, _ = http.NewRequest(POST, RouteAdminRotation, bytes.NewReader(body))
+	r.Header.Set(HeaderAuthorization, "Bearer admin")
+	rotation := serveRequest(t, service, r)["response"].(map[string]interface{})
+	assert.Equal(t, "51ae", rotation["init_script"])
+	assert.Equal(t, models.KeyRotationPending, rotation["status"])
+
+	// pending rotations are listed for admins but not published
+	r, _ = http.NewRequest(GET, RouteAdminRotation, nil)
+	assert.Equal(t, ErrorAdminUnauthorized, serveRequest(t, service, r)["error"])
+	r.Header.Set(HeaderAuthorization, "Bearer admin")
+	rotations := serveRequest(t, service, r)["response"].(map[string]interface{})["rotations"].([]interface{})
+	assert.Equal(t, 1, len(rotations))
+	r, _ = http.NewRequest(GET, RouteKeyRotations, nil)
+	assert.Equal(t, map[string]interface{}{"rotations": []interface{}{}}, serveRequest(t, service, r)["response"])
+
+	r, _ = http.NewRequest(POST, RouteAdminRotationCancel, nil)
+	assert.Equal(t, ErrorAdminUnauthorized, serveRequest(t, service, r)["error"])
+	r.Header.Set(HeaderAuthorization, "Bearer admin")
+	rotation = serveRequest(t, service, r)["response"].(map[string]interface{})
+	assert.Equal(t, models.KeyRotationCancelled, rotation["status"])
+	assert.Equal(t, "no rotation", serveRequest(t, service, r)["error"])
+
+	// active rotations are published
+	dbFake.SaveKeyRotation(models.KeyRotation{Id: 2, InitScript: "52ae", Status: models.KeyRotationActive, Txid: "abc"})
+	r, _ = http.NewRequest(GET, RouteKeyRotations, nil)
+	rotations = serveRequest(t, service, r)["response"].(map[string]interface{})["rotations"].([]interface{})
+	assert.Equal(t, 1, len(rotations))
+	assert.Equal(t, float64(2), rotations[0].(map[string]interface{})["id"])
+	assert.Equal(t, "abc", rotations[0].(map[string]interface{})["txid"])
+}
+
+// Test signer liveness is returned with the health of each signer
+func TestHandleSigners(t *testing.T) {
+	dbFake := db.NewDbFake()
+	service := NewRequestService(nil, nil, dbFake, confpkg.ApiConfig{})
+
+	r, _ := http.NewRequest(GET, RouteSigners, nil)
+	assert.Equal(t, map[string]interface{}{"signers": []interface{}{}}, serveRequest(t, service, r)["response"])
+
+	dbFake.SaveSignerStatus(models.SignerStatus{Pubkey: "03bb", Position: 1, LastRequest: 200, LastSeen: 100, Missed: 3})
+	dbFake.SaveSignerStatus(models.SignerStatus{Pubkey: "02aa", Position: 0, LastRequest: 200, LastSeen: 200})
+	r, _ = http.NewRequest(GET, RouteSigners, nil)
+	signers := serveRequest(t, service, r)["response"].(map[string]interface{})["signers"].([]interface{})
+	assert.Equal(t, 2, len(signers))
+	assert.Equal(t, map[string]interface{}{"pubkey": "02aa", "position": float64(0), "last_request": float64(200),
+		"last_seen": float64(200), "missed": float64(0), "health": models.SignerHealthOnline}, signers[0])
+	assert.Equal(t, models.SignerHealthOffline, signers[1].(map[string]interface{})["health"])
+	assert.Equal(t, float64(100), signers[1].(map[string]interface{})["last_seen"])
+}
+
+// StatusSource fake for testing the status route
+type statusSourceFake struct {
+	status models.AttestationStatus
+}
+
+func (s statusSourceFake) Status() models.AttestationStatus {
+	return s.status
+}
+
+// Test status request
+func TestHandleStatus(t *testing.T) {
+	dbFake := db.NewDbFake()
+	service := NewRequestService(nil, nil, dbFake, confpkg.ApiConfig{})
+
+	// nothing available yet
+	r, _ := http.NewRequest(GET, RouteStatus, nil)
+	response := serveRequest(t, service, r)["response"].(map[string]interface{})
+	assert.Nil(t, response["balance"])
+	assert.Nil(t, response["last_attestation"])
+	assert.Nil(t, response["attestation"])
+	assert.Equal(t, []interface{}{}, response["signers"])
+	assert.NotEqual(t, float64(0), response["time"])
+
+	// last confirmed attestation, unconfirmed attestations ignored
+	hash, _ := chainhash.NewHashFromStr(testCommitment)
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*hash})
+	txid, _ := chainhash.NewHashFromStr(fmt.Sprintf("%064x", 1))
+	attestation := models.NewAttestation(*txid, commitment)
+	attestation.Confirmed = true
+	dbFake.SaveAttestation(*attestation)
+	dbFake.SaveAttestationInfo(models.AttestationInfo{Txid: txid.String(), Blockhash: testCommitment,
+		Time: 1542121293, Height: 1000, BlockTime: 1542121300, Fee: 500})
+	unconfirmedTxid, _ := chainhash.NewHashFromStr(fmt.Sprintf("%064x", 2))
+	dbFake.SaveAttestation(*models.NewAttestation(*unconfirmedTxid, commitment))
+
+	service.SetBalanceSource(balanceSourceFake{models.Balance{Value: 18000, Time: 1}, true})
+	service.SetStatusSource(statusSourceFake{models.AttestationStatus{
+		State: "next_commitment", NextState: 1542121400, LastTransition: 1542121340, Paused: true}})
+	dbFake.SaveSignerStatus(models.SignerStatus{Pubkey: "02aa", Position: 0, LastRequest: 200, LastSeen: 200})
+
+	response = serveRequest(t, service, r)["response"].(map[string]interface{})
+	assert.Equal(t, float64(18000), response["balance"].(map[string]interface{})["value"])
+	assert.Equal(t, map[string]interface{}{
+		"txid":        txid.String(),
+		"merkle_root": commitment.GetCommitmentHash().String(),
+		"confirmed":   true,
+		"time":        float64(1542121293),
+		"height":      float64(1000),
+		"blockhash":   testCommitment,
+		"block_time":  float64(1542121300),
+		"fee":         float64(500),
+	}, response["last_attestation"])
+	assert.Equal(t, map[string]interface{}{
+		"state":           "next_commitment",
+		"next_state":      float64(1542121400),
+		"last_transition": float64(1542121340),
+		"failing":         false,
+		"paused":          true,
+		"syncing":         false,
+	}, response["attestation"])
+	signers := response["signers"].([]interface{})
+	assert.Equal(t, 1, len(signers))
+	assert.Equal(t, models.SignerHealthOnline, signers[0].(map[string]interface{})["health"])
+}
+
+// Test request ids are returned and stored with commitments
+func TestRequestId(t *testing.T) {
+	assert.Equal(t, true, isValidRequestId("abc-123_x.y"))
+	assert.Equal(t, false, isValidRequestId(""))
+	assert.Equal(t, false, isValidRequestId("abc 123"))
+	assert.Equal(t, false, isValidRequestId(strings.Repeat("a", RequestIdMaxLength+1)))
+
+	dbFake := db.NewDbFake()
+	dbFake.SaveClientDetails(models.ClientDetails{ClientPosition: 0, AuthToken: "token0", ClientName: "client0"})
+	service := NewRequestService(nil, nil, dbFake, confpkg.ApiConfig{})
+
+	// request id generated if not provided
+	r, _ := http.NewRequest(GET, RouteIndex, nil)
+	writer := httptest.NewRecorder()
+	service.router.ServeHTTP(writer, r)
+	assert.Equal(t, 32, len(writer.Header().Get(HeaderRequestId)))
+
+	// invalid request id replaced
+	r.Header.Set(HeaderRequestId, "bad id")
+	writer = httptest.NewRecorder()
+	service.router.ServeHTTP(writer, r)
+	assert.Equal(t, 32, len(writer.Header().Get(HeaderRequestId)))
+
+	// request id provided by client kept and stored with commitment
+	r, _ = http.NewRequest(POST, RouteCommitmentSend, bytes.NewReader(commitmentSendBody(testCommitment, 0, "token0", nil)))
+	r.Header.Set(HeaderRequestId, "client-request-1")
+	writer = httptest.NewRecorder()
+	service.router.ServeHTTP(writer, r)
+	assert.Equal(t, "client-request-1", writer.Header().Get(HeaderRequestId))
+
+	commitments, _ := dbFake.GetClientCommitments()
+	assert.Equal(t, 1, len(commitments))
+	assert.Equal(t, "client-request-1", commitments[0].RequestId)
+}
+
+// Test commitment exclusions are returned to the authorized client only
+func TestHandleCommitmentExclusions(t *testing.T) {
+	dbFake := db.NewDbFake()
+	dbFake.SaveClientDetails(models.ClientDetails{ClientPosition: 0, AuthToken: "token0", ClientName: "client0"})
+	dbFake.SaveClientDetails(models.ClientDetails{ClientPosition: 1, AuthToken: "token1", ClientName: "client1",
+		HmacSecret: hex.EncodeToString([]byte("secret1"))})
+	exclusion := models.CommitmentExclusion{ClientPosition: 0, Commitment: testCommitment, SubmittedAt: 1000,
+		RoundClose: 2000, MerkleRoot: testCommitment, Reason: models.ExclusionReasonMissed}
+	dbFake.SaveCommitmentExclusion(exclusion)
+	service := NewRequestService(nil, nil, dbFake,
+		confpkg.ApiConfig{AuthSchemes: []string{AuthSchemeToken, AuthSchemeHmac}})
+
+	newRequest := func(position string, authorization string) *http.Request {
+		r, _ := http.NewRequest(GET, "/api/commitment/exclusions/"+position+"/", nil)
+		if authorization != "" {
+			r.Header.Set(HeaderAuthorization, authorization)
+		}
+		return r
+	}
+
+	assert.Equal(t, ErrorAdminPositionInvalid, serveRequest(t, service, newRequest("x", ""))["error"])
+	assert.Equal(t, ErrorAuthClientNotFound, serveRequest(t, service, newRequest("2", ""))["error"])
+	assert.Equal(t, ErrorAuthTokenInvalid, serveRequest(t, service, newRequest("0", ""))["error"])
+	assert.Equal(t, ErrorAuthTokenInvalid, serveRequest(t, service, newRequest("0", "Bearer token1"))["error"])
+
+	response := serveRequest(t, service, newRequest("0", "Bearer token0"))
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"position":     float64(0),
+		"commitment":   testCommitment,
+		"submitted_at": float64(1000),
+		"round_close":  float64(2000),
+		"merkle_root":  testCommitment,
+		"included":     "",
+		"reason":       models.ExclusionReasonMissed,
+	}}, response["response"])
+
+	// hmac signed request with empty body
+	r := newRequest("1", "")
+	date := time.Now().UTC().Format(http.TimeFormat)
+	digest := HmacBodyDigest([]byte{})
+	r.Header.Set(HeaderDate, date)
+	r.Header.Set(HeaderDigest, digest)
+	r.Header.Set(HeaderAuthorization, HmacAuthorization(1,
+		HmacSignature([]byte("secret1"), HmacSigningString(GET, r.URL.Path, date, digest))))
+	assert.Equal(t, []interface{}{}, serveRequest(t, service, r)["response"])
+
+	r.Header.Set(HeaderAuthorization, HmacAuthorization(0,
+		HmacSignature([]byte("secret1"), HmacSigningString(GET, r.URL.Path, date, digest))))
+	assert.Equal(t, ErrorHmacPositionMismatch, serveRequest(t, service, r)["error"])
+}
+
+// Test commitment history pages are returned to the authorized client only
+func TestHandleCommitmentHistory(t *testing.T) {
+	dbFake := db.NewDbFake()
+	dbFake.SaveClientDetails(models.ClientDetails{ClientPosition: 0, AuthToken: "token0", ClientName: "client0"})
+	dbFake.SaveClientDetails(models.ClientDetails{ClientPosition: 1, AuthToken: "token1", ClientName: "client1"})
+	service := NewRequestService(nil, nil, dbFake,
+		confpkg.ApiConfig{AuthSchemes: []string{AuthSchemeToken}})
+
+	// three rounds including position 0, the latest not attested yet
+	var roots []chainhash.Hash
+	for i := 0; i < 3; i++ {
+		hash0, _ := chainhash.NewHashFromStr(fmt.Sprintf("%064x", 10+i))
+		hash1, _ := chainhash.NewHashFromStr(fmt.Sprintf("%064x", 20+i))
+		commitment, _ := models.NewCommitment([]chainhash.Hash{*hash0, *hash1})
+		dbFake.SaveMerkleCommitments(commitment.GetMerkleCommitments())
+		roots = append(roots, commitment.GetCommitmentHash())
+		if i < 2 {
+			attestation := models.NewAttestation(chainhash.Hash{byte(i + 1)}, commitment)
+			attestation.Confirmed = i == 0
+			dbFake.SaveAttestation(*attestation)
+		}
+	}
+
+	newRequest := func(position string, query string, authorization string) *http.Request {
+		r, _ := http.NewRequest(GET, "/api/position/"+position+"/commitments/"+query, nil)
+		if authorization != "" {
+			r.Header.Set(HeaderAuthorization, authorization)
+		}
+		return r
+	}
+
+	assert.Equal(t, ErrorAdminPositionInvalid, serveRequest(t, service, newRequest("x", "", ""))["error"])
+	assert.Equal(t, ErrorAuthClientNotFound, serveRequest(t, service, newRequest("2", "", ""))["error"])
+	assert.Equal(t, ErrorAuthTokenInvalid, serveRequest(t, service, newRequest("0", "", "Bearer token1"))["error"])
+	for _, query := range []string{"?offset=-1", "?offset=x", "?limit=0", "?limit=x"} {
+		assert.Equal(t, ErrorPaginationInvalid, serveRequest(t, service, newRequest("0", query, "Bearer token0"))["error"])
+	}
+
+	entry := func(i int, txid string, confirmed bool) map[string]interface{} {
+		return map[string]interface{}{
+			"commitment":  fmt.Sprintf("%064x", 10+i),
+			"merkle_root": roots[i].String(),
+			"txid":        txid,
+			"confirmed":   confirmed,
+		}
+	}
+	response := serveRequest(t, service, newRequest("0", "", "Bearer token0"))
+	assert.Equal(t, map[string]interface{}{
+		"position": float64(0),
+		"total":    float64(3),
+		"offset":   float64(0),
+		"limit":    float64(HistoryDefaultLimit),
+		"commitments": []interface{}{
+			entry(2, "", false),
+			entry(1, chainhash.Hash{2}.String(), false),
+			entry(0, chainhash.Hash{1}.String(), true),
+		},
+	}, response["response"])
+
+	response = serveRequest(t, service, newRequest("0", "?offset=1&limit=1", "Bearer token0"))
+	assert.Equal(t, []interface{}{entry(1, chainhash.Hash{2}.String(), false)},
+		response["response"].(map[string]interface{})["commitments"])
+
+	response = serveRequest(t, service, newRequest("0", "?offset=3&limit=5000", "Bearer token0"))
+	assert.Equal(t, float64(HistoryMaxLimit), response["response"].(map[string]interface{})["limit"])
+	assert.Equal(t, []interface{}{}, response["response"].(map[string]interface{})["commitments"])
+}
+
+// Test admin round snapshot requests
+func TestHandleAdminRounds(t *testing.T) {
+	dbFake := db.NewDbFake()
+	service := NewRequestService(nil, nil, dbFake, confpkg.ApiConfig{AdminToken: "admin"})
+
+	// two rounds with the same commitments and a later round
+	var snapshots []models.RoundSnapshot
+	for i, round := range []int64{1000, 2000, 3000} {
+		hash0, _ := chainhash.NewHashFromStr(fmt.Sprintf("%064x", 10+i/2))
+		commitment, _ := models.NewCommitment([]chainhash.Hash{*hash0})
+		snapshot := models.NewRoundSnapshot(round,
+			[]models.ClientCommitment{{Commitment: *hash0, ClientPosition: 0}}, commitment.GetCommitmentHash())
+		dbFake.SaveRoundSnapshot(snapshot)
+		snapshots = append(snapshots, snapshot)
+	}
+	// snapshot not matching its merkle root
+	dbFake.SaveRoundSnapshot(models.RoundSnapshot{Round: 4000, MerkleRoot: snapshots[2].MerkleRoot,
+		Commitments: snapshots[0].Commitments})
+
+	newRequest := func(path string) *http.Request {
+		r, _ := http.NewRequest(GET, path, nil)
+		r.Header.Set(HeaderAuthorization, "Bearer admin")
+		return r
+	}
+
+	r, _ := http.NewRequest(GET, RouteAdminRounds, nil)
+	assert.Equal(t, ErrorAdminUnauthorized, serveRequest(t, service, r)["error"])
+	assert.Equal(t, ErrorPaginationInvalid, serveRequest(t, service, newRequest(RouteAdminRounds+"?limit=0"))["error"])
+	assert.Equal(t, ErrorCommitmentInvalid, serveRequest(t, service, newRequest(RouteAdminRounds+"?root=x"))["error"])
+
+	decode := func(response map[string]interface{}, result interface{}) {
+		responseJson, _ := json.Marshal(response["response"])
+		assert.Equal(t, nil, json.Unmarshal(responseJson, result))
+	}
+	var page models.RoundSnapshots
+	decode(serveRequest(t, service, newRequest(RouteAdminRounds+"?offset=1&limit=2")), &page)
+	assert.Equal(t, models.RoundSnapshots{Total: 4, Offset: 1, Limit: 2,
+		Rounds: []models.RoundSnapshot{snapshots[2], snapshots[1]}}, page)
+
+	// snapshots of a merkle root
+	page = models.RoundSnapshots{}
+	decode(serveRequest(t, service, newRequest(RouteAdminRounds+"?root="+snapshots[0].MerkleRoot)), &page)
+	assert.Equal(t, int64(2), page.Total)
+	assert.Equal(t, []models.RoundSnapshot{snapshots[1], snapshots[0]}, page.Rounds)
+
+	// single round
+	assert.Equal(t, ErrorRoundInvalid, serveRequest(t, service, newRequest("/admin/round/x/"))["error"])
+	assert.Equal(t, ErrorRoundNotFound, serveRequest(t, service, newRequest("/admin/round/1500/"))["error"])
+	assert.Equal(t, ErrorRoundSnapshotInvalid, serveRequest(t, service, newRequest("/admin/round/4000/"))["error"])
+	var snapshot models.RoundSnapshot
+	decode(serveRequest(t, service, newRequest("/admin/round/1000/")), &snapshot)
+	assert.Equal(t, snapshots[0], snapshot)
+}
+
+// Test attestation requests with the block confirming the attestation
+func TestHandleAttestation(t *testing.T) {
+	dbFake := db.NewDbFake()
+	service := NewRequestService(nil, nil, dbFake, confpkg.ApiConfig{})
+
+	hash0, _ := chainhash.NewHashFromStr(fmt.Sprintf("%064x", 1))
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*hash0})
+	txid, _ := chainhash.NewHashFromStr(fmt.Sprintf("%064x", 2))
+	attestation := models.NewAttestation(*txid, commitment)
+	attestation.Info = models.AttestationInfo{Txid: txid.String(), Time: 1542121293}
+	dbFake.SaveAttestation(*attestation)
+
+	r, _ := http.NewRequest(GET, "/api/attestation/x/", nil)
+	assert.Equal(t, ErrorAttestationInvalid, serveRequest(t, service, r)["error"])
+	r, _ = http.NewRequest(GET, "/api/attestation/"+hash0.String()+"/", nil)
+	assert.Equal(t, ErrorAttestationNotFound, serveRequest(t, service, r)["error"])
+
+	// unconfirmed attestation
+	r, _ = http.NewRequest(GET, "/api/attestation/"+txid.String()+"/", nil)
+	assert.Equal(t, map[string]interface{}{"txid": txid.String(), "merkle_root": commitment.GetCommitmentHash().String(),
+		"confirmed": false, "time": float64(1542121293)}, serveRequest(t, service, r)["response"])
+
+	// confirmed attestation with block and fee
+	attestation.Confirmed = true
+	attestation.Info = models.AttestationInfo{Txid: txid.String(), Blockhash: hash0.String(), Time: 1542121293,
+		Height: 1000, BlockTime: 1542121400, Fee: 1234}
+	dbFake.SaveAttestation(*attestation)
+	assert.Equal(t, map[string]interface{}{"txid": txid.String(), "merkle_root": commitment.GetCommitmentHash().String(),
+		"confirmed": true, "time": float64(1542121293), "height": float64(1000), "blockhash": hash0.String(),
+		"block_time": float64(1542121400), "fee": float64(1234)}, serveRequest(t, service, r)["response"])
+}
+
+// Test staychain requests of the transactions indexed from the main chain
+func TestHandleStaychain(t *testing.T) {
+	dbFake := db.NewDbFake()
+	service := NewRequestService(nil, nil, dbFake, confpkg.ApiConfig{})
+
+	var txs []models.StaychainTx
+	for i := int64(0); i < 3; i++ {
+		tx := models.StaychainTx{Txid: fmt.Sprintf("%064x", i+1), Index: i, Height: 100 + i,
+			Blockhash: fmt.Sprintf("%064x", 100+i), Time: 1000 + i}
+		if i > 0 {
+			tx.Spends = txs[i-1].Txid
+			txs[i-1].SpentBy = tx.Txid
+		}
+		txs = append(txs, tx)
+	}
+	for _, tx := range txs {
+		dbFake.SaveStaychainTx(tx)
+	}
+
+	decode := func(response map[string]interface{}, result interface{}) {
+		responseJson, _ := json.Marshal(response["response"])
+		assert.Equal(t, nil, json.Unmarshal(responseJson, result))
+	}
+	r, _ := http.NewRequest(GET, RouteStaychain+"?offset=-1", nil)
+	assert.Equal(t, ErrorPaginationInvalid, serveRequest(t, service, r)["error"])
+	var page models.StaychainTxs
+	r, _ = http.NewRequest(GET, RouteStaychain+"?limit=2", nil)
+	decode(serveRequest(t, service, r), &page)
+	assert.Equal(t, models.StaychainTxs{Total: 3, Offset: 0, Limit: 2,
+		Txs: []models.StaychainTx{txs[2], txs[1]}}, page)
+
+	// single transaction
+	r, _ = http.NewRequest(GET, "/api/staychain/tx/x/", nil)
+	assert.Equal(t, ErrorStaychainTxInvalid, serveRequest(t, service, r)["error"])
+	r, _ = http.NewRequest(GET, "/api/staychain/tx/"+fmt.Sprintf("%064x", 10)+"/", nil)
+	assert.Equal(t, ErrorStaychainTxNotFound, serveRequest(t, service, r)["error"])
+	var tx models.StaychainTx
+	r, _ = http.NewRequest(GET, "/api/staychain/tx/"+txs[1].Txid+"/", nil)
+	decode(serveRequest(t, service, r), &tx)
+	assert.Equal(t, txs[1], tx)
+}
+
+type proofDelivererFake struct{}
+
+func (p *proofDelivererFake) ValidateTarget(deliveryUrl string) error {
+	if !strings.HasPrefix(deliveryUrl, "s3://") {
+		return errors.New("Invalid proof delivery url")
+	}
+	return nil
+}
+
+// Test client proof delivery registration requests
+func TestHandleClientDelivery(t *testing.T) {
+	dbFake := db.NewDbFake()
+	dbFake.SaveClientDetails(models.ClientDetails{ClientPosition: 0, AuthToken: "token0", ClientName: "client0"})
+	dbFake.SaveClientDetails(models.ClientDetails{ClientPosition: 1, AuthToken: "token1", ClientName: "client1",
+		HmacSecret: hex.EncodeToString([]byte("secret1"))})
+	service := NewRequestService(nil, nil, dbFake,
+		confpkg.ApiConfig{AuthSchemes: []string{AuthSchemeToken, AuthSchemeHmac}})
+
+	newRequest := func(method string, position string, body string, authorization string) *http.Request {
+		r, _ := http.NewRequest(method, "/api/client/"+position+"/delivery/", strings.NewReader(body))
+		if authorization != "" {
+			r.Header.Set(HeaderAuthorization, authorization)
+		}
+		return r
+	}
+
+	body := `{"url": "s3://proofs/client0"}`
+	assert.Equal(t, ErrorDeliveryUnavailable, serveRequest(t, service, newRequest(POST, "0", body, "Bearer token0"))["error"])
+	service.SetProofDeliverer(&proofDelivererFake{})
+
+	assert.Equal(t, ErrorAdminPositionInvalid, serveRequest(t, service, newRequest(POST, "x", body, ""))["error"])
+	assert.Equal(t, ErrorAuthClientNotFound, serveRequest(t, service, newRequest(POST, "2", body, ""))["error"])
+	assert.Equal(t, ErrorAuthTokenInvalid, serveRequest(t, service, newRequest(POST, "0", body, "Bearer token1"))["error"])
+	assert.Equal(t, ErrorRequestPayload, serveRequest(t, service, newRequest(POST, "0", "{", "Bearer token0"))["error"])
+	assert.Equal(t, "Invalid proof delivery url", serveRequest(t, service,
+		newRequest(POST, "0", `{"url": "ftp://proofs"}`, "Bearer token0"))["error"])
+
+	response := serveRequest(t, service, newRequest(POST, "0", body, "Bearer token0"))
+	assert.Equal(t, map[string]interface{}{"client_position": float64(0), "delivery_url": "s3://proofs/client0"},
+		response["response"])
+	details, _ := dbFake.GetClientDetails()
+	assert.Equal(t, "s3://proofs/client0", details[0].DeliveryUrl)
+
+	// hmac signed request with the request body
+	hmacBody := `{"url": "s3://proofs/client1"}`
+	r := newRequest(POST, "1", hmacBody, "")
+	date := time.Now().UTC().Format(http.TimeFormat)
+	digest := HmacBodyDigest([]byte(hmacBody))
+	r.Header.Set(HeaderDate, date)
+	r.Header.Set(HeaderDigest, digest)
+	r.Header.Set(HeaderAuthorization, HmacAuthorization(1,
+		HmacSignature([]byte("secret1"), HmacSigningString(POST, r.URL.Path, date, digest))))
+	response = serveRequest(t, service, r)
+	assert.Equal(t, "s3://proofs/client1", response["response"].(map[string]interface{})["delivery_url"])
+
+	// target removed
+	response = serveRequest(t, service, newRequest(DELETE, "0", "", "Bearer token0"))
+	assert.Equal(t, map[string]interface{}{"client_position": float64(0), "delivery_url": ""}, response["response"])
+	details, _ = dbFake.GetClientDetails()
+	assert.Equal(t, "", details[0].DeliveryUrl)
+	assert.Equal(t, "s3://proofs/client1", details[1].DeliveryUrl)
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Routes compatible with the hosted mainstay api
+// Proofs are returned in the response format of the hosted api, so that
+// client libraries of the hosted api work unmodified against a self-hosted
+// instance. Request parameters are query parameters as for the hosted api
+
+// hosted api query parameters
+const (
+	QueryHostedPosition   = "position"
+	QueryHostedCommitment = "commitment"
+)
+
+// Hosted commitment request handler
+// Returns the attestation and merkle proof of the commitment of the
+// commitment query parameter in the hosted api format
+func HandleHostedCommitment(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	commitment, commitmentErr := chainhash.NewHashFromStr(r.URL.Query().Get(QueryHostedCommitment))
+	if commitmentErr != nil {
+		writeError(w, ErrorCommitmentInvalid)
+		return
+	}
+
+	proof, proofErr := s.dbInterface.GetMerkleProofByCommitment(*commitment)
+	if proofErr != nil {
+		writeError(w, ErrorProofGet)
+		return
+	} else if proof.MerkleRoot == (chainhash.Hash{}) {
+		writeError(w, ErrorProofPending)
+		return
+	}
+	info, infoErr := s.dbInterface.GetAttestationInfoByMerkleRoot(proof.MerkleRoot)
+	if infoErr != nil {
+		writeError(w, ErrorProofGet)
+		return
+	}
+	writeHostedResponse(w, models.HostedCommitmentResponse{
+		Attestation: models.NewHostedAttestationResponse(proof.MerkleRoot, info),
+		MerkleProof: models.NewHostedMerkleProofResponse(proof),
+	})
+}
+
+// Hosted latest proof request handler
+// Returns the merkle proof of the client position of the position query
+// parameter in the latest confirmed attestation in the hosted api format
+func HandleHostedLatestProof(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	position, positionErr := strconv.ParseInt(r.URL.Query().Get(QueryHostedPosition), 10, 32)
+	if positionErr != nil {
+		writeError(w, ErrorAdminPositionInvalid)
+		return
+	}
+
+	proof, proofErr := s.dbInterface.GetLatestCommitmentMerkleProof(int32(position))
+	if proofErr != nil {
+		writeError(w, ErrorProofGet)
+		return
+	} else if proof.MerkleRoot == (chainhash.Hash{}) || proof.Commitment == (chainhash.Hash{}) {
+		writeError(w, ErrorLatestProofPending)
+		return
+	}
+	info, infoErr := s.dbInterface.GetAttestationInfoByMerkleRoot(proof.MerkleRoot)
+	if infoErr != nil {
+		writeError(w, ErrorProofGet)
+		return
+	}
+	writeHostedResponse(w, models.HostedLatestProofResponse{
+		Txid:       info.Txid,
+		Commitment: proof.Commitment.String(),
+		MerkleRoot: proof.MerkleRoot.String(),
+		Ops:        models.NewProofOpsResponse(proof.Ops),
+	})
+}
+
+// Hosted verify request handler
+// Returns whether the commitment of the commitment query parameter is
+// in a confirmed attestation for the client position of the position
+// query parameter in the hosted api format
+func HandleHostedVerify(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	position, positionErr := strconv.ParseInt(r.URL.Query().Get(QueryHostedPosition), 10, 32)
+	if positionErr != nil {
+		writeError(w, ErrorAdminPositionInvalid)
+		return
+	}
+	commitment, commitmentErr := chainhash.NewHashFromStr(r.URL.Query().Get(QueryHostedCommitment))
+	if commitmentErr != nil {
+		writeError(w, ErrorCommitmentInvalid)
+		return
+	}
+
+	proof, proofErr := s.dbInterface.GetCommitmentMerkleProof(int32(position), *commitment)
+	if proofErr != nil {
+		writeError(w, ErrorProofGet)
+		return
+	} else if proof.MerkleRoot == (chainhash.Hash{}) {
+		writeHostedResponse(w, models.HostedVerifyResponse{Confirmed: false})
+		return
+	}
+	info, infoErr := s.dbInterface.GetAttestationInfoByMerkleRoot(proof.MerkleRoot)
+	if infoErr != nil {
+		writeError(w, ErrorProofGet)
+		return
+	}
+	writeHostedResponse(w, models.HostedVerifyResponse{Confirmed: info.Blockhash != ""})
+}
+
+// Write json response in the hosted api envelope
+func writeHostedResponse(w http.ResponseWriter, response interface{}) {
+	writeResponseStatus(w, http.StatusOK, models.HostedResponse{
+		Response:  response,
+		Timestamp: time.Now().UnixMilli(),
+	})
+}
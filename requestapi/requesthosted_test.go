@@ -0,0 +1,77 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	confpkg "mainstay/config"
+	"mainstay/db"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test routes compatible with the hosted mainstay api
+func TestHandleHosted(t *testing.T) {
+	dbFake := db.NewDbFake()
+	service := NewRequestService(nil, nil, dbFake, confpkg.ApiConfig{ProofOps: models.ProofOpsPosition})
+
+	commitment0, _ := chainhash.NewHashFromStr(testCommitment)
+	commitment1, _ := chainhash.NewHashFromStr("3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*commitment0, *commitment1})
+	root := commitment.GetCommitmentHash().String()
+
+	r, _ := http.NewRequest(GET, "/api/v1/commitment/commitment?commitment=xyz", nil)
+	assert.Equal(t, ErrorCommitmentInvalid, serveRequest(t, service, r)["error"])
+	r, _ = http.NewRequest(GET, "/api/v1/commitment/latestproof?position=x", nil)
+	assert.Equal(t, ErrorAdminPositionInvalid, serveRequest(t, service, r)["error"])
+
+	commitmentRoute := fmt.Sprintf("/api/v1/commitment/commitment?commitment=%s", commitment1.String())
+	verifyRoute := fmt.Sprintf("/api/v1/commitment/verify?position=1&commitment=%s", commitment1.String())
+	r, _ = http.NewRequest(GET, commitmentRoute, nil)
+	assert.Equal(t, ErrorProofPending, serveRequest(t, service, r)["error"])
+	r, _ = http.NewRequest(GET, verifyRoute, nil)
+	assert.Equal(t, map[string]interface{}{"confirmed": false}, serveRequest(t, service, r)["response"])
+
+	// unconfirmed attestation
+	dbFake.SaveMerkleProofs(commitment.GetMerkleProofs())
+	txid, _ := chainhash.NewHashFromStr("4a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	attestation := models.NewAttestation(*txid, commitment)
+	dbFake.SaveAttestation(*attestation)
+	r, _ = http.NewRequest(GET, commitmentRoute, nil)
+	response := serveRequest(t, service, r)
+	assert.NotEqual(t, nil, response["timestamp"])
+	assert.Equal(t, map[string]interface{}{"merkle_root": root, "txid": txid.String(), "confirmed": false,
+		"inserted_at": ""}, response["response"].(map[string]interface{})["attestation"])
+	r, _ = http.NewRequest(GET, "/api/v1/commitment/latestproof?position=1", nil)
+	assert.Equal(t, ErrorLatestProofPending, serveRequest(t, service, r)["error"])
+
+	// confirmed attestation with append flag ops whatever the proof ops encoding
+	attestation.Confirmed = true
+	dbFake.SaveAttestation(*attestation)
+	dbFake.SaveAttestationInfo(models.AttestationInfo{Txid: txid.String(), Blockhash: testCommitment, Time: 1542121293})
+	ops := []interface{}{map[string]interface{}{"append": false, "commitment": testCommitment}}
+	r, _ = http.NewRequest(GET, commitmentRoute, nil)
+	assert.Equal(t, map[string]interface{}{
+		"attestation": map[string]interface{}{"merkle_root": root, "txid": txid.String(), "confirmed": true,
+			"inserted_at": "15:01:33 13/11/2018"},
+		"merkleproof": map[string]interface{}{"position": float64(1), "merkle_root": root,
+			"commitment": commitment1.String(), "ops": ops},
+	}, serveRequest(t, service, r)["response"])
+
+	r, _ = http.NewRequest(GET, "/api/v1/commitment/latestproof?position=1", nil)
+	assert.Equal(t, map[string]interface{}{"txid": txid.String(), "commitment": commitment1.String(),
+		"merkle_root": root, "ops": ops}, serveRequest(t, service, r)["response"])
+
+	r, _ = http.NewRequest(GET, verifyRoute, nil)
+	assert.Equal(t, map[string]interface{}{"confirmed": true}, serveRequest(t, service, r)["response"])
+	r, _ = http.NewRequest(GET, fmt.Sprintf("/api/v1/commitment/verify?position=0&commitment=%s",
+		commitment1.String()), nil)
+	assert.Equal(t, map[string]interface{}{"confirmed": false}, serveRequest(t, service, r)["response"])
+}
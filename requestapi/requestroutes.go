@@ -0,0 +1,609 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"mainstay/log"
+)
+
+// http methods
+const (
+	GET    = "GET"
+	POST   = "POST"
+	DELETE = "DELETE"
+)
+
+// request id header used to correlate api requests with resulting actions
+// a valid request id provided by the client is kept, otherwise one is generated
+const (
+	HeaderRequestId    = "X-Request-ID"
+	RequestIdMaxLength = 64
+)
+
+// route names
+const (
+	RouteNameIndex                  = "Index"
+	RouteNameCommitmentSend         = "CommitmentSend"
+	RouteNameCommitmentSendBulk     = "CommitmentSendBulk"
+	RouteNameBalance                = "Balance"
+	RouteNameFees                   = "Fees"
+	RouteNameEvents                 = "Events"
+	RouteNameAdminClientHmac        = "AdminClientHmac"
+	RouteNameAdminClientHmacRevoke  = "AdminClientHmacRevoke"
+	RouteNameAdminAttest            = "AdminAttest"
+	RouteNameAdminPause             = "AdminPause"
+	RouteNameAdminResume            = "AdminResume"
+	RouteNameAdminReview            = "AdminReview"
+	RouteNameAdminReviewVeto        = "AdminReviewVeto"
+	RouteNameAdminClientGroup       = "AdminClientGroup"
+	RouteNameAdminClientGroupRemove = "AdminClientGroupRemove"
+	RouteNameAdminRotation          = "AdminRotation"
+	RouteNameAdminRotationRequest   = "AdminRotationRequest"
+	RouteNameAdminRotationCancel    = "AdminRotationCancel"
+	RouteNameAdminRounds            = "AdminRounds"
+	RouteNameAdminRound             = "AdminRound"
+	RouteNameAdminIntegrity         = "AdminIntegrity"
+	RouteNameKeyRotations           = "KeyRotations"
+	RouteNameSigners                = "Signers"
+	RouteNameStatus                 = "Status"
+	RouteNameSlotGroupProof         = "SlotGroupProof"
+	RouteNameCommitmentProof        = "CommitmentProof"
+	RouteNameCommitmentProofBinary  = "CommitmentProofBinary"
+	RouteNameCommitmentTimestamp    = "CommitmentTimestamp"
+	RouteNameCommitmentSubmission   = "CommitmentSubmission"
+	RouteNameCommitmentExclusions   = "CommitmentExclusions"
+	RouteNameCommitmentHistory      = "CommitmentHistory"
+	RouteNameLatestProof            = "LatestProof"
+	RouteNameClientDelivery         = "ClientDelivery"
+	RouteNameClientDeliveryRemove   = "ClientDeliveryRemove"
+	RouteNameProofSchema            = "ProofSchema"
+	RouteNameProtocol               = "Protocol"
+	RouteNameDerivationHistory      = "DerivationHistory"
+	RouteNameAttestation            = "Attestation"
+	RouteNameStaychain              = "Staychain"
+	RouteNameStaychainTx            = "StaychainTx"
+	RouteNameApiSpec                = "ApiSpec"
+	RouteNameApiSpecUi              = "ApiSpecUi"
+	RouteNameGraphql                = "Graphql"
+	RouteNameGraphqlQuery           = "GraphqlQuery"
+	RouteNameHealthz                = "Healthz"
+	RouteNameReadyz                 = "Readyz"
+	RouteNameHostedCommitment       = "HostedCommitment"
+	RouteNameHostedLatestProof      = "HostedLatestProof"
+	RouteNameHostedVerify           = "HostedVerify"
+)
+
+// route patterns
+// path segments of the form {name} are captured as route variables
+const (
+	RouteAdminPrefix = "/admin/" // prefix of the admin route patterns
+
+	RouteIndex                 = "/"
+	RouteCommitmentSend        = "/api/commitment/send/"
+	RouteCommitmentSendBulk    = "/api/commitment/send/bulk/"
+	RouteBalance               = "/api/balance/"
+	RouteFees                  = "/api/fees/"
+	RouteEvents                = "/api/events/"
+	RouteAdminClientHmac       = "/admin/client/{position}/hmac/"
+	RouteAdminAttest           = "/admin/attest/"
+	RouteAdminPause            = "/admin/pause/"
+	RouteAdminResume           = "/admin/resume/"
+	RouteAdminReview           = "/admin/review/"
+	RouteAdminReviewVeto       = "/admin/review/veto/"
+	RouteAdminClientGroup      = "/admin/client/{position}/group/"
+	RouteAdminRotation         = "/admin/rotation/"
+	RouteAdminRotationCancel   = "/admin/rotation/cancel/"
+	RouteAdminRounds           = "/admin/rounds/"
+	RouteAdminRound            = "/admin/round/{round}/"
+	RouteAdminIntegrity        = "/admin/integrity/"
+	RouteKeyRotations          = "/api/rotations/"
+	RouteSigners               = "/signers/"
+	RouteStatus                = "/status/"
+	RouteSlotGroupProof        = "/api/group/proof/{position}/{commitment}/"
+	RouteCommitmentProof       = "/api/commitment/proof/{position}/{commitment}/"
+	RouteCommitmentProofBinary = "/api/commitment/proof/{position}/{commitment}/binary/"
+	RouteCommitmentTimestamp   = "/api/commitment/timestamp/{position}/{commitment}/"
+	RouteCommitmentSubmission  = "/api/commitment/submission/{id}/"
+	RouteCommitmentExclusions  = "/api/commitment/exclusions/{position}/"
+	RouteCommitmentHistory     = "/api/position/{position}/commitments/"
+	RouteLatestProof           = "/api/position/{position}/latestproof/"
+	RouteClientDelivery        = "/api/client/{position}/delivery/"
+	RouteProofSchema           = "/api/proof/schema/"
+	RouteProtocol              = "/api/protocol/"
+	RouteDerivationHistory     = "/derivation/history/{txid}/"
+	RouteAttestation           = "/api/attestation/{txid}/"
+	RouteStaychain             = "/api/staychain/"
+	RouteStaychainTx           = "/api/staychain/tx/{txid}/"
+	RouteApiSpec               = "/api/spec/"
+	RouteApiSpecUi             = "/api/spec/ui/"
+	RouteGraphql               = "/graphql/"
+	RouteHealthz               = "/healthz/"
+	RouteReadyz                = "/readyz/"
+
+	// routes compatible with the hosted mainstay api
+	RouteHostedCommitment  = "/api/v1/commitment/commitment/"
+	RouteHostedLatestProof = "/api/v1/commitment/latestproof/"
+	RouteHostedVerify      = "/api/v1/commitment/verify/"
+)
+
+// Route structure
+// Routing for http requests to request service
+type Route struct {
+	name        string
+	method      string
+	pattern     string
+	handlerFunc func(http.ResponseWriter, *http.Request, *RequestService)
+}
+
+var routes = []Route{
+	Route{
+		RouteNameIndex,
+		GET,
+		RouteIndex,
+		HandleIndex,
+	},
+	Route{
+		RouteNameCommitmentSend,
+		POST,
+		RouteCommitmentSend,
+		HandleCommitmentSend,
+	},
+	Route{
+		RouteNameCommitmentSendBulk,
+		POST,
+		RouteCommitmentSendBulk,
+		HandleCommitmentSendBulk,
+	},
+	Route{
+		RouteNameBalance,
+		GET,
+		RouteBalance,
+		HandleBalance,
+	},
+	Route{
+		RouteNameFees,
+		GET,
+		RouteFees,
+		HandleFees,
+	},
+	Route{
+		RouteNameEvents,
+		GET,
+		RouteEvents,
+		HandleEvents,
+	},
+	Route{
+		RouteNameSlotGroupProof,
+		GET,
+		RouteSlotGroupProof,
+		HandleSlotGroupProof,
+	},
+	Route{
+		RouteNameCommitmentProof,
+		GET,
+		RouteCommitmentProof,
+		HandleCommitmentProof,
+	},
+	Route{
+		RouteNameCommitmentProofBinary,
+		GET,
+		RouteCommitmentProofBinary,
+		HandleCommitmentProofBinary,
+	},
+	Route{
+		RouteNameCommitmentTimestamp,
+		GET,
+		RouteCommitmentTimestamp,
+		HandleCommitmentTimestamp,
+	},
+	Route{
+		RouteNameCommitmentSubmission,
+		GET,
+		RouteCommitmentSubmission,
+		HandleCommitmentSubmission,
+	},
+	Route{
+		RouteNameCommitmentExclusions,
+		GET,
+		RouteCommitmentExclusions,
+		HandleCommitmentExclusions,
+	},
+	Route{
+		RouteNameCommitmentHistory,
+		GET,
+		RouteCommitmentHistory,
+		HandleCommitmentHistory,
+	},
+	Route{
+		RouteNameLatestProof,
+		GET,
+		RouteLatestProof,
+		HandleLatestProof,
+	},
+	Route{
+		RouteNameClientDelivery,
+		POST,
+		RouteClientDelivery,
+		HandleClientDelivery,
+	},
+	Route{
+		RouteNameClientDeliveryRemove,
+		DELETE,
+		RouteClientDelivery,
+		HandleClientDeliveryRemove,
+	},
+	Route{
+		RouteNameProofSchema,
+		GET,
+		RouteProofSchema,
+		HandleProofSchema,
+	},
+	Route{
+		RouteNameProtocol,
+		GET,
+		RouteProtocol,
+		HandleProtocol,
+	},
+	Route{
+		RouteNameKeyRotations,
+		GET,
+		RouteKeyRotations,
+		HandleKeyRotations,
+	},
+	Route{
+		RouteNameSigners,
+		GET,
+		RouteSigners,
+		HandleSigners,
+	},
+	Route{
+		RouteNameStatus,
+		GET,
+		RouteStatus,
+		HandleStatus,
+	},
+	Route{
+		RouteNameHostedCommitment,
+		GET,
+		RouteHostedCommitment,
+		HandleHostedCommitment,
+	},
+	Route{
+		RouteNameHostedLatestProof,
+		GET,
+		RouteHostedLatestProof,
+		HandleHostedLatestProof,
+	},
+	Route{
+		RouteNameHostedVerify,
+		GET,
+		RouteHostedVerify,
+		HandleHostedVerify,
+	},
+	Route{
+		RouteNameHealthz,
+		GET,
+		RouteHealthz,
+		HandleHealthz,
+	},
+	Route{
+		RouteNameReadyz,
+		GET,
+		RouteReadyz,
+		HandleReadyz,
+	},
+	Route{
+		RouteNameDerivationHistory,
+		GET,
+		RouteDerivationHistory,
+		HandleDerivationHistory,
+	},
+	Route{
+		RouteNameAttestation,
+		GET,
+		RouteAttestation,
+		HandleAttestation,
+	},
+	Route{
+		RouteNameStaychain,
+		GET,
+		RouteStaychain,
+		HandleStaychain,
+	},
+	Route{
+		RouteNameStaychainTx,
+		GET,
+		RouteStaychainTx,
+		HandleStaychainTx,
+	},
+	Route{
+		RouteNameApiSpec,
+		GET,
+		RouteApiSpec,
+		HandleApiSpec,
+	},
+	Route{
+		RouteNameApiSpecUi,
+		GET,
+		RouteApiSpecUi,
+		HandleApiSpecUi,
+	},
+	Route{
+		RouteNameGraphql,
+		POST,
+		RouteGraphql,
+		HandleGraphql,
+	},
+	Route{
+		RouteNameGraphqlQuery,
+		GET,
+		RouteGraphql,
+		HandleGraphql,
+	},
+	Route{
+		RouteNameAdminClientHmac,
+		POST,
+		RouteAdminClientHmac,
+		HandleAdminClientHmac,
+	},
+	Route{
+		RouteNameAdminClientHmacRevoke,
+		DELETE,
+		RouteAdminClientHmac,
+		HandleAdminClientHmacRevoke,
+	},
+	Route{
+		RouteNameAdminAttest,
+		POST,
+		RouteAdminAttest,
+		HandleAdminAttest,
+	},
+	Route{
+		RouteNameAdminPause,
+		POST,
+		RouteAdminPause,
+		HandleAdminPause,
+	},
+	Route{
+		RouteNameAdminResume,
+		POST,
+		RouteAdminResume,
+		HandleAdminResume,
+	},
+	Route{
+		RouteNameAdminReview,
+		GET,
+		RouteAdminReview,
+		HandleAdminReview,
+	},
+	Route{
+		RouteNameAdminReviewVeto,
+		POST,
+		RouteAdminReviewVeto,
+		HandleAdminReviewVeto,
+	},
+	Route{
+		RouteNameAdminClientGroup,
+		POST,
+		RouteAdminClientGroup,
+		HandleAdminClientGroup,
+	},
+	Route{
+		RouteNameAdminClientGroupRemove,
+		DELETE,
+		RouteAdminClientGroup,
+		HandleAdminClientGroupRemove,
+	},
+	Route{
+		RouteNameAdminRotation,
+		GET,
+		RouteAdminRotation,
+		HandleAdminRotation,
+	},
+	Route{
+		RouteNameAdminRotationRequest,
+		POST,
+		RouteAdminRotation,
+		HandleAdminRotationRequest,
+	},
+	Route{
+		RouteNameAdminRotationCancel,
+		POST,
+		RouteAdminRotationCancel,
+		HandleAdminRotationCancel,
+	},
+	Route{
+		RouteNameAdminRounds,
+		GET,
+		RouteAdminRounds,
+		HandleAdminRounds,
+	},
+	Route{
+		RouteNameAdminRound,
+		GET,
+		RouteAdminRound,
+		HandleAdminRound,
+	},
+	Route{
+		RouteNameAdminIntegrity,
+		GET,
+		RouteAdminIntegrity,
+		HandleAdminIntegrity,
+	},
+}
+
+// Router struct
+// Matches requests against the route table and passes
+// the request service along to the route handler
+type Router struct {
+	service *RequestService
+	routes  []Route
+}
+
+// NewRouter returns pointer to Router instance
+// Public api routes are also routed under the prefix of each api version.
+// Admin routes are left out if served by a separate admin api
+func NewRouter(service *RequestService) *Router {
+	public, _ := splitAdminRoutes(versionedRoutes(routes), AdminApiEnabled(service.config))
+	return &Router{service, public}
+}
+
+// NewAdminRouter returns pointer to Router instance of the admin routes
+func NewAdminRouter(service *RequestService) *Router {
+	_, admin := splitAdminRoutes(routes, true)
+	return &Router{service, admin}
+}
+
+// Serve http request by finding matching route
+// Trailing slashes are ignored when matching route patterns
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	requestId := r.Header.Get(HeaderRequestId)
+	if !isValidRequestId(requestId) {
+		requestId = newRequestId()
+	}
+	w.Header().Set(HeaderRequestId, requestId)
+	r = r.WithContext(context.WithValue(r.Context(), requestIdKey{}, requestId))
+	setSecurityHeaders(w, r)
+	if r.Body != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, rt.service.maxBodyBytes)
+	}
+
+	if rt.service.cors != nil && isPreflight(r) {
+		if methods := rt.publicRouteMethods(r.URL.Path); len(methods) > 0 {
+			rt.service.cors.Preflight(w, r, methods)
+			return
+		}
+	}
+
+	pathMatched := false
+	for _, route := range rt.routes {
+		vars, ok := matchRoutePattern(route.pattern, r.URL.Path)
+		if !ok {
+			continue
+		}
+		pathMatched = true
+		if route.method != r.Method {
+			continue
+		}
+		if rt.service.adminAccess != nil && isAdminRoute(route.pattern) {
+			if accessErr := rt.service.adminAccess.Authorize(r); accessErr != nil {
+				log.WithFields(log.Fields{
+					log.FieldRequestId: requestId,
+					"remote":           r.RemoteAddr,
+					"route":            route.name}).Warnf("%s: %v\n", ErrorAdminAccessDenied, accessErr)
+				writeError(w, ErrorAdminAccessDenied)
+				return
+			}
+		}
+		if rt.service.cors != nil && !isAdminRoute(route.pattern) {
+			rt.service.cors.SetHeaders(w, r)
+		}
+		var versionErr error
+		if r, versionErr = setApiVersion(w, r, route.pattern); versionErr != nil {
+			writeError(w, versionErr.Error())
+			return
+		}
+		ctx := context.WithValue(r.Context(), routeVarsKey{}, vars)
+		route.handlerFunc(w, r.WithContext(ctx), rt.service)
+		log.WithFields(log.Fields{
+			log.FieldRequestId: requestId,
+			"method":           r.Method,
+			"uri":              r.RequestURI,
+			"route":            route.name,
+			"version":          ApiVersion(r),
+			"duration":         time.Since(start).String()}).Infoln("request served")
+		return
+	}
+	if pathMatched {
+		writeError(w, ErrorMethodNotAllowed)
+		return
+	}
+	writeError(w, ErrorRouteNotFound)
+}
+
+// Return methods of the public routes matching the path
+func (rt *Router) publicRouteMethods(path string) []string {
+	var methods []string
+	for _, route := range rt.routes {
+		if _, ok := matchRoutePattern(route.pattern, path); ok && !isAdminRoute(route.pattern) {
+			methods = append(methods, route.method)
+		}
+	}
+	return methods
+}
+
+// context key for route variables
+type routeVarsKey struct{}
+
+// Return route variables captured for request
+func Vars(r *http.Request) map[string]string {
+	if vars, ok := r.Context().Value(routeVarsKey{}).(map[string]string); ok {
+		return vars
+	}
+	return map[string]string{}
+}
+
+// context key for request id
+type requestIdKey struct{}
+
+// Return request id of request
+func RequestId(r *http.Request) string {
+	if requestId, ok := r.Context().Value(requestIdKey{}).(string); ok {
+		return requestId
+	}
+	return ""
+}
+
+// Generate new random request id
+func newRequestId() string {
+	requestId := make([]byte, 16)
+	rand.Read(requestId)
+	return hex.EncodeToString(requestId)
+}
+
+// Check request id provided by client is short and only
+// contains alphanumeric characters, dashes, underscores or dots
+func isValidRequestId(requestId string) bool {
+	if requestId == "" || len(requestId) > RequestIdMaxLength {
+		return false
+	}
+	for _, c := range requestId {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// Match path against route pattern and return captured route variables
+func matchRoutePattern(pattern string, path string) (map[string]string, bool) {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(patternParts) != len(pathParts) {
+		return nil, false
+	}
+
+	vars := make(map[string]string)
+	for i, part := range patternParts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			if pathParts[i] == "" {
+				return nil, false
+			}
+			vars[part[1:len(part)-1]] = pathParts[i]
+		} else if part != pathParts[i] {
+			return nil, false
+		}
+	}
+	return vars, true
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/log"
+)
+
+// Http server of the request api
+// Requests are served with read, write and idle timeouts and limited header
+// and body sizes, so that slow or oversized requests cannot exhaust the
+// service. On cancellation of the service context the server stops accepting
+// connections and in-flight requests are drained, up to the shutdown timeout,
+// before remaining connections are closed
+
+// error consts
+const (
+	ErrorRequestBodyTooLarge = "Request body too large"
+)
+
+// server defaults
+const (
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultReadTimeout       = 30 * time.Second
+	DefaultWriteTimeout      = 60 * time.Second
+	DefaultIdleTimeout       = 120 * time.Second
+	DefaultShutdownTimeout   = 15 * time.Second
+	DefaultMaxHeaderBytes    = 1 << 16
+	DefaultMaxBodyBytes      = 1 << 20
+)
+
+// ApiServer struct
+// Wraps http server with the timeouts of the api config and graceful shutdown
+type ApiServer struct {
+	name            string
+	srv             *http.Server
+	shutdownTimeout time.Duration
+}
+
+// Return new ApiServer instance serving the handler on the address
+func NewApiServer(name string, addr string, handler http.Handler, config confpkg.ApiConfig) *ApiServer {
+	return &ApiServer{
+		name: name,
+		srv: &http.Server{
+			Addr:              addr,
+			Handler:           handler,
+			ReadHeaderTimeout: DefaultReadHeaderTimeout,
+			ReadTimeout:       configDuration(config.ReadTimeoutSeconds, DefaultReadTimeout),
+			WriteTimeout:      configDuration(config.WriteTimeoutSeconds, DefaultWriteTimeout),
+			IdleTimeout:       configDuration(config.IdleTimeoutSeconds, DefaultIdleTimeout),
+			MaxHeaderBytes:    DefaultMaxHeaderBytes,
+		},
+		shutdownTimeout: configDuration(config.ShutdownTimeoutSeconds, DefaultShutdownTimeout),
+	}
+}
+
+// Return duration of config seconds, or the default if not set
+func configDuration(seconds int, defaultDuration time.Duration) time.Duration {
+	if seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultDuration
+}
+
+// Serve requests, over tls with the certificate files if tls is configured,
+// until the context is cancelled and then shut down gracefully
+func (a *ApiServer) Serve(ctx context.Context, wg *sync.WaitGroup, certFile string, keyFile string) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		log.Infof("%s listening on %s\n", a.name, a.srv.Addr)
+		var err error
+		if a.srv.TLSConfig != nil {
+			err = a.srv.ListenAndServeTLS(certFile, keyFile)
+		} else {
+			err = a.srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Warnln(err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Infof("Shutting down %s...\n", a.name)
+	a.Shutdown()
+}
+
+// Stop accepting connections and drain in-flight requests up to the
+// shutdown timeout, closing any connections remaining after
+func (a *ApiServer) Shutdown() {
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+	defer cancel()
+	if shutdownErr := a.srv.Shutdown(shutdownCtx); shutdownErr != nil {
+		log.Warnf("%s shutdown: %v\n", a.name, shutdownErr)
+		a.srv.Close()
+	}
+}
+
+// Return max request body size of the api config, or the default if not set
+func maxBodyBytes(config confpkg.ApiConfig) int64 {
+	if config.MaxBodyBytes > 0 {
+		return int64(config.MaxBodyBytes)
+	}
+	return DefaultMaxBodyBytes
+}
+
+// Return error of reading request body, distinguishing bodies over the limit
+func requestBodyError(bodyErr error) string {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(bodyErr, &maxBytesErr) {
+		return ErrorRequestBodyTooLarge
+	}
+	return ErrorRequestBody
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test server timeouts of the api config and defaults
+func TestApiServerTimeouts(t *testing.T) {
+	server := NewApiServer("test", ":8080", http.NotFoundHandler(), confpkg.ApiConfig{
+		ReadTimeoutSeconds: -1, WriteTimeoutSeconds: -1, IdleTimeoutSeconds: -1, ShutdownTimeoutSeconds: -1})
+	assert.Equal(t, DefaultReadHeaderTimeout, server.srv.ReadHeaderTimeout)
+	assert.Equal(t, DefaultReadTimeout, server.srv.ReadTimeout)
+	assert.Equal(t, DefaultWriteTimeout, server.srv.WriteTimeout)
+	assert.Equal(t, DefaultIdleTimeout, server.srv.IdleTimeout)
+	assert.Equal(t, DefaultMaxHeaderBytes, server.srv.MaxHeaderBytes)
+	assert.Equal(t, DefaultShutdownTimeout, server.shutdownTimeout)
+
+	server = NewApiServer("test", ":8080", http.NotFoundHandler(), confpkg.ApiConfig{
+		ReadTimeoutSeconds: 5, WriteTimeoutSeconds: 6, IdleTimeoutSeconds: 7, ShutdownTimeoutSeconds: 8})
+	assert.Equal(t, 5*time.Second, server.srv.ReadTimeout)
+	assert.Equal(t, 6*time.Second, server.srv.WriteTimeout)
+	assert.Equal(t, 7*time.Second, server.srv.IdleTimeout)
+	assert.Equal(t, 8*time.Second, server.shutdownTimeout)
+}
+
+// Test server shuts down on cancellation of the context
+func TestApiServerShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	server := NewApiServer("test", "127.0.0.1:0", http.NotFoundHandler(), confpkg.ApiConfig{ShutdownTimeoutSeconds: 1})
+
+	done := make(chan struct{})
+	go func() {
+		server.Serve(ctx, wg, "", "")
+		wg.Wait()
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server not shut down")
+	}
+}
+
+// Test request bodies over the limit are rejected
+func TestRequestBodyLimit(t *testing.T) {
+	service := NewRequestService(nil, nil, db.NewDbFake(), confpkg.ApiConfig{MaxBodyBytes: 64})
+	assert.Equal(t, int64(64), service.maxBodyBytes)
+
+	r, _ := http.NewRequest(POST, RouteCommitmentSend, strings.NewReader(strings.Repeat("a", 65)))
+	response := serveRequest(t, service, r)
+	assert.Equal(t, ErrorRequestBodyTooLarge, response["error"])
+	assert.Equal(t, CodePayloadTooLarge, response["code"])
+
+	r, _ = http.NewRequest(POST, RouteCommitmentSend, bytes.NewReader([]byte("{}")))
+	response = serveRequest(t, service, r)
+	assert.NotEqual(t, ErrorRequestBodyTooLarge, response["error"])
+
+	service = NewRequestService(nil, nil, db.NewDbFake(), confpkg.ApiConfig{})
+	assert.Equal(t, int64(DefaultMaxBodyBytes), service.maxBodyBytes)
+}
+
+// Test api address of the host and port of the api config
+func TestApiAddress(t *testing.T) {
+	assert.Equal(t, ":8080", ApiAddress(confpkg.ApiConfig{}))
+	assert.Equal(t, "127.0.0.1:8080", ApiAddress(confpkg.ApiConfig{Host: "127.0.0.1"}))
+	assert.Equal(t, ":9000", ApiAddress(confpkg.ApiConfig{Port: "9000"}))
+	assert.Equal(t, "[::1]:9000", ApiAddress(confpkg.ApiConfig{Host: "[::1]", Port: "9000"}))
+	assert.Equal(t, "[::1]:9000", ApiAddress(confpkg.ApiConfig{Host: "::1", Port: "9000"}))
+}
@@ -0,0 +1,403 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/db"
+	"mainstay/log"
+	"mainstay/models"
+
+	"github.com/graphql-go/graphql"
+)
+
+// request service defaults
+const (
+	DefaultApiPort = "8080" // port the request api listens on
+
+	ErrorApiPortInvalid = "Invalid api port"
+
+	WarningUnknownProofOps = "Unknown proof ops encoding - using append"
+	WarningSpvProofGet     = "Could not get spv proof - omitted"
+)
+
+// BalanceSource interface
+// Provides the latest staychain balance and whether it is available
+type BalanceSource interface {
+	Balance() (models.Balance, bool)
+}
+
+// FeeSpendSource interface
+// Provides the fees spent on attestations and the fee budgets
+type FeeSpendSource interface {
+	FeeSpend() (models.FeeSpend, error)
+}
+
+// EventSource interface
+// Subscribes to attestation lifecycle events until cancelled
+type EventSource interface {
+	Subscribe() (<-chan models.AttestationEvent, func())
+}
+
+// AttestTrigger interface
+// Triggers an out of schedule attestation
+type AttestTrigger interface {
+	AttestNow()
+}
+
+// AttestPauser interface
+// Pauses and resumes the attestation service
+type AttestPauser interface {
+	Pause() error
+	Resume() error
+	IsPaused() bool
+}
+
+// AttestReviewer interface
+// Exposes and vetoes attestations held for operator review before broadcast
+type AttestReviewer interface {
+	PendingReview() (models.AttestationReview, bool)
+	VetoReview(string) (models.AttestationReview, error)
+}
+
+// IntegrityChecker interface
+// Verifies the chain of stored attestations
+type IntegrityChecker interface {
+	CheckIntegrity() (models.IntegrityReport, error)
+}
+
+// AttestDeriver interface
+// Re-derives the address of historical attestations from stored data
+type AttestDeriver interface {
+	DeriveAttestation(string) (models.AttestationDerivation, error)
+}
+
+// KeyRotator interface
+// Requests and cancels rotations of the federation keys
+type KeyRotator interface {
+	RequestKeyRotation(models.KeyRotation) (models.KeyRotation, error)
+	CancelKeyRotation() (models.KeyRotation, error)
+}
+
+// Timestamper interface
+// Returns RFC 3161 timestamp responses of confirmed proofs
+type Timestamper interface {
+	TimestampResponse(models.ProofBundle) ([]byte, error)
+}
+
+// ProofDeliverer interface
+// Validates proof delivery targets registered by clients
+type ProofDeliverer interface {
+	ValidateTarget(string) error
+}
+
+// SpvSource interface
+// Returns spv proofs of attestation transactions in the blocks confirming them
+type SpvSource interface {
+	SpvProof(string, string) (models.SpvProof, error)
+}
+
+// HealthChecker interface
+// Reports liveness and readiness of the attestation service
+type HealthChecker interface {
+	Liveness() models.HealthReport
+	Readiness() models.HealthReport
+}
+
+// StatusSource interface
+// Reports the state of the attestation state machine
+type StatusSource interface {
+	Status() models.AttestationStatus
+}
+
+// RequestService struct
+// Handles setting a request router and handling api requests
+type RequestService struct {
+	ctx         context.Context
+	wg          *sync.WaitGroup
+	host        string
+	router      *Router
+	dbInterface db.Db
+	config      confpkg.ApiConfig
+
+	// optional router of the admin routes served by a separate admin api
+	adminRouter *Router
+
+	// enabled authentication schemes for commitment requests
+	authSchemes map[string]bool
+	hmacAuth    *HmacAuth
+
+	// ops encoding of the proof bundles returned
+	proofOps string
+
+	// ordering of the client commitments in the commitment merkle tree
+	ordering models.CommitmentOrdering
+
+	// hash function of the commitment and slot group merkle trees
+	merkleHash string
+
+	// optional transition to the next hash function at a switchover round
+	transition *models.ProtocolTransition
+
+	// optional source address and client certificate checks of admin routes
+	adminAccess *AdminAccess
+
+	// optional origins and methods allowed for cross-origin requests
+	cors *Cors
+
+	// max size of request bodies
+	maxBodyBytes int64
+
+	// schema of the graphql query api
+	graphqlSchema graphql.Schema
+
+	// optional source of the staychain balance
+	balanceSource BalanceSource
+
+	// optional source of the attestation fee spend
+	feeSpendSource FeeSpendSource
+
+	// optional source of attestation events streamed to clients
+	eventSource EventSource
+
+	// optional trigger for out of schedule attestations
+	attestTrigger AttestTrigger
+
+	// optional control for pausing attestations
+	attestPauser AttestPauser
+
+	// optional review of attestations before broadcast
+	attestReviewer AttestReviewer
+
+	// optional checker of the attestation chain
+	integrityChecker IntegrityChecker
+
+	// optional checker of service health
+	healthChecker HealthChecker
+
+	// optional re-derivation of historical attestations
+	attestDeriver AttestDeriver
+
+	// optional rotation of the federation keys
+	keyRotator KeyRotator
+
+	// optional timestamp authority of confirmed proofs
+	timestamper Timestamper
+
+	// optional delivery of confirmed proofs to client targets
+	proofDeliverer ProofDeliverer
+
+	// optional source of spv proofs of confirmed attestations
+	spvSource SpvSource
+
+	// optional source of the attestation state machine status
+	statusSource StatusSource
+}
+
+// NewRequestService returns a pointer to a RequestService instance
+func NewRequestService(ctx context.Context, wg *sync.WaitGroup, dbInterface db.Db, config confpkg.ApiConfig) *RequestService {
+	authSchemes := make(map[string]bool)
+	for _, scheme := range config.AuthSchemes {
+		if scheme != AuthSchemeToken && scheme != AuthSchemeHmac {
+			log.Warnf("%s: %s\n", WarningUnknownAuthScheme, scheme)
+			continue
+		}
+		authSchemes[scheme] = true
+	}
+	if len(authSchemes) == 0 {
+		authSchemes[AuthSchemeToken] = true
+	}
+
+	hmacWindow := DefaultHmacReplayWindow
+	if config.HmacReplayWindowSeconds > 0 {
+		hmacWindow = time.Duration(config.HmacReplayWindowSeconds) * time.Second
+	}
+
+	proofOps := models.ProofOpsAppend
+	if config.ProofOps == models.ProofOpsPosition {
+		proofOps = models.ProofOpsPosition
+	} else if config.ProofOps != "" && config.ProofOps != models.ProofOpsAppend {
+		log.Warnf("%s: %s\n", WarningUnknownProofOps, config.ProofOps)
+	}
+
+	if config.AdminClientCaFile != "" && !ApiTlsEnabled(config) {
+		log.Warnln(WarningAdminClientCaNoTls)
+	}
+
+	service := &RequestService{
+		ctx:          ctx,
+		wg:           wg,
+		host:         ApiAddress(config),
+		dbInterface:  dbInterface,
+		config:       config,
+		authSchemes:  authSchemes,
+		hmacAuth:     NewHmacAuth(hmacWindow),
+		proofOps:     proofOps,
+		ordering:     models.CommitmentOrdering{Strategy: models.OrderingPosition},
+		merkleHash:   models.MerkleHashSha256d,
+		adminAccess:  NewAdminAccess(config.AdminAllowedIps, config.AdminClientCaFile),
+		cors:         NewCors(config.CorsAllowedOrigins, config.CorsAllowedMethods),
+		maxBodyBytes: maxBodyBytes(config),
+	}
+	graphqlSchema, graphqlErr := newGraphqlSchema(service)
+	if graphqlErr != nil {
+		log.Error(graphqlErr)
+	}
+	service.graphqlSchema = graphqlSchema
+	service.router = NewRouter(service)
+	if AdminApiEnabled(config) {
+		service.adminRouter = NewAdminRouter(service)
+	}
+	return service
+}
+
+// Return address the request api listens on, from the host, all interfaces
+// if not set, and port of the api config
+func ApiAddress(config confpkg.ApiConfig) string {
+	port := config.Port
+	if port == "" {
+		port = DefaultApiPort
+	}
+	return net.JoinHostPort(confpkg.NormalizeHost(config.Host), port)
+}
+
+// Set ordering of the client commitments declared by the protocol route
+func (s *RequestService) SetCommitmentOrdering(ordering models.CommitmentOrdering) {
+	s.ordering = ordering
+}
+
+// Set hash function of the merkle trees declared by the protocol route,
+// also used to build the merkle trees of slot group members
+func (s *RequestService) SetMerkleHash(hash string) {
+	s.merkleHash = models.MerkleHashName(hash)
+}
+
+// Set transition to the next hash function advertised by the protocol route
+func (s *RequestService) SetTransition(transition models.ProtocolTransition) {
+	s.transition = &transition
+}
+
+// Return hash function of the merkle trees of the next attestation round
+// and the round, the number of confirmed attestations plus one, if there
+// is a protocol transition
+func (s *RequestService) roundMerkleHash() (string, int64, error) {
+	if s.transition == nil {
+		return s.merkleHash, 0, nil
+	}
+	count, countErr := s.dbInterface.GetConfirmedAttestationCount()
+	if countErr != nil {
+		return "", 0, countErr
+	}
+	return s.transition.MerkleHash(count+1, s.merkleHash), count + 1, nil
+}
+
+// Set source of the staychain balance returned by the balance route
+func (s *RequestService) SetBalanceSource(balanceSource BalanceSource) {
+	s.balanceSource = balanceSource
+}
+
+// Set source of the attestation fee spend returned by the fees route
+func (s *RequestService) SetFeeSpendSource(feeSpendSource FeeSpendSource) {
+	s.feeSpendSource = feeSpendSource
+}
+
+// Set source of attestation events streamed by the events route
+func (s *RequestService) SetEventSource(eventSource EventSource) {
+	s.eventSource = eventSource
+}
+
+// Set trigger for out of schedule attestations used by the admin attest route
+func (s *RequestService) SetAttestTrigger(attestTrigger AttestTrigger) {
+	s.attestTrigger = attestTrigger
+}
+
+// Set control for pausing attestations used by the admin pause routes
+func (s *RequestService) SetAttestPauser(attestPauser AttestPauser) {
+	s.attestPauser = attestPauser
+}
+
+// Set review of attestations before broadcast used by the admin review routes
+func (s *RequestService) SetAttestReviewer(attestReviewer AttestReviewer) {
+	s.attestReviewer = attestReviewer
+}
+
+// Set checker of the attestation chain used by the integrity route
+func (s *RequestService) SetIntegrityChecker(integrityChecker IntegrityChecker) {
+	s.integrityChecker = integrityChecker
+}
+
+// Set checker of service health used by the health probe routes
+func (s *RequestService) SetHealthChecker(healthChecker HealthChecker) {
+	s.healthChecker = healthChecker
+}
+
+// Set re-derivation of historical attestations used by the derivation history route
+func (s *RequestService) SetAttestDeriver(attestDeriver AttestDeriver) {
+	s.attestDeriver = attestDeriver
+}
+
+// Set rotation of the federation keys used by the admin rotation routes
+func (s *RequestService) SetKeyRotator(keyRotator KeyRotator) {
+	s.keyRotator = keyRotator
+}
+
+// Set timestamp authority of confirmed proofs used by the commitment timestamp route
+func (s *RequestService) SetTimestamper(timestamper Timestamper) {
+	s.timestamper = timestamper
+}
+
+// Set delivery of confirmed proofs used by the client delivery routes
+func (s *RequestService) SetProofDeliverer(proofDeliverer ProofDeliverer) {
+	s.proofDeliverer = proofDeliverer
+}
+
+// Set source of spv proofs included in the proof bundles of confirmed attestations
+func (s *RequestService) SetSpvSource(spvSource SpvSource) {
+	s.spvSource = spvSource
+}
+
+// Set source of the attestation state machine status used by the status route
+func (s *RequestService) SetStatusSource(statusSource StatusSource) {
+	s.statusSource = statusSource
+}
+
+// Main Run method
+func (s *RequestService) Run() {
+	defer s.wg.Done()
+
+	srv := NewApiServer("Request service", s.host, s.router, s.config)
+	tlsConfig, acmeManager := newApiTlsConfig(s.config)
+	srv.srv.TLSConfig = tlsConfig
+
+	// plain http requests redirected to https
+	if tlsConfig != nil && s.config.HttpRedirectAddr != "" {
+		redirectSrv := NewApiServer("Request service http redirect", s.config.HttpRedirectAddr,
+			newHttpRedirectHandler(s.host, acmeManager), s.config)
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			redirectSrv.Serve(s.ctx, s.wg, "", "")
+		}()
+	}
+
+	// admin routes served by a separate admin api
+	if s.adminRouter != nil {
+		adminSrv := NewApiServer("Admin api", AdminAddress(s.config), s.adminRouter, s.config)
+		adminSrv.srv.TLSConfig = newAdminTlsConfig(tlsConfig, s.adminAccess)
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			adminSrv.Serve(s.ctx, s.wg, s.config.TlsCertFile, s.config.TlsKeyFile)
+		}()
+	}
+
+	// certificate files empty if certificates are obtained by acme
+	srv.Serve(s.ctx, s.wg, s.config.TlsCertFile, s.config.TlsKeyFile)
+}
@@ -0,0 +1,392 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	_ "embed"
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"mainstay/models"
+
+	"github.com/graphql-go/graphql"
+)
+
+// Self-describing request api
+// An OpenAPI v3 document is generated at runtime from the route table, with
+// the path and query parameters of each route and json schemas of the request
+// and response models reflected from their types, and served by the api spec
+// route along with an interactive ui to explore and try the api routes.
+// Unversioned aliases of the versioned api routes are left out of the document
+
+// api spec consts
+const (
+	OpenApiVersion = "3.0.3"
+	ApiSpecTitle   = "Mainstay request api"
+
+	ContentTypeJson = "application/json"
+
+	// schema component name of the error response envelope
+	SchemaErrorResponse = "ErrorResponse"
+)
+
+// interactive api spec ui
+//
+//go:embed requestspec.html
+var apiSpecUi []byte
+
+// content security policy of the api spec ui, allowing its inline script
+// and style and requests to the api only
+const apiSpecUiPolicy = "default-src 'none'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; " +
+	"connect-src 'self'; frame-ancestors 'none'"
+
+// OpenApiDocument struct
+// OpenAPI v3 document of the request api
+type OpenApiDocument struct {
+	OpenApi    string                                  `json:"openapi"`
+	Info       OpenApiInfo                             `json:"info"`
+	Paths      map[string]map[string]*OpenApiOperation `json:"paths"`
+	Components OpenApiComponents                       `json:"components"`
+}
+
+// OpenApiInfo struct
+type OpenApiInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenApiComponents struct
+// Schemas of the models referenced by the operations and security schemes
+type OpenApiComponents struct {
+	Schemas         map[string]*OpenApiSchema        `json:"schemas"`
+	SecuritySchemes map[string]OpenApiSecurityScheme `json:"securitySchemes"`
+}
+
+// OpenApiSecurityScheme struct
+type OpenApiSecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme"`
+}
+
+// OpenApiOperation struct
+// Operation of a route method
+type OpenApiOperation struct {
+	OperationId string                     `json:"operationId"`
+	Summary     string                     `json:"summary"`
+	Tags        []string                   `json:"tags"`
+	Parameters  []OpenApiParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenApiRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]OpenApiResponse `json:"responses"`
+	Security    []map[string][]string      `json:"security,omitempty"`
+}
+
+// OpenApiParameter struct
+type OpenApiParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required"`
+	Schema   *OpenApiSchema `json:"schema"`
+}
+
+// OpenApiRequestBody struct
+type OpenApiRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]OpenApiMediaType `json:"content"`
+}
+
+// OpenApiResponse struct
+type OpenApiResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenApiMediaType `json:"content,omitempty"`
+}
+
+// OpenApiMediaType struct
+type OpenApiMediaType struct {
+	Schema *OpenApiSchema `json:"schema"`
+}
+
+// OpenApiSchema struct
+// Json schema of a model, or a reference to the schema component of a model
+type OpenApiSchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty"`
+	Properties           map[string]*OpenApiSchema `json:"properties,omitempty"`
+	Items                *OpenApiSchema            `json:"items,omitempty"`
+	AdditionalProperties *OpenApiSchema            `json:"additionalProperties,omitempty"`
+}
+
+// routeSpec struct
+// Description of a route used to generate its operation
+type routeSpec struct {
+	summary     string
+	request     interface{} // json request body model, if any
+	response    interface{} // model of the response envelope, if json
+	contentType string      // content type of non json responses
+	query       []string    // optional query parameters
+	hosted      bool        // response in the hosted api envelope
+	raw         bool        // response not in the response envelope
+}
+
+// description of each route by route name
+var routeSpecs = map[string]routeSpec{
+	RouteNameIndex:                  {summary: "Service banner", contentType: "text/plain"},
+	RouteNameCommitmentSend:         {summary: "Send client commitment", request: CommitmentSendRequest{}, response: models.CommitmentSubmission{}},
+	RouteNameCommitmentSendBulk:     {summary: "Send commitments of many clients", request: CommitmentBulkRequest{}, response: []CommitmentBulkResult{}},
+	RouteNameBalance:                {summary: "Latest staychain balance", response: models.Balance{}},
+	RouteNameFees:                   {summary: "Fees spent on attestations and fee budgets", response: models.FeeSpend{}},
+	RouteNameEvents:                 {summary: "Stream of attestation events", contentType: "text/event-stream"},
+	RouteNameSlotGroupProof:         {summary: "Proof of slot group member commitment", response: models.ProofBundle{}},
+	RouteNameCommitmentProof:        {summary: "Proof of client commitment", response: models.ProofBundle{}, query: []string{QueryHash}},
+	RouteNameCommitmentProofBinary:  {summary: "Binary proof of client commitment", response: models.ProofBinaryResponse{}},
+	RouteNameCommitmentTimestamp:    {summary: "RFC 3161 timestamp of client commitment", contentType: "application/timestamp-reply"},
+	RouteNameCommitmentSubmission:   {summary: "Status of commitment submission", response: models.CommitmentSubmission{}},
+	RouteNameCommitmentExclusions:   {summary: "Commitments of client excluded from attestations", response: []models.CommitmentExclusion{}},
+	RouteNameCommitmentHistory:      {summary: "Commitment history of client", response: models.CommitmentHistory{}, query: []string{QueryOffset, QueryLimit}},
+	RouteNameLatestProof:            {summary: "Proof of latest attested client commitment", response: models.ProofBundle{}},
+	RouteNameClientDelivery:         {summary: "Register proof delivery target of client", request: ClientDeliveryRequest{}, response: models.ClientDeliveryResponse{}},
+	RouteNameClientDeliveryRemove:   {summary: "Remove proof delivery target of client", response: models.ClientDeliveryResponse{}},
+	RouteNameProofSchema:            {summary: "Json schema of proof bundles", contentType: "application/schema+json"},
+	RouteNameProtocol:               {summary: "Protocol parameters of proof bundles", response: models.ProtocolResponse{}},
+	RouteNameKeyRotations:           {summary: "Active rotations of the federation keys", response: models.KeyRotationsResponse{}},
+	RouteNameSigners:                {summary: "Status of the attestation signers", response: models.SignersResponse{}},
+	RouteNameStatus:                 {summary: "Status of the staychain, attestations and signers", response: models.StatusResponse{}},
+	RouteNameHostedCommitment:       {summary: "Attestation of commitment (hosted api)", response: models.HostedCommitmentResponse{}, query: []string{QueryHostedCommitment}, hosted: true},
+	RouteNameHostedLatestProof:      {summary: "Latest proof of position (hosted api)", response: models.HostedLatestProofResponse{}, query: []string{QueryHostedPosition}, hosted: true},
+	RouteNameHostedVerify:           {summary: "Verify commitment of position (hosted api)", response: models.HostedVerifyResponse{}, query: []string{QueryHostedPosition, QueryHostedCommitment}, hosted: true},
+	RouteNameHealthz:                {summary: "Liveness probe", response: models.HealthReport{}},
+	RouteNameReadyz:                 {summary: "Readiness probe", response: models.HealthReport{}},
+	RouteNameDerivationHistory:      {summary: "Derivation of historical attestation", response: models.AttestationDerivation{}},
+	RouteNameAttestation:            {summary: "Attestation of transaction", response: models.AttestationResponse{}},
+	RouteNameStaychain:              {summary: "Staychain transactions", response: models.StaychainTxs{}, query: []string{QueryOffset, QueryLimit}},
+	RouteNameStaychainTx:            {summary: "Staychain transaction", response: models.StaychainTx{}},
+	RouteNameApiSpec:                {summary: "OpenAPI document of the request api", contentType: ContentTypeJson},
+	RouteNameApiSpecUi:              {summary: "Interactive ui of the request api", contentType: "text/html"},
+	RouteNameGraphql:                {summary: "GraphQL query of attestations, commitments and proofs", request: GraphqlRequest{}, response: graphql.Result{}, raw: true},
+	RouteNameGraphqlQuery:           {summary: "GraphQL query of attestations, commitments and proofs", response: graphql.Result{}, query: []string{QueryGraphql, QueryGraphqlOperationName, QueryGraphqlVariables}, raw: true},
+	RouteNameAdminClientHmac:        {summary: "Issue hmac secret of client", response: models.ClientHmacResponse{}},
+	RouteNameAdminClientHmacRevoke:  {summary: "Revoke hmac secret of client", response: ""},
+	RouteNameAdminAttest:            {summary: "Trigger attestation", response: ""},
+	RouteNameAdminPause:             {summary: "Pause attestations", response: models.StateResponse{}},
+	RouteNameAdminResume:            {summary: "Resume attestations", response: models.StateResponse{}},
+	RouteNameAdminReview:            {summary: "Pending attestation review", response: models.ReviewResponse{}},
+	RouteNameAdminReviewVeto:        {summary: "Veto pending attestation", response: models.ReviewResponse{}, query: []string{"txid"}},
+	RouteNameAdminClientGroup:       {summary: "Register client as slot group", response: models.ClientGroupResponse{}},
+	RouteNameAdminClientGroupRemove: {summary: "Remove slot group of client", response: models.ClientGroupResponse{}},
+	RouteNameAdminRotation:          {summary: "Rotations of the federation keys", response: models.KeyRotationsResponse{}},
+	RouteNameAdminRotationRequest:   {summary: "Request rotation of the federation keys", request: models.KeyRotation{}, response: models.KeyRotation{}},
+	RouteNameAdminRotationCancel:    {summary: "Cancel pending key rotation", response: models.KeyRotation{}},
+	RouteNameAdminRounds:            {summary: "Snapshots of attestation rounds", response: models.RoundSnapshots{}, query: []string{QueryRoot, QueryOffset, QueryLimit}},
+	RouteNameAdminRound:             {summary: "Snapshot of attestation round", response: models.RoundSnapshot{}},
+	RouteNameAdminIntegrity:         {summary: "Integrity report of the attestation chain", response: models.IntegrityReport{}},
+}
+
+// route variables with integer values
+var integerRouteVars = map[string]bool{"position": true, "round": true}
+
+// route variables of route patterns
+var routeVarPattern = regexp.MustCompile(`\{([a-z]+)\}`)
+
+// Return OpenAPI document of the routes, leaving out unversioned aliases of
+// versioned api routes
+func NewOpenApiDocument(routes []Route) OpenApiDocument {
+	doc := OpenApiDocument{
+		OpenApi: OpenApiVersion,
+		Info:    OpenApiInfo{Title: ApiSpecTitle, Version: ApiVersionDefault},
+		Paths:   make(map[string]map[string]*OpenApiOperation),
+		Components: OpenApiComponents{
+			Schemas: make(map[string]*OpenApiSchema),
+			SecuritySchemes: map[string]OpenApiSecurityScheme{
+				"bearer": {Type: "http", Scheme: "bearer"},
+			},
+		},
+	}
+	doc.schema(reflect.TypeOf(models.ErrorResponse{})) // SchemaErrorResponse component
+
+	for _, route := range routes {
+		version := routeVersion(route.pattern)
+		if strings.HasPrefix(route.pattern, RouteApiPrefix) && version == "" {
+			continue
+		}
+		if doc.Paths[route.pattern] == nil {
+			doc.Paths[route.pattern] = make(map[string]*OpenApiOperation)
+		}
+		doc.Paths[route.pattern][strings.ToLower(route.method)] = doc.operation(route, version)
+	}
+	return doc
+}
+
+// Return operation of route of the api version
+func (doc *OpenApiDocument) operation(route Route, version string) *OpenApiOperation {
+	spec := routeSpecs[route.name]
+	operationId := route.name
+	if version != "" {
+		operationId = version + route.name
+	}
+	operation := &OpenApiOperation{
+		OperationId: operationId,
+		Summary:     spec.summary,
+		Tags:        []string{routeTag(route.pattern)},
+		Responses: map[string]OpenApiResponse{
+			"default": {Description: "Error", Content: map[string]OpenApiMediaType{
+				ContentTypeJson: {Schema: &OpenApiSchema{Ref: schemaRef(SchemaErrorResponse)}}}},
+		},
+	}
+	for _, match := range routeVarPattern.FindAllStringSubmatch(route.pattern, -1) {
+		schema := &OpenApiSchema{Type: "string"}
+		if integerRouteVars[match[1]] {
+			schema = &OpenApiSchema{Type: "integer", Format: "int32"}
+		}
+		operation.Parameters = append(operation.Parameters,
+			OpenApiParameter{Name: match[1], In: "path", Required: true, Schema: schema})
+	}
+	for _, query := range spec.query {
+		operation.Parameters = append(operation.Parameters,
+			OpenApiParameter{Name: query, In: "query", Schema: &OpenApiSchema{Type: "string"}})
+	}
+	if spec.request != nil {
+		operation.RequestBody = &OpenApiRequestBody{Required: true, Content: map[string]OpenApiMediaType{
+			ContentTypeJson: {Schema: doc.schema(reflect.TypeOf(spec.request))}}}
+	}
+	if isAdminRoute(route.pattern) {
+		operation.Security = []map[string][]string{{"bearer": {}}}
+	}
+
+	success := OpenApiResponse{Description: "Success"}
+	if spec.response != nil && spec.raw {
+		success.Content = map[string]OpenApiMediaType{ContentTypeJson: {Schema: doc.schema(reflect.TypeOf(spec.response))}}
+	} else if spec.response != nil {
+		envelope := &OpenApiSchema{Type: "object", Properties: map[string]*OpenApiSchema{
+			"response": doc.schema(reflect.TypeOf(spec.response))}}
+		if spec.hosted {
+			envelope.Properties["timestamp"] = &OpenApiSchema{Type: "integer", Format: "int64"}
+		}
+		success.Content = map[string]OpenApiMediaType{ContentTypeJson: {Schema: envelope}}
+	} else if spec.contentType != "" {
+		success.Content = map[string]OpenApiMediaType{spec.contentType: {Schema: &OpenApiSchema{Type: "string"}}}
+	}
+	operation.Responses["200"] = success
+	return operation
+}
+
+// Return tag grouping the operations of the route pattern
+func routeTag(pattern string) string {
+	if isAdminRoute(pattern) {
+		return "admin"
+	} else if strings.HasPrefix(pattern, RouteApiPrefix) {
+		return "api"
+	}
+	return "service"
+}
+
+// Return reference to the schema component
+func schemaRef(name string) string {
+	return "#/components/schemas/" + name
+}
+
+// types with custom json encodings
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+)
+
+// Return json schema of the type, adding schema components of named structs
+func (doc *OpenApiDocument) schema(t reflect.Type) *OpenApiSchema {
+	switch {
+	case t == timeType:
+		return &OpenApiSchema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return &OpenApiSchema{}
+	case t.Implements(textMarshalerType):
+		return &OpenApiSchema{Type: "string"}
+	case t.Implements(jsonMarshalerType):
+		return &OpenApiSchema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := *doc.schema(t.Elem())
+		if schema.Ref != "" {
+			return &schema
+		}
+		schema.Nullable = true
+		return &schema
+	case reflect.Bool:
+		return &OpenApiSchema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &OpenApiSchema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &OpenApiSchema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &OpenApiSchema{Type: "number"}
+	case reflect.String:
+		return &OpenApiSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return &OpenApiSchema{Type: "string", Format: "byte"}
+		}
+		return &OpenApiSchema{Type: "array", Items: doc.schema(t.Elem())}
+	case reflect.Map:
+		return &OpenApiSchema{Type: "object", AdditionalProperties: doc.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return doc.structSchema(t)
+		}
+		if _, ok := doc.Components.Schemas[t.Name()]; !ok {
+			doc.Components.Schemas[t.Name()] = &OpenApiSchema{} // placeholder for recursive types
+			doc.Components.Schemas[t.Name()] = doc.structSchema(t)
+		}
+		return &OpenApiSchema{Ref: schemaRef(t.Name())}
+	}
+	return &OpenApiSchema{}
+}
+
+// Return json schema of the exported fields of the struct type, with
+// embedded struct fields inlined as by json encoding
+func (doc *OpenApiDocument) structSchema(t reflect.Type) *OpenApiSchema {
+	schema := &OpenApiSchema{Type: "object", Properties: make(map[string]*OpenApiSchema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for embeddedName, embedded := range doc.structSchema(field.Type).Properties {
+				schema.Properties[embeddedName] = embedded
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = doc.schema(field.Type)
+	}
+	return schema
+}
+
+// Api spec request handler
+// Returns the OpenAPI document generated from the route table
+func HandleApiSpec(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	writeResponseStatus(w, http.StatusOK, NewOpenApiDocument(s.router.routes))
+}
+
+// Api spec ui request handler
+// Returns the interactive ui of the api spec
+func HandleApiSpecUi(w http.ResponseWriter, r *http.Request, s *RequestService) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", apiSpecUiPolicy)
+	w.WriteHeader(http.StatusOK)
+	w.Write(apiSpecUi)
+}
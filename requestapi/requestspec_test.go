@@ -0,0 +1,123 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	confpkg "mainstay/config"
+	"mainstay/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Return schema component names referenced by the schema
+func schemaRefs(schema *OpenApiSchema) []string {
+	if schema == nil {
+		return nil
+	}
+	var refs []string
+	if schema.Ref != "" {
+		refs = append(refs, strings.TrimPrefix(schema.Ref, "#/components/schemas/"))
+	}
+	for _, property := range schema.Properties {
+		refs = append(refs, schemaRefs(property)...)
+	}
+	refs = append(refs, schemaRefs(schema.Items)...)
+	return append(refs, schemaRefs(schema.AdditionalProperties)...)
+}
+
+// Test OpenAPI document generated from the route table
+func TestOpenApiDocument(t *testing.T) {
+	for _, route := range routes {
+		assert.NotEqual(t, "", routeSpecs[route.name].summary, route.name)
+	}
+
+	doc := NewOpenApiDocument(versionedRoutes(routes))
+	assert.Equal(t, OpenApiVersion, doc.OpenApi)
+	assert.Equal(t, ApiVersion1, doc.Info.Version)
+
+	// unversioned aliases left out
+	assert.Nil(t, doc.Paths[RouteCommitmentSend])
+	assert.NotNil(t, doc.Paths["/api/v1/commitment/send/"][strings.ToLower(POST)])
+	assert.NotNil(t, doc.Paths[RouteHostedVerify]["get"])
+	assert.NotNil(t, doc.Paths[RouteHealthz]["get"])
+
+	proof := doc.Paths["/api/v1/commitment/proof/{position}/{commitment}/"]["get"]
+	assert.Equal(t, "v1"+RouteNameCommitmentProof, proof.OperationId)
+	assert.Equal(t, []OpenApiParameter{
+		{Name: "position", In: "path", Required: true, Schema: &OpenApiSchema{Type: "integer", Format: "int32"}},
+		{Name: "commitment", In: "path", Required: true, Schema: &OpenApiSchema{Type: "string"}},
+		{Name: QueryHash, In: "query", Schema: &OpenApiSchema{Type: "string"}},
+	}, proof.Parameters)
+	assert.Equal(t, schemaRef("ProofBundle"),
+		proof.Responses["200"].Content[ContentTypeJson].Schema.Properties["response"].Ref)
+	assert.Equal(t, schemaRef(SchemaErrorResponse), proof.Responses["default"].Content[ContentTypeJson].Schema.Ref)
+
+	bundle := doc.Components.Schemas["ProofBundle"]
+	assert.Equal(t, &OpenApiSchema{Type: "integer", Format: "int32", Nullable: true}, bundle.Properties["leaf"])
+	assert.Equal(t, "array", bundle.Properties["ops"].Type)
+	errorResponse := doc.Components.Schemas[SchemaErrorResponse]
+	assert.Equal(t, 3, len(errorResponse.Properties))
+
+	send := doc.Paths["/api/v1/commitment/send/"]["post"]
+	assert.Equal(t, schemaRef("CommitmentSendRequest"), send.RequestBody.Content[ContentTypeJson].Schema.Ref)
+	assert.Nil(t, send.Security)
+	assert.Equal(t, []map[string][]string{{"bearer": {}}}, doc.Paths[RouteAdminAttest]["post"].Security)
+	assert.Equal(t, []string{"admin"}, doc.Paths[RouteAdminAttest]["post"].Tags)
+
+	hosted := doc.Paths[RouteHostedVerify]["get"].Responses["200"].Content[ContentTypeJson].Schema
+	assert.Equal(t, "integer", hosted.Properties["timestamp"].Type)
+
+	// every schema reference resolves to a schema component
+	for path, operations := range doc.Paths {
+		for method, operation := range operations {
+			var refs []string
+			for _, response := range operation.Responses {
+				for _, content := range response.Content {
+					refs = append(refs, schemaRefs(content.Schema)...)
+				}
+			}
+			if operation.RequestBody != nil {
+				refs = append(refs, schemaRefs(operation.RequestBody.Content[ContentTypeJson].Schema)...)
+			}
+			for _, ref := range refs {
+				assert.NotNil(t, doc.Components.Schemas[ref], path+" "+method+" "+ref)
+			}
+		}
+	}
+	for name, schema := range doc.Components.Schemas {
+		for _, ref := range schemaRefs(schema) {
+			assert.NotNil(t, doc.Components.Schemas[ref], name+" "+ref)
+		}
+	}
+}
+
+// Test api spec and ui routes
+func TestApiSpecRequests(t *testing.T) {
+	service := NewRequestService(nil, nil, db.NewDbFake(), confpkg.ApiConfig{})
+
+	for _, path := range []string{RouteApiSpec, "/api/v1/spec/"} {
+		r, _ := http.NewRequest(GET, path, nil)
+		writer := httptest.NewRecorder()
+		service.router.ServeHTTP(writer, r)
+		assert.Equal(t, http.StatusOK, writer.Code)
+		var doc OpenApiDocument
+		assert.Equal(t, nil, json.NewDecoder(writer.Body).Decode(&doc))
+		assert.Equal(t, OpenApiVersion, doc.OpenApi)
+		assert.NotNil(t, doc.Paths["/api/v1/spec/"]["get"])
+	}
+
+	r, _ := http.NewRequest(GET, RouteApiSpecUi, nil)
+	writer := httptest.NewRecorder()
+	service.router.ServeHTTP(writer, r)
+	assert.Equal(t, http.StatusOK, writer.Code)
+	assert.Equal(t, apiSpecUiPolicy, writer.Header().Get("Content-Security-Policy"))
+	assert.Contains(t, writer.Body.String(), "<title>Mainstay request api</title>")
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+
+	confpkg "mainstay/config"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Native tls termination of the request api
+// The api is served over https with the configured certificate and key files
+// or with certificates obtained from an acme provider, e.g. Let's Encrypt, for
+// the configured domains. Plain http requests on the redirect address are
+// redirected to https, answering acme http challenges on the way
+
+// error / warning consts
+const (
+	ErrorApiTlsAcmeExclusive = "Api tls certificate files and acme domains are exclusive"
+
+	WarningAcmeCacheDir      = "Acme cache dir not set - certificates requested on every restart"
+	WarningHttpRedirectNoTls = "Http redirect requires tls - ignored"
+)
+
+// modern tls defaults, forward secret aead cipher suites for tls 1.2 with
+// tls 1.3 suites not configurable and always enabled
+var tlsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+}
+
+// curves preferred for key exchange
+var tlsCurvePreferences = []tls.CurveID{tls.X25519, tls.CurveP256}
+
+// Return whether the api is served over tls, either with certificate
+// files or certificates obtained by acme
+func ApiTlsEnabled(config confpkg.ApiConfig) bool {
+	return config.TlsCertFile != "" || len(config.AcmeDomains) > 0
+}
+
+// Return tls config of the request api, or nil if tls is not enabled, and
+// the acme certificate manager if certificates are obtained by acme.
+// Client certificates are requested but only verified for admin routes
+func newApiTlsConfig(config confpkg.ApiConfig) (*tls.Config, *autocert.Manager) {
+	if !ApiTlsEnabled(config) {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		CipherSuites:     tlsCipherSuites,
+		CurvePreferences: tlsCurvePreferences,
+		ClientAuth:       tls.RequestClientCert,
+	}
+	if config.TlsCertFile != "" {
+		return tlsConfig, nil
+	}
+
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(config.AcmeDomains...),
+		Email:      config.AcmeEmail,
+	}
+	if config.AcmeCacheDir != "" {
+		manager.Cache = autocert.DirCache(config.AcmeCacheDir)
+	}
+	tlsConfig.GetCertificate = manager.GetCertificate
+	tlsConfig.NextProtos = []string{"h2", "http/1.1", "acme-tls/1"}
+	return tlsConfig, manager
+}
+
+// Return handler redirecting plain http requests to the https address,
+// answering acme http challenges first if certificates are obtained by acme
+func newHttpRedirectHandler(httpsAddr string, manager *autocert.Manager) http.Handler {
+	_, httpsPort, _ := net.SplitHostPort(httpsAddr)
+	redirect := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, splitErr := net.SplitHostPort(host); splitErr == nil {
+			host = h
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+	if manager != nil {
+		return manager.HTTPHandler(redirect)
+	}
+	return redirect
+}
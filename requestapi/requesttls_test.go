@@ -0,0 +1,58 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	confpkg "mainstay/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test tls config of certificate files and acme domains
+func TestApiTlsConfig(t *testing.T) {
+	tlsConfig, manager := newApiTlsConfig(confpkg.ApiConfig{})
+	assert.Nil(t, tlsConfig)
+	assert.Nil(t, manager)
+
+	tlsConfig, manager = newApiTlsConfig(confpkg.ApiConfig{TlsCertFile: "/certs/api.pem", TlsKeyFile: "/certs/api.key"})
+	assert.Nil(t, manager)
+	assert.Equal(t, uint16(tls.VersionTLS12), tlsConfig.MinVersion)
+	assert.Equal(t, tlsCipherSuites, tlsConfig.CipherSuites)
+	assert.Equal(t, tls.RequestClientCert, tlsConfig.ClientAuth)
+	assert.Nil(t, tlsConfig.GetCertificate)
+
+	tlsConfig, manager = newApiTlsConfig(confpkg.ApiConfig{AcmeDomains: []string{"api.example.com"},
+		AcmeCacheDir: t.TempDir()})
+	assert.NotNil(t, manager)
+	assert.NotNil(t, tlsConfig.GetCertificate)
+	assert.Contains(t, tlsConfig.NextProtos, "acme-tls/1")
+	assert.Equal(t, nil, manager.HostPolicy(nil, "api.example.com"))
+	assert.NotEqual(t, nil, manager.HostPolicy(nil, "other.example.com"))
+}
+
+// Test plain http requests are redirected to the https address
+func TestHttpRedirect(t *testing.T) {
+	for _, test := range []struct {
+		httpsAddr string
+		host      string
+		location  string
+	}{
+		{":443", "api.example.com", "https://api.example.com/api/fees/?from=1"},
+		{":443", "api.example.com:80", "https://api.example.com/api/fees/?from=1"},
+		{":8443", "api.example.com:8080", "https://api.example.com:8443/api/fees/?from=1"},
+	} {
+		r, _ := http.NewRequest(GET, "/api/fees/?from=1", nil)
+		r.Host = test.host
+		writer := httptest.NewRecorder()
+		newHttpRedirectHandler(test.httpsAddr, nil).ServeHTTP(writer, r)
+		assert.Equal(t, http.StatusMovedPermanently, writer.Code)
+		assert.Equal(t, test.location, writer.Header().Get("Location"))
+	}
+}
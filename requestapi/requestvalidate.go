@@ -0,0 +1,64 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Strict validation of the commitments submitted by clients
+// Commitments must be exactly 32 bytes of hex in either case and not all
+// zero, as shorter strings would otherwise be zero padded when parsed. Each
+// failure has a machine-readable error code, returned with the error of the
+// response, so that client integrations can handle failures deterministically
+
+// commitment validation error codes
+const (
+	CodeCommitmentLength = "commitment_length"
+	CodeCommitmentHex    = "commitment_hex"
+	CodeCommitmentZero   = "commitment_zero"
+)
+
+// CommitmentError struct
+// Invalid commitment error with the code of the validation failure
+type CommitmentError struct {
+	Code string
+}
+
+// Return error message of invalid commitments
+func (e *CommitmentError) Error() string {
+	return ErrorCommitmentInvalid
+}
+
+// Parse submitted commitment of 64 hex characters, normalized to lower case
+func ParseCommitment(commitment string) (chainhash.Hash, error) {
+	if len(commitment) != 2*chainhash.HashSize {
+		return chainhash.Hash{}, &CommitmentError{CodeCommitmentLength}
+	}
+	commitment = strings.ToLower(commitment)
+	if _, hexErr := hex.DecodeString(commitment); hexErr != nil {
+		return chainhash.Hash{}, &CommitmentError{CodeCommitmentHex}
+	}
+	hash, hashErr := chainhash.NewHashFromStr(commitment)
+	if hashErr != nil {
+		return chainhash.Hash{}, &CommitmentError{CodeCommitmentHex}
+	} else if *hash == (chainhash.Hash{}) {
+		return chainhash.Hash{}, &CommitmentError{CodeCommitmentZero}
+	}
+	return *hash, nil
+}
+
+// Return error code of the error, empty for errors without code
+func errorCode(err error) string {
+	var commitmentErr *CommitmentError
+	if errors.As(err, &commitmentErr) {
+		return commitmentErr.Code
+	}
+	return ""
+}
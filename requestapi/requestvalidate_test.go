@@ -0,0 +1,42 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test strict parsing of submitted commitments
+func TestParseCommitment(t *testing.T) {
+	commitment, commitmentErr := ParseCommitment(testCommitment)
+	assert.Equal(t, nil, commitmentErr)
+	assert.Equal(t, testCommitment, commitment.String())
+
+	// case normalized
+	commitment, commitmentErr = ParseCommitment(strings.ToUpper(testCommitment))
+	assert.Equal(t, nil, commitmentErr)
+	assert.Equal(t, testCommitment, commitment.String())
+
+	for _, invalid := range []struct {
+		commitment string
+		code       string
+	}{
+		{"", CodeCommitmentLength},
+		{"1a39", CodeCommitmentLength},
+		{testCommitment + "00", CodeCommitmentLength},
+		{" " + testCommitment[1:], CodeCommitmentHex},
+		{"zz" + testCommitment[2:], CodeCommitmentHex},
+		{strings.Repeat("0", 64), CodeCommitmentZero},
+	} {
+		_, commitmentErr = ParseCommitment(invalid.commitment)
+		assert.Equal(t, ErrorCommitmentInvalid, commitmentErr.Error())
+		assert.Equal(t, invalid.code, errorCode(commitmentErr))
+	}
+	assert.Equal(t, "", errorCode(errors.New(ErrorCommitmentInvalid)))
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Versioning of the request api
+// Public api routes are served under the prefix of each api version, e.g.
+// /api/v1/commitment/send/, so that breaking changes to request or proof
+// formats are introduced under a new version without breaking deployed
+// clients. Unversioned /api/ routes remain, with the version negotiated from
+// the vendor media type of the Accept header, e.g.
+// application/vnd.mainstay.v1+json, and defaulting to v1 so that existing
+// clients keep the formats they were built against. The version served is
+// returned in the api version header of every api response
+
+// error consts
+const (
+	ErrorApiVersionUnsupported = "Unsupported api version"
+)
+
+// api version consts
+const (
+	ApiVersion1       = "v1"
+	ApiVersionDefault = ApiVersion1 // version of unversioned requests
+
+	RouteApiPrefix = "/api/" // prefix of the public api route patterns
+
+	HeaderAccept     = "Accept"
+	HeaderApiVersion = "X-MAINSTAY-API-VERSION"
+
+	ApiMediaTypePrefix = "application/vnd.mainstay."
+	ApiMediaTypeSuffix = "+json"
+)
+
+// supported api versions
+var ApiVersions = []string{ApiVersion1}
+
+// Return route table with the unversioned public api routes also served
+// under the prefix of each api version. Routes already under a version
+// prefix, e.g. the hosted api routes, are kept as they are
+func versionedRoutes(routes []Route) []Route {
+	versioned := append([]Route{}, routes...)
+	for _, version := range ApiVersions {
+		for _, route := range routes {
+			if !strings.HasPrefix(route.pattern, RouteApiPrefix) || routeVersion(route.pattern) != "" {
+				continue
+			}
+			route.pattern = RouteApiPrefix + version + "/" + strings.TrimPrefix(route.pattern, RouteApiPrefix)
+			versioned = append(versioned, route)
+		}
+	}
+	return versioned
+}
+
+// Return api version of the route pattern, or "" if unversioned
+func routeVersion(pattern string) string {
+	rest := strings.TrimPrefix(pattern, RouteApiPrefix)
+	if rest == pattern {
+		return ""
+	}
+	version := strings.SplitN(rest, "/", 2)[0]
+	if !isApiVersion(version) {
+		return ""
+	}
+	return version
+}
+
+// Return whether the version is a supported api version
+func isApiVersion(version string) bool {
+	for _, v := range ApiVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// Return api version requested by the vendor media type of the Accept
+// header, or the default version if none is requested
+func negotiateApiVersion(r *http.Request) (string, error) {
+	for _, mediaRange := range strings.Split(r.Header.Get(HeaderAccept), ",") {
+		mediaType := strings.TrimSpace(strings.SplitN(mediaRange, ";", 2)[0])
+		if !strings.HasPrefix(mediaType, ApiMediaTypePrefix) || !strings.HasSuffix(mediaType, ApiMediaTypeSuffix) {
+			continue
+		}
+		version := strings.TrimSuffix(strings.TrimPrefix(mediaType, ApiMediaTypePrefix), ApiMediaTypeSuffix)
+		if !isApiVersion(version) {
+			return "", errors.New(fmt.Sprintf("%s: %s", ErrorApiVersionUnsupported, version))
+		}
+		return version, nil
+	}
+	return ApiVersionDefault, nil
+}
+
+// context key for api version
+type apiVersionKey struct{}
+
+// Return api version served for request, or "" for routes outside the api
+func ApiVersion(r *http.Request) string {
+	if version, ok := r.Context().Value(apiVersionKey{}).(string); ok {
+		return version
+	}
+	return ""
+}
+
+// Set api version of public api route requests, from the route pattern or
+// negotiated for unversioned routes, and return request with the version
+func setApiVersion(w http.ResponseWriter, r *http.Request, pattern string) (*http.Request, error) {
+	if !strings.HasPrefix(pattern, RouteApiPrefix) {
+		return r, nil
+	}
+	version := routeVersion(pattern)
+	if version == "" {
+		w.Header().Add("Vary", HeaderAccept)
+		var versionErr error
+		if version, versionErr = negotiateApiVersion(r); versionErr != nil {
+			return r, versionErr
+		}
+	}
+	w.Header().Set(HeaderApiVersion, version)
+	return r.WithContext(context.WithValue(r.Context(), apiVersionKey{}, version)), nil
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package requestapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	confpkg "mainstay/config"
+	"mainstay/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test public api routes are also routed under each api version
+func TestVersionedRoutes(t *testing.T) {
+	versioned := versionedRoutes(routes)
+	patterns := make(map[string]int)
+	unversionedApi := 0
+	for _, route := range routes {
+		if strings.HasPrefix(route.pattern, RouteApiPrefix) && routeVersion(route.pattern) == "" {
+			unversionedApi++
+		}
+	}
+	for _, route := range versioned {
+		patterns[route.method+" "+route.pattern]++
+	}
+	assert.Equal(t, len(routes)+unversionedApi*len(ApiVersions), len(versioned))
+	for pattern, count := range patterns {
+		assert.Equal(t, 1, count, pattern)
+	}
+
+	assert.Equal(t, 1, patterns[POST+" /api/v1/commitment/send/"])
+	assert.Equal(t, 1, patterns[GET+" /api/v1/commitment/proof/{position}/{commitment}/"])
+	assert.Equal(t, 1, patterns[GET+" "+RouteHostedCommitment])
+	assert.Equal(t, 0, patterns[GET+" /api/v1/v1/commitment/commitment/"])
+	assert.Equal(t, 0, patterns[POST+" /api/v1/admin/attest/"])
+
+	assert.Equal(t, ApiVersion1, routeVersion("/api/v1/commitment/send/"))
+	assert.Equal(t, "", routeVersion(RouteCommitmentSend))
+	assert.Equal(t, "", routeVersion(RouteHealthz))
+}
+
+// Test negotiation of the api version of unversioned requests
+func TestNegotiateApiVersion(t *testing.T) {
+	for accept, expected := range map[string]string{
+		"":                                 ApiVersionDefault,
+		"application/json":                 ApiVersionDefault,
+		"application/vnd.mainstay.v1+json": ApiVersion1,
+		"text/html, application/vnd.mainstay.v1+json; q=0.9": ApiVersion1,
+	} {
+		r, _ := http.NewRequest(GET, RouteProofSchema, nil)
+		r.Header.Set(HeaderAccept, accept)
+		version, versionErr := negotiateApiVersion(r)
+		assert.Equal(t, nil, versionErr, accept)
+		assert.Equal(t, expected, version, accept)
+	}
+
+	r, _ := http.NewRequest(GET, RouteProofSchema, nil)
+	r.Header.Set(HeaderAccept, "application/vnd.mainstay.v9+json")
+	_, versionErr := negotiateApiVersion(r)
+	assert.Equal(t, ErrorApiVersionUnsupported+": v9", versionErr.Error())
+}
+
+// Test api version of versioned and unversioned requests
+func TestApiVersionRequests(t *testing.T) {
+	service := NewRequestService(nil, nil, db.NewDbFake(), confpkg.ApiConfig{})
+
+	for _, path := range []string{RouteProofSchema, "/api/v1/proof/schema/"} {
+		r, _ := http.NewRequest(GET, path, nil)
+		writer := httptest.NewRecorder()
+		service.router.ServeHTTP(writer, r)
+		assert.Equal(t, http.StatusOK, writer.Code, path)
+		assert.Equal(t, ApiVersion1, writer.Header().Get(HeaderApiVersion), path)
+	}
+
+	// unsupported version requested for unversioned route
+	r, _ := http.NewRequest(GET, RouteProofSchema, nil)
+	r.Header.Set(HeaderAccept, "application/vnd.mainstay.v2+json")
+	response := serveRequest(t, service, r)
+	assert.Equal(t, ErrorApiVersionUnsupported+": v2", response["error"])
+	assert.Equal(t, CodeNotAcceptable, response["code"])
+
+	// versioned route ignores the Accept header
+	r, _ = http.NewRequest(GET, "/api/v1/proof/schema/", nil)
+	r.Header.Set(HeaderAccept, "application/vnd.mainstay.v2+json")
+	writer := httptest.NewRecorder()
+	service.router.ServeHTTP(writer, r)
+	assert.Equal(t, http.StatusOK, writer.Code)
+
+	// routes outside the api are not versioned
+	r, _ = http.NewRequest(GET, "/api/v2/proof/schema/", nil)
+	response = serveRequest(t, service, r)
+	assert.Equal(t, ErrorRouteNotFound, response["error"])
+	r, _ = http.NewRequest(GET, RouteHealthz, nil)
+	writer = httptest.NewRecorder()
+	service.router.ServeHTTP(writer, r)
+	assert.Equal(t, "", writer.Header().Get(HeaderApiVersion))
+}
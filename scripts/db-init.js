@@ -28,6 +28,7 @@ db.createCollection("ClientDetails")
 db.createCollection("ClientSignup")
 db.createCollection("MerkleCommitment")
 db.createCollection("MerkleProof")
+db.createCollection("Migration")
 print(db.getCollectionNames())
 
 // Create roles
@@ -58,6 +59,7 @@ db.createRole(
 // mainstayService role
 // This allows writing to all collections except
 // ClientCommitment/ClientDetails/ClientSignup which only API is allowed to write to
+// and migrating collections and indexes on startup
 db.createRole(
 {
     role: "mainstayService",
@@ -69,6 +71,8 @@ db.createRole(
         { resource: { db: db_name, collection: "ClientCommitment" }, actions: ["find"] },
         { resource: { db: db_name, collection: "ClientDetails" }, actions: ["find"] },
         { resource: { db: db_name, collection: "ClientSignup" }, actions: ["find"] },
+        { resource: { db: db_name, collection: "Migration" }, actions: ["find", "insert", "createIndex"] },
+        { resource: { db: db_name, collection: "" }, actions: ["createCollection", "createIndex"] },
     ],
     roles: []
 }
@@ -0,0 +1,19 @@
+/*
+Package service allows running mainstay programmatically.
+
+The attestation service, attestation server and request api are wired
+together by NewMainstay, which can be customised with functional options
+in order to embed mainstay in other Go programs:
+
+	mainstay, err := service.NewMainstay(config,
+		service.WithContext(ctx),
+		service.WithoutRequestApi())
+	if err != nil {
+		...
+	}
+	mainstay.Start()
+	...
+	mainstay.Stop()
+	mainstay.Wait()
+*/
+package service
@@ -0,0 +1,352 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"mainstay/attestation"
+	confpkg "mainstay/config"
+	"mainstay/db"
+	"mainstay/delivery"
+	"mainstay/ingest"
+	"mainstay/models"
+	"mainstay/notify"
+	"mainstay/requestapi"
+	"mainstay/timestamp"
+	"mainstay/tracing"
+)
+
+// error consts
+const (
+	ErrorConfigMissing = "Mainstay config not provided"
+)
+
+// Mainstay struct
+// Wires together the attestation service, server and request api
+// and handles running and stopping these as a single unit
+type Mainstay struct {
+	// context and cancel function for stopping all services
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// waitgroup required to maintain all service goroutines
+	wg *sync.WaitGroup
+
+	// service config
+	config *confpkg.Config
+
+	// database interface shared by the services
+	dbInterface db.Db
+
+	// interface to signers used by the attestation service
+	signer attestation.AttestSigner
+
+	// flag to enable the request api service
+	withRequestApi bool
+
+	server           *attestation.AttestServer
+	attestService    *attestation.AttestService
+	requestService   *requestapi.RequestService
+	kafkaConsumer    *ingest.KafkaConsumer
+	sidechainFetcher *ingest.SourcePuller
+	sourcePuller     *ingest.SourcePuller
+	aggregator       *attestation.AttestAggregator
+	indexer          *attestation.AttestIndexer
+	proofDelivery    *delivery.ProofDelivery
+}
+
+// Option type
+// Functional option to customise a Mainstay instance
+type Option func(*Mainstay)
+
+// Use parent context for all services
+// Cancelling the parent context stops mainstay
+func WithContext(ctx context.Context) Option {
+	return func(m *Mainstay) {
+		m.ctx = ctx
+	}
+}
+
+// Use an existing waitgroup to track service goroutines
+func WithWaitGroup(wg *sync.WaitGroup) Option {
+	return func(m *Mainstay) {
+		m.wg = wg
+	}
+}
+
+// Use custom database interface instead of connecting to mongo from config
+func WithDb(dbInterface db.Db) Option {
+	return func(m *Mainstay) {
+		m.dbInterface = dbInterface
+	}
+}
+
+// Use custom signer interface instead of the http signer from config
+func WithSigner(signer attestation.AttestSigner) Option {
+	return func(m *Mainstay) {
+		m.signer = signer
+	}
+}
+
+// Do not run the request api service
+func WithoutRequestApi() Option {
+	return func(m *Mainstay) {
+		m.withRequestApi = false
+	}
+}
+
+// Return Mainstay instance from config and functional options
+// Any dependencies not provided through options are created from config
+func NewMainstay(config *confpkg.Config, opts ...Option) (*Mainstay, error) {
+	if config == nil {
+		return nil, errors.New(ErrorConfigMissing)
+	}
+
+	m := &Mainstay{
+		ctx:            context.Background(),
+		wg:             &sync.WaitGroup{},
+		config:         config,
+		withRequestApi: true,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.ctx, m.cancel = context.WithCancel(m.ctx)
+
+	if m.dbInterface == nil {
+		// collections are migrated before any service uses them
+		dbMongo, dbErr := db.NewDbMongo(m.ctx, config.DbConfig())
+		if dbErr != nil {
+			return nil, dbErr
+		}
+		if migrateErr := dbMongo.Migrate(); migrateErr != nil {
+			return nil, migrateErr
+		}
+		// transient db failures, e.g. while reconnecting, are retried
+		m.dbInterface = db.NewDbRetry(m.ctx, dbMongo, config.DbConfig())
+	}
+	if m.signer == nil {
+		m.signer = attestation.NewAttestSignerHttp(config.SignerConfig())
+	}
+
+	// attestation service db and signer calls are traced as part of the attestation cycle
+	traceScope := tracing.NewScope()
+	m.server = attestation.NewAttestServer(db.NewDbTraced(m.dbInterface, traceScope))
+	m.attestService = attestation.NewAttestService(m.ctx, m.wg, m.server,
+		attestation.NewAttestSignerTraced(m.signer, traceScope), config)
+	m.attestService.SetTraceScope(traceScope)
+
+	// client commitments are placed at the merkle tree leaves of the configured ordering
+	orderingConfig := config.OrderingConfig()
+	ordering, orderingErr := models.NewCommitmentOrdering(orderingConfig.Strategy, orderingConfig.Positions,
+		orderingConfig.Depth, orderingConfig.SlotIds)
+	if orderingErr != nil {
+		return nil, orderingErr
+	}
+	ordering, orderingErr = ordering.Padded(orderingConfig.Size)
+	if orderingErr != nil {
+		return nil, orderingErr
+	}
+	m.server.SetOrdering(ordering)
+	if hashErr := models.CheckMerkleHash(config.MerkleConfig().Hash); hashErr != nil {
+		return nil, hashErr
+	}
+	m.server.SetMerkleHash(config.MerkleConfig().Hash)
+
+	// merkle tree hash function switched at the transition switchover round if configured
+	var transition *models.ProtocolTransition
+	if transitionConfig := config.TransitionConfig(); transitionConfig.Hash != "" {
+		protocolTransition, transitionErr := models.NewProtocolTransition(transitionConfig.Hash,
+			transitionConfig.SwitchoverRound, transitionConfig.Rounds)
+		if transitionErr != nil {
+			return nil, transitionErr
+		}
+		m.server.SetTransition(protocolTransition)
+		transition = &protocolTransition
+	}
+
+	// commitments are pre-aggregated between rounds if configured
+	if config.AggregationConfig().SubtreeSize != -1 {
+		aggregator, aggregatorErr := attestation.NewAttestAggregator(m.ctx, m.wg, m.server, config.AggregationConfig())
+		if aggregatorErr != nil {
+			return nil, aggregatorErr
+		}
+		m.aggregator = aggregator
+	}
+
+	// staychain transactions are indexed from the main chain if configured
+	if config.IndexerConfig().IntervalSeconds != -1 {
+		m.indexer = m.attestService.NewIndexer(config.IndexerConfig())
+	}
+
+	// attestation events reach the request api through the event bus
+	eventBus, eventBusErr := notify.NewEventBus(m.ctx, config.EventsConfig())
+	if eventBusErr != nil {
+		return nil, eventBusErr
+	}
+	m.attestService.AddNotifier(eventBus)
+	if m.withRequestApi && config.ApiConfig().Enabled {
+		m.requestService = requestapi.NewRequestService(m.ctx, m.wg, m.dbInterface, config.ApiConfig())
+		m.requestService.SetBalanceSource(m.attestService.BalanceMonitor())
+		m.requestService.SetFeeSpendSource(m.attestService.FeeBudget())
+		m.requestService.SetEventSource(eventBus)
+		m.requestService.SetAttestTrigger(m.attestService)
+		m.requestService.SetAttestPauser(m.attestService)
+		m.requestService.SetAttestReviewer(m.attestService)
+		m.requestService.SetIntegrityChecker(m.attestService)
+		m.requestService.SetAttestDeriver(m.attestService)
+		m.requestService.SetHealthChecker(m.attestService)
+		m.requestService.SetKeyRotator(m.attestService)
+		m.requestService.SetSpvSource(m.attestService)
+		m.requestService.SetStatusSource(m.attestService)
+		m.requestService.SetCommitmentOrdering(ordering)
+		m.requestService.SetMerkleHash(config.MerkleConfig().Hash)
+		if transition != nil {
+			m.requestService.SetTransition(*transition)
+		}
+
+		// confirmed proofs are exported as RFC 3161 timestamp tokens
+		tsa, tsaErr := timestamp.NewTsa(config.TsaConfig())
+		if tsaErr != nil {
+			return nil, tsaErr
+		}
+		m.requestService.SetTimestamper(tsa)
+	}
+
+	// commitments are additionally consumed from kafka if configured
+	if len(config.KafkaConfig().Brokers) > 0 {
+		kafkaConsumer, kafkaErr := ingest.NewKafkaConsumer(m.ctx, m.wg, m.dbInterface, config.KafkaConfig())
+		if kafkaErr != nil {
+			return nil, kafkaErr
+		}
+		m.kafkaConsumer = kafkaConsumer
+	}
+
+	// latest block hashes of sidechains are committed if configured
+	if len(config.SidechainConfig().Chains) > 0 {
+		sidechainFetcher, sidechainErr := ingest.NewSidechainFetcher(m.ctx, m.wg, m.dbInterface,
+			config.SidechainConfig(), config.SidechainClients())
+		if sidechainErr != nil {
+			return nil, sidechainErr
+		}
+		m.sidechainFetcher = sidechainFetcher
+	}
+
+	// commitments are pulled from client commitment sources if configured
+	if len(config.PullConfig().Sources) > 0 {
+		sourcePuller, pullErr := ingest.NewSourcePuller(m.ctx, m.wg, m.dbInterface, config.PullConfig())
+		if pullErr != nil {
+			return nil, pullErr
+		}
+		m.sourcePuller = sourcePuller
+	}
+
+	// confirmed proofs are pushed to client delivery targets if configured
+	if delivery.Enabled(config.DeliveryConfig()) {
+		proofDelivery, deliveryErr := delivery.NewProofDelivery(m.ctx, m.wg, m.dbInterface,
+			config.DeliveryConfig(), config.ApiConfig().ProofOps)
+		if deliveryErr != nil {
+			return nil, deliveryErr
+		}
+		m.attestService.AddNotifier(proofDelivery)
+		if m.requestService != nil {
+			m.requestService.SetProofDeliverer(proofDelivery)
+		}
+		m.proofDelivery = proofDelivery
+	}
+	return m, nil
+}
+
+// Get database interface used by mainstay
+func (m *Mainstay) Db() db.Db {
+	return m.dbInterface
+}
+
+// Get context used by mainstay services
+func (m *Mainstay) Context() context.Context {
+	return m.ctx
+}
+
+// Start all services in the background
+func (m *Mainstay) Start() {
+	m.wg.Add(1)
+	go m.attestService.Run()
+
+	if m.requestService != nil {
+		m.wg.Add(1)
+		go m.requestService.Run()
+	}
+
+	if m.kafkaConsumer != nil {
+		m.wg.Add(1)
+		go m.kafkaConsumer.Run()
+	}
+
+	if m.sidechainFetcher != nil {
+		m.wg.Add(1)
+		go m.sidechainFetcher.Run()
+	}
+
+	if m.sourcePuller != nil {
+		m.wg.Add(1)
+		go m.sourcePuller.Run()
+	}
+
+	if m.aggregator != nil {
+		m.wg.Add(1)
+		go m.aggregator.Run()
+	}
+
+	if m.proofDelivery != nil {
+		m.wg.Add(1)
+		go m.proofDelivery.Run()
+	}
+
+	if m.indexer != nil {
+		m.wg.Add(1)
+		go m.indexer.Run()
+	}
+}
+
+// Trigger an out of schedule attestation
+func (m *Mainstay) AttestNow() {
+	m.attestService.AttestNow()
+}
+
+// Return liveness of the attestation service
+func (m *Mainstay) Liveness() models.HealthReport {
+	return m.attestService.Liveness()
+}
+
+// Reload timing, fee and signer config from conf options
+// Reloaded values are applied by the attestation service once no
+// attestation is in progress
+func (m *Mainstay) Reload(conf []byte) error {
+	reloadConfig, reloadErr := attestation.GetReloadConfig(conf)
+	if reloadErr != nil {
+		return reloadErr
+	}
+	m.attestService.Reload(reloadConfig)
+	return nil
+}
+
+// Stop all services
+func (m *Mainstay) Stop() {
+	m.cancel()
+}
+
+// Wait for all services to stop
+func (m *Mainstay) Wait() {
+	m.wg.Wait()
+}
+
+// Start all services and block until these have stopped
+func (m *Mainstay) Run() {
+	m.Start()
+	m.Wait()
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"mainstay/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test NewMainstay error cases
+func TestNewMainstayErrors(t *testing.T) {
+	mainstay, err := NewMainstay(nil)
+	assert.Equal(t, errors.New(ErrorConfigMissing), err)
+	assert.Nil(t, mainstay)
+}
+
+// Test Mainstay functional options
+func TestMainstayOptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wg := &sync.WaitGroup{}
+	dbFake := db.NewDbFake()
+
+	m := &Mainstay{withRequestApi: true}
+	for _, opt := range []Option{WithContext(ctx), WithWaitGroup(wg), WithDb(dbFake), WithoutRequestApi()} {
+		opt(m)
+	}
+	assert.Equal(t, ctx, m.ctx)
+	assert.Equal(t, wg, m.wg)
+	assert.Equal(t, dbFake, m.Db())
+	assert.Equal(t, false, m.withRequestApi)
+}
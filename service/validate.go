@@ -0,0 +1,904 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"mainstay/attestation"
+	confpkg "mainstay/config"
+	"mainstay/crypto"
+	"mainstay/daemon"
+	"mainstay/db"
+	"mainstay/delivery"
+	"mainstay/ingest"
+	"mainstay/log"
+	"mainstay/models"
+	"mainstay/notify"
+	"mainstay/requestapi"
+	"mainstay/timestamp"
+	"mainstay/tracing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+)
+
+// Config validation
+//
+// Validation checks a config file in full and collects all the problems
+// found instead of failing on the first one, as the daemon does at runtime
+// Problems that would stop the daemon are errors while problems that cause
+// a config value to be ignored and replaced by a default are warnings
+
+// validation severity consts
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// validation consts
+const (
+	ValidationConnectivityTimeout = 10 * time.Second
+
+	ErrorValidationRpcUnreachable  = "Main client rpc unreachable"
+	ErrorValidationDbUnreachable   = "Database unreachable"
+	ErrorValidationInvalidTx       = "Invalid init tx"
+	ErrorValidationInvalidScript   = "Invalid multisig script"
+	ErrorValidationInvalidAddress  = "Invalid topup address"
+	ErrorValidationInvalidUrl      = "Invalid signer url"
+	ErrorValidationVaultAddr       = "Invalid vault address"
+	ErrorValidationEsploraUrl      = "Invalid esplora url"
+	ErrorValidationScriptClass     = "Not a multisig script"
+	WarningValidationMissingTx     = "Init tx not set - must be provided with -tx"
+	WarningValidationMissingScript = "Init script not set - must be provided with -script"
+	WarningValidationInvalidChain  = "Unknown chain - defaulting to main"
+	WarningValidationInvalidInt    = "Invalid integer config value"
+	WarningValidationAdminToken    = "Admin token not set - hmac secrets cannot be issued"
+	WarningTransitionHashUnchanged = "Transition hash function is the current merkle hash function"
+)
+
+// ValidationIssue struct
+// Single problem found in config with severity and config category
+type ValidationIssue struct {
+	Severity string
+	Category string
+	Message  string
+}
+
+// Return readable issue description
+func (i ValidationIssue) String() string {
+	return fmt.Sprintf("[%s] %s: %s", i.Severity, i.Category, i.Message)
+}
+
+// Validation struct
+// List of all problems found when validating a config
+type Validation struct {
+	Issues []ValidationIssue
+}
+
+// Add issue with error severity
+func (v *Validation) addError(category string, format string, args ...interface{}) {
+	v.Issues = append(v.Issues, ValidationIssue{SeverityError, category, fmt.Sprintf(format, args...)})
+}
+
+// Add issue with warning severity
+func (v *Validation) addWarning(category string, format string, args ...interface{}) {
+	v.Issues = append(v.Issues, ValidationIssue{SeverityWarning, category, fmt.Sprintf(format, args...)})
+}
+
+// Return true if any issue with error severity was found
+func (v Validation) HasErrors() bool {
+	for _, issue := range v.Issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate config fully, including rpc and database connectivity
+func ValidateConfig(ctx context.Context, conf []byte) Validation {
+	v := ValidateConfigParams(conf)
+	v.validateConnectivity(ctx, conf)
+	return v
+}
+
+// Validate config parameters without connecting to any external services
+func ValidateConfigParams(conf []byte) Validation {
+	var v Validation
+	chainCfg := v.validateMainChain(conf)
+	v.validateStaychain(conf, chainCfg)
+	v.validateSigner(conf, chainCfg)
+	v.validateDb(conf)
+	v.validateWallet(conf)
+	v.validateSecrets(conf)
+	v.validateEsplora(conf)
+	v.validateThrottle(conf)
+	v.validateRpc(conf)
+	v.validateZmq(conf)
+	v.validateAggregation(conf)
+	v.validateIndexer(conf)
+	v.validateOrdering(conf)
+	v.validateMerkle(conf)
+	v.validateTransition(conf)
+	v.validateKafka(conf)
+	v.validateSidechain(conf)
+	v.validatePull(conf)
+	v.validateTsa(conf)
+	v.validateDelivery(conf)
+	v.validateFees(conf)
+	v.validateTiming(conf)
+	v.validateAdaptive(conf)
+	v.validateHeartbeat(conf)
+	v.validateRbf(conf)
+	v.validateConfirmation(conf)
+	v.validateBudget(conf)
+	v.validateApi(conf)
+	v.validateBalance(conf)
+	v.validateReview(conf)
+	v.validateQuorum(conf)
+	v.validateWebhook(conf)
+	v.validateAlert(conf)
+	v.validateEvents(conf)
+	v.validateLog(conf)
+	v.validateTracing(conf)
+	v.validateDaemon(conf)
+	return v
+}
+
+// Validate main chain parameters and return chain params used
+func (v *Validation) validateMainChain(conf []byte) *chaincfg.Params {
+	chainCfg, chainCfgErr := confpkg.GetChainCfgParams(confpkg.MainChainName, conf)
+	if chainCfgErr != nil {
+		v.addError(confpkg.MainChainName, "%v", chainCfgErr)
+		return &chaincfg.MainNetParams
+	}
+	rpcUrl := confpkg.TryGetParamFromConf(confpkg.MainChainName, confpkg.RpcClientUrlName, conf)
+	if _, _, hostErr := confpkg.SplitHostPort(rpcUrl); hostErr != nil {
+		v.addError(confpkg.MainChainName, "%v", hostErr)
+	}
+	chain := confpkg.TryGetParamFromConf(confpkg.MainChainName, confpkg.RpcClientChainName, conf)
+	if chain != "main" && chain != "testnet" && chain != "regtest" {
+		v.addWarning(confpkg.MainChainName, "%s (%s)", WarningValidationInvalidChain, chain)
+	}
+	return chainCfg
+}
+
+// Validate staychain init and topup parameters
+func (v *Validation) validateStaychain(conf []byte, chainCfg *chaincfg.Params) {
+	category := confpkg.StaychainName
+	get := func(name string) string {
+		return confpkg.TryGetParamFromConf(confpkg.StaychainName, name, conf)
+	}
+
+	initTx := get(confpkg.StaychainInitTxName)
+	if initTx == "" {
+		v.addWarning(category, WarningValidationMissingTx)
+	} else if _, txErr := chainhash.NewHashFromStr(initTx); txErr != nil || len(initTx) != 2*chainhash.HashSize {
+		v.addError(category, "%s (%s)", ErrorValidationInvalidTx, initTx)
+	}
+
+	if initPK := get(confpkg.StaychainInitPkName); initPK != "" {
+		if _, pkErr := crypto.GetWalletPrivKey(initPK); pkErr != nil {
+			v.addError(category, "%s %s", attestation.ErrorInvalidPk, confpkg.StaychainInitPkName)
+		}
+	}
+	if topupPK := get(confpkg.StaychainTopupPkName); topupPK != "" {
+		if _, pkErr := crypto.GetWalletPrivKey(topupPK); pkErr != nil {
+			v.addError(category, "%s %s", attestation.ErrorInvalidPk, confpkg.StaychainTopupPkName)
+		}
+	}
+
+	path, pathErr := crypto.ParseDerivationPath(get(confpkg.StaychainDerivationPathName))
+	if pathErr != nil {
+		v.addError(category, "%v", pathErr)
+	}
+	if initXprv := get(confpkg.StaychainInitXprvName); initXprv != "" {
+		if xprv, xprvErr := crypto.ParseExtendedKey(initXprv, chainCfg); xprvErr != nil || !xprv.IsPrivate() {
+			v.addError(category, "%s %s", attestation.ErrorInvalidPk, confpkg.StaychainInitXprvName)
+		}
+	}
+
+	initScript := get(confpkg.StaychainInitScriptName)
+	if initScript == "" {
+		v.addWarning(category, WarningValidationMissingScript)
+		return
+	}
+	numOfKeys, numOfSigs, scriptErr := parseMultisig(initScript, chainCfg)
+	if scriptErr != nil {
+		v.addError(category, "%s %s: %v", ErrorValidationInvalidScript, confpkg.StaychainInitScriptName, scriptErr)
+		return
+	}
+
+	if xpubsStr := get(confpkg.StaychainInitXpubsName); xpubsStr != "" {
+		v.validateXpubs(strings.Split(xpubsStr, ","), path, pathErr == nil, initScript, chainCfg)
+	} else {
+		var chaincodes []string
+		if chaincodesStr := get(confpkg.StaychainInitChaincodesName); chaincodesStr != "" {
+			chaincodes = strings.Split(chaincodesStr, ",")
+		}
+		if len(chaincodes) != numOfKeys {
+			v.addError(category, "%s %d != %d", attestation.ErrorMissingChaincodes, len(chaincodes), numOfKeys)
+		}
+		for _, chaincode := range chaincodes {
+			ccBytes, ccBytesErr := hex.DecodeString(strings.TrimSpace(chaincode))
+			if ccBytesErr != nil || len(ccBytes) != 32 {
+				v.addError(category, "%s %s", attestation.ErrorInvalidChaincode, strings.TrimSpace(chaincode))
+			}
+		}
+	}
+
+	topupAddress := get(confpkg.StaychainTopupAddressName)
+	topupScript := get(confpkg.StaychainTopupScriptName)
+	if topupAddress == "" || topupScript == "" {
+		v.addWarning(category, attestation.WarningTopupInfoMissing)
+	}
+	if topupAddress != "" {
+		if _, addrErr := btcutil.DecodeAddress(topupAddress, chainCfg); addrErr != nil {
+			v.addError(category, "%s %s: %v", ErrorValidationInvalidAddress, topupAddress, addrErr)
+		}
+	}
+	topupNumOfSigs := 0
+	if topupScript != "" {
+		_, topupSigs, topupScriptErr := parseMultisig(topupScript, chainCfg)
+		if topupScriptErr != nil {
+			v.addError(category, "%s %s: %v", ErrorValidationInvalidScript, confpkg.StaychainTopupScriptName, topupScriptErr)
+			return
+		}
+		topupNumOfSigs = topupSigs
+	}
+	if topupNumOfSigs != numOfSigs {
+		v.addError(category, "%s. %d != %d", attestation.ErrorTopUpScriptNumSigs, numOfSigs, topupNumOfSigs)
+	}
+}
+
+// Validate init xpubs derive the pubkeys of the init script along the derivation path
+func (v *Validation) validateXpubs(xpubs []string, path []uint32, pathValid bool, initScript string,
+	chainCfg *chaincfg.Params) {
+
+	category := confpkg.StaychainName
+	var derivedPubs []*btcec.PublicKey
+	for _, xpubStr := range xpubs {
+		xpub, xpubErr := crypto.ParseExtendedKey(xpubStr, chainCfg)
+		if xpubErr != nil {
+			v.addError(category, "%s %s", attestation.ErrorInvalidXpub, strings.TrimSpace(xpubStr))
+			continue
+		}
+		if baseKey, baseKeyErr := crypto.DeriveExtendedKey(xpub, path); baseKeyErr == nil {
+			if pub, pubErr := baseKey.ECPubKey(); pubErr == nil {
+				derivedPubs = append(derivedPubs, pub)
+			}
+		}
+	}
+	if !pathValid || len(derivedPubs) != len(xpubs) {
+		return
+	}
+
+	scriptPubs, _ := crypto.ParseRedeemScript(initScript)
+	if len(derivedPubs) != len(scriptPubs) {
+		v.addError(category, "%s %d != %d", attestation.ErrorXpubsScriptMismatch, len(derivedPubs), len(scriptPubs))
+		return
+	}
+	for i := range derivedPubs {
+		if !derivedPubs[i].IsEqual(scriptPubs[i]) {
+			v.addError(category, "%s %s", attestation.ErrorXpubsScriptMismatch, strings.TrimSpace(xpubs[i]))
+		}
+	}
+}
+
+// Parse multisig script without failing and return number of keys and sigs
+func parseMultisig(script string, chainCfg *chaincfg.Params) (int, int, error) {
+	scriptBytes, decodeErr := hex.DecodeString(script)
+	if decodeErr != nil {
+		return 0, 0, decodeErr
+	}
+	class, addrs, numOfSigs, extractErr := txscript.ExtractPkScriptAddrs(scriptBytes, chainCfg)
+	if extractErr != nil {
+		return 0, 0, extractErr
+	}
+	if class != txscript.MultiSigTy {
+		return 0, 0, errors.New(fmt.Sprintf("%s %s", ErrorValidationScriptClass, class))
+	}
+	return len(addrs), numOfSigs, nil
+}
+
+// Validate signer connectivity parameters
+func (v *Validation) validateSigner(conf []byte, chainCfg *chaincfg.Params) {
+	signerConfig, signerErr := confpkg.GetSignerConfig(conf)
+	if signerErr != nil {
+		v.addError(confpkg.Signer, "%v", signerErr)
+		return
+	}
+	signerUrl, urlErr := url.Parse(signerConfig.Url)
+	if urlErr != nil || signerUrl.Host == "" {
+		v.addError(confpkg.Signer, "%s (%s)", ErrorValidationInvalidUrl, signerConfig.Url)
+	}
+	if retries, set := v.validateInt(conf, confpkg.Signer, confpkg.SignerRetries); set && retries < 0 {
+		v.addWarning(confpkg.Signer, "%s (%d)", attestation.WarningInvalidSignerRetriesArg, retries)
+	}
+	v.validateInt(conf, confpkg.Signer, confpkg.SignerThreshold)
+
+	// signer threshold and pubkeys must match the init script
+	initScript := confpkg.TryGetParamFromConf(confpkg.StaychainName, confpkg.StaychainInitScriptName, conf)
+	if _, numOfSigs, scriptErr := parseMultisig(initScript, chainCfg); scriptErr == nil {
+		scriptPubs, _ := crypto.ParseRedeemScript(initScript)
+		if _, signerSetErr := attestation.NewAttestSignerSet(signerConfig, scriptPubs, numOfSigs); signerSetErr != nil {
+			v.addError(confpkg.Signer, "%v", signerSetErr)
+		}
+	}
+}
+
+// Validate database connectivity parameters
+func (v *Validation) validateDb(conf []byte) {
+	if dbConfig, dbErr := confpkg.GetDbConfig(conf); dbErr != nil {
+		v.addError(confpkg.DbName, "%v", dbErr)
+	} else if dbConfig.Uri != "" || dbConfig.Host != "" {
+		if validateErr := db.ValidateDbConfig(dbConfig); validateErr != nil {
+			v.addError(confpkg.DbName, "%v", validateErr)
+		}
+	}
+	maxPoolSize, maxSet := v.validateInt(conf, confpkg.DbName, confpkg.DbMaxPoolSizeName)
+	if maxSet && maxPoolSize <= 0 {
+		v.addWarning(confpkg.DbName, "%s %s (%d)", db.WarningInvalidDbPoolSizeArg, confpkg.DbMaxPoolSizeName, maxPoolSize)
+	}
+	if minPoolSize, set := v.validateInt(conf, confpkg.DbName, confpkg.DbMinPoolSizeName); set &&
+		(minPoolSize < 0 || (maxSet && maxPoolSize > 0 && minPoolSize > maxPoolSize)) {
+		v.addWarning(confpkg.DbName, "%s %s (%d)", db.WarningInvalidDbPoolSizeArg, confpkg.DbMinPoolSizeName, minPoolSize)
+	}
+	if timeout, set := v.validateInt(conf, confpkg.DbName, confpkg.DbTimeoutSecondsName); set && timeout <= 0 {
+		v.addWarning(confpkg.DbName, "%s (%d)", db.WarningInvalidDbTimeoutArg, timeout)
+	}
+	if retries, set := v.validateInt(conf, confpkg.DbName, confpkg.DbRetriesName); set && retries < 0 {
+		v.addWarning(confpkg.DbName, "%s (%d)", db.WarningInvalidDbRetriesArg, retries)
+	}
+}
+
+// Validate optional wallet parameters
+func (v *Validation) validateWallet(conf []byte) {
+	walletConfig, walletErr := confpkg.GetWalletConfig(conf)
+	if walletErr != nil {
+		v.addError(confpkg.WalletName, "%v", walletErr)
+	} else if walletConfig.WatchOnly {
+		if confpkg.TryGetParamFromConf(confpkg.StaychainName, confpkg.StaychainInitScriptName, conf) == "" {
+			v.addError(confpkg.WalletName, attestation.ErrorWatchOnlyMultisig)
+		}
+		if walletConfig.Passphrase != "" {
+			v.addWarning(confpkg.WalletName, attestation.WarningWatchOnlyPassphrase)
+		}
+	}
+	if seconds, set := v.validateInt(conf, confpkg.WalletName, confpkg.WalletUnlockSecondsName); set && seconds <= 0 {
+		v.addWarning(confpkg.WalletName, "%s (%d)", attestation.WarningInvalidWalletUnlockArg, seconds)
+	}
+}
+
+// Validate optional secrets backend parameters
+func (v *Validation) validateSecrets(conf []byte) {
+	vaultAddr := confpkg.GetSecretsConfig(conf).VaultAddr
+	if vaultAddr == "" {
+		return
+	}
+	addrUrl, urlErr := url.Parse(vaultAddr)
+	if urlErr != nil || (addrUrl.Scheme != "http" && addrUrl.Scheme != "https") || addrUrl.Host == "" {
+		v.addError(confpkg.SecretsName, "%s (%s)", ErrorValidationVaultAddr, vaultAddr)
+	}
+}
+
+// Validate optional esplora parameters
+func (v *Validation) validateEsplora(conf []byte) {
+	esploraUrl := confpkg.GetEsploraConfig(conf).Url
+	if esploraUrl == "" {
+		return
+	}
+	parsedUrl, urlErr := url.Parse(esploraUrl)
+	if urlErr != nil || (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") || parsedUrl.Host == "" {
+		v.addError(confpkg.EsploraName, "%s (%s)", ErrorValidationEsploraUrl, esploraUrl)
+	}
+}
+
+// Validate optional rpc throttle parameters
+func (v *Validation) validateThrottle(conf []byte) {
+	for _, name := range []string{confpkg.ThrottlePerSecondName, confpkg.ThrottlePerMinuteName} {
+		if rate, set := v.validateInt(conf, confpkg.ThrottleName, name); set && rate <= 0 {
+			v.addWarning(confpkg.ThrottleName, "%s (%d)", attestation.WarningInvalidThrottleRateArg, rate)
+		}
+	}
+}
+
+// Validate optional rpc timeout parameters
+func (v *Validation) validateRpc(conf []byte) {
+	if timeout, set := v.validateInt(conf, confpkg.RpcName, confpkg.RpcTimeoutSecondsName); set && timeout <= 0 {
+		v.addWarning(confpkg.RpcName, "%s (%d)", attestation.WarningInvalidRpcTimeout, timeout)
+	}
+}
+
+// Validate optional zmq notification urls
+func (v *Validation) validateZmq(conf []byte) {
+	zmqConfig := confpkg.GetZmqConfig(conf)
+	for _, zmqUrl := range []string{zmqConfig.RawBlock, zmqConfig.HashTx} {
+		if zmqUrl == "" {
+			continue
+		}
+		if urlErr := attestation.ValidateZmqUrl(zmqUrl); urlErr != nil {
+			v.addError(confpkg.ZmqName, "%v", urlErr)
+		}
+	}
+}
+
+// Validate optional commitment pre-aggregation parameters
+func (v *Validation) validateAggregation(conf []byte) {
+	if subtreeSize, set := v.validateInt(conf, confpkg.AggregationName, confpkg.AggregationSubtreeSizeName); set {
+		if _, aggregatorErr := models.NewCommitmentAggregator(subtreeSize); aggregatorErr != nil {
+			v.addError(confpkg.AggregationName, "%v", aggregatorErr)
+		}
+	}
+	if interval, set := v.validateInt(conf, confpkg.AggregationName, confpkg.AggregationIntervalSecondsName); set && interval <= 0 {
+		v.addWarning(confpkg.AggregationName, "%s (%d)", attestation.WarningInvalidAggregationIntervalArg, interval)
+	}
+}
+
+// Validate optional staychain indexer parameters
+func (v *Validation) validateIndexer(conf []byte) {
+	if interval, set := v.validateInt(conf, confpkg.IndexerName, confpkg.IndexerIntervalSecondsName); set && interval <= 0 {
+		v.addWarning(confpkg.IndexerName, "%s (%d)", attestation.WarningInvalidIndexerIntervalArg, interval)
+	}
+	if confirmations, set := v.validateInt(conf, confpkg.IndexerName, confpkg.IndexerConfirmationsName); set && confirmations <= 0 {
+		v.addWarning(confpkg.IndexerName, "%s (%d)", attestation.WarningInvalidIndexerConfirmationsArg, confirmations)
+	}
+}
+
+// Validate optional commitment ordering parameters
+func (v *Validation) validateOrdering(conf []byte) {
+	orderingConfig, orderingConfigErr := confpkg.GetOrderingConfig(conf)
+	if orderingConfigErr != nil {
+		v.addError(confpkg.OrderingName, "%v", orderingConfigErr)
+		return
+	}
+	ordering, orderingErr := models.NewCommitmentOrdering(orderingConfig.Strategy, orderingConfig.Positions,
+		orderingConfig.Depth, orderingConfig.SlotIds)
+	if orderingErr != nil {
+		v.addError(confpkg.OrderingName, "%v", orderingErr)
+		return
+	}
+	if _, paddedErr := ordering.Padded(orderingConfig.Size); paddedErr != nil {
+		v.addError(confpkg.OrderingName, "%v", paddedErr)
+	}
+}
+
+// Validate optional merkle tree parameters
+func (v *Validation) validateMerkle(conf []byte) {
+	if hashErr := models.CheckMerkleHash(confpkg.GetMerkleConfig(conf).Hash); hashErr != nil {
+		v.addError(confpkg.MerkleName, "%v", hashErr)
+	}
+}
+
+// Validate optional protocol transition parameters
+func (v *Validation) validateTransition(conf []byte) {
+	transitionConfig := confpkg.GetTransitionConfig(conf)
+	if transitionConfig.Hash == "" {
+		return
+	}
+	if _, transitionErr := models.NewProtocolTransition(transitionConfig.Hash,
+		transitionConfig.SwitchoverRound, transitionConfig.Rounds); transitionErr != nil {
+		v.addError(confpkg.TransitionName, "%v", transitionErr)
+	} else if models.MerkleHashName(transitionConfig.Hash) == models.MerkleHashName(confpkg.GetMerkleConfig(conf).Hash) {
+		v.addWarning(confpkg.TransitionName, "%s: %s", WarningTransitionHashUnchanged, transitionConfig.Hash)
+	}
+}
+
+// Validate optional kafka ingestion parameters
+func (v *Validation) validateKafka(conf []byte) {
+	kafkaConfig := confpkg.GetKafkaConfig(conf)
+	if len(kafkaConfig.Brokers) == 0 {
+		return
+	}
+	if kafkaErr := ingest.ValidateKafkaConfig(kafkaConfig); kafkaErr != nil {
+		v.addError(confpkg.KafkaName, "%v", kafkaErr)
+	}
+}
+
+// Validate optional sidechain commitment parameters
+func (v *Validation) validateSidechain(conf []byte) {
+	sidechainConfig := confpkg.GetSidechainConfig(conf)
+	if len(sidechainConfig.Chains) == 0 {
+		return
+	}
+	if sidechainErr := ingest.ValidateSidechainConfig(sidechainConfig); sidechainErr != nil {
+		v.addError(confpkg.SidechainName, "%v", sidechainErr)
+	} else if _, clientsErr := confpkg.GetSidechainClients(sidechainConfig, conf); clientsErr != nil {
+		v.addError(confpkg.SidechainName, "%v", clientsErr)
+	}
+	if interval, set := v.validateInt(conf, confpkg.SidechainName, confpkg.SidechainIntervalSecondsName); set && interval <= 0 {
+		v.addWarning(confpkg.SidechainName, "%s (%d)", ingest.WarningInvalidSidechainIntervalArg, interval)
+	}
+}
+
+// Validate optional commitment source parameters
+func (v *Validation) validatePull(conf []byte) {
+	if pullErr := ingest.ValidatePullConfig(confpkg.GetPullConfig(conf)); pullErr != nil {
+		v.addError(confpkg.PullName, "%v", pullErr)
+	}
+	if interval, set := v.validateInt(conf, confpkg.PullName, confpkg.PullIntervalSecondsName); set && interval <= 0 {
+		v.addWarning(confpkg.PullName, "%s (%d)", ingest.WarningInvalidPullIntervalArg, interval)
+	}
+}
+
+// Validate optional timestamp authority parameters
+func (v *Validation) validateTsa(conf []byte) {
+	if tsaErr := timestamp.ValidateTsaConfig(confpkg.GetTsaConfig(conf)); tsaErr != nil {
+		v.addError(confpkg.TsaName, "%v", tsaErr)
+	}
+}
+
+// Validate optional proof delivery parameters
+func (v *Validation) validateDelivery(conf []byte) {
+	if deliveryErr := delivery.ValidateDeliveryConfig(confpkg.GetDeliveryConfig(conf)); deliveryErr != nil {
+		v.addError(confpkg.DeliveryName, "%v", deliveryErr)
+	}
+	if retries, set := v.validateInt(conf, confpkg.DeliveryName, confpkg.DeliveryRetriesName); set && retries < 0 {
+		v.addWarning(confpkg.DeliveryName, "%s (%d)", delivery.WarningInvalidDeliveryRetriesArg, retries)
+	}
+}
+
+// Validate optional fee parameters against the limits of the attestation fees
+func (v *Validation) validateFees(conf []byte) {
+	minFee, minFeeSet := v.validateInt(conf, confpkg.FeesName, confpkg.FeesMinFeeName)
+	if minFeeSet && !(minFee > 0 && minFee < attestation.DefaultMaxFee) {
+		v.addWarning(confpkg.FeesName, "%s (%d)", attestation.WarningInvalidMinFeeArg, minFee)
+		minFeeSet = false
+	}
+	if !minFeeSet {
+		minFee = attestation.DefaultMinFee
+	}
+	maxFee, maxFeeSet := v.validateInt(conf, confpkg.FeesName, confpkg.FeesMaxFeeName)
+	if maxFeeSet && !(maxFee > minFee && maxFee < attestation.DefaultMaxFee) {
+		v.addWarning(confpkg.FeesName, "%s (%d)", attestation.WarningInvalidMaxFeeArg, maxFee)
+	}
+	if feeIncrement, set := v.validateInt(conf, confpkg.FeesName, confpkg.FeesFeeIncrementName); set && feeIncrement <= 0 {
+		v.addWarning(confpkg.FeesName, "%s (%d)", attestation.WarningInvalidFeeIncrementArg, feeIncrement)
+	}
+}
+
+// Validate optional timing parameters
+func (v *Validation) validateTiming(conf []byte) {
+	if minutes, set := v.validateInt(conf, confpkg.TimingName, confpkg.TimingNewAttestationMinutesName); set && minutes <= 0 {
+		v.addWarning(confpkg.TimingName, "%s (%d)", attestation.WarningInvalidATimeNewAttestationArg, minutes)
+	}
+	if minutes, set := v.validateInt(conf, confpkg.TimingName, confpkg.TimingHandleUnconfirmedMinutesName); set && minutes <= 0 {
+		v.addWarning(confpkg.TimingName, "%s (%d)", attestation.WarningInvalidATimeHandleUnconfirmedArg, minutes)
+	}
+	if seconds, set := v.validateInt(conf, confpkg.TimingName, confpkg.TimingStartupDelaySecondsName); set && seconds < 0 {
+		v.addWarning(confpkg.TimingName, "%s (%d)", attestation.WarningInvalidStartupDelayArg, seconds)
+	}
+	if seconds, set := v.validateInt(conf, confpkg.TimingName, confpkg.TimingFixedSecondsName); set && seconds <= 0 {
+		v.addWarning(confpkg.TimingName, "%s (%d)", attestation.WarningInvalidATimeFixedArg, seconds)
+	}
+	if seconds, set := v.validateInt(conf, confpkg.TimingName, confpkg.TimingSigsSecondsName); set && seconds <= 0 {
+		v.addWarning(confpkg.TimingName, "%s (%d)", attestation.WarningInvalidATimeSigsArg, seconds)
+	}
+	if seconds, set := v.validateInt(conf, confpkg.TimingName, confpkg.TimingConfirmationSecondsName); set && seconds <= 0 {
+		v.addWarning(confpkg.TimingName, "%s (%d)", attestation.WarningInvalidATimeConfirmationArg, seconds)
+	}
+
+	// stagger offset must fall within the new attestation interval
+	interval := int(attestation.DefaultATimeNewAttestation / time.Minute)
+	if minutes := confpkg.GetTimingConfig(conf).NewAttestationMinutes; minutes > 0 {
+		interval = minutes
+	}
+	if minutes, set := v.validateInt(conf, confpkg.TimingName, confpkg.TimingStaggerOffsetMinutesName); set &&
+		(minutes < 0 || minutes >= interval) {
+		v.addWarning(confpkg.TimingName, "%s (%d)", attestation.WarningInvalidStaggerOffsetArg, minutes)
+	}
+}
+
+// Validate optional adaptive interval parameters against the new attestation time
+func (v *Validation) validateAdaptive(conf []byte) {
+	ceiling, ceilingSet := v.validateInt(conf, confpkg.AdaptiveName, confpkg.AdaptiveFeeCeilingName)
+	if ceilingSet && ceiling <= 0 {
+		v.addWarning(confpkg.AdaptiveName, "%s (%d)", attestation.WarningInvalidAdaptiveFeeCeilingArg, ceiling)
+		ceilingSet = false
+	}
+	if floor, set := v.validateInt(conf, confpkg.AdaptiveName, confpkg.AdaptiveFeeFloorName); set &&
+		(floor <= 0 || (ceilingSet && floor > ceiling)) {
+		v.addWarning(confpkg.AdaptiveName, "%s (%d)", attestation.WarningInvalidAdaptiveFeeFloorArg, floor)
+	}
+
+	interval := int(attestation.DefaultATimeNewAttestation / time.Minute)
+	if minutes := confpkg.GetTimingConfig(conf).NewAttestationMinutes; minutes > 0 {
+		interval = minutes
+	}
+	if minutes, set := v.validateInt(conf, confpkg.AdaptiveName, confpkg.AdaptiveMinMinutesName); set &&
+		(minutes <= 0 || minutes > interval) {
+		v.addWarning(confpkg.AdaptiveName, "%s (%d)", attestation.WarningInvalidAdaptiveMinArg, minutes)
+	}
+	if minutes, set := v.validateInt(conf, confpkg.AdaptiveName, confpkg.AdaptiveMaxMinutesName); set && minutes < interval {
+		v.addWarning(confpkg.AdaptiveName, "%s (%d)", attestation.WarningInvalidAdaptiveMaxArg, minutes)
+	}
+}
+
+// Validate optional heartbeat parameters
+func (v *Validation) validateHeartbeat(conf []byte) {
+	if minutes, set := v.validateInt(conf, confpkg.HeartbeatName, confpkg.HeartbeatIdleMinutesName); set && minutes <= 0 {
+		v.addWarning(confpkg.HeartbeatName, "%s (%d)", attestation.WarningInvalidHeartbeatIdleArg, minutes)
+	}
+}
+
+// Validate optional replace-by-fee policy parameters
+func (v *Validation) validateRbf(conf []byte) {
+	rbfConfig := confpkg.GetRbfConfig(conf)
+	switch rbfConfig.BumpStrategy {
+	case attestation.BumpStrategyAbsolute, attestation.BumpStrategyPercentage, "":
+	default:
+		v.addWarning(confpkg.RbfName, "%s (%s)", attestation.WarningInvalidBumpStrategyArg, rbfConfig.BumpStrategy)
+	}
+	if percent, set := v.validateInt(conf, confpkg.RbfName, confpkg.RbfFeeIncrementPercentName); set && percent <= 0 {
+		v.addWarning(confpkg.RbfName, "%s (%d)", attestation.WarningInvalidFeeIncrementPercentArg, percent)
+	}
+	v.validateInt(conf, confpkg.RbfName, confpkg.RbfMaxBumpsName)
+	for _, minutes := range rbfConfig.BumpScheduleMinutes {
+		if minutes <= 0 {
+			v.addWarning(confpkg.RbfName, "%s (%v)", attestation.WarningInvalidBumpScheduleArg, rbfConfig.BumpScheduleMinutes)
+			break
+		}
+	}
+}
+
+// Validate optional confirmation depth parameters
+func (v *Validation) validateConfirmation(conf []byte) {
+	if depth, set := v.validateInt(conf, confpkg.ConfirmationName, confpkg.ConfirmationDepthName); set && depth <= 0 {
+		v.addWarning(confpkg.ConfirmationName, "%s (%d)", attestation.WarningInvalidConfirmationDepthArg, depth)
+	}
+}
+
+// Validate optional fee budget parameters
+func (v *Validation) validateBudget(conf []byte) {
+	if daily, set := v.validateInt(conf, confpkg.BudgetName, confpkg.BudgetDailyName); set && daily <= 0 {
+		v.addWarning(confpkg.BudgetName, "%s (%d)", attestation.WarningInvalidDailyBudgetArg, daily)
+	}
+	if monthly, set := v.validateInt(conf, confpkg.BudgetName, confpkg.BudgetMonthlyName); set && monthly <= 0 {
+		v.addWarning(confpkg.BudgetName, "%s (%d)", attestation.WarningInvalidMonthlyBudgetArg, monthly)
+	}
+}
+
+// Validate optional request api parameters
+func (v *Validation) validateApi(conf []byte) {
+	apiConfig := confpkg.GetApiConfig(conf)
+	hmacEnabled := false
+	for _, scheme := range apiConfig.AuthSchemes {
+		if scheme == requestapi.AuthSchemeHmac {
+			hmacEnabled = true
+		} else if scheme != requestapi.AuthSchemeToken {
+			v.addWarning(confpkg.ApiName, "%s: %s", requestapi.WarningUnknownAuthScheme, scheme)
+		}
+	}
+	if window, set := v.validateInt(conf, confpkg.ApiName, confpkg.ApiHmacReplayWindowSecondsName); set && window <= 0 {
+		v.addWarning(confpkg.ApiName, "%s %s (%d)", WarningValidationInvalidInt, confpkg.ApiHmacReplayWindowSecondsName, window)
+	}
+	for _, name := range []string{confpkg.ApiReadTimeoutSecondsName, confpkg.ApiWriteTimeoutSecondsName,
+		confpkg.ApiIdleTimeoutSecondsName, confpkg.ApiShutdownTimeoutSecondsName, confpkg.ApiMaxBodyBytesName} {
+		if value, set := v.validateInt(conf, confpkg.ApiName, name); set && value <= 0 {
+			v.addWarning(confpkg.ApiName, "%s %s (%d)", WarningValidationInvalidInt, name, value)
+		}
+	}
+	if apiConfig.Port != "" {
+		if port, portErr := strconv.Atoi(apiConfig.Port); portErr != nil || port <= 0 || port > 65535 {
+			v.addError(confpkg.ApiName, "%s: %s", requestapi.ErrorApiPortInvalid, apiConfig.Port)
+		}
+	}
+	if requestapi.AdminApiEnabled(apiConfig) {
+		if port, portErr := strconv.Atoi(apiConfig.AdminPort); portErr != nil || port <= 0 || port > 65535 {
+			v.addError(confpkg.ApiName, "%s: %s", requestapi.ErrorAdminPortInvalid, apiConfig.AdminPort)
+		} else if _, apiPort, _ := net.SplitHostPort(requestapi.ApiAddress(apiConfig)); apiPort == apiConfig.AdminPort {
+			v.addError(confpkg.ApiName, "%s: %s", requestapi.ErrorAdminPortConflict, apiConfig.AdminPort)
+		}
+	}
+	if hmacEnabled && apiConfig.AdminToken == "" {
+		v.addWarning(confpkg.ApiName, WarningValidationAdminToken)
+	}
+	if apiConfig.ProofOps != "" && apiConfig.ProofOps != models.ProofOpsAppend && apiConfig.ProofOps != models.ProofOpsPosition {
+		v.addWarning(confpkg.ApiName, "%s: %s", requestapi.WarningUnknownProofOps, apiConfig.ProofOps)
+	}
+	for _, entry := range apiConfig.AdminAllowedIps {
+		if _, entryErr := requestapi.ParseAdminAllowlistEntry(entry); entryErr != nil {
+			v.addWarning(confpkg.ApiName, "%s: %s", requestapi.WarningAdminAllowlistEntry, entry)
+		}
+	}
+	for _, origin := range apiConfig.CorsAllowedOrigins {
+		if originErr := requestapi.ParseCorsOrigin(origin); originErr != nil {
+			v.addWarning(confpkg.ApiName, "%s: %s", requestapi.WarningCorsOrigin, origin)
+		}
+	}
+	for _, method := range apiConfig.CorsAllowedMethods {
+		if !requestapi.IsCorsMethod(method) {
+			v.addWarning(confpkg.ApiName, "%s: %s", requestapi.WarningCorsMethod, method)
+		}
+	}
+	if (apiConfig.TlsCertFile == "") != (apiConfig.TlsKeyFile == "") {
+		v.addError(confpkg.ApiName, requestapi.ErrorApiTlsFiles)
+	}
+	if apiConfig.TlsCertFile != "" && len(apiConfig.AcmeDomains) > 0 {
+		v.addError(confpkg.ApiName, requestapi.ErrorApiTlsAcmeExclusive)
+	}
+	if len(apiConfig.AcmeDomains) > 0 && apiConfig.AcmeCacheDir == "" {
+		v.addWarning(confpkg.ApiName, requestapi.WarningAcmeCacheDir)
+	}
+	if apiConfig.HttpRedirectAddr != "" && !requestapi.ApiTlsEnabled(apiConfig) {
+		v.addWarning(confpkg.ApiName, requestapi.WarningHttpRedirectNoTls)
+	}
+	if apiConfig.AdminClientCaFile != "" {
+		if _, caErr := requestapi.LoadAdminClientCas(apiConfig.AdminClientCaFile); caErr != nil {
+			v.addWarning(confpkg.ApiName, "%s: %v", requestapi.WarningAdminClientCa, caErr)
+		}
+		if !requestapi.ApiTlsEnabled(apiConfig) {
+			v.addWarning(confpkg.ApiName, requestapi.WarningAdminClientCaNoTls)
+		}
+	}
+}
+
+// Validate optional balance monitoring parameters
+func (v *Validation) validateBalance(conf []byte) {
+	if threshold, set := v.validateInt(conf, confpkg.BalanceName, confpkg.BalanceAlertThresholdName); set && threshold < 0 {
+		v.addWarning(confpkg.BalanceName, "%s (%d)", attestation.WarningInvalidBalanceAlertThresholdArg, threshold)
+	}
+}
+
+// Validate optional review window parameters
+func (v *Validation) validateReview(conf []byte) {
+	if window, set := v.validateInt(conf, confpkg.ReviewName, confpkg.ReviewWindowMinutesName); set && window <= 0 {
+		v.addWarning(confpkg.ReviewName, "%s (%d)", attestation.WarningInvalidReviewWindowArg, window)
+	}
+}
+
+// Validate optional confirmation quorum parameters
+func (v *Validation) validateQuorum(conf []byte) {
+	quorumConfig, quorumErr := confpkg.GetQuorumConfig(conf)
+	if quorumErr != nil {
+		v.addError(confpkg.QuorumName, "%v", quorumErr)
+		return
+	}
+	for _, client := range quorumConfig.Clients {
+		client.Shutdown()
+	}
+	if threshold, set := v.validateInt(conf, confpkg.QuorumName, confpkg.QuorumThresholdName); set &&
+		(threshold < 1 || threshold > len(quorumConfig.Nodes)+1) {
+		v.addWarning(confpkg.QuorumName, "%s (%d)", attestation.WarningInvalidQuorumThresholdArg, threshold)
+	}
+}
+
+// Validate optional webhook parameters
+func (v *Validation) validateWebhook(conf []byte) {
+	webhookConfig := confpkg.GetWebhookConfig(conf)
+	for _, webhookUrl := range webhookConfig.Urls {
+		if urlErr := notify.ValidateUrl(webhookUrl); urlErr != nil {
+			v.addWarning(confpkg.WebhookName, "%v", urlErr)
+		}
+	}
+	if len(webhookConfig.Urls) > 0 && webhookConfig.Secret == "" {
+		v.addWarning(confpkg.WebhookName, "%s", notify.WarningWebhookSecretNotSet)
+	}
+	if retries, set := v.validateInt(conf, confpkg.WebhookName, confpkg.WebhookRetriesName); set && retries < 0 {
+		v.addWarning(confpkg.WebhookName, "%s (%d)", notify.WarningInvalidWebhookRetriesArg, retries)
+	}
+}
+
+// Validate optional alert parameters
+func (v *Validation) validateAlert(conf []byte) {
+	alertConfig := confpkg.GetAlertConfig(conf)
+	if alertConfig.SlackUrl != "" {
+		if urlErr := notify.ValidateUrl(alertConfig.SlackUrl); urlErr != nil {
+			v.addWarning(confpkg.AlertName, "%v", urlErr)
+		}
+	}
+	if alertConfig.SmtpHost != "" {
+		if smtpErr := notify.ValidateSmtp(alertConfig); smtpErr != nil {
+			v.addWarning(confpkg.AlertName, "%v", smtpErr)
+		}
+	}
+	if dedup, set := v.validateInt(conf, confpkg.AlertName, confpkg.AlertDedupMinutesName); set && dedup < 0 {
+		v.addWarning(confpkg.AlertName, "%s (%d)", notify.WarningInvalidAlertDedupArg, dedup)
+	}
+}
+
+// Validate optional events parameters
+func (v *Validation) validateEvents(conf []byte) {
+	eventsConfig := confpkg.GetEventsConfig(conf)
+	if eventsConfig.RedisUrl != "" {
+		if urlErr := notify.ValidateRedisUrl(eventsConfig.RedisUrl); urlErr != nil {
+			v.addError(confpkg.EventsName, "%v", urlErr)
+		}
+	}
+}
+
+// Validate optional log parameters
+func (v *Validation) validateLog(conf []byte) {
+	logConfig := confpkg.GetLogConfig(conf)
+	if logConfig.Level != "" {
+		if _, levelErr := log.ParseLevel(logConfig.Level); levelErr != nil {
+			v.addWarning(confpkg.LogName, "%v", levelErr)
+		}
+	}
+	if logConfig.Format != "" && logConfig.Format != log.FormatText && logConfig.Format != log.FormatJson {
+		v.addWarning(confpkg.LogName, "%s: %s", log.ErrorFormatInvalid, logConfig.Format)
+	}
+}
+
+// Validate optional tracing parameters
+func (v *Validation) validateTracing(conf []byte) {
+	tracingConfig := confpkg.GetTracingConfig(conf)
+	if tracingConfig.Endpoint != "" {
+		if endpointErr := tracing.ValidateEndpoint(tracingConfig.Endpoint); endpointErr != nil {
+			v.addWarning(confpkg.TracingName, "%v", endpointErr)
+		}
+	}
+}
+
+// Validate optional daemon parameters
+func (v *Validation) validateDaemon(conf []byte) {
+	if pidFile := confpkg.GetDaemonConfig(conf).PidFile; pidFile != "" && !filepath.IsAbs(pidFile) {
+		v.addWarning(confpkg.DaemonName, "%s (%s)", daemon.WarningPidFileRelative, pidFile)
+	}
+}
+
+// Validate optional integer parameter and return value and whether it was set
+func (v *Validation) validateInt(conf []byte, category string, name string) (int, bool) {
+	valueStr := confpkg.TryGetParamFromConf(category, name, conf)
+	if valueStr == "" {
+		return 0, false
+	}
+	value, valueErr := strconv.Atoi(valueStr)
+	if valueErr != nil {
+		v.addWarning(category, "%s %s (%s)", WarningValidationInvalidInt, name, valueStr)
+		return 0, false
+	}
+	return value, true
+}
+
+// Validate connectivity to the main client rpc and the database
+func (v *Validation) validateConnectivity(ctx context.Context, conf []byte) {
+	mainClients, rpcErr := confpkg.GetRPCs(confpkg.MainChainName, conf)
+	if rpcErr != nil {
+		v.addError(confpkg.MainChainName, "%v", rpcErr)
+	}
+	for i, mainClient := range mainClients {
+		if _, countErr := mainClient.GetBlockCount(); countErr != nil {
+			if len(mainClients) > 1 {
+				v.addError(confpkg.MainChainName, "%s: node %d: %v", ErrorValidationRpcUnreachable, i, countErr)
+			} else {
+				v.addError(confpkg.MainChainName, "%s: %v", ErrorValidationRpcUnreachable, countErr)
+			}
+		}
+		mainClient.Shutdown()
+	}
+
+	if quorumConfig, quorumErr := confpkg.GetQuorumConfig(conf); quorumErr == nil {
+		for i, client := range quorumConfig.Clients {
+			if _, countErr := client.GetBlockCount(); countErr != nil {
+				v.addError(confpkg.QuorumName, "%s: %s: %v", ErrorValidationRpcUnreachable, quorumConfig.Nodes[i], countErr)
+			}
+			client.Shutdown()
+		}
+	}
+
+	dbConfig, dbErr := confpkg.GetDbConfig(conf)
+	if dbErr != nil {
+		return // already reported
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, ValidationConnectivityTimeout)
+	defer cancel()
+	if pingErr := db.PingDbMongo(dbCtx, dbConfig); pingErr != nil {
+		v.addError(confpkg.DbName, "%s: %v", ErrorValidationDbUnreachable, pingErr)
+	}
+}
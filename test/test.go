@@ -128,7 +128,7 @@ func NewTest(logOutput bool, isRegtest bool) *Test {
 // Work on main client for regtest
 // Do block generation automatically
 // Do auto commitment for position 0
-func DoRegtestWork(dbMongo *db.DbMongo, config *confpkg.Config, wg *sync.WaitGroup, ctx context.Context) {
+func DoRegtestWork(dbInterface db.Db, config *confpkg.Config, wg *sync.WaitGroup, ctx context.Context) {
 	defer wg.Done()
 	doCommit := false
 	for {
@@ -151,7 +151,7 @@ func DoRegtestWork(dbMongo *db.DbMongo, config *confpkg.Config, wg *sync.WaitGro
 					Commitment:     *hash[0],
 					ClientPosition: 0}
 
-				saveErr := dbMongo.SaveClientCommitment(newClientCommitment)
+				saveErr := dbInterface.SaveClientCommitment(newClientCommitment)
 				if saveErr != nil {
 					log.Infoln(saveErr)
 				}
@@ -0,0 +1,16 @@
+/*
+Package timestamp provides exporting of confirmed attestation proofs as RFC
+3161 timestamp tokens.
+
+Each token is a CMS SignedData structure over a TSTInfo that timestamps the
+client commitment, as a sha256 message imprint, at the time of the block
+confirming the attestation. The proof bundle of the commitment is carried in
+a non-critical TSTInfo extension, so that the anchoring of the timestamp in
+the staychain can be verified with the proof bundle tooling independently of
+the timestamp authority.
+
+Tokens are signed with the configured timestamp authority key and certificate
+chain or, if none are configured, with a key and self-signed certificate
+generated on startup.
+*/
+package timestamp
@@ -0,0 +1,490 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package timestamp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/models"
+)
+
+// timestamp consts
+const (
+	DefaultTsaPolicy   = "1.3.6.1.4.1.0.3161.1" // placeholder policy of self-signed tokens
+	SelfSignedTsaName  = "Mainstay Timestamp Authority"
+	SelfSignedValidity = 10 * 365 * 24 * time.Hour
+
+	ErrorTsaKey         = "Invalid timestamp authority key"
+	ErrorTsaCert        = "Invalid timestamp authority certificate"
+	ErrorTsaKeyMismatch = "Timestamp authority key does not match certificate"
+	ErrorTsaFiles       = "Timestamp authority key and certificate files both required"
+	ErrorTsaPolicy      = "Invalid timestamp authority policy"
+	ErrorTokenPending   = "Proof not confirmed - timestamp unavailable"
+	ErrorTokenInvalid   = "Invalid timestamp token"
+	ErrorTokenSignature = "Invalid timestamp token signature"
+	ErrorTokenDigest    = "Timestamp token message digest mismatch"
+)
+
+// object identifiers
+var (
+	oidSignedData            = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidTSTInfo               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidContentType           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningCertificateV2  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	oidSHA256                = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidECDSAWithSHA256       = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidSHA256WithRSA         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidMainstayProofBundle   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 0, 3161, 2}
+	oidExtKeyUsage           = asn1.ObjectIdentifier{2, 5, 29, 37}
+	oidExtKeyUsageTimeStamps = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 8}
+)
+
+// RFC 3161 TSTInfo
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time        `asn1:"generalized"`
+	Extensions     []pkix.Extension `asn1:"optional,tag:1"`
+}
+
+// RFC 5652 cms signed data
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue // explicit [0] content
+}
+
+type encapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     []byte `asn1:"explicit,tag:0"`
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+type signerInfo struct {
+	Version            int
+	Sid                issuerAndSerialNumber
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapsulatedContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+// RFC 5035 signing certificate v2 with the default sha256 hash algorithm
+type essCertIdV2 struct {
+	CertHash []byte
+}
+
+type signingCertificateV2 struct {
+	Certs []essCertIdV2
+}
+
+// RFC 3161 TimeStampResp
+type pkiStatusInfo struct {
+	Status int
+}
+
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+// Tsa struct
+// Timestamp authority signing RFC 3161 timestamp tokens of confirmed proofs
+// with the configured key and certificate chain, or a self-signed certificate
+type Tsa struct {
+	signer crypto.Signer
+	certs  []*x509.Certificate
+	policy asn1.ObjectIdentifier
+}
+
+// Return new Tsa from config, generating a self-signed
+// certificate if no key and certificate are configured
+func NewTsa(config confpkg.TsaConfig) (*Tsa, error) {
+	if validateErr := ValidateTsaConfig(config); validateErr != nil {
+		return nil, validateErr
+	}
+	if config.Policy == "" {
+		config.Policy = DefaultTsaPolicy
+	}
+	policy, policyErr := ParsePolicy(config.Policy)
+	if policyErr != nil {
+		return nil, policyErr
+	}
+	if config.KeyFile == "" {
+		return newSelfSignedTsa(policy)
+	}
+
+	keyPem, keyErr := ioutil.ReadFile(config.KeyFile)
+	if keyErr != nil {
+		return nil, keyErr
+	}
+	signer, signerErr := parsePrivateKey(keyPem)
+	if signerErr != nil {
+		return nil, signerErr
+	}
+	certPem, certErr := ioutil.ReadFile(config.CertFile)
+	if certErr != nil {
+		return nil, certErr
+	}
+	var certs []*x509.Certificate
+	for block, rest := pem.Decode(certPem); block != nil; block, rest = pem.Decode(rest) {
+		cert, parseErr := x509.ParseCertificate(block.Bytes)
+		if parseErr != nil {
+			return nil, errors.New(fmt.Sprintf("%s: %v", ErrorTsaCert, parseErr))
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New(ErrorTsaCert)
+	}
+	if !publicKeyEqual(signer.Public(), certs[0].PublicKey) {
+		return nil, errors.New(ErrorTsaKeyMismatch)
+	}
+	return &Tsa{signer: signer, certs: certs, policy: policy}, nil
+}
+
+// Validate tsa config without reading the key and certificate files
+func ValidateTsaConfig(config confpkg.TsaConfig) error {
+	if (config.KeyFile == "") != (config.CertFile == "") {
+		return errors.New(ErrorTsaFiles)
+	}
+	if config.Policy != "" {
+		if _, policyErr := ParsePolicy(config.Policy); policyErr != nil {
+			return policyErr
+		}
+	}
+	return nil
+}
+
+// Return Tsa with a new key and self-signed timestamping certificate
+func newSelfSignedTsa(policy asn1.ObjectIdentifier) (*Tsa, error) {
+	key, keyErr := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if keyErr != nil {
+		return nil, keyErr
+	}
+	serial, serialErr := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if serialErr != nil {
+		return nil, serialErr
+	}
+	// RFC 3161 requires the timestamping extended key usage to be critical
+	extKeyUsage, extKeyUsageErr := asn1.Marshal([]asn1.ObjectIdentifier{oidExtKeyUsageTimeStamps})
+	if extKeyUsageErr != nil {
+		return nil, extKeyUsageErr
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: SelfSignedTsaName},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(SelfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		ExtraExtensions: []pkix.Extension{
+			{Id: oidExtKeyUsage, Critical: true, Value: extKeyUsage},
+		},
+	}
+	certDer, certErr := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if certErr != nil {
+		return nil, certErr
+	}
+	cert, parseErr := x509.ParseCertificate(certDer)
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	return &Tsa{signer: key, certs: []*x509.Certificate{cert}, policy: policy}, nil
+}
+
+// Parse dotted policy object identifier
+func ParsePolicy(policy string) (asn1.ObjectIdentifier, error) {
+	var oid asn1.ObjectIdentifier
+	for _, arc := range strings.Split(policy, ".") {
+		arcInt, arcErr := strconv.Atoi(arc)
+		if arcErr != nil || arcInt < 0 {
+			return nil, errors.New(fmt.Sprintf("%s: %s", ErrorTsaPolicy, policy))
+		}
+		oid = append(oid, arcInt)
+	}
+	if len(oid) < 2 || oid[0] > 2 {
+		return nil, errors.New(fmt.Sprintf("%s: %s", ErrorTsaPolicy, policy))
+	}
+	return oid, nil
+}
+
+// Parse pem encoded PKCS #8, EC or PKCS #1 private key
+func parsePrivateKey(keyPem []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(keyPem)
+	if block == nil {
+		return nil, errors.New(ErrorTsaKey)
+	}
+	if key, keyErr := x509.ParsePKCS8PrivateKey(block.Bytes); keyErr == nil {
+		switch signer := key.(type) {
+		case *ecdsa.PrivateKey:
+			return signer, nil
+		case *rsa.PrivateKey:
+			return signer, nil
+		}
+		return nil, errors.New(ErrorTsaKey)
+	}
+	if key, keyErr := x509.ParseECPrivateKey(block.Bytes); keyErr == nil {
+		return key, nil
+	}
+	if key, keyErr := x509.ParsePKCS1PrivateKey(block.Bytes); keyErr == nil {
+		return key, nil
+	}
+	return nil, errors.New(ErrorTsaKey)
+}
+
+// Return whether the public keys are equal
+func publicKeyEqual(a crypto.PublicKey, b crypto.PublicKey) bool {
+	equaler, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && equaler.Equal(b)
+}
+
+// Return signature algorithm of the signer
+func (t *Tsa) signatureAlgorithm() pkix.AlgorithmIdentifier {
+	if _, ok := t.signer.(*rsa.PrivateKey); ok {
+		return pkix.AlgorithmIdentifier{Algorithm: oidSHA256WithRSA, Parameters: asn1.NullRawValue}
+	}
+	return pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256}
+}
+
+// Return certificate of the timestamp authority
+func (t *Tsa) Certificate() *x509.Certificate {
+	return t.certs[0]
+}
+
+// Return RFC 3161 TimeStampResp with the timestamp token of a confirmed proof
+//
+// The token timestamps the commitment of the proof, as the sha256 message
+// imprint, at the time of the block confirming the attestation, and carries
+// the proof bundle in a non-critical TSTInfo extension so that the anchoring
+// of the timestamp in the staychain can be verified independently of the TSA
+func (t *Tsa) TimestampResponse(bundle models.ProofBundle) ([]byte, error) {
+	token, tokenErr := t.Token(bundle)
+	if tokenErr != nil {
+		return nil, tokenErr
+	}
+	return asn1.Marshal(timeStampResp{
+		Status:         pkiStatusInfo{Status: 0}, // granted
+		TimeStampToken: asn1.RawValue{FullBytes: token},
+	})
+}
+
+// Return RFC 3161 timestamp token of a confirmed proof
+func (t *Tsa) Token(bundle models.ProofBundle) ([]byte, error) {
+	if bundle.Block == nil {
+		return nil, errors.New(ErrorTokenPending)
+	}
+	commitment, commitmentErr := hex.DecodeString(bundle.Commitment)
+	if commitmentErr != nil || len(commitment) != sha256.Size {
+		return nil, errors.New(fmt.Sprintf("%s: %s", models.ErrorProofBundleHash, bundle.Commitment))
+	}
+	bundleJson, bundleErr := json.Marshal(bundle)
+	if bundleErr != nil {
+		return nil, bundleErr
+	}
+	serial := sha256.Sum256([]byte(fmt.Sprintf("%s:%d:%s", bundle.Txid, bundle.Slot, bundle.Commitment)))
+
+	info, infoErr := asn1.Marshal(tstInfo{
+		Version: 1,
+		Policy:  t.policy,
+		MessageImprint: messageImprint{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+			HashedMessage: commitment,
+		},
+		SerialNumber: new(big.Int).SetBytes(serial[:16]),
+		GenTime:      time.Unix(bundle.Block.Time, 0).UTC(),
+		Extensions:   []pkix.Extension{{Id: oidMainstayProofBundle, Value: bundleJson}},
+	})
+	if infoErr != nil {
+		return nil, infoErr
+	}
+	return t.sign(info)
+}
+
+// Return cms signed data content info of the TSTInfo signed by the tsa
+func (t *Tsa) sign(info []byte) ([]byte, error) {
+	digest := sha256.Sum256(info)
+	certHash := sha256.Sum256(t.certs[0].Raw)
+	signingCert, signingCertErr := asn1.Marshal(signingCertificateV2{Certs: []essCertIdV2{{CertHash: certHash[:]}}})
+	if signingCertErr != nil {
+		return nil, signingCertErr
+	}
+	contentType, _ := asn1.Marshal(oidTSTInfo)
+	messageDigest, _ := asn1.Marshal(digest[:])
+
+	// signed attributes are DER encoded as a SET OF, sorted by encoding
+	var attrs [][]byte
+	for _, attr := range []attribute{
+		{oidContentType, []asn1.RawValue{{FullBytes: contentType}}},
+		{oidMessageDigest, []asn1.RawValue{{FullBytes: messageDigest}}},
+		{oidSigningCertificateV2, []asn1.RawValue{{FullBytes: signingCert}}},
+	} {
+		attrBytes, attrErr := asn1.Marshal(attr)
+		if attrErr != nil {
+			return nil, attrErr
+		}
+		attrs = append(attrs, attrBytes)
+	}
+	sort.Slice(attrs, func(i, j int) bool { return bytes.Compare(attrs[i], attrs[j]) < 0 })
+	attrsBytes := bytes.Join(attrs, nil)
+	attrsSet, attrsSetErr := asn1.Marshal(asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: attrsBytes})
+	if attrsSetErr != nil {
+		return nil, attrsSetErr
+	}
+	attrsDigest := sha256.Sum256(attrsSet)
+	signature, signErr := t.signer.Sign(rand.Reader, attrsDigest[:], crypto.SHA256)
+	if signErr != nil {
+		return nil, signErr
+	}
+
+	var certsBytes []byte
+	for _, cert := range t.certs {
+		certsBytes = append(certsBytes, cert.Raw...)
+	}
+	sha256Id := pkix.AlgorithmIdentifier{Algorithm: oidSHA256}
+	signed, signedErr := asn1.Marshal(signedData{
+		Version:          3,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{sha256Id},
+		EncapContentInfo: encapsulatedContentInfo{EContentType: oidTSTInfo, EContent: info},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certsBytes},
+		SignerInfos: []signerInfo{{
+			Version: 1,
+			Sid: issuerAndSerialNumber{
+				Issuer:       asn1.RawValue{FullBytes: t.certs[0].RawIssuer},
+				SerialNumber: t.certs[0].SerialNumber,
+			},
+			DigestAlgorithm:    sha256Id,
+			SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: attrsBytes},
+			SignatureAlgorithm: t.signatureAlgorithm(),
+			Signature:          signature,
+		}},
+	})
+	if signedErr != nil {
+		return nil, signedErr
+	}
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signed},
+	})
+}
+
+// Verify the signature of a timestamp token against the certificate
+// of its signer and return the commitment, time and proof bundle timestamped
+// The certificate itself must be checked against trusted roots separately
+func VerifyToken(token []byte) (string, time.Time, models.ProofBundle, *x509.Certificate, error) {
+	var bundle models.ProofBundle
+	invalidErr := errors.New(ErrorTokenInvalid)
+
+	var content contentInfo
+	if _, contentErr := asn1.Unmarshal(token, &content); contentErr != nil || !content.ContentType.Equal(oidSignedData) ||
+		content.Content.Class != asn1.ClassContextSpecific || content.Content.Tag != 0 {
+		return "", time.Time{}, bundle, nil, invalidErr
+	}
+	var signed signedData
+	if _, signedErr := asn1.Unmarshal(content.Content.Bytes, &signed); signedErr != nil ||
+		!signed.EncapContentInfo.EContentType.Equal(oidTSTInfo) || len(signed.SignerInfos) != 1 {
+		return "", time.Time{}, bundle, nil, invalidErr
+	}
+	certs, certsErr := x509.ParseCertificates(signed.Certificates.Bytes)
+	if certsErr != nil || len(certs) == 0 {
+		return "", time.Time{}, bundle, nil, invalidErr
+	}
+	signer := signed.SignerInfos[0]
+	var cert *x509.Certificate
+	for _, c := range certs {
+		if bytes.Equal(c.RawIssuer, signer.Sid.Issuer.FullBytes) && c.SerialNumber.Cmp(signer.Sid.SerialNumber) == 0 {
+			cert = c
+		}
+	}
+	if cert == nil {
+		return "", time.Time{}, bundle, nil, invalidErr
+	}
+
+	// check the message digest attribute and the signature of the attributes
+	attrsSet, _ := asn1.Marshal(asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: signer.SignedAttrs.Bytes})
+	var attrs []attribute
+	if _, attrsErr := asn1.UnmarshalWithParams(attrsSet, &attrs, "set"); attrsErr != nil {
+		return "", time.Time{}, bundle, nil, invalidErr
+	}
+	digest := sha256.Sum256(signed.EncapContentInfo.EContent)
+	digestFound := false
+	for _, attr := range attrs {
+		var messageDigest []byte
+		if attr.Type.Equal(oidMessageDigest) && len(attr.Values) == 1 {
+			if _, digestErr := asn1.Unmarshal(attr.Values[0].FullBytes, &messageDigest); digestErr == nil {
+				digestFound = bytes.Equal(messageDigest, digest[:])
+			}
+		}
+	}
+	if !digestFound {
+		return "", time.Time{}, bundle, nil, errors.New(ErrorTokenDigest)
+	}
+	algorithm := x509.ECDSAWithSHA256
+	if signer.SignatureAlgorithm.Algorithm.Equal(oidSHA256WithRSA) {
+		algorithm = x509.SHA256WithRSA
+	}
+	if sigErr := cert.CheckSignature(algorithm, attrsSet, signer.Signature); sigErr != nil {
+		return "", time.Time{}, bundle, nil, errors.New(ErrorTokenSignature)
+	}
+
+	var info tstInfo
+	if _, infoErr := asn1.Unmarshal(signed.EncapContentInfo.EContent, &info); infoErr != nil {
+		return "", time.Time{}, bundle, nil, invalidErr
+	}
+	for _, ext := range info.Extensions {
+		if ext.Id.Equal(oidMainstayProofBundle) {
+			if bundleErr := json.Unmarshal(ext.Value, &bundle); bundleErr != nil {
+				return "", time.Time{}, bundle, nil, invalidErr
+			}
+		}
+	}
+	return hex.EncodeToString(info.MessageImprint.HashedMessage), info.GenTime, bundle, cert, nil
+}
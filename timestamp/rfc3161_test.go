@@ -0,0 +1,152 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package timestamp
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testCommitment = "2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+
+// Return confirmed proof bundle for tests
+func testBundle() models.ProofBundle {
+	return models.ProofBundle{
+		Version:    models.ProofBundleVersion,
+		Slot:       1,
+		Commitment: testCommitment,
+		Ops:        []models.ProofBundleOp{},
+		Root:       testCommitment,
+		Txid:       "4a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7",
+		Block:      &models.ProofBundleBlock{Hash: testCommitment, Time: 1542121293},
+		Params: models.ProofBundleParams{Protocol: models.ProofBundleProtocol, Hash: models.ProofBundleHash,
+			Encoding: models.ProofBundleEncoding, Ops: models.ProofOpsAppend},
+	}
+}
+
+// Test self-signed timestamp tokens of confirmed proofs
+func TestTsaSelfSigned(t *testing.T) {
+	tsa, tsaErr := NewTsa(confpkg.TsaConfig{})
+	assert.Equal(t, nil, tsaErr)
+	assert.Equal(t, SelfSignedTsaName, tsa.Certificate().Subject.CommonName)
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping}, tsa.Certificate().ExtKeyUsage)
+
+	bundle := testBundle()
+	token, tokenErr := tsa.Token(bundle)
+	assert.Equal(t, nil, tokenErr)
+	commitment, genTime, tokenBundle, cert, verifyErr := VerifyToken(token)
+	assert.Equal(t, nil, verifyErr)
+	assert.Equal(t, testCommitment, commitment)
+	assert.Equal(t, time.Unix(1542121293, 0).UTC(), genTime)
+	assert.Equal(t, bundle, tokenBundle)
+	assert.Equal(t, tsa.Certificate().Raw, cert.Raw)
+
+	// token serial and contents deterministic for the proof
+	var content contentInfo
+	var signed signedData
+	var info tstInfo
+	asn1.Unmarshal(token, &content)
+	asn1.Unmarshal(content.Content.Bytes, &signed)
+	asn1.Unmarshal(signed.EncapContentInfo.EContent, &info)
+	assert.Equal(t, 1, info.Version)
+	assert.Equal(t, true, info.Policy.Equal(asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 0, 3161, 1}))
+	assert.Equal(t, true, info.MessageImprint.HashAlgorithm.Algorithm.Equal(oidSHA256))
+	otherToken, _ := tsa.Token(bundle)
+	asn1.Unmarshal(otherToken, &content)
+	asn1.Unmarshal(content.Content.Bytes, &signed)
+	var otherInfo tstInfo
+	asn1.Unmarshal(signed.EncapContentInfo.EContent, &otherInfo)
+	assert.Equal(t, info.SerialNumber, otherInfo.SerialNumber)
+	assert.Equal(t, 1, info.SerialNumber.Sign())
+
+	// tampered token content
+	signed.EncapContentInfo.EContent[len(signed.EncapContentInfo.EContent)-1] ^= 1
+	tamperedSigned, _ := asn1.Marshal(signed)
+	tampered, _ := asn1.Marshal(contentInfo{ContentType: oidSignedData, Content: asn1.RawValue{
+		Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: tamperedSigned}})
+	_, _, _, _, verifyErr = VerifyToken(tampered)
+	assert.Equal(t, ErrorTokenDigest, verifyErr.Error())
+	_, _, _, _, verifyErr = VerifyToken([]byte{0x30, 0x00})
+	assert.Equal(t, ErrorTokenInvalid, verifyErr.Error())
+
+	// timestamp response granted with the token
+	response, responseErr := tsa.TimestampResponse(bundle)
+	assert.Equal(t, nil, responseErr)
+	var resp timeStampResp
+	_, respErr := asn1.Unmarshal(response, &resp)
+	assert.Equal(t, nil, respErr)
+	assert.Equal(t, 0, resp.Status.Status)
+	_, _, _, _, verifyErr = VerifyToken(resp.TimeStampToken.FullBytes)
+	assert.Equal(t, nil, verifyErr)
+
+	// unconfirmed and invalid proofs
+	bundle.Block = nil
+	_, tokenErr = tsa.Token(bundle)
+	assert.Equal(t, ErrorTokenPending, tokenErr.Error())
+	bundle = testBundle()
+	bundle.Commitment = "xyz"
+	_, tokenErr = tsa.Token(bundle)
+	assert.Equal(t, models.ErrorProofBundleHash+": xyz", tokenErr.Error())
+}
+
+// Test timestamp tokens signed with configured key and certificate
+func TestTsaConfigured(t *testing.T) {
+	dir := t.TempDir()
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(3161),
+		Subject:      pkix.Name{CommonName: "Test TSA"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}
+	certDer, _ := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	keyFile := filepath.Join(dir, "tsa.key")
+	certFile := filepath.Join(dir, "tsa.pem")
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600)
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDer}), 0600)
+
+	tsa, tsaErr := NewTsa(confpkg.TsaConfig{KeyFile: keyFile, CertFile: certFile, Policy: "1.2.3.4.1"})
+	assert.Equal(t, nil, tsaErr)
+	token, tokenErr := tsa.Token(testBundle())
+	assert.Equal(t, nil, tokenErr)
+	commitment, _, _, cert, verifyErr := VerifyToken(token)
+	assert.Equal(t, nil, verifyErr)
+	assert.Equal(t, testCommitment, commitment)
+	assert.Equal(t, "Test TSA", cert.Subject.CommonName)
+
+	// key not matching certificate
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	otherKeyFile := filepath.Join(dir, "other.key")
+	ioutil.WriteFile(otherKeyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(otherKey)}), 0600)
+	_, tsaErr = NewTsa(confpkg.TsaConfig{KeyFile: otherKeyFile, CertFile: certFile})
+	assert.Equal(t, ErrorTsaKeyMismatch, tsaErr.Error())
+
+	// invalid config
+	_, tsaErr = NewTsa(confpkg.TsaConfig{KeyFile: keyFile})
+	assert.Equal(t, ErrorTsaFiles, tsaErr.Error())
+	_, tsaErr = NewTsa(confpkg.TsaConfig{KeyFile: certFile, CertFile: certFile})
+	assert.Equal(t, ErrorTsaKey, tsaErr.Error())
+	_, tsaErr = NewTsa(confpkg.TsaConfig{Policy: "1.x.3"})
+	assert.Equal(t, ErrorTsaPolicy+": 1.x.3", tsaErr.Error())
+	_, tsaErr = NewTsa(confpkg.TsaConfig{Policy: "3.1"})
+	assert.Equal(t, ErrorTsaPolicy+": 3.1", tsaErr.Error())
+}
@@ -0,0 +1,7 @@
+/*
+Package tracing provides OpenTelemetry tracing of the attestation cycle
+
+Spans are exported to an OTLP http endpoint, e.g. a Jaeger collector,
+if a tracing endpoint is configured, and are not recorded otherwise.
+*/
+package tracing
@@ -0,0 +1,124 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+
+	confpkg "mainstay/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracing consts
+const (
+	DefaultServiceName = "mainstay"
+	TracerName         = "mainstay"
+
+	ErrorEndpointInvalid = "Invalid tracing endpoint"
+)
+
+// span attribute keys
+const (
+	AttrState      = "attestation.state"
+	AttrNextState  = "attestation.next_state"
+	AttrTxid       = "attestation.txid"
+	AttrCommitment = "attestation.commitment"
+)
+
+// Check that the tracing endpoint is an http or https url
+func ValidateEndpoint(endpoint string) error {
+	endpointUrl, urlErr := url.Parse(endpoint)
+	if urlErr != nil || endpointUrl.Host == "" ||
+		(endpointUrl.Scheme != "http" && endpointUrl.Scheme != "https") {
+		return errors.New(fmt.Sprintf("%s: %s", ErrorEndpointInvalid, endpoint))
+	}
+	return nil
+}
+
+// Set global tracer provider exporting spans to the tracing config endpoint
+// Spans are not recorded if no endpoint is set
+// Returns function flushing remaining spans and stopping the exporter
+func Init(tracingConfig confpkg.TracingConfig) (func(context.Context) error, error) {
+	if tracingConfig.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	if endpointErr := ValidateEndpoint(tracingConfig.Endpoint); endpointErr != nil {
+		return nil, endpointErr
+	}
+
+	exporter, exporterErr := otlptracehttp.New(context.Background(),
+		otlptracehttp.WithEndpointURL(tracingConfig.Endpoint))
+	if exporterErr != nil {
+		return nil, exporterErr
+	}
+
+	serviceName := tracingConfig.ServiceName
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start span as a child of the span in context
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(TracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End span and record error if there is one
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Scope struct
+// Holds the context of the current span of a long running process so
+// that calls made through interfaces without a context argument, like
+// db and signer calls, can start spans as children of this span
+type Scope struct {
+	mu  sync.Mutex
+	ctx context.Context
+}
+
+// Return new Scope instance without a current span
+func NewScope() *Scope {
+	return &Scope{ctx: context.Background()}
+}
+
+// Get context of the current span
+func (s *Scope) Context() context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ctx
+}
+
+// Set context of the current span
+func (s *Scope) Set(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ctx = ctx
+}
+
+// Start span as a child of the current span
+func (s *Scope) Start(name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Start(s.Context(), name, attrs...)
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	confpkg "mainstay/config"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Test tracing endpoint validation and disabled tracing
+func TestInit(t *testing.T) {
+	assert.Equal(t, nil, ValidateEndpoint("http://localhost:4318"))
+	assert.Equal(t, nil, ValidateEndpoint("https://collector.example.com/v1/traces"))
+	assert.Equal(t, ErrorEndpointInvalid+": localhost:4318", ValidateEndpoint("localhost:4318").Error())
+	assert.Equal(t, ErrorEndpointInvalid+": http://", ValidateEndpoint("http://").Error())
+
+	shutdown, initErr := Init(confpkg.TracingConfig{})
+	assert.Equal(t, nil, initErr)
+	assert.Equal(t, nil, shutdown(context.Background()))
+
+	_, initErr = Init(confpkg.TracingConfig{Endpoint: "localhost:4318"})
+	assert.Equal(t, ErrorEndpointInvalid+": localhost:4318", initErr.Error())
+}
+
+// Test spans started through scope are children of the scope span
+func TestScope(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	scope := NewScope()
+	_, orphan := scope.Start("orphan")
+	End(orphan, nil)
+
+	ctx, parent := Start(context.Background(), "parent")
+	scope.Set(ctx)
+	_, child := scope.Start("child")
+	End(child, errors.New("child failure"))
+	End(parent, nil)
+
+	ended := recorder.Ended()
+	assert.Equal(t, 3, len(ended))
+	assert.Equal(t, false, ended[0].Parent().IsValid())
+	assert.Equal(t, ended[2].SpanContext().SpanID(), ended[1].Parent().SpanID())
+	assert.Equal(t, codes.Error, ended[1].Status().Code)
+	assert.Equal(t, "child failure", ended[1].Status().Description)
+	assert.Equal(t, 1, len(ended[1].Events()))
+	assert.Equal(t, codes.Unset, ended[2].Status().Code)
+}